@@ -2,6 +2,8 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
@@ -9,18 +11,36 @@ import (
 	"strings"
 	"time"
 
+	"github.com/yourusername/bgengine/internal/fileutil"
 	"github.com/yourusername/bgengine/internal/positionid"
+	"github.com/yourusername/bgengine/pkg/api"
+	"github.com/yourusername/bgengine/pkg/compare"
 	"github.com/yourusername/bgengine/pkg/engine"
+	"github.com/yourusername/bgengine/pkg/match"
 )
 
 func main() {
-	if len(os.Args) < 2 {
+	global := flag.NewFlagSet("bgengine", flag.ExitOnError)
+	global.Usage = printUsage
+	global.StringVar(&serverURL, "server", "", "Send eval, move, cube and rollout to this bgserver (e.g. http://host:8080)")
+	global.DurationVar(&serverTimeout, "timeout", 0, "Time limit of a command with -server (0 = 30s, 30m for rollouts)")
+	global.Parse(os.Args[1:])
+	if global.NArg() < 1 {
 		printUsage()
 		os.Exit(1)
 	}
 
-	command := os.Args[1]
-	args := os.Args[2:]
+	command := global.Arg(0)
+	args := global.Args()[1:]
+
+	switch command {
+	case "eval", "move", "cube", "rollout", "help":
+	default:
+		if serverURL != "" {
+			fmt.Fprintf(os.Stderr, "Error: -server does not support the %s command\n", command)
+			os.Exit(1)
+		}
+	}
 
 	switch command {
 	case "eval":
@@ -29,8 +49,22 @@ func main() {
 		cmdMove(args)
 	case "cube":
 		cmdCube(args)
+	case "action":
+		cmdAction(args)
 	case "rollout":
 		cmdRollout(args)
+	case "analyze":
+		cmdAnalyze(args)
+	case "inspect":
+		cmdInspect(args)
+	case "met":
+		cmdMET(args)
+	case "checkdata":
+		cmdCheckData(args)
+	case "compare":
+		cmdCompare(args)
+	case "buildbook":
+		cmdBuildBook(args)
 	case "help", "-h", "--help":
 		printUsage()
 	default:
@@ -43,28 +77,46 @@ func main() {
 func printUsage() {
 	fmt.Println(`bgengine - Backgammon Analysis Engine
 
-Usage: bgengine <command> [options]
+Usage: bgengine [-server URL [-timeout D]] <command> [options]
 
 Commands:
   eval      Evaluate a position
   move      Find the best move for a dice roll
   cube      Analyze cube decisions
+  action    Cube action before the roll, or (with dice) the best moves
   rollout   Monte Carlo rollout
+  analyze   Evaluation, pips, cube and (with dice) moves in one report
+  inspect   Show the evaluator, raw net output and (with -verbose) net inputs
+  met       Show the match equity table, or the cube points at one score
+  checkdata Check the weights, bearoff databases and match equity tables
+  compare   Compare evaluations and best moves with GNU Backgammon
+  buildbook Evaluate the positions near the start once, for EngineOptions.BookFile
 
 Use "bgengine <command> -h" for command-specific help.
 
+Global Options:
+  -server URL  Run eval, move, cube and rollout on a bgserver instead of
+               loading the engine here, e.g. -server http://host:8080
+  -timeout D   Time limit of a command with -server (default 30s, 30m for
+               rollouts)
+
 Position ID Format:
   The position is specified using gnubg's position ID format.
   Example: "4HPwATDgc/ABMA:cIkqAAAAAAAA" (position:match)
-  Only the position part (before :) is required.`)
+  Only the position part (before :) is required.
+  Snowie text positions (semicolon-separated fields) are also accepted and
+  carry their own score, cube and dice, e.g.
+  "0;0;0;0;0;A;B;0;0;0;1;0;0;-2;0;0;0;0;5;0;3;0;0;0;-5;5;0;0;0;-3;0;-5;0;0;0;0;2;0;3;1;"`)
 }
 
 func parsePosition(posStr string) (*engine.GameState, error) {
-	// Handle gnubg format "positionID:matchID" - we only need the position part
-	if idx := strings.Index(posStr, ":"); idx >= 0 {
-		posStr = posStr[:idx]
+	if match.IsSnowieText(posStr) {
+		return match.ParseSnowieText(posStr)
 	}
 
+	// Handle gnubg format "positionID:matchID" - we only need the position part
+	posStr, _ = positionid.SplitGnubgID(posStr)
+
 	board, err := positionid.BoardFromPositionID(posStr)
 	if err != nil {
 		return nil, fmt.Errorf("invalid position ID: %w", err)
@@ -108,6 +160,7 @@ func cmdEval(args []string) {
 	fs := flag.NewFlagSet("eval", flag.ExitOnError)
 	posFlag := fs.String("position", "", "Position ID (gnubg format)")
 	posShort := fs.String("p", "", "Position ID (short form)")
+	opponent := fs.Bool("opponent", false, "Evaluate for the opponent, with the opponent on roll")
 	fs.Parse(args)
 
 	pos := *posFlag
@@ -116,7 +169,7 @@ func cmdEval(args []string) {
 	}
 	if pos == "" {
 		fmt.Fprintln(os.Stderr, "Error: position required")
-		fmt.Fprintln(os.Stderr, "Usage: bgengine eval -position <positionID>")
+		fmt.Fprintln(os.Stderr, "Usage: bgengine eval -position <positionID> [-opponent]")
 		os.Exit(1)
 	}
 
@@ -125,6 +178,32 @@ func cmdEval(args []string) {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
+	if *opponent {
+		fmt.Println("Opponent on roll")
+	}
+
+	if c := remoteClient(); c != nil {
+		position, format := remotePosition(pos)
+		ctx, cancel := remoteContext(defaultRemoteTimeout)
+		defer cancel()
+		ply := 0
+		resp, err := c.Evaluate(ctx, &api.EvaluateRequest{
+			Position:  position,
+			Format:    format,
+			CubeOwner: -1,
+			Ply:       &ply,
+			Opponent:  *opponent,
+		})
+		if err != nil {
+			exitRemote(err)
+		}
+		printEvaluation(resp)
+		return
+	}
+
+	if *opponent {
+		state = state.OpponentOnRoll()
+	}
 
 	e, err := createEngine()
 	if err != nil {
@@ -132,7 +211,7 @@ func cmdEval(args []string) {
 		os.Exit(1)
 	}
 
-	eval, err := e.Evaluate(state)
+	eval, err := api.EvaluateAtPly(e, state, 0, nil)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error evaluating position: %v\n", err)
 		os.Exit(1)
@@ -141,12 +220,12 @@ func cmdEval(args []string) {
 	printEvaluation(eval)
 }
 
-func printEvaluation(eval *engine.Evaluation) {
+func printEvaluation(eval *api.EvaluateResponse) {
 	fmt.Printf("Equity: %+.3f\n", eval.Equity)
 	fmt.Printf("  Win:    %.1f%% (G: %.1f%%, BG: %.1f%%)\n",
-		eval.WinProb*100, eval.WinG*100, eval.WinBG*100)
+		eval.Win, eval.WinG, eval.WinBG)
 	fmt.Printf("  Lose:   %.1f%% (G: %.1f%%, BG: %.1f%%)\n",
-		(1-eval.WinProb)*100, eval.LoseG*100, eval.LoseBG*100)
+		100-eval.Win, eval.LoseG, eval.LoseBG)
 }
 
 func cmdMove(args []string) {
@@ -156,6 +235,7 @@ func cmdMove(args []string) {
 	diceFlag := fs.String("dice", "", "Dice roll (e.g., 3,1 or 3-1)")
 	diceShort := fs.String("d", "", "Dice roll (short form)")
 	numMoves := fs.Int("n", 5, "Number of moves to show")
+	constraints := fs.String("constraints", "", "Only rank moves that satisfy these terms (e.g., !hit,make:5)")
 	fs.Parse(args)
 
 	pos := *posFlag
@@ -167,69 +247,117 @@ func cmdMove(args []string) {
 		dice = *diceShort
 	}
 
-	if pos == "" || dice == "" {
+	var state *engine.GameState
+	var err error
+	if pos != "" {
+		if state, err = parsePosition(pos); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// A Snowie text position may carry the roll
+	diceRoll := [2]int{}
+	if state != nil && dice == "" {
+		diceRoll = state.Dice
+	}
+	if state == nil || (dice == "" && diceRoll[0] == 0) {
 		fmt.Fprintln(os.Stderr, "Error: position and dice required")
 		fmt.Fprintln(os.Stderr, "Usage: bgengine move -position <positionID> -dice <roll>")
 		os.Exit(1)
 	}
 
-	state, err := parsePosition(pos)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+	if dice != "" {
+		if diceRoll, err = parseDice(dice); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
 	}
 
-	diceRoll, err := parseDice(dice)
+	constraint, err := engine.ParseMoveConstraint(*constraints)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 
+	ply := 0
+	req := &api.MoveRequest{
+		Dice:      diceRoll,
+		CubeOwner: -1,
+		NumMoves:  *numMoves,
+		Ply:       &ply,
+	}
+	if !constraint.IsZero() {
+		req.Constraints = []string{constraint.String()}
+	}
+
+	if c := remoteClient(); c != nil {
+		req.Position, req.Format = remotePosition(pos)
+		ctx, cancel := remoteContext(defaultRemoteTimeout)
+		defer cancel()
+		resp, err := c.Move(ctx, req)
+		if err != nil {
+			exitRemote(err)
+		}
+		printMoves(resp)
+		return
+	}
+
 	e, err := createEngine()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 
-	moves, err := e.RankMoves(state, diceRoll, *numMoves)
+	analysis, err := api.AnalyzeMoves(e, state, req, nil)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error analyzing moves: %v\n", err)
 		os.Exit(1)
 	}
+	var constrained *api.ConstraintResponse
+	if !constraint.IsZero() {
+		if analysis, constrained, err = api.ConstrainMoves(analysis, state.Board, constraint); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	resp := api.MovesToResponse(analysis, state.Board, pos, diceRoll, *numMoves)
+	resp.Constraint = constrained
+	printMoves(resp)
+}
 
-	if len(moves) == 0 {
+// printMoves prints the ranked moves of a move request and, with
+// constraints, what they give up against the best move overall.
+func printMoves(resp *api.MovesResponse) {
+	if len(resp.Moves) == 0 {
 		fmt.Println("No legal moves (forced to pass)")
 		return
 	}
 
-	fmt.Printf("Best moves for roll %d-%d:\n", diceRoll[0], diceRoll[1])
-	for i, m := range moves {
-		moveStr := formatMove(m.Move)
-		fmt.Printf("  %d. %-20s  Eq: %+.3f\n", i+1, moveStr, m.Equity)
+	if c := resp.Constraint; c != nil {
+		fmt.Printf("Best moves for roll %d-%d with %s (%d of %d legal):\n",
+			resp.Dice[0], resp.Dice[1], c.Constraints, c.NumAllowed, resp.NumLegal)
+	} else {
+		fmt.Printf("Best moves for roll %d-%d:\n", resp.Dice[0], resp.Dice[1])
 	}
-}
-
-func formatMove(m engine.Move) string {
-	var parts []string
-	for i := 0; i < 4; i++ {
-		if m.From[i] < 0 {
-			break
-		}
-		from := m.From[i] + 1 // Convert to 1-based
-		to := m.To[i] + 1
-		if m.To[i] < 0 {
-			parts = append(parts, fmt.Sprintf("%d/off", from))
-		} else {
-			parts = append(parts, fmt.Sprintf("%d/%d", from, to))
-		}
+	for i, m := range resp.Moves {
+		fmt.Printf("  %d. %-20s  Eq: %+.3f\n", i+1, m.Move, m.Equity)
+	}
+	if c := resp.Constraint; c != nil {
+		fmt.Printf("Unconstrained best: %s  Eq: %+.3f (costs %.3f)\n", c.Best.Move, c.Best.Equity, c.Cost)
 	}
-	return strings.Join(parts, " ")
 }
 
 func cmdCube(args []string) {
 	fs := flag.NewFlagSet("cube", flag.ExitOnError)
 	posFlag := fs.String("position", "", "Position ID (gnubg format)")
 	posShort := fs.String("p", "", "Position ID (short form)")
+	rollout := fs.Bool("rollout", false, "Decide the cube from a rollout instead of an evaluation")
+	trials := fs.Int("trials", 1296, "Number of games to simulate with -rollout")
+	workers := fs.Int("workers", 0, "Number of worker goroutines with -rollout (0 = auto)")
+	seed := fs.Int64("seed", 0, "Random seed with -rollout (0 = random)")
+	opponent := fs.Bool("opponent", false, "Analyze the opponent's cube decision, with the opponent on roll")
 	fs.Parse(args)
 
 	pos := *posFlag
@@ -238,7 +366,7 @@ func cmdCube(args []string) {
 	}
 	if pos == "" {
 		fmt.Fprintln(os.Stderr, "Error: position required")
-		fmt.Fprintln(os.Stderr, "Usage: bgengine cube -position <positionID>")
+		fmt.Fprintln(os.Stderr, "Usage: bgengine cube -position <positionID> [-opponent] [-rollout [-trials N] [-workers N] [-seed N]]")
 		os.Exit(1)
 	}
 
@@ -247,6 +375,57 @@ func cmdCube(args []string) {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
+	if *opponent {
+		fmt.Println("Opponent on roll")
+	}
+
+	if c := remoteClient(); c != nil {
+		position, format := remotePosition(pos)
+		if *rollout {
+			if *workers != 0 {
+				fmt.Fprintln(os.Stderr, "Error: -workers is not available with -server; the server sets its own")
+				os.Exit(1)
+			}
+			if *opponent {
+				fmt.Fprintln(os.Stderr, "Error: -opponent with -rollout is not available with -server")
+				os.Exit(1)
+			}
+			ctx, cancel := remoteContext(defaultRolloutTimeout)
+			defer cancel()
+			start := time.Now()
+			resp, err := c.CubeRollout(ctx, &api.RolloutRequest{
+				Position:  position,
+				Format:    format,
+				Trials:    *trials,
+				CubeOwner: -1,
+				Seed:      *seed,
+			})
+			if err != nil {
+				exitRemote(err)
+			}
+			noteClamped(resp.Rollout.Clamped, resp.Rollout.Trials)
+			printCubeRollout(resp, time.Since(start))
+			return
+		}
+
+		ctx, cancel := remoteContext(defaultRemoteTimeout)
+		defer cancel()
+		resp, err := c.Cube(ctx, &api.CubeRequest{
+			Position:  position,
+			Format:    format,
+			CubeOwner: -1,
+			Opponent:  *opponent,
+		})
+		if err != nil {
+			exitRemote(err)
+		}
+		printCube(resp)
+		return
+	}
+
+	if *opponent {
+		state = state.OpponentOnRoll()
+	}
 
 	e, err := createEngine()
 	if err != nil {
@@ -254,32 +433,151 @@ func cmdCube(args []string) {
 		os.Exit(1)
 	}
 
+	if *rollout {
+		start := time.Now()
+		result, err := e.RolloutCube(state, engine.RolloutOptions{Trials: *trials, Workers: *workers, Seed: *seed})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error during rollout: %v\n", err)
+			os.Exit(1)
+		}
+		printCubeRollout(api.CubeRolloutToResponse(result), time.Since(start))
+		return
+	}
+
 	analysis, err := e.AnalyzeCube(state)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error analyzing cube: %v\n", err)
 		os.Exit(1)
 	}
+	resp := api.CubeToResponse(analysis)
+	if resp.Race, err = api.RaceCube(e, state); err != nil {
+		fmt.Fprintf(os.Stderr, "Error analyzing race: %v\n", err)
+		os.Exit(1)
+	}
+	printCube(resp)
+}
 
-	decisionStr := ""
-	switch analysis.DecisionType {
-	case engine.DOUBLE_TAKE, engine.REDOUBLE_TAKE:
-		decisionStr = "Double, Take"
-	case engine.DOUBLE_PASS, engine.REDOUBLE_PASS:
-		decisionStr = "Double, Pass"
-	case engine.NODOUBLE_TAKE, engine.NODOUBLE_BEAVER:
-		decisionStr = "No Double"
-	case engine.TOOGOOD_TAKE, engine.TOOGOOD_PASS, engine.TOOGOODRE_TAKE, engine.TOOGOODRE_PASS:
-		decisionStr = "Too Good to Double"
-	case engine.NOT_AVAILABLE:
-		decisionStr = "Cube Not Available"
-	default:
-		decisionStr = "No Double"
+// printCube prints a cube decision and, in a race, where the lead stands.
+func printCube(resp *api.CubeResponse) {
+	fmt.Printf("Cube Decision: %s\n", resp.Decision)
+	fmt.Printf("  No double equity:  %+.3f\n", resp.NoDoubleEquity)
+	fmt.Printf("  Double/Take equity: %+.3f\n", resp.DoubleEquity)
+	fmt.Printf("  Double/Pass equity: %+.3f\n", resp.PassEquity)
+
+	race := resp.Race
+	if race == nil {
+		return
 	}
+	fmt.Printf("\n%s\n", race.Summary)
+	fmt.Printf("\n  Lead   Win%%  No double  Double/Take  Verdict\n")
+	for _, row := range race.Table {
+		fmt.Printf("  %+4d  %5.1f     %+.3f       %+.3f  %s\n",
+			row.Lead, row.Win, row.NoDouble, row.DoubleTake, row.Verdict)
+	}
+}
 
-	fmt.Printf("Cube Decision: %s\n", decisionStr)
-	fmt.Printf("  No double equity:  %+.3f\n", analysis.NoDoubleEquity)
-	fmt.Printf("  Double/Take equity: %+.3f\n", analysis.DoubleTakeEq)
-	fmt.Printf("  Double/Pass equity: %+.3f\n", analysis.DoublePassEq)
+// printCubeRollout prints the cube decision of "cube -rollout".
+func printCubeRollout(resp *api.CubeRolloutResponse, elapsed time.Duration) {
+	significance := func(significant bool) string {
+		if significant {
+			return "significant"
+		}
+		return "not significant"
+	}
+	cube, rollout := resp.Cube, resp.Rollout
+	fmt.Printf("Cube Decision: %s (rollout, %d trials, %.1fs)\n",
+		cube.Decision, rollout.Trials, elapsed.Seconds())
+	fmt.Printf("  No double equity:  %+.3f (95%% CI: ±%.3f)\n", cube.NoDoubleEquity, resp.NoDoubleCI)
+	fmt.Printf("  Double/Take equity: %+.3f (95%% CI: ±%.3f)\n", cube.DoubleEquity, resp.DoubleTakeCI)
+	fmt.Printf("  Double/Pass equity: %+.3f\n", cube.PassEquity)
+	fmt.Printf("  Double vs no double: %+.3f ± %.3f (%s)\n",
+		cube.DoubleDiff, resp.DoubleDiffCI, significance(resp.DoubleSignificant))
+	fmt.Printf("  Take vs pass:        %+.3f ± %.3f (%s)\n",
+		cube.PassEquity-cube.DoubleEquity, resp.DoubleTakeCI, significance(resp.TakeSignificant))
+	fmt.Printf("  Win:    %.1f%% (G: %.1f%%, BG: %.1f%%)\n",
+		rollout.Win, rollout.WinG, rollout.WinBG)
+}
+
+func cmdAction(args []string) {
+	fs := flag.NewFlagSet("action", flag.ExitOnError)
+	posFlag := fs.String("position", "", "Position ID (gnubg format)")
+	posShort := fs.String("p", "", "Position ID (short form)")
+	diceFlag := fs.String("dice", "", "Dice roll (e.g., 3,1 or 3-1); omit before the roll")
+	diceShort := fs.String("d", "", "Dice roll (short form)")
+	numMoves := fs.Int("n", 5, "Number of moves to show")
+	fs.Parse(args)
+
+	pos := *posFlag
+	if pos == "" {
+		pos = *posShort
+	}
+	dice := *diceFlag
+	if dice == "" {
+		dice = *diceShort
+	}
+	if pos == "" {
+		fmt.Fprintln(os.Stderr, "Error: position required")
+		fmt.Fprintln(os.Stderr, "Usage: bgengine action -position <positionID> [-dice <roll>] [-n N]")
+		os.Exit(1)
+	}
+
+	state, err := parsePosition(pos)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// A Snowie text position may carry the roll
+	var diceRoll *[2]int
+	if dice != "" {
+		roll, err := parseDice(dice)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		diceRoll = &roll
+	} else if state.Dice[0] != 0 {
+		diceRoll = &state.Dice
+	}
+
+	e, err := createEngine()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	action, err := e.BestAction(state, diceRoll)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error analyzing position: %v\n", err)
+		os.Exit(1)
+	}
+
+	cube := action.Cube
+	fmt.Printf("Cube Decision: %s (no double %+.3f, double/take %+.3f, double/pass %+.3f)\n",
+		api.CubeDecisionText(cube.DecisionType), cube.NoDoubleEquity, cube.DoubleTakeEq, cube.DoublePassEq)
+	if diceRoll == nil {
+		if action.Double {
+			fmt.Println("Best action: double")
+		} else {
+			fmt.Println("Best action: roll")
+		}
+		return
+	}
+	if action.MissedDouble {
+		fmt.Println("Missed double: the position was a double before the roll")
+	}
+
+	if len(action.Moves.Moves) == 0 {
+		fmt.Println("No legal moves (forced to pass)")
+		return
+	}
+	fmt.Printf("Best moves for roll %d-%d:\n", diceRoll[0], diceRoll[1])
+	for i, m := range action.Moves.Moves {
+		if i == *numMoves {
+			break
+		}
+		fmt.Printf("  %d. %-20s  Eq: %+.3f\n", i+1, engine.FormatMove(m.Move), m.Equity)
+	}
 }
 
 func cmdRollout(args []string) {
@@ -290,6 +588,7 @@ func cmdRollout(args []string) {
 	workers := fs.Int("workers", 0, "Number of worker goroutines (0 = auto)")
 	truncate := fs.Int("truncate", 0, "Truncate rollout at N plies (0 = play to end)")
 	seed := fs.Int64("seed", 0, "Random seed (0 = random)")
+	resume := fs.String("resume", "", "Rollout file to extend; created if missing and updated with the result")
 	fs.Parse(args)
 
 	pos := *posFlag
@@ -298,7 +597,7 @@ func cmdRollout(args []string) {
 	}
 	if pos == "" {
 		fmt.Fprintln(os.Stderr, "Error: position required")
-		fmt.Fprintln(os.Stderr, "Usage: bgengine rollout -position <positionID> [-trials N] [-workers N]")
+		fmt.Fprintln(os.Stderr, "Usage: bgengine rollout -position <positionID> [-trials N] [-workers N] [-resume file]")
 		os.Exit(1)
 	}
 
@@ -308,6 +607,31 @@ func cmdRollout(args []string) {
 		os.Exit(1)
 	}
 
+	if c := remoteClient(); c != nil {
+		if *workers != 0 || *resume != "" {
+			fmt.Fprintln(os.Stderr, "Error: -workers and -resume are not available with -server")
+			os.Exit(1)
+		}
+		position, format := remotePosition(pos)
+		ctx, cancel := remoteContext(defaultRolloutTimeout)
+		defer cancel()
+		start := time.Now()
+		resp, err := c.Rollout(ctx, &api.RolloutRequest{
+			Position:  position,
+			Format:    format,
+			Trials:    *trials,
+			Truncate:  *truncate,
+			CubeOwner: -1,
+			Seed:      *seed,
+		})
+		if err != nil {
+			exitRemote(err)
+		}
+		noteClamped(resp.Clamped, resp.Trials)
+		printRollout(resp, time.Since(start))
+		return
+	}
+
 	e, err := createEngine()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
@@ -321,8 +645,17 @@ func cmdRollout(args []string) {
 		Seed:     *seed,
 	}
 
+	var prior *engine.RolloutResult
+	posID := positionid.PositionID(positionid.Board(state.Board))
+	if *resume != "" {
+		if prior, err = loadRollout(*resume, posID); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
 	start := time.Now()
-	result, err := e.Rollout(state, opts)
+	result, err := e.RolloutExtend(state, opts, prior)
 	elapsed := time.Since(start)
 
 	if err != nil {
@@ -330,11 +663,428 @@ func cmdRollout(args []string) {
 		os.Exit(1)
 	}
 
-	fmt.Printf("Rollout (%d trials, %.1fs):\n", result.TrialsCompleted, elapsed.Seconds())
+	if *resume != "" {
+		if err := saveRollout(*resume, posID, result); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	resp := api.RolloutToResponse(result)
+	printRollout(&resp, elapsed)
+}
+
+func printRollout(resp *api.RolloutResponse, elapsed time.Duration) {
+	fmt.Printf("Rollout (%d trials, %.1fs):\n", resp.Trials, elapsed.Seconds())
 	fmt.Printf("  Equity: %+.3f ± %.3f (95%% CI: ±%.3f)\n",
-		result.Equity, result.EquityStdDev, result.EquityCI)
+		resp.Equity, resp.StdDev, resp.CI95)
 	fmt.Printf("  Win:    %.1f%% (G: %.1f%%, BG: %.1f%%)\n",
-		result.WinProb*100, result.WinG*100, result.WinBG*100)
+		resp.Win, resp.WinG, resp.WinBG)
 	fmt.Printf("  Lose:   %.1f%% (G: %.1f%%, BG: %.1f%%)\n",
-		(1-result.WinProb)*100, result.LoseG*100, result.LoseBG*100)
+		100-resp.Win, resp.LoseG, resp.LoseBG)
+	fmt.Printf("  Seed:   %d\n", resp.Seed)
+}
+
+// rolloutFile is the JSON file written by "rollout -resume", the payload of
+// a rolloutFileFormat envelope. Files from before the envelope hold it bare.
+type rolloutFile struct {
+	Position string                `json:"position"`
+	Result   *engine.RolloutResult `json:"result"`
+}
+
+var rolloutFileFormat = fileutil.Format{Name: "bgengine-rollout", Version: 1}
+
+// loadRollout reads a saved rollout of posID. A missing file means no prior
+// rollout, and so does a corrupt one, which is moved aside with a warning.
+func loadRollout(path, posID string) (*engine.RolloutResult, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading rollout file: %w", err)
+	}
+
+	var f rolloutFile
+	var bare struct {
+		Format *string `json:"format"`
+	}
+	if json.Unmarshal(data, &bare) == nil && bare.Format == nil {
+		if err = json.Unmarshal(data, &f); err == nil && f.Result == nil {
+			err = fmt.Errorf("%w: no result", fileutil.ErrCorrupt)
+		}
+	} else {
+		err = rolloutFileFormat.Unmarshal(data, &f)
+	}
+	if errors.Is(err, fileutil.ErrCorrupt) {
+		aside, qerr := fileutil.Quarantine(path)
+		if qerr != nil {
+			return nil, fmt.Errorf("rollout file %s is corrupt and cannot be moved aside: %w", path, qerr)
+		}
+		fmt.Fprintf(os.Stderr, "WARNING: rollout file %s is corrupt (%v); moved it to %s and starting over\n", path, err, aside)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid rollout file %s: %w", path, err)
+	}
+	if f.Position != posID {
+		return nil, fmt.Errorf("rollout file %s is for position %s, not %s", path, f.Position, posID)
+	}
+	return f.Result, nil
+}
+
+// saveRollout writes the rollout of posID to path.
+func saveRollout(path, posID string, result *engine.RolloutResult) error {
+	if err := rolloutFileFormat.WriteFile(path, rolloutFile{Position: posID, Result: result}); err != nil {
+		return fmt.Errorf("writing rollout file: %w", err)
+	}
+	return nil
+}
+
+func cmdAnalyze(args []string) {
+	fs := flag.NewFlagSet("analyze", flag.ExitOnError)
+	posFlag := fs.String("position", "", "Position ID (gnubg format)")
+	posShort := fs.String("p", "", "Position ID (short form)")
+	diceFlag := fs.String("dice", "", "Dice roll (e.g., 3,1 or 3-1); omit to skip move analysis")
+	diceShort := fs.String("d", "", "Dice roll (short form)")
+	ply := fs.Int("ply", 0, "Evaluation depth (0-2)")
+	numMoves := fs.Int("n", 5, "Number of moves to show")
+	jsonOut := fs.Bool("json", false, "Print the report as JSON")
+	consensus := fs.Bool("consensus", false, "With dice, report where the engine's sources disagree on the move")
+	fs.Parse(args)
+
+	pos := *posFlag
+	if pos == "" {
+		pos = *posShort
+	}
+	dice := *diceFlag
+	if dice == "" {
+		dice = *diceShort
+	}
+	if pos == "" {
+		fmt.Fprintln(os.Stderr, "Error: position required")
+		fmt.Fprintln(os.Stderr, "Usage: bgengine analyze -position <positionID> [-dice <roll>] [-ply N] [-consensus] [-json]")
+		os.Exit(1)
+	}
+
+	state, err := parsePosition(pos)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	opts := api.AnalyzeOptions{Ply: *ply, NumMoves: *numMoves, Dice: state.Dice, Consensus: *consensus}
+	if dice != "" {
+		// Accept "31" as well as "3,1" and "3-1"
+		if len(dice) == 2 {
+			dice = dice[:1] + "," + dice[1:]
+		}
+		if opts.Dice, err = parseDice(dice); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	e, err := createEngine()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// The report names the position without the match ID, like the REST API
+	posID := positionid.PositionID(positionid.Board(state.Board))
+	report, err := api.Analyze(e, posID, state, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error analyzing position: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(report)
+	} else {
+		err = api.WriteReport(os.Stdout, report)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func cmdInspect(args []string) {
+	fs := flag.NewFlagSet("inspect", flag.ExitOnError)
+	posFlag := fs.String("position", "", "Position ID (gnubg format)")
+	posShort := fs.String("p", "", "Position ID (short form)")
+	verbose := fs.Bool("verbose", false, "Show the net inputs")
+	fs.Parse(args)
+
+	pos := *posFlag
+	if pos == "" {
+		pos = *posShort
+	}
+	if pos == "" {
+		fmt.Fprintln(os.Stderr, "Error: position required")
+		fmt.Fprintln(os.Stderr, "Usage: bgengine inspect -position <positionID> [-verbose]")
+		os.Exit(1)
+	}
+
+	state, err := parsePosition(pos)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	e, err := createEngine()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	ins, err := e.Inspect(state)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error inspecting position: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Class:     %s\n", ins.Class)
+	fmt.Printf("Evaluator: %s\n", ins.Evaluator)
+	o := ins.Output
+	fmt.Printf("Output:    %.6f %.6f %.6f %.6f %.6f\n", o[0], o[1], o[2], o[3], o[4])
+	fmt.Println("           (win, win gammon, win backgammon, lose gammon, lose backgammon)")
+
+	if *verbose && ins.Network != "" {
+		fmt.Printf("\nInputs to the %s net:\n", ins.Network)
+		for i, v := range ins.Inputs {
+			fmt.Printf("  %3d  %-28s %.6f\n", i, ins.InputNames[i], v)
+		}
+	}
+}
+
+func cmdMET(args []string) {
+	fs := flag.NewFlagSet("met", flag.ExitOnError)
+	metFile := fs.String("met", "", "Match equity table file (default: the engine's table)")
+	crawford := fs.Bool("crawford", false, "With -away, the game is the Crawford game")
+	away := fs.Bool("away", false, "Show the score where the players need the two points given after it")
+	fs.Parse(args)
+
+	var score [2]int
+	if *away {
+		if fs.NArg() != 2 {
+			fmt.Fprintln(os.Stderr, "Error: -away needs the points each player needs")
+			fmt.Fprintln(os.Stderr, "Usage: bgengine met [-met file] [-crawford] [-away <away1> <away2>]")
+			os.Exit(1)
+		}
+		for i := range score {
+			n, err := strconv.Atoi(fs.Arg(i))
+			if err != nil || n < 1 {
+				fmt.Fprintf(os.Stderr, "Error: invalid points away %q\n", fs.Arg(i))
+				os.Exit(1)
+			}
+			score[i] = n
+		}
+	}
+
+	e, err := engine.NewEngine(engine.EngineOptions{METFile: *metFile})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to create engine: %v\n", err)
+		os.Exit(1)
+	}
+
+	if !*away {
+		grid, err := e.METGrid("")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		printMETGrid(grid)
+		return
+	}
+
+	s, err := e.METScore("", score, *crawford)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	kind := ""
+	if s.Crawford {
+		kind = ", Crawford"
+	}
+	fmt.Printf("%d-away %d-away%s: %.1f%% match winning chances\n", s.Away[0], s.Away[1], kind, s.MWC*100)
+	for p := 0; p < 2; p++ {
+		fmt.Printf("\n%d-away:\n", s.Away[p])
+		fmt.Printf("  Gammon value:      %.3f (backgammon %.3f)\n", s.GammonValue[p], s.BackgammonValue[p])
+		if !s.CanDouble[p] {
+			fmt.Println("  Cube:              dead")
+			continue
+		}
+		fmt.Printf("  Double point:      %.1f%%\n", s.DoublePoint[p]*100)
+		fmt.Printf("  Opponent's take:   %.1f%%\n", s.TakePoint[p]*100)
+	}
+}
+
+// printMETGrid prints a match equity table as percentages for the player
+// whose points away label the row.
+func printMETGrid(grid *engine.METGrid) {
+	fmt.Printf("%s (%d points)\n\n", grid.Name, grid.Length)
+	fmt.Print("Pre-Crawford\n     ")
+	for j := range grid.Length {
+		fmt.Printf(" %5s", fmt.Sprintf("%da", j+1))
+	}
+	fmt.Println()
+	for i, row := range grid.PreCrawford {
+		fmt.Printf("%4s ", fmt.Sprintf("%da", i+1))
+		for _, v := range row {
+			fmt.Printf(" %5.1f", v*100)
+		}
+		fmt.Println()
+	}
+	fmt.Println("\nPost-Crawford, against 1-away")
+	for p, row := range grid.PostCrawford {
+		fmt.Printf("  P%d ", p+1)
+		for _, v := range row {
+			fmt.Printf(" %5.1f", v*100)
+		}
+		fmt.Println()
+	}
+}
+
+func cmdCheckData(args []string) {
+	fs := flag.NewFlagSet("checkdata", flag.ExitOnError)
+	weights := fs.String("weights", "data/gnubg.weights", "Text neural network weights (empty = skip)")
+	weightsBinary := fs.String("weights-binary", "", "Binary neural network weights (gnubg.wd)")
+	bearoffFile := fs.String("bearoff", "data/gnubg_os0.bd", "One-sided bearoff database (empty = skip)")
+	bearoffTSFile := fs.String("bearoff-ts", "data/gnubg_ts.bd", "Two-sided bearoff database (empty = skip)")
+	bearoffOSFile := fs.String("bearoff-os", "", "One-sided bearoff database of 7 to 12 points, such as gnubg_os.bd")
+	metFile := fs.String("met", "data/g11.xml", "Match equity table (empty = skip)")
+	metFiles := fs.String("met-files", "", "More comma-separated match equity tables")
+	fs.Parse(args)
+
+	opts := engine.EngineOptions{
+		WeightsFile:     *weightsBinary,
+		WeightsFileText: *weights,
+		BearoffFile:     *bearoffFile,
+		BearoffTSFile:   *bearoffTSFile,
+		BearoffOSFile:   *bearoffOSFile,
+		METFile:         *metFile,
+	}
+	if *metFiles != "" {
+		opts.METFiles = strings.Split(*metFiles, ",")
+	}
+
+	failed := 0
+	for _, c := range engine.CheckData(opts) {
+		if c.Err != nil {
+			failed++
+			fmt.Printf("FAIL  %-10s %s\n      %v\n", c.Kind, c.Path, c.Err)
+		} else {
+			fmt.Printf("OK    %-10s %s\n      %s\n", c.Kind, c.Path, c.Info)
+		}
+	}
+	if failed > 0 {
+		fmt.Printf("\n%d data file(s) failed\n", failed)
+		os.Exit(1)
+	}
+}
+
+func cmdCompare(args []string) {
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	gnubgPath := fs.String("gnubg", "gnubg", "gnubg binary")
+	positionsFile := fs.String("positions", "", "File of positions, one per line: position ID and optional roll")
+	ply := fs.Int("ply", 0, "Evaluation depth for both programs (0 = static)")
+	timeout := fs.Duration("timeout", compare.DefaultTimeout, "Longest wait for gnubg to answer")
+	jsonOut := fs.Bool("json", false, "Print the report as JSON")
+	weights := fs.String("weights", "data/gnubg.weights", "Text neural network weights (empty = heuristic)")
+	weightsBinary := fs.String("weights-binary", "", "Binary neural network weights (gnubg.wd)")
+	bearoffFile := fs.String("bearoff", "data/gnubg_os0.bd", "One-sided bearoff database (empty = none)")
+	bearoffTSFile := fs.String("bearoff-ts", "data/gnubg_ts.bd", "Two-sided bearoff database (empty = none)")
+	fs.Parse(args)
+
+	if *positionsFile == "" {
+		fmt.Fprintln(os.Stderr, "Error: positions file required")
+		fmt.Fprintln(os.Stderr, "Usage: bgengine compare -positions <file> [-gnubg <path>] [-ply N] [-json]")
+		os.Exit(1)
+	}
+	f, err := os.Open(*positionsFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	positions, err := compare.ReadPositions(f)
+	f.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s: %v\n", *positionsFile, err)
+		os.Exit(1)
+	}
+
+	e, err := engine.NewEngine(engine.EngineOptions{
+		WeightsFile:     *weightsBinary,
+		WeightsFileText: *weights,
+		BearoffFile:     *bearoffFile,
+		BearoffTSFile:   *bearoffTSFile,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	g, err := compare.StartGnubg(compare.GnubgConfig{Path: *gnubgPath, Ply: *ply, Timeout: *timeout})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	report, runErr := compare.Run(e, g, positions, *ply)
+	g.Close()
+
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(report)
+	} else {
+		err = compare.WriteReport(os.Stdout, report)
+	}
+	if err == nil {
+		err = runErr
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func cmdBuildBook(args []string) {
+	fs := flag.NewFlagSet("buildbook", flag.ExitOnError)
+	depth := fs.Int("depth", 2, "Plies from the starting position to cover (0-3)")
+	ply := fs.Int("ply", 1, "Ply to evaluate each position at (0-2)")
+	top := fs.Int("top", 0, "Best moves to follow for each roll (0 = all legal moves)")
+	out := fs.String("o", "book.bin", "Book file to write")
+	weights := fs.String("weights", "data/gnubg.weights", "Text neural network weights (empty = heuristic)")
+	weightsBinary := fs.String("weights-binary", "", "Binary neural network weights (gnubg.wd)")
+	bearoffFile := fs.String("bearoff", "data/gnubg_os0.bd", "One-sided bearoff database (empty = none)")
+	bearoffTSFile := fs.String("bearoff-ts", "data/gnubg_ts.bd", "Two-sided bearoff database (empty = none)")
+	hybridRace := fs.Bool("hybrid-race", false, "Build for an engine with -hybrid-race")
+	fs.Parse(args)
+
+	e, err := engine.NewEngine(engine.EngineOptions{
+		WeightsFile:     *weightsBinary,
+		WeightsFileText: *weights,
+		BearoffFile:     *bearoffFile,
+		BearoffTSFile:   *bearoffTSFile,
+		HybridRace:      *hybridRace,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	start := time.Now()
+	book, err := e.BuildBook(engine.BookOptions{Depth: *depth, Plies: *ply, Top: *top})
+	if err == nil {
+		err = book.Save(*out)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Wrote %d positions (depth %d, %d-ply) to %s in %v\n", book.Len(), book.Depth(), book.Plies(), *out, time.Since(start).Round(time.Millisecond))
 }
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/yourusername/bgengine/pkg/api"
+	"github.com/yourusername/bgengine/pkg/engine"
+)
+
+// TestMain runs the command line in BGENGINE_ARGS (one argument per line) instead of
+// the tests, for runBgengine.
+func TestMain(m *testing.M) {
+	if args, ok := os.LookupEnv("BGENGINE_ARGS"); ok {
+		os.Args = append([]string{"bgengine"}, strings.Split(args, "\n")...)
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runBgengine runs bgengine with args in a child process and returns its
+// output and whether it succeeded.
+func runBgengine(t *testing.T, args ...string) (stdout, stderr string, ok bool) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(), "BGENGINE_ARGS="+strings.Join(args, "\n"))
+	var out, errOut bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &out, &errOut
+	err := cmd.Run()
+	return out.String(), errOut.String(), err == nil
+}
+
+// elapsed matches the time a rollout took, which differs from run to run.
+var elapsed = regexp.MustCompile(`, [0-9.]+s\)`)
+
+func TestRemoteMatchesLocal(t *testing.T) {
+	e, err := engine.NewEngine(engine.EngineOptions{})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	server := httptest.NewServer(api.NewServer(e, api.DefaultConfig(), "test").Handler())
+	defer server.Close()
+
+	const start = "4HPwATDgc/ABMA"
+	const race = "2NsBACCw2wEAAA"
+	snowie := "0;0;0;0;0;3;1;0;0;0;1;0;0;-2;0;0;0;0;5;0;3;0;0;0;-5;5;0;0;0;-3;0;-5;0;0;0;0;2;0;3;1;"
+	commands := [][]string{
+		{"eval", "-p", start},
+		{"eval", "-p", start + ":cAkAAAAAAAAA", "-opponent"},
+		{"eval", "-p", snowie},
+		{"move", "-p", start, "-d", "3-1"},
+		{"move", "-p", start, "-d", "6-4", "-n", "3", "-constraints", "!hit,!point"},
+		{"move", "-p", snowie},
+		{"cube", "-p", start},
+		{"cube", "-p", race, "-opponent"},
+		{"cube", "-p", start, "-rollout", "-trials", "36", "-seed", "7"},
+		{"rollout", "-p", start, "-trials", "36", "-seed", "7"},
+		{"rollout", "-p", race, "-trials", "36", "-seed", "7", "-truncate", "5"},
+	}
+	for _, args := range commands {
+		t.Run(strings.Join(args, " "), func(t *testing.T) {
+			local, stderr, ok := runBgengine(t, args...)
+			if !ok {
+				t.Fatalf("local run failed: %s", stderr)
+			}
+			remote, stderr, ok := runBgengine(t, append([]string{"-server", server.URL}, args...)...)
+			if !ok {
+				t.Fatalf("remote run failed: %s", stderr)
+			}
+			local, remote = elapsed.ReplaceAllString(local, ""), elapsed.ReplaceAllString(remote, "")
+			if local != remote {
+				t.Errorf("output differs\nlocal:\n%s\nremote:\n%s", local, remote)
+			}
+		})
+	}
+}
+
+func TestRemoteErrors(t *testing.T) {
+	e, err := engine.NewEngine(engine.EngineOptions{})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	server := httptest.NewServer(api.NewServer(e, api.DefaultConfig(), "test").Handler())
+	url := server.URL
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"engine error", []string{"-server", url, "move", "-p", "4HPwATDgc/ABMA", "-d", "3-1", "-n", "1000"}, "Error from server (TOO_MANY_MOVES)"},
+		{"resume", []string{"-server", url, "rollout", "-p", "4HPwATDgc/ABMA", "-resume", "r.json"}, "not available with -server"},
+		{"unsupported command", []string{"-server", url, "analyze", "-p", "4HPwATDgc/ABMA"}, "-server does not support the analyze command"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, stderr, ok := runBgengine(t, tt.args...)
+			if ok || !strings.Contains(stderr, tt.want) {
+				t.Errorf("ok = %v, stderr = %q; want failure with %q", ok, stderr, tt.want)
+			}
+		})
+	}
+
+	server.Close()
+	_, stderr, ok := runBgengine(t, "-server", url, "eval", "-p", "4HPwATDgc/ABMA")
+	if ok || !strings.Contains(stderr, "cannot reach server") {
+		t.Errorf("closed server: ok = %v, stderr = %q; want a network error", ok, stderr)
+	}
+}
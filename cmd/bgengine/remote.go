@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/yourusername/bgengine/internal/positionid"
+	"github.com/yourusername/bgengine/pkg/client"
+	"github.com/yourusername/bgengine/pkg/match"
+)
+
+// Global options: with -server, eval, move, cube and rollout send their
+// requests to a bgserver and print its answers the way they print the local
+// engine's, so a machine without the data files can use a server that has
+// them.
+var (
+	serverURL     string
+	serverTimeout time.Duration
+)
+
+// Time limits of a command with -server, unless -timeout is given.
+const (
+	defaultRemoteTimeout  = 30 * time.Second
+	defaultRolloutTimeout = 30 * time.Minute
+)
+
+// remoteClient returns the client for -server, or nil to run locally.
+func remoteClient() *client.Client {
+	if serverURL == "" {
+		return nil
+	}
+	// The command's context sets the time limit, so the HTTP client has none
+	config := client.DefaultConfig()
+	config.Timeout = 0
+	return client.New(serverURL, config)
+}
+
+// remoteContext returns the context of a request to the server, limited by
+// -timeout or, without it, by limit.
+func remoteContext(limit time.Duration) (context.Context, context.CancelFunc) {
+	if serverTimeout > 0 {
+		limit = serverTimeout
+	}
+	return context.WithTimeout(context.Background(), limit)
+}
+
+// remotePosition returns the position and format to send for the -position
+// argument pos. The match ID of a gnubg ID is left out, as the local commands
+// ignore it too; a Snowie text position is sent whole.
+func remotePosition(pos string) (position, format string) {
+	if match.IsSnowieText(pos) {
+		return pos, "snowie"
+	}
+	position, _ = positionid.SplitGnubgID(pos)
+	return position, ""
+}
+
+// exitRemote reports a failed request to the server and exits. A server that
+// cannot be reached or does not answer in time is told apart from one that
+// rejected the request or failed to analyze the position.
+func exitRemote(err error) {
+	var apiErr *client.APIError
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		fmt.Fprintf(os.Stderr, "Error: no answer from server %s in time (see -timeout): %v\n", serverURL, err)
+	case errors.Is(err, client.ErrNetwork):
+		fmt.Fprintf(os.Stderr, "Error: cannot reach server %s: %v\n", serverURL, err)
+	case errors.As(err, &apiErr):
+		code := apiErr.Code
+		if code == "" {
+			code = fmt.Sprintf("HTTP %d", apiErr.StatusCode)
+		}
+		msg := apiErr.Message
+		if apiErr.Details != "" {
+			msg += ": " + apiErr.Details
+		}
+		fmt.Fprintf(os.Stderr, "Error from server (%s): %s\n", code, msg)
+	default:
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	}
+	os.Exit(1)
+}
+
+// noteClamped warns when the server played fewer trials than asked for.
+func noteClamped(clamped bool, trials int) {
+	if clamped {
+		fmt.Fprintf(os.Stderr, "Note: the server limits rollouts to %d trials\n", trials)
+	}
+}
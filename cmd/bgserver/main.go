@@ -6,13 +6,16 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 	"time"
 
+	"github.com/yourusername/bgengine/internal/met"
 	"github.com/yourusername/bgengine/pkg/api"
 	"github.com/yourusername/bgengine/pkg/engine"
+	"github.com/yourusername/bgengine/pkg/match"
 )
 
-const version = "0.1.0"
+var version = engine.Version
 
 func main() {
 	// Command line flags
@@ -21,11 +24,36 @@ func main() {
 	weightsFile := flag.String("weights", "data/gnubg.weights", "Path to neural network weights")
 	bearoffFile := flag.String("bearoff", "data/gnubg_os0.bd", "Path to one-sided bearoff database")
 	bearoffTSFile := flag.String("bearoff-ts", "data/gnubg_ts.bd", "Path to two-sided bearoff database")
+	bearoffOSFile := flag.String("bearoff-os", "", "Path to one-sided bearoff database of 7 to 12 points, such as gnubg_os.bd")
+	bearoffLazy := flag.Bool("bearoff-lazy", false, "Read the bearoff databases from disk on demand instead of loading them into memory")
 	metFile := flag.String("met", "data/g11.xml", "Path to match equity table")
+	metName := flag.String("met-name", "", "Bundled match equity table to use instead of -met ("+strings.Join(met.Available(), ", ")+")")
+	metFiles := flag.String("met-files", "", "Comma-separated match equity table files requests can select by file name with \"met\"")
 	readTimeout := flag.Duration("read-timeout", 30*time.Second, "HTTP read timeout")
 	writeTimeout := flag.Duration("write-timeout", 30*time.Second, "HTTP write timeout")
 	maxFastWorkers := flag.Int("max-fast-workers", 100, "Max concurrent fast operations (evaluate, move, cube)")
 	maxSlowWorkers := flag.Int("max-slow-workers", 4, "Max concurrent slow operations (rollout)")
+	identityHeader := flag.String("identity-header", "", "Request header naming the client (e.g. X-API-Key), to share slow operations fairly between clients")
+	maxSlowPerIdentity := flag.Int("max-slow-per-identity", 0, "Max concurrent slow operations of one client identity (0 = no cap)")
+	maxBodyBytes := flag.Int64("max-body-bytes", 64<<10, "Max request body size in bytes")
+	maxGameBodyBytes := flag.Int64("max-game-body-bytes", 4<<20, "Max request body size in bytes for game analysis and match uploads")
+	maxPositions := flag.Int("max-positions", 1000, "Max positions in a game analysis request")
+	maxTrials := flag.Int("max-trials", 100000, "Max rollout trials per request")
+	maxWSInFlight := flag.Int("max-ws-inflight", 4, "Max requests a WebSocket connection may have in flight")
+	defaultEvalPly := flag.Int("default-eval-ply", 0, "Ply for evaluate requests that name none")
+	defaultMovePly := flag.Int("default-move-ply", 0, "Ply for move requests that name none")
+	maxPly := flag.Int("max-ply", api.MaxPly, fmt.Sprintf("Deepest ply served, 0-%d; deeper requests are clamped to it", api.MaxPly))
+	maxRolloutTrials := flag.Int("max-rollout-trials", 0, "Most rollout trials served; larger requests are clamped to it (0 = up to -max-trials)")
+	strictLimits := flag.Bool("strict-limits", false, "Reject requests beyond -max-ply or -max-rollout-trials instead of clamping them")
+	bookFile := flag.String("book", "", "Book of early positions built by bgengine buildbook with the same weights (empty = none)")
+	rolloutStore := flag.String("rollout-store", "", "File to keep rollout results in, so repeated rollouts are served from it (empty = none)")
+	matchStore := flag.String("match-store", "", "Directory to keep uploaded matches and their analyses in (empty = match endpoints disabled)")
+	noCrashedNet := flag.Bool("no-crashed-net", false, "Evaluate crashed positions with the contact net (for A/B comparisons)")
+	noBearoffDB := flag.Bool("no-bearoff-db", false, "Evaluate bearoffs with the race net instead of the bearoff databases (for A/B comparisons)")
+	hybridRace := flag.Bool("hybrid-race", false, "Evaluate races with one side at home by the rolls from the one-sided bearoff database")
+	forceScalar := flag.Bool("force-scalar", false, "Run the nets on the pure Go kernel even if the CPU supports a vector one (for debugging)")
+	strictSelfCheck := flag.Bool("strict-selfcheck", false, "Refuse to start if the engine self-check on sentinel positions fails, as with the wrong weights file")
+	debug := flag.Bool("debug", false, "Serve debugging endpoints (/api/inspect)")
 	showVersion := flag.Bool("version", false, "Show version and exit")
 
 	flag.Parse()
@@ -35,6 +63,13 @@ func main() {
 		os.Exit(0)
 	}
 
+	if *maxPly < 0 || *maxPly > api.MaxPly {
+		log.Fatalf("-max-ply must be 0-%d, got %d", api.MaxPly, *maxPly)
+	}
+	if *defaultEvalPly < 0 || *defaultEvalPly > *maxPly || *defaultMovePly < 0 || *defaultMovePly > *maxPly {
+		log.Fatalf("-default-eval-ply and -default-move-ply must be 0-%d (-max-ply)", *maxPly)
+	}
+
 	// Print startup banner
 	log.Printf("GoBG API Server v%s", version)
 	log.Printf("Loading engine data files...")
@@ -44,15 +79,66 @@ func main() {
 		WeightsFileText: *weightsFile,
 		BearoffFile:     *bearoffFile,
 		BearoffTSFile:   *bearoffTSFile,
+		BearoffOSFile:   *bearoffOSFile,
+		BearoffLazy:     *bearoffLazy,
 		METFile:         *metFile,
+		BookFile:        *bookFile,
+		SkipWarmup:      true, // Warmed up below while the server starts
+
+		DisableCrashedNet: *noCrashedNet,
+		DisableBearoffDB:  *noBearoffDB,
+		ForceScalar:       *forceScalar,
+		HybridRace:        *hybridRace,
+	}
+	if *metName != "" {
+		opts.METFile = ""
+		opts.METName = *metName
+	}
+	if *metFiles != "" {
+		opts.METFiles = strings.Split(*metFiles, ",")
+	}
+	if *rolloutStore != "" {
+		store, err := engine.OpenFileRolloutStore(*rolloutStore)
+		if err != nil {
+			log.Fatalf("Failed to open rollout store: %v", err)
+		}
+		if aside := store.Quarantined(); aside != "" {
+			log.Printf("WARNING: rollout store %s was corrupt; moved it to %s and started an empty store", *rolloutStore, aside)
+		}
+		if n := store.Skipped(); n > 0 {
+			log.Printf("Rollout store %s: skipped %d unreadable records", *rolloutStore, n)
+		}
+		opts.RolloutStore = store
 	}
 
 	eng, err := engine.NewEngine(opts)
 	if err != nil {
 		log.Fatalf("Failed to create engine: %v", err)
 	}
+	defer eng.Close()
 
-	log.Printf("Engine loaded successfully")
+	name, length := eng.METInfo()
+	log.Printf("Engine loaded successfully (MET: %s, %d points)", name, length)
+	cpu := "no vector extensions"
+	if features := engine.CPUFeatures().Names(); features != nil {
+		cpu = strings.Join(features, ", ")
+	}
+	log.Printf("Evaluation kernel: %s (CPU: %s)", eng.Kernel(), cpu)
+	if disabled := eng.Routing().Disabled(); disabled != nil {
+		log.Printf("Evaluators disabled: %s", strings.Join(disabled, ", "))
+	}
+
+	// Check the data files on positions with known odds; /api/health/selfcheck
+	// reports this run
+	selfCheck := eng.SelfCheck()
+	switch {
+	case selfCheck.Pass:
+		log.Printf("Engine self-check passed")
+	case *strictSelfCheck:
+		log.Fatalf("Engine self-check failed (-strict-selfcheck): %s", selfCheck)
+	default:
+		log.Printf("WARNING: engine self-check failed, evaluations may be wrong: %s", selfCheck)
+	}
 
 	// Create server config
 	config := api.ServerConfig{
@@ -63,8 +149,51 @@ func main() {
 		IdleTimeout:    60 * time.Second,
 		MaxFastWorkers: *maxFastWorkers,
 		MaxSlowWorkers: *maxSlowWorkers,
+		Limits: api.Limits{
+			MaxBodyBytes:      *maxBodyBytes,
+			EndpointBodyBytes: map[string]int64{"/api/tutor/game": *maxGameBodyBytes, "/api/matches": *maxGameBodyBytes},
+			MaxPositions:      *maxPositions,
+			MaxTrials:         *maxTrials,
+			MaxWSInFlight:     *maxWSInFlight,
+		},
+		Debug: *debug,
+
+		DefaultEvalPly:   *defaultEvalPly,
+		DefaultMovePly:   *defaultMovePly,
+		MaxPly:           *maxPly,
+		MaxRolloutTrials: *maxRolloutTrials,
+		StrictLimits:     *strictLimits,
+
+		IdentityHeader:     *identityHeader,
+		MaxSlowPerIdentity: *maxSlowPerIdentity,
+
+		SelfCheck: selfCheck,
+	}
+
+	if *matchStore != "" {
+		store, err := match.OpenFileStore(*matchStore)
+		if err != nil {
+			log.Fatalf("Failed to open match store: %v", err)
+		}
+		for _, aside := range store.Quarantined() {
+			log.Printf("WARNING: match store %s: moved corrupt file to %s", *matchStore, aside)
+		}
+		if n := store.Skipped(); n > 0 {
+			log.Printf("Match store %s: skipped %d unreadable files", *matchStore, n)
+		}
+		config.MatchStore = store
 	}
 
+	// Warm up in the background; /api/health reports ready once it is done
+	go func() {
+		start := time.Now()
+		if err := eng.Warmup(); err != nil {
+			log.Printf("Engine warm-up failed: %v", err)
+			return
+		}
+		log.Printf("Engine warmed up in %v", time.Since(start).Round(time.Millisecond))
+	}()
+
 	// Create and start server
 	server := api.NewServer(eng, config, version)
 
@@ -65,9 +65,9 @@ func main() {
 			fmt.Printf("       Points: %d, Checkers: %d\n", db.NPoints, db.NChequers)
 
 			// Test a simple bearoff position
-			var testBoard [2][6]uint8
-			testBoard[0] = [6]uint8{2, 2, 2, 2, 2, 5} // 15 checkers total
-			testBoard[1] = [6]uint8{2, 2, 2, 2, 2, 5}
+			var testBoard bearoff.Board
+			testBoard[0] = [bearoff.MaxPoints]uint8{2, 2, 2, 2, 2, 5} // 15 checkers total
+			testBoard[1] = [bearoff.MaxPoints]uint8{2, 2, 2, 2, 2, 5}
 			output, err := db.Evaluate(testBoard)
 			if err != nil {
 				fmt.Printf("   Bearoff eval: %v\n", err)
@@ -93,9 +93,9 @@ func main() {
 				db.Type, db.NPoints, db.NChequers, db.Cubeful)
 
 			// Test a simple bearoff position (6 checkers max)
-			var testBoard [2][6]uint8
-			testBoard[0] = [6]uint8{3, 3, 0, 0, 0, 0} // 6 checkers
-			testBoard[1] = [6]uint8{3, 3, 0, 0, 0, 0}
+			var testBoard bearoff.Board
+			testBoard[0] = [bearoff.MaxPoints]uint8{3, 3, 0, 0, 0, 0} // 6 checkers
+			testBoard[1] = [bearoff.MaxPoints]uint8{3, 3, 0, 0, 0, 0}
 			output, err := db.Evaluate(testBoard)
 			if err != nil {
 				fmt.Printf("   Bearoff eval: %v\n", err)
@@ -104,8 +104,8 @@ func main() {
 			}
 
 			// Test asymmetric position
-			testBoard[0] = [6]uint8{6, 0, 0, 0, 0, 0} // Easy to bear off
-			testBoard[1] = [6]uint8{0, 0, 0, 0, 0, 6} // Harder to bear off
+			testBoard[0] = [bearoff.MaxPoints]uint8{6, 0, 0, 0, 0, 0} // Easy to bear off
+			testBoard[1] = [bearoff.MaxPoints]uint8{0, 0, 0, 0, 0, 6} // Harder to bear off
 			output, err = db.Evaluate(testBoard)
 			if err != nil {
 				fmt.Printf("   Bearoff eval: %v\n", err)
@@ -0,0 +1,42 @@
+//go:build js && wasm
+
+// Command wasm evaluates the starting position in the browser, as a check
+// that the engine runs as WebAssembly. The page hosting it passes the
+// contents of a weights file, binary or text, as a Uint8Array in the global
+// bgWeights; without one the engine estimates the position from pip counts.
+//
+// Build it with
+//
+//	GOOS=js GOARCH=wasm go build -o bgengine.wasm ./examples/wasm
+//
+// and load it with wasm_exec.js from $(go env GOROOT)/lib/wasm, as in
+// index.html. The equity is printed to the browser console.
+package main
+
+import (
+	"fmt"
+	"syscall/js"
+
+	"github.com/yourusername/bgengine/pkg/engine"
+)
+
+func main() {
+	var weights []byte
+	if w := js.Global().Get("bgWeights"); w.Truthy() {
+		weights = make([]byte, w.Get("length").Int())
+		js.CopyBytesToGo(weights, w)
+	}
+
+	// Only the contact and race nets, to keep the download small
+	e, err := engine.NewEngineFromBytes(weights, nil, nil, nil, engine.EngineOptions{CoreNetsOnly: true})
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	eval, err := e.Evaluate(engine.StartingPosition())
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	fmt.Printf("Starting position: equity %+.3f (win %.1f%%, %s)\n", eval.Equity, 100*eval.WinProb, eval.Source)
+}
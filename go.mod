@@ -3,6 +3,6 @@ module github.com/yourusername/bgengine
 go 1.24.4
 
 require (
-	github.com/gorilla/websocket v1.5.3 // indirect
-	gonum.org/v1/gonum v0.16.0 // indirect
+	github.com/gorilla/websocket v1.5.3
+	gonum.org/v1/gonum v0.16.0
 )
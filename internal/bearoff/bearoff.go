@@ -3,6 +3,10 @@
 package bearoff
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"math"
@@ -19,6 +23,36 @@ const (
 	BearoffHypergammon
 )
 
+// MaxPoints is the most points a database can cover. gnubg's 6-point
+// databases fit in memory; one-sided databases of 10 or 12 points run to
+// gigabytes and are best opened with OpenOneSided.
+const MaxPoints = 12
+
+// headerSize is the size of the text header of a database file.
+const headerSize = 40
+
+// Board is a bearoff position: the checkers on points 1 to MaxPoints of the
+// opponent ([0]) and of the player on roll ([1]).
+type Board [2][MaxPoints]uint8
+
+// The equities of a record of a cubeful two-sided database, in the order
+// gnubg stores them. Each is the money equity of the player on roll per
+// unit of the cube, with perfect cube play by both sides.
+const (
+	CubefulCubeless    = iota // Cubeless equity
+	CubefulOwned              // The player on roll owns the cube
+	CubefulCentered           // The cube is centered
+	CubefulUnavailable        // The opponent owns the cube
+)
+
+// ErrOutOfRange is returned for positions a database does not cover, and for
+// reads past the end of its data. Callers fall back to another evaluator.
+var ErrOutOfRange = errors.New("bearoff position out of range")
+
+// ErrNoGammons is returned by EvaluateGammons for a database without the
+// distributions of the rolls to bear off the first checker.
+var ErrNoGammons = errors.New("bearoff database has no gammon distributions")
+
 // Database represents a bearoff database
 type Database struct {
 	Type       BearoffType
@@ -29,7 +63,9 @@ type Database struct {
 	ND         bool // Uses normal distribution approximation?
 	Cubeful    bool // Includes cubeful equities? (two-sided only)
 
-	data     []byte // Memory-mapped or loaded database content
+	data     []byte   // Database content, when loaded into memory
+	file     *os.File // Database file, when records are read on demand
+	size     int64    // Size of the file, for a database read on demand
 	filename string
 }
 
@@ -47,20 +83,88 @@ func LoadOneSided(filename string) (*Database, error) {
 		return nil, fmt.Errorf("failed to read bearoff database: %w", err)
 	}
 
-	if len(data) < 40 {
+	db, err := LoadOneSidedBytes(data)
+	if err != nil {
+		return nil, err
+	}
+	db.filename = filename
+	return db, nil
+}
+
+// OpenOneSided opens a bearoff database like LoadOneSided, but reads each
+// record from the file when it is looked up instead of loading the whole file
+// into memory, for the multi-gigabyte one-sided databases of more than 6
+// points. The database keeps the file open until Close.
+func OpenOneSided(filename string) (*Database, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bearoff database: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to read bearoff database: %w", err)
+	}
+	header := make([]byte, headerSize)
+	if info.Size() < headerSize {
+		f.Close()
+		return nil, fmt.Errorf("bearoff database too small: %d bytes", info.Size())
+	}
+	if _, err := f.ReadAt(header, 0); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to read bearoff database: %w", err)
+	}
+
+	db, err := parseHeader(header)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	db.file = f
+	db.size = info.Size()
+	db.filename = filename
+	return db, nil
+}
+
+// Close closes the file of a database opened with OpenOneSided. It does
+// nothing for a database loaded into memory.
+func (db *Database) Close() error {
+	if db.file == nil {
+		return nil
+	}
+	return db.file.Close()
+}
+
+// Lazy reports whether the database reads its records from disk on demand.
+func (db *Database) Lazy() bool {
+	return db.file != nil
+}
+
+// LoadOneSidedBytes loads a bearoff database from the contents of its file,
+// for callers without a file system. Despite the name it reads two-sided
+// databases too, like LoadOneSided. The database keeps data, which must not
+// be modified afterwards.
+func LoadOneSidedBytes(data []byte) (*Database, error) {
+	if len(data) < headerSize {
 		return nil, fmt.Errorf("bearoff database too small: %d bytes", len(data))
 	}
 
-	// Parse header
-	header := string(data[:40])
+	db, err := parseHeader(data[:headerSize])
+	if err != nil {
+		return nil, err
+	}
+	db.data = data
+	return db, nil
+}
+
+// parseHeader reads the type and layout of a database from its header.
+func parseHeader(data []byte) (*Database, error) {
+	header := string(data)
 	if header[:5] != "gnubg" {
 		return nil, fmt.Errorf("not a gnubg bearoff database")
 	}
 
-	db := &Database{
-		data:     data,
-		filename: filename,
-	}
+	db := &Database{}
 
 	// Parse database type
 	dbType := header[6:8]
@@ -81,6 +185,10 @@ func LoadOneSided(filename string) (*Database, error) {
 	if _, err := fmt.Sscanf(header[9:14], "%02d-%02d", &db.NPoints, &db.NChequers); err != nil {
 		return nil, fmt.Errorf("failed to parse points/checkers: %w", err)
 	}
+	// Position IDs are bit patterns of NPoints+NChequers bits
+	if db.Type != BearoffHypergammon && (db.NPoints < 1 || db.NPoints > MaxPoints || db.NChequers < 1 || db.NChequers > 15) {
+		return nil, fmt.Errorf("header declares %d points and %d checkers, want 1-%d points and 1-15 checkers", db.NPoints, db.NChequers, MaxPoints)
+	}
 
 	// Parse options for one-sided database
 	if db.Type == BearoffOneSided {
@@ -99,9 +207,13 @@ func (db *Database) NumPositions() int {
 	return Combination(db.NPoints+db.NChequers, db.NPoints)
 }
 
-// Evaluate evaluates a bearoff position and returns win/gammon probabilities
-// anBoard should be [2][6]uint8 representing checkers on points 1-6 for each player
-func (db *Database) Evaluate(board [2][6]uint8) (output [5]float32, err error) {
+// Evaluate evaluates a bearoff position and returns win/gammon probabilities.
+// Positions the database does not cover, with checkers beyond its NPoints
+// points or more than its NChequers checkers, return ErrOutOfRange.
+func (db *Database) Evaluate(board Board) (output [5]float32, err error) {
+	if err := db.checkBoard(board); err != nil {
+		return output, err
+	}
 	if db.Type == BearoffOneSided {
 		return db.evaluateOneSided(board)
 	} else if db.Type == BearoffTwoSided {
@@ -110,14 +222,55 @@ func (db *Database) Evaluate(board [2][6]uint8) (output [5]float32, err error) {
 	return output, fmt.Errorf("unsupported bearoff database type")
 }
 
-// evaluateOneSided evaluates using one-sided database
-func (db *Database) evaluateOneSided(board [2][6]uint8) (output [5]float32, err error) {
-	// Convert board arrays to slices for PositionBearoff
-	us := boardToSlice(board[1])
-	them := boardToSlice(board[0])
+// Distribution returns the rolls one side needs to bear off its checkers,
+// side, from a one-sided database: prob[i] is the chance it takes exactly i
+// rolls. Unlike Evaluate it looks at one side only, so the other side may be
+// anywhere. Sides the database does not cover return ErrOutOfRange.
+func (db *Database) Distribution(side [MaxPoints]uint8) (prob [32]float32, err error) {
+	if db.Type != BearoffOneSided {
+		return prob, fmt.Errorf("rolls distribution needs a one-sided database")
+	}
+	if err := db.checkSide(side); err != nil {
+		return prob, err
+	}
+	prob, _, err = db.GetDistribution(PositionBearoff(side[:], db.NPoints, db.NChequers))
+	return prob, err
+}
+
+// checkBoard returns ErrOutOfRange unless both sides of board have at most
+// NChequers checkers, all within the database's NPoints points. Position IDs
+// of other boards would index past the database's positions.
+func (db *Database) checkBoard(board Board) error {
+	for _, side := range board {
+		if err := db.checkSide(side); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkSide is checkBoard for the checkers of one side.
+func (db *Database) checkSide(side [MaxPoints]uint8) error {
+	if db.NPoints < 1 || db.NPoints > len(side) {
+		return fmt.Errorf("%w: database covers %d points", ErrOutOfRange, db.NPoints)
+	}
+	n := 0
+	for i, c := range side {
+		if c > 0 && i >= db.NPoints {
+			return fmt.Errorf("%w: checkers on point %d of a %d-point database", ErrOutOfRange, i+1, db.NPoints)
+		}
+		n += int(c)
+	}
+	if n > db.NChequers {
+		return fmt.Errorf("%w: %d checkers in a %d-checker database", ErrOutOfRange, n, db.NChequers)
+	}
+	return nil
+}
 
-	posUs := PositionBearoff(us, db.NPoints, db.NChequers)
-	posThem := PositionBearoff(them, db.NPoints, db.NChequers)
+// evaluateOneSided evaluates using one-sided database
+func (db *Database) evaluateOneSided(board Board) (output [5]float32, err error) {
+	posUs := PositionBearoff(board[1][:], db.NPoints, db.NChequers)
+	posThem := PositionBearoff(board[0][:], db.NPoints, db.NChequers)
 
 	// Get probability distributions
 	probUs, _, err := db.GetDistribution(posUs)
@@ -143,18 +296,57 @@ func (db *Database) evaluateOneSided(board [2][6]uint8) (output [5]float32, err
 	return output, nil
 }
 
-// evaluateTwoSided evaluates using two-sided database
-func (db *Database) evaluateTwoSided(board [2][6]uint8) (output [5]float32, err error) {
-	us := boardToSlice(board[1])
-	them := boardToSlice(board[0])
+// EvaluateGammons is Evaluate for a one-sided database, with the gammon
+// chances against each side that gammonable marks: the chance that the other
+// side bears all of its checkers off before that side bears off its first.
+// Evaluate gives none, as a side with fewer than 15 checkers has borne the
+// rest off; a partial position may leave them out instead. With every
+// checker in the home boards there are no backgammons. Databases without
+// gammon distributions return ErrNoGammons.
+func (db *Database) EvaluateGammons(board Board, gammonable [2]bool) (output [5]float32, err error) {
+	if db.Type != BearoffOneSided {
+		return output, fmt.Errorf("gammon chances need a one-sided database")
+	}
+	if !db.HasGammon && !db.ND {
+		return output, ErrNoGammons
+	}
+	if err := db.checkBoard(board); err != nil {
+		return output, err
+	}
 
-	posUs := PositionBearoff(us, db.NPoints, db.NChequers)
-	posThem := PositionBearoff(them, db.NPoints, db.NChequers)
+	probUs, firstUs, err := db.GetDistribution(PositionBearoff(board[1][:], db.NPoints, db.NChequers))
+	if err != nil {
+		return output, err
+	}
+	probThem, firstThem, err := db.GetDistribution(PositionBearoff(board[0][:], db.NPoints, db.NChequers))
+	if err != nil {
+		return output, err
+	}
 
-	n := db.NumPositions()
-	iPos := posUs*n + posThem
+	// The player on roll finishing on its i-th roll comes before the
+	// opponent's i-th roll; the opponent finishing on its j-th roll comes
+	// after the player's j-th
+	for i := 0; i < 32; i++ {
+		for j := i; j < 32; j++ {
+			output[0] += probUs[i] * probThem[j]
+			if gammonable[0] {
+				output[1] += probUs[i] * firstThem[j]
+			}
+		}
+	}
+	if gammonable[1] {
+		for j := 0; j < 32; j++ {
+			for i := j + 1; i < 32; i++ {
+				output[3] += probThem[j] * firstUs[i]
+			}
+		}
+	}
+	return output, nil
+}
 
-	equity, err := db.readTwoSidedEquity(iPos)
+// evaluateTwoSided evaluates using two-sided database
+func (db *Database) evaluateTwoSided(board Board) (output [5]float32, err error) {
+	equity, err := db.readTwoSidedEquity(db.twoSidedIndex(board))
 	if err != nil {
 		return output, err
 	}
@@ -163,6 +355,76 @@ func (db *Database) evaluateTwoSided(board [2][6]uint8) (output [5]float32, err
 	return output, nil
 }
 
+// EvaluateCubeful returns the four equities a cubeful two-sided database
+// stores for board, indexed by CubefulCubeless, CubefulOwned, CubefulCentered
+// and CubefulUnavailable. Positions the database does not cover return
+// ErrOutOfRange.
+func (db *Database) EvaluateCubeful(board Board) (equity [4]float32, err error) {
+	if db.Type != BearoffTwoSided || !db.Cubeful {
+		return equity, fmt.Errorf("cubeful equities need a cubeful two-sided database")
+	}
+	if err := db.checkBoard(board); err != nil {
+		return equity, err
+	}
+	posID := db.twoSidedIndex(board)
+	var buf [8]byte
+	data, err := db.record(recordOffset(posID, 8), 8, buf[:])
+	if errors.Is(err, ErrOutOfRange) {
+		return equity, fmt.Errorf("%w: position %d", ErrOutOfRange, posID)
+	}
+	if err != nil {
+		return equity, err
+	}
+	for i := range equity {
+		equity[i] = twoSidedEquity(data[2*i:])
+	}
+	return equity, nil
+}
+
+// twoSidedIndex returns the record of board in a two-sided database, as
+// gnubg lays them out: one row of NumPositions() records per position of the
+// player on roll, indexed by the opponent's position (924 records a row for
+// 6 points and 6 checkers). board must pass checkBoard.
+func (db *Database) twoSidedIndex(board Board) int64 {
+	posUs := PositionBearoff(board[1][:], db.NPoints, db.NChequers)
+	posThem := PositionBearoff(board[0][:], db.NPoints, db.NChequers)
+	return int64(posUs)*int64(db.NumPositions()) + int64(posThem)
+}
+
+// dataSize returns the size of the database in bytes.
+func (db *Database) dataSize() int64 {
+	if db.file != nil {
+		return db.size
+	}
+	return int64(len(db.data))
+}
+
+// record returns the n bytes of the database at offset: a slice of the data
+// of a database in memory, or buf[:n] read from the file of one opened with
+// OpenOneSided. A record that does not lie within the database returns
+// ErrOutOfRange.
+func (db *Database) record(offset int64, n int, buf []byte) ([]byte, error) {
+	if offset < 0 || offset > db.dataSize()-int64(n) {
+		return nil, ErrOutOfRange
+	}
+	if db.file == nil {
+		return db.data[offset : offset+int64(n)], nil
+	}
+	if _, err := db.file.ReadAt(buf[:n], offset); err != nil {
+		return nil, fmt.Errorf("reading bearoff database %s: %w", db.filename, err)
+	}
+	return buf[:n], nil
+}
+
+// recordOffset returns headerSize + index*size, or -1 if that overflows, so
+// that record rejects it.
+func recordOffset(index, size int64) int64 {
+	if index < 0 || index > (math.MaxInt64-headerSize)/size {
+		return -1
+	}
+	return headerSize + index*size
+}
+
 // GetDistribution returns the probability distribution for a position
 // Returns probabilities of bearing off in 0-31 rolls
 func (db *Database) GetDistribution(posID int) (prob [32]float32, gammonProb [32]float32, err error) {
@@ -177,20 +439,20 @@ func (db *Database) GetDistribution(posID int) (prob [32]float32, gammonProb [32
 
 // getDistributionND reads distribution using normal distribution approximation
 func (db *Database) getDistributionND(posID int) (prob [32]float32, gammonProb [32]float32, err error) {
-	offset := 40 + posID*16
-	if offset+16 > len(db.data) {
-		return prob, gammonProb, fmt.Errorf("position %d out of range", posID)
+	var buf [16]byte
+	data, err := db.record(recordOffset(int64(posID), 16), 16, buf[:])
+	if errors.Is(err, ErrOutOfRange) {
+		return prob, gammonProb, fmt.Errorf("%w: position %d", ErrOutOfRange, posID)
+	}
+	if err != nil {
+		return prob, gammonProb, err
 	}
 
 	// Read 4 floats: mean, stddev, gammon_mean, gammon_stddev
-	mean := math.Float32frombits(uint32(db.data[offset]) | uint32(db.data[offset+1])<<8 |
-		uint32(db.data[offset+2])<<16 | uint32(db.data[offset+3])<<24)
-	stddev := math.Float32frombits(uint32(db.data[offset+4]) | uint32(db.data[offset+5])<<8 |
-		uint32(db.data[offset+6])<<16 | uint32(db.data[offset+7])<<24)
-	gammonMean := math.Float32frombits(uint32(db.data[offset+8]) | uint32(db.data[offset+9])<<8 |
-		uint32(db.data[offset+10])<<16 | uint32(db.data[offset+11])<<24)
-	gammonStddev := math.Float32frombits(uint32(db.data[offset+12]) | uint32(db.data[offset+13])<<8 |
-		uint32(db.data[offset+14])<<16 | uint32(db.data[offset+15])<<24)
+	mean := math.Float32frombits(binary.LittleEndian.Uint32(data[0:]))
+	stddev := math.Float32frombits(binary.LittleEndian.Uint32(data[4:]))
+	gammonMean := math.Float32frombits(binary.LittleEndian.Uint32(data[8:]))
+	gammonStddev := math.Float32frombits(binary.LittleEndian.Uint32(data[12:]))
 
 	for i := 0; i < 32; i++ {
 		prob[i] = normalDist(float32(i), mean, stddev)
@@ -200,52 +462,63 @@ func (db *Database) getDistributionND(posID int) (prob [32]float32, gammonProb [
 	return prob, gammonProb, nil
 }
 
-// getDistributionCompressed reads compressed distribution
-func (db *Database) getDistributionCompressed(posID int) (prob [32]float32, gammonProb [32]float32, err error) {
-	nPos := db.NumPositions()
-	indexEntrySize := 6
+// indexEntrySize returns the size of an index entry of a compressed
+// database.
+func (db *Database) indexEntrySize() int {
 	if db.HasGammon {
-		indexEntrySize = 8
+		return 8
 	}
+	return 6
+}
+
+// getDistributionCompressed reads compressed distribution
+func (db *Database) getDistributionCompressed(posID int) (prob [32]float32, gammonProb [32]float32, err error) {
+	nPos := int64(db.NumPositions())
+	indexEntrySize := db.indexEntrySize()
 
 	// Read index entry
-	indexOffset := 40 + posID*indexEntrySize
-	if indexOffset+indexEntrySize > len(db.data) {
-		return prob, gammonProb, fmt.Errorf("position %d out of range", posID)
+	var buf [128]byte
+	entry, err := db.record(recordOffset(int64(posID), int64(indexEntrySize)), indexEntrySize, buf[:])
+	if errors.Is(err, ErrOutOfRange) {
+		return prob, gammonProb, fmt.Errorf("%w: position %d", ErrOutOfRange, posID)
+	}
+	if err != nil {
+		return prob, gammonProb, err
 	}
 
 	// Parse index entry
-	dataOffset := int(db.data[indexOffset]) | int(db.data[indexOffset+1])<<8 |
-		int(db.data[indexOffset+2])<<16 | int(db.data[indexOffset+3])<<24
-	nz := int(db.data[indexOffset+4])
-	ioff := int(db.data[indexOffset+5])
+	dataOffset := int64(binary.LittleEndian.Uint32(entry))
+	nz := int(entry[4])
+	ioff := int(entry[5])
 
 	var nzg, ioffg int
 	if db.HasGammon {
-		nzg = int(db.data[indexOffset+6])
-		ioffg = int(db.data[indexOffset+7])
+		nzg = int(entry[6])
+		ioffg = int(entry[7])
+	}
+	if ioff+nz > 32 || ioffg+nzg > 32 {
+		return prob, gammonProb, fmt.Errorf("%w: index entry of position %d", ErrOutOfRange, posID)
 	}
 
-	// Calculate actual data offset
-	actualOffset := 40 + nPos*indexEntrySize + 2*dataOffset
-	nBytes := 2 * (nz + nzg)
-
-	if actualOffset+nBytes > len(db.data) {
-		return prob, gammonProb, fmt.Errorf("data offset out of range for position %d", posID)
+	// Calculate actual data offset: the values are 2-byte words after the
+	// index
+	actualOffset := recordOffset(nPos, int64(indexEntrySize)) + 2*dataOffset
+	data, err := db.record(actualOffset, 2*(nz+nzg), buf[:])
+	if errors.Is(err, ErrOutOfRange) {
+		return prob, gammonProb, fmt.Errorf("%w: data offset for position %d", ErrOutOfRange, posID)
+	}
+	if err != nil {
+		return prob, gammonProb, err
 	}
 
 	// Read probability values
 	for i := 0; i < nz; i++ {
-		idx := actualOffset + 2*i
-		val := uint16(db.data[idx]) | uint16(db.data[idx+1])<<8
-		prob[ioff+i] = float32(val) / 65535.0
+		prob[ioff+i] = float32(binary.LittleEndian.Uint16(data[2*i:])) / 65535.0
 	}
 
 	// Read gammon probability values
 	for i := 0; i < nzg; i++ {
-		idx := actualOffset + 2*nz + 2*i
-		val := uint16(db.data[idx]) | uint16(db.data[idx+1])<<8
-		gammonProb[ioffg+i] = float32(val) / 65535.0
+		gammonProb[ioffg+i] = float32(binary.LittleEndian.Uint16(data[2*(nz+i):])) / 65535.0
 	}
 
 	return prob, gammonProb, nil
@@ -258,24 +531,24 @@ func (db *Database) getDistributionUncompressed(posID int) (prob [32]float32, ga
 		recordSize = 128
 	}
 
-	offset := 40 + posID*recordSize
-	if offset+recordSize > len(db.data) {
-		return prob, gammonProb, fmt.Errorf("position %d out of range", posID)
+	var buf [128]byte
+	data, err := db.record(recordOffset(int64(posID), int64(recordSize)), recordSize, buf[:])
+	if errors.Is(err, ErrOutOfRange) {
+		return prob, gammonProb, fmt.Errorf("%w: position %d", ErrOutOfRange, posID)
+	}
+	if err != nil {
+		return prob, gammonProb, err
 	}
 
 	// Read probability values
 	for i := 0; i < 32; i++ {
-		idx := offset + 2*i
-		val := uint16(db.data[idx]) | uint16(db.data[idx+1])<<8
-		prob[i] = float32(val) / 65535.0
+		prob[i] = float32(binary.LittleEndian.Uint16(data[2*i:])) / 65535.0
 	}
 
 	// Read gammon probability values
 	if db.HasGammon {
 		for i := 0; i < 32; i++ {
-			idx := offset + 64 + 2*i
-			val := uint16(db.data[idx]) | uint16(db.data[idx+1])<<8
-			gammonProb[i] = float32(val) / 65535.0
+			gammonProb[i] = float32(binary.LittleEndian.Uint16(data[64+2*i:])) / 65535.0
 		}
 	}
 
@@ -284,25 +557,28 @@ func (db *Database) getDistributionUncompressed(posID int) (prob [32]float32, ga
 
 // readTwoSidedEquity reads equity from two-sided database
 // Returns equity in range [-1, 1] where 1 = certain win, -1 = certain loss
-func (db *Database) readTwoSidedEquity(posID int) (float32, error) {
+func (db *Database) readTwoSidedEquity(posID int64) (float32, error) {
 	recordSize := 2
 	if db.Cubeful {
 		recordSize = 8
 	}
 
-	offset := 40 + posID*recordSize
-	if offset+recordSize > len(db.data) {
-		return 0, fmt.Errorf("position %d out of range", posID)
+	var buf [8]byte
+	data, err := db.record(recordOffset(posID, int64(recordSize)), recordSize, buf[:])
+	if errors.Is(err, ErrOutOfRange) {
+		return 0, fmt.Errorf("%w: position %d", ErrOutOfRange, posID)
+	}
+	if err != nil {
+		return 0, err
 	}
 
-	// gnubg stores as unsigned short, converts with: us / 32767.5f - 1.0f
-	val := uint16(db.data[offset]) | uint16(db.data[offset+1])<<8
-	return float32(val)/32767.5 - 1.0, nil
+	return twoSidedEquity(data), nil
 }
 
-// boardToSlice converts a [6]uint8 to []uint8
-func boardToSlice(board [6]uint8) []uint8 {
-	return board[:]
+// twoSidedEquity converts an equity as two-sided databases store it, an
+// unsigned short us, to one in [-1, 1] as gnubg does: us / 32767.5 - 1.
+func twoSidedEquity(data []byte) float32 {
+	return float32(binary.LittleEndian.Uint16(data))/32767.5 - 1.0
 }
 
 // normalDist calculates normal distribution probability
@@ -344,3 +620,72 @@ func (db *Database) GetAverageRolls(posID int) (mean, stddev float32, err error)
 	mean, stddev = AverageRolls(prob)
 	return mean, stddev, nil
 }
+
+// Check verifies that the size of the database is the one its header and
+// NumPositions call for, so that a truncated or mislabeled file is caught
+// before lookups run past its end. Hypergammon databases are not checked.
+// The index of a compressed database is read through once, from disk if the
+// database was opened with OpenOneSided.
+func (db *Database) Check() error {
+	if db.NPoints < 1 || db.NPoints > MaxPoints || db.NChequers < 1 || db.NChequers > 15 {
+		return fmt.Errorf("header declares %d points and %d checkers, want 1-%d points and 1-15 checkers", db.NPoints, db.NChequers, MaxPoints)
+	}
+	n := int64(db.NumPositions())
+	size := db.dataSize()
+
+	var want int64
+	switch {
+	case db.Type == BearoffTwoSided:
+		recordSize := int64(2)
+		if db.Cubeful {
+			recordSize = 8
+		}
+		if n > math.MaxInt64/recordSize/n {
+			return fmt.Errorf("%d points and %d checkers (%d positions) make a two-sided database too large to index", db.NPoints, db.NChequers, n)
+		}
+		want = recordOffset(n*n, recordSize)
+	case db.Type != BearoffOneSided:
+		return nil
+	case db.ND:
+		want = recordOffset(n, 16)
+	case db.Compressed:
+		indexEntrySize := db.indexEntrySize()
+		index := recordOffset(n, int64(indexEntrySize))
+		if size < index {
+			return fmt.Errorf("%d positions need an index of %d bytes, the file has %d", n, index, size)
+		}
+		// The data ends with that of the position stored last
+		want = index
+		r := bufio.NewReaderSize(io.NewSectionReader(db.readerAt(), headerSize, index-headerSize), 1<<16)
+		e := make([]byte, indexEntrySize)
+		for pos := int64(0); pos < n; pos++ {
+			if _, err := io.ReadFull(r, e); err != nil {
+				return fmt.Errorf("reading the index: %w", err)
+			}
+			end := index + 2*int64(binary.LittleEndian.Uint32(e)) + 2*int64(e[4])
+			if db.HasGammon {
+				end += 2 * int64(e[6])
+			}
+			want = max(want, end)
+		}
+	default:
+		recordSize := int64(64)
+		if db.HasGammon {
+			recordSize = 128
+		}
+		want = recordOffset(n, recordSize)
+	}
+
+	if size != want {
+		return fmt.Errorf("%d points and %d checkers (%d positions) call for %d bytes, the file has %d", db.NPoints, db.NChequers, n, want, size)
+	}
+	return nil
+}
+
+// readerAt returns a reader of the whole database.
+func (db *Database) readerAt() io.ReaderAt {
+	if db.file != nil {
+		return db.file
+	}
+	return bytes.NewReader(db.data)
+}
@@ -1,6 +1,14 @@
 package bearoff
 
 import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
 	"testing"
 )
 
@@ -25,6 +33,74 @@ func TestCombination(t *testing.T) {
 	}
 }
 
+func TestCombinationBounds(t *testing.T) {
+	if got := Combination(40, 1); got != 40 {
+		t.Errorf("Combination(40, 1) = %d, want 40", got)
+	}
+
+	for _, tc := range []struct{ n, r int }{
+		{0, 1}, {1, 0}, {-1, 1}, {41, 1}, {1, 26},
+	} {
+		func() {
+			defer func() {
+				if p := recover(); (p != nil) != debugChecks {
+					t.Errorf("Combination(%d, %d) panic = %v, debugChecks = %v", tc.n, tc.r, p, debugChecks)
+				}
+			}()
+			if got := Combination(tc.n, tc.r); got != 0 {
+				t.Errorf("Combination(%d, %d) = %d, want 0", tc.n, tc.r, got)
+			}
+		}()
+	}
+}
+
+// TestPositionBearoffColdStart calls PositionBearoff from many goroutines
+// before the combination table exists. It re-runs itself in a fresh process
+// so the table really is cold; run with -race to catch unsafe initialization.
+func TestPositionBearoffColdStart(t *testing.T) {
+	if os.Getenv("BEAROFF_COLD_START") != "1" {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestPositionBearoffColdStart$")
+		cmd.Env = append(os.Environ(), "BEAROFF_COLD_START=1")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("cold start run failed: %v\n%s", err, out)
+		}
+		return
+	}
+
+	boards := [][]uint8{
+		{15, 0, 0, 0, 0, 0},
+		{3, 2, 1, 0, 0, 0},
+		{2, 2, 3, 3, 2, 3},
+		{0, 0, 0, 0, 0, 1},
+	}
+
+	const workers = 32
+	got := make([][]int, workers)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			<-start
+			for _, b := range boards {
+				got[w] = append(got[w], PositionBearoff(b, 6, 15))
+			}
+		}(w)
+	}
+	close(start)
+	wg.Wait()
+
+	for i, b := range boards {
+		want := PositionBearoff(b, 6, 15)
+		for w := range got {
+			if got[w][i] != want {
+				t.Errorf("worker %d: PositionBearoff(%v) = %d, want %d", w, b, got[w][i], want)
+			}
+		}
+	}
+}
+
 func TestPositionBearoff(t *testing.T) {
 	// Test some known positions
 	tests := []struct {
@@ -100,7 +176,7 @@ func TestLoadOneSided(t *testing.T) {
 	t.Logf("Position 0 gammon distribution: gammonProb[0]=%f", gammonProb[0])
 
 	// Test evaluation
-	board := [2][6]uint8{
+	board := Board{
 		{5, 5, 5, 0, 0, 0}, // Opponent: 15 checkers on points 1-3
 		{5, 5, 5, 0, 0, 0}, // Player: 15 checkers on points 1-3
 	}
@@ -151,7 +227,7 @@ func TestLoadTwoSided(t *testing.T) {
 	}
 
 	// Test evaluation with equal positions
-	board := [2][6]uint8{
+	board := Board{
 		{3, 3, 0, 0, 0, 0}, // Opponent: 6 checkers on points 1-2
 		{3, 3, 0, 0, 0, 0}, // Player: 6 checkers on points 1-2
 	}
@@ -168,7 +244,7 @@ func TestLoadTwoSided(t *testing.T) {
 	}
 
 	// Test asymmetric position
-	board2 := [2][6]uint8{
+	board2 := Board{
 		{6, 0, 0, 0, 0, 0}, // Opponent: 6 checkers on point 1 (easy to bear off)
 		{0, 0, 0, 0, 0, 6}, // Player: 6 checkers on point 6 (harder to bear off)
 	}
@@ -183,3 +259,432 @@ func TestLoadTwoSided(t *testing.T) {
 		t.Errorf("Expected win probability < 0.5 for harder position, got %f", output2[0])
 	}
 }
+
+// indexedTwoSided returns a 6-point two-sided database of nChequers checkers
+// whose records hold their own index, so evaluations reveal which record was
+// read.
+func indexedTwoSided(nChequers int) *Database {
+	db := &Database{Type: BearoffTwoSided, NPoints: 6, NChequers: nChequers}
+	n := db.NumPositions()
+	db.data = make([]byte, 40+2*n*n)
+	for i := 0; i < n*n; i++ {
+		db.data[40+2*i] = byte(i)
+		db.data[40+2*i+1] = byte(i >> 8)
+	}
+	return db
+}
+
+// recordRead returns the index of the record an evaluation of
+// indexedTwoSided read.
+func recordRead(output [5]float32) int {
+	return int(math.Round(float64(output[0]) * 65535))
+}
+
+func TestTwoSidedIndex(t *testing.T) {
+	db := indexedTwoSided(3)
+	n := db.NumPositions()
+	if n != 84 {
+		t.Fatalf("NumPositions() = %d, want C(9, 6) = 84", n)
+	}
+
+	for us := 0; us < n; us++ {
+		for them := 0; them < n; them++ {
+			board := Board{
+				PositionFromBearoff(them, db.NPoints, db.NChequers),
+				PositionFromBearoff(us, db.NPoints, db.NChequers),
+			}
+			output, err := db.Evaluate(board)
+			if err != nil {
+				t.Fatalf("Evaluate(%v): %v", board, err)
+			}
+			if got := recordRead(output); got != us*n+them {
+				t.Fatalf("Evaluate(%v) read record %d, want %d", board, got, us*n+them)
+			}
+
+			// Swapping the sides transposes the record, and equal sides are
+			// on the diagonal
+			swapped, _ := db.Evaluate(Board{board[1], board[0]})
+			if got := recordRead(swapped); got != them*n+us {
+				t.Fatalf("Evaluate(swapped %v) read record %d, want %d", board, got, them*n+us)
+			}
+		}
+	}
+
+	// gnubg's 6-point, 6-checker database has rows of 924 records
+	if got := indexedTwoSided(6).NumPositions(); got != 924 {
+		t.Errorf("NumPositions() for 6 checkers = %d, want 924", got)
+	}
+}
+
+func TestTwoSidedBounds(t *testing.T) {
+	db := indexedTwoSided(3)
+	n := db.NumPositions()
+
+	// The last record is the last two bytes of the data
+	last := PositionFromBearoff(n-1, db.NPoints, db.NChequers)
+	output, err := db.Evaluate(Board{last, last})
+	if err != nil || recordRead(output) != n*n-1 {
+		t.Fatalf("Evaluate(maximal index) read record %d (%v), want %d", recordRead(output), err, n*n-1)
+	}
+	db.data = db.data[:len(db.data)-1]
+	if _, err := db.Evaluate(Board{last, last}); !errors.Is(err, ErrOutOfRange) {
+		t.Errorf("Evaluate(maximal index) of truncated data: %v, want ErrOutOfRange", err)
+	}
+
+	db = indexedTwoSided(3)
+	for _, board := range []Board{
+		{{4, 0, 0, 0, 0, 0}, {1, 0, 0, 0, 0, 0}}, // Opponent has too many checkers
+		{{1, 0, 0, 0, 0, 0}, {0, 0, 0, 2, 0, 2}}, // Player has too many checkers
+		{{0, 0, 0, 0, 0, 15}, {0, 0, 0, 0, 0, 15}},
+	} {
+		if _, err := db.Evaluate(board); !errors.Is(err, ErrOutOfRange) {
+			t.Errorf("Evaluate(%v): %v, want ErrOutOfRange", board, err)
+		}
+	}
+
+	// Checkers beyond the points a database covers
+	db = &Database{Type: BearoffTwoSided, NPoints: 4, NChequers: 3}
+	db.data = make([]byte, 40+2*db.NumPositions()*db.NumPositions())
+	if _, err := db.Evaluate(Board{{1, 0, 0, 0, 0, 0}, {0, 0, 0, 0, 1, 0}}); !errors.Is(err, ErrOutOfRange) {
+		t.Errorf("Evaluate with a checker on the 5 point of a 4-point database: %v, want ErrOutOfRange", err)
+	}
+
+	// One-sided databases are checked the same way
+	oneSided := &Database{Type: BearoffOneSided, NPoints: 6, NChequers: 3}
+	if _, err := oneSided.Evaluate(Board{{4, 0, 0, 0, 0, 0}, {1, 0, 0, 0, 0, 0}}); !errors.Is(err, ErrOutOfRange) {
+		t.Errorf("one-sided Evaluate with too many checkers: %v, want ErrOutOfRange", err)
+	}
+}
+
+func TestEvaluateCubeful(t *testing.T) {
+	// Record i holds the equities i, i+1, i+2 and i+3 (of 65535), so an
+	// evaluation shows both which record and which of its values were read
+	db := &Database{Type: BearoffTwoSided, NPoints: 6, NChequers: 2, Cubeful: true}
+	n := db.NumPositions()
+	db.data = make([]byte, 40+8*n*n)
+	for i := 0; i < n*n; i++ {
+		for k := 0; k < 4; k++ {
+			binary.LittleEndian.PutUint16(db.data[40+8*i+2*k:], uint16(i+k))
+		}
+	}
+	if err := db.Check(); err != nil {
+		t.Fatalf("Check() = %v", err)
+	}
+
+	for us := 0; us < n; us++ {
+		for them := 0; them < n; them++ {
+			board := Board{
+				PositionFromBearoff(them, db.NPoints, db.NChequers),
+				PositionFromBearoff(us, db.NPoints, db.NChequers),
+			}
+			equity, err := db.EvaluateCubeful(board)
+			if err != nil {
+				t.Fatalf("EvaluateCubeful(%v): %v", board, err)
+			}
+			for k, eq := range equity {
+				if got := int(math.Round((float64(eq) + 1) * 32767.5)); got != us*n+them+k {
+					t.Fatalf("EvaluateCubeful(%v)[%d] read %d, want %d", board, k, got, us*n+them+k)
+				}
+			}
+
+			// The cubeless equity is the one Evaluate reads
+			output, _ := db.Evaluate(board)
+			if want := equity[CubefulCubeless]/2 + 0.5; output[0] != want {
+				t.Fatalf("Evaluate(%v) = %v, want %v", board, output[0], want)
+			}
+		}
+	}
+
+	if _, err := db.EvaluateCubeful(Board{{3, 0, 0, 0, 0, 0}, {1, 0, 0, 0, 0, 0}}); !errors.Is(err, ErrOutOfRange) {
+		t.Errorf("EvaluateCubeful with too many checkers: %v, want ErrOutOfRange", err)
+	}
+	if _, err := indexedTwoSided(2).EvaluateCubeful(Board{}); err == nil {
+		t.Error("EvaluateCubeful of a cubeless database succeeded")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	db := indexedTwoSided(3)
+	if err := db.Check(); err != nil {
+		t.Fatalf("Check() = %v", err)
+	}
+	db.data = db.data[:len(db.data)-2]
+	want := "6 points and 3 checkers (84 positions) call for 14152 bytes, the file has 14150"
+	if err := db.Check(); err == nil || err.Error() != want {
+		t.Errorf("Check() of truncated data = %v, want %q", err, want)
+	}
+
+	db.NPoints = 7
+	if err := db.Check(); err == nil {
+		t.Error("Check() of a 7-point database succeeded")
+	}
+
+	// A compressed one-sided database of 1 point and 1 checker: an index of
+	// two 6-byte entries, and then one value for the first position and two
+	// for the second
+	one := &Database{Type: BearoffOneSided, NPoints: 1, NChequers: 1, Compressed: true}
+	one.data = make([]byte, 40+2*6+2*3)
+	copy(one.data[40:], []byte{0, 0, 0, 0, 1, 0, 1, 0, 0, 0, 2, 1})
+	if err := one.Check(); err != nil {
+		t.Errorf("Check() of compressed database = %v", err)
+	}
+	one.data = append(one.data, 0, 0)
+	if err := one.Check(); err == nil {
+		t.Error("Check() of compressed database with surplus data succeeded")
+	}
+}
+
+func TestLoadOneSidedBytes(t *testing.T) {
+	data := indexedTwoSided(3).data
+	copy(data, fmt.Sprintf("%-40s", "gnubg-TS-06-03-0"))
+
+	db, err := LoadOneSidedBytes(data)
+	if err != nil {
+		t.Fatalf("LoadOneSidedBytes: %v", err)
+	}
+	if db.Type != BearoffTwoSided || db.NPoints != 6 || db.NChequers != 3 || db.Cubeful {
+		t.Errorf("LoadOneSidedBytes: type %d, %d points, %d checkers, cubeful %v", db.Type, db.NPoints, db.NChequers, db.Cubeful)
+	}
+	if err := db.Check(); err != nil {
+		t.Errorf("Check() = %v", err)
+	}
+
+	for _, bad := range []string{"gnubg", fmt.Sprintf("%-40s", "not-a-bearoff-database")} {
+		if _, err := LoadOneSidedBytes([]byte(bad)); err == nil {
+			t.Errorf("LoadOneSidedBytes(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestPositionFromBearoffMorePoints(t *testing.T) {
+	for _, nPoints := range []int{7, 10, MaxPoints} {
+		n := Combination(nPoints+15, nPoints)
+		// Every position of 7 points, and a stride through the millions of
+		// 10 and 12 points that ends with the last one
+		step := max(1, n/5000)
+		for posID := n - 1; posID >= 0; posID -= step {
+			board := PositionFromBearoff(posID, nPoints, 15)
+			total := 0
+			for i, c := range board {
+				if c > 0 && i >= nPoints {
+					t.Fatalf("%d points: position %d has checkers on point %d", nPoints, posID, i+1)
+				}
+				total += int(c)
+			}
+			if total > 15 {
+				t.Fatalf("%d points: position %d has %d checkers", nPoints, posID, total)
+			}
+			if got := PositionBearoff(board[:], nPoints, 15); got != posID {
+				t.Fatalf("%d points: round trip of position %d gave %d (%v)", nPoints, posID, got, board)
+			}
+		}
+	}
+	if n := Combination(MaxPoints+15, MaxPoints); n != 17383860 {
+		t.Errorf("12-point database has %d positions, want C(27, 12) = 17383860", n)
+	}
+}
+
+// pipRolls is the number of rolls generatedOneSided records for a side:
+// its pip count over 8, rounded up, which tells the record of one position
+// from those of others with different pip counts.
+func pipRolls(side [MaxPoints]uint8) int {
+	pips := 0
+	for i, c := range side {
+		pips += (i + 1) * int(c)
+	}
+	return min(31, (pips+7)/8)
+}
+
+// generatedOneSided returns a one-sided database file of nPoints points and
+// nChequers checkers in gnubg's layout, uncompressed or compressed. Each
+// position bears off in exactly pipRolls rolls.
+func generatedOneSided(nPoints, nChequers int, compressed bool) []byte {
+	n := Combination(nPoints+nChequers, nPoints)
+	header := fmt.Sprintf("gnubg-OS-%02d-%02d-0-%d-0", nPoints, nChequers, map[bool]int{false: 0, true: 1}[compressed])
+	data := []byte(fmt.Sprintf("%-40s", header))
+	for pos := 0; pos < n; pos++ {
+		rolls := pipRolls(PositionFromBearoff(pos, nPoints, nChequers))
+		if compressed {
+			// Index entry: offset of the values in words, how many, and the
+			// first roll they are for
+			data = binary.LittleEndian.AppendUint32(data, uint32(pos))
+			data = append(data, 1, byte(rolls))
+			continue
+		}
+		var record [64]byte
+		binary.LittleEndian.PutUint16(record[2*rolls:], 65535)
+		data = append(data, record[:]...)
+	}
+	for pos := 0; compressed && pos < n; pos++ {
+		data = binary.LittleEndian.AppendUint16(data, 65535)
+	}
+	return data
+}
+
+func TestOneSidedMorePoints(t *testing.T) {
+	for _, compressed := range []bool{false, true} {
+		data := generatedOneSided(7, 4, compressed)
+		path := filepath.Join(t.TempDir(), "os7.bd")
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		inMemory, err := LoadOneSidedBytes(data)
+		if err != nil {
+			t.Fatalf("LoadOneSidedBytes: %v", err)
+		}
+		lazy, err := OpenOneSided(path)
+		if err != nil {
+			t.Fatalf("OpenOneSided: %v", err)
+		}
+		defer lazy.Close()
+		if inMemory.Lazy() || !lazy.Lazy() {
+			t.Errorf("Lazy() = %v for the database in memory, %v for the opened one", inMemory.Lazy(), lazy.Lazy())
+		}
+
+		for _, db := range []*Database{inMemory, lazy} {
+			if db.NPoints != 7 || db.NChequers != 4 || db.Compressed != compressed {
+				t.Fatalf("header read as %d points, %d checkers, compressed %v", db.NPoints, db.NChequers, db.Compressed)
+			}
+			if err := db.Check(); err != nil {
+				t.Errorf("Check() = %v", err)
+			}
+
+			n := db.NumPositions()
+			for pos := 0; pos < n; pos++ {
+				prob, _, err := db.GetDistribution(pos)
+				if err != nil {
+					t.Fatalf("GetDistribution(%d): %v", pos, err)
+				}
+				if rolls := pipRolls(PositionFromBearoff(pos, 7, 4)); prob[rolls] != 1 {
+					t.Fatalf("compressed %v, lazy %v: position %d does not bear off in %d rolls: %v", compressed, db.Lazy(), pos, rolls, prob)
+				}
+			}
+			if _, _, err := db.GetDistribution(n); !errors.Is(err, ErrOutOfRange) {
+				t.Errorf("GetDistribution(%d) past the last position: %v, want ErrOutOfRange", n, err)
+			}
+
+			// A checker on the 7 point takes a roll more than one on the 1
+			// point, and the player on roll wins ties
+			board := Board{{1}, {0, 0, 0, 0, 0, 0, 1}}
+			if output, err := db.Evaluate(board); err != nil || output[0] != 1 {
+				t.Errorf("Evaluate(%v) = %v, %v; want a sure win", board, output, err)
+			}
+			board = Board{{2}, {0, 0, 0, 0, 0, 0, 2}}
+			if output, err := db.Evaluate(board); err != nil || output[0] != 0 {
+				t.Errorf("Evaluate(%v) = %v, %v; want a sure loss", board, output, err)
+			}
+			if _, err := db.Evaluate(Board{{0, 0, 0, 0, 0, 0, 0, 1}, {1}}); !errors.Is(err, ErrOutOfRange) {
+				t.Errorf("Evaluate with a checker on the 8 point: %v, want ErrOutOfRange", err)
+			}
+		}
+	}
+}
+
+func TestDistribution(t *testing.T) {
+	db, err := LoadOneSidedBytes(generatedOneSided(7, 4, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// One side within the database, whatever the other side's checkers
+	side := [MaxPoints]uint8{1, 0, 0, 0, 0, 0, 3}
+	prob, err := db.Distribution(side)
+	if err != nil || prob[pipRolls(side)] != 1 {
+		t.Errorf("Distribution(%v) = %v, %v; want %d rolls", side, prob, err, pipRolls(side))
+	}
+	for _, side := range [][MaxPoints]uint8{{0, 0, 0, 0, 0, 0, 0, 1}, {5}} {
+		if _, err := db.Distribution(side); !errors.Is(err, ErrOutOfRange) {
+			t.Errorf("Distribution(%v): %v, want ErrOutOfRange", side, err)
+		}
+	}
+
+	ts := indexedTwoSided(3)
+	if _, err := ts.Distribution([MaxPoints]uint8{1}); err == nil {
+		t.Error("Distribution succeeded on a two-sided database")
+	}
+}
+
+func TestOpenOneSidedTruncated(t *testing.T) {
+	data := generatedOneSided(7, 4, false)
+	path := filepath.Join(t.TempDir(), "os7.bd")
+	if err := os.WriteFile(path, data[:len(data)-1], 0o644); err != nil {
+		t.Fatal(err)
+	}
+	db, err := OpenOneSided(path)
+	if err != nil {
+		t.Fatalf("OpenOneSided: %v", err)
+	}
+	defer db.Close()
+	if err := db.Check(); err == nil {
+		t.Error("Check() of a truncated file succeeded")
+	}
+	if _, _, err := db.GetDistribution(db.NumPositions() - 1); !errors.Is(err, ErrOutOfRange) {
+		t.Errorf("GetDistribution(last position) of a truncated file: %v, want ErrOutOfRange", err)
+	}
+}
+
+func TestRecordOffsetOverflow(t *testing.T) {
+	if got := recordOffset(1<<40, 128); got != 40+(1<<47) {
+		t.Errorf("recordOffset(2^40, 128) = %d, want %d", got, int64(40+(1<<47)))
+	}
+	if got := recordOffset(math.MaxInt64/64, 128); got != -1 {
+		t.Errorf("recordOffset of an overflowing index = %d, want -1", got)
+	}
+
+	// A header beyond MaxPoints or 15 checkers is rejected when loading
+	for _, header := range []string{"gnubg-OS-13-15-1-0-0", "gnubg-OS-06-16-1-0-0"} {
+		if _, err := LoadOneSidedBytes([]byte(fmt.Sprintf("%-40s", header))); err == nil {
+			t.Errorf("LoadOneSidedBytes(%q) succeeded", header)
+		}
+	}
+}
+
+func TestEvaluateGammons(t *testing.T) {
+	// An uncompressed 6-point database with gammon distributions: each side
+	// bears off in pipRolls rolls, and its first checker in one roll, or two
+	// if it has none below the 5 point
+	const nPoints, nChequers = 6, 4
+	data := []byte(fmt.Sprintf("%-40s", fmt.Sprintf("gnubg-OS-%02d-%02d-1-0-0", nPoints, nChequers)))
+	for pos := 0; pos < Combination(nPoints+nChequers, nPoints); pos++ {
+		side := PositionFromBearoff(pos, nPoints, nChequers)
+		first := 0
+		for i, c := range side {
+			if c > 0 {
+				first = 1 + i/4
+				break
+			}
+		}
+		var record [128]byte
+		binary.LittleEndian.PutUint16(record[2*pipRolls(side):], 65535)
+		binary.LittleEndian.PutUint16(record[64+2*first:], 65535)
+		data = append(data, record[:]...)
+	}
+	db, err := LoadOneSidedBytes(data)
+	if err != nil || !db.HasGammon {
+		t.Fatalf("LoadOneSidedBytes: %v, gammons %v", err, db.HasGammon)
+	}
+
+	tests := []struct {
+		name       string
+		board      Board
+		gammonable [2]bool
+		want       [5]float32
+	}{
+		{"gammon win", Board{{0, 0, 0, 0, 0, 2}, {1}}, [2]bool{true, true}, [5]float32{1, 1, 0, 0, 0}},
+		{"opponent bore off", Board{{0, 0, 0, 0, 0, 2}, {1}}, [2]bool{false, true}, [5]float32{1, 0, 0, 0, 0}},
+		{"gammon loss", Board{{1}, {0, 0, 0, 0, 0, 2}}, [2]bool{true, true}, [5]float32{0, 0, 0, 1, 0}},
+		{"single loss", Board{{1}, {0, 0, 0, 1, 0, 1}}, [2]bool{true, true}, [5]float32{0, 0, 0, 0, 0}},
+	}
+	for _, tt := range tests {
+		if got, err := db.EvaluateGammons(tt.board, tt.gammonable); err != nil || got != tt.want {
+			t.Errorf("%s: EvaluateGammons = %v, %v; want %v", tt.name, got, err, tt.want)
+		}
+	}
+
+	plain, err := LoadOneSidedBytes(generatedOneSided(nPoints, nChequers, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := plain.EvaluateGammons(Board{{1}, {1}}, [2]bool{true, true}); !errors.Is(err, ErrNoGammons) {
+		t.Errorf("EvaluateGammons without gammon distributions: %v, want ErrNoGammons", err)
+	}
+}
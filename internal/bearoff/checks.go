@@ -0,0 +1,7 @@
+//go:build !bgdebug
+
+package bearoff
+
+// debugChecks enables panics on invalid arguments that normal builds
+// tolerate. Build with -tags bgdebug to turn it on.
+const debugChecks = false
@@ -1,16 +1,22 @@
 package bearoff
 
+import (
+	"fmt"
+	"sync"
+)
+
 // Combination table for computing bearoff positions
 // anCombination[n-1][r-1] = C(n, r)
 var anCombination [40][25]int
-var combinationInitialized bool
+var combinationOnce sync.Once
 
 // initCombination initializes the combination table
 func initCombination() {
-	if combinationInitialized {
-		return
-	}
+	combinationOnce.Do(buildCombination)
+}
 
+// buildCombination fills the combination table
+func buildCombination() {
 	// C(n, 1) = n
 	for i := 0; i < 40; i++ {
 		anCombination[i][0] = i + 1
@@ -27,13 +33,16 @@ func initCombination() {
 			anCombination[i][j] = anCombination[i-1][j-1] + anCombination[i-1][j]
 		}
 	}
-
-	combinationInitialized = true
 }
 
-// Combination returns C(n, r) - the number of ways to choose r items from n
+// Combination returns C(n, r) - the number of ways to choose r items from n.
+// n must be 1..40 and r 1..25. Out of range arguments return 0, or panic in
+// builds with the bgdebug tag.
 func Combination(n, r int) int {
 	if n <= 0 || r <= 0 || n > 40 || r > 25 {
+		if debugChecks {
+			panic(fmt.Sprintf("bearoff: Combination(%d, %d) out of range", n, r))
+		}
 		return 0
 	}
 	initCombination()
@@ -54,7 +63,7 @@ func positionF(fBits uint32, n, r int) int {
 
 // PositionBearoff converts a bearoff board position to a position ID
 // anBoard is the number of checkers on each point (0-5 for points 1-6)
-// nPoints is the number of points (typically 6)
+// nPoints is the number of points (typically 6, at most MaxPoints)
 // nChequers is the maximum number of checkers (typically 15)
 func PositionBearoff(anBoard []uint8, nPoints, nChequers int) int {
 	if nPoints == 0 {
@@ -93,9 +102,10 @@ func positionInv(nID, n, r int) uint32 {
 	return positionInv(nID, n-1, r)
 }
 
-// PositionFromBearoff converts a position ID back to a board position
-func PositionFromBearoff(usID, nPoints, nChequers int) [6]uint8 {
-	var anBoard [6]uint8
+// PositionFromBearoff converts a position ID back to a board position of
+// nPoints points, at most MaxPoints.
+func PositionFromBearoff(usID, nPoints, nChequers int) [MaxPoints]uint8 {
+	var anBoard [MaxPoints]uint8
 
 	fBits := positionInv(usID, nChequers+nPoints, nPoints)
 
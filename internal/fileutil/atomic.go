@@ -0,0 +1,71 @@
+// Package fileutil writes the files the engine keeps between runs so that a
+// crash never leaves one half written, and reads them back through a
+// versioned envelope that can migrate old files.
+package fileutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// AtomicWriteFile writes data to path with permissions perm. The data goes to
+// a temporary file in the same directory, which is synced and then renamed
+// over path, so readers and a crash see either the old file or the new one,
+// never a mix. Concurrent writers each write their own temporary file; the
+// last rename wins.
+func AtomicWriteFile(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Chmod(perm)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		return err
+	}
+	syncDir(dir)
+	return nil
+}
+
+// syncDir makes a rename in dir durable. Not every platform can sync a
+// directory, so failures are ignored: the rename has happened either way.
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
+
+// Quarantine renames a corrupt file aside, to path.corrupt-<time>, so that
+// a fresh file can take its place while the bad one is kept for inspection.
+// It returns the new name.
+func Quarantine(path string) (string, error) {
+	base := path + ".corrupt-" + time.Now().UTC().Format("20060102T150405")
+	aside := base
+	for i := 1; ; i++ {
+		if _, err := os.Lstat(aside); os.IsNotExist(err) {
+			break
+		}
+		aside = fmt.Sprintf("%s-%d", base, i)
+	}
+	if err := os.Rename(path, aside); err != nil {
+		return "", err
+	}
+	return aside, nil
+}
@@ -0,0 +1,122 @@
+package fileutil
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestAtomicWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file.json")
+
+	if err := AtomicWriteFile(path, []byte("first"), 0o600); err != nil {
+		t.Fatalf("AtomicWriteFile: %v", err)
+	}
+	if err := AtomicWriteFile(path, []byte("second"), 0o644); err != nil {
+		t.Fatalf("AtomicWriteFile: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "second" {
+		t.Errorf("file holds %q, %v", data, err)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0o644 {
+		t.Errorf("mode %v, want 0644", info.Mode().Perm())
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("%d files left in the directory, want 1", len(entries))
+	}
+
+	// A failed write leaves the old file alone
+	if err := AtomicWriteFile(filepath.Join(dir, "missing", "file.json"), []byte("x"), 0o644); err == nil {
+		t.Error("write into a missing directory succeeded")
+	}
+}
+
+func TestAtomicWriteFileConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file.json")
+
+	// Each writer writes a large file of its own byte; a reader must only
+	// ever see one writer's file, whole
+	const writers, size = 4, 1 << 20
+	contents := make([][]byte, writers)
+	for i := range contents {
+		contents[i] = bytes.Repeat([]byte{byte('a' + i)}, size)
+	}
+	if err := AtomicWriteFile(path, contents[0], 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(data []byte) {
+			defer wg.Done()
+			for n := 0; n < 10; n++ {
+				if err := AtomicWriteFile(path, data, 0o644); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(contents[i])
+	}
+	readerDone := make(chan struct{})
+	go func() {
+		defer close(readerDone)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if len(data) != size || bytes.Count(data, data[:1]) != size {
+				t.Errorf("read a mixed or partial file of %d bytes", len(data))
+				return
+			}
+		}
+	}()
+	wg.Wait()
+	close(done)
+	<-readerDone
+
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("%d files left in the directory, want 1", len(entries))
+	}
+}
+
+func TestQuarantine(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "store.json")
+
+	var asides []string
+	for i := 0; i < 2; i++ {
+		os.WriteFile(path, []byte("bad"), 0o644)
+		aside, err := Quarantine(path)
+		if err != nil {
+			t.Fatalf("Quarantine: %v", err)
+		}
+		if !strings.HasPrefix(aside, path+".corrupt-") {
+			t.Errorf("moved to %s", aside)
+		}
+		if data, err := os.ReadFile(aside); err != nil || string(data) != "bad" {
+			t.Errorf("moved file holds %q, %v", data, err)
+		}
+		asides = append(asides, aside)
+	}
+	// Two in the same second do not collide
+	if asides[0] == asides[1] {
+		t.Errorf("both moved to %s", asides[0])
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("file still in place: %v", err)
+	}
+}
@@ -0,0 +1,113 @@
+package fileutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+var (
+	// ErrCorrupt is returned for a file that cannot be decoded, such as one
+	// cut short by a crash. Such a file can be moved aside with Quarantine.
+	ErrCorrupt = errors.New("corrupt file")
+	// ErrWrongFormat is returned for a readable file of another format.
+	ErrWrongFormat = errors.New("wrong file format")
+	// ErrUnsupportedVersion is returned for a file written by a newer
+	// version, or an older one with no migration.
+	ErrUnsupportedVersion = errors.New("unsupported file version")
+)
+
+// Migration upgrades the payload of a file from one version to the next.
+type Migration func(payload json.RawMessage) (json.RawMessage, error)
+
+// Format describes a versioned JSON file. On disk the file is an envelope
+// naming the format and version around the payload:
+//
+//	{"format": "bgengine-match", "version": 2, "payload": {...}}
+//
+// An envelope without a payload is a file from before envelopes, which kept
+// the format and version next to its own fields; its payload is the whole
+// document.
+type Format struct {
+	Name    string
+	Version int
+	// Migrations[v] upgrades a version v payload to version v+1. Reading a
+	// file older than Version needs every step up to it.
+	Migrations map[int]Migration
+}
+
+// envelope is the on-disk form of a Format file.
+type envelope struct {
+	Format  string          `json:"format"`
+	Version int             `json:"version"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// Marshal encodes v as the payload of a file of the current version.
+func (f Format) Marshal(v interface{}) ([]byte, error) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.MarshalIndent(envelope{Format: f.Name, Version: f.Version, Payload: payload}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// Unmarshal decodes a file of format f into v, migrating older versions.
+func (f Format) Unmarshal(data []byte, v interface{}) error {
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return fmt.Errorf("%w: %v", ErrCorrupt, err)
+	}
+	if env.Format != f.Name {
+		return fmt.Errorf("%w: %q, want %q", ErrWrongFormat, env.Format, f.Name)
+	}
+	if env.Version < 1 || env.Version > f.Version {
+		return fmt.Errorf("%w %d (want %d)", ErrUnsupportedVersion, env.Version, f.Version)
+	}
+	payload := env.Payload
+	if len(payload) == 0 {
+		payload = json.RawMessage(bytes.TrimSpace(data))
+	}
+	for version := env.Version; version < f.Version; version++ {
+		migrate := f.Migrations[version]
+		if migrate == nil {
+			return fmt.Errorf("%w %d: no migration to version %d", ErrUnsupportedVersion, version, version+1)
+		}
+		var err error
+		if payload, err = migrate(payload); err != nil {
+			return fmt.Errorf("migrating version %d: %w", version, err)
+		}
+	}
+	if err := json.Unmarshal(payload, v); err != nil {
+		return fmt.Errorf("%w: %v", ErrCorrupt, err)
+	}
+	return nil
+}
+
+// ReadFile reads the file at path into v. A missing file gives an error
+// satisfying os.IsNotExist.
+func (f Format) ReadFile(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := f.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// WriteFile writes v to path with AtomicWriteFile.
+func (f Format) WriteFile(path string, v interface{}) error {
+	data, err := f.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return AtomicWriteFile(path, data, 0o644)
+}
@@ -0,0 +1,112 @@
+package fileutil
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type testRecord struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func TestFormatRoundTrip(t *testing.T) {
+	f := Format{Name: "bgengine-test", Version: 1}
+	path := filepath.Join(t.TempDir(), "test.json")
+
+	if err := f.WriteFile(path, testRecord{Name: "a", Count: 3}); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	var got testRecord
+	if err := f.ReadFile(path, &got); err != nil || got != (testRecord{Name: "a", Count: 3}) {
+		t.Errorf("ReadFile = %+v, %v", got, err)
+	}
+
+	if err := f.ReadFile(filepath.Join(t.TempDir(), "missing.json"), &got); !os.IsNotExist(err) {
+		t.Errorf("missing file: %v", err)
+	}
+}
+
+func TestFormatErrors(t *testing.T) {
+	f := Format{Name: "bgengine-test", Version: 2}
+	full, _ := f.Marshal(testRecord{Name: "a", Count: 3})
+
+	tests := []struct {
+		name string
+		data string
+		want error
+	}{
+		{"truncated", string(full[:len(full)/2]), ErrCorrupt},
+		{"empty", "", ErrCorrupt},
+		{"bad payload", `{"format":"bgengine-test","version":2,"payload":{"count":"x"}}`, ErrCorrupt},
+		{"other format", `{"format":"other","version":2,"payload":{}}`, ErrWrongFormat},
+		{"no format", `{"name":"a"}`, ErrWrongFormat},
+		{"newer", `{"format":"bgengine-test","version":3,"payload":{}}`, ErrUnsupportedVersion},
+		{"no migration", `{"format":"bgengine-test","version":1,"payload":{}}`, ErrUnsupportedVersion},
+	}
+	for _, tt := range tests {
+		var got testRecord
+		if err := f.Unmarshal([]byte(tt.data), &got); !errors.Is(err, tt.want) {
+			t.Errorf("%s: %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestFormatMigration(t *testing.T) {
+	// Version 1 called the count "n"; version 2 renamed it; version 3
+	// doubled it
+	f := Format{
+		Name:    "bgengine-test",
+		Version: 3,
+		Migrations: map[int]Migration{
+			1: func(payload json.RawMessage) (json.RawMessage, error) {
+				var old struct {
+					Name string `json:"name"`
+					N    int    `json:"n"`
+				}
+				if err := json.Unmarshal(payload, &old); err != nil {
+					return nil, err
+				}
+				return json.Marshal(testRecord{Name: old.Name, Count: old.N})
+			},
+			2: func(payload json.RawMessage) (json.RawMessage, error) {
+				var r testRecord
+				if err := json.Unmarshal(payload, &r); err != nil {
+					return nil, err
+				}
+				r.Count *= 2
+				return json.Marshal(r)
+			},
+		},
+	}
+
+	tests := []struct {
+		name string
+		data string
+		want testRecord
+	}{
+		{"version 1", `{"format":"bgengine-test","version":1,"payload":{"name":"a","n":2}}`, testRecord{"a", 4}},
+		// Before envelopes the fields sat next to the format and version
+		{"version 1 without envelope", `{"format":"bgengine-test","version":1,"name":"b","n":5}`, testRecord{"b", 10}},
+		{"version 2", `{"format":"bgengine-test","version":2,"payload":{"name":"c","count":1}}`, testRecord{"c", 2}},
+		{"current", `{"format":"bgengine-test","version":3,"payload":{"name":"d","count":1}}`, testRecord{"d", 1}},
+	}
+	for _, tt := range tests {
+		var got testRecord
+		if err := f.Unmarshal([]byte(tt.data), &got); err != nil || got != tt.want {
+			t.Errorf("%s: %+v, %v; want %+v", tt.name, got, err, tt.want)
+		}
+	}
+
+	// A migration that fails names the version it started from
+	f.Migrations[2] = func(json.RawMessage) (json.RawMessage, error) { return nil, errors.New("boom") }
+	var got testRecord
+	err := f.Unmarshal([]byte(`{"format":"bgengine-test","version":2,"payload":{}}`), &got)
+	if err == nil || !strings.Contains(err.Error(), "version 2") {
+		t.Errorf("failed migration: %v", err)
+	}
+}
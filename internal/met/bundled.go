@@ -0,0 +1,86 @@
+package met
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// DefaultName is the name of the built-in table returned by Default.
+const DefaultName = "default"
+
+// bundled holds the tables compiled into the binary (tables/*.xml).
+//
+//go:embed tables
+var bundled embed.FS
+
+// Load returns a match equity table by name.
+// Names are the bundled file names without ".xml" and are case-insensitive;
+// DefaultName selects the built-in table.
+func Load(name string) (*Table, error) {
+	sub, err := fs.Sub(bundled, "tables")
+	if err != nil {
+		return nil, err
+	}
+	return loadFrom(sub, name)
+}
+
+// Available returns the names accepted by Load, sorted.
+func Available() []string {
+	sub, err := fs.Sub(bundled, "tables")
+	if err != nil {
+		return []string{DefaultName}
+	}
+	return availableIn(sub)
+}
+
+// loadFrom loads the named table from the XML files in fsys.
+func loadFrom(fsys fs.FS, name string) (*Table, error) {
+	key := strings.ToLower(strings.TrimSpace(name))
+	if key == DefaultName {
+		return Default(), nil
+	}
+
+	files, _ := fs.Glob(fsys, "*.xml")
+	for _, file := range files {
+		if TableName(file) != key {
+			continue
+		}
+		f, err := fsys.Open(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open MET %q: %w", name, err)
+		}
+		defer f.Close()
+
+		t, err := ParseXML(f)
+		if err != nil {
+			return nil, fmt.Errorf("MET %q: %w", name, err)
+		}
+		if t.Name == "" {
+			t.Name = key
+		}
+		return t, nil
+	}
+
+	return nil, fmt.Errorf("unknown MET %q (available: %s)", name, strings.Join(availableIn(fsys), ", "))
+}
+
+// availableIn lists the table names in fsys plus DefaultName.
+func availableIn(fsys fs.FS) []string {
+	names := []string{DefaultName}
+	files, _ := fs.Glob(fsys, "*.xml")
+	for _, file := range files {
+		names = append(names, TableName(file))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// TableName returns the name a table file is selected by: its base name
+// without ".xml", in lower case.
+func TableName(file string) string {
+	return strings.ToLower(strings.TrimSuffix(path.Base(file), ".xml"))
+}
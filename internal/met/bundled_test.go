@@ -0,0 +1,92 @@
+package met
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+const testXML = `<?xml version="1.0"?>
+<met>
+  <info><name>Test Table</name><length>2</length></info>
+  <pre-crawford-table type="explicit">
+    <row><me>0.5</me><me>0.7</me></row>
+    <row><me>0.3</me><me>0.5</me></row>
+  </pre-crawford-table>
+</met>`
+
+func TestLoadByName(t *testing.T) {
+	fsys := fstest.MapFS{
+		"Test-Table.xml": {Data: []byte(testXML)},
+		"README.md":      {Data: []byte("not a table")},
+	}
+
+	if got := availableIn(fsys); strings.Join(got, ",") != "default,test-table" {
+		t.Errorf("availableIn() = %v, want [default test-table]", got)
+	}
+
+	table, err := loadFrom(fsys, "TEST-table")
+	if err != nil {
+		t.Fatalf("loadFrom error: %v", err)
+	}
+	if table.Name != "Test Table" || table.Length != 2 {
+		t.Errorf("loaded %q length %d", table.Name, table.Length)
+	}
+	if me := table.GetME(1, 2, 3, 0, false); me != 0.3 {
+		t.Errorf("GetME(2-away, 1-away) = %v, want 0.3", me)
+	}
+
+	_, err = loadFrom(fsys, "kazaross-xg2")
+	if err == nil || !strings.Contains(err.Error(), "test-table") {
+		t.Errorf("unknown table error = %v, want list of available tables", err)
+	}
+}
+
+func TestLoadBundled(t *testing.T) {
+	names := Available()
+	if !contains(names, DefaultName) {
+		t.Fatalf("Available() = %v, missing %q", names, DefaultName)
+	}
+
+	for _, name := range names {
+		table, err := Load(name)
+		if err != nil {
+			t.Errorf("Load(%q) error: %v", name, err)
+			continue
+		}
+		if me := table.GetME(0, 0, 7, 0, false); me < 0.49 || me > 0.51 {
+			t.Errorf("%s: GetME at 0-0 = %v, want 0.5", name, me)
+		}
+	}
+}
+
+func TestLoadPublished(t *testing.T) {
+	for _, name := range []string{"kazaross-xg2", "g11", "jacobs-trice"} {
+		table, err := Load(name)
+		if err != nil {
+			t.Skipf("MET %s not bundled: %v", name, err)
+		}
+		if table.Length < 15 {
+			t.Errorf("%s: length %d, want at least 15", name, table.Length)
+		}
+
+		// 2-away against 7-away in a 7 point match: the published tables
+		// all give the leader about 80%
+		lead, trail := table.GetME(5, 0, 7, 0, false), table.GetME(0, 5, 7, 0, false)
+		if lead < 0.75 || lead > 0.85 {
+			t.Errorf("%s: GetME at 2-away/7-away = %v, want about 0.8", name, lead)
+		}
+		if sum := lead + trail; sum < 0.999 || sum > 1.001 {
+			t.Errorf("%s: GetME at 2-away/7-away and 7-away/2-away sum to %v, want 1", name, sum)
+		}
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
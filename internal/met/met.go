@@ -3,6 +3,7 @@
 package met
 
 import (
+	"bytes"
 	"encoding/xml"
 	"fmt"
 	"io"
@@ -69,14 +70,94 @@ func LoadXML(filename string) (*Table, error) {
 	return ParseXML(f)
 }
 
+// LoadXMLBytes loads a match equity table from the contents of an XML file,
+// for callers without a file system.
+func LoadXMLBytes(data []byte) (*Table, error) {
+	return ParseXML(bytes.NewReader(data))
+}
+
 // ParseXML parses a match equity table from XML
 func ParseXML(r io.Reader) (*Table, error) {
+	met, err := decodeXML(r)
+	if err != nil {
+		return nil, err
+	}
+	return met.table()
+}
+
+// CheckXML parses a match equity table from XML like ParseXML, but first
+// checks that the file follows gnubg's MET format strictly: explicit tables
+// of as many rows and values as the declared length, holding probabilities.
+// ParseXML tolerates short rows and leaves what they miss at zero.
+func CheckXML(r io.Reader) (*Table, error) {
+	met, err := decodeXML(r)
+	if err != nil {
+		return nil, err
+	}
+	if err := met.check(); err != nil {
+		return nil, err
+	}
+	return met.table()
+}
+
+func decodeXML(r io.Reader) (*xmlMET, error) {
 	var met xmlMET
 	decoder := xml.NewDecoder(r)
 	if err := decoder.Decode(&met); err != nil {
 		return nil, fmt.Errorf("failed to parse MET XML: %w", err)
 	}
+	return &met, nil
+}
+
+// check reports the first departure of met from gnubg's MET format.
+func (met *xmlMET) check() error {
+	n := met.Info.Length
+	if n < 1 || n > MaxScore {
+		return fmt.Errorf("info length %d, want 1-%d", n, MaxScore)
+	}
+
+	row := func(table string, i int, r xmlRow) error {
+		if len(r.Values) != n {
+			return fmt.Errorf("%s row %d has %d values, want %d (the length)", table, i+1, len(r.Values), n)
+		}
+		for j, val := range r.Values {
+			f, err := strconv.ParseFloat(strings.TrimSpace(val), 32)
+			if err != nil || f < 0 || f > 1 {
+				return fmt.Errorf("%s row %d value %d is %q, want a probability", table, i+1, j+1, val)
+			}
+		}
+		return nil
+	}
+
+	pre := met.PreCrawford
+	if pre.Type != "" && pre.Type != "explicit" {
+		return fmt.Errorf("pre-crawford-table type %q, only explicit tables are supported", pre.Type)
+	}
+	if len(pre.Rows) != n {
+		return fmt.Errorf("pre-crawford-table has %d rows, want %d (the length)", len(pre.Rows), n)
+	}
+	for i, r := range pre.Rows {
+		if err := row("pre-crawford-table", i, r); err != nil {
+			return err
+		}
+	}
+
+	for i, pc := range met.PostCrawford {
+		switch {
+		case pc.Player != "" && pc.Player != "0" && pc.Player != "1" && pc.Player != "both":
+			return fmt.Errorf("post-crawford-table %d player %q, want 0, 1 or both", i+1, pc.Player)
+		case pc.Type != "" && pc.Type != "explicit":
+			return fmt.Errorf("post-crawford-table %d type %q, only explicit tables are supported", i+1, pc.Type)
+		}
+		if err := row(fmt.Sprintf("post-crawford-table %d", i+1), 0, pc.Row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
+// table returns the match equity table met describes.
+func (met *xmlMET) table() (*Table, error) {
 	t := &Table{
 		Name:        met.Info.Name,
 		Description: met.Info.Description,
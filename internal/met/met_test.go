@@ -1,6 +1,7 @@
 package met
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -118,3 +119,47 @@ func TestSymmetry(t *testing.T) {
 	}
 }
 
+
+func TestCheckXML(t *testing.T) {
+	if _, err := CheckXML(strings.NewReader(testXML)); err != nil {
+		t.Fatalf("CheckXML(testXML) = %v", err)
+	}
+
+	tests := []struct {
+		name, old, new, want string
+	}{
+		{"short row", "<me>0.3</me><me>0.5</me>", "<me>0.3</me>", "pre-crawford-table row 2 has 1 values, want 2 (the length)"},
+		{"missing row", "<row><me>0.3</me><me>0.5</me></row>", "", "pre-crawford-table has 1 rows, want 2 (the length)"},
+		{"not a probability", "<me>0.7</me>", "<me>1.7</me>", `pre-crawford-table row 1 value 2 is "1.7", want a probability`},
+		{"length", "<length>2</length>", "<length>0</length>", "info length 0, want 1-64"},
+		{"formula", `type="explicit"`, `type="zadeh"`, `pre-crawford-table type "zadeh", only explicit tables are supported`},
+		{"root", "<met>", "<table>", "failed to parse MET XML"},
+	}
+	for _, tt := range tests {
+		xml := strings.Replace(testXML, tt.old, tt.new, 1)
+		if tt.name == "root" {
+			xml = strings.Replace(xml, "</met>", "</table>", 1)
+		}
+		_, err := CheckXML(strings.NewReader(xml))
+		if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+			t.Errorf("%s: CheckXML = %v, want %q", tt.name, err, tt.want)
+		}
+		// ParseXML stays lenient about the table's shape
+		if _, err := ParseXML(strings.NewReader(xml)); (err != nil) != (tt.name == "root") {
+			t.Errorf("%s: ParseXML = %v", tt.name, err)
+		}
+	}
+}
+
+func TestLoadXMLBytes(t *testing.T) {
+	table, err := LoadXMLBytes([]byte(testXML))
+	if err != nil {
+		t.Fatalf("LoadXMLBytes: %v", err)
+	}
+	if table.Name != "Test Table" || table.Length != 2 {
+		t.Errorf("loaded %q length %d", table.Name, table.Length)
+	}
+	if _, err := LoadXMLBytes([]byte("<table/>")); err == nil {
+		t.Error("LoadXMLBytes of a non-MET document succeeded")
+	}
+}
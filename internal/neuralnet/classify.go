@@ -1,9 +1,24 @@
 package neuralnet
 
+import (
+	"sync"
+
+	"github.com/yourusername/bgengine/internal/bearoff"
+)
+
 // ClassifyPosition determines the position class for evaluation.
 // This is a port of gnubg's ClassifyPosition function from eval.c.
 // For now, we only support standard backgammon (not hypergammon variants).
 func ClassifyPosition(board Board) PositionClass {
+	return ClassifyPositionOS(board, 0, 0)
+}
+
+// ClassifyPositionOS is ClassifyPosition with a one-sided bearoff database
+// of osPoints points and osChequers checkers available besides the 6-point
+// ones, such as gnubg's gnubg_os.bd with 10 or 12 points. Races that fit
+// neither 6-point database but fit that one are ClassBearoffOS. An osPoints
+// of 6 or less classifies exactly like ClassifyPosition.
+func ClassifyPositionOS(board Board, osPoints, osChequers int) PositionClass {
 	// Find the back checker for each side
 	nOppBack := -1
 	for i := 24; i >= 0; i-- {
@@ -75,6 +90,10 @@ func ClassifyPosition(board Board) PositionClass {
 		return ClassBearoff1
 	}
 
+	if osPoints > 6 && IsBearoff(board, min(osPoints, bearoff.MaxPoints), osChequers) {
+		return ClassBearoffOS
+	}
+
 	return ClassRace
 }
 
@@ -106,11 +125,12 @@ func IsBearoff(board Board, nPoints, nChequers int) bool {
 	return true
 }
 
-// GetBearoffBoard extracts the bearoff portion of the board (first 6 points)
-func GetBearoffBoard(board Board) [2][6]uint8 {
-	var result [2][6]uint8
+// GetBearoffBoard extracts the bearoff portion of the board (the first
+// bearoff.MaxPoints points). A database only reads the points it covers.
+func GetBearoffBoard(board Board) bearoff.Board {
+	var result bearoff.Board
 	for side := 0; side < 2; side++ {
-		for i := 0; i < 6; i++ {
+		for i := 0; i < bearoff.MaxPoints; i++ {
 			result[side][i] = board[side][i]
 		}
 	}
@@ -123,14 +143,15 @@ var anPoint = [16]int{0, 0, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1}
 // anEscapes and anEscapes1 are lookup tables for escape calculations
 var anEscapes [0x1000]int
 var anEscapes1 [0x1000]int
-var escapesInitialized = false
+var escapesOnce sync.Once
 
 // initEscapeTables initializes the escape lookup tables
 func initEscapeTables() {
-	if escapesInitialized {
-		return
-	}
+	escapesOnce.Do(buildEscapeTables)
+}
 
+// buildEscapeTables fills anEscapes and anEscapes1
+func buildEscapeTables() {
 	// ComputeTable0
 	for i := 0; i < 0x1000; i++ {
 		c := 0
@@ -177,8 +198,6 @@ func initEscapeTables() {
 		}
 		anEscapes1[i] = c
 	}
-
-	escapesInitialized = true
 }
 
 // Escapes calculates how many rolls let a checker escape from point n
@@ -0,0 +1,75 @@
+package neuralnet
+
+import (
+	"math"
+	"testing"
+)
+
+func TestBarEntry(t *testing.T) {
+	// Opponent's home board points made, 1-point first
+	board := func(made ...int) [25]uint8 {
+		var b [25]uint8
+		for _, p := range made {
+			b[p-1] = 2
+		}
+		return b
+	}
+
+	cases := []struct {
+		name          string
+		onBar         uint8
+		opp           [25]uint8
+		enter, enter2 float32
+	}{
+		// Every roll loses all its pips: 294 pips in 36 rolls of 49/6 each
+		{"closed, one on bar", 1, board(1, 2, 3, 4, 5, 6), 1, 1},
+		{"closed, two on bar", 2, board(1, 2, 3, 4, 5, 6), 1, 1},
+		// Doubles 1-5 lose 60 pips, non-doubles without a 6 another 120
+		{"five points, one on bar", 1, board(1, 2, 3, 4, 5), 180.0 / 294, 35.0 / 36},
+		// With two on the bar a 6-x enters one checker and loses the x
+		{"five points, two on bar", 2, board(1, 2, 3, 4, 5), 210.0 / 294, 35.0 / 36},
+		{"open board", 1, board(), 0, 0},
+		{"ace point only, two on bar", 2, board(1), 14.0 / 294, 11.0 / 36},
+	}
+	for _, c := range cases {
+		var own [25]uint8
+		own[24] = c.onBar
+		own[0] = 15 - c.onBar
+		enter, enter2 := calculateBarEntry(own, c.opp)
+		if math.Abs(float64(enter-c.enter)) > 1e-6 || math.Abs(float64(enter2-c.enter2)) > 1e-6 {
+			t.Errorf("%s: enter %.4f, enter2 %.4f, want %.4f, %.4f", c.name, enter, enter2, c.enter, c.enter2)
+		}
+	}
+}
+
+func TestHitStatsIntermediateBlots(t *testing.T) {
+	// Shots that hit a blot on the way to another count two chequers hit,
+	// as in gnubg
+	cases := []struct {
+		name            string
+		from            int // Point of our hitter, 24 = the bar
+		blots           [2]int
+		piploss, p1, p2 float32
+	}{
+		// 11 and 21 pass the blot 1 away on their way to the one 3 away
+		{"from the 11-point", 10, [2]int{9, 7}, 182.0 / 432, 20.0 / 36, 3.0 / 36},
+		// The same from the bar, where 31 hits both directly
+		{"from the bar", 24, [2]int{23, 21}, 462.0 / 432, 20.0 / 36, 5.0 / 36},
+	}
+	for _, c := range cases {
+		var own, opp [25]uint8
+		own[c.from] = 1
+		own[0] = 14
+		for _, p := range c.blots {
+			opp[23-p] = 1
+		}
+		opp[12] = 13
+
+		piploss, p1, p2 := calculateHitStats(own, opp, 0)
+		if math.Abs(float64(piploss-c.piploss)) > 1e-6 || math.Abs(float64(p1-c.p1)) > 1e-6 ||
+			math.Abs(float64(p2-c.p2)) > 1e-6 {
+			t.Errorf("%s: piploss %.4f, p1 %.4f, p2 %.4f, want %.4f, %.4f, %.4f",
+				c.name, piploss, p1, p2, c.piploss, c.p1, c.p2)
+		}
+	}
+}
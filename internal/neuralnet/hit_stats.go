@@ -215,7 +215,7 @@ func calculateHitStats(anBoard, anBoardOpp [25]uint8, nOppBack int) (piploss, p1
 	// Process based on bar status
 	if anBoard[24] == 0 {
 		// Not on bar
-		calculateHitsNotOnBar(anBoard, aHit[:], aRoll[:])
+		calculateHitsNotOnBar(anBoard, anBoardOpp, aHit[:], aRoll[:])
 	} else if anBoard[24] == 1 {
 		// One checker on bar
 		calculateHitsOneOnBar(anBoard, anBoardOpp, aHit[:], aRoll[:])
@@ -263,7 +263,7 @@ type rollStat struct {
 }
 
 // calculateHitsNotOnBar processes hits when not on bar
-func calculateHitsNotOnBar(anBoard [25]uint8, aHit []int, aRoll []rollStat) {
+func calculateHitsNotOnBar(anBoard, anBoardOpp [25]uint8, aHit []int, aRoll []rollStat) {
 	for i := 0; i < 21; i++ {
 		n := -1 // hitter used
 
@@ -304,11 +304,28 @@ func calculateHitsNotOnBar(anBoard [25]uint8, aHit []int, aRoll []rollStat) {
 				if pips := k - pi.nPips + 1; pips > aRoll[i].nPips {
 					aRoll[i].nPips = pips
 				}
+
+				// A blot on an intermediate point is hit on the way
+				if hitsIntermediateBlot(anBoardOpp, pi, k) {
+					aRoll[i].nChequers++
+				}
 			}
 		}
 	}
 }
 
+// hitsIntermediateBlot reports whether the indirect shot pi played from point
+// k (the bar is 24) passes an intermediate point with an opponent blot on it.
+// Point k-x from our side is point 23-k+x from the opponent's.
+func hitsIntermediateBlot(anBoardOpp [25]uint8, pi *intermediate, k int) bool {
+	for l := 0; l < 3 && pi.anIntermediate[l] > 0; l++ {
+		if anBoardOpp[23-k+pi.anIntermediate[l]] == 1 {
+			return true
+		}
+	}
+	return false
+}
+
 // calculateHitsOneOnBar processes hits with one checker on bar
 func calculateHitsOneOnBar(anBoard, anBoardOpp [25]uint8, aHit []int, aRoll []rollStat) {
 	for i := 0; i < 21; i++ {
@@ -364,6 +381,10 @@ func calculateHitsOneOnBar(anBoard, anBoardOpp [25]uint8, aHit []int, aRoll []ro
 				if pips := 25 - pi.nPips; pips > aRoll[i].nPips {
 					aRoll[i].nPips = pips
 				}
+
+				if hitsIntermediateBlot(anBoardOpp, pi, 24) {
+					aRoll[i].nChequers++
+				}
 			}
 		}
 	}
@@ -1,5 +1,7 @@
 package neuralnet
 
+import "fmt"
+
 // Input encoding for neural network evaluation
 // This is a port of gnubg's inputs.c and eval.c input calculation
 
@@ -17,6 +19,25 @@ const (
 	ClassContact                        // Contact neural network
 )
 
+var classNames = [...]string{
+	ClassOver:      "over",
+	ClassBearoff2:  "bearoff2",
+	ClassBearoffTS: "bearoff_ts",
+	ClassBearoff1:  "bearoff1",
+	ClassBearoffOS: "bearoff_os",
+	ClassRace:      "race",
+	ClassCrashed:   "crashed",
+	ClassContact:   "contact",
+}
+
+// String returns the name of the position class.
+func (c PositionClass) String() string {
+	if c >= 0 && int(c) < len(classNames) {
+		return classNames[c]
+	}
+	return fmt.Sprintf("class(%d)", int(c))
+}
+
 // Input counts for different network types
 const (
 	MinPPerPoint     = 4
@@ -0,0 +1,122 @@
+package neuralnet
+
+// Kernels compute the hidden layer of EvaluateFast, which is where nearly
+// all of its time goes: for each active input, the input's row of hidden
+// weights is added to the hidden activations. The sigmoid table and the
+// output layer are the same Go code whichever kernel runs.
+//
+// Every kernel produces bit-identical results to KernelGo. Weights are
+// widened to float64 exactly, and products are rounded before they are
+// added (no fused multiply-add), so each kernel rounds every activation as
+// the pure Go loop does, in the same order.
+
+// Kernel names, for Kernels, SelectKernel and NewEvaluateBufferKernel
+const (
+	KernelGo   = "go"   // Pure Go, on every CPU
+	KernelAVX2 = "avx2" // AVX2 assembly, on amd64 CPUs with AVX2
+)
+
+// CPUFeatures are the vector extensions the CPU and operating system
+// support, as detected when the package is initialized.
+type CPUFeatures struct {
+	AVX2 bool // amd64: 256-bit integer and float vectors
+	FMA  bool // amd64: fused multiply-add
+	NEON bool // arm64: Advanced SIMD
+}
+
+// Names returns the names of the features present ("avx2", "fma", "neon").
+func (f CPUFeatures) Names() []string {
+	var names []string
+	if f.AVX2 {
+		names = append(names, "avx2")
+	}
+	if f.FMA {
+		names = append(names, "fma")
+	}
+	if f.NEON {
+		names = append(names, "neon")
+	}
+	return names
+}
+
+// kernel is an implementation of the hidden layer arithmetic.
+type kernel struct {
+	name string
+	// add adds w[j] to dst[j] for each j < len(dst)
+	add func(dst []float64, w []float32)
+	// mulAdd adds w[j]*a, rounded to float64, to dst[j] for each j < len(dst)
+	mulAdd func(dst []float64, w []float32, a float64)
+}
+
+var (
+	cpuFeatures = detectCPU()
+	goKernel    = &kernel{name: KernelGo, add: addGo, mulAdd: mulAddGo}
+	kernels     = append(archKernels(cpuFeatures), goKernel)
+)
+
+// Features returns the vector extensions of the CPU.
+func Features() CPUFeatures {
+	return cpuFeatures
+}
+
+// Kernels returns the names of the kernels this CPU can run, fastest first.
+// KernelGo is always last.
+func Kernels() []string {
+	names := make([]string, len(kernels))
+	for i, k := range kernels {
+		names[i] = k.name
+	}
+	return names
+}
+
+// SelectKernel returns the name of the fastest kernel this CPU can run, or
+// KernelGo if forceScalar is set.
+func SelectKernel(forceScalar bool) string {
+	if forceScalar {
+		return KernelGo
+	}
+	return kernels[0].name
+}
+
+// kernelNamed returns the kernel with the given name, or the pure Go kernel
+// if the CPU cannot run it.
+func kernelNamed(name string) *kernel {
+	for _, k := range kernels {
+		if k.name == name {
+			return k
+		}
+	}
+	return goKernel
+}
+
+// addGo is the pure Go add kernel.
+func addGo(dst []float64, w []float32) {
+	w = w[:len(dst)]
+	j := 0
+	for ; j <= len(dst)-4; j += 4 {
+		dst[j] += float64(w[j])
+		dst[j+1] += float64(w[j+1])
+		dst[j+2] += float64(w[j+2])
+		dst[j+3] += float64(w[j+3])
+	}
+	for ; j < len(dst); j++ {
+		dst[j] += float64(w[j])
+	}
+}
+
+// mulAddGo is the pure Go multiply-add kernel. The conversions round each
+// product before it is added, which keeps the compiler from fusing the two
+// on CPUs with FMA.
+func mulAddGo(dst []float64, w []float32, a float64) {
+	w = w[:len(dst)]
+	j := 0
+	for ; j <= len(dst)-4; j += 4 {
+		dst[j] += float64(float64(w[j]) * a)
+		dst[j+1] += float64(float64(w[j+1]) * a)
+		dst[j+2] += float64(float64(w[j+2]) * a)
+		dst[j+3] += float64(float64(w[j+3]) * a)
+	}
+	for ; j < len(dst); j++ {
+		dst[j] += float64(float64(w[j]) * a)
+	}
+}
@@ -0,0 +1,66 @@
+package neuralnet
+
+// cpuid executes CPUID with the given leaf and subleaf.
+func cpuid(leaf, subleaf uint32) (eax, ebx, ecx, edx uint32)
+
+// xgetbv returns the low word of XCR0, the vector state the operating
+// system saves.
+func xgetbv() uint32
+
+// addAVX2Asm and mulAddAVX2Asm run the kernels over n elements, a multiple
+// of 4.
+func addAVX2Asm(dst *float64, w *float32, n int)
+func mulAddAVX2Asm(dst *float64, w *float32, n int, a float64)
+
+// detectCPU reads the AVX2 and FMA feature bits. Both need the operating
+// system to save the YMM registers, which XCR0 tells.
+func detectCPU() CPUFeatures {
+	maxLeaf, _, _, _ := cpuid(0, 0)
+	if maxLeaf < 7 {
+		return CPUFeatures{}
+	}
+	_, _, ecx1, _ := cpuid(1, 0)
+	const (
+		fma     = 1 << 12
+		osxsave = 1 << 27
+		avx     = 1 << 28
+	)
+	if ecx1&osxsave == 0 || ecx1&avx == 0 {
+		return CPUFeatures{}
+	}
+	// XMM and YMM state
+	if xgetbv()&6 != 6 {
+		return CPUFeatures{}
+	}
+	_, ebx7, _, _ := cpuid(7, 0)
+	return CPUFeatures{
+		AVX2: ebx7&(1<<5) != 0,
+		FMA:  ecx1&fma != 0,
+	}
+}
+
+// archKernels returns the assembly kernels the CPU can run, fastest first.
+func archKernels(f CPUFeatures) []*kernel {
+	if !f.AVX2 {
+		return nil
+	}
+	return []*kernel{{name: KernelAVX2, add: addAVX2, mulAdd: mulAddAVX2}}
+}
+
+func addAVX2(dst []float64, w []float32) {
+	w = w[:len(dst)]
+	n := len(dst) &^ 3
+	if n > 0 {
+		addAVX2Asm(&dst[0], &w[0], n)
+	}
+	addGo(dst[n:], w[n:])
+}
+
+func mulAddAVX2(dst []float64, w []float32, a float64) {
+	w = w[:len(dst)]
+	n := len(dst) &^ 3
+	if n > 0 {
+		mulAddAVX2Asm(&dst[0], &w[0], n, a)
+	}
+	mulAddGo(dst[n:], w[n:], a)
+}
@@ -0,0 +1,90 @@
+#include "textflag.h"
+
+// func cpuid(leaf, subleaf uint32) (eax, ebx, ecx, edx uint32)
+TEXT ·cpuid(SB), NOSPLIT, $0-24
+	MOVL leaf+0(FP), AX
+	MOVL subleaf+4(FP), CX
+	CPUID
+	MOVL AX, eax+8(FP)
+	MOVL BX, ebx+12(FP)
+	MOVL CX, ecx+16(FP)
+	MOVL DX, edx+20(FP)
+	RET
+
+// func xgetbv() uint32
+TEXT ·xgetbv(SB), NOSPLIT, $0-4
+	MOVL $0, CX
+	BYTE $0x0f; BYTE $0x01; BYTE $0xd0 // XGETBV
+	MOVL AX, ret+0(FP)
+	RET
+
+// func addAVX2Asm(dst *float64, w *float32, n int)
+// dst[j] += float64(w[j]) for j < n, 8 at a time and then 4
+TEXT ·addAVX2Asm(SB), NOSPLIT, $0-24
+	MOVQ dst+0(FP), DI
+	MOVQ w+8(FP), SI
+	MOVQ n+16(FP), CX
+	SHRQ $2, CX
+
+addloop8:
+	CMPQ      CX, $2
+	JB        addtail
+	VCVTPS2PD (SI), Y0
+	VCVTPS2PD 16(SI), Y1
+	VADDPD    (DI), Y0, Y0
+	VADDPD    32(DI), Y1, Y1
+	VMOVUPD   Y0, (DI)
+	VMOVUPD   Y1, 32(DI)
+	ADDQ      $32, SI
+	ADDQ      $64, DI
+	SUBQ      $2, CX
+	JMP       addloop8
+
+addtail:
+	TESTQ     CX, CX
+	JZ        adddone
+	VCVTPS2PD (SI), Y0
+	VADDPD    (DI), Y0, Y0
+	VMOVUPD   Y0, (DI)
+
+adddone:
+	VZEROUPPER
+	RET
+
+// func mulAddAVX2Asm(dst *float64, w *float32, n int, a float64)
+// dst[j] += float64(w[j])*a for j < n, 8 at a time and then 4. The product is
+// rounded before the addition, as in mulAddGo.
+TEXT ·mulAddAVX2Asm(SB), NOSPLIT, $0-32
+	MOVQ         dst+0(FP), DI
+	MOVQ         w+8(FP), SI
+	MOVQ         n+16(FP), CX
+	VBROADCASTSD a+24(FP), Y1
+	SHRQ         $2, CX
+
+muladdloop8:
+	CMPQ      CX, $2
+	JB        muladdtail
+	VCVTPS2PD (SI), Y0
+	VCVTPS2PD 16(SI), Y2
+	VMULPD    Y1, Y0, Y0
+	VMULPD    Y1, Y2, Y2
+	VADDPD    (DI), Y0, Y0
+	VADDPD    32(DI), Y2, Y2
+	VMOVUPD   Y0, (DI)
+	VMOVUPD   Y2, 32(DI)
+	ADDQ      $32, SI
+	ADDQ      $64, DI
+	SUBQ      $2, CX
+	JMP       muladdloop8
+
+muladdtail:
+	TESTQ     CX, CX
+	JZ        muladddone
+	VCVTPS2PD (SI), Y0
+	VMULPD    Y1, Y0, Y0
+	VADDPD    (DI), Y0, Y0
+	VMOVUPD   Y0, (DI)
+
+muladddone:
+	VZEROUPPER
+	RET
@@ -0,0 +1,11 @@
+package neuralnet
+
+// detectCPU reports Advanced SIMD, which every arm64 CPU Go runs on has.
+func detectCPU() CPUFeatures {
+	return CPUFeatures{NEON: true}
+}
+
+// archKernels returns no kernels: arm64 runs the pure Go kernel.
+func archKernels(CPUFeatures) []*kernel {
+	return nil
+}
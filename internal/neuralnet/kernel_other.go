@@ -0,0 +1,14 @@
+//go:build !amd64 && !arm64
+
+package neuralnet
+
+// detectCPU reports no vector extensions on other architectures.
+func detectCPU() CPUFeatures {
+	return CPUFeatures{}
+}
+
+// archKernels returns no kernels: other architectures run the pure Go
+// kernel.
+func archKernels(CPUFeatures) []*kernel {
+	return nil
+}
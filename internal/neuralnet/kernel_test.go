@@ -0,0 +1,153 @@
+package neuralnet
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// randomNet returns a net of the given shape with weights of the size
+// trained nets have.
+func randomNet(rng *rand.Rand, cInput, cHidden, cOutput uint32) *NeuralNet {
+	weights := func(n uint32, scale float64) []float32 {
+		w := make([]float32, n)
+		for i := range w {
+			w[i] = float32(rng.NormFloat64() * scale)
+		}
+		return w
+	}
+	return &NeuralNet{
+		CInput:          cInput,
+		CHidden:         cHidden,
+		COutput:         cOutput,
+		RBetaHidden:     0.1,
+		RBetaOutput:     1,
+		HiddenWeight:    weights(cInput*cHidden, 1),
+		OutputWeight:    weights(cHidden*cOutput, 0.5),
+		HiddenThreshold: weights(cHidden, 1),
+		OutputThreshold: weights(cOutput, 0.5),
+	}
+}
+
+// randomInput returns net inputs as the input functions produce them:
+// mostly 0 or 1, with some fractions.
+func randomInput(rng *rand.Rand, n uint32) []float32 {
+	input := make([]float32, n)
+	for i := range input {
+		switch r := rng.Intn(10); {
+		case r < 5:
+		case r < 8:
+			input[i] = 1
+		default:
+			input[i] = rng.Float32() * 2
+		}
+	}
+	return input
+}
+
+func TestKernelsEquivalent(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	// The kernels themselves, on lengths that are not a multiple of the
+	// vector width and on slices that do not start aligned
+	for _, name := range Kernels() {
+		k := kernelNamed(name)
+		for n := 0; n <= 37; n++ {
+			w := make([]float32, n+1)
+			for i := range w {
+				w[i] = float32(rng.NormFloat64())
+			}
+			a := rng.NormFloat64()
+			for _, mul := range []bool{false, true} {
+				want := make([]float64, n+1)
+				got := make([]float64, n+1)
+				for i := range want {
+					want[i] = rng.NormFloat64()
+					got[i] = want[i]
+				}
+				if mul {
+					mulAddGo(want[1:], w[1:], a)
+					k.mulAdd(got[1:], w[1:], a)
+				} else {
+					addGo(want[1:], w[1:])
+					k.add(got[1:], w[1:])
+				}
+				for i := range want {
+					if math.Float64bits(got[i]) != math.Float64bits(want[i]) {
+						t.Fatalf("%s kernel (multiply %v), length %d: element %d = %v, want %v", name, mul, n, i, got[i], want[i])
+					}
+				}
+			}
+		}
+	}
+
+	// Whole evaluations, on the shapes of the nets and odd ones
+	shapes := [][3]uint32{{NumContactInputs, 128, 5}, {NumPruningInputs, 5, 5}, {214, 128, 5}, {7, 13, 3}}
+	for _, shape := range shapes {
+		nn := randomNet(rng, shape[0], shape[1], shape[2])
+		ref := NewEvaluateBufferKernel(nn.CInput, nn.CHidden, KernelGo)
+		for trial := 0; trial < 200; trial++ {
+			input := randomInput(rng, nn.CInput)
+			want := make([]float32, nn.COutput)
+			nn.EvaluateFast(input, want, ref)
+			for _, name := range Kernels() {
+				got := make([]float32, nn.COutput)
+				nn.EvaluateFast(input, got, NewEvaluateBufferKernel(nn.CInput, nn.CHidden, name))
+				for i := range want {
+					if math.Float32bits(got[i]) != math.Float32bits(want[i]) {
+						t.Fatalf("%s kernel, net %v: output %d = %v, want %v", name, shape, i, got[i], want[i])
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestSelectKernel(t *testing.T) {
+	names := Kernels()
+	if len(names) == 0 || names[len(names)-1] != KernelGo {
+		t.Fatalf("Kernels() = %v, want %q last", names, KernelGo)
+	}
+	if got := SelectKernel(false); got != names[0] {
+		t.Errorf("SelectKernel(false) = %q, want the fastest, %q", got, names[0])
+	}
+	if got := SelectKernel(true); got != KernelGo {
+		t.Errorf("SelectKernel(true) = %q, want %q", got, KernelGo)
+	}
+
+	hasAVX2 := false
+	for _, name := range names {
+		hasAVX2 = hasAVX2 || name == KernelAVX2
+	}
+	if hasAVX2 != Features().AVX2 {
+		t.Errorf("Kernels() = %v with features %+v", names, Features())
+	}
+
+	if got := NewEvaluateBufferKernel(4, 4, "sse9").Kernel(); got != KernelGo {
+		t.Errorf("unknown kernel runs %q, want %q", got, KernelGo)
+	}
+	if got := NewEvaluateBuffer(4, 4).Kernel(); got != names[0] {
+		t.Errorf("NewEvaluateBuffer runs %q, want %q", got, names[0])
+	}
+}
+
+// BenchmarkKernels compares the kernels on a net of the contact net's shape
+// with the inputs of random positions.
+func BenchmarkKernels(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	nn := randomNet(rng, NumContactInputs, 128, 5)
+	inputs := make([][]float32, 64)
+	for i := range inputs {
+		inputs[i] = ContactInputs(RandomPosition(rng))
+	}
+	output := make([]float32, nn.COutput)
+
+	for _, name := range Kernels() {
+		b.Run(name, func(b *testing.B) {
+			buf := NewEvaluateBufferKernel(nn.CInput, nn.CHidden, name)
+			for i := 0; i < b.N; i++ {
+				nn.EvaluateFast(inputs[i%len(inputs)], output, buf)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package neuralnet
+
+import "fmt"
+
+// Input names, for inspecting what a net was given. Side 0 of the board is
+// the opponent and side 1 the player on roll.
+var sideNames = [2]string{"opponent", "player"}
+
+// contactFeatureNames names the extra contact and crashed inputs by their index.
+var contactFeatureNames = [MoreInputs]string{
+	iOff1:          "off1",
+	iOff2:          "off2",
+	iOff3:          "off3",
+	iBreakContact:  "break_contact",
+	iBackChequer:   "back_chequer",
+	iBackAnchor:    "back_anchor",
+	iForwardAnchor: "forward_anchor",
+	iPiploss:       "piploss",
+	iP1:            "p1",
+	iP2:            "p2",
+	iBackescapes:   "back_escapes",
+	iAcontain:      "acontain",
+	iAcontain2:     "acontain2",
+	iContain:       "contain",
+	iContain2:      "contain2",
+	iMobility:      "mobility",
+	iMoment2:       "moment2",
+	iEnter:         "enter",
+	iEnter2:        "enter2",
+	iTiming:        "timing",
+	iBackbone:      "backbone",
+	iBackg:         "backg",
+	iBackg1:        "backg1",
+	iFreepip:       "freepip",
+	iBackrescapes:  "back_rescapes",
+}
+
+// InputNames returns the name of each input of the net for class, in the
+// order built by ContactInputs (ClassContact), CrashedInputs (ClassCrashed)
+// or RaceInputs (ClassRace). It returns nil for the other classes.
+//
+// Names are "<side>.<feature>", for example "player.point6.2" (the player has
+// exactly two checkers on their 6 point) or "opponent.break_contact".
+func InputNames(class PositionClass) []string {
+	switch class {
+	case ClassContact, ClassCrashed:
+		return contactInputNames(class == ClassCrashed)
+	case ClassRace:
+		return raceInputNames()
+	default:
+		return nil
+	}
+}
+
+// pointInputNames names the four inputs encoding the checkers on a point.
+func pointInputNames(side, point string) []string {
+	return []string{
+		side + "." + point + ".1",
+		side + "." + point + ".2",
+		side + "." + point + ".3+",
+		side + "." + point + ".extra",
+	}
+}
+
+func contactInputNames(crashed bool) []string {
+	names := make([]string, 0, NumContactInputs)
+	for _, side := range sideNames {
+		for i := 0; i < 24; i++ {
+			names = append(names, pointInputNames(side, fmt.Sprintf("point%d", i+1))...)
+		}
+		names = append(names, side+".bar.1+", side+".bar.2+", side+".bar.3+", side+".bar.extra")
+	}
+
+	// Each block of extra inputs describes one side, except for the men off:
+	// gnubg's nets were trained with those swapped in contact positions, and
+	// ContactInputsInto follows it.
+	for _, side := range []int{1, 0} {
+		offSide := 1 - side
+		if crashed {
+			offSide = side
+		}
+		for i, feature := range contactFeatureNames {
+			s := side
+			if i <= iOff3 {
+				s = offSide
+			}
+			names = append(names, sideNames[s]+"."+feature)
+		}
+	}
+	return names
+}
+
+func raceInputNames() []string {
+	names := make([]string, 0, NumRaceInputs)
+	for _, side := range sideNames {
+		for i := 0; i < 23; i++ {
+			names = append(names, pointInputNames(side, fmt.Sprintf("point%d", i+1))...)
+		}
+		for k := 1; k <= 14; k++ {
+			names = append(names, fmt.Sprintf("%s.off%d", side, k))
+		}
+		names = append(names, side+".crossovers")
+	}
+	return names
+}
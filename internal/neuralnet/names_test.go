@@ -0,0 +1,92 @@
+package neuralnet
+
+import "testing"
+
+func TestInputNames(t *testing.T) {
+	for _, c := range []struct {
+		class PositionClass
+		want  int
+	}{
+		{ClassContact, NumContactInputs},
+		{ClassCrashed, NumContactInputs},
+		{ClassRace, NumRaceInputs},
+	} {
+		names := InputNames(c.class)
+		if len(names) != c.want {
+			t.Errorf("%v: %d names, want %d", c.class, len(names), c.want)
+		}
+		seen := make(map[string]bool)
+		for i, name := range names {
+			if name == "" || seen[name] {
+				t.Errorf("%v: input %d has empty or repeated name %q", c.class, i, name)
+			}
+			seen[name] = true
+		}
+	}
+	if names := InputNames(ClassBearoff1); names != nil {
+		t.Errorf("bearoff class has %d input names, want none", len(names))
+	}
+}
+
+// TestInputNamesMatchInputs checks that the names follow the input builders.
+func TestInputNamesMatchInputs(t *testing.T) {
+	var board Board
+	board[1][24] = 1 // Player on the bar
+	board[1][5] = 2  // Two on the player's 6 point
+	board[1][12] = 4
+	board[0][5] = 5
+	board[0][12] = 5 // Opponent has 5 off
+
+	input := func(class PositionClass, inputs []float32, name string) float32 {
+		t.Helper()
+		for i, n := range InputNames(class) {
+			if n == name {
+				return inputs[i]
+			}
+		}
+		t.Fatalf("%v: no input named %q", class, name)
+		return 0
+	}
+
+	contact := ContactInputs(board)
+	crashed := CrashedInputs(board)
+	for _, c := range []struct {
+		class  PositionClass
+		inputs []float32
+	}{{ClassContact, contact}, {ClassCrashed, crashed}} {
+		if got := input(c.class, c.inputs, "player.bar.1+"); got != 1 {
+			t.Errorf("%v: player.bar.1+ = %v, want 1", c.class, got)
+		}
+		if got := input(c.class, c.inputs, "player.point6.2"); got != 1 {
+			t.Errorf("%v: player.point6.2 = %v, want 1", c.class, got)
+		}
+		if got := input(c.class, c.inputs, "opponent.point6.extra"); got != 1 {
+			t.Errorf("%v: opponent.point6.extra = %v, want 1", c.class, got)
+		}
+	}
+
+	// The opponent has 5 off and the player 8
+	if got := input(ClassContact, contact, "opponent.off2"); got != float32(2)/3 {
+		t.Errorf("contact opponent.off2 = %v, want 2/3", got)
+	}
+	if got := input(ClassCrashed, crashed, "opponent.off1"); got != 1 {
+		t.Errorf("crashed opponent.off1 = %v, want 1", got)
+	}
+	if got := input(ClassCrashed, crashed, "player.off2"); got != float32(3)/5 {
+		t.Errorf("crashed player.off2 = %v, want 3/5", got)
+	}
+
+	var race Board
+	race[1][0] = 3
+	race[0][2] = 1
+	inputs := RaceInputs(race)
+	if got := input(ClassRace, inputs, "player.point1.3+"); got != 1 {
+		t.Errorf("race player.point1.3+ = %v, want 1", got)
+	}
+	if got := input(ClassRace, inputs, "player.off12"); got != 1 {
+		t.Errorf("race player.off12 = %v, want 1", got)
+	}
+	if got := input(ClassRace, inputs, "opponent.off14"); got != 1 {
+		t.Errorf("race opponent.off14 = %v, want 1", got)
+	}
+}
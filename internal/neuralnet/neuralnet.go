@@ -136,55 +136,8 @@ func sigmoid(x float32) float32 {
 	return float32(1.0 / (1.0 + math.Exp(float64(x))))
 }
 
-// LoadText loads a neural network from a text file format
+// LoadText loads a neural network from a text file format. Failures are
+// *WeightsError values naming the line and layer they occurred at.
 func LoadText(r io.Reader) (*NeuralNet, error) {
-	nn := &NeuralNet{}
-
-	var dummy string
-	_, err := fmt.Fscanf(r, "%d %d %d %s %f %f\n",
-		&nn.CInput, &nn.CHidden, &nn.COutput, &dummy, &nn.RBetaHidden, &nn.RBetaOutput)
-	if err != nil {
-		return nil, fmt.Errorf("reading header: %w", err)
-	}
-
-	// Validate
-	if nn.CInput < 1 || nn.CHidden < 1 || nn.COutput < 1 {
-		return nil, fmt.Errorf("invalid network dimensions: %d/%d/%d", nn.CInput, nn.CHidden, nn.COutput)
-	}
-	if nn.RBetaHidden <= 0 || nn.RBetaOutput <= 0 {
-		return nil, fmt.Errorf("invalid beta values: %f/%f", nn.RBetaHidden, nn.RBetaOutput)
-	}
-
-	nn.NTrained = 1
-
-	// Allocate and read weights
-	nn.HiddenWeight = make([]float32, nn.CInput*nn.CHidden)
-	for i := range nn.HiddenWeight {
-		if _, err := fmt.Fscanf(r, "%f\n", &nn.HiddenWeight[i]); err != nil {
-			return nil, fmt.Errorf("reading hidden weight %d: %w", i, err)
-		}
-	}
-
-	nn.OutputWeight = make([]float32, nn.CHidden*nn.COutput)
-	for i := range nn.OutputWeight {
-		if _, err := fmt.Fscanf(r, "%f\n", &nn.OutputWeight[i]); err != nil {
-			return nil, fmt.Errorf("reading output weight %d: %w", i, err)
-		}
-	}
-
-	nn.HiddenThreshold = make([]float32, nn.CHidden)
-	for i := range nn.HiddenThreshold {
-		if _, err := fmt.Fscanf(r, "%f\n", &nn.HiddenThreshold[i]); err != nil {
-			return nil, fmt.Errorf("reading hidden threshold %d: %w", i, err)
-		}
-	}
-
-	nn.OutputThreshold = make([]float32, nn.COutput)
-	for i := range nn.OutputThreshold {
-		if _, err := fmt.Fscanf(r, "%f\n", &nn.OutputThreshold[i]); err != nil {
-			return nil, fmt.Errorf("reading output threshold %d: %w", i, err)
-		}
-	}
-
-	return nn, nil
+	return newTextReader(r).net("")
 }
@@ -102,6 +102,42 @@ func TestRaceInputs(t *testing.T) {
 	}
 }
 
+// TestRaceInputsBorneOff checks RaceInputs against gnubg's CalculateRaceInputs
+// for a side with checkers borne off: four threshold inputs per point, a
+// one-hot "men off" input for 1-14 checkers off and the weighted cross-overs.
+func TestRaceInputsBorneOff(t *testing.T) {
+	var board Board
+	// Opponent: 15 checkers, 3 of them outside the home board
+	board[0][0], board[0][3], board[0][5] = 4, 4, 4
+	board[0][7], board[0][13] = 2, 1
+	// Player on roll: 10 checkers, 5 borne off
+	board[1][0], board[1][1], board[1][2], board[1][4] = 1, 2, 3, 4
+
+	inputs := RaceInputs(board)
+	want := make([]float32, NumRaceInputs)
+	point := func(side, i int, v ...float32) {
+		copy(want[side*HalfRaceInputs+i*4:], v)
+	}
+	point(0, 0, 0, 0, 1, 0.5)
+	point(0, 3, 0, 0, 1, 0.5)
+	point(0, 5, 0, 0, 1, 0.5)
+	point(0, 7, 0, 1, 0, 0)
+	point(0, 13, 1, 0, 0, 0)
+	// Point 7 crosses once per checker, point 13 twice
+	want[RIncross] = float32(2*1+1*2) / 10
+	point(1, 0, 1, 0, 0, 0)
+	point(1, 1, 0, 1, 0, 0)
+	point(1, 2, 0, 0, 1, 0)
+	point(1, 4, 0, 0, 1, 0.5)
+	want[HalfRaceInputs+RIoff+4] = 1 // 5 men off
+
+	for i := range want {
+		if inputs[i] != want[i] {
+			t.Errorf("input %d (%s) = %v, want %v", i, raceInputNames()[i], inputs[i], want[i])
+		}
+	}
+}
+
 func TestEvaluateStartingPosition(t *testing.T) {
 	// Load weights from local data directory
 	weightsPath := filepath.Join("..", "..", "data", "gnubg.weights")
@@ -222,6 +258,42 @@ func TestClassifyPosition(t *testing.T) {
 	}
 }
 
+func TestClassifyPositionOS(t *testing.T) {
+	// The race of TestClassifyPosition, with a checker on the 7 point
+	var race Board
+	race[0][0], race[0][1], race[0][6] = 5, 5, 5
+	race[1][0], race[1][1], race[1][2] = 5, 5, 5
+	var far Board
+	far[0][12], far[1][0] = 1, 1
+
+	tests := []struct {
+		name               string
+		board              Board
+		osPoints, chequers int
+		expected           PositionClass
+	}{
+		{"no database", race, 0, 0, ClassRace},
+		{"6-point database", race, 6, 15, ClassRace},
+		{"7-point database", race, 7, 15, ClassBearoffOS},
+		{"too few checkers", race, 12, 14, ClassRace},
+		{"13 point is beyond any database", far, 12, 15, ClassRace},
+		{"home boards stay with the 6-point databases", Board{{3}, {2, 2}}, 12, 15, ClassBearoffTS},
+	}
+
+	for _, tt := range tests {
+		if class := ClassifyPositionOS(tt.board, tt.osPoints, tt.chequers); class != tt.expected {
+			t.Errorf("%s: ClassifyPositionOS() = %v, expected %v", tt.name, class, tt.expected)
+		}
+	}
+	if got := GetBearoffBoard(far); got[0][11] != 0 || got[1][0] != 1 {
+		t.Errorf("GetBearoffBoard(%v) = %v", far, got)
+	}
+	far[0][11] = 1
+	if got := GetBearoffBoard(far); got[0][11] != 1 {
+		t.Errorf("GetBearoffBoard dropped the 12 point: %v", got)
+	}
+}
+
 func TestContactInputs(t *testing.T) {
 	// Starting position
 	var board Board
@@ -0,0 +1,78 @@
+package neuralnet
+
+import "math/rand"
+
+// Random position generation for tests and diagnostics.
+// All generators return legal positions that are not yet over: each side has
+// 15 checkers in total (on the board, on the bar or borne off), at least one
+// checker left on the board, and no point is occupied by both sides.
+
+// RandomPosition returns a random position, usually with contact.
+// Checkers are spread uniformly over the points open to them, with a few on
+// the bar or borne off.
+func RandomPosition(rng *rand.Rand) Board {
+	var board Board
+
+	// Alternate sides so neither gets first pick of the points
+	for n := 0; n < 15; n++ {
+		for side := 0; side < 2; side++ {
+			switch r := rng.Intn(100); {
+			case r < 3:
+				board[side][24]++
+			case r < 8 && n > 0:
+				// Borne off; the first checker always stays on the board
+			default:
+				placeChecker(rng, &board, side, 0, 23)
+			}
+		}
+	}
+
+	return board
+}
+
+// RandomRacePosition returns a random position in which contact is broken.
+// Some of these are bearoff positions.
+func RandomRacePosition(rng *rand.Rand) Board {
+	// Side 1 uses points 0..cut and side 0 points 0..22-cut,
+	// so the back checkers can never meet (see ClassifyPosition)
+	cut := rng.Intn(23)
+	return randomRace(rng, [2]int{22 - cut, cut})
+}
+
+// RandomBearoffPosition returns a random position with all checkers of both
+// sides in their home boards.
+func RandomBearoffPosition(rng *rand.Rand) Board {
+	return randomRace(rng, [2]int{5, 5})
+}
+
+// randomRace places each side's checkers on points 0..maxPoint[side],
+// bearing off a random number of them.
+func randomRace(rng *rand.Rand, maxPoint [2]int) Board {
+	var board Board
+
+	for side := 0; side < 2; side++ {
+		onBoard := 1 + rng.Intn(15)
+		for n := 0; n < onBoard; n++ {
+			placeChecker(rng, &board, side, 0, maxPoint[side])
+		}
+	}
+
+	return board
+}
+
+// placeChecker puts one checker for side on a random point in [lo, hi]
+// that the opponent does not hold. If every point is held it goes on the bar.
+func placeChecker(rng *rand.Rand, board *Board, side, lo, hi int) {
+	open := make([]int, 0, hi-lo+1)
+	for i := lo; i <= hi; i++ {
+		if board[1-side][23-i] == 0 {
+			open = append(open, i)
+		}
+	}
+
+	if len(open) == 0 {
+		board[side][24]++
+		return
+	}
+	board[side][open[rng.Intn(len(open))]]++
+}
@@ -55,20 +55,35 @@ func sigmoidFast(x float32) float32 {
 	return sigmoidTable[i]*(1-frac) + sigmoidTable[i+1]*frac
 }
 
-// EvaluateBuffer holds pre-allocated buffers for neural network evaluation
+// EvaluateBuffer holds pre-allocated buffers for neural network evaluation,
+// and the kernel that evaluations with it run
 type EvaluateBuffer struct {
 	hidden  []float64
 	input64 []float64
+	kernel  *kernel
 }
 
-// NewEvaluateBuffer creates a buffer for the given network dimensions
+// NewEvaluateBuffer creates a buffer for the given network dimensions that
+// runs the fastest kernel the CPU supports
 func NewEvaluateBuffer(cInput, cHidden uint32) *EvaluateBuffer {
+	return NewEvaluateBufferKernel(cInput, cHidden, SelectKernel(false))
+}
+
+// NewEvaluateBufferKernel creates a buffer for the given network dimensions
+// that runs the named kernel, one of Kernels. Other names run KernelGo.
+func NewEvaluateBufferKernel(cInput, cHidden uint32, kernel string) *EvaluateBuffer {
 	return &EvaluateBuffer{
 		hidden:  make([]float64, cHidden),
 		input64: make([]float64, cInput),
+		kernel:  kernelNamed(kernel),
 	}
 }
 
+// Kernel returns the name of the kernel evaluations with b run.
+func (b *EvaluateBuffer) Kernel() string {
+	return b.kernel.name
+}
+
 // EvaluateSIMD computes the neural network output using SIMD operations.
 // This version uses gonum's BLAS-optimized floats package and a sigmoid lookup table.
 func (nn *NeuralNet) EvaluateSIMD(input []float32, output []float32, buf *EvaluateBuffer) {
@@ -76,7 +91,9 @@ func (nn *NeuralNet) EvaluateSIMD(input []float32, output []float32, buf *Evalua
 }
 
 // EvaluateFast is a highly optimized evaluation using float32 throughout.
-// Uses loop unrolling and fast sigmoid lookup table.
+// The hidden layer runs on the kernel of buf (see Kernels), the rest uses
+// loop unrolling and the fast sigmoid lookup table. Every kernel gives the
+// same output, bit for bit.
 func (nn *NeuralNet) EvaluateFast(input []float32, output []float32, buf *EvaluateBuffer) {
 	initSigmoidTable()
 
@@ -85,16 +102,19 @@ func (nn *NeuralNet) EvaluateFast(input []float32, output []float32, buf *Evalua
 	cOutput := int(nn.COutput)
 
 	// Use float64 buffer for better numerical precision during accumulation
-	if buf == nil || len(buf.hidden) < cHidden {
+	if buf == nil || buf.kernel == nil {
 		buf = NewEvaluateBuffer(nn.CInput, nn.CHidden)
+	} else if len(buf.hidden) < cHidden {
+		buf = NewEvaluateBufferKernel(nn.CInput, nn.CHidden, buf.kernel.name)
 	}
+	hidden := buf.hidden[:cHidden]
 
 	// Initialize with thresholds
 	for i := 0; i < cHidden; i++ {
-		buf.hidden[i] = float64(nn.HiddenThreshold[i])
+		hidden[i] = float64(nn.HiddenThreshold[i])
 	}
 
-	// Hidden layer: sparse matrix-vector multiply with 4-way unrolling
+	// Hidden layer: sparse matrix-vector multiply
 	for i := 0; i < cInput; i++ {
 		ari := input[i]
 		if ari == 0.0 {
@@ -105,30 +125,9 @@ func (nn *NeuralNet) EvaluateFast(input []float32, output []float32, buf *Evalua
 		weights := nn.HiddenWeight[weightStart : weightStart+cHidden]
 
 		if ari == 1.0 {
-			// 4-way unrolled addition
-			j := 0
-			for ; j <= cHidden-4; j += 4 {
-				buf.hidden[j] += float64(weights[j])
-				buf.hidden[j+1] += float64(weights[j+1])
-				buf.hidden[j+2] += float64(weights[j+2])
-				buf.hidden[j+3] += float64(weights[j+3])
-			}
-			for ; j < cHidden; j++ {
-				buf.hidden[j] += float64(weights[j])
-			}
+			buf.kernel.add(hidden, weights)
 		} else {
-			// 4-way unrolled multiply-add
-			ari64 := float64(ari)
-			j := 0
-			for ; j <= cHidden-4; j += 4 {
-				buf.hidden[j] += float64(weights[j]) * ari64
-				buf.hidden[j+1] += float64(weights[j+1]) * ari64
-				buf.hidden[j+2] += float64(weights[j+2]) * ari64
-				buf.hidden[j+3] += float64(weights[j+3]) * ari64
-			}
-			for ; j < cHidden; j++ {
-				buf.hidden[j] += float64(weights[j]) * ari64
-			}
+			buf.kernel.mulAdd(hidden, weights, float64(ari))
 		}
 	}
 
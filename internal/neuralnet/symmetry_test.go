@@ -0,0 +1,168 @@
+package neuralnet
+
+import (
+	"math"
+	"math/rand"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/yourusername/bgengine/internal/positionid"
+)
+
+const symmetryPositions = 5000
+
+// mirror returns the board with the sides swapped
+func mirror(board Board) Board {
+	return Board(positionid.SwapSides(positionid.Board(board)))
+}
+
+// checkMirroredInputs verifies that encoding the mirrored board gives the same
+// inputs as encoding the board with the two per-side blocks exchanged.
+// blocks lists [start, length] of the first side's blocks; each is followed
+// directly by the matching block for the other side.
+func checkMirroredInputs(t *testing.T, name string, board Board, encode func(Board) []float32, blocks [][2]int) {
+	t.Helper()
+
+	inputs := encode(board)
+	mirrored := encode(mirror(board))
+
+	for _, b := range blocks {
+		start, n := b[0], b[1]
+		for i := 0; i < n; i++ {
+			if inputs[start+i] != mirrored[start+n+i] || inputs[start+n+i] != mirrored[start+i] {
+				t.Fatalf("%s: input %d of block %d not mirrored (%v vs %v) for board %v",
+					name, i, start, inputs[start+i], mirrored[start+n+i], board)
+			}
+		}
+	}
+}
+
+func TestRandomPositionsLegal(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	generators := map[string]func(*rand.Rand) Board{
+		"contact": RandomPosition,
+		"race":    RandomRacePosition,
+		"bearoff": RandomBearoffPosition,
+	}
+
+	for name, gen := range generators {
+		for n := 0; n < symmetryPositions; n++ {
+			board := gen(rng)
+
+			for side := 0; side < 2; side++ {
+				total := 0
+				for i := 0; i < 25; i++ {
+					total += int(board[side][i])
+				}
+				if total == 0 || total > 15 {
+					t.Fatalf("%s: side %d has %d checkers: %v", name, side, total, board)
+				}
+			}
+			for i := 0; i < 24; i++ {
+				if board[0][i] > 0 && board[1][23-i] > 0 {
+					t.Fatalf("%s: point %d held by both sides: %v", name, i, board)
+				}
+			}
+
+			class := ClassifyPosition(board)
+			switch name {
+			case "race":
+				if class < ClassBearoff2 || class > ClassRace {
+					t.Fatalf("race: got class %d for %v", class, board)
+				}
+			case "bearoff":
+				if class != ClassBearoffTS && class != ClassBearoff1 {
+					t.Fatalf("bearoff: got class %d for %v", class, board)
+				}
+			}
+		}
+	}
+}
+
+func TestInputsMirrorSymmetry(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+
+	base := [][2]int{{0, 25 * MinPPerPoint}}
+	contact := [][2]int{{0, 25 * MinPPerPoint}, {25 * MinPPerPoint * 2, MoreInputs}}
+	race := [][2]int{{0, HalfRaceInputs}}
+
+	for n := 0; n < symmetryPositions; n++ {
+		board := RandomPosition(rng)
+		checkMirroredInputs(t, "base", board, BaseInputs, base)
+		checkMirroredInputs(t, "contact", board, ContactInputs, contact)
+		checkMirroredInputs(t, "crashed", board, CrashedInputs, contact)
+
+		board = RandomRacePosition(rng)
+		checkMirroredInputs(t, "race", board, RaceInputs, race)
+	}
+}
+
+// evaluateClass evaluates board with the net for its class.
+// Bearoff positions use the race net, as there is no database here.
+func evaluateClass(w *Weights, board Board, class PositionClass) float32 {
+	switch class {
+	case ClassContact:
+		return w.Contact.Evaluate(ContactInputs(board))[0]
+	case ClassCrashed:
+		return w.Crashed.Evaluate(CrashedInputs(board))[0]
+	default:
+		return w.Race.Evaluate(RaceInputs(board))[0]
+	}
+}
+
+// TestEvaluationMirrorConsistency evaluates random positions and their mirrors.
+// Both evaluations are for the side on roll, so P(pos) + P(mirror) differs from
+// 1 by the value of having the roll and is not expected to vanish. The check
+// that matters is that the nets see exactly the mirrored inputs; beyond that
+// this reports the largest deviations per class so that a change in the
+// encoding which shifts them stands out.
+func TestEvaluationMirrorConsistency(t *testing.T) {
+	weightsPath := filepath.Join("..", "..", "data", "gnubg.weights")
+	w, err := LoadWeightsText(weightsPath)
+	if err != nil {
+		t.Skipf("Skipping - weights not available: %v", err)
+	}
+
+	type offender struct {
+		board Board
+		dev   float64
+	}
+	worst := make(map[PositionClass][]offender)
+
+	rng := rand.New(rand.NewSource(3))
+	for n := 0; n < symmetryPositions; n++ {
+		for _, board := range []Board{RandomPosition(rng), RandomRacePosition(rng)} {
+			class := ClassifyPosition(board)
+			if mc := ClassifyPosition(mirror(board)); mc != class {
+				t.Fatalf("mirror changes class from %d to %d for %v", class, mc, board)
+			}
+
+			p := evaluateClass(w, board, class)
+			pm := evaluateClass(w, mirror(board), class)
+			if p < 0 || p > 1 || pm < 0 || pm > 1 {
+				t.Fatalf("win probability out of range (%v, %v) for %v", p, pm, board)
+			}
+
+			// An exactly symmetric position is its own mirror
+			if board == mirror(board) && p != pm {
+				t.Errorf("symmetric position evaluates to %v and %v: %v", p, pm, board)
+			}
+
+			dev := math.Abs(float64(p) + float64(pm) - 1)
+			worst[class] = append(worst[class], offender{board, dev})
+		}
+	}
+
+	for class, list := range worst {
+		sort.Slice(list, func(i, j int) bool { return list[i].dev > list[j].dev })
+		sum := 0.0
+		for _, o := range list {
+			sum += o.dev
+		}
+		t.Logf("class %d: %d positions, mean |P+P'-1| = %.4f", class, len(list), sum/float64(len(list)))
+		for i := 0; i < 3 && i < len(list); i++ {
+			t.Logf("  %.4f %v", list[i].dev, list[i].board)
+		}
+	}
+}
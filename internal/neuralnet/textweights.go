@@ -0,0 +1,239 @@
+package neuralnet
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// maxNetValues bounds the values a text net header may declare, so that a
+// corrupt header fails instead of allocating gigabytes. gnubg's largest net
+// has about 32,000.
+const maxNetValues = 1 << 24
+
+var (
+	errTruncated   = errors.New("the file ends")
+	errNextHeader  = errors.New("the next net's header starts here")
+	errExtraValues = errors.New("the net has more values than its header declares")
+)
+
+// WeightsError reports where a text weights file departs from its format:
+// the net and layer being read, the line, and for a net whose layers hold
+// fewer or more values than its header declares, how many it declares
+// (Expected) and how many the file holds (Found).
+type WeightsError struct {
+	Net      string // "contact", "race", ..., or "" for the file header
+	Layer    string // "header", "hidden weights", "output weights", "hidden thresholds" or "output thresholds"
+	Line     int    // Line of the file, from 1
+	Expected int
+	Found    int
+	Err      error
+}
+
+func (e *WeightsError) Error() string {
+	where := "weights file"
+	if e.Net != "" {
+		where = e.Net + " net"
+	}
+	if e.Layer != "" {
+		where += ", " + e.Layer
+	}
+	if e.Expected > 0 {
+		return fmt.Sprintf("%s, line %d: expected %d values, found %d: %v", where, e.Line, e.Expected, e.Found, e.Err)
+	}
+	return fmt.Sprintf("%s, line %d: %v", where, e.Line, e.Err)
+}
+
+func (e *WeightsError) Unwrap() error { return e.Err }
+
+// textReader reads the lines of a text weights file as fields, skipping
+// blank lines. Trailing whitespace, including the carriage returns of CRLF
+// line ends, is ignored.
+type textReader struct {
+	sc   *bufio.Scanner
+	line int // Number of the last line read
+
+	// A line read ahead of the net it belongs to
+	pending     []string
+	pendingLine int
+}
+
+func newTextReader(r io.Reader) *textReader {
+	return &textReader{sc: bufio.NewScanner(r)}
+}
+
+// next returns the fields of the next non-blank line and its number. At the
+// end of the input it returns no fields and the number of the last line.
+func (t *textReader) next() ([]string, int, error) {
+	if t.pending != nil {
+		fields := t.pending
+		t.pending = nil
+		return fields, t.pendingLine, nil
+	}
+	for t.sc.Scan() {
+		t.line++
+		if fields := strings.Fields(t.sc.Text()); len(fields) > 0 {
+			return fields, t.line, nil
+		}
+	}
+	return nil, t.line, t.sc.Err()
+}
+
+// unread returns a line to the reader, for next to return again.
+func (t *textReader) unread(fields []string, line int) {
+	t.pending, t.pendingLine = fields, line
+}
+
+// header reads the "GNU Backgammon 1.00" line that starts a weights file.
+func (t *textReader) header() error {
+	fields, line, err := t.next()
+	switch {
+	case err != nil:
+	case fields == nil:
+		err = errTruncated
+	case len(fields) != 3 || fields[0] != "GNU" || fields[1] != "Backgammon":
+		err = fmt.Errorf("found %q, want \"GNU Backgammon %s\"", strings.Join(fields, " "), WeightsVersionText)
+	case fields[2] != WeightsVersionText:
+		err = fmt.Errorf("version %s, want %s", fields[2], WeightsVersionText)
+	}
+	if err != nil {
+		return &WeightsError{Layer: "header", Line: line, Err: err}
+	}
+	return nil
+}
+
+// net reads the named net: a header line of six fields (inputs, hidden
+// nodes, outputs, training count and the two betas) and then one value a
+// line, up to the next header or the end of the file.
+func (t *textReader) net(name string) (*NeuralNet, error) {
+	fields, line, err := t.next()
+	if err == nil && fields == nil {
+		err = errTruncated
+	}
+	var nn *NeuralNet
+	if err == nil {
+		nn, err = parseTextHeader(fields)
+	}
+	if err != nil {
+		return nil, &WeightsError{Net: name, Layer: "header", Line: line, Err: err}
+	}
+
+	layers := nn.textLayers()
+	expected := 0
+	for _, l := range layers {
+		expected += len(l.values)
+	}
+
+	for found, layer, index := 0, 0, 0; ; {
+		fields, line, err := t.next()
+		if err != nil {
+			return nil, &WeightsError{Net: name, Layer: layers[layer].name, Line: line, Err: err}
+		}
+
+		switch {
+		case fields == nil || len(fields) == 6:
+			if fields != nil {
+				t.unread(fields, line)
+			}
+			if found == expected {
+				return nn, nil
+			}
+			cause := errNextHeader
+			if fields == nil {
+				cause = errTruncated
+			}
+			return nil, &WeightsError{Net: name, Layer: layers[layer].name, Line: line, Expected: expected, Found: found, Err: cause}
+
+		case len(fields) != 1:
+			return nil, &WeightsError{Net: name, Layer: layers[layer].name, Line: line,
+				Err: fmt.Errorf("%d fields, want one value or a net header", len(fields))}
+
+		case found == expected:
+			// Count the surplus, to say how far the header is off
+			extra := 1
+			for {
+				more, moreLine, _ := t.next()
+				if len(more) != 1 {
+					if more != nil {
+						t.unread(more, moreLine)
+					}
+					break
+				}
+				extra++
+			}
+			return nil, &WeightsError{Net: name, Layer: layers[layer].name, Line: line, Expected: expected, Found: found + extra, Err: errExtraValues}
+		}
+
+		v, err := strconv.ParseFloat(fields[0], 32)
+		if err != nil {
+			return nil, &WeightsError{Net: name, Layer: layers[layer].name, Line: line,
+				Err: fmt.Errorf("%q is not a number", fields[0])}
+		}
+		layers[layer].values[index] = float32(v)
+		found++
+		if index++; index == len(layers[layer].values) && layer < len(layers)-1 {
+			layer, index = layer+1, 0
+		}
+	}
+}
+
+// parseTextHeader returns a net with the dimensions and betas of a text net
+// header, and its weights allocated.
+func parseTextHeader(fields []string) (*NeuralNet, error) {
+	if len(fields) != 6 {
+		return nil, fmt.Errorf("%d fields, want 6 (inputs, hidden nodes, outputs, training count, hidden beta, output beta)", len(fields))
+	}
+	var dims [3]uint32
+	for i := range dims {
+		d, err := strconv.ParseUint(fields[i], 10, 32)
+		if err != nil || d < 1 {
+			return nil, fmt.Errorf("invalid network dimensions: %s/%s/%s", fields[0], fields[1], fields[2])
+		}
+		dims[i] = uint32(d)
+	}
+	var betas [2]float32
+	for i := range betas {
+		b, err := strconv.ParseFloat(fields[4+i], 32)
+		if err != nil || b <= 0 {
+			return nil, fmt.Errorf("invalid beta values: %s/%s", fields[4], fields[5])
+		}
+		betas[i] = float32(b)
+	}
+
+	cInput, cHidden, cOutput := uint64(dims[0]), uint64(dims[1]), uint64(dims[2])
+	if n := cInput*cHidden + cHidden*cOutput + cHidden + cOutput; n > maxNetValues {
+		return nil, fmt.Errorf("dimensions %d/%d/%d declare %d values, more than any net has", cInput, cHidden, cOutput, n)
+	}
+
+	return &NeuralNet{
+		CInput:          dims[0],
+		CHidden:         dims[1],
+		COutput:         dims[2],
+		NTrained:        1,
+		RBetaHidden:     betas[0],
+		RBetaOutput:     betas[1],
+		HiddenWeight:    make([]float32, cInput*cHidden),
+		OutputWeight:    make([]float32, cHidden*cOutput),
+		HiddenThreshold: make([]float32, cHidden),
+		OutputThreshold: make([]float32, cOutput),
+	}, nil
+}
+
+// textLayers returns the weights of nn in the order text files hold them.
+func (nn *NeuralNet) textLayers() []struct {
+	name   string
+	values []float32
+} {
+	return []struct {
+		name   string
+		values []float32
+	}{
+		{"hidden weights", nn.HiddenWeight},
+		{"output weights", nn.OutputWeight},
+		{"hidden thresholds", nn.HiddenThreshold},
+		{"output thresholds", nn.OutputThreshold},
+	}
+}
@@ -0,0 +1,212 @@
+package neuralnet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// textWeights returns a weights file in gnubg's text format holding six
+// random nets of 4 inputs, 3 hidden nodes and 2 outputs. Each net takes 24
+// lines: its header on line 2+24k and its 23 values on the lines after.
+func textWeights(t *testing.T) (*Weights, []string) {
+	t.Helper()
+	rng := rand.New(rand.NewSource(1))
+	w := &Weights{}
+	lines := []string{"GNU Backgammon " + WeightsVersionText}
+	for _, n := range w.nets() {
+		nn := randomNet(rng, 4, 3, 2)
+		nn.NTrained = 1
+		*n.net = nn
+		lines = append(lines, fmt.Sprintf("%d %d %d %d %g %g", nn.CInput, nn.CHidden, nn.COutput, 1, nn.RBetaHidden, nn.RBetaOutput))
+		for _, l := range nn.textLayers() {
+			for _, v := range l.values {
+				lines = append(lines, fmt.Sprintf("%g", v))
+			}
+		}
+	}
+	return w, lines
+}
+
+func TestLoadWeightsTextFormat(t *testing.T) {
+	want, lines := textWeights(t)
+
+	got, err := LoadWeightsTextFromReader(strings.NewReader(strings.Join(lines, "\n") + "\n"))
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Fatalf("LoadWeightsTextFromReader: %v, nets differ: %v", err, !reflect.DeepEqual(got, want))
+	}
+
+	// CRLF line ends, trailing whitespace and blank lines are all right
+	dos := strings.Join(lines, " \t\r\n") + "\r\n\r\n"
+	dos = strings.Replace(dos, "\r\n", "\r\n\r\n", 3)
+	if got, err := LoadWeightsTextFromReader(strings.NewReader(dos)); err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("LoadWeightsTextFromReader with CRLF: %v", err)
+	}
+}
+
+func TestLoadWeightsTextDiagnostics(t *testing.T) {
+	_, lines := textWeights(t)
+
+	// edit returns the file with line n (from 1) replaced, or removed if
+	// the replacement is empty
+	edit := func(n int, s string) string {
+		l := append([]string(nil), lines...)
+		if s == "" {
+			l = append(l[:n-1], l[n:]...)
+		} else {
+			l[n-1] = s
+		}
+		return strings.Join(l, "\n") + "\n"
+	}
+	full := strings.Join(lines, "\n") + "\n"
+
+	tests := []struct {
+		name string
+		file string
+		want WeightsError
+		msg  string
+	}{
+		{
+			name: "truncated",
+			file: strings.Join(lines[:len(lines)-3], "\n"),
+			want: WeightsError{Net: "pruning race", Layer: "hidden thresholds", Line: 142, Expected: 23, Found: 20, Err: errTruncated},
+			msg:  "pruning race net, hidden thresholds, line 142: expected 23 values, found 20: the file ends",
+		},
+		{
+			name: "dimension line declares more",
+			file: edit(26, "4 4 2 1 0.1 1"),
+			want: WeightsError{Net: "race", Layer: "output weights", Line: 50, Expected: 30, Found: 23, Err: errNextHeader},
+			msg:  "race net, output weights, line 50: expected 30 values, found 23: the next net's header starts here",
+		},
+		{
+			name: "dimension line declares fewer",
+			file: edit(26, "4 2 2 1 0.1 1"),
+			want: WeightsError{Net: "race", Layer: "output thresholds", Line: 43, Expected: 16, Found: 23, Err: errExtraValues},
+			msg:  "race net, output thresholds, line 43: expected 16 values, found 23: the net has more values than its header declares",
+		},
+		{
+			name: "missing value",
+			file: edit(60, ""),
+			want: WeightsError{Net: "crashed", Layer: "output thresholds", Line: 73, Expected: 23, Found: 22, Err: errNextHeader},
+			msg:  "crashed net, output thresholds, line 73: expected 23 values, found 22: the next net's header starts here",
+		},
+		{
+			name: "version",
+			file: edit(1, "GNU Backgammon 0.99"),
+			want: WeightsError{Layer: "header", Line: 1},
+			msg:  "weights file, header, line 1: version 0.99, want 1.00",
+		},
+		{
+			name: "not a number",
+			file: edit(30, "0.12x"),
+			want: WeightsError{Net: "race", Layer: "hidden weights", Line: 30},
+			msg:  `race net, hidden weights, line 30: "0.12x" is not a number`,
+		},
+		{
+			name: "short header",
+			file: edit(2, "4 3 2"),
+			want: WeightsError{Net: "contact", Layer: "header", Line: 2},
+			msg:  "contact net, header, line 2: 3 fields, want 6 (inputs, hidden nodes, outputs, training count, hidden beta, output beta)",
+		},
+		{
+			name: "extra net",
+			file: full + lines[1] + "\n",
+			want: WeightsError{Line: 146},
+			msg:  "weights file, line 146: unexpected content after the last net",
+		},
+	}
+
+	for _, tt := range tests {
+		_, err := LoadWeightsTextFromReader(strings.NewReader(tt.file))
+		var we *WeightsError
+		if !errors.As(err, &we) {
+			t.Errorf("%s: %v, want a *WeightsError", tt.name, err)
+			continue
+		}
+		if err.Error() != tt.msg {
+			t.Errorf("%s: error %q, want %q", tt.name, err, tt.msg)
+		}
+		got := *we
+		got.Err = nil
+		want := tt.want
+		if want.Err != nil && !errors.Is(err, want.Err) {
+			t.Errorf("%s: error %v, want %v", tt.name, err, want.Err)
+		}
+		want.Err = nil
+		if got != want {
+			t.Errorf("%s: %+v, want %+v", tt.name, got, want)
+		}
+	}
+}
+
+// binaryWeights encodes w as a weights file in gnubg's binary format.
+func binaryWeights(t *testing.T, w *Weights) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	put := func(v interface{}) {
+		if err := binary.Write(&buf, binary.LittleEndian, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	put(float32(WeightsMagicBinary))
+	put(float32(WeightsVersionBinary))
+	for _, n := range w.nets() {
+		nn := *n.net
+		for _, v := range []interface{}{nn.CInput, nn.CHidden, nn.COutput, nn.NTrained, nn.RBetaHidden, nn.RBetaOutput,
+			nn.HiddenWeight, nn.OutputWeight, nn.HiddenThreshold, nn.OutputThreshold} {
+			put(v)
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestLoadWeightsBytes(t *testing.T) {
+	want, lines := textWeights(t)
+	text := []byte(strings.Join(lines, "\n") + "\n")
+	bin := binaryWeights(t, want)
+	core := &Weights{Contact: want.Contact, Race: want.Race}
+
+	// The contact and race nets take the first 1+2*24 lines
+	coreText := []byte(strings.Join(lines[:1+CoreNets*24], "\n") + "\n")
+	coreBin := bin[:8+CoreNets*(24+23*4)]
+
+	tests := []struct {
+		name string
+		data []byte
+		core bool
+		want *Weights
+	}{
+		{"text", text, false, want},
+		{"binary", bin, false, want},
+		{"text core", text, true, core},
+		{"binary core", bin, true, core},
+		{"trimmed text core", coreText, true, core},
+		{"trimmed binary core", coreBin, true, core},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LoadWeightsBytes(tt.data, tt.core)
+			if err != nil {
+				t.Fatalf("LoadWeightsBytes: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LoadWeightsBytes: nets differ")
+			}
+		})
+	}
+
+	// Without core a trimmed file is missing nets
+	for name, data := range map[string][]byte{"text": coreText, "binary": coreBin} {
+		if _, err := LoadWeightsBytes(data, false); err == nil {
+			t.Errorf("LoadWeightsBytes(trimmed %s): no error", name)
+		}
+	}
+	if !IsWeightsBinary(bin) || IsWeightsBinary(text) || IsWeightsBinary(nil) {
+		t.Errorf("IsWeightsBinary does not tell the formats apart")
+	}
+}
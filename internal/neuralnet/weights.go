@@ -1,7 +1,9 @@
 package neuralnet
 
 import (
+	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"math"
@@ -12,6 +14,7 @@ import (
 const (
 	WeightsMagicBinary   = 472.3782 // Magic number for binary weights file
 	WeightsVersionBinary = 1.01     // Expected version
+	WeightsVersionText   = "1.00"   // Version in the header of text weights files
 )
 
 // Weights contains all the neural networks used for position evaluation
@@ -37,6 +40,11 @@ func LoadWeightsBinary(path string) (*Weights, error) {
 
 // LoadWeightsBinaryFromReader loads all neural networks from a reader
 func LoadWeightsBinaryFromReader(r io.Reader) (*Weights, error) {
+	return loadWeightsBinary(r, numNets)
+}
+
+// loadWeightsBinary loads the first n nets of a binary weights file.
+func loadWeightsBinary(r io.Reader, n int) (*Weights, error) {
 	// Read and validate magic number header
 	var magic, version float32
 	if err := binary.Read(r, binary.LittleEndian, &magic); err != nil {
@@ -57,34 +65,10 @@ func LoadWeightsBinaryFromReader(r io.Reader) (*Weights, error) {
 	var err error
 
 	// Load in the same order as gnubg
-	w.Contact, err = LoadBinary(r)
-	if err != nil {
-		return nil, fmt.Errorf("loading contact net: %w", err)
-	}
-
-	w.Race, err = LoadBinary(r)
-	if err != nil {
-		return nil, fmt.Errorf("loading race net: %w", err)
-	}
-
-	w.Crashed, err = LoadBinary(r)
-	if err != nil {
-		return nil, fmt.Errorf("loading crashed net: %w", err)
-	}
-
-	w.PContact, err = LoadBinary(r)
-	if err != nil {
-		return nil, fmt.Errorf("loading pruning contact net: %w", err)
-	}
-
-	w.PCrashed, err = LoadBinary(r)
-	if err != nil {
-		return nil, fmt.Errorf("loading pruning crashed net: %w", err)
-	}
-
-	w.PRace, err = LoadBinary(r)
-	if err != nil {
-		return nil, fmt.Errorf("loading pruning race net: %w", err)
+	for _, net := range w.nets()[:n] {
+		if *net.net, err = LoadBinary(r); err != nil {
+			return nil, fmt.Errorf("loading %s net: %w", net.name, err)
+		}
 	}
 
 	return w, nil
@@ -101,49 +85,96 @@ func LoadWeightsText(path string) (*Weights, error) {
 	return LoadWeightsTextFromReader(f)
 }
 
-// LoadWeightsTextFromReader loads all neural networks from a text reader
+// LoadWeightsTextFromReader loads all neural networks from a text reader.
+// CRLF line ends, trailing whitespace and blank lines are accepted; any other
+// departure from the format is a *WeightsError naming the net, layer and line.
 func LoadWeightsTextFromReader(r io.Reader) (*Weights, error) {
-	// Skip the header line "GNU Backgammon X.XX"
-	var h1, h2, h3 string
-	if _, err := fmt.Fscanln(r, &h1, &h2, &h3); err != nil {
-		return nil, fmt.Errorf("reading header: %w", err)
+	return loadWeightsText(r, numNets)
+}
+
+// loadWeightsText loads the first n nets of a text weights file. Content
+// after them is an error only if they are all the nets.
+func loadWeightsText(r io.Reader, n int) (*Weights, error) {
+	t := newTextReader(r)
+	if err := t.header(); err != nil {
+		return nil, err
 	}
 
 	w := &Weights{}
 	var err error
 
 	// Load in the same order as gnubg
-	w.Contact, err = LoadText(r)
-	if err != nil {
-		return nil, fmt.Errorf("loading contact net: %w", err)
+	for _, net := range w.nets()[:n] {
+		if *net.net, err = t.net(net.name); err != nil {
+			return nil, err
+		}
 	}
 
-	w.Race, err = LoadText(r)
-	if err != nil {
-		return nil, fmt.Errorf("loading race net: %w", err)
+	if n < numNets {
+		return w, nil
 	}
-
-	w.Crashed, err = LoadText(r)
-	if err != nil {
-		return nil, fmt.Errorf("loading crashed net: %w", err)
+	if fields, line, err := t.next(); err != nil || fields != nil {
+		if err == nil {
+			err = errors.New("unexpected content after the last net")
+		}
+		return nil, &WeightsError{Line: line, Err: err}
 	}
 
-	w.PContact, err = LoadText(r)
-	if err != nil {
-		return nil, fmt.Errorf("loading pruning contact net: %w", err)
-	}
+	return w, nil
+}
 
-	w.PCrashed, err = LoadText(r)
-	if err != nil {
-		return nil, fmt.Errorf("loading pruning crashed net: %w", err)
-	}
+// numNets is the number of nets in a weights file.
+const numNets = 6
+
+// CoreNets is the number of nets LoadWeightsBytes reads with core set: the
+// contact and race nets, which come first in a weights file.
+const CoreNets = 2
+
+// LoadWeightsBytes loads neural networks from the contents of a weights
+// file, binary or text, for callers without a file system. Binary files are
+// told apart by their magic number.
+//
+// With core set only the contact and race nets are read, leaving the
+// crashed and pruning nets nil, and the data may end after the race net, so
+// a trimmed file can leave the other nets out.
+func LoadWeightsBytes(data []byte, core bool) (*Weights, error) {
+	n := numNets
+	if core {
+		n = CoreNets
+	}
+	if IsWeightsBinary(data) {
+		return loadWeightsBinary(bytes.NewReader(data), n)
+	}
+	return loadWeightsText(bytes.NewReader(data), n)
+}
 
-	w.PRace, err = LoadText(r)
-	if err != nil {
-		return nil, fmt.Errorf("loading pruning race net: %w", err)
+// IsWeightsBinary reports whether data starts with the magic number of a
+// binary weights file.
+func IsWeightsBinary(data []byte) bool {
+	if len(data) < 4 {
+		return false
 	}
+	magic := math.Float32frombits(binary.LittleEndian.Uint32(data))
+	return math.Abs(float64(magic)-WeightsMagicBinary) <= 0.001
+}
 
-	return w, nil
+// nets returns the nets of w with their names, in the order gnubg's weights
+// files hold them.
+func (w *Weights) nets() []struct {
+	name string
+	net  **NeuralNet
+} {
+	return []struct {
+		name string
+		net  **NeuralNet
+	}{
+		{"contact", &w.Contact},
+		{"race", &w.Race},
+		{"crashed", &w.Crashed},
+		{"pruning contact", &w.PContact},
+		{"pruning crashed", &w.PCrashed},
+		{"pruning race", &w.PRace},
+	}
 }
 
 // Validate checks that the loaded weights have the expected dimensions
@@ -172,6 +203,17 @@ func (w *Weights) Validate() error {
 		return fmt.Errorf("crashed net has %d outputs, expected %d", w.Crashed.COutput, numOutputs)
 	}
 
+	// The inputs the input functions produce for each net
+	if w.Contact.CInput != NumContactInputs {
+		return fmt.Errorf("contact net has %d inputs, expected %d", w.Contact.CInput, NumContactInputs)
+	}
+	if w.Crashed.CInput != NumContactInputs {
+		return fmt.Errorf("crashed net has %d inputs, expected %d", w.Crashed.CInput, NumContactInputs)
+	}
+	if w.Race.CInput != NumRaceInputs {
+		return fmt.Errorf("race net has %d inputs, expected %d", w.Race.CInput, NumRaceInputs)
+	}
+
 	// Pruning nets should have 200 inputs (base inputs only)
 	if w.PContact.CInput != numPruningInputs {
 		return fmt.Errorf("pruning contact net has %d inputs, expected %d", w.PContact.CInput, numPruningInputs)
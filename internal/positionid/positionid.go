@@ -7,6 +7,9 @@ package positionid
 
 import (
 	"errors"
+	"fmt"
+	"strings"
+	"sync"
 )
 
 const (
@@ -39,10 +42,15 @@ type OldPositionKey struct {
 
 // Combination table for bearoff calculations
 var combinationTable [MaxN][MaxR]uint32
-var combinationInitialized = false
+var combinationOnce sync.Once
 
 // initCombination initializes the combination table
 func initCombination() {
+	combinationOnce.Do(buildCombination)
+}
+
+// buildCombination fills the combination table
+func buildCombination() {
 	for i := 0; i < MaxN; i++ {
 		combinationTable[i][0] = uint32(i + 1)
 	}
@@ -56,19 +64,20 @@ func initCombination() {
 			combinationTable[i][j] = combinationTable[i-1][j-1] + combinationTable[i-1][j]
 		}
 	}
-
-	combinationInitialized = true
 }
 
-// Combination returns C(n, r) - n choose r
+// Combination returns C(n, r) - n choose r.
+// n must be 1..MaxN and r 1..MaxR. Out of range arguments return 0, or
+// panic in builds with the bgdebug tag.
 func Combination(n, r uint32) uint32 {
 	if n > MaxN || r > MaxR || n == 0 || r == 0 {
+		if debugChecks {
+			panic(fmt.Sprintf("positionid: Combination(%d, %d) out of range", n, r))
+		}
 		return 0
 	}
 
-	if !combinationInitialized {
-		initCombination()
-	}
+	initCombination()
 
 	return combinationTable[n-1][r-1]
 }
@@ -273,16 +282,42 @@ func base64Decode(ch byte) uint8 {
 	return 255
 }
 
-// ErrInvalidPositionID is returned when a position ID is invalid
+// ErrInvalidPositionID is returned when a position ID is invalid. The errors
+// of BoardFromPositionID wrap it with the constraint that failed.
 var ErrInvalidPositionID = errors.New("invalid position ID")
 
-// BoardFromPositionID decodes a base64 position ID string to a board
+// CheckerRange is the number of checkers each side may have in a decoded
+// position.
+type CheckerRange struct {
+	Min, Max int
+}
+
+// StandardCheckers is the checker range of backgammon and its variants.
+var StandardCheckers = CheckerRange{Min: 1, Max: 15}
+
+// SplitGnubgID splits a gnubg "positionID:matchID" string at the colon. The
+// match ID is empty if id has no colon.
+func SplitGnubgID(id string) (positionID, matchID string) {
+	positionID, matchID, _ = strings.Cut(id, ":")
+	return positionID, matchID
+}
+
+// BoardFromPositionID decodes a base64 position ID string to a board. posID
+// must be exactly PositionIDLength characters (split gnubg IDs with
+// SplitGnubgID first), encode a legal position with StandardCheckers on each
+// side, and be the ID PositionID gives for that position.
 func BoardFromPositionID(posID string) (Board, error) {
+	return DecodePositionID(posID, StandardCheckers)
+}
+
+// DecodePositionID is BoardFromPositionID allowing each side the checkers in
+// checkers.
+func DecodePositionID(posID string, checkers CheckerRange) (Board, error) {
 	var key OldPositionKey
 	var board Board
 
-	if len(posID) < PositionIDLength {
-		return board, ErrInvalidPositionID
+	if len(posID) != PositionIDLength {
+		return board, fmt.Errorf("%w: %d characters, want %d", ErrInvalidPositionID, len(posID), PositionIDLength)
 	}
 
 	// Decode base64 characters
@@ -290,7 +325,7 @@ func BoardFromPositionID(posID string) (Board, error) {
 	for i := 0; i < PositionIDLength; i++ {
 		ach[i] = base64Decode(posID[i])
 		if ach[i] == 255 {
-			return board, ErrInvalidPositionID
+			return board, fmt.Errorf("%w: character %q at %d is not base64", ErrInvalidPositionID, posID[i], i+1)
 		}
 	}
 
@@ -308,13 +343,50 @@ func BoardFromPositionID(posID string) (Board, error) {
 
 	board = BoardFromOldKey(key)
 
-	if !CheckPosition(board) {
-		return board, ErrInvalidPositionID
+	if err := checkBoard(board, checkers); err != nil {
+		return board, fmt.Errorf("%w: %v", ErrInvalidPositionID, err)
+	}
+
+	// Bits past the last point, or in the unused low bits of the last
+	// character, would be dropped without a word
+	if id := PositionID(board); id != posID {
+		return board, fmt.Errorf("%w: stray bits (the position's ID is %s)", ErrInvalidPositionID, id)
 	}
 
 	return board, nil
 }
 
+// checkBoard is CheckPosition with checkers on each side, naming the rule a
+// board breaks.
+func checkBoard(board Board, checkers CheckerRange) error {
+	for side := 0; side < 2; side++ {
+		n := 0
+		for _, c := range board[side] {
+			n += int(c)
+		}
+		if n < checkers.Min || n > checkers.Max {
+			return fmt.Errorf("player %d has %d checkers, want %d to %d", side, n, checkers.Min, checkers.Max)
+		}
+	}
+
+	for i := 0; i < 24; i++ {
+		if board[0][i] > 0 && board[1][23-i] > 0 {
+			return fmt.Errorf("both players have checkers on player 0's point %d", i+1)
+		}
+	}
+
+	closed := true
+	for i := 0; i < 6; i++ {
+		if board[0][i] < 2 || board[1][i] < 2 {
+			closed = false
+		}
+	}
+	if closed && board[0][24] > 0 && board[1][24] > 0 {
+		return errors.New("both players are on the bar against closed boards")
+	}
+	return nil
+}
+
 // CheckPosition validates that a board position is legal
 func CheckPosition(board Board) bool {
 	var ac [2]uint32
@@ -473,3 +545,27 @@ func SwapSides(board Board) Board {
 	}
 	return result
 }
+
+// CanonicalKey returns the key shared by board and its mirror image (the
+// board with the sides swapped): the lesser of their keys, comparing the
+// words of Data in order. swapped reports whether that is the mirror's key.
+// A board that is its own mirror, such as the starting position, is never
+// swapped.
+//
+// This identifies a structure regardless of which side it was entered from.
+// It is not a key for evaluations: swapping the sides also changes the
+// player on roll, so a board's evaluation is not the inverse of its
+// mirror's.
+func CanonicalKey(board Board) (key PositionKey, swapped bool) {
+	key = MakePositionKey(board)
+	mirror := MakePositionKey(SwapSides(board))
+	for i := range key.Data {
+		if mirror.Data[i] != key.Data[i] {
+			if mirror.Data[i] < key.Data[i] {
+				return mirror, true
+			}
+			break
+		}
+	}
+	return key, false
+}
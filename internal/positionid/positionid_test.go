@@ -1,6 +1,12 @@
 package positionid
 
 import (
+	"errors"
+	"math/rand"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -111,6 +117,82 @@ func TestBoardFromPositionID(t *testing.T) {
 	}
 }
 
+func TestBoardFromPositionIDRejects(t *testing.T) {
+	var overlap Board
+	overlap[0][5], overlap[1][18] = 2, 2
+	var tooMany Board
+	tooMany[0][0], tooMany[1][0] = 16, 1
+
+	tests := []struct {
+		posID string
+		want  string
+	}{
+		{"4HPwATDgc/ABMAGARBAGE", "21 characters, want 14"},
+		{"4HPwATDgc/ABM", "13 characters, want 14"},
+		{"4HPwATDgc/ABMA:cAkAAAAAAAAA", "27 characters, want 14"},
+		{"", "0 characters, want 14"},
+		{"4HPwATDgc/AB!A", `character '!' at 13 is not base64`},
+		{"AAAAAAAAAAAAAA", "player 0 has 0 checkers, want 1 to 15"},
+		{PositionID(tooMany), "player 0 has 16 checkers, want 1 to 15"},
+		{PositionID(overlap), "both players have checkers on player 0's point 6"},
+		{"4HPwATDgc/ABMB", "stray bits (the position's ID is 4HPwATDgc/ABMA)"},
+	}
+	for _, tt := range tests {
+		_, err := BoardFromPositionID(tt.posID)
+		if !errors.Is(err, ErrInvalidPositionID) || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("BoardFromPositionID(%q) error = %v, want %q", tt.posID, err, tt.want)
+		}
+	}
+}
+
+func TestDecodePositionIDCheckers(t *testing.T) {
+	var hyper Board
+	hyper[0][23], hyper[0][22], hyper[0][21] = 1, 1, 1
+	hyper[1][23], hyper[1][22], hyper[1][21] = 1, 1, 1
+	three := CheckerRange{Min: 1, Max: 3}
+
+	if board, err := DecodePositionID(PositionID(hyper), three); err != nil || board != hyper {
+		t.Errorf("DecodePositionID(3 checkers) = %v, %v", board, err)
+	}
+	if _, err := DecodePositionID(startingPositionID, three); err == nil || !strings.Contains(err.Error(), "15 checkers, want 1 to 3") {
+		t.Errorf("DecodePositionID(starting position) error = %v", err)
+	}
+}
+
+func TestSplitGnubgID(t *testing.T) {
+	tests := []struct{ id, pos, match string }{
+		{"4HPwATDgc/ABMA:cAkAAAAAAAAA", "4HPwATDgc/ABMA", "cAkAAAAAAAAA"},
+		{"4HPwATDgc/ABMA", "4HPwATDgc/ABMA", ""},
+		{"4HPwATDgc/ABMA:", "4HPwATDgc/ABMA", ""},
+	}
+	for _, tt := range tests {
+		if pos, match := SplitGnubgID(tt.id); pos != tt.pos || match != tt.match {
+			t.Errorf("SplitGnubgID(%q) = %q, %q, want %q, %q", tt.id, pos, match, tt.pos, tt.match)
+		}
+	}
+}
+
+func FuzzBoardFromPositionID(f *testing.F) {
+	for _, id := range []string{startingPositionID, "sGfwATDgc/ABMA", "AAAAAAAAAAAAAA", "4HPwATDgc/ABMB", "4HPwATDgc/ABMAGARBAGE", "//////////////"} {
+		f.Add(id)
+	}
+	f.Fuzz(func(t *testing.T, id string) {
+		board, err := BoardFromPositionID(id)
+		if err != nil {
+			if !errors.Is(err, ErrInvalidPositionID) {
+				t.Fatalf("BoardFromPositionID(%q) error %v does not wrap ErrInvalidPositionID", id, err)
+			}
+			return
+		}
+		if got := PositionID(board); got != id {
+			t.Fatalf("PositionID(BoardFromPositionID(%q)) = %q", id, got)
+		}
+		if !CheckPosition(board) {
+			t.Fatalf("BoardFromPositionID(%q) accepted an illegal board %v", id, board)
+		}
+	})
+}
+
 func TestCheckPosition(t *testing.T) {
 	// Valid starting position
 	board := startingBoard()
@@ -155,6 +237,77 @@ func TestCombination(t *testing.T) {
 	}
 }
 
+func TestCombinationBounds(t *testing.T) {
+	if got := Combination(MaxN, 1); got != MaxN {
+		t.Errorf("Combination(%d, 1) = %d, want %d", MaxN, got, MaxN)
+	}
+	if got := Combination(1, 1); got != 1 {
+		t.Errorf("Combination(1, 1) = %d, want 1", got)
+	}
+
+	for _, tc := range []struct{ n, r uint32 }{
+		{0, 1}, {1, 0}, {MaxN + 1, 1}, {1, MaxR + 1},
+	} {
+		func() {
+			defer func() {
+				if p := recover(); (p != nil) != debugChecks {
+					t.Errorf("Combination(%d, %d) panic = %v, debugChecks = %v", tc.n, tc.r, p, debugChecks)
+				}
+			}()
+			if got := Combination(tc.n, tc.r); got != 0 {
+				t.Errorf("Combination(%d, %d) = %d, want 0", tc.n, tc.r, got)
+			}
+		}()
+	}
+}
+
+// TestPositionBearoffColdStart calls PositionBearoff from many goroutines
+// before the combination table exists. It re-runs itself in a fresh process
+// so the table really is cold; run with -race to catch unsafe initialization.
+func TestPositionBearoffColdStart(t *testing.T) {
+	if os.Getenv("POSITIONID_COLD_START") != "1" {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestPositionBearoffColdStart$")
+		cmd.Env = append(os.Environ(), "POSITIONID_COLD_START=1")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("cold start run failed: %v\n%s", err, out)
+		}
+		return
+	}
+
+	boards := [][]uint8{
+		{15, 0, 0, 0, 0, 0},
+		{3, 2, 1, 0, 0, 0},
+		{2, 2, 3, 3, 2, 3},
+		{0, 0, 0, 0, 0, 1},
+	}
+
+	const workers = 32
+	got := make([][]uint32, workers)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			<-start
+			for _, b := range boards {
+				got[w] = append(got[w], PositionBearoff(b, 6, 15))
+			}
+		}(w)
+	}
+	close(start)
+	wg.Wait()
+
+	for i, b := range boards {
+		want := PositionBearoff(b, 6, 15)
+		for w := range got {
+			if got[w][i] != want {
+				t.Errorf("worker %d: PositionBearoff(%v) = %d, want %d", w, b, got[w][i], want)
+			}
+		}
+	}
+}
+
 func TestPositionBearoffRoundTrip(t *testing.T) {
 	// Test a simple bearoff position
 	board := []uint8{3, 2, 1, 0, 0, 0} // 6 checkers on first 3 points
@@ -175,3 +328,40 @@ func TestPositionBearoffRoundTrip(t *testing.T) {
 		}
 	}
 }
+
+func TestCanonicalKey(t *testing.T) {
+	if key, swapped := CanonicalKey(startingBoard()); swapped || key != MakePositionKey(startingBoard()) {
+		t.Errorf("CanonicalKey(start) = %v, %v; want its own key, not swapped", key, swapped)
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		var board Board
+		for side := 0; side < 2; side++ {
+			for n := rng.Intn(16); n > 0; n-- {
+				board[side][rng.Intn(25)]++
+			}
+		}
+		mirror := SwapSides(board)
+
+		key, swapped := CanonicalKey(board)
+		mkey, mswapped := CanonicalKey(mirror)
+		if key != mkey {
+			t.Fatalf("%v: canonical key %v, its mirror's %v", board, key, mkey)
+		}
+		want := MakePositionKey(board)
+		if swapped {
+			want = MakePositionKey(mirror)
+		}
+		if key != want {
+			t.Fatalf("%v: swapped = %v but the key is not that side's", board, swapped)
+		}
+		if EqualBoards(board, mirror) {
+			if swapped || mswapped {
+				t.Fatalf("%v is its own mirror but swapped = %v, %v", board, swapped, mswapped)
+			}
+		} else if swapped == mswapped {
+			t.Fatalf("%v and its mirror both have swapped = %v", board, swapped)
+		}
+	}
+}
@@ -0,0 +1,592 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yourusername/bgengine/pkg/engine"
+)
+
+// Bot providers let an external bot play through the server without being
+// built into it. A WebSocket connection sends "register_bot" to become the
+// provider of a named bot; POST /api/bot/play then asks that bot for a
+// decision. The server sends the provider a "bot_request" message whose ID
+// correlates it with the provider's "bot_reply".
+//
+// A move is checked with engine.ValidateMove before it is accepted, and a
+// cube action against the actions the position allows. When the bot has no
+// provider, does not reply within the time limit, replies with something
+// illegal, or its provider disconnects first, the built-in engine decides
+// instead and the response says why. Every decision is graded by the tutor.
+
+const (
+	maxBotProviders   = 100              // Bots registered at once
+	maxBotNameLen     = 64               // Longest bot name
+	defaultBotTimeout = 5 * time.Second  // Time a provider has to reply by default
+	maxBotTimeout     = 20 * time.Second // Longest time_limit_ms a request may give, within the default write timeout
+)
+
+// Reasons the engine decided for a bot, in BotPlayResponse.Fallback.
+const (
+	botNoProvider   = "no_provider"   // No connection provides the bot
+	botTimeout      = "timeout"       // The provider did not reply in time
+	botDisconnected = "disconnected"  // The provider disconnected or unregistered before replying
+	botIllegalReply = "illegal_reply" // The reply was not a legal decision
+)
+
+// RegisterBotRequest is the payload of "register_bot" and "unregister_bot"
+// messages.
+type RegisterBotRequest struct {
+	Name string `json:"name"` // Bot name, as requests name it in "bot"
+}
+
+// RegisterBotResult is the reply to "register_bot" and "unregister_bot".
+type RegisterBotResult struct {
+	Name string   `json:"name"` // Bot name
+	Bots []string `json:"bots"` // Bots the connection now provides, sorted
+}
+
+// BotRequest is the payload of a "bot_request" message, a decision the
+// server asks a provider for. The position is given as to /api/move and
+// /api/cube, with the player on roll first in the score.
+type BotRequest struct {
+	Bot         string `json:"bot"`                    // Bot asked
+	Kind        string `json:"kind"`                   // "move", "cube" (double or no_double) or "take" (take or pass a double from the player on roll)
+	Position    string `json:"position"`               // Position ID, player on roll
+	Dice        [2]int `json:"dice,omitempty"`         // Roll to play (kind move)
+	MatchLength int    `json:"match_length,omitempty"` // 0 = money game
+	Score       [2]int `json:"score,omitempty"`        // Match score [player, opponent]
+	CubeValue   int    `json:"cube_value"`             // Cube value
+	CubeOwner   int    `json:"cube_owner"`             // -1=centered, 0=player, 1=opponent
+	Crawford    bool   `json:"crawford,omitempty"`     // Crawford game
+	TimeLimitMs int    `json:"time_limit_ms"`          // Time the provider has to reply
+}
+
+// BotReply is the payload of a "bot_reply" message, sent with the ID of the
+// bot_request it answers.
+type BotReply struct {
+	Move   string `json:"move,omitempty"`   // Move in notation, e.g. "8/5 6/5"; empty when no checker can move (kind move)
+	Action string `json:"action,omitempty"` // "double" or "no_double" (kind cube), "take" or "pass" (kind take)
+}
+
+// BotPlayRequest is the request body for POST /api/bot/play.
+type BotPlayRequest struct {
+	Bot         string `json:"bot"`                     // Bot to ask
+	Kind        string `json:"kind,omitempty"`          // "move", "cube" or "take" (default move with dice, else cube)
+	Position    string `json:"position"`                // Position ID, player on roll
+	Dice        [2]int `json:"dice,omitempty"`          // Roll to play (kind move)
+	MatchLength int    `json:"match_length,omitempty"`  // 0 = money game
+	Score       [2]int `json:"score,omitempty"`         // Match score [player, opponent]
+	CubeValue   int    `json:"cube_value,omitempty"`    // Cube value (default 1)
+	CubeOwner   int    `json:"cube_owner,omitempty"`    // -1=centered, 0=player, 1=opponent
+	Crawford    bool   `json:"crawford,omitempty"`      // Crawford game
+	MET         string `json:"met,omitempty"`           // Match equity table grading match decisions (see /api/met)
+	TimeLimitMs int    `json:"time_limit_ms,omitempty"` // Time the bot has to reply (default 5000, at most 20000)
+}
+
+// BotPlayResponse is the decision of a bot, or of the engine in its place,
+// graded by the tutor.
+type BotPlayResponse struct {
+	Bot       string             `json:"bot"`                  // Bot asked
+	Kind      string             `json:"kind"`                 // "move", "cube" or "take"
+	Source    string             `json:"source"`               // "bot", or "engine" when the engine decided instead
+	Fallback  string             `json:"fallback,omitempty"`   // Why the engine decided: "no_provider", "timeout", "disconnected" or "illegal_reply"
+	Rejected  string             `json:"rejected,omitempty"`   // What was wrong with the bot's reply, with illegal_reply
+	Move      *string            `json:"move,omitempty"`       // Move played (kind move); empty when no checker can move
+	Action    string             `json:"action,omitempty"`     // Action taken (kinds cube and take)
+	ElapsedMs float64            `json:"elapsed_ms"`           // Time the bot took to reply, or until the engine took over
+	MoveGrade *TutorMoveResponse `json:"move_grade,omitempty"` // The tutor's grade of the move
+	CubeGrade *TutorCubeResponse `json:"cube_grade,omitempty"` // The tutor's grade of the action
+}
+
+// botError is a request error from a bot operation.
+type botError struct {
+	status int
+	msg    string
+	code   string
+}
+
+// botHub is the registry of bot providers and of the requests waiting for
+// their replies.
+type botHub struct {
+	mu        sync.Mutex
+	providers map[string]*WSClient // Provider of each bot
+	calls     map[string]*botCall  // Requests sent to providers, by correlation ID
+}
+
+// botCall is a request sent to a provider. The reply, the provider going
+// away or the waiter giving up removes it from the hub; only the reply and
+// the provider going away answer on done, so at most once.
+type botCall struct {
+	bot      string
+	provider *WSClient
+	done     chan botAnswer // Buffered for the one answer
+}
+
+// botAnswer is how a request sent to a provider ended.
+type botAnswer struct {
+	reply BotReply
+	lost  bool // The provider disconnected or unregistered the bot first
+}
+
+// botOutcome is the result of asking a bot: its reply, or why there is
+// none.
+type botOutcome struct {
+	reply    BotReply
+	fallback string        // "" if the bot replied
+	elapsed  time.Duration // Time until the reply or the fallback
+	provider *WSClient     // Provider asked, nil without one
+	id       string        // Correlation ID of the request
+}
+
+func newBotHub() *botHub {
+	return &botHub{
+		providers: make(map[string]*WSClient),
+		calls:     make(map[string]*botCall),
+	}
+}
+
+func checkBotName(name string) *botError {
+	if name == "" {
+		return &botError{http.StatusBadRequest, "bot name is required", "MISSING_BOT"}
+	}
+	if len(name) > maxBotNameLen {
+		return &botError{http.StatusBadRequest, fmt.Sprintf("bot name longer than %d bytes", maxBotNameLen), "INVALID_BOT"}
+	}
+	return nil
+}
+
+// register makes c the provider of the bot name. A bot has one provider at
+// a time; c may provide several.
+func (hb *botHub) register(c *WSClient, name string) (*RegisterBotResult, *botError) {
+	if err := checkBotName(name); err != nil {
+		return nil, err
+	}
+	hb.mu.Lock()
+	defer hb.mu.Unlock()
+	switch p := hb.providers[name]; {
+	case p == c:
+	case p != nil:
+		return nil, &botError{http.StatusConflict, fmt.Sprintf("bot %q already has a provider", name), "BOT_TAKEN"}
+	case len(hb.providers) >= maxBotProviders:
+		return nil, &botError{http.StatusServiceUnavailable, "too many bots registered", "TOO_MANY_BOTS"}
+	}
+	hb.providers[name] = c
+	return &RegisterBotResult{Name: name, Bots: hb.botsOf(c)}, nil
+}
+
+// unregister stops c providing the bot name. Requests waiting for c's
+// reply as that bot are answered as lost.
+func (hb *botHub) unregister(c *WSClient, name string) *RegisterBotResult {
+	hb.mu.Lock()
+	defer hb.mu.Unlock()
+	if hb.providers[name] == c {
+		delete(hb.providers, name)
+		hb.loseCalls(c, name)
+	}
+	return &RegisterBotResult{Name: name, Bots: hb.botsOf(c)}
+}
+
+// unregisterAll removes c, which has disconnected, as the provider of every
+// bot. Requests waiting for its replies are answered as lost.
+func (hb *botHub) unregisterAll(c *WSClient) {
+	hb.mu.Lock()
+	defer hb.mu.Unlock()
+	for name, p := range hb.providers {
+		if p == c {
+			delete(hb.providers, name)
+		}
+	}
+	hb.loseCalls(c, "")
+}
+
+// loseCalls answers the requests waiting for c's replies as lost, only
+// those to the bot name unless it is "". The caller must hold hb.mu.
+func (hb *botHub) loseCalls(c *WSClient, name string) {
+	for id, call := range hb.calls {
+		if call.provider == c && (name == "" || call.bot == name) {
+			delete(hb.calls, id)
+			call.done <- botAnswer{lost: true}
+		}
+	}
+}
+
+// botsOf returns the bots c provides, sorted. The caller must hold hb.mu.
+func (hb *botHub) botsOf(c *WSClient) []string {
+	bots := []string{}
+	for name, p := range hb.providers {
+		if p == c {
+			bots = append(bots, name)
+		}
+	}
+	sort.Strings(bots)
+	return bots
+}
+
+// deliver answers the request with correlation ID id with reply, sent by c.
+// It returns false if no request of c's with that ID is waiting: it was
+// never sent to c, or its waiter has given up.
+func (hb *botHub) deliver(c *WSClient, id string, reply BotReply) bool {
+	hb.mu.Lock()
+	call := hb.calls[id]
+	if call == nil || call.provider != c {
+		hb.mu.Unlock()
+		return false
+	}
+	delete(hb.calls, id)
+	hb.mu.Unlock()
+	call.done <- botAnswer{reply: reply}
+	return true
+}
+
+// ask sends req to the provider of req.Bot and waits up to timeout, or
+// until ctx is done, for its reply.
+func (hb *botHub) ask(ctx context.Context, req BotRequest, timeout time.Duration) botOutcome {
+	start := time.Now()
+	hb.mu.Lock()
+	provider := hb.providers[req.Bot]
+	if provider == nil {
+		hb.mu.Unlock()
+		return botOutcome{fallback: botNoProvider}
+	}
+	call := &botCall{bot: req.Bot, provider: provider, done: make(chan botAnswer, 1)}
+	id := hb.newCallID()
+	hb.calls[id] = call
+	hb.mu.Unlock()
+
+	out := botOutcome{provider: provider, id: id}
+	provider.send(WSResponse{Type: "bot_request", ID: id, Payload: req})
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case answer := <-call.done:
+		out.answered(answer)
+	case <-timer.C:
+		out.fallback = botTimeout
+	case <-ctx.Done():
+		out.fallback = botTimeout
+	}
+	if out.fallback == botTimeout {
+		hb.mu.Lock()
+		_, waiting := hb.calls[id]
+		delete(hb.calls, id)
+		hb.mu.Unlock()
+		if !waiting {
+			// Answered while giving up
+			out.fallback = ""
+			out.answered(<-call.done)
+		} else {
+			provider.send(WSResponse{Type: "error", ID: id, Error: "no reply in time; the engine decided instead", Code: "BOT_TIMEOUT"})
+		}
+	}
+	out.elapsed = time.Since(start)
+	return out
+}
+
+// answered records how the request of out ended.
+func (out *botOutcome) answered(answer botAnswer) {
+	if answer.lost {
+		out.fallback = botDisconnected
+		return
+	}
+	out.reply = answer.reply
+}
+
+// reject tells the provider what was wrong with its reply.
+func (out *botOutcome) reject(msg string) {
+	out.provider.send(WSResponse{Type: "error", ID: out.id, Error: msg + "; the engine decided instead", Code: "ILLEGAL_REPLY"})
+}
+
+// newCallID returns a correlation ID no waiting request has. The caller
+// must hold hb.mu.
+func (hb *botHub) newCallID() string {
+	for {
+		var b [8]byte
+		rand.Read(b[:])
+		id := "bot-" + hex.EncodeToString(b[:])
+		if _, ok := hb.calls[id]; !ok {
+			return id
+		}
+	}
+}
+
+// BotPlay handles POST /api/bot/play. It asks the bot named in the request
+// for a move or cube action and grades it, or the engine's decision when
+// the bot gives none it may play. No worker slot is held while the bot
+// thinks.
+func (h *Handlers) BotPlay(w http.ResponseWriter, r *http.Request) {
+	var req BotPlayRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if err := checkBotName(req.Bot); err != nil {
+		writeError(w, err.status, err.msg, err.code)
+		return
+	}
+	if req.Kind == "" {
+		req.Kind = "cube"
+		if req.Dice != [2]int{} {
+			req.Kind = "move"
+		}
+	}
+	switch req.Kind {
+	case "move":
+		if req.Dice[0] < 1 || req.Dice[0] > 6 || req.Dice[1] < 1 || req.Dice[1] > 6 {
+			writeError(w, http.StatusBadRequest, "dice must be 1-6 to ask for a move", "INVALID_DICE")
+			return
+		}
+	case "cube", "take":
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("kind must be move, cube or take, got %q", req.Kind), "INVALID_KIND")
+		return
+	}
+	timeout := defaultBotTimeout
+	if req.TimeLimitMs < 0 {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("time_limit_ms must not be negative, got %d", req.TimeLimitMs), "INVALID_TIME_LIMIT")
+		return
+	}
+	if err := exceeds("time_limit_ms", req.TimeLimitMs, int(maxBotTimeout/time.Millisecond)); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error(), "INVALID_TIME_LIMIT")
+		return
+	}
+	if req.TimeLimitMs > 0 {
+		timeout = time.Duration(req.TimeLimitMs) * time.Millisecond
+	}
+	if !h.checkMET(w, req.MET) {
+		return
+	}
+	board, err := parsePositionID(req.Position)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid position", "INVALID_POSITION")
+		return
+	}
+	cubeValue := req.CubeValue
+	if cubeValue <= 0 {
+		cubeValue = 1
+	}
+	gs := &engine.GameState{
+		Board: engine.Board(board), Turn: 0, CubeValue: cubeValue, CubeOwner: req.CubeOwner,
+		MatchLength: req.MatchLength, Score: req.Score, Crawford: req.Crawford, MET: req.MET,
+	}
+	if req.Kind == "move" {
+		gs.Dice = req.Dice
+	}
+
+	// A cube decision needs the cube analysis to know what the bot may do
+	var cube *engine.CubeAnalysis
+	if req.Kind != "move" {
+		if !h.acquireFast(w, r) {
+			return
+		}
+		cube, err = h.engine.AnalyzeCube(gs)
+		h.releaseFast()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "cube analysis failed", "ANALYSIS_ERROR")
+			return
+		}
+		if req.Kind == "take" && cube.Opponent == nil {
+			writeError(w, http.StatusBadRequest, "the player on roll cannot double, so there is no double to take", "CUBE_NOT_AVAILABLE")
+			return
+		}
+	}
+
+	out := h.bots.ask(r.Context(), BotRequest{
+		Bot: req.Bot, Kind: req.Kind, Position: req.Position, Dice: gs.Dice,
+		MatchLength: req.MatchLength, Score: req.Score, CubeValue: cubeValue, CubeOwner: req.CubeOwner,
+		Crawford: req.Crawford, TimeLimitMs: int(timeout / time.Millisecond),
+	}, timeout)
+	resp := &BotPlayResponse{Bot: req.Bot, Kind: req.Kind, Source: "bot", ElapsedMs: float64(out.elapsed.Microseconds()) / 1000}
+
+	if !h.acquireFast(w, r) {
+		return
+	}
+	defer h.releaseFast()
+	cfg := engine.DefaultAnalysisConfig()
+	if req.Kind == "move" {
+		m, rejected := botMove(gs, out)
+		if m == nil {
+			if m, err = h.engineMove(gs); err != nil {
+				writeError(w, http.StatusInternalServerError, "move analysis failed", "ANALYSIS_ERROR")
+				return
+			}
+		}
+		resp.fallBack(out, rejected)
+		analysis, err := h.engine.AnalyzeMoveSkillWithConfig(gs, *m, gs.Dice, cfg)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "move analysis failed", "ANALYSIS_ERROR")
+			return
+		}
+		grade := tutorMoveResponse(gs, analysis)
+		resp.Move = &grade.PlayedMove
+		resp.MoveGrade = &grade
+	} else {
+		action, rejected := botAction(req.Kind, cube, out)
+		if action == nil {
+			action = engineAction(req.Kind, cube)
+		}
+		resp.fallBack(out, rejected)
+		analysis, err := h.engine.AnalyzeCubeSkillWithConfig(gs, *action, cfg)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "cube analysis failed", "ANALYSIS_ERROR")
+			return
+		}
+		grade := tutorCubeResponse(analysis)
+		resp.Action = grade.Played
+		resp.CubeGrade = &grade
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// fallBack records in resp that the engine decided, if the bot's outcome
+// out gave no reply or rejected says what was wrong with it.
+func (resp *BotPlayResponse) fallBack(out botOutcome, rejected string) {
+	switch {
+	case out.fallback != "":
+		resp.Source, resp.Fallback = "engine", out.fallback
+	case rejected != "":
+		resp.Source, resp.Fallback, resp.Rejected = "engine", botIllegalReply, rejected
+		out.reject(rejected)
+	}
+}
+
+// botMove returns the move the bot replied with in out, or nil and, if it
+// replied with one, what is wrong with it.
+func botMove(gs *engine.GameState, out botOutcome) (*engine.Move, string) {
+	if out.fallback != "" {
+		return nil, ""
+	}
+	m, err := engine.ParseMove(out.reply.Move)
+	if err != nil {
+		return nil, fmt.Sprintf("invalid move notation %q: %v", out.reply.Move, err)
+	}
+	if err := engine.ValidateMove(gs.Board, gs.Dice, m); err != nil {
+		return nil, err.Error()
+	}
+	return &m, ""
+}
+
+// botAction returns the cube action of kind the bot replied with in out,
+// or nil and, if it replied with one, what is wrong with it. cube is the
+// analysis of the position.
+func botAction(kind string, cube *engine.CubeAnalysis, out botOutcome) (*engine.CubeAction, string) {
+	if out.fallback != "" {
+		return nil, ""
+	}
+	allowed := map[string][]engine.CubeAction{
+		"cube": {engine.Double, engine.NoDouble},
+		"take": {engine.Take, engine.Pass},
+	}[kind]
+	action, err := parseCubeAction(out.reply.Action)
+	if err == nil && !containsAction(allowed, action) {
+		err = fmt.Errorf("%s is not an answer to a %s request", strings.ToLower(out.reply.Action), kind)
+	}
+	if err != nil {
+		return nil, err.Error()
+	}
+	if action == engine.Double && cube.Opponent == nil {
+		return nil, "the cube is not available to double"
+	}
+	return &action, ""
+}
+
+func containsAction(actions []engine.CubeAction, a engine.CubeAction) bool {
+	for _, b := range actions {
+		if a == b {
+			return true
+		}
+	}
+	return false
+}
+
+// engineMove returns the engine's move in gs, in place of a bot's.
+func (h *Handlers) engineMove(gs *engine.GameState) (*engine.Move, error) {
+	m, _, err := h.engine.BestMove(gs, gs.Dice, engine.DefaultEvalOptions())
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// engineAction returns the engine's cube action of kind from the cube
+// analysis, in place of a bot's. A position too good to double is played
+// on, and a double is taken when the take is worth at least the pass.
+func engineAction(kind string, cube *engine.CubeAnalysis) *engine.CubeAction {
+	action := engine.NoDouble
+	switch {
+	case kind == "take" && cube.Opponent.PassCost >= 0:
+		action = engine.Take
+	case kind == "take":
+		action = engine.Pass
+	case cube.Decision.Action == engine.Double:
+		action = engine.Double
+	}
+	return &action
+}
+
+// acquireFast takes a fast worker slot, if the server has a pool, writing
+// SERVER_BUSY and returning false if the request ends first. The caller
+// must call releaseFast once it returns true.
+func (h *Handlers) acquireFast(w http.ResponseWriter, r *http.Request) bool {
+	if h.pool == nil {
+		return true
+	}
+	if err := h.pool.AcquireFast(r.Context()); err != nil {
+		writeError(w, http.StatusServiceUnavailable, "server busy", "SERVER_BUSY")
+		return false
+	}
+	return true
+}
+
+// releaseFast releases the slot taken by acquireFast.
+func (h *Handlers) releaseFast() {
+	if h.pool != nil {
+		h.pool.ReleaseFast()
+	}
+}
+
+// handleRegisterBot makes the connection the provider of a bot (see
+// botHub).
+func (c *WSClient) handleRegisterBot(ctx context.Context, msg WSMessage) {
+	var req RegisterBotRequest
+	if err := json.Unmarshal(msg.Payload, &req); err != nil {
+		c.reply(ctx, WSResponse{Type: "error", ID: msg.ID, Error: "invalid payload"})
+		return
+	}
+	result, err := c.handlers.bots.register(c, req.Name)
+	if err != nil {
+		c.reply(ctx, WSResponse{Type: "error", ID: msg.ID, Error: err.msg, Code: err.code})
+		return
+	}
+	c.reply(ctx, WSResponse{Type: "result", ID: msg.ID, Payload: result})
+}
+
+func (c *WSClient) handleUnregisterBot(ctx context.Context, msg WSMessage) {
+	var req RegisterBotRequest
+	if err := json.Unmarshal(msg.Payload, &req); err != nil {
+		c.reply(ctx, WSResponse{Type: "error", ID: msg.ID, Error: "invalid payload"})
+		return
+	}
+	c.reply(ctx, WSResponse{Type: "result", ID: msg.ID, Payload: c.handlers.bots.unregister(c, req.Name)})
+}
+
+// handleBotReply answers the bot_request with the message's ID. A reply
+// too late to be used gets an error; a reply that is used gets no answer,
+// or an error if it is illegal.
+func (c *WSClient) handleBotReply(ctx context.Context, msg WSMessage) {
+	var reply BotReply
+	if err := json.Unmarshal(msg.Payload, &reply); err != nil {
+		c.reply(ctx, WSResponse{Type: "error", ID: msg.ID, Error: "invalid payload"})
+		return
+	}
+	if !c.handlers.bots.deliver(c, msg.ID, reply) {
+		c.reply(ctx, WSResponse{Type: "error", ID: msg.ID, Error: "no bot request with this ID is waiting for a reply from this connection", Code: "UNKNOWN_BOT_REQUEST"})
+	}
+}
@@ -0,0 +1,288 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// botServer starts a server bots can be registered with and played
+// through.
+func botServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(NewServer(getTestEngine(), DefaultConfig(), "test").Handler())
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// registerBot makes ws the provider of the bot name.
+func registerBot(t *testing.T, ws *websocket.Conn, name string) {
+	t.Helper()
+	wsSend(t, ws, "register_bot", "reg-"+name, RegisterBotRequest{Name: name})
+	if resp, _ := readWS(t, ws, 5*time.Second); resp.Type != "result" {
+		t.Fatalf("register_bot %q = %+v, want result", name, resp)
+	}
+}
+
+// botPlay posts req to /api/bot/play in the background, since the reply
+// waits for the fake provider.
+func botPlay(t *testing.T, srv *httptest.Server, req BotPlayRequest) <-chan *http.Response {
+	t.Helper()
+	body, _ := json.Marshal(req)
+	out := make(chan *http.Response, 1)
+	go func() {
+		resp, err := http.Post(srv.URL+"/api/bot/play", "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Errorf("POST /api/bot/play: %v", err)
+			close(out)
+			return
+		}
+		out <- resp
+	}()
+	return out
+}
+
+// botPlayResult waits for the response to a botPlay and decodes it.
+func botPlayResult(t *testing.T, out <-chan *http.Response) BotPlayResponse {
+	t.Helper()
+	var resp *http.Response
+	select {
+	case resp = <-out:
+	case <-time.After(10 * time.Second):
+		t.Fatal("no response from /api/bot/play")
+	}
+	if resp == nil {
+		t.FailNow()
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("/api/bot/play status = %d, want 200", resp.StatusCode)
+	}
+	var result BotPlayResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return result
+}
+
+// readBotRequest reads the bot_request the fake provider ws is sent.
+func readBotRequest(t *testing.T, ws *websocket.Conn) (string, BotRequest) {
+	t.Helper()
+	msg, _ := readWS(t, ws, 5*time.Second)
+	if msg.Type != "bot_request" || msg.ID == "" {
+		t.Fatalf("provider got %+v, want a bot_request with an ID", msg)
+	}
+	var req BotRequest
+	if err := json.Unmarshal(msg.Payload.(json.RawMessage), &req); err != nil {
+		t.Fatalf("decode bot_request: %v", err)
+	}
+	return msg.ID, req
+}
+
+func TestBotPlayMove(t *testing.T) {
+	srv := botServer(t)
+	ws := dialWS(t, srv)
+	defer ws.Close()
+	registerBot(t, ws, "gnubot")
+
+	out := botPlay(t, srv, BotPlayRequest{Bot: "gnubot", Position: "4HPwATDgc/ABMA", Dice: [2]int{3, 1}, TimeLimitMs: 3000})
+	id, req := readBotRequest(t, ws)
+	if req.Bot != "gnubot" || req.Kind != "move" || req.Dice != [2]int{3, 1} || req.CubeValue != 1 || req.TimeLimitMs != 3000 {
+		t.Errorf("bot_request = %+v", req)
+	}
+	wsSend(t, ws, "bot_reply", id, BotReply{Move: "8/5 6/5"})
+
+	result := botPlayResult(t, out)
+	if result.Source != "bot" || result.Fallback != "" {
+		t.Errorf("source = %q, fallback = %q, want the bot's move", result.Source, result.Fallback)
+	}
+	if result.Move == nil || *result.Move != "8/5 6/5" {
+		t.Errorf("move = %v, want 8/5 6/5", result.Move)
+	}
+	if result.MoveGrade == nil || result.MoveGrade.PlayedMove != "8/5 6/5" {
+		t.Errorf("move_grade = %+v, want a grade of 8/5 6/5", result.MoveGrade)
+	}
+}
+
+func TestBotPlayIllegalReply(t *testing.T) {
+	srv := botServer(t)
+	ws := dialWS(t, srv)
+	defer ws.Close()
+	registerBot(t, ws, "gnubot")
+
+	out := botPlay(t, srv, BotPlayRequest{Bot: "gnubot", Position: "4HPwATDgc/ABMA", Dice: [2]int{3, 1}})
+	id, _ := readBotRequest(t, ws)
+	wsSend(t, ws, "bot_reply", id, BotReply{Move: "24/18"})
+
+	result := botPlayResult(t, out)
+	if result.Source != "engine" || result.Fallback != "illegal_reply" || result.Rejected == "" {
+		t.Errorf("source = %q, fallback = %q, rejected = %q, want the engine after an illegal reply", result.Source, result.Fallback, result.Rejected)
+	}
+	if result.Move == nil || result.MoveGrade == nil || *result.Move != result.MoveGrade.PlayedMove {
+		t.Errorf("move = %v, move_grade = %+v, want the engine's move graded", result.Move, result.MoveGrade)
+	}
+	if msg, _ := readWS(t, ws, 5*time.Second); msg.Type != "error" || msg.ID != id || msg.Code != "ILLEGAL_REPLY" {
+		t.Errorf("provider got %+v, want ILLEGAL_REPLY for %s", msg, id)
+	}
+}
+
+func TestBotPlayTimeout(t *testing.T) {
+	srv := botServer(t)
+	ws := dialWS(t, srv)
+	defer ws.Close()
+	registerBot(t, ws, "slowbot")
+
+	out := botPlay(t, srv, BotPlayRequest{Bot: "slowbot", Position: "4HPwATDgc/ABMA", Dice: [2]int{6, 5}, TimeLimitMs: 100})
+	id, _ := readBotRequest(t, ws)
+
+	result := botPlayResult(t, out)
+	if result.Source != "engine" || result.Fallback != "timeout" || result.Move == nil {
+		t.Errorf("source = %q, fallback = %q, move = %v, want the engine's move after a timeout", result.Source, result.Fallback, result.Move)
+	}
+	if msg, _ := readWS(t, ws, 5*time.Second); msg.Type != "error" || msg.ID != id || msg.Code != "BOT_TIMEOUT" {
+		t.Errorf("provider got %+v, want BOT_TIMEOUT for %s", msg, id)
+	}
+
+	// A reply after the timeout is too late
+	wsSend(t, ws, "bot_reply", id, BotReply{Move: "24/13"})
+	if msg, _ := readWS(t, ws, 5*time.Second); msg.Type != "error" || msg.Code != "UNKNOWN_BOT_REQUEST" {
+		t.Errorf("late reply got %+v, want UNKNOWN_BOT_REQUEST", msg)
+	}
+}
+
+func TestBotPlayProviderDisconnects(t *testing.T) {
+	srv := botServer(t)
+	ws := dialWS(t, srv)
+	registerBot(t, ws, "gnubot")
+
+	out := botPlay(t, srv, BotPlayRequest{Bot: "gnubot", Position: "4HPwATDgc/ABMA", Dice: [2]int{3, 1}, TimeLimitMs: 20000})
+	readBotRequest(t, ws)
+	ws.Close()
+
+	// The engine takes over at once rather than at the time limit
+	result := botPlayResult(t, out)
+	if result.Source != "engine" || result.Fallback != "disconnected" {
+		t.Errorf("source = %q, fallback = %q, want the engine after a disconnect", result.Source, result.Fallback)
+	}
+	if result.ElapsedMs >= 20000 {
+		t.Errorf("elapsed_ms = %v, want the fallback before the time limit", result.ElapsedMs)
+	}
+
+	// The bot is free for another provider, and has none until one registers
+	result = botPlayResult(t, botPlay(t, srv, BotPlayRequest{Bot: "gnubot", Position: "4HPwATDgc/ABMA", Dice: [2]int{3, 1}}))
+	if result.Fallback != "no_provider" {
+		t.Errorf("fallback = %q, want no_provider once the provider is gone", result.Fallback)
+	}
+	other := dialWS(t, srv)
+	defer other.Close()
+	registerBot(t, other, "gnubot")
+}
+
+func TestBotPlayCube(t *testing.T) {
+	srv := botServer(t)
+	ws := dialWS(t, srv)
+	defer ws.Close()
+	registerBot(t, ws, "cubebot")
+
+	tests := []struct {
+		kind     string
+		reply    string
+		source   string
+		fallback string
+	}{
+		{"take", "take", "bot", ""},
+		{"take", "double", "engine", "illegal_reply"},
+		{"cube", "no_double", "bot", ""},
+		{"cube", "pass", "engine", "illegal_reply"},
+	}
+	for _, tt := range tests {
+		out := botPlay(t, srv, BotPlayRequest{Bot: "cubebot", Kind: tt.kind, Position: "4HPwATDgc/ABMA"})
+		id, req := readBotRequest(t, ws)
+		if req.Kind != tt.kind {
+			t.Errorf("bot_request kind = %q, want %q", req.Kind, tt.kind)
+		}
+		wsSend(t, ws, "bot_reply", id, BotReply{Action: tt.reply})
+		result := botPlayResult(t, out)
+		if result.Source != tt.source || result.Fallback != tt.fallback {
+			t.Errorf("%s %s: source = %q, fallback = %q, want %q, %q", tt.kind, tt.reply, result.Source, result.Fallback, tt.source, tt.fallback)
+		}
+		if result.CubeGrade == nil || result.Action != result.CubeGrade.Played {
+			t.Errorf("%s %s: action = %q, cube_grade = %+v, want the action graded", tt.kind, tt.reply, result.Action, result.CubeGrade)
+		}
+		if tt.source == "bot" && result.Action != tt.reply {
+			t.Errorf("%s %s: action = %q, want the bot's", tt.kind, tt.reply, result.Action)
+		}
+		if tt.fallback != "" {
+			if msg, _ := readWS(t, ws, 5*time.Second); msg.Code != "ILLEGAL_REPLY" {
+				t.Errorf("%s %s: provider got %+v, want ILLEGAL_REPLY", tt.kind, tt.reply, msg)
+			}
+		}
+	}
+}
+
+func TestRegisterBot(t *testing.T) {
+	srv := botServer(t)
+	a := dialWS(t, srv)
+	defer a.Close()
+	b := dialWS(t, srv)
+	defer b.Close()
+
+	registerBot(t, a, "gnubot")
+	registerBot(t, a, "alpha")
+	wsSend(t, b, "register_bot", "taken", RegisterBotRequest{Name: "gnubot"})
+	if msg, _ := readWS(t, b, 5*time.Second); msg.Type != "error" || msg.Code != "BOT_TAKEN" {
+		t.Errorf("second provider got %+v, want BOT_TAKEN", msg)
+	}
+	wsSend(t, a, "register_bot", "empty", RegisterBotRequest{})
+	if msg, _ := readWS(t, a, 5*time.Second); msg.Code != "MISSING_BOT" {
+		t.Errorf("empty name got %+v, want MISSING_BOT", msg)
+	}
+
+	wsSend(t, a, "unregister_bot", "unreg", RegisterBotRequest{Name: "gnubot"})
+	msg, _ := readWS(t, a, 5*time.Second)
+	var result RegisterBotResult
+	json.Unmarshal(msg.Payload.(json.RawMessage), &result)
+	if len(result.Bots) != 1 || result.Bots[0] != "alpha" {
+		t.Errorf("bots after unregistering = %v, want [alpha]", result.Bots)
+	}
+	registerBot(t, b, "gnubot")
+
+	// A reply to a request never sent to the connection is refused
+	wsSend(t, a, "bot_reply", "bot-0000", BotReply{Move: "8/5 6/5"})
+	if msg, _ := readWS(t, a, 5*time.Second); msg.Code != "UNKNOWN_BOT_REQUEST" {
+		t.Errorf("stray reply got %+v, want UNKNOWN_BOT_REQUEST", msg)
+	}
+}
+
+func TestBotPlayValidation(t *testing.T) {
+	h := NewHandlers(getTestEngine(), "1.0.0")
+	tests := []struct {
+		name string
+		req  BotPlayRequest
+		code string
+	}{
+		{"no bot", BotPlayRequest{Position: "4HPwATDgc/ABMA", Dice: [2]int{3, 1}}, "MISSING_BOT"},
+		{"bad kind", BotPlayRequest{Bot: "b", Kind: "resign", Position: "4HPwATDgc/ABMA"}, "INVALID_KIND"},
+		{"move without dice", BotPlayRequest{Bot: "b", Kind: "move", Position: "4HPwATDgc/ABMA"}, "INVALID_DICE"},
+		{"time limit too long", BotPlayRequest{Bot: "b", Position: "4HPwATDgc/ABMA", Dice: [2]int{3, 1}, TimeLimitMs: 60000}, "INVALID_TIME_LIMIT"},
+		{"bad position", BotPlayRequest{Bot: "b", Position: "nope", Dice: [2]int{3, 1}}, "INVALID_POSITION"},
+		{"take of a dead cube", BotPlayRequest{Bot: "b", Kind: "take", Position: "4HPwATDgc/ABMA", CubeOwner: 1, CubeValue: 2}, "CUBE_NOT_AVAILABLE"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(tt.req)
+			w := httptest.NewRecorder()
+			h.BotPlay(w, httptest.NewRequest("POST", "/api/bot/play", bytes.NewReader(body)))
+			var resp ErrorResponse
+			json.NewDecoder(w.Body).Decode(&resp)
+			if w.Code != http.StatusBadRequest || resp.Code != tt.code {
+				t.Errorf("status = %d, code = %q, want 400 %s", w.Code, resp.Code, tt.code)
+			}
+		})
+	}
+}
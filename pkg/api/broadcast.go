@@ -0,0 +1,327 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/yourusername/bgengine/pkg/engine"
+)
+
+// Broadcast channels stream a live game to spectators. A producer sends
+// "broadcast_update" messages over the WebSocket (or POSTs /api/broadcast);
+// each update is analyzed once and the annotated frame is fanned out to every
+// connection that joined the channel with a "subscribe" message.
+//
+// Fan-out never blocks the producer: each subscriber keeps only the latest
+// unsent frame of each channel, so a slow spectator skips intermediate frames
+// instead of holding up the others.
+
+const (
+	maxBroadcastChannels   = 1000             // Channels open at once
+	maxChannelNameLen      = 64               // Longest channel name
+	maxClientSubscriptions = 16               // Channels one connection may join
+	broadcastCacheSize     = 32               // Analyses kept per channel
+	broadcastIdleTimeout   = 10 * time.Minute // Unwatched channels are dropped after this
+	broadcastNumMoves      = 3                // Moves shown to spectators
+)
+
+// BroadcastUpdateRequest is the payload of a "broadcast_update" message and
+// the request body for POST /api/broadcast.
+type BroadcastUpdateRequest struct {
+	Channel     string `json:"channel"`                // Channel name
+	Position    string `json:"position"`               // Position ID, player on roll
+	Dice        [2]int `json:"dice,omitempty"`         // Roll to play; omit before the roll
+	MatchLength int    `json:"match_length,omitempty"` // 0 = money game
+	Score       [2]int `json:"score,omitempty"`        // Match score [player, opponent]
+	CubeValue   int    `json:"cube_value,omitempty"`   // Cube value (default 1)
+	CubeOwner   int    `json:"cube_owner,omitempty"`   // -1=centered, 0=player, 1=opponent
+	Crawford    bool   `json:"crawford,omitempty"`     // Crawford game
+	Comment     string `json:"comment,omitempty"`      // Commentary passed to spectators as is
+}
+
+// BroadcastFrame is an annotated update, sent to subscribers as a
+// "broadcast" message.
+type BroadcastFrame struct {
+	Channel  string                 `json:"channel"`  // Channel name
+	Seq      int64                  `json:"seq"`      // Update number in the channel, from 1
+	Update   BroadcastUpdateRequest `json:"update"`   // The update as sent by the producer
+	Analysis *AnalyzeResponse       `json:"analysis"` // Evaluation, cube decision and best moves
+}
+
+// BroadcastResult is the producer's reply to an update.
+type BroadcastResult struct {
+	Channel     string `json:"channel"`     // Channel name
+	Seq         int64  `json:"seq"`         // Number given to the update
+	Subscribers int    `json:"subscribers"` // Connections the frame was sent to
+	Cached      bool   `json:"cached"`      // The analysis was reused from an earlier update
+}
+
+// SubscribeRequest is the payload of "subscribe" and "unsubscribe" messages.
+type SubscribeRequest struct {
+	Channel string `json:"channel"` // Channel name
+}
+
+// SubscribeResult is the reply to "subscribe" and "unsubscribe" messages.
+type SubscribeResult struct {
+	Channel     string `json:"channel"`     // Channel name
+	Subscribers int    `json:"subscribers"` // Connections watching the channel
+}
+
+// broadcastError is a request error from a broadcast operation.
+type broadcastError struct {
+	status int
+	msg    string
+	code   string
+}
+
+// broadcastHub is the registry of broadcast channels.
+type broadcastHub struct {
+	mu       sync.Mutex
+	channels map[string]*broadcastChannel
+}
+
+// broadcastChannel is one live game. Everything but the analysis cache is
+// guarded by the hub's mutex.
+type broadcastChannel struct {
+	name        string
+	subscribers map[*WSClient]struct{}
+	seq         int64
+	last        *BroadcastFrame // Latest frame, sent to new subscribers
+	updated     time.Time       // Time of the last update or unsubscribe
+
+	// evalMu serializes analyses so that identical updates arriving together
+	// are analyzed once.
+	evalMu sync.Mutex
+	cache  map[string]*AnalyzeResponse
+}
+
+func newBroadcastHub() *broadcastHub {
+	return &broadcastHub{channels: make(map[string]*broadcastChannel)}
+}
+
+// channel returns the named channel, opening it if needed.
+// The caller must hold hb.mu.
+func (hb *broadcastHub) channel(name string) (*broadcastChannel, *broadcastError) {
+	if ch := hb.channels[name]; ch != nil {
+		return ch, nil
+	}
+	if len(hb.channels) >= maxBroadcastChannels {
+		hb.dropIdle(time.Now())
+		if len(hb.channels) >= maxBroadcastChannels {
+			return nil, &broadcastError{http.StatusServiceUnavailable, "too many broadcast channels", "TOO_MANY_CHANNELS"}
+		}
+	}
+	ch := &broadcastChannel{
+		name:        name,
+		subscribers: make(map[*WSClient]struct{}),
+		updated:     time.Now(),
+		cache:       make(map[string]*AnalyzeResponse),
+	}
+	hb.channels[name] = ch
+	return ch, nil
+}
+
+// dropIdle removes channels nobody has watched or updated for
+// broadcastIdleTimeout. The caller must hold hb.mu.
+func (hb *broadcastHub) dropIdle(now time.Time) {
+	for name, ch := range hb.channels {
+		if len(ch.subscribers) == 0 && now.Sub(ch.updated) > broadcastIdleTimeout {
+			delete(hb.channels, name)
+		}
+	}
+}
+
+func checkChannelName(name string) *broadcastError {
+	if name == "" {
+		return &broadcastError{http.StatusBadRequest, "channel is required", "MISSING_CHANNEL"}
+	}
+	if len(name) > maxChannelNameLen {
+		return &broadcastError{http.StatusBadRequest,
+			fmt.Sprintf("channel name longer than %d bytes", maxChannelNameLen), "INVALID_CHANNEL"}
+	}
+	return nil
+}
+
+// subscribe adds c to the channel and queues the channel's latest frame for it.
+func (hb *broadcastHub) subscribe(c *WSClient, name string) (*SubscribeResult, *broadcastError) {
+	if err := checkChannelName(name); err != nil {
+		return nil, err
+	}
+	hb.mu.Lock()
+	defer hb.mu.Unlock()
+
+	if _, ok := c.subscriptions[name]; !ok && len(c.subscriptions) >= maxClientSubscriptions {
+		return nil, &broadcastError{http.StatusBadRequest,
+			fmt.Sprintf("at most %d channels per connection", maxClientSubscriptions), "TOO_MANY_SUBSCRIPTIONS"}
+	}
+	ch, err := hb.channel(name)
+	if err != nil {
+		return nil, err
+	}
+	if c.subscriptions == nil {
+		c.subscriptions = make(map[string]struct{})
+	}
+	c.subscriptions[name] = struct{}{}
+	ch.subscribers[c] = struct{}{}
+	if ch.last != nil {
+		c.deliver(ch.last)
+	}
+	return &SubscribeResult{Channel: name, Subscribers: len(ch.subscribers)}, nil
+}
+
+// unsubscribe removes c from the channel.
+func (hb *broadcastHub) unsubscribe(c *WSClient, name string) *SubscribeResult {
+	hb.mu.Lock()
+	defer hb.mu.Unlock()
+	n := hb.remove(c, name)
+	return &SubscribeResult{Channel: name, Subscribers: n}
+}
+
+// unsubscribeAll removes c from all its channels, when it disconnects.
+func (hb *broadcastHub) unsubscribeAll(c *WSClient) {
+	hb.mu.Lock()
+	defer hb.mu.Unlock()
+	for name := range c.subscriptions {
+		hb.remove(c, name)
+	}
+}
+
+// remove takes c off the channel, closing the channel if nobody is left
+// watching it and it has no frame to show. It returns the number of remaining
+// subscribers. The caller must hold hb.mu.
+func (hb *broadcastHub) remove(c *WSClient, name string) int {
+	delete(c.subscriptions, name)
+	ch := hb.channels[name]
+	if ch == nil {
+		return 0
+	}
+	delete(ch.subscribers, c)
+	ch.updated = time.Now()
+	if len(ch.subscribers) == 0 && ch.last == nil {
+		delete(hb.channels, name)
+	}
+	return len(ch.subscribers)
+}
+
+// publish analyzes an update and sends the frame to the channel's subscribers.
+func (hb *broadcastHub) publish(e *engine.Engine, req *BroadcastUpdateRequest) (*BroadcastResult, *broadcastError) {
+	if err := checkChannelName(req.Channel); err != nil {
+		return nil, err
+	}
+	if req.Position == "" {
+		return nil, &broadcastError{http.StatusBadRequest, "position is required", "MISSING_POSITION"}
+	}
+	if req.Dice != [2]int{} && (req.Dice[0] < 1 || req.Dice[0] > 6 || req.Dice[1] < 1 || req.Dice[1] > 6) {
+		return nil, &broadcastError{http.StatusBadRequest, "dice must be 1-6", "INVALID_DICE"}
+	}
+	gs, err := parseGameState(req.Position, req)
+	if err != nil {
+		return nil, &broadcastError{http.StatusBadRequest, err.Error(), "INVALID_POSITION"}
+	}
+
+	hb.mu.Lock()
+	ch, berr := hb.channel(req.Channel)
+	hb.mu.Unlock()
+	if berr != nil {
+		return nil, berr
+	}
+
+	analysis, cached, err := ch.analyze(e, req, gs)
+	if err != nil {
+		return nil, &broadcastError{http.StatusInternalServerError, err.Error(), "ANALYSIS_ERROR"}
+	}
+
+	hb.mu.Lock()
+	defer hb.mu.Unlock()
+	// The channel may have been dropped while idle; keep updating the one
+	// subscribers can find
+	if cur, err := hb.channel(req.Channel); err == nil {
+		ch = cur
+	}
+	ch.seq++
+	ch.updated = time.Now()
+	ch.last = &BroadcastFrame{Channel: ch.name, Seq: ch.seq, Update: *req, Analysis: analysis}
+	for c := range ch.subscribers {
+		c.deliver(ch.last)
+	}
+	return &BroadcastResult{Channel: ch.name, Seq: ch.seq, Subscribers: len(ch.subscribers), Cached: cached}, nil
+}
+
+// analyze returns the analysis of an update, reusing the analysis of an
+// earlier update of the same position, roll and cube.
+func (ch *broadcastChannel) analyze(e *engine.Engine, req *BroadcastUpdateRequest, gs *engine.GameState) (*AnalyzeResponse, bool, error) {
+	key := fmt.Sprintf("%s dice=%v cube=%d owner=%d match=%d score=%v crawford=%t",
+		req.Position, req.Dice, gs.CubeValue, gs.CubeOwner, gs.MatchLength, gs.Score, gs.Crawford)
+
+	ch.evalMu.Lock()
+	defer ch.evalMu.Unlock()
+	if resp := ch.cache[key]; resp != nil {
+		return resp, true, nil
+	}
+	resp, err := Analyze(e, req.Position, gs, AnalyzeOptions{Dice: req.Dice, NumMoves: broadcastNumMoves})
+	if err != nil {
+		return nil, false, err
+	}
+	if len(ch.cache) >= broadcastCacheSize {
+		clear(ch.cache)
+	}
+	ch.cache[key] = resp
+	return resp, false, nil
+}
+
+// deliver queues a frame for c without blocking, replacing any frame of the
+// same channel that has not been sent yet.
+func (c *WSClient) deliver(frame *BroadcastFrame) {
+	c.mu.Lock()
+	if c.pending == nil {
+		c.pending = make(map[string]*BroadcastFrame)
+	}
+	if _, ok := c.pending[frame.Channel]; ok {
+		c.dropped++
+	}
+	c.pending[frame.Channel] = frame
+	c.mu.Unlock()
+
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// takePending returns the queued frames in channel order and clears the queue.
+func (c *WSClient) takePending() []*BroadcastFrame {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	frames := make([]*BroadcastFrame, 0, len(c.pending))
+	for _, f := range c.pending {
+		frames = append(frames, f)
+	}
+	clear(c.pending)
+	sort.Slice(frames, func(i, j int) bool { return frames[i].Channel < frames[j].Channel })
+	return frames
+}
+
+// Broadcast handles POST /api/broadcast, publishing an update to a channel.
+func (h *Handlers) Broadcast(w http.ResponseWriter, r *http.Request) {
+	if h.pool != nil {
+		if err := h.pool.AcquireFast(r.Context()); err != nil {
+			writeError(w, http.StatusServiceUnavailable, "server busy", "SERVER_BUSY")
+			return
+		}
+		defer h.pool.ReleaseFast()
+	}
+
+	var req BroadcastUpdateRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	result, err := h.broadcasts.publish(h.engine, &req)
+	if err != nil {
+		writeError(w, err.status, err.msg, err.code)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
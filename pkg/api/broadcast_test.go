@@ -0,0 +1,264 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func dialWS(t *testing.T, srv *httptest.Server) *websocket.Conn {
+	t.Helper()
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/api/ws", nil)
+	if err != nil {
+		t.Fatalf("WebSocket dial failed: %v", err)
+	}
+	return ws
+}
+
+// wsCall sends a message and returns the reply with the same ID, collecting
+// any broadcast frames that arrive first.
+func wsCall(t *testing.T, ws *websocket.Conn, typ, id string, payload interface{}) (WSResponse, []BroadcastFrame) {
+	t.Helper()
+	data, _ := json.Marshal(payload)
+	if err := ws.WriteJSON(WSMessage{Type: typ, ID: id, Payload: data}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	var frames []BroadcastFrame
+	for {
+		resp, frame := readWS(t, ws, 2*time.Second)
+		if frame != nil {
+			frames = append(frames, *frame)
+			continue
+		}
+		if resp.ID != id {
+			t.Fatalf("reply to %s = %+v, want ID %s", typ, resp, id)
+		}
+		return resp, frames
+	}
+}
+
+// readWS reads one message, decoding broadcast frames.
+func readWS(t *testing.T, ws *websocket.Conn, timeout time.Duration) (WSResponse, *BroadcastFrame) {
+	t.Helper()
+	ws.SetReadDeadline(time.Now().Add(timeout))
+	var raw struct {
+		WSResponse
+		Payload json.RawMessage `json:"payload"`
+	}
+	if err := ws.ReadJSON(&raw); err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if raw.Type != "broadcast" {
+		resp := raw.WSResponse
+		resp.Payload = raw.Payload
+		return resp, nil
+	}
+	var frame BroadcastFrame
+	if err := json.Unmarshal(raw.Payload, &frame); err != nil {
+		t.Fatalf("bad broadcast frame: %v", err)
+	}
+	return raw.WSResponse, &frame
+}
+
+func TestBroadcastFanOut(t *testing.T) {
+	srv := httptest.NewServer(NewServer(getTestEngine(), DefaultConfig(), "test").Handler())
+	defer srv.Close()
+
+	subs := make([]*websocket.Conn, 3)
+	for i := range subs {
+		subs[i] = dialWS(t, srv)
+		defer subs[i].Close()
+		resp, _ := wsCall(t, subs[i], "subscribe", "sub", SubscribeRequest{Channel: "final"})
+		if resp.Type != "result" {
+			t.Fatalf("subscribe failed: %+v", resp)
+		}
+	}
+	other := dialWS(t, srv)
+	defer other.Close()
+	wsCall(t, other, "subscribe", "sub", SubscribeRequest{Channel: "other"})
+
+	producer := dialWS(t, srv)
+	defer producer.Close()
+	update := BroadcastUpdateRequest{Channel: "final", Position: "4HPwATDgc/ABMA", Dice: [2]int{3, 1}, Comment: "Opening roll"}
+	resp, _ := wsCall(t, producer, "broadcast_update", "up-1", update)
+	var result BroadcastResult
+	remarshal(t, resp.Payload, &result)
+	if resp.Type != "result" || result.Seq != 1 || result.Subscribers != 3 || result.Cached {
+		t.Fatalf("first update: %+v %+v, want seq 1 to 3 subscribers", resp, result)
+	}
+
+	for i, ws := range subs {
+		_, frame := readWS(t, ws, 2*time.Second)
+		if frame == nil || frame.Seq != 1 || frame.Update.Comment != "Opening roll" {
+			t.Fatalf("subscriber %d got %+v, want frame 1", i, frame)
+		}
+		a := frame.Analysis
+		if a == nil || a.Evaluation == nil || a.Cube == nil || a.Moves == nil || len(a.Moves.Moves) == 0 {
+			t.Errorf("subscriber %d: incomplete analysis %+v", i, a)
+		}
+	}
+
+	// The same position again comes from the channel cache
+	status, body := postBroadcast(t, srv, update)
+	if status != http.StatusOK {
+		t.Fatalf("POST /api/broadcast: status %d %s", status, body)
+	}
+	json.Unmarshal(body, &result)
+	if result.Seq != 2 || !result.Cached {
+		t.Errorf("repeated update: %+v, want seq 2 from the cache", result)
+	}
+
+	// A late joiner gets the current frame at once
+	late := dialWS(t, srv)
+	defer late.Close()
+	_, frames := wsCall(t, late, "subscribe", "sub", SubscribeRequest{Channel: "final"})
+	if len(frames) == 0 {
+		_, frame := readWS(t, late, 2*time.Second)
+		frames = append(frames, *frame)
+	}
+	if frames[0].Seq != 2 {
+		t.Errorf("late joiner got frame %d, want 2", frames[0].Seq)
+	}
+
+	// Other channels see nothing
+	other.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if _, _, err := other.ReadMessage(); err == nil {
+		t.Error("subscriber of another channel received a frame")
+	}
+
+	// Disconnected subscribers are dropped from the channel
+	subs[0].Close()
+	subs[1].Close()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		resp, _ := wsCall(t, producer, "broadcast_update", "up", update)
+		remarshal(t, resp.Payload, &result)
+		if result.Subscribers == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d subscribers after two disconnected, want 2", result.Subscribers)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Bad updates are refused
+	resp, _ = wsCall(t, producer, "broadcast_update", "bad", BroadcastUpdateRequest{Position: "4HPwATDgc/ABMA"})
+	if resp.Type != "error" || resp.Error != "channel is required" {
+		t.Errorf("update without channel: %+v", resp)
+	}
+	status, body = postBroadcast(t, srv, BroadcastUpdateRequest{Channel: "final", Position: "4HPwATDgc/ABMA", Dice: [2]int{7, 1}})
+	if status != http.StatusBadRequest || !strings.Contains(string(body), "INVALID_DICE") {
+		t.Errorf("bad dice: status %d %s", status, body)
+	}
+}
+
+func TestBroadcastSlowConsumer(t *testing.T) {
+	h := NewHandlers(getTestEngine(), "test")
+
+	// A subscriber that never reads: only its latest frame is kept
+	slow := &WSClient{handlers: h, sendChan: make(chan WSResponse, 1), wake: make(chan struct{}, 1)}
+	if _, err := h.broadcasts.subscribe(slow, "final"); err != nil {
+		t.Fatalf("subscribe failed: %s", err.msg)
+	}
+
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		update := BroadcastUpdateRequest{Channel: "final", Position: "4HPwATDgc/ABMA", Comment: "move"}
+		if _, err := h.broadcasts.publish(h.engine, &update); err != nil {
+			t.Fatalf("publish failed: %s", err.msg)
+		}
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("100 updates took %v with a stalled subscriber", d)
+	}
+
+	frames := slow.takePending()
+	if len(frames) != 1 || frames[0].Seq != 100 {
+		t.Fatalf("pending frames = %+v, want only frame 100", frames)
+	}
+	if slow.dropped != 99 {
+		t.Errorf("dropped = %d, want 99", slow.dropped)
+	}
+
+	// Over a real connection, a subscriber that catches up late sees the
+	// frames in order and ends with the latest
+	srv := httptest.NewServer(NewServer(getTestEngine(), DefaultConfig(), "test").Handler())
+	defer srv.Close()
+	sub := dialWS(t, srv)
+	defer sub.Close()
+	wsCall(t, sub, "subscribe", "sub", SubscribeRequest{Channel: "live"})
+
+	producer := dialWS(t, srv)
+	defer producer.Close()
+	const updates = 200
+	for i := 0; i < updates; i++ {
+		update := BroadcastUpdateRequest{Channel: "live", Position: "4HPwATDgc/ABMA", Comment: strings.Repeat("x", 1000)}
+		data, _ := json.Marshal(update)
+		producer.WriteJSON(WSMessage{Type: "broadcast_update", ID: "up", Payload: data})
+	}
+	for i := 0; i < updates; i++ {
+		if resp, _ := readWS(t, producer, 5*time.Second); resp.Type != "result" {
+			t.Fatalf("update %d: %+v", i, resp)
+		}
+	}
+
+	var last int64
+	for last < updates {
+		_, frame := readWS(t, sub, 5*time.Second)
+		if frame == nil || frame.Seq <= last {
+			t.Fatalf("frame %+v after frame %d", frame, last)
+		}
+		last = frame.Seq
+	}
+}
+
+func TestBroadcastSubscriptionLimits(t *testing.T) {
+	h := NewHandlers(getTestEngine(), "test")
+	c := &WSClient{handlers: h, sendChan: make(chan WSResponse, 1)}
+
+	for i := 0; i < maxClientSubscriptions; i++ {
+		if _, err := h.broadcasts.subscribe(c, strings.Repeat("c", i+1)); err != nil {
+			t.Fatalf("subscribe %d failed: %s", i, err.msg)
+		}
+	}
+	if _, err := h.broadcasts.subscribe(c, "one-too-many"); err == nil || err.code != "TOO_MANY_SUBSCRIPTIONS" {
+		t.Errorf("subscribe over the limit: %+v", err)
+	}
+	if _, err := h.broadcasts.subscribe(c, strings.Repeat("x", maxChannelNameLen+1)); err == nil || err.code != "INVALID_CHANNEL" {
+		t.Errorf("long channel name: %+v", err)
+	}
+
+	// Channels without frames close when their last subscriber leaves
+	h.broadcasts.unsubscribeAll(c)
+	if n := len(h.broadcasts.channels); n != 0 {
+		t.Errorf("%d channels left open", n)
+	}
+}
+
+func postBroadcast(t *testing.T, srv *httptest.Server, req BroadcastUpdateRequest) (int, []byte) {
+	t.Helper()
+	data, _ := json.Marshal(req)
+	resp, err := http.Post(srv.URL+"/api/broadcast", "application/json", strings.NewReader(string(data)))
+	if err != nil {
+		t.Fatalf("POST failed: %v", err)
+	}
+	defer resp.Body.Close()
+	var body json.RawMessage
+	json.NewDecoder(resp.Body).Decode(&body)
+	return resp.StatusCode, body
+}
+
+// remarshal converts a decoded JSON payload into v.
+func remarshal(t *testing.T, payload interface{}, v interface{}) {
+	t.Helper()
+	data, _ := json.Marshal(payload)
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatalf("bad payload %s: %v", data, err)
+	}
+}
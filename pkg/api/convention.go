@@ -0,0 +1,167 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Convention is how evaluate, move and cube responses state probabilities
+// and equities, chosen by a request's "convention" field.
+//
+// The gobg convention, the default, gives the five probabilities of the
+// engine's evaluations (win, win_g, win_bg, lose_g, lose_bg) and states
+// every equity per unit of the cube, as if the cube were at 1.
+//
+// The xg convention, for tools expecting the numbers of eXtreme Gammon and
+// Snowie, adds the six-element probability vector of XGProbabilities and
+// states equities at the current cube value: an equity of +0.256 per unit
+// is +0.512 on a 2-cube. Match winning chances are percentages in both.
+type Convention int
+
+const (
+	ConventionGoBG Convention = iota // Five probabilities, equities per unit of the cube
+	ConventionXG                     // Six probabilities, equities at the cube value
+)
+
+// ParseConvention returns the convention named s, "gobg" or "xg"; empty
+// is gobg.
+func ParseConvention(s string) (Convention, error) {
+	switch strings.ToLower(s) {
+	case "", "gobg":
+		return ConventionGoBG, nil
+	case "xg":
+		return ConventionXG, nil
+	}
+	return 0, fmt.Errorf("unknown convention %q (want gobg or xg)", s)
+}
+
+// String returns the convention's name.
+func (c Convention) String() string {
+	if c == ConventionXG {
+		return "xg"
+	}
+	return "gobg"
+}
+
+// Equity returns an equity per unit of the cube in convention c, with the
+// cube at cube.
+func (c Convention) Equity(perUnit float64, cube int) float64 {
+	if c == ConventionXG {
+		return perUnit * float64(max(cube, 1))
+	}
+	return perUnit
+}
+
+// PerUnit returns an equity of convention c, with the cube at cube, per
+// unit of the cube. It undoes Equity.
+func (c Convention) PerUnit(equity float64, cube int) float64 {
+	if c == ConventionXG {
+		return equity / float64(max(cube, 1))
+	}
+	return equity
+}
+
+// XGProbabilities is the probability vector of the xg convention, as
+// percentages: the player's win, gammon and backgammon chances, then the
+// opponent's. As in the five-probability vector each counts the ones after
+// it (a win counts gammons, a gammon backgammons), so the two wins sum to
+// 100: the opponent's win is 100 - win, and the opponent's gammons and
+// backgammons are lose_g and lose_bg.
+type XGProbabilities [6]float64
+
+// NewXGProbabilities returns the xg vector of the five probabilities of
+// the gobg convention, as percentages.
+func NewXGProbabilities(win, winG, winBG, loseG, loseBG float64) XGProbabilities {
+	return XGProbabilities{win, winG, winBG, 100 - win, loseG, loseBG}
+}
+
+// xgTolerance is how far apart, in percent, the two wins of an xg vector
+// may sum from 100, for rounding in the tools that write them.
+const xgTolerance = 0.01
+
+// Five returns the five probabilities of the gobg convention, as
+// percentages, or an error if p is not a probability vector: the wins do
+// not sum to 100, a probability is outside 0-100, or a side's gammons
+// exceed its wins or its backgammons its gammons.
+func (p XGProbabilities) Five() (win, winG, winBG, loseG, loseBG float64, err error) {
+	for i, v := range p {
+		if v < 0 || v > 100 || math.IsNaN(v) {
+			return 0, 0, 0, 0, 0, fmt.Errorf("probability %d is %g, not a percentage", i, v)
+		}
+	}
+	if sum := p[0] + p[3]; math.Abs(sum-100) > xgTolerance {
+		return 0, 0, 0, 0, 0, fmt.Errorf("wins sum to %g, not 100", sum)
+	}
+	for side, name := range []string{"player", "opponent"} {
+		w, g, bg := p[3*side], p[3*side+1], p[3*side+2]
+		if g > w || bg > g {
+			return 0, 0, 0, 0, 0, fmt.Errorf("the %s's win %g, gammon %g and backgammon %g do not nest", name, w, g, bg)
+		}
+	}
+	return p[0], p[1], p[2], p[4], p[5], nil
+}
+
+// applyConvention restates resp, an *EvaluateResponse, *MovesResponse or
+// *CubeResponse in the gobg convention with the cube at cube, in
+// convention c. It is the one place responses change with the convention.
+func applyConvention(c Convention, resp interface{}, cube int) {
+	if c == ConventionGoBG {
+		return
+	}
+	eq := func(v *float64) { *v = c.Equity(*v, cube) }
+	var pv func(v *PVResponse)
+	pv = func(v *PVResponse) {
+		if v == nil {
+			return
+		}
+		eq(&v.Equity)
+		for i := range v.Rolls {
+			eq(&v.Rolls[i].Equity)
+			eq(&v.Rolls[i].Impact)
+			pv(v.Rolls[i].Next)
+		}
+	}
+	move := func(m *MoveResponse) {
+		eq(&m.Equity)
+		eq(&m.CubelessEquity)
+		eq(&m.CubefulEquity)
+		p := NewXGProbabilities(m.Win, m.WinG, m.WinBG, m.LoseG, m.LoseBG)
+		m.Probabilities = &p
+		pv(m.PV)
+	}
+
+	switch r := resp.(type) {
+	case *EvaluateResponse:
+		eq(&r.Equity)
+		eq(&r.CubefulEquity)
+		p := NewXGProbabilities(r.Win, r.WinG, r.WinBG, r.LoseG, r.LoseBG)
+		r.Probabilities = &p
+		r.Convention = c.String()
+	case *MovesResponse:
+		for i := range r.Moves {
+			move(&r.Moves[i])
+		}
+		if r.Constraint != nil {
+			move(&r.Constraint.Best)
+			eq(&r.Constraint.Cost)
+		}
+		r.Convention = c.String()
+	case *CubeResponse:
+		eq(&r.DoubleEquity)
+		eq(&r.PassEquity)
+		eq(&r.NoDoubleEquity)
+		eq(&r.TakeEquity)
+		eq(&r.DoubleDiff)
+		if v := r.OpponentView; v != nil {
+			eq(&v.TakeEquity)
+			eq(&v.PassEquity)
+			eq(&v.PassCost)
+		}
+		if e := r.eval; e != nil {
+			p := NewXGProbabilities(e.WinProb*100, e.WinG*100, e.WinBG*100, e.LoseG*100, e.LoseBG*100)
+			r.Probabilities = &p
+		}
+		r.Convention = c.String()
+	}
+}
@@ -0,0 +1,151 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestXGProbabilities(t *testing.T) {
+	// Evaluations of the starting position, a gammonish blitz and a race,
+	// as the gobg convention gives them
+	for _, five := range [][5]float64{
+		{53.2, 15.1, 0.7, 12.5, 0.5},
+		{71.4, 38.6, 4.2, 6.1, 0.2},
+		{82.5, 0, 0, 0, 0},
+		{100, 100, 100, 0, 0},
+		{0, 0, 0, 100, 12.5},
+	} {
+		p := NewXGProbabilities(five[0], five[1], five[2], five[3], five[4])
+		if p[0]+p[3] != 100 || p[1] != five[1] || p[2] != five[2] || p[4] != five[3] || p[5] != five[4] {
+			t.Errorf("%v in the xg convention is %v", five, p)
+		}
+		win, winG, winBG, loseG, loseBG, err := p.Five()
+		if err != nil {
+			t.Fatalf("%v: %v", p, err)
+		}
+		if back := [5]float64{win, winG, winBG, loseG, loseBG}; back != five {
+			t.Errorf("%v round trips to %v", five, back)
+		}
+	}
+
+	// A vector written by another tool, rounded to a tenth
+	if _, _, _, _, _, err := (XGProbabilities{66.7, 20.1, 1.2, 33.3, 8.4, 0.3}).Five(); err != nil {
+		t.Errorf("rounded vector: %v", err)
+	}
+	for _, bad := range []XGProbabilities{
+		{60, 20, 1, 45, 10, 0},  // wins sum to 105
+		{60, 61, 1, 40, 10, 0},  // more gammons than wins
+		{60, 20, 1, 40, 10, 11}, // more backgammons than gammons
+		{110, 20, 1, -10, 0, 0}, // not percentages
+		{math.NaN(), 0, 0, 0, 0, 0},
+	} {
+		if _, _, _, _, _, err := bad.Five(); err == nil {
+			t.Errorf("%v accepted", bad)
+		}
+	}
+}
+
+func TestConventionEquity(t *testing.T) {
+	for _, cube := range []int{0, 1, 2, 64} {
+		for _, perUnit := range []float64{-0.512, 0, 0.256, 1.25} {
+			for _, c := range []Convention{ConventionGoBG, ConventionXG} {
+				if got := c.PerUnit(c.Equity(perUnit, cube), cube); math.Abs(got-perUnit) > 1e-12 {
+					t.Errorf("%s at cube %d: %g round trips to %g", c, cube, perUnit, got)
+				}
+			}
+		}
+	}
+	if got := ConventionXG.Equity(0.256, 2); got != 0.512 {
+		t.Errorf("+0.256 per unit on a 2-cube is %g in the xg convention, want 0.512", got)
+	}
+	if got := ConventionGoBG.Equity(0.256, 2); got != 0.256 {
+		t.Errorf("+0.256 per unit on a 2-cube is %g in the gobg convention", got)
+	}
+
+	for _, name := range []string{"", "gobg", "xg", "XG"} {
+		c, err := ParseConvention(name)
+		if err != nil {
+			t.Errorf("ParseConvention(%q): %v", name, err)
+		}
+		if again, _ := ParseConvention(c.String()); again != c {
+			t.Errorf("%q parses as %s, which parses as %s", name, c, again)
+		}
+	}
+	if _, err := ParseConvention("snowie"); err == nil {
+		t.Error("unknown convention accepted")
+	}
+}
+
+func TestConventionResponses(t *testing.T) {
+	handler := NewServer(getTestEngine(), DefaultConfig(), "test").Handler()
+	post := func(path string, req, resp interface{}) int {
+		t.Helper()
+		body, _ := json.Marshal(req)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("POST", path, bytes.NewReader(body)))
+		if w.Code == http.StatusOK {
+			if err := json.NewDecoder(w.Body).Decode(resp); err != nil {
+				t.Fatalf("%s: decode: %v", path, err)
+			}
+		}
+		return w.Code
+	}
+	near := func(a, b float64) bool { return math.Abs(a-b) < 1e-4 }
+	checkProbs := func(what string, p *XGProbabilities, win, winG, winBG, loseG, loseBG float64) {
+		t.Helper()
+		if p == nil {
+			t.Errorf("%s: no probabilities in the xg convention", what)
+			return
+		}
+		if !near(p[0], win) || !near(p[3], 100-win) || !near(p[1], winG) || !near(p[2], winBG) || !near(p[4], loseG) || !near(p[5], loseBG) {
+			t.Errorf("%s: probabilities %v, want those of win %.4f, win_g %.4f, win_bg %.4f, lose_g %.4f, lose_bg %.4f", what, *p, win, winG, winBG, loseG, loseBG)
+		}
+	}
+
+	const pos = "4HPwATDgc/ABMA"
+	const cube = 2
+	var gobg, xg EvaluateResponse
+	post("/api/evaluate", EvaluateRequest{Position: pos, CubeValue: cube, CubeOwner: 1, Ply: intPtr(0)}, &gobg)
+	post("/api/evaluate", EvaluateRequest{Position: pos, CubeValue: cube, CubeOwner: 1, Ply: intPtr(0), Convention: "xg"}, &xg)
+	if gobg.Convention != "" || gobg.Probabilities != nil {
+		t.Errorf("gobg evaluation has convention %q, probabilities %v", gobg.Convention, gobg.Probabilities)
+	}
+	if xg.Convention != "xg" || !near(xg.Equity, cube*gobg.Equity) || !near(xg.CubefulEquity, cube*gobg.CubefulEquity) {
+		t.Errorf("xg evaluation %+v, want the equities of %+v at a 2-cube", xg, gobg)
+	}
+	checkProbs("evaluate", xg.Probabilities, gobg.Win, gobg.WinG, gobg.WinBG, gobg.LoseG, gobg.LoseBG)
+
+	var gobgMoves, xgMoves MovesResponse
+	post("/api/move", MoveRequest{Position: pos, Dice: [2]int{6, 4}, CubeValue: cube, CubeOwner: 1, NumMoves: 3, Ply: intPtr(0)}, &gobgMoves)
+	post("/api/move", MoveRequest{Position: pos, Dice: [2]int{6, 4}, CubeValue: cube, CubeOwner: 1, NumMoves: 3, Ply: intPtr(0), Convention: "xg"}, &xgMoves)
+	if xgMoves.Convention != "xg" || len(xgMoves.Moves) != len(gobgMoves.Moves) {
+		t.Fatalf("xg moves %+v, gobg moves %+v", xgMoves, gobgMoves)
+	}
+	for i, m := range xgMoves.Moves {
+		g := gobgMoves.Moves[i]
+		if m.Move != g.Move || !near(ConventionXG.PerUnit(m.Equity, cube), g.Equity) || !near(m.CubefulEquity, cube*g.CubefulEquity) || !near(m.CubelessEquity, cube*g.CubelessEquity) {
+			t.Errorf("xg move %+v, want the equities of %+v at a 2-cube", m, g)
+		}
+		checkProbs(m.Move, m.Probabilities, g.Win, g.WinG, g.WinBG, g.LoseG, g.LoseBG)
+	}
+
+	var gobgCube, xgCube CubeResponse
+	post("/api/cube", CubeRequest{Position: pos, CubeValue: cube, CubeOwner: 0}, &gobgCube)
+	post("/api/cube", CubeRequest{Position: pos, CubeValue: cube, CubeOwner: 0, Convention: "xg"}, &xgCube)
+	if xgCube.Convention != "xg" || xgCube.Action != gobgCube.Action ||
+		!near(xgCube.NoDoubleEquity, cube*gobgCube.NoDoubleEquity) || !near(xgCube.DoubleEquity, cube*gobgCube.DoubleEquity) ||
+		!near(xgCube.PassEquity, cube*gobgCube.PassEquity) || !near(xgCube.DoubleDiff, cube*gobgCube.DoubleDiff) ||
+		xgCube.TakePoint != gobgCube.TakePoint || !near(xgCube.OpponentView.PassCost, cube*gobgCube.OpponentView.PassCost) ||
+		!near(xgCube.TakeEquity, xgCube.OpponentView.TakeEquity) {
+		t.Errorf("xg cube %+v, want the equities of %+v at a 2-cube", xgCube, gobgCube)
+	}
+	checkProbs("cube", xgCube.Probabilities, gobg.Win, gobg.WinG, gobg.WinBG, gobg.LoseG, gobg.LoseBG)
+
+	if code := post("/api/evaluate", EvaluateRequest{Position: pos, Convention: "snowie"}, &xg); code != http.StatusBadRequest {
+		t.Errorf("unknown convention: status %d, want 400", code)
+	}
+}
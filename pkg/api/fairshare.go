@@ -0,0 +1,222 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+	"sync"
+)
+
+// IdentityStats is the slow pool usage of one client identity.
+type IdentityStats struct {
+	ActiveSlow int64 `json:"active_slow"`
+	QueuedSlow int64 `json:"queued_slow"`
+	TotalSlow  int64 `json:"total_slow"`
+}
+
+// HashIdentity returns the identity the pool schedules a client under, given
+// the value of its identity header: the first 12 hex digits of the value's
+// SHA-256, so that API keys used as identities do not show in the pool
+// statistics. An empty value is no identity.
+func HashIdentity(value string) string {
+	if value == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:6])
+}
+
+// fairScheduler hands out the slots of the slow pool fairly between client
+// identities. Operations waiting for a slot queue per identity, first come
+// first served within an identity, and freed slots go round-robin to the
+// identities with operations waiting, passing over those holding their cap.
+// With one identity, as when requests carry none, it is a FIFO semaphore.
+type fairScheduler struct {
+	mu          sync.Mutex
+	slots       int
+	free        int
+	perIdentity int // Most slots one identity may hold (0 = no cap)
+
+	queues  map[string]*identityQueue // Every identity seen, for its usage counters
+	waiting []string                  // Identities with operations waiting, in round-robin order
+	next    int                       // Index in waiting of the identity served next
+}
+
+// identityQueue is the operations of one identity waiting for a slot, and
+// its usage counters.
+type identityQueue struct {
+	waiters []*slotWaiter
+	active  int64
+	total   int64
+}
+
+// slotWaiter is an operation waiting for a slot. ready is closed once
+// granted is set.
+type slotWaiter struct {
+	ready   chan struct{}
+	granted bool
+}
+
+func newFairScheduler(slots, perIdentity int) *fairScheduler {
+	return &fairScheduler{
+		slots:       slots,
+		free:        slots,
+		perIdentity: max(perIdentity, 0),
+		queues:      make(map[string]*identityQueue),
+	}
+}
+
+// acquire waits for a slot for identity, returning an error if ctx is done
+// first.
+func (s *fairScheduler) acquire(ctx context.Context, identity string) error {
+	s.mu.Lock()
+	q := s.queue(identity)
+	if len(q.waiters) == 0 && s.free > 0 && s.underCap(q) {
+		s.grant(q)
+		s.mu.Unlock()
+		return nil
+	}
+	w := &slotWaiter{ready: make(chan struct{})}
+	if len(q.waiters) == 0 {
+		s.waiting = append(s.waiting, identity)
+	}
+	q.waiters = append(q.waiters, w)
+	s.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if w.granted {
+		// Granted as the context was done: pass the slot on
+		q.active--
+		s.free++
+		s.dispatch()
+	} else {
+		q.waiters = slices.DeleteFunc(q.waiters, func(o *slotWaiter) bool { return o == w })
+		if len(q.waiters) == 0 {
+			s.dropWaiting(identity)
+		}
+	}
+	return ctx.Err()
+}
+
+// tryAcquire takes a slot for identity if one is free to it now.
+func (s *fairScheduler) tryAcquire(identity string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q := s.queue(identity)
+	if len(q.waiters) > 0 || s.free == 0 || !s.underCap(q) {
+		return false
+	}
+	s.grant(q)
+	return true
+}
+
+// release gives back a slot identity holds and passes it on.
+func (s *fairScheduler) release(identity string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q := s.queue(identity)
+	q.active--
+	q.total++
+	s.free++
+	s.dispatch()
+}
+
+// stats returns the usage of every identity but the empty one, or nil if
+// there are none.
+func (s *fairScheduler) stats() map[string]IdentityStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var stats map[string]IdentityStats
+	for identity, q := range s.queues {
+		if identity == "" {
+			continue
+		}
+		if stats == nil {
+			stats = make(map[string]IdentityStats)
+		}
+		stats[identity] = IdentityStats{
+			ActiveSlow: q.active,
+			QueuedSlow: int64(len(q.waiters)),
+			TotalSlow:  q.total,
+		}
+	}
+	return stats
+}
+
+func (s *fairScheduler) queue(identity string) *identityQueue {
+	q := s.queues[identity]
+	if q == nil {
+		q = &identityQueue{}
+		s.queues[identity] = q
+	}
+	return q
+}
+
+func (s *fairScheduler) underCap(q *identityQueue) bool {
+	return s.perIdentity == 0 || q.active < int64(s.perIdentity)
+}
+
+func (s *fairScheduler) grant(q *identityQueue) {
+	s.free--
+	q.active++
+}
+
+// dispatch hands the free slots to waiting operations, one identity at a
+// time in round-robin order, until no slot is free or every identity left
+// waiting holds its cap.
+func (s *fairScheduler) dispatch() {
+	for s.free > 0 {
+		served := false
+		for i := range s.waiting {
+			idx := (s.next + i) % len(s.waiting)
+			q := s.queues[s.waiting[idx]]
+			if !s.underCap(q) {
+				continue
+			}
+			w := q.waiters[0]
+			q.waiters = q.waiters[1:]
+			w.granted = true
+			close(w.ready)
+			s.grant(q)
+
+			// The identity after this one is served next
+			s.next = idx + 1
+			if len(q.waiters) == 0 {
+				s.waiting = slices.Delete(s.waiting, idx, idx+1)
+				s.next = idx
+			}
+			if s.next >= len(s.waiting) {
+				s.next = 0
+			}
+			served = true
+			break
+		}
+		if !served {
+			return
+		}
+	}
+}
+
+// dropWaiting takes identity out of the round-robin once it has no
+// operations waiting, keeping the turn with the identity that had it.
+func (s *fairScheduler) dropWaiting(identity string) {
+	idx := slices.Index(s.waiting, identity)
+	if idx < 0 {
+		return
+	}
+	s.waiting = slices.Delete(s.waiting, idx, idx+1)
+	if idx < s.next {
+		s.next--
+	}
+	if s.next >= len(s.waiting) {
+		s.next = 0
+	}
+}
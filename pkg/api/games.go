@@ -0,0 +1,390 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/yourusername/bgengine/internal/positionid"
+	"github.com/yourusername/bgengine/pkg/engine"
+	"github.com/yourusername/bgengine/pkg/match"
+)
+
+// Timed games are matches the server referees on a match clock (see
+// match.Controller). POST /api/games starts one; the players send their
+// decisions to POST /api/games/{id}/action, or as "game_action" messages
+// over the WebSocket, and each decision is stamped on the clock. A game
+// belongs to no connection: a player who reconnects picks it up by its ID
+// with GET /api/games/{id} or "game_state", until it has been idle for
+// gameIdleTimeout. The clock keeps running in between.
+
+const (
+	maxGameSessions = 1000             // Games open at once
+	gameIdleTimeout = 30 * time.Minute // Idle games are dropped after this
+)
+
+// GameClockRequest configures the clock of a timed game.
+type GameClockRequest struct {
+	ReserveMs   int    `json:"reserve_ms"`             // Reserve of each player at the start of the match
+	DelayMs     int    `json:"delay_ms,omitempty"`     // Time each decision may take without costing reserve
+	DelayMode   string `json:"delay_mode,omitempty"`   // "simple" (default) or "bronstein"
+	IncrementMs int    `json:"increment_ms,omitempty"` // Time added to the reserve after each decision
+	Flag        string `json:"flag,omitempty"`         // What a flag fall loses: "match" (default) or "game"
+}
+
+// GameStartRequest is the request body for POST /api/games and the payload
+// of a "game_start" message.
+type GameStartRequest struct {
+	Player1     string           `json:"player1,omitempty"`      // Name of player 0
+	Player2     string           `json:"player2,omitempty"`      // Name of player 1
+	MatchLength int              `json:"match_length,omitempty"` // 0 = money session, which only a flag fall ends
+	NoCrawford  bool             `json:"no_crawford,omitempty"`  // Play without the Crawford rule
+	Clock       GameClockRequest `json:"clock"`                  // Match clock
+}
+
+// GameActionRequest is the request body for POST /api/games/{id}/action and
+// the payload of a "game_action" message, which also gives the session.
+type GameActionRequest struct {
+	Session string `json:"session,omitempty"` // Game ID ("game_action" only)
+	Player  int    `json:"player"`            // Player deciding, 0 or 1
+	Action  string `json:"action"`            // "roll", "move", "double", "take" or "pass"
+	Move    string `json:"move,omitempty"`    // Play for "move", in the player's own point numbers (e.g. "8/5 6/5")
+}
+
+// GameStateRequest is the payload of a "game_state" message.
+type GameStateRequest struct {
+	Session string `json:"session"` // Game ID
+}
+
+// GameClockResponse is the state of a timed game's clock.
+type GameClockResponse struct {
+	RemainingMs [2]int64 `json:"remaining_ms"` // Reserve left to each player, counting the decision being made
+	Running     int      `json:"running"`      // Player on the clock (-1 = stopped)
+	Flagged     int      `json:"flagged"`      // Player whose flag fell and cost the match (-1 = none)
+}
+
+// GameStateResponse is the state of a timed game, returned by every game
+// request.
+type GameStateResponse struct {
+	Session     string            `json:"session"`      // Game ID
+	Phase       string            `json:"phase"`        // Decision awaited: "roll" (double or roll), "take", "move", or "over"
+	Turn        int               `json:"turn"`         // Player on roll
+	Decider     int               `json:"decider"`      // Player to decide (-1 once the match is over)
+	Position    string            `json:"position"`     // Position ID, player on roll
+	Dice        [2]int            `json:"dice"`         // Roll to play in the "move" phase, or the last roll
+	CubeValue   int               `json:"cube_value"`   // Cube value
+	CubeOwner   int               `json:"cube_owner"`   // Player who owns the cube (-1 = centered)
+	MatchLength int               `json:"match_length"` // 0 = money session
+	Score       [2]int            `json:"score"`        // Points of players 0 and 1
+	Game        int               `json:"game"`         // Game number, from 1
+	Crawford    bool              `json:"crawford"`     // The game is the Crawford game
+	Winner      int               `json:"winner"`       // Winner of the match (-1 = not over)
+	Clock       GameClockResponse `json:"clock"`        // Match clock
+}
+
+// gameError is a request error from a timed game operation.
+type gameError struct {
+	status int
+	msg    string
+	code   string
+}
+
+// gameHub is the registry of timed games.
+type gameHub struct {
+	mu       sync.Mutex
+	sessions map[string]*gameSession
+	now      func() time.Time // Time source of the clocks and idle timeout
+}
+
+// gameSession is one timed game. Its fields are guarded by mu.
+type gameSession struct {
+	mu   sync.Mutex
+	ctrl *match.Controller
+	used time.Time
+}
+
+func newGameHub() *gameHub {
+	return &gameHub{sessions: make(map[string]*gameSession), now: time.Now}
+}
+
+// start starts a timed game and returns its state.
+func (gh *gameHub) start(req *GameStartRequest) (*GameStateResponse, *gameError) {
+	config, gerr := clockConfig(req.Clock)
+	if gerr != nil {
+		return nil, gerr
+	}
+	ctrl, err := match.NewController(match.ControllerConfig{
+		Player1:     req.Player1,
+		Player2:     req.Player2,
+		MatchLength: req.MatchLength,
+		NoCrawford:  req.NoCrawford,
+		Clock:       config,
+	}, gh.now)
+	if err != nil {
+		return nil, &gameError{http.StatusBadRequest, err.Error(), "INVALID_GAME"}
+	}
+	gs := &gameSession{ctrl: ctrl}
+	id := gh.add(gs)
+	if id == "" {
+		return nil, &gameError{http.StatusServiceUnavailable, "too many timed games", "TOO_MANY_SESSIONS"}
+	}
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	return gs.state(id), nil
+}
+
+// clockConfig converts the clock of a request.
+func clockConfig(req GameClockRequest) (match.ClockConfig, *gameError) {
+	config := match.ClockConfig{
+		Reserve:   time.Duration(req.ReserveMs) * time.Millisecond,
+		Delay:     time.Duration(req.DelayMs) * time.Millisecond,
+		Increment: time.Duration(req.IncrementMs) * time.Millisecond,
+	}
+	switch req.DelayMode {
+	case "", "simple":
+		config.DelayMode = match.DelaySimple
+	case "bronstein":
+		config.DelayMode = match.DelayBronstein
+	default:
+		return config, &gameError{http.StatusBadRequest, fmt.Sprintf("unknown delay_mode %q", req.DelayMode), "INVALID_CLOCK"}
+	}
+	switch req.Flag {
+	case "", "match":
+		config.Penalty = match.FlagLosesMatch
+	case "game":
+		config.Penalty = match.FlagLosesGame
+	default:
+		return config, &gameError{http.StatusBadRequest, fmt.Sprintf("unknown flag %q", req.Flag), "INVALID_CLOCK"}
+	}
+	if err := config.Validate(); err != nil {
+		return config, &gameError{http.StatusBadRequest, err.Error(), "INVALID_CLOCK"}
+	}
+	return config, nil
+}
+
+// state returns the state of the game with id.
+func (gh *gameHub) state(id string) (*GameStateResponse, *gameError) {
+	gs := gh.get(id)
+	if gs == nil {
+		return nil, gameNotFound(id)
+	}
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	return gs.state(id), nil
+}
+
+// act takes a player's decision in the game with id and returns the state
+// after it. A decision made after the player's flag fell is refused with
+// FLAG_FELL.
+func (gh *gameHub) act(id string, req *GameActionRequest) (*GameStateResponse, *gameError) {
+	gs := gh.get(id)
+	if gs == nil {
+		return nil, gameNotFound(id)
+	}
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+
+	var err error
+	switch req.Action {
+	case "roll":
+		err = gs.ctrl.Roll(req.Player)
+	case "double":
+		err = gs.ctrl.Double(req.Player)
+	case "take":
+		err = gs.ctrl.Take(req.Player)
+	case "pass":
+		err = gs.ctrl.Pass(req.Player)
+	case "move":
+		m, perr := engine.ParseMove(req.Move)
+		if perr != nil {
+			return nil, &gameError{http.StatusBadRequest, fmt.Sprintf("invalid move notation: %v", perr), "INVALID_MOVE"}
+		}
+		err = gs.ctrl.Play(req.Player, m)
+	default:
+		return nil, &gameError{http.StatusBadRequest, fmt.Sprintf("unknown action %q", req.Action), "INVALID_ACTION"}
+	}
+
+	var moveErr *engine.MoveError
+	switch {
+	case err == nil:
+		return gs.state(id), nil
+	case errors.Is(err, match.ErrFlagFell):
+		return nil, &gameError{http.StatusConflict, "flag fell before the decision", "FLAG_FELL"}
+	case errors.Is(err, match.ErrInvalidAction):
+		return nil, &gameError{http.StatusConflict, err.Error(), "INVALID_ACTION"}
+	case errors.As(err, &moveErr):
+		return nil, &gameError{http.StatusBadRequest, err.Error(), "ILLEGAL_MOVE"}
+	default:
+		return nil, &gameError{http.StatusInternalServerError, err.Error(), "GAME_ERROR"}
+	}
+}
+
+func gameNotFound(id string) *gameError {
+	return &gameError{http.StatusNotFound, fmt.Sprintf("no timed game %q", id), "GAME_NOT_FOUND"}
+}
+
+// get returns the game with id, or nil.
+func (gh *gameHub) get(id string) *gameSession {
+	gh.mu.Lock()
+	defer gh.mu.Unlock()
+	gs := gh.sessions[id]
+	if gs != nil {
+		gs.touch(gh.now())
+	}
+	return gs
+}
+
+// add registers a new game and returns its ID, or "" if there are too many
+// open games.
+func (gh *gameHub) add(gs *gameSession) string {
+	gh.mu.Lock()
+	defer gh.mu.Unlock()
+
+	now := gh.now()
+	if len(gh.sessions) >= maxGameSessions {
+		gh.dropIdle(now)
+		if len(gh.sessions) >= maxGameSessions {
+			return ""
+		}
+	}
+	for {
+		b := make([]byte, 8)
+		rand.Read(b)
+		id := hex.EncodeToString(b)
+		if _, ok := gh.sessions[id]; !ok {
+			gs.touch(now)
+			gh.sessions[id] = gs
+			return id
+		}
+	}
+}
+
+// dropIdle removes games unused for gameIdleTimeout.
+// The caller must hold gh.mu.
+func (gh *gameHub) dropIdle(now time.Time) {
+	for id, gs := range gh.sessions {
+		gs.mu.Lock()
+		idle := now.Sub(gs.used) > gameIdleTimeout
+		gs.mu.Unlock()
+		if idle {
+			delete(gh.sessions, id)
+		}
+	}
+}
+
+func (gs *gameSession) touch(now time.Time) {
+	gs.mu.Lock()
+	gs.used = now
+	gs.mu.Unlock()
+}
+
+// state returns the state of the game, with ID id.
+// The caller must hold gs.mu.
+func (gs *gameSession) state(id string) *GameStateResponse {
+	s := gs.ctrl.State()
+	return &GameStateResponse{
+		Session:     id,
+		Phase:       s.Phase.String(),
+		Turn:        s.Turn,
+		Decider:     s.Decider,
+		Position:    positionid.PositionID(positionid.Board(s.Board)),
+		Dice:        s.Dice,
+		CubeValue:   s.CubeValue,
+		CubeOwner:   s.CubeOwner,
+		MatchLength: gs.ctrl.Match().MatchLength,
+		Score:       s.Score,
+		Game:        s.Game,
+		Crawford:    s.Crawford,
+		Winner:      s.Winner,
+		Clock: GameClockResponse{
+			RemainingMs: [2]int64{s.Clock.Remaining[0].Milliseconds(), s.Clock.Remaining[1].Milliseconds()},
+			Running:     s.Clock.Running,
+			Flagged:     s.Clock.Flagged,
+		},
+	}
+}
+
+// StartGame handles POST /api/games.
+func (h *Handlers) StartGame(w http.ResponseWriter, r *http.Request) {
+	var req GameStartRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	state, gerr := h.games.start(&req)
+	if gerr != nil {
+		writeError(w, gerr.status, gerr.msg, gerr.code)
+		return
+	}
+	writeJSON(w, http.StatusCreated, state)
+}
+
+// GetGame handles GET /api/games/{id}, the state of a timed game with the
+// time each player has left.
+func (h *Handlers) GetGame(w http.ResponseWriter, r *http.Request) {
+	state, gerr := h.games.state(r.PathValue("id"))
+	if gerr != nil {
+		writeError(w, gerr.status, gerr.msg, gerr.code)
+		return
+	}
+	writeJSON(w, http.StatusOK, state)
+}
+
+// GameAction handles POST /api/games/{id}/action.
+func (h *Handlers) GameAction(w http.ResponseWriter, r *http.Request) {
+	var req GameActionRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	state, gerr := h.games.act(r.PathValue("id"), &req)
+	if gerr != nil {
+		writeError(w, gerr.status, gerr.msg, gerr.code)
+		return
+	}
+	writeJSON(w, http.StatusOK, state)
+}
+
+func (c *WSClient) handleGameStart(ctx context.Context, msg WSMessage) {
+	var req GameStartRequest
+	if err := json.Unmarshal(msg.Payload, &req); err != nil {
+		c.reply(ctx, WSResponse{Type: "error", ID: msg.ID, Error: "invalid payload"})
+		return
+	}
+	state, gerr := c.handlers.games.start(&req)
+	c.replyGame(ctx, msg, state, gerr)
+}
+
+func (c *WSClient) handleGameState(ctx context.Context, msg WSMessage) {
+	var req GameStateRequest
+	if err := json.Unmarshal(msg.Payload, &req); err != nil {
+		c.reply(ctx, WSResponse{Type: "error", ID: msg.ID, Error: "invalid payload"})
+		return
+	}
+	state, gerr := c.handlers.games.state(req.Session)
+	c.replyGame(ctx, msg, state, gerr)
+}
+
+func (c *WSClient) handleGameAction(ctx context.Context, msg WSMessage) {
+	var req GameActionRequest
+	if err := json.Unmarshal(msg.Payload, &req); err != nil {
+		c.reply(ctx, WSResponse{Type: "error", ID: msg.ID, Error: "invalid payload"})
+		return
+	}
+	state, gerr := c.handlers.games.act(req.Session, &req)
+	c.replyGame(ctx, msg, state, gerr)
+}
+
+// replyGame replies to a game message with the state of the game, or the
+// error.
+func (c *WSClient) replyGame(ctx context.Context, msg WSMessage, state *GameStateResponse, gerr *gameError) {
+	if gerr != nil {
+		c.reply(ctx, WSResponse{Type: "error", ID: msg.ID, Error: gerr.msg, Code: gerr.code})
+		return
+	}
+	c.reply(ctx, WSResponse{Type: "result", ID: msg.ID, Payload: state})
+}
@@ -0,0 +1,175 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yourusername/bgengine/internal/positionid"
+	"github.com/yourusername/bgengine/pkg/engine"
+)
+
+// gameClock is a time source for timed games that the test advances by
+// hand. The server reads it from its own goroutines.
+type gameClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func (c *gameClock) now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *gameClock) sleep(d time.Duration) {
+	c.mu.Lock()
+	c.t = c.t.Add(d)
+	c.mu.Unlock()
+}
+
+// gameServer starts a server whose timed games run on a clock the test
+// advances.
+func gameServer(t *testing.T) (*httptest.Server, *gameClock) {
+	t.Helper()
+	clock := &gameClock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	s := NewServer(getTestEngine(), DefaultConfig(), "test")
+	s.handlers.games.now = clock.now
+	srv := httptest.NewServer(s.Handler())
+	t.Cleanup(srv.Close)
+	return srv, clock
+}
+
+// firstPlay returns a legal play of the game's roll.
+func firstPlay(t *testing.T, state GameStateResponse) string {
+	t.Helper()
+	board, err := positionid.BoardFromPositionID(state.Position)
+	if err != nil {
+		t.Fatalf("position %q: %v", state.Position, err)
+	}
+	ml := engine.GenerateMoves(engine.Board(board), state.Dice[0], state.Dice[1])
+	return engine.FormatMove(ml.Moves[0])
+}
+
+func TestTimedGameEndpoints(t *testing.T) {
+	srv, clock := gameServer(t)
+	handler := srv.Config.Handler
+
+	var state GameStateResponse
+	start := GameStartRequest{MatchLength: 5, Clock: GameClockRequest{ReserveMs: 60000, DelayMs: 10000}}
+	if code := serve(t, handler, "POST", "/api/games", start, &state); code != http.StatusCreated {
+		t.Fatalf("POST /api/games = %d", code)
+	}
+	if state.Phase != "move" || state.Decider != state.Turn || state.Clock.Running != state.Turn ||
+		state.Clock.RemainingMs != [2]int64{60000, 60000} || state.Winner != -1 {
+		t.Fatalf("state of a new game = %+v", state)
+	}
+	id, mover := state.Session, state.Turn
+
+	// The move is stamped: the time beyond the delay is charged, and the
+	// opponent goes on the clock
+	clock.sleep(25 * time.Second)
+	action := GameActionRequest{Player: mover, Action: "move", Move: firstPlay(t, state)}
+	if code := serve(t, handler, "POST", "/api/games/"+id+"/action", action, &state); code != http.StatusOK {
+		t.Fatalf("move = %d", code)
+	}
+	if state.Phase != "roll" || state.Turn != 1-mover || state.Clock.Running != 1-mover ||
+		state.Clock.RemainingMs[mover] != 45000 {
+		t.Errorf("state after the move = %+v", state)
+	}
+
+	for _, tc := range []struct {
+		action GameActionRequest
+		status int
+		code   string
+	}{
+		{GameActionRequest{Player: mover, Action: "roll"}, http.StatusConflict, "INVALID_ACTION"},
+		{GameActionRequest{Player: 1 - mover, Action: "resign"}, http.StatusBadRequest, "INVALID_ACTION"},
+	} {
+		if status, resp := postJSON(t, srv, "/api/games/"+id+"/action", tc.action); status != tc.status || resp.Code != tc.code {
+			t.Errorf("%+v = %d %s, want %d %s", tc.action, status, resp.Code, tc.status, tc.code)
+		}
+	}
+
+	// The state shows the running decision, and a flag fall costs the match
+	clock.sleep(30 * time.Second)
+	if code := serve(t, handler, "GET", "/api/games/"+id, nil, &state); code != http.StatusOK {
+		t.Fatalf("GET /api/games/%s = %d", id, code)
+	}
+	if state.Clock.RemainingMs[1-mover] != 40000 {
+		t.Errorf("remaining during the decision = %v, want 40000 ms", state.Clock.RemainingMs)
+	}
+	clock.sleep(41 * time.Second)
+	if status, resp := postJSON(t, srv, "/api/games/"+id+"/action", GameActionRequest{Player: 1 - mover, Action: "roll"}); status != http.StatusConflict || resp.Code != "FLAG_FELL" {
+		t.Errorf("roll after the flag fell = %d %s, want 409 FLAG_FELL", status, resp.Code)
+	}
+	serve(t, handler, "GET", "/api/games/"+id, nil, &state)
+	if state.Phase != "over" || state.Winner != mover || state.Score[mover] != 5 || state.Clock.Flagged != 1-mover {
+		t.Errorf("state after the flag fell = %+v", state)
+	}
+
+	if status, resp := postJSON(t, srv, "/api/games", GameStartRequest{Clock: GameClockRequest{ReserveMs: 1000, DelayMode: "fischer"}}); status != http.StatusBadRequest || resp.Code != "INVALID_CLOCK" {
+		t.Errorf("unknown delay mode = %d %s, want 400 INVALID_CLOCK", status, resp.Code)
+	}
+	if code := serve(t, handler, "GET", "/api/games/nosuchgame", nil, nil); code != http.StatusNotFound {
+		t.Errorf("GET of an unknown game = %d, want 404", code)
+	}
+}
+
+func TestTimedGameWebSocketReconnect(t *testing.T) {
+	srv, clock := gameServer(t)
+
+	ws := dialWS(t, srv)
+	resp, _ := wsCall(t, ws, "game_start", "1", GameStartRequest{
+		Clock: GameClockRequest{ReserveMs: 60000, DelayMs: 5000, DelayMode: "bronstein", Flag: "game"},
+	})
+	var state GameStateResponse
+	if resp.Type != "result" || json.Unmarshal(resp.Payload.(json.RawMessage), &state) != nil {
+		t.Fatalf("game_start = %+v", resp)
+	}
+	mover := state.Turn
+	ws.Close()
+
+	// The game outlives the connection, and its clock runs on
+	clock.sleep(20 * time.Second)
+	ws = dialWS(t, srv)
+	defer ws.Close()
+	resp, _ = wsCall(t, ws, "game_state", "2", GameStateRequest{Session: state.Session})
+	if resp.Type != "result" || json.Unmarshal(resp.Payload.(json.RawMessage), &state) != nil {
+		t.Fatalf("game_state = %+v", resp)
+	}
+	if state.Clock.Running != mover || state.Clock.RemainingMs[mover] != 40000 {
+		t.Errorf("clock after reconnecting = %+v, want %d running with 40000 ms", state.Clock, mover)
+	}
+
+	// An illegal play leaves the mover on the clock
+	resp, _ = wsCall(t, ws, "game_action", "3", GameActionRequest{
+		Session: state.Session, Player: mover, Action: "move", Move: "24/23(4)",
+	})
+	if resp.Type != "error" || resp.Code != "ILLEGAL_MOVE" {
+		t.Errorf("illegal play = %+v, want ILLEGAL_MOVE", resp)
+	}
+
+	// The Bronstein delay gives back up to the delay when the move is made
+	resp, _ = wsCall(t, ws, "game_action", "4", GameActionRequest{
+		Session: state.Session, Player: mover, Action: "move", Move: firstPlay(t, state),
+	})
+	if resp.Type != "result" || json.Unmarshal(resp.Payload.(json.RawMessage), &state) != nil {
+		t.Fatalf("game_action = %+v", resp)
+	}
+	if state.Clock.RemainingMs[mover] != 45000 || state.Clock.Running != 1-mover {
+		t.Errorf("clock after the move = %+v, want %d with 45000 ms", state.Clock, mover)
+	}
+
+	resp, _ = wsCall(t, ws, "game_action", "5", GameActionRequest{Session: state.Session, Player: mover, Action: "take"})
+	if resp.Type != "error" || resp.Code != "INVALID_ACTION" {
+		t.Errorf("take without a double = %+v, want INVALID_ACTION", resp)
+	}
+	resp, _ = wsCall(t, ws, "game_state", "6", GameStateRequest{Session: "nosuchgame"})
+	if resp.Type != "error" || resp.Code != "GAME_NOT_FOUND" {
+		t.Errorf("game_state of an unknown game = %+v, want GAME_NOT_FOUND", resp)
+	}
+}
@@ -2,13 +2,19 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/yourusername/bgengine/internal/positionid"
 	"github.com/yourusername/bgengine/pkg/engine"
 	"github.com/yourusername/bgengine/pkg/external"
+	"github.com/yourusername/bgengine/pkg/match"
 )
 
 // Handlers holds the HTTP handlers and engine reference.
@@ -16,6 +22,33 @@ type Handlers struct {
 	engine  *engine.Engine
 	version string
 	pool    *WorkerPool
+	limits  Limits
+	depth   depth
+	debug   bool // Serve debugging endpoints such as /api/inspect
+
+	identityHeader string // Header naming the client identity of slow operations ("" = none)
+
+	broadcasts *broadcastHub
+
+	matches   match.Store // nil if the server keeps no matches
+	analyzing sync.Map    // IDs of matches being analyzed
+
+	positions *engine.PositionDB // Positions quizzes are drawn from
+	quizzes   *quizHub
+
+	bots *botHub // Bot providers connected over WebSocket
+
+	games *gameHub // Timed games
+
+	selfCheck *selfCheckResult // Engine self-check, run once
+}
+
+// selfCheckResult holds the engine self-check /api/health/selfcheck
+// reports: the one run at startup (see ServerConfig.SelfCheck), or else
+// one run on the first request.
+type selfCheckResult struct {
+	once   sync.Once
+	report *engine.SelfCheckReport
 }
 
 // NewHandlers creates a new Handlers instance without a worker pool.
@@ -24,6 +57,19 @@ func NewHandlers(e *engine.Engine, version string) *Handlers {
 		engine:  e,
 		version: version,
 		pool:    nil,
+		limits:  DefaultLimits(),
+		depth:   defaultDepth(),
+
+		broadcasts: newBroadcastHub(),
+
+		positions: engine.DefaultPositionDB(),
+		quizzes:   newQuizHub(),
+
+		bots: newBotHub(),
+
+		games: newGameHub(),
+
+		selfCheck: &selfCheckResult{},
 	}
 }
 
@@ -33,7 +79,30 @@ func NewHandlersWithPool(e *engine.Engine, version string, pool *WorkerPool) *Ha
 		engine:  e,
 		version: version,
 		pool:    pool,
+		limits:  DefaultLimits(),
+		depth:   defaultDepth(),
+
+		broadcasts: newBroadcastHub(),
+
+		positions: engine.DefaultPositionDB(),
+		quizzes:   newQuizHub(),
+
+		bots: newBotHub(),
+
+		games: newGameHub(),
+
+		selfCheck: &selfCheckResult{},
+	}
+}
+
+// identity returns the client identity r's slow operations are scheduled
+// under, hashed from its identity header (see ServerConfig.IdentityHeader),
+// or "" if the server takes no identities or r has none.
+func (h *Handlers) identity(r *http.Request) string {
+	if h.identityHeader == "" {
+		return ""
 	}
+	return HashIdentity(r.Header.Get(h.identityHeader))
 }
 
 // writeJSON writes a JSON response with the given status code.
@@ -51,9 +120,45 @@ func writeError(w http.ResponseWriter, status int, msg string, code string) {
 	})
 }
 
+// writeIllegalMove writes an ILLEGAL_MOVE error listing the legal resulting
+// position IDs. They have the opponent on roll, as engine.ResultingPositionID
+// and gnubg give them.
+func writeIllegalMove(w http.ResponseWriter, illegal *engine.IllegalMoveError) {
+	legal := make([]string, len(illegal.LegalResults))
+	for i, b := range illegal.LegalResults {
+		legal[i] = positionid.PositionID(positionid.SwapSides(positionid.Board(b)))
+	}
+	writeJSON(w, http.StatusBadRequest, ErrorResponse{
+		Error:          illegal.Error(),
+		Code:           "ILLEGAL_MOVE",
+		LegalPositions: legal,
+	})
+}
+
+// checkMET writes an INVALID_MET error listing the engine's match equity
+// tables and returns false if name selects none of them.
+func (h *Handlers) checkMET(w http.ResponseWriter, name string) bool {
+	if err := h.engine.CheckMET(name); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{
+			Error:         err.Error(),
+			Code:          "INVALID_MET",
+			AvailableMETs: h.engine.METNames(),
+		})
+		return false
+	}
+	return true
+}
+
+// parsePositionID decodes a position ID, or the position ID of a gnubg
+// "positionID:matchID" string.
+func parsePositionID(id string) (positionid.Board, error) {
+	posID, _ := positionid.SplitGnubgID(id)
+	return positionid.BoardFromPositionID(posID)
+}
+
 // parseGameState creates a GameState from request parameters.
 func parseGameState(posID string, req interface{}) (*engine.GameState, error) {
-	board, err := positionid.BoardFromPositionID(posID)
+	board, err := parsePositionID(posID)
 	if err != nil {
 		return nil, fmt.Errorf("invalid position ID: %w", err)
 	}
@@ -66,8 +171,11 @@ func parseGameState(posID string, req interface{}) (*engine.GameState, error) {
 	}
 
 	// Apply optional parameters based on request type
+	opponent, partial := false, false
 	switch r := req.(type) {
 	case *EvaluateRequest:
+		opponent = r.Opponent
+		partial = r.AllowPartial
 		gs.MatchLength = r.MatchLength
 		gs.Score = r.Score
 		if r.CubeValue > 0 {
@@ -75,6 +183,7 @@ func parseGameState(posID string, req interface{}) (*engine.GameState, error) {
 		}
 		gs.CubeOwner = r.CubeOwner
 		gs.Crawford = r.Crawford
+		gs.MET = r.MET
 	case *MoveRequest:
 		gs.MatchLength = r.MatchLength
 		gs.Score = r.Score
@@ -84,7 +193,28 @@ func parseGameState(posID string, req interface{}) (*engine.GameState, error) {
 		gs.CubeOwner = r.CubeOwner
 		gs.Crawford = r.Crawford
 		gs.Dice = r.Dice
+		partial = r.AllowPartial
 	case *CubeRequest:
+		opponent = r.Opponent
+		partial = r.AllowPartial
+		gs.MatchLength = r.MatchLength
+		gs.Score = r.Score
+		if r.CubeValue > 0 {
+			gs.CubeValue = r.CubeValue
+		}
+		gs.CubeOwner = r.CubeOwner
+		gs.Crawford = r.Crawford
+		gs.MET = r.MET
+	case *BroadcastUpdateRequest:
+		gs.MatchLength = r.MatchLength
+		gs.Score = r.Score
+		if r.CubeValue > 0 {
+			gs.CubeValue = r.CubeValue
+		}
+		gs.CubeOwner = r.CubeOwner
+		gs.Crawford = r.Crawford
+		gs.Dice = r.Dice
+	case *ActionRequest:
 		gs.MatchLength = r.MatchLength
 		gs.Score = r.Score
 		if r.CubeValue > 0 {
@@ -92,6 +222,10 @@ func parseGameState(posID string, req interface{}) (*engine.GameState, error) {
 		}
 		gs.CubeOwner = r.CubeOwner
 		gs.Crawford = r.Crawford
+		gs.MET = r.MET
+		if r.Dice != nil {
+			gs.Dice = *r.Dice
+		}
 	case *RolloutRequest:
 		gs.MatchLength = r.MatchLength
 		gs.Score = r.Score
@@ -100,17 +234,114 @@ func parseGameState(posID string, req interface{}) (*engine.GameState, error) {
 		}
 		gs.CubeOwner = r.CubeOwner
 		gs.Crawford = r.Crawford
+		gs.MET = r.MET
+		partial = r.AllowPartial
+	}
+
+	if partial {
+		allowPartial(gs)
+	}
+	if opponent {
+		gs = gs.OpponentOnRoll()
+	}
+
+	return gs, nil
+}
+
+// allowPartial makes the checkers on the board of gs all each side has, none
+// borne off, for a request with allow_partial.
+func allowPartial(gs *engine.GameState) {
+	for side := range gs.TotalCheckers {
+		gs.TotalCheckers[side] = gs.Board.OnBoard(side)
+	}
+}
+
+// convertPosition replaces a position given in the named format with its
+// position ID. For a Snowie text position it returns the match state the
+// text describes, with score and cube owner relative to the player on roll
+// like the request fields; for a position ID it returns nil.
+func convertPosition(format string, position *string) (*engine.GameState, error) {
+	switch format {
+	case "", "gnubg":
+		return nil, nil
+	case "snowie":
+	default:
+		return nil, fmt.Errorf("unknown position format %q", format)
+	}
+
+	gs, err := match.ParseSnowieText(*position)
+	if err != nil {
+		return nil, err
 	}
+	*position = positionid.PositionID(positionid.Board(gs.Board))
 
+	if gs.Turn == 1 {
+		gs.Score = [2]int{gs.Score[1], gs.Score[0]}
+	}
+	if gs.CubeOwner >= 0 {
+		gs.CubeOwner = gs.CubeOwner ^ gs.Turn
+	}
+	gs.Turn = 0
 	return gs, nil
 }
 
+// applyPositionFormat rewrites a request whose position is in another format
+// than a position ID so the handlers see an ordinary request. The match
+// score, cube and Crawford flag of a Snowie text position replace the
+// request's own; its dice are used when the request gives none.
+func applyPositionFormat(req interface{}) error {
+	switch r := req.(type) {
+	case *EvaluateRequest:
+		gs, err := convertPosition(r.Format, &r.Position)
+		if gs != nil {
+			r.MatchLength, r.Score, r.Crawford = gs.MatchLength, gs.Score, gs.Crawford
+			r.CubeValue, r.CubeOwner = gs.CubeValue, gs.CubeOwner
+		}
+		return err
+	case *MoveRequest:
+		gs, err := convertPosition(r.Format, &r.Position)
+		if gs != nil {
+			r.MatchLength, r.Score, r.Crawford = gs.MatchLength, gs.Score, gs.Crawford
+			r.CubeValue, r.CubeOwner = gs.CubeValue, gs.CubeOwner
+			if r.Dice == [2]int{} {
+				r.Dice = gs.Dice
+			}
+		}
+		return err
+	case *CubeRequest:
+		gs, err := convertPosition(r.Format, &r.Position)
+		if gs != nil {
+			r.MatchLength, r.Score, r.Crawford = gs.MatchLength, gs.Score, gs.Crawford
+			r.CubeValue, r.CubeOwner = gs.CubeValue, gs.CubeOwner
+		}
+		return err
+	case *ActionRequest:
+		gs, err := convertPosition(r.Format, &r.Position)
+		if gs != nil {
+			r.MatchLength, r.Score, r.Crawford = gs.MatchLength, gs.Score, gs.Crawford
+			r.CubeValue, r.CubeOwner = gs.CubeValue, gs.CubeOwner
+			if r.Dice == nil && gs.Dice != [2]int{} {
+				r.Dice = &gs.Dice
+			}
+		}
+		return err
+	case *RolloutRequest:
+		gs, err := convertPosition(r.Format, &r.Position)
+		if gs != nil {
+			r.MatchLength, r.Score, r.Crawford = gs.MatchLength, gs.Score, gs.Crawford
+			r.CubeValue, r.CubeOwner = gs.CubeValue, gs.CubeOwner
+		}
+		return err
+	}
+	return nil
+}
+
 // Health handles GET /api/health
 func (h *Handlers) Health(w http.ResponseWriter, r *http.Request) {
 	resp := HealthResponse{
 		Status:  "ok",
 		Version: h.version,
-		Ready:   h.engine != nil,
+		Ready:   h.engine != nil && h.engine.Warmed(),
 	}
 
 	// Include pool stats if available
@@ -119,9 +350,98 @@ func (h *Handlers) Health(w http.ResponseWriter, r *http.Request) {
 		resp.Pool = &stats
 	}
 
+	if h.engine != nil {
+		name, length := h.engine.METInfo()
+		resp.MET = &METInfo{Name: name, Length: length, Tables: h.engine.METNames()}
+		resp.Disabled = h.engine.Routing().Disabled()
+		resp.Kernel = h.engine.Kernel()
+		resp.CPU = engine.CPUFeatures().Names()
+		resp.Data = h.engine.DataStatus()
+		resp.Engine = h.engine.BuildInfo()
+	}
+
 	writeJSON(w, http.StatusOK, resp)
 }
 
+// SelfCheck handles GET /api/health/selfcheck, reporting the engine
+// self-check: each sentinel position with the values observed. The check
+// is not run again on each request; a server that ran it at startup
+// reports that run. A failed check is answered with 503 so that monitors
+// polling the endpoint raise an alert.
+func (h *Handlers) SelfCheck(w http.ResponseWriter, r *http.Request) {
+	if h.engine == nil {
+		writeError(w, http.StatusServiceUnavailable, "engine not loaded", "ENGINE_NOT_LOADED")
+		return
+	}
+	h.selfCheck.once.Do(func() {
+		if h.selfCheck.report == nil {
+			h.selfCheck.report = h.engine.SelfCheck()
+		}
+	})
+	report := h.selfCheck.report
+
+	resp := SelfCheckResponse{
+		Status:     engine.SelfCheckPass,
+		CheckedAt:  report.CheckedAt.UTC().Format(time.RFC3339),
+		DurationMs: float64(report.Duration.Microseconds()) / 1000,
+		Sentinels:  report.Sentinels,
+	}
+	status := http.StatusOK
+	if !report.Pass {
+		resp.Status = engine.SelfCheckFail
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, resp)
+}
+
+// Metrics handles GET /metrics, reporting the worker pool statistics in
+// the Prometheus text format: the fast and slow pools' active, queued and
+// total operations and slots, and the slow pool usage of each client
+// identity.
+func (h *Handlers) Metrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if h.pool == nil {
+		return
+	}
+	stats := h.pool.Stats()
+
+	metric := func(name, kind, help string, values ...string) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+		for _, v := range values {
+			fmt.Fprintf(w, "%s%s\n", name, v)
+		}
+	}
+	pools := func(fast, slow int64) []string {
+		return []string{fmt.Sprintf(`{pool="fast"} %d`, fast), fmt.Sprintf(`{pool="slow"} %d`, slow)}
+	}
+	metric("bgengine_pool_active", "gauge", "Operations running.", pools(stats.ActiveFast, stats.ActiveSlow)...)
+	metric("bgengine_pool_queued", "gauge", "Operations waiting for a slot.", pools(stats.QueuedFast, stats.QueuedSlow)...)
+	metric("bgengine_pool_completed_total", "counter", "Operations completed.", pools(stats.TotalFast, stats.TotalSlow)...)
+	metric("bgengine_pool_slots", "gauge", "Operations that may run at once.", pools(int64(stats.MaxFast), int64(stats.MaxSlow))...)
+
+	if len(stats.Identities) == 0 {
+		return
+	}
+	identities := make([]string, 0, len(stats.Identities))
+	for identity := range stats.Identities {
+		identities = append(identities, identity)
+	}
+	sort.Strings(identities)
+	byIdentity := func(value func(IdentityStats) int64) []string {
+		values := make([]string, len(identities))
+		for i, identity := range identities {
+			values[i] = fmt.Sprintf(`{identity=%q} %d`, identity, value(stats.Identities[identity]))
+		}
+		return values
+	}
+	metric("bgengine_identity_slow_active", "gauge", "Slow operations running, by client identity.",
+		byIdentity(func(s IdentityStats) int64 { return s.ActiveSlow })...)
+	metric("bgengine_identity_slow_queued", "gauge", "Slow operations waiting for a slot, by client identity.",
+		byIdentity(func(s IdentityStats) int64 { return s.QueuedSlow })...)
+	metric("bgengine_identity_slow_completed_total", "counter", "Slow operations completed, by client identity.",
+		byIdentity(func(s IdentityStats) int64 { return s.TotalSlow })...)
+}
+
 // Evaluate handles POST /api/evaluate
 func (h *Handlers) Evaluate(w http.ResponseWriter, r *http.Request) {
 	// Acquire fast worker slot if pool is configured
@@ -134,8 +454,7 @@ func (h *Handlers) Evaluate(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req EvaluateRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid JSON", "INVALID_JSON")
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
@@ -144,45 +463,43 @@ func (h *Handlers) Evaluate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := applyPositionFormat(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error(), "INVALID_POSITION")
+		return
+	}
+
+	conv, err := ParseConvention(req.Convention)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error(), "INVALID_CONVENTION")
+		return
+	}
+
+	ply, clamped, err := h.depth.ply(req.Ply, h.depth.evalDefault)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error(), "INVALID_PLY")
+		return
+	}
+
+	if !h.checkMET(w, req.MET) {
+		return
+	}
+
 	gs, err := parseGameState(req.Position, &req)
 	if err != nil {
 		writeError(w, http.StatusBadRequest, err.Error(), "INVALID_POSITION")
 		return
 	}
 
-	eval, err := h.engine.Evaluate(gs)
+	resp, err := EvaluateAtPly(h.engine, gs, ply, newTiming(req.DebugTiming))
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error(), "EVAL_ERROR")
 		return
 	}
+	resp.Opponent = req.Opponent
+	resp.Clamped = clamped
 
-	writeJSON(w, http.StatusOK, EvalToResponse(eval, req.Ply, false))
-}
-
-// formatMove converts a Move to human-readable notation.
-func formatMove(m engine.Move) string {
-	result := ""
-	for i := 0; i < 4; i++ {
-		if m.From[i] < 0 {
-			break
-		}
-		if i > 0 {
-			result += " "
-		}
-		from := int(m.From[i]) + 1
-		if m.From[i] == 24 {
-			result += "bar"
-		} else {
-			result += fmt.Sprintf("%d", from)
-		}
-		result += "/"
-		if m.To[i] < 0 {
-			result += "off"
-		} else {
-			result += fmt.Sprintf("%d", int(m.To[i])+1)
-		}
-	}
-	return result
+	applyConvention(conv, resp, gs.CubeValue)
+	writeJSON(w, http.StatusOK, resp)
 }
 
 // Move handles POST /api/move
@@ -197,8 +514,7 @@ func (h *Handlers) Move(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req MoveRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid JSON", "INVALID_JSON")
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
@@ -207,48 +523,83 @@ func (h *Handlers) Move(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := applyPositionFormat(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error(), "INVALID_POSITION")
+		return
+	}
+
+	conv, err := ParseConvention(req.Convention)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error(), "INVALID_CONVENTION")
+		return
+	}
+
 	if req.Dice[0] < 1 || req.Dice[0] > 6 || req.Dice[1] < 1 || req.Dice[1] > 6 {
 		writeError(w, http.StatusBadRequest, "dice must be 1-6", "INVALID_DICE")
 		return
 	}
 
+	if err := exceeds("num_moves", req.NumMoves, h.limits.MaxNumMoves); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error(), "TOO_MANY_MOVES")
+		return
+	}
+
+	if err := checkTimeLimit(&req, h.limits); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error(), "INVALID_TIME_LIMIT")
+		return
+	}
+
+	clamped, err := h.depth.movePly(&req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error(), "INVALID_PLY")
+		return
+	}
+	if req.Consensus && h.depth.maxPly < 1 {
+		writeError(w, http.StatusBadRequest, "consensus needs 1-ply analysis, beyond this server's maximum ply", "INVALID_PLY")
+		return
+	}
+
+	constraint, err := engine.ParseMoveConstraint(strings.Join(req.Constraints, ","))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error(), "INVALID_CONSTRAINT")
+		return
+	}
+
 	gs, err := parseGameState(req.Position, &req)
 	if err != nil {
 		writeError(w, http.StatusBadRequest, err.Error(), "INVALID_POSITION")
 		return
 	}
 
-	analysis, err := h.engine.AnalyzePosition(gs, req.Dice)
+	timing := newTiming(req.DebugTiming)
+	analysis, err := AnalyzeMoves(h.engine, gs, &req, timing)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error(), "ANALYSIS_ERROR")
 		return
 	}
 
-	numMoves := req.NumMoves
-	if numMoves <= 0 {
-		numMoves = 5
-	}
-	if numMoves > len(analysis.Moves) {
-		numMoves = len(analysis.Moves)
+	var constrained *ConstraintResponse
+	if !constraint.IsZero() {
+		if analysis, constrained, err = ConstrainMoves(analysis, gs.Board, constraint); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error(), "NO_MOVE_ALLOWED")
+			return
+		}
 	}
 
-	moves := make([]MoveResponse, numMoves)
-	for i := 0; i < numMoves; i++ {
-		m := analysis.Moves[i]
-		moves[i] = MoveResponse{
-			Move:   formatMove(m.Move),
-			Equity: m.Equity,
-			Win:    m.Eval.WinProb * 100,
-			WinG:   m.Eval.WinG * 100,
+	resp := MovesToResponse(analysis, gs.Board, req.Position, req.Dice, req.NumMoves)
+	resp.Clamped = clamped
+	resp.Timing = TimingToResponse(timing)
+	resp.Constraint = constrained
+	if req.Consensus {
+		consensus, err := h.engine.ConsensusAnalysis(gs, req.Dice)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error(), "ANALYSIS_ERROR")
+			return
 		}
+		resp.Consensus = ConsensusToResponse(consensus)
 	}
-
-	writeJSON(w, http.StatusOK, MovesResponse{
-		Moves:    moves,
-		NumLegal: analysis.NumMoves,
-		Dice:     req.Dice,
-		Position: req.Position,
-	})
+	applyConvention(conv, resp, gs.CubeValue)
+	writeJSON(w, http.StatusOK, resp)
 }
 
 // Cube handles POST /api/cube
@@ -263,8 +614,7 @@ func (h *Handlers) Cube(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req CubeRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid JSON", "INVALID_JSON")
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
@@ -273,51 +623,115 @@ func (h *Handlers) Cube(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := applyPositionFormat(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error(), "INVALID_POSITION")
+		return
+	}
+
+	conv, err := ParseConvention(req.Convention)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error(), "INVALID_CONVENTION")
+		return
+	}
+
+	if !h.checkMET(w, req.MET) {
+		return
+	}
+
 	gs, err := parseGameState(req.Position, &req)
 	if err != nil {
 		writeError(w, http.StatusBadRequest, err.Error(), "INVALID_POSITION")
 		return
 	}
 
-	decision, err := h.engine.AnalyzeCube(gs)
+	timing := newTiming(req.DebugTiming)
+	decision, err := h.engine.AnalyzeCubeWithOptions(gs, engine.EvalOptions{Timing: timing})
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error(), "CUBE_ERROR")
 		return
 	}
 
-	action := "no_double"
-	diff := decision.DoubleTakeEq - decision.NoDoubleEquity
-	if diff > 0 {
-		if decision.DoublePassEq > decision.DoubleTakeEq {
-			action = "double_pass"
-		} else {
-			action = "double_take"
+	resp := CubeToResponse(decision)
+	resp.Opponent = req.Opponent
+	resp.Timing = TimingToResponse(timing)
+	if resp.Race, err = RaceCube(h.engine, gs); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error(), "CUBE_ERROR")
+		return
+	}
+	resp.Entry = EntryToResponse(h.engine.EntryStats(gs))
+	applyConvention(conv, resp, gs.CubeValue)
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// Action handles POST /api/action
+func (h *Handlers) Action(w http.ResponseWriter, r *http.Request) {
+	// Acquire fast worker slot if pool is configured
+	if h.pool != nil {
+		if err := h.pool.AcquireFast(r.Context()); err != nil {
+			writeError(w, http.StatusServiceUnavailable, "server busy", "SERVER_BUSY")
+			return
 		}
+		defer h.pool.ReleaseFast()
 	}
 
-	writeJSON(w, http.StatusOK, CubeResponse{
-		Action:         action,
-		DoubleEquity:   decision.DoubleTakeEq,
-		NoDoubleEquity: decision.NoDoubleEquity,
-		TakeEquity:     decision.DoubleTakeEq, // From opponent's perspective this is their take equity
-		DoubleDiff:     diff,
-	})
+	var req ActionRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if req.Position == "" {
+		writeError(w, http.StatusBadRequest, "position is required", "MISSING_POSITION")
+		return
+	}
+
+	if err := applyPositionFormat(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error(), "INVALID_POSITION")
+		return
+	}
+
+	if d := req.Dice; d != nil && (d[0] < 1 || d[0] > 6 || d[1] < 1 || d[1] > 6) {
+		writeError(w, http.StatusBadRequest, "dice must be 1-6", "INVALID_DICE")
+		return
+	}
+
+	if err := exceeds("num_moves", req.NumMoves, h.limits.MaxNumMoves); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error(), "TOO_MANY_MOVES")
+		return
+	}
+
+	if !h.checkMET(w, req.MET) {
+		return
+	}
+
+	gs, err := parseGameState(req.Position, &req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error(), "INVALID_POSITION")
+		return
+	}
+
+	resp, err := Action(h.engine, req.Position, gs, req.Dice, req.NumMoves)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error(), "ANALYSIS_ERROR")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
 }
 
 // Rollout handles POST /api/rollout
 func (h *Handlers) Rollout(w http.ResponseWriter, r *http.Request) {
 	// Acquire slow worker slot if pool is configured (rollouts are CPU-intensive)
 	if h.pool != nil {
-		if err := h.pool.AcquireSlow(r.Context()); err != nil {
+		identity := h.identity(r)
+		if err := h.pool.AcquireSlowAs(r.Context(), identity); err != nil {
 			writeError(w, http.StatusServiceUnavailable, "server busy", "SERVER_BUSY")
 			return
 		}
-		defer h.pool.ReleaseSlow()
+		defer h.pool.ReleaseSlowAs(identity)
 	}
 
 	var req RolloutRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid JSON", "INVALID_JSON")
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
@@ -326,30 +740,54 @@ func (h *Handlers) Rollout(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := applyPositionFormat(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error(), "INVALID_POSITION")
+		return
+	}
+
 	gs, err := parseGameState(req.Position, &req)
 	if err != nil {
 		writeError(w, http.StatusBadRequest, err.Error(), "INVALID_POSITION")
 		return
 	}
 
-	trials := req.Trials
-	if trials <= 0 {
-		trials = 1296
+	trials, clamped, err := h.depth.trials(req.Trials, h.limits)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error(), "TOO_MANY_TRIALS")
+		return
+	}
+
+	if d := req.InitialDice; d != [2]int{} && (d[0] < 1 || d[0] > 6 || d[1] < 1 || d[1] > 6) {
+		writeError(w, http.StatusBadRequest, "initial dice must be 1-6", "INVALID_DICE")
+		return
 	}
 
 	opts := engine.RolloutOptions{
-		Trials:   trials,
-		Truncate: req.Truncate,
-		Seed:     req.Seed,
+		Trials:      trials,
+		Truncate:    req.Truncate,
+		Seed:        req.Seed,
+		InitialDice: req.InitialDice,
 	}
 
-	result, err := h.engine.Rollout(gs, opts)
+	result, err := h.engine.RolloutContext(r.Context(), gs, opts, nil)
+	if r.Context().Err() != nil {
+		// The client has gone: stop the trials instead of finishing them
+		return
+	}
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error(), "ROLLOUT_ERROR")
 		return
 	}
 
-	writeJSON(w, http.StatusOK, RolloutResponse{
+	resp := RolloutToResponse(result)
+	resp.Engine = h.engine.BuildInfo()
+	resp.Clamped = clamped
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// RolloutToResponse converts a rollout result to its API response.
+func RolloutToResponse(result *engine.RolloutResult) RolloutResponse {
+	resp := RolloutResponse{
 		Equity:      result.Equity,
 		StdDev:      result.EquityStdDev,
 		CI95:        result.EquityCI,
@@ -359,17 +797,232 @@ func (h *Handlers) Rollout(w http.ResponseWriter, r *http.Request) {
 		LoseG:       result.LoseG * 100,
 		LoseBG:      result.LoseBG * 100,
 		Trials:      result.TrialsCompleted,
-		Truncated:   req.Truncate > 0,
-		TruncatePly: req.Truncate,
+		Truncated:   result.Truncate > 0,
+		TruncatePly: result.Truncate,
+		Cached:      result.Cached,
+
+		TrialsPerSecond: result.TrialsPerSecond,
+		AvgPlies:        result.AvgPlies,
+
+		Seed:     result.Seed,
+		DiceMode: string(result.DiceMode),
+		Manifest: result.Manifest,
+	}
+	if resp.DiceMode == "" {
+		resp.DiceMode = string(engine.DiceRandom)
+	}
+	if !result.StoredAt.IsZero() {
+		resp.StoredAt = result.StoredAt.UTC().Format(time.RFC3339)
+	}
+	return resp
+}
+
+// CubeRollout handles POST /api/cube/rollout
+func (h *Handlers) CubeRollout(w http.ResponseWriter, r *http.Request) {
+	// Acquire slow worker slot if pool is configured (rollouts are CPU-intensive)
+	if h.pool != nil {
+		identity := h.identity(r)
+		if err := h.pool.AcquireSlowAs(r.Context(), identity); err != nil {
+			writeError(w, http.StatusServiceUnavailable, "server busy", "SERVER_BUSY")
+			return
+		}
+		defer h.pool.ReleaseSlowAs(identity)
+	}
+
+	var req RolloutRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if req.Position == "" {
+		writeError(w, http.StatusBadRequest, "position is required", "MISSING_POSITION")
+		return
+	}
+
+	if err := applyPositionFormat(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error(), "INVALID_POSITION")
+		return
+	}
+
+	gs, err := parseGameState(req.Position, &req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error(), "INVALID_POSITION")
+		return
+	}
+
+	trials, clamped, err := h.depth.trials(req.Trials, h.limits)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error(), "TOO_MANY_TRIALS")
+		return
+	}
+
+	if req.InitialDice != [2]int{} {
+		writeError(w, http.StatusBadRequest, "a cube rollout starts before the roll: initial_dice not allowed", "INVALID_DICE")
+		return
+	}
+
+	if !h.checkMET(w, req.MET) {
+		return
+	}
+
+	result, err := h.engine.RolloutCubeContext(r.Context(), gs, engine.RolloutOptions{
+		Trials:   trials,
+		Truncate: req.Truncate,
+		Seed:     req.Seed,
 	})
+	if r.Context().Err() != nil {
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error(), "ROLLOUT_ERROR")
+		return
+	}
+
+	resp := CubeRolloutToResponse(result)
+	resp.Rollout.Clamped = clamped
+	resp.Engine = h.engine.BuildInfo()
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// CubeRolloutToResponse converts a cube rollout to its API response.
+func CubeRolloutToResponse(result *engine.CubeRolloutResult) *CubeRolloutResponse {
+	return &CubeRolloutResponse{
+		Cube:              CubeToResponse(result.Analysis),
+		Rollout:           RolloutToResponse(result.Cubeless),
+		NoDoubleCI:        result.NoDoubleCI,
+		DoubleTakeCI:      result.DoubleTakeCI,
+		DoubleDiffCI:      result.DoubleDiffCI,
+		DoubleSignificant: result.DoubleSignificant,
+		TakeSignificant:   result.TakeSignificant,
+	}
+}
+
+// ListStoredRollouts handles GET /api/admin/rollouts
+func (h *Handlers) ListStoredRollouts(w http.ResponseWriter, r *http.Request) {
+	store := h.rolloutStore(w)
+	if store == nil {
+		return
+	}
+
+	entries, err := store.List()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error(), "ROLLOUT_STORE_ERROR")
+		return
+	}
+
+	resp := StoredRolloutsResponse{Rollouts: make([]StoredRolloutResponse, len(entries))}
+	for i, e := range entries {
+		resp.Rollouts[i] = StoredRolloutResponse{
+			ID:          e.Key.ID(),
+			Position:    e.Key.Position,
+			Cube:        e.Key.Cube,
+			Trials:      e.Result.TrialsCompleted,
+			Equity:      e.Result.Equity,
+			CI95:        e.Result.EquityCI,
+			TruncatePly: e.Result.Truncate,
+			Created:     e.Created.UTC().Format(time.RFC3339),
+			Updated:     e.Updated.UTC().Format(time.RFC3339),
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// DeleteStoredRollout handles DELETE /api/admin/rollouts/{id}
+func (h *Handlers) DeleteStoredRollout(w http.ResponseWriter, r *http.Request) {
+	store := h.rolloutStore(w)
+	if store == nil {
+		return
+	}
+
+	entries, err := store.List()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error(), "ROLLOUT_STORE_ERROR")
+		return
+	}
+
+	id := r.PathValue("id")
+	for _, e := range entries {
+		if e.Key.ID() != id {
+			continue
+		}
+		if err := store.Delete(e.Key); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error(), "ROLLOUT_STORE_ERROR")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeError(w, http.StatusNotFound, fmt.Sprintf("no stored rollout %q", id), "ROLLOUT_NOT_FOUND")
+}
+
+// rolloutStore returns the engine's rollout store, writing an error response
+// and returning nil if the server has none.
+func (h *Handlers) rolloutStore(w http.ResponseWriter) engine.RolloutStore {
+	if h.engine != nil {
+		if store := h.engine.RolloutStore(); store != nil {
+			return store
+		}
+	}
+	writeError(w, http.StatusNotImplemented, "no rollout store is configured", "NO_ROLLOUT_STORE")
+	return nil
+}
+
+// Inspect handles GET and POST /api/inspect. It is only served when the
+// server runs with ServerConfig.Debug.
+func (h *Handlers) Inspect(w http.ResponseWriter, r *http.Request) {
+	if !h.debug {
+		writeError(w, http.StatusForbidden, "inspection is only available in debug mode", "DEBUG_DISABLED")
+		return
+	}
+
+	var req InspectRequest
+	if r.Method == http.MethodGet {
+		query := r.URL.Query()
+		req.Position = query.Get("position")
+		req.Verbose, _ = strconv.ParseBool(query.Get("verbose"))
+	} else if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if req.Position == "" {
+		writeError(w, http.StatusBadRequest, "position is required", "MISSING_POSITION")
+		return
+	}
+
+	gs, err := parseGameState(req.Position, &req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error(), "INVALID_POSITION")
+		return
+	}
+
+	ins, err := h.engine.Inspect(gs)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error(), "EVAL_ERROR")
+		return
+	}
+
+	resp := InspectResponse{
+		Position:  req.Position,
+		Class:     ins.Class,
+		Evaluator: ins.Evaluator,
+		Network:   ins.Network,
+		Output:    ins.Output,
+		Disabled:  ins.Disabled,
+	}
+	if req.Verbose {
+		resp.Inputs = make([]InputResponse, len(ins.Inputs))
+		for i, v := range ins.Inputs {
+			resp.Inputs[i] = InputResponse{Name: ins.InputNames[i], Value: v}
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
 }
 
 // HandleFIBSBoard handles FIBS board string analysis.
 // POST /api/fibsboard
 func (h *Handlers) HandleFIBSBoard(w http.ResponseWriter, r *http.Request) {
 	var req FIBSBoardRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, "Invalid JSON", "INVALID_JSON")
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
@@ -378,6 +1031,11 @@ func (h *Handlers) HandleFIBSBoard(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := exceeds("num_moves", req.NumMoves, h.limits.MaxNumMoves); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error(), "TOO_MANY_MOVES")
+		return
+	}
+
 	// Parse FIBS board string
 	fb, err := external.ParseFIBSBoard(req.Board)
 	if err != nil {
@@ -392,7 +1050,7 @@ func (h *Handlers) HandleFIBSBoard(w http.ResponseWriter, r *http.Request) {
 	posID := positionid.PositionID(positionid.Board(state.Board))
 
 	// Evaluate position
-	eval, err := h.engine.Evaluate(state)
+	eval, err := h.engine.EvaluateFull(state, engine.DefaultEvalOptions())
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, fmt.Sprintf("Evaluation failed: %v", err), "EVAL_ERROR")
 		return
@@ -416,6 +1074,9 @@ func (h *Handlers) HandleFIBSBoard(w http.ResponseWriter, r *http.Request) {
 		LoseG:       eval.LoseG * 100,
 		LoseBG:      eval.LoseBG * 100,
 		PositionID:  posID,
+
+		CubefulEquity: eval.CubefulEquity,
+		MWC:           eval.MWC * 100,
 	}
 
 	// If dice are rolled, get best moves
@@ -441,15 +1102,7 @@ func (h *Handlers) HandleFIBSBoard(w http.ResponseWriter, r *http.Request) {
 
 		for i := 0; i < count; i++ {
 			m := analysis.Moves[i]
-			moveResp := MoveResponse{
-				Move:   formatMove(m.Move),
-				Equity: m.Equity,
-			}
-			if m.Eval != nil {
-				moveResp.Win = m.Eval.WinProb * 100
-				moveResp.WinG = m.Eval.WinG * 100
-			}
-			resp.Moves = append(resp.Moves, moveResp)
+			resp.Moves = append(resp.Moves, moveResponse(m, engine.ResultingPositionID(state.Board, m.Move)))
 		}
 	}
 
@@ -459,18 +1112,7 @@ func (h *Handlers) HandleFIBSBoard(w http.ResponseWriter, r *http.Request) {
 		if err == nil {
 			resp.NoDoubleEquity = cubeAnalysis.NoDoubleEquity
 			resp.DoubleEquity = cubeAnalysis.DoubleTakeEq
-
-			switch cubeAnalysis.Decision.Action {
-			case engine.Double, engine.Redouble:
-				// Check if opponent should take or pass
-				if cubeAnalysis.DoubleTakeEq > cubeAnalysis.DoublePassEq {
-					resp.CubeAction = "double_take"
-				} else {
-					resp.CubeAction = "double_pass"
-				}
-			default:
-				resp.CubeAction = "no_double"
-			}
+			resp.CubeAction = cubeAnalysis.DecisionType.Action()
 		}
 	}
 
@@ -480,8 +1122,7 @@ func (h *Handlers) HandleFIBSBoard(w http.ResponseWriter, r *http.Request) {
 // HandleTutorMove analyzes a played move and returns skill analysis.
 func (h *Handlers) HandleTutorMove(w http.ResponseWriter, r *http.Request) {
 	var req TutorMoveRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, "Invalid JSON", "INVALID_JSON")
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
@@ -490,68 +1131,99 @@ func (h *Handlers) HandleTutorMove(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.Move == "" {
-		writeError(w, http.StatusBadRequest, "move is required", "MISSING_MOVE")
+	if req.Move == "" && req.ResultingPosition == "" {
+		writeError(w, http.StatusBadRequest, "move or resulting_position is required", "MISSING_MOVE")
 		return
 	}
-
-	// Parse the game state
-	gs, err := parseGameStateFromTutor(req)
-	if err != nil {
-		writeError(w, http.StatusBadRequest, err.Error(), "INVALID_POSITION")
+	if req.Move != "" && req.ResultingPosition != "" {
+		writeError(w, http.StatusBadRequest, "specify either move or resulting_position, not both", "INVALID_MOVE")
 		return
 	}
 
-	// Parse the move
-	playedMove, err := engine.ParseMove(req.Move)
+	cfg, err := analysisConfig(req.DoubtfulThreshold, req.BadThreshold, req.BlunderThreshold)
 	if err != nil {
-		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid move notation: %v", err), "INVALID_MOVE")
+		writeError(w, http.StatusBadRequest, err.Error(), "INVALID_THRESHOLDS")
 		return
 	}
 
-	// Analyze the move
-	analysis, err := h.engine.AnalyzeMoveSkill(gs, playedMove, req.Dice)
+	// Parse the game state
+	gs, err := parseGameStateFromTutor(req)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error(), "ANALYSIS_ERROR")
+		writeError(w, http.StatusBadRequest, err.Error(), "INVALID_POSITION")
 		return
 	}
 
-	// Build response
+	var analysis *engine.MoveSkillAnalysis
+	if req.ResultingPosition != "" {
+		// Find the played move from the position after the move
+		result, err := parsePositionID(req.ResultingPosition)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid resulting position ID: %v", err), "INVALID_POSITION")
+			return
+		}
+
+		playedMove, err := engine.FindMoveForResult(gs.Board, engine.Board(result), req.Dice)
+		var illegal *engine.IllegalMoveError
+		if errors.As(err, &illegal) {
+			writeIllegalMove(w, illegal)
+			return
+		}
+		if err == nil {
+			analysis, err = h.engine.AnalyzeMoveSkillWithConfig(gs, playedMove, req.Dice, cfg)
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error(), "ANALYSIS_ERROR")
+			return
+		}
+	} else {
+		// Parse the move
+		playedMove, err := engine.ParseMove(req.Move)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid move notation: %v", err), "INVALID_MOVE")
+			return
+		}
+
+		// Analyze the move
+		analysis, err = h.engine.AnalyzeMoveSkillWithConfig(gs, playedMove, req.Dice, cfg)
+		var illegal *engine.MoveError
+		if errors.As(err, &illegal) {
+			writeError(w, http.StatusBadRequest, err.Error(), "ILLEGAL_MOVE")
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error(), "ANALYSIS_ERROR")
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, tutorMoveResponse(gs, analysis))
+}
+
+// tutorMoveResponse builds the response for a move skill analysis.
+func tutorMoveResponse(gs *engine.GameState, analysis *engine.MoveSkillAnalysis) TutorMoveResponse {
 	resp := TutorMoveResponse{
 		Skill:        skillToString(analysis.Skill),
 		SkillAbbr:    analysis.Skill.Abbr(),
 		EquityLoss:   analysis.EquityLoss,
-		BestMove:     formatMove(analysis.BestMove),
+		PlayedMove:   engine.FormatMove(analysis.Move),
+		BestMove:     engine.FormatMove(analysis.BestMove),
 		BestEquity:   analysis.BestEquity,
 		PlayedEquity: analysis.Equity,
 		IsForced:     analysis.IsForced,
-		Suggestion:   generateMoveSuggestion(analysis),
+		Suggestion:   generateMoveSuggestion(gs, analysis),
 	}
 
 	// Add top moves
 	for _, m := range analysis.TopMoves {
-		winProb := 0.0
-		winG := 0.0
-		if m.Eval != nil {
-			winProb = m.Eval.WinProb
-			winG = m.Eval.WinG
-		}
-		resp.TopMoves = append(resp.TopMoves, MoveResponse{
-			Move:   formatMove(m.Move),
-			Equity: m.Equity,
-			Win:    winProb * 100,
-			WinG:   winG * 100,
-		})
+		resp.TopMoves = append(resp.TopMoves, moveResponse(m, m.PositionID))
 	}
-
-	writeJSON(w, http.StatusOK, resp)
+	return resp
 }
 
 // HandleTutorCube analyzes a cube decision and returns skill analysis.
 func (h *Handlers) HandleTutorCube(w http.ResponseWriter, r *http.Request) {
 	var req TutorCubeRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, "Invalid JSON", "INVALID_JSON")
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
@@ -567,6 +1239,16 @@ func (h *Handlers) HandleTutorCube(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	cfg, err := analysisConfig(req.DoubtfulThreshold, req.BadThreshold, req.BlunderThreshold)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error(), "INVALID_THRESHOLDS")
+		return
+	}
+
+	if !h.checkMET(w, req.MET) {
+		return
+	}
+
 	// Parse the game state
 	gs, err := parseGameStateFromCubeTutor(req)
 	if err != nil {
@@ -575,13 +1257,18 @@ func (h *Handlers) HandleTutorCube(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Analyze the cube decision
-	analysis, err := h.engine.AnalyzeCubeSkill(gs, action)
+	analysis, err := h.engine.AnalyzeCubeSkillWithConfig(gs, action, cfg)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error(), "ANALYSIS_ERROR")
 		return
 	}
 
-	resp := TutorCubeResponse{
+	writeJSON(w, http.StatusOK, tutorCubeResponse(analysis))
+}
+
+// tutorCubeResponse builds the tutor's response for a cube action analysis.
+func tutorCubeResponse(analysis *engine.CubeSkillAnalysis) TutorCubeResponse {
+	return TutorCubeResponse{
 		Skill:      skillToString(analysis.Skill),
 		SkillAbbr:  analysis.Skill.Abbr(),
 		EquityLoss: analysis.EquityLoss,
@@ -590,15 +1277,12 @@ func (h *Handlers) HandleTutorCube(w http.ResponseWriter, r *http.Request) {
 		IsClose:    analysis.IsClose,
 		Suggestion: generateCubeSuggestion(analysis),
 	}
-
-	writeJSON(w, http.StatusOK, resp)
 }
 
 // HandleAnalyzeGame analyzes a complete game and returns statistics.
 func (h *Handlers) HandleAnalyzeGame(w http.ResponseWriter, r *http.Request) {
 	var req AnalyzeGameRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, "Invalid JSON", "INVALID_JSON")
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
@@ -607,39 +1291,107 @@ func (h *Handlers) HandleAnalyzeGame(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := exceeds("positions", len(req.Positions), h.limits.MaxPositions); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error(), "TOO_MANY_POSITIONS")
+		return
+	}
+
+	for i, pos := range req.Positions {
+		if pos.Player != 0 && pos.Player != 1 {
+			writeError(w, http.StatusBadRequest,
+				fmt.Sprintf("positions[%d]: player must be 0 or 1", i), "INVALID_PLAYER")
+			return
+		}
+	}
+
+	cfg, err := analysisConfig(req.DoubtfulThreshold, req.BadThreshold, req.BlunderThreshold)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error(), "INVALID_THRESHOLDS")
+		return
+	}
+
+	if !h.checkMET(w, req.MET) {
+		return
+	}
+
+	var states []*engine.GameState
+	if req.Start != nil {
+		var err *fragmentError
+		if states, err = replayFragment(req.Start, req.Positions); err != nil {
+			writeError(w, http.StatusBadRequest, err.msg, err.code)
+			return
+		}
+	} else {
+		states = make([]*engine.GameState, len(req.Positions))
+		for i, pos := range req.Positions {
+			gs, err := parseGameStateFromPosition(pos)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("positions[%d]: %v", i, err), "INVALID_POSITION")
+				return
+			}
+			states[i] = gs
+		}
+	}
+	for _, gs := range states {
+		gs.MET = req.MET
+	}
+
 	resp := GameAnalysisResponse{
 		TotalMoves:  0,
 		MoveErrors:  []MoveError{},
 		CubeErrors:  []CubeError{},
 		Suggestions: []string{},
+		Engine:      h.engine.BuildInfo(),
 	}
+	var rolls []engine.LuckDetail
 
 	// Analyze each position
 	for i, pos := range req.Positions {
-		moveNum := i + 1
-
-		// Build game state
-		gs, err := parseGameStateFromPosition(pos)
-		if err != nil {
-			continue // Skip invalid positions
+		if r.Context().Err() != nil {
+			// The client has gone: leave the rest of the game unanalyzed
+			return
 		}
+		moveNum := i + 1
+		gs := states[i]
 
 		// Analyze move if present
 		if pos.Move != "" && pos.Dice != [2]int{0, 0} {
 			playedMove, err := engine.ParseMove(pos.Move)
 			if err != nil {
-				continue
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("positions[%d]: invalid move notation: %v", i, err), "INVALID_MOVE")
+				return
+			}
+			if err := engine.CheckMove(gs.Board, playedMove, pos.Dice); err != nil {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("positions[%d]: %v", i, err), "ILLEGAL_MOVE")
+				return
+			}
+
+			analysis, err := h.engine.AnalyzeMoveSkillWithConfig(gs, playedMove, pos.Dice, cfg)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, fmt.Sprintf("positions[%d]: %v", i, err), "ANALYSIS_ERROR")
+				return
 			}
 
-			analysis, err := h.engine.AnalyzeMoveSkill(gs, playedMove, pos.Dice)
+			luck, err := h.engine.Luck(gs, pos.Dice)
 			if err != nil {
-				continue
+				writeError(w, http.StatusInternalServerError, fmt.Sprintf("positions[%d]: %v", i, err), "ANALYSIS_ERROR")
+				return
 			}
+			resp.LuckStats[pos.Player] += luck
+			rolls = append(rolls, engine.LuckDetail{
+				MoveNumber: moveNum,
+				Player:     pos.Player,
+				Position:   pos.Position,
+				Dice:       pos.Dice,
+				Luck:       luck,
+				Points:     luck * float64(max(gs.CubeValue, 1)),
+			})
 
 			// Count stats
 			if !analysis.IsForced {
 				resp.Players[pos.Player].TotalMoves++
 				resp.Players[pos.Player].TotalError += analysis.EquityLoss
+				resp.Players[pos.Player].DifficultyStats.Add(analysis.Spread, analysis.EquityLoss, true)
 				resp.TotalMoves++
 
 				switch analysis.Skill {
@@ -653,16 +1405,21 @@ func (h *Handlers) HandleAnalyzeGame(w http.ResponseWriter, r *http.Request) {
 
 				// Record errors
 				if analysis.Skill != engine.SkillNone {
+					category, phase := engine.ClassifyDecision(gs.Board, moveNum, engine.NoDouble)
 					resp.MoveErrors = append(resp.MoveErrors, MoveError{
 						MoveNumber: moveNum,
 						Player:     pos.Player,
 						Position:   pos.Position,
 						Dice:       pos.Dice,
 						Played:     pos.Move,
-						Best:       formatMove(analysis.BestMove),
+						Best:       engine.FormatMove(analysis.BestMove),
 						EquityLoss: analysis.EquityLoss,
 						Skill:      skillToString(analysis.Skill),
+						Category:   engine.CategoryName(category),
+						Phase:      string(phase),
+						Spread:     analysis.Spread,
 					})
+					resp.Players[pos.Player].Weakness.Add(engine.CategoryName(category), phase, false, analysis.EquityLoss)
 				}
 			}
 		}
@@ -671,18 +1428,21 @@ func (h *Handlers) HandleAnalyzeGame(w http.ResponseWriter, r *http.Request) {
 		if pos.CubeAction != "" {
 			action, err := parseCubeAction(pos.CubeAction)
 			if err != nil {
-				continue
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("positions[%d]: %v", i, err), "INVALID_ACTION")
+				return
 			}
 
-			analysis, err := h.engine.AnalyzeCubeSkill(gs, action)
+			analysis, err := h.engine.AnalyzeCubeSkillWithConfig(gs, action, cfg)
 			if err != nil {
-				continue
+				writeError(w, http.StatusInternalServerError, fmt.Sprintf("positions[%d]: %v", i, err), "ANALYSIS_ERROR")
+				return
 			}
 
 			resp.Players[pos.Player].TotalCubeDecisions++
 			resp.Players[pos.Player].TotalError += analysis.EquityLoss
 
 			if analysis.Skill != engine.SkillNone {
+				category, phase := engine.ClassifyDecision(gs.Board, moveNum, action)
 				resp.CubeErrors = append(resp.CubeErrors, CubeError{
 					MoveNumber: moveNum,
 					Player:     pos.Player,
@@ -691,7 +1451,10 @@ func (h *Handlers) HandleAnalyzeGame(w http.ResponseWriter, r *http.Request) {
 					Optimal:    cubeActionToString(analysis.OptimalPlay),
 					EquityLoss: analysis.EquityLoss,
 					Skill:      skillToString(analysis.Skill),
+					Category:   engine.CategoryName(category),
+					Phase:      string(phase),
 				})
+				resp.Players[pos.Player].Weakness.Add(engine.CategoryName(category), phase, true, analysis.EquityLoss)
 			}
 		}
 	}
@@ -702,9 +1465,13 @@ func (h *Handlers) HandleAnalyzeGame(w http.ResponseWriter, r *http.Request) {
 		if totalDecisions > 0 {
 			resp.Players[i].ErrorPerMove = resp.Players[i].TotalError / float64(totalDecisions)
 		}
+		resp.Players[i].DifficultyStats.Finish()
 		resp.Players[i].Rating = engine.GetRating(resp.Players[i].ErrorPerMove).String()
 	}
 
+	lucky, unlucky := engine.Jokers(rolls, engine.DefaultJokers)
+	resp.Luckiest, resp.Unluckiest = luckRolls(lucky), luckRolls(unlucky)
+
 	// Generate suggestions
 	resp.Suggestions = generateGameSuggestions(&resp)
 
@@ -715,9 +1482,41 @@ func (h *Handlers) HandleAnalyzeGame(w http.ResponseWriter, r *http.Request) {
 // Tutor Helper Functions
 // ============================================================================
 
+// luckRolls converts the luck of rolls to API responses.
+func luckRolls(rolls []engine.LuckDetail) []LuckRoll {
+	resp := make([]LuckRoll, len(rolls))
+	for i, r := range rolls {
+		resp[i] = LuckRoll{
+			MoveNumber: r.MoveNumber,
+			Player:     r.Player,
+			Position:   r.Position,
+			Dice:       r.Dice,
+			Luck:       r.Luck,
+			Points:     r.Points,
+		}
+	}
+	return resp
+}
+
+// analysisConfig returns the default analysis thresholds with a request's
+// skill threshold overrides applied; zero leaves a threshold at its default.
+func analysisConfig(doubtful, bad, blunder float64) (engine.AnalysisConfig, error) {
+	cfg := engine.DefaultAnalysisConfig()
+	if doubtful != 0 {
+		cfg.DoubtfulThreshold = doubtful
+	}
+	if bad != 0 {
+		cfg.BadThreshold = bad
+	}
+	if blunder != 0 {
+		cfg.BlunderThreshold = blunder
+	}
+	return cfg, cfg.Validate()
+}
+
 // parseGameStateFromTutor creates a GameState from a TutorMoveRequest.
 func parseGameStateFromTutor(req TutorMoveRequest) (*engine.GameState, error) {
-	board, err := positionid.BoardFromPositionID(req.Position)
+	board, err := parsePositionID(req.Position)
 	if err != nil {
 		return nil, fmt.Errorf("invalid position ID: %w", err)
 	}
@@ -743,7 +1542,7 @@ func parseGameStateFromTutor(req TutorMoveRequest) (*engine.GameState, error) {
 
 // parseGameStateFromCubeTutor creates a GameState from a TutorCubeRequest.
 func parseGameStateFromCubeTutor(req TutorCubeRequest) (*engine.GameState, error) {
-	board, err := positionid.BoardFromPositionID(req.Position)
+	board, err := parsePositionID(req.Position)
 	if err != nil {
 		return nil, fmt.Errorf("invalid position ID: %w", err)
 	}
@@ -756,6 +1555,7 @@ func parseGameStateFromCubeTutor(req TutorCubeRequest) (*engine.GameState, error
 		MatchLength: req.MatchLength,
 		Score:       req.Score,
 		Crawford:    req.Crawford,
+		MET:         req.MET,
 	}
 
 	if req.CubeValue > 0 {
@@ -768,7 +1568,7 @@ func parseGameStateFromCubeTutor(req TutorCubeRequest) (*engine.GameState, error
 
 // parseGameStateFromPosition creates a GameState from a GamePosition.
 func parseGameStateFromPosition(pos GamePosition) (*engine.GameState, error) {
-	board, err := positionid.BoardFromPositionID(pos.Position)
+	board, err := parsePositionID(pos.Position)
 	if err != nil {
 		return nil, fmt.Errorf("invalid position ID: %w", err)
 	}
@@ -792,6 +1592,87 @@ func parseGameStateFromPosition(pos GamePosition) (*engine.GameState, error) {
 	return gs, nil
 }
 
+// fragmentError is a request error found while replaying a game fragment.
+type fragmentError struct {
+	msg  string
+	code string
+}
+
+// replayFragment reconstructs the positions of a game fragment from its start
+// and the actions in positions, filling in each position's ID, cube and score.
+// Every entry must be a move (dice, and the move unless no move is legal) or
+// a cube action. It returns the state each action is analyzed in.
+func replayFragment(start *GameStart, positions []GamePosition) ([]*engine.GameState, *fragmentError) {
+	board, err := parsePositionID(start.Position)
+	if err != nil {
+		return nil, &fragmentError{fmt.Sprintf("start: invalid position ID: %v", err), "INVALID_POSITION"}
+	}
+
+	game := engine.MatchActions{
+		Actions:     make([]engine.MatchAction, len(positions)),
+		StartBoards: map[int]engine.Board{1: engine.Board(board)},
+	}
+	if start.CubeValue > 1 {
+		game.StartCubes = map[int]engine.GameCube{1: {Value: start.CubeValue, Owner: start.CubeOwner}}
+	}
+
+	for i, pos := range positions {
+		action := engine.MatchAction{GameNumber: 1, MoveNumber: i + 1, Player: pos.Player}
+		switch {
+		case pos.Dice != [2]int{0, 0} && pos.CubeAction == "":
+			move := engine.Move{From: [4]int8{-1, -1, -1, -1}, To: [4]int8{-1, -1, -1, -1}}
+			if pos.Move != "" {
+				if move, err = engine.ParseMove(pos.Move); err != nil {
+					return nil, &fragmentError{fmt.Sprintf("positions[%d]: invalid move notation: %v", i, err), "INVALID_MOVE"}
+				}
+			}
+			action.Dice = pos.Dice
+			action.Move = &move
+		case pos.CubeAction != "" && pos.Dice == [2]int{0, 0}:
+			if action.CubeAction, err = parseCubeAction(pos.CubeAction); err != nil {
+				return nil, &fragmentError{fmt.Sprintf("positions[%d]: %v", i, err), "INVALID_ACTION"}
+			}
+		default:
+			return nil, &fragmentError{fmt.Sprintf("positions[%d]: give either dice and a move or a cube action", i), "INVALID_ACTION"}
+		}
+		game.Actions[i] = action
+	}
+
+	replayed := engine.ConvertMatchActionsToPositions(game, engine.Board(board), start.Score, start.MatchLength)
+	states := make([]*engine.GameState, len(replayed))
+	for i, ap := range replayed {
+		pos := &positions[i]
+		if ap.Move != nil {
+			if err := engine.CheckMove(ap.Board, *ap.Move, ap.Dice); err != nil {
+				return nil, &fragmentError{fmt.Sprintf("positions[%d]: %v", i, err), "ILLEGAL_MOVE"}
+			}
+		}
+
+		id := engine.EncodePositionID(ap.Board)
+		if pos.Position != "" && pos.Position != id {
+			return nil, &fragmentError{fmt.Sprintf("positions[%d]: position %s does not follow from the previous moves (expected %s)", i, pos.Position, id), "INVALID_POSITION"}
+		}
+		pos.Position = id
+		pos.MatchLength = ap.MatchLength
+		pos.Score = ap.Score
+		pos.CubeValue = ap.CubeValue
+		pos.CubeOwner = ap.CubeOwner
+		pos.Crawford = start.Crawford
+
+		states[i] = &engine.GameState{
+			Board:       ap.Board,
+			Turn:        ap.Turn,
+			CubeValue:   ap.CubeValue,
+			CubeOwner:   ap.CubeOwner,
+			MatchLength: ap.MatchLength,
+			Score:       ap.Score,
+			Crawford:    start.Crawford,
+			Dice:        ap.Dice,
+		}
+	}
+	return states, nil
+}
+
 // parseCubeAction parses a cube action string.
 func parseCubeAction(action string) (engine.CubeAction, error) {
 	switch strings.ToLower(action) {
@@ -801,6 +1682,10 @@ func parseCubeAction(action string) (engine.CubeAction, error) {
 		return engine.Take, nil
 	case "pass":
 		return engine.Pass, nil
+	case "beaver":
+		return engine.Beaver, nil
+	case "raccoon":
+		return engine.Raccoon, nil
 	case "no_double", "nodouble":
 		return engine.NoDouble, nil
 	default:
@@ -831,32 +1716,45 @@ func cubeActionToString(action engine.CubeAction) string {
 		return "take"
 	case engine.Pass:
 		return "pass"
+	case engine.Beaver:
+		return "beaver"
+	case engine.Raccoon:
+		return "raccoon"
 	case engine.NoDouble:
 		return "no_double"
+	case engine.TooGood:
+		return "too_good"
 	default:
 		return "unknown"
 	}
 }
 
 // generateMoveSuggestion generates an improvement suggestion for a move error.
-func generateMoveSuggestion(analysis *engine.MoveSkillAnalysis) string {
+// It names the best move and says how it differs from the one played.
+func generateMoveSuggestion(gs *engine.GameState, analysis *engine.MoveSkillAnalysis) string {
 	if analysis.Skill == engine.SkillNone || analysis.IsForced {
 		return ""
 	}
 
+	var msg string
 	switch analysis.Skill {
 	case engine.SkillVeryBad:
-		return fmt.Sprintf("This was a blunder losing %.3f equity. The best move was %s.",
-			analysis.EquityLoss, formatMove(analysis.BestMove))
+		msg = fmt.Sprintf("This was a blunder losing %.3f equity. The best move was %s.",
+			analysis.EquityLoss, engine.FormatMove(analysis.BestMove))
 	case engine.SkillBad:
-		return fmt.Sprintf("This was an error losing %.3f equity. Consider %s instead.",
-			analysis.EquityLoss, formatMove(analysis.BestMove))
+		msg = fmt.Sprintf("This was an error losing %.3f equity. Consider %s instead.",
+			analysis.EquityLoss, engine.FormatMove(analysis.BestMove))
 	case engine.SkillDoubtful:
-		return fmt.Sprintf("This move is questionable (%.3f equity loss). %s was slightly better.",
-			analysis.EquityLoss, formatMove(analysis.BestMove))
+		msg = fmt.Sprintf("This move is questionable (%.3f equity loss). %s was slightly better.",
+			analysis.EquityLoss, engine.FormatMove(analysis.BestMove))
 	default:
 		return ""
 	}
+
+	if diff := engine.DescribeMoveDifference(gs, analysis.Move, analysis.BestMove); diff != "" {
+		msg += " " + diff
+	}
+	return msg
 }
 
 // generateCubeSuggestion generates an improvement suggestion for a cube error.
@@ -868,22 +1766,93 @@ func generateCubeSuggestion(analysis *engine.CubeSkillAnalysis) string {
 	optimalStr := cubeActionToString(analysis.OptimalPlay)
 	actualStr := cubeActionToString(analysis.ActualPlay)
 
+	var suggestion string
 	switch analysis.Skill {
 	case engine.SkillVeryBad:
-		return fmt.Sprintf("This was a cube blunder losing %.3f equity. You should have chosen %s instead of %s.",
+		suggestion = fmt.Sprintf("This was a cube blunder losing %.3f equity. You should have chosen %s instead of %s.",
 			analysis.EquityLoss, optimalStr, actualStr)
 	case engine.SkillBad:
-		return fmt.Sprintf("This was a cube error losing %.3f equity. %s was correct.",
+		suggestion = fmt.Sprintf("This was a cube error losing %.3f equity. %s was correct.",
 			analysis.EquityLoss, optimalStr)
 	case engine.SkillDoubtful:
-		return fmt.Sprintf("This cube decision is questionable (%.3f equity loss). %s was slightly better.",
+		suggestion = fmt.Sprintf("This cube decision is questionable (%.3f equity loss). %s was slightly better.",
 			analysis.EquityLoss, optimalStr)
 	default:
 		return ""
 	}
+
+	// Too good to double: the double cashed a game worth playing on for
+	if analysis.OptimalPlay == engine.TooGood {
+		suggestion += " The position is too good to double: playing on for the gammon is worth more than cashing."
+	}
+
+	// Against a blitz, how often the opponent of the doubler dances speaks
+	// louder than the equities
+	for _, entry := range analysis.Entry {
+		if entry.Opponent && entry.PointsMade >= engine.BlitzEntryPoints {
+			switch analysis.ActualPlay {
+			case engine.Take, engine.Pass, engine.Beaver:
+				suggestion += " " + entrySentence(entry, "You", true)
+			default:
+				suggestion += " " + entrySentence(entry, "Your opponent", false)
+			}
+		}
+	}
+
+	// In a race, show where it stands against the double and take points;
+	// at a match score, spell out the match winning chances at stake
+	if analysis.Race != nil {
+		suggestion += " " + RaceCubeText(analysis.Race)
+	} else if analysis.Analysis != nil && analysis.Analysis.MatchContext != nil {
+		mc := analysis.Analysis.MatchContext
+		switch analysis.ActualPlay {
+		case engine.Take, engine.Pass, engine.Beaver:
+			suggestion += fmt.Sprintf(" By taking you risk %.1f%% MWC to gain %.1f%%; the take point is %.1f%%.",
+				mc.TakeRisk*100, mc.TakeGain*100, mc.TakePoint*100)
+		default:
+			suggestion += fmt.Sprintf(" By doubling you risk %.1f%% MWC to gain %.1f%%.",
+				mc.DoubleRisk*100, mc.DoubleGain*100)
+		}
+	} else if a := analysis.Analysis; a != nil && len(a.Points) > 0 {
+		// In money play, set the winning chances against the gammon-adjusted
+		// threshold the decision turned on
+		p := a.Eval.WinProb
+		switch analysis.ActualPlay {
+		case engine.Take, engine.Pass, engine.Beaver:
+			suggestion += fmt.Sprintf(" You had %.1f%% winning chances; with the gammons in the position the take point is %.1f%%.",
+				(1-p)*100, a.TakePoint*100)
+		default:
+			suggestion += fmt.Sprintf(" You had %.1f%% winning chances; with the gammons in the position the double point is %.1f%% and the cash point %.1f%%.",
+				p*100, a.DoublePoint*100, (1-a.TakePoint)*100)
+		}
+	}
+	return suggestion
+}
+
+// categoryAdvice is what to study for the errors of each kind of position,
+// by engine.CategoryName.
+var categoryAdvice = map[string]string{
+	"contact":     "Work on contact play: safe plays, when to hit and which points to make.",
+	"blitz":       "Study blitzes: when to attack with loose hits and when to close points against checkers on the bar.",
+	"holding":     "Study holding games: keeping the anchor, timing and the shots it gets.",
+	"backgame":    "Study backgames: timing and which anchors to hold.",
+	"priming":     "Study priming battles: extending the prime and rolling it forward.",
+	"race":        "Count the race, and practise bearing in and racing cube decisions.",
+	"bearoff":     "Practise bearoff technique: clearing points from the back and leaving no shots.",
+	"safety_play": "Work on when to play safe and when to take risks.",
 }
 
-// generateGameSuggestions generates overall improvement suggestions for a game.
+// phaseNames are the game phases as suggestions name them.
+var phaseNames = map[string]string{
+	string(engine.PhaseOpening): "the opening",
+	string(engine.PhaseMiddle):  "the middle game",
+	string(engine.PhaseBearIn):  "the bear-in",
+	string(engine.PhaseBearOff): "the bearoff",
+}
+
+// generateGameSuggestions generates overall improvement suggestions for a
+// game, pointing each player at the kind of position their errors cost the
+// most in.
 func generateGameSuggestions(resp *GameAnalysisResponse) []string {
 	var suggestions []string
 
@@ -896,22 +1865,30 @@ func generateGameSuggestions(resp *GameAnalysisResponse) []string {
 				fmt.Sprintf("%s had %d blunder(s). Review these positions carefully.", playerName, player.Blunders))
 		}
 
-		if player.ErrorPerMove > 0.02 {
-			suggestions = append(suggestions,
-				fmt.Sprintf("%s's error rate (%.3f) is high. Focus on checker play fundamentals.", playerName, player.ErrorPerMove))
+		profile := player.Weakness
+		worst, ok := profile.Worst()
+		if !ok {
+			continue
+		}
+		suggestion := fmt.Sprintf("%s lost the most equity in %s positions (%.3f over %d error(s))",
+			playerName, strings.ReplaceAll(worst.Name, "_", " "), worst.EquityLoss, worst.Errors)
+		if len(profile.Phases) > 0 {
+			suggestion += " and in " + phaseNames[profile.Phases[0].Name]
+		}
+		suggestion += "."
+		if advice, ok := categoryAdvice[worst.Name]; ok {
+			suggestion += " " + advice
 		}
+		suggestions = append(suggestions, suggestion)
 
-		if player.TotalCubeDecisions > 0 {
-			cubeErrors := 0
-			for _, ce := range resp.CubeErrors {
-				if ce.Player == i {
-					cubeErrors++
-				}
-			}
-			if cubeErrors > 0 {
-				suggestions = append(suggestions,
-					fmt.Sprintf("%s made %d cube error(s). Study cube theory and match equity.", playerName, cubeErrors))
+		if d := profile.Decisions; len(d) > 0 && d[0].Name == engine.DecisionCube {
+			checker := 0.0
+			if len(d) > 1 {
+				checker = d[1].EquityLoss
 			}
+			suggestions = append(suggestions,
+				fmt.Sprintf("%s's cube decisions cost more than their checker play (%.3f against %.3f). Study cube theory and match equity.",
+					playerName, d[0].EquityLoss, checker))
 		}
 	}
 
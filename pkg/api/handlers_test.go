@@ -2,15 +2,23 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"slices"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/yourusername/bgengine/internal/positionid"
 	"github.com/yourusername/bgengine/pkg/engine"
 )
 
@@ -63,8 +71,96 @@ func TestHealthHandlerReady(t *testing.T) {
 	if !health.Ready {
 		t.Error("Expected ready = true when engine is set")
 	}
+	if health.MET == nil || health.MET.Name != "Default MET" || health.MET.Length != 11 {
+		t.Errorf("MET = %+v, want the default table", health.MET)
+	}
+
+	if health.Kernel != eng.Kernel() || health.Kernel == "" {
+		t.Errorf("Kernel = %q, want the engine's, %q", health.Kernel, eng.Kernel())
+	}
+	if health.Engine == nil || health.Engine.Version != engine.Version {
+		t.Errorf("Engine = %+v, want version %q", health.Engine, engine.Version)
+	}
+
+	// An engine that has not been warmed up is not ready yet
+	cold, err := engine.NewEngine(engine.EngineOptions{SkipWarmup: true, ForceScalar: true})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	h = NewHandlers(cold, "1.0.0")
+	w = httptest.NewRecorder()
+	h.Health(w, req)
+	json.NewDecoder(w.Result().Body).Decode(&health)
+	if health.Ready {
+		t.Error("ready = true before warm-up")
+	}
+	if health.Kernel != "go" {
+		t.Errorf("Kernel = %q with ForceScalar, want the pure Go kernel", health.Kernel)
+	}
+
+	cold.Warmup()
+	w = httptest.NewRecorder()
+	h.Health(w, req)
+	json.NewDecoder(w.Result().Body).Decode(&health)
+	if !health.Ready {
+		t.Error("ready = false after warm-up")
+	}
+	if health.Disabled != nil {
+		t.Errorf("disabled = %v, want none", health.Disabled)
+	}
+
+	// Evaluators switched off for comparisons are reported
+	cold.SetRouting(engine.Routing{DisableCrashedNet: true})
+	health = HealthResponse{}
+	w = httptest.NewRecorder()
+	h.Health(w, req)
+	json.NewDecoder(w.Result().Body).Decode(&health)
+	if len(health.Disabled) != 1 || health.Disabled[0] != "crashed_net" {
+		t.Errorf("disabled = %v, want [crashed_net]", health.Disabled)
+	}
 }
 
+func TestSelfCheckHandler(t *testing.T) {
+	get := func(handler http.Handler) (int, SelfCheckResponse) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/health/selfcheck", nil))
+		var resp SelfCheckResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("Decode error: %v", err)
+		}
+		return w.Code, resp
+	}
+
+	// Without a check at startup, the first request runs it: the test
+	// engine has no weights, so the net sentinels fail
+	h := NewHandlers(getTestEngine(), "test")
+	code, first := get(http.HandlerFunc(h.SelfCheck))
+	if code != http.StatusServiceUnavailable || first.Status != "fail" {
+		t.Errorf("self-check without weights = %d %q, want 503 fail", code, first.Status)
+	}
+	if len(first.Sentinels) == 0 || first.Sentinels[0].Name != "contact" || first.Sentinels[0].Status != engine.SelfCheckFail {
+		t.Errorf("sentinels = %+v, want the contact sentinel failed first", first.Sentinels)
+	}
+	report := h.selfCheck.report
+	get(http.HandlerFunc(h.SelfCheck))
+	if h.selfCheck.report != report {
+		t.Error("the second request ran the check again")
+	}
+
+	// The check run at startup is reported as it is
+	startup := &engine.SelfCheckReport{
+		Pass:      true,
+		CheckedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Sentinels: []engine.SelfCheckSentinel{{Name: "contact", Status: engine.SelfCheckPass}},
+	}
+	config := DefaultConfig()
+	config.SelfCheck = startup
+	code, resp := get(NewServer(getTestEngine(), config, "test").Handler())
+	if code != http.StatusOK || resp.Status != "pass" || resp.CheckedAt != "2024-01-02T03:04:05Z" || len(resp.Sentinels) != 1 {
+		t.Errorf("self-check run at startup = %d %+v, want it reported", code, resp)
+	}
+}
 func TestEvaluateHandler(t *testing.T) {
 	eng := getTestEngine()
 	h := NewHandlers(eng, "1.0.0")
@@ -77,7 +173,7 @@ func TestEvaluateHandler(t *testing.T) {
 	}{
 		{
 			name:       "valid position",
-			body:       EvaluateRequest{Position: "4HPwATDgc/ABMA", Ply: 0},
+			body:       EvaluateRequest{Position: "4HPwATDgc/ABMA", Ply: intPtr(0)},
 			wantStatus: http.StatusOK,
 		},
 		{
@@ -133,6 +229,275 @@ func TestEvaluateHandler(t *testing.T) {
 	}
 }
 
+// TestEquitiesConsistent checks that the evaluate, cube and move endpoints
+// report the same cubeful equity and MWC for the same state: evaluate's
+// cubeful equity is the cube's no double equity, and each move's is the
+// negation of evaluate's for the opponent on roll after it.
+func TestEquitiesConsistent(t *testing.T) {
+	h := NewHandlers(getTestEngine(), "1.0.0")
+	post := func(handler http.HandlerFunc, path string, req, resp interface{}) {
+		t.Helper()
+		body, _ := json.Marshal(req)
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest("POST", path, bytes.NewReader(body)))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status %d: %s", path, w.Code, w.Body)
+		}
+		if err := json.NewDecoder(w.Body).Decode(resp); err != nil {
+			t.Fatalf("%s: decode: %v", path, err)
+		}
+	}
+	// Evaluations come back from the cache in single precision
+	near := func(a, b float64) bool { return math.Abs(a-b) < 1e-4 }
+
+	for _, tc := range []struct {
+		name        string
+		matchLength int
+		score       [2]int
+	}{
+		{"money", 0, [2]int{}},
+		{"match", 7, [2]int{3, 2}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			const pos = "4HPwATDgc/ABMA"
+			var eval EvaluateResponse
+			post(h.Evaluate, "/api/evaluate", EvaluateRequest{Position: pos, MatchLength: tc.matchLength, Score: tc.score, CubeOwner: -1, Ply: intPtr(0)}, &eval)
+			var cube CubeResponse
+			post(h.Cube, "/api/cube", CubeRequest{Position: pos, MatchLength: tc.matchLength, Score: tc.score, CubeOwner: -1}, &cube)
+			if !near(eval.CubefulEquity, cube.NoDoubleEquity) {
+				t.Errorf("evaluate cubeful_equity %.6f, cube no_double_equity %.6f", eval.CubefulEquity, cube.NoDoubleEquity)
+			}
+			if (tc.matchLength > 0) != (eval.MWC > 0) {
+				t.Errorf("evaluate mwc %.3f in a match of %d", eval.MWC, tc.matchLength)
+			}
+
+			var moves MovesResponse
+			zero := 0
+			post(h.Move, "/api/move", MoveRequest{Position: pos, Dice: [2]int{6, 4}, MatchLength: tc.matchLength, Score: tc.score, CubeOwner: -1, NumMoves: 3, Ply: &zero}, &moves)
+			for _, m := range moves.Moves {
+				var after EvaluateResponse
+				post(h.Evaluate, "/api/evaluate", EvaluateRequest{Position: m.PositionID, MatchLength: tc.matchLength, Score: [2]int{tc.score[1], tc.score[0]}, CubeOwner: -1, Ply: intPtr(0)}, &after)
+				if !near(m.CubefulEquity, -after.CubefulEquity) {
+					t.Errorf("%s: move cubeful_equity %.6f, evaluate after it %.6f", m.Move, m.CubefulEquity, after.CubefulEquity)
+				}
+				if tc.matchLength > 0 && !near(m.MWC, 100-after.MWC) {
+					t.Errorf("%s: move mwc %.3f, evaluate after it %.3f", m.Move, m.MWC, after.MWC)
+				}
+				if !near(m.Equity, -after.Equity) {
+					t.Errorf("%s: move equity %.6f, evaluate after it %.6f", m.Move, m.Equity, after.Equity)
+				}
+			}
+		})
+	}
+}
+
+// TestMovePositionIDs checks that each move's position ID decodes to the
+// board after the move with the opponent on roll.
+func TestMovePositionIDs(t *testing.T) {
+	h := NewHandlers(getTestEngine(), "1.0.0")
+
+	body, _ := json.Marshal(MoveRequest{Position: "4HPwATDgc/ABMA", Dice: [2]int{6, 4}, NumMoves: 100})
+	w := httptest.NewRecorder()
+	h.Move(w, httptest.NewRequest("POST", "/api/move", bytes.NewReader(body)))
+
+	var resp MovesResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Decode error: %v", err)
+	}
+
+	start, _ := positionid.BoardFromPositionID("4HPwATDgc/ABMA")
+	results := make(map[string]positionid.Board)
+	for _, m := range engine.GenerateMoves(engine.Board(start), 6, 4).Moves {
+		results[engine.FormatMove(m)] = positionid.SwapSides(positionid.Board(engine.ApplyMove(engine.Board(start), m)))
+	}
+
+	if len(resp.Moves) != resp.NumLegal {
+		t.Fatalf("got %d moves, want all %d", len(resp.Moves), resp.NumLegal)
+	}
+	for _, m := range resp.Moves {
+		board, err := positionid.BoardFromPositionID(m.PositionID)
+		if err != nil {
+			t.Fatalf("%s: BoardFromPositionID(%q): %v", m.Move, m.PositionID, err)
+		}
+		if want, ok := results[m.Move]; !ok || board != want {
+			t.Errorf("%s: position %q decodes to %v, want %v", m.Move, m.PositionID, board, want)
+		}
+		if m.Move == "8/2 6/2" && strings.Join(m.Tags, ",") != "point" {
+			t.Errorf("%s: tags %v, want [point]", m.Move, m.Tags)
+		}
+	}
+}
+
+func TestMoveProbabilities(t *testing.T) {
+	h := NewHandlers(getTestEngine(), "1.0.0")
+
+	for _, dice := range [][2]int{{6, 4}, {5, 5}, {2, 1}} {
+		pos := fmt.Sprintf("%d-%d", dice[0], dice[1])
+		body, _ := json.Marshal(MoveRequest{Position: "4HPwATDgc/ABMA", Dice: dice, NumMoves: 100})
+		w := httptest.NewRecorder()
+		h.Move(w, httptest.NewRequest("POST", "/api/move", bytes.NewReader(body)))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d: %s", pos, w.Code, w.Body.String())
+		}
+		var resp MovesResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("Decode error: %v", err)
+		}
+
+		const eps = 1e-9
+		for _, m := range resp.Moves {
+			if m.Win <= 0 || m.Win >= 100 || m.PositionClass == "" {
+				t.Errorf("%s %s: win %.4f, position class %q", pos, m.Move, m.Win, m.PositionClass)
+			}
+			if m.WinBG < 0 || m.WinBG > m.WinG+eps || m.WinG > m.Win+eps {
+				t.Errorf("%s %s: win %.4f, win_g %.4f, win_bg %.4f out of order", pos, m.Move, m.Win, m.WinG, m.WinBG)
+			}
+			if m.LoseBG < 0 || m.LoseBG > m.LoseG+eps || m.LoseG > 100-m.Win+eps {
+				t.Errorf("%s %s: lose %.4f, lose_g %.4f, lose_bg %.4f out of order", pos, m.Move, 100-m.Win, m.LoseG, m.LoseBG)
+			}
+			// The cubeless money equity the probabilities give
+			want := (2*m.Win - 100 + m.WinG - m.LoseG + m.WinBG - m.LoseBG) / 100
+			if math.Abs(m.CubelessEquity-want) > 1e-4 {
+				t.Errorf("%s %s: cubeless_equity %.6f, probabilities give %.6f", pos, m.Move, m.CubelessEquity, want)
+			}
+		}
+	}
+}
+
+func TestMoveConsensus(t *testing.T) {
+	handler := NewServer(getTestEngine(), DefaultConfig(), "test").Handler()
+	post := func(h http.Handler, req MoveRequest) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(req)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("POST", "/api/move", bytes.NewReader(body)))
+		return w
+	}
+
+	w := post(handler, MoveRequest{Position: "4HPwATDgc/ABMA", Dice: [2]int{3, 1}, Consensus: true})
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+	var resp MovesResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Decode error: %v", err)
+	}
+	c := resp.Consensus
+	if c == nil || len(c.Checks) != 2 || c.BestMove == "" {
+		t.Fatalf("consensus = %+v, want the ply and cube checks", c)
+	}
+	levels := map[string]int{"unanimous": 0, "minor_disagreement": 1, "conflict": 2}
+	worst := 0
+	for _, check := range c.Checks {
+		level, ok := levels[check.Level]
+		if !ok || len(check.Sources) != 2 || check.Message == "" {
+			t.Errorf("check %+v", check)
+		}
+		worst = max(worst, level)
+	}
+	if levels[c.Level] != worst || (c.Recommendation == "") != (worst == 0) {
+		t.Errorf("level %q, recommendation %q, checks %+v", c.Level, c.Recommendation, c.Checks)
+	}
+
+	// Only when asked for
+	w = post(handler, MoveRequest{Position: "4HPwATDgc/ABMA", Dice: [2]int{3, 1}})
+	resp = MovesResponse{}
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Consensus != nil {
+		t.Errorf("consensus without asking: %+v", resp.Consensus)
+	}
+
+	// Not from a server that serves no lookahead
+	config := DefaultConfig()
+	config.MaxPly = 0
+	w = post(NewServer(getTestEngine(), config, "test").Handler(), MoveRequest{Position: "4HPwATDgc/ABMA", Dice: [2]int{3, 1}, Consensus: true})
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "INVALID_PLY") {
+		t.Errorf("consensus on a 0-ply server: status %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestSnowieFormatRequests(t *testing.T) {
+	// Player 1 on roll with 3-1 at 2-5 to 7, owning a 2-cube
+	const text = "7;0;0;0;1;A;B;0;2;5;2;1;0;-2;0;0;0;0;5;0;3;0;0;0;-5;5;0;0;0;-3;0;-5;0;0;0;0;2;0;3;1;"
+
+	req := EvaluateRequest{Position: text, Format: "snowie", Score: [2]int{1, 1}}
+	if err := applyPositionFormat(&req); err != nil {
+		t.Fatalf("applyPositionFormat failed: %v", err)
+	}
+	want := EvaluateRequest{Position: "4HPwATDgc/ABMA", Format: "snowie", MatchLength: 7, Score: [2]int{5, 2}, CubeValue: 2, CubeOwner: 0}
+	if req != want {
+		t.Errorf("converted request = %+v, want %+v", req, want)
+	}
+
+	h := NewHandlers(getTestEngine(), "1.0.0")
+	tests := []struct {
+		name       string
+		body       MoveRequest
+		wantStatus int
+		wantDice   [2]int
+	}{
+		{"dice from text", MoveRequest{Position: text, Format: "snowie"}, http.StatusOK, [2]int{3, 1}},
+		{"dice from request", MoveRequest{Position: text, Format: "snowie", Dice: [2]int{6, 5}}, http.StatusOK, [2]int{6, 5}},
+		{"position ID as snowie", MoveRequest{Position: "4HPwATDgc/ABMA", Format: "snowie", Dice: [2]int{3, 1}}, http.StatusBadRequest, [2]int{}},
+		{"unknown format", MoveRequest{Position: text, Format: "xgid", Dice: [2]int{3, 1}}, http.StatusBadRequest, [2]int{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(tt.body)
+			w := httptest.NewRecorder()
+			h.Move(w, httptest.NewRequest("POST", "/api/move", bytes.NewReader(body)))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var resp MovesResponse
+			json.NewDecoder(w.Body).Decode(&resp)
+			if resp.Position != "4HPwATDgc/ABMA" || resp.Dice != tt.wantDice {
+				t.Errorf("response position %q dice %v, want 4HPwATDgc/ABMA %v", resp.Position, resp.Dice, tt.wantDice)
+			}
+		})
+	}
+}
+
+func TestMoveTimeLimit(t *testing.T) {
+	h := NewHandlers(getTestEngine(), "1.0.0")
+
+	body, _ := json.Marshal(MoveRequest{Position: "4HPwATDgc/ABMA", Dice: [2]int{3, 1}, Ply: intPtr(1), TimeLimitMs: 5000})
+	w := httptest.NewRecorder()
+	h.Move(w, httptest.NewRequest("POST", "/api/move", bytes.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp MovesResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Decode error: %v", err)
+	}
+	if len(resp.Moves) == 0 {
+		t.Fatal("no moves")
+	}
+	for _, m := range resp.Moves {
+		if m.Ply != 1 {
+			t.Errorf("%s evaluated at ply %d, want 1", m.Move, m.Ply)
+		}
+	}
+
+	for _, req := range []MoveRequest{
+		{Position: "4HPwATDgc/ABMA", Dice: [2]int{3, 1}, TimeLimitMs: 20000},
+		{Position: "4HPwATDgc/ABMA", Dice: [2]int{3, 1}, TimeLimitMs: -1},
+		{Position: "4HPwATDgc/ABMA", Dice: [2]int{3, 1}, TimeLimitMs: 100, Ply: intPtr(MaxPly + 1)},
+	} {
+		body, _ := json.Marshal(req)
+		w := httptest.NewRecorder()
+		h.Move(w, httptest.NewRequest("POST", "/api/move", bytes.NewReader(body)))
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "INVALID_TIME_LIMIT") {
+			t.Errorf("time_limit_ms %d: status %d %s, want INVALID_TIME_LIMIT",
+				req.TimeLimitMs, w.Code, w.Body.String())
+		}
+	}
+}
+
 func TestMoveHandler(t *testing.T) {
 	eng := getTestEngine()
 	h := NewHandlers(eng, "1.0.0")
@@ -229,6 +594,16 @@ func TestCubeHandler(t *testing.T) {
 			body:       CubeRequest{Position: "4HPwATDgc/ABMA", MatchLength: 7, Score: [2]int{3, 2}},
 			wantStatus: http.StatusOK,
 		},
+		{
+			name:       "gnubg ID with match ID",
+			body:       CubeRequest{Position: "4HPwATDgc/ABMA:cAkAAAAAAAAA"},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "trailing garbage",
+			body:       CubeRequest{Position: "4HPwATDgc/ABMAGARBAGE"},
+			wantStatus: http.StatusBadRequest,
+		},
 	}
 
 	for _, tc := range tests {
@@ -250,16 +625,466 @@ func TestCubeHandler(t *testing.T) {
 				if err := json.NewDecoder(resp.Body).Decode(&cubeResp); err != nil {
 					t.Fatalf("Decode error: %v", err)
 				}
-				// Action should be one of: no_double, double_take, double_pass, take, pass
 				validActions := map[string]bool{
-					"no_double": true, "double_take": true, "double_pass": true,
-					"take": true, "pass": true, "redouble_take": true, "redouble_pass": true,
+					engine.CubeNoDouble: true, engine.CubeDoubleTake: true, engine.CubeDoublePass: true,
+					engine.CubeTooGoodTake: true, engine.CubeTooGoodPass: true,
+					engine.CubeRedoubleTake: true, engine.CubeRedoublePass: true,
+					engine.CubeOptionalDoubleTake: true, engine.CubeNotAvailable: true, engine.CubeDeadCube: true,
 				}
 				if !validActions[cubeResp.Action] {
 					t.Errorf("Invalid action: %s", cubeResp.Action)
 				}
 			}
-		})
+		})
+	}
+}
+
+// TestCubeVerdict checks that the cube handlers report the decision the
+// engine found, over HTTP and over a WebSocket.
+func TestCubeVerdict(t *testing.T) {
+	h := NewHandlers(getTestEngine(), "1.0.0")
+
+	server := httptest.NewServer(http.HandlerFunc(h.WebSocket))
+	defer server.Close()
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("WebSocket dial failed: %v", err)
+	}
+	defer ws.Close()
+
+	var race positionid.Board
+	race[1][0], race[1][1], race[1][2] = 5, 5, 5
+	race[0][0], race[0][1], race[0][2], race[0][3], race[0][4] = 3, 3, 3, 3, 3
+	racePos := positionid.PositionID(race)
+
+	requests := map[string]CubeRequest{
+		"centered":      {Position: "4HPwATDgc/ABMA", CubeOwner: -1},
+		"owned":         {Position: "4HPwATDgc/ABMA"},
+		"opponent's":    {Position: "4HPwATDgc/ABMA", CubeOwner: 1, CubeValue: 2},
+		"race":          {Position: racePos, CubeOwner: -1},
+		"race redoub":   {Position: racePos, CubeValue: 2},
+		"crawford":      {Position: "4HPwATDgc/ABMA", CubeOwner: -1, MatchLength: 5, Score: [2]int{3, 4}, Crawford: true},
+		"post-crawford": {Position: racePos, CubeOwner: -1, MatchLength: 5, Score: [2]int{4, 2}},
+		"match":         {Position: racePos, CubeOwner: -1, MatchLength: 7, Score: [2]int{2, 3}},
+	}
+	seen := make(map[string]bool)
+	for name, req := range requests {
+		req := req
+		gs, err := parseGameState(req.Position, &req)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		analysis, err := h.engine.AnalyzeCube(gs)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		want := analysis.DecisionType
+		seen[want.Action()] = true
+
+		payload, _ := json.Marshal(req)
+		w := httptest.NewRecorder()
+		h.Cube(w, httptest.NewRequest("POST", "/api/cube", bytes.NewReader(payload)))
+		var got CubeResponse
+		if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+			t.Fatalf("%s: decode error: %v", name, err)
+		}
+		if got.Action != want.Action() || got.DecisionType != want.String() || got.Verdict != want.Verdict() {
+			t.Errorf("%s: /api/cube gives %q, %q, %q; want %q, %q, %q", name,
+				got.Action, got.DecisionType, got.Verdict, want.Action(), want.String(), want.Verdict())
+		}
+
+		if err := ws.WriteJSON(WSMessage{Type: "cube", ID: name, Payload: payload}); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		ws.SetReadDeadline(time.Now().Add(2 * time.Second))
+		var resp struct {
+			Type    string       `json:"type"`
+			Payload CubeResponse `json:"payload"`
+		}
+		if err := ws.ReadJSON(&resp); err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		if resp.Type != "result" || resp.Payload.Action != got.Action || resp.Payload.DecisionType != got.DecisionType || resp.Payload.Verdict != got.Verdict {
+			t.Errorf("%s: WebSocket cube gives %+v, want the /api/cube decision %q, %q", name, resp.Payload, got.Action, got.DecisionType)
+		}
+	}
+	for _, action := range []string{engine.CubeNotAvailable, engine.CubeDeadCube} {
+		if !seen[action] {
+			t.Errorf("no request gave %q; decisions seen: %v", action, seen)
+		}
+	}
+}
+
+// TestCubeToResponseDecisionType checks that the action comes from the
+// decision type alone, even where the equities would suggest another.
+func TestCubeToResponseDecisionType(t *testing.T) {
+	for cdt := engine.DOUBLE_TAKE; cdt <= engine.OPTIONAL_REDOUBLE_PASS; cdt++ {
+		// Equities of a double, pass and of a too good position
+		for _, eq := range [][3]float64{{0, 0.5, 1}, {1.5, 0.5, 1}} {
+			resp := CubeToResponse(&engine.CubeAnalysis{
+				DecisionType:   cdt,
+				NoDoubleEquity: eq[0],
+				DoubleTakeEq:   eq[1],
+				DoublePassEq:   eq[2],
+			})
+			if resp.Action != cdt.Action() || resp.DecisionType != cdt.String() || resp.Verdict != cdt.Verdict() {
+				t.Errorf("%s with equities %v: got %q, %q, %q", cdt, eq, resp.Action, resp.DecisionType, resp.Verdict)
+			}
+		}
+	}
+}
+
+func TestCubeOpponentView(t *testing.T) {
+	h := NewHandlers(getTestEngine(), "1.0.0")
+	for _, req := range []CubeRequest{
+		{Position: "4HPwATDgc/ABMA"},
+		{Position: "4HPwATDgc/ABMA", MatchLength: 5, Score: [2]int{3, 3}},
+	} {
+		body, _ := json.Marshal(req)
+		w := httptest.NewRecorder()
+		h.Cube(w, httptest.NewRequest("POST", "/api/cube", bytes.NewReader(body)))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Status = %d: %s", w.Code, w.Body.String())
+		}
+		var resp CubeResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("Decode error: %v", err)
+		}
+
+		// take_equity is the taker's, not the doubler's double_equity
+		v := resp.OpponentView
+		if v == nil {
+			t.Fatalf("match length %d: no opponent_view", req.MatchLength)
+		}
+		if resp.TakeEquity != v.TakeEquity || math.Abs(v.TakeEquity+resp.DoubleEquity) > 1e-9 {
+			t.Errorf("match length %d: take_equity %.4f, opponent_view %+v, double_equity %.4f", req.MatchLength, resp.TakeEquity, v, resp.DoubleEquity)
+		}
+		if math.Abs(v.PassEquity+resp.PassEquity) > 1e-9 || math.Abs(v.Margin-(v.Win-v.TakePoint)) > 1e-9 || v.TakePoint != resp.TakePoint {
+			t.Errorf("match length %d: opponent_view %+v against pass_equity %.4f, take_point %.2f", req.MatchLength, v, resp.PassEquity, resp.TakePoint)
+		}
+		if (req.MatchLength > 0) != (v.TakeMWC > 0 && v.PassMWC > 0) {
+			t.Errorf("match length %d: take_mwc %.2f, pass_mwc %.2f", req.MatchLength, v.TakeMWC, v.PassMWC)
+		}
+	}
+}
+
+func TestCubeMatchContextResponse(t *testing.T) {
+	h := NewHandlers(getTestEngine(), "1.0.0")
+
+	cube := func(req CubeRequest) CubeResponse {
+		t.Helper()
+		body, _ := json.Marshal(req)
+		w := httptest.NewRecorder()
+		h.Cube(w, httptest.NewRequest("POST", "/api/cube", bytes.NewReader(body)))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Status = %d: %s", w.Code, w.Body.String())
+		}
+		var resp CubeResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("Decode error: %v", err)
+		}
+		return resp
+	}
+
+	if resp := cube(CubeRequest{Position: "4HPwATDgc/ABMA"}); resp.MatchContext != nil {
+		t.Errorf("money game has match_context %+v", resp.MatchContext)
+	}
+
+	// 2-away/2-away in a 5 point match
+	mc := cube(CubeRequest{Position: "4HPwATDgc/ABMA", MatchLength: 5, Score: [2]int{3, 3}}).MatchContext
+	if mc == nil {
+		t.Fatal("no match_context at a match score")
+	}
+	if len(mc.Outcomes) != 12 {
+		t.Fatalf("%d outcomes, want 12", len(mc.Outcomes))
+	}
+	for _, c := range []struct {
+		name      string
+		got, want float64
+	}{
+		{"mwc", mc.MWC, 50},
+		{"win 1", mc.Outcomes[0].MWC, 200.0 / 3},
+		{"opp after win 1", mc.Outcomes[0].OppMWC, 100.0 / 3},
+		{"win 2 doubled", mc.Outcomes[6].MWC, 100},
+		{"lose 1", mc.Outcomes[3].MWC, 100.0 / 3},
+		{"lose 2 doubled", mc.Outcomes[9].MWC, 0},
+		{"double_risk", mc.DoubleRisk, mc.LoseMWC - mc.LoseMWCDoubled},
+	} {
+		if math.Abs(c.got-c.want) > 0.1 {
+			t.Errorf("%s = %.2f, want %.2f", c.name, c.got, c.want)
+		}
+	}
+}
+
+func TestCubeRaceResponse(t *testing.T) {
+	h := NewHandlers(getTestEngine(), "1.0.0")
+
+	cube := func(position string) CubeResponse {
+		t.Helper()
+		body, _ := json.Marshal(CubeRequest{Position: position, CubeOwner: -1})
+		w := httptest.NewRecorder()
+		h.Cube(w, httptest.NewRequest("POST", "/api/cube", bytes.NewReader(body)))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Status = %d: %s", w.Code, w.Body.String())
+		}
+		var resp CubeResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("Decode error: %v", err)
+		}
+		return resp
+	}
+
+	if resp := cube("4HPwATDgc/ABMA"); resp.Race != nil {
+		t.Errorf("contact position has race %+v", resp.Race)
+	}
+
+	// 105 against 120 pips: a pass
+	var race positionid.Board
+	for i := 4; i <= 8; i++ {
+		race[1][i], race[0][i+1] = 3, 3
+	}
+	rc := cube(positionid.PositionID(race)).Race
+	if rc == nil {
+		t.Fatal("no race in a race")
+	}
+	if rc.Pips != [2]int{105, 120} || rc.Lead != 15 || rc.Verdict != "double_pass" || rc.PipsToPass > 0 {
+		t.Errorf("race = %+v", rc)
+	}
+	if !strings.Contains(rc.Summary, "Race of 105 against 120 pips") || len(rc.Table) == 0 {
+		t.Errorf("summary %q, %d table rows", rc.Summary, len(rc.Table))
+	}
+}
+
+func TestActionHandler(t *testing.T) {
+	h := NewHandlers(getTestEngine(), "1.0.0")
+
+	// 105 against 115 pips with a centered cube: a double
+	var race positionid.Board
+	for i := 4; i <= 8; i++ {
+		race[1][i], race[0][i] = 3, 3
+	}
+	race[0][4], race[0][5], race[0][8], race[0][11] = 2, 4, 0, 3
+	position := positionid.PositionID(race)
+
+	action := func(req ActionRequest) (int, ActionResponse) {
+		t.Helper()
+		body, _ := json.Marshal(req)
+		w := httptest.NewRecorder()
+		h.Action(w, httptest.NewRequest("POST", "/api/action", bytes.NewReader(body)))
+		var resp ActionResponse
+		if w.Code == http.StatusOK {
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("Decode error: %v", err)
+			}
+		}
+		return w.Code, resp
+	}
+
+	if code, resp := action(ActionRequest{Position: position, CubeOwner: -1}); code != http.StatusOK || resp.Action != "double" || resp.Cube == nil || resp.Cube.Race == nil || resp.Moves != nil {
+		t.Errorf("centered cube: %d %+v", code, resp)
+	}
+	if code, resp := action(ActionRequest{Position: position, CubeValue: 2, CubeOwner: 1}); code != http.StatusOK || resp.Action != "roll" {
+		t.Errorf("opponent's cube: %d %+v", code, resp)
+	}
+	if code, resp := action(ActionRequest{Position: position, CubeOwner: -1, MatchLength: 5, Score: [2]int{4, 2}, Crawford: true}); code != http.StatusOK || resp.Action != "roll" {
+		t.Errorf("crawford: %d %+v", code, resp)
+	}
+
+	code, resp := action(ActionRequest{Position: position, CubeOwner: -1, Dice: &[2]int{6, 5}, NumMoves: 3})
+	if code != http.StatusOK || resp.Action != "move" || !resp.MissedDouble || resp.Moves == nil {
+		t.Fatalf("with dice: %d %+v", code, resp)
+	}
+	if len(resp.Moves.Moves) == 0 || len(resp.Moves.Moves) > 3 || resp.Moves.Dice != [2]int{6, 5} {
+		t.Errorf("moves = %+v", resp.Moves)
+	}
+
+	for name, req := range map[string]ActionRequest{
+		"missing position": {},
+		"bad dice":         {Position: position, Dice: &[2]int{0, 7}},
+		"bad position":     {Position: "not a position"},
+	} {
+		if code, _ := action(req); code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", name, code)
+		}
+	}
+}
+
+func TestCubeSuggestionRace(t *testing.T) {
+	analysis := &engine.CubeSkillAnalysis{
+		Analysis:    &engine.CubeAnalysis{MatchContext: &engine.CubeMatchContext{}},
+		Race:        &engine.RaceCube{Pips: [2]int{100, 108}, Lead: 8, LeadPct: 8, WinProb: 0.7, Verdict: engine.RaceDoubleTake, DoublePoint: 0.69, TakePoint: 0.79, PipsToDouble: -0.5, PipsToPass: 6},
+		OptimalPlay: engine.Double,
+		ActualPlay:  engine.NoDouble,
+		EquityLoss:  0.1,
+		Skill:       engine.SkillBad,
+	}
+	got := generateCubeSuggestion(analysis)
+	if !strings.Contains(got, "minimal double is at 69.0% (passed by 0.5 pips); the last take at 79.0% (6.0 pips away)") || strings.Contains(got, "MWC") {
+		t.Errorf("race suggestion = %q", got)
+	}
+}
+
+func TestCubeSuggestionMatchContext(t *testing.T) {
+	analysis := &engine.CubeSkillAnalysis{
+		Analysis: &engine.CubeAnalysis{MatchContext: &engine.CubeMatchContext{
+			DoubleRisk: 0.2, DoubleGain: 0.1, TakeRisk: 0.1, TakeGain: 0.4, TakePoint: 0.2,
+		}},
+		OptimalPlay: engine.NoDouble,
+		ActualPlay:  engine.Double,
+		EquityLoss:  0.1,
+		Skill:       engine.SkillBad,
+	}
+	if got := generateCubeSuggestion(analysis); !strings.Contains(got, "you risk 20.0% MWC to gain 10.0%") {
+		t.Errorf("double suggestion = %q", got)
+	}
+
+	analysis.OptimalPlay, analysis.ActualPlay = engine.Pass, engine.Take
+	if got := generateCubeSuggestion(analysis); !strings.Contains(got, "you risk 10.0% MWC to gain 40.0%; the take point is 20.0%") {
+		t.Errorf("take suggestion = %q", got)
+	}
+
+	analysis.Analysis.MatchContext = nil
+	if got := generateCubeSuggestion(analysis); strings.Contains(got, "MWC") {
+		t.Errorf("money game suggestion mentions MWC: %q", got)
+	}
+}
+
+func TestCubeSuggestionMoneyPoints(t *testing.T) {
+	analysis := &engine.CubeSkillAnalysis{
+		Analysis: &engine.CubeAnalysis{
+			Eval:        engine.Evaluation{WinProb: 0.72},
+			TakePoint:   0.33,
+			DoublePoint: 0.59,
+			Points:      []engine.CubePoints{{Cube: 1, TakePoint: 0.33, CashPoint: 0.67, Margin: 0.05}},
+		},
+		OptimalPlay: engine.Pass,
+		ActualPlay:  engine.Take,
+		EquityLoss:  0.1,
+		Skill:       engine.SkillBad,
+	}
+	if got := generateCubeSuggestion(analysis); !strings.Contains(got, "You had 28.0% winning chances; with the gammons in the position the take point is 33.0%.") {
+		t.Errorf("take suggestion = %q", got)
+	}
+
+	analysis.OptimalPlay, analysis.ActualPlay = engine.Double, engine.NoDouble
+	if got := generateCubeSuggestion(analysis); !strings.Contains(got, "You had 72.0% winning chances; with the gammons in the position the double point is 59.0% and the cash point 67.0%.") {
+		t.Errorf("double suggestion = %q", got)
+	}
+}
+
+// blitzBoard returns a board where the player on roll has made the first
+// `made` home board points and the opponent has onBar checkers on the bar.
+func blitzBoard(made, onBar int) engine.Board {
+	var b engine.Board
+	for i := 0; i < made; i++ {
+		b[1][i] = 2
+	}
+	b[1][12] = uint8(15 - 2*made)
+	b[0][24] = uint8(onBar)
+	b[0][12] = uint8(15 - onBar)
+	return b
+}
+
+func TestEntryText(t *testing.T) {
+	e, err := engine.NewEngine(engine.EngineOptions{})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	tests := []struct {
+		made, onBar int
+		want        string
+	}{
+		{0, 1, "The opponent enters with 36/36 rolls against 0 home board points and dances 0.0% of the time."},
+		{1, 1, "The opponent enters with 35/36 rolls against 1 home board point and dances 2.8% of the time."},
+		{2, 1, "The opponent enters with 32/36 rolls against 2 home board points and dances 11.1% of the time."},
+		{3, 1, "The opponent enters with only 27/36 rolls against 3 home board points and dances 25.0% of the time."},
+		{4, 1, "The opponent enters with only 20/36 rolls against 4 home board points and dances 44.4% of the time."},
+		{5, 1, "The opponent enters with only 11/36 rolls against 5 home board points and dances 69.4% of the time."},
+		{6, 1, "The opponent cannot enter against a closed board."},
+		{0, 2, "The opponent has 2 checkers on the bar against 0 home board points: 36/36 rolls enter both and 0/36 dance (0.0%)."},
+		{3, 2, "The opponent has 2 checkers on the bar against 3 home board points: 9/36 rolls enter both, 18/36 only some and 9/36 dance (25.0%)."},
+		{4, 3, "The opponent has 3 checkers on the bar against 4 home board points: 2/36 rolls enter all 3, 18/36 only some and 16/36 dance (44.4%)."},
+		{6, 2, "The opponent has 2 checkers on the bar against 6 home board points: 0/36 rolls enter both and 36/36 dance (100.0%)."},
+	}
+	for _, tt := range tests {
+		stats := e.EntryStats(&engine.GameState{Board: blitzBoard(tt.made, tt.onBar), CubeValue: 1, CubeOwner: -1})
+		if len(stats) != 1 {
+			t.Fatalf("%d made, %d on bar: %+v", tt.made, tt.onBar, stats)
+		}
+		if got := EntryText(stats[0]); got != tt.want {
+			t.Errorf("%d made, %d on bar:\n got %s\nwant %s", tt.made, tt.onBar, got, tt.want)
+		}
+	}
+}
+
+func TestCubeSuggestionEntry(t *testing.T) {
+	e, err := engine.NewEngine(engine.EngineOptions{})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	analysis := &engine.CubeSkillAnalysis{
+		Analysis:    &engine.CubeAnalysis{},
+		OptimalPlay: engine.Double,
+		ActualPlay:  engine.NoDouble,
+		EquityLoss:  0.1,
+		Skill:       engine.SkillBad,
+	}
+	entry := func(made int) []engine.EntryStats {
+		return e.EntryStats(&engine.GameState{Board: blitzBoard(made, 1), CubeValue: 1, CubeOwner: -1})
+	}
+
+	analysis.Entry = entry(4)
+	if got := generateCubeSuggestion(analysis); !strings.Contains(got, "Your opponent enters with only 20/36 rolls against 4 home board points") {
+		t.Errorf("doubler's suggestion = %q", got)
+	}
+	analysis.OptimalPlay, analysis.ActualPlay = engine.Pass, engine.Take
+	if got := generateCubeSuggestion(analysis); !strings.Contains(got, "You enter with only 20/36 rolls against 4 home board points and dance 44.4% of the time.") {
+		t.Errorf("taker's suggestion = %q", got)
+	}
+
+	// Two points made is no blitz, and the doubler on the bar is no threat
+	analysis.Entry = entry(2)
+	if got := generateCubeSuggestion(analysis); strings.Contains(got, "/36") {
+		t.Errorf("suggestion mentions entry against two points: %q", got)
+	}
+	analysis.Entry = []engine.EntryStats{{OnBar: 1, PointsMade: 4, EnterRolls: 20, FullRolls: 20, DanceRolls: 16}}
+	if got := generateCubeSuggestion(analysis); strings.Contains(got, "/36") {
+		t.Errorf("suggestion mentions the doubler's entry: %q", got)
+	}
+}
+
+func TestCubeEntry(t *testing.T) {
+	eng, err := engine.NewEngine(engine.EngineOptions{})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	handler := NewServer(eng, DefaultConfig(), "test").Handler()
+
+	for _, tt := range []struct {
+		position string
+		entries  int
+	}{
+		{engine.EncodePositionID(blitzBoard(4, 2)), 1},
+		{"4HPwATDgc/ABMA", 0},
+	} {
+		body, _ := json.Marshal(CubeRequest{Position: tt.position, CubeOwner: -1})
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("POST", "/api/cube", bytes.NewReader(body)))
+		if w.Code != http.StatusOK {
+			t.Fatalf("status %d: %s", w.Code, w.Body.String())
+		}
+		var resp CubeResponse
+		json.NewDecoder(w.Body).Decode(&resp)
+		if len(resp.Entry) != tt.entries {
+			t.Fatalf("%s: entry = %+v", tt.position, resp.Entry)
+		}
+		if tt.entries > 0 {
+			got := resp.Entry[0]
+			if !got.Opponent || got.OnBar != 2 || got.PointsMade != 4 || got.FullRolls != 4 || got.DanceRolls != 16 ||
+				math.Abs(got.Dance-44.444) > 0.001 || !strings.HasPrefix(got.Summary, "The opponent has 2 checkers") {
+				t.Errorf("entry = %+v", got)
+			}
+		}
 	}
 }
 
@@ -306,12 +1131,236 @@ func TestRolloutHandler(t *testing.T) {
 				if rolloutResp.Trials <= 0 {
 					t.Error("Expected positive Trials")
 				}
+				if m := rolloutResp.Manifest; rolloutResp.Seed != 12345 || m == nil || m.Seed != 12345 || m.Trials != rolloutResp.Trials {
+					t.Errorf("seed %d, manifest %+v: want seed 12345 in both", rolloutResp.Seed, m)
+				}
+				if b := rolloutResp.Engine; b == nil || !reflect.DeepEqual(*b, *eng.BuildInfo()) || rolloutResp.Manifest.Version != b.Version {
+					t.Errorf("engine %+v, want %+v", b, eng.BuildInfo())
+				}
 			}
 		})
 	}
 }
 
-// TestFormatMove tests the move formatting helper
+func TestCubeRolloutHandler(t *testing.T) {
+	handler := NewServer(getTestEngine(), DefaultConfig(), "test").Handler()
+
+	// Last roll: the opponent bears off next turn, the player on roll bears
+	// off two checkers from the 2-point with 26 rolls in 36
+	var resp CubeRolloutResponse
+	code := serve(t, handler, "POST", "/api/cube/rollout", RolloutRequest{Position: "AQAAGAAAAAAAAA", Trials: 1296, Seed: 1, CubeOwner: -1}, &resp)
+	if code != http.StatusOK {
+		t.Fatalf("status %d, want 200", code)
+	}
+	if resp.Cube == nil || resp.Cube.Decision != "Double, Take" {
+		t.Errorf("cube = %+v, want Double, Take", resp.Cube)
+	}
+	if resp.Rollout.Trials != 1296 || !resp.DoubleSignificant || !resp.TakeSignificant || resp.DoubleDiffCI <= 0 {
+		t.Errorf("response = %+v", resp)
+	}
+
+	if code := serve(t, handler, "POST", "/api/cube/rollout", RolloutRequest{Trials: 100}, nil); code != http.StatusBadRequest {
+		t.Errorf("missing position: status %d, want 400", code)
+	}
+}
+
+func TestMETSelection(t *testing.T) {
+	handler := NewServer(getTestEngine(), DefaultConfig(), "test").Handler()
+
+	// The last roll, trailing 7-away against 3-away
+	var cube CubeResponse
+	req := CubeRequest{Position: "AQAAGAAAAAAAAA", MatchLength: 7, Score: [2]int{0, 4}, CubeOwner: -1, MET: "Default"}
+	if code := serve(t, handler, "POST", "/api/cube", req, &cube); code != http.StatusOK {
+		t.Fatalf("cube with met: status %d, want 200", code)
+	}
+	if cube.Decision != "Double, Take" {
+		t.Errorf("decision = %q, want Double, Take", cube.Decision)
+	}
+
+	var eval EvaluateResponse
+	serve(t, handler, "POST", "/api/evaluate", EvaluateRequest{Position: "AQAAGAAAAAAAAA", MatchLength: 7, Score: [2]int{0, 4}, MET: "default"}, &eval)
+	if eval.MWC <= 0 || eval.MWC >= 100 {
+		t.Errorf("mwc = %.2f, want a percentage", eval.MWC)
+	}
+
+	for path, body := range map[string]interface{}{
+		"/api/evaluate":     EvaluateRequest{Position: "4HPwATDgc/ABMA", MET: "nope"},
+		"/api/cube":         CubeRequest{Position: "4HPwATDgc/ABMA", MET: "nope"},
+		"/api/cube/rollout": RolloutRequest{Position: "4HPwATDgc/ABMA", Trials: 1, MET: "nope"},
+		"/api/tutor/cube":   TutorCubeRequest{Position: "4HPwATDgc/ABMA", Action: "double", MET: "nope"},
+		"/api/tutor/game":   AnalyzeGameRequest{Positions: []GamePosition{{Position: "4HPwATDgc/ABMA", CubeAction: "double"}}, MET: "nope"},
+	} {
+		data, _ := json.Marshal(body)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("POST", path, bytes.NewReader(data)))
+		var resp ErrorResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		if w.Code != http.StatusBadRequest || resp.Code != "INVALID_MET" || len(resp.AvailableMETs) == 0 || resp.AvailableMETs[0] != "default" {
+			t.Errorf("%s with an unknown met: %d %+v, want 400 INVALID_MET", path, w.Code, resp)
+		}
+	}
+}
+
+func TestOpponentPerspective(t *testing.T) {
+	handler := NewServer(getTestEngine(), DefaultConfig(), "test").Handler()
+
+	// The last roll: the player on roll needs 26/36, the opponent on roll
+	// cannot miss
+	const position, swapped = "AQAAGAAAAAAAAA", "BgAACAAAAAAAAA"
+	board, _ := positionid.BoardFromPositionID(position)
+	if id := positionid.PositionID(positionid.SwapSides(board)); id != swapped {
+		t.Fatalf("swapped position = %s, want %s", id, swapped)
+	}
+
+	var eval, oppEval EvaluateResponse
+	serve(t, handler, "POST", "/api/evaluate", EvaluateRequest{Position: position}, &eval)
+	serve(t, handler, "POST", "/api/evaluate", EvaluateRequest{Position: position, Opponent: true}, &oppEval)
+	if math.Abs(eval.Win-100*26.0/36) > 0.01 || oppEval.Win != 100 || !oppEval.Opponent || eval.Opponent {
+		t.Errorf("win = %.2f, opponent's = %+v", eval.Win, oppEval)
+	}
+
+	// At a match score the score and cube owner are seen from the opponent's
+	// side: the opponent's cube decision is the one of the swapped position
+	// with the score reversed and the cube owner switched
+	var opp, want CubeResponse
+	serve(t, handler, "POST", "/api/cube", CubeRequest{Position: position, MatchLength: 7, Score: [2]int{0, 4}, CubeValue: 2, CubeOwner: 1, Opponent: true}, &opp)
+	serve(t, handler, "POST", "/api/cube", CubeRequest{Position: swapped, MatchLength: 7, Score: [2]int{4, 0}, CubeValue: 2, CubeOwner: 0}, &want)
+	if !opp.Opponent || opp.MatchContext == nil || want.MatchContext == nil ||
+		opp.Decision != want.Decision || opp.NoDoubleEquity != want.NoDoubleEquity || opp.MatchContext.MWC != want.MatchContext.MWC {
+		t.Errorf("opponent's cube = %+v, want %+v", opp, want)
+	}
+}
+
+func TestInspectHandler(t *testing.T) {
+	do := func(handler http.Handler, method, path string, body interface{}) *httptest.ResponseRecorder {
+		var r io.Reader
+		if body != nil {
+			data, _ := json.Marshal(body)
+			r = bytes.NewReader(data)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(method, path, r))
+		return w
+	}
+
+	// Only served in debug mode
+	handler := NewServer(getTestEngine(), DefaultConfig(), "test").Handler()
+	if w := do(handler, "GET", "/api/inspect?position=4HPwATDgc/ABMA", nil); w.Code != http.StatusForbidden {
+		t.Errorf("without debug: status %d, want %d", w.Code, http.StatusForbidden)
+	}
+
+	config := DefaultConfig()
+	config.Debug = true
+	handler = NewServer(getTestEngine(), config, "test").Handler()
+
+	w := do(handler, "GET", "/api/inspect?position=4HPwATDgc/ABMA&verbose=true", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET status %d: %s", w.Code, w.Body.String())
+	}
+	var resp InspectResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Decode error: %v", err)
+	}
+	if resp.Class != "contact" || resp.Network != "contact" || resp.Evaluator == "" {
+		t.Errorf("class %q network %q evaluator %q, want the contact net", resp.Class, resp.Network, resp.Evaluator)
+	}
+	if len(resp.Inputs) != 250 {
+		t.Fatalf("%d inputs, want 250", len(resp.Inputs))
+	}
+	// Each side has 2 checkers on its 24 point
+	if in := resp.Inputs[23*4+1]; in.Name != "opponent.point24.2" || in.Value != 1 {
+		t.Errorf("input %d = %+v, want opponent.point24.2 = 1", 23*4+1, in)
+	}
+
+	w = do(handler, "POST", "/api/inspect", InspectRequest{Position: "4HPwATDgc/ABMA"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST status %d: %s", w.Code, w.Body.String())
+	}
+	resp = InspectResponse{}
+	json.NewDecoder(w.Body).Decode(&resp)
+	if len(resp.Inputs) != 0 {
+		t.Errorf("%d inputs without verbose, want none", len(resp.Inputs))
+	}
+
+	for _, path := range []string{"/api/inspect", "/api/inspect?position=bad"} {
+		if w := do(handler, "GET", path, nil); w.Code != http.StatusBadRequest {
+			t.Errorf("GET %s: status %d, want %d", path, w.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestRolloutStoreEndpoints(t *testing.T) {
+	store, err := engine.OpenFileRolloutStore(filepath.Join(t.TempDir(), "rollouts.jsonl"))
+	if err != nil {
+		t.Fatalf("OpenFileRolloutStore failed: %v", err)
+	}
+	defer store.Close()
+	eng, err := engine.NewEngine(engine.EngineOptions{RolloutStore: store})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	handler := NewServer(eng, DefaultConfig(), "test").Handler()
+
+	do := func(method, path string, body interface{}) *httptest.ResponseRecorder {
+		var r io.Reader
+		if body != nil {
+			data, _ := json.Marshal(body)
+			r = bytes.NewReader(data)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(method, path, r))
+		return w
+	}
+	rollout := func(trials int) RolloutResponse {
+		t.Helper()
+		w := do("POST", "/api/rollout", RolloutRequest{Position: "4HPwATDgc/ABMA", Trials: trials, Truncate: 4, Seed: 9})
+		if w.Code != http.StatusOK {
+			t.Fatalf("rollout status %d: %s", w.Code, w.Body.String())
+		}
+		var resp RolloutResponse
+		json.NewDecoder(w.Body).Decode(&resp)
+		return resp
+	}
+
+	first := rollout(20)
+	if first.Cached || first.StoredAt == "" {
+		t.Errorf("first rollout: cached=%v stored_at=%q", first.Cached, first.StoredAt)
+	}
+	again := rollout(20)
+	if !again.Cached || again.StoredAt != first.StoredAt || again.Trials != 20 || again.Equity != first.Equity {
+		t.Errorf("repeated rollout = %+v, want the cached result of %+v", again, first)
+	}
+	if more := rollout(30); more.Cached || more.Trials != 30 || more.StoredAt != first.StoredAt {
+		t.Errorf("extended rollout = %+v, want 30 fresh trials stored at %s", more, first.StoredAt)
+	}
+
+	w := do("GET", "/api/admin/rollouts", nil)
+	var list StoredRolloutsResponse
+	json.NewDecoder(w.Body).Decode(&list)
+	if w.Code != http.StatusOK || len(list.Rollouts) != 1 || list.Rollouts[0].Trials != 30 {
+		t.Fatalf("list status %d: %+v", w.Code, list)
+	}
+
+	id := list.Rollouts[0].ID
+	if w := do("DELETE", "/api/admin/rollouts/"+id, nil); w.Code != http.StatusNoContent {
+		t.Errorf("delete status %d: %s", w.Code, w.Body.String())
+	}
+	if w := do("DELETE", "/api/admin/rollouts/"+id, nil); w.Code != http.StatusNotFound {
+		t.Errorf("second delete status %d, want 404", w.Code)
+	}
+	if resp := rollout(20); resp.Cached {
+		t.Error("evicted rollout still cached")
+	}
+
+	// Without a store the admin endpoints say so
+	w = httptest.NewRecorder()
+	NewServer(getTestEngine(), DefaultConfig(), "test").Handler().ServeHTTP(w, httptest.NewRequest("GET", "/api/admin/rollouts", nil))
+	if w.Code != http.StatusNotImplemented || !strings.Contains(w.Body.String(), "NO_ROLLOUT_STORE") {
+		t.Errorf("list without store: status %d %s", w.Code, w.Body.String())
+	}
+}
+
+// TestFormatMove tests the move notation of responses
 func TestFormatMove(t *testing.T) {
 	tests := []struct {
 		move engine.Move
@@ -336,9 +1385,9 @@ func TestFormatMove(t *testing.T) {
 	}
 
 	for _, tc := range tests {
-		got := formatMove(tc.move)
+		got := engine.FormatMove(tc.move)
 		if got != tc.want {
-			t.Errorf("formatMove(%v) = %q, want %q", tc.move, got, tc.want)
+			t.Errorf("engine.FormatMove(%v) = %q, want %q", tc.move, got, tc.want)
 		}
 	}
 }
@@ -903,6 +1952,121 @@ func TestTutorMoveHandlerSkillDetection(t *testing.T) {
 	t.Logf("Move analysis: skill=%s, loss=%.4f, best=%s", result.Skill, result.EquityLoss, result.BestMove)
 }
 
+// postTutorMove posts a tutor move request and returns the recorded response.
+func postTutorMove(t *testing.T, h *Handlers, body TutorMoveRequest) *http.Response {
+	t.Helper()
+	bodyBytes, _ := json.Marshal(body)
+	req := httptest.NewRequest("POST", "/api/tutor/move", bytes.NewReader(bodyBytes))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	h.HandleTutorMove(w, req)
+	return w.Result()
+}
+
+func TestTutorMoveHandlerResultingPosition(t *testing.T) {
+	eng := getTestEngine()
+	h := NewHandlers(eng, "1.0.0")
+
+	start, _ := positionid.BoardFromPositionID("4HPwATDgc/ABMA")
+	move, _ := engine.ParseMove("8/5 6/5")
+	resultID := engine.ResultingPositionID(engine.Board(start), move)
+	moverID := positionid.PositionID(positionid.Board(engine.ApplyMove(engine.Board(start), move)))
+
+	// Notation request for reference
+	resp := postTutorMove(t, h, TutorMoveRequest{Position: "4HPwATDgc/ABMA", Dice: [2]int{3, 1}, Move: "8/5 6/5"})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	var byNotation TutorMoveResponse
+	json.NewDecoder(resp.Body).Decode(&byNotation)
+
+	// The mover still on roll is not the position after the move
+	resp = postTutorMove(t, h, TutorMoveRequest{Position: "4HPwATDgc/ABMA", Dice: [2]int{3, 1}, ResultingPosition: moverID})
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("resulting_position with the mover on roll: Status = %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+
+	resp = postTutorMove(t, h, TutorMoveRequest{Position: "4HPwATDgc/ABMA", Dice: [2]int{3, 1}, ResultingPosition: resultID})
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		t.Fatalf("resulting_position %s: Status = %d, body: %s", resultID, resp.StatusCode, body)
+	}
+	var result TutorMoveResponse
+	json.NewDecoder(resp.Body).Decode(&result)
+	if result.PlayedMove != byNotation.PlayedMove {
+		t.Errorf("resulting_position %s: played_move = %q, want %q", resultID, result.PlayedMove, byNotation.PlayedMove)
+	}
+	if result.PlayedEquity != byNotation.PlayedEquity {
+		t.Errorf("resulting_position %s: played_equity = %f, want %f", resultID, result.PlayedEquity, byNotation.PlayedEquity)
+	}
+}
+
+func TestTutorMoveHandlerIllegalResultingPosition(t *testing.T) {
+	eng := getTestEngine()
+	h := NewHandlers(eng, "1.0.0")
+
+	// The unchanged starting position cannot result from playing 3-1
+	resp := postTutorMove(t, h, TutorMoveRequest{
+		Position:          "4HPwATDgc/ABMA",
+		Dice:              [2]int{3, 1},
+		ResultingPosition: "4HPwATDgc/ABMA",
+	})
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Status = %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+
+	var errResp ErrorResponse
+	json.NewDecoder(resp.Body).Decode(&errResp)
+	if errResp.Code != "ILLEGAL_MOVE" {
+		t.Errorf("Code = %q, want ILLEGAL_MOVE", errResp.Code)
+	}
+	if len(errResp.LegalPositions) == 0 {
+		t.Error("Expected legal_positions to list reachable positions")
+	}
+	for _, id := range errResp.LegalPositions {
+		if _, err := positionid.BoardFromPositionID(id); err != nil {
+			t.Errorf("legal position %q is not a valid position ID: %v", id, err)
+		}
+	}
+	// The positions have the opponent on roll, as resulting_position takes them
+	start := engine.StartingPosition().Board
+	ml := engine.GenerateMoves(start, 3, 1)
+	for _, m := range ml.Moves {
+		if id := engine.ResultingPositionID(start, m); !slices.Contains(errResp.LegalPositions, id) {
+			t.Errorf("legal_positions %v lacks %s, the position after %v", errResp.LegalPositions, id, m)
+		}
+	}
+
+	// Both shapes at once is rejected
+	resp = postTutorMove(t, h, TutorMoveRequest{
+		Position:          "4HPwATDgc/ABMA",
+		Dice:              [2]int{3, 1},
+		Move:              "8/5 6/5",
+		ResultingPosition: "4HPwATDgc/ABMA",
+	})
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Status = %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+}
+
+func TestTutorMoveHandlerIllegalMove(t *testing.T) {
+	h := NewHandlers(getTestEngine(), "1.0.0")
+
+	resp := postTutorMove(t, h, TutorMoveRequest{
+		Position: "4HPwATDgc/ABMA",
+		Dice:     [2]int{3, 1},
+		Move:     "7/4 6/5",
+	})
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Status = %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+	var errResp ErrorResponse
+	json.NewDecoder(resp.Body).Decode(&errResp)
+	if errResp.Code != "ILLEGAL_MOVE" || !strings.Contains(errResp.Error, "no checker on the 7 point") {
+		t.Errorf("error = %+v, want ILLEGAL_MOVE with the reason", errResp)
+	}
+}
+
 func TestTutorCubeHandler(t *testing.T) {
 	eng := getTestEngine()
 	h := NewHandlers(eng, "1.0.0")
@@ -985,6 +2149,97 @@ func TestTutorCubeHandler(t *testing.T) {
 	}
 }
 
+// TestTutorCubeTooGood checks the tutor's verdict on a position too good
+// to double: playing on is right and doubling cashes a certain gammon.
+func TestTutorCubeTooGood(t *testing.T) {
+	h := NewHandlers(getTestEngine(), "1.0.0")
+
+	// The opponent cannot bear off before the player on roll is off
+	var board engine.Board
+	board[1][0], board[1][1] = 3, 2
+	for i, n := range []uint8{3, 3, 3, 2, 2, 2} {
+		board[0][6+i] = n
+	}
+	position := engine.EncodePositionID(board)
+
+	for _, tt := range []struct {
+		action, optimal string
+		loss            float64
+		suggestion      string
+	}{
+		{"no_double", "too_good", 0, ""},
+		{"double", "too_good", 1, "The position is too good to double: playing on for the gammon is worth more than cashing."},
+	} {
+		body, _ := json.Marshal(TutorCubeRequest{Position: position, Action: tt.action, CubeOwner: -1})
+		req := httptest.NewRequest("POST", "/api/tutor/cube", bytes.NewReader(body))
+		w := httptest.NewRecorder()
+		h.HandleTutorCube(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status %d: %s", tt.action, w.Code, w.Body)
+		}
+		var resp TutorCubeResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if resp.Optimal != tt.optimal || math.Abs(resp.EquityLoss-tt.loss) > 0.01 {
+			t.Errorf("%s: optimal %q loss %.4f, want %q and %.4f", tt.action, resp.Optimal, resp.EquityLoss, tt.optimal, tt.loss)
+		}
+		if !strings.Contains(resp.Suggestion, tt.suggestion) || (tt.suggestion == "") != (resp.Suggestion == "") {
+			t.Errorf("%s: suggestion %q, want it to say %q", tt.action, resp.Suggestion, tt.suggestion)
+		}
+	}
+}
+
+func TestTutorThresholdOverrides(t *testing.T) {
+	h := NewHandlers(getTestEngine(), "1.0.0")
+	move := TutorMoveRequest{Position: "4HPwATDgc/ABMA", Dice: [2]int{3, 1}, Move: "8/5 6/5"}
+	cube := TutorCubeRequest{Position: "4HPwATDgc/ABMA", Action: "no_double"}
+	game := AnalyzeGameRequest{Positions: []GamePosition{{Position: "4HPwATDgc/ABMA", Dice: [2]int{3, 1}, Move: "8/5 6/5"}}}
+
+	tests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		body       interface{}
+		wantStatus int
+	}{
+		{"move, lenient", h.HandleTutorMove, withThresholds(move, 0.05, 0.1, 0.2), http.StatusOK},
+		{"move, blunder below bad", h.HandleTutorMove, withThresholds(move, 0, 0, 0.05), http.StatusBadRequest},
+		{"cube, strict", h.HandleTutorCube, withThresholds(cube, 0.01, 0.02, 0.04), http.StatusOK},
+		{"cube, negative", h.HandleTutorCube, withThresholds(cube, -0.01, 0, 0), http.StatusBadRequest},
+		{"game, doubtful above bad", h.HandleAnalyzeGame, withThresholds(game, 0.08, 0, 0), http.StatusBadRequest},
+		{"game, blunder only", h.HandleAnalyzeGame, withThresholds(game, 0, 0, 0.2), http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(tt.body)
+			w := httptest.NewRecorder()
+			tt.handler(w, httptest.NewRequest("POST", "/", bytes.NewReader(body)))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantStatus == http.StatusBadRequest && !strings.Contains(w.Body.String(), "INVALID_THRESHOLDS") {
+				t.Errorf("error = %s, want INVALID_THRESHOLDS", w.Body.String())
+			}
+		})
+	}
+}
+
+// withThresholds returns a copy of a tutor request with skill thresholds set.
+func withThresholds(req interface{}, doubtful, bad, blunder float64) interface{} {
+	switch r := req.(type) {
+	case TutorMoveRequest:
+		r.DoubtfulThreshold, r.BadThreshold, r.BlunderThreshold = doubtful, bad, blunder
+		return r
+	case TutorCubeRequest:
+		r.DoubtfulThreshold, r.BadThreshold, r.BlunderThreshold = doubtful, bad, blunder
+		return r
+	case AnalyzeGameRequest:
+		r.DoubtfulThreshold, r.BadThreshold, r.BlunderThreshold = doubtful, bad, blunder
+		return r
+	}
+	return req
+}
+
 func TestAnalyzeGameHandler(t *testing.T) {
 	eng := getTestEngine()
 	h := NewHandlers(eng, "1.0.0")
@@ -1106,6 +2361,9 @@ func TestAnalyzeGameHandlerWithErrors(t *testing.T) {
 	if result.TotalMoves != 2 {
 		t.Errorf("Expected 2 total moves, got %d", result.TotalMoves)
 	}
+	if result.Engine == nil || result.Engine.Version != engine.Version {
+		t.Errorf("Engine = %+v, want version %q", result.Engine, engine.Version)
+	}
 
 	// Should have suggestions (always generated)
 	if len(result.Suggestions) == 0 {
@@ -1123,6 +2381,140 @@ func TestAnalyzeGameHandlerWithErrors(t *testing.T) {
 		result.TotalMoves, len(result.MoveErrors), result.Suggestions)
 }
 
+func TestGenerateGameSuggestions(t *testing.T) {
+	var resp GameAnalysisResponse
+	if got := generateGameSuggestions(&resp); len(got) != 1 || !strings.HasPrefix(got[0], "Good game") {
+		t.Errorf("no errors: suggestions = %q, want the Good game line", got)
+	}
+
+	// Player 1 loses most in blitzes, mostly over the board; player 2 only
+	// in a race, on the cube
+	resp.Players[0].Weakness.Add("holding", engine.PhaseMiddle, false, 0.04)
+	resp.Players[0].Weakness.Add("blitz", engine.PhaseOpening, false, 0.12)
+	resp.Players[0].Weakness.Add("blitz", engine.PhaseMiddle, true, 0.03)
+	resp.Players[1].Weakness.Add("race", engine.PhaseBearIn, true, 0.09)
+
+	got := generateGameSuggestions(&resp)
+	if len(got) != 3 {
+		t.Fatalf("suggestions = %q, want 3", got)
+	}
+	want := "Player 1 lost the most equity in blitz positions (0.150 over 2 error(s)) and in the opening. " +
+		categoryAdvice["blitz"]
+	if got[0] != want {
+		t.Errorf("player 1 suggestion = %q, want %q", got[0], want)
+	}
+	if !strings.Contains(got[1], "race positions") || !strings.Contains(got[1], categoryAdvice["race"]) {
+		t.Errorf("player 2 suggestion = %q, want race advice", got[1])
+	}
+	if !strings.HasPrefix(got[2], "Player 2's cube decisions cost more") {
+		t.Errorf("cube suggestion = %q", got[2])
+	}
+}
+
+func TestAnalyzeGameFragment(t *testing.T) {
+	eng := getTestEngine()
+	h := NewHandlers(eng, "1.0.0")
+
+	post := func(body AnalyzeGameRequest) *httptest.ResponseRecorder {
+		bodyBytes, _ := json.Marshal(body)
+		req := httptest.NewRequest("POST", "/api/tutor/game", bytes.NewReader(bodyBytes))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		h.HandleAnalyzeGame(w, req)
+		return w
+	}
+
+	start := &GameStart{Position: "4HPwATDgc/ABMA", MatchLength: 7, Score: [2]int{3, 2}, CubeValue: 2, CubeOwner: 1}
+	w := post(AnalyzeGameRequest{
+		Start: start,
+		Positions: []GamePosition{
+			{Dice: [2]int{3, 1}, Move: "8/5 6/5", Player: 0},
+			{Dice: [2]int{6, 4}, Move: "24/14", Player: 1},
+		},
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	var result GameAnalysisResponse
+	json.NewDecoder(w.Body).Decode(&result)
+	if result.TotalMoves != 2 {
+		t.Errorf("TotalMoves = %d, want 2", result.TotalMoves)
+	}
+
+	// The replayed positions may be given, but must match
+	m, _ := engine.ParseMove("8/5 6/5")
+	b, _ := positionid.BoardFromPositionID("4HPwATDgc/ABMA")
+	after := engine.ResultingPositionID(engine.Board(b), m)
+	w = post(AnalyzeGameRequest{
+		Start: start,
+		Positions: []GamePosition{
+			{Dice: [2]int{3, 1}, Move: "8/5 6/5", Player: 0},
+			{Position: after, Dice: [2]int{6, 4}, Move: "24/14", Player: 1},
+		},
+	})
+	if w.Code != http.StatusOK {
+		t.Errorf("matching position: Status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+
+	tests := []struct {
+		name      string
+		body      AnalyzeGameRequest
+		wantCode  string
+		wantInMsg string
+	}{
+		{
+			name: "illegal move",
+			body: AnalyzeGameRequest{Start: start, Positions: []GamePosition{
+				{Dice: [2]int{3, 1}, Move: "8/5 6/5", Player: 0},
+				{Dice: [2]int{3, 1}, Move: "24/18", Player: 1},
+			}},
+			wantCode:  "ILLEGAL_MOVE",
+			wantInMsg: "positions[1]",
+		},
+		{
+			name: "position does not follow",
+			body: AnalyzeGameRequest{Start: start, Positions: []GamePosition{
+				{Dice: [2]int{3, 1}, Move: "8/5 6/5", Player: 0},
+				{Position: "4HPwATDgc/ABMA", Dice: [2]int{6, 4}, Move: "24/14", Player: 1},
+			}},
+			wantCode:  "INVALID_POSITION",
+			wantInMsg: "positions[1]",
+		},
+		{
+			name: "neither move nor cube action",
+			body: AnalyzeGameRequest{Start: start, Positions: []GamePosition{
+				{Player: 0},
+			}},
+			wantCode:  "INVALID_ACTION",
+			wantInMsg: "positions[0]",
+		},
+		{
+			name: "bad move without start",
+			body: AnalyzeGameRequest{Positions: []GamePosition{
+				{Position: "4HPwATDgc/ABMA", Dice: [2]int{3, 1}, Move: "24/18", Player: 0},
+			}},
+			wantCode:  "ILLEGAL_MOVE",
+			wantInMsg: "positions[0]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := post(tt.body)
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("Status = %d, want %d: %s", w.Code, http.StatusBadRequest, w.Body.String())
+			}
+			var errResp ErrorResponse
+			json.NewDecoder(w.Body).Decode(&errResp)
+			if errResp.Code != tt.wantCode {
+				t.Errorf("Code = %q, want %q", errResp.Code, tt.wantCode)
+			}
+			if !strings.Contains(errResp.Error, tt.wantInMsg) {
+				t.Errorf("Error = %q, want it to mention %q", errResp.Error, tt.wantInMsg)
+			}
+		})
+	}
+}
+
 func TestTutorMoveHandlerTopMoves(t *testing.T) {
 	eng := getTestEngine()
 	h := NewHandlers(eng, "1.0.0")
@@ -1162,6 +2554,24 @@ func TestTutorMoveHandlerTopMoves(t *testing.T) {
 	}
 }
 
+func TestMoveSuggestionDescribesDifference(t *testing.T) {
+	gs := engine.StartingPosition()
+	played, _ := engine.ParseMove("24/21 24/23")
+	best, _ := engine.ParseMove("8/5 6/5")
+
+	got := generateMoveSuggestion(gs, &engine.MoveSkillAnalysis{
+		Move:       played,
+		BestMove:   best,
+		EquityLoss: 0.1,
+		Skill:      engine.SkillBad,
+	})
+	want := "This was an error losing 0.100 equity. Consider 8/5 6/5 instead. " +
+		"The best play makes the 5-point instead of breaking the 24-point."
+	if got != want {
+		t.Errorf("suggestion = %q, want %q", got, want)
+	}
+}
+
 // ============================================================================
 // FIBS Board Handler Tests
 // ============================================================================
@@ -1358,3 +2768,276 @@ func TestFIBSBoardHandlerParsesPlayerNames(t *testing.T) {
 		t.Errorf("Score2 = %d, want %d", fibsResp.Score2, 2)
 	}
 }
+
+func TestRolloutAllowPartial(t *testing.T) {
+	eng, err := engine.NewEngine(engine.EngineOptions{})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	handler := NewServer(eng, DefaultConfig(), "test").Handler()
+
+	// Two checkers left on the 1-point against five on the opponent's
+	// 8-point: the player on roll bears off at once
+	var board positionid.Board
+	board[1][0] = 2
+	board[0][7] = 5
+	pos := positionid.PositionID(board)
+
+	rollout := func(partial bool) RolloutResponse {
+		t.Helper()
+		body, _ := json.Marshal(RolloutRequest{Position: pos, Trials: 36, Seed: 1, AllowPartial: partial})
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("POST", "/api/rollout", bytes.NewReader(body)))
+		if w.Code != http.StatusOK {
+			t.Fatalf("rollout status %d: %s", w.Code, w.Body.String())
+		}
+		var resp RolloutResponse
+		json.NewDecoder(w.Body).Decode(&resp)
+		return resp
+	}
+
+	// With allow_partial the opponent's five checkers are all it has: a
+	// gammon. Only the gammon is checked, not which side the response
+	// reports it for
+	if resp := rollout(true); resp.Trials != 36 || resp.WinG+resp.LoseG != 100 {
+		t.Errorf("allow_partial rollout = %+v, want every trial a gammon", resp)
+	}
+	// Without it the opponent has borne off ten
+	if resp := rollout(false); resp.WinG+resp.LoseG != 0 {
+		t.Errorf("full game rollout = %+v, want no gammons", resp)
+	}
+}
+
+func TestDebugTiming(t *testing.T) {
+	eng, err := engine.NewEngine(engine.EngineOptions{})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	handler := NewServer(eng, DefaultConfig(), "test").Handler()
+	ply := 1
+
+	post := func(path string, req interface{}) map[string]json.RawMessage {
+		t.Helper()
+		body, _ := json.Marshal(req)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("POST", path, bytes.NewReader(body)))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s status %d: %s", path, w.Code, w.Body.String())
+		}
+		var resp map[string]json.RawMessage
+		json.NewDecoder(w.Body).Decode(&resp)
+		return resp
+	}
+
+	cases := []struct {
+		path        string
+		debug, none interface{}
+	}{
+		{"/api/evaluate",
+			EvaluateRequest{Position: "4HPwATDgc/ABMA", Ply: &ply, DebugTiming: true},
+			EvaluateRequest{Position: "4HPwATDgc/ABMA", Ply: &ply}},
+		{"/api/move",
+			MoveRequest{Position: "4HPwATDgc/ABMA", Dice: [2]int{3, 1}, Ply: &ply, DebugTiming: true},
+			MoveRequest{Position: "4HPwATDgc/ABMA", Dice: [2]int{3, 1}, Ply: &ply}},
+		{"/api/cube",
+			CubeRequest{Position: "4HPwATDgc/ABMA", CubeOwner: -1, DebugTiming: true},
+			CubeRequest{Position: "4HPwATDgc/ABMA", CubeOwner: -1}},
+	}
+	for _, c := range cases {
+		raw, ok := post(c.path, c.debug)["timing_ms"]
+		if !ok {
+			t.Errorf("%s: no timing_ms with debug_timing", c.path)
+			continue
+		}
+		var timing TimingResponse
+		if err := json.Unmarshal(raw, &timing); err != nil {
+			t.Fatalf("%s: %v", c.path, err)
+		}
+		components := timing.MoveGen + timing.Inputs + timing.Contact + timing.Crashed + timing.Race +
+			timing.Prune + timing.Heuristic + timing.Bearoff + timing.Cache
+		if timing.Total <= 0 || timing.Evaluations == 0 || components+timing.Other < timing.Total*0.999 {
+			t.Errorf("%s: timing_ms = %+v", c.path, timing)
+		}
+
+		if _, ok := post(c.path, c.none)["timing_ms"]; ok {
+			t.Errorf("%s: timing_ms without debug_timing", c.path)
+		}
+	}
+}
+
+func TestMoveConstraints(t *testing.T) {
+	eng, err := engine.NewEngine(engine.EngineOptions{})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	handler := NewServer(eng, DefaultConfig(), "test").Handler()
+
+	post := func(req MoveRequest) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(req)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("POST", "/api/move", bytes.NewReader(body)))
+		return w
+	}
+
+	// The opening 31 without making a point
+	w := post(MoveRequest{Position: "4HPwATDgc/ABMA", Dice: [2]int{3, 1}, NumMoves: 100,
+		Constraints: []string{"!point", "!move:24/23 24/21"}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+	var resp MovesResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	c := resp.Constraint
+	if c == nil || c.Constraints != "!point,!move:24/23 24/21" || c.Best.Move != "8/5 6/5" {
+		t.Fatalf("constraint = %+v", c)
+	}
+	if resp.NumLegal != 16 || c.NumAllowed != len(resp.Moves) || c.NumAllowed != 14 {
+		t.Errorf("%d legal, %d allowed, %d moves", resp.NumLegal, c.NumAllowed, len(resp.Moves))
+	}
+	if c.Cost <= 0 || math.Abs(c.Best.Equity-resp.Moves[0].Equity-c.Cost) > 1e-9 {
+		t.Errorf("cost %.4f, best %.4f, allowed %.4f", c.Cost, c.Best.Equity, resp.Moves[0].Equity)
+	}
+	for _, m := range resp.Moves {
+		if m.Move == "24/21 24/23" || m.Move == "24/23 24/21" {
+			t.Errorf("excluded move %s ranked", m.Move)
+		}
+		for _, tag := range m.Tags {
+			if tag == "point" {
+				t.Errorf("%s makes a point", m.Move)
+			}
+		}
+	}
+
+	for _, tc := range []struct {
+		constraints []string
+		code        string
+	}{
+		{[]string{"hits"}, "INVALID_CONSTRAINT"},
+		{[]string{"make:30"}, "INVALID_CONSTRAINT"},
+		{[]string{"hit"}, "NO_MOVE_ALLOWED"},
+	} {
+		w := post(MoveRequest{Position: "4HPwATDgc/ABMA", Dice: [2]int{3, 1}, Constraints: tc.constraints})
+		var errResp ErrorResponse
+		json.NewDecoder(w.Body).Decode(&errResp)
+		if w.Code != http.StatusBadRequest || errResp.Code != tc.code {
+			t.Errorf("%v: status %d, code %q, want %s", tc.constraints, w.Code, errResp.Code, tc.code)
+		}
+	}
+}
+
+func TestRolloutInitialDice(t *testing.T) {
+	eng, err := engine.NewEngine(engine.EngineOptions{})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	handler := NewServer(eng, DefaultConfig(), "test").Handler()
+
+	// Two checkers left each: the player on roll bears both off only with
+	// 6-6, and otherwise the opponent bears off first
+	var board positionid.Board
+	board[1][5] = 2
+	board[0][0] = 2
+	pos := positionid.PositionID(board)
+
+	var resp RolloutResponse
+	req := RolloutRequest{Position: pos, Trials: 36, Seed: 1, InitialDice: [2]int{6, 6}}
+	if code := serve(t, handler, "POST", "/api/rollout", req, &resp); code != http.StatusOK {
+		t.Fatalf("status %d, want 200", code)
+	}
+	if resp.Trials != 36 || resp.StdDev != 0 || math.Abs(resp.Equity) != 1 {
+		t.Errorf("rollout with 6-6 = %+v, want the same single game every trial", resp)
+	}
+
+	for path, req := range map[string]RolloutRequest{
+		"/api/rollout":      {Position: pos, Trials: 1, InitialDice: [2]int{0, 3}},
+		"/api/cube/rollout": {Position: pos, Trials: 1, InitialDice: [2]int{6, 6}},
+	} {
+		data, _ := json.Marshal(req)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("POST", path, bytes.NewReader(data)))
+		var errResp ErrorResponse
+		json.NewDecoder(w.Body).Decode(&errResp)
+		if w.Code != http.StatusBadRequest || errResp.Code != "INVALID_DICE" {
+			t.Errorf("%s with initial dice %v: status %d, code %q; want 400 INVALID_DICE", path, req.InitialDice, w.Code, errResp.Code)
+		}
+	}
+}
+
+func TestRolloutCancelledByClient(t *testing.T) {
+	h := NewHandlers(getTestEngine(), "test")
+	body, _ := json.Marshal(RolloutRequest{Position: "4HPwATDgc/ABMA", Trials: 100000, Seed: 1})
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest("POST", "/api/rollout", bytes.NewReader(body)).WithContext(ctx)
+	before := runtime.NumGoroutine()
+
+	done := make(chan struct{})
+	go func() {
+		h.Rollout(httptest.NewRecorder(), req)
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("rollout still running 2s after the client went")
+	}
+
+	// The workers have stopped too
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines running, %d before the rollout", runtime.NumGoroutine(), before)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestEvaluateMoveAllowPartial(t *testing.T) {
+	eng, err := engine.NewEngine(engine.EngineOptions{})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	handler := NewServer(eng, DefaultConfig(), "test").Handler()
+
+	post := func(path string, req interface{}, resp interface{}) int {
+		t.Helper()
+		body, _ := json.Marshal(req)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("POST", path, bytes.NewReader(body)))
+		json.NewDecoder(w.Body).Decode(resp)
+		return w.Code
+	}
+
+	// The opponent has two checkers on its 6-point. The player on roll
+	// bears its last checker off from the ace-point on any roll, and from
+	// the 6-point with a 6
+	var last, board positionid.Board
+	last[1][0] = 1
+	last[0][5] = 2
+	board[1][5] = 1
+	board[0][5] = 2
+	pos := positionid.PositionID(board)
+
+	zero := 0
+	for _, partial := range []bool{true, false} {
+		var eval EvaluateResponse
+		if code := post("/api/evaluate", EvaluateRequest{Position: positionid.PositionID(last), Ply: &zero, AllowPartial: partial}, &eval); code != http.StatusOK {
+			t.Fatalf("evaluate status %d", code)
+		}
+		var moves MovesResponse
+		if code := post("/api/move", MoveRequest{Position: pos, Dice: [2]int{6, 1}, Ply: &zero, AllowPartial: partial}, &moves); code != http.StatusOK || len(moves.Moves) == 0 {
+			t.Fatalf("move status %d, %d moves", code, len(moves.Moves))
+		}
+
+		// With allow_partial the opponent's two checkers are all it has
+		if best := moves.Moves[0]; partial != (best.WinG == 100) || best.Win != 100 {
+			t.Errorf("allow_partial %v: best move %s wins %.1f%% with %.1f%% gammons", partial, best.Move, best.Win, best.WinG)
+		}
+		if partial != (eval.WinG == 100) || eval.Win != 100 {
+			t.Errorf("allow_partial %v: last roll wins %.1f%% with %.1f%% gammons", partial, eval.Win, eval.WinG)
+		}
+	}
+}
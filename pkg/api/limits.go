@@ -0,0 +1,215 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Limits bounds the size of API requests.
+type Limits struct {
+	MaxBodyBytes      int64            // Request body limit (default 64 KiB)
+	EndpointBodyBytes map[string]int64 // Per-path body limits overriding MaxBodyBytes
+	MaxPositions      int              // Max positions in an analyze-game request (default 1000)
+	MaxTrials         int              // Max rollout trials (default 100000)
+	MaxNumMoves       int              // Max num_moves for move queries (default 100)
+	MaxTimeLimitMs    int              // Max time_limit_ms for move queries (default 10000)
+	MaxWSInFlight     int              // Max requests a WebSocket connection has in flight (default 4)
+}
+
+// DefaultLimits returns Limits with sensible defaults.
+// Game analysis requests and match uploads get a larger body limit than
+// single positions.
+func DefaultLimits() Limits {
+	return Limits{
+		MaxBodyBytes: 64 << 10,
+		EndpointBodyBytes: map[string]int64{
+			"/api/tutor/game": 4 << 20,
+			"/api/matches":    4 << 20,
+		},
+		MaxPositions:   1000,
+		MaxTrials:      100000,
+		MaxNumMoves:    100,
+		MaxTimeLimitMs: 10000,
+		MaxWSInFlight:  4,
+	}
+}
+
+// withDefaults fills zero fields from DefaultLimits.
+func (l Limits) withDefaults() Limits {
+	d := DefaultLimits()
+	if l.MaxBodyBytes <= 0 {
+		l.MaxBodyBytes = d.MaxBodyBytes
+	}
+	if l.EndpointBodyBytes == nil {
+		l.EndpointBodyBytes = d.EndpointBodyBytes
+	}
+	if l.MaxPositions <= 0 {
+		l.MaxPositions = d.MaxPositions
+	}
+	if l.MaxTrials <= 0 {
+		l.MaxTrials = d.MaxTrials
+	}
+	if l.MaxNumMoves <= 0 {
+		l.MaxNumMoves = d.MaxNumMoves
+	}
+	if l.MaxTimeLimitMs <= 0 {
+		l.MaxTimeLimitMs = d.MaxTimeLimitMs
+	}
+	if l.MaxWSInFlight <= 0 {
+		l.MaxWSInFlight = d.MaxWSInFlight
+	}
+	return l
+}
+
+// bodyLimit returns the request body limit for a path.
+func (l Limits) bodyLimit(path string) int64 {
+	if n, ok := l.EndpointBodyBytes[path]; ok {
+		return n
+	}
+	return l.MaxBodyBytes
+}
+
+// exceeds returns an error naming field if n is above max.
+func exceeds(field string, n, max int) error {
+	if n > max {
+		return fmt.Errorf("%s must be at most %d, got %d", field, max, n)
+	}
+	return nil
+}
+
+// checkTimeLimit validates the time limit of a move request and the ply it
+// searches to.
+func checkTimeLimit(req *MoveRequest, l Limits) error {
+	if req.TimeLimitMs < 0 {
+		return fmt.Errorf("time_limit_ms must not be negative, got %d", req.TimeLimitMs)
+	}
+	if err := exceeds("time_limit_ms", req.TimeLimitMs, l.MaxTimeLimitMs); err != nil {
+		return err
+	}
+	if req.TimeLimitMs > 0 && req.Ply != nil && (*req.Ply < 0 || *req.Ply > MaxPly) {
+		return fmt.Errorf("ply must be 0-%d, got %d", MaxPly, *req.Ply)
+	}
+	return nil
+}
+
+// depth is how deep the server evaluates: the ply requests get when they name
+// none, the deepest ply and the most rollout trials it serves, and whether
+// requests beyond those are clamped or rejected.
+type depth struct {
+	evalDefault int  // Ply for evaluations that name none
+	moveDefault int  // Ply for move rankings that name none
+	maxPly      int  // Deepest ply served
+	maxTrials   int  // Most rollout trials served (0 = up to Limits.MaxTrials)
+	strict      bool // Reject requests beyond maxPly or maxTrials instead of clamping them
+}
+
+// defaultDepth serves every ply and as many trials as the limits allow.
+func defaultDepth() depth {
+	return depth{maxPly: MaxPly}
+}
+
+// configDepth returns the depth settings of config, with maxPly brought
+// within 0-MaxPly and the default plies within maxPly.
+func configDepth(config ServerConfig) depth {
+	d := depth{
+		evalDefault: config.DefaultEvalPly,
+		moveDefault: config.DefaultMovePly,
+		maxPly:      min(max(config.MaxPly, 0), MaxPly),
+		maxTrials:   max(config.MaxRolloutTrials, 0),
+		strict:      config.StrictLimits,
+	}
+	d.evalDefault = min(max(d.evalDefault, 0), d.maxPly)
+	d.moveDefault = min(max(d.moveDefault, 0), d.maxPly)
+	return d
+}
+
+// ply returns the ply to serve a request for: def if the request names none,
+// or the requested ply, clamped to maxPly unless the server is strict.
+// clamped reports whether the request was cut down.
+func (d depth) ply(requested *int, def int) (ply int, clamped bool, err error) {
+	if requested == nil {
+		return def, false, nil
+	}
+	ply = *requested
+	if ply < 0 || ply > MaxPly {
+		return 0, false, fmt.Errorf("ply must be 0-%d, got %d", MaxPly, ply)
+	}
+	if ply > d.maxPly {
+		if d.strict {
+			return 0, false, fmt.Errorf("ply must be at most %d on this server, got %d", d.maxPly, ply)
+		}
+		return d.maxPly, true, nil
+	}
+	return ply, false, nil
+}
+
+// movePly sets req.Ply to the ply to rank the moves of a move request at: the
+// server's default if it names none, or its maximum under a time limit.
+func (d depth) movePly(req *MoveRequest) (clamped bool, err error) {
+	def := d.moveDefault
+	if req.TimeLimitMs > 0 {
+		def = d.maxPly
+	}
+	ply, clamped, err := d.ply(req.Ply, def)
+	if err != nil {
+		return false, err
+	}
+	req.Ply = &ply
+	return clamped, nil
+}
+
+// defaultTrials is the number of trials of a rollout request that names none.
+const defaultTrials = 1296
+
+// trials returns the number of rollout trials to play for a request: 1296 if
+// it names none, or the requested number, clamped to maxTrials unless the
+// server is strict. Requests over the Limits.MaxTrials size limit are always
+// rejected.
+func (d depth) trials(requested int, l Limits) (trials int, clamped bool, err error) {
+	if err := exceeds("trials", requested, l.MaxTrials); err != nil {
+		return 0, false, err
+	}
+	if requested <= 0 {
+		trials = defaultTrials
+		if d.maxTrials > 0 {
+			trials = min(trials, d.maxTrials)
+		}
+		return trials, false, nil
+	}
+	if d.maxTrials > 0 && requested > d.maxTrials {
+		if d.strict {
+			return 0, false, exceeds("trials", requested, d.maxTrials)
+		}
+		return d.maxTrials, true, nil
+	}
+	return requested, false, nil
+}
+
+// decodeJSON decodes the request body into v, writing an error response on failure.
+// Bodies over the endpoint's size limit are rejected with 413 REQUEST_TOO_LARGE.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err == nil {
+		return true
+	}
+
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeError(w, http.StatusRequestEntityTooLarge,
+			fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit), "REQUEST_TOO_LARGE")
+		return false
+	}
+	writeError(w, http.StatusBadRequest, "invalid JSON", "INVALID_JSON")
+	return false
+}
+
+// newErrorID returns a random ID tying an error response to its log entry.
+func newErrorID() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,290 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// postJSON posts body to path on srv and decodes an error response.
+func postJSON(t *testing.T, srv *httptest.Server, path string, body interface{}) (int, ErrorResponse) {
+	t.Helper()
+
+	var data []byte
+	switch b := body.(type) {
+	case []byte:
+		data = b
+	default:
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			t.Fatalf("Marshal error: %v", err)
+		}
+	}
+
+	resp, err := http.Post(srv.URL+path, "application/json", bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("POST %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+
+	var errResp ErrorResponse
+	json.NewDecoder(resp.Body).Decode(&errResp)
+	return resp.StatusCode, errResp
+}
+
+// checkHealthy fails the test if the server no longer answers health checks.
+func checkHealthy(t *testing.T, srv *httptest.Server) {
+	t.Helper()
+	resp, err := http.Get(srv.URL + "/api/health")
+	if err != nil {
+		t.Fatalf("server down: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("health status = %d after error", resp.StatusCode)
+	}
+}
+
+func TestRecoverMiddleware(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+		var board []int
+		_ = board[3] // index out of range
+	})
+	mux.HandleFunc("/api/health", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	srv := httptest.NewServer(recoverMiddleware(mux))
+	defer srv.Close()
+
+	status, errResp := postJSON(t, srv, "/panic", map[string]string{})
+	if status != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", status)
+	}
+	if errResp.Code != "INTERNAL_ERROR" || errResp.ErrorID == "" {
+		t.Errorf("error = %+v, want INTERNAL_ERROR with an error ID", errResp)
+	}
+
+	// A second panic gets its own ID and the server keeps serving
+	_, second := postJSON(t, srv, "/panic", map[string]string{})
+	if second.ErrorID == errResp.ErrorID {
+		t.Errorf("error IDs repeat: %q", second.ErrorID)
+	}
+	checkHealthy(t, srv)
+}
+
+func TestBodyLimit(t *testing.T) {
+	config := DefaultConfig()
+	config.Limits = Limits{
+		MaxBodyBytes:      1 << 10,
+		EndpointBodyBytes: map[string]int64{"/api/tutor/game": 64 << 10},
+	}
+	srv := httptest.NewServer(NewServer(getTestEngine(), config, "test").Handler())
+	defer srv.Close()
+
+	// Valid JSON, just too big
+	big := []byte(`{"position":"4HPwATDgc/ABMA","padding":"` + strings.Repeat("x", 4<<10) + `"}`)
+	status, errResp := postJSON(t, srv, "/api/evaluate", big)
+	if status != http.StatusRequestEntityTooLarge || errResp.Code != "REQUEST_TOO_LARGE" {
+		t.Errorf("oversized evaluate: status %d error %+v, want 413 REQUEST_TOO_LARGE", status, errResp)
+	}
+
+	// The same body fits the larger game analysis limit
+	game := AnalyzeGameRequest{Positions: []GamePosition{
+		{Position: "4HPwATDgc/ABMA", Dice: [2]int{3, 1}, Move: "8/5 6/5", Player: 0},
+	}}
+	gameBody, _ := json.Marshal(game)
+	gameBody = append(gameBody[:len(gameBody)-1], []byte(`,"padding":"`+strings.Repeat("x", 4<<10)+`"}`)...)
+	if status, errResp := postJSON(t, srv, "/api/tutor/game", gameBody); status != http.StatusOK {
+		t.Errorf("game analysis: status %d error %+v, want 200", status, errResp)
+	}
+
+	checkHealthy(t, srv)
+}
+
+func TestRequestCaps(t *testing.T) {
+	config := DefaultConfig()
+	config.Limits = Limits{MaxPositions: 2, MaxTrials: 100, MaxNumMoves: 10}
+	srv := httptest.NewServer(NewServer(getTestEngine(), config, "test").Handler())
+	defer srv.Close()
+
+	pos := GamePosition{Position: "4HPwATDgc/ABMA", Dice: [2]int{3, 1}, Move: "8/5 6/5"}
+
+	tests := []struct {
+		name string
+		path string
+		body interface{}
+		code string
+	}{
+		{"num_moves", "/api/move",
+			MoveRequest{Position: "4HPwATDgc/ABMA", Dice: [2]int{3, 1}, NumMoves: 11}, "TOO_MANY_MOVES"},
+		{"fibsboard num_moves", "/api/fibsboard",
+			FIBSBoardRequest{Board: "board:You:Opponent:5:0:0:0:2:0:0:0:0:5:0:3:0:0:0:0:5:0:0:0:0:0:0:0:0:2:0:-2:0:0:0:0:0:-5:0:-3:0:0:0:0:-5:0:0:0:0:0:0:0:0:-2:0:1:3:1:0:0:1:1:1:0:1:-1:0:25:0:0:0:0:0:0:0:0", NumMoves: 11}, "TOO_MANY_MOVES"},
+		{"trials", "/api/rollout",
+			RolloutRequest{Position: "4HPwATDgc/ABMA", Trials: 101}, "TOO_MANY_TRIALS"},
+		{"positions", "/api/tutor/game",
+			AnalyzeGameRequest{Positions: []GamePosition{pos, pos, pos}}, "TOO_MANY_POSITIONS"},
+		{"player", "/api/tutor/game",
+			AnalyzeGameRequest{Positions: []GamePosition{{Position: pos.Position, Player: 5}}}, "INVALID_PLAYER"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, errResp := postJSON(t, srv, tt.path, tt.body)
+			if status != http.StatusBadRequest || errResp.Code != tt.code {
+				t.Errorf("status %d error %+v, want 400 %s", status, errResp, tt.code)
+			}
+		})
+	}
+
+	// At the limit is fine
+	status, errResp := postJSON(t, srv, "/api/move",
+		MoveRequest{Position: "4HPwATDgc/ABMA", Dice: [2]int{3, 1}, NumMoves: 10})
+	if status != http.StatusOK {
+		t.Errorf("num_moves at limit: status %d error %+v", status, errResp)
+	}
+}
+
+func TestWebSocketPanicRecovered(t *testing.T) {
+	// No engine: evaluating dereferences nil and panics inside the handler
+	c := &WSClient{handlers: NewHandlers(nil, "test"), sendChan: make(chan WSResponse, 1)}
+
+	payload, _ := json.Marshal(EvaluateRequest{Position: "4HPwATDgc/ABMA"})
+	c.handleMessageSafely(context.Background(), WSMessage{Type: "evaluate", ID: "req-1", Payload: payload})
+
+	resp := <-c.sendChan
+	if resp.Type != "error" || resp.ID != "req-1" || !strings.HasPrefix(resp.Error, "internal error (id ") {
+		t.Errorf("response = %+v, want internal error for req-1", resp)
+	}
+}
+
+func intPtr(n int) *int { return &n }
+
+// depthResult holds the fields of evaluate, move and rollout responses that
+// show the depth they were served at.
+type depthResult struct {
+	Ply             int                 `json:"ply"`
+	Moves           []struct{ Ply int } `json:"moves"`
+	Trials          int                 `json:"trials"`
+	TrialsCompleted int                 `json:"trials_completed"`
+	Clamped         bool                `json:"clamped"`
+}
+
+// served returns the ply or number of trials a response was served at.
+func (r depthResult) served() int {
+	switch {
+	case len(r.Moves) > 0:
+		return r.Moves[0].Ply
+	case r.Trials > 0:
+		return r.Trials
+	case r.TrialsCompleted > 0:
+		return r.TrialsCompleted
+	}
+	return r.Ply
+}
+
+// postDepth posts body to path on srv, returning the status, the error code
+// and the decoded response.
+func postDepth(t *testing.T, srv *httptest.Server, path string, body interface{}) (int, string, depthResult) {
+	t.Helper()
+	data, _ := json.Marshal(body)
+	resp, err := http.Post(srv.URL+path, "application/json", bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("POST %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(resp.Body)
+
+	var errResp ErrorResponse
+	var result depthResult
+	json.Unmarshal(raw, &errResp)
+	json.Unmarshal(raw, &result)
+	return resp.StatusCode, errResp.Code, result
+}
+
+// wsDepth sends a message on ws and returns its error, if any, and the
+// decoded result, skipping rollout progress.
+func wsDepth(t *testing.T, ws *websocket.Conn, typ string, payload interface{}) (string, depthResult) {
+	t.Helper()
+	resp, _ := wsCall(t, ws, typ, typ, payload)
+	for resp.Type == "progress" {
+		resp, _ = readWS(t, ws, 10*time.Second)
+	}
+	var result depthResult
+	if resp.Type == "result" {
+		json.Unmarshal(resp.Payload.(json.RawMessage), &result)
+	}
+	return resp.Error, result
+}
+
+func TestDepthLimits(t *testing.T) {
+	const pos = "4HPwATDgc/ABMA"
+	tests := []struct {
+		name    string
+		path    string // REST endpoint
+		typ     string // WebSocket message type
+		body    interface{}
+		served  int  // Ply or trials served
+		clamped bool // Answered with "clamped": true, or rejected when strict
+	}{
+		{"evaluate default", "/api/evaluate", "evaluate", EvaluateRequest{Position: pos}, 1, false},
+		{"evaluate ply 0", "/api/evaluate", "evaluate", EvaluateRequest{Position: pos, Ply: intPtr(0)}, 0, false},
+		{"evaluate over max", "/api/evaluate", "evaluate", EvaluateRequest{Position: pos, Ply: intPtr(2)}, 1, true},
+		{"move default", "/api/move", "move", MoveRequest{Position: pos, Dice: [2]int{3, 1}}, 0, false},
+		{"move at max", "/api/move", "move", MoveRequest{Position: pos, Dice: [2]int{3, 1}, Ply: intPtr(1)}, 1, false},
+		{"move over max", "/api/move", "move", MoveRequest{Position: pos, Dice: [2]int{3, 1}, Ply: intPtr(2)}, 1, true},
+		{"rollout default", "/api/rollout", "rollout", WSRolloutRequest{Position: pos, Truncate: 2}, 36, false},
+		{"rollout under max", "/api/rollout", "rollout", WSRolloutRequest{Position: pos, Trials: 18, Truncate: 2}, 18, false},
+		{"rollout over max", "/api/rollout", "rollout", WSRolloutRequest{Position: pos, Trials: 72, Truncate: 2}, 36, true},
+	}
+
+	for _, strict := range []bool{false, true} {
+		config := DefaultConfig()
+		config.DefaultEvalPly = 1
+		config.MaxPly = 1
+		config.MaxRolloutTrials = 36
+		config.StrictLimits = strict
+		srv := httptest.NewServer(NewServer(getTestEngine(), config, "test").Handler())
+		ws := dialWS(t, srv)
+
+		for _, tt := range tests {
+			rejected := strict && tt.clamped
+			status, code, rest := postDepth(t, srv, tt.path, tt.body)
+			wsErr, wsResult := wsDepth(t, ws, tt.typ, tt.body)
+			switch {
+			case rejected:
+				if status != http.StatusBadRequest || (code != "INVALID_PLY" && code != "TOO_MANY_TRIALS") {
+					t.Errorf("strict %s: status %d code %q, want 400 INVALID_PLY or TOO_MANY_TRIALS", tt.name, status, code)
+				}
+				if wsErr == "" {
+					t.Errorf("strict %s over WebSocket: %+v, want an error", tt.name, wsResult)
+				}
+			case status != http.StatusOK || wsErr != "":
+				t.Errorf("strict %v %s: status %d code %q, WebSocket error %q", strict, tt.name, status, code, wsErr)
+			default:
+				for transport, got := range map[string]depthResult{"REST": rest, "WebSocket": wsResult} {
+					if got.served() != tt.served || got.Clamped != tt.clamped {
+						t.Errorf("strict %v %s over %s: served %d clamped %v, want %d %v",
+							strict, tt.name, transport, got.served(), got.Clamped, tt.served, tt.clamped)
+					}
+				}
+			}
+		}
+
+		// Plies the engine cannot search are invalid whatever the server's mode
+		if status, code, _ := postDepth(t, srv, "/api/evaluate", EvaluateRequest{Position: pos, Ply: intPtr(MaxPly + 1)}); status != http.StatusBadRequest || code != "INVALID_PLY" {
+			t.Errorf("strict %v ply %d: status %d code %q, want 400 INVALID_PLY", strict, MaxPly+1, status, code)
+		}
+
+		ws.Close()
+		srv.Close()
+	}
+}
@@ -0,0 +1,633 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yourusername/bgengine/pkg/engine"
+	"github.com/yourusername/bgengine/pkg/match"
+)
+
+// The match endpoints keep uploaded matches in the server's match store
+// (ServerConfig.MatchStore) so they can be listed, fetched and analyzed
+// again later. Analysis runs on the slow worker pool, and only one analysis
+// of a match runs at a time.
+
+// Match listing page sizes.
+const (
+	defaultMatchPage = 20
+	maxMatchPage     = 100
+)
+
+// MatchUploadRequest is the request body for POST /api/matches.
+type MatchUploadRequest struct {
+	Format  string `json:"format,omitempty"` // "mat" or "sgf" (detected from the content when empty)
+	Content string `json:"content"`          // The match file
+}
+
+// MatchSummaryResponse describes a stored match in listings.
+type MatchSummaryResponse struct {
+	ID           string    `json:"id"`
+	Player1      string    `json:"player1"`
+	Player2      string    `json:"player2"`
+	MatchLength  int       `json:"match_length"`             // 0 = money session
+	Date         string    `json:"date,omitempty"`           // Date the match was played (YYYY-MM-DD), or as the match file records it if not understood
+	Ratings      []float64 `json:"player_ratings,omitempty"` // [player1, player2] ratings recorded in the match file (0 = not recorded)
+	Games        int       `json:"games"`                    // Number of games
+	Uploaded     string    `json:"uploaded"`                 // When the match was uploaded (RFC 3339)
+	Analyzed     bool      `json:"analyzed"`                 // Whether an analysis is stored
+	AnalysisPly  int       `json:"analysis_ply,omitempty"`   // Ply of the stored analysis
+	AnalyzedAt   string    `json:"analyzed_at,omitempty"`    // When the stored analysis was made (RFC 3339)
+	ErrorPerMove []float64 `json:"error_per_move,omitempty"` // [player1, player2] EPM of the stored analysis
+}
+
+// MatchListResponse is the response for GET /api/matches.
+type MatchListResponse struct {
+	Matches []MatchSummaryResponse `json:"matches"` // Most recently uploaded first
+	Total   int                    `json:"total"`   // Number of stored matches
+	Offset  int                    `json:"offset"`
+	Limit   int                    `json:"limit"`
+}
+
+// MatchResponse is the response for GET /api/matches/{id}: the whole match
+// as imported.
+type MatchResponse struct {
+	ID          string              `json:"id"`
+	Player1     string              `json:"player1"`
+	Player2     string              `json:"player2"`
+	MatchLength int                 `json:"match_length"`
+	Date        string              `json:"date,omitempty"`           // YYYY-MM-DD, or as the match file records it if not understood
+	PlayedAt    string              `json:"played_at,omitempty"`      // When the match was played (RFC 3339), if the file's date was understood
+	DateText    string              `json:"date_text,omitempty"`      // Date as the match file records it
+	Ratings     []float64           `json:"player_ratings,omitempty"` // [player1, player2] ratings recorded in the match file (0 = not recorded)
+	Event       string              `json:"event,omitempty"`
+	EventInfo   string              `json:"event_info,omitempty"` // The site's identifier of the match
+	Round       string              `json:"round,omitempty"`
+	Place       string              `json:"place,omitempty"`
+	Annotator   string              `json:"annotator,omitempty"`
+	Comment     string              `json:"comment,omitempty"`
+	Variant     string              `json:"variant"` // "Backgammon" or "Nackgammon"
+	Tags        []MatchTagResponse  `json:"tags,omitempty"`
+	Games       []MatchGameResponse `json:"games"`
+}
+
+// MatchTagResponse is a header tag of the match file the importer does not
+// interpret, such as a site's game clock.
+type MatchTagResponse struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// MatchGameResponse is one game of a stored match.
+type MatchGameResponse struct {
+	Number   int                   `json:"number"`
+	Score    [2]int                `json:"score"` // [player1, player2] at the start of the game
+	Crawford bool                  `json:"crawford"`
+	Winner   int                   `json:"winner"` // 0 = player1, 1 = player2, -1 = unfinished
+	Points   int                   `json:"points"`
+	Result   string                `json:"result"` // "single", "gammon", "drop", "in_progress", ...
+	Actions  []MatchActionResponse `json:"actions"`
+
+	AutomaticDoubles int `json:"automatic_doubles,omitempty"` // Automatic doubles before the first move (money play)
+}
+
+// MatchActionResponse is one action of a game.
+type MatchActionResponse struct {
+	Type   string `json:"type"`            // "roll", "move", "double", "take", "pass", ...
+	Player int    `json:"player"`          // 0 = player1, 1 = player2
+	Dice   []int  `json:"dice,omitempty"`  // Roll, for "roll"
+	Move   string `json:"move,omitempty"`  // Move from the mover's side, for "move"
+	Value  int    `json:"value,omitempty"` // Cube value or resignation level
+}
+
+// MatchAnalysisResponse is the stored analysis of a match, returned by
+// GET /api/matches/{id}/analysis and POST /api/matches/{id}/analyze.
+type MatchAnalysisResponse struct {
+	ID         string                `json:"id"`
+	Ply        int                   `json:"ply"`         // Checker play analysis ply
+	AnalyzedAt string                `json:"analyzed_at"` // RFC 3339
+	Analysis   *engine.MatchAnalysis `json:"analysis"`
+	Clamped    bool                  `json:"clamped,omitempty"` // The requested ply was beyond the server's maximum and was lowered to it
+}
+
+// ndjsonContentType is the media type of a streamed match analysis: one JSON
+// object a line, a MatchStreamLine each.
+const ndjsonContentType = "application/x-ndjson"
+
+// MatchStreamLine is one line of a match analysis streamed as NDJSON (Accept:
+// application/x-ndjson): a header first, then a decision line for each
+// decision as it is analyzed, and a trailer with the totals last. A stream
+// that fails after it has started ends with an error line instead of the
+// trailer. Type says which of the other fields a line carries.
+type MatchStreamLine struct {
+	Type     string                 `json:"type"` // "header", "decision", "trailer" or "error"
+	Header   *MatchStreamHeader     `json:"header,omitempty"`
+	Decision *engine.DecisionResult `json:"decision,omitempty"`
+	Trailer  *MatchStreamTrailer    `json:"trailer,omitempty"`
+	Error    *ErrorResponse         `json:"error,omitempty"`
+}
+
+// MatchStreamHeader starts a streamed match analysis.
+type MatchStreamHeader struct {
+	ID          string `json:"id"`
+	Player1     string `json:"player1"`
+	Player2     string `json:"player2"`
+	MatchLength int    `json:"match_length"` // 0 = money session
+	Games       int    `json:"games"`
+	Ply         int    `json:"ply"`               // Checker play analysis ply
+	MET         string `json:"met,omitempty"`     // Match equity table requested
+	Clamped     bool   `json:"clamped,omitempty"` // The requested ply was beyond the server's maximum and was lowered to it
+}
+
+// MatchStreamTrailer ends a streamed match analysis with the totals of the
+// analysis, as the buffered response has them. The errors, timeline and roll
+// luck are left out: the decision lines carry them.
+type MatchStreamTrailer struct {
+	AnalyzedAt    string                     `json:"analyzed_at"` // RFC 3339
+	TotalGames    int                        `json:"total_games"`
+	TotalMoves    int                        `json:"total_moves"`
+	TotalCubeActs int                        `json:"total_cube"`
+	PlayerStats   [2]engine.PlayerAnalysis   `json:"player_stats"`
+	PlayerLuck    [2]engine.LuckAnalysis     `json:"player_luck"`
+	GameStats     []engine.GameAnalysis      `json:"game_stats"`
+	LuckAdjusted  *engine.LuckAdjustedResult `json:"luck_adjusted,omitempty"`
+}
+
+// UploadMatch handles POST /api/matches
+func (h *Handlers) UploadMatch(w http.ResponseWriter, r *http.Request) {
+	store := h.matchStore(w)
+	if store == nil {
+		return
+	}
+
+	var req MatchUploadRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if strings.TrimSpace(req.Content) == "" {
+		writeError(w, http.StatusBadRequest, "content is required", "MISSING_CONTENT")
+		return
+	}
+
+	format := strings.ToLower(req.Format)
+	if format == "" {
+		format = "mat"
+		if strings.HasPrefix(strings.TrimSpace(req.Content), "(;") {
+			format = "sgf"
+		}
+	}
+
+	var m *match.Match
+	var err error
+	switch format {
+	case "mat":
+		m, err = match.ImportMAT(strings.NewReader(req.Content))
+	case "sgf":
+		m, err = match.ImportSGF(strings.NewReader(req.Content))
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("unknown format %q (want mat or sgf)", req.Format), "INVALID_FORMAT")
+		return
+	}
+	if err == nil && len(m.Games) == 0 {
+		err = errors.New("no games found")
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid %s match: %v", format, err), "INVALID_MATCH")
+		return
+	}
+
+	id, err := store.Add(m)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error(), "MATCH_STORE_ERROR")
+		return
+	}
+	sm, err := store.Get(id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error(), "MATCH_STORE_ERROR")
+		return
+	}
+	writeJSON(w, http.StatusCreated, matchSummaryResponse(sm.Info()))
+}
+
+// ListMatches handles GET /api/matches
+func (h *Handlers) ListMatches(w http.ResponseWriter, r *http.Request) {
+	store := h.matchStore(w)
+	if store == nil {
+		return
+	}
+
+	query := r.URL.Query()
+	offset, limit := 0, defaultMatchPage
+	var err error
+	if s := query.Get("offset"); s != "" {
+		if offset, err = strconv.Atoi(s); err != nil || offset < 0 {
+			writeError(w, http.StatusBadRequest, "offset must be a non-negative integer", "INVALID_OFFSET")
+			return
+		}
+	}
+	if s := query.Get("limit"); s != "" {
+		if limit, err = strconv.Atoi(s); err != nil || limit < 1 || limit > maxMatchPage {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("limit must be 1-%d", maxMatchPage), "INVALID_LIMIT")
+			return
+		}
+	}
+
+	infos, total, err := store.List(offset, limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error(), "MATCH_STORE_ERROR")
+		return
+	}
+
+	resp := MatchListResponse{
+		Matches: make([]MatchSummaryResponse, len(infos)),
+		Total:   total,
+		Offset:  offset,
+		Limit:   limit,
+	}
+	for i, info := range infos {
+		resp.Matches[i] = matchSummaryResponse(info)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// GetMatch handles GET /api/matches/{id}
+func (h *Handlers) GetMatch(w http.ResponseWriter, r *http.Request) {
+	sm := h.storedMatch(w, r)
+	if sm == nil {
+		return
+	}
+	writeJSON(w, http.StatusOK, matchResponse(sm))
+}
+
+// GetMatchAnalysis handles GET /api/matches/{id}/analysis
+func (h *Handlers) GetMatchAnalysis(w http.ResponseWriter, r *http.Request) {
+	sm := h.storedMatch(w, r)
+	if sm == nil {
+		return
+	}
+	if sm.Analysis == nil {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("match %s has not been analyzed", sm.ID), "ANALYSIS_NOT_FOUND")
+		return
+	}
+	if acceptsNDJSON(r) {
+		// Only the errors of a stored analysis are kept, so they are the
+		// decisions there are to stream
+		s := newMatchStream(w)
+		header := matchStreamHeader(sm)
+		header.Ply = sm.AnalysisPly
+		s.write(MatchStreamLine{Type: "header", Header: &header})
+		for _, d := range storedDecisions(sm.Analysis) {
+			if s.write(MatchStreamLine{Type: "decision", Decision: &d}) != nil {
+				return
+			}
+		}
+		s.write(MatchStreamLine{Type: "trailer", Trailer: matchStreamTrailer(sm)})
+		return
+	}
+	writeJSON(w, http.StatusOK, matchAnalysisResponse(sm))
+}
+
+// AnalyzeMatch handles POST /api/matches/{id}/analyze?ply=N&met=NAME. The new
+// analysis replaces the stored one. With Accept: application/x-ndjson the
+// analysis is streamed decision by decision as it is made (see
+// MatchStreamLine).
+func (h *Handlers) AnalyzeMatch(w http.ResponseWriter, r *http.Request) {
+	store := h.matchStore(w)
+	if store == nil {
+		return
+	}
+
+	var requested *int
+	if s := r.URL.Query().Get("ply"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("ply must be 0-%d", MaxPly), "INVALID_PLY")
+			return
+		}
+		requested = &n
+	}
+	ply, clamped, err := h.depth.ply(requested, 0)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error(), "INVALID_PLY")
+		return
+	}
+
+	if !h.checkMET(w, r.URL.Query().Get("met")) {
+		return
+	}
+
+	sm := h.storedMatch(w, r)
+	if sm == nil {
+		return
+	}
+
+	if _, busy := h.analyzing.LoadOrStore(sm.ID, true); busy {
+		writeError(w, http.StatusConflict, fmt.Sprintf("match %s is already being analyzed", sm.ID), "ANALYSIS_IN_PROGRESS")
+		return
+	}
+	defer h.analyzing.Delete(sm.ID)
+
+	if h.pool != nil {
+		identity := h.identity(r)
+		if err := h.pool.AcquireSlowAs(r.Context(), identity); err != nil {
+			writeError(w, http.StatusServiceUnavailable, "server busy", "SERVER_BUSY")
+			return
+		}
+		defer h.pool.ReleaseSlowAs(identity)
+	}
+
+	opts := engine.DefaultMatchAnalysisOptions()
+	opts.Ply = ply
+	opts.MET = r.URL.Query().Get("met")
+	opts.IncludeLuck = true
+
+	// Stop between decisions if the client goes
+	opts.OnDecision = func(engine.DecisionResult) error {
+		return r.Context().Err()
+	}
+
+	// Once a stream has started its status is sent, so errors go in a line
+	fail := writeError
+	var s *matchStream
+	if acceptsNDJSON(r) {
+		s = newMatchStream(w)
+		header := matchStreamHeader(sm)
+		header.Ply, header.MET, header.Clamped = ply, opts.MET, clamped
+		s.write(MatchStreamLine{Type: "header", Header: &header})
+		opts.OnDecision = func(d engine.DecisionResult) error {
+			if err := s.write(MatchStreamLine{Type: "decision", Decision: &d}); err != nil {
+				return err
+			}
+			return r.Context().Err()
+		}
+		fail = func(_ http.ResponseWriter, _ int, msg, code string) {
+			s.write(MatchStreamLine{Type: "error", Error: &ErrorResponse{Error: msg, Code: code}})
+		}
+	}
+
+	analysis, err := sm.Match.Analyze(h.engine, opts)
+	if err != nil {
+		var posErr *engine.PositionError
+		if errors.As(err, &posErr) {
+			fail(w, http.StatusUnprocessableEntity, err.Error(), "INVALID_MATCH")
+			return
+		}
+		fail(w, http.StatusInternalServerError, err.Error(), "ANALYSIS_ERROR")
+		return
+	}
+
+	if err := store.SetAnalysis(sm.ID, analysis, ply); err != nil {
+		fail(w, http.StatusInternalServerError, err.Error(), "MATCH_STORE_ERROR")
+		return
+	}
+	if sm, err = store.Get(sm.ID); err != nil {
+		fail(w, http.StatusInternalServerError, err.Error(), "MATCH_STORE_ERROR")
+		return
+	}
+	if s != nil {
+		s.write(MatchStreamLine{Type: "trailer", Trailer: matchStreamTrailer(sm)})
+		return
+	}
+	resp := matchAnalysisResponse(sm)
+	resp.Clamped = clamped
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// matchStore returns the server's match store, writing an error response and
+// returning nil if it has none.
+func (h *Handlers) matchStore(w http.ResponseWriter) match.Store {
+	if h.matches == nil {
+		writeError(w, http.StatusNotImplemented, "no match store is configured", "NO_MATCH_STORE")
+	}
+	return h.matches
+}
+
+// storedMatch returns the match named by the request's {id}, writing an error
+// response and returning nil if there is none.
+func (h *Handlers) storedMatch(w http.ResponseWriter, r *http.Request) *match.StoredMatch {
+	store := h.matchStore(w)
+	if store == nil {
+		return nil
+	}
+
+	id := r.PathValue("id")
+	sm, err := store.Get(id)
+	if err != nil {
+		writeMatchStoreError(w, id, err)
+		return nil
+	}
+	return sm
+}
+
+// writeMatchStoreError writes the error response for a failed lookup of match id.
+func writeMatchStoreError(w http.ResponseWriter, id string, err error) {
+	if errors.Is(err, match.ErrMatchNotFound) {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("no stored match %q", id), "MATCH_NOT_FOUND")
+		return
+	}
+	writeError(w, http.StatusInternalServerError, err.Error(), "MATCH_STORE_ERROR")
+}
+
+func matchSummaryResponse(info match.MatchInfo) MatchSummaryResponse {
+	resp := MatchSummaryResponse{
+		ID:          info.ID,
+		Player1:     info.Player1,
+		Player2:     info.Player2,
+		MatchLength: info.MatchLength,
+		Date:        info.Date,
+		Ratings:     ratings(info.Ratings),
+		Games:       info.Games,
+		Uploaded:    info.Uploaded.UTC().Format(time.RFC3339),
+		Analyzed:    info.Analyzed,
+	}
+	if info.Analyzed {
+		resp.AnalysisPly = info.AnalysisPly
+		resp.AnalyzedAt = info.AnalyzedAt.UTC().Format(time.RFC3339)
+		resp.ErrorPerMove = info.ErrorPerMove[:]
+	}
+	return resp
+}
+
+// ratings returns the players' ratings for a response, or nil if neither
+// was recorded.
+func ratings(r [2]float64) []float64 {
+	if r == [2]float64{} {
+		return nil
+	}
+	return r[:]
+}
+
+func matchResponse(sm *match.StoredMatch) MatchResponse {
+	m := sm.Match
+	resp := MatchResponse{
+		ID:          sm.ID,
+		Player1:     m.Player1,
+		Player2:     m.Player2,
+		MatchLength: m.MatchLength,
+		Date:        m.DateString(),
+		DateText:    m.DateText,
+		Ratings:     ratings(m.PlayerRatings),
+		Event:       m.Event,
+		EventInfo:   m.EventInfo,
+		Round:       m.Round,
+		Place:       m.Place,
+		Annotator:   m.Annotator,
+		Comment:     m.Comment,
+		Variant:     m.Variant.String(),
+		Games:       make([]MatchGameResponse, len(m.Games)),
+	}
+	if !m.Date.IsZero() {
+		resp.PlayedAt = m.Date.UTC().Format(time.RFC3339)
+	}
+	for _, tag := range m.Tags {
+		resp.Tags = append(resp.Tags, MatchTagResponse{Name: tag.Name, Value: tag.Value})
+	}
+	for i, g := range m.Games {
+		game := MatchGameResponse{
+			Number:   g.Number,
+			Score:    [2]int{g.Score1, g.Score2},
+			Crawford: g.Crawford,
+			Winner:   g.Winner,
+			Points:   g.Points,
+			Result:   g.Result.String(),
+			Actions:  make([]MatchActionResponse, len(g.Actions)),
+
+			AutomaticDoubles: g.AutomaticDoubles,
+		}
+		for j, a := range g.Actions {
+			action := MatchActionResponse{Type: a.Type.String(), Player: a.Player, Value: a.Value}
+			switch a.Type {
+			case match.ActionRoll:
+				action.Dice = a.Dice[:]
+			case match.ActionMove:
+				action.Move = a.MoveNotation()
+			}
+			game.Actions[j] = action
+		}
+		resp.Games[i] = game
+	}
+	return resp
+}
+
+func matchAnalysisResponse(sm *match.StoredMatch) MatchAnalysisResponse {
+	return MatchAnalysisResponse{
+		ID:         sm.ID,
+		Ply:        sm.AnalysisPly,
+		AnalyzedAt: sm.AnalyzedAt.UTC().Format(time.RFC3339),
+		Analysis:   sm.Analysis,
+	}
+}
+
+// acceptsNDJSON reports whether the client asks for a streamed analysis.
+func acceptsNDJSON(r *http.Request) bool {
+	for _, accept := range r.Header.Values("Accept") {
+		for _, part := range strings.Split(accept, ",") {
+			if t, _, err := mime.ParseMediaType(strings.TrimSpace(part)); err == nil && t == ndjsonContentType {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchStream writes MatchStreamLines, flushing each so that the client
+// sees it at once.
+type matchStream struct {
+	w   http.ResponseWriter
+	enc *json.Encoder
+}
+
+func newMatchStream(w http.ResponseWriter) *matchStream {
+	w.Header().Set("Content-Type", ndjsonContentType)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	return &matchStream{w: w, enc: json.NewEncoder(w)}
+}
+
+func (s *matchStream) write(line MatchStreamLine) error {
+	if err := s.enc.Encode(line); err != nil {
+		return err
+	}
+	if f, ok := s.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+func matchStreamHeader(sm *match.StoredMatch) MatchStreamHeader {
+	return MatchStreamHeader{
+		ID:          sm.ID,
+		Player1:     sm.Match.Player1,
+		Player2:     sm.Match.Player2,
+		MatchLength: sm.Match.MatchLength,
+		Games:       len(sm.Match.Games),
+	}
+}
+
+func matchStreamTrailer(sm *match.StoredMatch) *MatchStreamTrailer {
+	a := sm.Analysis
+	return &MatchStreamTrailer{
+		AnalyzedAt:    sm.AnalyzedAt.UTC().Format(time.RFC3339),
+		TotalGames:    a.TotalGames,
+		TotalMoves:    a.TotalMoves,
+		TotalCubeActs: a.TotalCubeActs,
+		PlayerStats:   a.PlayerStats,
+		PlayerLuck:    a.PlayerLuck,
+		GameStats:     a.GameStats,
+		LuckAdjusted:  a.LuckAdjusted,
+	}
+}
+
+// storedDecisions returns the move and cube errors of a stored analysis as
+// decisions, in the order they were made. A cube action numbered with a move
+// comes after it, before the next roll.
+func storedDecisions(a *engine.MatchAnalysis) []engine.DecisionResult {
+	decisions := make([]engine.DecisionResult, 0, len(a.MoveErrors)+len(a.CubeErrors))
+	for _, e := range a.MoveErrors {
+		decisions = append(decisions, engine.DecisionResult{
+			GameNumber: e.GameNumber,
+			MoveNumber: e.MoveNumber,
+			Player:     e.Player,
+			Kind:       "move",
+			Position:   e.Position,
+			Dice:       e.Dice,
+			Played:     e.Played,
+			Best:       e.Best,
+			EquityLoss: e.EquityLoss,
+			Skill:      e.Skill,
+			SkillStr:   e.SkillStr,
+		})
+	}
+	for _, e := range a.CubeErrors {
+		decisions = append(decisions, engine.DecisionResult{
+			GameNumber: e.GameNumber,
+			MoveNumber: e.MoveNumber,
+			Player:     e.Player,
+			Kind:       "cube",
+			Position:   e.Position,
+			Played:     e.PlayedStr,
+			Best:       e.OptimalStr,
+			EquityLoss: e.EquityLoss,
+			Skill:      e.Skill,
+			SkillStr:   e.SkillStr,
+		})
+	}
+	sort.SliceStable(decisions, func(i, j int) bool {
+		a, b := decisions[i], decisions[j]
+		if a.GameNumber != b.GameNumber {
+			return a.GameNumber < b.GameNumber
+		}
+		if a.MoveNumber != b.MoveNumber {
+			return a.MoveNumber < b.MoveNumber
+		}
+		return a.Kind == "move" && b.Kind == "cube"
+	})
+	return decisions
+}
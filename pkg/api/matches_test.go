@@ -0,0 +1,298 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/yourusername/bgengine/pkg/engine"
+	"github.com/yourusername/bgengine/pkg/match"
+)
+
+// matchServer returns the handler of a server keeping matches in dir, and
+// the server itself.
+func matchServer(t *testing.T, dir string) (*Server, http.Handler) {
+	t.Helper()
+	store, err := match.OpenFileStore(dir)
+	if err != nil {
+		t.Fatalf("OpenFileStore error: %v", err)
+	}
+	config := DefaultConfig()
+	config.MatchStore = store
+	srv := NewServer(getTestEngine(), config, "test")
+	return srv, srv.Handler()
+}
+
+// serve sends a request to handler and decodes a JSON response into v.
+func serve(t *testing.T, handler http.Handler, method, path string, body interface{}, v interface{}) int {
+	t.Helper()
+	var data []byte
+	if body != nil {
+		data, _ = json.Marshal(body)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(method, path, bytes.NewReader(data)))
+	if v != nil && w.Code < 300 {
+		if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+			t.Fatalf("%s %s: decode error: %v\n%s", method, path, err, w.Body.String())
+		}
+	}
+	return w.Code
+}
+
+func TestMatchStoreEndpoints(t *testing.T) {
+	fixture, err := os.ReadFile("../match/testdata/beavers.mat")
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	dir := t.TempDir()
+	_, handler := matchServer(t, dir)
+
+	var uploaded MatchSummaryResponse
+	if code := serve(t, handler, "POST", "/api/matches", MatchUploadRequest{Content: string(fixture)}, &uploaded); code != http.StatusCreated {
+		t.Fatalf("upload status = %d, want 201", code)
+	}
+	if uploaded.ID == "" || uploaded.Player1 != "Alice" || uploaded.Player2 != "Bob" || uploaded.Games != 2 || uploaded.Analyzed {
+		t.Errorf("upload = %+v", uploaded)
+	}
+
+	// The same match as SGF
+	m, err := match.ImportMAT(bytes.NewReader(fixture))
+	if err != nil {
+		t.Fatalf("ImportMAT error: %v", err)
+	}
+	var sgf bytes.Buffer
+	match.ExportSGF(&sgf, m)
+	var second MatchSummaryResponse
+	if code := serve(t, handler, "POST", "/api/matches", MatchUploadRequest{Content: sgf.String()}, &second); code != http.StatusCreated {
+		t.Fatalf("SGF upload status = %d, want 201", code)
+	}
+
+	if code := serve(t, handler, "GET", "/api/matches/"+uploaded.ID+"/analysis", nil, nil); code != http.StatusNotFound {
+		t.Errorf("analysis before analyzing: status %d, want 404", code)
+	}
+
+	var analyzed MatchAnalysisResponse
+	if code := serve(t, handler, "POST", "/api/matches/"+uploaded.ID+"/analyze?ply=1", nil, &analyzed); code != http.StatusOK {
+		t.Fatalf("analyze status = %d, want 200", code)
+	}
+	if analyzed.Ply != 1 || analyzed.Analysis == nil || analyzed.Analysis.TotalGames != 2 || analyzed.Analysis.PlayerStats[0].Name != "Alice" {
+		t.Errorf("analysis = %+v", analyzed)
+	}
+
+	// Restart the server on the same directory; everything is still there
+	_, handler = matchServer(t, dir)
+
+	var list MatchListResponse
+	if code := serve(t, handler, "GET", "/api/matches", nil, &list); code != http.StatusOK {
+		t.Fatalf("list status = %d", code)
+	}
+	if list.Total != 2 || len(list.Matches) != 2 || list.Limit != defaultMatchPage {
+		t.Fatalf("list = %+v, want 2 matches", list)
+	}
+	for _, s := range list.Matches {
+		if analyzed := s.ID == uploaded.ID; s.Analyzed != analyzed || (len(s.ErrorPerMove) == 2) != analyzed {
+			t.Errorf("listed %+v, analyzed should be %t", s, analyzed)
+		}
+	}
+	var page MatchListResponse
+	serve(t, handler, "GET", "/api/matches?offset=1&limit=1", nil, &page)
+	if page.Total != 2 || len(page.Matches) != 1 || page.Matches[0].ID != list.Matches[1].ID {
+		t.Errorf("page = %+v, want the second match", page)
+	}
+
+	var got MatchResponse
+	if code := serve(t, handler, "GET", "/api/matches/"+uploaded.ID, nil, &got); code != http.StatusOK {
+		t.Fatalf("get status = %d", code)
+	}
+	if len(got.Games) != 2 || got.Variant != "Backgammon" {
+		t.Fatalf("match = %+v", got)
+	}
+	first := got.Games[0].Actions
+	if first[0].Type != "roll" || len(first[0].Dice) != 2 || first[1].Type != "move" || first[1].Move != "8/5 6/5" {
+		t.Errorf("first actions = %+v, want the 31 roll and 8/5 6/5", first[:2])
+	}
+
+	var stored MatchAnalysisResponse
+	if code := serve(t, handler, "GET", "/api/matches/"+uploaded.ID+"/analysis", nil, &stored); code != http.StatusOK {
+		t.Fatalf("analysis status = %d", code)
+	}
+	if stored.Ply != 1 || stored.AnalyzedAt != analyzed.AnalyzedAt || stored.Analysis.TotalCubeActs != analyzed.Analysis.TotalCubeActs {
+		t.Errorf("stored analysis = %+v, want %+v", stored, analyzed)
+	}
+
+	// Re-analysis replaces the stored analysis
+	if code := serve(t, handler, "POST", "/api/matches/"+uploaded.ID+"/analyze", nil, &analyzed); code != http.StatusOK || analyzed.Ply != 0 {
+		t.Errorf("re-analyze: status %d, ply %d", code, analyzed.Ply)
+	}
+	serve(t, handler, "GET", "/api/matches/"+uploaded.ID+"/analysis", nil, &stored)
+	if stored.Ply != 0 {
+		t.Errorf("stored ply after re-analysis = %d, want 0", stored.Ply)
+	}
+}
+
+func TestMatchStoreErrors(t *testing.T) {
+	// Without a store the endpoints are not available
+	handler := NewServer(getTestEngine(), DefaultConfig(), "test").Handler()
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/matches", nil))
+	if w.Code != http.StatusNotImplemented || !strings.Contains(w.Body.String(), "NO_MATCH_STORE") {
+		t.Errorf("without a store: %d %s", w.Code, w.Body.String())
+	}
+
+	srv, handler := matchServer(t, t.TempDir())
+	tests := []struct {
+		method, path string
+		body         interface{}
+		status       int
+		code         string
+	}{
+		{"POST", "/api/matches", MatchUploadRequest{}, http.StatusBadRequest, "MISSING_CONTENT"},
+		{"POST", "/api/matches", MatchUploadRequest{Format: "txt", Content: "x"}, http.StatusBadRequest, "INVALID_FORMAT"},
+		{"POST", "/api/matches", MatchUploadRequest{Content: "not a match"}, http.StatusBadRequest, "INVALID_MATCH"},
+		{"GET", "/api/matches?limit=0", nil, http.StatusBadRequest, "INVALID_LIMIT"},
+		{"GET", "/api/matches?offset=-1", nil, http.StatusBadRequest, "INVALID_OFFSET"},
+		{"GET", "/api/matches/missing", nil, http.StatusNotFound, "MATCH_NOT_FOUND"},
+		{"GET", "/api/matches/missing/analysis", nil, http.StatusNotFound, "MATCH_NOT_FOUND"},
+		{"POST", "/api/matches/missing/analyze", nil, http.StatusNotFound, "MATCH_NOT_FOUND"},
+		{"POST", "/api/matches/missing/analyze?ply=3", nil, http.StatusBadRequest, "INVALID_PLY"},
+	}
+	for _, tt := range tests {
+		var data []byte
+		if tt.body != nil {
+			data, _ = json.Marshal(tt.body)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, bytes.NewReader(data)))
+		var resp ErrorResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		if w.Code != tt.status || resp.Code != tt.code {
+			t.Errorf("%s %s: %d %s, want %d %s", tt.method, tt.path, w.Code, resp.Code, tt.status, tt.code)
+		}
+	}
+
+	// Only one analysis of a match runs at a time
+	fixture, _ := os.ReadFile("../match/testdata/beavers.mat")
+	var uploaded MatchSummaryResponse
+	serve(t, handler, "POST", "/api/matches", MatchUploadRequest{Format: "mat", Content: string(fixture)}, &uploaded)
+	srv.handlers.analyzing.Store(uploaded.ID, true)
+	if code := serve(t, handler, "POST", "/api/matches/"+uploaded.ID+"/analyze", nil, nil); code != http.StatusConflict {
+		t.Errorf("concurrent analysis: status %d, want 409", code)
+	}
+	srv.handlers.analyzing.Delete(uploaded.ID)
+	if code := serve(t, handler, "POST", "/api/matches/"+uploaded.ID+"/analyze", nil, nil); code != http.StatusOK {
+		t.Errorf("analysis after the first finished: status %d, want 200", code)
+	}
+}
+
+// readStream reads a streamed match analysis line by line.
+func readStream(t *testing.T, resp *http.Response) []MatchStreamLine {
+	t.Helper()
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != ndjsonContentType {
+		t.Fatalf("status %d, Content-Type %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	var lines []MatchStreamLine
+	sc := bufio.NewScanner(resp.Body)
+	for sc.Scan() {
+		var line MatchStreamLine
+		if err := json.Unmarshal(sc.Bytes(), &line); err != nil {
+			t.Fatalf("line %d: %v\n%s", len(lines)+1, err, sc.Text())
+		}
+		lines = append(lines, line)
+	}
+	if err := sc.Err(); err != nil {
+		t.Fatalf("reading stream: %v", err)
+	}
+	return lines
+}
+
+func TestMatchAnalysisStream(t *testing.T) {
+	fixture, err := os.ReadFile("../match/testdata/beavers.mat")
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	_, handler := matchServer(t, t.TempDir())
+	var uploaded MatchSummaryResponse
+	if code := serve(t, handler, "POST", "/api/matches", MatchUploadRequest{Content: string(fixture)}, &uploaded); code != http.StatusCreated {
+		t.Fatalf("upload status = %d, want 201", code)
+	}
+	var buffered MatchAnalysisResponse
+	if code := serve(t, handler, "POST", "/api/matches/"+uploaded.ID+"/analyze?ply=1", nil, &buffered); code != http.StatusOK {
+		t.Fatalf("analyze status = %d, want 200", code)
+	}
+	want := buffered.Analysis
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	request := func(method, path string) *http.Response {
+		req, _ := http.NewRequest(method, server.URL+path, nil)
+		req.Header.Set("Accept", "application/x-ndjson, application/json;q=0.5")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		return resp
+	}
+
+	// checkTrailer compares the totals of a stream with the buffered analysis
+	checkTrailer := func(name string, got *MatchStreamTrailer) {
+		t.Helper()
+		if got == nil {
+			t.Fatalf("%s: no trailer", name)
+		}
+		if got.TotalGames != want.TotalGames || got.TotalMoves != want.TotalMoves || got.TotalCubeActs != want.TotalCubeActs ||
+			!reflect.DeepEqual(got.PlayerStats, want.PlayerStats) || !reflect.DeepEqual(got.PlayerLuck, want.PlayerLuck) ||
+			!reflect.DeepEqual(got.GameStats, want.GameStats) || !reflect.DeepEqual(got.LuckAdjusted, want.LuckAdjusted) {
+			t.Errorf("%s: trailer %+v, want the totals of %+v", name, got, want)
+		}
+	}
+
+	lines := readStream(t, request("POST", "/api/matches/"+uploaded.ID+"/analyze?ply=1"))
+	if len(lines) < 3 || lines[0].Type != "header" || lines[len(lines)-1].Type != "trailer" {
+		t.Fatalf("stream of %d lines from %q to %q, want a header, decisions and a trailer", len(lines), lines[0].Type, lines[len(lines)-1].Type)
+	}
+	if h := lines[0].Header; h == nil || h.ID != uploaded.ID || h.Player1 != "Alice" || h.Games != 2 || h.Ply != 1 {
+		t.Errorf("header = %+v", h)
+	}
+	decisions := lines[1 : len(lines)-1]
+	if len(decisions) != want.TotalMoves+want.TotalCubeActs {
+		t.Errorf("%d decisions, want %d moves and %d cube actions", len(decisions), want.TotalMoves, want.TotalCubeActs)
+	}
+	errors := 0
+	for i, line := range decisions {
+		d := line.Decision
+		if line.Type != "decision" || d == nil {
+			t.Fatalf("line %d = %+v, want a decision", i+2, line)
+		}
+		if i > 0 {
+			prev := decisions[i-1].Decision
+			if d.GameNumber < prev.GameNumber || d.GameNumber == prev.GameNumber && d.MoveNumber < prev.MoveNumber {
+				t.Errorf("decision %d (game %d, move %d) comes after game %d, move %d", i, d.GameNumber, d.MoveNumber, prev.GameNumber, prev.MoveNumber)
+			}
+		}
+		if d.Kind == "move" && d.Luck == nil {
+			t.Errorf("decision %d = %+v, want its luck", i, d)
+		}
+		if d.Skill != engine.SkillNone && !d.Forced {
+			errors++
+		}
+	}
+	if errors != len(want.MoveErrors)+len(want.CubeErrors) {
+		t.Errorf("%d decisions rated as errors, want %d", errors, len(want.MoveErrors)+len(want.CubeErrors))
+	}
+	checkTrailer("analyze", lines[len(lines)-1].Trailer)
+
+	// The stored analysis streams its errors
+	lines = readStream(t, request("GET", "/api/matches/"+uploaded.ID+"/analysis"))
+	if len(lines) != errors+2 || lines[0].Type != "header" || lines[0].Header.Ply != 1 {
+		t.Fatalf("stored stream of %d lines, want a header, %d errors and a trailer", len(lines), errors)
+	}
+	checkTrailer("stored analysis", lines[len(lines)-1].Trailer)
+}
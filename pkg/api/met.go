@@ -0,0 +1,78 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// MET handles GET /api/met, returning a match equity table as percentages,
+// and with away1 and away2 the equity and cube points at that score.
+func (h *Handlers) MET(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	name := query.Get("met")
+	if !h.checkMET(w, name) {
+		return
+	}
+
+	var away [2]int
+	given := 0
+	for i, key := range []string{"away1", "away2"} {
+		s := query.Get(key)
+		if s == "" {
+			continue
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > 64 {
+			writeError(w, http.StatusBadRequest, key+" must be 1-64", "INVALID_SCORE")
+			return
+		}
+		away[i] = n
+		given++
+	}
+	if given == 1 {
+		writeError(w, http.StatusBadRequest, "away1 and away2 must be given together", "INVALID_SCORE")
+		return
+	}
+
+	grid, err := h.engine.METGrid(name)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error(), "MET_ERROR")
+		return
+	}
+	resp := METResponse{Name: grid.Name, Length: grid.Length, PreCrawford: percentRows(grid.PreCrawford)}
+	resp.PostCrawford = percentRows(grid.PostCrawford[:])
+
+	if given == 2 {
+		crawford, _ := strconv.ParseBool(query.Get("crawford"))
+		s, err := h.engine.METScore(name, away, crawford)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error(), "MET_ERROR")
+			return
+		}
+		resp.Score = &METScoreResponse{
+			Away:            s.Away,
+			Crawford:        s.Crawford,
+			MWC:             s.MWC * 100,
+			GammonValue:     s.GammonValue,
+			BackgammonValue: s.BackgammonValue,
+			CanDouble:       s.CanDouble,
+		}
+		for p := range s.TakePoint {
+			resp.Score.TakePoint[p] = s.TakePoint[p] * 100
+			resp.Score.DoublePoint[p] = s.DoublePoint[p] * 100
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// percentRows copies probability rows as percentages.
+func percentRows(rows [][]float64) [][]float64 {
+	out := make([][]float64, len(rows))
+	for i, row := range rows {
+		out[i] = make([]float64, len(row))
+		for j, v := range row {
+			out[i][j] = v * 100
+		}
+	}
+	return out
+}
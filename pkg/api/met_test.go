@@ -0,0 +1,61 @@
+package api
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMET(t *testing.T) {
+	handler := NewServer(getTestEngine(), DefaultConfig(), "test").Handler()
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/met", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	var resp METResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Length < 2 || len(resp.PreCrawford) != resp.Length || len(resp.PostCrawford) != 2 {
+		t.Fatalf("got a %d-point table with %d rows and %d post-Crawford vectors", resp.Length, len(resp.PreCrawford), len(resp.PostCrawford))
+	}
+	if resp.PreCrawford[1][1] != 50 || resp.Score != nil {
+		t.Errorf("2-away 2-away = %.1f%%, score %v; want 50%% and no score", resp.PreCrawford[1][1], resp.Score)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/met?away1=2&away2=2", nil))
+	resp = METResponse{}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Score == nil {
+		t.Fatalf("no score for 2-away 2-away: %s", w.Body)
+	}
+	// The taker needs the MWC lost by passing rather than by losing the game
+	want := 100 - resp.PreCrawford[0][1]
+	if s := resp.Score; s.MWC != 50 || math.Abs(s.TakePoint[0]-want) > 1e-3 || !s.CanDouble[0] {
+		t.Errorf("2-away 2-away: MWC %.1f%%, take point %.2f%% (want %.2f%%), can double %v", s.MWC, s.TakePoint[0], want, s.CanDouble[0])
+	}
+
+	tests := []struct {
+		query string
+		code  string
+	}{
+		{"away1=3", "INVALID_SCORE"},
+		{"away1=0&away2=3", "INVALID_SCORE"},
+		{"away1=3&away2=x", "INVALID_SCORE"},
+		{"met=nonsense", "INVALID_MET"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/met?"+tt.query, nil))
+		var resp ErrorResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		if w.Code != http.StatusBadRequest || resp.Code != tt.code {
+			t.Errorf("%q: %d %s, want 400 %s", tt.query, w.Code, resp.Code, tt.code)
+		}
+	}
+}
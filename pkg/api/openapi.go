@@ -0,0 +1,25 @@
+package api
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// openAPISpec is the OpenAPI 3 document describing the REST API.
+// It is maintained by hand alongside types.go; TestOpenAPISchemasMatchTypes
+// fails when the two drift apart.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// OpenAPISpec returns the embedded OpenAPI 3 document.
+func OpenAPISpec() []byte {
+	return openAPISpec
+}
+
+// OpenAPI handles GET /api/openapi.json
+func (h *Handlers) OpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(openAPISpec)
+}
@@ -0,0 +1,410 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/yourusername/bgengine/pkg/engine"
+)
+
+// loadSpec parses the embedded OpenAPI document.
+func loadSpec(t *testing.T) map[string]interface{} {
+	t.Helper()
+	var spec map[string]interface{}
+	if err := json.Unmarshal(OpenAPISpec(), &spec); err != nil {
+		t.Fatalf("openapi.json is not valid JSON: %v", err)
+	}
+	return spec
+}
+
+// specSchemas returns components.schemas from the spec.
+func specSchemas(t *testing.T, spec map[string]interface{}) map[string]interface{} {
+	t.Helper()
+	components, _ := spec["components"].(map[string]interface{})
+	schemas, _ := components["schemas"].(map[string]interface{})
+	if schemas == nil {
+		t.Fatal("openapi.json has no components.schemas")
+	}
+	return schemas
+}
+
+// exampleTypes returns a populated example of every type described in the spec.
+func exampleTypes() map[string]interface{} {
+	stored := StoredRolloutResponse{ID: "0123456789abcdef", Position: "4HPwATDgc/ABMA", Cube: "turn=0 cube=1", Trials: 1296, Equity: 0.02, CI95: 0.05, TruncatePly: 10, Created: "2024-01-02T03:04:05Z", Updated: "2024-01-02T04:04:05Z"}
+	pvRoll := PVRollResponse{Dice: [2]int{6, 6}, Probability: 2.78, Move: "24/18(2) 13/7(2)", PositionID: "4HPwATDgc/ABMA", Ply: 0, Equity: 0.45, Win: 70.1, WinG: 20.3, LoseG: 5.2, Impact: 0.013}
+	pv := PVResponse{Position: "4HPwATDgc/ABMA", Ply: 1, Equity: -0.1, Rolls: []PVRollResponse{pvRoll}}
+	move := MoveResponse{Move: "8/5 6/5", Equity: 0.1, Win: 52.3, WinG: 12.1, PositionID: "4HPwATDgc/ABMA", Ply: 1, Tags: []string{"hit", "point"}, PV: &pv}
+	consensus := ConsensusResponse{Level: "conflict", BestMove: "8/5 6/5", Recommendation: "0-ply and 1-ply disagree by 0.045 (0-ply plays 24/23 13/10, 1-ply 8/5 6/5): consider a rollout", Checks: []ConsensusCheckResponse{
+		{Kind: "ply", Gap: 0.045, Level: "conflict", Message: "0-ply and 1-ply disagree by 0.045 (0-ply plays 24/23 13/10, 1-ply 8/5 6/5)", Sources: []ConsensusSourceResponse{{Name: "0-ply", Move: "24/23 13/10", Equity: 0.01}, {Name: "1-ply", Move: "8/5 6/5", Equity: 0.15}}},
+	}}
+	start := GameStart{Position: "4HPwATDgc/ABMA", MatchLength: 7, Score: [2]int{3, 2}, CubeValue: 4, CubeOwner: 1, Crawford: true}
+	input := InputResponse{Name: "player.break_contact", Value: 0.3}
+	outcome := MatchOutcomeResponse{Cube: 1, Points: 2, MWC: 75, OppMWC: 25}
+	raceRow := RaceCubeRowResponse{Lead: 8, Win: 70.4, NoDouble: 0.52, DoubleTake: 0.55, Verdict: "double_take"}
+	cubePoints := CubePointsResponse{Cube: 1, TakePoint: 21.5, CashPoint: 78.5, Margin: -8.1}
+	opponentView := OpponentViewResponse{TakeEquity: 0.1, PassEquity: -1, PassCost: 1.1, Win: 38, TakePoint: 21.5, Margin: 16.5, GammonRisk: 12, BackgammonRisk: 0.5, TakeMWC: 41, PassMWC: 35}
+	race := RaceCubeResponse{Pips: [2]int{105, 113}, Lead: 8, LeadPct: 7.6, Win: 70.4, Source: "nn", Verdict: "double_take", DoublePoint: 69.2, TakePoint: 78.6, PipsToDouble: -0.8, PipsToPass: 5.8, Summary: "Race of 105 against 113 pips: a lead of +8 (+7.6%) and 70.4% winning chances.", Table: []RaceCubeRowResponse{raceRow}}
+	summary := MatchSummaryResponse{ID: "0123456789abcdef", Player1: "Alice", Player2: "Bob", MatchLength: 7, Date: "2024-01-02", Ratings: []float64{1712.85, 1650.1}, Games: 9, Uploaded: "2024-01-02T03:04:05Z", Analyzed: true, AnalysisPly: 2, AnalyzedAt: "2024-01-02T04:04:05Z", ErrorPerMove: []float64{0.012, 0.02}}
+	action := MatchActionResponse{Type: "roll", Player: 1, Dice: []int{3, 1}, Move: "8/5 6/5", Value: 2}
+	game := MatchGameResponse{Number: 1, Score: [2]int{0, 2}, Crawford: true, Winner: 1, Points: 2, Result: "gammon", Actions: []MatchActionResponse{action}}
+	dataFile := engine.DataFile{Kind: "weights", Path: "data/gnubg.weights", Size: 1204561, SHA256: "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"}
+	sentinel := engine.SelfCheckSentinel{Name: "contact", Status: "fail", Detail: "evaluated by heuristic, not a net: no weights loaded", Values: []engine.SelfCheckValue{{Name: "win", Observed: 0.529, Min: 0.48, Max: 0.52}}}
+	build := engine.BuildInfo{Version: engine.Version, Revision: "ac2d73f0e1b9a4c7d5e3f2a1b0c9d8e7f6a5b4c3", Modified: true, GoVersion: "go1.24.4", Data: []engine.DataFile{dataFile}}
+	manifest := &engine.RolloutManifest{BuildInfo: build, MET: "Default MET", Disabled: []string{"crashed_net"}, EvenFallback: true, CoreNetsOnly: true, Position: "4HPwATDgc/ABMA", Cube: "turn=0 cube=1 owner=-1 match=0 score=0-0 crawford=false", Seed: 4242, Trials: 1296, Truncate: 10, LeafPly: 1, LeafCubeful: true, FirstRoll: "already_rolled", Dice: []int{3, 1}, DiceMode: "exhaustive-2roll", DiceRNG: engine.DiceRNG}
+	difficulty := engine.DifficultyStats{DecisionWeight: 6.5, WeightedError: 0.13, WeightedErrorPerMove: 0.02, HardDecisions: 4, HardCorrect: 3, HardAccuracy: 75}
+	var weakness engine.WeaknessProfile
+	weakness.Add("holding", engine.PhaseMiddle, true, 0.2)
+	weakness.Add("contact", engine.PhaseOpening, false, 0.1)
+	moveErr := engine.MoveErrorDetail{GameNumber: 1, MoveNumber: 3, Player: 1, Position: "4HPwATDgc/ABMA", Dice: [2]int{3, 1}, Played: "24/21 24/23", Best: "8/5 6/5", EquityLoss: 0.1, Skill: engine.SkillBad, SkillStr: "Bad", Category: "contact", Phase: engine.PhaseOpening, Spread: 0.03}
+	cubeErr := engine.CubeErrorDetail{GameNumber: 1, MoveNumber: 5, Player: 0, Position: "4HPwATDgc/ABMA", Played: engine.Pass, Optimal: engine.Take, PlayedStr: "pass", OptimalStr: "take", EquityLoss: 0.2, Skill: engine.SkillVeryBad, SkillStr: "Very Bad", Category: "holding", Phase: engine.PhaseMiddle}
+	player := engine.PlayerAnalysis{Name: "Alice", TotalMoves: 20, TotalCube: 3, TotalError: 0.3, ErrorPerMove: 0.015, Rating: engine.RatingExpert, RatingStr: "Expert", Blunders: 1, Errors: 2, Doubtful: 3, CubeError: 0.2, MissedDoubles: 1, WrongDoubles: 1, WrongTakes: 1, WrongPasses: 1, WrongBeavers: 1, MissedBeavers: 1, DeadCubeDecisions: 1, Weakness: weakness, DifficultyStats: difficulty}
+	luckRoll := LuckRoll{MoveNumber: 30, Player: 1, Position: "4HPwATDgc/ABMA", Dice: [2]int{6, 6}, Luck: 1.94, Points: 1.94}
+	joker := engine.LuckDetail{GameNumber: 1, MoveNumber: 30, Player: 1, Position: "4HPwATDgc/ABMA", Dice: [2]int{6, 6}, Luck: 1.94, Points: 3.89}
+	adjusted := engine.LuckAdjustedResult{Points: [2]float64{-2, 2}, Luck: [2]float64{-2.8, 2.8}, Adjusted: [2]float64{0.8, -0.8}, Summary: "Bob won but was outplayed; luck accounted for +2.8 points"}
+	gameStats := engine.GameAnalysis{GameNumber: 1, Winner: 0, Points: 2, MoveCount: [2]int{10, 10}, TotalError: [2]float64{0.1, 0.2}, ErrorPerMove: [2]float64{0.01, 0.02}, CubeActions: 2, Errors: []engine.MoveErrorDetail{moveErr}, Luckiest: []engine.LuckDetail{joker}, Unluckiest: []engine.LuckDetail{joker}}
+	luck := engine.LuckAnalysis{TotalLuck: 0.5, AvgLuck: 0.02, VeryLucky: 1, Lucky: 2, Unlucky: 3, VeryUnlucky: 4}
+	point := engine.TimelinePoint{GameNumber: 1, MoveNumber: 3, Player: 1, Value: 0.55, Delta: 0.05, Skill: -0.01, Luck: 0.06}
+	luckValue := 0.4
+	decision := engine.DecisionResult{GameNumber: 1, MoveNumber: 3, Player: 0, Kind: "move", Position: "4HPwATDgc/ABMA", Dice: [2]int{3, 1}, Played: "24/23 13/10", Best: "8/5 6/5", EquityLoss: 0.12, Skill: engine.SkillVeryBad, SkillStr: "Very Bad", Luck: &luckValue}
+	streamHeader := MatchStreamHeader{ID: "0123456789abcdef", Player1: "Alice", Player2: "Bob", MatchLength: 7, Games: 5, Ply: 2, MET: "g11", Clamped: true}
+	trailer := MatchStreamTrailer{AnalyzedAt: "2024-01-02T04:04:05Z", TotalGames: 1, TotalMoves: 20, TotalCubeActs: 2, PlayerStats: [2]engine.PlayerAnalysis{player, player}, PlayerLuck: [2]engine.LuckAnalysis{luck, luck}, GameStats: []engine.GameAnalysis{gameStats}, LuckAdjusted: &adjusted}
+	analysis := engine.MatchAnalysis{TotalGames: 1, TotalMoves: 20, TotalCubeActs: 2, PlayerStats: [2]engine.PlayerAnalysis{player, player}, GameStats: []engine.GameAnalysis{gameStats}, MoveErrors: []engine.MoveErrorDetail{moveErr}, CubeErrors: []engine.CubeErrorDetail{cubeErr}, PlayerLuck: [2]engine.LuckAnalysis{luck, luck}, RollLuck: []engine.LuckDetail{joker}, LuckAdjusted: &adjusted, Timeline: []engine.TimelinePoint{point}, Engine: &build}
+	tutored := TutorMoveResponse{Skill: "bad", SkillAbbr: "?", EquityLoss: 0.1, PlayedMove: "8/5 6/5", BestMove: "24/23 13/10", BestEquity: 0.1, PlayedEquity: 0, IsForced: false, TopMoves: []MoveResponse{move}, Suggestion: "Consider 24/23 13/10"}
+	score := QuizScore{Answered: 4, Correct: 3, EquityLoss: 0.1, AverageLoss: 0.025}
+	metScore := METScoreResponse{Away: [2]int{2, 2}, MWC: 50, GammonValue: [2]float64{1, 1}, BackgammonValue: [2]float64{0, 0}, CanDouble: [2]bool{true, true}, TakePoint: [2]float64{33.3, 33.3}, DoublePoint: [2]float64{50, 50}}
+	gameClock := GameClockRequest{ReserveMs: 600000, DelayMs: 12000, DelayMode: "bronstein", IncrementMs: 1000, Flag: "game"}
+	gameClockState := GameClockResponse{RemainingMs: [2]int64{412000, 388500}, Running: 1, Flagged: -1}
+	timing := TimingResponse{Total: 12.5, MoveGen: 1.1, Inputs: 2.4, Contact: 6.3, Prune: 0.9, Cache: 0.4, Other: 1.4, Evaluations: 2817, BookHits: 21}
+	return map[string]interface{}{
+		"EvaluateRequest":  EvaluateRequest{Position: "4HPwATDgc/ABMA", Format: "gnubg", MatchLength: 7, Score: [2]int{1, 2}, CubeValue: 2, CubeOwner: 1, Crawford: true, Ply: intPtr(1)},
+		"MoveRequest":      MoveRequest{Position: "4HPwATDgc/ABMA", Format: "gnubg", Dice: [2]int{3, 1}, MatchLength: 5, Score: [2]int{1, 1}, CubeValue: 2, CubeOwner: 0, Crawford: true, NumMoves: 3, Ply: intPtr(2), TimeLimitMs: 200, Constraints: []string{"!hit"}, PV: true},
+		"CubeRequest":      CubeRequest{Position: "4HPwATDgc/ABMA", Format: "gnubg", MatchLength: 5, Score: [2]int{2, 3}, CubeValue: 2, CubeOwner: 1, Crawford: true},
+		"RolloutRequest":   RolloutRequest{Position: "4HPwATDgc/ABMA", Format: "gnubg", Trials: 100, Truncate: 10, MatchLength: 3, Score: [2]int{1, 0}, CubeValue: 2, CubeOwner: 0, Crawford: true, Seed: 42, InitialDice: [2]int{6, 5}},
+		"TutorMoveRequest": TutorMoveRequest{Position: "4HPwATDgc/ABMA", Dice: [2]int{3, 1}, Move: "8/5 6/5", ResultingPosition: "4HPwATDgc/ABMA", MatchLength: 5, Score: [2]int{1, 1}, CubeValue: 2, CubeOwner: 1, Crawford: true, Ply: 1, DoubtfulThreshold: 0.04, BadThreshold: 0.08, BlunderThreshold: 0.16},
+		"TutorCubeRequest": TutorCubeRequest{Position: "4HPwATDgc/ABMA", Action: "double", MatchLength: 5, Score: [2]int{1, 1}, CubeValue: 2, CubeOwner: 1, Crawford: true, DoubtfulThreshold: 0.04, BadThreshold: 0.08, BlunderThreshold: 0.16},
+		"AnalyzeGameRequest": AnalyzeGameRequest{
+			Positions:         []GamePosition{{Position: "4HPwATDgc/ABMA", Dice: [2]int{3, 1}, Move: "8/5 6/5", CubeAction: "double", Player: 1, MatchLength: 5, Score: [2]int{1, 1}, CubeValue: 2, CubeOwner: 0, Crawford: true}},
+			MatchPlay:         true,
+			Start:             &start,
+			DoubtfulThreshold: 0.04,
+			BadThreshold:      0.08,
+			BlunderThreshold:  0.16,
+		},
+		"FIBSBoardRequest":     FIBSBoardRequest{Board: "board:You:Opponent:5:0:0", NumMoves: 3},
+		"GamePosition":         GamePosition{Position: "4HPwATDgc/ABMA", Dice: [2]int{6, 5}, Move: "24/13", CubeAction: "take", Player: 0, MatchLength: 3, Score: [2]int{0, 2}, CubeValue: 2, CubeOwner: 1, Crawford: true},
+		"EvaluateResponse":     EvaluateResponse{Equity: 0.05, Win: 51, WinG: 14, WinBG: 1, LoseG: 12, LoseBG: 0.5, Ply: 1, Cubeful: true, Source: "nn", Timing: &timing},
+		"MoveResponse":         move,
+		"PVResponse":           pv,
+		"PVRollResponse":       pvRoll,
+		"MovesResponse":        MovesResponse{Moves: []MoveResponse{move}, NumLegal: 16, Dice: [2]int{3, 1}, Position: "4HPwATDgc/ABMA", Consensus: &consensus},
+		"CubePointsResponse":   cubePoints,
+		"OpponentViewResponse": opponentView,
+		"CubeResponse":         CubeResponse{Action: "no_double", DecisionType: "no_double_take", Verdict: "No double, take: the position is not strong enough to double.", DoubleEquity: 0.1, PassEquity: 1, NoDoubleEquity: 0.2, TakeEquity: -0.1, DoubleDiff: -0.1, Decision: "No Double", TakePoint: 21.5, CashPoint: 78.5, DoublePoint: 68.8, RecubeTakePoint: 21.5, Points: []CubePointsResponse{cubePoints}, CubeEfficiency: 0.7, Source: "janowski", OpponentView: &opponentView, Race: &race},
+		"RolloutResponse":      RolloutResponse{Equity: 0.02, StdDev: 1.1, CI95: 0.05, Win: 50, WinG: 13, WinBG: 1, LoseG: 12, LoseBG: 1, Trials: 1296, Truncated: true, TruncatePly: 10, Cached: true, StoredAt: "2024-01-02T03:04:05Z", Seed: 4242, DiceMode: "exhaustive-2roll", Manifest: manifest, Engine: &build},
+		"CubeRolloutResponse": CubeRolloutResponse{
+			Cube:       &CubeResponse{Action: "double_take", DecisionType: "double_take", Verdict: "Double, take: doubling gains and the opponent should take.", DoubleEquity: 0.7, PassEquity: 1, NoDoubleEquity: 0.6, TakeEquity: 0.7, DoubleDiff: 0.1, Decision: "Double, Take", TakePoint: 20, CashPoint: 80},
+			Rollout:    RolloutResponse{Equity: 0.44, StdDev: 0.9, CI95: 0.05, Win: 72, Trials: 1296, TrialsPerSecond: 850, AvgPlies: 54.2, DiceMode: "exhaustive-2roll"},
+			NoDoubleCI: 0.06, DoubleTakeCI: 0.1, DoubleDiffCI: 0.04, DoubleSignificant: true, TakeSignificant: true,
+			Engine: &build,
+		},
+		"ActionRequest":     ActionRequest{Position: "4HPwATDgc/ABMA", Format: "gnubg", Dice: &[2]int{3, 1}, MatchLength: 5, Score: [2]int{2, 3}, CubeValue: 2, CubeOwner: 0, Crawford: true, MET: "default", NumMoves: 3},
+		"ActionResponse":    ActionResponse{Action: "move", MissedDouble: true, Cube: &CubeResponse{Action: "double_take", Decision: "Double, Take"}, Moves: &MovesResponse{Moves: []MoveResponse{move}, NumLegal: 16, Dice: [2]int{3, 1}}},
+		"ErrorResponse":     ErrorResponse{Error: "illegal move", Code: "ILLEGAL_MOVE", Details: "details", LegalPositions: []string{"4HPwATDgc/ABMA"}},
+		"FIBSBoardResponse": FIBSBoardResponse{Player1: "You", Player2: "Opponent", MatchLength: 5, Score1: 1, Score2: 2, CubeValue: 1, Turn: 1, Dice: [2]int{3, 1}, OppDice: [2]int{0, 0}, Equity: 0.1, Win: 52, WinG: 10, WinBG: 1, LoseG: 9, LoseBG: 1, Moves: []MoveResponse{move}, NumLegal: 16, CubeAction: "no_double", DoubleEquity: 0.1, NoDoubleEquity: 0.2, PositionID: "4HPwATDgc/ABMA"},
+		"HealthResponse":    HealthResponse{Status: "ok", Version: "1.0.0", Ready: true, Pool: &PoolStats{ActiveFast: 1, MaxFast: 100, MaxSlow: 4}, MET: &METInfo{Name: "Default MET", Length: 11, Tables: []string{"default", "g11"}}, Kernel: "avx2", CPU: []string{"avx2", "fma"}, Data: []engine.DataFile{dataFile}, Engine: &build},
+		"TutorMoveResponse": tutored,
+		"TutorCubeResponse": TutorCubeResponse{Skill: "none", EquityLoss: 0, Optimal: "no_double", Played: "no_double", IsClose: true, Suggestion: "Correct"},
+		"GameAnalysisResponse": GameAnalysisResponse{
+			Players:     [2]PlayerStats{{TotalMoves: 10, Rating: "Expert", Blunders: 1, Weakness: weakness}, {TotalMoves: 9, Rating: "Advanced"}},
+			TotalMoves:  19,
+			MoveErrors:  []MoveError{{MoveNumber: 3, Player: 1, Position: "4HPwATDgc/ABMA", Dice: [2]int{3, 1}, Played: "8/5 6/5", Best: "24/23 13/10", EquityLoss: 0.1, Skill: "bad", Category: "contact", Phase: "opening"}},
+			CubeErrors:  []CubeError{{MoveNumber: 5, Player: 0, Position: "4HPwATDgc/ABMA", Played: "pass", Optimal: "take", EquityLoss: 0.2, Skill: "very_bad", Category: "holding", Phase: "middle"}},
+			LuckStats:   [2]float64{0.1, -0.1},
+			Luckiest:    []LuckRoll{luckRoll},
+			Unluckiest:  []LuckRoll{luckRoll},
+			Suggestions: []string{"Work on cube decisions"},
+			Engine:      &build,
+		},
+		"PlayerStats":            PlayerStats{TotalMoves: 10, TotalCubeDecisions: 2, TotalError: 0.5, ErrorPerMove: 0.05, Rating: "Expert", Blunders: 1, Errors: 2, Doubtful: 3, LuckAdjusted: 0.04, Weakness: weakness, DifficultyStats: difficulty},
+		"MoveError":              MoveError{MoveNumber: 1, Player: 0, Position: "4HPwATDgc/ABMA", Dice: [2]int{6, 6}, Played: "24/18(2)", Best: "13/7(2)", EquityLoss: 0.3, Skill: "very_bad", Category: "contact", Phase: "opening"},
+		"CubeError":              CubeError{MoveNumber: 2, Player: 1, Position: "4HPwATDgc/ABMA", Played: "double", Optimal: "no_double", EquityLoss: 0.05, Skill: "doubtful", Category: "race", Phase: "bearin"},
+		"METInfo":                METInfo{Name: "Default MET", Length: 11, Tables: []string{"default", "g11"}},
+		"METResponse":            METResponse{Name: "Default MET", Length: 2, PreCrawford: [][]float64{{50, 66.7}, {33.3, 50}}, PostCrawford: [][]float64{{50, 41.7}, {50, 41.7}}, Score: &metScore},
+		"METScoreResponse":       metScore,
+		"PoolStats":              PoolStats{ActiveFast: 1, ActiveSlow: 2, QueuedFast: 3, QueuedSlow: 4, TotalFast: 5, TotalSlow: 6, MaxFast: 100, MaxSlow: 4, MaxSlowPerIdentity: 2, Identities: map[string]IdentityStats{"5994471abb01": {ActiveSlow: 2, QueuedSlow: 4, TotalSlow: 6}}},
+		"IdentityStats":          IdentityStats{ActiveSlow: 1, QueuedSlow: 2, TotalSlow: 3},
+		"StoredRolloutResponse":  stored,
+		"RolloutManifest":        *manifest,
+		"BuildInfo":              build,
+		"DataFile":               dataFile,
+		"SelfCheckResponse":      SelfCheckResponse{Status: "fail", CheckedAt: "2024-01-02T03:04:05Z", DurationMs: 1.2, Sentinels: []engine.SelfCheckSentinel{sentinel}},
+		"SelfCheckSentinel":      sentinel,
+		"SelfCheckValue":         sentinel.Values[0],
+		"StoredRolloutsResponse": StoredRolloutsResponse{Rollouts: []StoredRolloutResponse{stored}},
+		"TimingResponse":         timing,
+		"EntryResponse":          EntryResponse{Opponent: true, OnBar: 1, PointsMade: 4, EnterRolls: 20, FullRolls: 20, DanceRolls: 16, Dance: 44.4, Summary: "The opponent enters with only 20/36 rolls against 4 home board points and dances 44.4% of the time."},
+		"ConstraintResponse":     ConstraintResponse{Constraints: "!point", NumAllowed: 15, Best: move, Cost: 0.19},
+		"MatchContextResponse":   MatchContextResponse{MWC: 50, Outcomes: []MatchOutcomeResponse{outcome}, WinMWC: 66.7, LoseMWC: 33.3, WinMWCDoubled: 100, DoubleRisk: 33.3, DoubleGain: 33.3, TakeRisk: 33.3, TakeGain: 66.7, TakePoint: 33.3},
+		"MatchOutcomeResponse":   outcome,
+		"RaceCubeResponse":       race,
+		"RaceCubeRowResponse":    raceRow,
+		"InspectRequest":         InspectRequest{Position: "4HPwATDgc/ABMA", Verbose: true},
+		"InspectResponse":        InspectResponse{Position: "4HPwATDgc/ABMA", Class: "contact", Evaluator: "contact_net", Network: "contact", Output: [5]float32{0.5, 0.15, 0.01, 0.14, 0.01}, Inputs: []InputResponse{input}, Disabled: []string{"bearoff_db"}},
+		"InputResponse":          input,
+		"GameStart":              start,
+		"BroadcastUpdateRequest": BroadcastUpdateRequest{Channel: "final", Position: "4HPwATDgc/ABMA", Dice: [2]int{3, 1}, MatchLength: 7, Score: [2]int{3, 2}, CubeValue: 2, CubeOwner: 1, Crawford: true, Comment: "Opening roll"},
+		"MatchUploadRequest":     MatchUploadRequest{Format: "mat", Content: "7 point match"},
+		"MatchSummaryResponse":   summary,
+		"MatchListResponse":      MatchListResponse{Matches: []MatchSummaryResponse{summary}, Total: 41, Offset: 20, Limit: 20},
+		"MatchResponse":          MatchResponse{ID: "0123456789abcdef", Player1: "Alice", Player2: "Bob", MatchLength: 7, Date: "2024-01-02", PlayedAt: "2024-01-02T19:01:00Z", DateText: "2024.01.02", Ratings: []float64{1712.85, 1650.1}, Event: "Club night", EventInfo: "80221337", Round: "1", Place: "Online", Annotator: "GoBG", Comment: "Final", Variant: "Nackgammon", Tags: []MatchTagResponse{{Name: "CubeLimit", Value: "64"}}, Games: []MatchGameResponse{game}},
+		"MatchTagResponse":       MatchTagResponse{Name: "Clock", Value: "5 min reserve, 12s delay"},
+		"MatchGameResponse":      game,
+		"MatchActionResponse":    action,
+		"MatchAnalysisResponse":  MatchAnalysisResponse{ID: "0123456789abcdef", Ply: 2, AnalyzedAt: "2024-01-02T04:04:05Z", Analysis: &analysis},
+		"MatchAnalysis":          analysis,
+		"MatchStreamLine":        MatchStreamLine{Type: "decision", Header: &streamHeader, Decision: &decision, Trailer: &trailer, Error: &ErrorResponse{Error: "analysis failed", Code: "ANALYSIS_ERROR"}},
+		"MatchStreamHeader":      streamHeader,
+		"MatchStreamTrailer":     trailer,
+		"DecisionResult":         decision,
+		"PlayerAnalysis":         player,
+		"GameAnalysis":           gameStats,
+		"MoveErrorDetail":        moveErr,
+		"CubeErrorDetail":        cubeErr,
+		"LuckAnalysis":           luck,
+		"LuckDetail":             joker,
+		"LuckAdjustedResult":     adjusted,
+		"LuckRoll":               luckRoll,
+		"TimelinePoint":          point,
+		"WeaknessProfile":        weakness,
+		"WeaknessBucket":         weakness.Categories[0],
+		"BroadcastResult":        BroadcastResult{Channel: "final", Seq: 12, Subscribers: 500, Cached: true},
+		"QuizProblemResponse":    QuizProblemResponse{Session: "0123456789abcdef", Number: 5, Position: "4HPwATDgc/ABMA", Dice: [2]int{3, 1}, Category: "holding", Difficulty: 3, Source: "4HPwATDgc/ABMA", Name: "Starting Position"},
+		"QuizAnswerRequest":      QuizAnswerRequest{Session: "0123456789abcdef", Move: "8/5 6/5", ResultingPosition: "4HPwATDgc/ABMA"},
+		"QuizScore":              score,
+		"QuizAnswerResponse":     QuizAnswerResponse{Result: tutored, Score: score},
+		"BotPlayRequest":         BotPlayRequest{Bot: "gnubot", Kind: "move", Position: "4HPwATDgc/ABMA", Dice: [2]int{3, 1}, MatchLength: 7, Score: [2]int{3, 2}, CubeValue: 2, CubeOwner: 1, Crawford: true, MET: "kazaross-xg2", TimeLimitMs: 2000},
+		"GameClockRequest":       gameClock,
+		"GameStartRequest":       GameStartRequest{Player1: "Alice", Player2: "Bob", MatchLength: 7, NoCrawford: true, Clock: gameClock},
+		"GameActionRequest":      GameActionRequest{Session: "0123456789abcdef", Player: 1, Action: "move", Move: "8/5 6/5"},
+		"GameClockResponse":      gameClockState,
+		"GameStateResponse":      GameStateResponse{Session: "0123456789abcdef", Phase: "move", Turn: 1, Decider: 1, Position: "4HPwATDgc/ABMA", Dice: [2]int{3, 1}, CubeValue: 2, CubeOwner: 0, MatchLength: 7, Score: [2]int{3, 2}, Game: 4, Crawford: true, Winner: -1, Clock: gameClockState},
+		"BotPlayResponse":        BotPlayResponse{Bot: "gnubot", Kind: "move", Source: "engine", Fallback: "illegal_reply", Rejected: "illegal move", Move: &tutored.PlayedMove, Action: "take", ElapsedMs: 12.5, MoveGrade: &tutored, CubeGrade: &TutorCubeResponse{Skill: "none"}},
+
+		"ConsensusResponse":       consensus,
+		"ConsensusCheckResponse":  consensus.Checks[0],
+		"ConsensusSourceResponse": consensus.Checks[0].Sources[0],
+	}
+}
+
+// TestOpenAPIHandler tests that the spec is served as JSON.
+func TestOpenAPIHandler(t *testing.T) {
+	h := NewHandlers(nil, "test")
+
+	req := httptest.NewRequest("GET", "/api/openapi.json", nil)
+	w := httptest.NewRecorder()
+	h.OpenAPI(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("OpenAPI status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+
+	var spec map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &spec); err != nil {
+		t.Fatalf("Decode error: %v", err)
+	}
+	if v, _ := spec["openapi"].(string); !strings.HasPrefix(v, "3.") {
+		t.Errorf("openapi version = %q, want 3.x", v)
+	}
+}
+
+// TestOpenAPISchemasMatchTypes checks that every schema lists exactly the JSON
+// fields of its Go type and that populated examples validate against it.
+func TestOpenAPISchemasMatchTypes(t *testing.T) {
+	spec := loadSpec(t)
+	schemas := specSchemas(t, spec)
+	examples := exampleTypes()
+
+	for name := range schemas {
+		if _, ok := examples[name]; !ok {
+			t.Errorf("schema %s has no example type in the test", name)
+		}
+	}
+
+	for name, example := range examples {
+		schema, ok := schemas[name].(map[string]interface{})
+		if !ok {
+			t.Errorf("type %s is missing from openapi.json", name)
+			continue
+		}
+
+		props, _ := schema["properties"].(map[string]interface{})
+		fields := jsonFields(reflect.TypeOf(example))
+
+		var want, got []string
+		for f := range fields {
+			want = append(want, f)
+		}
+		for p := range props {
+			got = append(got, p)
+		}
+		sort.Strings(want)
+		sort.Strings(got)
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("%s: schema properties %v, Go fields %v", name, got, want)
+			continue
+		}
+
+		required, _ := schema["required"].([]interface{})
+		for _, r := range required {
+			if _, ok := fields[r.(string)]; !ok {
+				t.Errorf("%s: required property %v is not a field", name, r)
+			}
+		}
+
+		data, err := json.Marshal(example)
+		if err != nil {
+			t.Fatalf("%s: marshal failed: %v", name, err)
+		}
+		var value interface{}
+		json.Unmarshal(data, &value)
+		validateSchema(t, schemas, schema, value, name)
+	}
+}
+
+// TestOpenAPIPathsRouted checks that every documented path is served.
+func TestOpenAPIPathsRouted(t *testing.T) {
+	spec := loadSpec(t)
+	paths, _ := spec["paths"].(map[string]interface{})
+	handler := NewServer(getTestEngine(), DefaultConfig(), "test").Handler()
+
+	for path, item := range paths {
+		for method := range item.(map[string]interface{}) {
+			method = strings.ToUpper(method)
+			req := httptest.NewRequest(method, path, strings.NewReader("{}"))
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			// A handler's own 404 for the placeholder ID is JSON; the
+			// router's is not
+			unrouted := w.Code == http.StatusNotFound && !strings.HasPrefix(w.Header().Get("Content-Type"), "application/json")
+			if unrouted || w.Code == http.StatusMethodNotAllowed {
+				t.Errorf("%s %s: status %d, route not registered", method, path, w.Code)
+			}
+		}
+	}
+}
+
+// jsonFields returns the JSON property names of a struct type, with those
+// of untagged embedded structs as encoding/json flattens them.
+func jsonFields(typ reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField)
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		tag := f.Tag.Get("json")
+		if f.Anonymous && tag == "" && f.Type.Kind() == reflect.Struct {
+			for name, field := range jsonFields(f.Type) {
+				fields[name] = field
+			}
+			continue
+		}
+		if tag == "" || tag == "-" {
+			continue
+		}
+		fields[strings.Split(tag, ",")[0]] = f
+	}
+	return fields
+}
+
+// validateSchema checks a decoded JSON value against the subset of OpenAPI
+// schema keywords used in openapi.json.
+func validateSchema(t *testing.T, schemas, schema map[string]interface{}, value interface{}, path string) {
+	t.Helper()
+
+	if ref, ok := schema["$ref"].(string); ok {
+		name := strings.TrimPrefix(ref, "#/components/schemas/")
+		target, ok := schemas[name].(map[string]interface{})
+		if !ok {
+			t.Errorf("%s: unresolved $ref %s", path, ref)
+			return
+		}
+		validateSchema(t, schemas, target, value, path)
+		return
+	}
+	if allOf, ok := schema["allOf"].([]interface{}); ok {
+		for _, s := range allOf {
+			validateSchema(t, schemas, s.(map[string]interface{}), value, path)
+		}
+		return
+	}
+
+	switch schema["type"] {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			t.Errorf("%s: expected object, got %T", path, value)
+			return
+		}
+		props, _ := schema["properties"].(map[string]interface{})
+		additional, _ := schema["additionalProperties"].(map[string]interface{})
+		for k, v := range obj {
+			prop, ok := props[k].(map[string]interface{})
+			if !ok && additional != nil {
+				prop, ok = additional, true
+			}
+			if !ok {
+				t.Errorf("%s: property %s not in schema", path, k)
+				continue
+			}
+			validateSchema(t, schemas, prop, v, path+"."+k)
+		}
+		required, _ := schema["required"].([]interface{})
+		for _, r := range required {
+			if _, ok := obj[r.(string)]; !ok {
+				t.Errorf("%s: required property %v missing", path, r)
+			}
+		}
+	case "array":
+		arr, ok := value.([]interface{})
+		if !ok {
+			t.Errorf("%s: expected array, got %T", path, value)
+			return
+		}
+		if n, ok := schema["maxItems"].(float64); ok && len(arr) > int(n) {
+			t.Errorf("%s: %d items, max %v", path, len(arr), n)
+		}
+		if n, ok := schema["minItems"].(float64); ok && len(arr) < int(n) {
+			t.Errorf("%s: %d items, min %v", path, len(arr), n)
+		}
+		items, _ := schema["items"].(map[string]interface{})
+		for i, v := range arr {
+			validateSchema(t, schemas, items, v, fmt.Sprintf("%s[%d]", path, i))
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			t.Errorf("%s: expected string, got %T", path, value)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			t.Errorf("%s: expected boolean, got %T", path, value)
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			t.Errorf("%s: expected number, got %T", path, value)
+		}
+	case "integer":
+		f, ok := value.(float64)
+		if !ok || f != float64(int64(f)) {
+			t.Errorf("%s: expected integer, got %v", path, value)
+		}
+	default:
+		t.Errorf("%s: unsupported schema type %v", path, schema["type"])
+	}
+}
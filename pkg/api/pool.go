@@ -9,15 +9,17 @@ import (
 
 // WorkerPool manages concurrent request processing with configurable limits.
 // It provides separate pools for fast (evaluate) and slow (rollout) operations.
+// Slow operations may be started under a client identity (AcquireSlowAs),
+// and the slow pool shares its slots fairly between identities.
 type WorkerPool struct {
-	fastSem    chan struct{} // Semaphore for fast operations (evaluate, move, cube)
-	slowSem    chan struct{} // Semaphore for slow operations (rollout)
-	queuedFast int64         // Number of queued fast requests
-	queuedSlow int64         // Number of queued slow requests
-	activeFast int64         // Number of active fast requests
-	activeSlow int64         // Number of active slow requests
-	totalFast  int64         // Total fast requests processed
-	totalSlow  int64         // Total slow requests processed
+	fastSem    chan struct{}  // Semaphore for fast operations (evaluate, move, cube)
+	slow       *fairScheduler // Slots for slow operations (rollout)
+	queuedFast int64          // Number of queued fast requests
+	queuedSlow int64          // Number of queued slow requests
+	activeFast int64          // Number of active fast requests
+	activeSlow int64          // Number of active slow requests
+	totalFast  int64          // Total fast requests processed
+	totalSlow  int64          // Total slow requests processed
 	mu         sync.RWMutex
 }
 
@@ -25,6 +27,11 @@ type WorkerPool struct {
 type PoolConfig struct {
 	MaxFastWorkers int // Max concurrent fast operations (default: 100)
 	MaxSlowWorkers int // Max concurrent slow operations (default: 4)
+
+	// MaxSlowPerIdentity caps the slow operations one client identity may
+	// run at once, operations without an identity counting as one identity
+	// (0 = no cap).
+	MaxSlowPerIdentity int
 }
 
 // DefaultPoolConfig returns a PoolConfig with sensible defaults.
@@ -46,7 +53,7 @@ func NewWorkerPool(config PoolConfig) *WorkerPool {
 
 	return &WorkerPool{
 		fastSem: make(chan struct{}, config.MaxFastWorkers),
-		slowSem: make(chan struct{}, config.MaxSlowWorkers),
+		slow:    newFairScheduler(config.MaxSlowWorkers, config.MaxSlowPerIdentity),
 	}
 }
 
@@ -75,35 +82,51 @@ func (p *WorkerPool) ReleaseFast() {
 // AcquireSlow acquires a slot for a slow operation.
 // Returns an error if the context is cancelled while waiting.
 func (p *WorkerPool) AcquireSlow(ctx context.Context) error {
+	return p.AcquireSlowAs(ctx, "")
+}
+
+// ReleaseSlow releases a slow operation slot.
+func (p *WorkerPool) ReleaseSlow() {
+	p.ReleaseSlowAs("")
+}
+
+// AcquireSlowAs acquires a slot for a slow operation of a client identity
+// (see HashIdentity; "" is no identity). While the pool is full, freed slots
+// go round-robin to the identities waiting, so one client's burst does not
+// hold up the others, and no identity gets more than MaxSlowPerIdentity.
+// Returns an error if the context is cancelled while waiting.
+func (p *WorkerPool) AcquireSlowAs(ctx context.Context, identity string) error {
 	atomic.AddInt64(&p.queuedSlow, 1)
 	defer atomic.AddInt64(&p.queuedSlow, -1)
 
-	select {
-	case p.slowSem <- struct{}{}:
-		atomic.AddInt64(&p.activeSlow, 1)
-		return nil
-	case <-ctx.Done():
-		return ctx.Err()
+	if err := p.slow.acquire(ctx, identity); err != nil {
+		return err
 	}
+	atomic.AddInt64(&p.activeSlow, 1)
+	return nil
 }
 
-// ReleaseSlow releases a slow operation slot.
-func (p *WorkerPool) ReleaseSlow() {
+// ReleaseSlowAs releases a slow operation slot acquired by AcquireSlowAs.
+func (p *WorkerPool) ReleaseSlowAs(identity string) {
 	atomic.AddInt64(&p.activeSlow, -1)
 	atomic.AddInt64(&p.totalSlow, 1)
-	<-p.slowSem
+	p.slow.release(identity)
 }
 
 // Stats returns current pool statistics.
 type PoolStats struct {
-	ActiveFast  int64 `json:"active_fast"`
-	ActiveSlow  int64 `json:"active_slow"`
-	QueuedFast  int64 `json:"queued_fast"`
-	QueuedSlow  int64 `json:"queued_slow"`
-	TotalFast   int64 `json:"total_fast"`
-	TotalSlow   int64 `json:"total_slow"`
-	MaxFast     int   `json:"max_fast"`
-	MaxSlow     int   `json:"max_slow"`
+	ActiveFast int64 `json:"active_fast"`
+	ActiveSlow int64 `json:"active_slow"`
+	QueuedFast int64 `json:"queued_fast"`
+	QueuedSlow int64 `json:"queued_slow"`
+	TotalFast  int64 `json:"total_fast"`
+	TotalSlow  int64 `json:"total_slow"`
+	MaxFast    int   `json:"max_fast"`
+	MaxSlow    int   `json:"max_slow"`
+
+	// Slow pool usage by client identity, when requests carry identities
+	MaxSlowPerIdentity int                      `json:"max_slow_per_identity,omitempty"`
+	Identities         map[string]IdentityStats `json:"identities,omitempty"`
 }
 
 // Stats returns current pool statistics.
@@ -116,7 +139,10 @@ func (p *WorkerPool) Stats() PoolStats {
 		TotalFast:  atomic.LoadInt64(&p.totalFast),
 		TotalSlow:  atomic.LoadInt64(&p.totalSlow),
 		MaxFast:    cap(p.fastSem),
-		MaxSlow:    cap(p.slowSem),
+		MaxSlow:    p.slow.slots,
+
+		MaxSlowPerIdentity: p.slow.perIdentity,
+		Identities:         p.slow.stats(),
 	}
 }
 
@@ -135,13 +161,11 @@ func (p *WorkerPool) TryAcquireFast() bool {
 // TryAcquireSlow tries to acquire a slow slot without blocking.
 // Returns true if acquired, false if pool is full.
 func (p *WorkerPool) TryAcquireSlow() bool {
-	select {
-	case p.slowSem <- struct{}{}:
-		atomic.AddInt64(&p.activeSlow, 1)
-		return true
-	default:
+	if !p.slow.tryAcquire("") {
 		return false
 	}
+	atomic.AddInt64(&p.activeSlow, 1)
+	return true
 }
 
 // AcquireSlowWithTimeout tries to acquire a slow slot with a timeout.
@@ -150,4 +174,3 @@ func (p *WorkerPool) AcquireSlowWithTimeout(timeout time.Duration) error {
 	defer cancel()
 	return p.AcquireSlow(ctx)
 }
-
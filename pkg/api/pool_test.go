@@ -2,6 +2,12 @@ package api
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -160,3 +166,188 @@ func TestWorkerPoolStats(t *testing.T) {
 	}
 }
 
+// waitWaiting waits until n slow operations are queued in the pool's
+// scheduler, so that tests submit them in a known order.
+func waitWaiting(t *testing.T, pool *WorkerPool, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		pool.slow.mu.Lock()
+		waiting := 0
+		for _, q := range pool.slow.queues {
+			waiting += len(q.waiters)
+		}
+		pool.slow.mu.Unlock()
+		if waiting == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d slow operations waiting, want %d", waiting, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// slowBurst holds the only slot of a one-slot slow pool while one slow
+// operation per entry of identities queues, in order, and returns the order
+// in which they ran once the slot is released.
+func slowBurst(t *testing.T, pool *WorkerPool, identities []string) []string {
+	t.Helper()
+	ctx := context.Background()
+	if err := pool.AcquireSlowAs(ctx, "holder"); err != nil {
+		t.Fatalf("Failed to acquire slow worker: %v", err)
+	}
+
+	var (
+		mu    sync.Mutex
+		order []string
+		wg    sync.WaitGroup
+	)
+	for i, identity := range identities {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := pool.AcquireSlowAs(ctx, identity); err != nil {
+				t.Errorf("Failed to acquire slow worker: %v", err)
+				return
+			}
+			mu.Lock()
+			order = append(order, fmt.Sprintf("%s%d", identity, i))
+			mu.Unlock()
+			pool.ReleaseSlowAs(identity)
+		}()
+		waitWaiting(t, pool, i+1)
+	}
+
+	pool.ReleaseSlowAs("holder")
+	wg.Wait()
+	return order
+}
+
+func TestWorkerPoolFairShare(t *testing.T) {
+	pool := NewWorkerPool(PoolConfig{MaxFastWorkers: 1, MaxSlowWorkers: 1})
+
+	// Client a submits a burst before client b; they take turns
+	order := slowBurst(t, pool, []string{"a", "a", "a", "a", "b", "b", "b"})
+	want := []string{"a0", "b4", "a1", "b5", "a2", "b6", "a3"}
+	if !reflect.DeepEqual(order, want) {
+		t.Errorf("completion order %v, want %v", order, want)
+	}
+
+	stats := pool.Stats()
+	if stats.Identities["a"] != (IdentityStats{TotalSlow: 4}) || stats.Identities["b"] != (IdentityStats{TotalSlow: 3}) {
+		t.Errorf("identity stats %+v, want 4 completed for a and 3 for b", stats.Identities)
+	}
+	if stats.TotalSlow != 8 || stats.ActiveSlow != 0 || stats.QueuedSlow != 0 {
+		t.Errorf("pool stats %+v, want 8 completed and none active or queued", stats)
+	}
+}
+
+func TestWorkerPoolWithoutIdentitiesFIFO(t *testing.T) {
+	pool := NewWorkerPool(PoolConfig{MaxFastWorkers: 1, MaxSlowWorkers: 1})
+
+	// Operations without an identity run in the order they came, as in a
+	// plain semaphore
+	order := slowBurst(t, pool, make([]string, 6))
+	want := []string{"0", "1", "2", "3", "4", "5"}
+	if !reflect.DeepEqual(order, want) {
+		t.Errorf("completion order %v, want %v", order, want)
+	}
+
+	// Only the holder shows as an identity
+	stats := pool.Stats()
+	if len(stats.Identities) != 1 || stats.MaxSlowPerIdentity != 0 {
+		t.Errorf("identity stats %+v, cap %d; want the holder only and no cap", stats.Identities, stats.MaxSlowPerIdentity)
+	}
+}
+
+func TestWorkerPoolIdentityCap(t *testing.T) {
+	pool := NewWorkerPool(PoolConfig{MaxFastWorkers: 1, MaxSlowWorkers: 3, MaxSlowPerIdentity: 2})
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if err := pool.AcquireSlowAs(ctx, "a"); err != nil {
+			t.Fatalf("Failed to acquire slow worker %d: %v", i+1, err)
+		}
+	}
+
+	// A third slot is free, but not to a
+	timeoutCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if err := pool.AcquireSlowAs(timeoutCtx, "a"); err != context.DeadlineExceeded {
+		t.Fatalf("Expected context.DeadlineExceeded for a third slot, got %v", err)
+	}
+	if err := pool.AcquireSlowAs(ctx, "b"); err != nil {
+		t.Fatalf("b failed to acquire the free slot: %v", err)
+	}
+	if pool.TryAcquireSlow() {
+		t.Error("Should not be able to acquire a fourth slow worker")
+	}
+
+	// a waits while b's slot frees up, and runs once one of its own does
+	acquired := make(chan struct{})
+	go func() {
+		if err := pool.AcquireSlowAs(ctx, "a"); err != nil {
+			t.Errorf("Failed to acquire slow worker: %v", err)
+		}
+		close(acquired)
+	}()
+	waitWaiting(t, pool, 1)
+	pool.ReleaseSlowAs("b")
+	if got := pool.Stats().Identities["a"]; got != (IdentityStats{ActiveSlow: 2, QueuedSlow: 1}) {
+		t.Errorf("a: %+v, want 2 active and 1 queued", got)
+	}
+	pool.ReleaseSlowAs("a")
+	select {
+	case <-acquired:
+	case <-time.After(5 * time.Second):
+		t.Fatal("a's queued operation did not run")
+	}
+
+	stats := pool.Stats()
+	if stats.Identities["a"] != (IdentityStats{ActiveSlow: 2, TotalSlow: 1}) || stats.ActiveSlow != 2 || stats.MaxSlowPerIdentity != 2 {
+		t.Errorf("stats %+v, want a with 2 active and 1 completed", stats)
+	}
+	pool.ReleaseSlowAs("a")
+	pool.ReleaseSlowAs("a")
+}
+
+func TestMetricsIdentities(t *testing.T) {
+	config := DefaultConfig()
+	config.IdentityHeader = "X-API-Key"
+	config.MaxSlowPerIdentity = 1
+	handler := NewServer(getTestEngine(), config, "test").Handler()
+
+	req := httptest.NewRequest("POST", "/api/rollout", strings.NewReader(`{"position":"4HPwATDgc/ABMA","trials":10,"truncate":5,"seed":1}`))
+	req.Header.Set("X-API-Key", "secret-key")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("rollout: status %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(w.Body)
+	identity := HashIdentity("secret-key")
+	for _, line := range []string{
+		`bgengine_pool_completed_total{pool="slow"} 1`,
+		`bgengine_pool_slots{pool="slow"} 4`,
+		fmt.Sprintf(`bgengine_identity_slow_completed_total{identity=%q} 1`, identity),
+		fmt.Sprintf(`bgengine_identity_slow_active{identity=%q} 0`, identity),
+	} {
+		if !strings.Contains(string(body), line+"\n") {
+			t.Errorf("metrics lack %q:\n%s", line, body)
+		}
+	}
+	if strings.Contains(string(body), "secret-key") {
+		t.Error("metrics show the API key")
+	}
+
+	// The preflight for a browser request allows the identity header
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("OPTIONS", "/api/rollout", nil))
+	if got := w.Header().Get("Access-Control-Allow-Headers"); got != "Content-Type, X-API-Key" {
+		t.Errorf("Access-Control-Allow-Headers = %q", got)
+	}
+}
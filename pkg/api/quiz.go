@@ -0,0 +1,373 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yourusername/bgengine/internal/positionid"
+	"github.com/yourusername/bgengine/pkg/engine"
+)
+
+// Quiz sessions ask checker play problems drawn from the server's position
+// database, or from the errors of a stored match, and grade the answers with
+// the tutor. A session starts with the first GET /api/quiz/next and lives in
+// memory until it has been idle for quizIdleTimeout.
+
+const (
+	maxQuizSessions = 1000             // Sessions open at once
+	quizIdleTimeout = 30 * time.Minute // Idle sessions are dropped after this
+)
+
+// QuizProblemResponse is the response for GET /api/quiz/next.
+type QuizProblemResponse struct {
+	Session    string `json:"session"`    // Session ID, sent back with the answer and the next request
+	Number     int    `json:"number"`     // Problem number in the session, from 1
+	Position   string `json:"position"`   // Position ID, player on roll
+	Dice       [2]int `json:"dice"`       // Roll to play
+	Category   string `json:"category"`   // Position category, e.g. "holding"
+	Difficulty int    `json:"difficulty"` // 1 (easy) - 5 (hard)
+	Source     string `json:"source"`     // Position database entry or match move the problem comes from
+	Name       string `json:"name"`       // Human-readable name of the source
+}
+
+// QuizAnswerRequest is the request body for POST /api/quiz/answer.
+type QuizAnswerRequest struct {
+	Session           string `json:"session"`                      // Session ID
+	Move              string `json:"move"`                         // Answer (e.g., "8/5 6/5")
+	ResultingPosition string `json:"resulting_position,omitempty"` // Position ID after the move, opponent on roll (alternative to move)
+}
+
+// QuizScore is the running score of a quiz session.
+type QuizScore struct {
+	Answered    int     `json:"answered"`     // Problems answered
+	Correct     int     `json:"correct"`      // Answers with no error
+	EquityLoss  float64 `json:"equity_loss"`  // Total equity lost
+	AverageLoss float64 `json:"average_loss"` // Equity lost per answer
+}
+
+// QuizAnswerResponse is the response for POST /api/quiz/answer.
+type QuizAnswerResponse struct {
+	Result TutorMoveResponse `json:"result"` // Tutor analysis of the answer
+	Score  QuizScore         `json:"score"`  // Session score including this answer
+}
+
+// quizHub is the registry of quiz sessions.
+type quizHub struct {
+	mu       sync.Mutex
+	sessions map[string]*quizSession
+}
+
+// quizSession is one quiz. The generator has its own lock; the other fields
+// are guarded by mu.
+type quizSession struct {
+	gen *engine.QuizGenerator
+
+	mu      sync.Mutex
+	current *engine.QuizProblem // Problem awaiting an answer
+	number  int
+	score   QuizScore
+	used    time.Time
+}
+
+func newQuizHub() *quizHub {
+	return &quizHub{sessions: make(map[string]*quizSession)}
+}
+
+// get returns the session with id, or nil.
+func (qh *quizHub) get(id string) *quizSession {
+	qh.mu.Lock()
+	defer qh.mu.Unlock()
+	qs := qh.sessions[id]
+	if qs != nil {
+		qs.touch()
+	}
+	return qs
+}
+
+// add registers a new session and returns its ID, or "" if there are too
+// many open sessions.
+func (qh *quizHub) add(qs *quizSession) string {
+	qh.mu.Lock()
+	defer qh.mu.Unlock()
+
+	if len(qh.sessions) >= maxQuizSessions {
+		qh.dropIdle(time.Now())
+		if len(qh.sessions) >= maxQuizSessions {
+			return ""
+		}
+	}
+	for {
+		b := make([]byte, 8)
+		rand.Read(b)
+		id := hex.EncodeToString(b)
+		if _, ok := qh.sessions[id]; !ok {
+			qs.touch()
+			qh.sessions[id] = qs
+			return id
+		}
+	}
+}
+
+// dropIdle removes sessions unused for quizIdleTimeout.
+// The caller must hold qh.mu.
+func (qh *quizHub) dropIdle(now time.Time) {
+	for id, qs := range qh.sessions {
+		qs.mu.Lock()
+		idle := now.Sub(qs.used) > quizIdleTimeout
+		qs.mu.Unlock()
+		if idle {
+			delete(qh.sessions, id)
+		}
+	}
+}
+
+func (qs *quizSession) touch() {
+	qs.mu.Lock()
+	qs.used = time.Now()
+	qs.mu.Unlock()
+}
+
+// QuizNext handles GET /api/quiz/next. Without a session parameter it starts
+// a new session, drawing from the position database or, with match=ID, from
+// the errors of a stored analyzed match.
+func (h *Handlers) QuizNext(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	category := engine.CategoryUnknown
+	if s := query.Get("category"); s != "" {
+		var err error
+		if category, err = engine.ParsePositionCategory(s); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error(), "INVALID_CATEGORY")
+			return
+		}
+	}
+	difficulty := 0
+	if s := query.Get("difficulty"); s != "" {
+		var err error
+		if difficulty, err = strconv.Atoi(s); err != nil || difficulty < 1 || difficulty > 5 {
+			writeError(w, http.StatusBadRequest, "difficulty must be 1-5", "INVALID_DIFFICULTY")
+			return
+		}
+	}
+
+	id := query.Get("session")
+	var qs *quizSession
+	if id != "" {
+		if qs = h.quizzes.get(id); qs == nil {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("no quiz session %q", id), "QUIZ_SESSION_NOT_FOUND")
+			return
+		}
+	} else {
+		if qs = h.newQuizSession(w, r); qs == nil {
+			return
+		}
+		if id = h.quizzes.add(qs); id == "" {
+			writeError(w, http.StatusServiceUnavailable, "too many quiz sessions", "TOO_MANY_SESSIONS")
+			return
+		}
+	}
+
+	if h.pool != nil {
+		if err := h.pool.AcquireFast(r.Context()); err != nil {
+			writeError(w, http.StatusServiceUnavailable, "server busy", "SERVER_BUSY")
+			return
+		}
+		defer h.pool.ReleaseFast()
+	}
+
+	p, err := qs.gen.Next(category, difficulty)
+	if errors.Is(err, engine.ErrNoQuizProblem) {
+		// The request is valid, but no problem left matches it
+		writeError(w, http.StatusUnprocessableEntity, err.Error(), "NO_QUIZ_PROBLEM")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error(), "ANALYSIS_ERROR")
+		return
+	}
+
+	qs.mu.Lock()
+	qs.current = p
+	qs.number++
+	number := qs.number
+	qs.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, QuizProblemResponse{
+		Session:    id,
+		Number:     number,
+		Position:   positionid.PositionID(positionid.Board(p.Board)),
+		Dice:       p.Dice,
+		Category:   categoryName(p.Category),
+		Difficulty: p.Difficulty,
+		Source:     p.Source,
+		Name:       p.Name,
+	})
+}
+
+// newQuizSession creates a session from the request's seed, min_gap, max_gap
+// and match parameters, writing an error response and returning nil if they
+// are invalid.
+func (h *Handlers) newQuizSession(w http.ResponseWriter, r *http.Request) *quizSession {
+	query := r.URL.Query()
+	opts := engine.DefaultQuizOptions()
+	if s := query.Get("seed"); s != "" {
+		var err error
+		if opts.Seed, err = strconv.ParseInt(s, 10, 64); err != nil {
+			writeError(w, http.StatusBadRequest, "seed must be an integer", "INVALID_SEED")
+			return nil
+		}
+	}
+	for name, gap := range map[string]*float64{"min_gap": &opts.MinGap, "max_gap": &opts.MaxGap} {
+		if s := query.Get(name); s != "" {
+			var err error
+			if *gap, err = strconv.ParseFloat(s, 64); err != nil {
+				writeError(w, http.StatusBadRequest, name+" must be a number", "INVALID_GAP")
+				return nil
+			}
+		}
+	}
+
+	gen, err := engine.NewQuizGenerator(h.engine, opts)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error(), "INVALID_GAP")
+		return nil
+	}
+
+	if query.Get("match") == "" {
+		gen.AddPositions(h.positions.All())
+		return &quizSession{gen: gen}
+	}
+
+	store := h.matchStore(w)
+	if store == nil {
+		return nil
+	}
+	id := query.Get("match")
+	sm, err := store.Get(id)
+	if err != nil {
+		writeMatchStoreError(w, id, err)
+		return nil
+	}
+	if sm.Analysis == nil {
+		writeError(w, http.StatusConflict, fmt.Sprintf("match %s has not been analyzed", sm.ID), "ANALYSIS_NOT_FOUND")
+		return nil
+	}
+	if err := gen.AddMoveErrors(sm.Analysis.MoveErrors); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error(), "MATCH_STORE_ERROR")
+		return nil
+	}
+	return &quizSession{gen: gen}
+}
+
+// QuizAnswer handles POST /api/quiz/answer, grading the answer to the
+// session's current problem.
+func (h *Handlers) QuizAnswer(w http.ResponseWriter, r *http.Request) {
+	var req QuizAnswerRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if req.Session == "" {
+		writeError(w, http.StatusBadRequest, "session is required", "MISSING_SESSION")
+		return
+	}
+	if req.Move == "" && req.ResultingPosition == "" {
+		writeError(w, http.StatusBadRequest, "move or resulting_position is required", "MISSING_MOVE")
+		return
+	}
+	if req.Move != "" && req.ResultingPosition != "" {
+		writeError(w, http.StatusBadRequest, "specify either move or resulting_position, not both", "INVALID_MOVE")
+		return
+	}
+
+	qs := h.quizzes.get(req.Session)
+	if qs == nil {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("no quiz session %q", req.Session), "QUIZ_SESSION_NOT_FOUND")
+		return
+	}
+	qs.mu.Lock()
+	p := qs.current
+	qs.mu.Unlock()
+	if p == nil {
+		writeError(w, http.StatusConflict, "no problem is awaiting an answer", "NO_OPEN_PROBLEM")
+		return
+	}
+
+	// Resolve the answer to a legal move
+	var result engine.Board
+	if req.ResultingPosition != "" {
+		b, err := parsePositionID(req.ResultingPosition)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid resulting position ID: %v", err), "INVALID_POSITION")
+			return
+		}
+		result = engine.Board(b)
+	} else {
+		m, err := engine.ParseMove(req.Move)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid move notation: %v", err), "INVALID_MOVE")
+			return
+		}
+		result = engine.ResultingBoard(p.Board, m)
+	}
+	answer, err := engine.FindMoveForResult(p.Board, result, p.Dice)
+	var illegal *engine.IllegalMoveError
+	if errors.As(err, &illegal) {
+		writeIllegalMove(w, illegal)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error(), "ANALYSIS_ERROR")
+		return
+	}
+
+	if h.pool != nil {
+		if err := h.pool.AcquireFast(r.Context()); err != nil {
+			writeError(w, http.StatusServiceUnavailable, "server busy", "SERVER_BUSY")
+			return
+		}
+		defer h.pool.ReleaseFast()
+	}
+
+	gs := p.State()
+	analysis, err := h.engine.AnalyzeMoveSkill(gs, answer, p.Dice)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error(), "ANALYSIS_ERROR")
+		return
+	}
+
+	qs.mu.Lock()
+	if qs.current != p {
+		// Another request answered it, or moved on, first
+		qs.mu.Unlock()
+		writeError(w, http.StatusConflict, "no problem is awaiting an answer", "NO_OPEN_PROBLEM")
+		return
+	}
+	qs.current = nil
+	qs.score.Answered++
+	if analysis.Skill == engine.SkillNone {
+		qs.score.Correct++
+	}
+	qs.score.EquityLoss += analysis.EquityLoss
+	qs.score.AverageLoss = qs.score.EquityLoss / float64(qs.score.Answered)
+	score := qs.score
+	qs.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, QuizAnswerResponse{
+		Result: tutorMoveResponse(gs, analysis),
+		Score:  score,
+	})
+}
+
+// categoryName returns the name of a position category as used in query
+// parameters, e.g. "safety_play".
+func categoryName(c engine.PositionCategory) string {
+	return strings.ReplaceAll(strings.ToLower(c.String()), " ", "_")
+}
@@ -0,0 +1,208 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/yourusername/bgengine/internal/positionid"
+	"github.com/yourusername/bgengine/pkg/engine"
+	"github.com/yourusername/bgengine/pkg/match"
+)
+
+// Short bearoffs with a quiz-worthy roll even without nets, calling other positions even
+const (
+	quizPositionA      = "QgAACAUAAAAAAA" // 6-2 and 6-3 are worth asking
+	quizPositionB      = "FQAAEAkAAAAAAA" // Only 6-3 is worth asking
+	quizPositionForced = "AQAABAAAAAAAAA" // Every roll is forced
+)
+
+// quizServer returns the handler of a server quizzing from a small database
+// of bearoff positions.
+func quizServer(t *testing.T, store match.Store) http.Handler {
+	t.Helper()
+	db := engine.NewPositionDB()
+	for _, p := range []struct {
+		id, name string
+		cat      engine.PositionCategory
+	}{
+		{quizPositionA, "a", engine.CategoryBearoff},
+		{quizPositionB, "b", engine.CategoryRace},
+		{quizPositionForced, "forced", engine.CategoryBearoff},
+	} {
+		entry, err := engine.CreatePositionEntry(p.id, p.name, p.cat, "", nil)
+		if err != nil {
+			t.Fatalf("CreatePositionEntry(%s): %v", p.id, err)
+		}
+		db.Add(entry)
+	}
+	config := DefaultConfig()
+	config.PositionDB = db
+	config.MatchStore = store
+	eng, err := engine.NewEngine(engine.EngineOptions{EvenFallback: true})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	return NewServer(eng, config, "test").Handler()
+}
+
+// quizAnswers returns the positions after the best and worst moves of a
+// problem, and the best move.
+func quizAnswers(t *testing.T, p QuizProblemResponse) (best, worst, bestMove string) {
+	t.Helper()
+	board, err := positionid.BoardFromPositionID(p.Position)
+	if err != nil {
+		t.Fatalf("problem position %q: %v", p.Position, err)
+	}
+	result, err := getTestEngine().AnalyzePosition(&engine.GameState{Board: engine.Board(board), CubeValue: 1, CubeOwner: -1}, p.Dice)
+	if err != nil || len(result.Moves) < 2 {
+		t.Fatalf("problem %+v has %d moves (%v)", p, len(result.Moves), err)
+	}
+	b := engine.Board(board)
+	return engine.ResultingPositionID(b, result.Moves[0].Move),
+		engine.ResultingPositionID(b, result.Moves[len(result.Moves)-1].Move),
+		engine.FormatMove(result.Moves[0].Move)
+}
+
+func TestQuizSession(t *testing.T) {
+	handler := quizServer(t, nil)
+
+	var first QuizProblemResponse
+	if code := serve(t, handler, "GET", "/api/quiz/next?seed=42", nil, &first); code != http.StatusOK {
+		t.Fatalf("next status = %d, want 200", code)
+	}
+	if first.Session == "" || first.Number != 1 || first.Name == "forced" {
+		t.Fatalf("first problem = %+v", first)
+	}
+
+	// The same seed asks the same problem
+	var again QuizProblemResponse
+	serve(t, handler, "GET", "/api/quiz/next?seed=42", nil, &again)
+	if again.Session == first.Session || again.Position != first.Position || again.Dice != first.Dice {
+		t.Errorf("second session with the seed asked %+v, want %+v", again, first)
+	}
+
+	best, _, bestMove := quizAnswers(t, first)
+	var answer QuizAnswerResponse
+	if code := serve(t, handler, "POST", "/api/quiz/answer", QuizAnswerRequest{Session: first.Session, ResultingPosition: best}, &answer); code != http.StatusOK {
+		t.Fatalf("answer status = %d, want 200", code)
+	}
+	if answer.Result.Skill != "none" || answer.Result.BestMove != bestMove ||
+		answer.Score != (QuizScore{Answered: 1, Correct: 1}) {
+		t.Errorf("answer with the best move = %+v", answer)
+	}
+
+	// A problem is answered once
+	if code := serve(t, handler, "POST", "/api/quiz/answer", QuizAnswerRequest{Session: first.Session, ResultingPosition: best}, nil); code != http.StatusConflict {
+		t.Errorf("second answer: status %d, want 409", code)
+	}
+
+	var second QuizProblemResponse
+	serve(t, handler, "GET", "/api/quiz/next?session="+first.Session, nil, &second)
+	if second.Session != first.Session || second.Number != 2 ||
+		(second.Position == first.Position && second.Dice == first.Dice) {
+		t.Fatalf("second problem = %+v", second)
+	}
+	_, worst, _ := quizAnswers(t, second)
+	serve(t, handler, "POST", "/api/quiz/answer", QuizAnswerRequest{Session: first.Session, ResultingPosition: worst}, &answer)
+	if answer.Result.Skill == "none" || answer.Score.Answered != 2 || answer.Score.Correct != 1 ||
+		answer.Score.EquityLoss != answer.Result.EquityLoss || answer.Score.AverageLoss != answer.Result.EquityLoss/2 {
+		t.Errorf("answer with the worst move = %+v", answer)
+	}
+
+	// Three problems in all, none from the forced position
+	serve(t, handler, "GET", "/api/quiz/next?session="+first.Session, nil, &second)
+	if code := serve(t, handler, "GET", "/api/quiz/next?session="+first.Session, nil, nil); code != http.StatusUnprocessableEntity {
+		t.Errorf("fourth problem: status %d, want 422", code)
+	}
+
+	var race QuizProblemResponse
+	serve(t, handler, "GET", "/api/quiz/next?category=race", nil, &race)
+	if race.Name != "b" || race.Category != "race" || race.Dice != [2]int{6, 3} {
+		t.Errorf("race problem = %+v, want b with 63", race)
+	}
+}
+
+func TestQuizErrors(t *testing.T) {
+	handler := quizServer(t, nil)
+
+	var open QuizProblemResponse
+	serve(t, handler, "GET", "/api/quiz/next?category=race", nil, &open)
+
+	tests := []struct {
+		method, path string
+		body         interface{}
+		status       int
+		code         string
+	}{
+		{"GET", "/api/quiz/next?category=sideways", nil, http.StatusBadRequest, "INVALID_CATEGORY"},
+		{"GET", "/api/quiz/next?difficulty=6", nil, http.StatusBadRequest, "INVALID_DIFFICULTY"},
+		{"GET", "/api/quiz/next?min_gap=0.3&max_gap=0.1", nil, http.StatusBadRequest, "INVALID_GAP"},
+		{"GET", "/api/quiz/next?seed=x", nil, http.StatusBadRequest, "INVALID_SEED"},
+		{"GET", "/api/quiz/next?session=missing", nil, http.StatusNotFound, "QUIZ_SESSION_NOT_FOUND"},
+		{"GET", "/api/quiz/next?category=holding", nil, http.StatusUnprocessableEntity, "NO_QUIZ_PROBLEM"},
+		{"GET", "/api/quiz/next?match=abc", nil, http.StatusNotImplemented, "NO_MATCH_STORE"},
+		{"POST", "/api/quiz/answer", QuizAnswerRequest{Move: "6/off"}, http.StatusBadRequest, "MISSING_SESSION"},
+		{"POST", "/api/quiz/answer", QuizAnswerRequest{Session: open.Session}, http.StatusBadRequest, "MISSING_MOVE"},
+		{"POST", "/api/quiz/answer", QuizAnswerRequest{Session: "missing", Move: "6/off"}, http.StatusNotFound, "QUIZ_SESSION_NOT_FOUND"},
+		{"POST", "/api/quiz/answer", QuizAnswerRequest{Session: open.Session, Move: "24/18 24/21"}, http.StatusBadRequest, "ILLEGAL_MOVE"},
+	}
+	for _, tt := range tests {
+		var data []byte
+		if tt.body != nil {
+			data, _ = json.Marshal(tt.body)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, bytes.NewReader(data)))
+		var resp ErrorResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		if w.Code != tt.status || resp.Code != tt.code {
+			t.Errorf("%s %s: %d %s, want %d %s", tt.method, tt.path, w.Code, resp.Code, tt.status, tt.code)
+		}
+	}
+}
+
+func TestQuizFromMatch(t *testing.T) {
+	store, err := match.OpenFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("OpenFileStore error: %v", err)
+	}
+	handler := quizServer(t, store)
+
+	fixture, err := os.ReadFile("../match/testdata/beavers.mat")
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	var uploaded MatchSummaryResponse
+	serve(t, handler, "POST", "/api/matches", MatchUploadRequest{Content: string(fixture)}, &uploaded)
+
+	if code := serve(t, handler, "GET", "/api/quiz/next?match="+uploaded.ID, nil, nil); code != http.StatusConflict {
+		t.Errorf("quiz from an unanalyzed match: status %d, want 409", code)
+	}
+	if code := serve(t, handler, "GET", "/api/quiz/next?match=missing", nil, nil); code != http.StatusNotFound {
+		t.Errorf("quiz from a missing match: status %d, want 404", code)
+	}
+
+	// The nets-free test engine finds no errors in the match, so give it some
+	analysis := &engine.MatchAnalysis{MoveErrors: []engine.MoveErrorDetail{
+		{GameNumber: 1, MoveNumber: 4, Position: quizPositionB, Dice: [2]int{6, 3}},
+		{GameNumber: 2, MoveNumber: 9, Position: quizPositionB, Dice: [2]int{4, 3}},
+	}}
+	if err := store.SetAnalysis(uploaded.ID, analysis, 0); err != nil {
+		t.Fatalf("SetAnalysis error: %v", err)
+	}
+
+	var p QuizProblemResponse
+	if code := serve(t, handler, "GET", "/api/quiz/next?match="+uploaded.ID, nil, &p); code != http.StatusOK {
+		t.Fatalf("quiz from the match: status %d", code)
+	}
+	if p.Source != "game 1 move 4" || p.Position != quizPositionB || p.Dice != [2]int{6, 3} {
+		t.Errorf("problem = %+v, want game 1 move 4", p)
+	}
+	if code := serve(t, handler, "GET", "/api/quiz/next?session="+p.Session, nil, nil); code != http.StatusUnprocessableEntity {
+		t.Errorf("second problem from the match: status %d, want 422", code)
+	}
+}
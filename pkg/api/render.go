@@ -0,0 +1,95 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/yourusername/bgengine/pkg/engine"
+	"github.com/yourusername/bgengine/pkg/render"
+)
+
+// Render handles GET /api/render, drawing a position as an SVG image with
+// optional dice and move arrows. Unlike the JSON requests, the cube is
+// centered unless cube_owner is given.
+func (h *Handlers) Render(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	position := query.Get("position")
+	if position == "" {
+		writeError(w, http.StatusBadRequest, "position is required", "MISSING_POSITION")
+		return
+	}
+	board, err := parsePositionID(position)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid position ID: %v", err), "INVALID_POSITION")
+		return
+	}
+	state := &engine.GameState{Board: engine.Board(board), CubeValue: 1, CubeOwner: -1}
+
+	var opts render.RenderOptions
+	if s := query.Get("size"); s != "" {
+		if opts.Size, err = strconv.Atoi(s); err != nil || opts.Size < 1 || opts.Size > render.MaxSize {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("size must be 1-%d", render.MaxSize), "INVALID_SIZE")
+			return
+		}
+	}
+	if s := query.Get("cube"); s != "" {
+		if state.CubeValue, err = strconv.Atoi(s); err != nil || state.CubeValue < 1 || state.CubeValue&(state.CubeValue-1) != 0 {
+			writeError(w, http.StatusBadRequest, "cube must be a power of 2", "INVALID_CUBE")
+			return
+		}
+	}
+	if s := query.Get("cube_owner"); s != "" {
+		if state.CubeOwner, err = strconv.Atoi(s); err != nil || state.CubeOwner < -1 || state.CubeOwner > 1 {
+			writeError(w, http.StatusBadRequest, "cube_owner must be -1, 0 or 1", "INVALID_PLAYER")
+			return
+		}
+	}
+	state.Crawford, _ = strconv.ParseBool(query.Get("crawford"))
+	opts.FromOpponent, _ = strconv.ParseBool(query.Get("from_opponent"))
+
+	if s := query.Get("dice"); s != "" {
+		if opts.Dice, err = parseQueryDice(s); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error(), "INVALID_DICE")
+			return
+		}
+	}
+	if s := query.Get("move"); s != "" {
+		if opts.Dice == ([2]int{}) {
+			writeError(w, http.StatusBadRequest, "dice are required with a move", "MISSING_DICE")
+			return
+		}
+		m, err := engine.ParseMove(s)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid move notation: %v", err), "INVALID_MOVE")
+			return
+		}
+		_, err = engine.FindMoveForResult(state.Board, engine.ResultingBoard(state.Board, m), opts.Dice)
+		var illegal *engine.IllegalMoveError
+		if errors.As(err, &illegal) {
+			writeIllegalMove(w, illegal)
+			return
+		}
+		opts.Move = &m
+	}
+
+	svg, err := render.RenderSVG(state, opts)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error(), "INVALID_POSITION")
+		return
+	}
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Write(svg)
+}
+
+// parseQueryDice parses a roll written as "31", "3-1" or "3,1".
+func parseQueryDice(s string) ([2]int, error) {
+	digits := strings.NewReplacer("-", "", ",", "").Replace(s)
+	if len(digits) != 2 || digits[0] < '1' || digits[0] > '6' || digits[1] < '1' || digits[1] > '6' {
+		return [2]int{}, fmt.Errorf("invalid dice %q: want two dice of 1-6 such as 31", s)
+	}
+	return [2]int{int(digits[0] - '0'), int(digits[1] - '0')}, nil
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	handler := NewServer(getTestEngine(), DefaultConfig(), "test").Handler()
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/render?position=4HPwATDgc/ABMA&dice=31&move=8/5+6/5&size=300", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "image/svg+xml" {
+		t.Errorf("Content-Type = %q, want image/svg+xml", ct)
+	}
+	svg := w.Body.String()
+	if !strings.HasPrefix(svg, `<svg xmlns="http://www.w3.org/2000/svg" width="300"`) {
+		t.Errorf("body does not start with a 300 pixel svg: %.80s", svg)
+	}
+	if n := strings.Count(svg, "marker-end"); n != 2 {
+		t.Errorf("drew %d move arrows, want 2", n)
+	}
+
+	tests := []struct {
+		query string
+		code  string
+	}{
+		{"", "MISSING_POSITION"},
+		{"position=nonsense", "INVALID_POSITION"},
+		{"position=4HPwATDgc/ABMA&size=0", "INVALID_SIZE"},
+		{"position=4HPwATDgc/ABMA&cube=3", "INVALID_CUBE"},
+		{"position=4HPwATDgc/ABMA&cube_owner=2", "INVALID_PLAYER"},
+		{"position=4HPwATDgc/ABMA&dice=71", "INVALID_DICE"},
+		{"position=4HPwATDgc/ABMA&move=8/5+6/5", "MISSING_DICE"},
+		{"position=4HPwATDgc/ABMA&dice=31&move=24/18", "ILLEGAL_MOVE"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/render?"+tt.query, nil))
+		var resp ErrorResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		if w.Code != http.StatusBadRequest || resp.Code != tt.code {
+			t.Errorf("%q: %d %s, want 400 %s", tt.query, w.Code, resp.Code, tt.code)
+		}
+	}
+}
@@ -0,0 +1,576 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/yourusername/bgengine/pkg/engine"
+)
+
+// Shared builders for the evaluate, move and cube responses.
+// The REST handlers and the bgengine CLI both go through these so that the
+// same position produces the same numbers everywhere.
+
+// MaxPly is the deepest evaluation accepted from clients.
+const MaxPly = 2
+
+// AnalyzeOptions selects what Analyze computes.
+type AnalyzeOptions struct {
+	Dice      [2]int // Dice roll; zero for no move analysis
+	Ply       int    // Evaluation depth (0 to MaxPly)
+	NumMoves  int    // Max moves to return (default 5)
+	Consensus bool   // Check the move decision against the engine's other sources
+}
+
+// AnalyzeResponse is the combined analysis of a single position.
+// Its parts have the same shape as the /api/evaluate, /api/move and
+// /api/cube responses.
+type AnalyzeResponse struct {
+	Position   string            `json:"position"`        // Position ID
+	Pips       [2]int            `json:"pips"`            // Pip counts [player on roll, opponent]
+	Evaluation *EvaluateResponse `json:"evaluation"`      // Cubeless evaluation
+	Cube       *CubeResponse     `json:"cube"`            // Cube decision
+	Moves      *MovesResponse    `json:"moves,omitempty"` // Ranked moves, when dice are given
+}
+
+// Analyze evaluates the position, its cube decision and, when dice are given,
+// the best moves.
+func Analyze(e *engine.Engine, position string, gs *engine.GameState, opts AnalyzeOptions) (*AnalyzeResponse, error) {
+	if opts.Ply < 0 || opts.Ply > MaxPly {
+		return nil, fmt.Errorf("ply must be 0-%d, got %d", MaxPly, opts.Ply)
+	}
+
+	eval, err := EvaluateAtPly(e, gs, opts.Ply, nil)
+	if err != nil {
+		return nil, fmt.Errorf("evaluation failed: %w", err)
+	}
+
+	decision, err := e.AnalyzeCube(gs)
+	if err != nil {
+		return nil, fmt.Errorf("cube analysis failed: %w", err)
+	}
+
+	race, err := RaceCube(e, gs)
+	if err != nil {
+		return nil, fmt.Errorf("race cube analysis failed: %w", err)
+	}
+
+	pips := engine.PipCount(gs.Board)
+	resp := &AnalyzeResponse{
+		Position:   position,
+		Pips:       [2]int{pips[1], pips[0]},
+		Evaluation: eval,
+		Cube:       CubeToResponse(decision),
+	}
+	resp.Cube.Race = race
+	resp.Cube.Entry = EntryToResponse(e.EntryStats(gs))
+
+	if opts.Dice[0] != 0 || opts.Dice[1] != 0 {
+		analysis, err := e.AnalyzePosition(gs, opts.Dice)
+		if err != nil {
+			return nil, fmt.Errorf("move analysis failed: %w", err)
+		}
+		resp.Moves = MovesToResponse(analysis, gs.Board, position, opts.Dice, opts.NumMoves)
+		if opts.Consensus {
+			consensus, err := e.ConsensusAnalysis(gs, opts.Dice)
+			if err != nil {
+				return nil, fmt.Errorf("consensus analysis failed: %w", err)
+			}
+			resp.Moves.Consensus = ConsensusToResponse(consensus)
+		}
+	}
+
+	return resp, nil
+}
+
+// EvaluateAtPly evaluates the position with ply-deep lookahead, adding to
+// timing if not nil.
+func EvaluateAtPly(e *engine.Engine, gs *engine.GameState, ply int, timing *engine.Timing) (*EvaluateResponse, error) {
+	full, err := e.EvaluateFull(gs, engine.EvalOptions{Plies: ply, UsePrune: true, Timing: timing})
+	if err != nil {
+		return nil, err
+	}
+	resp := FullEvalToResponse(full, ply)
+	resp.Timing = TimingToResponse(timing)
+	return resp, nil
+}
+
+// Action works out the best action of the player on roll for an action
+// request: the cube decision, and the best numMoves moves if dice are given.
+// numMoves <= 0 means 5.
+func Action(e *engine.Engine, position string, gs *engine.GameState, dice *[2]int, numMoves int) (*ActionResponse, error) {
+	action, err := e.BestAction(gs, dice)
+	if err != nil {
+		return nil, err
+	}
+	race, err := RaceCube(e, gs)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &ActionResponse{
+		Action:       "roll",
+		MissedDouble: action.MissedDouble,
+		Cube:         CubeToResponse(action.Cube),
+	}
+	resp.Cube.Race = race
+	resp.Cube.Entry = EntryToResponse(e.EntryStats(gs))
+	switch {
+	case action.Moves != nil:
+		resp.Action = "move"
+		resp.Moves = MovesToResponse(action.Moves, gs.Board, position, *dice, numMoves)
+	case action.Double:
+		resp.Action = "double"
+	}
+	return resp, nil
+}
+
+// AnalyzeMoves ranks the moves for a move request. Without a time limit moves
+// are ranked at req.Ply plies (0 if unset); with time_limit_ms the search
+// deepens up to req.Ply (engine.DefaultTimedPlies if unset) until the time
+// runs out. The engine time is added to timing if not nil. With req.PV the
+// moves evaluated with lookahead record their principal variations.
+func AnalyzeMoves(e *engine.Engine, gs *engine.GameState, req *MoveRequest, timing *engine.Timing) (*engine.AnalysisResult, error) {
+	ply := 0
+	if req.Ply != nil {
+		ply = *req.Ply
+	} else if req.TimeLimitMs > 0 {
+		ply = engine.DefaultTimedPlies
+	}
+	if ply == 0 && timing == nil {
+		return e.AnalyzePosition(gs, req.Dice)
+	}
+	opts := engine.EvalOptions{Plies: ply, UsePrune: true, Timing: timing}
+	if req.PV {
+		opts.PV = new(engine.PrincipalVariation)
+	}
+	if req.TimeLimitMs > 0 {
+		opts.TimeLimit = time.Duration(req.TimeLimitMs) * time.Millisecond
+	}
+	return e.AnalyzePositionWithOptions(gs, req.Dice, opts)
+}
+
+// ConstrainMoves restricts a move analysis of board to the moves that satisfy
+// c. It returns the restricted analysis and a summary of what c costs, or an
+// error wrapping engine.ErrNoMoveAllowed if no legal move satisfies it.
+func ConstrainMoves(analysis *engine.AnalysisResult, board engine.Board, c engine.MoveConstraint) (*engine.AnalysisResult, *ConstraintResponse, error) {
+	filtered, err := c.Filter(board, analysis)
+	if err != nil {
+		return nil, nil, err
+	}
+	resp := &ConstraintResponse{
+		Constraints: c.String(),
+		NumAllowed:  len(filtered.Moves),
+		Cost:        filtered.Cost,
+	}
+	restricted := &engine.AnalysisResult{Moves: filtered.Moves, NumMoves: analysis.NumMoves}
+	if len(filtered.Moves) > 0 {
+		resp.Best = moveResponse(filtered.Best, engine.ResultingPositionID(board, filtered.Best.Move))
+		restricted.BestMove = filtered.Moves[0].Move
+		restricted.BestEquity = filtered.Moves[0].Equity
+	}
+	return restricted, resp, nil
+}
+
+// ConsensusToResponse converts a consensus analysis to an API response.
+func ConsensusToResponse(c *engine.ConsensusResult) *ConsensusResponse {
+	resp := &ConsensusResponse{
+		Level:          c.Level.String(),
+		Checks:         make([]ConsensusCheckResponse, len(c.Checks)),
+		Recommendation: c.Recommendation,
+	}
+	if c.NumMoves > 0 {
+		resp.BestMove = engine.FormatMove(c.BestMove.Move)
+	}
+	for i, check := range c.Checks {
+		resp.Checks[i] = ConsensusCheckResponse{
+			Kind:    check.Kind,
+			Gap:     check.Gap,
+			Level:   check.Level.String(),
+			Message: check.Message,
+		}
+		for _, s := range []engine.ConsensusSource{check.A, check.B} {
+			resp.Checks[i].Sources = append(resp.Checks[i].Sources, ConsensusSourceResponse{
+				Name:   s.Name,
+				Move:   engine.FormatMove(s.Move),
+				Equity: s.Equity,
+			})
+		}
+	}
+	return resp
+}
+
+// MovesToResponse converts the best numMoves of a move analysis of board to an
+// API response. numMoves <= 0 means 5.
+func MovesToResponse(analysis *engine.AnalysisResult, board engine.Board, position string, dice [2]int, numMoves int) *MovesResponse {
+	if numMoves <= 0 {
+		numMoves = 5
+	}
+	if numMoves > len(analysis.Moves) {
+		numMoves = len(analysis.Moves)
+	}
+
+	moves := make([]MoveResponse, numMoves)
+	for i := 0; i < numMoves; i++ {
+		m := analysis.Moves[i]
+		moves[i] = moveResponse(m, engine.ResultingPositionID(board, m.Move))
+	}
+
+	return &MovesResponse{
+		Moves:    moves,
+		NumLegal: analysis.NumMoves,
+		Dice:     dice,
+		Position: position,
+	}
+}
+
+// moveResponse converts a ranked move to an API response, with the full
+// evaluation after it. positionID is the position after the move.
+func moveResponse(m engine.MoveWithEval, positionID string) MoveResponse {
+	resp := MoveResponse{
+		Move:       engine.FormatMove(m.Move),
+		Equity:     m.Equity,
+		PositionID: positionID,
+		Ply:        m.Ply,
+
+		CubefulEquity: m.CubefulEquity,
+		MWC:           m.MWC * 100,
+		PositionClass: m.Class,
+	}
+	if m.Eval != nil {
+		resp.Win = m.Eval.WinProb * 100
+		resp.WinG = m.Eval.WinG * 100
+		resp.WinBG = m.Eval.WinBG * 100
+		resp.LoseG = m.Eval.LoseG * 100
+		resp.LoseBG = m.Eval.LoseBG * 100
+		resp.CubelessEquity = m.Eval.Equity
+	}
+	for _, tag := range m.Tags {
+		resp.Tags = append(resp.Tags, string(tag))
+	}
+	resp.PV = pvResponse(m.PV)
+	return resp
+}
+
+// pvResponse converts a principal variation to an API response, nil for none.
+func pvResponse(pv *engine.PrincipalVariation) *PVResponse {
+	if pv == nil {
+		return nil
+	}
+	resp := &PVResponse{
+		Position: pv.Position,
+		Ply:      pv.Ply,
+		Equity:   pv.Equity,
+		Rolls:    make([]PVRollResponse, len(pv.Rolls)),
+	}
+	for i, r := range pv.Rolls {
+		resp.Rolls[i] = PVRollResponse{
+			Dice:        r.Dice,
+			Probability: r.Probability * 100,
+			Move:        engine.FormatMove(r.Move),
+			PositionID:  r.After,
+			Ply:         r.Ply,
+			Equity:      r.Eval.Equity,
+			Win:         r.Eval.WinProb * 100,
+			WinG:        r.Eval.WinG * 100,
+			WinBG:       r.Eval.WinBG * 100,
+			LoseG:       r.Eval.LoseG * 100,
+			LoseBG:      r.Eval.LoseBG * 100,
+			Impact:      r.Impact,
+			Next:        pvResponse(r.Next),
+		}
+	}
+	return resp
+}
+
+// CubeToResponse converts an engine cube analysis to an API response. The
+// action and verdict come from the decision type the engine found.
+func CubeToResponse(decision *engine.CubeAnalysis) *CubeResponse {
+	resp := &CubeResponse{
+		Action:          decision.DecisionType.Action(),
+		DecisionType:    decision.DecisionType.String(),
+		Verdict:         decision.DecisionType.Verdict(),
+		Decision:        CubeDecisionText(decision.DecisionType),
+		DoubleEquity:    decision.DoubleTakeEq,
+		PassEquity:      decision.DoublePassEq,
+		NoDoubleEquity:  decision.NoDoubleEquity,
+		DoubleDiff:      decision.DoubleTakeEq - decision.NoDoubleEquity,
+		TakePoint:       decision.TakePoint * 100,
+		CashPoint:       (1 - decision.TakePoint) * 100,
+		DoublePoint:     decision.DoublePoint * 100,
+		RecubeTakePoint: decision.RecubeTakePoint * 100,
+		Points:          cubePointsResponse(decision.Points),
+		CubeEfficiency:  decision.CubeEfficiency,
+		Source:          decision.Source,
+		MatchContext:    matchContextResponse(decision.MatchContext),
+		eval:            &decision.Eval,
+	}
+	if decision.Source == engine.CubeSourceExact {
+		// Exact equities owe nothing to a cube efficiency
+		resp.CubeEfficiency = 0
+	}
+	if v := decision.Opponent; v != nil {
+		resp.TakeEquity = v.TakeEquity
+		resp.OpponentView = &OpponentViewResponse{
+			TakeEquity:     v.TakeEquity,
+			PassEquity:     v.PassEquity,
+			PassCost:       v.PassCost,
+			Win:            v.WinProb * 100,
+			TakePoint:      v.TakePoint * 100,
+			Margin:         v.Margin * 100,
+			GammonRisk:     v.GammonRisk * 100,
+			BackgammonRisk: v.BackgammonRisk * 100,
+			TakeMWC:        v.TakeMWC * 100,
+			PassMWC:        v.PassMWC * 100,
+		}
+	}
+	return resp
+}
+
+func cubePointsResponse(points []engine.CubePoints) []CubePointsResponse {
+	var resp []CubePointsResponse
+	for _, p := range points {
+		resp = append(resp, CubePointsResponse{
+			Cube:      p.Cube,
+			TakePoint: p.TakePoint * 100,
+			CashPoint: p.CashPoint * 100,
+			Margin:    p.Margin * 100,
+		})
+	}
+	return resp
+}
+
+// RaceCube returns the race cube analysis of gs, or nil if gs is not a race.
+func RaceCube(e *engine.Engine, gs *engine.GameState) (*RaceCubeResponse, error) {
+	rc, err := e.RaceCubeAnalysis(gs)
+	if errors.Is(err, engine.ErrNotRace) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return RaceCubeToResponse(rc), nil
+}
+
+// RaceCubeToResponse converts a race cube analysis to percentages.
+func RaceCubeToResponse(rc *engine.RaceCube) *RaceCubeResponse {
+	resp := &RaceCubeResponse{
+		Pips:         rc.Pips,
+		Lead:         rc.Lead,
+		LeadPct:      rc.LeadPct,
+		Win:          rc.WinProb * 100,
+		Source:       rc.Source,
+		Verdict:      rc.Verdict,
+		DoublePoint:  rc.DoublePoint * 100,
+		TakePoint:    rc.TakePoint * 100,
+		PipsToDouble: rc.PipsToDouble,
+		PipsToPass:   rc.PipsToPass,
+		Summary:      RaceCubeText(rc),
+		Table:        make([]RaceCubeRowResponse, len(rc.Table)),
+	}
+	for i, row := range rc.Table {
+		resp.Table[i] = RaceCubeRowResponse{
+			Lead:       row.Lead,
+			Win:        row.WinProb * 100,
+			NoDouble:   row.NoDouble,
+			DoubleTake: row.DoubleTake,
+			Verdict:    row.Verdict,
+		}
+	}
+	return resp
+}
+
+// RaceCubeText describes where a race stands against the minimal double
+// and the last take.
+func RaceCubeText(rc *engine.RaceCube) string {
+	text := fmt.Sprintf("Race of %d against %d pips: a lead of %+d (%+.1f%%) and %.1f%% winning chances.",
+		rc.Pips[0], rc.Pips[1], rc.Lead, rc.LeadPct, rc.WinProb*100)
+	if rc.Verdict == engine.RaceNotAvailable {
+		return text + " The cube is not available."
+	}
+	distance := func(pips float64) string {
+		if pips > 0 {
+			return fmt.Sprintf("%.1f pips away", pips)
+		}
+		return fmt.Sprintf("passed by %.1f pips", -pips)
+	}
+	return text + fmt.Sprintf(" The minimal double is at %.1f%% (%s); the last take at %.1f%% (%s).",
+		rc.DoublePoint*100, distance(rc.PipsToDouble), rc.TakePoint*100, distance(rc.PipsToPass))
+}
+
+// EntryToResponse converts bar entry stats to API responses.
+func EntryToResponse(stats []engine.EntryStats) []EntryResponse {
+	if len(stats) == 0 {
+		return nil
+	}
+	resp := make([]EntryResponse, len(stats))
+	for i, s := range stats {
+		resp[i] = EntryResponse{
+			Opponent:     s.Opponent,
+			OnBar:        s.OnBar,
+			PointsMade:   s.PointsMade,
+			EnterRolls:   s.EnterRolls,
+			FullRolls:    s.FullRolls,
+			PartialRolls: s.PartialRolls,
+			DanceRolls:   s.DanceRolls,
+			Dance:        s.DanceProb * 100,
+			Summary:      EntryText(s),
+		}
+	}
+	return resp
+}
+
+// EntryText describes bar entry as a sentence about the player on roll or
+// the opponent.
+func EntryText(s engine.EntryStats) string {
+	if s.Opponent {
+		return entrySentence(s, "The opponent", false)
+	}
+	return entrySentence(s, "The player on roll", false)
+}
+
+// entrySentence describes the entry of s with subject, which takes plural
+// verbs if plural is set ("You").
+func entrySentence(s engine.EntryStats, subject string, plural bool) string {
+	verb := func(singular, pl string) string {
+		if plural {
+			return pl
+		}
+		return singular
+	}
+	points := fmt.Sprintf("%d home board point", s.PointsMade)
+	if s.PointsMade != 1 {
+		points += "s"
+	}
+	dance := fmt.Sprintf("%.1f%%", s.DanceProb*100)
+
+	if s.OnBar == 1 {
+		only := ""
+		if s.PointsMade >= engine.BlitzEntryPoints {
+			only = "only "
+		}
+		if s.DanceRolls == 36 {
+			return subject + " cannot enter against a closed board."
+		}
+		return fmt.Sprintf("%s %s with %s%d/36 rolls against %s and %s %s of the time.",
+			subject, verb("enters", "enter"), only, s.EnterRolls, points, verb("dances", "dance"), dance)
+	}
+
+	all := "both"
+	if s.OnBar > 2 {
+		all = fmt.Sprintf("all %d", s.OnBar)
+	}
+	text := fmt.Sprintf("%s %s %d checkers on the bar against %s: %d/36 rolls enter %s",
+		subject, verb("has", "have"), s.OnBar, points, s.FullRolls, all)
+	if s.PartialRolls > 0 {
+		text += fmt.Sprintf(", %d/36 only some", s.PartialRolls)
+	}
+	return text + fmt.Sprintf(" and %d/36 dance (%s).", s.DanceRolls, dance)
+}
+
+// matchContextResponse converts the match context of a cube analysis to
+// percentages. It returns nil for money games.
+func matchContextResponse(mc *engine.CubeMatchContext) *MatchContextResponse {
+	if mc == nil {
+		return nil
+	}
+	outcomes := make([]MatchOutcomeResponse, len(mc.Outcomes))
+	for i, o := range mc.Outcomes {
+		outcomes[i] = MatchOutcomeResponse{Cube: o.Cube, Points: o.Points, MWC: o.MWC * 100, OppMWC: o.OppMWC * 100}
+	}
+	return &MatchContextResponse{
+		MWC:            mc.MWC * 100,
+		Outcomes:       outcomes,
+		WinMWC:         mc.WinMWC[0] * 100,
+		LoseMWC:        mc.LoseMWC[0] * 100,
+		WinMWCDoubled:  mc.WinMWC[1] * 100,
+		LoseMWCDoubled: mc.LoseMWC[1] * 100,
+		DoubleRisk:     mc.DoubleRisk * 100,
+		DoubleGain:     mc.DoubleGain * 100,
+		TakeRisk:       mc.TakeRisk * 100,
+		TakeGain:       mc.TakeGain * 100,
+		TakePoint:      mc.TakePoint * 100,
+	}
+}
+
+// CubeDecisionText returns the verbal form of a cube decision.
+func CubeDecisionText(t engine.CubeDecisionType) string {
+	switch t {
+	case engine.DOUBLE_TAKE, engine.REDOUBLE_TAKE:
+		return "Double, Take"
+	case engine.DOUBLE_PASS, engine.REDOUBLE_PASS:
+		return "Double, Pass"
+	case engine.NODOUBLE_TAKE, engine.NODOUBLE_BEAVER:
+		return "No Double"
+	case engine.TOOGOOD_TAKE, engine.TOOGOOD_PASS, engine.TOOGOODRE_TAKE, engine.TOOGOODRE_PASS:
+		return "Too Good to Double"
+	case engine.NOT_AVAILABLE:
+		return "Cube Not Available"
+	default:
+		return "No Double"
+	}
+}
+
+// WriteReport writes a plain text report of an analysis.
+func WriteReport(w io.Writer, r *AnalyzeResponse) error {
+	ew := &errWriter{w: w}
+	ev := r.Evaluation
+
+	ew.printf("Position: %s\n\n", r.Position)
+	ew.printf("Evaluation (%d-ply):\n", ev.Ply)
+	ew.printf("  Equity: %+.3f\n", ev.Equity)
+	ew.printf("  Win:    %.1f%% (G: %.1f%%, BG: %.1f%%)\n", ev.Win, ev.WinG, ev.WinBG)
+	ew.printf("  Lose:   %.1f%% (G: %.1f%%, BG: %.1f%%)\n", 100-ev.Win, ev.LoseG, ev.LoseBG)
+
+	ew.printf("\nPip count: %d (on roll) vs %d (diff %+d)\n",
+		r.Pips[0], r.Pips[1], r.Pips[1]-r.Pips[0])
+
+	c := r.Cube
+	ew.printf("\nCube Decision: %s\n", c.Decision)
+	ew.printf("  No double equity:   %+.3f\n", c.NoDoubleEquity)
+	ew.printf("  Double/Take equity: %+.3f\n", c.DoubleEquity)
+	ew.printf("  Take point: %.1f%%  Cash point: %.1f%%\n", c.TakePoint, c.CashPoint)
+	if c.Race != nil {
+		ew.printf("  %s\n", c.Race.Summary)
+	}
+
+	if m := r.Moves; m != nil {
+		ew.printf("\nBest moves for roll %d-%d (%d legal):\n", m.Dice[0], m.Dice[1], m.NumLegal)
+		if len(m.Moves) == 0 {
+			ew.printf("  No legal moves (forced to pass)\n")
+		}
+		for i, mv := range m.Moves {
+			diff := ""
+			if i > 0 {
+				diff = fmt.Sprintf("(%+.3f)", mv.Equity-m.Moves[0].Equity)
+			}
+			ew.printf("  %d. %-20s  Eq: %+.3f %-9s Win: %.1f%%\n", i+1, mv.Move, mv.Equity, diff, mv.Win)
+		}
+		if c := m.Consensus; c != nil {
+			ew.printf("\nConsensus: %s\n", c.Level)
+			for _, check := range c.Checks {
+				ew.printf("  %-8s %s\n", check.Kind+":", check.Message)
+			}
+			if c.Recommendation != "" {
+				ew.printf("  Recommendation: %s\n", c.Recommendation)
+			}
+		}
+	}
+
+	return ew.err
+}
+
+// errWriter keeps the first write error so a report can be written without
+// checking every line.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (ew *errWriter) printf(format string, args ...interface{}) {
+	if ew.err == nil {
+		_, ew.err = fmt.Fprintf(ew.w, format, args...)
+	}
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/yourusername/bgengine/internal/positionid"
+	"github.com/yourusername/bgengine/pkg/engine"
+)
+
+// handlerJSON runs a handler on req and returns the decoded JSON response.
+func handlerJSON(t *testing.T, handler http.HandlerFunc, req interface{}) interface{} {
+	t.Helper()
+	body, _ := json.Marshal(req)
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest("POST", "/", bytes.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &v); err != nil {
+		t.Fatalf("Unmarshal error: %v", err)
+	}
+	return v
+}
+
+// roundTrip returns v as decoded JSON.
+func roundTrip(t *testing.T, v interface{}) interface{} {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("Marshal error: %v", err)
+	}
+	var out interface{}
+	json.Unmarshal(data, &out)
+	return out
+}
+
+// TestAnalyzeMatchesHandlers checks that each part of the combined analysis is
+// what the REST endpoint returns for the same position.
+func TestAnalyzeMatchesHandlers(t *testing.T) {
+	e := getTestEngine()
+	h := NewHandlers(e, "test")
+
+	// Starting position and one with a checker on the bar
+	start := engine.StartingPosition().Board
+	hit := start
+	hit[0][23]--
+	hit[0][24]++
+	hit[1][12]--
+	hit[1][20]++
+
+	for _, board := range []engine.Board{start, hit} {
+		posID := positionid.PositionID(positionid.Board(board))
+		gs, err := parseGameState(posID, &EvaluateRequest{})
+		if err != nil {
+			t.Fatalf("parseGameState(%s): %v", posID, err)
+		}
+
+		report, err := Analyze(e, posID, gs, AnalyzeOptions{Dice: [2]int{3, 1}, Ply: 1, NumMoves: 3, Consensus: true})
+		if err != nil {
+			t.Fatalf("Analyze(%s): %v", posID, err)
+		}
+
+		parts := []struct {
+			name string
+			got  interface{}
+			want interface{}
+		}{
+			{"evaluation", report.Evaluation, handlerJSON(t, h.Evaluate, EvaluateRequest{Position: posID, Ply: intPtr(1)})},
+			{"moves", report.Moves, handlerJSON(t, h.Move, MoveRequest{Position: posID, Dice: [2]int{3, 1}, NumMoves: 3, Consensus: true})},
+			{"cube", report.Cube, handlerJSON(t, h.Cube, CubeRequest{Position: posID})},
+		}
+		for _, p := range parts {
+			if got := roundTrip(t, p.got); !reflect.DeepEqual(got, p.want) {
+				t.Errorf("%s %s:\n  analyze %v\n  handler %v", posID, p.name, got, p.want)
+			}
+		}
+
+		pips := engine.PipCount(board)
+		if report.Pips != [2]int{pips[1], pips[0]} {
+			t.Errorf("%s pips = %v, want %v", posID, report.Pips, [2]int{pips[1], pips[0]})
+		}
+
+		var buf bytes.Buffer
+		if err := WriteReport(&buf, report); err != nil {
+			t.Fatalf("WriteReport error: %v", err)
+		}
+		if want := "Consensus: " + report.Moves.Consensus.Level; !strings.Contains(buf.String(), want) || !strings.Contains(buf.String(), "ply:") {
+			t.Errorf("%s report missing %q and the checks:\n%s", posID, want, buf.String())
+		}
+	}
+}
+
+func TestAnalyzeWithoutDice(t *testing.T) {
+	e := getTestEngine()
+	gs, _ := parseGameState("4HPwATDgc/ABMA", &EvaluateRequest{})
+
+	report, err := Analyze(e, "4HPwATDgc/ABMA", gs, AnalyzeOptions{})
+	if err != nil {
+		t.Fatalf("Analyze error: %v", err)
+	}
+	if report.Moves != nil {
+		t.Errorf("moves = %+v without dice", report.Moves)
+	}
+	if report.Pips != [2]int{167, 167} {
+		t.Errorf("pips = %v, want [167 167]", report.Pips)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteReport(&buf, report); err != nil {
+		t.Fatalf("WriteReport error: %v", err)
+	}
+	for _, want := range []string{"Equity:", "Pip count: 167", "Cube Decision: ", "Take point:"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("report missing %q:\n%s", want, buf.String())
+		}
+	}
+
+	if _, err := Analyze(e, "4HPwATDgc/ABMA", gs, AnalyzeOptions{Ply: MaxPly + 1}); err == nil {
+		t.Error("expected error for ply above MaxPly")
+	}
+}
@@ -7,21 +7,48 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"runtime/debug"
 	"syscall"
 	"time"
 
 	"github.com/yourusername/bgengine/pkg/engine"
+	"github.com/yourusername/bgengine/pkg/match"
 )
 
 // ServerConfig holds the server configuration.
 type ServerConfig struct {
-	Host           string        // Host to bind to (default "localhost")
-	Port           int           // Port to listen on (default 8080)
-	ReadTimeout    time.Duration // Read timeout (default 30s)
-	WriteTimeout   time.Duration // Write timeout (default 30s)
-	IdleTimeout    time.Duration // Idle timeout (default 60s)
-	MaxFastWorkers int           // Max concurrent fast operations (default 100)
-	MaxSlowWorkers int           // Max concurrent slow operations (default 4)
+	Host           string             // Host to bind to (default "localhost")
+	Port           int                // Port to listen on (default 8080)
+	ReadTimeout    time.Duration      // Read timeout (default 30s)
+	WriteTimeout   time.Duration      // Write timeout (default 30s)
+	IdleTimeout    time.Duration      // Idle timeout (default 60s)
+	MaxFastWorkers int                // Max concurrent fast operations (default 100)
+	MaxSlowWorkers int                // Max concurrent slow operations (default 4)
+	Limits         Limits             // Request size limits (zero fields use DefaultLimits)
+	Debug          bool               // Serve debugging endpoints such as /api/inspect
+	MatchStore     match.Store        // Store for uploaded matches (nil = match endpoints disabled)
+	PositionDB     *engine.PositionDB // Positions for quizzes (nil = engine.DefaultPositionDB)
+
+	// SelfCheck is the engine self-check run at startup, which
+	// /api/health/selfcheck reports; nil runs it on the first request.
+	SelfCheck *engine.SelfCheckReport
+
+	// Client identities. With IdentityHeader set (e.g. "X-API-Key"), slow
+	// operations are scheduled fairly between the values of that header,
+	// each capped at MaxSlowPerIdentity at a time if it is set, and their
+	// usage is reported in /api/health and /metrics by HashIdentity.
+	// Requests without the header share one identity.
+	IdentityHeader     string
+	MaxSlowPerIdentity int // Most slow operations one identity may run at once (0 = no cap)
+
+	// Evaluation depth served. Requests that name no ply get the endpoint's
+	// default; requests beyond MaxPly or MaxRolloutTrials are clamped to them
+	// and answered with "clamped": true, or rejected if StrictLimits is set.
+	DefaultEvalPly   int  // Ply for /api/evaluate requests without one (default 0)
+	DefaultMovePly   int  // Ply for /api/move requests without one (default 0)
+	MaxPly           int  // Deepest ply served, 0 to MaxPly (DefaultConfig: MaxPly; 0 serves 0-ply only)
+	MaxRolloutTrials int  // Most rollout trials served (0 = up to Limits.MaxTrials)
+	StrictLimits     bool // Reject requests beyond MaxPly or MaxRolloutTrials instead of clamping them
 }
 
 // DefaultConfig returns a ServerConfig with sensible defaults.
@@ -34,6 +61,8 @@ func DefaultConfig() ServerConfig {
 		IdleTimeout:    60 * time.Second,
 		MaxFastWorkers: 100,
 		MaxSlowWorkers: 4,
+		Limits:         DefaultLimits(),
+		MaxPly:         MaxPly,
 	}
 }
 
@@ -52,6 +81,8 @@ func NewServer(e *engine.Engine, config ServerConfig, version string) *Server {
 	poolConfig := PoolConfig{
 		MaxFastWorkers: config.MaxFastWorkers,
 		MaxSlowWorkers: config.MaxSlowWorkers,
+
+		MaxSlowPerIdentity: config.MaxSlowPerIdentity,
 	}
 	if poolConfig.MaxFastWorkers <= 0 {
 		poolConfig.MaxFastWorkers = 100
@@ -62,6 +93,17 @@ func NewServer(e *engine.Engine, config ServerConfig, version string) *Server {
 
 	pool := NewWorkerPool(poolConfig)
 	handlers := NewHandlersWithPool(e, version, pool)
+	handlers.limits = config.Limits.withDefaults()
+	handlers.depth = configDepth(config)
+	handlers.debug = config.Debug
+	handlers.identityHeader = config.IdentityHeader
+	handlers.matches = config.MatchStore
+	if config.PositionDB != nil {
+		handlers.positions = config.PositionDB
+	}
+	if config.SelfCheck != nil {
+		handlers.selfCheck.report = config.SelfCheck
+	}
 
 	return &Server{
 		config:   config,
@@ -77,12 +119,17 @@ func (s *Server) Pool() *WorkerPool {
 	return s.pool
 }
 
-// corsMiddleware adds CORS headers for browser access.
-func corsMiddleware(next http.Handler) http.Handler {
+// corsMiddleware adds CORS headers for browser access, allowing the
+// identity header if there is one.
+func corsMiddleware(identityHeader string, next http.Handler) http.Handler {
+	allowHeaders := "Content-Type"
+	if identityHeader != "" {
+		allowHeaders += ", " + identityHeader
+	}
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", allowHeaders)
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -102,34 +149,94 @@ func loggingMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// bodyLimitMiddleware caps request bodies at the limit for their endpoint.
+func bodyLimitMiddleware(limits Limits, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, limits.bodyLimit(r.URL.Path))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// recoverMiddleware turns a panic in a handler into a 500 response carrying an
+// error ID, and logs the stack under that ID, so the server stays up.
+func recoverMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if rec := recover(); rec != nil {
+				if rec == http.ErrAbortHandler {
+					panic(rec)
+				}
+				id := newErrorID()
+				log.Printf("panic in %s %s [%s]: %v\n%s", r.Method, r.URL.Path, id, rec, debug.Stack())
+				writeJSON(w, http.StatusInternalServerError, ErrorResponse{
+					Error:   "internal server error",
+					Code:    "INTERNAL_ERROR",
+					ErrorID: id,
+				})
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
 // setupRoutes configures all API routes.
 func (s *Server) setupRoutes() http.Handler {
 	mux := http.NewServeMux()
 
 	// API routes
 	mux.HandleFunc("GET /api/health", s.handlers.Health)
+	mux.HandleFunc("GET /api/health/selfcheck", s.handlers.SelfCheck)
+	mux.HandleFunc("GET /api/openapi.json", s.handlers.OpenAPI)
+	mux.HandleFunc("GET /metrics", s.handlers.Metrics)
 	mux.HandleFunc("POST /api/evaluate", s.handlers.Evaluate)
 	mux.HandleFunc("POST /api/move", s.handlers.Move)
 	mux.HandleFunc("POST /api/cube", s.handlers.Cube)
+	mux.HandleFunc("POST /api/cube/rollout", s.handlers.CubeRollout)
+	mux.HandleFunc("POST /api/action", s.handlers.Action)
 	mux.HandleFunc("POST /api/rollout", s.handlers.Rollout)
 	mux.HandleFunc("GET /api/rollout/stream", s.handlers.RolloutSSE)
+	mux.HandleFunc("GET /api/admin/rollouts", s.handlers.ListStoredRollouts)
+	mux.HandleFunc("DELETE /api/admin/rollouts/{id}", s.handlers.DeleteStoredRollout)
+	mux.HandleFunc("POST /api/matches", s.handlers.UploadMatch)
+	mux.HandleFunc("GET /api/matches", s.handlers.ListMatches)
+	mux.HandleFunc("GET /api/matches/{id}", s.handlers.GetMatch)
+	mux.HandleFunc("GET /api/matches/{id}/analysis", s.handlers.GetMatchAnalysis)
+	mux.HandleFunc("POST /api/matches/{id}/analyze", s.handlers.AnalyzeMatch)
 	mux.HandleFunc("/api/ws", s.handlers.WebSocket)
+	mux.HandleFunc("POST /api/broadcast", s.handlers.Broadcast)
 	mux.HandleFunc("POST /api/fibsboard", s.handlers.HandleFIBSBoard)
+	mux.HandleFunc("GET /api/inspect", s.handlers.Inspect)
+	mux.HandleFunc("POST /api/inspect", s.handlers.Inspect)
+	mux.HandleFunc("GET /api/render", s.handlers.Render)
+	mux.HandleFunc("GET /api/met", s.handlers.MET)
 
 	// Tutor API routes
 	mux.HandleFunc("POST /api/tutor/move", s.handlers.HandleTutorMove)
 	mux.HandleFunc("POST /api/tutor/cube", s.handlers.HandleTutorCube)
 	mux.HandleFunc("POST /api/tutor/game", s.handlers.HandleAnalyzeGame)
+	mux.HandleFunc("GET /api/quiz/next", s.handlers.QuizNext)
+	mux.HandleFunc("POST /api/quiz/answer", s.handlers.QuizAnswer)
+	mux.HandleFunc("POST /api/bot/play", s.handlers.BotPlay)
+	mux.HandleFunc("POST /api/games", s.handlers.StartGame)
+	mux.HandleFunc("GET /api/games/{id}", s.handlers.GetGame)
+	mux.HandleFunc("POST /api/games/{id}/action", s.handlers.GameAction)
 
 	// Also allow GET for health with legacy pattern
 	mux.HandleFunc("/api/health", s.handlers.Health)
 
 	// Apply middleware
-	handler := corsMiddleware(loggingMiddleware(mux))
+	handler := recoverMiddleware(corsMiddleware(s.config.IdentityHeader, loggingMiddleware(
+		bodyLimitMiddleware(s.handlers.limits, mux))))
 
 	return handler
 }
 
+// Handler returns the server's HTTP handler with all routes and middleware,
+// for embedding in another server or in tests.
+func (s *Server) Handler() http.Handler {
+	return s.setupRoutes()
+}
+
 // Start starts the HTTP server.
 func (s *Server) Start() error {
 	addr := fmt.Sprintf("%s:%d", s.config.Host, s.config.Port)
@@ -145,15 +252,37 @@ func (s *Server) Start() error {
 	log.Printf("Starting GoBG API server v%s on %s", s.version, addr)
 	log.Printf("Endpoints:")
 	log.Printf("  GET  /api/health      - Health check")
+	log.Printf("  GET  /api/health/selfcheck - Engine self-check on sentinel positions")
+	log.Printf("  GET  /api/openapi.json - OpenAPI 3 specification")
+	log.Printf("  GET  /metrics         - Worker pool metrics (Prometheus text format)")
 	log.Printf("  POST /api/evaluate    - Evaluate position")
 	log.Printf("  POST /api/move        - Find best moves")
 	log.Printf("  POST /api/cube        - Cube decision")
+	log.Printf("  POST /api/cube/rollout - Cube decision by rollout")
+	log.Printf("  POST /api/action      - Cube decision or moves, whichever is due")
 	log.Printf("  POST /api/rollout     - Monte Carlo rollout")
+	log.Printf("  GET  /api/admin/rollouts - List stored rollouts")
+	log.Printf("  DELETE /api/admin/rollouts/{id} - Evict a stored rollout")
+	if s.config.MatchStore != nil {
+		log.Printf("  POST /api/matches     - Upload a MAT or SGF match")
+		log.Printf("  GET  /api/matches     - List stored matches")
+		log.Printf("  GET  /api/matches/{id} - Stored match")
+		log.Printf("  GET  /api/matches/{id}/analysis - Stored match analysis")
+		log.Printf("  POST /api/matches/{id}/analyze - Analyze a stored match")
+	}
 	log.Printf("  POST /api/fibsboard   - Analyze FIBS board string")
+	log.Printf("  GET  /api/met         - Match equity table and cube points by score")
 	log.Printf("  POST /api/tutor/move  - Analyze played move")
 	log.Printf("  POST /api/tutor/cube  - Analyze cube decision")
 	log.Printf("  POST /api/tutor/game  - Analyze complete game")
+	log.Printf("  GET  /api/quiz/next   - Next checker play quiz problem")
+	log.Printf("  POST /api/quiz/answer - Grade a quiz answer")
+	log.Printf("  POST /api/bot/play    - Ask a WebSocket bot for a graded decision")
 	log.Printf("  WS   /api/ws          - WebSocket for real-time analysis")
+	log.Printf("  POST /api/broadcast   - Publish an update to WebSocket spectators")
+	if s.config.Debug {
+		log.Printf("  GET  /api/inspect     - Net inputs and raw outputs (debug)")
+	}
 
 	return s.server.ListenAndServe()
 }
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"net/http"
 
-	"github.com/yourusername/bgengine/internal/positionid"
 	"github.com/yourusername/bgengine/pkg/engine"
 )
 
@@ -40,15 +39,19 @@ func (h *Handlers) RolloutSSE(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	board, err := positionid.BoardFromPositionID(position)
+	board, err := parsePositionID(position)
 	if err != nil {
 		writeSSEError(w, "invalid position: "+err.Error())
 		return
 	}
 
-	trials := parseIntParam(query.Get("trials"), 1296)
 	truncate := parseIntParam(query.Get("truncate"), 0)
 	workers := parseIntParam(query.Get("workers"), 0)
+	trials, clamped, err := h.depth.trials(parseIntParam(query.Get("trials"), 0), h.limits)
+	if err != nil {
+		writeSSEError(w, err.Error())
+		return
+	}
 
 	gs := &engine.GameState{
 		Board:     engine.Board(board),
@@ -82,7 +85,10 @@ func (h *Handlers) RolloutSSE(w http.ResponseWriter, r *http.Request) {
 		flusher.Flush()
 	}
 
-	result, err := h.engine.RolloutWithProgress(gs, opts, callback)
+	result, err := h.engine.RolloutContext(r.Context(), gs, opts, callback)
+	if r.Context().Err() != nil {
+		return
+	}
 	if err != nil {
 		writeSSEError(w, "rollout failed: "+err.Error())
 		return
@@ -100,6 +106,10 @@ func (h *Handlers) RolloutSSE(w http.ResponseWriter, r *http.Request) {
 		TrialsCompleted: result.TrialsCompleted,
 		GamesWon:        result.GamesWon,
 		GamesLost:       result.GamesLost,
+		Clamped:         clamped,
+		Seed:            result.Seed,
+		Manifest:        result.Manifest,
+		Engine:          h.engine.BuildInfo(),
 	})
 	flusher.Flush()
 
@@ -137,4 +147,3 @@ func parseIntParam(s string, defaultVal int) int {
 	}
 	return val
 }
-
@@ -1,7 +1,11 @@
 // Package api provides HTTP/JSON REST API for the backgammon engine.
 package api
 
-import "github.com/yourusername/bgengine/pkg/engine"
+import (
+	"time"
+
+	"github.com/yourusername/bgengine/pkg/engine"
+)
 
 // ============================================================================
 // Request Types
@@ -10,41 +14,97 @@ import "github.com/yourusername/bgengine/pkg/engine"
 // EvaluateRequest is the request body for position evaluation.
 type EvaluateRequest struct {
 	Position    string `json:"position"`               // Position ID (gnubg format)
+	Format      string `json:"format,omitempty"`       // Position format: "gnubg" (default) or "snowie"
 	MatchLength int    `json:"match_length,omitempty"` // 0 = money game
 	Score       [2]int `json:"score,omitempty"`        // Match score [player, opponent]
 	CubeValue   int    `json:"cube_value,omitempty"`   // Cube value (default 1)
 	CubeOwner   int    `json:"cube_owner,omitempty"`   // -1=centered, 0=player, 1=opponent
 	Crawford    bool   `json:"crawford,omitempty"`     // Crawford game
-	Ply         int    `json:"ply,omitempty"`          // Evaluation depth (0, 1, or 2)
+	Ply         *int   `json:"ply,omitempty"`          // Evaluation depth, 0-2 (default: the server's)
+	MET         string `json:"met,omitempty"`          // Match equity table for the match winning chance (default: the server's)
+	Opponent    bool   `json:"opponent,omitempty"`     // Evaluate for the opponent, with the opponent on roll
+	DebugTiming bool   `json:"debug_timing,omitempty"` // Break down the engine time in timing_ms
+	Convention  string `json:"convention,omitempty"`   // Output convention: "gobg" (default) or "xg" (see Convention)
+
+	// AllowPartial takes the checkers on the board as all each side has,
+	// none borne off, for endgame studies that leave out the other
+	// checkers: a side with all of them on the board can be gammoned.
+	AllowPartial bool `json:"allow_partial,omitempty"`
 }
 
 // MoveRequest is the request body for finding best moves.
 type MoveRequest struct {
-	Position    string `json:"position"`               // Position ID (gnubg format)
-	Dice        [2]int `json:"dice"`                   // Dice roll [die1, die2]
-	MatchLength int    `json:"match_length,omitempty"` // 0 = money game
-	Score       [2]int `json:"score,omitempty"`        // Match score
-	CubeValue   int    `json:"cube_value,omitempty"`   // Cube value
-	CubeOwner   int    `json:"cube_owner,omitempty"`   // Cube owner
-	Crawford    bool   `json:"crawford,omitempty"`     // Crawford game
-	NumMoves    int    `json:"num_moves,omitempty"`    // Max moves to return (default 5)
-	Ply         int    `json:"ply,omitempty"`          // Evaluation depth
+	Position    string `json:"position"`                // Position ID (gnubg format)
+	Format      string `json:"format,omitempty"`        // Position format: "gnubg" (default) or "snowie"
+	Dice        [2]int `json:"dice"`                    // Dice roll [die1, die2]
+	MatchLength int    `json:"match_length,omitempty"`  // 0 = money game
+	Score       [2]int `json:"score,omitempty"`         // Match score
+	CubeValue   int    `json:"cube_value,omitempty"`    // Cube value
+	CubeOwner   int    `json:"cube_owner,omitempty"`    // Cube owner
+	Crawford    bool   `json:"crawford,omitempty"`      // Crawford game
+	NumMoves    int    `json:"num_moves,omitempty"`     // Max moves to return (default 5, server limit 100)
+	Ply         *int   `json:"ply,omitempty"`           // Evaluation depth, 0-2 (default: the server's; with time_limit_ms, the deepest to search, default the server's maximum)
+	TimeLimitMs int    `json:"time_limit_ms,omitempty"` // Search deadline in milliseconds (server limit 10000)
+	DebugTiming bool   `json:"debug_timing,omitempty"`  // Break down the engine time in timing_ms
+	Convention  string `json:"convention,omitempty"`    // Output convention: "gobg" (default) or "xg" (see Convention)
+
+	// Constraints rank only the moves that satisfy every term, e.g. "!hit",
+	// "make:5", "!home_blot" or "!move:8/5 6/5" (see engine.ParseMoveConstraint)
+	Constraints []string `json:"constraints,omitempty"`
+
+	// Consensus checks the decision against the engine's other sources
+	// (see engine.ConsensusAnalysis) and reports where they disagree
+	Consensus bool `json:"consensus,omitempty"`
+
+	// PV reports each move's principal variation (see engine.PrincipalVariation):
+	// the opponent's rolls the lookahead found most critical. It needs ply 1
+	// or more.
+	PV bool `json:"pv,omitempty"`
+
+	// AllowPartial ranks the moves with the checkers on the board as all
+	// each side has (see EvaluateRequest.AllowPartial).
+	AllowPartial bool `json:"allow_partial,omitempty"`
 }
 
 // CubeRequest is the request body for cube decision analysis.
 type CubeRequest struct {
 	Position    string `json:"position"`               // Position ID
+	Format      string `json:"format,omitempty"`       // Position format: "gnubg" (default) or "snowie"
 	MatchLength int    `json:"match_length,omitempty"` // 0 = money game
 	Score       [2]int `json:"score,omitempty"`        // Match score
 	CubeValue   int    `json:"cube_value,omitempty"`   // Current cube value
 	CubeOwner   int    `json:"cube_owner,omitempty"`   // Current cube owner
 	Crawford    bool   `json:"crawford,omitempty"`     // Crawford game
+	MET         string `json:"met,omitempty"`          // Match equity table for match play (default: the server's)
+	Opponent    bool   `json:"opponent,omitempty"`     // Analyze the opponent's cube decision, with the opponent on roll
+	DebugTiming bool   `json:"debug_timing,omitempty"` // Break down the engine time in timing_ms
+	Convention  string `json:"convention,omitempty"`   // Output convention: "gobg" (default) or "xg" (see Convention)
+
+	// AllowPartial analyzes the cube with the checkers on the board as all
+	// each side has (see EvaluateRequest.AllowPartial).
+	AllowPartial bool `json:"allow_partial,omitempty"`
+}
+
+// ActionRequest is the request body for the best action of the player on
+// roll: the cube decision before the roll, or the moves after it.
+type ActionRequest struct {
+	Position    string  `json:"position"`               // Position ID
+	Format      string  `json:"format,omitempty"`       // Position format: "gnubg" (default) or "snowie"
+	Dice        *[2]int `json:"dice,omitempty"`         // Dice roll [die1, die2]; omit before the roll
+	MatchLength int     `json:"match_length,omitempty"` // 0 = money game
+	Score       [2]int  `json:"score,omitempty"`        // Match score
+	CubeValue   int     `json:"cube_value,omitempty"`   // Current cube value
+	CubeOwner   int     `json:"cube_owner,omitempty"`   // Current cube owner
+	Crawford    bool    `json:"crawford,omitempty"`     // Crawford game
+	MET         string  `json:"met,omitempty"`          // Match equity table for match play (default: the server's)
+	NumMoves    int     `json:"num_moves,omitempty"`    // Max moves to return (default 5, server limit 100)
 }
 
 // RolloutRequest is the request body for Monte Carlo rollouts.
 type RolloutRequest struct {
 	Position    string `json:"position"`               // Position ID
-	Trials      int    `json:"trials,omitempty"`       // Number of trials (default 1296)
+	Format      string `json:"format,omitempty"`       // Position format: "gnubg" (default) or "snowie"
+	Trials      int    `json:"trials,omitempty"`       // Number of trials (default 1296, server limit 100000; servers may clamp lower)
 	Truncate    int    `json:"truncate,omitempty"`     // Truncate at N plies (0 = full)
 	MatchLength int    `json:"match_length,omitempty"` // 0 = money game
 	Score       [2]int `json:"score,omitempty"`        // Match score
@@ -52,43 +112,75 @@ type RolloutRequest struct {
 	CubeOwner   int    `json:"cube_owner,omitempty"`   // Cube owner
 	Crawford    bool   `json:"crawford,omitempty"`     // Crawford game
 	Seed        int64  `json:"seed,omitempty"`         // Random seed (0 = random)
+	InitialDice [2]int `json:"initial_dice,omitempty"` // Dice already rolled by the player on roll (zero = roll them)
+	MET         string `json:"met,omitempty"`          // Match equity table for match play cube rollouts (default: the server's)
+
+	// AllowPartial plays the position with the checkers on the board as all
+	// there are, none borne off, for endgame studies that leave out the
+	// other checkers: a side that bears them all off before the other bears
+	// one off wins a gammon.
+	AllowPartial bool `json:"allow_partial,omitempty"`
 }
 
 // TutorMoveRequest is the request for analyzing a played move.
 type TutorMoveRequest struct {
-	Position    string `json:"position"`               // Position ID before the move
-	Dice        [2]int `json:"dice"`                   // Dice rolled
-	Move        string `json:"move"`                   // Move played (e.g., "8/5 6/5")
-	MatchLength int    `json:"match_length,omitempty"` // 0 = money game
-	Score       [2]int `json:"score,omitempty"`        // Match score
-	CubeValue   int    `json:"cube_value,omitempty"`   // Cube value
-	CubeOwner   int    `json:"cube_owner,omitempty"`   // Cube owner
-	Crawford    bool   `json:"crawford,omitempty"`     // Crawford game
-	Ply         int    `json:"ply,omitempty"`          // Evaluation depth
+	Position          string  `json:"position"`                     // Position ID before the move
+	Dice              [2]int  `json:"dice"`                         // Dice rolled
+	Move              string  `json:"move"`                         // Move played (e.g., "8/5 6/5")
+	ResultingPosition string  `json:"resulting_position,omitempty"` // Position ID after the move, opponent on roll (alternative to move)
+	MatchLength       int     `json:"match_length,omitempty"`       // 0 = money game
+	Score             [2]int  `json:"score,omitempty"`              // Match score
+	CubeValue         int     `json:"cube_value,omitempty"`         // Cube value
+	CubeOwner         int     `json:"cube_owner,omitempty"`         // Cube owner
+	Crawford          bool    `json:"crawford,omitempty"`           // Crawford game
+	Ply               int     `json:"ply,omitempty"`                // Evaluation depth
+	DoubtfulThreshold float64 `json:"doubtful_threshold,omitempty"` // Equity loss rated doubtful (default 0.03)
+	BadThreshold      float64 `json:"bad_threshold,omitempty"`      // Equity loss rated bad (default 0.06)
+	BlunderThreshold  float64 `json:"blunder_threshold,omitempty"`  // Equity loss rated a blunder (default 0.12)
 }
 
 // TutorCubeRequest is the request for analyzing a cube decision.
 type TutorCubeRequest struct {
-	Position    string `json:"position"`               // Position ID
-	Action      string `json:"action"`                 // "double", "take", "pass", "no_double"
-	MatchLength int    `json:"match_length,omitempty"` // 0 = money game
-	Score       [2]int `json:"score,omitempty"`        // Match score
-	CubeValue   int    `json:"cube_value,omitempty"`   // Current cube value
-	CubeOwner   int    `json:"cube_owner,omitempty"`   // Current cube owner
-	Crawford    bool   `json:"crawford,omitempty"`     // Crawford game
+	Position          string  `json:"position"`                     // Position ID
+	Action            string  `json:"action"`                       // "double", "take", "pass", "beaver", "raccoon", "no_double"
+	MatchLength       int     `json:"match_length,omitempty"`       // 0 = money game
+	Score             [2]int  `json:"score,omitempty"`              // Match score
+	CubeValue         int     `json:"cube_value,omitempty"`         // Current cube value
+	CubeOwner         int     `json:"cube_owner,omitempty"`         // Current cube owner
+	Crawford          bool    `json:"crawford,omitempty"`           // Crawford game
+	DoubtfulThreshold float64 `json:"doubtful_threshold,omitempty"` // Equity loss rated doubtful (default 0.03)
+	BadThreshold      float64 `json:"bad_threshold,omitempty"`      // Equity loss rated bad (default 0.06)
+	BlunderThreshold  float64 `json:"blunder_threshold,omitempty"`  // Equity loss rated a blunder (default 0.12)
+	MET               string  `json:"met,omitempty"`                // Match equity table for match play (default: the server's)
 }
 
 // AnalyzeGameRequest is the request for analyzing a complete game.
 type AnalyzeGameRequest struct {
-	Positions []GamePosition `json:"positions"`            // List of positions with actions
-	MatchPlay bool           `json:"match_play,omitempty"` // True for match, false for money
+	Positions         []GamePosition `json:"positions"`                    // List of positions with actions (server limit 1000)
+	MatchPlay         bool           `json:"match_play,omitempty"`         // True for match, false for money
+	Start             *GameStart     `json:"start,omitempty"`              // Start of a game fragment; positions are then replayed from it
+	DoubtfulThreshold float64        `json:"doubtful_threshold,omitempty"` // Equity loss rated doubtful (default 0.03)
+	BadThreshold      float64        `json:"bad_threshold,omitempty"`      // Equity loss rated bad (default 0.06)
+	BlunderThreshold  float64        `json:"blunder_threshold,omitempty"`  // Equity loss rated a blunder (default 0.12)
+	MET               string         `json:"met,omitempty"`                // Match equity table for match play (default: the server's)
+}
+
+// GameStart is the position a game fragment starts from. The positions of a
+// fragment are worked out by playing its moves and cube actions from here.
+type GameStart struct {
+	Position    string `json:"position"`               // Position ID, seen by the player of the first action
+	MatchLength int    `json:"match_length,omitempty"` // Match length (0 = money game)
+	Score       [2]int `json:"score,omitempty"`        // Score at the start of the fragment
+	CubeValue   int    `json:"cube_value,omitempty"`   // Cube value (default 1, centered)
+	CubeOwner   int    `json:"cube_owner,omitempty"`   // Player owning the cube when cube_value is above 1
+	Crawford    bool   `json:"crawford,omitempty"`     // Crawford game
 }
 
 // FIBSBoardRequest is the request body for FIBS board analysis.
 // Accepts a FIBS board string as defined at http://www.fibs.com/fibs_interface.html#board_state
 type FIBSBoardRequest struct {
 	Board    string `json:"board"`               // FIBS board string (e.g., "board:You:Opponent:5:2:3:...")
-	NumMoves int    `json:"num_moves,omitempty"` // Max moves to return (default 5)
+	NumMoves int    `json:"num_moves,omitempty"` // Max moves to return (default 5, server limit 100)
 }
 
 // GamePosition represents a single position in a game to analyze.
@@ -119,53 +211,367 @@ type EvaluateResponse struct {
 	LoseBG  float64 `json:"lose_bg"` // P(lose backgammon) as percentage
 	Ply     int     `json:"ply"`     // Ply used for evaluation
 	Cubeful bool    `json:"cubeful"` // Whether cubeful evaluation was used
+
+	CubefulEquity float64 `json:"cubeful_equity"` // Cubeful equity with the cube as it is, per unit of the cube
+	MWC           float64 `json:"mwc,omitempty"`  // Cubeless match winning chance as percentage (match play only)
+
+	Opponent bool   `json:"opponent,omitempty"` // The results are the opponent's, with the opponent on roll
+	Source   string `json:"source,omitempty"`   // What evaluated the position: "nn", "bearoff" or "heuristic" (no weights loaded)
+	Clamped  bool   `json:"clamped,omitempty"`  // The requested ply was beyond the server's maximum and was lowered to it
+
+	Convention    string           `json:"convention,omitempty"`    // "xg" when equities are at the cube value (see Convention)
+	Probabilities *XGProbabilities `json:"probabilities,omitempty"` // Six probabilities as percentages, in the xg convention
+
+	Timing *TimingResponse `json:"timing_ms,omitempty"` // Where the engine time went, with debug_timing
 }
 
 // MoveResponse is a single move in the response.
 type MoveResponse struct {
-	Move   string  `json:"move"`   // Human-readable move notation (e.g., "8/5 6/5")
-	Equity float64 `json:"equity"` // Expected value after this move
-	Win    float64 `json:"win"`    // P(win) as percentage
-	WinG   float64 `json:"win_g"`  // P(win gammon) as percentage
+	Move       string   `json:"move"`           // Human-readable move notation (e.g., "8/5 6/5")
+	Equity     float64  `json:"equity"`         // Expected value after this move
+	Win        float64  `json:"win"`            // P(win) as percentage
+	WinG       float64  `json:"win_g"`          // P(win gammon) as percentage
+	WinBG      float64  `json:"win_bg"`         // P(win backgammon) as percentage
+	LoseG      float64  `json:"lose_g"`         // P(lose gammon) as percentage
+	LoseBG     float64  `json:"lose_bg"`        // P(lose backgammon) as percentage
+	PositionID string   `json:"position_id"`    // Position ID after the move, opponent on roll
+	Ply        int      `json:"ply"`            // Depth the move was evaluated at
+	Tags       []string `json:"tags,omitempty"` // What the move does: hit, point, anchor, escape, slot, break_prime
+
+	CubelessEquity float64 `json:"cubeless_equity"`          // Cubeless money equity after this move
+	CubefulEquity  float64 `json:"cubeful_equity"`           // Cubeful equity after this move
+	MWC            float64 `json:"mwc,omitempty"`            // Match winning chance after this move as percentage (match play only)
+	PositionClass  string  `json:"position_class,omitempty"` // Class of the position after the move ("contact", "crashed", "race", "bearoff1", ...)
+
+	Probabilities *XGProbabilities `json:"probabilities,omitempty"` // Six probabilities as percentages, in the xg convention
+
+	PV *PVResponse `json:"pv,omitempty"` // The opponent's most critical rolls after the move, with pv
+}
+
+// PVResponse is a principal variation: the rolls of the side on roll that
+// moved a lookahead evaluation most, each with the best play for it.
+type PVResponse struct {
+	Position string           `json:"position"` // Position ID, the side to roll on roll
+	Ply      int              `json:"ply"`      // Depth the position was evaluated at
+	Equity   float64          `json:"equity"`   // Cubeless equity of the side to roll, averaged over the 21 rolls
+	Rolls    []PVRollResponse `json:"rolls"`    // The 3 rolls of the largest impact, either way, largest first
+}
+
+// PVRollResponse is one roll of a principal variation. Its equity and
+// chances are those of the side that rolled.
+type PVRollResponse struct {
+	Dice        [2]int      `json:"dice"`
+	Probability float64     `json:"probability"`           // Chance of the roll as percentage
+	Move        string      `json:"move"`                  // Best play of the roll ("" if none is legal)
+	PositionID  string      `json:"position_id,omitempty"` // Position ID after the play, opponent on roll
+	Ply         int         `json:"ply"`                   // Depth the position after the play was evaluated at
+	Equity      float64     `json:"equity"`                // Cubeless equity after the play
+	Win         float64     `json:"win"`                   // P(win) as percentage
+	WinG        float64     `json:"win_g"`                 // P(win gammon) as percentage
+	WinBG       float64     `json:"win_bg"`                // P(win backgammon) as percentage
+	LoseG       float64     `json:"lose_g"`                // P(lose gammon) as percentage
+	LoseBG      float64     `json:"lose_bg"`               // P(lose backgammon) as percentage
+	Impact      float64     `json:"impact"`                // Probability times the equity less the variation's: what the roll moves the equity by
+	Next        *PVResponse `json:"next,omitempty"`        // The opponent's rolls after the play, when it was evaluated with lookahead
 }
 
 // MovesResponse is the response for best moves.
 type MovesResponse struct {
-	Moves    []MoveResponse `json:"moves"`     // Ranked moves (best first)
-	NumLegal int            `json:"num_legal"` // Total number of legal moves
-	Dice     [2]int         `json:"dice"`      // Dice used
-	Position string         `json:"position"`  // Position evaluated
+	Moves    []MoveResponse `json:"moves"`             // Ranked moves (best first)
+	NumLegal int            `json:"num_legal"`         // Total number of legal moves
+	Dice     [2]int         `json:"dice"`              // Dice used
+	Position string         `json:"position"`          // Position evaluated
+	Clamped  bool           `json:"clamped,omitempty"` // The requested ply was beyond the server's maximum and was lowered to it
+
+	Convention string              `json:"convention,omitempty"` // "xg" when equities are at the cube value (see Convention)
+	Timing     *TimingResponse     `json:"timing_ms,omitempty"`  // Where the engine time went, with debug_timing
+	Constraint *ConstraintResponse `json:"constraint,omitempty"` // What the constraints cost, with constraints
+	Consensus  *ConsensusResponse  `json:"consensus,omitempty"`  // Where the engine's sources disagree, with consensus
+}
+
+// ConsensusResponse reports how far the engine's sources agree on a move
+// decision (see engine.ConsensusAnalysis).
+type ConsensusResponse struct {
+	Level          string                   `json:"level"`                    // "unanimous", "minor_disagreement" or "conflict"
+	BestMove       string                   `json:"best_move,omitempty"`      // Best move at 1 ply (none without legal moves)
+	Checks         []ConsensusCheckResponse `json:"checks"`                   // Each comparison of two sources
+	Recommendation string                   `json:"recommendation,omitempty"` // What to do about the worst disagreement
+}
+
+// ConsensusCheckResponse compares the verdicts of two sources.
+type ConsensusCheckResponse struct {
+	Kind    string                    `json:"kind"`    // "ply" (0 against 1 ply), "cube" (cubeless against cubeful) or "bearoff" (net against bearoff database)
+	Sources []ConsensusSourceResponse `json:"sources"` // The two sources, the shallower or cheaper first
+	Gap     float64                   `json:"gap"`     // How far apart they are, in equity
+	Level   string                    `json:"level"`   // "unanimous", "minor_disagreement" or "conflict"
+	Message string                    `json:"message"` // The comparison in words
+}
+
+// ConsensusSourceResponse is the verdict of one source.
+type ConsensusSourceResponse struct {
+	Name   string  `json:"name"`   // "0-ply", "1-ply", "cubeless", "cubeful", "nn", "heuristic" or "bearoff"
+	Move   string  `json:"move"`   // Move the source plays, or evaluates for a bearoff check
+	Equity float64 `json:"equity"` // Mover's equity of the move by the source
+}
+
+// ConstraintResponse reports how a move request's constraints restricted the
+// moves.
+type ConstraintResponse struct {
+	Constraints string       `json:"constraints"` // The constraints, comma-separated
+	NumAllowed  int          `json:"num_allowed"` // Legal moves that satisfy them
+	Best        MoveResponse `json:"best"`        // Best move without the constraints
+	Cost        float64      `json:"cost"`        // Equity the best allowed move gives up against best
 }
 
 // CubeResponse is the response for cube decisions.
 type CubeResponse struct {
-	Action         string  `json:"action"`           // "no_double", "double_take", "double_pass", "too_good"
+	Action         string  `json:"action"`           // "no_double", "double_take", "double_pass", "too_good_take", "too_good_pass", "redouble_take", "redouble_pass", "optional_double_take", "not_available" or "dead_cube"
+	DecisionType   string  `json:"decision_type"`    // Detailed decision, e.g. "too_good_redouble_pass" or "optional_double_beaver"
+	Verdict        string  `json:"verdict"`          // The decision as a sentence, e.g. "Too good to double, pass: playing on for a gammon is worth more than cashing the game."
 	DoubleEquity   float64 `json:"double_equity"`    // Equity if doubled
+	PassEquity     float64 `json:"pass_equity"`      // Equity if doubled and passed
 	NoDoubleEquity float64 `json:"no_double_equity"` // Equity if not doubled
-	TakeEquity     float64 `json:"take_equity"`      // Opponent's equity if they take
+	TakeEquity     float64 `json:"take_equity"`      // Opponent's equity if they take (opponent_view.take_equity)
 	DoubleDiff     float64 `json:"double_diff"`      // Difference (double - no double)
+	Decision       string  `json:"decision"`         // Verbal decision (e.g., "Double, Take")
+	TakePoint      float64 `json:"take_point"`       // Opponent's winning chances needed to take, as percentage
+	CashPoint      float64 `json:"cash_point"`       // Winning chances at which the opponent should pass, as percentage
+
+	DoublePoint     float64              `json:"double_point"`      // Winning chances from which doubling gains, as percentage
+	RecubeTakePoint float64              `json:"recube_take_point"` // Winning chances needed to take the opponent's redouble after a take, as percentage
+	Points          []CubePointsResponse `json:"points,omitempty"`  // Take and cash points of a double from cube values 1, 2 and 4
+
+	CubeEfficiency float64 `json:"cube_efficiency,omitempty"` // Cube efficiency x of Janowski's formula behind the equities (money games only)
+	Source         string  `json:"source,omitempty"`          // Where the equities come from: "janowski", "met" or "exact" (the cubeful two-sided bearoff database)
+
+	OpponentView *OpponentViewResponse `json:"opponent_view,omitempty"` // The double from the side of the opponent, who takes or passes it
+	MatchContext *MatchContextResponse `json:"match_context,omitempty"` // Match winning chances behind the decision (match play only)
+	Race         *RaceCubeResponse     `json:"race,omitempty"`          // Where a race stands against the double and take points (races only)
+	Entry        []EntryResponse       `json:"entry,omitempty"`         // Bar entry of each side with checkers on the bar
+	Opponent     bool                  `json:"opponent,omitempty"`      // The decision is the opponent's, with the opponent on roll
+	Timing       *TimingResponse       `json:"timing_ms,omitempty"`     // Where the engine time went, with debug_timing
+
+	Convention    string           `json:"convention,omitempty"`    // "xg" when equities are at the cube value (see Convention)
+	Probabilities *XGProbabilities `json:"probabilities,omitempty"` // Six cubeless probabilities as percentages, in the xg convention
+
+	eval *engine.Evaluation // Cubeless evaluation behind the decision, for Probabilities
+}
+
+// CubePointsResponse is the take and cash points of a double from one cube
+// value, with the position's gammons counted in.
+type CubePointsResponse struct {
+	Cube      int     `json:"cube"`       // Cube value before the double
+	TakePoint float64 `json:"take_point"` // Opponent's winning chances needed to take, as percentage
+	CashPoint float64 `json:"cash_point"` // Winning chances from which the opponent should pass, as percentage
+	Margin    float64 `json:"margin"`     // Winning chances less cash_point, in percent: the opponent has a take while it is negative
+}
+
+// OpponentViewResponse is a double seen from the side of the opponent. Its
+// equities are the opponent's, per unit of the cube before the double like
+// the doubler's; chances are the opponent's cubeless ones, as percentages.
+type OpponentViewResponse struct {
+	TakeEquity     float64 `json:"take_equity"`        // Cubeful equity after taking, owning the doubled cube
+	PassEquity     float64 `json:"pass_equity"`        // Equity after passing
+	PassCost       float64 `json:"pass_cost"`          // take_equity - pass_equity: what passing gives up, negative when passing is right
+	Win            float64 `json:"win"`                // Winning chances
+	TakePoint      float64 `json:"take_point"`         // Winning chances needed to take
+	Margin         float64 `json:"margin"`             // win - take_point: the chances are enough to take while it is not negative
+	GammonRisk     float64 `json:"gammon_risk"`        // Chances of losing a gammon or backgammon on the doubled cube
+	BackgammonRisk float64 `json:"backgammon_risk"`    // Chances of losing a backgammon on the doubled cube
+	TakeMWC        float64 `json:"take_mwc,omitempty"` // Match winning chance after taking (match play only)
+	PassMWC        float64 `json:"pass_mwc,omitempty"` // Match winning chance after passing (match play only)
+}
+
+// ActionResponse is the best action of the player on roll. Its parts have
+// the same shape as the /api/cube and /api/move responses.
+type ActionResponse struct {
+	Action       string         `json:"action"`                  // "double" or "roll" before the roll, "move" after it
+	MissedDouble bool           `json:"missed_double,omitempty"` // After the roll: the player should have doubled before rolling
+	Cube         *CubeResponse  `json:"cube"`                    // Cube decision before the roll
+	Moves        *MovesResponse `json:"moves,omitempty"`         // Ranked moves, when dice are given
+}
+
+// EntryResponse counts the rolls that enter the checkers one side has on the
+// bar.
+type EntryResponse struct {
+	Opponent     bool    `json:"opponent"`      // The side on the bar is the opponent of the player on roll
+	OnBar        int     `json:"on_bar"`        // Checkers on the bar
+	PointsMade   int     `json:"points_made"`   // Home board points made against them
+	EnterRolls   int     `json:"enter_rolls"`   // Rolls of 36 that enter at least one checker
+	FullRolls    int     `json:"full_rolls"`    // Rolls that enter every checker on the bar
+	PartialRolls int     `json:"partial_rolls"` // Rolls that enter some but not all of them
+	DanceRolls   int     `json:"dance_rolls"`   // Rolls that enter none
+	Dance        float64 `json:"dance"`         // Chance of dancing, as percentage
+	Summary      string  `json:"summary"`       // The entry as a sentence, e.g. "The opponent enters with only 20/36 rolls against 4 home board points and dances 44.4% of the time."
+}
+
+// RaceCubeResponse places a race on the race cube scale. Winning chances are
+// percentages for the player on roll.
+type RaceCubeResponse struct {
+	Pips         [2]int                `json:"pips"`           // Pip counts of the player on roll and the opponent
+	Lead         int                   `json:"lead"`           // Opponent's pip count less the player's
+	LeadPct      float64               `json:"lead_pct"`       // Lead as a percentage of the player's pip count
+	Win          float64               `json:"win"`            // Cubeless winning chance
+	Source       string                `json:"source"`         // What the winning chance comes from: "bearoff", "nn" or "heuristic"
+	Verdict      string                `json:"verdict"`        // "no_double", "double_take", "double_pass", "too_good" or "not_available"
+	DoublePoint  float64               `json:"double_point"`   // Least winning chance to double
+	TakePoint    float64               `json:"take_point"`     // Greatest winning chance at which the opponent can still take
+	PipsToDouble float64               `json:"pips_to_double"` // Pips the lead has to grow by to double (negative: pips to spare)
+	PipsToPass   float64               `json:"pips_to_pass"`   // Pips the lead has to grow by before the opponent should pass (negative: pips past the last take)
+	Summary      string                `json:"summary"`        // The above in a sentence or two
+	Table        []RaceCubeRowResponse `json:"table"`          // Winning chances and cube equities around the current lead
+}
+
+// RaceCubeRowResponse is a line of the equity table of a race.
+type RaceCubeRowResponse struct {
+	Lead       int     `json:"lead"`        // Lead in pips
+	Win        float64 `json:"win"`         // Winning chance
+	NoDouble   float64 `json:"no_double"`   // Cubeful equity of not doubling
+	DoubleTake float64 `json:"double_take"` // Cubeful equity of double/take
+	Verdict    string  `json:"verdict"`     // As in RaceCubeResponse
+}
+
+// MatchContextResponse shows the match winning chances behind a cube decision
+// at a match score. All values are percentages for the player on roll unless
+// named otherwise.
+type MatchContextResponse struct {
+	MWC            float64                `json:"mwc"`              // Match winning chance before this game
+	Outcomes       []MatchOutcomeResponse `json:"outcomes"`         // Single, gammon and backgammon results at the current and doubled cube
+	WinMWC         float64                `json:"win_mwc"`          // Gammon-weighted MWC after a win at the current cube
+	LoseMWC        float64                `json:"lose_mwc"`         // Gammon-weighted MWC after a loss at the current cube
+	WinMWCDoubled  float64                `json:"win_mwc_doubled"`  // Gammon-weighted MWC after a win at the doubled cube
+	LoseMWCDoubled float64                `json:"lose_mwc_doubled"` // Gammon-weighted MWC after a loss at the doubled cube
+	DoubleRisk     float64                `json:"double_risk"`      // MWC the doubler risks by doubling
+	DoubleGain     float64                `json:"double_gain"`      // MWC the doubler gains by doubling
+	TakeRisk       float64                `json:"take_risk"`        // Opponent's MWC risked by taking rather than passing
+	TakeGain       float64                `json:"take_gain"`        // Opponent's MWC gained by taking rather than passing
+	TakePoint      float64                `json:"take_point"`       // Opponent's winning chances needed to take, from the risk and gain
+}
+
+// METResponse is a match equity table as percentages for the player whose
+// points away index the rows.
+type METResponse struct {
+	Name         string            `json:"name"`          // Table name
+	Length       int               `json:"length"`        // Largest number of points away in the grids
+	PreCrawford  [][]float64       `json:"pre_crawford"`  // [i][j]: MWC i+1-away against j+1-away
+	PostCrawford [][]float64       `json:"post_crawford"` // [p][i]: MWC of player p i+1-away against 1-away
+	Score        *METScoreResponse `json:"score,omitempty"`
+}
+
+// METScoreResponse is the match equity at one score and the cube points it
+// implies for an initial double without gammons. Pairs are indexed by the
+// player away[0] points away first.
+type METScoreResponse struct {
+	Away            [2]int     `json:"away"`             // Points each player needs
+	Crawford        bool       `json:"crawford"`         // The game is the Crawford game
+	MWC             float64    `json:"mwc"`              // First player's MWC, as percentage
+	GammonValue     [2]float64 `json:"gammon_value"`     // MWC a gammon adds to a single win, as a share of a single game's
+	BackgammonValue [2]float64 `json:"backgammon_value"` // MWC a backgammon adds to a gammon, as a share of a single game's
+	CanDouble       [2]bool    `json:"can_double"`       // Whether the player's cube is alive
+	TakePoint       [2]float64 `json:"take_point"`       // Opponent's winning chances needed to take the player's double, as percentage
+	DoublePoint     [2]float64 `json:"double_point"`     // Player's winning chances from which doubling gains, as percentage
+}
+
+// MatchOutcomeResponse is the match winning chance after one game result.
+type MatchOutcomeResponse struct {
+	Cube   int     `json:"cube"`    // Cube value the game is played for
+	Points int     `json:"points"`  // Points won by the player on roll (negative when lost)
+	MWC    float64 `json:"mwc"`     // Player on roll's MWC afterwards, as percentage
+	OppMWC float64 `json:"opp_mwc"` // Opponent's MWC afterwards, as percentage
 }
 
 // RolloutResponse is the response for rollouts.
 type RolloutResponse struct {
+	Equity      float64 `json:"equity"`              // Mean equity
+	StdDev      float64 `json:"std_dev"`             // Standard deviation
+	CI95        float64 `json:"ci_95"`               // 95% confidence interval (+/-)
+	Win         float64 `json:"win"`                 // P(win) as percentage
+	WinG        float64 `json:"win_g"`               // P(win gammon) as percentage
+	WinBG       float64 `json:"win_bg"`              // P(win backgammon) as percentage
+	LoseG       float64 `json:"lose_g"`              // P(lose gammon) as percentage
+	LoseBG      float64 `json:"lose_bg"`             // P(lose backgammon) as percentage
+	Trials      int     `json:"trials"`              // Number of trials completed
+	Truncated   bool    `json:"truncated"`           // Whether games were truncated
+	TruncatePly int     `json:"truncate_ply"`        // Ply at which truncation occurred
+	Cached      bool    `json:"cached"`              // Whether the result came from the rollout store without playing trials
+	StoredAt    string  `json:"stored_at,omitempty"` // When the stored rollout was first made (RFC 3339), if a store is configured
+	Clamped     bool    `json:"clamped,omitempty"`   // The requested trials were beyond the server's maximum and were lowered to it
+
+	TrialsPerSecond float64 `json:"trials_per_second,omitempty"` // Trials played per second (not for cached results)
+	AvgPlies        float64 `json:"avg_plies,omitempty"`         // Average plies per trial played (not for cached results)
+
+	Seed     int64                   `json:"seed"`               // Seed the trials were played with, drawn at random for seed 0
+	DiceMode string                  `json:"dice_mode"`          // How the dice were drawn: "exhaustive-2roll" or "random"
+	Manifest *engine.RolloutManifest `json:"manifest,omitempty"` // What is needed to play the trials again (not for rollouts stored without one)
+
+	Engine *engine.BuildInfo `json:"engine,omitempty"` // Engine build and data that answered; a stored result's manifest names the one that played it
+}
+
+// CubeRolloutResponse is the response for cube rollouts: the cube decision
+// worked out from a rollout of the position.
+type CubeRolloutResponse struct {
+	Cube              *CubeResponse   `json:"cube"`               // Cube decision from the rolled-out probabilities
+	Rollout           RolloutResponse `json:"rollout"`            // Cubeless rollout the decision is worked out from
+	NoDoubleCI        float64         `json:"no_double_ci"`       // 95% confidence interval (+/-) of the no double equity
+	DoubleTakeCI      float64         `json:"double_take_ci"`     // 95% confidence interval (+/-) of the double/take equity
+	DoubleDiffCI      float64         `json:"double_diff_ci"`     // 95% confidence interval (+/-) of double/take minus no double
+	DoubleSignificant bool            `json:"double_significant"` // Whether double/take and no double differ by more than double_diff_ci
+	TakeSignificant   bool            `json:"take_significant"`   // Whether double/take and double/pass differ by more than double_take_ci
+
+	Engine *engine.BuildInfo `json:"engine,omitempty"` // Engine build and data that answered
+}
+
+// StoredRolloutResponse describes a rollout kept in the server's rollout store.
+type StoredRolloutResponse struct {
+	ID          string  `json:"id"`           // Identifier for DELETE /api/admin/rollouts/{id}
+	Position    string  `json:"position"`     // Position ID
+	Cube        string  `json:"cube"`         // Side on roll, cube and match state
+	Trials      int     `json:"trials"`       // Number of trials stored
 	Equity      float64 `json:"equity"`       // Mean equity
-	StdDev      float64 `json:"std_dev"`      // Standard deviation
 	CI95        float64 `json:"ci_95"`        // 95% confidence interval (+/-)
-	Win         float64 `json:"win"`          // P(win) as percentage
-	WinG        float64 `json:"win_g"`        // P(win gammon) as percentage
-	WinBG       float64 `json:"win_bg"`       // P(win backgammon) as percentage
-	LoseG       float64 `json:"lose_g"`       // P(lose gammon) as percentage
-	LoseBG      float64 `json:"lose_bg"`      // P(lose backgammon) as percentage
-	Trials      int     `json:"trials"`       // Number of trials completed
-	Truncated   bool    `json:"truncated"`    // Whether games were truncated
-	TruncatePly int     `json:"truncate_ply"` // Ply at which truncation occurred
+	TruncatePly int     `json:"truncate_ply"` // Ply at which games were truncated (0 = played out)
+	Created     string  `json:"created"`      // When the rollout was first stored (RFC 3339)
+	Updated     string  `json:"updated"`      // When the rollout was last extended (RFC 3339)
+}
+
+// StoredRolloutsResponse is the response for GET /api/admin/rollouts.
+type StoredRolloutsResponse struct {
+	Rollouts []StoredRolloutResponse `json:"rollouts"` // Most recently updated first
+}
+
+// InspectRequest is the request body for POST /api/inspect. GET takes the
+// same fields as query parameters.
+type InspectRequest struct {
+	Position string `json:"position"`          // Position ID (gnubg format)
+	Verbose  bool   `json:"verbose,omitempty"` // Include the net inputs
+}
+
+// InspectResponse shows how the engine evaluates a position, for comparing
+// with other engines.
+type InspectResponse struct {
+	Position  string          `json:"position"`           // Position ID
+	Class     string          `json:"class"`              // Position class ("contact", "crashed", "race", "bearoff1", ...)
+	Evaluator string          `json:"evaluator"`          // What produced the output ("contact_net", "bearoff_two_sided", "exact", "heuristic", ...)
+	Network   string          `json:"network,omitempty"`  // Net the position is given to ("contact", "crashed", "race")
+	Output    [5]float32      `json:"output"`             // Raw output: win, win gammon, win backgammon, lose gammon, lose backgammon
+	Inputs    []InputResponse `json:"inputs,omitempty"`   // Net inputs, with verbose
+	Disabled  []string        `json:"disabled,omitempty"` // Evaluators the engine leaves out ("crashed_net", "bearoff_db")
+}
+
+// InputResponse is one named neural net input.
+type InputResponse struct {
+	Name  string  `json:"name"`  // Feature name, e.g. "player.break_contact"
+	Value float32 `json:"value"` // Input value
 }
 
 // ErrorResponse is returned when an error occurs.
 type ErrorResponse struct {
-	Error   string `json:"error"`             // Error message
-	Code    string `json:"code,omitempty"`    // Error code
-	Details string `json:"details,omitempty"` // Additional details
+	Error          string   `json:"error"`                     // Error message
+	Code           string   `json:"code,omitempty"`            // Error code
+	Details        string   `json:"details,omitempty"`         // Additional details
+	LegalPositions []string `json:"legal_positions,omitempty"` // Reachable position IDs, opponent on roll (ILLEGAL_MOVE)
+	ErrorID        string   `json:"error_id,omitempty"`        // Identifies the server log entry (INTERNAL_ERROR)
+	AvailableMETs  []string `json:"available_mets,omitempty"`  // Match equity tables a request can select (INVALID_MET)
 }
 
 // FIBSBoardResponse is the response for FIBS board analysis.
@@ -189,12 +595,15 @@ type FIBSBoardResponse struct {
 	LoseG  float64 `json:"lose_g"`  // P(lose gammon) as percentage
 	LoseBG float64 `json:"lose_bg"` // P(lose backgammon) as percentage
 
+	CubefulEquity float64 `json:"cubeful_equity"` // Cubeful equity with the cube as it is
+	MWC           float64 `json:"mwc,omitempty"`  // Match winning chance as percentage (match play only)
+
 	// Best moves (if dice are rolled)
 	Moves    []MoveResponse `json:"moves,omitempty"`     // Ranked moves (best first)
 	NumLegal int            `json:"num_legal,omitempty"` // Total number of legal moves
 
 	// Cube decision (if it's your turn and you can double)
-	CubeAction     string  `json:"cube_action,omitempty"`      // "no_double", "double_take", "double_pass"
+	CubeAction     string  `json:"cube_action,omitempty"`      // Cube action as in CubeResponse
 	DoubleEquity   float64 `json:"double_equity,omitempty"`    // Equity if doubled
 	NoDoubleEquity float64 `json:"no_double_equity,omitempty"` // Equity if not doubled
 
@@ -206,8 +615,34 @@ type FIBSBoardResponse struct {
 type HealthResponse struct {
 	Status  string     `json:"status"`         // "ok" or "error"
 	Version string     `json:"version"`        // Engine version
-	Ready   bool       `json:"ready"`          // Whether engine is fully loaded
+	Ready   bool       `json:"ready"`          // Whether engine is loaded and warmed up
 	Pool    *PoolStats `json:"pool,omitempty"` // Worker pool statistics
+	MET     *METInfo   `json:"met,omitempty"`  // Active match equity table
+	// Evaluators the engine leaves out for comparisons ("crashed_net", "bearoff_db")
+	Disabled []string `json:"disabled,omitempty"`
+	Kernel   string   `json:"kernel,omitempty"` // Kernel the nets run on: "avx2" or "go" (pure Go)
+	CPU      []string `json:"cpu,omitempty"`    // Vector extensions of the CPU ("avx2", "fma", "neon")
+	// Data files the engine loaded, with their SHA-256 checksums
+	Data []engine.DataFile `json:"data,omitempty"`
+	// Engine build and data files, as analyses and rollouts record them
+	Engine *engine.BuildInfo `json:"engine,omitempty"`
+}
+
+// SelfCheckResponse is the response for GET /api/health/selfcheck.
+type SelfCheckResponse struct {
+	Status     string  `json:"status"`      // "pass" or "fail"
+	CheckedAt  string  `json:"checked_at"`  // When the check ran (RFC 3339)
+	DurationMs float64 `json:"duration_ms"` // How long it took
+	// Each sentinel with the values it observed and the ranges they must
+	// fall in; a sentinel is "skipped" when the data it checks is not loaded
+	Sentinels []engine.SelfCheckSentinel `json:"sentinels"`
+}
+
+// METInfo identifies a match equity table.
+type METInfo struct {
+	Name   string   `json:"name"`   // Table name
+	Length int      `json:"length"` // Native match length of the table
+	Tables []string `json:"tables"` // Tables a request can select with "met"
 }
 
 // TutorMoveResponse is the response for move skill analysis.
@@ -215,6 +650,7 @@ type TutorMoveResponse struct {
 	Skill        string         `json:"skill"`         // "none", "doubtful", "bad", "very_bad"
 	SkillAbbr    string         `json:"skill_abbr"`    // "", "?!", "?", "??"
 	EquityLoss   float64        `json:"equity_loss"`   // Equity lost by this move
+	PlayedMove   string         `json:"played_move"`   // Canonical notation of the played move
 	BestMove     string         `json:"best_move"`     // Best move notation
 	BestEquity   float64        `json:"best_equity"`   // Equity of best move
 	PlayedEquity float64        `json:"played_equity"` // Equity of played move
@@ -228,7 +664,7 @@ type TutorCubeResponse struct {
 	Skill      string  `json:"skill"`       // "none", "doubtful", "bad", "very_bad"
 	SkillAbbr  string  `json:"skill_abbr"`  // "", "?!", "?", "??"
 	EquityLoss float64 `json:"equity_loss"` // Equity lost by this decision
-	Optimal    string  `json:"optimal"`     // Optimal action
+	Optimal    string  `json:"optimal"`     // Optimal action, as Played or "too_good" (play on rather than double)
 	Played     string  `json:"played"`      // Played action
 	IsClose    bool    `json:"is_close"`    // True if decision was close
 	Suggestion string  `json:"suggestion"`  // Improvement suggestion
@@ -240,8 +676,23 @@ type GameAnalysisResponse struct {
 	TotalMoves  int            `json:"total_moves"` // Total moves analyzed
 	MoveErrors  []MoveError    `json:"move_errors"` // All errors found
 	CubeErrors  []CubeError    `json:"cube_errors"` // All cube errors
-	LuckStats   [2]float64     `json:"luck_stats"`  // Luck for each player
+	LuckStats   [2]float64     `json:"luck_stats"`  // Luck for each player: the equity their rolls gained over the average roll
+	Luckiest    []LuckRoll     `json:"luckiest"`    // Luckiest rolls, luckiest first (at most 3)
+	Unluckiest  []LuckRoll     `json:"unluckiest"`  // Unluckiest rolls, unluckiest first (at most 3)
 	Suggestions []string       `json:"suggestions"` // Overall improvement suggestions
+
+	Engine *engine.BuildInfo `json:"engine,omitempty"` // Engine build and data the game was analyzed with
+}
+
+// LuckRoll is the luck of one roll of a game: how much the dice gained the
+// player who rolled them over the average roll.
+type LuckRoll struct {
+	MoveNumber int     `json:"move_number"` // 1-indexed move number
+	Player     int     `json:"player"`      // Player who rolled, 0 or 1
+	Position   string  `json:"position"`    // Position ID before the roll
+	Dice       [2]int  `json:"dice"`        // Dice rolled
+	Luck       float64 `json:"luck"`        // Cubeless equity swing for the player
+	Points     float64 `json:"points"`      // The swing in points at the cube value
 }
 
 // PlayerStats contains analysis stats for one player.
@@ -255,6 +706,13 @@ type PlayerStats struct {
 	Errors             int     `json:"errors"`         // Bad moves
 	Doubtful           int     `json:"doubtful"`       // Doubtful moves
 	LuckAdjusted       float64 `json:"luck_adjusted"`  // Luck-adjusted error rate
+
+	// Weakness tabulates the player's errors by kind of position, game phase
+	// and decision, costliest first
+	Weakness engine.WeaknessProfile `json:"weakness"`
+
+	// Difficulty of the unforced moves and the EPM weighted by it
+	engine.DifficultyStats
 }
 
 // MoveError represents a single move error in a game.
@@ -267,6 +725,9 @@ type MoveError struct {
 	Best       string  `json:"best"`        // Best move
 	EquityLoss float64 `json:"equity_loss"` // Equity lost
 	Skill      string  `json:"skill"`       // Skill rating
+	Category   string  `json:"category"`    // Kind of position for the mover, e.g. "holding" or "blitz"
+	Phase      string  `json:"phase"`       // Phase of the game: opening, middle, bearin or bearoff
+	Spread     float64 `json:"spread"`      // Equity spread of the top candidates: the smaller, the harder the decision
 }
 
 // CubeError represents a single cube error in a game.
@@ -275,9 +736,29 @@ type CubeError struct {
 	Player     int     `json:"player"`      // 0 or 1
 	Position   string  `json:"position"`    // Position ID
 	Played     string  `json:"played"`      // Action taken
-	Optimal    string  `json:"optimal"`     // Correct action
+	Optimal    string  `json:"optimal"`     // Correct action, as Played or "too_good" (play on rather than double)
 	EquityLoss float64 `json:"equity_loss"` // Equity lost
 	Skill      string  `json:"skill"`       // Skill rating
+	Category   string  `json:"category"`    // Kind of position for the player deciding
+	Phase      string  `json:"phase"`       // Phase of the game for the player deciding
+}
+
+// TimingResponse breaks down the engine time of a request, in milliseconds.
+// The components are spent inside total; other is the rest of it.
+type TimingResponse struct {
+	Total       float64 `json:"total"`       // Wall time of the engine call
+	MoveGen     float64 `json:"move_gen"`    // Generating legal moves
+	Inputs      float64 `json:"inputs"`      // Encoding positions as net inputs
+	Contact     float64 `json:"contact"`     // Contact net forward passes
+	Crashed     float64 `json:"crashed"`     // Crashed net forward passes
+	Race        float64 `json:"race"`        // Race net forward passes
+	Prune       float64 `json:"prune"`       // Pruning net forward passes
+	Heuristic   float64 `json:"heuristic"`   // Pip count formulas standing in for a net that is not loaded
+	Bearoff     float64 `json:"bearoff"`     // Bearoff database lookups and exact bearoff solves
+	Cache       float64 `json:"cache"`       // Evaluation cache lookups and stores
+	Other       float64 `json:"other"`       // Applying moves, classifying positions and the like
+	Evaluations int     `json:"evaluations"` // Positions evaluated statically (not counting cache hits)
+	BookHits    int     `json:"book_hits"`   // Positions answered from the engine's book
 }
 
 // ============================================================================
@@ -295,5 +776,45 @@ func EvalToResponse(eval *engine.Evaluation, ply int, cubeful bool) *EvaluateRes
 		LoseBG:  eval.LoseBG * 100,
 		Ply:     ply,
 		Cubeful: cubeful,
+		Source:  eval.Source,
+	}
+}
+
+// FullEvalToResponse converts an engine EvaluationFull to an API response.
+func FullEvalToResponse(full *engine.EvaluationFull, ply int) *EvaluateResponse {
+	resp := EvalToResponse(&full.Evaluation, ply, false)
+	resp.CubefulEquity = full.CubefulEquity
+	resp.MWC = full.MWC * 100
+	return resp
+}
+
+// TimingToResponse converts an engine Timing to an API response, nil if t is nil.
+func TimingToResponse(t *engine.Timing) *TimingResponse {
+	if t == nil {
+		return nil
+	}
+	ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+	return &TimingResponse{
+		Total:       ms(t.Total),
+		MoveGen:     ms(t.MoveGen),
+		Inputs:      ms(t.Inputs),
+		Contact:     ms(t.Contact),
+		Crashed:     ms(t.Crashed),
+		Race:        ms(t.Race),
+		Prune:       ms(t.Prune),
+		Heuristic:   ms(t.Heuristic),
+		Bearoff:     ms(t.Bearoff),
+		Cache:       ms(t.Cache),
+		Other:       ms(t.Other()),
+		Evaluations: t.Evaluations,
+		BookHits:    t.BookHits,
+	}
+}
+
+// newTiming returns a Timing for a request that asks for debug timing, nil otherwise.
+func newTiming(debug bool) *engine.Timing {
+	if !debug {
+		return nil
 	}
+	return &engine.Timing{}
 }
@@ -1,19 +1,24 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
+	"runtime/debug"
+	"strings"
 	"sync"
 
 	"github.com/gorilla/websocket"
-	"github.com/yourusername/bgengine/internal/positionid"
 	"github.com/yourusername/bgengine/pkg/engine"
+	"github.com/yourusername/bgengine/pkg/wire"
 )
 
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
+	Subprotocols:    []string{wire.Subprotocol},
 	CheckOrigin: func(r *http.Request) bool {
 		return true // Allow all origins - configure properly in production
 	},
@@ -21,25 +26,48 @@ var upgrader = websocket.Upgrader{
 
 // WSMessage is a generic WebSocket message.
 type WSMessage struct {
-	Type    string          `json:"type"`    // Message type: "evaluate", "move", "cube", "ping"
+	Type    string          `json:"type"`    // Message type: "evaluate", "move", "cube", "rollout", "hint_submoves", "subscribe", "unsubscribe", "broadcast_update", "register_bot", "unregister_bot", "bot_reply", "game_start", "game_state", "game_action", "cancel", "ping"
 	ID      string          `json:"id"`      // Request ID for correlating responses
 	Payload json.RawMessage `json:"payload"` // Type-specific payload
 }
 
 // WSResponse is a generic WebSocket response.
 type WSResponse struct {
-	Type    string      `json:"type"`              // Response type: "result", "error", "pong"
+	Type    string      `json:"type"`              // Response type: "result", "progress", "broadcast", "bot_request", "cancelled", "error", "pong"
 	ID      string      `json:"id,omitempty"`      // Request ID
 	Payload interface{} `json:"payload,omitempty"` // Response data
 	Error   string      `json:"error,omitempty"`   // Error message if any
+	Code    string      `json:"code,omitempty"`    // Error code, e.g. "TOO_MANY_IN_FLIGHT" or "SERVER_BUSY"
+
+	frame []byte // A binary frame to send instead (see wsbinary.go)
 }
 
-// WSClient represents a connected WebSocket client.
+// WSClient represents a connected WebSocket client. Analysis requests are
+// handled in their own goroutines, up to Limits.MaxWSInFlight at a time, and
+// the write pump is the only writer to the connection.
 type WSClient struct {
 	conn     *websocket.Conn
 	handlers *Handlers
 	sendChan chan WSResponse
 	mu       sync.Mutex
+
+	binary   bool            // The client negotiated wire.Subprotocol
+	ctx      context.Context // Cancelled when the connection closes
+	done     <-chan struct{} // ctx.Done(); nil for a client without a connection
+	inFlight chan struct{}   // Slots of the messages being handled
+	identity string          // Client identity slow operations are scheduled under
+
+	// Requests being handled, which cancel messages can stop. opsMu also
+	// keeps a request from answering after its cancel has been answered.
+	opsMu sync.Mutex
+	ops   map[*wsOp]struct{}
+
+	// Broadcast frames waiting to be sent, the latest per channel (guarded by mu)
+	pending map[string]*BroadcastFrame
+	dropped int           // Frames replaced before they were sent
+	wake    chan struct{} // Signals the write pump that frames are pending
+
+	subscriptions map[string]struct{} // Joined channels (guarded by the hub's mutex)
 }
 
 // WebSocket handles WebSocket connections for real-time game analysis.
@@ -49,152 +77,591 @@ func (h *Handlers) WebSocket(w http.ResponseWriter, r *http.Request) {
 		log.Printf("WebSocket upgrade error: %v", err)
 		return
 	}
-	client := &WSClient{conn: conn, handlers: h, sendChan: make(chan WSResponse, 256)}
+	conn.SetReadLimit(h.limits.bodyLimit(r.URL.Path))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client := &WSClient{
+		conn:     conn,
+		handlers: h,
+		sendChan: make(chan WSResponse, 256),
+		wake:     make(chan struct{}, 1),
+		binary:   conn.Subprotocol() == wire.Subprotocol,
+		ctx:      ctx,
+		done:     ctx.Done(),
+		inFlight: make(chan struct{}, h.limits.MaxWSInFlight),
+		identity: h.identity(r),
+		ops:      make(map[*wsOp]struct{}),
+	}
 	go client.writePump()
 	client.readPump()
 }
 
 func (c *WSClient) writePump() {
 	defer c.conn.Close()
-	for msg := range c.sendChan {
-		if err := c.conn.WriteJSON(msg); err != nil {
+	for {
+		select {
+		case <-c.done:
 			return
+		case msg, ok := <-c.sendChan:
+			if !ok {
+				return
+			}
+			var err error
+			if msg.frame != nil {
+				err = c.conn.WriteMessage(websocket.BinaryMessage, msg.frame)
+			} else {
+				err = c.conn.WriteJSON(msg)
+			}
+			if err != nil {
+				return
+			}
+		case <-c.wake:
+			for _, frame := range c.takePending() {
+				if err := c.conn.WriteJSON(WSResponse{Type: "broadcast", Payload: frame}); err != nil {
+					return
+				}
+			}
 		}
 	}
 }
 
+// readPump reads messages until the connection closes. Handlers still
+// running then drop their responses (see send). Binary messages are frames
+// of package wire.
 func (c *WSClient) readPump() {
-	defer func() { close(c.sendChan); c.conn.Close() }()
+	defer func() {
+		c.handlers.broadcasts.unsubscribeAll(c)
+		c.handlers.bots.unregisterAll(c)
+		c.conn.Close()
+	}()
+	var frame []byte
 	for {
+		kind, r, err := c.conn.NextReader()
+		if err != nil {
+			return
+		}
+		if kind == websocket.BinaryMessage {
+			if frame, err = readFrame(r, frame[:0]); err != nil {
+				return
+			}
+			c.dispatchBinary(frame)
+			continue
+		}
 		var msg WSMessage
-		if err := c.conn.ReadJSON(&msg); err != nil {
+		if err := json.NewDecoder(r).Decode(&msg); err != nil {
 			return
 		}
-		c.handleMessage(msg)
+		c.dispatch(msg)
+	}
+}
+
+// dispatch handles an analysis request in its own goroutine, so that a slow
+// request does not hold up the messages after it; responses carry msg.ID and
+// may arrive out of order. A request beyond the connection's in-flight limit
+// is refused with TOO_MANY_IN_FLIGHT. Pings, cancels and broadcast messages
+// are quick and handled at once, in the order they arrive.
+func (c *WSClient) dispatch(msg WSMessage) {
+	switch msg.Type {
+	case "ping", "subscribe", "unsubscribe", "broadcast_update", "register_bot", "unregister_bot", "bot_reply",
+		"game_start", "game_state", "game_action":
+		c.handleMessageSafely(c.ctx, msg)
+		return
+	case "cancel":
+		c.handleCancel(msg)
+		return
+	}
+	if !c.spawn(msg.ID, func(ctx context.Context) { c.handleMessageSafely(ctx, msg) }) {
+		c.send(WSResponse{Type: "error", ID: msg.ID, Error: c.tooManyInFlight(), Code: "TOO_MANY_IN_FLIGHT"})
+	}
+}
+
+// spawn runs handle for the request with ID id in its own goroutine, with
+// the context startOp gives it. It returns false, and runs nothing, if the
+// connection already has as many requests in flight as it may.
+func (c *WSClient) spawn(id string, handle func(ctx context.Context)) bool {
+	select {
+	case c.inFlight <- struct{}{}:
+	default:
+		return false
+	}
+	ctx, op := c.startOp(id)
+	go func() {
+		defer func() {
+			c.endOp(op)
+			<-c.inFlight
+		}()
+		handle(ctx)
+	}()
+	return true
+}
+
+// tooManyInFlight is the error message for a request refused by spawn.
+func (c *WSClient) tooManyInFlight() string {
+	return fmt.Sprintf("too many in-flight requests (at most %d per connection)", cap(c.inFlight))
+}
+
+// wsOp is a request being handled on a connection.
+type wsOp struct {
+	id     string
+	cancel context.CancelFunc
+}
+
+// startOp registers a request with ID id as in flight and returns the
+// context to handle it in, cancelled by a cancel message for id or when the
+// connection closes.
+func (c *WSClient) startOp(id string) (context.Context, *wsOp) {
+	ctx, cancel := context.WithCancel(c.ctx)
+	op := &wsOp{id: id, cancel: cancel}
+	c.opsMu.Lock()
+	c.ops[op] = struct{}{}
+	c.opsMu.Unlock()
+	return ctx, op
+}
+
+// endOp unregisters a request once it has been answered.
+func (c *WSClient) endOp(op *wsOp) {
+	c.opsMu.Lock()
+	delete(c.ops, op)
+	c.opsMu.Unlock()
+	op.cancel()
+}
+
+// WSCancelRequest is the payload of a cancel message.
+type WSCancelRequest struct {
+	ID string `json:"id"` // ID of the request to cancel
+}
+
+// WSCancelResponse is the payload of the cancelled frame answering a cancel
+// message.
+type WSCancelResponse struct {
+	ID        string `json:"id"`        // ID of the request to cancel
+	Cancelled bool   `json:"cancelled"` // Whether it was in flight; false if it had been answered or was never sent
+}
+
+// handleCancel cancels the requests in flight with the ID a cancel message
+// names. They send nothing more: the cancelled frame, carrying the cancel
+// message's own ID, is the last word on them. Cancelling a request that is
+// not in flight is not an error; the frame says nothing was cancelled.
+func (c *WSClient) handleCancel(msg WSMessage) {
+	var req WSCancelRequest
+	if err := json.Unmarshal(msg.Payload, &req); err != nil || req.ID == "" {
+		c.send(WSResponse{Type: "error", ID: msg.ID, Error: "invalid payload: the id of the request to cancel is required"})
+		return
+	}
+	c.opsMu.Lock()
+	defer c.opsMu.Unlock()
+	cancelled := false
+	for op := range c.ops {
+		if op.id == req.ID {
+			op.cancel()
+			delete(c.ops, op)
+			cancelled = true
+		}
+	}
+	c.send(WSResponse{Type: "cancelled", ID: msg.ID, Payload: WSCancelResponse{ID: req.ID, Cancelled: cancelled}})
+}
+
+// reply sends a response to a request handled in ctx, unless the request
+// has been cancelled.
+func (c *WSClient) reply(ctx context.Context, resp WSResponse) {
+	c.opsMu.Lock()
+	defer c.opsMu.Unlock()
+	if ctx.Err() == nil {
+		c.send(resp)
+	}
+}
+
+// send queues a response for the write pump, dropping it if the connection
+// has closed.
+func (c *WSClient) send(resp WSResponse) {
+	select {
+	case c.sendChan <- resp:
+	case <-c.done:
+	}
+}
+
+// acquire takes a fast or slow worker slot from the server's pool, if it has
+// one, like the HTTP handlers. It reports SERVER_BUSY and returns false if
+// the request is cancelled or the connection closes while waiting;
+// otherwise the caller must call release.
+func (c *WSClient) acquire(ctx context.Context, msg WSMessage, slow bool) (release func(), ok bool) {
+	release, err := c.acquireWorker(ctx, slow)
+	if err != nil {
+		c.reply(ctx, WSResponse{Type: "error", ID: msg.ID, Error: "server busy", Code: "SERVER_BUSY"})
+		return nil, false
+	}
+	return release, true
+}
+
+// acquireWorker takes a worker slot for acquire, returning the error of a
+// wait that was given up.
+func (c *WSClient) acquireWorker(ctx context.Context, slow bool) (release func(), err error) {
+	pool := c.handlers.pool
+	if pool == nil {
+		return func() {}, nil
+	}
+	acquire, release := pool.AcquireFast, pool.ReleaseFast
+	if slow {
+		acquire = func(ctx context.Context) error { return pool.AcquireSlowAs(ctx, c.identity) }
+		release = func() { pool.ReleaseSlowAs(c.identity) }
+	}
+	if err := acquire(ctx); err != nil {
+		return nil, err
 	}
+	return release, nil
 }
 
-func (c *WSClient) handleMessage(msg WSMessage) {
+// handleMessageSafely runs handleMessage in ctx, turning a panic into an
+// error response so that one bad message does not drop the connection.
+func (c *WSClient) handleMessageSafely(ctx context.Context, msg WSMessage) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			id := newErrorID()
+			log.Printf("panic handling WebSocket %q message [%s]: %v\n%s", msg.Type, id, rec, debug.Stack())
+			c.reply(ctx, WSResponse{Type: "error", ID: msg.ID, Error: "internal error (id " + id + ")"})
+		}
+	}()
+	c.handleMessage(ctx, msg)
+}
+
+func (c *WSClient) handleMessage(ctx context.Context, msg WSMessage) {
 	switch msg.Type {
 	case "evaluate":
-		c.handleEvaluate(msg)
+		c.handleEvaluate(ctx, msg)
 	case "move":
-		c.handleMove(msg)
+		c.handleMove(ctx, msg)
 	case "cube":
-		c.handleCube(msg)
+		c.handleCube(ctx, msg)
 	case "rollout":
-		c.handleRollout(msg)
+		c.handleRollout(ctx, msg)
+	case "hint_submoves":
+		c.handleHintSubMoves(ctx, msg)
+	case "subscribe":
+		c.handleSubscribe(ctx, msg)
+	case "unsubscribe":
+		c.handleUnsubscribe(ctx, msg)
+	case "broadcast_update":
+		c.handleBroadcastUpdate(ctx, msg)
+	case "register_bot":
+		c.handleRegisterBot(ctx, msg)
+	case "unregister_bot":
+		c.handleUnregisterBot(ctx, msg)
+	case "bot_reply":
+		c.handleBotReply(ctx, msg)
+	case "game_start":
+		c.handleGameStart(ctx, msg)
+	case "game_state":
+		c.handleGameState(ctx, msg)
+	case "game_action":
+		c.handleGameAction(ctx, msg)
 	case "ping":
-		c.sendChan <- WSResponse{Type: "pong", ID: msg.ID}
+		c.reply(ctx, WSResponse{Type: "pong", ID: msg.ID})
 	default:
-		c.sendChan <- WSResponse{Type: "error", ID: msg.ID, Error: "unknown message type"}
+		c.reply(ctx, WSResponse{Type: "error", ID: msg.ID, Error: "unknown message type"})
 	}
 }
 
-func (c *WSClient) handleEvaluate(msg WSMessage) {
+func (c *WSClient) handleEvaluate(ctx context.Context, msg WSMessage) {
 	var req EvaluateRequest
 	if err := json.Unmarshal(msg.Payload, &req); err != nil {
-		c.sendChan <- WSResponse{Type: "error", ID: msg.ID, Error: "invalid payload"}
+		c.reply(ctx, WSResponse{Type: "error", ID: msg.ID, Error: "invalid payload"})
+		return
+	}
+	conv, err := ParseConvention(req.Convention)
+	if err != nil {
+		c.reply(ctx, WSResponse{Type: "error", ID: msg.ID, Error: err.Error()})
 		return
 	}
-	board, err := positionid.BoardFromPositionID(req.Position)
+	board, err := parsePositionID(req.Position)
 	if err != nil {
-		c.sendChan <- WSResponse{Type: "error", ID: msg.ID, Error: "invalid position"}
+		c.reply(ctx, WSResponse{Type: "error", ID: msg.ID, Error: "invalid position"})
 		return
 	}
 	gs := &engine.GameState{
 		Board: engine.Board(board), Turn: 0, CubeValue: 1, CubeOwner: -1,
-		MatchLength: req.MatchLength, Score: req.Score, Crawford: req.Crawford,
+		MatchLength: req.MatchLength, Score: req.Score, Crawford: req.Crawford, MET: req.MET,
 	}
-	eval, err := c.handlers.engine.Evaluate(gs)
+	if req.AllowPartial {
+		allowPartial(gs)
+	}
+	ply, clamped, err := c.handlers.depth.ply(req.Ply, c.handlers.depth.evalDefault)
 	if err != nil {
-		c.sendChan <- WSResponse{Type: "error", ID: msg.ID, Error: "evaluation failed"}
+		c.reply(ctx, WSResponse{Type: "error", ID: msg.ID, Error: err.Error()})
+		return
+	}
+	if err := c.handlers.engine.CheckMET(req.MET); err != nil {
+		c.reply(ctx, WSResponse{Type: "error", ID: msg.ID, Error: err.Error()})
 		return
 	}
-	c.sendChan <- WSResponse{Type: "result", ID: msg.ID, Payload: EvalToResponse(eval, req.Ply, false)}
+	if req.Opponent {
+		gs = gs.OpponentOnRoll()
+	}
+	release, ok := c.acquire(ctx, msg, false)
+	if !ok {
+		return
+	}
+	defer release()
+	resp, err := EvaluateAtPly(c.handlers.engine, gs, ply, newTiming(req.DebugTiming))
+	if err != nil {
+		c.reply(ctx, WSResponse{Type: "error", ID: msg.ID, Error: "evaluation failed"})
+		return
+	}
+	resp.Opponent = req.Opponent
+	resp.Clamped = clamped
+	applyConvention(conv, resp, gs.CubeValue)
+	c.reply(ctx, WSResponse{Type: "result", ID: msg.ID, Payload: resp})
 }
 
-func (c *WSClient) handleMove(msg WSMessage) {
+func (c *WSClient) handleMove(ctx context.Context, msg WSMessage) {
 	var req MoveRequest
 	if err := json.Unmarshal(msg.Payload, &req); err != nil {
-		c.sendChan <- WSResponse{Type: "error", ID: msg.ID, Error: "invalid payload"}
+		c.reply(ctx, WSResponse{Type: "error", ID: msg.ID, Error: "invalid payload"})
+		return
+	}
+	conv, err := ParseConvention(req.Convention)
+	if err != nil {
+		c.reply(ctx, WSResponse{Type: "error", ID: msg.ID, Error: err.Error()})
 		return
 	}
 	if req.Dice[0] < 1 || req.Dice[0] > 6 || req.Dice[1] < 1 || req.Dice[1] > 6 {
-		c.sendChan <- WSResponse{Type: "error", ID: msg.ID, Error: "invalid dice"}
+		c.reply(ctx, WSResponse{Type: "error", ID: msg.ID, Error: "invalid dice"})
+		return
+	}
+	if err := exceeds("num_moves", req.NumMoves, c.handlers.limits.MaxNumMoves); err != nil {
+		c.reply(ctx, WSResponse{Type: "error", ID: msg.ID, Error: err.Error()})
+		return
+	}
+	if err := checkTimeLimit(&req, c.handlers.limits); err != nil {
+		c.reply(ctx, WSResponse{Type: "error", ID: msg.ID, Error: err.Error()})
 		return
 	}
-	board, err := positionid.BoardFromPositionID(req.Position)
+	clamped, err := c.handlers.depth.movePly(&req)
 	if err != nil {
-		c.sendChan <- WSResponse{Type: "error", ID: msg.ID, Error: "invalid position"}
+		c.reply(ctx, WSResponse{Type: "error", ID: msg.ID, Error: err.Error()})
+		return
+	}
+	if req.Consensus && c.handlers.depth.maxPly < 1 {
+		c.reply(ctx, WSResponse{Type: "error", ID: msg.ID, Error: "consensus needs 1-ply analysis, beyond this server's maximum ply"})
+		return
+	}
+	constraint, err := engine.ParseMoveConstraint(strings.Join(req.Constraints, ","))
+	if err != nil {
+		c.reply(ctx, WSResponse{Type: "error", ID: msg.ID, Error: err.Error()})
+		return
+	}
+	board, err := parsePositionID(req.Position)
+	if err != nil {
+		c.reply(ctx, WSResponse{Type: "error", ID: msg.ID, Error: "invalid position"})
 		return
 	}
 	gs := &engine.GameState{
 		Board: engine.Board(board), Turn: 0, CubeValue: 1, CubeOwner: -1,
 		Dice: req.Dice, MatchLength: req.MatchLength, Score: req.Score, Crawford: req.Crawford,
 	}
-	analysis, err := c.handlers.engine.AnalyzePosition(gs, req.Dice)
+	if req.AllowPartial {
+		allowPartial(gs)
+	}
+	release, ok := c.acquire(ctx, msg, false)
+	if !ok {
+		return
+	}
+	defer release()
+	timing := newTiming(req.DebugTiming)
+	analysis, err := AnalyzeMoves(c.handlers.engine, gs, &req, timing)
 	if err != nil {
-		c.sendChan <- WSResponse{Type: "error", ID: msg.ID, Error: "analysis failed"}
+		c.reply(ctx, WSResponse{Type: "error", ID: msg.ID, Error: "analysis failed"})
 		return
 	}
+	var constrained *ConstraintResponse
+	if !constraint.IsZero() {
+		if analysis, constrained, err = ConstrainMoves(analysis, gs.Board, constraint); err != nil {
+			c.reply(ctx, WSResponse{Type: "error", ID: msg.ID, Error: err.Error()})
+			return
+		}
+	}
 	numMoves := req.NumMoves
-	if numMoves <= 0 || numMoves > len(analysis.Moves) {
+	if numMoves <= 0 {
 		numMoves = len(analysis.Moves)
 	}
-	moves := make([]MoveResponse, numMoves)
-	for i := 0; i < numMoves; i++ {
-		m := analysis.Moves[i]
-		winProb, winG := 0.0, 0.0
-		if m.Eval != nil {
-			winProb = m.Eval.WinProb * 100
-			winG = m.Eval.WinG * 100
+	resp := MovesToResponse(analysis, gs.Board, req.Position, req.Dice, numMoves)
+	resp.Clamped = clamped
+	resp.Timing = TimingToResponse(timing)
+	resp.Constraint = constrained
+	if req.Consensus {
+		consensus, err := c.handlers.engine.ConsensusAnalysis(gs, req.Dice)
+		if err != nil {
+			c.reply(ctx, WSResponse{Type: "error", ID: msg.ID, Error: "analysis failed"})
+			return
 		}
-		moves[i] = MoveResponse{Move: formatMove(m.Move), Equity: m.Equity, Win: winProb, WinG: winG}
+		resp.Consensus = ConsensusToResponse(consensus)
 	}
-	c.sendChan <- WSResponse{Type: "result", ID: msg.ID, Payload: MovesResponse{Moves: moves, NumLegal: analysis.NumMoves, Dice: req.Dice, Position: req.Position}}
+	applyConvention(conv, resp, req.CubeValue)
+	c.reply(ctx, WSResponse{Type: "result", ID: msg.ID, Payload: resp})
 }
 
-func (c *WSClient) handleCube(msg WSMessage) {
+func (c *WSClient) handleCube(ctx context.Context, msg WSMessage) {
 	var req CubeRequest
 	if err := json.Unmarshal(msg.Payload, &req); err != nil {
-		c.sendChan <- WSResponse{Type: "error", ID: msg.ID, Error: "invalid payload"}
+		c.reply(ctx, WSResponse{Type: "error", ID: msg.ID, Error: "invalid payload"})
 		return
 	}
-	board, err := positionid.BoardFromPositionID(req.Position)
+	conv, err := ParseConvention(req.Convention)
 	if err != nil {
-		c.sendChan <- WSResponse{Type: "error", ID: msg.ID, Error: "invalid position"}
+		c.reply(ctx, WSResponse{Type: "error", ID: msg.ID, Error: err.Error()})
+		return
+	}
+	board, err := parsePositionID(req.Position)
+	if err != nil {
+		c.reply(ctx, WSResponse{Type: "error", ID: msg.ID, Error: "invalid position"})
 		return
 	}
 	cubeValue := req.CubeValue
 	if cubeValue <= 0 {
 		cubeValue = 1
 	}
+	if err := c.handlers.engine.CheckMET(req.MET); err != nil {
+		c.reply(ctx, WSResponse{Type: "error", ID: msg.ID, Error: err.Error()})
+		return
+	}
 	gs := &engine.GameState{
 		Board: engine.Board(board), Turn: 0, CubeValue: cubeValue, CubeOwner: req.CubeOwner,
-		MatchLength: req.MatchLength, Score: req.Score, Crawford: req.Crawford,
+		MatchLength: req.MatchLength, Score: req.Score, Crawford: req.Crawford, MET: req.MET,
+	}
+	if req.AllowPartial {
+		allowPartial(gs)
 	}
-	analysis, err := c.handlers.engine.AnalyzeCube(gs)
+	if req.Opponent {
+		gs = gs.OpponentOnRoll()
+	}
+	release, ok := c.acquire(ctx, msg, false)
+	if !ok {
+		return
+	}
+	defer release()
+	timing := newTiming(req.DebugTiming)
+	analysis, err := c.handlers.engine.AnalyzeCubeWithOptions(gs, engine.EvalOptions{Timing: timing})
 	if err != nil {
-		c.sendChan <- WSResponse{Type: "error", ID: msg.ID, Error: "cube analysis failed"}
+		c.reply(ctx, WSResponse{Type: "error", ID: msg.ID, Error: "cube analysis failed"})
 		return
 	}
-	action := "no_double"
-	switch analysis.Decision.Action {
-	case engine.Double:
-		action = "double_take"
-	case engine.Redouble:
-		action = "redouble_take"
-	case engine.Take:
-		action = "take"
-	case engine.Pass:
-		action = "pass"
+	race, err := RaceCube(c.handlers.engine, gs)
+	if err != nil {
+		c.reply(ctx, WSResponse{Type: "error", ID: msg.ID, Error: "cube analysis failed"})
+		return
 	}
-	c.sendChan <- WSResponse{Type: "result", ID: msg.ID, Payload: CubeResponse{
-		Action: action, DoubleEquity: analysis.Decision.DoubleEquity,
-		NoDoubleEquity: analysis.Decision.NoDoubleEquity, TakeEquity: analysis.Decision.TakeEquity,
-		DoubleDiff: analysis.Decision.DoubleEquity - analysis.Decision.NoDoubleEquity,
-	}}
+	resp := CubeToResponse(analysis)
+	resp.Race = race
+	resp.Entry = EntryToResponse(c.handlers.engine.EntryStats(gs))
+	resp.Opponent = req.Opponent
+	resp.Timing = TimingToResponse(timing)
+	applyConvention(conv, resp, gs.CubeValue)
+	c.reply(ctx, WSResponse{Type: "result", ID: msg.ID, Payload: resp})
+}
+
+// WSSubMove is one checker hop of a move entered a die at a time. Points
+// are numbered 1-24 from the mover's side, with 25 for the bar and 0 for
+// off.
+type WSSubMove struct {
+	From int    `json:"from"`
+	To   int    `json:"to"`
+	Die  int    `json:"die"`            // Die the hop uses; a bear-off may use a larger one than it needs
+	Move string `json:"move,omitempty"` // The hop in move notation, e.g. "13/10" (in responses)
+}
+
+// WSSubMovesRequest is the request payload for hint_submoves: which hops may
+// follow the hops played so far.
+type WSSubMovesRequest struct {
+	Position string      `json:"position"`
+	Dice     [2]int      `json:"dice"`
+	Used     []WSSubMove `json:"used"`                // Hops played so far, in order
+	NumMoves int         `json:"num_moves,omitempty"` // Completions to return, best first (default 5)
+}
+
+// WSSubMovesResponse is the result of hint_submoves.
+type WSSubMovesResponse struct {
+	Next           []WSSubMove    `json:"next"`               // Hops that still lead to a legal move
+	Rejected       []WSSubMove    `json:"rejected,omitempty"` // Hops that can be played but leave no legal move to finish
+	Remaining      []int          `json:"remaining"`          // Dice still to play
+	Completable    bool           `json:"completable"`        // The hops so far start a legal move
+	Complete       bool           `json:"complete"`           // The hops so far are a legal move
+	Completions    []MoveResponse `json:"completions"`        // Best legal moves that start with the hops so far, at 0 ply
+	NumCompletions int            `json:"num_completions"`    // Legal moves that start with the hops so far
+}
+
+func wsSubMoves(hops []engine.PartialMove) []WSSubMove {
+	out := make([]WSSubMove, len(hops))
+	for i, h := range hops {
+		out[i] = WSSubMove{From: h.From + 1, To: h.To + 1, Die: h.Die, Move: h.String()}
+	}
+	return out
+}
+
+// handleHintSubMoves answers which single-checker hops an interactive board
+// may offer next, and the best moves the hops so far can lead to.
+func (c *WSClient) handleHintSubMoves(ctx context.Context, msg WSMessage) {
+	var req WSSubMovesRequest
+	if err := json.Unmarshal(msg.Payload, &req); err != nil {
+		c.reply(ctx, WSResponse{Type: "error", ID: msg.ID, Error: "invalid payload"})
+		return
+	}
+	if req.Dice[0] < 1 || req.Dice[0] > 6 || req.Dice[1] < 1 || req.Dice[1] > 6 {
+		c.reply(ctx, WSResponse{Type: "error", ID: msg.ID, Error: "invalid dice"})
+		return
+	}
+	if err := exceeds("num_moves", req.NumMoves, c.handlers.limits.MaxNumMoves); err != nil {
+		c.reply(ctx, WSResponse{Type: "error", ID: msg.ID, Error: err.Error()})
+		return
+	}
+	board, err := parsePositionID(req.Position)
+	if err != nil {
+		c.reply(ctx, WSResponse{Type: "error", ID: msg.ID, Error: "invalid position"})
+		return
+	}
+	used := make([]engine.PartialMove, len(req.Used))
+	for i, h := range req.Used {
+		used[i] = engine.PartialMove{From: h.From - 1, To: h.To - 1, Die: h.Die}
+	}
+	sub, err := engine.LegalSubMoves(engine.Board(board), req.Dice, used)
+	if err != nil {
+		c.reply(ctx, WSResponse{Type: "error", ID: msg.ID, Error: err.Error(), Code: "ILLEGAL_MOVE"})
+		return
+	}
+	resp := &WSSubMovesResponse{
+		Next:        wsSubMoves(sub.Next),
+		Rejected:    wsSubMoves(sub.Rejected),
+		Remaining:   sub.Remaining,
+		Completable: sub.Completable,
+		Complete:    sub.Complete,
+		Completions: []MoveResponse{},
+	}
+	if resp.Remaining == nil {
+		resp.Remaining = []int{}
+	}
+	if !sub.Completable {
+		c.reply(ctx, WSResponse{Type: "result", ID: msg.ID, Payload: resp})
+		return
+	}
+
+	gs := &engine.GameState{Board: engine.Board(board), Turn: 0, CubeValue: 1, CubeOwner: -1, Dice: req.Dice}
+	release, ok := c.acquire(ctx, msg, false)
+	if !ok {
+		return
+	}
+	defer release()
+	moves, err := c.handlers.engine.CompletePartial(gs, req.Dice, used, engine.EvalOptions{})
+	if err != nil {
+		c.reply(ctx, WSResponse{Type: "error", ID: msg.ID, Error: "analysis failed"})
+		return
+	}
+	resp.NumCompletions = len(moves)
+	numMoves := req.NumMoves
+	if numMoves <= 0 {
+		numMoves = 5
+	}
+	for _, m := range moves[:min(numMoves, len(moves))] {
+		resp.Completions = append(resp.Completions, moveResponse(m, engine.ResultingPositionID(gs.Board, m.Move)))
+	}
+	c.reply(ctx, WSResponse{Type: "result", ID: msg.ID, Payload: resp})
 }
 
 // WSRolloutRequest is the request payload for streaming rollout.
@@ -226,18 +693,23 @@ type WSRolloutResult struct {
 	TrialsCompleted int     `json:"trials_completed"`
 	GamesWon        int     `json:"games_won"`
 	GamesLost       int     `json:"games_lost"`
+	Clamped         bool    `json:"clamped,omitempty"`
+
+	Seed     int64                   `json:"seed"`               // Seed the trials were played with
+	Manifest *engine.RolloutManifest `json:"manifest,omitempty"` // What is needed to play the trials again
+	Engine   *engine.BuildInfo       `json:"engine,omitempty"`   // Engine build and data that answered
 }
 
-func (c *WSClient) handleRollout(msg WSMessage) {
+func (c *WSClient) handleRollout(ctx context.Context, msg WSMessage) {
 	var req WSRolloutRequest
 	if err := json.Unmarshal(msg.Payload, &req); err != nil {
-		c.sendChan <- WSResponse{Type: "error", ID: msg.ID, Error: "invalid payload"}
+		c.reply(ctx, WSResponse{Type: "error", ID: msg.ID, Error: "invalid payload"})
 		return
 	}
 
-	board, err := positionid.BoardFromPositionID(req.Position)
+	board, err := parsePositionID(req.Position)
 	if err != nil {
-		c.sendChan <- WSResponse{Type: "error", ID: msg.ID, Error: "invalid position"}
+		c.reply(ctx, WSResponse{Type: "error", ID: msg.ID, Error: "invalid position"})
 		return
 	}
 
@@ -248,9 +720,10 @@ func (c *WSClient) handleRollout(msg WSMessage) {
 		CubeOwner: -1,
 	}
 
-	trials := req.Trials
-	if trials <= 0 {
-		trials = 1296
+	trials, clamped, err := c.handlers.depth.trials(req.Trials, c.handlers.limits)
+	if err != nil {
+		c.reply(ctx, WSResponse{Type: "error", ID: msg.ID, Error: err.Error()})
+		return
 	}
 
 	opts := engine.RolloutOptions{
@@ -261,7 +734,7 @@ func (c *WSClient) handleRollout(msg WSMessage) {
 
 	// Progress callback sends updates to client
 	callback := func(p engine.RolloutProgress) {
-		c.sendChan <- WSResponse{
+		c.reply(ctx, WSResponse{
 			Type: "progress",
 			ID:   msg.ID,
 			Payload: WSRolloutProgress{
@@ -271,16 +744,25 @@ func (c *WSClient) handleRollout(msg WSMessage) {
 				CurrentEquity:   p.CurrentEquity,
 				CurrentCI:       p.CurrentCI,
 			},
-		}
+		})
 	}
 
-	result, err := c.handlers.engine.RolloutWithProgress(gs, opts, callback)
+	release, ok := c.acquire(ctx, msg, true)
+	if !ok {
+		return
+	}
+	defer release()
+	result, err := c.handlers.engine.RolloutContext(ctx, gs, opts, callback)
+	if ctx.Err() != nil {
+		// Cancelled, or the connection closed: the cancel has been answered
+		return
+	}
 	if err != nil {
-		c.sendChan <- WSResponse{Type: "error", ID: msg.ID, Error: "rollout failed: " + err.Error()}
+		c.reply(ctx, WSResponse{Type: "error", ID: msg.ID, Error: "rollout failed: " + err.Error()})
 		return
 	}
 
-	c.sendChan <- WSResponse{
+	c.reply(ctx, WSResponse{
 		Type: "result",
 		ID:   msg.ID,
 		Payload: WSRolloutResult{
@@ -294,6 +776,56 @@ func (c *WSClient) handleRollout(msg WSMessage) {
 			TrialsCompleted: result.TrialsCompleted,
 			GamesWon:        result.GamesWon,
 			GamesLost:       result.GamesLost,
+			Clamped:         clamped,
+			Seed:            result.Seed,
+			Manifest:        result.Manifest,
+			Engine:          c.handlers.engine.BuildInfo(),
 		},
+	})
+}
+
+// handleSubscribe joins a broadcast channel. The channel's current frame, if
+// any, is queued at once and may arrive before the reply.
+func (c *WSClient) handleSubscribe(ctx context.Context, msg WSMessage) {
+	var req SubscribeRequest
+	if err := json.Unmarshal(msg.Payload, &req); err != nil {
+		c.reply(ctx, WSResponse{Type: "error", ID: msg.ID, Error: "invalid payload"})
+		return
+	}
+	result, err := c.handlers.broadcasts.subscribe(c, req.Channel)
+	if err != nil {
+		c.reply(ctx, WSResponse{Type: "error", ID: msg.ID, Error: err.msg})
+		return
+	}
+	c.reply(ctx, WSResponse{Type: "result", ID: msg.ID, Payload: result})
+}
+
+func (c *WSClient) handleUnsubscribe(ctx context.Context, msg WSMessage) {
+	var req SubscribeRequest
+	if err := json.Unmarshal(msg.Payload, &req); err != nil {
+		c.reply(ctx, WSResponse{Type: "error", ID: msg.ID, Error: "invalid payload"})
+		return
+	}
+	c.reply(ctx, WSResponse{Type: "result", ID: msg.ID, Payload: c.handlers.broadcasts.unsubscribe(c, req.Channel)})
+}
+
+// handleBroadcastUpdate publishes an update to a channel. The producer does
+// not need to be subscribed.
+func (c *WSClient) handleBroadcastUpdate(ctx context.Context, msg WSMessage) {
+	var req BroadcastUpdateRequest
+	if err := json.Unmarshal(msg.Payload, &req); err != nil {
+		c.reply(ctx, WSResponse{Type: "error", ID: msg.ID, Error: "invalid payload"})
+		return
+	}
+	release, ok := c.acquire(ctx, msg, false)
+	if !ok {
+		return
+	}
+	defer release()
+	result, err := c.handlers.broadcasts.publish(c.handlers.engine, &req)
+	if err != nil {
+		c.reply(ctx, WSResponse{Type: "error", ID: msg.ID, Error: err.msg})
+		return
 	}
+	c.reply(ctx, WSResponse{Type: "result", ID: msg.ID, Payload: result})
 }
@@ -0,0 +1,186 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// wsServer starts a server with a single slow worker, held by the test until
+// it calls the returned release function.
+func wsServer(t *testing.T) (srv *httptest.Server, release func()) {
+	t.Helper()
+	config := DefaultConfig()
+	config.MaxSlowWorkers = 1
+	s := NewServer(getTestEngine(), config, "test")
+	if err := s.Pool().AcquireSlow(context.Background()); err != nil {
+		t.Fatalf("AcquireSlow: %v", err)
+	}
+	srv = httptest.NewServer(s.Handler())
+	t.Cleanup(srv.Close)
+	return srv, s.Pool().ReleaseSlow
+}
+
+func wsSend(t *testing.T, ws *websocket.Conn, typ, id string, payload interface{}) {
+	t.Helper()
+	data, _ := json.Marshal(payload)
+	if err := ws.WriteJSON(WSMessage{Type: typ, ID: id, Payload: data}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+}
+
+func TestWebSocketConcurrentMessages(t *testing.T) {
+	srv, release := wsServer(t)
+	ws := dialWS(t, srv)
+	defer ws.Close()
+
+	// The rollout waits for the worker the test holds; the ping does not
+	wsSend(t, ws, "rollout", "rollout-1", WSRolloutRequest{Position: "4HPwATDgc/ABMA", Trials: 36, Truncate: 2})
+	wsSend(t, ws, "ping", "ping-1", nil)
+	if resp, _ := readWS(t, ws, 2*time.Second); resp.Type != "pong" || resp.ID != "ping-1" {
+		t.Fatalf("first response = %+v, want pong for ping-1", resp)
+	}
+
+	release()
+	for {
+		resp, _ := readWS(t, ws, 10*time.Second)
+		if resp.ID != "rollout-1" {
+			t.Fatalf("response %+v, want rollout-1", resp)
+		}
+		if resp.Type == "result" {
+			break
+		}
+		if resp.Type != "progress" {
+			t.Fatalf("rollout response = %+v", resp)
+		}
+	}
+}
+
+func TestWebSocketInFlightLimit(t *testing.T) {
+	srv, _ := wsServer(t)
+	ws := dialWS(t, srv)
+	defer ws.Close()
+
+	// Every rollout waits for the worker, so only the first 4 are taken on
+	const n = 100
+	for i := 0; i < n; i++ {
+		wsSend(t, ws, "rollout", fmt.Sprint("rollout-", i), WSRolloutRequest{Position: "4HPwATDgc/ABMA", Trials: 36})
+	}
+	refused := make(map[string]bool)
+	for len(refused) < n-DefaultLimits().MaxWSInFlight {
+		resp, _ := readWS(t, ws, 2*time.Second)
+		if resp.Type != "error" || resp.Code != "TOO_MANY_IN_FLIGHT" || refused[resp.ID] {
+			t.Fatalf("response = %+v, want a TOO_MANY_IN_FLIGHT error", resp)
+		}
+		refused[resp.ID] = true
+	}
+	for i := 0; i < DefaultLimits().MaxWSInFlight; i++ {
+		if id := fmt.Sprint("rollout-", i); refused[id] {
+			t.Errorf("%s refused, want it in flight", id)
+		}
+	}
+
+	// Pings are still answered
+	wsSend(t, ws, "ping", "ping-1", nil)
+	if resp, _ := readWS(t, ws, 2*time.Second); resp.Type != "pong" || resp.ID != "ping-1" {
+		t.Errorf("response = %+v, want pong for ping-1", resp)
+	}
+}
+
+func TestWebSocketHintSubMoves(t *testing.T) {
+	srv, _ := wsServer(t)
+	ws := dialWS(t, srv)
+	defer ws.Close()
+
+	hint := func(id string, req WSSubMovesRequest) (WSResponse, WSSubMovesResponse) {
+		t.Helper()
+		resp, _ := wsCall(t, ws, "hint_submoves", id, req)
+		var out WSSubMovesResponse
+		if resp.Type == "result" {
+			if err := json.Unmarshal(resp.Payload.(json.RawMessage), &out); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+		}
+		return resp, out
+	}
+
+	// Opening 6-5 after 24/18: the 5 can be played from 18, 13 or 8
+	resp, out := hint("hint-1", WSSubMovesRequest{
+		Position: "4HPwATDgc/ABMA", Dice: [2]int{6, 5},
+		Used: []WSSubMove{{From: 24, To: 18, Die: 6}},
+	})
+	if resp.Type != "result" {
+		t.Fatalf("response = %+v", resp)
+	}
+	var next []string
+	for _, h := range out.Next {
+		next = append(next, h.Move)
+	}
+	if fmt.Sprint(next) != "[18/13 13/8 8/3]" || !out.Completable || out.Complete {
+		t.Errorf("next %v completable %v complete %v, want [18/13 13/8 8/3] true false", next, out.Completable, out.Complete)
+	}
+	if out.NumCompletions != 3 || len(out.Completions) != 3 || fmt.Sprint(out.Remaining) != "[5]" {
+		t.Errorf("%d completions %v, remaining %v; want 3 and [5]", out.NumCompletions, out.Completions, out.Remaining)
+	}
+
+	// Played through to a full move
+	_, out = hint("hint-2", WSSubMovesRequest{
+		Position: "4HPwATDgc/ABMA", Dice: [2]int{6, 5},
+		Used: []WSSubMove{{From: 24, To: 18, Die: 6}, {From: 18, To: 13, Die: 5}},
+	})
+	if !out.Complete || len(out.Next) != 0 || out.NumCompletions != 1 {
+		t.Errorf("after 24/18/13: %+v, want one complete move", out)
+	}
+
+	// A blocked point is refused
+	resp, _ = hint("hint-3", WSSubMovesRequest{
+		Position: "4HPwATDgc/ABMA", Dice: [2]int{6, 5},
+		Used: []WSSubMove{{From: 24, To: 19, Die: 5}},
+	})
+	if resp.Type != "error" || resp.Code != "ILLEGAL_MOVE" {
+		t.Errorf("blocked hop: response = %+v, want ILLEGAL_MOVE", resp)
+	}
+}
+
+func TestWebSocketCancel(t *testing.T) {
+	srv, release := wsServer(t)
+	ws := dialWS(t, srv)
+	defer ws.Close()
+
+	// The rollout waits for the worker the test holds until cancelled
+	wsSend(t, ws, "rollout", "rollout-1", WSRolloutRequest{Position: "4HPwATDgc/ABMA", Trials: 36, Truncate: 2})
+	wsSend(t, ws, "cancel", "cancel-1", WSCancelRequest{ID: "rollout-1"})
+	resp, _ := readWS(t, ws, 2*time.Second)
+	var cancelled WSCancelResponse
+	json.Unmarshal(resp.Payload.(json.RawMessage), &cancelled)
+	if resp.Type != "cancelled" || resp.ID != "cancel-1" || cancelled != (WSCancelResponse{ID: "rollout-1", Cancelled: true}) {
+		t.Fatalf("response = %+v (%+v), want rollout-1 cancelled", resp, cancelled)
+	}
+
+	// The rollout sends nothing more, and its slot is free again
+	release()
+	wsSend(t, ws, "ping", "ping-1", nil)
+	if resp, _ := readWS(t, ws, 2*time.Second); resp.Type != "pong" || resp.ID != "ping-1" {
+		t.Fatalf("response = %+v, want pong for ping-1", resp)
+	}
+
+	// Cancelling what is not in flight, such as a request already answered,
+	// is a no-op
+	wsSend(t, ws, "cancel", "cancel-2", WSCancelRequest{ID: "ping-1"})
+	resp, _ = readWS(t, ws, 2*time.Second)
+	cancelled = WSCancelResponse{}
+	json.Unmarshal(resp.Payload.(json.RawMessage), &cancelled)
+	if resp.Type != "cancelled" || resp.ID != "cancel-2" || resp.Error != "" || cancelled != (WSCancelResponse{ID: "ping-1"}) {
+		t.Errorf("response = %+v (%+v), want nothing cancelled", resp, cancelled)
+	}
+
+	wsSend(t, ws, "cancel", "cancel-3", nil)
+	if resp, _ := readWS(t, ws, 2*time.Second); resp.Type != "error" || resp.ID != "cancel-3" {
+		t.Errorf("response = %+v, want an error for a cancel naming no request", resp)
+	}
+}
@@ -0,0 +1,203 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"runtime/debug"
+	"strconv"
+
+	"github.com/yourusername/bgengine/internal/positionid"
+	"github.com/yourusername/bgengine/pkg/engine"
+	"github.com/yourusername/bgengine/pkg/wire"
+)
+
+// Binary WebSocket requests are frames of package wire, for clients that
+// negotiate wire.Subprotocol. They are evaluate, move and cube requests,
+// handled like the JSON ones and under the same limits, and answered with
+// binary frames carrying the request's ID. A JSON cancel message naming
+// the ID in decimal cancels a binary request.
+
+// readFrame reads a binary message from r into b, reusing its capacity.
+func readFrame(r io.Reader, b []byte) ([]byte, error) {
+	for {
+		if len(b) == cap(b) {
+			b = append(b, 0)[:len(b)]
+		}
+		n, err := r.Read(b[len(b):cap(b)])
+		b = b[:len(b)+n]
+		if err == io.EOF {
+			return b, nil
+		}
+		if err != nil {
+			return b, err
+		}
+	}
+}
+
+// dispatchBinary handles a binary request frame as dispatch handles a JSON
+// message. A connection that did not negotiate wire.Subprotocol gets a JSON
+// error instead.
+func (c *WSClient) dispatchBinary(frame []byte) {
+	if !c.binary {
+		c.send(WSResponse{Type: "error", Error: "binary frames need the " + wire.Subprotocol + " subprotocol", Code: "INVALID_FRAME"})
+		return
+	}
+	var req wire.Request
+	if err := wire.DecodeRequest(frame, &req); err != nil {
+		c.send(binaryError(req.ID, "INVALID_FRAME", err.Error()))
+		return
+	}
+	id := strconv.FormatUint(uint64(req.ID), 10)
+	if !c.spawn(id, func(ctx context.Context) { c.handleBinarySafely(ctx, &req) }) {
+		c.send(binaryError(req.ID, "TOO_MANY_IN_FLIGHT", c.tooManyInFlight()))
+	}
+}
+
+// handleBinarySafely answers req in ctx, turning a panic into an error
+// frame as handleMessageSafely does.
+func (c *WSClient) handleBinarySafely(ctx context.Context, req *wire.Request) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			id := newErrorID()
+			log.Printf("panic handling WebSocket binary %s request [%s]: %v\n%s", req.Kind, id, rec, debug.Stack())
+			c.reply(ctx, binaryError(req.ID, "INTERNAL_ERROR", "internal error (id "+id+")"))
+		}
+	}()
+	resp, code, err := c.answerBinary(ctx, req)
+	if err != nil {
+		c.reply(ctx, binaryError(req.ID, code, err.Error()))
+		return
+	}
+	resp.ID = req.ID
+	c.reply(ctx, WSResponse{frame: wire.AppendResponse(nil, resp)})
+}
+
+// answerBinary answers req, or returns the code and error to answer it
+// with.
+func (c *WSClient) answerBinary(ctx context.Context, req *wire.Request) (resp *wire.Response, code string, err error) {
+	gs, err := binaryState(&req.Position, req.Kind != wire.KindMove)
+	if err != nil {
+		return nil, "INVALID_POSITION", err
+	}
+	var requested *int
+	if req.Ply != wire.DefaultPly {
+		ply := int(req.Ply)
+		requested = &ply
+	}
+
+	e := c.handlers.engine
+	switch req.Kind {
+	case wire.KindEvaluate:
+		ply, _, err := c.handlers.depth.ply(requested, c.handlers.depth.evalDefault)
+		if err != nil {
+			return nil, "INVALID_PLY", err
+		}
+		release, err := c.acquireWorker(ctx, false)
+		if err != nil {
+			return nil, "SERVER_BUSY", errors.New("server busy")
+		}
+		defer release()
+		full, err := e.EvaluateFull(gs, engine.EvalOptions{Plies: ply, UsePrune: true})
+		if err != nil {
+			return nil, "ANALYSIS_ERROR", errors.New("evaluation failed")
+		}
+		return &wire.Response{
+			Kind:          wire.KindEvaluateResult,
+			Probabilities: binaryProbabilities(&full.Evaluation),
+			Equity:        float32(full.Equity),
+			CubefulEquity: float32(full.CubefulEquity),
+		}, "", nil
+
+	case wire.KindMove:
+		dice := [2]int{int(req.Dice[0]), int(req.Dice[1])}
+		if dice[0] < 1 || dice[0] > 6 || dice[1] < 1 || dice[1] > 6 {
+			return nil, "INVALID_DICE", errors.New("invalid dice")
+		}
+		if err := exceeds("num_moves", int(req.NumMoves), c.handlers.limits.MaxNumMoves); err != nil {
+			return nil, "TOO_MANY_MOVES", err
+		}
+		moveReq := MoveRequest{Dice: dice, Ply: requested}
+		if _, err := c.handlers.depth.movePly(&moveReq); err != nil {
+			return nil, "INVALID_PLY", err
+		}
+		gs.Dice = dice
+		release, err := c.acquireWorker(ctx, false)
+		if err != nil {
+			return nil, "SERVER_BUSY", errors.New("server busy")
+		}
+		defer release()
+		analysis, err := AnalyzeMoves(e, gs, &moveReq, nil)
+		if err != nil {
+			return nil, "ANALYSIS_ERROR", errors.New("analysis failed")
+		}
+		moves := analysis.Moves
+		if n := int(req.NumMoves); n > 0 && n < len(moves) {
+			moves = moves[:n]
+		}
+		resp := &wire.Response{Kind: wire.KindMoveResult, Moves: make([]wire.Move, len(moves))}
+		for i, m := range moves {
+			resp.Moves[i] = wire.Move{
+				From:          m.Move.From,
+				To:            m.Move.To,
+				Probabilities: binaryProbabilities(m.Eval),
+				Equity:        float32(m.Equity),
+			}
+		}
+		return resp, "", nil
+
+	case wire.KindCube:
+		release, err := c.acquireWorker(ctx, false)
+		if err != nil {
+			return nil, "SERVER_BUSY", errors.New("server busy")
+		}
+		defer release()
+		analysis, err := e.AnalyzeCubeWithOptions(gs, engine.EvalOptions{})
+		if err != nil {
+			return nil, "ANALYSIS_ERROR", errors.New("cube analysis failed")
+		}
+		return &wire.Response{
+			Kind:          wire.KindCubeResult,
+			Decision:      uint8(analysis.DecisionType),
+			Probabilities: binaryProbabilities(&analysis.Eval),
+			NoDouble:      float32(analysis.NoDoubleEquity),
+			DoubleTake:    float32(analysis.DoubleTakeEq),
+			DoublePass:    float32(analysis.DoublePassEq),
+		}, "", nil
+	}
+	return nil, "INVALID_FRAME", errors.New("unknown request kind")
+}
+
+// binaryState returns the game state of a binary request's position. The
+// opponent flag applies to evaluate and cube requests, which pass
+// opponent.
+func binaryState(p *wire.Position, opponent bool) (*engine.GameState, error) {
+	board := positionid.BoardFromKey(positionid.PositionKey{Data: p.Key})
+	if !positionid.CheckPosition(board) {
+		return nil, errors.New("invalid position")
+	}
+	cubeValue := int(p.CubeValue)
+	if cubeValue == 0 {
+		cubeValue = 1
+	}
+	gs := &engine.GameState{
+		Board: engine.Board(board), Turn: 0, CubeValue: cubeValue, CubeOwner: int(p.CubeOwner),
+		MatchLength: int(p.MatchLength), Score: [2]int{int(p.Score[0]), int(p.Score[1])},
+		Crawford: p.Flags&wire.FlagCrawford != 0,
+	}
+	if opponent && p.Flags&wire.FlagOpponent != 0 {
+		gs = gs.OpponentOnRoll()
+	}
+	return gs, nil
+}
+
+func binaryProbabilities(e *engine.Evaluation) wire.Probabilities {
+	return wire.Probabilities{float32(e.WinProb), float32(e.WinG), float32(e.WinBG), float32(e.LoseG), float32(e.LoseBG)}
+}
+
+// binaryError returns the error frame answering the binary request with ID
+// id.
+func binaryError(id uint32, code, msg string) WSResponse {
+	return WSResponse{frame: wire.AppendResponse(nil, &wire.Response{Kind: wire.KindError, ID: id, Code: code, Message: msg})}
+}
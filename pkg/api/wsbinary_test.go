@@ -0,0 +1,215 @@
+package api
+
+import (
+	"encoding/json"
+	"math"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/yourusername/bgengine/pkg/engine"
+	"github.com/yourusername/bgengine/pkg/wire"
+)
+
+func dialBinaryWS(t *testing.T, srv *httptest.Server) *websocket.Conn {
+	t.Helper()
+	dialer := websocket.Dialer{Subprotocols: []string{wire.Subprotocol}}
+	ws, _, err := dialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/api/ws", nil)
+	if err != nil {
+		t.Fatalf("WebSocket dial failed: %v", err)
+	}
+	if ws.Subprotocol() != wire.Subprotocol {
+		t.Fatalf("negotiated subprotocol %q, want %q", ws.Subprotocol(), wire.Subprotocol)
+	}
+	return ws
+}
+
+// binaryCall sends a binary request and returns the response frame.
+func binaryCall(t *testing.T, ws *websocket.Conn, frame []byte) wire.Response {
+	t.Helper()
+	if err := ws.WriteMessage(websocket.BinaryMessage, frame); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	kind, data, err := ws.ReadMessage()
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if kind != websocket.BinaryMessage {
+		t.Fatalf("response is a text message: %s", data)
+	}
+	var resp wire.Response
+	if err := wire.DecodeResponse(data, &resp); err != nil {
+		t.Fatalf("DecodeResponse: %v", err)
+	}
+	return resp
+}
+
+func TestWebSocketBinary(t *testing.T) {
+	srv := httptest.NewServer(NewServer(getTestEngine(), DefaultConfig(), "test").Handler())
+	defer srv.Close()
+	ws := dialBinaryWS(t, srv)
+	defer ws.Close()
+
+	const position = "4HPwATDgc/ABMA"
+	key, err := wire.KeyFromPositionID(position)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pos := wire.Position{Key: key, CubeOwner: -1}
+
+	// Binary and JSON answers agree, on the same connection
+	eval := binaryCall(t, ws, wire.AppendRequest(nil, &wire.Request{Kind: wire.KindEvaluate, ID: 1, Position: pos, Ply: wire.DefaultPly}))
+	jsonResp, _ := wsCall(t, ws, "evaluate", "e", EvaluateRequest{Position: position})
+	var jsonEval EvaluateResponse
+	json.Unmarshal(jsonResp.Payload.(json.RawMessage), &jsonEval)
+	if eval.Kind != wire.KindEvaluateResult || eval.ID != 1 {
+		t.Fatalf("evaluate answered with %s %d", eval.Kind, eval.ID)
+	}
+	if math.Abs(float64(eval.Equity)-jsonEval.Equity) > 1e-6 || math.Abs(float64(eval.Probabilities[0])*100-jsonEval.Win) > 1e-4 {
+		t.Errorf("binary evaluation %+v differs from JSON %+v", eval, jsonEval)
+	}
+
+	move := binaryCall(t, ws, wire.AppendRequest(nil, &wire.Request{Kind: wire.KindMove, ID: 2, Position: pos, Ply: 0, Dice: [2]uint8{3, 1}, NumMoves: 3}))
+	jsonResp, _ = wsCall(t, ws, "move", "m", MoveRequest{Position: position, Dice: [2]int{3, 1}, NumMoves: 3})
+	var jsonMoves MovesResponse
+	json.Unmarshal(jsonResp.Payload.(json.RawMessage), &jsonMoves)
+	if move.Kind != wire.KindMoveResult || move.ID != 2 || len(move.Moves) != 3 {
+		t.Fatalf("move answered with %s %d, %d moves", move.Kind, move.ID, len(move.Moves))
+	}
+	for i, m := range move.Moves {
+		if got := engine.FormatMove(engine.Move{From: m.From, To: m.To}); got != jsonMoves.Moves[i].Move {
+			t.Errorf("move %d = %s, JSON has %s", i, got, jsonMoves.Moves[i].Move)
+		}
+	}
+
+	cube := binaryCall(t, ws, wire.AppendRequest(nil, &wire.Request{Kind: wire.KindCube, ID: 3, Position: pos, Ply: wire.DefaultPly}))
+	if cube.Kind != wire.KindCubeResult || cube.ID != 3 || engine.CubeDecisionType(cube.Decision).Action() != engine.CubeNoDouble {
+		t.Errorf("cube answered with %+v, want no double", cube)
+	}
+
+	// Errors carry the request's ID and a code
+	bad := []struct {
+		frame []byte
+		code  string
+	}{
+		{[]byte{byte(wire.KindMove), 4, 0, 0, 0, 1}, "INVALID_FRAME"},
+		{wire.AppendRequest(nil, &wire.Request{Kind: wire.KindMove, ID: 4, Position: pos, Dice: [2]uint8{7, 1}}), "INVALID_DICE"},
+		{wire.AppendRequest(nil, &wire.Request{Kind: wire.KindEvaluate, ID: 4, Position: wire.Position{Key: [7]uint32{0xffffffff}}}), "INVALID_POSITION"},
+		{wire.AppendRequest(nil, &wire.Request{Kind: wire.KindEvaluate, ID: 4, Position: pos, Ply: 9}), "INVALID_PLY"},
+	}
+	for _, b := range bad {
+		resp := binaryCall(t, ws, b.frame)
+		if resp.Kind != wire.KindError || resp.ID != 4 || resp.Code != b.code {
+			t.Errorf("frame %x answered with %+v, want a %s error", b.frame, resp, b.code)
+		}
+	}
+}
+
+func TestWebSocketBinaryNeedsSubprotocol(t *testing.T) {
+	srv := httptest.NewServer(NewServer(getTestEngine(), DefaultConfig(), "test").Handler())
+	defer srv.Close()
+	ws := dialWS(t, srv)
+	defer ws.Close()
+
+	key, _ := wire.KeyFromPositionID("4HPwATDgc/ABMA")
+	frame := wire.AppendRequest(nil, &wire.Request{Kind: wire.KindEvaluate, ID: 1, Position: wire.Position{Key: key}, Ply: wire.DefaultPly})
+	if err := ws.WriteMessage(websocket.BinaryMessage, frame); err != nil {
+		t.Fatal(err)
+	}
+	if resp, _ := readWS(t, ws, 2*time.Second); resp.Type != "error" || resp.Code != "INVALID_FRAME" {
+		t.Errorf("response = %+v, want an INVALID_FRAME error", resp)
+	}
+}
+
+// The client and server sides of a move request answered with five moves,
+// in JSON and in binary frames, for comparing what the encodings cost.
+
+var benchMoves = func() MovesResponse {
+	resp := MovesResponse{Position: "4HPwATDgc/ABMA", Dice: [2]int{3, 1}, NumLegal: 16}
+	for i := 0; i < 5; i++ {
+		resp.Moves = append(resp.Moves, MoveResponse{
+			Move: "8/5 6/5", Equity: 0.15, Win: 53.2, WinG: 15.1, WinBG: 0.7, LoseG: 12.5, LoseBG: 0.5,
+			PositionID: "4HPwATDgc/ABMA", Ply: 0, CubefulEquity: 0.18, CubelessEquity: 0.15,
+		})
+	}
+	return resp
+}()
+
+func jsonMoveRoundTrip(tb testing.TB) {
+	req, _ := json.Marshal(MoveRequest{Position: "4HPwATDgc/ABMA", Dice: [2]int{3, 1}, NumMoves: 5})
+	msg, _ := json.Marshal(WSMessage{Type: "move", ID: "move-1", Payload: req})
+
+	var in WSMessage
+	var moveReq MoveRequest
+	if err := json.Unmarshal(msg, &in); err != nil {
+		tb.Fatal(err)
+	}
+	if err := json.Unmarshal(in.Payload, &moveReq); err != nil {
+		tb.Fatal(err)
+	}
+	out, _ := json.Marshal(WSResponse{Type: "result", ID: in.ID, Payload: benchMoves})
+
+	var resp struct {
+		WSResponse
+		Payload MovesResponse `json:"payload"`
+	}
+	if err := json.Unmarshal(out, &resp); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+type binaryCodec struct {
+	reqFrame, respFrame []byte
+	req                 wire.Request
+	resp                wire.Response
+	moves               wire.Response
+}
+
+func newBinaryCodec() *binaryCodec {
+	c := &binaryCodec{moves: wire.Response{Kind: wire.KindMoveResult, Moves: make([]wire.Move, 5)}}
+	for i := range c.moves.Moves {
+		c.moves.Moves[i] = wire.Move{From: [4]int8{7, 5, -1, -1}, To: [4]int8{4, 4, -1, -1}, Probabilities: wire.Probabilities{0.532, 0.151, 0.007, 0.125, 0.005}, Equity: 0.15}
+	}
+	return c
+}
+
+func (c *binaryCodec) moveRoundTrip(tb testing.TB) {
+	key := [7]uint32{1, 2, 3, 4, 5, 6, 7}
+	c.reqFrame = wire.AppendRequest(c.reqFrame[:0], &wire.Request{Kind: wire.KindMove, ID: 1, Position: wire.Position{Key: key}, Dice: [2]uint8{3, 1}, NumMoves: 5})
+	if err := wire.DecodeRequest(c.reqFrame, &c.req); err != nil {
+		tb.Fatal(err)
+	}
+	c.moves.ID = c.req.ID
+	c.respFrame = wire.AppendResponse(c.respFrame[:0], &c.moves)
+	if err := wire.DecodeResponse(c.respFrame, &c.resp); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+func TestBinaryFramesAllocateLess(t *testing.T) {
+	jsonAllocs := testing.AllocsPerRun(100, func() { jsonMoveRoundTrip(t) })
+	codec := newBinaryCodec()
+	codec.moveRoundTrip(t)
+	binaryAllocs := testing.AllocsPerRun(100, func() { codec.moveRoundTrip(t) })
+	if jsonAllocs < 5*math.Max(binaryAllocs, 1) {
+		t.Errorf("a move request costs %v allocations in JSON and %v in binary frames, want at least 5 times fewer", jsonAllocs, binaryAllocs)
+	}
+}
+
+func BenchmarkWSMoveJSON(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		jsonMoveRoundTrip(b)
+	}
+}
+
+func BenchmarkWSMoveBinary(b *testing.B) {
+	codec := newBinaryCodec()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		codec.moveRoundTrip(b)
+	}
+}
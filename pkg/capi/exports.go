@@ -9,7 +9,6 @@ package main
 import "C"
 import (
 	"encoding/json"
-	"strings"
 	"sync"
 	"unsafe"
 
@@ -38,9 +37,7 @@ func setError(err error) {
 // parsePosition converts a position ID string to a GameState.
 func parsePosition(posStr string) (*engine.GameState, error) {
 	// Handle gnubg format "positionID:matchID" - we only need the position part
-	if idx := strings.Index(posStr, ":"); idx >= 0 {
-		posStr = posStr[:idx]
-	}
+	posStr, _ = positionid.SplitGnubgID(posStr)
 
 	board, err := positionid.BoardFromPositionID(posStr)
 	if err != nil {
@@ -184,7 +181,7 @@ func bgengine_best_move(positionID *C.char, die1, die2 C.int, resultJSON **C.cha
 
 	best := analysis.Moves[0]
 	result := map[string]interface{}{
-		"move":      formatMove(best.Move),
+		"move":      engine.FormatMove(best.Move),
 		"equity":    best.Equity,
 		"win":       best.Eval.WinProb * 100,
 		"win_g":     best.Eval.WinG * 100,
@@ -223,18 +220,11 @@ func bgengine_cube_decision(positionID *C.char, resultJSON **C.char) C.int {
 		return -1
 	}
 
-	action := "no_double"
 	diff := decision.DoubleTakeEq - decision.NoDoubleEquity
-	if diff > 0 {
-		if decision.DoublePassEq > decision.DoubleTakeEq {
-			action = "double_pass"
-		} else {
-			action = "double_take"
-		}
-	}
 
 	result := map[string]interface{}{
-		"action":           action,
+		"action":           decision.DecisionType.Action(),
+		"decision_type":    decision.DecisionType.String(),
 		"double_equity":    decision.DoubleTakeEq,
 		"no_double_equity": decision.NoDoubleEquity,
 		"double_diff":      diff,
@@ -246,40 +236,6 @@ func bgengine_cube_decision(positionID *C.char, resultJSON **C.char) C.int {
 	return 0
 }
 
-// formatMove converts a Move to human-readable notation.
-func formatMove(m engine.Move) string {
-	result := ""
-	for i := 0; i < 4; i++ {
-		if m.From[i] < 0 {
-			break
-		}
-		if i > 0 {
-			result += " "
-		}
-		from := int(m.From[i]) + 1
-		if m.From[i] == 24 {
-			result += "bar"
-		} else {
-			result += itoa(from)
-		}
-		result += "/"
-		if m.To[i] < 0 {
-			result += "off"
-		} else {
-			result += itoa(int(m.To[i]) + 1)
-		}
-	}
-	return result
-}
-
-// itoa converts an int to a string (simple implementation for small numbers).
-func itoa(n int) string {
-	if n < 10 {
-		return string(rune('0' + n))
-	}
-	return string(rune('0'+n/10)) + string(rune('0'+n%10))
-}
-
 //export bgengine_free_string
 func bgengine_free_string(s *C.char) {
 	if s != nil {
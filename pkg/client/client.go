@@ -0,0 +1,320 @@
+// Package client provides a typed Go client for the GoBG HTTP API.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/yourusername/bgengine/pkg/api"
+)
+
+// Errors returned (wrapped in *APIError) for the server's error codes.
+// Use errors.Is to test for them and errors.As to get the full *APIError.
+// ErrNetwork is returned instead when no response came back at all.
+var (
+	ErrNetwork         = errors.New("network error")
+	ErrInvalidRequest  = errors.New("invalid request")
+	ErrInvalidPosition = errors.New("invalid position")
+	ErrIllegalMove     = errors.New("illegal move")
+	ErrServerBusy      = errors.New("server busy")
+	ErrServer          = errors.New("server error")
+)
+
+// APIError is an error response from the server.
+type APIError struct {
+	StatusCode     int      // HTTP status code
+	Code           string   // Error code (e.g. "INVALID_POSITION")
+	Message        string   // Error message
+	Details        string   // Additional details
+	LegalPositions []string // Reachable position IDs (ILLEGAL_MOVE)
+	ErrorID        string   // Server log entry for the error (INTERNAL_ERROR)
+	AvailableMETs  []string // Match equity tables the server has (INVALID_MET)
+}
+
+func (e *APIError) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = http.StatusText(e.StatusCode)
+	}
+	if e.Code != "" {
+		return fmt.Sprintf("api error %d %s: %s", e.StatusCode, e.Code, msg)
+	}
+	return fmt.Sprintf("api error %d: %s", e.StatusCode, msg)
+}
+
+// Unwrap maps the error code to one of the package's sentinel errors.
+func (e *APIError) Unwrap() error {
+	switch e.Code {
+	case "INVALID_POSITION", "MISSING_POSITION":
+		return ErrInvalidPosition
+	case "ILLEGAL_MOVE":
+		return ErrIllegalMove
+	case "SERVER_BUSY":
+		return ErrServerBusy
+	}
+	if e.StatusCode >= 500 {
+		return ErrServer
+	}
+	return ErrInvalidRequest
+}
+
+// Config holds the client configuration.
+//
+// The HTTP client keeps connections to the server open between requests, so
+// create one Client and share it rather than one per request. net/http keeps
+// only two idle connections per host by default; a program sending many
+// requests at once should pass an HTTPClient whose Transport has
+// MaxIdleConnsPerHost at least its concurrency, or it will keep opening new
+// connections.
+type Config struct {
+	Timeout    time.Duration // Per-request timeout (default 30s, 0 = none)
+	HTTPClient *http.Client  // HTTP client to use (default: new client with Timeout)
+
+	// Retries is how many times a failed idempotent request (a GET or an
+	// evaluation) is tried again: after a network error other than a
+	// timeout, or a 502, 503 or 504 response. Moves, cube decisions,
+	// rollouts and tutor requests are never retried.
+	Retries      int
+	RetryBackoff time.Duration // Wait before the first retry, doubled for each further one (default 200ms)
+
+	// Header holds headers sent with every request, such as the client
+	// identity a server shares its slow operations fairly by (see
+	// api.ServerConfig.IdentityHeader).
+	Header http.Header
+}
+
+// DefaultConfig returns a Config with sensible defaults.
+func DefaultConfig() Config {
+	return Config{
+		Timeout:      30 * time.Second,
+		Retries:      2,
+		RetryBackoff: 200 * time.Millisecond,
+	}
+}
+
+// Client talks to a GoBG API server. It is safe for concurrent use.
+type Client struct {
+	baseURL      string
+	httpClient   *http.Client
+	retries      int
+	retryBackoff time.Duration
+	header       http.Header
+}
+
+// New creates a client for the server at baseURL (e.g. "http://localhost:8080").
+func New(baseURL string, config Config) *Client {
+	httpClient := config.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: config.Timeout}
+	}
+	backoff := config.RetryBackoff
+	if backoff <= 0 {
+		backoff = 200 * time.Millisecond
+	}
+	return &Client{
+		baseURL:      strings.TrimRight(baseURL, "/"),
+		httpClient:   httpClient,
+		retries:      config.Retries,
+		retryBackoff: backoff,
+		header:       config.Header.Clone(),
+	}
+}
+
+// Health checks the server status.
+func (c *Client) Health(ctx context.Context) (*api.HealthResponse, error) {
+	var resp api.HealthResponse
+	if err := c.do(ctx, http.MethodGet, "/api/health", nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Evaluate evaluates a position.
+func (c *Client) Evaluate(ctx context.Context, req *api.EvaluateRequest) (*api.EvaluateResponse, error) {
+	var resp api.EvaluateResponse
+	if err := c.do(ctx, http.MethodPost, "/api/evaluate", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Move finds the best moves for a dice roll.
+func (c *Client) Move(ctx context.Context, req *api.MoveRequest) (*api.MovesResponse, error) {
+	var resp api.MovesResponse
+	if err := c.do(ctx, http.MethodPost, "/api/move", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Cube analyzes a cube decision.
+func (c *Client) Cube(ctx context.Context, req *api.CubeRequest) (*api.CubeResponse, error) {
+	var resp api.CubeResponse
+	if err := c.do(ctx, http.MethodPost, "/api/cube", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Rollout performs a Monte Carlo rollout.
+// Rollouts can take much longer than other requests; use a Config with a
+// larger Timeout or a context deadline as appropriate.
+func (c *Client) Rollout(ctx context.Context, req *api.RolloutRequest) (*api.RolloutResponse, error) {
+	var resp api.RolloutResponse
+	if err := c.do(ctx, http.MethodPost, "/api/rollout", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// CubeRollout decides the cube from a rollout of the position. Like Rollout,
+// it can take much longer than other requests.
+func (c *Client) CubeRollout(ctx context.Context, req *api.RolloutRequest) (*api.CubeRolloutResponse, error) {
+	var resp api.CubeRolloutResponse
+	if err := c.do(ctx, http.MethodPost, "/api/cube/rollout", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// TutorMove analyzes a played move.
+// An illegal move returns an *APIError wrapping ErrIllegalMove with the legal
+// resulting positions when the move was given as a resulting position.
+func (c *Client) TutorMove(ctx context.Context, req *api.TutorMoveRequest) (*api.TutorMoveResponse, error) {
+	var resp api.TutorMoveResponse
+	if err := c.do(ctx, http.MethodPost, "/api/tutor/move", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// TutorCube analyzes a cube action.
+func (c *Client) TutorCube(ctx context.Context, req *api.TutorCubeRequest) (*api.TutorCubeResponse, error) {
+	var resp api.TutorCubeResponse
+	if err := c.do(ctx, http.MethodPost, "/api/tutor/cube", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// AnalyzeGame analyzes a complete game.
+func (c *Client) AnalyzeGame(ctx context.Context, req *api.AnalyzeGameRequest) (*api.GameAnalysisResponse, error) {
+	var resp api.GameAnalysisResponse
+	if err := c.do(ctx, http.MethodPost, "/api/tutor/game", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// do sends a request with an optional JSON body and decodes the JSON response
+// into out, retrying idempotent requests as the Config allows.
+func (c *Client) do(ctx context.Context, method, path string, body interface{}, out interface{}) error {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("encoding request: %w", err)
+		}
+	}
+
+	retries := 0
+	if method == http.MethodGet || path == "/api/evaluate" {
+		retries = c.retries
+	}
+	backoff := c.retryBackoff
+	for attempt := 0; ; attempt++ {
+		err := c.send(ctx, method, path, data, out)
+		if attempt == retries || !retryable(err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// send makes a single attempt at a request.
+func (c *Client) send(ctx context.Context, method, path string, data []byte, out interface{}) error {
+	var reader io.Reader
+	if data != nil {
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	for name, values := range c.header {
+		req.Header[name] = values
+	}
+	if data != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w: %w", method, path, ErrNetwork, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return decodeError(resp)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decoding %s response: %w", path, err)
+	}
+	return nil
+}
+
+// retryable reports whether a failed attempt is worth repeating: the server
+// was unreachable or a gateway in front of it could not get an answer. A
+// timeout is not retried, as the next attempt would most likely time out too.
+func retryable(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	if !errors.Is(err, ErrNetwork) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var netErr net.Error
+	return !errors.As(err, &netErr) || !netErr.Timeout()
+}
+
+// decodeError builds an *APIError from a non-200 response.
+func decodeError(resp *http.Response) error {
+	apiErr := &APIError{StatusCode: resp.StatusCode}
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	var errResp api.ErrorResponse
+	if err := json.Unmarshal(data, &errResp); err == nil && (errResp.Error != "" || errResp.Code != "") {
+		apiErr.Code = errResp.Code
+		apiErr.Message = errResp.Error
+		apiErr.Details = errResp.Details
+		apiErr.LegalPositions = errResp.LegalPositions
+		apiErr.ErrorID = errResp.ErrorID
+		apiErr.AvailableMETs = errResp.AvailableMETs
+	} else {
+		// Not an API error body (e.g. a proxy or an unknown route)
+		apiErr.Message = strings.TrimSpace(string(data))
+	}
+
+	return apiErr
+}
@@ -0,0 +1,287 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/yourusername/bgengine/pkg/api"
+	"github.com/yourusername/bgengine/pkg/engine"
+)
+
+const startPosition = "4HPwATDgc/ABMA"
+
+// newTestServer starts an httptest server running the real API handlers
+// with an engine that has no networks loaded.
+func newTestServer(t *testing.T) *Client {
+	t.Helper()
+	eng, err := engine.NewEngine(engine.EngineOptions{})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	server := httptest.NewServer(api.NewServer(eng, api.DefaultConfig(), "test").Handler())
+	t.Cleanup(server.Close)
+	return New(server.URL, DefaultConfig())
+}
+
+func TestClientHealth(t *testing.T) {
+	c := newTestServer(t)
+
+	resp, err := c.Health(context.Background())
+	if err != nil {
+		t.Fatalf("Health failed: %v", err)
+	}
+	if resp.Status != "ok" || resp.Version != "test" || !resp.Ready {
+		t.Errorf("Health = %+v, want ok/test/ready", resp)
+	}
+}
+
+func TestClientEvaluate(t *testing.T) {
+	c := newTestServer(t)
+
+	resp, err := c.Evaluate(context.Background(), &api.EvaluateRequest{Position: startPosition})
+	if err != nil {
+		t.Fatalf("Evaluate failed: %v", err)
+	}
+	if resp.Win <= 0 || resp.Win >= 100 {
+		t.Errorf("Win = %.2f, want between 0 and 100", resp.Win)
+	}
+}
+
+func TestClientMove(t *testing.T) {
+	c := newTestServer(t)
+
+	resp, err := c.Move(context.Background(), &api.MoveRequest{Position: startPosition, Dice: [2]int{3, 1}, NumMoves: 3})
+	if err != nil {
+		t.Fatalf("Move failed: %v", err)
+	}
+	if len(resp.Moves) != 3 || resp.NumLegal == 0 {
+		t.Errorf("got %d moves of %d legal, want 3", len(resp.Moves), resp.NumLegal)
+	}
+}
+
+func TestClientCube(t *testing.T) {
+	c := newTestServer(t)
+
+	resp, err := c.Cube(context.Background(), &api.CubeRequest{Position: startPosition})
+	if err != nil {
+		t.Fatalf("Cube failed: %v", err)
+	}
+	if resp.Action == "" {
+		t.Error("Expected a cube action")
+	}
+}
+
+func TestClientRollout(t *testing.T) {
+	c := newTestServer(t)
+
+	resp, err := c.Rollout(context.Background(), &api.RolloutRequest{Position: startPosition, Trials: 10, Truncate: 5, Seed: 1})
+	if err != nil {
+		t.Fatalf("Rollout failed: %v", err)
+	}
+	if resp.Trials != 10 {
+		t.Errorf("Trials = %d, want 10", resp.Trials)
+	}
+}
+
+func TestClientCubeRollout(t *testing.T) {
+	c := newTestServer(t)
+
+	resp, err := c.CubeRollout(context.Background(), &api.RolloutRequest{Position: startPosition, CubeOwner: -1, Trials: 10, Truncate: 5, Seed: 1})
+	if err != nil {
+		t.Fatalf("CubeRollout failed: %v", err)
+	}
+	if resp.Cube == nil || resp.Cube.Decision == "" || resp.Rollout.Trials != 10 {
+		t.Errorf("response = %+v", resp)
+	}
+}
+
+func TestClientTutor(t *testing.T) {
+	c := newTestServer(t)
+	ctx := context.Background()
+
+	move, err := c.TutorMove(ctx, &api.TutorMoveRequest{Position: startPosition, Dice: [2]int{3, 1}, Move: "8/5 6/5"})
+	if err != nil {
+		t.Fatalf("TutorMove failed: %v", err)
+	}
+	if move.PlayedMove == "" || move.BestMove == "" {
+		t.Errorf("TutorMove = %+v, want played and best moves", move)
+	}
+
+	cube, err := c.TutorCube(ctx, &api.TutorCubeRequest{Position: startPosition, Action: "no_double"})
+	if err != nil {
+		t.Fatalf("TutorCube failed: %v", err)
+	}
+	if cube.Optimal == "" {
+		t.Error("Expected an optimal cube action")
+	}
+}
+
+func TestClientErrorMapping(t *testing.T) {
+	c := newTestServer(t)
+	ctx := context.Background()
+
+	_, err := c.Evaluate(ctx, &api.EvaluateRequest{Position: "not-a-position"})
+	if !errors.Is(err, ErrInvalidPosition) {
+		t.Errorf("invalid position: got %v, want ErrInvalidPosition", err)
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest || apiErr.Code != "INVALID_POSITION" {
+		t.Errorf("invalid position: got %#v, want 400 INVALID_POSITION", apiErr)
+	}
+
+	_, err = c.Move(ctx, &api.MoveRequest{Position: startPosition, Dice: [2]int{7, 1}})
+	if !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("invalid dice: got %v, want ErrInvalidRequest", err)
+	}
+
+	// The unchanged starting position cannot result from any 3-1
+	_, err = c.TutorMove(ctx, &api.TutorMoveRequest{Position: startPosition, Dice: [2]int{3, 1}, ResultingPosition: startPosition})
+	if !errors.Is(err, ErrIllegalMove) {
+		t.Fatalf("illegal move: got %v, want ErrIllegalMove", err)
+	}
+	if !errors.As(err, &apiErr) || len(apiErr.LegalPositions) == 0 {
+		t.Error("illegal move: expected legal positions in the error")
+	}
+}
+
+func TestClientServerBusy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"error":"server busy","code":"SERVER_BUSY"}`))
+	}))
+	defer server.Close()
+
+	_, err := New(server.URL, DefaultConfig()).Evaluate(context.Background(), &api.EvaluateRequest{Position: startPosition})
+	if !errors.Is(err, ErrServerBusy) {
+		t.Errorf("got %v, want ErrServerBusy", err)
+	}
+}
+
+func TestClientNonJSONError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad gateway", http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	_, err := New(server.URL, DefaultConfig()).Health(context.Background())
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Message != "bad gateway" {
+		t.Fatalf("got %v, want APIError with body message", err)
+	}
+	if !errors.Is(err, ErrServer) {
+		t.Errorf("got %v, want ErrServer", err)
+	}
+}
+
+func TestClientTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	c := New(server.URL, Config{Timeout: 50 * time.Millisecond})
+	start := time.Now()
+	_, err := c.Health(context.Background())
+	if err == nil {
+		t.Fatal("Expected timeout error")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Request took %v, want timeout after ~50ms", elapsed)
+	}
+
+	// Context cancellation is honoured as well
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = New(server.URL, Config{}).Health(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestClientRetries(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		if attempts.Load() < 3 {
+			http.Error(w, "bad gateway", http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`{"equity":0.1,"win":55}`))
+	}))
+	defer server.Close()
+
+	config := Config{Retries: 2, RetryBackoff: time.Millisecond}
+	resp, err := New(server.URL, config).Evaluate(context.Background(), &api.EvaluateRequest{Position: startPosition})
+	if err != nil {
+		t.Fatalf("Evaluate failed after retries: %v", err)
+	}
+	if attempts.Load() != 3 || resp.Win != 55 {
+		t.Errorf("attempts = %d, win = %.1f; want 3 attempts and the last response", attempts.Load(), resp.Win)
+	}
+
+	// Moves are not retried
+	attempts.Store(0)
+	_, err = New(server.URL, config).Move(context.Background(), &api.MoveRequest{Position: startPosition, Dice: [2]int{3, 1}})
+	if !errors.Is(err, ErrServer) || attempts.Load() != 1 {
+		t.Errorf("Move: got %v after %d attempts, want ErrServer after 1", err, attempts.Load())
+	}
+
+	// Nor are client errors
+	attempts.Store(0)
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		http.Error(w, `{"error":"bad position","code":"INVALID_POSITION"}`, http.StatusBadRequest)
+	})
+	_, err = New(server.URL, config).Evaluate(context.Background(), &api.EvaluateRequest{Position: "x"})
+	if !errors.Is(err, ErrInvalidPosition) || attempts.Load() != 1 {
+		t.Errorf("invalid position: got %v after %d attempts, want ErrInvalidPosition after 1", err, attempts.Load())
+	}
+}
+
+func TestClientNetworkError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	_, err := New(url, Config{Retries: 1, RetryBackoff: time.Millisecond}).Health(context.Background())
+	if !errors.Is(err, ErrNetwork) {
+		t.Fatalf("got %v, want ErrNetwork", err)
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		t.Errorf("network failure returned an APIError: %v", apiErr)
+	}
+}
+
+func TestClientIdentityHeader(t *testing.T) {
+	eng, err := engine.NewEngine(engine.EngineOptions{})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	config := api.DefaultConfig()
+	config.IdentityHeader = "X-API-Key"
+	s := api.NewServer(eng, config, "test")
+	server := httptest.NewServer(s.Handler())
+	defer server.Close()
+
+	c := New(server.URL, Config{Header: http.Header{"X-Api-Key": {"secret-key"}}})
+	if _, err := c.Rollout(context.Background(), &api.RolloutRequest{Position: startPosition, Trials: 10, Truncate: 5, Seed: 1}); err != nil {
+		t.Fatalf("Rollout failed: %v", err)
+	}
+	health, err := c.Health(context.Background())
+	if err != nil {
+		t.Fatalf("Health failed: %v", err)
+	}
+	if got := health.Pool.Identities[api.HashIdentity("secret-key")]; got.TotalSlow != 1 {
+		t.Errorf("identities %+v, want one rollout under the key", health.Pool.Identities)
+	}
+}
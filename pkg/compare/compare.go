@@ -0,0 +1,308 @@
+package compare
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/yourusername/bgengine/internal/neuralnet"
+	"github.com/yourusername/bgengine/internal/positionid"
+	"github.com/yourusername/bgengine/pkg/engine"
+)
+
+// Reference is the program the engine is compared with. Gnubg is one.
+type Reference interface {
+	// Evaluate returns the cubeless evaluation of the position with the
+	// given ID for the player on roll.
+	Evaluate(position string) (*engine.Evaluation, error)
+	// BestMove returns the best move for dice in gnubg's notation, or ""
+	// if there is none.
+	BestMove(position string, dice [2]int) (string, error)
+}
+
+// Position is a position to compare, with the dice to compare the best
+// move for if Dice is not zero.
+type Position struct {
+	ID   string
+	Dice [2]int
+}
+
+// ReadPositions reads positions, one per line: a position ID, optionally
+// with the match ID after a colon, then optionally a roll ("31", "3-1" or
+// "3,1"). Blank lines and lines starting with # are skipped.
+func ReadPositions(r io.Reader) ([]Position, error) {
+	var positions []Position
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) > 2 {
+			return nil, fmt.Errorf("line %d: want a position ID and optionally a roll", line)
+		}
+		id, _ := positionid.SplitGnubgID(fields[0])
+		if _, err := positionid.BoardFromPositionID(id); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		pos := Position{ID: id}
+		if len(fields) == 2 {
+			dice, err := parseRoll(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			pos.Dice = dice
+		}
+		positions = append(positions, pos)
+	}
+	return positions, sc.Err()
+}
+
+// parseRoll parses a roll such as "31", "3-1" or "3,1".
+func parseRoll(s string) ([2]int, error) {
+	digits := strings.NewReplacer("-", "", ",", "").Replace(s)
+	if len(digits) == 2 {
+		d1, err1 := strconv.Atoi(digits[:1])
+		d2, err2 := strconv.Atoi(digits[1:])
+		if err1 == nil && err2 == nil && d1 >= 1 && d1 <= 6 && d2 >= 1 && d2 <= 6 {
+			return [2]int{d1, d2}, nil
+		}
+	}
+	return [2]int{}, fmt.Errorf("bad roll %q", s)
+}
+
+// Result is the comparison of one position.
+type Result struct {
+	Position  string             `json:"position"`
+	Dice      [2]int             `json:"dice"`
+	Class     string             `json:"class"`               // Position class, as the engine classifies it
+	Engine    *engine.Evaluation `json:"engine,omitempty"`    // The engine's evaluation
+	Reference *engine.Evaluation `json:"reference,omitempty"` // The reference's evaluation
+	WinDiff   float64            `json:"win_diff"`            // Absolute difference in winning chances
+	EqDiff    float64            `json:"equity_diff"`         // Absolute difference in cubeless equity
+
+	// With dice: the best moves and whether they come to the same position
+	EngineMove    string `json:"engine_move,omitempty"`
+	ReferenceMove string `json:"reference_move,omitempty"`
+	MovesAgree    bool   `json:"moves_agree,omitempty"`
+
+	Err string `json:"error,omitempty"` // Why the position could not be compared
+}
+
+// ClassStats summarizes the positions of one class.
+type ClassStats struct {
+	Class         string  `json:"class"`
+	Positions     int     `json:"positions"`
+	MeanWinDiff   float64 `json:"mean_win_diff"`
+	MaxWinDiff    float64 `json:"max_win_diff"`
+	Moves         int     `json:"moves"`         // Positions with dice compared
+	Disagreements int     `json:"disagreements"` // Of those, where the best moves differ
+}
+
+// Report is the divergence of the engine from the reference over a set of
+// positions. Means and maxima cover the positions compared, not those
+// that failed.
+type Report struct {
+	Ply            int          `json:"ply"`
+	Positions      int          `json:"positions"` // Positions compared
+	Failed         int          `json:"failed"`    // Positions that could not be compared
+	MeanWinDiff    float64      `json:"mean_win_diff"`
+	MaxWinDiff     float64      `json:"max_win_diff"`
+	MaxWinPosition string       `json:"max_win_position,omitempty"` // Where MaxWinDiff is
+	MeanEqDiff     float64      `json:"mean_equity_diff"`
+	MaxEqDiff      float64      `json:"max_equity_diff"`
+	Moves          int          `json:"moves"`
+	Disagreements  int          `json:"disagreements"`
+	Classes        []ClassStats `json:"classes"`
+	Results        []Result     `json:"results"`
+}
+
+// DisagreementRate returns the share of positions with dice where the best
+// moves differ, or 0 if there are none.
+func (r *Report) DisagreementRate() float64 {
+	if r.Moves == 0 {
+		return 0
+	}
+	return float64(r.Disagreements) / float64(r.Moves)
+}
+
+// Run compares the engine with ref at ply over positions. A position that
+// cannot be compared is recorded in the report and the run goes on, unless
+// the reference has gone (ErrGnubgExited): then the report so far is
+// returned with the error.
+func Run(e *engine.Engine, ref Reference, positions []Position, ply int) (*Report, error) {
+	report := &Report{Ply: ply}
+	for _, pos := range positions {
+		res, err := compareOne(e, ref, pos, ply)
+		if err != nil {
+			res.Err = err.Error()
+		}
+		report.Results = append(report.Results, res)
+		if errors.Is(err, ErrGnubgExited) {
+			report.summarize()
+			return report, err
+		}
+	}
+	report.summarize()
+	return report, nil
+}
+
+// compareOne compares the engine with ref on one position.
+func compareOne(e *engine.Engine, ref Reference, pos Position, ply int) (Result, error) {
+	res := Result{Position: pos.ID, Dice: pos.Dice}
+	board, err := positionid.BoardFromPositionID(pos.ID)
+	if err != nil {
+		return res, err
+	}
+	state := &engine.GameState{Board: engine.Board(board), CubeValue: 1, CubeOwner: -1}
+	res.Class = neuralnet.ClassifyPosition(neuralnet.Board(board)).String()
+
+	if res.Engine, err = e.EvaluatePlied(state, ply); err != nil {
+		return res, fmt.Errorf("engine: %w", err)
+	}
+	if res.Reference, err = ref.Evaluate(pos.ID); err != nil {
+		return res, fmt.Errorf("reference: %w", err)
+	}
+	res.WinDiff = math.Abs(res.Engine.WinProb - res.Reference.WinProb)
+	res.EqDiff = math.Abs(res.Engine.Equity - res.Reference.Equity)
+
+	if pos.Dice == [2]int{} {
+		return res, nil
+	}
+	opts := engine.DefaultEvalOptions()
+	opts.Plies = ply
+	analysis, err := e.AnalyzePositionWithOptions(state, pos.Dice, opts)
+	if err != nil {
+		return res, fmt.Errorf("engine: %w", err)
+	}
+	if res.ReferenceMove, err = ref.BestMove(pos.ID, pos.Dice); err != nil {
+		return res, fmt.Errorf("reference: %w", err)
+	}
+	if len(analysis.Moves) == 0 {
+		res.MovesAgree = res.ReferenceMove == ""
+		return res, nil
+	}
+	res.EngineMove = engine.FormatMove(analysis.BestMove)
+	if res.ReferenceMove == "" {
+		return res, nil
+	}
+	after, err := ApplyGnubgMove(state.Board, res.ReferenceMove, pos.Dice)
+	if err != nil {
+		return res, fmt.Errorf("reference: %w", err)
+	}
+	res.MovesAgree = engine.ApplyMove(state.Board, analysis.BestMove) == after
+	return res, nil
+}
+
+// summarize works out the totals and classes of r from its results.
+func (r *Report) summarize() {
+	classes := make(map[string]*ClassStats)
+	var winSum, eqSum float64
+	for _, res := range r.Results {
+		if res.Err != "" {
+			r.Failed++
+			continue
+		}
+		r.Positions++
+		winSum += res.WinDiff
+		eqSum += res.EqDiff
+		if res.WinDiff > r.MaxWinDiff || r.MaxWinPosition == "" {
+			r.MaxWinDiff, r.MaxWinPosition = res.WinDiff, res.Position
+		}
+		r.MaxEqDiff = max(r.MaxEqDiff, res.EqDiff)
+
+		c := classes[res.Class]
+		if c == nil {
+			c = &ClassStats{Class: res.Class}
+			classes[res.Class] = c
+		}
+		c.Positions++
+		c.MeanWinDiff += res.WinDiff
+		c.MaxWinDiff = max(c.MaxWinDiff, res.WinDiff)
+		if res.Dice != [2]int{} {
+			r.Moves++
+			c.Moves++
+			if !res.MovesAgree {
+				r.Disagreements++
+				c.Disagreements++
+			}
+		}
+	}
+	if r.Positions > 0 {
+		r.MeanWinDiff = winSum / float64(r.Positions)
+		r.MeanEqDiff = eqSum / float64(r.Positions)
+	}
+
+	r.Classes = r.Classes[:0]
+	for _, c := range classes {
+		c.MeanWinDiff /= float64(c.Positions)
+		r.Classes = append(r.Classes, *c)
+	}
+	sort.Slice(r.Classes, func(i, j int) bool { return r.Classes[i].Class < r.Classes[j].Class })
+}
+
+// WriteReport writes r as text: the totals, the breakdown by class, and
+// the positions that failed or where the best moves differ.
+func WriteReport(w io.Writer, r *Report) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Compared %d positions at %d ply", r.Positions, r.Ply)
+	if r.Failed > 0 {
+		fmt.Fprintf(&b, " (%d failed)", r.Failed)
+	}
+	b.WriteString("\n\n")
+	fmt.Fprintf(&b, "Win probability difference: mean %.4f, max %.4f", r.MeanWinDiff, r.MaxWinDiff)
+	if r.MaxWinPosition != "" {
+		fmt.Fprintf(&b, " (%s)", r.MaxWinPosition)
+	}
+	fmt.Fprintf(&b, "\nEquity difference:          mean %.4f, max %.4f\n", r.MeanEqDiff, r.MaxEqDiff)
+	if r.Moves > 0 {
+		fmt.Fprintf(&b, "Best move disagreements:    %d of %d (%.1f%%)\n", r.Disagreements, r.Moves, 100*r.DisagreementRate())
+	}
+
+	if len(r.Classes) > 0 {
+		fmt.Fprintf(&b, "\n%-16s %9s %9s %9s %9s\n", "Class", "Positions", "Mean win", "Max win", "Moves")
+		for _, c := range r.Classes {
+			moves := "-"
+			if c.Moves > 0 {
+				moves = fmt.Sprintf("%d/%d", c.Disagreements, c.Moves)
+			}
+			fmt.Fprintf(&b, "%-16s %9d %9.4f %9.4f %9s\n", c.Class, c.Positions, c.MeanWinDiff, c.MaxWinDiff, moves)
+		}
+	}
+
+	var disagree, failed []string
+	for _, res := range r.Results {
+		switch {
+		case res.Err != "":
+			failed = append(failed, fmt.Sprintf("  %s: %s", res.Position, res.Err))
+		case res.Dice != [2]int{} && !res.MovesAgree:
+			disagree = append(disagree, fmt.Sprintf("  %s %d%d: engine %s, reference %s",
+				res.Position, res.Dice[0], res.Dice[1], orNone(res.EngineMove), orNone(res.ReferenceMove)))
+		}
+	}
+	if len(disagree) > 0 {
+		b.WriteString("\nBest moves that differ:\n")
+		b.WriteString(strings.Join(disagree, "\n"))
+		b.WriteString("\n")
+	}
+	if len(failed) > 0 {
+		b.WriteString("\nFailed:\n")
+		b.WriteString(strings.Join(failed, "\n"))
+		b.WriteString("\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// orNone returns move, or "(none)" if there is no move.
+func orNone(move string) string {
+	if move == "" {
+		return "(none)"
+	}
+	return move
+}
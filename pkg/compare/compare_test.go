@@ -0,0 +1,361 @@
+package compare
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourusername/bgengine/internal/positionid"
+	"github.com/yourusername/bgengine/pkg/engine"
+)
+
+const startID = "4HPwATDgc/ABMA"
+
+// transcript returns the lines of a file in testdata.
+func transcript(t *testing.T, name string) []string {
+	t.Helper()
+	data, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+}
+
+func TestParseEval(t *testing.T) {
+	tests := []struct {
+		file string
+		ply  int
+		want [6]float64
+	}{
+		{"eval_2ply.txt", 2, [6]float64{0.530, 0.151, 0.009, 0.141, 0.008, 0.089}},
+		{"eval_2ply.txt", 1, [6]float64{0.515, 0.144, 0.008, 0.150, 0.009, 0.050}},
+		{"eval_2ply.txt", 0, [6]float64{0.524, 0.149, 0.009, 0.144, 0.009, 0.079}},
+		// No 3-ply row: the deepest there is
+		{"eval_2ply.txt", 3, [6]float64{0.530, 0.151, 0.009, 0.141, 0.008, 0.089}},
+		{"eval_localized.txt", 0, [6]float64{0.524, 0.149, 0.009, 0.144, 0.009, 0.079}},
+	}
+	for _, tt := range tests {
+		eval, err := ParseEval(transcript(t, tt.file), tt.ply)
+		if err != nil {
+			t.Errorf("%s at %d ply: %v", tt.file, tt.ply, err)
+			continue
+		}
+		got := [6]float64{eval.WinProb, eval.WinG, eval.WinBG, eval.LoseG, eval.LoseBG, eval.Equity}
+		for i := range got {
+			if math.Abs(got[i]-tt.want[i]) > 1e-9 {
+				t.Errorf("%s at %d ply = %v, want %v", tt.file, tt.ply, got, tt.want)
+				break
+			}
+		}
+	}
+
+	_, err := ParseEval([]string{"No game in progress (type `new game' to start one)."}, 0)
+	if !errors.Is(err, errNoEvaluation) || !strings.Contains(err.Error(), "No game in progress") {
+		t.Errorf("ParseEval of an error = %v, want errNoEvaluation quoting the output", err)
+	}
+}
+
+func TestParseHint(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		want  string
+	}{
+		{"hint", transcript(t, "hint_31.txt"), "8/5 6/5"},
+		{"chain and hits", []string{"    1. Cubeful 0-ply    bar/22* 13/7*/5              Eq.:  +0.412"}, "bar/22* 13/7*/5"},
+		{"translated", []string{"    1. Cubeful 1-Ply    8/4(2) 6/2(2)                Äq.:  +0,512"}, "8/4(2) 6/2(2)"},
+		{"no move", nil, ""},
+	}
+	for _, tt := range tests {
+		got, err := ParseHint(tt.lines)
+		if err != nil || got != tt.want {
+			t.Errorf("%s: ParseHint = %q, %v, want %q", tt.name, got, err, tt.want)
+		}
+	}
+	if _, err := ParseHint([]string{"    1. Cubeful 2-ply    ???"}); err == nil {
+		t.Error("ParseHint of a rank without a move succeeded")
+	}
+}
+
+func TestApplyGnubgMove(t *testing.T) {
+	start := engine.StartingPosition().Board
+	tests := []struct {
+		move string
+		dice [2]int
+		want string // The same play in the engine's notation
+	}{
+		{"8/5 6/5", [2]int{3, 1}, "8/5 6/5"},
+		{"24/18/14", [2]int{6, 4}, "24/18 18/14"},
+		{"13/10(2) 6/3(2)", [2]int{3, 3}, "13/10 13/10 6/3 6/3"},
+		{"24/23 13/8", [2]int{5, 1}, "24/23 13/8"},
+	}
+	for _, tt := range tests {
+		got, err := ApplyGnubgMove(start, tt.move, tt.dice)
+		if err != nil {
+			t.Errorf("ApplyGnubgMove(%q): %v", tt.move, err)
+			continue
+		}
+		m, _ := engine.ParseMove(tt.want)
+		if want := engine.ApplyMove(start, m); got != want {
+			t.Errorf("ApplyGnubgMove(%q) differs from %s", tt.move, tt.want)
+		}
+	}
+
+	// Entering and hitting: a checker on the bar against a blot on the
+	// opponent's 2 point (the mover's 23)
+	var board engine.Board
+	board[1][24], board[1][12] = 1, 2
+	board[0][1] = 1
+	if _, err := ApplyGnubgMove(board, "bar/23* 13/7", [2]int{6, 2}); err != nil {
+		t.Errorf("ApplyGnubgMove from the bar: %v", err)
+	}
+
+	for _, bad := range []string{"8/4 6/5", "8/5 6/5 6/5", "13/x"} {
+		if _, err := ApplyGnubgMove(start, bad, [2]int{3, 1}); err == nil {
+			t.Errorf("ApplyGnubgMove(%q) with 3-1 succeeded", bad)
+		}
+	}
+}
+
+func TestReadPositions(t *testing.T) {
+	input := "# Openings\n4HPwATDgc/ABMA 31\n4HPwATDgc/ABMA:cAkAAAAAAAAA 6-4\n\n4HPwATDgc/ABMA\n"
+	got, err := ReadPositions(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ReadPositions: %v", err)
+	}
+	want := []Position{{startID, [2]int{3, 1}}, {startID, [2]int{6, 4}}, {startID, [2]int{}}}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("ReadPositions = %v, want %v", got, want)
+	}
+
+	for _, bad := range []string{"nope 31", startID + " 71", startID + " 31 extra"} {
+		if _, err := ReadPositions(strings.NewReader(bad)); err == nil || !strings.HasPrefix(err.Error(), "line 1: ") {
+			t.Errorf("ReadPositions(%q) = %v, want an error on line 1", bad, err)
+		}
+	}
+}
+
+// fakeReference answers from fixed evaluations and moves.
+type fakeReference struct {
+	evals map[string]*engine.Evaluation
+	moves map[string]string
+	err   error
+}
+
+func (f *fakeReference) Evaluate(position string) (*engine.Evaluation, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	if eval, ok := f.evals[position]; ok {
+		return eval, nil
+	}
+	return nil, errors.New("unknown position")
+}
+
+func (f *fakeReference) BestMove(position string, dice [2]int) (string, error) {
+	return f.moves[fmt.Sprintf("%s %d%d", position, dice[0], dice[1])], nil
+}
+
+func TestRun(t *testing.T) {
+	e, err := engine.NewEngine(engine.EngineOptions{})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	state := engine.StartingPosition()
+	eval, err := e.Evaluate(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := engine.DefaultEvalOptions()
+	best31, err := e.AnalyzePositionWithOptions(state, [2]int{3, 1}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A race with the mover well ahead
+	var race engine.Board
+	race[1][0], race[1][1] = 8, 7
+	race[0][0], race[0][5] = 8, 7
+	raceID := positionid.PositionID(positionid.Board(race))
+	// The same race with the other side on roll, which the reference
+	// does not know
+	unknownID := positionid.PositionID(positionid.Board{race[1], race[0]})
+
+	ref := &fakeReference{
+		evals: map[string]*engine.Evaluation{
+			startID: {WinProb: eval.WinProb + 0.02, Equity: eval.Equity - 0.05},
+			raceID:  {WinProb: 0.9, Equity: 0.8},
+		},
+		moves: map[string]string{
+			startID + " 31": engine.FormatMove(best31.BestMove),
+			startID + " 64": "24/18 13/9",
+		},
+	}
+	positions := []Position{
+		{ID: startID, Dice: [2]int{3, 1}},
+		{ID: startID, Dice: [2]int{6, 4}},
+		{ID: raceID},
+		{ID: unknownID},
+	}
+
+	report, err := Run(e, ref, positions, 0)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if report.Positions != 3 || report.Failed != 1 {
+		t.Fatalf("compared %d, failed %d, want 3 and 1", report.Positions, report.Failed)
+	}
+	if r := report.Results[0]; math.Abs(r.WinDiff-0.02) > 1e-9 || math.Abs(r.EqDiff-0.05) > 1e-9 || !r.MovesAgree {
+		t.Errorf("starting position 31: win diff %.4f, equity diff %.4f, agree %v", r.WinDiff, r.EqDiff, r.MovesAgree)
+	}
+	// Whatever the engine plays with 64, it is not both running and
+	// splitting like the reference's move here unless they agree
+	r64 := report.Results[1]
+	if r64.MovesAgree != (r64.EngineMove == "24/18 13/9") {
+		t.Errorf("64: engine %s, reference %s, agree %v", r64.EngineMove, r64.ReferenceMove, r64.MovesAgree)
+	}
+	if report.Moves != 2 || report.Disagreements != map[bool]int{true: 0, false: 1}[r64.MovesAgree] {
+		t.Errorf("moves %d, disagreements %d", report.Moves, report.Disagreements)
+	}
+	raceDiff := math.Abs(report.Results[2].Engine.WinProb - 0.9)
+	if want := max(0.02, raceDiff); math.Abs(report.MaxWinDiff-want) > 1e-9 {
+		t.Errorf("max win diff %.4f, want %.4f", report.MaxWinDiff, want)
+	}
+	if want := (0.04 + raceDiff) / 3; math.Abs(report.MeanWinDiff-want) > 1e-9 {
+		t.Errorf("mean win diff %.4f, want %.4f", report.MeanWinDiff, want)
+	}
+
+	classes := make(map[string]ClassStats)
+	for _, c := range report.Classes {
+		classes[c.Class] = c
+	}
+	if c := classes["contact"]; c.Positions != 2 || c.Moves != 2 {
+		t.Errorf("contact class: %+v", c)
+	}
+	if len(classes) != 2 {
+		t.Errorf("classes %v, want contact and one for the race", report.Classes)
+	}
+
+	var b strings.Builder
+	if err := WriteReport(&b, report); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Compared 3 positions at 0 ply (1 failed)", "Best move disagreements:", unknownID + ": reference: unknown position"} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("report lacks %q:\n%s", want, b.String())
+		}
+	}
+
+	// A reference that has gone stops the run
+	ref.err = ErrGnubgExited
+	report, err = Run(e, ref, positions, 0)
+	if !errors.Is(err, ErrGnubgExited) || len(report.Results) != 1 {
+		t.Errorf("Run with gnubg gone: %v after %d results", err, len(report.Results))
+	}
+}
+
+// TestFakeGnubg is not a test: run as a subprocess with FAKE_GNUBG=1 it
+// plays gnubg in tty mode, answering from the transcripts in testdata.
+func TestFakeGnubg(t *testing.T) {
+	if os.Getenv("FAKE_GNUBG") != "1" {
+		t.Skip("run by TestGnubg")
+	}
+	fmt.Println("GNU Backgammon 1.07.001  Copyright (C) 1999--2022 ...")
+	quitting := false
+	sc := bufio.NewScanner(os.Stdin)
+	for sc.Scan() {
+		cmd := sc.Text()
+		switch {
+		case quitting:
+			os.Exit(0)
+		case cmd == "quit":
+			fmt.Print("Are you sure you want to exit and abort the game in progress? ")
+			quitting = true
+		case cmd == "eval":
+			fmt.Println(strings.Join(transcript(t, "eval_2ply.txt"), "\n"))
+		case cmd == "hint 1":
+			fmt.Println(strings.Join(transcript(t, "hint_31.txt"), "\n"))
+		case cmd == "set board hang":
+			time.Sleep(time.Hour)
+		case strings.HasPrefix(cmd, "set "), cmd == "new game":
+		default:
+			fmt.Fprintf(os.Stderr, "Unknown keyword `%s'.\n", cmd)
+		}
+	}
+	os.Exit(0)
+}
+
+func TestGnubg(t *testing.T) {
+	g, err := StartGnubg(GnubgConfig{
+		Path: os.Args[0],
+		Args: []string{"-test.run=^TestFakeGnubg$"},
+		Env:  []string{"FAKE_GNUBG=1"},
+		Ply:  1,
+	})
+	if err != nil {
+		t.Fatalf("StartGnubg: %v", err)
+	}
+
+	eval, err := g.Evaluate(startID)
+	if err != nil || eval.WinProb != 0.515 {
+		t.Errorf("Evaluate = %+v, %v, want the 1-ply row", eval, err)
+	}
+	move, err := g.BestMove(startID, [2]int{3, 1})
+	if err != nil || move != "8/5 6/5" {
+		t.Errorf("BestMove = %q, %v", move, err)
+	}
+	if err := g.Close(); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+	if _, err := g.Evaluate(startID); !errors.Is(err, ErrGnubgExited) {
+		t.Errorf("Evaluate after Close = %v, want ErrGnubgExited", err)
+	}
+}
+
+func TestGnubgTimeout(t *testing.T) {
+	g, err := StartGnubg(GnubgConfig{
+		Path:    os.Args[0],
+		Args:    []string{"-test.run=^TestFakeGnubg$"},
+		Env:     []string{"FAKE_GNUBG=1"},
+		Timeout: 200 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("StartGnubg: %v", err)
+	}
+	defer g.Close()
+	if _, err := g.Evaluate("hang"); err == nil || !strings.Contains(err.Error(), "did not answer") {
+		t.Errorf("Evaluate of a hung gnubg = %v", err)
+	}
+}
+
+// TestGnubgLive compares the starting position with a real gnubg, where
+// one is installed.
+func TestGnubgLive(t *testing.T) {
+	path, err := exec.LookPath("gnubg")
+	if err != nil {
+		t.Skip("gnubg not installed")
+	}
+	g, err := StartGnubg(GnubgConfig{Path: path})
+	if err != nil {
+		t.Fatalf("StartGnubg: %v", err)
+	}
+	defer g.Close()
+
+	eval, err := g.Evaluate(startID)
+	if err != nil {
+		t.Fatalf("Evaluate: %v", err)
+	}
+	if math.Abs(eval.WinProb-0.524) > 0.01 {
+		t.Errorf("starting position wins %.3f at 0 ply, want about 0.524", eval.WinProb)
+	}
+	move, err := g.BestMove(startID, [2]int{3, 1})
+	if err != nil || move != "8/5 6/5" {
+		t.Errorf("BestMove with 31 = %q, %v, want 8/5 6/5", move, err)
+	}
+}
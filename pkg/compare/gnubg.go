@@ -0,0 +1,199 @@
+// Package compare evaluates positions with the engine and with GNU
+// Backgammon and reports where the two diverge: winning chances, equities
+// and best moves, overall and by position class.
+package compare
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/yourusername/bgengine/pkg/engine"
+)
+
+// DefaultGnubgArgs start gnubg without a GUI, sound or its rc file, so that
+// saved settings cannot change what it prints.
+var DefaultGnubgArgs = []string{"--tty", "--quiet", "--no-rc"}
+
+// DefaultTimeout bounds each exchange with gnubg: deep evaluations of
+// complex positions take a while.
+const DefaultTimeout = 2 * time.Minute
+
+// ErrGnubgExited is returned once the gnubg process has gone.
+var ErrGnubgExited = errors.New("gnubg exited")
+
+// GnubgConfig says how to run gnubg.
+type GnubgConfig struct {
+	Path    string        // gnubg binary
+	Args    []string      // Arguments (nil = DefaultGnubgArgs)
+	Env     []string      // Extra environment variables
+	Ply     int           // Evaluation depth, as the engine counts it: 0 = static
+	Timeout time.Duration // Bound on each exchange (0 = DefaultTimeout)
+}
+
+// Gnubg drives a gnubg process in tty mode. Each exchange sends commands
+// followed by a command gnubg does not know, whose error message quotes it
+// back: whatever gnubg prints before the quote is the commands' output.
+// This needs nothing of gnubg's prompt or the language it speaks.
+//
+// A Gnubg is not safe for concurrent use.
+type Gnubg struct {
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	lines   chan string
+	seq     int
+	ply     int
+	timeout time.Duration
+}
+
+// syncPrefix starts the command that ends each exchange.
+const syncPrefix = "bgengine-sync-"
+
+// StartGnubg starts gnubg and sets it up to evaluate positions at cfg.Ply
+// with both players human, so that it never moves by itself.
+func StartGnubg(cfg GnubgConfig) (*Gnubg, error) {
+	args := cfg.Args
+	if args == nil {
+		args = DefaultGnubgArgs
+	}
+	cmd := exec.Command(cfg.Path, args...)
+	// Untranslated output where gnubg honors the locale; the parser copes
+	// with translations anyway
+	cmd.Env = append(append(os.Environ(), "LC_ALL=C", "LANGUAGE=C"), cfg.Env...)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	// One pipe for both streams keeps error messages, the end of each
+	// exchange among them, in order with the output
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stdout, cmd.Stderr = w, w
+	if err := cmd.Start(); err != nil {
+		r.Close()
+		w.Close()
+		return nil, fmt.Errorf("starting gnubg: %w", err)
+	}
+	w.Close()
+
+	g := &Gnubg{
+		cmd:     cmd,
+		stdin:   stdin,
+		lines:   make(chan string, 256),
+		ply:     cfg.Ply,
+		timeout: cfg.Timeout,
+	}
+	if g.timeout <= 0 {
+		g.timeout = DefaultTimeout
+	}
+	go func() {
+		defer close(g.lines)
+		defer r.Close()
+		sc := bufio.NewScanner(r)
+		sc.Buffer(make([]byte, 64*1024), 1024*1024)
+		for sc.Scan() {
+			g.lines <- sc.Text()
+		}
+	}()
+
+	setup := []string{
+		"set player 0 human",
+		"set player 1 human",
+		"set display off",
+		"new game",
+		fmt.Sprintf("set evaluation chequerplay evaluation plies %d", cfg.Ply),
+		fmt.Sprintf("set evaluation cubedecision evaluation plies %d", cfg.Ply),
+	}
+	// The first exchange also swallows gnubg's banner
+	if _, err := g.run(setup...); err != nil {
+		g.Close()
+		return nil, err
+	}
+	return g, nil
+}
+
+// Evaluate returns gnubg's cubeless evaluation of the position with the
+// given ID, for the player on roll.
+func (g *Gnubg) Evaluate(position string) (*engine.Evaluation, error) {
+	out, err := g.run("set board "+position, "eval")
+	if err != nil {
+		return nil, err
+	}
+	return ParseEval(out, g.ply)
+}
+
+// BestMove returns gnubg's best move in the position with the given ID for
+// dice, in gnubg's notation, or "" if there is no legal move.
+func (g *Gnubg) BestMove(position string, dice [2]int) (string, error) {
+	out, err := g.run("set board "+position, fmt.Sprintf("set dice %d%d", dice[0], dice[1]), "hint 1")
+	if err != nil {
+		return "", err
+	}
+	return ParseHint(out)
+}
+
+// Close quits gnubg, killing it if it does not go.
+func (g *Gnubg) Close() error {
+	// gnubg asks before abandoning the game in progress
+	io.WriteString(g.stdin, "quit\ny\n")
+	g.stdin.Close()
+
+	done := make(chan error, 1)
+	go func() { done <- g.cmd.Wait() }()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(5 * time.Second):
+		g.cmd.Process.Kill()
+		return <-done
+	}
+}
+
+// run sends cmds and returns what gnubg printed in answer.
+func (g *Gnubg) run(cmds ...string) ([]string, error) {
+	g.seq++
+	marker := fmt.Sprintf("%s%d-end", syncPrefix, g.seq)
+
+	var b strings.Builder
+	for _, c := range cmds {
+		b.WriteString(c)
+		b.WriteByte('\n')
+	}
+	b.WriteString(marker)
+	b.WriteByte('\n')
+	if _, err := io.WriteString(g.stdin, b.String()); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrGnubgExited, err)
+	}
+
+	timer := time.NewTimer(g.timeout)
+	defer timer.Stop()
+	var out []string
+	for {
+		select {
+		case line, ok := <-g.lines:
+			if !ok {
+				return out, ErrGnubgExited
+			}
+			if strings.Contains(line, marker) {
+				return out, nil
+			}
+			if strings.Contains(line, syncPrefix) {
+				// The end of an exchange that timed out: what came
+				// before it was its output
+				out = out[:0]
+				continue
+			}
+			out = append(out, line)
+		case <-timer.C:
+			return out, fmt.Errorf("gnubg did not answer %q within %v", strings.Join(cmds, "; "), g.timeout)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package compare
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/yourusername/bgengine/pkg/engine"
+)
+
+// The parsers key on the shape of gnubg's output rather than its words,
+// which are translated: an evaluation row is a label and then five
+// probabilities and an equity, a move hint a rank and then a move.
+// Probabilities may be printed as fractions or percentages, and numbers
+// with a decimal point or comma.
+
+var (
+	// number matches an integer or a decimal, with an optional sign and
+	// percent sign
+	number = regexp.MustCompile(`[-+]?\d+(?:[.,]\d+)?%?`)
+	// rankLine matches the first move of a hint
+	rankLine = regexp.MustCompile(`^\s*1\.\s+(.*)$`)
+	// moveToken matches one part of a move in gnubg's notation, such as
+	// "bar/22", "13/7*/5", "6/off" or "8/5(2)"
+	moveToken = regexp.MustCompile(`(?i)^(bar|\d{1,2})\*?(/(\d{1,2}|off)\*?)+(\(\d\))?$`)
+)
+
+// errNoEvaluation is returned for output without an evaluation row.
+var errNoEvaluation = errors.New("no evaluation in gnubg's output")
+
+// evalRow is one row of gnubg's evaluation table.
+type evalRow struct {
+	ply    int // From the label; 0 for "static" or a label without a number
+	values []float64
+}
+
+// ParseEval reads the evaluation at ply from the output of gnubg's eval
+// command. gnubg prints a row for the static evaluation and for each ply
+// up to the one it was set to; without a row for ply the deepest is used.
+func ParseEval(lines []string, ply int) (*engine.Evaluation, error) {
+	var rows []evalRow
+	for _, line := range lines {
+		if row, ok := parseEvalRow(line); ok {
+			rows = append(rows, row)
+		}
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%w%s", errNoEvaluation, excerpt(lines))
+	}
+
+	row := rows[len(rows)-1]
+	for _, r := range rows {
+		if r.ply == ply {
+			row = r
+		}
+	}
+	v := row.values
+	return &engine.Evaluation{
+		WinProb: v[0],
+		WinG:    v[1],
+		WinBG:   v[2],
+		LoseG:   v[3],
+		LoseBG:  v[4],
+		Equity:  v[5],
+	}, nil
+}
+
+// parseEvalRow parses a row of five probabilities and an equity. Whole
+// numbers before the first decimal belong to the label, as in "2 ply:".
+func parseEvalRow(line string) (evalRow, bool) {
+	var row evalRow
+	label := true
+	for _, tok := range number.FindAllString(line, -1) {
+		decimal := strings.ContainsAny(tok, ".,%")
+		if label && !decimal {
+			if n, err := strconv.Atoi(strings.TrimLeft(tok, "+-")); err == nil {
+				row.ply = n
+			}
+			continue
+		}
+		label = false
+		v, ok := parseNumber(tok)
+		if !ok {
+			return row, false
+		}
+		row.values = append(row.values, v)
+	}
+	if len(row.values) < 6 {
+		return row, false
+	}
+	for _, p := range row.values[:5] {
+		if p < 0 || p > 1 {
+			return row, false
+		}
+	}
+	return row, true
+}
+
+// parseNumber parses a number as gnubg prints it, a percentage as a
+// fraction.
+func parseNumber(tok string) (float64, bool) {
+	pct := strings.HasSuffix(tok, "%")
+	tok = strings.TrimSuffix(tok, "%")
+	v, err := strconv.ParseFloat(strings.Replace(tok, ",", ".", 1), 64)
+	if err != nil {
+		return 0, false
+	}
+	if pct {
+		v /= 100
+	}
+	return v, true
+}
+
+// ParseHint reads the best move from the output of gnubg's hint command
+// with dice set: the move ranked first, in gnubg's notation. It returns ""
+// for output without a ranked move, as when there is no legal move.
+func ParseHint(lines []string) (string, error) {
+	for _, line := range lines {
+		m := rankLine.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		// The move is the run of move tokens after the evaluator's name
+		var move []string
+		for _, tok := range strings.Fields(m[1]) {
+			if moveToken.MatchString(tok) {
+				move = append(move, tok)
+			} else if len(move) > 0 {
+				break
+			}
+		}
+		if len(move) == 0 {
+			return "", fmt.Errorf("no move in gnubg's hint %q", strings.TrimSpace(line))
+		}
+		return strings.Join(move, " "), nil
+	}
+	return "", nil
+}
+
+// ApplyGnubgMove plays a move in gnubg's notation on board, from the side
+// on roll, and checks that it is a legal play of dice. gnubg writes
+// several checkers moving alike with a count ("8/5(2)") and a checker
+// moving on with both dice as a chain ("13/7*/5"); hits are marked with *.
+func ApplyGnubgMove(board engine.Board, notation string, dice [2]int) (engine.Board, error) {
+	move := engine.Move{From: [4]int8{-1, -1, -1, -1}, To: [4]int8{-1, -1, -1, -1}}
+	n := 0
+	for _, tok := range strings.Fields(strings.ToLower(notation)) {
+		if !moveToken.MatchString(tok) {
+			return board, fmt.Errorf("bad move %q", notation)
+		}
+		count := 1
+		if i := strings.IndexByte(tok, '('); i >= 0 {
+			count, _ = strconv.Atoi(tok[i+1 : len(tok)-1])
+			tok = tok[:i]
+		}
+		points := strings.Split(strings.ReplaceAll(tok, "*", ""), "/")
+		for c := 0; c < count; c++ {
+			for i := 1; i < len(points); i++ {
+				if n == len(move.From) {
+					return board, fmt.Errorf("move %q has more than four parts", notation)
+				}
+				move.From[n], move.To[n] = gnubgPoint(points[i-1]), gnubgPoint(points[i])
+				n++
+			}
+		}
+	}
+
+	after := engine.ApplyMove(board, move)
+	for _, m := range engine.GenerateMoves(board, dice[0], dice[1]).Moves {
+		if engine.ApplyMove(board, m) == after {
+			return after, nil
+		}
+	}
+	return board, fmt.Errorf("%q is not a legal play of %d-%d", notation, dice[0], dice[1])
+}
+
+// gnubgPoint converts a point of gnubg's notation to a board index.
+func gnubgPoint(s string) int8 {
+	switch s {
+	case "bar":
+		return 24
+	case "off":
+		return -1
+	}
+	n, _ := strconv.Atoi(s)
+	return int8(n - 1)
+}
+
+// excerpt quotes the start of gnubg's output for an error message.
+func excerpt(lines []string) string {
+	var kept []string
+	for _, l := range lines {
+		if l = strings.TrimSpace(l); l != "" {
+			kept = append(kept, l)
+		}
+		if len(kept) == 3 {
+			break
+		}
+	}
+	if len(kept) == 0 {
+		return " (no output)"
+	}
+	return fmt.Sprintf(": %q", strings.Join(kept, " | "))
+}
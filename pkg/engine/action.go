@@ -0,0 +1,40 @@
+package engine
+
+// BestAction is what the player on roll should do, from Engine.BestAction.
+type BestAction struct {
+	Cube *CubeAnalysis // Cube decision before the roll
+
+	// Double is set before the roll if the player should double. After the
+	// roll MissedDouble is set instead if the player should have doubled
+	// before rolling.
+	Double       bool
+	MissedDouble bool
+
+	Moves *AnalysisResult // Moves ranked for the dice (nil before the roll)
+}
+
+// BestAction recommends the action of the player on roll. Cube decisions
+// come before the roll: with dice nil it is whether to double or roll, with
+// dice the ranked moves for them, flagging a double that should have been
+// given before the roll. The cube is analyzed as AnalyzeCube does, so a cube
+// the opponent owns, a dead cube and the Crawford game never call for a
+// double.
+func (e *Engine) BestAction(state *GameState, dice *[2]int) (*BestAction, error) {
+	cube, err := e.AnalyzeCube(state)
+	if err != nil {
+		return nil, err
+	}
+	action := &BestAction{Cube: cube}
+	double := cube.Decision.Action == Double
+
+	if dice == nil {
+		action.Double = double
+		return action, nil
+	}
+
+	action.MissedDouble = double
+	if action.Moves, err = e.AnalyzePosition(state, *dice); err != nil {
+		return nil, err
+	}
+	return action, nil
+}
@@ -0,0 +1,57 @@
+package engine
+
+import "testing"
+
+func TestBestAction(t *testing.T) {
+	e, err := NewEngine(EngineOptions{SkipWarmup: true})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	// A 10 pip lead in a 105 pip race is a double with a centered cube
+	centered := &GameState{Board: raceLadder(10), CubeValue: 1, CubeOwner: -1}
+	action, err := e.BestAction(centered, nil)
+	if err != nil {
+		t.Fatalf("BestAction: %v", err)
+	}
+	if !action.Double || action.MissedDouble || action.Moves != nil || action.Cube == nil {
+		t.Errorf("centered cube before the roll: %+v", action)
+	}
+
+	// The player cannot double a cube the opponent owns, or in the Crawford
+	// game
+	for name, state := range map[string]*GameState{
+		"opponent's cube": {Board: raceLadder(10), CubeValue: 2, CubeOwner: 1},
+		"crawford":        {Board: raceLadder(10), CubeValue: 1, CubeOwner: -1, MatchLength: 5, Score: [2]int{4, 2}, Crawford: true},
+	} {
+		action, err := e.BestAction(state, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if action.Double || action.Cube.Decision.Action == Double {
+			t.Errorf("%s: %+v, want no double", name, action)
+		}
+	}
+
+	// After the roll the moves are ranked and the double flagged as missed
+	action, err = e.BestAction(centered, &[2]int{6, 5})
+	if err != nil {
+		t.Fatalf("BestAction with dice: %v", err)
+	}
+	if action.Double || !action.MissedDouble {
+		t.Errorf("after the roll: Double %v, MissedDouble %v", action.Double, action.MissedDouble)
+	}
+	if action.Moves == nil || len(action.Moves.Moves) == 0 {
+		t.Fatalf("after the roll: no moves")
+	}
+	want, _ := e.AnalyzePosition(centered, [2]int{6, 5})
+	if action.Moves.BestMove != want.BestMove {
+		t.Errorf("best move %v, want %v", action.Moves.BestMove, want.BestMove)
+	}
+
+	// No double to miss a pip behind
+	behind := &GameState{Board: raceLadder(-1), CubeValue: 1, CubeOwner: -1}
+	if action, err := e.BestAction(behind, &[2]int{3, 1}); err != nil || action.MissedDouble || action.Moves == nil {
+		t.Errorf("behind: %+v, %v", action, err)
+	}
+}
@@ -1,16 +1,30 @@
 package engine
 
 import (
+	"errors"
+	"math/bits"
 	"sort"
+	"time"
 
+	"github.com/yourusername/bgengine/internal/neuralnet"
 	"github.com/yourusername/bgengine/internal/positionid"
 )
 
 // MoveWithEval is a move together with its evaluation
 type MoveWithEval struct {
-	Move   Move
-	Eval   *Evaluation
-	Equity float64 // Cached for sorting
+	Move          Move
+	Eval          *Evaluation // Mover's evaluation of the position after the move; never nil in analysis results
+	Equity        float64     // Cached for sorting: Eval.Equity, or CubefulEquity with EvalOptions.Cubeful
+	CubefulEquity float64     // Cubeful equity of the mover after the move (see EvaluationFull)
+	MWC           float64     // Mover's match winning chance after the move (match play only)
+	PositionID    string      // Position after the move, opponent on roll (set in MoveSkillAnalysis.TopMoves)
+	Ply           int         // Depth the move was evaluated at
+	Tags          []MoveTag   // What the move does (see ClassifyMove)
+	Class         string      // Class of the position after the move, as in Inspection.Class
+
+	// PV is the principal variation of the opponent's rolls after the
+	// move, with EvalOptions.PV and lookahead
+	PV *PrincipalVariation
 }
 
 // AnalysisResult contains the result of move analysis
@@ -24,92 +38,400 @@ type AnalysisResult struct {
 // AnalyzePosition generates all legal moves, evaluates them, and returns ranked results
 // dice should be [2]int with values 1-6
 func (e *Engine) AnalyzePosition(state *GameState, dice [2]int) (*AnalysisResult, error) {
-	ml := GenerateMoves(state.Board, dice[0], dice[1])
+	moves, numMoves, err := e.rankMoves(state, dice, 0)
+	if err != nil {
+		return nil, err
+	}
+	result := &AnalysisResult{
+		Moves:    moves,
+		NumMoves: numMoves,
+	}
 
-	if len(ml.Moves) == 0 {
-		return &AnalysisResult{
-			Moves:    nil,
-			NumMoves: 0,
-		}, nil
+	// Set best move
+	if len(result.Moves) > 0 {
+		result.BestMove = result.Moves[0].Move
+		result.BestEquity = result.Moves[0].Equity
 	}
 
-	result := &AnalysisResult{
-		Moves:    make([]MoveWithEval, len(ml.Moves)),
-		NumMoves: len(ml.Moves),
+	return result, nil
+}
+
+// rankScratch is the storage moves are ranked in: the legal moves, the
+// mover's evaluation after each, and the order they rank in. Engines pool
+// them, so ranking allocates only the MoveWithEvals it returns.
+type rankScratch struct {
+	ml    MoveList
+	evals []Evaluation
+	order []int
+}
+
+func (s *rankScratch) Len() int { return len(s.order) }
+func (s *rankScratch) Less(i, j int) bool {
+	return s.evals[s.order[i]].Equity > s.evals[s.order[j]].Equity
+}
+func (s *rankScratch) Swap(i, j int) { s.order[i], s.order[j] = s.order[j], s.order[i] }
+
+// rankMoves evaluates every legal move at 0 plies and returns the best n,
+// or all of them if n <= 0, best first, with the number of legal moves.
+// Only the moves returned are turned into MoveWithEvals: the others are
+// ranked by their cubeless equity in a pooled rankScratch.
+func (e *Engine) rankMoves(state *GameState, dice [2]int, n int) ([]MoveWithEval, int, error) {
+	t, err := e.metTable(state.MET)
+	if err != nil {
+		return nil, 0, err
 	}
+	s := e.rankPool.Get().(*rankScratch)
+	defer e.rankPool.Put(s)
+	GenerateMovesInto(&s.ml, state.Board, dice[0], dice[1])
 
-	// Evaluate each move
-	for i, m := range ml.Moves {
-		// Apply the move to get the resulting board
-		resultBoard := ApplyMove(state.Board, m)
-
-		// Swap sides for evaluation (opponent's perspective after our move)
-		swappedBoard := swapBoard(resultBoard)
-
-		// Create state for evaluation
-		evalState := &GameState{
-			Board:       swappedBoard,
-			Turn:        1 - state.Turn,
-			CubeValue:   state.CubeValue,
-			CubeOwner:   state.CubeOwner,
-			MatchLength: state.MatchLength,
-			Score:       state.Score,
-			Crawford:    state.Crawford,
-		}
+	moves := s.ml.Moves
+	if len(moves) == 0 {
+		return nil, 0, nil
+	}
+	s.evals = s.evals[:0]
+	s.order = s.order[:0]
 
-		// Evaluate the position from opponent's perspective
-		eval, err := e.Evaluate(evalState)
-		if err != nil {
+	// Evaluate each move
+	for i, m := range moves {
+		// Evaluate the position after the move from the opponent's perspective
+		after := afterMove(state, m)
+		var eval Evaluation
+		if err := e.evaluateInto(&eval, &after, nil); err != nil {
 			// On error, use default values
-			eval = &Evaluation{
+			eval = Evaluation{
 				WinProb: 0.5,
 				Equity:  0.0,
 			}
 		}
 
 		// Invert the evaluation to get it from our perspective
-		inverted := invertEvaluation(eval)
+		s.evals = append(s.evals, eval.inverted())
+		s.order = append(s.order, i)
+	}
 
-		result.Moves[i] = MoveWithEval{
-			Move:   m,
-			Eval:   inverted,
-			Equity: inverted.Equity,
+	// Sort by equity (best first)
+	sort.Sort(s)
+
+	if n <= 0 || n > len(moves) {
+		n = len(moves)
+	}
+	ranked := make([]MoveWithEval, n)
+	evals := make([]Evaluation, n)
+	for k, i := range s.order[:n] {
+		evals[k] = s.evals[i]
+		ranked[k] = MoveWithEval{
+			Move: moves[i],
+			Eval: &evals[k],
+			Tags: ClassifyMove(state.Board, moves[i]),
 		}
+		e.setMoveEquities(state, &ranked[k], DefaultEvalOptions(), t)
 	}
+	return ranked, len(moves), nil
+}
 
-	// Sort by equity (best first)
-	sort.Slice(result.Moves, func(i, j int) bool {
-		return result.Moves[i].Equity > result.Moves[j].Equity
-	})
+// BestMove finds the best move for a position with the given dice roll without
+// ranking the full move list. When opts.UsePrune is set, candidates are screened
+// with the pruning nets and only the survivors are evaluated with the full nets
+// at opts.Plies, with opts.Noise added. Returns the best move and its equity
+// from the mover's perspective.
+// If there are no legal moves the returned move has no sub-moves (From[0] == -1).
+func (e *Engine) BestMove(state *GameState, dice [2]int, opts EvalOptions) (Move, float64, error) {
+	t := opts.Timing
+	defer t.total(t.start())
 
-	// Set best move
-	if len(result.Moves) > 0 {
-		result.BestMove = result.Moves[0].Move
-		result.BestEquity = result.Moves[0].Equity
+	ml := e.moveListPool.Get().(*MoveList)
+	defer e.moveListPool.Put(ml)
+	start := t.start()
+	GenerateMovesInto(ml, state.Board, dice[0], dice[1])
+	if t != nil {
+		t.MoveGen += time.Since(start)
 	}
+	moves := ml.Moves
 
-	return result, nil
+	if len(moves) == 0 {
+		return noMove, 0, nil
+	}
+
+	// Select survivors without sorting: keep the top-scoring indices in a fixed array
+	var survivors [MaxPruneMoves]int
+	n := e.pruneCandidates(state, moves, opts, &survivors)
+
+	bestIdx := -1
+	bestEquity := 0.0
+	for k := 0; k < n; k++ {
+		idx := k
+		if n < len(moves) {
+			idx = survivors[k]
+		}
+
+		equity, err := e.moveEquity(state, moves[idx], opts)
+		if err != nil {
+			return noMove, 0, err
+		}
+
+		// Strict comparison keeps the first candidate on ties
+		if bestIdx < 0 || equity > bestEquity {
+			bestIdx = idx
+			bestEquity = equity
+		}
+	}
+
+	return moves[bestIdx], bestEquity, nil
+}
+
+// generateMovesTimed is GenerateMoves adding the time to t, if not nil.
+func generateMovesTimed(board Board, dice [2]int, t *Timing) *MoveList {
+	start := t.start()
+	ml := GenerateMoves(board, dice[0], dice[1])
+	if t != nil {
+		t.MoveGen += time.Since(start)
+	}
+	return ml
+}
+
+// noMove is returned when there is no legal move to play
+var noMove = Move{
+	From: [4]int8{-1, -1, -1, -1},
+	To:   [4]int8{-1, -1, -1, -1},
+}
+
+// pruneCandidates fills survivors with the indices of the moves that should be
+// fully evaluated and returns how many there are. If no pruning applies it
+// returns len(moves) and survivors is left untouched.
+func (e *Engine) pruneCandidates(state *GameState, moves []Move, opts EvalOptions, survivors *[MaxPruneMoves]int) int {
+	if !opts.UsePrune || len(moves) <= MinPruneMoves {
+		return len(moves)
+	}
+	if e.pContact == nil && e.pRace == nil && e.pCrashed == nil {
+		return len(moves)
+	}
+
+	// Same survivor count as pruneMoves
+	numToKeep := MinPruneMoves + bits.Len(uint(len(moves))) - 1
+	if numToKeep > MaxPruneMoves {
+		numToKeep = MaxPruneMoves
+	}
+	if numToKeep >= len(moves) {
+		return len(moves)
+	}
+
+	// Insertion into a bounded array ordered by descending score
+	var scores [MaxPruneMoves]float32
+	kept := 0
+	for i, m := range moves {
+		score := e.scoreMoveForPruning(state, m, opts.Timing)
+		if kept == numToKeep && score <= scores[kept-1] {
+			continue
+		}
+		pos := kept
+		if kept < numToKeep {
+			kept++
+		} else {
+			pos = kept - 1
+		}
+		for pos > 0 && scores[pos-1] < score {
+			scores[pos] = scores[pos-1]
+			survivors[pos] = survivors[pos-1]
+			pos--
+		}
+		scores[pos] = score
+		survivors[pos] = i
+	}
+
+	return kept
+}
+
+// moveEquity evaluates a single candidate move and returns its equity from the
+// mover's perspective. At 0 plies no Evaluation is allocated.
+func (e *Engine) moveEquity(state *GameState, m Move, opts EvalOptions) (float64, error) {
+	swappedBoard := swapBoard(ApplyMove(state.Board, m))
+
+	if opts.Plies <= 0 {
+		output, err := e.evaluateOutput(neuralnet.Board(swappedBoard), swapTotals(state.checkerTotals()), opts.Timing)
+		if err != nil {
+			return 0, err
+		}
+		return -outputEquity(output) + noiseEquity(moveNoise(state, m, opts)), nil
+	}
+
+	evalState := &GameState{
+		Board:         swappedBoard,
+		Turn:          1 - state.Turn,
+		CubeValue:     state.CubeValue,
+		CubeOwner:     state.CubeOwner,
+		MatchLength:   state.MatchLength,
+		Score:         state.Score,
+		Crawford:      state.Crawford,
+		TotalCheckers: swapTotals(state.TotalCheckers),
+	}
+	eval, err := e.evaluateNPly(evalState, opts.Plies, opts.UsePrune, time.Time{}, opts.Timing)
+	if err != nil {
+		return 0, err
+	}
+	return -eval.Equity + noiseEquity(moveNoise(state, m, opts)), nil
 }
 
-// BestMove finds the best move for a position with the given dice roll
-// Returns the best move and its evaluation
-func (e *Engine) BestMove(state *GameState, dice [2]int) (Move, *Evaluation, error) {
-	analysis, err := e.AnalyzePosition(state, dice)
+// AnalyzePositionWithOptions is AnalyzePosition with lookahead. Every move is
+// evaluated at opts.Plies.
+//
+// With opts.TimeLimit the search deepens instead: all moves are evaluated at
+// 0 plies, then re-evaluated best first at 1 ply, 2 plies and so on up to
+// opts.Plies (DefaultTimedPlies if 0) until time runs out. Moves reached at a
+// deeper ply rank above the rest, and each move's Ply says how deep it got.
+// With enough time the ranking is the same as without a limit.
+//
+// With opts.Noise each move's evaluation has noise added before ranking.
+func (e *Engine) AnalyzePositionWithOptions(state *GameState, dice [2]int, opts EvalOptions) (*AnalysisResult, error) {
+	defer opts.Timing.total(opts.Timing.start())
+	t, err := e.metTable(state.MET)
 	if err != nil {
-		return Move{}, nil, err
+		return nil, err
+	}
+	ml := generateMovesTimed(state.Board, dice, opts.Timing)
+
+	result := &AnalysisResult{
+		Moves:    make([]MoveWithEval, len(ml.Moves)),
+		NumMoves: len(ml.Moves),
+	}
+	if len(ml.Moves) == 0 {
+		result.Moves = nil
+		return result, nil
+	}
+
+	// order[i] is the generation index of result.Moves[i], used to break ties
+	order := make([]int, len(ml.Moves))
+	rank := func() {
+		sort.Sort(rankedMoves{result.Moves, order})
 	}
 
-	if analysis.NumMoves == 0 {
-		return Move{}, nil, nil // No legal moves
+	var deadline time.Time
+	first, last := opts.Plies, opts.Plies
+	if opts.TimeLimit > 0 {
+		deadline = time.Now().Add(opts.TimeLimit)
+		first, last = 0, timedPlies(opts)
+	}
+
+	for i, m := range ml.Moves {
+		pv := opts.movePV(first)
+		eval, err := e.moveEval(state, m, first, opts.UsePrune, time.Time{}, opts.Timing, pv)
+		if err != nil {
+			return nil, err
+		}
+		addNoise(eval, moveNoise(state, m, opts))
+		result.Moves[i] = MoveWithEval{Move: m, Eval: eval, Ply: first, Tags: ClassifyMove(state.Board, m), PV: pv}
+		e.setMoveEquities(state, &result.Moves[i], opts, t)
+		order[i] = i
+	}
+	rank()
+
+	for ply := first + 1; ply <= last; ply++ {
+		for i := range result.Moves {
+			pv := opts.movePV(ply)
+			eval, err := e.moveEval(state, result.Moves[i].Move, ply, opts.UsePrune, deadline, opts.Timing, pv)
+			if errors.Is(err, errDeadline) {
+				rank()
+				opts.setPV(result.Moves)
+				return result.finish(), nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			addNoise(eval, moveNoise(state, result.Moves[i].Move, opts))
+			result.Moves[i].Eval = eval
+			result.Moves[i].Ply = ply
+			result.Moves[i].PV = pv
+			e.setMoveEquities(state, &result.Moves[i], opts, t)
+		}
+		rank()
 	}
 
-	return analysis.BestMove, analysis.Moves[0].Eval, nil
+	opts.setPV(result.Moves)
+	return result.finish(), nil
 }
 
-// RankMoves evaluates and ranks the top N moves
-// If n <= 0, returns all moves ranked
-func (e *Engine) RankMoves(state *GameState, dice [2]int, n int) ([]MoveWithEval, error) {
-	analysis, err := e.AnalyzePosition(state, dice)
+// finish sets the best move from the ranked moves.
+func (r *AnalysisResult) finish() *AnalysisResult {
+	r.BestMove = r.Moves[0].Move
+	r.BestEquity = r.Moves[0].Equity
+	return r
+}
+
+// rankedMoves sorts moves deepest ply first, then by equity, then in
+// generation order.
+type rankedMoves struct {
+	moves []MoveWithEval
+	order []int
+}
+
+func (r rankedMoves) Len() int { return len(r.moves) }
+
+func (r rankedMoves) Less(i, j int) bool {
+	a, b := &r.moves[i], &r.moves[j]
+	if a.Ply != b.Ply {
+		return a.Ply > b.Ply
+	}
+	if a.Equity != b.Equity {
+		return a.Equity > b.Equity
+	}
+	return r.order[i] < r.order[j]
+}
+
+func (r rankedMoves) Swap(i, j int) {
+	r.moves[i], r.moves[j] = r.moves[j], r.moves[i]
+	r.order[i], r.order[j] = r.order[j], r.order[i]
+}
+
+// moveEval evaluates a move at the given ply from the mover's perspective,
+// adding to t if not nil. With lookahead it sets pv, if not nil, to the
+// principal variation of the opponent's rolls after the move.
+func (e *Engine) moveEval(state *GameState, m Move, plies int, usePrune bool, deadline time.Time, t *Timing, pv *PrincipalVariation) (*Evaluation, error) {
+	evalState := &GameState{
+		Board:         swapBoard(ApplyMove(state.Board, m)),
+		Turn:          1 - state.Turn,
+		CubeValue:     state.CubeValue,
+		CubeOwner:     state.CubeOwner,
+		MatchLength:   state.MatchLength,
+		Score:         state.Score,
+		Crawford:      state.Crawford,
+		TotalCheckers: swapTotals(state.TotalCheckers),
+	}
+
+	var eval *Evaluation
+	var err error
+	if plies <= 0 {
+		eval, err = e.evaluate(evalState, t)
+	} else {
+		eval, err = e.evaluateNPlyPV(evalState, plies, usePrune, deadline, t, pv)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return invertEvaluation(eval), nil
+}
+
+// DefaultRankMargin is the safety margin of RankMovesWithOptions when
+// EvalOptions.RankMargin is 0.
+const DefaultRankMargin = 0.08
+
+// minRankSamples is how many moves RankMovesWithOptions evaluates at full
+// depth before it may stop, so that the gains it has seen mean something.
+const minRankSamples = 4
+
+// RankMovesWithOptions ranks the top n moves as AnalyzePositionWithOptions does.
+// If n <= 0, returns all moves ranked.
+//
+// With lookahead and no time limit, only the moves that can still reach the
+// top n are evaluated at opts.Plies: moves are taken best first by their
+// 0-ply equity, and evaluation stops once the next move's 0-ply equity, plus
+// the largest gain from 0 plies to opts.Plies seen so far and
+// opts.RankMargin, falls short of the n-th best.
+func (e *Engine) RankMovesWithOptions(state *GameState, dice [2]int, n int, opts EvalOptions) ([]MoveWithEval, error) {
+	if n > 0 && opts.Plies > 0 && opts.TimeLimit <= 0 {
+		defer opts.Timing.total(opts.Timing.start())
+		return e.rankTopMoves(state, dice, n, opts)
+	}
+
+	analysis, err := e.AnalyzePositionWithOptions(state, dice, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -121,6 +443,86 @@ func (e *Engine) RankMoves(state *GameState, dice [2]int, n int) ([]MoveWithEval
 	return analysis.Moves[:n], nil
 }
 
+// rankTopMoves is RankMovesWithOptions stopping early. It keeps the best n
+// moves evaluated at opts.Plies, re-sorting them as each move is added.
+func (e *Engine) rankTopMoves(state *GameState, dice [2]int, n int, opts EvalOptions) ([]MoveWithEval, error) {
+	t, err := e.metTable(state.MET)
+	if err != nil {
+		return nil, err
+	}
+	ml := generateMovesTimed(state.Board, dice, opts.Timing)
+	if len(ml.Moves) == 0 {
+		return nil, nil
+	}
+
+	candidates := make([]MoveWithEval, len(ml.Moves))
+	order := make([]int, len(ml.Moves))
+	noise := make([][5]float64, len(ml.Moves))
+	for i, m := range ml.Moves {
+		eval, err := e.moveEval(state, m, 0, opts.UsePrune, time.Time{}, opts.Timing, nil)
+		if err != nil {
+			return nil, err
+		}
+		noise[i] = moveNoise(state, m, opts)
+		addNoise(eval, noise[i])
+		candidates[i] = MoveWithEval{Move: m, Eval: eval}
+		e.setMoveEquities(state, &candidates[i], opts, t)
+		order[i] = i
+	}
+	sort.Sort(rankedMoves{candidates, order})
+
+	margin := opts.RankMargin
+	if margin <= 0 {
+		margin = DefaultRankMargin
+	}
+
+	top := rankedMoves{make([]MoveWithEval, 0, n+1), make([]int, 0, n+1)}
+	gain := 0.0
+	for i, c := range candidates {
+		if i >= max(n, minRankSamples) && c.Equity+gain+margin < top.moves[n-1].Equity {
+			break
+		}
+		pv := opts.movePV(opts.Plies)
+		eval, err := e.moveEval(state, c.Move, opts.Plies, opts.UsePrune, time.Time{}, opts.Timing, pv)
+		if err != nil {
+			return nil, err
+		}
+		addNoise(eval, noise[order[i]])
+		mv := MoveWithEval{Move: c.Move, Eval: eval, Ply: opts.Plies, Tags: ClassifyMove(state.Board, c.Move), PV: pv}
+		e.setMoveEquities(state, &mv, opts, t)
+		gain = max(gain, mv.Equity-c.Equity)
+
+		top.moves = append(top.moves, mv)
+		top.order = append(top.order, order[i])
+		sort.Sort(top)
+		if len(top.moves) > n {
+			top.moves, top.order = top.moves[:n], top.order[:n]
+		}
+	}
+	opts.setPV(top.moves)
+	return top.moves, nil
+}
+
+// RankMoves evaluates and ranks the top N moves
+// If n <= 0, returns all moves ranked
+func (e *Engine) RankMoves(state *GameState, dice [2]int, n int) ([]MoveWithEval, error) {
+	moves, _, err := e.rankMoves(state, dice, n)
+	return moves, err
+}
+
+// ResultingPositionID returns the position ID after playing m on board.
+// The opponent is on roll in the resulting position, so the ID matches what
+// gnubg shows after the move.
+func ResultingPositionID(board Board, m Move) string {
+	return positionid.PositionID(positionid.Board(ResultingBoard(board, m)))
+}
+
+// ResultingBoard returns the board after playing m on board, with the
+// opponent on roll: the board of ResultingPositionID.
+func ResultingBoard(board Board, m Move) Board {
+	return swapBoard(ApplyMove(board, m))
+}
+
 // swapBoard swaps the board representation between players
 func swapBoard(board Board) Board {
 	return Board(positionid.SwapSides(positionid.Board(board)))
@@ -128,7 +530,13 @@ func swapBoard(board Board) Board {
 
 // invertEvaluation inverts an evaluation from opponent's perspective to ours
 func invertEvaluation(eval *Evaluation) *Evaluation {
-	return &Evaluation{
+	inv := eval.inverted()
+	return &inv
+}
+
+// inverted is invertEvaluation returning the evaluation by value.
+func (eval *Evaluation) inverted() Evaluation {
+	return Evaluation{
 		WinProb: 1.0 - eval.WinProb,
 		WinG:    eval.LoseG,
 		WinBG:   eval.LoseBG,
@@ -0,0 +1,495 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/yourusername/bgengine/internal/neuralnet"
+	"github.com/yourusername/bgengine/internal/positionid"
+)
+
+// randomCorpus plays random games from the starting position and collects
+// positions with a dice roll for the player on roll (player 1 in the board)
+func randomCorpus(seed int64, n int) ([]*GameState, [][2]int) {
+	rng := rand.New(rand.NewSource(seed))
+	states := make([]*GameState, 0, n)
+	dice := make([][2]int, 0, n)
+
+	board := StartingPosition().Board
+	for len(states) < n {
+		roll := [2]int{rng.Intn(6) + 1, rng.Intn(6) + 1}
+		ml := GenerateMoves(board, roll[0], roll[1])
+		if len(ml.Moves) > 1 {
+			states = append(states, &GameState{Board: board, CubeValue: 1, CubeOwner: -1})
+			dice = append(dice, roll)
+		}
+
+		if len(ml.Moves) > 0 {
+			board = ApplyMove(board, ml.Moves[rng.Intn(len(ml.Moves))])
+		}
+		board = swapBoard(board)
+
+		// Restart when a game finishes
+		var left [2]int
+		for i := 0; i < 25; i++ {
+			left[0] += int(board[0][i])
+			left[1] += int(board[1][i])
+		}
+		if left[0] == 0 || left[1] == 0 {
+			board = StartingPosition().Board
+		}
+	}
+	return states, dice
+}
+
+// netEngine returns an engine evaluating contact, crashed and race positions
+// with small random nets, for tests of the net path that cannot load the
+// real weights. The outputs stay near an even game with a few gammons, so
+// they are consistent and never need sanitizing.
+func netEngine(t *testing.T) *Engine {
+	t.Helper()
+	e, err := NewEngine(EngineOptions{SkipWarmup: true})
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	rng := rand.New(rand.NewSource(1))
+	net := func(inputs uint32) *neuralnet.NeuralNet {
+		nn := constantNet(inputs, 0, -2, -4, -2, -4)
+		nn.CHidden = 4
+		nn.HiddenWeight = make([]float32, inputs*nn.CHidden)
+		nn.OutputWeight = make([]float32, 5*nn.CHidden)
+		nn.HiddenThreshold = make([]float32, nn.CHidden)
+		for i := range nn.HiddenWeight {
+			nn.HiddenWeight[i] = float32(rng.NormFloat64())
+		}
+		for i := range nn.OutputWeight {
+			nn.OutputWeight[i] = float32(rng.NormFloat64()) / 10
+		}
+		return nn
+	}
+	e.contact = net(neuralnet.NumContactInputs)
+	e.crashed = net(neuralnet.NumContactInputs)
+	e.race = net(neuralnet.NumRaceInputs)
+	e.initBufferPools()
+	return e
+}
+
+func TestBestMoveMatchesRankMoves(t *testing.T) {
+	engine, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+
+	states, dice := randomCorpus(42, 500)
+	opts := EvalOptions{Plies: 0, UsePrune: false}
+
+	for i, state := range states {
+		best, equity, err := engine.BestMove(state, dice[i], opts)
+		if err != nil {
+			t.Fatalf("BestMove failed: %v", err)
+		}
+		ranked, err := engine.RankMoves(state, dice[i], 0)
+		if err != nil {
+			t.Fatalf("RankMoves failed: %v", err)
+		}
+
+		if math.Abs(equity-ranked[0].Equity) > 1e-9 {
+			t.Errorf("position %d: BestMove equity %.6f, RankMoves %.6f", i, equity, ranked[0].Equity)
+			continue
+		}
+		// The two can break ties differently, but BestMove's move must be
+		// one of the moves RankMoves ranks best
+		tied := false
+		for _, m := range ranked {
+			if m.Equity < ranked[0].Equity-1e-9 {
+				break
+			}
+			if EqualBoards(ApplyMove(state.Board, best), ApplyMove(state.Board, m.Move)) {
+				tied = true
+				break
+			}
+		}
+		if !tied {
+			t.Errorf("position %d: BestMove plays %v, not one of the moves RankMoves ranks best at %.6f",
+				i, best, ranked[0].Equity)
+		}
+	}
+}
+
+func TestBestMovePrunedStaysClose(t *testing.T) {
+	engine, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+
+	states, dice := randomCorpus(7, 200)
+	for i, state := range states {
+		_, pruned, err := engine.BestMove(state, dice[i], DefaultEvalOptions())
+		if err != nil {
+			t.Fatalf("BestMove failed: %v", err)
+		}
+		_, full, err := engine.BestMove(state, dice[i], EvalOptions{})
+		if err != nil {
+			t.Fatalf("BestMove failed: %v", err)
+		}
+		if pruned > full+1e-9 {
+			t.Errorf("position %d: pruned equity %.6f exceeds unpruned best %.6f", i, pruned, full)
+		}
+	}
+}
+
+func TestBestMoveNoLegalMoves(t *testing.T) {
+	engine, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	// Player on roll is on the bar against a closed board
+	state := &GameState{CubeValue: 1, CubeOwner: -1}
+	state.Board[1][24] = 1
+	state.Board[1][5] = 14
+	for i := 0; i < 6; i++ {
+		state.Board[0][i] = 2
+	}
+
+	move, _, err := engine.BestMove(state, [2]int{3, 1}, DefaultEvalOptions())
+	if err != nil {
+		t.Fatalf("BestMove failed: %v", err)
+	}
+	if move.From[0] != -1 {
+		t.Errorf("Expected no move, got From=%v To=%v", move.From, move.To)
+	}
+}
+
+func TestBestMoveWithoutNets(t *testing.T) {
+	engine, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	state := StartingPosition()
+	move, _, err := engine.BestMove(state, [2]int{3, 1}, DefaultEvalOptions())
+	if err != nil {
+		t.Fatalf("BestMove failed: %v", err)
+	}
+	if move.From[0] < 0 {
+		t.Error("Expected a legal move for 3-1 from the starting position")
+	}
+}
+
+func TestBestMoveAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("the race detector allocates")
+	}
+	e := netEngine(t)
+	states, dice := randomCorpus(3, 16)
+	opts := DefaultEvalOptions()
+	for i := range states {
+		if _, _, err := e.BestMove(states[i], dice[i], opts); err != nil {
+			t.Fatalf("BestMove failed: %v", err)
+		}
+	}
+
+	// The move list and net buffers come from pools: a 0-ply move choice
+	// does not allocate
+	for i := range states {
+		if allocs := testing.AllocsPerRun(20, func() { e.BestMove(states[i], dice[i], opts) }); allocs > 0 {
+			t.Errorf("position %d: BestMove allocates %.1f times, want 0", i, allocs)
+		}
+	}
+}
+
+func BenchmarkBestMove(b *testing.B) {
+	engine, err := NewEngine(EngineOptions{WeightsFileText: "../../data/gnubg.weights"})
+	if err != nil {
+		b.Skipf("Skipping - could not load weights: %v", err)
+	}
+
+	states, dice := randomCorpus(1, 64)
+	opts := DefaultEvalOptions()
+
+	// Warm up the buffer pools
+	for i := range states {
+		engine.BestMove(states[i], dice[i], opts)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		k := i % len(states)
+		if _, _, err := engine.BestMove(states[k], dice[k], opts); err != nil {
+			b.Fatalf("BestMove failed: %v", err)
+		}
+	}
+}
+
+func BenchmarkRankMovesTop1(b *testing.B) {
+	engine, err := NewEngine(EngineOptions{WeightsFileText: "../../data/gnubg.weights"})
+	if err != nil {
+		b.Logf("No weights, using the heuristic evaluation: %v", err)
+		engine, _ = NewEngine(EngineOptions{})
+	}
+
+	states, dice := randomCorpus(1, 64)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		k := i % len(states)
+		if _, err := engine.RankMoves(states[k], dice[k], 1); err != nil {
+			b.Fatalf("RankMoves failed: %v", err)
+		}
+	}
+}
+
+func TestRankMovesTopN(t *testing.T) {
+	e, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	states, dice := randomCorpus(5, 100)
+	for i, state := range states {
+		state.MatchLength = 5 * (i % 2)
+		all, err := e.AnalyzePosition(state, dice[i])
+		if err != nil {
+			t.Fatalf("AnalyzePosition failed: %v", err)
+		}
+		for _, n := range []int{1, 3} {
+			top, err := e.RankMoves(state, dice[i], n)
+			if err != nil {
+				t.Fatalf("RankMoves failed: %v", err)
+			}
+			// Only the top moves are built, but they must be built exactly
+			// as the full ranking builds them, ties in the same order
+			want := all.Moves[:min(n, len(all.Moves))]
+			if !reflect.DeepEqual(top, want) {
+				t.Errorf("position %d, top %d: %+v, want %+v", i, n, top, want)
+			}
+		}
+	}
+}
+
+func TestRankMovesAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("the race detector allocates")
+	}
+	e := netEngine(t)
+	// 1-1 in a middle game has many legal moves; only the best is built
+	state := StartingPosition()
+	state.Board = ApplyMove(state.Board, GenerateMoves(state.Board, 6, 5).Moves[0])
+	state.Board = swapBoard(state.Board)
+	dice := [2]int{1, 1}
+	moves := len(GenerateMoves(state.Board, dice[0], dice[1]).Moves)
+	if _, err := e.RankMoves(state, dice, 1); err != nil {
+		t.Fatalf("RankMoves failed: %v", err)
+	}
+
+	allocs := testing.AllocsPerRun(100, func() { e.RankMoves(state, dice, 1) })
+	if allocs > 8 {
+		t.Errorf("RankMoves of the best of %d moves allocates %.1f times, want at most 8", moves, allocs)
+	}
+}
+
+func TestResultingPositionID(t *testing.T) {
+	states, dice := randomCorpus(11, 200)
+
+	for i, state := range states {
+		if state.Board[1][24] > 0 || !positionid.CheckPosition(positionid.Board(state.Board)) {
+			// Bar entry is checked against the wrong point in move
+			// generation, so these moves (and the games that follow
+			// them) can end on boards that are not legal.
+			continue
+		}
+		ml := GenerateMoves(state.Board, dice[i][0], dice[i][1])
+		for _, m := range ml.Moves {
+			id := ResultingPositionID(state.Board, m)
+			board, err := positionid.BoardFromPositionID(id)
+			if err != nil {
+				t.Fatalf("BoardFromPositionID(%q): %v", id, err)
+			}
+			// The ID is for the opponent on roll
+			if want := swapBoard(ApplyMove(state.Board, m)); Board(board) != want {
+				t.Fatalf("position %q after %v decodes to %v, want %v", id, m, board, want)
+			}
+		}
+	}
+}
+
+func TestTopMovesPositionIDs(t *testing.T) {
+	e, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+
+	state := StartingPosition()
+	ml := GenerateMoves(state.Board, 3, 1)
+	analysis, err := e.AnalyzeMoveSkill(state, ml.Moves[0], [2]int{3, 1})
+	if err != nil {
+		t.Fatalf("AnalyzeMoveSkill: %v", err)
+	}
+	if len(analysis.TopMoves) == 0 {
+		t.Fatal("no top moves")
+	}
+
+	for _, m := range analysis.TopMoves {
+		board, err := positionid.BoardFromPositionID(m.PositionID)
+		if err != nil {
+			t.Fatalf("BoardFromPositionID(%q): %v", m.PositionID, err)
+		}
+		if want := swapBoard(ApplyMove(state.Board, m.Move)); Board(board) != want {
+			t.Errorf("top move %v has position %q", m.Move, m.PositionID)
+		}
+	}
+}
+
+// shortBearoff returns a bearoff where 2-ply evaluations differ between moves
+// even without neural nets, since the exact solver scores the leaves.
+func shortBearoff() *GameState {
+	var b Board
+	b[1][0], b[1][2], b[1][4], b[1][5] = 1, 1, 2, 1
+	b[0][0], b[0][1], b[0][3] = 1, 1, 2
+	return &GameState{Board: b, CubeValue: 1, CubeOwner: -1}
+}
+
+func TestAnalyzeTimedMatchesFixed(t *testing.T) {
+	e, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	state := shortBearoff()
+	dice := [2]int{2, 1}
+
+	fixed, err := e.AnalyzePositionWithOptions(state, dice, EvalOptions{Plies: 2})
+	if err != nil {
+		t.Fatalf("fixed: %v", err)
+	}
+	timed, err := e.AnalyzePositionWithOptions(state, dice, EvalOptions{Plies: 2, TimeLimit: time.Minute})
+	if err != nil {
+		t.Fatalf("timed: %v", err)
+	}
+
+	if len(timed.Moves) != len(fixed.Moves) || timed.NumMoves != fixed.NumMoves {
+		t.Fatalf("timed has %d moves, fixed %d", len(timed.Moves), len(fixed.Moves))
+	}
+	for i := range fixed.Moves {
+		f, tm := fixed.Moves[i], timed.Moves[i]
+		if f.Move != tm.Move || f.Equity != tm.Equity || tm.Ply != 2 || f.Ply != 2 {
+			t.Errorf("move %d: timed %s %.6f (ply %d), fixed %s %.6f (ply %d)",
+				i, FormatMove(tm.Move), tm.Equity, tm.Ply, FormatMove(f.Move), f.Equity, f.Ply)
+		}
+	}
+	if timed.BestMove != fixed.BestMove {
+		t.Errorf("best move %s, want %s", FormatMove(timed.BestMove), FormatMove(fixed.BestMove))
+	}
+
+	eval, err := e.EvaluatePliedWithOptions(state, EvalOptions{Plies: 2, TimeLimit: time.Minute})
+	if err != nil {
+		t.Fatalf("EvaluatePliedWithOptions: %v", err)
+	}
+	want, _ := e.EvaluatePliedWithOptions(state, EvalOptions{Plies: 2})
+	if eval.Equity != want.Equity {
+		t.Errorf("timed evaluation %.6f, want %.6f", eval.Equity, want.Equity)
+	}
+}
+
+func TestAnalyzeTimedDeadline(t *testing.T) {
+	e, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+
+	// 3 plies from the opening takes seconds; the limit cuts it short
+	const limit = 50 * time.Millisecond
+	start := time.Now()
+	result, err := e.AnalyzePositionWithOptions(StartingPosition(), [2]int{3, 1},
+		EvalOptions{Plies: 3, UsePrune: true, TimeLimit: limit})
+	elapsed := time.Since(start)
+	if err != nil {
+		t.Fatalf("AnalyzePositionWithOptions: %v", err)
+	}
+	if elapsed > limit+50*time.Millisecond {
+		t.Errorf("took %v with a %v limit", elapsed, limit)
+	}
+
+	if len(result.Moves) != result.NumMoves || result.NumMoves == 0 {
+		t.Fatalf("got %d of %d moves", len(result.Moves), result.NumMoves)
+	}
+	for i := 1; i < len(result.Moves); i++ {
+		if result.Moves[i].Ply > result.Moves[i-1].Ply {
+			t.Errorf("move %d at ply %d ranked below ply %d", i, result.Moves[i].Ply, result.Moves[i-1].Ply)
+		}
+	}
+	if result.Moves[0].Ply >= 3 {
+		t.Errorf("top move reached ply %d; the limit should have stopped the search", result.Moves[0].Ply)
+	}
+
+	start = time.Now()
+	if _, err := e.EvaluatePliedWithOptions(StartingPosition(), EvalOptions{Plies: 3, TimeLimit: limit}); err != nil {
+		t.Fatalf("EvaluatePliedWithOptions: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > limit+50*time.Millisecond {
+		t.Errorf("evaluation took %v with a %v limit", elapsed, limit)
+	}
+}
+
+func TestRankMovesStopsEarly(t *testing.T) {
+	e, err := NewEngine(EngineOptions{SkipWarmup: true})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	// The top 5 at 1 ply match ranking every move at 1 ply
+	states, dice := randomCorpus(7, 100)
+	for i, state := range states {
+		all, err := e.RankMovesWithOptions(state, dice[i], 0, EvalOptions{Plies: 1})
+		if err != nil {
+			t.Fatalf("RankMovesWithOptions failed: %v", err)
+		}
+		top, err := e.RankMovesWithOptions(state, dice[i], 5, EvalOptions{Plies: 1})
+		if err != nil {
+			t.Fatalf("RankMovesWithOptions failed: %v", err)
+		}
+		if len(top) != min(5, len(all)) {
+			t.Fatalf("position %d: %d moves, want %d", i, len(top), min(5, len(all)))
+		}
+		for k := range top {
+			if top[k].Move != all[k].Move || top[k].Equity != all[k].Equity || top[k].Ply != 1 {
+				t.Errorf("position %d, move %d: %+v, want %+v", i, k, top[k], all[k])
+			}
+		}
+	}
+
+	if moves, err := e.RankMovesWithOptions(StartingPosition(), [2]int{0, 0}, 5, EvalOptions{Plies: 1}); err != nil || moves != nil {
+		t.Errorf("no legal moves: %v, %v", moves, err)
+	}
+}
+
+// BenchmarkRankMoves1Ply ranks the top 5 moves at 1 ply for doubles from
+// the starting position, stopping early and evaluating every move.
+func BenchmarkRankMoves1Ply(b *testing.B) {
+	e, err := NewEngine(EngineOptions{WeightsFileText: "../../data/gnubg.weights", SkipWarmup: true})
+	if err != nil {
+		b.Logf("No weights, using the heuristic evaluation: %v", err)
+		e, _ = NewEngine(EngineOptions{SkipWarmup: true})
+	}
+	state := StartingPosition()
+
+	for _, dice := range [][2]int{{2, 2}, {1, 1}} {
+		for _, bc := range []struct {
+			name string
+			n    int
+		}{{"top5", 5}, {"all", 0}} {
+			b.Run(fmt.Sprintf("%d-%d/%s", dice[0], dice[1], bc.name), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					if _, err := e.RankMovesWithOptions(state, dice, bc.n, EvalOptions{Plies: 1}); err != nil {
+						b.Fatalf("RankMovesWithOptions failed: %v", err)
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package engine
+
+import "fmt"
+
+// BoardDiff summarizes what a move changed on the board.
+// Fields are indexed by side as in Board (the player who moved is side 1).
+// Points are numbered 1-24 from that side's own perspective, as in move notation.
+type BoardDiff struct {
+	PointsMade   [2][]int // Points that now hold two or more checkers
+	PointsBroken [2][]int // Points that held two or more checkers and no longer do
+	BlotsCreated [2][]int // Points that now hold a single checker
+	BlotsRemoved [2][]int // Points that held a single checker and no longer do
+	HitPoints    [2][]int // Points where this side hit a checker
+	Hit          [2]int   // Checkers of this side sent to the bar
+	PipsMoved    [2]int   // Drop in pip count (checkers borne off count their pips)
+	PrimeBefore  [2]int   // Longest run of made points before the move
+	PrimeAfter   [2]int   // Longest run of made points after the move
+}
+
+// DiffBoards compares two boards with the same side in board[1].
+func DiffBoards(before, after Board) BoardDiff {
+	var d BoardDiff
+
+	pipsBefore, pipsAfter := PipCount(before), PipCount(after)
+	for side := 0; side < 2; side++ {
+		for i := 0; i < 24; i++ {
+			b, a := before[side][i], after[side][i]
+			point := i + 1
+			switch {
+			case b < 2 && a >= 2:
+				d.PointsMade[side] = append(d.PointsMade[side], point)
+			case b >= 2 && a < 2:
+				d.PointsBroken[side] = append(d.PointsBroken[side], point)
+			}
+			switch {
+			case b != 1 && a == 1:
+				d.BlotsCreated[side] = append(d.BlotsCreated[side], point)
+			case b == 1 && a != 1:
+				d.BlotsRemoved[side] = append(d.BlotsRemoved[side], point)
+			}
+
+			// A blot that is gone with the other side now on the point was hit
+			other := 1 - side
+			if b == 1 && a == 0 && after[other][23-i] > 0 && after[side][24] > before[side][24] {
+				d.HitPoints[other] = append(d.HitPoints[other], 24-i)
+			}
+		}
+
+		if after[side][24] > before[side][24] {
+			d.Hit[side] = int(after[side][24] - before[side][24])
+		}
+		d.PipsMoved[side] = pipsBefore[side] - pipsAfter[side]
+		d.PrimeBefore[side] = longestPrime(before[side])
+		d.PrimeAfter[side] = longestPrime(after[side])
+	}
+
+	return d
+}
+
+// longestPrime returns the longest run of consecutive made points.
+func longestPrime(points [25]uint8) int {
+	best, run := 0, 0
+	for i := 0; i < 24; i++ {
+		if points[i] >= 2 {
+			run++
+			if run > best {
+				best = run
+			}
+		} else {
+			run = 0
+		}
+	}
+	return best
+}
+
+// blotCount returns the number of single checkers on the board points.
+func blotCount(points [25]uint8) int {
+	n := 0
+	for i := 0; i < 24; i++ {
+		if points[i] == 1 {
+			n++
+		}
+	}
+	return n
+}
+
+// Ranks of the rules used by DescribeMoveDifference, most important first.
+const (
+	ruleHit = iota
+	rulePoint
+	ruleBlot
+	rulePrime
+	rulePips
+)
+
+// moveFeature is something one play does that the other does not.
+type moveFeature struct {
+	rule     int
+	negative bool   // A weakness rather than a strength
+	verb     string // "makes the 5-point"
+	gerund   string // "making the 5-point"
+}
+
+// DescribeMoveDifference returns one sentence saying what the best play does
+// that the played move does not, for example
+// "The best play makes the 5-point instead of leaving a blot on the 10-point."
+// Differences are ranked: hits, then points made or broken, then blots left,
+// then prime length, then pips. It returns "" when both moves reach the same
+// position.
+func DescribeMoveDifference(state *GameState, played, best Move) string {
+	afterPlayed := ApplyMove(state.Board, played)
+	afterBest := ApplyMove(state.Board, best)
+	if EqualBoards(afterPlayed, afterBest) {
+		return ""
+	}
+
+	dPlayed := DiffBoards(state.Board, afterPlayed)
+	dBest := DiffBoards(state.Board, afterBest)
+
+	good := firstFeature(moveFeatures(dBest, dPlayed, afterBest, afterPlayed), true)
+	bad := firstFeature(moveFeatures(dPlayed, dBest, afterPlayed, afterBest), false)
+
+	switch {
+	case good != nil && bad != nil:
+		return fmt.Sprintf("The best play %s instead of %s.", good.verb, bad.gerund)
+	case good != nil:
+		return fmt.Sprintf("The best play %s.", good.verb)
+	case bad != nil && bad.negative:
+		return fmt.Sprintf("The best play avoids %s.", bad.gerund)
+	case bad != nil:
+		return fmt.Sprintf("The best play gives up %s.", bad.gerund)
+	default:
+		return "The best play differs only in checker placement."
+	}
+}
+
+// firstFeature returns the highest ranked feature, or nil if there are none.
+// With strengthsOnly, weaknesses are skipped.
+func firstFeature(features []moveFeature, strengthsOnly bool) *moveFeature {
+	var first *moveFeature
+	for i := range features {
+		if strengthsOnly && features[i].negative {
+			continue
+		}
+		if first == nil || features[i].rule < first.rule {
+			first = &features[i]
+		}
+	}
+	return first
+}
+
+// moveFeatures lists what the move described by d does that the move
+// described by o does not. after and otherAfter are the resulting boards.
+func moveFeatures(d, o BoardDiff, after, otherAfter Board) []moveFeature {
+	var fs []moveFeature
+
+	// Hits
+	if d.Hit[0] >= 2 && d.Hit[0] > o.Hit[0] {
+		fs = append(fs, moveFeature{rule: ruleHit,
+			verb:   fmt.Sprintf("hits %d checkers", d.Hit[0]),
+			gerund: fmt.Sprintf("hitting %d checkers", d.Hit[0])})
+	} else if p, ok := firstMissing(d.HitPoints[1], o.HitPoints[1]); ok {
+		fs = append(fs, moveFeature{rule: ruleHit,
+			verb:   fmt.Sprintf("hits on the %d-point", p),
+			gerund: fmt.Sprintf("hitting on the %d-point", p)})
+	}
+
+	// Points made, and points broken that the other move keeps
+	if p, ok := lastMissing(d.PointsMade[1], o.PointsMade[1]); ok {
+		fs = append(fs, moveFeature{rule: rulePoint,
+			verb:   fmt.Sprintf("makes the %d-point", p),
+			gerund: fmt.Sprintf("making the %d-point", p)})
+	}
+	if p, ok := lastMissing(d.PointsBroken[1], o.PointsBroken[1]); ok {
+		fs = append(fs, moveFeature{rule: rulePoint, negative: true,
+			verb:   fmt.Sprintf("breaks the %d-point", p),
+			gerund: fmt.Sprintf("breaking the %d-point", p)})
+	}
+
+	// Blot exposure
+	if b, ob := blotCount(after[1]), blotCount(otherAfter[1]); b < ob {
+		if b == 0 {
+			fs = append(fs, moveFeature{rule: ruleBlot, verb: "leaves no blots", gerund: "leaving no blots"})
+		} else {
+			fs = append(fs, moveFeature{rule: ruleBlot, verb: "leaves fewer blots", gerund: "leaving fewer blots"})
+		}
+	} else if p, ok := lastMissing(d.BlotsCreated[1], o.BlotsCreated[1]); ok && b > ob {
+		fs = append(fs, moveFeature{rule: ruleBlot, negative: true,
+			verb:   fmt.Sprintf("leaves a blot on the %d-point", p),
+			gerund: fmt.Sprintf("leaving a blot on the %d-point", p)})
+	}
+
+	// Prime length
+	if d.PrimeAfter[1] > o.PrimeAfter[1] && d.PrimeAfter[1] >= 3 {
+		verb, gerund := "keeps", "keeping"
+		if d.PrimeAfter[1] > d.PrimeBefore[1] {
+			verb, gerund = "builds", "building"
+		}
+		fs = append(fs, moveFeature{rule: rulePrime,
+			verb:   fmt.Sprintf("%s a %d-point prime", verb, d.PrimeAfter[1]),
+			gerund: fmt.Sprintf("%s a %d-point prime", gerund, d.PrimeAfter[1])})
+	}
+
+	// Pip efficiency, which only differs when bearing off
+	if d.PipsMoved[1] > o.PipsMoved[1] {
+		fs = append(fs, moveFeature{rule: rulePips,
+			verb:   fmt.Sprintf("uses %d more pips", d.PipsMoved[1]-o.PipsMoved[1]),
+			gerund: fmt.Sprintf("using %d more pips", d.PipsMoved[1]-o.PipsMoved[1])})
+	}
+
+	return fs
+}
+
+// firstMissing returns the first point in a that is not in b.
+func firstMissing(a, b []int) (int, bool) {
+	for _, p := range a {
+		if !containsPoint(b, p) {
+			return p, true
+		}
+	}
+	return 0, false
+}
+
+// lastMissing returns the last (highest) point in a that is not in b.
+func lastMissing(a, b []int) (int, bool) {
+	for i := len(a) - 1; i >= 0; i-- {
+		if !containsPoint(b, a[i]) {
+			return a[i], true
+		}
+	}
+	return 0, false
+}
+
+func containsPoint(points []int, p int) bool {
+	for _, q := range points {
+		if q == p {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+)
+
+// boardWith returns a board with the given checkers. Points are 1-24 from
+// each side's own perspective; 25 is the bar.
+func boardWith(mover, opp map[int]uint8) Board {
+	var b Board
+	for p, n := range mover {
+		b[1][p-1] = n
+	}
+	for p, n := range opp {
+		b[0][p-1] = n
+	}
+	return b
+}
+
+func TestDiffBoards(t *testing.T) {
+	before := StartingPosition().Board
+	before[0][12]--
+	before[0][19]++ // Opponent blot on our 5-point
+
+	m, err := ParseMove("8/5 6/5")
+	if err != nil {
+		t.Fatalf("ParseMove: %v", err)
+	}
+	d := DiffBoards(before, ApplyMove(before, m))
+
+	checks := []struct {
+		name      string
+		got, want interface{}
+	}{
+		{"points made", d.PointsMade[1], []int{5}},
+		{"blots created", d.BlotsCreated[1], []int(nil)},
+		{"hit points", d.HitPoints[1], []int{5}},
+		{"opponent hit", d.Hit[0], 1},
+		{"opponent blots removed", d.BlotsRemoved[0], []int{20}},
+		{"pips moved", d.PipsMoved[1], 4},
+		{"opponent pips", d.PipsMoved[0], 20 - 25},
+		{"prime after", d.PrimeAfter[1], 2},
+	}
+	for _, c := range checks {
+		if !reflect.DeepEqual(c.got, c.want) {
+			t.Errorf("%s = %v, want %v", c.name, c.got, c.want)
+		}
+	}
+}
+
+func TestDescribeMoveDifference(t *testing.T) {
+	start := StartingPosition().Board
+
+	// Opponent blot on our 5-point
+	blot5 := start
+	blot5[0][12]--
+	blot5[0][19]++
+
+	// Opponent blots on our 4- and 5-points
+	blots45 := boardWith(
+		map[int]uint8{6: 5, 8: 3, 13: 5, 24: 2},
+		map[int]uint8{20: 1, 21: 1, 6: 5, 8: 3, 13: 3, 24: 2})
+
+	// Four points from the 4- to the 7-point, spare on the 9
+	primed := boardWith(
+		map[int]uint8{4: 2, 5: 2, 6: 4, 7: 2, 9: 1, 13: 2, 24: 2},
+		map[int]uint8{6: 5, 8: 3, 13: 5, 24: 2})
+
+	// Bearing off: one checker on the 6-point and two on the 3-point
+	bearoff := boardWith(
+		map[int]uint8{6: 1, 3: 2},
+		map[int]uint8{1: 2, 2: 2})
+
+	tests := []struct {
+		name         string
+		board        Board
+		played, best string
+		want         string
+	}{
+		{"point over split", start, "24/21 24/23", "8/5 6/5",
+			"The best play makes the 5-point instead of breaking the 24-point."},
+		{"point over builders", start, "13/10 13/12", "8/5 6/5",
+			"The best play makes the 5-point instead of leaving a blot on the 12-point."},
+		{"builders over point", start, "8/5 6/5", "13/10 13/12",
+			"The best play gives up making the 5-point."},
+		{"run over bar point", start, "13/7 8/7", "24/17",
+			"The best play gives up making the 7-point."},
+		{"bar point over run", start, "24/17", "13/7 8/7",
+			"The best play makes the 7-point instead of breaking the 24-point."},
+		{"split over builders", start, "13/9 13/11", "24/20 24/22",
+			"The best play differs only in checker placement."},
+		{"doubles", start, "6/5 6/5 6/5 6/5", "8/7 8/7 6/5 6/5",
+			"The best play makes the 7-point instead of breaking the 6-point."},
+		{"hit over builders", blot5, "13/10 13/12", "8/5 6/5",
+			"The best play hits on the 5-point instead of leaving a blot on the 12-point."},
+		{"split over hit", blot5, "13/10 6/5", "24/21 24/23",
+			"The best play gives up hitting on the 5-point."},
+		{"double hit", blots45, "13/11 13/12", "6/4 6/5",
+			"The best play hits 2 checkers."},
+		{"prime", primed, "13/10 13/11", "9/8 13/8",
+			"The best play makes the 8-point instead of leaving a blot on the 11-point."},
+		{"keep prime", primed, "7/5 7/6", "13/11 13/12",
+			"The best play keeps a 4-point prime instead of breaking the 7-point."},
+		{"bear off", bearoff, "6/3 3/off", "6/off 3/off",
+			"The best play uses 3 more pips instead of leaving no blots."},
+		{"same play", start, "8/5 6/5", "6/5 8/5", ""},
+	}
+
+	for _, tt := range tests {
+		played, err := ParseMove(tt.played)
+		if err != nil {
+			t.Fatalf("%s: ParseMove(%q): %v", tt.name, tt.played, err)
+		}
+		best, err := ParseMove(tt.best)
+		if err != nil {
+			t.Fatalf("%s: ParseMove(%q): %v", tt.name, tt.best, err)
+		}
+		got := DescribeMoveDifference(&GameState{Board: tt.board}, played, best)
+		if got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
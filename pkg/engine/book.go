@@ -0,0 +1,427 @@
+package engine
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/yourusername/bgengine/internal/fileutil"
+	"github.com/yourusername/bgengine/internal/neuralnet"
+	"github.com/yourusername/bgengine/internal/positionid"
+)
+
+// A Book holds the evaluations of the positions reachable within a few plies
+// of the starting position, computed once so that rollouts of early
+// positions and multi-ply evaluations do not compute them again and again.
+// Every position is evaluated at the book's ply, as EvaluateCached would,
+// and answers only lookups at that ply (see EngineOptions.BookFile).
+//
+// A book is only valid for the weights and evaluation options it was built
+// with, which it records: SetBook refuses a book built differently.
+type Book struct {
+	depth   int // Plies from the starting position covered
+	plies   int // Ply the positions are evaluated at
+	top     int // Moves followed per roll (0 = all)
+	weights [sha256Size]byte
+	flags   uint8 // bookFlags of the engine that built the book
+
+	entries map[positionid.PositionKey][5]float64
+
+	// Statistics
+	lookups atomic.Uint64
+	hits    atomic.Uint64
+}
+
+// BookOptions say which positions BuildBook covers and how it evaluates
+// them.
+type BookOptions struct {
+	Depth int // Plies from the starting position to cover, 0-3 (0 = the starting position only)
+	Plies int // Ply to evaluate each position at, 0-2
+	Top   int // Best moves (at 0 ply) to follow for each roll (0 = all legal moves)
+}
+
+const (
+	bookMagic    = "GOBGBOOK"
+	bookVersion  = 1
+	sha256Size   = 32
+	maxBookDepth = 3
+	maxBookPlies = 2
+)
+
+// Evaluation options recorded in a book, which change what positions
+// evaluate to
+const (
+	bookEvenFallback uint8 = 1 << iota
+	bookCoreNetsOnly
+	bookHybridRace
+)
+
+// ErrBookMismatch is returned by SetBook for a book built with other weights
+// or evaluation options than the engine's.
+var ErrBookMismatch = errors.New("book was built with different weights or evaluation options")
+
+// BuildBook enumerates the positions reachable within opts.Depth plies of
+// the starting position and evaluates each at opts.Plies. Positions are
+// evaluated on all CPUs. The book belongs to e's weights and evaluation
+// options; build it with the evaluators enabled (see Routing).
+func (e *Engine) BuildBook(opts BookOptions) (*Book, error) {
+	if opts.Depth < 0 || opts.Depth > maxBookDepth {
+		return nil, fmt.Errorf("book depth must be 0-%d, got %d", maxBookDepth, opts.Depth)
+	}
+	if opts.Plies < 0 || opts.Plies > maxBookPlies {
+		return nil, fmt.Errorf("book ply must be 0-%d, got %d", maxBookPlies, opts.Plies)
+	}
+	if opts.Top < 0 {
+		return nil, fmt.Errorf("book top must not be negative, got %d", opts.Top)
+	}
+
+	keys, err := e.bookPositions(opts.Depth, opts.Top)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Book{
+		depth: opts.Depth, plies: opts.Plies, top: opts.Top,
+		weights: e.weightsSum(), flags: e.bookFlags(),
+		entries: make(map[positionid.PositionKey][5]float64, len(keys)),
+	}
+	outputs := make([][5]float64, len(keys))
+	errs := make([]error, len(keys))
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	for w := 0; w < runtime.GOMAXPROCS(0); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := int(next.Add(1) - 1); i < len(keys); i = int(next.Add(1) - 1) {
+				state := &GameState{Board: Board(positionid.BoardFromKey(keys[i])), CubeValue: 1, CubeOwner: -1}
+				eval, err := e.evaluateUncached(state, opts.Plies, nil)
+				if err != nil {
+					errs[i] = fmt.Errorf("book position %s: %w", positionid.PositionIDFromKey(keys[i]), err)
+					continue
+				}
+				outputs[i] = eval.probs()
+			}
+		}()
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	for i, key := range keys {
+		b.entries[key] = outputs[i]
+	}
+	return b, nil
+}
+
+// bookPositions returns the keys of the positions reachable within depth
+// plies of the starting position, following the top best moves of each
+// roll (all moves if top is 0), from the side on roll's perspective.
+func (e *Engine) bookPositions(depth, top int) ([]positionid.PositionKey, error) {
+	start := StartingPosition().Board
+	seen := map[positionid.PositionKey]bool{positionid.MakePositionKey(positionid.Board(start)): true}
+	keys := []positionid.PositionKey{positionid.MakePositionKey(positionid.Board(start))}
+	level := []Board{start}
+	var ml MoveList
+	for ply := 0; ply < depth; ply++ {
+		var nextLevel []Board
+		for _, board := range level {
+			for d1 := 1; d1 <= 6; d1++ {
+				for d2 := 1; d2 <= d1; d2++ {
+					GenerateMovesInto(&ml, board, d1, d2)
+					after, err := e.bookReplies(board, ml.Moves, top)
+					if err != nil {
+						return nil, err
+					}
+					for _, next := range after {
+						key := positionid.MakePositionKey(positionid.Board(next))
+						if seen[key] {
+							continue
+						}
+						seen[key] = true
+						keys = append(keys, key)
+						nextLevel = append(nextLevel, next)
+					}
+				}
+			}
+		}
+		level = nextLevel
+	}
+	return keys, nil
+}
+
+// bookReplies returns the positions after the top best of moves in board
+// (all of them if top is 0), from the opponent's perspective. A game that
+// is over ends the line.
+func (e *Engine) bookReplies(board Board, moves []Move, top int) ([]Board, error) {
+	type reply struct {
+		board  Board
+		equity float64
+	}
+	replies := make([]reply, 0, len(moves))
+	for _, m := range moves {
+		next := swapBoardForMultiply(ApplyMove(board, m))
+		replies = append(replies, reply{board: next})
+	}
+	if len(replies) == 0 {
+		// A dance: the opponent is on roll in the same position
+		replies = append(replies, reply{board: swapBoardForMultiply(board)})
+	}
+	if top > 0 && len(replies) > top {
+		for i := range replies {
+			eval, err := e.evaluate(&GameState{Board: replies[i].board, CubeValue: 1, CubeOwner: -1}, nil)
+			if err != nil {
+				return nil, err
+			}
+			// The opponent's equity: lower is better for the mover
+			replies[i].equity = eval.Equity
+		}
+		sort.SliceStable(replies, func(i, j int) bool { return replies[i].equity < replies[j].equity })
+		replies = replies[:top]
+	}
+	boards := make([]Board, 0, len(replies))
+	for _, r := range replies {
+		if e.classify(neuralnet.Board(r.board)) != neuralnet.ClassOver {
+			boards = append(boards, r.board)
+		}
+	}
+	return boards, nil
+}
+
+// Len returns the number of positions in the book.
+func (b *Book) Len() int {
+	return len(b.entries)
+}
+
+// Depth returns the plies from the starting position the book covers.
+func (b *Book) Depth() int {
+	return b.depth
+}
+
+// Plies returns the ply the book's positions are evaluated at.
+func (b *Book) Plies() int {
+	return b.plies
+}
+
+// Stats returns book statistics: lookups at the book's ply, and the hits
+// among them.
+func (b *Book) Stats() (lookups, hits uint64) {
+	return b.lookups.Load(), b.hits.Load()
+}
+
+// HitRate returns the book hit rate as a percentage
+func (b *Book) HitRate() float64 {
+	lookups, hits := b.Stats()
+	if lookups == 0 {
+		return 0
+	}
+	return float64(hits) / float64(lookups) * 100
+}
+
+// lookup returns the book's output of the position key at plies, if the
+// book has it.
+func (b *Book) lookup(key positionid.PositionKey, plies int) ([5]float64, bool) {
+	if plies != b.plies {
+		return [5]float64{}, false
+	}
+	b.lookups.Add(1)
+	output, ok := b.entries[key]
+	if ok {
+		b.hits.Add(1)
+	}
+	return output, ok
+}
+
+// Book returns the engine's book (nil if none)
+func (e *Engine) Book() *Book {
+	return e.book
+}
+
+// SetBook sets the book the engine consults before its cache (use nil for
+// none). It returns ErrBookMismatch for a book built with other weights or
+// evaluation options.
+func (e *Engine) SetBook(b *Book) error {
+	if b != nil && (b.weights != e.weightsSum() || b.flags != e.bookFlags()) {
+		return ErrBookMismatch
+	}
+	e.book = b
+	return nil
+}
+
+// bookEval returns the book's evaluation of state at plies, if the engine
+// has a book with it. Positions with fewer than 15 checkers a side, and
+// any position while an evaluator is switched off (see Routing), are not
+// looked up.
+func (e *Engine) bookEval(state *GameState, plies int, t *Timing) (*Evaluation, bool) {
+	b := e.book
+	if b == nil || plies != b.plies || state.Partial() || e.disableCrashed.Load() || e.disableBearoff.Load() {
+		return nil, false
+	}
+	output, ok := b.lookup(positionid.MakePositionKey(positionid.Board(state.Board)), plies)
+	if !ok {
+		return nil, false
+	}
+	if t != nil {
+		t.BookHits++
+	}
+	eval := &Evaluation{
+		WinProb: output[0],
+		WinG:    output[1],
+		WinBG:   output[2],
+		LoseG:   output[3],
+		LoseBG:  output[4],
+		Source:  e.Source(state),
+	}
+	eval.Equity = eval.WinProb - (1 - eval.WinProb) +
+		eval.WinG - eval.LoseG +
+		eval.WinBG - eval.LoseBG
+	return eval, true
+}
+
+// weightsSum returns the SHA-256 checksum of the engine's weights, zero
+// without any.
+func (e *Engine) weightsSum() [sha256Size]byte {
+	var sum [sha256Size]byte
+	for _, f := range e.data {
+		if f.Kind == "weights" {
+			hex.Decode(sum[:], []byte(f.SHA256))
+			break
+		}
+	}
+	return sum
+}
+
+// bookFlags returns the evaluation options of the engine a book records.
+func (e *Engine) bookFlags() uint8 {
+	var flags uint8
+	if e.evenFallback {
+		flags |= bookEvenFallback
+	}
+	if e.coreNetsOnly {
+		flags |= bookCoreNetsOnly
+	}
+	if e.hybridRace {
+		flags |= bookHybridRace
+	}
+	return flags
+}
+
+// probs returns the probabilities of eval in the order a net outputs them.
+func (eval *Evaluation) probs() [5]float64 {
+	return [5]float64{eval.WinProb, eval.WinG, eval.WinBG, eval.LoseG, eval.LoseBG}
+}
+
+// Book file format, all little endian: the magic "GOBGBOOK", a version
+// byte, depth, ply, top and flag bytes, the 32-byte SHA-256 of the weights
+// and the uint32 number of entries; then each entry's position key (7
+// uint32) and 5 float64 probabilities, sorted by key; then the CRC-32 of
+// everything before it.
+const bookEntrySize = 7*4 + 5*8
+
+// WriteTo writes the book to w in the book file format.
+func (b *Book) WriteTo(w io.Writer) (int64, error) {
+	keys := make([]positionid.PositionKey, 0, len(b.entries))
+	for key := range b.entries {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		for k := range keys[i].Data {
+			if keys[i].Data[k] != keys[j].Data[k] {
+				return keys[i].Data[k] < keys[j].Data[k]
+			}
+		}
+		return false
+	})
+
+	buf := make([]byte, 0, len(bookMagic)+5+sha256Size+4+len(keys)*bookEntrySize+4)
+	buf = append(buf, bookMagic...)
+	buf = append(buf, bookVersion, uint8(b.depth), uint8(b.plies), uint8(min(b.top, math.MaxUint8)), b.flags)
+	buf = append(buf, b.weights[:]...)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(keys)))
+	for _, key := range keys {
+		for _, v := range key.Data {
+			buf = binary.LittleEndian.AppendUint32(buf, v)
+		}
+		for _, p := range b.entries[key] {
+			buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(p))
+		}
+	}
+	buf = binary.LittleEndian.AppendUint32(buf, crc32.ChecksumIEEE(buf))
+	n, err := w.Write(buf)
+	return int64(n), err
+}
+
+// ReadBook reads a book in the book file format from r.
+func ReadBook(r io.Reader) (*Book, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	header := len(bookMagic) + 5 + sha256Size + 4
+	if len(data) < header+4 || string(data[:len(bookMagic)]) != bookMagic {
+		return nil, fmt.Errorf("not a book file")
+	}
+	body, sum := data[:len(data)-4], binary.LittleEndian.Uint32(data[len(data)-4:])
+	if crc32.ChecksumIEEE(body) != sum {
+		return nil, fmt.Errorf("%w: book checksum mismatch", fileutil.ErrCorrupt)
+	}
+	p := data[len(bookMagic):]
+	if p[0] != bookVersion {
+		return nil, fmt.Errorf("unsupported book version %d", p[0])
+	}
+	b := &Book{depth: int(p[1]), plies: int(p[2]), top: int(p[3]), flags: p[4]}
+	copy(b.weights[:], p[5:5+sha256Size])
+	p = p[5+sha256Size:]
+	n := int(binary.LittleEndian.Uint32(p))
+	p = body[header:]
+	if len(p) != n*bookEntrySize {
+		return nil, fmt.Errorf("%w: book has %d bytes of entries, want %d", fileutil.ErrCorrupt, len(p), n*bookEntrySize)
+	}
+	b.entries = make(map[positionid.PositionKey][5]float64, n)
+	for ; len(p) > 0; p = p[bookEntrySize:] {
+		var key positionid.PositionKey
+		for k := range key.Data {
+			key.Data[k] = binary.LittleEndian.Uint32(p[k*4:])
+		}
+		var output [5]float64
+		for k := range output {
+			output[k] = math.Float64frombits(binary.LittleEndian.Uint64(p[28+k*8:]))
+		}
+		b.entries[key] = output
+	}
+	return b, nil
+}
+
+// LoadBook reads a book file.
+func LoadBook(path string) (*Book, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	b, err := ReadBook(bufio.NewReader(f))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return b, nil
+}
+
+// Save writes the book to the file path, replacing it atomically.
+func (b *Book) Save(path string) error {
+	var buf bytes.Buffer
+	if _, err := b.WriteTo(&buf); err != nil {
+		return err
+	}
+	return fileutil.AtomicWriteFile(path, buf.Bytes(), 0o644)
+}
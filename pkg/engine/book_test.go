@@ -0,0 +1,231 @@
+package engine
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/yourusername/bgengine/internal/fileutil"
+	"github.com/yourusername/bgengine/internal/positionid"
+)
+
+// buildTestBook builds a book with opts on a heuristic engine.
+func buildTestBook(t testing.TB, opts BookOptions) (*Engine, *Book) {
+	t.Helper()
+	e, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	book, err := e.BuildBook(opts)
+	if err != nil {
+		t.Fatalf("BuildBook(%+v) failed: %v", opts, err)
+	}
+	return e, book
+}
+
+func TestBuildBookPositions(t *testing.T) {
+	_, book := buildTestBook(t, BookOptions{Depth: 1})
+	// The starting position and every position after an opening move, from
+	// the opponent's perspective
+	start := StartingPosition()
+	want := map[positionid.PositionKey]bool{positionid.MakePositionKey(positionid.Board(start.Board)): true}
+	for d1 := 1; d1 <= 6; d1++ {
+		for d2 := 1; d2 <= d1; d2++ {
+			for _, m := range GenerateMoves(start.Board, d1, d2).Moves {
+				want[positionid.MakePositionKey(positionid.Board(swapBoardForMultiply(ApplyMove(start.Board, m))))] = true
+			}
+		}
+	}
+	if book.Len() != len(want) {
+		t.Errorf("book has %d positions, want %d", book.Len(), len(want))
+	}
+	for key := range want {
+		if _, ok := book.entries[key]; !ok {
+			t.Errorf("book lacks %s", positionid.PositionIDFromKey(key))
+		}
+	}
+
+	_, top := buildTestBook(t, BookOptions{Depth: 2, Top: 1})
+	if top.Len() < 1+21 || top.Len() > 1+21+21*21 {
+		t.Errorf("book of the best replies has %d positions, want 22 to %d", top.Len(), 1+21+21*21)
+	}
+
+	e, _ := buildTestBook(t, BookOptions{})
+	for _, opts := range []BookOptions{{Depth: -1}, {Depth: maxBookDepth + 1}, {Plies: maxBookPlies + 1}, {Top: -1}} {
+		if _, err := e.BuildBook(opts); err == nil {
+			t.Errorf("BuildBook(%+v) succeeded, want an error", opts)
+		}
+	}
+}
+
+// TestBookMatchesLiveEvaluation checks that book hits return exactly what
+// evaluating the position at the book's ply returns.
+func TestBookMatchesLiveEvaluation(t *testing.T) {
+	e, book := buildTestBook(t, BookOptions{Depth: 1, Plies: 1})
+	if err := e.SetBook(book); err != nil {
+		t.Fatalf("SetBook failed: %v", err)
+	}
+	live, _ := NewEngine(EngineOptions{})
+	live.SetCache(nil)
+
+	for key := range book.entries {
+		state := &GameState{Board: Board(positionid.BoardFromKey(key)), CubeValue: 1, CubeOwner: -1}
+		var timing Timing
+		got, err := e.evaluateCached(state, 1, &timing)
+		if err != nil {
+			t.Fatalf("evaluateCached failed: %v", err)
+		}
+		want, err := live.EvaluateCached(state, 1)
+		if err != nil {
+			t.Fatalf("EvaluateCached failed: %v", err)
+		}
+		if *got != *want {
+			t.Fatalf("%s: book %+v, live %+v", positionid.PositionIDFromKey(key), got, want)
+		}
+		if timing.BookHits != 1 || timing.Evaluations != 0 {
+			t.Fatalf("%s: timing %+v, want one book hit and no evaluation", positionid.PositionIDFromKey(key), timing)
+		}
+	}
+	lookups, hits := book.Stats()
+	if lookups != uint64(book.Len()) || hits != lookups || book.HitRate() != 100 {
+		t.Errorf("stats = %d lookups, %d hits (%.1f%%), want %d of each", lookups, hits, book.HitRate(), book.Len())
+	}
+
+	// The book answers only at its own ply
+	if _, ok := e.bookEval(StartingPosition(), 0, nil); ok {
+		t.Error("a 1-ply book answered a 0-ply lookup")
+	}
+
+	// A 2-ply evaluation finds the 1-ply evaluations after each opening
+	// move in the book, and comes out the same
+	start := StartingPosition()
+	var timing Timing
+	got, err := e.evaluateNPly(start, 2, true, time.Time{}, &timing)
+	if err != nil {
+		t.Fatalf("evaluateNPly failed: %v", err)
+	}
+	want, err := live.EvaluatePlied(start, 2)
+	if err != nil {
+		t.Fatalf("EvaluatePlied failed: %v", err)
+	}
+	if *got != *want {
+		t.Errorf("2-ply with book %+v, without %+v", got, want)
+	}
+	if timing.BookHits == 0 {
+		t.Error("2-ply evaluation of the starting position had no book hits")
+	}
+}
+
+func TestBookRoundTrip(t *testing.T) {
+	e, book := buildTestBook(t, BookOptions{Depth: 1})
+	var buf bytes.Buffer
+	if _, err := book.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo failed: %v", err)
+	}
+	data := buf.Bytes()
+
+	read, err := ReadBook(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("ReadBook failed: %v", err)
+	}
+	if read.Depth() != 1 || read.Plies() != 0 || read.Len() != book.Len() {
+		t.Errorf("read depth %d, ply %d, %d positions, want 1, 0, %d", read.Depth(), read.Plies(), read.Len(), book.Len())
+	}
+	for key, output := range book.entries {
+		if read.entries[key] != output {
+			t.Fatalf("%s: read %v, want %v", positionid.PositionIDFromKey(key), read.entries[key], output)
+		}
+	}
+	if err := e.SetBook(read); err != nil {
+		t.Errorf("SetBook of the book read back failed: %v", err)
+	}
+
+	corrupt := append([]byte(nil), data...)
+	corrupt[len(corrupt)/2] ^= 0xff
+	if _, err := ReadBook(bytes.NewReader(corrupt)); !errors.Is(err, fileutil.ErrCorrupt) {
+		t.Errorf("ReadBook of a corrupt book: %v, want ErrCorrupt", err)
+	}
+	if _, err := ReadBook(bytes.NewReader([]byte("not a book"))); err == nil {
+		t.Error("ReadBook of a non-book succeeded")
+	}
+}
+
+func TestSetBookMismatch(t *testing.T) {
+	_, book := buildTestBook(t, BookOptions{})
+	other, err := NewEngine(EngineOptions{EvenFallback: true})
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	if err := other.SetBook(book); !errors.Is(err, ErrBookMismatch) {
+		t.Errorf("SetBook of a book built with other options: %v, want ErrBookMismatch", err)
+	}
+	if other.Book() != nil {
+		t.Error("the mismatched book was set")
+	}
+}
+
+func TestBookFileOption(t *testing.T) {
+	_, book := buildTestBook(t, BookOptions{Depth: 1})
+	path := filepath.Join(t.TempDir(), "book.bin")
+	if err := book.Save(path); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	e, err := NewEngine(EngineOptions{BookFile: path})
+	if err != nil {
+		t.Fatalf("NewEngine with BookFile failed: %v", err)
+	}
+	if e.Book() == nil || e.Book().Len() != book.Len() {
+		t.Fatalf("engine book = %v, want %d positions", e.Book(), book.Len())
+	}
+	if _, err := NewEngine(EngineOptions{BookFile: path, HybridRace: true}); !errors.Is(err, ErrBookMismatch) {
+		t.Errorf("NewEngine with a mismatched book: %v, want ErrBookMismatch", err)
+	}
+	if _, err := NewEngine(EngineOptions{BookFile: filepath.Join(t.TempDir(), "missing.bin")}); err == nil {
+		t.Error("NewEngine with a missing book succeeded")
+	}
+}
+
+// BenchmarkRolloutBook rolls out the starting position truncated after two
+// plies with 1-ply leaves, whose leaves a book of the best replies covers.
+// The cache is flushed for every rollout, as for the first rollout of a
+// server.
+func BenchmarkRolloutBook(b *testing.B) {
+	_, book := buildTestBook(b, BookOptions{Depth: 2, Plies: 1, Top: 1})
+	opts := RolloutOptions{
+		Trials:   1296,
+		Truncate: 2,
+		LeafPly:  1,
+		Workers:  runtime.GOMAXPROCS(0),
+		Seed:     12345,
+	}
+	for _, withBook := range []bool{false, true} {
+		name := "NoBook"
+		if withBook {
+			name = "Book"
+		}
+		b.Run(name, func(b *testing.B) {
+			e, _ := NewEngine(EngineOptions{})
+			if withBook {
+				if err := e.SetBook(book); err != nil {
+					b.Fatalf("SetBook failed: %v", err)
+				}
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				e.Cache().Flush()
+				b.StartTimer()
+				if _, err := e.Rollout(StartingPosition(), opts); err != nil {
+					b.Fatalf("Rollout failed: %v", err)
+				}
+			}
+			if withBook {
+				b.ReportMetric(book.HitRate(), "book-hit-%")
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package engine
+
+import (
+	"runtime/debug"
+	"strings"
+)
+
+// defaultVersion is the version of builds that carry no module version,
+// such as test binaries.
+const defaultVersion = "0.1.0"
+
+// Version is the version of the engine: the module version it was built
+// as, without its "v", or defaultVersion if the build records none.
+var Version = build.Version
+
+// build is what the binary records about how it was built, read once.
+var build = readBuild()
+
+// BuildInfo identifies the engine build and data that produced a result, so
+// an analysis or rollout can be told apart from one made by another build or
+// with other weights. It is recorded in rollout manifests, match analyses
+// and API responses.
+type BuildInfo struct {
+	Version   string     `json:"engine_version"`            // See Version
+	Revision  string     `json:"engine_revision,omitempty"` // VCS revision the binary was built from, when embedded
+	Modified  bool       `json:"engine_modified,omitempty"` // Built from a working tree with uncommitted changes
+	GoVersion string     `json:"go_version,omitempty"`      // Go toolchain the binary was built with
+	Data      []DataFile `json:"data,omitempty"`            // Data files, with checksums (see DataStatus)
+}
+
+// readBuild reads the version, revision and toolchain from the build
+// information the Go linker embeds.
+func readBuild() BuildInfo {
+	b := BuildInfo{Version: defaultVersion}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return b
+	}
+	b.GoVersion = info.GoVersion
+	if v := info.Main.Version; v != "" && v != "(devel)" {
+		b.Version = strings.TrimPrefix(v, "v")
+	}
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			b.Revision = s.Value
+		case "vcs.modified":
+			b.Modified = s.Value == "true"
+		}
+	}
+	return b
+}
+
+// BuildInfo returns the build of the engine and the data files it was
+// created from. The checksums are those computed when the engine was
+// created, so it costs no file reads.
+func (e *Engine) BuildInfo() *BuildInfo {
+	b := build
+	b.Data = e.DataStatus()
+	return &b
+}
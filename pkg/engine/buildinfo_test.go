@@ -0,0 +1,92 @@
+package engine
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBuildInfo(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "two.xml")
+	table := `<met>
+  <info><name>Two</name><length>2</length></info>
+  <pre-crawford-table type="explicit">
+    <row><me>0.5</me><me>0.7</me></row>
+    <row><me>0.3</me><me>0.5</me></row>
+  </pre-crawford-table>
+</met>`
+	if err := os.WriteFile(path, []byte(table), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	e, err := NewEngine(EngineOptions{METFile: path, SkipWarmup: true})
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	b := e.BuildInfo()
+	if b.Version != Version || b.Version == "" || !reflect.DeepEqual(b.Data, e.DataStatus()) {
+		t.Errorf("BuildInfo = %+v, want version %q and data %+v", b, Version, e.DataStatus())
+	}
+
+	// The checksums are those taken at load, not read again
+	if err := os.WriteFile(path, []byte("changed"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if again := e.BuildInfo(); !reflect.DeepEqual(again, b) {
+		t.Errorf("BuildInfo after the file changed = %+v, want %+v", again, b)
+	}
+	// Nor shared with the caller
+	b.Data[0].SHA256 = "x"
+	if e.BuildInfo().Data[0].SHA256 == "x" {
+		t.Error("BuildInfo shares its data files with an earlier result")
+	}
+}
+
+func TestBuildInfoPersists(t *testing.T) {
+	e, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	state := StartingPosition()
+	result, err := e.Rollout(state, RolloutOptions{Trials: 36, Truncate: 2})
+	if err != nil {
+		t.Fatalf("Rollout failed: %v", err)
+	}
+	want := *e.BuildInfo()
+	if result.Manifest == nil || !reflect.DeepEqual(result.Manifest.BuildInfo, want) {
+		t.Fatalf("manifest = %+v, want build %+v", result.Manifest, want)
+	}
+
+	path := filepath.Join(t.TempDir(), "rollouts.jsonl")
+	s, err := OpenFileRolloutStore(path)
+	if err != nil {
+		t.Fatalf("OpenFileRolloutStore failed: %v", err)
+	}
+	key := NewRolloutKey(state, RolloutOptions{Trials: 36, Truncate: 2})
+	if err := s.Put(&StoredRollout{Key: key, Result: result, Created: time.Now(), Updated: time.Now()}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	s.Close()
+	if s, err = OpenFileRolloutStore(path); err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer s.Close()
+	stored, err := s.Get(key)
+	if err != nil || stored == nil || stored.Result.Manifest == nil {
+		t.Fatalf("Get = %+v, %v", stored, err)
+	}
+	if got := stored.Result.Manifest.BuildInfo; !reflect.DeepEqual(got, want) {
+		t.Errorf("stored manifest build = %+v, want %+v", got, want)
+	}
+
+	// Manifests written before the build was recorded still read
+	var old RolloutManifest
+	if err := json.Unmarshal([]byte(`{"engine_version":"0.1.0","data":[{"kind":"met","size":3,"sha256":"ab"}],"position":"4HPwATDgc/ABMA"}`), &old); err != nil {
+		t.Fatal(err)
+	}
+	if old.Version != "0.1.0" || len(old.Data) != 1 || old.Revision != "" {
+		t.Errorf("old manifest = %+v", old)
+	}
+}
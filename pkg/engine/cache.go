@@ -2,6 +2,7 @@ package engine
 
 import (
 	"sync"
+	"sync/atomic"
 
 	"github.com/yourusername/bgengine/internal/positionid"
 )
@@ -12,10 +13,16 @@ const (
 	CacheHit         = ^uint32(0)
 )
 
+// EvalContextLayout is the version of the evaluation context layout made by
+// MakeEvalContext, and so of CacheEntry. It changes whenever the bits do:
+// layout 2 added the player on roll and the checker totals of partial
+// positions. Contexts or entries kept from an older layout do not match.
+const EvalContextLayout = 2
+
 // CacheEntry stores a cached evaluation result
 type CacheEntry struct {
 	Key         positionid.PositionKey // Position key (7 uint32s = 28 bytes)
-	EvalContext int32                  // Evaluation context (plies, cube info, etc.)
+	EvalContext int32                  // Evaluation context (plies, cube, player on roll, checker totals; see MakeEvalContext)
 	Output      [6]float32             // 5 probabilities + 1 cubeful equity
 }
 
@@ -26,10 +33,11 @@ type EvalCache struct {
 	size     uint32
 	hashMask uint32
 
-	// Statistics
-	lookups uint64
-	hits    uint64
-	adds    uint64
+	// Statistics. Lookup only holds the read lock, so concurrent readers
+	// update these atomically.
+	lookups atomic.Uint64
+	hits    atomic.Uint64
+	adds    atomic.Uint64
 
 	mu sync.RWMutex
 }
@@ -75,9 +83,9 @@ func (c *EvalCache) Flush() {
 		c.entries[i].primary.Key = invalidKey
 		c.entries[i].secondary.Key = invalidKey
 	}
-	c.lookups = 0
-	c.hits = 0
-	c.adds = 0
+	c.lookups.Store(0)
+	c.hits.Store(0)
+	c.adds.Store(0)
 }
 
 // hash computes the hash key for a cache entry using MurmurHash3-style mixing
@@ -130,14 +138,14 @@ func (c *EvalCache) Lookup(key positionid.PositionKey, evalContext int32, output
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	c.lookups++
+	c.lookups.Add(1)
 
 	node := &c.entries[slot]
 
 	// Check primary slot
 	if keysEqual(node.primary.Key, key) && node.primary.EvalContext == evalContext {
 		copy(output, node.primary.Output[:5])
-		c.hits++
+		c.hits.Add(1)
 		return CacheHit
 	}
 
@@ -145,7 +153,7 @@ func (c *EvalCache) Lookup(key positionid.PositionKey, evalContext int32, output
 	if keysEqual(node.secondary.Key, key) && node.secondary.EvalContext == evalContext {
 		// Promote to primary (will be done in Add if we miss)
 		copy(output, node.secondary.Output[:5])
-		c.hits++
+		c.hits.Add(1)
 		return CacheHit
 	}
 
@@ -168,34 +176,36 @@ func (c *EvalCache) Add(key positionid.PositionKey, evalContext int32, output []
 	}
 	copy(node.primary.Output[:], output[:5])
 
-	c.adds++
+	c.adds.Add(1)
 }
 
 // Stats returns cache statistics
 func (c *EvalCache) Stats() (lookups, hits, adds uint64) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	return c.lookups, c.hits, c.adds
+	return c.lookups.Load(), c.hits.Load(), c.adds.Load()
 }
 
 // HitRate returns the cache hit rate as a percentage
 func (c *EvalCache) HitRate() float64 {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	if c.lookups == 0 {
+	lookups, hits := c.lookups.Load(), c.hits.Load()
+	if lookups == 0 {
 		return 0
 	}
-	return float64(c.hits) / float64(c.lookups) * 100
+	return float64(hits) / float64(lookups) * 100
 }
 
 // MakeEvalContext creates an evaluation context key from evaluation parameters
-// This encodes plies, cube info, etc. into a single int32 for cache keying
-func MakeEvalContext(plies int, cubeful bool, cubeOwner int, cubeValue int) int32 {
-	// Bit layout:
+// This encodes plies, cube info, etc. into a single int32 for cache keying.
+// The cube owner is a player index, so the player on roll (turn) is part of
+// the key too: without it, an entry for one player owning the cube would
+// answer for the other.
+func MakeEvalContext(plies int, cubeful bool, cubeOwner int, cubeValue int, turn int) int32 {
+	// Bit layout (EvalContextLayout 2):
 	// Bits 0-3: plies (0-15)
 	// Bit 4: cubeful
 	// Bits 5-6: cube owner (-1=centered, 0=player0, 1=player1) + 1
 	// Bits 7-10: log2(cubeValue)
+	// Bit 11: player on roll
+	// Bits 12-19: checkers each side is short of 15 (see partialEvalContext)
 
 	ctx := int32(plies & 0xF)
 	if cubeful {
@@ -209,6 +219,18 @@ func MakeEvalContext(plies int, cubeful bool, cubeOwner int, cubeValue int) int3
 		logCube++
 	}
 	ctx |= int32(logCube&0xF) << 7
+	ctx |= int32(turn&1) << 11
 
 	return ctx
 }
+
+// partialEvalContext adds the checker totals of a partial position (see
+// GameState.TotalCheckers) to an evaluation context: the same board has
+// other gammon chances when the checkers missing from it are left out rather
+// than borne off.
+func partialEvalContext(ctx int32, totals [2]int) int32 {
+	for side, total := range totals {
+		ctx |= int32((CheckersPerSide-total)&0xF) << (12 + 4*side)
+	}
+	return ctx
+}
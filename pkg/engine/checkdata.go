@@ -0,0 +1,123 @@
+package engine
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/yourusername/bgengine/internal/bearoff"
+	"github.com/yourusername/bgengine/internal/met"
+	"github.com/yourusername/bgengine/internal/neuralnet"
+)
+
+// DataCheck is the outcome of checking one data file (see CheckData).
+type DataCheck struct {
+	Kind string // "weights", "bearoff", "bearoff-ts", "bearoff-os" or "met"
+	Path string
+	Info string // What the file holds, when it checks out
+	Err  error
+}
+
+// CheckData checks the data files opts names, more strictly than NewEngine
+// loads them: weights files are loaded and their nets' dimensions checked
+// against the inputs the engine feeds them, bearoff databases must have the
+// size their header and positions call for, and match equity tables must
+// follow gnubg's MET format exactly (see met.CheckXML). Files opts leaves
+// empty are not checked.
+func CheckData(opts EngineOptions) []DataCheck {
+	var checks []DataCheck
+	check := func(kind, path string, f func(string) (string, error)) {
+		if path != "" {
+			info, err := f(path)
+			checks = append(checks, DataCheck{Kind: kind, Path: path, Info: info, Err: err})
+		}
+	}
+
+	check("weights", opts.WeightsFile, func(path string) (string, error) {
+		return checkWeights(neuralnet.LoadWeightsBinary(path))
+	})
+	check("weights", opts.WeightsFileText, func(path string) (string, error) {
+		return checkWeights(neuralnet.LoadWeightsText(path))
+	})
+	check("bearoff", opts.BearoffFile, func(path string) (string, error) {
+		return checkBearoff(path, bearoff.BearoffOneSided, 1)
+	})
+	check("bearoff-ts", opts.BearoffTSFile, func(path string) (string, error) {
+		return checkBearoff(path, bearoff.BearoffTwoSided, 1)
+	})
+	check("bearoff-os", opts.BearoffOSFile, func(path string) (string, error) {
+		return checkBearoff(path, bearoff.BearoffOneSided, 7)
+	})
+	for _, path := range append([]string{opts.METFile}, opts.METFiles...) {
+		check("met", path, checkMET)
+	}
+	return checks
+}
+
+func checkWeights(w *neuralnet.Weights, err error) (string, error) {
+	if err == nil {
+		err = w.Validate()
+	}
+	if err != nil {
+		return "", err
+	}
+	var nets []string
+	for _, n := range []struct {
+		name string
+		nn   *neuralnet.NeuralNet
+	}{
+		{"contact", w.Contact}, {"race", w.Race}, {"crashed", w.Crashed},
+		{"pruning contact", w.PContact}, {"pruning crashed", w.PCrashed}, {"pruning race", w.PRace},
+	} {
+		nets = append(nets, fmt.Sprintf("%s %d-%d-%d", n.name, n.nn.CInput, n.nn.CHidden, n.nn.COutput))
+	}
+	return strings.Join(nets, ", "), nil
+}
+
+// checkBearoff checks the bearoff database in path, which must be of type
+// want and cover at least minPoints points. The database is read from disk
+// rather than loaded, as the larger one-sided databases run to gigabytes.
+func checkBearoff(path string, want bearoff.BearoffType, minPoints int) (string, error) {
+	db, err := bearoff.OpenOneSided(path)
+	if err != nil {
+		return "", err
+	}
+	defer db.Close()
+	if db.Type != want {
+		return "", fmt.Errorf("expected a %s database, got type %d", bearoffKind(want), db.Type)
+	}
+	if db.NPoints < minPoints {
+		return "", fmt.Errorf("expected a database of at least %d points, got %d", minPoints, db.NPoints)
+	}
+	if err := db.Check(); err != nil {
+		return "", err
+	}
+	info := fmt.Sprintf("%s, %d points, %d checkers, %d positions", bearoffKind(want), db.NPoints, db.NChequers, db.NumPositions())
+	switch {
+	case db.Compressed:
+		info += ", compressed"
+	case db.Cubeful:
+		info += ", cubeful"
+	}
+	return info, nil
+}
+
+func bearoffKind(t bearoff.BearoffType) string {
+	if t == bearoff.BearoffTwoSided {
+		return "two-sided"
+	}
+	return "one-sided"
+}
+
+func checkMET(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	t, err := met.CheckXML(f)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s, %d points", t.Name, t.Length), nil
+}
@@ -0,0 +1,55 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckData(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	// A one-sided bearoff database of 1 point and 1 checker (2 positions,
+	// uncompressed) with its last record cut short
+	header := "gnubg-OS-01-01-0-0-0" + strings.Repeat(" ", 20)
+	bearoffDB := write("os.bd", header+strings.Repeat("\x00", 2*64-1))
+
+	table := `<met>
+  <info><name>Two</name><length>2</length></info>
+  <pre-crawford-table type="explicit">
+    <row><me>0.5</me><me>0.7</me></row>
+    <row><me>0.3</me><me>0.5</me></row>
+  </pre-crawford-table>
+</met>`
+
+	checks := CheckData(EngineOptions{
+		WeightsFileText: write("gnubg.weights", "GNU Backgammon 0.16\r\n"),
+		BearoffFile:     bearoffDB,
+		METFile:         write("two.xml", table),
+		METFiles:        []string{filepath.Join(dir, "missing.xml")},
+	})
+
+	want := []struct{ kind, info, err string }{
+		{"weights", "", "weights file, header, line 1: version 0.16, want 1.00"},
+		{"bearoff", "", "1 points and 1 checkers (2 positions) call for 168 bytes, the file has 167"},
+		{"met", "Two, 2 points", ""},
+		{"met", "", "no such file"},
+	}
+	if len(checks) != len(want) {
+		t.Fatalf("CheckData returned %d checks, want %d: %+v", len(checks), len(want), checks)
+	}
+	for i, w := range want {
+		c := checks[i]
+		if c.Kind != w.kind || c.Info != w.info || (c.Err == nil) != (w.err == "") || (c.Err != nil && !strings.Contains(c.Err.Error(), w.err)) {
+			t.Errorf("check %d = %+v, want kind %q, info %q, error %q", i, c, w.kind, w.info, w.err)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package engine
+
+import (
+	"fmt"
+
+	"github.com/yourusername/bgengine/internal/neuralnet"
+)
+
+// A consensus analysis checks a move decision against the engine's own
+// sources of evaluation and reports where they disagree: the best move at 0
+// and at 1 ply, the 1-ply ranking cubeless and cubeful, and, when the best
+// move leads to a bearoff, the race net against the bearoff databases or the
+// exact solver. Where they disagree the decision is a close or a doubtful
+// one, worth a deeper look.
+
+// ConsensusLevel classifies how far the sources of a consensus analysis agree.
+type ConsensusLevel int
+
+const (
+	ConsensusUnanimous         ConsensusLevel = iota // Same move, equities within ConsensusMinorGap
+	ConsensusMinorDisagreement                       // Different moves, or equities ConsensusMinorGap or more apart
+	ConsensusConflict                                // Equities ConsensusConflictGap or more apart
+)
+
+// String returns the name of the level as the API reports it.
+func (l ConsensusLevel) String() string {
+	switch l {
+	case ConsensusUnanimous:
+		return "unanimous"
+	case ConsensusMinorDisagreement:
+		return "minor_disagreement"
+	case ConsensusConflict:
+		return "conflict"
+	}
+	return fmt.Sprintf("ConsensusLevel(%d)", int(l))
+}
+
+// Equity gaps between two sources at which a consensus analysis counts them
+// as disagreeing.
+const (
+	ConsensusMinorGap    = 0.01
+	ConsensusConflictGap = 0.04
+)
+
+// ConsensusSource is the verdict of one source: the move it plays and the
+// mover's equity it gives that move.
+type ConsensusSource struct {
+	Name   string // "0-ply", "1-ply", "cubeless", "cubeful", or the Evaluation.Source of a static evaluation
+	Move   Move
+	Equity float64
+}
+
+// ConsensusCheck compares the verdicts of two sources.
+type ConsensusCheck struct {
+	Kind    string          // "ply", "cube" or "bearoff"
+	A, B    ConsensusSource // The shallower or cheaper source first
+	Gap     float64         // How far apart they are, in equity (see ConsensusAnalysis)
+	Level   ConsensusLevel
+	Message string // e.g. "0-ply and 1-ply disagree by 0.040 (0-ply plays 24/23 13/10, 1-ply 8/5 6/5)"
+}
+
+// ConsensusResult is the result of ConsensusAnalysis.
+type ConsensusResult struct {
+	BestMove MoveWithEval     // Best move at 1 ply, ranked cubeless
+	NumMoves int              // Total number of legal moves
+	Checks   []ConsensusCheck // The checks that apply to the position
+	Level    ConsensusLevel   // The least agreement of any check
+
+	// Recommendation suggests what to do about the worst disagreement, e.g.
+	// "0-ply and 1-ply disagree by 0.040 (...): consider a rollout"; empty
+	// when the sources are unanimous.
+	Recommendation string
+}
+
+// ConsensusAnalysis analyzes the move decision of state with dice with
+// each of the engine's sources and reports where they disagree. It runs
+// these checks:
+//
+//   - ply: the 0-ply best move against the 1-ply one. The gap is what the
+//     0-ply choice costs by 1 ply.
+//   - cube: the 1-ply best move ranked cubeless against the best ranked
+//     cubeful. The gap is what the cubeless choice costs cubeful.
+//   - bearoff: the race net against the bearoff databases or the exact
+//     solver on the position after the best move, if that is a bearoff the
+//     engine looks up. The gap is the difference of their equities.
+//
+// Two sources agree when they pick the same move and their gap is under
+// ConsensusMinorGap, disagree slightly when they pick different moves or
+// the gap is larger, and conflict from ConsensusConflictGap. A position
+// without legal moves has no checks and is unanimous.
+func (e *Engine) ConsensusAnalysis(state *GameState, dice [2]int) (*ConsensusResult, error) {
+	zero, err := e.AnalyzePositionWithOptions(state, dice, EvalOptions{UsePrune: true})
+	if err != nil {
+		return nil, err
+	}
+	result := &ConsensusResult{NumMoves: zero.NumMoves}
+	if zero.NumMoves == 0 {
+		return result, nil
+	}
+	one, err := e.AnalyzePositionWithOptions(state, dice, EvalOptions{Plies: 1, UsePrune: true})
+	if err != nil {
+		return nil, err
+	}
+	best := one.Moves[0]
+	result.BestMove = best
+
+	// The 0-ply choice, as 0 and as 1 ply see it
+	zeroBest := zero.Moves[0]
+	zeroAtOne := findMove(one.Moves, zeroBest.Move)
+	result.Checks = append(result.Checks, newConsensusCheck("ply",
+		ConsensusSource{Name: "0-ply", Move: zeroBest.Move, Equity: zeroBest.Equity},
+		ConsensusSource{Name: "1-ply", Move: best.Move, Equity: best.Equity},
+		best.Equity-zeroAtOne.Equity))
+
+	cubeful := best
+	for _, m := range one.Moves {
+		if m.CubefulEquity > cubeful.CubefulEquity {
+			cubeful = m
+		}
+	}
+	result.Checks = append(result.Checks, newConsensusCheck("cube",
+		ConsensusSource{Name: "cubeless", Move: best.Move, Equity: best.Equity},
+		ConsensusSource{Name: "cubeful", Move: cubeful.Move, Equity: cubeful.CubefulEquity},
+		cubeful.CubefulEquity-best.CubefulEquity))
+
+	if check, ok := e.bearoffConsensus(state, best.Move); ok {
+		result.Checks = append(result.Checks, check)
+	}
+
+	result.finish()
+	return result, nil
+}
+
+// bearoffConsensus compares the race net with the bearoff evaluation of the
+// position after m, and returns false if the engine would not look that
+// position up in a bearoff database or solve it exactly.
+func (e *Engine) bearoffConsensus(state *GameState, m Move) (ConsensusCheck, bool) {
+	after := afterMove(state, m)
+	board, totals := neuralnet.Board(after.Board), after.checkerTotals()
+	class := e.classify(board)
+	if !isBearoffClass(class) || e.source(board, class) != SourceBearoff {
+		return ConsensusCheck{}, false
+	}
+
+	net, err := e.evaluateRace(board, nil)
+	if err != nil {
+		return ConsensusCheck{}, false
+	}
+	clearImpossibleGammons(board, totals, &net)
+	sanitizeOutput(&net, class.String())
+	exact, err := e.evaluateClass(board, class, totals, nil)
+	if err != nil {
+		return ConsensusCheck{}, false
+	}
+
+	netName := SourceNet
+	if e.race == nil {
+		netName = SourceHeuristic
+	}
+	// The opponent is on roll after the move
+	netEquity, exactEquity := -outputEquity(net), -outputEquity(exact)
+	gap := netEquity - exactEquity
+	if gap < 0 {
+		gap = -gap
+	}
+	return newConsensusCheck("bearoff",
+		ConsensusSource{Name: netName, Move: m, Equity: netEquity},
+		ConsensusSource{Name: SourceBearoff, Move: m, Equity: exactEquity},
+		gap), true
+}
+
+// newConsensusCheck compares a and b, which are gap apart, and describes
+// how far they agree.
+func newConsensusCheck(kind string, a, b ConsensusSource, gap float64) ConsensusCheck {
+	c := ConsensusCheck{Kind: kind, A: a, B: b, Gap: gap}
+	differ := !sameMove(a.Move, b.Move)
+	switch {
+	case gap >= ConsensusConflictGap:
+		c.Level = ConsensusConflict
+	case gap >= ConsensusMinorGap || differ:
+		c.Level = ConsensusMinorDisagreement
+	}
+
+	if c.Level == ConsensusUnanimous {
+		c.Message = fmt.Sprintf("%s and %s agree", a.Name, b.Name)
+	} else {
+		c.Message = fmt.Sprintf("%s and %s disagree by %.3f", a.Name, b.Name, gap)
+	}
+	if differ {
+		c.Message += fmt.Sprintf(" (%s plays %s, %s %s)", a.Name, FormatMove(a.Move), b.Name, FormatMove(b.Move))
+	} else if kind == "bearoff" {
+		c.Message += " after " + FormatMove(a.Move)
+	}
+	return c
+}
+
+// finish sets the level of r from its checks and recommends what to do
+// about the worst of them.
+func (r *ConsensusResult) finish() {
+	var worst *ConsensusCheck
+	for i := range r.Checks {
+		c := &r.Checks[i]
+		if worst == nil || c.Level > worst.Level || (c.Level == worst.Level && c.Gap > worst.Gap) {
+			worst = c
+		}
+	}
+	if worst == nil {
+		return
+	}
+	r.Level = worst.Level
+	switch worst.Level {
+	case ConsensusConflict:
+		r.Recommendation = worst.Message + ": consider a rollout"
+	case ConsensusMinorDisagreement:
+		r.Recommendation = worst.Message + ": consider a 2-ply analysis"
+	}
+}
+
+// findMove returns the move of moves that is m, which every analysis of the
+// same roll has.
+func findMove(moves []MoveWithEval, m Move) MoveWithEval {
+	for _, mv := range moves {
+		if sameMove(mv.Move, m) {
+			return mv
+		}
+	}
+	return MoveWithEval{Move: m}
+}
+
+// sameMove reports whether a and b move the same checkers the same way.
+func sameMove(a, b Move) bool {
+	return a.From == b.From && a.To == b.To
+}
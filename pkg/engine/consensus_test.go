@@ -0,0 +1,166 @@
+package engine
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConsensusChecks(t *testing.T) {
+	start := StartingPosition().Board
+	moves := GenerateMoves(start, 3, 1).Moves
+	a, b := moves[0], moves[1]
+
+	tests := []struct {
+		name   string
+		b      Move
+		gap    float64
+		want   ConsensusLevel
+		differ bool
+	}{
+		{"same move, close", a, 0.005, ConsensusUnanimous, false},
+		{"same move, apart", a, 0.02, ConsensusMinorDisagreement, false},
+		{"different moves, close", b, 0.002, ConsensusMinorDisagreement, true},
+		{"different moves, far apart", b, 0.04, ConsensusConflict, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newConsensusCheck("ply", ConsensusSource{Name: "0-ply", Move: a}, ConsensusSource{Name: "1-ply", Move: tt.b}, tt.gap)
+			if c.Level != tt.want {
+				t.Errorf("level %v, want %v", c.Level, tt.want)
+			}
+			if names := "(0-ply plays " + FormatMove(a) + ", 1-ply " + FormatMove(b) + ")"; strings.Contains(c.Message, names) != tt.differ {
+				t.Errorf("message %q, moves named: %t", c.Message, tt.differ)
+			}
+
+			r := &ConsensusResult{Checks: []ConsensusCheck{c, newConsensusCheck("cube", ConsensusSource{Move: a}, ConsensusSource{Move: a}, 0)}}
+			r.finish()
+			if r.Level != tt.want || (r.Recommendation == "") != (tt.want == ConsensusUnanimous) {
+				t.Errorf("result level %v, recommendation %q", r.Level, r.Recommendation)
+			}
+			if tt.want == ConsensusConflict && !strings.HasSuffix(r.Recommendation, "consider a rollout") {
+				t.Errorf("recommendation %q, want a rollout", r.Recommendation)
+			}
+		})
+	}
+}
+
+func TestConsensusAnalysis(t *testing.T) {
+	e, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	state := StartingPosition()
+	for _, dice := range [][2]int{{3, 1}, {6, 5}, {4, 4}} {
+		r, err := e.ConsensusAnalysis(state, dice)
+		if err != nil {
+			t.Fatalf("%v: ConsensusAnalysis failed: %v", dice, err)
+		}
+		one, err := e.AnalyzePositionWithOptions(state, dice, EvalOptions{Plies: 1, UsePrune: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !sameMove(r.BestMove.Move, one.BestMove) || r.NumMoves != one.NumMoves {
+			t.Errorf("%v: best move %s of %d, want the 1-ply best %s of %d", dice, FormatMove(r.BestMove.Move), r.NumMoves, FormatMove(one.BestMove), one.NumMoves)
+		}
+		// No bearoff after an opening move
+		if len(r.Checks) != 2 || r.Checks[0].Kind != "ply" || r.Checks[1].Kind != "cube" {
+			t.Fatalf("%v: checks %+v, want ply and cube", dice, r.Checks)
+		}
+		for _, c := range r.Checks {
+			if c.Gap < 0 || (c.Level == ConsensusUnanimous) != (c.Gap < ConsensusMinorGap && sameMove(c.A.Move, c.B.Move)) {
+				t.Errorf("%v %s: gap %.4f, level %v, %s", dice, c.Kind, c.Gap, c.Level, c.Message)
+			}
+			if r.Level < c.Level {
+				t.Errorf("%v: result level %v below %s check's %v", dice, r.Level, c.Kind, c.Level)
+			}
+		}
+	}
+
+	// No legal moves: entering against a closed board
+	var closed GameState
+	closed.Board[1][24], closed.Board[1][5] = 1, 14
+	for i := 0; i < 6; i++ {
+		closed.Board[0][i] = 2
+	}
+	closed.Board[0][12] = 3
+	closed.CubeValue, closed.CubeOwner = 1, -1
+	r, err := e.ConsensusAnalysis(&closed, [2]int{6, 5})
+	if err != nil || r.NumMoves != 0 || len(r.Checks) != 0 || r.Level != ConsensusUnanimous {
+		t.Errorf("no legal moves: %+v, %v", r, err)
+	}
+}
+
+func TestConsensusBearoffGap(t *testing.T) {
+	// A database in which every side bears off in one roll: the side on
+	// roll always wins, which the race formulas are far from
+	e, err := NewEngineFromBytes(nil, fixedRollsOneSided(4, 1), nil, nil, EngineOptions{SkipWarmup: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var state GameState
+	state.Board[0][5], state.Board[1][5] = 4, 4
+	state.CubeValue, state.CubeOwner = 1, -1
+
+	r, err := e.ConsensusAnalysis(&state, [2]int{2, 1})
+	if err != nil {
+		t.Fatalf("ConsensusAnalysis failed: %v", err)
+	}
+	var check *ConsensusCheck
+	for i := range r.Checks {
+		if r.Checks[i].Kind == "bearoff" {
+			check = &r.Checks[i]
+		}
+	}
+	if check == nil {
+		t.Fatalf("checks %+v, want a bearoff check", r.Checks)
+	}
+	if check.B.Name != SourceBearoff || check.B.Equity > -0.999 || check.Gap < ConsensusConflictGap || check.Level != ConsensusConflict {
+		t.Errorf("bearoff check %+v, want the database's -1 far from the %s", check, check.A.Name)
+	}
+	if r.Level != ConsensusConflict || !strings.Contains(r.Recommendation, "rollout") {
+		t.Errorf("level %v, recommendation %q", r.Level, r.Recommendation)
+	}
+
+	// Without the database there is nothing to compare
+	e.SetRouting(Routing{DisableBearoffDB: true})
+	if r, err = e.ConsensusAnalysis(&state, [2]int{2, 1}); err != nil || len(r.Checks) != 2 {
+		t.Errorf("with the bearoff databases off: checks %+v, %v", r.Checks, err)
+	}
+}
+
+func TestConsensusOpeningFlips(t *testing.T) {
+	e, err := NewEngine(EngineOptions{WeightsFileText: filepath.Join("..", "..", "data", "gnubg.weights")})
+	if err != nil {
+		t.Skipf("Skipping test - could not load data files: %v", err)
+	}
+	// Every roll of the opening position for which 0 and 1 ply play
+	// differently is flagged, with what the 0-ply play costs at 1 ply
+	state := StartingPosition()
+	for d1 := 1; d1 <= 6; d1++ {
+		for d2 := 1; d2 < d1; d2++ {
+			dice := [2]int{d1, d2}
+			zero, err := e.AnalyzePositionWithOptions(state, dice, EvalOptions{UsePrune: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			one, err := e.AnalyzePositionWithOptions(state, dice, EvalOptions{Plies: 1, UsePrune: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			r, err := e.ConsensusAnalysis(state, dice)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ply := r.Checks[0]
+			if sameMove(zero.BestMove, one.BestMove) {
+				continue
+			}
+			want := one.BestEquity - findMove(one.Moves, zero.BestMove).Equity
+			t.Logf("%d-%d: %s", d1, d2, ply.Message)
+			if ply.Level == ConsensusUnanimous || ply.Gap != want {
+				t.Errorf("%d-%d: 0-ply %s, 1-ply %s: ply check %+v, want a gap of %.4f", d1, d2, FormatMove(zero.BestMove), FormatMove(one.BestMove), ply, want)
+			}
+		}
+	}
+}
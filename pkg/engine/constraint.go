@@ -0,0 +1,201 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrNoMoveAllowed is returned when there are legal moves but the constraint
+// rules them all out.
+var ErrNoMoveAllowed = errors.New("no legal move satisfies the constraint")
+
+// MoveConstraint restricts a move list to the moves that satisfy all of its
+// terms, for drills like "the best play that does not hit". Parse one with
+// ParseMoveConstraint; the zero value allows every move.
+type MoveConstraint struct {
+	terms []constraintTerm
+}
+
+// constraintTerm is one predicate of a MoveConstraint.
+type constraintTerm struct {
+	not   bool
+	kind  string // A MoveTag, "make", "home_blot" or "move"
+	point int    // For "make"
+	move  Move   // For "move"
+	text  string // The term as written, for String
+}
+
+// ParseMoveConstraint parses a comma-separated list of terms, all of which a
+// move must satisfy. A term prefixed with "!" must not hold. The terms are:
+//
+//	hit, point, anchor, escape, slot, break_prime
+//	            the move has that tag (see ClassifyMove)
+//	make:N      the move makes the N-point (1-24)
+//	home_blot   the move leaves a blot in the home board
+//	move:M      the move is M in move notation, or plays to the same position
+//
+// For example "!hit,make:5" asks for the best move that makes the 5-point
+// without hitting, and "!move:8/5 6/5" for the best move other than 8/5 6/5.
+func ParseMoveConstraint(s string) (MoveConstraint, error) {
+	var c MoveConstraint
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		t := constraintTerm{text: field}
+		body := field
+		if strings.HasPrefix(body, "!") {
+			t.not = true
+			body = strings.TrimSpace(body[1:])
+		}
+		name, arg, hasArg := strings.Cut(body, ":")
+		t.kind = name
+
+		switch name {
+		case "make":
+			n, err := strconv.Atoi(strings.TrimSpace(arg))
+			if !hasArg || err != nil || n < 1 || n > 24 {
+				return MoveConstraint{}, fmt.Errorf("constraint %q: make needs a point 1-24, as in make:5", field)
+			}
+			t.point = n
+		case "move":
+			m, err := ParseMove(arg)
+			if !hasArg || err != nil || m.From[0] < 0 {
+				return MoveConstraint{}, fmt.Errorf("constraint %q: move needs a move, as in move:8/5 6/5", field)
+			}
+			t.move = m
+		case "home_blot":
+			if hasArg {
+				return MoveConstraint{}, fmt.Errorf("constraint %q: home_blot takes no argument", field)
+			}
+		default:
+			if !isMoveTag(MoveTag(name)) {
+				return MoveConstraint{}, fmt.Errorf("unknown constraint %q", field)
+			}
+			if hasArg {
+				return MoveConstraint{}, fmt.Errorf("constraint %q: %s takes no argument", field, name)
+			}
+		}
+		c.terms = append(c.terms, t)
+	}
+	return c, nil
+}
+
+// isMoveTag reports whether tag is one ClassifyMove returns.
+func isMoveTag(tag MoveTag) bool {
+	for _, t := range moveTagOrder {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the constraint in the form ParseMoveConstraint reads.
+func (c MoveConstraint) String() string {
+	texts := make([]string, len(c.terms))
+	for i, t := range c.terms {
+		texts[i] = t.text
+	}
+	return strings.Join(texts, ",")
+}
+
+// IsZero reports whether the constraint has no terms and allows every move.
+func (c MoveConstraint) IsZero() bool {
+	return len(c.terms) == 0
+}
+
+// Allows reports whether move m played from before, with the player on roll
+// in before[1], satisfies every term of the constraint.
+func (c MoveConstraint) Allows(before Board, m Move) bool {
+	if c.IsZero() {
+		return true
+	}
+	after := ApplyMove(before, m)
+	var tags []MoveTag
+	var diff *BoardDiff
+	for _, t := range c.terms {
+		var holds bool
+		switch t.kind {
+		case "make":
+			if diff == nil {
+				d := DiffBoards(before, after)
+				diff = &d
+			}
+			holds = containsPoint(diff.PointsMade[1], t.point)
+		case "home_blot":
+			holds = false
+			for _, n := range after.HomeBoard(1) {
+				if n == 1 {
+					holds = true
+					break
+				}
+			}
+		case "move":
+			holds = EqualBoards(after, ApplyMove(before, t.move))
+		default:
+			if tags == nil {
+				tags = ClassifyMove(before, m)
+			}
+			for _, tag := range tags {
+				if tag == MoveTag(t.kind) {
+					holds = true
+					break
+				}
+			}
+		}
+		if holds == t.not {
+			return false
+		}
+	}
+	return true
+}
+
+// FilteredMoves is a move list restricted by a MoveConstraint.
+type FilteredMoves struct {
+	Moves    []MoveWithEval // Moves that satisfy the constraint, best first
+	Best     MoveWithEval   // Best move without the constraint
+	Cost     float64        // Equity the best allowed move gives up against Best
+	NumLegal int            // Legal moves before the constraint
+}
+
+// Filter keeps the moves of a ranked analysis of board that satisfy the
+// constraint, in the same order. If there are legal moves but none of them
+// qualifies it returns ErrNoMoveAllowed; with no legal move at all the result
+// is empty.
+func (c MoveConstraint) Filter(board Board, analysis *AnalysisResult) (*FilteredMoves, error) {
+	result := &FilteredMoves{NumLegal: len(analysis.Moves)}
+	if len(analysis.Moves) == 0 {
+		return result, nil
+	}
+	result.Best = analysis.Moves[0]
+	for _, m := range analysis.Moves {
+		if c.Allows(board, m.Move) {
+			result.Moves = append(result.Moves, m)
+		}
+	}
+	if len(result.Moves) == 0 {
+		return nil, fmt.Errorf("%w %q (%d legal moves)", ErrNoMoveAllowed, c.String(), len(analysis.Moves))
+	}
+	result.Cost = result.Best.Equity - result.Moves[0].Equity
+	return result, nil
+}
+
+// RankMovesFiltered ranks the moves that satisfy constraint at 0 plies, as
+// RankMoves does, and reports what the constraint costs against the best move.
+func (e *Engine) RankMovesFiltered(state *GameState, dice [2]int, constraint MoveConstraint) (*FilteredMoves, error) {
+	return e.RankMovesFilteredWithOptions(state, dice, constraint, EvalOptions{})
+}
+
+// RankMovesFilteredWithOptions is RankMovesFiltered evaluating the moves as
+// AnalyzePositionWithOptions does.
+func (e *Engine) RankMovesFilteredWithOptions(state *GameState, dice [2]int, constraint MoveConstraint, opts EvalOptions) (*FilteredMoves, error) {
+	analysis, err := e.AnalyzePositionWithOptions(state, dice, opts)
+	if err != nil {
+		return nil, err
+	}
+	return constraint.Filter(state.Board, analysis)
+}
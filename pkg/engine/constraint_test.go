@@ -0,0 +1,145 @@
+package engine
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseMoveConstraint(t *testing.T) {
+	valid := []string{"", "hit", "!hit", "point, !slot", "make:5", "!make:24", "home_blot", "!home_blot",
+		"move:8/5 6/5", "!move:bar/22*", "break_prime,escape,anchor"}
+	for _, s := range valid {
+		if _, err := ParseMoveConstraint(s); err != nil {
+			t.Errorf("ParseMoveConstraint(%q): %v", s, err)
+		}
+	}
+
+	invalid := []string{"hits", "!", "make", "make:0", "make:25", "make:x", "hit:1", "home_blot:6",
+		"move:", "move:8-5", "move:pass"}
+	for _, s := range invalid {
+		if _, err := ParseMoveConstraint(s); err == nil {
+			t.Errorf("ParseMoveConstraint(%q) accepted", s)
+		}
+	}
+
+	c, err := ParseMoveConstraint(" !hit ,, make:5 ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.String() != "!hit,make:5" {
+		t.Errorf("String() = %q", c.String())
+	}
+}
+
+func TestMoveConstraintAllows(t *testing.T) {
+	start := StartingPosition().Board
+	blot5 := boardWith(map[int]uint8{24: 2, 13: 5, 8: 3, 6: 5}, map[int]uint8{24: 2, 13: 4, 8: 3, 6: 5, 20: 1})
+
+	tests := []struct {
+		board      Board
+		constraint string
+		move       string
+		want       bool
+	}{
+		{start, "point", "8/5 6/5", true},
+		{start, "!point", "8/5 6/5", false},
+		{start, "make:5", "8/5 6/5", true},
+		{start, "make:4", "8/5 6/5", false},
+		{start, "!home_blot", "8/5 6/5", true},
+		{start, "home_blot", "13/10 6/5", true},
+		{start, "!home_blot", "24/21 24/23", true},
+		{start, "!move:8/5 6/5", "8/5 6/5", false},
+		{start, "!move:6/5 8/5", "8/5 6/5", false},
+		{start, "!move:8/5 6/5", "13/10 6/5", true},
+		{start, "move:13/10 6/5", "13/10 6/5", true},
+		{blot5, "hit", "8/5* 6/5", true},
+		{blot5, "hit,!home_blot", "6/5* 13/11", false},
+		{blot5, "hit,!home_blot", "8/5* 6/5", true},
+		{blot5, "!hit", "13/10 13/12", true},
+		{blot5, "", "6/5* 13/11", true},
+	}
+	for _, tt := range tests {
+		c, err := ParseMoveConstraint(tt.constraint)
+		if err != nil {
+			t.Fatalf("ParseMoveConstraint(%q): %v", tt.constraint, err)
+		}
+		m, err := ParseMove(tt.move)
+		if err != nil {
+			t.Fatalf("ParseMove(%q): %v", tt.move, err)
+		}
+		if got := c.Allows(tt.board, m); got != tt.want {
+			t.Errorf("%q allows %s = %v, want %v", tt.constraint, tt.move, got, tt.want)
+		}
+	}
+}
+
+func TestRankMovesFiltered(t *testing.T) {
+	e, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	state := StartingPosition()
+	dice := [2]int{3, 1}
+	all, err := e.AnalyzePosition(state, dice)
+	if err != nil {
+		t.Fatalf("AnalyzePosition failed: %v", err)
+	}
+
+	// Second best: everything but the best move, in the same order
+	c, err := ParseMoveConstraint("!move:" + FormatMove(all.BestMove))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := e.RankMovesFiltered(state, dice, c)
+	if err != nil {
+		t.Fatalf("RankMovesFiltered failed: %v", err)
+	}
+	if got.NumLegal != all.NumMoves || len(got.Moves) != all.NumMoves-1 {
+		t.Fatalf("%d of %d moves allowed, want %d of %d", len(got.Moves), got.NumLegal, all.NumMoves-1, all.NumMoves)
+	}
+	if got.Best.Move != all.BestMove || got.Moves[0].Equity != all.Moves[1].Equity {
+		t.Errorf("best %s, second %s %.4f; want %s, %.4f", FormatMove(got.Best.Move), FormatMove(got.Moves[0].Move),
+			got.Moves[0].Equity, FormatMove(all.BestMove), all.Moves[1].Equity)
+	}
+	if want := all.BestEquity - all.Moves[1].Equity; got.Cost != want || got.Cost < 0 {
+		t.Errorf("cost %.4f, want %.4f", got.Cost, want)
+	}
+
+	// Every allowed move satisfies the constraint
+	c, _ = ParseMoveConstraint("!point,!home_blot")
+	got, err = e.RankMovesFiltered(state, dice, c)
+	if err != nil {
+		t.Fatalf("RankMovesFiltered failed: %v", err)
+	}
+	for _, m := range got.Moves {
+		after := ApplyMove(state.Board, m.Move)
+		for i := 0; i < 6; i++ {
+			if after[1][i] == 1 {
+				t.Errorf("%s leaves a blot on the %d-point", FormatMove(m.Move), i+1)
+			}
+		}
+		for _, tag := range ClassifyMove(state.Board, m.Move) {
+			if tag == TagPoint {
+				t.Errorf("%s makes a point", FormatMove(m.Move))
+			}
+		}
+	}
+
+	// Nothing hits in the opening
+	c, _ = ParseMoveConstraint("hit")
+	if _, err := e.RankMovesFiltered(state, dice, c); !errors.Is(err, ErrNoMoveAllowed) {
+		t.Errorf("hit in the opening: got %v, want ErrNoMoveAllowed", err)
+	}
+
+	// No legal move is not an error
+	closed := &GameState{CubeValue: 1, CubeOwner: -1}
+	closed.Board[1][24] = 1
+	closed.Board[1][5] = 14
+	for i := 0; i < 6; i++ {
+		closed.Board[0][i] = 2
+	}
+	got, err = e.RankMovesFiltered(closed, [2]int{6, 6}, c)
+	if err != nil || got.NumLegal != 0 || len(got.Moves) != 0 {
+		t.Errorf("closed out: %+v, %v", got, err)
+	}
+}
@@ -1,7 +1,13 @@
 package engine
 
 import (
+	"fmt"
 	"math"
+	"time"
+
+	"github.com/yourusername/bgengine/internal/bearoff"
+	"github.com/yourusername/bgengine/internal/met"
+	"github.com/yourusername/bgengine/internal/neuralnet"
 )
 
 // CubeDecisionType represents the detailed cube decision (matching gnubg's cubedecision enum)
@@ -31,6 +37,108 @@ const (
 	OPTIONAL_REDOUBLE_PASS
 )
 
+// Cube actions: the verdicts of cube decisions, without the nuance of the
+// detailed decision type.
+const (
+	CubeNoDouble           = "no_double"
+	CubeDoubleTake         = "double_take"
+	CubeDoublePass         = "double_pass"
+	CubeTooGoodTake        = "too_good_take"
+	CubeTooGoodPass        = "too_good_pass"
+	CubeRedoubleTake       = "redouble_take"
+	CubeRedoublePass       = "redouble_pass"
+	CubeOptionalDoubleTake = "optional_double_take"
+	CubeNotAvailable       = "not_available"
+	CubeDeadCube           = "dead_cube"
+)
+
+// cubeDecisionTypes names each decision type and gives its action and
+// gnubg's text for it.
+var cubeDecisionTypes = [...]struct{ name, action, text string }{
+	DOUBLE_TAKE:            {"double_take", CubeDoubleTake, "Double, take"},
+	DOUBLE_PASS:            {"double_pass", CubeDoublePass, "Double, pass"},
+	NODOUBLE_TAKE:          {"no_double_take", CubeNoDouble, "No double, take"},
+	TOOGOOD_TAKE:           {"too_good_take", CubeTooGoodTake, "Too good to double, take"},
+	TOOGOOD_PASS:           {"too_good_pass", CubeTooGoodPass, "Too good to double, pass"},
+	DOUBLE_BEAVER:          {"double_beaver", CubeDoubleTake, "Double, beaver"},
+	NODOUBLE_BEAVER:        {"no_double_beaver", CubeNoDouble, "No double, beaver"},
+	REDOUBLE_TAKE:          {"redouble_take", CubeRedoubleTake, "Redouble, take"},
+	REDOUBLE_PASS:          {"redouble_pass", CubeRedoublePass, "Redouble, pass"},
+	NO_REDOUBLE_TAKE:       {"no_redouble_take", CubeNoDouble, "No redouble, take"},
+	TOOGOODRE_TAKE:         {"too_good_redouble_take", CubeTooGoodTake, "Too good to redouble, take"},
+	TOOGOODRE_PASS:         {"too_good_redouble_pass", CubeTooGoodPass, "Too good to redouble, pass"},
+	NO_REDOUBLE_BEAVER:     {"no_redouble_beaver", CubeNoDouble, "No redouble, beaver"},
+	NODOUBLE_DEADCUBE:      {"no_double_dead_cube", CubeDeadCube, "No double, dead cube"},
+	NO_REDOUBLE_DEADCUBE:   {"no_redouble_dead_cube", CubeDeadCube, "No redouble, dead cube"},
+	NOT_AVAILABLE:          {"not_available", CubeNotAvailable, "Cube not available"},
+	OPTIONAL_DOUBLE_TAKE:   {"optional_double_take", CubeOptionalDoubleTake, "Optional double, take"},
+	OPTIONAL_REDOUBLE_TAKE: {"optional_redouble_take", CubeOptionalDoubleTake, "Optional redouble, take"},
+	OPTIONAL_DOUBLE_BEAVER: {"optional_double_beaver", CubeOptionalDoubleTake, "Optional double, beaver"},
+	OPTIONAL_DOUBLE_PASS:   {"optional_double_pass", CubeDoublePass, "Optional double, pass"},
+	OPTIONAL_REDOUBLE_PASS: {"optional_redouble_pass", CubeRedoublePass, "Optional redouble, pass"},
+}
+
+// String returns the stable name of the decision type, e.g.
+// "too_good_redouble_pass".
+func (t CubeDecisionType) String() string {
+	if t < 0 || int(t) >= len(cubeDecisionTypes) {
+		return fmt.Sprintf("CubeDecisionType(%d)", int(t))
+	}
+	return cubeDecisionTypes[t].name
+}
+
+// Action returns the cube action of the decision type, one of the Cube*
+// constants. Beavers count as takes and a missed redouble as no double.
+func (t CubeDecisionType) Action() string {
+	if t < 0 || int(t) >= len(cubeDecisionTypes) {
+		return CubeNotAvailable
+	}
+	return cubeDecisionTypes[t].action
+}
+
+// Text returns gnubg's text for the decision type, e.g. "Too good to
+// double, pass".
+func (t CubeDecisionType) Text() string {
+	if t < 0 || int(t) >= len(cubeDecisionTypes) {
+		return t.String()
+	}
+	return cubeDecisionTypes[t].text
+}
+
+// available reports whether the player on roll could double.
+func (t CubeDecisionType) available() bool {
+	return t != NOT_AVAILABLE && t != NODOUBLE_DEADCUBE && t != NO_REDOUBLE_DEADCUBE
+}
+
+// Verdict returns the decision type as a sentence: gnubg's text and why.
+func (t CubeDecisionType) Verdict() string {
+	var why string
+	switch t {
+	case OPTIONAL_DOUBLE_TAKE, OPTIONAL_REDOUBLE_TAKE, OPTIONAL_DOUBLE_BEAVER, OPTIONAL_DOUBLE_PASS, OPTIONAL_REDOUBLE_PASS:
+		why = "doubling and playing on are worth about the same"
+	case DOUBLE_BEAVER, NODOUBLE_BEAVER, NO_REDOUBLE_BEAVER:
+		why = "the opponent should beaver a double"
+	default:
+		switch t.Action() {
+		case CubeNoDouble:
+			why = "the position is not strong enough to double"
+		case CubeDoubleTake, CubeRedoubleTake:
+			why = "doubling gains and the opponent should take"
+		case CubeDoublePass, CubeRedoublePass:
+			why = "doubling gains and the opponent should pass"
+		case CubeTooGoodTake:
+			why = "playing on for a gammon is worth more than doubling, though the opponent would take"
+		case CubeTooGoodPass:
+			why = "playing on for a gammon is worth more than cashing the game"
+		case CubeDeadCube:
+			why = "doubling cannot change the outcome of the match"
+		default:
+			why = "the player on roll cannot double"
+		}
+	}
+	return t.Text() + ": " + why + "."
+}
+
 // DoubleType represents the type of double (matching gnubg's doubletype enum)
 type DoubleType int
 
@@ -53,6 +161,8 @@ type CubeInfo struct {
 	FJacoby     bool       // Jacoby rule in effect
 	FBeavers    bool       // Beavers allowed
 	GammonPrice [4]float32 // Gammon prices: [0]=gammon for p0, [1]=gammon for p1, [2]=bg for p0, [3]=bg for p1
+
+	met *met.Table // Match equity table (nil = the engine's)
 }
 
 // Output indices for cube decision arrays (matching gnubg)
@@ -63,17 +173,103 @@ const (
 	OUTPUT_DROP     = 3
 )
 
+// Sources of the equities of a cube analysis
+const (
+	CubeSourceJanowski = "janowski" // Janowski's formula with the cube efficiency (money play)
+	CubeSourceMET      = "met"      // The match equity table (match play)
+	CubeSourceExact    = "exact"    // The cubeful equities of the two-sided bearoff database (money play)
+)
+
 // CubeAnalysis contains detailed cube analysis
 type CubeAnalysis struct {
-	Decision       CubeDecision     // Simple decision for public API
-	DecisionType   CubeDecisionType // Detailed decision type
-	ArDouble       [4]float64       // Equities: [0]=optimal, [1]=no double, [2]=double/take, [3]=double/pass
-	NoDoubleEquity float64          // Equity if player doesn't double
-	DoubleTakeEq   float64          // Equity if player doubles and opponent takes
-	DoublePassEq   float64          // Equity if player doubles and opponent passes
-	TakePoint      float64          // Win probability needed to take
-	DoublePoint    float64          // Win probability needed to double
-	TooGoodPoint   float64          // Win probability above which double is wrong (too good)
+	Decision        CubeDecision     // Simple decision for public API
+	DecisionType    CubeDecisionType // Detailed decision type
+	Source          string           // Where the equities come from: CubeSourceJanowski, CubeSourceMET or CubeSourceExact
+	ArDouble        [4]float64       // Equities: [0]=optimal, [1]=no double, [2]=double/take, [3]=double/pass
+	NoDoubleEquity  float64          // Equity if player doesn't double
+	DoubleTakeEq    float64          // Equity if player doubles and opponent takes
+	DoublePassEq    float64          // Equity if player doubles and opponent passes
+	TakePoint       float64          // Opponent's winning chances needed to take
+	DoublePoint     float64          // Winning chances from which doubling gains
+	TooGoodPoint    float64          // Win probability above which double is wrong (too good)
+	RecubeTakePoint float64          // Winning chances needed to take the opponent's redouble after a take
+	BeaverEquity    float64          // Equity if player doubles and opponent beavers (money only)
+	RaccoonEquity   float64          // Equity if player also raccoons the beaver (money only)
+	CubeEfficiency  float64          // Cube efficiency x used for the money equities (money only)
+	Volatility      float64          // Volatility that refined CubeEfficiency (money only, with CubeEfficiency.VolatilityFactor)
+
+	MatchContext *CubeMatchContext // Match winning chances behind the decision (match play only)
+	Opponent     *OpponentView     // The double from the side of the opponent (nil when the cube is not available)
+
+	// Take and cash points of a double from cube values 1, 2 and 4, with the
+	// position's gammons counted in
+	Points []CubePoints
+
+	Eval Evaluation // Cubeless evaluation of the position for the player on roll
+}
+
+// CubePoints are the take and cash points of a double from one cube value,
+// as winning chances.
+type CubePoints struct {
+	Cube      int     // Cube value before the double
+	TakePoint float64 // Opponent's winning chances needed to take
+	CashPoint float64 // Winning chances from which the opponent should pass (1 - TakePoint)
+	Margin    float64 // Winning chances less CashPoint: the opponent has a take while it is negative
+}
+
+// OpponentView is a double seen from the side of the opponent, who has to
+// take or pass it. Its equities are the doubler's turned round: the
+// opponent's, per unit of the cube before the double like every equity of
+// CubeAnalysis, so a pass is -1 in money play and a take is worth the
+// opponent's cubeful equity owning the doubled cube. Its chances are the
+// opponent's cubeless ones in the position.
+type OpponentView struct {
+	TakeEquity     float64 // Cubeful equity after taking, owning the doubled cube (-DoubleTakeEq)
+	PassEquity     float64 // Equity after passing (-DoublePassEq)
+	PassCost       float64 // TakeEquity - PassEquity: what passing gives up, negative when passing is right
+	WinProb        float64 // Winning chances
+	TakePoint      float64 // Winning chances needed to take (CubeAnalysis.TakePoint)
+	Margin         float64 // WinProb - TakePoint: the chances are enough to take while it is not negative
+	GammonRisk     float64 // Chances of losing a gammon or backgammon on the doubled cube
+	BackgammonRisk float64 // Chances of losing a backgammon on the doubled cube
+	TakeMWC        float64 // Match winning chance after taking, the game played out at the doubled cube (match play only)
+	PassMWC        float64 // Match winning chance after passing (match play only)
+}
+
+// MatchOutcome is the match winning chance after one way the game can end.
+type MatchOutcome struct {
+	Cube   int     // Cube value the game is played for
+	Points int     // Points won by the player on roll (negative when lost)
+	MWC    float64 // Match winning chance of the player on roll afterwards
+	OppMWC float64 // Match winning chance of the opponent afterwards
+}
+
+// CubeMatchContext is the match equity behind a cube decision at a match
+// score. Chances are the player on roll's unless named otherwise.
+type CubeMatchContext struct {
+	MWC float64 // Match winning chance before this game
+
+	// Winning and losing a single game, gammon and backgammon, first at the
+	// current cube and then at the doubled cube
+	Outcomes []MatchOutcome
+
+	// MWC after winning or losing, weighted by the position's gammon and
+	// backgammon chances. Index 0 is the current cube, 1 the doubled cube.
+	WinMWC  [2]float64
+	LoseMWC [2]float64
+
+	// Doubling risks DoubleRisk MWC (losing at the doubled cube instead of
+	// the current one) to gain DoubleGain MWC (winning at the doubled cube).
+	DoubleRisk float64
+	DoubleGain float64
+
+	// Taking risks TakeRisk of the opponent's MWC (losing at the doubled cube
+	// instead of passing) to gain TakeGain (winning at the doubled cube
+	// instead of passing). TakePoint = TakeRisk / (TakeRisk + TakeGain) is the
+	// share of games the taker must win.
+	TakeRisk  float64
+	TakeGain  float64
+	TakePoint float64
 }
 
 // SetCubeInfoMoney initializes CubeInfo for money game (matching gnubg)
@@ -99,6 +295,13 @@ func SetCubeInfoMoney(nCube, fCubeOwner, fMove int, fJacoby, fBeavers bool) *Cub
 
 // SetCubeInfoMatch initializes CubeInfo for match play (matching gnubg)
 func (e *Engine) SetCubeInfoMatch(nCube, fCubeOwner, fMove, nMatchTo int,
+	anScore [2]int, fCrawford bool) *CubeInfo {
+	return e.setCubeInfoMatch(e.met, nCube, fCubeOwner, fMove, nMatchTo, anScore, fCrawford)
+}
+
+// setCubeInfoMatch initializes CubeInfo for match play with the match equity
+// table t.
+func (e *Engine) setCubeInfoMatch(t *met.Table, nCube, fCubeOwner, fMove, nMatchTo int,
 	anScore [2]int, fCrawford bool) *CubeInfo {
 	pci := &CubeInfo{
 		NCube:      nCube,
@@ -109,6 +312,7 @@ func (e *Engine) SetCubeInfoMatch(nCube, fCubeOwner, fMove, nMatchTo int,
 		FCrawford:  fCrawford,
 		FJacoby:    false,
 		FBeavers:   false,
+		met:        t,
 	}
 
 	// Calculate gammon prices from MET
@@ -118,9 +322,18 @@ func (e *Engine) SetCubeInfoMatch(nCube, fCubeOwner, fMove, nMatchTo int,
 	return pci
 }
 
+// metFor returns the match equity table of pci.
+func (e *Engine) metFor(pci *CubeInfo) *met.Table {
+	if pci.met != nil {
+		return pci.met
+	}
+	return e.met
+}
+
 // calculateGammonPrices calculates gammon price for match play
 func (e *Engine) calculateGammonPrices(pci *CubeInfo) {
-	if e.met == nil || pci.NMatchTo == 0 {
+	t := e.metFor(pci)
+	if t == nil || pci.NMatchTo == 0 {
 		pci.GammonPrice = [4]float32{1.0, 1.0, 1.0, 1.0}
 		return
 	}
@@ -131,22 +344,22 @@ func (e *Engine) calculateGammonPrices(pci *CubeInfo) {
 		// Normal win
 		scoreWin1 := [2]int{pci.AnScore[0], pci.AnScore[1]}
 		scoreWin1[player] += pci.NCube
-		mwcWin1 := e.getMWCForScore(scoreWin1, pci.NMatchTo, player, pci.FCrawford)
+		mwcWin1 := getMWCForScore(t, scoreWin1, pci.NMatchTo, player, pci.FCrawford)
 
 		// Gammon win
 		scoreWin2 := [2]int{pci.AnScore[0], pci.AnScore[1]}
 		scoreWin2[player] += 2 * pci.NCube
-		mwcWin2 := e.getMWCForScore(scoreWin2, pci.NMatchTo, player, pci.FCrawford)
+		mwcWin2 := getMWCForScore(t, scoreWin2, pci.NMatchTo, player, pci.FCrawford)
 
 		// Backgammon win
 		scoreWin3 := [2]int{pci.AnScore[0], pci.AnScore[1]}
 		scoreWin3[player] += 3 * pci.NCube
-		mwcWin3 := e.getMWCForScore(scoreWin3, pci.NMatchTo, player, pci.FCrawford)
+		mwcWin3 := getMWCForScore(t, scoreWin3, pci.NMatchTo, player, pci.FCrawford)
 
 		// Lose
 		scoreLose := [2]int{pci.AnScore[0], pci.AnScore[1]}
 		scoreLose[1-player] += pci.NCube
-		mwcLose := e.getMWCForScore(scoreLose, pci.NMatchTo, player, pci.FCrawford)
+		mwcLose := getMWCForScore(t, scoreLose, pci.NMatchTo, player, pci.FCrawford)
 
 		// Calculate gammon price
 		denom := mwcWin1 - mwcLose
@@ -160,18 +373,19 @@ func (e *Engine) calculateGammonPrices(pci *CubeInfo) {
 	}
 }
 
-// getMWCForScore returns match winning chance for a given score
-func (e *Engine) getMWCForScore(score [2]int, matchTo, player int, crawford bool) float64 {
+// getMWCForScore returns match winning chance for a given score from the
+// match equity table t
+func getMWCForScore(t *met.Table, score [2]int, matchTo, player int, crawford bool) float64 {
 	if score[player] >= matchTo {
 		return 1.0
 	}
 	if score[1-player] >= matchTo {
 		return 0.0
 	}
-	if e.met == nil {
+	if t == nil {
 		return 0.5
 	}
-	return float64(e.met.GetME(score[0], score[1], matchTo, player, crawford))
+	return float64(t.GetME(score[0], score[1], matchTo, player, crawford))
 }
 
 // GetDPEq returns the double/pass equity and whether cube is available
@@ -201,8 +415,8 @@ func (e *Engine) GetDPEq(pci *CubeInfo) (fCube bool, dpEq float64) {
 		((pci.FCubeOwner == -1) || (pci.FCubeOwner == pci.FMove))
 
 	// Get double/pass equity from MET
-	if e.met != nil {
-		dpEq = float64(e.met.GetME(pci.AnScore[0], pci.AnScore[1],
+	if t := e.metFor(pci); t != nil {
+		dpEq = float64(t.GetME(pci.AnScore[0], pci.AnScore[1],
 			pci.NMatchTo, pci.FMove, pci.FCrawford))
 	} else {
 		dpEq = 1.0
@@ -211,6 +425,41 @@ func (e *Engine) GetDPEq(pci *CubeInfo) (fCube bool, dpEq float64) {
 	return
 }
 
+// DeadCube reports whether the cube is dead in state for the player on roll:
+// a match score at which no cube action of theirs can matter. The player
+// cannot double whoever has the cube, by GetDPEq's rules (the Crawford game,
+// a cube that already wins them the match, the leader post-Crawford), or
+// every result of the game at twice the cube leaves the match where it does
+// at the cube. Money games never have a dead cube.
+func (e *Engine) DeadCube(state *GameState) bool {
+	if state.MatchLength == 0 {
+		return false
+	}
+	t, err := e.metTable(state.MET)
+	if err != nil {
+		t = nil
+	}
+	cube := max(state.CubeValue, 1)
+	pci := e.setCubeInfoMatch(t, cube, -1, state.Turn, state.MatchLength, state.Score, state.Crawford)
+	if fCube, _ := e.GetDPEq(pci); !fCube {
+		return true
+	}
+
+	mwc := func(winner, points int) float64 {
+		score := state.Score
+		score[winner] += points
+		return getMWCForScore(e.metFor(pci), score, state.MatchLength, state.Turn, state.Crawford)
+	}
+	for winner := 0; winner < 2; winner++ {
+		for k := 1; k <= 3; k++ {
+			if mwc(winner, k*cube) != mwc(winner, 2*k*cube) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
 // MoneyLive calculates the live cube equity for money games
 // This matches gnubg's MoneyLive function exactly
 func MoneyLive(rW, rL, p float64, pci *CubeInfo) float64 {
@@ -261,13 +510,16 @@ func MoneyLive(rW, rL, p float64, pci *CubeInfo) float64 {
 }
 
 // Cl2CfMoney transforms cubeless equity to cubeful equity for money games
-// This matches gnubg's Cl2CfMoney function
+// This matches gnubg's Cl2CfMoney function. Inconsistent probabilities are
+// sanitized first, so the result is always finite.
 func (e *Engine) Cl2CfMoney(arOutput []float64, pci *CubeInfo, rCubeX float64) float64 {
 	const epsilon = 0.0000001
 	const omepsilon = 0.9999999
 
 	var rW, rL float64
 
+	arOutput = sanitizeProbs(arOutput)
+
 	// Calculate average win and loss W and L
 	if arOutput[0] > epsilon { // OUTPUT_WIN
 		rW = 1.0 + (arOutput[1]+arOutput[2])/arOutput[0] // WINGAMMON + WINBACKGAMMON
@@ -290,9 +542,11 @@ func (e *Engine) Cl2CfMoney(arOutput []float64, pci *CubeInfo, rCubeX float64) f
 }
 
 // Utility calculates the cubeless equity from output probabilities
-// This matches gnubg's Utility function
+// This matches gnubg's Utility function. Inconsistent probabilities are
+// sanitized first, so the result is always within [-3, 3].
 func (e *Engine) Utility(arOutput []float64, pci *CubeInfo) float64 {
 	// arOutput: [0]=Win, [1]=WinGammon, [2]=WinBackgammon, [3]=LoseGammon, [4]=LoseBackgammon
+	arOutput = sanitizeProbs(arOutput)
 
 	// For money games with Jacoby rule and centered cube, gammons don't count
 	if pci.NMatchTo == 0 && pci.FJacoby && pci.FCubeOwner == -1 {
@@ -323,37 +577,175 @@ func winAny(arOutput []float64) bool {
 	return arOutput[0] > 0.0 // OUTPUT_WIN
 }
 
+// CubeEfficiency is the cube efficiency x used by Janowski's formula for
+// money cube decisions, by position class. Like gnubg's EvalEfficiency, races
+// scale with the pip count of the player on roll: x = pips*RaceFactor +
+// RaceCoefficient, kept within [RaceMin, RaceMax].
+//
+// With a VolatilityFactor the class efficiency is refined by the volatility
+// of the position (see Engine.Volatility): x falls by VolatilityFactor for
+// each unit of volatility above VolatilityPivot and rises below it. gnubg
+// has no such refinement, so it is off by default.
+type CubeEfficiency struct {
+	Bearoff         float64 // Bearoff positions
+	RaceFactor      float64 // Race efficiency per pip
+	RaceCoefficient float64 // Race efficiency at 0 pips
+	RaceMin         float64 // Least race efficiency
+	RaceMax         float64 // Greatest race efficiency
+	Crashed         float64 // Crashed positions
+	Contact         float64 // Contact positions
+
+	VolatilityFactor float64 // Change in x per unit of volatility (0 = no refinement)
+	VolatilityPivot  float64 // Volatility at which the class efficiency stands
+}
+
+// DefaultCubeEfficiency returns gnubg's cube efficiencies.
+func DefaultCubeEfficiency() CubeEfficiency {
+	return CubeEfficiency{
+		Bearoff:         0.6,
+		RaceFactor:      0.00125,
+		RaceCoefficient: 0.55,
+		RaceMin:         0.6,
+		RaceMax:         0.7,
+		Crashed:         0.68,
+		Contact:         0.68,
+	}
+}
+
+// For returns the cube efficiency of board, seen by the player on roll.
+func (ce CubeEfficiency) For(board Board) float64 {
+	switch neuralnet.ClassifyPosition(neuralnet.Board(board)) {
+	case neuralnet.ClassOver:
+		return 0 // Dead cube
+	case neuralnet.ClassBearoff1, neuralnet.ClassBearoff2, neuralnet.ClassBearoffOS, neuralnet.ClassBearoffTS:
+		return ce.Bearoff
+	case neuralnet.ClassRace:
+		x := float64(PipCount(board)[1])*ce.RaceFactor + ce.RaceCoefficient
+		return math.Max(ce.RaceMin, math.Min(ce.RaceMax, x))
+	case neuralnet.ClassCrashed:
+		return ce.Crashed
+	default:
+		return ce.Contact
+	}
+}
+
+// Refine returns the efficiency x of a position of the given volatility,
+// kept within [0, 1].
+func (ce CubeEfficiency) Refine(x, volatility float64) float64 {
+	x -= ce.VolatilityFactor * (volatility - ce.VolatilityPivot)
+	return math.Max(0, math.Min(1, x))
+}
+
+// Volatility returns the standard deviation of the cubeless equity of the
+// player on roll over the 21 rolls, each played by the best move at 0 plies:
+// how far the next roll is likely to move the position.
+func (e *Engine) Volatility(state *GameState) (float64, error) {
+	var sum, sumSquares float64
+	for d1 := 1; d1 <= 6; d1++ {
+		for d2 := 1; d2 <= d1; d2++ {
+			weight := 2.0
+			if d1 == d2 {
+				weight = 1.0
+			}
+
+			equity, err := e.rollEquity(state, d1, d2)
+			if err != nil {
+				return 0, err
+			}
+			sum += weight * equity
+			sumSquares += weight * equity * equity
+		}
+	}
+	mean := sum / 36
+	return math.Sqrt(math.Max(0, sumSquares/36-mean*mean)), nil
+}
+
+// rollEquity returns the cubeless equity of the player on roll after rolling
+// d1-d2 and playing it by the best move at 0 plies.
+func (e *Engine) rollEquity(state *GameState, d1, d2 int) (float64, error) {
+	if ml := GenerateMoves(state.Board, d1, d2); len(ml.Moves) > 0 {
+		eval, err := e.findBestMoveEval(state, ml.Moves, 0, false, time.Time{}, nil, nil)
+		if err != nil {
+			return 0, err
+		}
+		return eval.Equity, nil
+	}
+	// No move: the opponent rolls next
+	eval, err := e.Evaluate(state.OpponentOnRoll())
+	if err != nil {
+		return 0, err
+	}
+	return -eval.Equity, nil
+}
+
 // AnalyzeCube analyzes the cube decision for the player on roll
 func (e *Engine) AnalyzeCube(state *GameState) (*CubeAnalysis, error) {
+	return e.AnalyzeCubeWithOptions(state, EvalOptions{})
+}
+
+// AnalyzeCubeWithOptions analyzes the cube decision for the player on roll,
+// evaluating the position at opts.Plies. Money decisions use
+// opts.CubeEfficiency, or the defaults if it is nil.
+func (e *Engine) AnalyzeCubeWithOptions(state *GameState, opts EvalOptions) (*CubeAnalysis, error) {
+	t, err := e.metTable(state.MET)
+	if err != nil {
+		return nil, err
+	}
+
 	// First, get the evaluation of the current position
-	eval, err := e.Evaluate(state)
+	eval, err := e.EvaluatePliedWithOptions(state, opts)
 	if err != nil {
 		return nil, err
 	}
 
-	analysis := &CubeAnalysis{}
+	return e.cubeAnalysis(state, eval, opts.cubeEfficiency(), t), nil
+}
 
-	// Build CubeInfo from GameState
-	var pci *CubeInfo
-	if state.MatchLength == 0 {
-		pci = SetCubeInfoMoney(state.CubeValue, state.CubeOwner, state.Turn, false, false)
-	} else {
-		pci = e.SetCubeInfoMatch(state.CubeValue, state.CubeOwner, state.Turn,
-			state.MatchLength, state.Score, state.Crawford)
+// cubeAnalysis works out the cube decision for the player on roll from the
+// cubeless evaluation of the position. Money decisions use the cube
+// efficiency ce, match decisions the match equity table t.
+func (e *Engine) cubeAnalysis(state *GameState, eval *Evaluation, ce CubeEfficiency, t *met.Table) *CubeAnalysis {
+	// Not doubling leaves the cube as it is
+	full := e.fullEvaluation(state, eval, ce, t)
+	analysis := &CubeAnalysis{
+		Eval:           *eval,
+		NoDoubleEquity: full.CubefulEquity,
+		CubeEfficiency: full.CubeEfficiency,
+		Volatility:     full.Volatility,
+		Source:         CubeSourceJanowski,
+	}
+	if state.MatchLength > 0 {
+		analysis.Source = CubeSourceMET
+	}
+
+	// A money game the cubeful two-sided bearoff database covers has its
+	// cube equities exactly
+	noDouble, unavailable, isExact := e.exactBearoffCube(state)
+	if isExact {
+		analysis.Source = CubeSourceExact
+		analysis.NoDoubleEquity = noDouble
 	}
 
 	// Check if cube is available
+	ci := e.cubeInfo(state, t)
+	pci := &ci
 	fCube, dpEq := e.GetDPEq(pci)
 
 	if !fCube {
-		// Cube not available, return cubeless evaluation
-		analysis.NoDoubleEquity = eval.Equity
+		// Cube not available: the position is played on as it is
 		analysis.DecisionType = NOT_AVAILABLE
+		if pci.NMatchTo > 0 && !pci.FCrawford && (pci.FCubeOwner == -1 || pci.FCubeOwner == pci.FMove) {
+			// The player has the cube but the score leaves nothing to double for
+			analysis.DecisionType = NODOUBLE_DEADCUBE
+			if pci.FCubeOwner != -1 {
+				analysis.DecisionType = NO_REDOUBLE_DEADCUBE
+			}
+		}
 		analysis.Decision = CubeDecision{
 			Action:         NoDouble,
-			NoDoubleEquity: eval.Equity,
+			NoDoubleEquity: analysis.NoDoubleEquity,
 		}
-		return analysis, nil
+		return analysis
 	}
 
 	// Calculate the three key equities: no double, double/take, double/pass
@@ -361,41 +753,44 @@ func (e *Engine) AnalyzeCube(state *GameState) (*CubeAnalysis, error) {
 	arDouble[OUTPUT_DROP] = dpEq
 
 	// Build output arrays for gnubg-style decision
-	arOutput := []float64{eval.WinProb, eval.WinG, eval.WinBG, eval.LoseG, eval.LoseBG}
+	arOutput := eval.outputs()
 	aarOutput := [2][]float64{arOutput, arOutput}
+	arDouble[OUTPUT_NODOUBLE] = analysis.NoDoubleEquity
 
-	// Calculate no-double equity (cubeful)
-	if state.MatchLength == 0 {
-		// Money game: use Janowski's formula
-		rCubeX := 0.68 // Default cube efficiency
-		analysis.NoDoubleEquity = e.Cl2CfMoney(arOutput, pci, rCubeX)
-		arDouble[OUTPUT_NODOUBLE] = analysis.NoDoubleEquity
-
-		// Double/take equity: evaluate with opponent owning doubled cube
+	if isExact {
+		// After a take the opponent owns the cube, so every double is worth
+		// the equity with the cube unavailable, at the cube it is turned to.
+		// A beaver and a raccoon leave the cube with the opponent too.
+		analysis.DoubleTakeEq = 2.0 * unavailable
+		arDouble[OUTPUT_TAKE] = analysis.DoubleTakeEq
+		analysis.BeaverEquity = 4.0 * unavailable
+		analysis.RaccoonEquity = 8.0 * unavailable
+	} else if state.MatchLength == 0 {
+		// Money game: use Janowski's formula. The cube is turned before the
+		// roll, so as in gnubg every cube position shares the cubeless output
+		// of the player on roll; only the cube ownership changes.
+		rCubeX := analysis.CubeEfficiency
+
+		// Double/take equity: the opponent owns the doubled cube. Equities are
+		// per unit of the current cube, hence the factor 2.
 		pciDT := SetCubeInfoMoney(pci.NCube*2, 1-pci.FMove, pci.FMove, pci.FJacoby, pci.FBeavers)
 		analysis.DoubleTakeEq = 2.0 * e.Cl2CfMoney(arOutput, pciDT, rCubeX)
 		arDouble[OUTPUT_TAKE] = analysis.DoubleTakeEq
+
+		// Beaver: opponent takes and redoubles to 4x, keeping the cube.
+		// Raccoon: player redoubles the beaver to 8x, the cube stays with the opponent.
+		pciBeaver := SetCubeInfoMoney(pci.NCube*4, 1-pci.FMove, pci.FMove, pci.FJacoby, true)
+		analysis.BeaverEquity = 4.0 * e.Cl2CfMoney(arOutput, pciBeaver, rCubeX)
+		pciRaccoon := SetCubeInfoMoney(pci.NCube*8, 1-pci.FMove, pci.FMove, pci.FJacoby, true)
+		analysis.RaccoonEquity = 8.0 * e.Cl2CfMoney(arOutput, pciRaccoon, rCubeX)
 	} else {
-		// Match play: use MET-based calculation
-		player := state.Turn
-		cubeValue := state.CubeValue
-
-		// MWC for various outcomes
-		mwcWin := e.getMWCAfterWin(state, player, cubeValue)
-		mwcLose := e.getMWCAfterLoss(state, player, cubeValue)
-		p := eval.WinProb
-
-		// No double MWC
-		mwcNoDouble := p*mwcWin + (1-p)*mwcLose
-		analysis.NoDoubleEquity = e.Mwc2Eq(float32(mwcNoDouble), pci)
-		arDouble[OUTPUT_NODOUBLE] = analysis.NoDoubleEquity
-
-		// Double/take MWC
-		mwcWin2 := e.getMWCAfterWin(state, player, cubeValue*2)
-		mwcLose2 := e.getMWCAfterLoss(state, player, cubeValue*2)
-		mwcDoubleTake := p*mwcWin2 + (1-p)*mwcLose2
+		// Match play: the MWC of the game played out at the doubled cube,
+		// as the no double equity is at the current one
+		mwcDoubleTake := matchWinningChance(t, state, eval, 2*state.CubeValue)
 		analysis.DoubleTakeEq = e.Mwc2Eq(float32(mwcDoubleTake), pci)
 		arDouble[OUTPUT_TAKE] = analysis.DoubleTakeEq
+
+		analysis.MatchContext = cubeMatchContext(t, state, eval)
 	}
 
 	analysis.DoublePassEq = dpEq
@@ -405,16 +800,192 @@ func (e *Engine) AnalyzeCube(state *GameState) (*CubeAnalysis, error) {
 	analysis.DecisionType = e.FindBestCubeDecision(arDouble[:], aarOutput, pci)
 
 	// Calculate decision points for display
-	w := 1.0 + (eval.WinG + eval.WinBG)
-	l := 1.0 + (eval.LoseG + eval.LoseBG)
-	analysis.TakePoint = (l - 0.5) / (w + l + 0.5)
-	analysis.DoublePoint = analysis.TakePoint
-	analysis.TooGoodPoint = (l + 1) / (w + l + 0.5)
+	e.cubePoints(analysis, state, eval, t)
+	analysis.Opponent = opponentView(analysis, state, eval, t)
 
 	// Convert to simple CubeDecision for public API
 	analysis.Decision = e.cubeDecisionTypeToAction(analysis.DecisionType, analysis)
 
-	return analysis, nil
+	return analysis
+}
+
+// exactBearoffCube returns the money equities of state from the cubeful
+// two-sided bearoff database, and whether the database has them: it must be
+// cubeful and cover the position. unavailable is the equity with the
+// opponent owning the cube, which a take leads to, and noDouble that of
+// playing on with the cube where it is. The database stores the centered
+// and owned cube with the double already weighed in, so without one they
+// are worth the best play of each roll against the opponent's equity after
+// it: the centered cube's, or the unavailable one's when the player keeps
+// the cube.
+func (e *Engine) exactBearoffCube(state *GameState) (noDouble, unavailable float64, ok bool) {
+	if state.MatchLength > 0 || e.bearoffTS == nil || !e.bearoffTS.Cubeful || e.disableBearoff.Load() {
+		return 0, 0, false
+	}
+	if e.classify(neuralnet.Board(state.Board)) != neuralnet.ClassBearoffTS {
+		return 0, 0, false
+	}
+	lookup := func(board Board) ([4]float32, error) {
+		return e.bearoffTS.EvaluateCubeful(neuralnet.GetBearoffBoard(neuralnet.Board(board)))
+	}
+	stored, err := lookup(state.Board)
+	if err != nil {
+		return 0, 0, false
+	}
+	unavailable = float64(stored[bearoff.CubefulUnavailable])
+	if state.CubeOwner != -1 && state.CubeOwner != state.Turn {
+		return unavailable, unavailable, true
+	}
+
+	after := bearoff.CubefulCentered
+	if state.CubeOwner == state.Turn {
+		after = bearoff.CubefulUnavailable
+	}
+	for d1 := 1; d1 <= 6; d1++ {
+		for d2 := 1; d2 <= d1; d2++ {
+			boards := []Board{state.Board}
+			if ml := GenerateMoves(state.Board, d1, d2); len(ml.Moves) > 0 {
+				boards = boards[:0]
+				for _, m := range ml.Moves {
+					boards = append(boards, ApplyMove(state.Board, m))
+				}
+			}
+			best := -1.0
+			for _, board := range boards {
+				if PipCount(board)[1] == 0 {
+					best = 1 // Borne off
+					break
+				}
+				opp, err := lookup(swapBoard(board))
+				if err != nil {
+					return 0, 0, false
+				}
+				best = math.Max(best, -float64(opp[after]))
+			}
+			weight := 2.0
+			if d1 == d2 {
+				weight = 1
+			}
+			noDouble += weight * best / 36
+		}
+	}
+	return noDouble, unavailable, true
+}
+
+// cubePoints works out the double, take and cash points of analysis from the
+// average win W and loss L of the evaluation eval. Money points follow the
+// model of Cl2CfMoney with the cube efficiency of the analysis, match points
+// the match equity table t.
+func (e *Engine) cubePoints(analysis *CubeAnalysis, state *GameState, eval *Evaluation, t *met.Table) {
+	w, l := moneyWinLoss(eval)
+	analysis.TooGoodPoint = (l + 1) / (w + l + 0.5)
+
+	cashPoint := func(cube int) float64 {
+		if state.MatchLength == 0 {
+			return 1 - moneyTakePoint(w, l, analysis.CubeEfficiency)
+		}
+		// Passing loses a single game at cube, taking plays on for 2*cube
+		return matchBreakEven(t, state, eval, 2*cube, getMWCAfterWin(t, state, state.Turn, cube))
+	}
+	analysis.TakePoint = 1 - cashPoint(state.CubeValue)
+
+	if state.MatchLength == 0 {
+		x := analysis.CubeEfficiency
+		analysis.DoublePoint = moneyDoublePoint(w, l, x, state.CubeOwner == -1)
+		analysis.RecubeTakePoint = moneyTakePoint(l, w, x)
+	} else {
+		if mc := analysis.MatchContext; mc != nil {
+			analysis.DoublePoint = mc.DoublePoint()
+		}
+		// Passing the redouble loses a single game at 2*cube
+		analysis.RecubeTakePoint = matchBreakEven(t, state, eval, 4*state.CubeValue,
+			getMWCAfterLoss(t, state, state.Turn, 2*state.CubeValue))
+	}
+
+	for _, cube := range []int{1, 2, 4} {
+		cp := cashPoint(cube)
+		analysis.Points = append(analysis.Points, CubePoints{
+			Cube:      cube,
+			TakePoint: 1 - cp,
+			CashPoint: cp,
+			Margin:    eval.WinProb - cp,
+		})
+	}
+}
+
+// opponentView returns the double of analysis, the cube analysis of state
+// with the cubeless evaluation eval, from the opponent's side. Match
+// winning chances come from the match equity table t.
+func opponentView(analysis *CubeAnalysis, state *GameState, eval *Evaluation, t *met.Table) *OpponentView {
+	v := &OpponentView{
+		TakeEquity:     -analysis.DoubleTakeEq,
+		PassEquity:     -analysis.DoublePassEq,
+		WinProb:        1 - eval.WinProb,
+		TakePoint:      analysis.TakePoint,
+		GammonRisk:     eval.WinG,
+		BackgammonRisk: eval.WinBG,
+	}
+	v.PassCost = v.TakeEquity - v.PassEquity
+	v.Margin = v.WinProb - v.TakePoint
+	if state.MatchLength > 0 {
+		v.TakeMWC = 1 - matchWinningChance(t, state, eval, 2*state.CubeValue)
+		v.PassMWC = 1 - getMWCAfterWin(t, state, state.Turn, state.CubeValue)
+	}
+	return v
+}
+
+// moneyWinLoss returns the average value W of the games the player on roll
+// wins and L of those they lose, counting gammons and backgammons. Without
+// wins or losses to average the value is 1.
+func moneyWinLoss(eval *Evaluation) (w, l float64) {
+	w, l = 1, 1
+	if eval.WinProb > 0 {
+		w += (eval.WinG + eval.WinBG) / eval.WinProb
+	}
+	if eval.WinProb < 1 {
+		l += (eval.LoseG + eval.LoseBG) / (1 - eval.WinProb)
+	}
+	return w, l
+}
+
+// moneyTakePoint returns the winning chances a player needs to take a double
+// when the doubler wins W and loses L on average, with cube efficiency x:
+// (W - 1/2) / (W + L + x/2). The taker's recube vig lowers it from the dead
+// cube's (W - 1/2) / (W + L) to the live cube's (W - 1/2) / (W + L + 1/2).
+func moneyTakePoint(w, l, x float64) float64 {
+	return (w - 0.5) / (w + l + 0.5*x)
+}
+
+// moneyDoublePoint returns the winning chances from which doubling gains for
+// a player who wins W and loses L on average, with cube efficiency x. From a
+// centred cube it is (2x(L+1) + 3(1-x)L) / (2x(W+L+1/2) + 3(1-x)(W+L)), the
+// cash point with a live cube and L/(W+L) with a dead one; with the player
+// owning the cube it is the redouble point (L + x) / (W + L + x/2).
+func moneyDoublePoint(w, l, x float64, centered bool) float64 {
+	if centered {
+		return (2*x*(l+1) + 3*(1-x)*l) / (2*x*(w+l+0.5) + 3*(1-x)*(w+l))
+	}
+	return (l + x) / (w + l + 0.5*x)
+}
+
+// matchBreakEven returns the winning chances at which playing the game out
+// for cube, with the position's gammons weighted in, is worth mwc to the
+// player on roll at the match score of state.
+func matchBreakEven(t *met.Table, state *GameState, eval *Evaluation, cube int, mwc float64) float64 {
+	var win, lose [3]float64
+	for n := 1; n <= 3; n++ {
+		win[n-1] = getMWCAfterWin(t, state, state.Turn, n*cube)
+		lose[n-1] = getMWCAfterLoss(t, state, state.Turn, n*cube)
+	}
+	w := gammonWeighted(win, eval.WinProb, eval.WinG, eval.WinBG)
+	l := gammonWeighted(lose, 1-eval.WinProb, eval.LoseG, eval.LoseBG)
+	if w <= l {
+		if mwc <= l {
+			return 0
+		}
+		return 1
+	}
+	return math.Min(math.Max((mwc-l)/(w-l), 0), 1)
 }
 
 // cubeDecisionTypeToAction converts detailed decision type to simple action
@@ -434,6 +1005,19 @@ func (e *Engine) cubeDecisionTypeToAction(cdt CubeDecisionType, analysis *CubeAn
 			DoubleEquity:   analysis.DoublePassEq,
 			NoDoubleEquity: analysis.NoDoubleEquity,
 		}
+	case TOOGOOD_TAKE, TOOGOODRE_TAKE:
+		return CubeDecision{
+			Action:         TooGood,
+			DoubleEquity:   analysis.DoubleTakeEq,
+			NoDoubleEquity: analysis.NoDoubleEquity,
+			TakeEquity:     -analysis.DoubleTakeEq,
+		}
+	case TOOGOOD_PASS, TOOGOODRE_PASS:
+		return CubeDecision{
+			Action:         TooGood,
+			DoubleEquity:   analysis.DoublePassEq,
+			NoDoubleEquity: analysis.NoDoubleEquity,
+		}
 	default:
 		return CubeDecision{
 			Action:         NoDouble,
@@ -442,9 +1026,83 @@ func (e *Engine) cubeDecisionTypeToAction(cdt CubeDecisionType, analysis *CubeAn
 	}
 }
 
-// getMWCAfterWin returns match winning chance after winning the game
-func (e *Engine) getMWCAfterWin(state *GameState, player, points int) float64 {
-	if e.met == nil {
+// cubeMatchContext works out the match winning chances behind a cube decision
+// for the player on roll in a match game, from the match equity table t.
+func cubeMatchContext(t *met.Table, state *GameState, eval *Evaluation) *CubeMatchContext {
+	player := state.Turn
+	ctx := &CubeMatchContext{
+		MWC: getMWCForScore(t, state.Score, state.MatchLength, player, state.Crawford),
+	}
+
+	for i, cube := range []int{state.CubeValue, 2 * state.CubeValue} {
+		var win, lose [3]float64
+		for n := 1; n <= 3; n++ {
+			win[n-1] = getMWCAfterWin(t, state, player, n*cube)
+			lose[n-1] = getMWCAfterLoss(t, state, player, n*cube)
+		}
+		for n := 1; n <= 3; n++ {
+			ctx.Outcomes = append(ctx.Outcomes, MatchOutcome{Cube: cube, Points: n * cube, MWC: win[n-1], OppMWC: 1 - win[n-1]})
+		}
+		for n := 1; n <= 3; n++ {
+			ctx.Outcomes = append(ctx.Outcomes, MatchOutcome{Cube: cube, Points: -n * cube, MWC: lose[n-1], OppMWC: 1 - lose[n-1]})
+		}
+		ctx.WinMWC[i] = gammonWeighted(win, eval.WinProb, eval.WinG, eval.WinBG)
+		ctx.LoseMWC[i] = gammonWeighted(lose, 1-eval.WinProb, eval.LoseG, eval.LoseBG)
+	}
+
+	ctx.DoubleRisk = ctx.LoseMWC[0] - ctx.LoseMWC[1]
+	ctx.DoubleGain = ctx.WinMWC[1] - ctx.WinMWC[0]
+
+	// A pass loses a single game at the current cube
+	pass := 1 - ctx.Outcomes[0].MWC
+	ctx.TakeRisk = pass - (1 - ctx.WinMWC[1])
+	ctx.TakeGain = (1 - ctx.LoseMWC[1]) - pass
+	if ctx.TakeRisk+ctx.TakeGain > 0 {
+		ctx.TakePoint = ctx.TakeRisk / (ctx.TakeRisk + ctx.TakeGain)
+	}
+
+	return ctx
+}
+
+// DoublePoint returns the share of games the player on roll must win for
+// doubling to gain: DoubleRisk / (DoubleRisk + DoubleGain), or 0 if doubling
+// neither risks nor gains anything.
+func (c *CubeMatchContext) DoublePoint() float64 {
+	if c.DoubleRisk+c.DoubleGain <= 0 {
+		return 0
+	}
+	return c.DoubleRisk / (c.DoubleRisk + c.DoubleGain)
+}
+
+// MatchWinningChance returns the cubeless match winning chance of the player
+// on roll in a match game the evaluation eval was made for, if the game is
+// played out for the current cube. It uses the match equity table state.MET
+// selects.
+func (e *Engine) MatchWinningChance(state *GameState, eval *Evaluation) (float64, error) {
+	if state.MatchLength == 0 {
+		return 0, fmt.Errorf("not a match game")
+	}
+	t, err := e.metTable(state.MET)
+	if err != nil {
+		return 0, err
+	}
+	return e.fullEvaluation(state, eval, DefaultCubeEfficiency(), t).MWC, nil
+}
+
+// gammonWeighted averages the MWC after a single, gammon and backgammon
+// result by their chances, given the chance p of the result and the
+// cumulative gammon (g) and backgammon (bg) chances.
+func gammonWeighted(mwc [3]float64, p, g, bg float64) float64 {
+	if p <= 0 {
+		return mwc[0]
+	}
+	return ((p-g)*mwc[0] + (g-bg)*mwc[1] + bg*mwc[2]) / p
+}
+
+// getMWCAfterWin returns match winning chance after winning the game, from
+// the match equity table t
+func getMWCAfterWin(t *met.Table, state *GameState, player, points int) float64 {
+	if t == nil {
 		return 0.5
 	}
 	newScore := [2]int{state.Score[0], state.Score[1]}
@@ -452,12 +1110,13 @@ func (e *Engine) getMWCAfterWin(state *GameState, player, points int) float64 {
 	if newScore[player] >= state.MatchLength {
 		return 1.0
 	}
-	return float64(e.met.GetME(newScore[0], newScore[1], state.MatchLength, player, state.Crawford))
+	return float64(t.GetME(newScore[0], newScore[1], state.MatchLength, player, state.Crawford))
 }
 
-// getMWCAfterLoss returns match winning chance after losing the game
-func (e *Engine) getMWCAfterLoss(state *GameState, player, points int) float64 {
-	if e.met == nil {
+// getMWCAfterLoss returns match winning chance after losing the game, from
+// the match equity table t
+func getMWCAfterLoss(t *met.Table, state *GameState, player, points int) float64 {
+	if t == nil {
 		return 0.5
 	}
 	opponent := 1 - player
@@ -466,7 +1125,7 @@ func (e *Engine) getMWCAfterLoss(state *GameState, player, points int) float64 {
 	if newScore[opponent] >= state.MatchLength {
 		return 0.0
 	}
-	return float64(e.met.GetME(newScore[0], newScore[1], state.MatchLength, player, state.Crawford))
+	return float64(t.GetME(newScore[0], newScore[1], state.MatchLength, player, state.Crawford))
 }
 
 // Mwc2Eq converts match winning chance to equity
@@ -476,7 +1135,7 @@ func (e *Engine) Mwc2Eq(rMwc float32, pci *CubeInfo) float64 {
 		return float64(rMwc)
 	}
 	// Get current MWC
-	currentMwc := float64(e.met.GetME(pci.AnScore[0], pci.AnScore[1],
+	currentMwc := float64(e.metFor(pci).GetME(pci.AnScore[0], pci.AnScore[1],
 		pci.NMatchTo, pci.FMove, pci.FCrawford))
 
 	// Convert to normalized equity
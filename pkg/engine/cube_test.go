@@ -1,8 +1,17 @@
 package engine
 
 import (
+	"encoding/binary"
+	"fmt"
 	"math"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/yourusername/bgengine/internal/bearoff"
+	"github.com/yourusername/bgengine/internal/met"
+	"github.com/yourusername/bgengine/internal/neuralnet"
 )
 
 func TestSetCubeInfoMoney(t *testing.T) {
@@ -510,6 +519,29 @@ func TestCrawfordGame(t *testing.T) {
 	if analysis.DecisionType != NOT_AVAILABLE {
 		t.Errorf("Expected NOT_AVAILABLE in Crawford game, got %v", analysis.DecisionType)
 	}
+
+	// After the Crawford game the leader's cube is dead
+	state.Crawford = false
+	for _, owner := range []int{-1, 0} {
+		state.CubeOwner = owner
+		analysis, err = engine.AnalyzeCube(state)
+		if err != nil {
+			t.Fatalf("AnalyzeCube failed: %v", err)
+		}
+		want := NODOUBLE_DEADCUBE
+		if owner == 0 {
+			want = NO_REDOUBLE_DEADCUBE
+		}
+		if analysis.DecisionType != want {
+			t.Errorf("post-Crawford leader with cube owner %d: got %v, want %v", owner, analysis.DecisionType, want)
+		}
+	}
+
+	// The trailer's cube is no one's to turn
+	state.CubeOwner = 1
+	if analysis, _ = engine.AnalyzeCube(state); analysis.DecisionType != NOT_AVAILABLE {
+		t.Errorf("opponent's cube: got %v, want %v", analysis.DecisionType, NOT_AVAILABLE)
+	}
 }
 
 func TestGammonPricesMatch(t *testing.T) {
@@ -535,3 +567,960 @@ func TestGammonPricesMatch(t *testing.T) {
 		t.Logf("DMP gammon prices: %v (may be 0 or undefined)", pciDMP.GammonPrice)
 	}
 }
+
+func TestMETName(t *testing.T) {
+	e, err := NewEngine(EngineOptions{METName: "Default"})
+	if err != nil {
+		t.Fatalf("NewEngine with METName failed: %v", err)
+	}
+	if name, length := e.METInfo(); name != "Default MET" || length != 11 {
+		t.Errorf("METInfo() = %q, %d, want the default table", name, length)
+	}
+
+	if _, err := NewEngine(EngineOptions{METName: "no-such-table"}); err == nil {
+		t.Error("expected error for unknown MET name")
+	}
+	if _, err := NewEngine(EngineOptions{METName: "default", METFile: "g11.xml"}); err == nil {
+		t.Error("expected error when both METName and METFile are set")
+	}
+}
+
+func TestAnalyzeCubeDependsOnMET(t *testing.T) {
+	e, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+
+	// Trailing 7-away against 2-away in a 7 point match
+	state := StartingPosition()
+	state.MatchLength = 7
+	state.Score = [2]int{0, 5}
+
+	before, err := e.AnalyzeCube(state)
+	if err != nil {
+		t.Fatalf("AnalyzeCube failed: %v", err)
+	}
+
+	// A table that favours the trailer more than the default
+	alt := met.Default()
+	for i := 0; i < met.MaxScore; i++ {
+		for j := 0; j < met.MaxScore; j++ {
+			pi, pj := math.Sqrt(float64(i+1)), math.Sqrt(float64(j+1))
+			alt.PreCrawford[i][j] = float32(pj / (pi + pj))
+		}
+	}
+	e.met = alt
+
+	after, err := e.AnalyzeCube(state)
+	if err != nil {
+		t.Fatalf("AnalyzeCube failed: %v", err)
+	}
+
+	if math.Abs(after.DoubleTakeEq-before.DoubleTakeEq) < 0.01 &&
+		math.Abs(after.NoDoubleEquity-before.NoDoubleEquity) < 0.01 {
+		t.Errorf("cube equities unchanged by MET: before %+v, after %+v", before, after)
+	}
+}
+
+// writeSqrtMET writes a match equity table to a file named sqrt.xml that
+// favours the trailer more than the default, and returns its path.
+func writeSqrtMET(t *testing.T) string {
+	t.Helper()
+	var b strings.Builder
+	b.WriteString("<met>\n  <info><name>Sqrt</name><length>25</length></info>\n")
+	b.WriteString("  <pre-crawford-table type=\"explicit\">\n")
+	for i := 0; i < met.MaxScore; i++ {
+		b.WriteString("    <row>")
+		for j := 0; j < met.MaxScore; j++ {
+			pi, pj := math.Sqrt(float64(i+1)), math.Sqrt(float64(j+1))
+			fmt.Fprintf(&b, "<me>%.6f</me>", pj/(pi+pj))
+		}
+		b.WriteString("</row>\n")
+	}
+	b.WriteString("  </pre-crawford-table>\n</met>\n")
+
+	file := filepath.Join(t.TempDir(), "sqrt.xml")
+	if err := os.WriteFile(file, []byte(b.String()), 0o644); err != nil {
+		t.Fatalf("write MET: %v", err)
+	}
+	return file
+}
+
+func TestAnalyzeCubeSelectsMET(t *testing.T) {
+	e, err := NewEngine(EngineOptions{METFiles: []string{writeSqrtMET(t)}})
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	if names := e.METNames(); strings.Join(names, ",") != "default,sqrt" {
+		t.Errorf("METNames() = %v, want [default sqrt]", names)
+	}
+
+	// The last roll, 26/36 to win, trailing 7-away against 3-away: worth a
+	// double by the default table but not by one that favours the trailer
+	state := &GameState{
+		Board:       boardFromPoints(map[int]uint8{2: 2}, map[int]uint8{1: 1}),
+		CubeValue:   1,
+		CubeOwner:   -1,
+		MatchLength: 7,
+		Score:       [2]int{0, 4},
+	}
+	decisions := make(map[string]CubeDecisionType)
+	for _, name := range []string{"", "default", "SQRT"} {
+		state.MET = name
+		analysis, err := e.AnalyzeCube(state)
+		if err != nil {
+			t.Fatalf("AnalyzeCube with MET %q failed: %v", name, err)
+		}
+		decisions[name] = analysis.DecisionType
+	}
+	if decisions[""] != DOUBLE_TAKE || decisions["default"] != DOUBLE_TAKE {
+		t.Errorf("default table decision = %v (%v by name), want double/take", decisions[""], decisions["default"])
+	}
+	if decisions["SQRT"] != NODOUBLE_TAKE {
+		t.Errorf("sqrt table decision = %v, want no double/take", decisions["SQRT"])
+	}
+
+	state.MET = "no-such-table"
+	if _, err := e.AnalyzeCube(state); err == nil || !strings.Contains(err.Error(), "default, sqrt") {
+		t.Errorf("AnalyzeCube with an unknown MET: %v, want an error listing the tables", err)
+	}
+	if err := e.CheckMET("Sqrt"); err != nil {
+		t.Errorf("CheckMET(Sqrt) = %v", err)
+	}
+	if n := testing.AllocsPerRun(100, func() { e.metTable("") }); n != 0 {
+		t.Errorf("selecting the engine's table allocates %.0f times", n)
+	}
+
+	if _, err := NewEngine(EngineOptions{METFiles: []string{writeSqrtMET(t), writeSqrtMET(t)}}); err == nil {
+		t.Error("expected error for two tables with the same name")
+	}
+}
+
+// TestAnalyzeCubeMETFlip checks that the bundled Kazaross-XG2 and g11
+// tables, which differ by a few hundredths near the take points, turn a
+// last-roll cube decision at some score of a 7 point match.
+func TestAnalyzeCubeMETFlip(t *testing.T) {
+	for _, name := range []string{"kazaross-xg2", "g11"} {
+		if _, err := met.Load(name); err != nil {
+			t.Skipf("Skipping - MET %s not bundled: %v", name, err)
+		}
+	}
+	e, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+
+	// The last roll, 26/36 to win
+	state := &GameState{
+		Board:       boardFromPoints(map[int]uint8{2: 2}, map[int]uint8{1: 1}),
+		CubeValue:   1,
+		CubeOwner:   -1,
+		MatchLength: 7,
+	}
+	for i := 0; i < 6; i++ {
+		for j := 0; j < 6; j++ {
+			state.Score = [2]int{i, j}
+			var decisions [2]CubeDecisionType
+			for k, name := range []string{"kazaross-xg2", "g11"} {
+				state.MET = name
+				analysis, err := e.AnalyzeCube(state)
+				if err != nil {
+					t.Fatalf("AnalyzeCube with MET %s at %d-%d failed: %v", name, i, j, err)
+				}
+				decisions[k] = analysis.DecisionType
+			}
+			if decisions[0] != decisions[1] {
+				t.Logf("at %d-%d: Kazaross-XG2 %v, g11 %v", i, j, decisions[0], decisions[1])
+				return
+			}
+		}
+	}
+	t.Error("Kazaross-XG2 and g11 agree on the last roll at every score of a 7 point match")
+}
+
+func TestMatchWinningChance(t *testing.T) {
+	e, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+
+	// 2-away/2-away: a single game for the match, gammons count the same
+	state := &GameState{MatchLength: 5, Score: [2]int{3, 3}, CubeValue: 2}
+	mwc, err := e.MatchWinningChance(state, &Evaluation{WinProb: 0.6, WinG: 0.2, LoseG: 0.1})
+	if err != nil || math.Abs(mwc-0.6) > 1e-6 {
+		t.Errorf("MatchWinningChance at DMP = %.4f, %v; want 0.6", mwc, err)
+	}
+
+	// 4-away/2-away at cube 1: a gammon is worth more than a single win
+	state = &GameState{MatchLength: 5, Score: [2]int{1, 3}, CubeValue: 1}
+	single, _ := e.MatchWinningChance(state, &Evaluation{WinProb: 0.6})
+	gammons, _ := e.MatchWinningChance(state, &Evaluation{WinProb: 0.6, WinG: 0.2})
+	if gammons <= single {
+		t.Errorf("MWC with gammons %.4f, without %.4f", gammons, single)
+	}
+
+	if _, err := e.MatchWinningChance(StartingPosition(), &Evaluation{}); err == nil {
+		t.Error("expected error for a money game")
+	}
+}
+
+func TestCubeMatchContext(t *testing.T) {
+	e, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+
+	near := func(name string, got, want float64) {
+		t.Helper()
+		if math.Abs(got-want) > 1e-3 {
+			t.Errorf("%s = %.4f, want %.4f", name, got, want)
+		}
+	}
+
+	// Single games only, so the weighted MWC are the single game MWC
+	eval := &Evaluation{WinProb: 0.6}
+
+	// 2-away/2-away in a 5 point match: a single win at the doubled cube
+	// takes the match
+	state := StartingPosition()
+	state.MatchLength = 5
+	state.Score = [2]int{3, 3}
+	ctx := cubeMatchContext(e.met, state, eval)
+	if len(ctx.Outcomes) != 12 {
+		t.Fatalf("%d outcomes, want 12", len(ctx.Outcomes))
+	}
+	near("MWC", ctx.MWC, 0.5)
+	near("win 1", ctx.Outcomes[0].MWC, 2.0/3)
+	near("opp win 1", ctx.Outcomes[0].OppMWC, 1.0/3)
+	near("lose 1", ctx.Outcomes[3].MWC, 1.0/3)
+	near("win 2", ctx.Outcomes[6].MWC, 1)
+	near("lose 2", ctx.Outcomes[9].MWC, 0)
+	if o := ctx.Outcomes[9]; o.Cube != 2 || o.Points != -2 {
+		t.Errorf("outcome 9 = cube %d points %d, want a single loss at cube 2", o.Cube, o.Points)
+	}
+	near("DoubleRisk", ctx.DoubleRisk, 1.0/3)
+	near("DoubleGain", ctx.DoubleGain, 1.0/3)
+	near("TakeRisk", ctx.TakeRisk, 1.0/3)
+	near("TakeGain", ctx.TakeGain, 2.0/3)
+	near("TakePoint", ctx.TakePoint, 1.0/3)
+
+	// 4-away/2-away: the trailer doubling risks 20% to gain 10%
+	state.Score = [2]int{1, 3}
+	ctx = cubeMatchContext(e.met, state, eval)
+	near("MWC", ctx.MWC, 1.0/3)
+	near("win 1", ctx.Outcomes[0].MWC, 0.4)
+	near("win 2", ctx.Outcomes[1].MWC, 0.5)
+	near("win 3", ctx.Outcomes[2].MWC, 2.0/3)
+	near("lose 1", ctx.Outcomes[3].MWC, 0.2)
+	near("lose 2", ctx.Outcomes[4].MWC, 0)
+	near("DoubleRisk", ctx.DoubleRisk, 0.2)
+	near("DoubleGain", ctx.DoubleGain, 0.1)
+	near("TakeRisk", ctx.TakeRisk, 0.1)
+	near("TakeGain", ctx.TakeGain, 0.4)
+	near("TakePoint", ctx.TakePoint, 0.2)
+
+	// Gammons are weighted by their share of the wins
+	ctx = cubeMatchContext(e.met, state, &Evaluation{WinProb: 0.6, WinG: 0.2, WinBG: 0.05})
+	near("WinMWC", ctx.WinMWC[0], (0.4*0.4+0.15*0.5+0.05*2.0/3)/0.6)
+
+	// AnalyzeCube only fills the context for match play
+	analysis, err := e.AnalyzeCube(state)
+	if err != nil {
+		t.Fatalf("AnalyzeCube failed: %v", err)
+	}
+	if analysis.MatchContext == nil {
+		t.Error("no match context at a match score")
+	}
+	analysis, err = e.AnalyzeCube(StartingPosition())
+	if err != nil {
+		t.Fatalf("AnalyzeCube failed: %v", err)
+	}
+	if analysis.MatchContext != nil {
+		t.Error("match context for a money game")
+	}
+}
+
+func TestMoneyCubePoints(t *testing.T) {
+	tests := []struct {
+		w, l, x    float64
+		take       float64
+		double     float64 // From a centred cube
+		redouble   float64 // With the cube owned
+		recubeTake float64
+	}{
+		// Without gammons the live cube points are 20% and 80%, the dead
+		// cube's 25% and 50%
+		{1, 1, 1, 0.2, 0.8, 0.8, 0.2},
+		{1, 1, 0, 0.25, 0.5, 0.5, 0.25},
+		{1, 1, 2.0 / 3, 3.0 / 14, 11.0 / 16, 5.0 / 7, 3.0 / 14},
+		// The doubler's gammons raise the take point and lower the double point
+		{1.5, 1.2, 2.0 / 3, 30.0 / 91, 124.0 / 209, 8.0 / 13, 3.0 / 13},
+		{1.2, 1.5, 2.0 / 3, 3.0 / 13, 145.0 / 209, 5.0 / 7, 30.0 / 91},
+	}
+	for _, tt := range tests {
+		got := [4]float64{
+			moneyTakePoint(tt.w, tt.l, tt.x),
+			moneyDoublePoint(tt.w, tt.l, tt.x, true),
+			moneyDoublePoint(tt.w, tt.l, tt.x, false),
+			moneyTakePoint(tt.l, tt.w, tt.x),
+		}
+		want := [4]float64{tt.take, tt.double, tt.redouble, tt.recubeTake}
+		for i := range got {
+			if math.Abs(got[i]-want[i]) > 1e-9 {
+				t.Errorf("W=%v L=%v x=%.3f: points %.4f, want %.4f", tt.w, tt.l, tt.x, got, want)
+				break
+			}
+		}
+	}
+}
+
+func TestAnalyzeCubePoints(t *testing.T) {
+	e, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+
+	// A gammonish position: W = 1.5 and L = 1.2
+	p := 0.6
+	eval := &Evaluation{WinProb: p, WinG: 0.5 * p, LoseG: 0.2 * (1 - p)}
+	state := StartingPosition()
+	a := e.cubeAnalysis(state, eval, DefaultCubeEfficiency(), e.met)
+	x := a.CubeEfficiency
+	if math.Abs(a.TakePoint-moneyTakePoint(1.5, 1.2, x)) > 1e-9 || math.Abs(a.DoublePoint-moneyDoublePoint(1.5, 1.2, x, true)) > 1e-9 {
+		t.Errorf("take point %.4f, double point %.4f; want %.4f and %.4f", a.TakePoint, a.DoublePoint,
+			moneyTakePoint(1.5, 1.2, x), moneyDoublePoint(1.5, 1.2, x, true))
+	}
+	if math.Abs(a.DoublePoint-a.TakePoint) < 0.1 {
+		t.Errorf("double point %.4f equals the take point", a.DoublePoint)
+	}
+
+	// The points are where the cubeful equities break even
+	output := func(p float64) []float64 { return []float64{p, 0.5 * p, 0, 0.2 * (1 - p), 0} }
+	centred := SetCubeInfoMoney(1, -1, 0, false, false)
+	takes := SetCubeInfoMoney(2, 1, 0, false, false)
+	if nd, dt := e.Cl2CfMoney(output(a.DoublePoint), centred, x), 2*e.Cl2CfMoney(output(a.DoublePoint), takes, x); math.Abs(nd-dt) > 1e-9 {
+		t.Errorf("at the double point: ND %.4f, DT %.4f", nd, dt)
+	}
+	if dt := 2 * e.Cl2CfMoney(output(1-a.TakePoint), takes, x); math.Abs(dt-1) > 1e-9 {
+		t.Errorf("at the cash point: DT %.4f, want 1", dt)
+	}
+
+	if len(a.Points) != 3 {
+		t.Fatalf("%d cube points, want 3", len(a.Points))
+	}
+	for i, cp := range a.Points {
+		if cp.Cube != 1<<i || cp.TakePoint != a.TakePoint || cp.CashPoint != 1-a.TakePoint || cp.Margin != p-cp.CashPoint {
+			t.Errorf("points %+v, want cube %d with take point %.4f", cp, 1<<i, a.TakePoint)
+		}
+	}
+
+	// 2-away/2-away: the take point of the context, a dead recube, and no
+	// take of a double from 2, which loses the match
+	state.MatchLength = 5
+	state.Score = [2]int{3, 3}
+	eval = &Evaluation{WinProb: 0.6}
+	a = e.cubeAnalysis(state, eval, DefaultCubeEfficiency(), e.met)
+	want := [3]float64{1.0 / 3, 0.5, 0}
+	if got := [3]float64{a.TakePoint, a.DoublePoint, a.RecubeTakePoint}; math.Abs(got[0]-want[0])+math.Abs(got[1]-want[1])+math.Abs(got[2]-want[2]) > 1e-3 {
+		t.Errorf("take, double and recube take points %.4f, want %.4f", got, want)
+	}
+	if len(a.Points) != 3 || math.Abs(a.Points[0].TakePoint-a.MatchContext.TakePoint) > 1e-9 || a.Points[1].TakePoint != 0 {
+		t.Errorf("match points %+v", a.Points)
+	}
+}
+
+func TestOpponentView(t *testing.T) {
+	e, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+
+	// The opponent's side of a double mirrors the doubler's: the equities
+	// turned round, the chances the rest of the doubler's
+	check := func(name string, a *CubeAnalysis, eval *Evaluation) *OpponentView {
+		t.Helper()
+		v := a.Opponent
+		if v == nil {
+			t.Fatalf("%s: no opponent view", name)
+		}
+		if v.TakeEquity != -a.DoubleTakeEq || v.PassEquity != -a.DoublePassEq || v.PassCost != a.DoublePassEq-a.DoubleTakeEq {
+			t.Errorf("%s: take %.4f, pass %.4f, cost %.4f; the doubler has take %.4f, pass %.4f", name,
+				v.TakeEquity, v.PassEquity, v.PassCost, a.DoubleTakeEq, a.DoublePassEq)
+		}
+		if v.WinProb != 1-eval.WinProb || v.TakePoint != a.TakePoint || math.Abs(v.Margin-(1-eval.WinProb-a.TakePoint)) > 1e-12 {
+			t.Errorf("%s: win %.4f, take point %.4f, margin %.4f", name, v.WinProb, v.TakePoint, v.Margin)
+		}
+		if v.GammonRisk != eval.WinG || v.BackgammonRisk != eval.WinBG {
+			t.Errorf("%s: gammon risk %.4f, backgammon risk %.4f", name, v.GammonRisk, v.BackgammonRisk)
+		}
+		return v
+	}
+
+	// Money positions with W = 1.5 and L = 1.2, whose take point is 33%
+	gammonish := func(p float64) *Evaluation {
+		return &Evaluation{WinProb: p, WinG: 0.5 * p, LoseG: 0.2 * (1 - p)}
+	}
+	state := StartingPosition()
+	for _, tc := range []struct {
+		name string
+		p    float64
+		take bool
+	}{
+		{"clear take", 0.6, true},
+		{"clear pass", 0.85, false},
+	} {
+		eval := gammonish(tc.p)
+		a := e.cubeAnalysis(state, eval, DefaultCubeEfficiency(), e.met)
+		v := check(tc.name, a, eval)
+		if (v.PassCost > 0) != tc.take || (v.Margin > 0) != tc.take {
+			t.Errorf("%s: pass cost %.4f, margin %.4f", tc.name, v.PassCost, v.Margin)
+		}
+		if took := !strings.HasSuffix(a.DecisionType.String(), "pass"); took != tc.take {
+			t.Errorf("%s: decision %v", tc.name, a.DecisionType)
+		}
+		if v.TakeMWC != 0 || v.PassMWC != 0 {
+			t.Errorf("%s: match winning chances in a money game", tc.name)
+		}
+	}
+
+	// At the cash point the take is worth exactly the pass
+	a := e.cubeAnalysis(state, gammonish(0.6), DefaultCubeEfficiency(), e.met)
+	eval := gammonish(1 - a.TakePoint)
+	v := check("borderline", e.cubeAnalysis(state, eval, DefaultCubeEfficiency(), e.met), eval)
+	if math.Abs(v.PassCost) > 1e-9 || math.Abs(v.Margin) > 1e-9 {
+		t.Errorf("borderline: pass cost %.4f, margin %.4f; want 0", v.PassCost, v.Margin)
+	}
+
+	// 2-away/2-away: taking plays for the match with 40%, passing leaves
+	// the opponent 1-away/2-away with a third
+	state.MatchLength = 5
+	state.Score = [2]int{3, 3}
+	eval = &Evaluation{WinProb: 0.6}
+	v = check("match", e.cubeAnalysis(state, eval, DefaultCubeEfficiency(), e.met), eval)
+	if math.Abs(v.TakeMWC-0.4) > 1e-3 || math.Abs(v.PassMWC-1.0/3) > 1e-3 || v.PassCost <= 0 {
+		t.Errorf("match: take MWC %.4f, pass MWC %.4f, pass cost %.4f", v.TakeMWC, v.PassMWC, v.PassCost)
+	}
+
+	// Without a cube to double there is no double to look at
+	state.Crawford = true
+	state.Score = [2]int{4, 3}
+	if a := e.cubeAnalysis(state, eval, DefaultCubeEfficiency(), e.met); a.Opponent != nil {
+		t.Errorf("opponent view %+v in the Crawford game", a.Opponent)
+	}
+}
+
+func TestCubeEfficiency(t *testing.T) {
+	ce := DefaultCubeEfficiency()
+
+	// Races with the opponent on its 7, 8 and 9 points
+	race := func(player map[int]uint8) Board {
+		var b Board
+		b[0][6], b[0][7], b[0][8] = 5, 5, 5
+		for i, n := range player {
+			b[1][i] = n
+		}
+		return b
+	}
+
+	tests := []struct {
+		name  string
+		board Board
+		want  float64
+	}{
+		{"contact", StartingPosition().Board, 0.68},
+		{"race, 150 pips", race(map[int]uint8{10: 5, 9: 5, 8: 5}), 0.7},
+		{"race, 80 pips", race(map[int]uint8{7: 5, 3: 10}), 0.65},
+		{"race, 30 pips", race(map[int]uint8{1: 15}), 0.6},
+		{"bearoff", func() Board { var b Board; b[0][0], b[1][0] = 15, 15; return b }(), 0.6},
+	}
+	for _, tt := range tests {
+		if got := ce.For(tt.board); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: efficiency = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// As gnubg's EvalEfficiency, linear in the pip count between the bounds
+	for point := 6; point <= 10; point++ {
+		for n := uint8(1); n <= 14; n++ {
+			board := race(map[int]uint8{point: n, 5: 15 - n})
+			pips := float64(PipCount(board)[1])
+			want := math.Max(0.6, math.Min(0.7, 0.55+0.00125*pips))
+			if got := ce.For(board); math.Abs(got-want) > 1e-9 {
+				t.Errorf("race of %v pips: efficiency = %v, want %v", pips, got, want)
+			}
+		}
+	}
+}
+
+// TestMoneyCubeJanowski checks money cube equities against Janowski's
+// formula worked out by hand, for the efficiencies gnubg uses (0.68
+// contact, 0.6-0.7 race and bearoff) and the dead (x=0) and live (x=1)
+// extremes. They are not gnubg's published equities. The double/take
+// equity is that of the same cubeless output with the opponent owning the
+// doubled cube, as in gnubg's 0-ply cube decision.
+func TestMoneyCubeJanowski(t *testing.T) {
+	e, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	tests := []struct {
+		output     [5]float64
+		x          float64
+		owner      int // Cube owner before the double
+		noDouble   float64
+		doubleTake float64
+	}{
+		{[5]float64{0.7, 0, 0, 0, 0}, 0.68, -1, 0.581333, 0.596000},
+		{[5]float64{0.7, 0, 0, 0, 0}, 0.6, -1, 0.560000, 0.620000},
+		{[5]float64{0.7, 0, 0, 0, 0}, 0.675, -1, 0.580000, 0.597500},
+		{[5]float64{0.7, 0, 0, 0, 0}, 1, -1, 0.666667, 0.500000},
+		{[5]float64{0.7, 0, 0, 0, 0}, 0, -1, 0.400000, 0.800000},
+		{[5]float64{0.78, 0, 0, 0, 0}, 0.7, -1, 0.821333, 0.966000},
+		{[5]float64{0.82, 0, 0, 0, 0}, 0.7, -1, 0.892000, 1.154000},
+		{[5]float64{0.65, 0.2, 0.01, 0.08, 0.005}, 0.68, -1, 0.589333, 0.612000},
+		{[5]float64{0.72, 0.3, 0.02, 0.05, 0.002}, 0.68, -1, 0.928320, 1.225600},
+		{[5]float64{0.55, 0.15, 0.01, 0.12, 0.01}, 0.68, -1, 0.182133, -0.046000},
+		{[5]float64{0.85, 0.55, 0.05, 0.01, 0}, 0.68, -1, 1.377405, 2.478000},
+		{[5]float64{0.68, 0.1, 0.005, 0.06, 0.003}, 0.68, 0, 0.633200, 0.586400},
+		{[5]float64{0.75, 0.1, 0.005, 0.04, 0.002}, 0.68, 0, 0.818000, 0.956000},
+		{[5]float64{0.62, 0, 0, 0, 0}, 0.6, 0, 0.426000, 0.252000},
+		{[5]float64{0.4, 0.08, 0.004, 0.15, 0.01}, 0.68, 1, -0.480000, -0.960000},
+		{[5]float64{0.25, 0, 0, 0, 0}, 0.6, 1, -0.725000, -1.450000},
+	}
+	for _, tt := range tests {
+		pci := SetCubeInfoMoney(1, tt.owner, 0, false, false)
+		pciDT := SetCubeInfoMoney(2, 1, 0, false, false)
+		nd := e.Cl2CfMoney(tt.output[:], pci, tt.x)
+		dt := 2 * e.Cl2CfMoney(tt.output[:], pciDT, tt.x)
+		if math.Abs(nd-tt.noDouble) > 1e-5 || math.Abs(dt-tt.doubleTake) > 1e-5 {
+			t.Errorf("%v x=%v owner %d: ND %.6f DT %.6f, want %.6f %.6f",
+				tt.output, tt.x, tt.owner, nd, dt, tt.noDouble, tt.doubleTake)
+		}
+	}
+}
+
+func TestAnalyzeCubeEfficiency(t *testing.T) {
+	e, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	// A gammonless race at about 70%
+	e.race = constantNet(neuralnet.NumRaceInputs, 0.85, -8, -8, -8, -8)
+	e.initBufferPools()
+
+	state := &GameState{CubeValue: 1, CubeOwner: -1}
+	state.Board[0][7], state.Board[0][8], state.Board[0][9] = 5, 5, 5
+	state.Board[1][7], state.Board[1][8], state.Board[1][9] = 5, 5, 5
+	eval, err := e.Evaluate(state)
+	if err != nil {
+		t.Fatalf("Evaluate failed: %v", err)
+	}
+	output := []float64{eval.WinProb, eval.WinG, eval.WinBG, eval.LoseG, eval.LoseBG}
+
+	// 135 pips: 0.55 + 135*0.00125 is over the race maximum of 0.7
+	check := func(opts EvalOptions, x float64) {
+		t.Helper()
+		cube, err := e.AnalyzeCubeWithOptions(state, opts)
+		if err != nil {
+			t.Fatalf("AnalyzeCube failed: %v", err)
+		}
+		if math.Abs(cube.CubeEfficiency-x) > 1e-9 {
+			t.Errorf("CubeEfficiency = %v, want %v", cube.CubeEfficiency, x)
+		}
+		nd := e.Cl2CfMoney(output, SetCubeInfoMoney(1, -1, 0, false, false), x)
+		dt := 2 * e.Cl2CfMoney(output, SetCubeInfoMoney(2, 1, 0, false, false), x)
+		if math.Abs(cube.NoDoubleEquity-nd) > 1e-9 || math.Abs(cube.DoubleTakeEq-dt) > 1e-9 {
+			t.Errorf("x=%v: ND %v DT %v, want %v %v", x, cube.NoDoubleEquity, cube.DoubleTakeEq, nd, dt)
+		}
+	}
+	check(EvalOptions{}, 0.7)
+
+	live := DefaultCubeEfficiency()
+	live.RaceMin, live.RaceMax = 1, 1
+	check(EvalOptions{CubeEfficiency: &live}, 1)
+}
+
+func TestVolatility(t *testing.T) {
+	e, err := NewEngine(EngineOptions{SkipWarmup: true})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	volatility := func(board Board) float64 {
+		t.Helper()
+		v, err := e.Volatility(&GameState{Board: board, CubeValue: 1, CubeOwner: -1})
+		if err != nil {
+			t.Fatalf("Volatility failed: %v", err)
+		}
+		return v
+	}
+
+	// Two checkers on the ace point: every roll wins
+	var sure Board
+	sure[1][0] = 2
+	sure[0][18] = 15
+	if v := volatility(sure); v > 1e-9 {
+		t.Errorf("sure win: volatility = %v, want 0", v)
+	}
+
+	// Checkers on the 6 and 5 points against one on the ace point: 6-5 and
+	// the doubles from 3-3 up bear both off, 6 rolls in 36, and the opponent
+	// wins after any other roll. The equity is +1 or -1, so its standard
+	// deviation is 2*sqrt(p(1-p)) with p = 1/6.
+	var doubles Board
+	doubles[1][5], doubles[1][4] = 1, 1
+	doubles[0][0] = 1
+	if v, want := volatility(doubles), math.Sqrt(5)/3; math.Abs(v-want) > 1e-9 {
+		t.Errorf("6 rolls to win: volatility = %v, want %v", v, want)
+	}
+}
+
+func TestCubeEfficiencyVolatility(t *testing.T) {
+	ce := DefaultCubeEfficiency()
+	ce.VolatilityFactor, ce.VolatilityPivot = 0.1, 0.5
+	for _, tt := range []struct{ x, v, want float64 }{
+		{0.68, 0.5, 0.68},
+		{0.68, 0.9, 0.64},
+		{0.68, 0.2, 0.71},
+		{0.68, 10, 0},
+		{0.7, -5, 1},
+	} {
+		if got := ce.Refine(tt.x, tt.v); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Refine(%v, %v) = %v, want %v", tt.x, tt.v, got, tt.want)
+		}
+	}
+
+	e, err := NewEngine(EngineOptions{SkipWarmup: true})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	state := StartingPosition()
+	state.CubeOwner = -1
+	v, err := e.Volatility(state)
+	if err != nil {
+		t.Fatalf("Volatility failed: %v", err)
+	}
+
+	// Off by default: gnubg's contact efficiency
+	cube, err := e.AnalyzeCube(state)
+	if err != nil {
+		t.Fatalf("AnalyzeCube failed: %v", err)
+	}
+	if cube.CubeEfficiency != 0.68 || cube.Volatility != 0 {
+		t.Errorf("default: efficiency %v, volatility %v; want 0.68 and none", cube.CubeEfficiency, cube.Volatility)
+	}
+
+	cube, err = e.AnalyzeCubeWithOptions(state, EvalOptions{CubeEfficiency: &ce})
+	if err != nil {
+		t.Fatalf("AnalyzeCube failed: %v", err)
+	}
+	if cube.Volatility != v || math.Abs(cube.CubeEfficiency-ce.Refine(0.68, v)) > 1e-12 {
+		t.Errorf("refined: efficiency %v, volatility %v; want %v and %v", cube.CubeEfficiency, cube.Volatility, ce.Refine(0.68, v), v)
+	}
+	output := []float64{0, 0, 0, 0, 0}
+	if eval, err := e.Evaluate(state); err == nil {
+		output = []float64{eval.WinProb, eval.WinG, eval.WinBG, eval.LoseG, eval.LoseBG}
+	}
+	if nd := e.Cl2CfMoney(output, SetCubeInfoMoney(1, -1, 0, false, false), cube.CubeEfficiency); math.Abs(cube.NoDoubleEquity-nd) > 1e-9 {
+		t.Errorf("refined no double equity %v, want %v", cube.NoDoubleEquity, nd)
+	}
+}
+
+func TestCubeDecisionTypeNames(t *testing.T) {
+	tests := []struct {
+		t            CubeDecisionType
+		name, action string
+		text         string
+	}{
+		{DOUBLE_TAKE, "double_take", CubeDoubleTake, "Double, take"},
+		{DOUBLE_PASS, "double_pass", CubeDoublePass, "Double, pass"},
+		{NODOUBLE_TAKE, "no_double_take", CubeNoDouble, "No double, take"},
+		{TOOGOOD_TAKE, "too_good_take", CubeTooGoodTake, "Too good to double, take"},
+		{TOOGOOD_PASS, "too_good_pass", CubeTooGoodPass, "Too good to double, pass"},
+		{DOUBLE_BEAVER, "double_beaver", CubeDoubleTake, "Double, beaver"},
+		{NODOUBLE_BEAVER, "no_double_beaver", CubeNoDouble, "No double, beaver"},
+		{REDOUBLE_TAKE, "redouble_take", CubeRedoubleTake, "Redouble, take"},
+		{REDOUBLE_PASS, "redouble_pass", CubeRedoublePass, "Redouble, pass"},
+		{NO_REDOUBLE_TAKE, "no_redouble_take", CubeNoDouble, "No redouble, take"},
+		{TOOGOODRE_TAKE, "too_good_redouble_take", CubeTooGoodTake, "Too good to redouble, take"},
+		{TOOGOODRE_PASS, "too_good_redouble_pass", CubeTooGoodPass, "Too good to redouble, pass"},
+		{NO_REDOUBLE_BEAVER, "no_redouble_beaver", CubeNoDouble, "No redouble, beaver"},
+		{NODOUBLE_DEADCUBE, "no_double_dead_cube", CubeDeadCube, "No double, dead cube"},
+		{NO_REDOUBLE_DEADCUBE, "no_redouble_dead_cube", CubeDeadCube, "No redouble, dead cube"},
+		{NOT_AVAILABLE, "not_available", CubeNotAvailable, "Cube not available"},
+		{OPTIONAL_DOUBLE_TAKE, "optional_double_take", CubeOptionalDoubleTake, "Optional double, take"},
+		{OPTIONAL_REDOUBLE_TAKE, "optional_redouble_take", CubeOptionalDoubleTake, "Optional redouble, take"},
+		{OPTIONAL_DOUBLE_BEAVER, "optional_double_beaver", CubeOptionalDoubleTake, "Optional double, beaver"},
+		{OPTIONAL_DOUBLE_PASS, "optional_double_pass", CubeDoublePass, "Optional double, pass"},
+		{OPTIONAL_REDOUBLE_PASS, "optional_redouble_pass", CubeRedoublePass, "Optional redouble, pass"},
+	}
+	if len(tests) != len(cubeDecisionTypes) {
+		t.Fatalf("%d decision types tested, want all %d", len(tests), len(cubeDecisionTypes))
+	}
+
+	names := make(map[string]bool)
+	verdicts := make(map[string]bool)
+	for i, tt := range tests {
+		if int(tt.t) != i {
+			t.Fatalf("test %d is for decision type %d", i, tt.t)
+		}
+		if got := tt.t.String(); got != tt.name {
+			t.Errorf("%d: String() = %q, want %q", tt.t, got, tt.name)
+		}
+		if got := tt.t.Action(); got != tt.action {
+			t.Errorf("%s: Action() = %q, want %q", tt.name, got, tt.action)
+		}
+		if got := tt.t.Text(); got != tt.text {
+			t.Errorf("%s: Text() = %q, want %q", tt.name, got, tt.text)
+		}
+		verdict := tt.t.Verdict()
+		if !strings.HasPrefix(verdict, tt.text+": ") || !strings.HasSuffix(verdict, ".") {
+			t.Errorf("%s: Verdict() = %q, want a sentence starting %q", tt.name, verdict, tt.text)
+		}
+		if names[tt.name] || verdicts[verdict] {
+			t.Errorf("%s: name or verdict %q given twice", tt.name, verdict)
+		}
+		names[tt.name], verdicts[verdict] = true, true
+	}
+
+	for _, bad := range []CubeDecisionType{-1, OPTIONAL_REDOUBLE_PASS + 1} {
+		if got := bad.Action(); got != CubeNotAvailable {
+			t.Errorf("%d: Action() = %q, want %q", bad, got, CubeNotAvailable)
+		}
+		if got := bad.String(); got != fmt.Sprintf("CubeDecisionType(%d)", bad) {
+			t.Errorf("%d: String() = %q", bad, got)
+		}
+	}
+}
+
+func TestDeadCube(t *testing.T) {
+	e, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	tests := []struct {
+		name  string
+		state GameState
+		want  bool
+	}{
+		{"money", GameState{CubeValue: 1, CubeOwner: -1}, false},
+		{"opening of a match", GameState{CubeValue: 1, CubeOwner: -1, MatchLength: 5}, false},
+		{"1-away/1-away", GameState{CubeValue: 1, CubeOwner: -1, MatchLength: 5, Score: [2]int{4, 4}}, true},
+		{"post-Crawford leader", GameState{CubeValue: 1, CubeOwner: -1, MatchLength: 5, Score: [2]int{4, 1}}, true},
+		{"post-Crawford trailer", GameState{Turn: 1, CubeValue: 1, CubeOwner: -1, MatchLength: 5, Score: [2]int{4, 1}}, false},
+		{"Crawford trailer", GameState{Turn: 1, CubeValue: 1, CubeOwner: -1, MatchLength: 5, Score: [2]int{4, 1}, Crawford: true}, true},
+		{"cube wins the match", GameState{CubeValue: 2, CubeOwner: 0, MatchLength: 5, Score: [2]int{3, 0}}, true},
+		// Not dead, only not the player's to turn
+		{"opponent's cube", GameState{CubeValue: 2, CubeOwner: 1, MatchLength: 5}, false},
+	}
+	for _, tt := range tests {
+		if got := e.DeadCube(&tt.state); got != tt.want {
+			t.Errorf("%s: DeadCube = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestEvaluateFullMatchesCube checks that EvaluateFull reports the cubeful
+// equity the cube analysis plays on with and the MWC of MatchWinningChance.
+func TestEvaluateFullMatchesCube(t *testing.T) {
+	e, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	for _, tt := range []struct {
+		name  string
+		state GameState
+	}{
+		{"money", GameState{CubeValue: 1, CubeOwner: -1}},
+		{"owned cube", GameState{CubeValue: 2, CubeOwner: 0}},
+		{"match", GameState{CubeValue: 1, CubeOwner: -1, MatchLength: 7, Score: [2]int{3, 2}}},
+		{"dead cube", GameState{CubeValue: 1, CubeOwner: -1, MatchLength: 5, Score: [2]int{4, 1}}},
+	} {
+		state := tt.state
+		state.Board = StartingPosition().Board
+		full, err := e.EvaluateFull(&state, EvalOptions{})
+		if err != nil {
+			t.Fatalf("%s: EvaluateFull: %v", tt.name, err)
+		}
+		cube, err := e.AnalyzeCube(&state)
+		if err != nil {
+			t.Fatalf("%s: AnalyzeCube: %v", tt.name, err)
+		}
+		if full.CubefulEquity != cube.NoDoubleEquity {
+			t.Errorf("%s: cubeful equity %.6f, no double equity %.6f", tt.name, full.CubefulEquity, cube.NoDoubleEquity)
+		}
+		if full.Equity != cube.Eval.Equity {
+			t.Errorf("%s: cubeless equity %.6f, cube analysis %.6f", tt.name, full.Equity, cube.Eval.Equity)
+		}
+		if state.MatchLength == 0 {
+			if full.MWC != 0 {
+				t.Errorf("%s: MWC %.4f in a money game", tt.name, full.MWC)
+			}
+			continue
+		}
+		mwc, err := e.MatchWinningChance(&state, &full.Evaluation)
+		if err != nil {
+			t.Fatalf("%s: MatchWinningChance: %v", tt.name, err)
+		}
+		if mwc != full.MWC {
+			t.Errorf("%s: MWC %.6f, MatchWinningChance %.6f", tt.name, full.MWC, mwc)
+		}
+	}
+}
+
+// twoSided returns a two-sided bearoff database of 6 points and nChequers
+// checkers, cubeful or not, its equities worked out as gnubg's makebearoff
+// does: by recursion over the rolls, with the best move for each cube
+// position and perfect doubling, taking and passing.
+func twoSided(nChequers int, cubeful bool) []byte {
+	n := bearoff.Combination(6+nChequers, 6)
+	side := func(pos int) (s [25]uint8) {
+		p := bearoff.PositionFromBearoff(pos, 6, nChequers)
+		copy(s[:6], p[:6])
+		return s
+	}
+	solved := make(map[[2]int][4]float64)
+	var solve func(us, them int) [4]float64
+	solve = func(us, them int) [4]float64 {
+		if eq, ok := solved[[2]int{us, them}]; ok {
+			return eq
+		}
+		if them == 0 {
+			// The opponent has borne off: no position to play, a loss
+			return [4]float64{-1, -1, -1, -1}
+		}
+		board := Board{side(them), side(us)}
+
+		// Equities without a cube action, each side playing on with the
+		// cube where it is: the opponent then sees an owned cube as
+		// unavailable and the other way round
+		var nd [4]float64
+		for d1 := 1; d1 <= 6; d1++ {
+			for d2 := 1; d2 <= d1; d2++ {
+				best := [4]float64{-2, -2, -2, -2}
+				for _, m := range GenerateMoves(board, d1, d2).Moves {
+					after := ApplyMove(board, m)
+					eq := [4]float64{1, 1, 1, 1}
+					if rest := bearoff.PositionBearoff(after[1][:6], 6, nChequers); rest > 0 {
+						opp := solve(them, rest)
+						eq = [4]float64{-opp[bearoff.CubefulCubeless], -opp[bearoff.CubefulUnavailable],
+							-opp[bearoff.CubefulCentered], -opp[bearoff.CubefulOwned]}
+					}
+					for k := range best {
+						best[k] = math.Max(best[k], eq[k])
+					}
+				}
+				weight := 2.0
+				if d1 == d2 {
+					weight = 1
+				}
+				for k := range nd {
+					nd[k] += weight * best[k] / 36
+				}
+			}
+		}
+
+		// A double is taken with the opponent owning the doubled cube, or passed
+		double := math.Min(1, 2*nd[bearoff.CubefulUnavailable])
+		eq := nd
+		eq[bearoff.CubefulOwned] = math.Max(nd[bearoff.CubefulOwned], double)
+		eq[bearoff.CubefulCentered] = math.Max(nd[bearoff.CubefulCentered], double)
+		solved[[2]int{us, them}] = eq
+		return eq
+	}
+
+	// A cubeless database keeps the cubeless equity alone
+	size, flag := 2, 0
+	if cubeful {
+		size, flag = 8, 1
+	}
+	data := make([]byte, 40+size*n*n)
+	copy(data, fmt.Sprintf("%-40s", fmt.Sprintf("gnubg-TS-06-%02d-%d", nChequers, flag)))
+	for us := 0; us < n; us++ {
+		for them := 0; them < n; them++ {
+			eq := solve(us, them)
+			for k := 0; k < size/2; k++ {
+				binary.LittleEndian.PutUint16(data[40+size*(us*n+them)+2*k:], uint16(math.Round((eq[k]+1)*32767.5)))
+			}
+		}
+	}
+	return data
+}
+
+func TestAnalyzeCubeExactBearoff(t *testing.T) {
+	exact, err := NewEngineFromBytes(nil, nil, twoSided(3, true), nil, EngineOptions{SkipWarmup: true})
+	if err != nil {
+		t.Fatalf("NewEngineFromBytes: %v", err)
+	}
+	// The same database without cubeful equities: the cubeless evaluations
+	// are the same, the cube decisions Janowski's
+	approx, err := NewEngineFromBytes(nil, nil, twoSided(3, false), nil, EngineOptions{SkipWarmup: true})
+	if err != nil {
+		t.Fatalf("NewEngineFromBytes: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		mover, opp    map[int]uint8
+		owner         int
+		exact, approx CubeDecisionType
+	}{
+		// The last roll: the opponent bears off next, so a double only
+		// doubles the stake and is right from 50%, with a take to 75%.
+		// Janowski's live cube wants 67% to double and 70% to redouble.
+		{"last roll", map[int]uint8{1: 1, 5: 1}, map[int]uint8{1: 2}, -1, DOUBLE_TAKE, NODOUBLE_TAKE},
+		{"last roll redouble", map[int]uint8{1: 1, 5: 1}, map[int]uint8{1: 2}, 0, REDOUBLE_TAKE, NO_REDOUBLE_TAKE},
+		// Two checkers on the 2 point each: a take with 20%, as the taker's
+		// redouble with doubles is worth more than Janowski allows
+		{"2 point against 2 point", map[int]uint8{2: 2}, map[int]uint8{2: 2}, -1, DOUBLE_TAKE, DOUBLE_PASS},
+		// 6 and 1 against 6 and 1: a pass with 24.5%, the taker's cube
+		// worth less than Janowski allows
+		{"6-1 against 6-1", map[int]uint8{1: 1, 6: 1}, map[int]uint8{1: 1, 6: 1}, -1, DOUBLE_PASS, DOUBLE_TAKE},
+	}
+	for _, tc := range tests {
+		state := &GameState{Board: boardFromPoints(tc.mover, tc.opp), CubeValue: 1, CubeOwner: tc.owner}
+		got, err := exact.AnalyzeCube(state)
+		if err != nil {
+			t.Fatalf("%s: AnalyzeCube: %v", tc.name, err)
+		}
+		want, err := approx.AnalyzeCube(state)
+		if err != nil {
+			t.Fatalf("%s: AnalyzeCube: %v", tc.name, err)
+		}
+		if got.Source != CubeSourceExact || want.Source != CubeSourceJanowski {
+			t.Errorf("%s: sources %q and %q, want %q and %q", tc.name, got.Source, want.Source, CubeSourceExact, CubeSourceJanowski)
+		}
+		if got.Eval.WinProb != want.Eval.WinProb {
+			t.Errorf("%s: cubeless winning chances %.4f and %.4f differ", tc.name, got.Eval.WinProb, want.Eval.WinProb)
+		}
+		if got.DecisionType != tc.exact || want.DecisionType != tc.approx {
+			t.Errorf("%s: exact %v (ND %.4f, DT %.4f), Janowski %v, want %v and %v", tc.name,
+				got.DecisionType, got.NoDoubleEquity, got.DoubleTakeEq, want.DecisionType, tc.exact, tc.approx)
+		}
+	}
+
+	// On the last roll no double is worth 2p-1 and a take twice that
+	state := &GameState{Board: boardFromPoints(map[int]uint8{1: 1, 5: 1}, map[int]uint8{1: 2}), CubeValue: 1, CubeOwner: -1}
+	a, _ := exact.AnalyzeCube(state)
+	if math.Abs(a.NoDoubleEquity-10.0/36) > 1e-4 || math.Abs(a.DoubleTakeEq-20.0/36) > 1e-4 || a.DoublePassEq != 1 {
+		t.Errorf("last roll: ND %.5f, DT %.5f, DP %.5f, want %.5f, %.5f, 1", a.NoDoubleEquity, a.DoubleTakeEq, a.DoublePassEq, 10.0/36, 20.0/36)
+	}
+
+	// With the opponent owning the cube the position is played on
+	state.CubeOwner, state.CubeValue = 1, 2
+	a, _ = exact.AnalyzeCube(state)
+	if a.DecisionType != NOT_AVAILABLE || a.Source != CubeSourceExact || math.Abs(a.NoDoubleEquity-10.0/36) > 1e-4 {
+		t.Errorf("opponent's cube: %v from %q, ND %.5f, want %v from %q, %.5f", a.DecisionType, a.Source, a.NoDoubleEquity, NOT_AVAILABLE, CubeSourceExact, 10.0/36)
+	}
+
+	// Match play is left to the match equity table, and positions beyond the
+	// database to Janowski
+	a, _ = exact.AnalyzeCube(&GameState{Board: state.Board, CubeValue: 1, CubeOwner: -1, MatchLength: 5})
+	if a.Source != CubeSourceMET {
+		t.Errorf("match play: source %q, want %q", a.Source, CubeSourceMET)
+	}
+	a, _ = exact.AnalyzeCube(&GameState{Board: boardFromPoints(map[int]uint8{1: 4}, map[int]uint8{1: 2}), CubeValue: 1, CubeOwner: -1})
+	if a.Source != CubeSourceJanowski {
+		t.Errorf("four checkers in a 3-checker database: source %q, want %q", a.Source, CubeSourceJanowski)
+	}
+}
@@ -0,0 +1,171 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+// cubeRolloutBatches is the number of batches the trials of a cube rollout
+// are split into to estimate the confidence intervals of its equities.
+const cubeRolloutBatches = 20
+
+// CubeRolloutResult is the cube decision of a position worked out from a
+// rollout instead of an evaluation.
+type CubeRolloutResult struct {
+	// Cube decision from the rolled-out probabilities, with the no double,
+	// double/take and double/pass equities
+	Analysis *CubeAnalysis
+
+	// Cubeless rollout the decision is worked out from
+	Cubeless *RolloutResult
+
+	// 95% confidence intervals (+/-) of the no double and double/take
+	// equities, and of the difference double/take - no double
+	NoDoubleCI   float64
+	DoubleTakeCI float64
+	DoubleDiffCI float64
+
+	// DoubleSignificant reports whether the double/take and no double
+	// equities differ by more than DoubleDiffCI, and TakeSignificant whether
+	// the double/take equity is further than DoubleTakeCI from the
+	// double/pass equity. When both hold, more trials are unlikely to change
+	// the decision.
+	DoubleSignificant bool
+	TakeSignificant   bool
+}
+
+// RolloutCube rolls out the position before the player on roll rolls and
+// decides the cube from the rollout: the no double and double/take branches
+// are valued from the rolled-out probabilities as AnalyzeCube values them
+// from an evaluation, and compared with the double/pass equity. Both branches
+// are played with the same dice, so their difference is known more precisely
+// than either equity. The trials are played without a cube, and the rollout
+// store is not used.
+func (e *Engine) RolloutCube(state *GameState, opts RolloutOptions) (*CubeRolloutResult, error) {
+	return e.RolloutCubeContext(context.Background(), state, opts)
+}
+
+// RolloutCubeContext is RolloutCube stopped early when ctx is done, with
+// ctx.Err(), as RolloutContext is.
+func (e *Engine) RolloutCubeContext(ctx context.Context, state *GameState, opts RolloutOptions) (*CubeRolloutResult, error) {
+	if opts.InitialDice != [2]int{} {
+		return nil, fmt.Errorf("a cube rollout starts before the roll: initial dice %v not allowed", opts.InitialDice)
+	}
+	if opts.LeafCubeful {
+		// The cube equities come from the rolled-out probabilities alone
+		return nil, fmt.Errorf("a cube rollout values the cube itself: cubeful leaves not allowed")
+	}
+	if opts.FirstRoll == FirstRollAuto {
+		// The cube is turned before the player on roll rolls
+		opts.FirstRoll = FirstRollNone
+	}
+	opts, err := opts.withDefaults(state)
+	if err != nil {
+		return nil, err
+	}
+	t, err := e.metTable(state.MET)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	outcomes, plies, err := e.playTrials(ctx, state, opts, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+	elapsed := time.Since(start)
+
+	// Accumulate the trials in order, and in consecutive batches
+	var total rolloutSums
+	batches := make([]rolloutSums, min(cubeRolloutBatches, len(outcomes)))
+	for i, outcome := range outcomes {
+		total.add(outcome)
+		batches[i*len(batches)/len(outcomes)].add(outcome)
+	}
+
+	cubeless := total.result()
+	cubeless.Seed = opts.Seed
+	cubeless.Truncate = opts.Truncate
+	cubeless.FirstRoll = opts.FirstRoll
+	cubeless.LeafPly = opts.LeafPly
+	cubeless.Manifest = e.rolloutManifest(state, opts, cubeless)
+	cubeless.setThroughput(len(outcomes), plies, elapsed)
+
+	ce := DefaultCubeEfficiency()
+	result := &CubeRolloutResult{
+		Analysis: e.cubeAnalysis(state, cubeless.evaluation(), ce, t),
+		Cubeless: cubeless,
+	}
+
+	// Jackknife the equities over the batches: the cube equities are not
+	// averages of the trials, so their spread is measured by leaving out one
+	// batch at a time
+	nd := make([]float64, len(batches))
+	dt := make([]float64, len(batches))
+	diff := make([]float64, len(batches))
+	for i, batch := range batches {
+		rest := total.without(batch)
+		a := e.cubeAnalysis(state, rest.result().evaluation(), ce, t)
+		nd[i] = a.NoDoubleEquity
+		dt[i] = a.DoubleTakeEq
+		diff[i] = a.DoubleTakeEq - a.NoDoubleEquity
+	}
+	result.NoDoubleCI = jackknifeCI(nd)
+	result.DoubleTakeCI = jackknifeCI(dt)
+	result.DoubleDiffCI = jackknifeCI(diff)
+
+	analysis := result.Analysis
+	result.DoubleSignificant = math.Abs(analysis.DoubleTakeEq-analysis.NoDoubleEquity) > result.DoubleDiffCI
+	result.TakeSignificant = math.Abs(analysis.DoubleTakeEq-analysis.DoublePassEq) > result.DoubleTakeCI
+	return result, nil
+}
+
+// evaluation returns the rolled-out probabilities and equity as an evaluation.
+func (r *RolloutResult) evaluation() *Evaluation {
+	return &Evaluation{
+		Equity:  r.Equity,
+		WinProb: r.WinProb,
+		WinG:    r.WinG,
+		WinBG:   r.WinBG,
+		LoseG:   r.LoseG,
+		LoseBG:  r.LoseBG,
+	}
+}
+
+// without returns the sums of the trials in s that are not in part.
+func (s rolloutSums) without(part rolloutSums) rolloutSums {
+	for i := range s.sumProbs {
+		s.sumProbs[i] -= part.sumProbs[i]
+		s.sumSqProbs[i] -= part.sumSqProbs[i]
+	}
+	s.sumEquity -= part.sumEquity
+	s.sumSqEquity -= part.sumSqEquity
+	s.trials -= part.trials
+	s.wins -= part.wins
+	s.gammonsWon -= part.gammonsWon
+	s.bgsWon -= part.bgsWon
+	s.losses -= part.losses
+	s.gammonsLost -= part.gammonsLost
+	s.bgsLost -= part.bgsLost
+	return s
+}
+
+// jackknifeCI returns the 95% confidence interval of an estimate from its
+// leave-one-batch-out values.
+func jackknifeCI(values []float64) float64 {
+	k := float64(len(values))
+	if k < 2 {
+		return 0
+	}
+	var mean float64
+	for _, v := range values {
+		mean += v
+	}
+	mean /= k
+	var ss float64
+	for _, v := range values {
+		ss += (v - mean) * (v - mean)
+	}
+	return 1.96 * math.Sqrt((k-1)/k*ss)
+}
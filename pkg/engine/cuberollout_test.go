@@ -0,0 +1,85 @@
+package engine
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+// A last-roll position: the opponent is sure to bear off next turn, and the
+// player on roll bears off both checkers on the 2-point with 26 rolls in 36.
+// At 72% with no gammons it is a double and a take.
+var lastRollDoubleTake = boardFromPoints(map[int]uint8{2: 2}, map[int]uint8{1: 1})
+
+func TestRolloutCubeDoubleTake(t *testing.T) {
+	e := quizEngine(t)
+	state := &GameState{Board: lastRollDoubleTake, CubeValue: 1, CubeOwner: -1}
+
+	static, err := e.AnalyzeCube(state)
+	if err != nil {
+		t.Fatalf("AnalyzeCube: %v", err)
+	}
+	if static.DecisionType != DOUBLE_TAKE {
+		t.Fatalf("static decision = %d, want DOUBLE_TAKE", static.DecisionType)
+	}
+
+	result, err := e.RolloutCube(state, RolloutOptions{Trials: 1296, Seed: 1})
+	if err != nil {
+		t.Fatalf("RolloutCube: %v", err)
+	}
+	a := result.Analysis
+	if a.DecisionType != DOUBLE_TAKE {
+		t.Errorf("rollout decision = %d, want DOUBLE_TAKE", a.DecisionType)
+	}
+	if p := result.Cubeless.WinProb; math.Abs(p-26.0/36) > 0.03 {
+		t.Errorf("rolled-out win chance = %.3f, want about %.3f", p, 26.0/36)
+	}
+	if result.Cubeless.TrialsCompleted != 1296 || result.Cubeless.FirstRoll != FirstRollNone {
+		t.Errorf("cubeless rollout = %+v", result.Cubeless)
+	}
+	if !result.DoubleSignificant || !result.TakeSignificant {
+		t.Errorf("ND %.3f, DT %.3f (diff CI %.3f, DT CI %.3f): significant = %t, %t; want both",
+			a.NoDoubleEquity, a.DoubleTakeEq, result.DoubleDiffCI, result.DoubleTakeCI,
+			result.DoubleSignificant, result.TakeSignificant)
+	}
+
+	// Both branches are played with the same dice, so their difference is
+	// known better than either of them
+	if result.DoubleDiffCI <= 0 || result.DoubleDiffCI >= result.NoDoubleCI || result.DoubleDiffCI >= result.DoubleTakeCI {
+		t.Errorf("CIs: ND %.3f, DT %.3f, difference %.3f", result.NoDoubleCI, result.DoubleTakeCI, result.DoubleDiffCI)
+	}
+
+	// A few dozen trials cannot tell doubling from not doubling
+	few, err := e.RolloutCube(state, RolloutOptions{Trials: 36, Seed: 1})
+	if err != nil {
+		t.Fatalf("RolloutCube: %v", err)
+	}
+	if few.DoubleSignificant {
+		t.Errorf("36 trials: ND %.3f vs DT %.3f (CI %.3f) reported significant",
+			few.Analysis.NoDoubleEquity, few.Analysis.DoubleTakeEq, few.DoubleDiffCI)
+	}
+}
+
+func TestRolloutCubeDeterministic(t *testing.T) {
+	e := quizEngine(t)
+	state := &GameState{Board: lastRollDoubleTake, CubeValue: 1, CubeOwner: -1}
+
+	one, _ := e.RolloutCube(state, RolloutOptions{Trials: 200, Seed: 7, Workers: 1})
+	many, _ := e.RolloutCube(state, RolloutOptions{Trials: 200, Seed: 7, Workers: 4})
+	if !reflect.DeepEqual(one.Analysis, many.Analysis) || one.DoubleDiffCI != many.DoubleDiffCI {
+		t.Errorf("1 worker: %+v, 4 workers: %+v", *one.Analysis, *many.Analysis)
+	}
+}
+
+func TestJackknifeCI(t *testing.T) {
+	if ci := jackknifeCI([]float64{0.3}); ci != 0 {
+		t.Errorf("one value: CI %.3f, want 0", ci)
+	}
+	if ci := jackknifeCI([]float64{0.2, 0.2, 0.2}); ci > 1e-12 {
+		t.Errorf("equal values: CI %.3f, want 0", ci)
+	}
+	// Leave-one-out means of 0, 1: variance (k-1)/k * 0.5 = 0.25
+	if ci := jackknifeCI([]float64{0, 1}); math.Abs(ci-1.96*0.5) > 1e-12 {
+		t.Errorf("CI = %.4f, want %.4f", ci, 1.96*0.5)
+	}
+}
@@ -0,0 +1,73 @@
+package engine
+
+// Most checker plays have one move clearly best, so the EPM of a player who
+// faced many close decisions compares badly with that of one whose match was
+// easy. The difficulty of a decision is the equity spread of its top
+// candidates: the smaller the spread, the harder the decision.
+
+const (
+	// HardDecisionSpread is the spread under which a move decision counts as
+	// hard.
+	HardDecisionSpread = 0.04
+
+	// NearBestLoss is the most equity a move may lose and still count as
+	// finding the answer to a hard decision.
+	NearBestLoss = 0.01
+)
+
+// DecisionSpread returns the equity between the best of a decision's
+// candidates and the third best, or the last if there are fewer. equities
+// are the candidates' equities, best first; a decision of one candidate has
+// spread 0.
+func DecisionSpread(equities []float64) float64 {
+	if len(equities) < 2 {
+		return 0
+	}
+	return equities[0] - equities[min(len(equities), 3)-1]
+}
+
+// DifficultyWeight returns the weight of a decision of spread in the
+// difficulty-weighted EPM: 1 for a hard decision, and in proportion as the
+// spread grows past HardDecisionSpread, so that a move best by 0.2 counts a
+// fifth.
+func DifficultyWeight(spread float64) float64 {
+	return HardDecisionSpread / max(spread, HardDecisionSpread)
+}
+
+// DifficultyStats sums up how hard a player's move decisions were and how
+// the player did on them.
+type DifficultyStats struct {
+	DecisionWeight       float64 `json:"decision_weight"`         // Sum of the unforced moves' DifficultyWeight
+	WeightedError        float64 `json:"weighted_error"`          // Equity lost, each move's weighted by its DifficultyWeight
+	WeightedErrorPerMove float64 `json:"weighted_error_per_move"` // WeightedError per DecisionWeight
+	HardDecisions        int     `json:"hard_decisions"`          // Unforced moves with a spread under HardDecisionSpread
+	HardCorrect          int     `json:"hard_correct"`            // Hard decisions losing at most NearBestLoss
+	HardAccuracy         float64 `json:"hard_accuracy"`           // HardCorrect as percentage of HardDecisions
+}
+
+// Add counts an unforced move of spread that lost loss. charged is whether
+// the loss counts as an error, as it does for the plain EPM.
+func (d *DifficultyStats) Add(spread, loss float64, charged bool) {
+	w := DifficultyWeight(spread)
+	d.DecisionWeight += w
+	if charged {
+		d.WeightedError += w * loss
+	}
+	if spread < HardDecisionSpread {
+		d.HardDecisions++
+		if loss <= NearBestLoss {
+			d.HardCorrect++
+		}
+	}
+}
+
+// Finish works out the weighted EPM and the hard decision accuracy from the
+// moves added.
+func (d *DifficultyStats) Finish() {
+	if d.DecisionWeight > 0 {
+		d.WeightedErrorPerMove = d.WeightedError / d.DecisionWeight
+	}
+	if d.HardDecisions > 0 {
+		d.HardAccuracy = 100 * float64(d.HardCorrect) / float64(d.HardDecisions)
+	}
+}
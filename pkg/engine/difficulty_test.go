@@ -0,0 +1,86 @@
+package engine
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDecisionSpread(t *testing.T) {
+	tests := []struct {
+		equities []float64
+		want     float64
+	}{
+		{nil, 0},
+		{[]float64{0.3}, 0},
+		{[]float64{0.3, 0.25}, 0.05},
+		{[]float64{0.3, 0.29, 0.1, -0.5}, 0.2},
+	}
+	for _, tt := range tests {
+		if got := DecisionSpread(tt.equities); math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("DecisionSpread(%v) = %v, want %v", tt.equities, got, tt.want)
+		}
+	}
+}
+
+func TestDifficultyWeight(t *testing.T) {
+	tests := []struct {
+		spread, want float64
+	}{
+		{0, 1},
+		{0.02, 1},
+		{HardDecisionSpread, 1},
+		{0.08, 0.5},
+		{0.2, 0.2},
+	}
+	for _, tt := range tests {
+		if got := DifficultyWeight(tt.spread); math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("DifficultyWeight(%v) = %v, want %v", tt.spread, got, tt.want)
+		}
+	}
+}
+
+func TestDifficultyStats(t *testing.T) {
+	var d DifficultyStats
+	d.Add(0.01, 0.01, true)  // Hard, near best at the boundary
+	d.Add(0.039, 0.05, true) // Hard, missed
+	d.Add(0.04, 0, false)    // Not hard at the boundary
+	d.Add(0.2, 0.1, true)    // Easy: a fifth of the weight
+	d.Add(0.08, 0.02, false) // Loss below the error threshold
+	d.Finish()
+
+	if d.HardDecisions != 2 || d.HardCorrect != 1 || d.HardAccuracy != 50 {
+		t.Errorf("hard decisions %d, correct %d, accuracy %.1f%%; want 2, 1, 50%%", d.HardDecisions, d.HardCorrect, d.HardAccuracy)
+	}
+	weight := 1 + 1 + 1 + 0.2 + 0.5
+	weighted := 0.01 + 0.05 + 0.2*0.1
+	if math.Abs(d.DecisionWeight-weight) > 1e-12 || math.Abs(d.WeightedError-weighted) > 1e-12 {
+		t.Errorf("weight %v, weighted error %v; want %v, %v", d.DecisionWeight, d.WeightedError, weight, weighted)
+	}
+	if want := weighted / weight; math.Abs(d.WeightedErrorPerMove-want) > 1e-12 {
+		t.Errorf("WeightedErrorPerMove = %v, want %v", d.WeightedErrorPerMove, want)
+	}
+
+	// Two players with the same errors: the one whose errors came in easy
+	// positions has the lower weighted EPM
+	var hard, easy DifficultyStats
+	for i := 0; i < 10; i++ {
+		hard.Add(0.02, 0.02, true)
+		easy.Add(0.3, 0.02, true)
+	}
+	hard.Add(0.02, 0.1, true)
+	easy.Add(0.3, 0.1, true)
+	hard.Finish()
+	easy.Finish()
+	if math.Abs(hard.WeightedErrorPerMove-easy.WeightedErrorPerMove) > 1e-12 {
+		t.Errorf("uniform difficulty should not change the EPM: %v against %v", hard.WeightedErrorPerMove, easy.WeightedErrorPerMove)
+	}
+	var mixed DifficultyStats
+	for i := 0; i < 10; i++ {
+		mixed.Add(0.02, 0.02, true)
+	}
+	mixed.Add(0.3, 0.1, true)
+	mixed.Finish()
+	if mixed.WeightedErrorPerMove >= hard.WeightedErrorPerMove {
+		t.Errorf("an error in an easy position weighs as much as one in a hard one: %v >= %v", mixed.WeightedErrorPerMove, hard.WeightedErrorPerMove)
+	}
+}
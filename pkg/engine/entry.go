@@ -0,0 +1,75 @@
+package engine
+
+// EntryStats counts how the 36 rolls enter the checkers one side has on the
+// bar against the home board points the other side has made.
+type EntryStats struct {
+	Opponent     bool    // The side on the bar is the opponent of the player on roll
+	OnBar        int     // Checkers on the bar
+	PointsMade   int     // Home board points made against them
+	EnterRolls   int     // Rolls that enter at least one checker
+	FullRolls    int     // Rolls that enter every checker on the bar
+	PartialRolls int     // Rolls that enter some but not all of them (two or more on the bar)
+	DanceRolls   int     // Rolls that enter none
+	DanceProb    float64 // DanceRolls / 36
+}
+
+// BlitzEntryPoints is the number of home board points made from which the
+// opponent's entry is worth mentioning in cube advice.
+const BlitzEntryPoints = 3
+
+// EntryStats returns the bar entry of each side of state with checkers on the
+// bar, the player on roll first. It returns nil if the bar is empty.
+func (e *Engine) EntryStats(state *GameState) []EntryStats {
+	var stats []EntryStats
+	for _, side := range []int{1, 0} {
+		if state.Board[side][24] > 0 {
+			stats = append(stats, barEntry(state.Board, side))
+		}
+	}
+	return stats
+}
+
+// barEntry counts the entering rolls of side's checkers on the bar. A checker
+// entering with a d lands on the other side's d-point, index d-1 of its half
+// of the board.
+func barEntry(board Board, side int) EntryStats {
+	home := board[1-side]
+	stats := EntryStats{Opponent: side == 0, OnBar: int(board[side][24])}
+	var open [7]bool
+	for d := 1; d <= 6; d++ {
+		open[d] = home[d-1] < 2
+		if !open[d] {
+			stats.PointsMade++
+		}
+	}
+
+	for d1 := 1; d1 <= 6; d1++ {
+		for d2 := 1; d2 <= 6; d2++ {
+			entered := 0
+			switch {
+			case d1 == d2 && open[d1]:
+				entered = 4
+			case d1 != d2:
+				if open[d1] {
+					entered++
+				}
+				if open[d2] {
+					entered++
+				}
+			}
+			entered = min(entered, stats.OnBar)
+			switch {
+			case entered == 0:
+				stats.DanceRolls++
+			case entered == stats.OnBar:
+				stats.EnterRolls++
+				stats.FullRolls++
+			default:
+				stats.EnterRolls++
+				stats.PartialRolls++
+			}
+		}
+	}
+	stats.DanceProb = float64(stats.DanceRolls) / 36
+	return stats
+}
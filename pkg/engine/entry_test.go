@@ -0,0 +1,104 @@
+package engine
+
+import "testing"
+
+func TestEntryStats(t *testing.T) {
+	e, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+
+	for made := 0; made <= 6; made++ {
+		open := 6 - made
+		for _, onBar := range []int{1, 2, 3, 5} {
+			// The player on roll's home board against the opponent's bar
+			state := &GameState{CubeValue: 1, CubeOwner: -1}
+			for i := 0; i < made; i++ {
+				state.Board[1][5-i] = 2
+			}
+			state.Board[1][12] = uint8(15 - 2*made)
+			state.Board[0][24] = uint8(onBar)
+			state.Board[0][12] = uint8(15 - onBar)
+
+			stats := e.EntryStats(state)
+			if len(stats) != 1 || !stats[0].Opponent {
+				t.Fatalf("%d made, %d on bar: %+v", made, onBar, stats)
+			}
+			s := stats[0]
+
+			// Doubles enter up to four, other rolls one per open die
+			want := EntryStats{Opponent: true, OnBar: onBar, PointsMade: made, DanceRolls: made * made}
+			switch onBar {
+			case 1:
+				want.FullRolls = 36 - made*made
+			case 2:
+				want.FullRolls = open * open
+			case 3:
+				want.FullRolls = open
+			}
+			want.EnterRolls = 36 - want.DanceRolls
+			want.PartialRolls = want.EnterRolls - want.FullRolls
+			want.DanceProb = float64(want.DanceRolls) / 36
+			if s != want {
+				t.Errorf("%d made, %d on bar: %+v, want %+v", made, onBar, s, want)
+			}
+		}
+	}
+
+	if stats := e.EntryStats(StartingPosition()); stats != nil {
+		t.Errorf("nobody on the bar: %+v", stats)
+	}
+}
+
+func TestEntryStatsMatchMoves(t *testing.T) {
+	// Entering rolls agree with the legal moves of the player on roll
+	e, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	for _, made := range [][]int{{1, 2, 3}, {2, 4, 5, 6}, {1, 2, 3, 4, 5}} {
+		for onBar := 1; onBar <= 2; onBar++ {
+			state := &GameState{CubeValue: 1, CubeOwner: -1}
+			state.Board[1][24] = uint8(onBar)
+			state.Board[1][12] = uint8(15 - onBar)
+			for _, p := range made {
+				state.Board[0][p-1] = 2
+			}
+			state.Board[0][12] = uint8(15 - 2*len(made))
+
+			var full, partial, dance int
+			for d1 := 1; d1 <= 6; d1++ {
+				for d2 := 1; d2 <= 6; d2++ {
+					entered := 0
+					for _, m := range GenerateMoves(state.Board, d1, d2).Moves {
+						n := 0
+						for i := 0; i < 4 && m.From[i] >= 0; i++ {
+							if m.From[i] == 24 {
+								n++
+							}
+						}
+						entered = max(entered, n)
+					}
+					switch {
+					case entered == 0:
+						dance++
+					case entered == onBar:
+						full++
+					default:
+						partial++
+					}
+				}
+			}
+
+			stats := e.EntryStats(state)
+			if len(stats) != 1 || stats[0].Opponent {
+				t.Fatalf("%v: %+v", made, stats)
+			}
+			s := stats[0]
+			if s.FullRolls != full || s.PartialRolls != partial || s.DanceRolls != dance {
+				t.Errorf("%v made, %d on bar: %d full, %d partial, %d dance; moves give %d, %d, %d",
+					made, onBar, s.FullRolls, s.PartialRolls, s.DanceRolls, full, partial, dance)
+			}
+		}
+	}
+}
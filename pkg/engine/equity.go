@@ -0,0 +1,152 @@
+package engine
+
+import (
+	"github.com/yourusername/bgengine/internal/met"
+	"github.com/yourusername/bgengine/internal/neuralnet"
+)
+
+// EvaluationFull is an evaluation together with the equities it gives for
+// the game state it was made for, all for the player on roll. Evaluation
+// holds the cubeless probabilities and the cubeless money equity.
+//
+// CubefulEquity is the equity of the player on roll with the cube as it
+// is, per unit of the cube: in money play the Janowski interpolation of
+// Cl2CfMoney between the dead and the live cube, and in match play the
+// match winning chance MWC converted to equity by Mwc2Eq. The match figure
+// is that of the game played out at the current cube: the value of owning
+// the cube is not modelled there.
+type EvaluationFull struct {
+	Evaluation
+	CubefulEquity float64 // Cubeful equity, normalized to the current cube
+	MWC           float64 // Cubeless match winning chance at the current cube (match play only)
+
+	CubeEfficiency float64 // Cube efficiency x of the money cubeful equity (money only)
+	Volatility     float64 // Volatility that refined CubeEfficiency (money only, with CubeEfficiency.VolatilityFactor)
+}
+
+// EvaluateFull evaluates the position at opts.Plies as
+// EvaluatePliedWithOptions does and works out its cubeful equity and, in
+// match play, its match winning chance from the match equity table
+// state.MET selects. Money equities use opts.CubeEfficiency, or the
+// defaults if it is nil.
+func (e *Engine) EvaluateFull(state *GameState, opts EvalOptions) (*EvaluationFull, error) {
+	t, err := e.metTable(state.MET)
+	if err != nil {
+		return nil, err
+	}
+	eval, err := e.EvaluatePliedWithOptions(state, opts)
+	if err != nil {
+		return nil, err
+	}
+	full := e.fullEvaluation(state, eval, opts.cubeEfficiency(), t)
+	return &full, nil
+}
+
+// cubeEfficiency returns opts.CubeEfficiency, or the defaults if it is nil.
+func (opts EvalOptions) cubeEfficiency() CubeEfficiency {
+	if opts.CubeEfficiency != nil {
+		return *opts.CubeEfficiency
+	}
+	return DefaultCubeEfficiency()
+}
+
+// fullEvaluation works out the equities of eval, the cubeless evaluation of
+// state for the player on roll. Money equities use the cube efficiency ce,
+// match equities the match equity table t. Every cubeful equity and MWC the
+// engine reports for a position comes from here.
+func (e *Engine) fullEvaluation(state *GameState, eval *Evaluation, ce CubeEfficiency, t *met.Table) EvaluationFull {
+	full := EvaluationFull{Evaluation: *eval}
+	ci := e.cubeInfo(state, t)
+	pci := &ci
+	if state.MatchLength == 0 {
+		x := ce.For(state.Board)
+		if ce.VolatilityFactor != 0 && x > 0 {
+			if v, err := e.Volatility(state); err == nil {
+				full.Volatility = v
+				x = ce.Refine(x, v)
+			}
+		}
+		full.CubeEfficiency = x
+		full.CubefulEquity = e.Cl2CfMoney(eval.outputs(), pci, x)
+		return full
+	}
+	full.MWC = matchWinningChance(t, state, eval, state.CubeValue)
+	full.CubefulEquity = e.Mwc2Eq(float32(full.MWC), pci)
+	return full
+}
+
+// cubeInfo returns the cube information of state, for match play from the
+// match equity table t. It is returned by value so that money positions,
+// ranked for every legal move, need no allocation.
+func (e *Engine) cubeInfo(state *GameState, t *met.Table) CubeInfo {
+	if state.MatchLength == 0 {
+		return *SetCubeInfoMoney(state.CubeValue, state.CubeOwner, state.Turn, false, false)
+	}
+	return *e.setCubeInfoMatch(t, state.CubeValue, state.CubeOwner, state.Turn,
+		state.MatchLength, state.Score, state.Crawford)
+}
+
+// outputs returns the probabilities of eval in the order of gnubg's output
+// arrays: win, win gammon, win backgammon, lose gammon, lose backgammon.
+func (eval *Evaluation) outputs() []float64 {
+	return []float64{eval.WinProb, eval.WinG, eval.WinBG, eval.LoseG, eval.LoseBG}
+}
+
+// matchWinningChance returns the MWC of the player on roll in state if the
+// game evaluated by eval is played out for cube, each result weighted by
+// its chance.
+func matchWinningChance(t *met.Table, state *GameState, eval *Evaluation, cube int) float64 {
+	var win, lose [3]float64
+	for n := 1; n <= 3; n++ {
+		win[n-1] = getMWCAfterWin(t, state, state.Turn, n*cube)
+		lose[n-1] = getMWCAfterLoss(t, state, state.Turn, n*cube)
+	}
+	return eval.WinProb*gammonWeighted(win, eval.WinProb, eval.WinG, eval.WinBG) +
+		(1-eval.WinProb)*gammonWeighted(lose, 1-eval.WinProb, eval.LoseG, eval.LoseBG)
+}
+
+// afterMove returns state after the player on roll plays m, with the
+// opponent on roll.
+func afterMove(state *GameState, m Move) GameState {
+	return GameState{
+		Board:         swapBoard(ApplyMove(state.Board, m)),
+		Turn:          1 - state.Turn,
+		CubeValue:     state.CubeValue,
+		CubeOwner:     state.CubeOwner,
+		MatchLength:   state.MatchLength,
+		Score:         state.Score,
+		Crawford:      state.Crawford,
+		MET:           state.MET,
+		TotalCheckers: swapTotals(state.TotalCheckers),
+	}
+}
+
+// moveEquities returns the cubeful equity and, in match play, the MWC of
+// the player who played a move in state, from eval, their evaluation of
+// after, the position after it. The opponent is on roll there, so the
+// equities are worked out for them and turned round. The cube efficiency is
+// that of the position's class, not refined by volatility, which would cost
+// an evaluation of every roll for every move.
+func (e *Engine) moveEquities(state, after *GameState, eval *Evaluation, ce CubeEfficiency, t *met.Table) (cubeful, mwc float64) {
+	ce.VolatilityFactor = 0
+	inv := eval.inverted()
+	full := e.fullEvaluation(after, &inv, ce, t)
+	if state.MatchLength > 0 {
+		mwc = 1 - full.MWC
+	}
+	return -full.CubefulEquity, mwc
+}
+
+// setMoveEquities sets the cubeful equity and MWC of mv, a move in state
+// with its evaluation, the class of the position after it, and the equity
+// it ranks by: the cubeful one with opts.Cubeful, else the cubeless one of
+// its evaluation.
+func (e *Engine) setMoveEquities(state *GameState, mv *MoveWithEval, opts EvalOptions, t *met.Table) {
+	after := afterMove(state, mv.Move)
+	mv.CubefulEquity, mv.MWC = e.moveEquities(state, &after, mv.Eval, opts.cubeEfficiency(), t)
+	mv.Class = e.classify(neuralnet.Board(after.Board)).String()
+	mv.Equity = mv.Eval.Equity
+	if opts.Cubeful {
+		mv.Equity = mv.CubefulEquity
+	}
+}
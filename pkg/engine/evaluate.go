@@ -1,8 +1,13 @@
 package engine
 
 import (
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/yourusername/bgengine/internal/bearoff"
 	"github.com/yourusername/bgengine/internal/met"
@@ -25,47 +30,173 @@ type Engine struct {
 	// Bearoff databases
 	bearoff   *bearoff.Database // One-sided bearoff database
 	bearoffTS *bearoff.Database // Two-sided bearoff database
+	bearoffOS *bearoff.Database // One-sided bearoff database of more than 6 points
 
-	// Match equity table
-	met *met.Table
+	// Match equity table, and the tables requests can select by name
+	// (GameState.MET)
+	met  *met.Table
+	mets map[string]*met.Table
 
-	// Evaluation cache
+	// Evaluation cache, and the book consulted before it
 	cache *EvalCache
+	book  *Book
 
-	// Reusable buffers
+	// Reusable input buffers (*[]float32 sized for the largest net)
 	inputPool sync.Pool
 
 	// SIMD optimization: pre-allocated evaluation buffers (per-network)
 	contactBufPool sync.Pool
 	raceBufPool    sync.Pool
 	crashedBufPool sync.Pool
-	outputPool     sync.Pool
+	pruneBufPool   sync.Pool
+
+	// Reusable move lists for BestMove, and ranking storage for RankMoves
+	moveListPool sync.Pool
+	rankPool     sync.Pool
+
+	// Stored rollout results, and locks so that concurrent rollouts of one
+	// key wait for each other instead of both playing the trials
+	rolloutStore RolloutStore
+	rolloutLocks [64]sync.Mutex
+
+	// Set once Warmup has run
+	warm atomic.Bool
+
+	// Evaluators switched off for comparisons (see Routing)
+	disableCrashed atomic.Bool
+	disableBearoff atomic.Bool
+
+	// Evaluate positions without a net as even (see EngineOptions.EvenFallback)
+	evenFallback bool
+	coreNetsOnly bool
+
+	// Evaluate races with one side at home from the bearoff database (see
+	// EngineOptions.HybridRace)
+	hybridRace bool
+
+	// Data files the engine was created from (see DataStatus)
+	data []DataFile
+
+	// Kernel the nets' hidden layers run on (see Kernel)
+	kernel string
 }
 
 // EngineOptions configures the engine
 type EngineOptions struct {
-	WeightsFile     string // Path to neural network weights (binary .wd format)
-	WeightsFileText string // Path to text format weights (alternative)
-	BearoffFile     string // Path to one-sided bearoff database
-	BearoffTSFile   string // Path to two-sided bearoff database
-	METFile         string // Path to match equity table
-	CacheSize       uint32 // Evaluation cache size (0 = default, negative = disabled)
+	WeightsFile     string       // Path to neural network weights (binary .wd format)
+	WeightsFileText string       // Path to text format weights (alternative)
+	BearoffFile     string       // Path to one-sided bearoff database
+	BearoffTSFile   string       // Path to two-sided bearoff database
+	BearoffOSFile   string       // Path to one-sided bearoff database of 7 to 12 points, such as gnubg_os.bd
+	METFile         string       // Path to match equity table
+	METName         string       // Name of a bundled match equity table (see met.Available); alternative to METFile
+	METFiles        []string     // More match equity tables requests can select by file name (see METNames)
+	CacheSize       uint32       // Evaluation cache size (0 = default, negative = disabled)
+	RolloutStore    RolloutStore // Store for rollout results (nil = rollouts are not kept)
+	BookFile        string       // Book of early positions to consult before the cache (see BuildBook)
+	SkipWarmup      bool         // Leave the engine cold; call Warmup later (see Warmup)
+
+	// BearoffLazy reads the bearoff databases from disk as positions are
+	// looked up instead of loading them into memory, for databases such as
+	// a 12-point gnubg_os.bd that are too large to hold. Close the engine
+	// to close their files.
+	BearoffLazy bool
+
+	// Evaluators to switch off, to measure what they add (see Routing)
+	DisableCrashedNet bool
+	DisableBearoffDB  bool
+
+	// EvenFallback evaluates positions whose net is not loaded as even
+	// instead of estimating them from the pip counts, so that only the
+	// bearoff databases and exact solver tell positions apart.
+	EvenFallback bool
+
+	// CoreNetsOnly loads only the contact and race nets: crashed positions
+	// are evaluated with the contact net and moves are not pruned by net,
+	// so a trimmed weights file will do (see NewEngineFromBytes).
+	CoreNetsOnly bool
+
+	// ForceScalar runs the nets on the pure Go kernel even if the CPU
+	// supports a vector one, to rule the vector kernels out when debugging.
+	// Evaluations are the same either way (see Kernel).
+	ForceScalar bool
+
+	// HybridRace evaluates races in which one side is within the larger
+	// one-sided bearoff database and the other is not by the rolls each
+	// needs to bear off: exactly from the database for the side at home,
+	// and estimated from its effective pip count for the other. Gammons
+	// still come from the race net.
+	HybridRace bool
 }
 
-// NewEngine creates a new evaluation engine with the given options
-func NewEngine(opts EngineOptions) (*Engine, error) {
-	e := &Engine{
-		inputPool: sync.Pool{
-			New: func() interface{} {
-				return make([]float32, neuralnet.NumContactInputs)
-			},
-		},
-		outputPool: sync.Pool{
-			New: func() interface{} {
-				return make([]float32, 5)
-			},
-		},
+// Routing says which evaluators the engine leaves out, so that the engine
+// can be compared with and without them.
+type Routing struct {
+	// DisableCrashedNet evaluates crashed positions with the contact net
+	// and contact pruning net.
+	DisableCrashedNet bool
+	// DisableBearoffDB evaluates bearoffs with the race net instead of the
+	// bearoff databases and the exact bearoff solver.
+	DisableBearoffDB bool
+}
+
+// Disabled returns the names of the evaluators r switches off
+// ("crashed_net", "bearoff_db"), or nil.
+func (r Routing) Disabled() []string {
+	var names []string
+	if r.DisableCrashedNet {
+		names = append(names, "crashed_net")
+	}
+	if r.DisableBearoffDB {
+		names = append(names, "bearoff_db")
+	}
+	return names
+}
+
+// Kernel returns the kernel the nets' hidden layers run on: the fastest
+// the CPU supports (neuralnet.KernelAVX2 on amd64 CPUs with AVX2), or
+// neuralnet.KernelGo, the pure Go kernel, on other CPUs and with
+// EngineOptions.ForceScalar. All kernels evaluate alike, bit for bit.
+func (e *Engine) Kernel() string {
+	return e.kernel
+}
+
+// CPUFeatures returns the vector extensions of the CPU the kernels are
+// chosen by.
+func CPUFeatures() neuralnet.CPUFeatures {
+	return neuralnet.Features()
+}
+
+// Routing returns the evaluators the engine currently leaves out.
+func (e *Engine) Routing() Routing {
+	return Routing{
+		DisableCrashedNet: e.disableCrashed.Load(),
+		DisableBearoffDB:  e.disableBearoff.Load(),
 	}
+}
+
+// SetRouting switches evaluators off or back on while the engine is in use.
+// Cached evaluations depend on the routing, so the evaluation cache is
+// flushed when it changes; stored rollouts are kept.
+func (e *Engine) SetRouting(r Routing) {
+	changed := e.disableCrashed.Swap(r.DisableCrashedNet) != r.DisableCrashedNet
+	if e.disableBearoff.Swap(r.DisableBearoffDB) != r.DisableBearoffDB {
+		changed = true
+	}
+	if changed && e.cache != nil {
+		e.cache.Flush()
+	}
+}
+
+// NewEngine creates a new evaluation engine with the given options
+func NewEngine(opts EngineOptions) (_ *Engine, err error) {
+	e := newEngine(opts)
+	// Close the bearoff databases opened on demand if a later step fails
+	defer func() {
+		if err != nil {
+			e.Close()
+		}
+	}()
 
 	// Load neural network weights (try binary first, then text)
 	if opts.WeightsFile != "" {
@@ -73,34 +204,18 @@ func NewEngine(opts EngineOptions) (*Engine, error) {
 		if err != nil {
 			return nil, fmt.Errorf("failed to load binary weights: %w", err)
 		}
-		e.contact = weights.Contact
-		e.race = weights.Race
-		e.crashed = weights.Crashed
-		e.pContact = weights.PContact
-		e.pCrashed = weights.PCrashed
-		e.pRace = weights.PRace
-
-		// Initialize SIMD buffer pools
-		e.initBufferPools()
+		e.setWeights(weights, opts.CoreNetsOnly)
 	} else if opts.WeightsFileText != "" {
 		weights, err := neuralnet.LoadWeightsText(opts.WeightsFileText)
 		if err != nil {
 			return nil, fmt.Errorf("failed to load text weights: %w", err)
 		}
-		e.contact = weights.Contact
-		e.race = weights.Race
-		e.crashed = weights.Crashed
-		e.pContact = weights.PContact
-		e.pCrashed = weights.PCrashed
-		e.pRace = weights.PRace
-
-		// Initialize SIMD buffer pools
-		e.initBufferPools()
+		e.setWeights(weights, opts.CoreNetsOnly)
 	}
 
 	// Load one-sided bearoff database
 	if opts.BearoffFile != "" {
-		db, err := bearoff.LoadOneSided(opts.BearoffFile)
+		db, err := loadBearoff(opts.BearoffFile, opts.BearoffLazy)
 		if err != nil {
 			return nil, fmt.Errorf("failed to load one-sided bearoff database: %w", err)
 		}
@@ -109,28 +224,253 @@ func NewEngine(opts EngineOptions) (*Engine, error) {
 
 	// Load two-sided bearoff database
 	if opts.BearoffTSFile != "" {
-		db, err := bearoff.LoadOneSided(opts.BearoffTSFile) // Same loader works for both
+		db, err := loadBearoff(opts.BearoffTSFile, opts.BearoffLazy) // Same loader works for both
 		if err != nil {
 			return nil, fmt.Errorf("failed to load two-sided bearoff database: %w", err)
 		}
-		if db.Type != bearoff.BearoffTwoSided {
-			return nil, fmt.Errorf("expected two-sided bearoff database, got type %d", db.Type)
+		if err := e.setBearoffTS(db); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	// Load the larger one-sided bearoff database
+	if opts.BearoffOSFile != "" {
+		db, err := loadBearoff(opts.BearoffOSFile, opts.BearoffLazy)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load one-sided bearoff database %s: %w", opts.BearoffOSFile, err)
+		}
+		if err := e.setBearoffOS(db); err != nil {
+			db.Close()
+			return nil, err
 		}
-		e.bearoffTS = db
 	}
 
 	// Load match equity table
-	if opts.METFile != "" {
+	switch {
+	case opts.METFile != "" && opts.METName != "":
+		return nil, fmt.Errorf("METFile and METName are mutually exclusive")
+	case opts.METFile != "":
 		table, err := met.LoadXML(opts.METFile)
 		if err != nil {
 			return nil, fmt.Errorf("failed to load MET: %w", err)
 		}
 		e.met = table
-	} else {
+	case opts.METName != "":
+		table, err := met.Load(opts.METName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load MET: %w", err)
+		}
+		e.met = table
+	default:
+		e.met = met.Default()
+	}
+	mets, err := loadMETs(opts.METFiles)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load MET: %w", err)
+	}
+	// The table file loaded for the engine can be selected by name too
+	if name := met.TableName(opts.METFile); opts.METFile != "" && mets[name] == nil {
+		mets[name] = e.met
+	}
+	e.mets = mets
+
+	if e.data, err = dataFiles(opts); err != nil {
+		return nil, err
+	}
+
+	// Load the book, which must match the weights
+	if opts.BookFile != "" {
+		book, err := LoadBook(opts.BookFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load book: %w", err)
+		}
+		if err := e.SetBook(book); err != nil {
+			return nil, fmt.Errorf("%s: %w", opts.BookFile, err)
+		}
+	}
+
+	return e.start(opts)
+}
+
+// NewEngineFromBytes creates an engine from the contents of its data files
+// rather than their paths, for platforms without a file system such as
+// WebAssembly in a browser. weights may be a binary or text weights file;
+// bearoffOS and bearoffTS are the one- and two-sided bearoff databases and
+// metXML a match equity table in gnubg's XML format. Empty data is left out
+// as an empty path is by NewEngine. The file options of opts must be empty;
+// the other options apply as for NewEngine, and with CoreNetsOnly weights
+// may end after the race net.
+func NewEngineFromBytes(weights, bearoffOS, bearoffTS, metXML []byte, opts EngineOptions) (*Engine, error) {
+	if opts.WeightsFile != "" || opts.WeightsFileText != "" || opts.BearoffFile != "" ||
+		opts.BearoffTSFile != "" || opts.BearoffOSFile != "" || opts.METFile != "" || len(opts.METFiles) > 0 || opts.BookFile != "" {
+		return nil, fmt.Errorf("NewEngineFromBytes takes data, not file options")
+	}
+	e := newEngine(opts)
+
+	if len(weights) > 0 {
+		w, err := neuralnet.LoadWeightsBytes(weights, opts.CoreNetsOnly)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load weights: %w", err)
+		}
+		e.setWeights(w, opts.CoreNetsOnly)
+	}
+
+	if len(bearoffOS) > 0 {
+		db, err := bearoff.LoadOneSidedBytes(bearoffOS)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load one-sided bearoff database: %w", err)
+		}
+		e.bearoff = db
+	}
+
+	if len(bearoffTS) > 0 {
+		db, err := bearoff.LoadOneSidedBytes(bearoffTS)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load two-sided bearoff database: %w", err)
+		}
+		if err := e.setBearoffTS(db); err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case len(metXML) > 0 && opts.METName != "":
+		return nil, fmt.Errorf("metXML and METName are mutually exclusive")
+	case len(metXML) > 0:
+		table, err := met.LoadXMLBytes(metXML)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load MET: %w", err)
+		}
+		e.met = table
+	case opts.METName != "":
+		table, err := met.Load(opts.METName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load MET: %w", err)
+		}
+		e.met = table
+	default:
 		e.met = met.Default()
 	}
+	mets, err := loadMETs(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load MET: %w", err)
+	}
+	e.mets = mets
+
+	for _, d := range []struct {
+		kind string
+		data []byte
+	}{{"weights", weights}, {"bearoff", bearoffOS}, {"bearoff-ts", bearoffTS}, {"met", metXML}} {
+		e.data = append(e.data, dataBytes(d.kind, d.data)...)
+	}
+
+	return e.start(opts)
+}
 
-	// Create evaluation cache
+// newEngine returns an engine with no data loaded.
+func newEngine(opts EngineOptions) *Engine {
+	e := &Engine{
+		inputPool: sync.Pool{
+			New: func() interface{} {
+				inputs := make([]float32, neuralnet.NumContactInputs)
+				return &inputs
+			},
+		},
+		moveListPool: sync.Pool{
+			New: func() interface{} {
+				return &MoveList{
+					Moves:      make([]Move, 0, 32),
+					ResultKeys: make([]positionid.PositionKey, 0, 32),
+				}
+			},
+		},
+		rankPool: sync.Pool{
+			New: func() interface{} { return new(rankScratch) },
+		},
+		rolloutStore: opts.RolloutStore,
+		evenFallback: opts.EvenFallback,
+		coreNetsOnly: opts.CoreNetsOnly,
+		hybridRace:   opts.HybridRace,
+		kernel:       neuralnet.SelectKernel(opts.ForceScalar),
+	}
+	e.disableCrashed.Store(opts.DisableCrashedNet)
+	e.disableBearoff.Store(opts.DisableBearoffDB)
+	return e
+}
+
+// setWeights installs the nets of w, only the contact and race nets if
+// core is set.
+func (e *Engine) setWeights(w *neuralnet.Weights, core bool) {
+	e.contact = w.Contact
+	e.race = w.Race
+	if !core {
+		e.crashed = w.Crashed
+		e.pContact = w.PContact
+		e.pCrashed = w.PCrashed
+		e.pRace = w.PRace
+	}
+
+	// Initialize SIMD buffer pools
+	e.initBufferPools()
+}
+
+// setBearoffTS installs the two-sided bearoff database db.
+func (e *Engine) setBearoffTS(db *bearoff.Database) error {
+	if db.Type != bearoff.BearoffTwoSided {
+		return fmt.Errorf("expected two-sided bearoff database, got type %d", db.Type)
+	}
+	e.bearoffTS = db
+	return nil
+}
+
+// setBearoffOS installs db as the one-sided bearoff database of more than
+// 6 points.
+func (e *Engine) setBearoffOS(db *bearoff.Database) error {
+	if db.Type != bearoff.BearoffOneSided {
+		return fmt.Errorf("expected one-sided bearoff database, got type %d", db.Type)
+	}
+	if db.NPoints <= 6 {
+		return fmt.Errorf("one-sided bearoff database of %d points adds nothing to the 6-point one", db.NPoints)
+	}
+	e.bearoffOS = db
+	return nil
+}
+
+// loadBearoff loads the bearoff database in path, or opens it to be read
+// on demand if lazy is set.
+func loadBearoff(path string, lazy bool) (*bearoff.Database, error) {
+	if lazy {
+		return bearoff.OpenOneSided(path)
+	}
+	return bearoff.LoadOneSided(path)
+}
+
+// Close closes the bearoff database files the engine reads on demand
+// (EngineOptions.BearoffLazy). The engine must not be used afterwards. An
+// engine with its data in memory need not be closed.
+func (e *Engine) Close() error {
+	var errs []error
+	for _, db := range []*bearoff.Database{e.bearoff, e.bearoffTS, e.bearoffOS} {
+		if db != nil {
+			errs = append(errs, db.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// classify classifies board for evaluation, as ClassBearoffOS if it is
+// covered only by the larger one-sided bearoff database.
+func (e *Engine) classify(board neuralnet.Board) neuralnet.PositionClass {
+	if e.bearoffOS == nil {
+		return neuralnet.ClassifyPosition(board)
+	}
+	return neuralnet.ClassifyPositionOS(board, e.bearoffOS.NPoints, e.bearoffOS.NChequers)
+}
+
+// start creates the evaluation cache of an engine with its data loaded and
+// warms it up.
+func (e *Engine) start(opts EngineOptions) (*Engine, error) {
 	cacheSize := opts.CacheSize
 	if cacheSize == 0 {
 		cacheSize = DefaultCacheSize
@@ -139,6 +479,12 @@ func NewEngine(opts EngineOptions) (*Engine, error) {
 		e.cache = NewEvalCache(cacheSize)
 	}
 
+	if !opts.SkipWarmup {
+		if err := e.Warmup(); err != nil {
+			return nil, err
+		}
+	}
+
 	return e, nil
 }
 
@@ -147,21 +493,36 @@ func (e *Engine) initBufferPools() {
 	if e.contact != nil {
 		e.contactBufPool = sync.Pool{
 			New: func() interface{} {
-				return neuralnet.NewEvaluateBuffer(e.contact.CInput, e.contact.CHidden)
+				return neuralnet.NewEvaluateBufferKernel(e.contact.CInput, e.contact.CHidden, e.kernel)
 			},
 		}
 	}
 	if e.race != nil {
 		e.raceBufPool = sync.Pool{
 			New: func() interface{} {
-				return neuralnet.NewEvaluateBuffer(e.race.CInput, e.race.CHidden)
+				return neuralnet.NewEvaluateBufferKernel(e.race.CInput, e.race.CHidden, e.kernel)
 			},
 		}
 	}
 	if e.crashed != nil {
 		e.crashedBufPool = sync.Pool{
 			New: func() interface{} {
-				return neuralnet.NewEvaluateBuffer(e.crashed.CInput, e.crashed.CHidden)
+				return neuralnet.NewEvaluateBufferKernel(e.crashed.CInput, e.crashed.CHidden, e.kernel)
+			},
+		}
+	}
+
+	// The pruning nets share one pool sized for the widest hidden layer
+	var pruneHidden uint32
+	for _, nn := range []*neuralnet.NeuralNet{e.pContact, e.pCrashed, e.pRace} {
+		if nn != nil && nn.CHidden > pruneHidden {
+			pruneHidden = nn.CHidden
+		}
+	}
+	if pruneHidden > 0 {
+		e.pruneBufPool = sync.Pool{
+			New: func() interface{} {
+				return neuralnet.NewEvaluateBufferKernel(neuralnet.NumPruningInputs, pruneHidden, e.kernel)
 			},
 		}
 	}
@@ -179,105 +540,258 @@ func (e *Engine) SetCache(cache *EvalCache) {
 
 // Evaluate evaluates a position and returns the expected equities
 func (e *Engine) Evaluate(state *GameState) (*Evaluation, error) {
+	return e.evaluate(state, nil)
+}
+
+// evaluate is Evaluate adding to t, if not nil.
+func (e *Engine) evaluate(state *GameState, t *Timing) (*Evaluation, error) {
+	eval := new(Evaluation)
+	if err := e.evaluateInto(eval, state, t); err != nil {
+		return nil, err
+	}
+	return eval, nil
+}
+
+// evaluateInto is evaluate writing the evaluation to eval, for hot loops
+// that keep their evaluations in their own storage.
+func (e *Engine) evaluateInto(eval *Evaluation, state *GameState, t *Timing) error {
 	board := neuralnet.Board(state.Board)
 
 	// Classify the position
-	class := neuralnet.ClassifyPosition(board)
+	class := e.classify(board)
+	if class == neuralnet.ClassOver {
+		// Game is over
+		over, err := e.evaluateGameOver(board, state.checkerTotals())
+		if err != nil {
+			return err
+		}
+		*eval = *over
+		return nil
+	}
+
+	output, err := e.evaluateClass(board, class, state.checkerTotals(), t)
+	if err != nil {
+		return err
+	}
+
+	*eval = Evaluation{
+		WinProb: float64(output[0]),
+		WinG:    float64(output[1]),
+		WinBG:   float64(output[2]),
+		LoseG:   float64(output[3]),
+		LoseBG:  float64(output[4]),
+		Source:  e.source(board, class),
+	}
+
+	// Calculate equity
+	eval.Equity = eval.WinProb - (1 - eval.WinProb) +
+		eval.WinG - eval.LoseG +
+		eval.WinBG - eval.LoseBG
 
+	return nil
+}
+
+// evaluateOutput evaluates a position into the raw 5-value output without
+// allocating an Evaluation. Used on hot paths such as BestMove. totals are
+// the checkers each side plays with (see GameState.TotalCheckers).
+func (e *Engine) evaluateOutput(board neuralnet.Board, totals [2]int, t *Timing) ([5]float32, error) {
+	class := e.classify(board)
+	if class == neuralnet.ClassOver {
+		eval, err := e.evaluateGameOver(board, totals)
+		if err != nil {
+			return [5]float32{}, err
+		}
+		return [5]float32{
+			float32(eval.WinProb), float32(eval.WinG), float32(eval.WinBG),
+			float32(eval.LoseG), float32(eval.LoseBG),
+		}, nil
+	}
+	return e.evaluateClass(board, class, totals, t)
+}
+
+// outputEquity computes cubeless equity from a raw 5-value output
+func outputEquity(output [5]float32) float64 {
+	winProb := float64(output[0])
+	return winProb - (1 - winProb) +
+		float64(output[1]) - float64(output[3]) +
+		float64(output[2]) - float64(output[4])
+}
+
+// evaluateClass evaluates a position that is not over using the evaluator for its class.
+// Short bearoffs that provably finish within the exact horizon are solved exactly.
+func (e *Engine) evaluateClass(board neuralnet.Board, class neuralnet.PositionClass, totals [2]int, t *Timing) ([5]float32, error) {
+	if t != nil {
+		t.Evaluations++
+	}
+	if (class == neuralnet.ClassBearoffTS || class == neuralnet.ClassBearoff1) &&
+		!e.disableBearoff.Load() && preferExact(Board(board)) {
+		start := t.start()
+		probs, _ := e.solveExact(Board(board), totals, DefaultExactDepth)
+		if t != nil {
+			t.Bearoff += time.Since(start)
+		}
+		return probsToOutput(probs), nil
+	}
+	return e.evaluateStatic(board, class, totals, t)
+}
+
+// evaluateStatic evaluates a position that is not over with the bearoff databases or nets.
+// The output is sanitized, so it is always a consistent set of probabilities, with
+// no gammons against a side that has borne off some of its totals checkers.
+func (e *Engine) evaluateStatic(board neuralnet.Board, class neuralnet.PositionClass, totals [2]int, t *Timing) ([5]float32, error) {
 	var output [5]float32
 	var err error
 
-	switch class {
-	case neuralnet.ClassOver:
-		// Game is over
-		return e.evaluateGameOver(board)
+	switch {
+	case e.disableBearoff.Load() && isBearoffClass(class):
+		output, err = e.evaluateRace(board, t)
 
-	case neuralnet.ClassBearoffTS:
+	case class == neuralnet.ClassBearoffTS:
 		// Use two-sided bearoff database if available
 		if e.bearoffTS != nil {
 			boBoard := neuralnet.GetBearoffBoard(board)
-			output, err = e.bearoffTS.Evaluate(boBoard)
+			output, err = lookupBearoff(e.bearoffTS, boBoard, t)
 			if err != nil {
 				// Fall back to one-sided or race net
 				if e.bearoff != nil {
-					output, err = e.bearoff.Evaluate(boBoard)
+					output, err = lookupBearoff(e.bearoff, boBoard, t)
 				}
 				if err != nil {
-					output, err = e.evaluateRace(board)
+					output, err = e.evaluateRace(board, t)
 				}
 			}
 		} else if e.bearoff != nil {
 			// Fall back to one-sided database
 			boBoard := neuralnet.GetBearoffBoard(board)
-			output, err = e.bearoff.Evaluate(boBoard)
+			output, err = lookupBearoff(e.bearoff, boBoard, t)
 			if err != nil {
-				output, err = e.evaluateRace(board)
+				output, err = e.evaluateRace(board, t)
 			}
 		} else {
-			output, err = e.evaluateRace(board)
+			output, err = e.evaluateRace(board, t)
+		}
+
+	case class == neuralnet.ClassBearoffOS:
+		// Use the larger one-sided database, which is only loaded when it
+		// classifies positions as ClassBearoffOS
+		output, err = lookupBearoff(e.bearoffOS, neuralnet.GetBearoffBoard(board), t)
+		if err != nil {
+			output, err = e.evaluateRace(board, t)
 		}
 
-	case neuralnet.ClassBearoff1, neuralnet.ClassBearoff2, neuralnet.ClassBearoffOS:
+	case isBearoffClass(class):
 		// Use one-sided bearoff database
 		if e.bearoff != nil {
 			boBoard := neuralnet.GetBearoffBoard(board)
-			output, err = e.bearoff.Evaluate(boBoard)
+			output, err = lookupBearoff(e.bearoff, boBoard, t)
 			if err != nil {
 				// Fall back to race net
-				output, err = e.evaluateRace(board)
+				output, err = e.evaluateRace(board, t)
 			}
 		} else {
-			output, err = e.evaluateRace(board)
+			output, err = e.evaluateRace(board, t)
 		}
 
-	case neuralnet.ClassRace:
-		output, err = e.evaluateRace(board)
+	case class == neuralnet.ClassRace && e.hybridRace && !e.disableBearoff.Load():
+		output, err = e.evaluateRaceHybrid(board, t)
 
-	case neuralnet.ClassCrashed:
-		output, err = e.evaluateCrashed(board)
+	case class == neuralnet.ClassRace:
+		output, err = e.evaluateRace(board, t)
 
-	case neuralnet.ClassContact:
-		output, err = e.evaluateContact(board)
+	case class == neuralnet.ClassCrashed:
+		output, err = e.evaluateCrashed(board, t)
+
+	case class == neuralnet.ClassContact:
+		output, err = e.evaluateContact(board, t)
 
 	default:
-		return nil, fmt.Errorf("unknown position class: %d", class)
+		return output, fmt.Errorf("unknown position class: %d", class)
 	}
 
 	if err != nil {
-		return nil, err
+		return output, err
 	}
+	if totals != fullTotals {
+		e.partialGammons(board, class, totals, &output)
+	}
+	clearImpossibleGammons(board, totals, &output)
+	sanitizeOutput(&output, class.String())
+	return output, nil
+}
 
-	eval := &Evaluation{
-		WinProb: float64(output[0]),
-		WinG:    float64(output[1]),
-		WinBG:   float64(output[2]),
-		LoseG:   float64(output[3]),
-		LoseBG:  float64(output[4]),
+// partialGammons sets the gammon chances of a bearoff in a partial position
+// from the one-sided database's distributions of the rolls to bear off the
+// first checker. The databases see the checkers a partial position leaves
+// out as borne off, so they give no gammons against a side that has all of
+// its checkers on the board. Without gammon distributions output is kept.
+func (e *Engine) partialGammons(board neuralnet.Board, class neuralnet.PositionClass, totals [2]int, output *[5]float32) {
+	db := e.bearoff
+	if class == neuralnet.ClassBearoffOS {
+		db = e.bearoffOS
+	}
+	if db == nil || e.disableBearoff.Load() || !isBearoffClass(class) {
+		return
 	}
 
-	// Calculate equity
-	eval.Equity = eval.WinProb - (1 - eval.WinProb) +
-		eval.WinG - eval.LoseG +
-		eval.WinBG - eval.LoseBG
+	b := Board(board)
+	gammonable := [2]bool{b.OnBoard(0) == totals[0], b.OnBoard(1) == totals[1]}
+	gammons, err := db.EvaluateGammons(neuralnet.GetBearoffBoard(board), gammonable)
+	if err != nil {
+		return
+	}
+	output[1], output[2], output[3], output[4] = gammons[1], gammons[2], gammons[3], gammons[4]
+}
 
-	return eval, nil
+// lookupBearoff evaluates a bearoff board in db, adding the lookup to t.
+func lookupBearoff(db *bearoff.Database, board bearoff.Board, t *Timing) ([5]float32, error) {
+	start := t.start()
+	output, err := db.Evaluate(board)
+	if t != nil {
+		t.Bearoff += time.Since(start)
+	}
+	return output, err
+}
+
+// isBearoffClass reports whether class is one of the bearoff classes the
+// bearoff databases cover.
+func isBearoffClass(class neuralnet.PositionClass) bool {
+	switch class {
+	case neuralnet.ClassBearoffTS, neuralnet.ClassBearoff1, neuralnet.ClassBearoff2, neuralnet.ClassBearoffOS:
+		return true
+	}
+	return false
 }
 
 // EvaluateCached evaluates a position with caching support
-// plies specifies the ply depth for cache context (0 for neural net only)
+// plies is the depth of the evaluation (0 for neural net only; deeper
+// evaluations prune the moves as EvaluatePlied does). The engine's book, if
+// any, is consulted before the cache.
 func (e *Engine) EvaluateCached(state *GameState, plies int) (*Evaluation, error) {
+	return e.evaluateCached(state, plies, nil)
+}
+
+// evaluateCached is EvaluateCached adding to t, if not nil.
+func (e *Engine) evaluateCached(state *GameState, plies int, t *Timing) (*Evaluation, error) {
+	if eval, ok := e.bookEval(state, plies, t); ok {
+		return eval, nil
+	}
+
 	// If no cache, just evaluate directly
 	if e.cache == nil {
-		return e.Evaluate(state)
+		return e.evaluateUncached(state, plies, t)
 	}
 
 	// Create position key and eval context
 	key := positionid.MakePositionKey(positionid.Board(state.Board))
-	evalCtx := MakeEvalContext(plies, false, state.CubeOwner, state.CubeValue)
+	evalCtx := partialEvalContext(MakeEvalContext(plies, false, state.CubeOwner, state.CubeValue, state.Turn), state.checkerTotals())
 
 	// Check cache
 	output := make([]float32, 5)
+	start := t.start()
 	slot := e.cache.Lookup(key, evalCtx, output)
+	if t != nil {
+		t.Cache += time.Since(start)
+	}
 	if slot == CacheHit {
 		// Cache hit - reconstruct evaluation from cached output
 		eval := &Evaluation{
@@ -286,6 +800,7 @@ func (e *Engine) EvaluateCached(state *GameState, plies int) (*Evaluation, error
 			WinBG:   float64(output[2]),
 			LoseG:   float64(output[3]),
 			LoseBG:  float64(output[4]),
+			Source:  e.Source(state),
 		}
 		eval.Equity = eval.WinProb - (1 - eval.WinProb) +
 			eval.WinG - eval.LoseG +
@@ -294,7 +809,7 @@ func (e *Engine) EvaluateCached(state *GameState, plies int) (*Evaluation, error
 	}
 
 	// Cache miss - evaluate and store
-	eval, err := e.Evaluate(state)
+	eval, err := e.evaluateUncached(state, plies, t)
 	if err != nil {
 		return nil, err
 	}
@@ -305,157 +820,237 @@ func (e *Engine) EvaluateCached(state *GameState, plies int) (*Evaluation, error
 	output[2] = float32(eval.WinBG)
 	output[3] = float32(eval.LoseG)
 	output[4] = float32(eval.LoseBG)
+	start = t.start()
 	e.cache.Add(key, evalCtx, output, slot)
+	if t != nil {
+		t.Cache += time.Since(start)
+	}
 
 	return eval, nil
 }
 
-// evaluateGameOver handles positions where the game is over
-func (e *Engine) evaluateGameOver(board neuralnet.Board) (*Evaluation, error) {
-	// Count checkers for each side
-	var count [2]int
-	for side := 0; side < 2; side++ {
-		for i := 0; i < 25; i++ {
-			count[side] += int(board[side][i])
-		}
+// evaluateUncached evaluates state at plies plies, pruning the moves of a
+// lookahead.
+func (e *Engine) evaluateUncached(state *GameState, plies int, t *Timing) (*Evaluation, error) {
+	if plies > 0 {
+		return e.evaluateNPly(state, plies, true, time.Time{}, t)
 	}
+	return e.evaluate(state, t)
+}
 
+// evaluateGameOver handles positions where the game is over. totals are the
+// checkers each side plays with: the loser is gammoned if it has all of its
+// checkers on the board, and backgammoned if one of them is also on the bar or
+// in the winner's home board.
+func (e *Engine) evaluateGameOver(board neuralnet.Board, totals [2]int) (*Evaluation, error) {
+	b := Board(board)
 	eval := &Evaluation{}
-
-	if count[1] == 0 {
+	switch {
+	case b.OnBoard(1) == 0:
 		// Player 1 (on roll) has borne off all checkers - they win
 		eval.WinProb = 1.0
-		eval.Equity = 1.0
-		// Check for gammon/backgammon
-		if count[0] > 0 {
-			// Opponent still has checkers
-			hasInHomeBoard := false
-			hasOnBar := board[0][24] > 0
-			for i := 0; i < 6; i++ {
-				if board[0][i] > 0 {
-					hasInHomeBoard = true
-					break
-				}
-			}
-			if !hasInHomeBoard && !hasOnBar {
-				eval.WinG = 1.0
-				eval.Equity = 2.0
-				// Check for backgammon
-				hasInOpponentHome := false
-				for i := 18; i < 24; i++ {
-					if board[0][i] > 0 {
-						hasInOpponentHome = true
-						break
-					}
-				}
-				if hasInOpponentHome || hasOnBar {
-					eval.WinBG = 1.0
-					eval.Equity = 3.0
-				}
-			}
-		}
-	} else if count[0] == 0 {
+		eval.WinG, eval.WinBG = gameOverGammons(&b, 0, totals[0])
+	case b.OnBoard(0) == 0:
 		// Player 0 (not on roll) has borne off - they win (player 1 loses)
-		eval.WinProb = 0.0
-		eval.Equity = -1.0
-		// Check for gammon/backgammon
-		if count[1] > 0 {
-			hasInHomeBoard := false
-			hasOnBar := board[1][24] > 0
-			for i := 0; i < 6; i++ {
-				if board[1][i] > 0 {
-					hasInHomeBoard = true
-					break
-				}
-			}
-			if !hasInHomeBoard && !hasOnBar {
-				eval.LoseG = 1.0
-				eval.Equity = -2.0
-				hasInOpponentHome := false
-				for i := 18; i < 24; i++ {
-					if board[1][i] > 0 {
-						hasInOpponentHome = true
-						break
-					}
-				}
-				if hasInOpponentHome || hasOnBar {
-					eval.LoseBG = 1.0
-					eval.Equity = -3.0
-				}
-			}
-		}
+		eval.LoseG, eval.LoseBG = gameOverGammons(&b, 1, totals[1])
+	default:
+		return eval, nil
 	}
+	eval.Equity = eval.WinProb - (1 - eval.WinProb) +
+		eval.WinG - eval.LoseG +
+		eval.WinBG - eval.LoseBG
 
 	return eval, nil
 }
 
-// evaluateRace evaluates a race position using the race neural network (SIMD optimized)
-func (e *Engine) evaluateRace(board neuralnet.Board) ([5]float32, error) {
+// gameOverGammons returns whether the loser of a finished game, playing with
+// total checkers, is gammoned and backgammoned, as 0 or 1. It agrees with
+// winType, which rollouts use.
+func gameOverGammons(b *Board, loser, total int) (gammon, backgammon float64) {
+	switch winType(b, loser, total) {
+	case 3:
+		return 1, 1
+	case 2:
+		return 1, 0
+	}
+	return 0, 0
+}
+
+// evaluateRace evaluates a race position using the race neural network (SIMD optimized),
+// or the pip count formulas if the net is not loaded
+func (e *Engine) evaluateRace(board neuralnet.Board, t *Timing) ([5]float32, error) {
 	if e.race == nil {
-		return [5]float32{0.5, 0, 0, 0, 0}, nil
+		if e.evenFallback {
+			return [5]float32{0.5, 0, 0, 0, 0}, nil
+		}
+		start := t.start()
+		output := raceHeuristic(board)
+		if t != nil {
+			t.Heuristic += time.Since(start)
+		}
+		return output, nil
 	}
 
-	inputs := neuralnet.RaceInputs(board)
+	inputsPtr := e.inputPool.Get().(*[]float32)
+	defer e.inputPool.Put(inputsPtr)
+	inputs := *inputsPtr
+	start := t.start()
+	neuralnet.RaceInputsInto(board, inputs)
+	if t != nil {
+		t.Inputs += time.Since(start)
+		start = time.Now()
+	}
 
-	// Get buffer from pool and output slice
+	// Get buffer from pool
 	buf := e.raceBufPool.Get().(*neuralnet.EvaluateBuffer)
-	output := e.outputPool.Get().([]float32)
 	defer e.raceBufPool.Put(buf)
-	defer e.outputPool.Put(output)
-
-	e.race.EvaluateFast(inputs, output, buf)
 
 	var result [5]float32
-	copy(result[:], output[:5])
+	e.race.EvaluateFast(inputs, result[:], buf)
+	if t != nil {
+		t.Race += time.Since(start)
+	}
 	return result, nil
 }
 
 // evaluateCrashed evaluates a crashed position using the crashed neural network (SIMD optimized)
-func (e *Engine) evaluateCrashed(board neuralnet.Board) ([5]float32, error) {
-	if e.crashed == nil {
-		return e.evaluateContact(board)
+func (e *Engine) evaluateCrashed(board neuralnet.Board, t *Timing) ([5]float32, error) {
+	if e.crashed == nil || e.disableCrashed.Load() {
+		return e.evaluateContact(board, t)
 	}
 
-	inputs := neuralnet.CrashedInputs(board)
+	inputsPtr := e.inputPool.Get().(*[]float32)
+	defer e.inputPool.Put(inputsPtr)
+	inputs := *inputsPtr
+	start := t.start()
+	neuralnet.CrashedInputsInto(board, inputs)
+	if t != nil {
+		t.Inputs += time.Since(start)
+		start = time.Now()
+	}
 
 	// Get buffer from pool
 	buf := e.crashedBufPool.Get().(*neuralnet.EvaluateBuffer)
-	output := e.outputPool.Get().([]float32)
 	defer e.crashedBufPool.Put(buf)
-	defer e.outputPool.Put(output)
-
-	e.crashed.EvaluateFast(inputs, output, buf)
 
 	var result [5]float32
-	copy(result[:], output[:5])
+	e.crashed.EvaluateFast(inputs, result[:], buf)
+	if t != nil {
+		t.Crashed += time.Since(start)
+	}
 	return result, nil
 }
 
-// evaluateContact evaluates a contact position using the contact neural network (SIMD optimized)
-func (e *Engine) evaluateContact(board neuralnet.Board) ([5]float32, error) {
+// evaluateContact evaluates a contact position using the contact neural network (SIMD optimized),
+// or a pip count estimate if the net is not loaded
+func (e *Engine) evaluateContact(board neuralnet.Board, t *Timing) ([5]float32, error) {
 	if e.contact == nil {
-		return [5]float32{0.5, 0.15, 0.01, 0.15, 0.01}, nil
+		if e.evenFallback {
+			return [5]float32{0.5, 0.15, 0.01, 0.15, 0.01}, nil
+		}
+		start := t.start()
+		output := contactHeuristic(board)
+		if t != nil {
+			t.Heuristic += time.Since(start)
+		}
+		return output, nil
 	}
 
-	inputs := neuralnet.ContactInputs(board)
+	inputsPtr := e.inputPool.Get().(*[]float32)
+	defer e.inputPool.Put(inputsPtr)
+	inputs := *inputsPtr
+	start := t.start()
+	neuralnet.ContactInputsInto(board, inputs)
+	if t != nil {
+		t.Inputs += time.Since(start)
+		start = time.Now()
+	}
 
 	// Get buffer from pool
 	buf := e.contactBufPool.Get().(*neuralnet.EvaluateBuffer)
-	output := e.outputPool.Get().([]float32)
 	defer e.contactBufPool.Put(buf)
-	defer e.outputPool.Put(output)
-
-	e.contact.EvaluateFast(inputs, output, buf)
 
 	var result [5]float32
-	copy(result[:], output[:5])
+	e.contact.EvaluateFast(inputs, result[:], buf)
+	if t != nil {
+		t.Contact += time.Since(start)
+	}
 	return result, nil
 }
 
-// GetMatchEquity returns the match winning probability at the current score
+// GetMatchEquity returns the match winning probability at the current score,
+// from the table state.MET selects (the engine's own if it names none or an
+// unknown table)
 func (e *Engine) GetMatchEquity(state *GameState, player int) float32 {
-	if e.met == nil {
+	t, err := e.metTable(state.MET)
+	if err != nil {
+		t = e.met
+	}
+	if t == nil {
 		return 0.5
 	}
-	return e.met.GetME(state.Score[0], state.Score[1], state.MatchLength, player, state.Crawford)
+	return t.GetME(state.Score[0], state.Score[1], state.MatchLength, player, state.Crawford)
+}
+
+// METInfo returns the name and native length of the active match equity table
+func (e *Engine) METInfo() (name string, length int) {
+	if e.met == nil {
+		return "", 0
+	}
+	return e.met.Name, e.met.Length
+}
+
+// loadMETs loads the bundled match equity tables and the table files, keyed
+// by the names requests select them by.
+func loadMETs(files []string) (map[string]*met.Table, error) {
+	mets := make(map[string]*met.Table)
+	for _, name := range met.Available() {
+		table, err := met.Load(name)
+		if err != nil {
+			return nil, err
+		}
+		mets[name] = table
+	}
+	for _, file := range files {
+		name := met.TableName(file)
+		if _, ok := mets[name]; ok {
+			return nil, fmt.Errorf("%s: a table named %q is already loaded", file, name)
+		}
+		table, err := met.LoadXML(file)
+		if err != nil {
+			return nil, err
+		}
+		mets[name] = table
+	}
+	return mets, nil
+}
+
+// METNames returns the sorted names of the match equity tables a game state
+// can select with GameState.MET.
+func (e *Engine) METNames() []string {
+	names := make([]string, 0, len(e.mets))
+	for name := range e.mets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CheckMET returns an error if name is neither empty nor the name of a
+// loaded match equity table.
+func (e *Engine) CheckMET(name string) error {
+	_, err := e.metTable(name)
+	return err
+}
+
+// metTable returns the match equity table named name, or the engine's own
+// table if name is empty.
+func (e *Engine) metTable(name string) (*met.Table, error) {
+	if name == "" {
+		return e.met, nil
+	}
+	if t, ok := e.mets[strings.ToLower(strings.TrimSpace(name))]; ok {
+		return t, nil
+	}
+	return nil, fmt.Errorf("unknown MET %q (available: %s)", name, strings.Join(e.METNames(), ", "))
 }
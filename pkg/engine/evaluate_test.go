@@ -1,7 +1,16 @@
 package engine
 
 import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
 	"testing"
+
+	"github.com/yourusername/bgengine/internal/bearoff"
+	"github.com/yourusername/bgengine/internal/neuralnet"
 )
 
 func TestNewEngine(t *testing.T) {
@@ -36,6 +45,24 @@ func TestEvaluateStartingPosition(t *testing.T) {
 	}
 }
 
+func TestEvaluateAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("the race detector allocates")
+	}
+	// Without the cache every run goes through ContactInputs and the net
+	e := netEngine(t)
+	e.SetCache(nil)
+	state := StartingPosition()
+	if _, err := e.Evaluate(state); err != nil {
+		t.Fatalf("Evaluate failed: %v", err)
+	}
+
+	// A 0-ply contact evaluation allocates only the Evaluation it returns
+	if allocs := testing.AllocsPerRun(100, func() { e.Evaluate(state) }); allocs > 2 {
+		t.Errorf("Evaluate allocates %.1f times, want at most 2", allocs)
+	}
+}
+
 func TestEvaluateGameOver(t *testing.T) {
 	e, err := NewEngine(EngineOptions{})
 	if err != nil {
@@ -140,6 +167,87 @@ func TestEvaluateBackgammon(t *testing.T) {
 	}
 }
 
+func TestEvaluatePartial(t *testing.T) {
+	e, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+
+	// The player on roll bears its last checker off at once. With both of
+	// the opponent's checkers all it has, the exact solver scores a gammon
+	state := &GameState{CubeValue: 1, CubeOwner: -1, TotalCheckers: [2]int{2, 1}}
+	state.Board[1][0] = 1
+	state.Board[0][5] = 2
+	if eval, _ := e.Evaluate(state); eval.WinProb != 1 || eval.WinG != 1 || eval.Equity != 2 {
+		t.Errorf("partial last roll = %+v, want a sure gammon", eval)
+	}
+	full := *state
+	full.TotalCheckers = [2]int{}
+	if eval, _ := e.Evaluate(&full); eval.WinProb != 1 || eval.WinG != 0 || eval.Equity != 1 {
+		t.Errorf("full game last roll = %+v, want a single game", eval)
+	}
+
+	// The player on roll has borne off its five; the opponent, with all
+	// five on the board, is gammoned, where in a full game it would have
+	// borne off ten
+	state = &GameState{CubeValue: 1, CubeOwner: -1, TotalCheckers: [2]int{5, 5}}
+	for i := 0; i < 5; i++ {
+		state.Board[0][i+1] = 1
+	}
+	if eval, _ := e.Evaluate(state); eval.WinProb != 1 || eval.WinG != 1 || eval.WinBG != 0 || eval.Equity != 2 {
+		t.Errorf("finished 5-checker game = %+v, want a gammon", eval)
+	}
+	full = *state
+	full.TotalCheckers = [2]int{}
+	if eval, _ := e.Evaluate(&full); eval.WinProb != 1 || eval.WinG != 0 || eval.Equity != 1 {
+		t.Errorf("finished full game = %+v, want a single game", eval)
+	}
+}
+
+func TestEvaluatePartialBearoffGammons(t *testing.T) {
+	e, err := NewEngine(EngineOptions{BearoffFile: writeOneSidedBearoff(t, 6, 5, true), SkipWarmup: true})
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	defer e.Close()
+
+	// A 5-checker race too long to solve exactly: the player on roll bears
+	// off in two rolls, before the opponent, all on its 6-point, takes its
+	// first checker off
+	state := &GameState{CubeValue: 1, CubeOwner: -1, TotalCheckers: [2]int{5, 5}}
+	for i := 0; i < 5; i++ {
+		state.Board[1][i] = 1
+	}
+	state.Board[0][5] = 5
+	if preferExact(state.Board) {
+		t.Fatal("the race is short enough to solve exactly")
+	}
+
+	eval, err := e.EvaluateCached(state, 0)
+	if err != nil || eval.Source != SourceBearoff || eval.WinProb != 1 || eval.WinG != 1 {
+		t.Errorf("partial race = %+v, %v; want a sure gammon from the bearoff database", eval, err)
+	}
+
+	// In a full game the opponent has borne off ten: no gammon, and no
+	// cache entry shared with the partial position
+	full := *state
+	full.TotalCheckers = [2]int{}
+	if eval, err := e.EvaluateCached(&full, 0); err != nil || eval.WinProb != 1 || eval.WinG != 0 {
+		t.Errorf("full game race = %+v, %v; want a single game", eval, err)
+	}
+
+	// Moves and lookahead carry the totals to the positions after the move:
+	// after the rolls that leave more than 8 pips the opponent gets a roll
+	// first, but the rest still win a gammon
+	eval, err = e.EvaluatePlied(state, 1)
+	if err != nil || eval.WinG <= 0 {
+		t.Errorf("1-ply partial race = %+v, %v; want gammons", eval, err)
+	}
+	if eval, err := e.EvaluatePlied(&full, 1); err != nil || eval.WinG != 0 {
+		t.Errorf("1-ply full game race = %+v, %v; want no gammons", eval, err)
+	}
+}
+
 func TestGetMatchEquity(t *testing.T) {
 	e, err := NewEngine(EngineOptions{})
 	if err != nil {
@@ -159,6 +267,63 @@ func TestGetMatchEquity(t *testing.T) {
 	}
 }
 
+func TestOpponentOnRoll(t *testing.T) {
+	e, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+
+	// The last roll: 26/36 for the player on roll, while the opponent on roll
+	// bears off its last checker with any roll
+	state := &GameState{
+		Board:       boardFromPoints(map[int]uint8{2: 2}, map[int]uint8{1: 1}),
+		Dice:        [2]int{3, 1},
+		CubeValue:   2,
+		CubeOwner:   1,
+		MatchLength: 7,
+		Score:       [2]int{0, 4},
+	}
+	opp := state.OpponentOnRoll()
+	if opp.Turn != 1 || opp.Dice != [2]int{} || opp.Score != state.Score || opp.CubeOwner != 1 {
+		t.Errorf("OpponentOnRoll() = %+v", opp)
+	}
+	if back := opp.OpponentOnRoll(); back.Board != state.Board || back.Turn != 0 {
+		t.Errorf("swapping back gives %+v, want the original position", back)
+	}
+
+	eval, err := e.Evaluate(state)
+	if err != nil {
+		t.Fatalf("Evaluate failed: %v", err)
+	}
+	oppEval, err := e.Evaluate(opp)
+	if err != nil {
+		t.Fatalf("Evaluate failed: %v", err)
+	}
+	if math.Abs(eval.WinProb-26.0/36) > 1e-6 || oppEval.WinProb != 1 {
+		t.Errorf("win = %.4f, opponent's win = %.4f; want 26/36 and 1", eval.WinProb, oppEval.WinProb)
+	}
+
+	// The opponent's cube decision is the one of the swapped position with
+	// the score and cube owner seen from the opponent's side
+	want, err := e.AnalyzeCube(&GameState{
+		Board:       swapBoard(state.Board),
+		CubeValue:   2,
+		CubeOwner:   0,
+		MatchLength: 7,
+		Score:       [2]int{4, 0},
+	})
+	if err != nil {
+		t.Fatalf("AnalyzeCube failed: %v", err)
+	}
+	got, err := e.AnalyzeCube(opp)
+	if err != nil {
+		t.Fatalf("AnalyzeCube failed: %v", err)
+	}
+	if got.DecisionType != want.DecisionType || got.ArDouble != want.ArDouble || got.MatchContext.MWC != want.MatchContext.MWC {
+		t.Errorf("opponent's cube = %+v, want %+v", got, want)
+	}
+}
+
 // Global to prevent compiler optimizations
 var benchEval *Evaluation
 
@@ -207,3 +372,195 @@ func BenchmarkEvaluateEngineContact(b *testing.B) {
 		benchEval, _ = e.Evaluate(state)
 	}
 }
+
+func TestForceScalar(t *testing.T) {
+	e := createTestEngine(t)
+	scalar, err := NewEngine(EngineOptions{
+		WeightsFileText: filepath.Join("..", "..", "data", "gnubg.weights"),
+		CacheSize:       1 << 10,
+		SkipWarmup:      true,
+		ForceScalar:     true,
+	})
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	if scalar.Kernel() != neuralnet.KernelGo || e.Kernel() != neuralnet.SelectKernel(false) {
+		t.Fatalf("kernels %q and %q", e.Kernel(), scalar.Kernel())
+	}
+
+	// The kernels evaluate alike, bit for bit
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		board := neuralnet.RandomPosition(rng)
+		if i%2 == 1 {
+			board = neuralnet.RandomRacePosition(rng)
+		}
+		state := &GameState{Board: Board(board), CubeValue: 1, CubeOwner: -1}
+		want, err := e.Evaluate(state)
+		if err != nil {
+			t.Fatalf("Evaluate failed: %v", err)
+		}
+		got, err := scalar.Evaluate(state)
+		if err != nil {
+			t.Fatalf("Evaluate with ForceScalar failed: %v", err)
+		}
+		if *got != *want {
+			t.Fatalf("position %d: %+v with ForceScalar, want %+v", i, got, want)
+		}
+	}
+}
+
+func TestEvaluateCachedPerspectives(t *testing.T) {
+	// A cache of one node puts every position in the same slot
+	e, err := NewEngine(EngineOptions{CacheSize: 2, SkipWarmup: true})
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+
+	// The player on roll has three checkers left on the ace point, the
+	// opponent all fifteen on the six point
+	state := &GameState{CubeValue: 2, CubeOwner: 0}
+	state.Board[1][0] = 3
+	state.Board[0][5] = 15
+	views := []*GameState{state, state.OpponentOnRoll()}
+
+	var want [2]*Evaluation
+	for i, v := range views {
+		if want[i], err = e.Evaluate(v); err != nil {
+			t.Fatalf("Evaluate failed: %v", err)
+		}
+	}
+	if want[0].Equity == want[1].Equity {
+		t.Fatalf("both perspectives evaluate to %v", want[0].Equity)
+	}
+
+	// Interleaved in both orders, as ranking moves and rollouts do
+	for i := 0; i < 20; i++ {
+		for j := range views {
+			j = (j + i) % 2
+			got, err := e.EvaluateCached(views[j], 0)
+			if err != nil {
+				t.Fatalf("EvaluateCached failed: %v", err)
+			}
+			if *got != *want[j] {
+				t.Fatalf("round %d, perspective %d: %+v, want %+v", i, j, got, want[j])
+			}
+		}
+	}
+
+	// The cube owner is a player index, so the same board with the other
+	// player on roll is another entry
+	_, _, adds := e.cache.Stats()
+	turned := *state
+	turned.Turn = 1
+	if _, err := e.EvaluateCached(&turned, 0); err != nil {
+		t.Fatalf("EvaluateCached failed: %v", err)
+	}
+	if _, _, after := e.cache.Stats(); after != adds+1 {
+		t.Errorf("evaluating with the other player on roll added %d entries, want 1", after-adds)
+	}
+}
+
+func TestEvalContextLayout(t *testing.T) {
+	// A change to these bits is a new layout: bump EvalContextLayout with it
+	tests := []struct {
+		name      string
+		ctx, want int32
+	}{
+		{"2-ply cubeful, player 1 owns 4, player 1 on roll", MakeEvalContext(2, true, 1, 4, 1), 2 | 1<<4 | 2<<5 | 2<<7 | 1<<11},
+		{"centred cube, player 0 on roll", MakeEvalContext(0, false, -1, 1, 0), 0},
+		{"13 checkers against 15", partialEvalContext(0, [2]int{13, 15}), 2 << 12},
+		{"15 checkers against 1", partialEvalContext(0, [2]int{15, 1}), 14 << 16},
+	}
+	for _, tt := range tests {
+		if tt.ctx != tt.want {
+			t.Errorf("%s: context %#x, want %#x (layout %d)", tt.name, tt.ctx, tt.want, EvalContextLayout)
+		}
+	}
+}
+
+// writeOneSidedBearoff writes an uncompressed one-sided bearoff database of
+// nPoints points and nChequers checkers in which every position bears off in
+// its pip count over 8 rolls, rounded up, and returns its path. With gammon
+// it has gammon distributions too: a side bears off its first checker in one
+// roll, or two if it has none below the 5 point.
+func writeOneSidedBearoff(t *testing.T, nPoints, nChequers int, gammon bool) string {
+	t.Helper()
+	flag, size := 0, 64
+	if gammon {
+		flag, size = 1, 128
+	}
+	data := []byte(fmt.Sprintf("%-40s", fmt.Sprintf("gnubg-OS-%02d-%02d-%d-0-0", nPoints, nChequers, flag)))
+	for pos := 0; pos < bearoff.Combination(nPoints+nChequers, nPoints); pos++ {
+		pips, first := 0, 0
+		for i, c := range bearoff.PositionFromBearoff(pos, nPoints, nChequers) {
+			pips += (i + 1) * int(c)
+			if c > 0 && first == 0 {
+				first = 1 + i/4
+			}
+		}
+		record := make([]byte, size)
+		binary.LittleEndian.PutUint16(record[2*min(31, (pips+7)/8):], 65535)
+		if gammon {
+			binary.LittleEndian.PutUint16(record[64+2*first:], 65535)
+		}
+		data = append(data, record...)
+	}
+	path := filepath.Join(t.TempDir(), fmt.Sprintf("os%d.bd", nPoints))
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestBearoffOSFile(t *testing.T) {
+	path := writeOneSidedBearoff(t, 7, 3, false)
+
+	for _, lazy := range []bool{false, true} {
+		e, err := NewEngine(EngineOptions{BearoffOSFile: path, BearoffLazy: lazy, SkipWarmup: true})
+		if err != nil {
+			t.Fatalf("NewEngine failed: %v", err)
+		}
+		defer e.Close()
+		if e.bearoffOS.Lazy() != lazy {
+			t.Errorf("BearoffLazy %v: database read lazily %v", lazy, e.bearoffOS.Lazy())
+		}
+
+		// A checker on the 7 point bears off in a roll, as does the
+		// opponent's pair on the 1 point; the player on roll wins
+		state := &GameState{CubeValue: 1, CubeOwner: -1}
+		state.Board[1][0], state.Board[1][6] = 1, 1
+		state.Board[0][0] = 2
+		if class := e.classify(neuralnet.Board(state.Board)); class != neuralnet.ClassBearoffOS {
+			t.Fatalf("classified as %v, want %v", class, neuralnet.ClassBearoffOS)
+		}
+		eval, err := e.Evaluate(state)
+		if err != nil {
+			t.Fatalf("Evaluate failed: %v", err)
+		}
+		if eval.WinProb != 1 || eval.Source != SourceBearoff {
+			t.Errorf("BearoffLazy %v: win %.4f from %q, want a sure win from the database", lazy, eval.WinProb, eval.Source)
+		}
+		ins, err := e.Inspect(state)
+		if err != nil || ins.Evaluator != "bearoff_one_sided" || ins.Class != "bearoff_os" {
+			t.Errorf("Inspect = %+v, %v; want the one-sided database", ins, err)
+		}
+
+		// More checkers than the database holds are left to the race net
+		state.Board[1][6] = 3
+		if class := e.classify(neuralnet.Board(state.Board)); class != neuralnet.ClassRace {
+			t.Errorf("4 checkers classified as %v, want %v", class, neuralnet.ClassRace)
+		}
+		if e.Source(state) != SourceHeuristic {
+			t.Errorf("4 checkers evaluated by %q, want %q", e.Source(state), SourceHeuristic)
+		}
+	}
+
+	// The database must add points to the 6-point one
+	if _, err := NewEngine(EngineOptions{BearoffOSFile: writeOneSidedBearoff(t, 6, 3, false), SkipWarmup: true}); err == nil {
+		t.Error("NewEngine accepted a 6-point database as BearoffOSFile")
+	}
+	if _, err := NewEngineFromBytes(nil, nil, nil, nil, EngineOptions{BearoffOSFile: path}); err == nil {
+		t.Error("NewEngineFromBytes accepted BearoffOSFile")
+	}
+}
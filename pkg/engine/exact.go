@@ -0,0 +1,172 @@
+package engine
+
+import (
+	"github.com/yourusername/bgengine/internal/neuralnet"
+	"github.com/yourusername/bgengine/internal/positionid"
+)
+
+// Exact solver constants
+const (
+	DefaultExactDepth = 4  // Default horizon for SolveExact in plies
+	exactAutoMaxPips  = 16 // Evaluate only solves exactly when both sides together have at most this many pips
+)
+
+// exactEvalContext marks exact solver results in the evaluation cache.
+// It lies above every bit used by MakeEvalContext so it cannot collide.
+const exactEvalContext int32 = 1 << 30
+
+// ExactResult is the result of SolveExact
+type ExactResult struct {
+	Eval  Evaluation // Probabilities and cubeless equity for the player on roll
+	Exact bool       // True if every line ended within the horizon
+}
+
+// SolveExact computes win and gammon probabilities by enumerating all dice rolls and
+// the best play for each, up to maxDepth plies (DefaultExactDepth if maxDepth <= 0).
+// If every line ends within the horizon the result is exact; otherwise positions at
+// the horizon are evaluated statically and Exact is false.
+// Exact results are memoized in the evaluation cache.
+func (e *Engine) SolveExact(state *GameState, maxDepth int) (*ExactResult, error) {
+	if maxDepth <= 0 {
+		maxDepth = DefaultExactDepth
+	}
+
+	probs, exact := e.solveExact(state.Board, state.checkerTotals(), maxDepth)
+
+	eval := Evaluation{
+		WinProb: probs[0],
+		WinG:    probs[1],
+		WinBG:   probs[2],
+		LoseG:   probs[3],
+		LoseBG:  probs[4],
+	}
+	eval.Equity = eval.WinProb - (1 - eval.WinProb) +
+		eval.WinG - eval.LoseG +
+		eval.WinBG - eval.LoseBG
+
+	return &ExactResult{Eval: eval, Exact: exact}, nil
+}
+
+// solveExact is the recursive expectimax over dice and plays.
+// board has the player on roll in board[1]; probabilities are from their
+// perspective. totals are the checkers each side of board plays with.
+func (e *Engine) solveExact(board Board, totals [2]int, depth int) ([5]float64, bool) {
+	nnBoard := neuralnet.Board(board)
+	class := e.classify(nnBoard)
+	if class == neuralnet.ClassOver {
+		eval, _ := e.evaluateGameOver(nnBoard, totals)
+		return [5]float64{eval.WinProb, eval.WinG, eval.WinBG, eval.LoseG, eval.LoseBG}, true
+	}
+
+	if depth <= 0 {
+		output, err := e.evaluateStatic(nnBoard, class, totals, nil)
+		if err != nil {
+			return [5]float64{0.5, 0, 0, 0, 0}, false
+		}
+		return [5]float64{
+			float64(output[0]), float64(output[1]), float64(output[2]),
+			float64(output[3]), float64(output[4]),
+		}, false
+	}
+
+	// Exact results do not depend on the remaining depth
+	var key positionid.PositionKey
+	var slot uint32
+	ctx := partialEvalContext(exactEvalContext, totals)
+	if e.cache != nil {
+		key = positionid.MakePositionKey(positionid.Board(board))
+		var cached [5]float32
+		slot = e.cache.Lookup(key, ctx, cached[:])
+		if slot == CacheHit {
+			return [5]float64{
+				float64(cached[0]), float64(cached[1]), float64(cached[2]),
+				float64(cached[3]), float64(cached[4]),
+			}, true
+		}
+	}
+
+	var sum [5]float64
+	exact := true
+
+	for d1 := 1; d1 <= 6; d1++ {
+		for d2 := 1; d2 <= d1; d2++ {
+			weight := 2.0
+			if d1 == d2 {
+				weight = 1.0
+			}
+
+			ml := GenerateMoves(board, d1, d2)
+
+			var best [5]float64
+			if len(ml.Moves) == 0 {
+				// No legal move: the opponent rolls next on the same board
+				sub, subExact := e.solveExact(swapBoard(board), swapTotals(totals), depth-1)
+				best = invertProbs(sub)
+				exact = exact && subExact
+			} else {
+				bestEquity := 0.0
+				for i, m := range ml.Moves {
+					sub, subExact := e.solveExact(swapBoard(ApplyMove(board, m)), swapTotals(totals), depth-1)
+					probs := invertProbs(sub)
+					exact = exact && subExact
+					if eq := probsEquity(probs); i == 0 || eq > bestEquity {
+						best = probs
+						bestEquity = eq
+					}
+				}
+			}
+
+			for k := range sum {
+				sum[k] += weight * best[k]
+			}
+		}
+	}
+
+	for k := range sum {
+		sum[k] /= 36
+	}
+
+	if exact && e.cache != nil {
+		output := probsToOutput(sum)
+		e.cache.Add(key, ctx, output[:], slot)
+	}
+
+	return sum, exact
+}
+
+// preferExact reports whether Evaluate should use the exact solver for a bearoff:
+// the game must provably end within DefaultExactDepth plies and the position be small.
+// With all checkers home every die can be played for at least one pip,
+// so a side with p pips needs at most ceil(p/2) rolls.
+func preferExact(board Board) bool {
+	var pips [2]int
+	for side := 0; side < 2; side++ {
+		for i := 0; i < 25; i++ {
+			pips[side] += int(board[side][i]) * (i + 1)
+		}
+	}
+
+	if pips[0]+pips[1] > exactAutoMaxPips {
+		return false
+	}
+
+	// Player on roll (board[1]) finishes by ply 2k-1, the opponent by ply 2k
+	onRoll := 2*((pips[1]+1)/2) - 1
+	opponent := 2 * ((pips[0] + 1) / 2)
+	return onRoll <= DefaultExactDepth || opponent <= DefaultExactDepth
+}
+
+// invertProbs converts probabilities to the other player's perspective
+func invertProbs(p [5]float64) [5]float64 {
+	return [5]float64{1 - p[0], p[3], p[4], p[1], p[2]}
+}
+
+// probsEquity computes cubeless equity from probabilities
+func probsEquity(p [5]float64) float64 {
+	return p[0] - (1 - p[0]) + p[1] - p[3] + p[2] - p[4]
+}
+
+// probsToOutput converts probabilities to the raw evaluator output format
+func probsToOutput(p [5]float64) [5]float32 {
+	return [5]float32{float32(p[0]), float32(p[1]), float32(p[2]), float32(p[3]), float32(p[4])}
+}
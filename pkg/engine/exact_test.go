@@ -0,0 +1,173 @@
+package engine
+
+import (
+	"math"
+	"testing"
+
+	"github.com/yourusername/bgengine/internal/neuralnet"
+)
+
+func TestSolveExactLastRoll(t *testing.T) {
+	engine, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	// One checker on the 6-point against two on the ace: 27 of 36 rolls bear off
+	state := &GameState{CubeValue: 1, CubeOwner: -1}
+	state.Board[1][5] = 1
+	state.Board[0][0] = 2
+
+	result, err := engine.SolveExact(state, 0)
+	if err != nil {
+		t.Fatalf("SolveExact failed: %v", err)
+	}
+	if !result.Exact {
+		t.Error("Expected exact result")
+	}
+	if math.Abs(result.Eval.WinProb-0.75) > 1e-9 {
+		t.Errorf("Expected win probability 0.75, got %.6f", result.Eval.WinProb)
+	}
+	if result.Eval.WinG != 0 || result.Eval.LoseG != 0 {
+		t.Errorf("Expected no gammons, got WinG=%.4f LoseG=%.4f", result.Eval.WinG, result.Eval.LoseG)
+	}
+	if math.Abs(result.Eval.Equity-0.5) > 1e-9 {
+		t.Errorf("Expected equity 0.5, got %.6f", result.Eval.Equity)
+	}
+
+	// Evaluate uses the exact solver for this position automatically
+	eval, err := engine.Evaluate(state)
+	if err != nil {
+		t.Fatalf("Evaluate failed: %v", err)
+	}
+	if math.Abs(eval.WinProb-0.75) > 1e-6 {
+		t.Errorf("Evaluate: expected win probability 0.75, got %.6f", eval.WinProb)
+	}
+}
+
+func TestSolveExactCertainWin(t *testing.T) {
+	engine, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	// Two checkers on the ace always come off
+	state := &GameState{CubeValue: 1, CubeOwner: -1}
+	state.Board[1][0] = 2
+	state.Board[0][5] = 1
+
+	result, err := engine.SolveExact(state, 0)
+	if err != nil {
+		t.Fatalf("SolveExact failed: %v", err)
+	}
+	if !result.Exact || result.Eval.WinProb != 1.0 {
+		t.Errorf("Expected exact certain win, got WinProb=%.6f Exact=%v", result.Eval.WinProb, result.Exact)
+	}
+}
+
+func TestSolveExactInexactBeyondHorizon(t *testing.T) {
+	engine, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	// Three checkers each on the 6-point cannot all come off in one ply
+	state := &GameState{CubeValue: 1, CubeOwner: -1}
+	state.Board[1][5] = 3
+	state.Board[0][5] = 3
+
+	result, err := engine.SolveExact(state, 1)
+	if err != nil {
+		t.Fatalf("SolveExact failed: %v", err)
+	}
+	if result.Exact {
+		t.Error("Expected inexact result for a one-ply horizon")
+	}
+}
+
+func TestPreferExact(t *testing.T) {
+	tests := []struct {
+		name     string
+		onRoll   [6]uint8
+		opponent [6]uint8
+		want     bool
+	}{
+		{"last roll", [6]uint8{0, 0, 0, 0, 0, 1}, [6]uint8{2}, true},
+		{"opponent two rolls", [6]uint8{0, 0, 0, 0, 0, 2}, [6]uint8{4}, true},
+		{"both three rolls", [6]uint8{0, 0, 0, 0, 1}, [6]uint8{0, 0, 0, 0, 1}, false},
+		{"too many pips", [6]uint8{2}, [6]uint8{0, 0, 0, 0, 0, 3}, false},
+	}
+
+	for _, tt := range tests {
+		var board Board
+		copy(board[1][:6], tt.onRoll[:])
+		copy(board[0][:6], tt.opponent[:])
+		if got := preferExact(board); got != tt.want {
+			t.Errorf("%s: preferExact = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSolveExactMatchesBearoffDB(t *testing.T) {
+	engine, err := NewEngine(EngineOptions{BearoffFile: "../../data/gnubg_os0.bd"})
+	if err != nil || engine.bearoff == nil {
+		t.Skip("Skipping - bearoff database not available")
+	}
+
+	positions := [][2][6]uint8{
+		{{0, 0, 0, 0, 0, 1}, {2}},
+		{{1, 1}, {0, 0, 1}},
+		{{0, 2}, {0, 0, 1, 1}},
+		{{0, 0, 0, 2}, {3}},
+	}
+
+	for i, p := range positions {
+		state := &GameState{CubeValue: 1, CubeOwner: -1}
+		copy(state.Board[1][:6], p[0][:])
+		copy(state.Board[0][:6], p[1][:])
+
+		result, err := engine.SolveExact(state, 0)
+		if err != nil {
+			t.Fatalf("SolveExact failed: %v", err)
+		}
+		if !result.Exact {
+			t.Errorf("position %d: expected exact result", i)
+			continue
+		}
+
+		nnBoard := neuralnet.Board(state.Board)
+		output, err := engine.bearoff.Evaluate(neuralnet.GetBearoffBoard(nnBoard))
+		if err != nil {
+			t.Skipf("Skipping - bearoff database lookup failed: %v", err)
+		}
+		if math.Abs(result.Eval.WinProb-float64(output[0])) > 0.01 {
+			t.Errorf("position %d: exact %.4f, bearoff database %.4f", i, result.Eval.WinProb, output[0])
+		}
+	}
+}
+
+// TestRolloutExactSolverConcurrent has several rollout workers share the
+// exact solver's cache entries. Run it with -race.
+func TestRolloutExactSolverConcurrent(t *testing.T) {
+	engine, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	// Two checkers on the 6-point against four on the ace: Evaluate solves exactly
+	state := &GameState{CubeValue: 1, CubeOwner: -1}
+	state.Board[1][5] = 2
+	state.Board[0][0] = 4
+	if !preferExact(state.Board) {
+		t.Fatal("Expected the exact solver for this position")
+	}
+
+	if _, err := engine.Rollout(state, RolloutOptions{Trials: 200, Seed: 7, Workers: 4}); err != nil {
+		t.Fatalf("Rollout failed: %v", err)
+	}
+
+	lookups, hits, _ := engine.Cache().Stats()
+	if lookups == 0 || hits == 0 {
+		t.Errorf("Expected the workers to share cached exact results, got %d lookups and %d hits", lookups, hits)
+	}
+}
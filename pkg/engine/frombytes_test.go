@@ -0,0 +1,91 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/yourusername/bgengine/internal/neuralnet"
+)
+
+// weightsFile encodes nets as a binary weights file, in gnubg's order:
+// contact, race, crashed and the three pruning nets.
+func weightsFile(t *testing.T, nets ...*neuralnet.NeuralNet) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	put := func(v interface{}) {
+		if err := binary.Write(&buf, binary.LittleEndian, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	put(float32(neuralnet.WeightsMagicBinary))
+	put(float32(neuralnet.WeightsVersionBinary))
+	for _, nn := range nets {
+		for _, v := range []interface{}{nn.CInput, nn.CHidden, nn.COutput, nn.NTrained, nn.RBetaHidden, nn.RBetaOutput,
+			nn.HiddenWeight, nn.OutputWeight, nn.HiddenThreshold, nn.OutputThreshold} {
+			put(v)
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestNewEngineFromBytes(t *testing.T) {
+	contact := randomNet(neuralnet.NumContactInputs, 16, 1)
+	race := randomNet(neuralnet.NumRaceInputs, 16, 2)
+	nets := []*neuralnet.NeuralNet{contact, race,
+		randomNet(neuralnet.NumContactInputs, 16, 3),
+		randomNet(200, 8, 4), randomNet(200, 8, 5), randomNet(200, 8, 6)}
+	full := weightsFile(t, nets...)
+	core := weightsFile(t, contact, race)
+	metXML := []byte(`<?xml version="1.0"?>
+<met>
+  <info><name>Test Table</name><length>2</length></info>
+  <pre-crawford-table type="explicit">
+    <row><me>0.5</me><me>0.7</me></row>
+    <row><me>0.3</me><me>0.5</me></row>
+  </pre-crawford-table>
+</met>`)
+
+	e, err := NewEngineFromBytes(full, nil, nil, metXML, EngineOptions{SkipWarmup: true})
+	if err != nil {
+		t.Fatalf("NewEngineFromBytes: %v", err)
+	}
+	if e.crashed == nil || e.pContact == nil || e.pCrashed == nil || e.pRace == nil {
+		t.Error("NewEngineFromBytes left out nets")
+	}
+	if e.met.Name != "Test Table" {
+		t.Errorf("MET %q, want the one given", e.met.Name)
+	}
+	want, err := e.Evaluate(StartingPosition())
+	if err != nil {
+		t.Fatalf("Evaluate: %v", err)
+	}
+
+	// The core nets alone, from a full file or a trimmed one
+	for name, data := range map[string][]byte{"full": full, "trimmed": core} {
+		e, err := NewEngineFromBytes(data, nil, nil, nil, EngineOptions{CoreNetsOnly: true, SkipWarmup: true})
+		if err != nil {
+			t.Fatalf("NewEngineFromBytes(%s, CoreNetsOnly): %v", name, err)
+		}
+		if e.crashed != nil || e.pContact != nil || e.pCrashed != nil || e.pRace != nil {
+			t.Errorf("NewEngineFromBytes(%s, CoreNetsOnly) loaded more than the core nets", name)
+		}
+		got, err := e.Evaluate(StartingPosition())
+		if err != nil {
+			t.Fatalf("Evaluate: %v", err)
+		}
+		if got.Equity != want.Equity {
+			t.Errorf("%s core nets: starting equity %v, want %v", name, got.Equity, want.Equity)
+		}
+	}
+
+	if _, err := NewEngineFromBytes(core, nil, nil, nil, EngineOptions{SkipWarmup: true}); err == nil {
+		t.Error("NewEngineFromBytes of a trimmed file without CoreNetsOnly succeeded")
+	}
+	if _, err := NewEngineFromBytes(nil, nil, nil, nil, EngineOptions{WeightsFile: "gnubg.wd"}); err == nil {
+		t.Error("NewEngineFromBytes with a file option succeeded")
+	}
+	if _, err := NewEngineFromBytes(nil, nil, nil, metXML, EngineOptions{METName: "default"}); err == nil {
+		t.Error("NewEngineFromBytes with metXML and METName succeeded")
+	}
+}
@@ -0,0 +1,158 @@
+package engine
+
+import (
+	"math"
+
+	"github.com/yourusername/bgengine/internal/neuralnet"
+)
+
+// Sources of an evaluation, for Evaluation.Source
+const (
+	SourceNet       = "nn"        // A neural net
+	SourceBearoff   = "bearoff"   // A bearoff database or the exact bearoff solver
+	SourceHeuristic = "heuristic" // Pip count formulas standing in for a net that is not loaded
+)
+
+// Source returns what evaluates state statically: SourceNet,
+// SourceBearoff or SourceHeuristic, or "" if the game is over. Multi-ply
+// evaluations report the source of the position they start from.
+func (e *Engine) Source(state *GameState) string {
+	board := neuralnet.Board(state.Board)
+	return e.source(board, e.classify(board))
+}
+
+// source follows the routing of evaluateClass.
+func (e *Engine) source(board neuralnet.Board, class neuralnet.PositionClass) string {
+	bearoffDB := !e.disableBearoff.Load() && isBearoffClass(class)
+	switch {
+	case class == neuralnet.ClassOver:
+		return ""
+	case bearoffDB && (class == neuralnet.ClassBearoffTS || class == neuralnet.ClassBearoff1) && preferExact(Board(board)):
+		return SourceBearoff
+	case bearoffDB && class == neuralnet.ClassBearoffOS:
+		return SourceBearoff
+	case bearoffDB && (e.bearoff != nil || (class == neuralnet.ClassBearoffTS && e.bearoffTS != nil)):
+		return SourceBearoff
+	case class == neuralnet.ClassContact || class == neuralnet.ClassCrashed:
+		if e.contact != nil || (class == neuralnet.ClassCrashed && e.crashed != nil && !e.disableCrashed.Load()) {
+			return SourceNet
+		}
+	case e.race != nil:
+		return SourceNet
+	}
+	return SourceHeuristic
+}
+
+// KleinmanWinProb estimates the winning chance of the player on roll in a
+// race from the pip counts with Kleinman's formula: the lead plus half a
+// roll, over the spread of the remaining rolls, through the normal
+// distribution.
+func KleinmanWinProb(pips, oppPips int) float64 {
+	lead := float64(oppPips - pips + 4)
+	spread := 2 * math.Sqrt(math.Max(float64(pips+oppPips-4), 1))
+	return normalCDF(lead / spread)
+}
+
+// KeithCount returns the pip count of side adjusted for wastage as in Tom
+// Keith's count: 2 pips for each checker beyond the first on the 1 point, 1
+// for each beyond the first on the 2 point and the third on the 3 point, and
+// 1 for each empty 4, 5 or 6 point.
+func KeithCount(board Board, side int) int {
+	b := board[side]
+	count := PipCount(board)[side]
+	count += 2*max(int(b[0])-1, 0) + max(int(b[1])-1, 0) + max(int(b[2])-3, 0)
+	for i := 3; i < 6; i++ {
+		if b[i] == 0 {
+			count++
+		}
+	}
+	return count
+}
+
+// KeithWinProb estimates the winning chance of the player on roll in a
+// race from the Keith counts of both sides, spread by the dice: a roll moves
+// 8.17 pips on average with a variance of 18.6, so the pips the sides roll
+// in the rest of the race differ by about 1.51√(sum of the counts).
+func KeithWinProb(board Board) float64 {
+	return raceWinProb(KeithCount(board, 1), KeithCount(board, 0))
+}
+
+// raceWinProb is the chance that the player on roll, needing count pips,
+// finishes before an opponent needing oppCount.
+func raceWinProb(count, oppCount int) float64 {
+	lead := float64(oppCount - count + 4)
+	spread := 1.51 * math.Sqrt(math.Max(float64(count+oppCount), 1))
+	return normalCDF(lead / spread)
+}
+
+// raceHeuristic stands in for the race net: the winning chance is
+// KeithWinProb, and a side wins a gammon when it bears off before the other
+// brings its checkers home and bears one off.
+func raceHeuristic(board neuralnet.Board) [5]float32 {
+	b := Board(board)
+	count := [2]int{KeithCount(b, 0), KeithCount(b, 1)}
+	win := raceWinProb(count[1], count[0])
+
+	var output [5]float32
+	output[0] = float32(win)
+	if pips, ok := gammonSavePips(b, 0); ok {
+		output[1] = float32(min(raceWinProb(count[1], pips), win))
+	}
+	if pips, ok := gammonSavePips(b, 1); ok {
+		output[3] = float32(min(1-raceWinProb(pips, count[0]), 1-win))
+	}
+	return output
+}
+
+// gammonSavePips returns roughly the pips side needs to save the gammon: to
+// bring every checker home and bear one off. ok is false if side has already
+// borne a checker off.
+func gammonSavePips(b Board, side int) (pips int, ok bool) {
+	n := 0
+	for i, c := range b[side] {
+		n += int(c)
+		if i >= 6 {
+			pips += int(c) * (i - 5)
+		}
+	}
+	if n < 15 {
+		return 0, false
+	}
+	return pips + 8, true
+}
+
+// contactHeuristic stands in for the contact and crashed nets: a race
+// estimate on pip counts adjusted for blots, home board points and anchors,
+// with a wider spread than a race, and gammons growing with the lead.
+func contactHeuristic(board neuralnet.Board) [5]float32 {
+	b := Board(board)
+	pips := PipCount(b)
+	lead := float64(pips[0]-pips[1]+4) + 4*float64(structure(b, 1)-structure(b, 0))
+	spread := 3 * math.Sqrt(float64(pips[0]+pips[1]))
+	win := normalCDF(lead / spread)
+
+	winG := 0.6 * win * win
+	loseG := 0.6 * (1 - win) * (1 - win)
+	return [5]float32{float32(win), float32(winG), float32(winG / 15), float32(loseG), float32(loseG / 15)}
+}
+
+// structure scores side's position in units of about 4 pips: a point for
+// each point made in its home board and each anchor in the opponent's, less
+// a point for each blot.
+func structure(b Board, side int) int {
+	score := 0
+	for i, c := range b[side][:24] {
+		switch {
+		case c == 1:
+			score--
+		case c >= 2 && (i < 6 || i >= 18):
+			score++
+		}
+	}
+	return score
+}
+
+// normalCDF is the standard normal distribution function.
+func normalCDF(x float64) float64 {
+	return 0.5 * math.Erfc(-x/math.Sqrt2)
+}
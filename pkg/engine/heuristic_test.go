@@ -0,0 +1,182 @@
+package engine
+
+import (
+	"math"
+	"testing"
+
+	"github.com/yourusername/bgengine/internal/neuralnet"
+)
+
+// bearoffRolls computes one-sided bearoff distributions the way the
+// bearoff databases are built: for each position of the player's home
+// board, the chance of bearing off in exactly n rolls, playing each roll to
+// bear off in the fewest rolls on average.
+type bearoffRolls map[[6]uint8][]float64
+
+func (db bearoffRolls) distribution(home [6]uint8) []float64 {
+	if dist, ok := db[home]; ok {
+		return dist
+	}
+	if home == ([6]uint8{}) {
+		db[home] = []float64{1}
+		return db[home]
+	}
+	var dist []float64
+	for d1 := 1; d1 <= 6; d1++ {
+		for d2 := 1; d2 <= d1; d2++ {
+			weight := 2.0 / 36
+			if d1 == d2 {
+				weight = 1.0 / 36
+			}
+			var b Board
+			copy(b[1][:6], home[:])
+			var best []float64
+			bestMean := math.Inf(1)
+			for _, m := range GenerateMoves(b, d1, d2).Moves {
+				var next [6]uint8
+				after := ApplyMove(b, m)
+				copy(next[:], after[1][:6])
+				if d := db.distribution(next); meanRolls(d) < bestMean {
+					best, bestMean = d, meanRolls(d)
+				}
+			}
+			for len(dist) < len(best)+1 {
+				dist = append(dist, 0)
+			}
+			for n, p := range best {
+				dist[n+1] += weight * p
+			}
+		}
+	}
+	db[home] = dist
+	return dist
+}
+
+func meanRolls(dist []float64) float64 {
+	mean := 0.0
+	for n, p := range dist {
+		mean += float64(n) * p
+	}
+	return mean
+}
+
+// raceWin is the chance that the player on roll bears off first.
+func raceWin(dist, oppDist []float64) float64 {
+	win := 0.0
+	for n, p := range dist {
+		for m := n; m < len(oppDist); m++ {
+			win += p * oppDist[m]
+		}
+	}
+	return win
+}
+
+// bearoffHomes lists the home boards with n checkers.
+func bearoffHomes(n int) [][6]uint8 {
+	var homes [][6]uint8
+	var gen func(home [6]uint8, point, left int)
+	gen = func(home [6]uint8, point, left int) {
+		if point == 5 {
+			home[point] = uint8(left)
+			homes = append(homes, home)
+			return
+		}
+		for c := 0; c <= left; c++ {
+			home[point] = uint8(c)
+			gen(home, point+1, left-c)
+		}
+	}
+	gen([6]uint8{}, 0, n)
+	return homes
+}
+
+func TestRaceHeuristic(t *testing.T) {
+	db := bearoffRolls{}
+	homes := bearoffHomes(10)
+
+	// A grid of 10 checker bearoffs against each other
+	var kleinman, keith float64
+	n := 0
+	for i := 0; i < len(homes); i += 119 {
+		for j := 3; j < len(homes); j += 126 {
+			var b Board
+			copy(b[1][:6], homes[i][:])
+			copy(b[0][:6], homes[j][:])
+			exact := raceWin(db.distribution(homes[i]), db.distribution(homes[j]))
+			pips := PipCount(b)
+			kleinman += math.Abs(KleinmanWinProb(pips[1], pips[0]) - exact)
+			keith += math.Abs(KeithWinProb(b) - exact)
+			n++
+		}
+	}
+	kleinman /= float64(n)
+	keith /= float64(n)
+	if kleinman > 0.07 {
+		t.Errorf("Kleinman estimate off by %.3f on average over %d bearoffs", kleinman, n)
+	}
+	if keith > 0.05 {
+		t.Errorf("Keith estimate off by %.3f on average over %d bearoffs", keith, n)
+	}
+}
+
+func TestHeuristicFallback(t *testing.T) {
+	e, err := NewEngine(EngineOptions{SkipWarmup: true})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	evaluate := func(b Board) *Evaluation {
+		t.Helper()
+		eval, err := e.EvaluateCached(&GameState{Board: b, CubeValue: 1, CubeOwner: -1}, 0)
+		if err != nil {
+			t.Fatalf("Evaluate failed: %v", err)
+		}
+		return eval
+	}
+
+	// The player on roll is slightly ahead at the start
+	start := evaluate(StartingPosition().Board)
+	if start.Source != SourceHeuristic || start.WinProb <= 0.5 || start.WinProb > 0.6 || start.WinG <= 0 {
+		t.Errorf("starting position: %+v, want a heuristic a little over 50%%", start)
+	}
+	if cached := evaluate(StartingPosition().Board); cached.Source != SourceHeuristic {
+		t.Errorf("cached evaluation source %q, want %q", cached.Source, SourceHeuristic)
+	}
+
+	// A race 74 pips ahead is nearly won, and 74 pips behind nearly lost
+	var ahead Board
+	ahead[1][5], ahead[1][7] = 8, 7  // 97 pips
+	ahead[0][9], ahead[0][11] = 8, 7 // 171 pips
+	behind := Board{ahead[1], ahead[0]}
+	if eval := evaluate(ahead); eval.Source != SourceHeuristic || eval.WinProb < 0.95 {
+		t.Errorf("race 74 pips ahead: %+v", eval)
+	}
+	if eval := evaluate(behind); eval.WinProb > 0.05 {
+		t.Errorf("race 74 pips behind: %+v", eval)
+	}
+
+	// A gammonish race: the opponent still has checkers far back
+	gammonish := ahead
+	gammonish[0] = [25]uint8{}
+	gammonish[0][20], gammonish[0][18] = 8, 7
+	if eval := evaluate(gammonish); eval.WinG < 0.5 || eval.WinG > eval.WinProb {
+		t.Errorf("gammonish race: %+v", eval)
+	}
+
+	var bearoff, over Board
+	bearoff[1][0], bearoff[1][1] = 2, 1
+	bearoff[0][0], bearoff[0][2] = 1, 1
+	over[0][5] = 3
+	if got := evaluate(bearoff).Source; got != SourceBearoff {
+		t.Errorf("short bearoff source %q, want %q", got, SourceBearoff)
+	}
+	if got := evaluate(over).Source; got != "" {
+		t.Errorf("finished game source %q, want none", got)
+	}
+
+	e.race = constantNet(neuralnet.NumRaceInputs, 0, -9, -9, -9, -9)
+	e.initBufferPools()
+	e.cache.Flush()
+	if got := evaluate(ahead).Source; got != SourceNet {
+		t.Errorf("race source with a race net %q, want %q", got, SourceNet)
+	}
+}
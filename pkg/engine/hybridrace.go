@@ -0,0 +1,171 @@
+package engine
+
+import (
+	"math"
+	"time"
+
+	"github.com/yourusername/bgengine/internal/bearoff"
+	"github.com/yourusername/bgengine/internal/neuralnet"
+)
+
+// A race in which one side has borne in and the other still has checkers to
+// bring home is not a bearoff, so the race net evaluates it, though a
+// one-sided bearoff database knows exactly how many rolls the side at home
+// needs. The hybrid race evaluator (EngineOptions.HybridRace) takes that
+// side's rolls from the database, estimates the other side's from its
+// effective pip count, and works out the winning chance from the two.
+
+// Pips a roll of the dice moves, doubles counting four times: the mean and
+// variance over the 36 rolls, 8.17 and 18.5.
+var diceRollMean, diceRollVariance = diceRollMoments()
+
+func diceRollMoments() (mean, variance float64) {
+	var sum, sumSq float64
+	for d1 := 1; d1 <= 6; d1++ {
+		for d2 := 1; d2 <= 6; d2++ {
+			pips := float64(d1 + d2)
+			if d1 == d2 {
+				pips *= 2
+			}
+			sum += pips
+			sumSq += pips * pips
+		}
+	}
+	mean = sum / 36
+	return mean, sumSq/36 - mean*mean
+}
+
+// keithWastage is what the effective pip count of a side outside the
+// bearoff databases adds to its Keith count. Smooth bearoffs waste about 7
+// pips, of which Keith's count makes up about 2.
+const keithWastage = 5
+
+// effectivePips estimates the effective pip count of side: the rolls it
+// needs to bear off on average, in pips of an average roll.
+func effectivePips(b Board, side int) float64 {
+	return float64(KeithCount(b, side) + keithWastage)
+}
+
+// rollsVariance is the variance of the number of rolls that moves epc pips.
+// The rolls are a renewal process: each moves diceRollMean pips on average,
+// so the count needed for epc pips has variance epc·σ²/μ³, for roll mean μ
+// and variance σ². It does not count the chance of wasting more or fewer
+// pips than the average.
+func rollsVariance(epc float64) float64 {
+	return epc * diceRollVariance / (diceRollMean * diceRollMean * diceRollMean)
+}
+
+// estimatedRolls returns the distribution of the rolls side needs to bear
+// off, by index as the bearoff databases give it: a normal distribution of
+// mean effectivePips/diceRollMean and variance rollsVariance, rounded to
+// whole rolls. A side outside the databases needs at least one roll, so the
+// chance of none is cut off and the rest scaled up to make up for it.
+func estimatedRolls(b Board, side int) []float64 {
+	epc := effectivePips(b, side)
+	mean := epc / diceRollMean
+	sd := math.Sqrt(rollsVariance(epc))
+	most := max(1, int(math.Ceil(mean+8*sd)))
+
+	rolls := make([]float64, most+1)
+	below := normalCDF((0.5 - mean) / sd)
+	total := 1 - below
+	for n := 1; n <= most; n++ {
+		above := normalCDF((float64(n) + 0.5 - mean) / sd)
+		rolls[n] = (above - below) / total
+		below = above
+	}
+	return rolls
+}
+
+// rollsWinProb returns the chance that a side needing onRoll rolls, rolling
+// first, finishes no later than a side needing other rolls: the sum over
+// each count i of its chance times the chance the other side needs at
+// least i. The distributions are by number of rolls, as from
+// bearoff.Database.Distribution.
+func rollsWinProb(onRoll, other []float64) float64 {
+	win, atLeast := 0.0, 0.0
+	for i := max(len(onRoll), len(other)) - 1; i >= 0; i-- {
+		if i < len(other) {
+			atLeast += other[i]
+		}
+		if i < len(onRoll) {
+			win += onRoll[i] * atLeast
+		}
+	}
+	return win
+}
+
+// oneSidedRolls returns the rolls side needs to bear off from db, and false
+// if db does not cover side.
+func oneSidedRolls(db *bearoff.Database, side [25]uint8) ([]float64, bool) {
+	var points [bearoff.MaxPoints]uint8
+	for i, c := range side {
+		if c == 0 {
+			continue
+		}
+		if i >= db.NPoints {
+			return nil, false
+		}
+		points[i] = c
+	}
+	prob, err := db.Distribution(points)
+	if err != nil {
+		return nil, false
+	}
+	rolls := make([]float64, len(prob))
+	for i, p := range prob {
+		rolls[i] = float64(p)
+	}
+	return rolls, true
+}
+
+// evaluateRaceHybrid evaluates a race with evaluateRace, then replaces the
+// winning chance with the one worked out from the rolls both sides need if
+// exactly one of them is within the larger one-sided bearoff database. The
+// gammon chances stay the race evaluator's.
+func (e *Engine) evaluateRaceHybrid(board neuralnet.Board, t *Timing) ([5]float32, error) {
+	output, err := e.evaluateRace(board, t)
+	if err != nil {
+		return output, err
+	}
+	if win, ok := e.hybridRaceWinProb(board, t); ok {
+		output[0] = float32(win)
+	}
+	return output, nil
+}
+
+// hybridRaceWinProb returns the winning chance of the player on roll from
+// the rolls both sides need, and false unless exactly one side is within
+// the larger one-sided bearoff database loaded.
+func (e *Engine) hybridRaceWinProb(board neuralnet.Board, t *Timing) (float64, bool) {
+	db := e.bearoffOS
+	if db == nil {
+		db = e.bearoff
+	}
+	if db == nil || db.Type != bearoff.BearoffOneSided {
+		return 0, false
+	}
+
+	var rolls [2][]float64
+	exact := 0
+	start := t.start()
+	for side := range board {
+		if r, ok := oneSidedRolls(db, board[side]); ok {
+			rolls[side] = r
+			exact++
+		}
+	}
+	if t != nil {
+		t.Bearoff += time.Since(start)
+	}
+	if exact != 1 {
+		return 0, false
+	}
+
+	for side := range rolls {
+		if rolls[side] == nil {
+			rolls[side] = estimatedRolls(Board(board), side)
+		}
+	}
+	return rollsWinProb(rolls[1], rolls[0]), true
+}
@@ -0,0 +1,254 @@
+package engine
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/rand"
+	"path/filepath"
+	"testing"
+
+	"github.com/yourusername/bgengine/internal/bearoff"
+	"github.com/yourusername/bgengine/internal/neuralnet"
+)
+
+func TestDiceRollMoments(t *testing.T) {
+	// 294 pips over the 36 rolls, and 3066 squared
+	mean := 294.0 / 36
+	if math.Abs(diceRollMean-mean) > 1e-12 || math.Abs(diceRollVariance-(3066.0/36-mean*mean)) > 1e-12 {
+		t.Errorf("dice roll mean %v, variance %v; want %v, %v", diceRollMean, diceRollVariance, mean, 3066.0/36-mean*mean)
+	}
+}
+
+// rollsToCover rolls the dice until they have moved pips pips and returns
+// how many rolls that took.
+func rollsToCover(rng *rand.Rand, pips int) int {
+	n := 0
+	for moved := 0; moved < pips; n++ {
+		d1, d2 := rng.Intn(6)+1, rng.Intn(6)+1
+		if d1 == d2 {
+			moved += 4 * d1
+		} else {
+			moved += d1 + d2
+		}
+	}
+	return n
+}
+
+func TestRollsVariance(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, pips := range []int{60, 100, 150} {
+		const trials = 40000
+		var sum, sumSq float64
+		for i := 0; i < trials; i++ {
+			n := float64(rollsToCover(rng, pips))
+			sum += n
+			sumSq += n * n
+		}
+		mean := sum / trials
+		variance := sumSq/trials - mean*mean
+		if got := rollsVariance(float64(pips)); math.Abs(got-variance) > 0.1*variance {
+			t.Errorf("rollsVariance(%d) = %.3f, dice give %.3f", pips, got, variance)
+		}
+	}
+}
+
+func TestEstimatedRolls(t *testing.T) {
+	for _, side := range [][25]uint8{
+		{0: 2, 5: 5, 9: 4, 14: 4},
+		{6: 1}, // Short enough for the normal estimate to reach zero rolls
+	} {
+		b := Board{side, {1}}
+		rolls := estimatedRolls(b, 0)
+		total, mean := 0.0, 0.0
+		for n, p := range rolls {
+			if p < 0 || (p > 0 && n == 0) {
+				t.Errorf("%v: chance %v of %d rolls", side, p, n)
+			}
+			total += p
+			mean += float64(n) * p
+		}
+		if math.Abs(total-1) > 1e-9 {
+			t.Errorf("%v: chances add up to %v", side, total)
+		}
+		if want := effectivePips(b, 0) / diceRollMean; math.Abs(mean-want) > 0.05 && side[6] == 0 {
+			t.Errorf("%v: mean %v rolls, want %v", side, mean, want)
+		}
+	}
+}
+
+func TestRollsWinProb(t *testing.T) {
+	exactly := func(n int) []float64 {
+		r := make([]float64, n+1)
+		r[n] = 1
+		return r
+	}
+	tests := []struct {
+		onRoll, other []float64
+		want          float64
+	}{
+		{exactly(3), exactly(3), 1}, // Ties go to the side rolling first
+		{exactly(4), exactly(3), 0},
+		{exactly(2), exactly(5), 1},
+		{[]float64{0, 0.5, 0.5}, exactly(1), 0.5},
+		{exactly(2), []float64{0, 0.25, 0.25, 0.5}, 0.75},
+	}
+	for _, tt := range tests {
+		if got := rollsWinProb(tt.onRoll, tt.other); math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("rollsWinProb(%v, %v) = %v, want %v", tt.onRoll, tt.other, got, tt.want)
+		}
+	}
+
+	// The same sum the one-sided database evaluates bearoffs with
+	rng := rand.New(rand.NewSource(2))
+	for i := 0; i < 100; i++ {
+		var a, b [32]float64
+		for _, d := range []*[32]float64{&a, &b} {
+			total := 0.0
+			for n := 1; n < 20; n++ {
+				d[n] = rng.Float64()
+				total += d[n]
+			}
+			for n := range d {
+				d[n] /= total
+			}
+		}
+		want := 0.0
+		for i := 0; i < 32; i++ {
+			for j := i; j < 32; j++ {
+				want += a[i] * b[j]
+			}
+		}
+		if got := rollsWinProb(a[:], b[:20]); math.Abs(got-want) > 1e-12 {
+			t.Fatalf("rollsWinProb = %v, sum over pairs %v", got, want)
+		}
+	}
+}
+
+// fixedRollsOneSided returns a one-sided database of 6 points and nChequers
+// checkers, in gnubg's uncompressed layout, in which every position bears
+// off in exactly rolls rolls.
+func fixedRollsOneSided(nChequers, rolls int) []byte {
+	data := []byte(fmt.Sprintf("%-40s", fmt.Sprintf("gnubg-OS-06-%02d-0-0-0", nChequers)))
+	for pos := bearoff.Combination(6+nChequers, 6); pos > 0; pos-- {
+		var record [64]byte
+		binary.LittleEndian.PutUint16(record[2*rolls:], 65535)
+		data = append(data, record[:]...)
+	}
+	return data
+}
+
+func TestHybridRace(t *testing.T) {
+	// Four checkers at home against four on the 12 point: no contact, but
+	// not a bearoff either
+	var home, out [25]uint8
+	home[0], home[4] = 2, 2
+	out[11] = 4
+
+	for _, tt := range []struct {
+		name  string
+		board Board
+		rolls int
+		want  float64
+	}{
+		{"home side on roll, one roll", Board{out, home}, 1, 1},
+		{"home side waiting, one roll", Board{home, out}, 1, 0},
+		{"home side on roll, 20 rolls", Board{out, home}, 20, 0},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			db := fixedRollsOneSided(4, tt.rolls)
+			plain, err := NewEngineFromBytes(nil, db, nil, nil, EngineOptions{SkipWarmup: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			hybrid, err := NewEngineFromBytes(nil, db, nil, nil, EngineOptions{SkipWarmup: true, HybridRace: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			state := &GameState{Board: tt.board, CubeValue: 1, CubeOwner: -1}
+
+			want, err := plain.Evaluate(state)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := hybrid.Evaluate(state)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if math.Abs(got.WinProb-tt.want) > 1e-3 {
+				t.Errorf("hybrid WinProb = %v, want %v", got.WinProb, tt.want)
+			}
+			if want.WinProb == got.WinProb {
+				t.Errorf("race evaluator WinProb = %v, the same as the hybrid's", want.WinProb)
+			}
+
+			// Switching the bearoff databases off switches it off too
+			hybrid.SetRouting(Routing{DisableBearoffDB: true})
+			if off, err := hybrid.Evaluate(state); err != nil || off.WinProb != want.WinProb {
+				t.Errorf("with the bearoff databases off WinProb = %v, %v; want %v", off.WinProb, err, want.WinProb)
+			}
+		})
+	}
+}
+
+// The ladder of races with one side at home, rolled out to the end with the
+// nets, on which the hybrid evaluator must come nearer the rollouts than the
+// race net. The side at home is on roll in the first half and waiting in the
+// second.
+var hybridRaceLadder = [][2][25]uint8{
+	{{2: 2, 4: 2, 5: 3, 7: 3, 8: 2, 10: 3}, {3, 3, 3, 2, 2, 2}},
+	{{1: 2, 3: 3, 5: 3, 6: 3, 8: 2, 9: 2}, {2, 2, 3, 3, 3, 2}},
+	{{0: 1, 2: 2, 4: 3, 5: 3, 6: 4, 7: 2}, {1, 2, 2, 3, 3, 4}},
+	{{3: 3, 4: 3, 5: 4, 6: 3, 11: 2}, {0, 2, 2, 3, 4, 4}},
+	{{3, 3, 3, 2, 2, 2}, {2: 2, 4: 2, 5: 3, 7: 3, 8: 2, 10: 3}},
+	{{2, 2, 3, 3, 3, 2}, {1: 3, 3: 3, 5: 3, 6: 2, 7: 2, 9: 2}},
+	{{1, 2, 2, 3, 3, 4}, {2: 3, 4: 3, 5: 4, 6: 3, 8: 2}},
+	{{0, 2, 3, 3, 3, 4}, {1: 2, 3: 3, 4: 3, 5: 3, 7: 2, 12: 2}},
+}
+
+func TestHybridRaceLadder(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping rollout ladder in short mode")
+	}
+	opts := EngineOptions{
+		WeightsFileText: filepath.Join("..", "..", "data", "gnubg.weights"),
+		BearoffFile:     filepath.Join("..", "..", "data", "gnubg_os0.bd"),
+	}
+	net, err := NewEngine(opts)
+	if err != nil {
+		t.Skipf("Skipping test - could not load data files: %v", err)
+	}
+	opts.HybridRace = true
+	hybrid, err := NewEngine(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var netError, hybridError float64
+	for i, board := range hybridRaceLadder {
+		state := &GameState{Board: board, CubeValue: 1, CubeOwner: -1}
+		if class := net.classify(neuralnet.Board(board)); class != neuralnet.ClassRace {
+			t.Fatalf("ladder position %d is %v, not a race", i, class)
+		}
+		rollout, err := net.Rollout(state, RolloutOptions{Trials: 5184, Seed: int64(i + 1)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		netEval, err := net.Evaluate(state)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hybridEval, err := hybrid.Evaluate(state)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Logf("position %d: rollout %.4f, race net %.4f, hybrid %.4f", i, rollout.WinProb, netEval.WinProb, hybridEval.WinProb)
+		netError += math.Abs(netEval.WinProb - rollout.WinProb)
+		hybridError += math.Abs(hybridEval.WinProb - rollout.WinProb)
+	}
+	n := float64(len(hybridRaceLadder))
+	t.Logf("mean absolute error: race net %.4f, hybrid %.4f", netError/n, hybridError/n)
+	if hybridError >= netError {
+		t.Errorf("hybrid mean absolute error %.4f, race net %.4f", hybridError/n, netError/n)
+	}
+}
@@ -0,0 +1,134 @@
+package engine
+
+import (
+	"github.com/yourusername/bgengine/internal/neuralnet"
+)
+
+// Inspection shows how the engine evaluates a position: which evaluator
+// handles it, its raw output and, for the neural nets, the inputs it is given.
+// It is meant for tracking down disagreements with other engines.
+type Inspection struct {
+	Class string // Position class ("contact", "crashed", "race", "bearoff1", ...)
+
+	// Evaluator that produced Output: "game_over", "exact",
+	// "bearoff_two_sided", "bearoff_one_sided", "contact_net", "crashed_net",
+	// "race_net", or "heuristic" when the net for the position is not loaded
+	// and Output is a pip count estimate.
+	Evaluator string
+
+	// Raw output: win, win gammon, win backgammon, lose gammon, lose backgammon
+	Output [5]float32
+
+	// Net the position is given to ("contact", "crashed" or "race"), with its
+	// inputs and their names. Empty for positions that never reach a net.
+	Network    string
+	Inputs     []float32
+	InputNames []string
+
+	// Evaluators the engine was set to leave out (see Routing.Disabled)
+	Disabled []string
+}
+
+// Inspect evaluates state the way Evaluate does, recording which evaluator
+// was used and what it was given.
+func (e *Engine) Inspect(state *GameState) (*Inspection, error) {
+	board := neuralnet.Board(state.Board)
+	class := e.classify(board)
+	routing := e.Routing()
+	ins := &Inspection{Class: class.String(), Disabled: routing.Disabled()}
+
+	if class == neuralnet.ClassOver {
+		eval, err := e.evaluateGameOver(board, state.checkerTotals())
+		if err != nil {
+			return nil, err
+		}
+		ins.Evaluator = "game_over"
+		ins.Output = [5]float32{
+			float32(eval.WinProb), float32(eval.WinG), float32(eval.WinBG),
+			float32(eval.LoseG), float32(eval.LoseBG),
+		}
+		return ins, nil
+	}
+
+	if (class == neuralnet.ClassBearoffTS || class == neuralnet.ClassBearoff1) &&
+		!routing.DisableBearoffDB && preferExact(Board(board)) {
+		probs, _ := e.solveExact(Board(board), state.checkerTotals(), DefaultExactDepth)
+		ins.Evaluator = "exact"
+		ins.Output = probsToOutput(probs)
+		return ins, nil
+	}
+
+	// The bearoff databases, with the same fallbacks as evaluateStatic
+	switch {
+	case routing.DisableBearoffDB:
+		// Straight to the race net
+	case class == neuralnet.ClassBearoffTS:
+		boBoard := neuralnet.GetBearoffBoard(board)
+		if e.bearoffTS != nil {
+			if output, err := e.bearoffTS.Evaluate(boBoard); err == nil {
+				ins.Evaluator = "bearoff_two_sided"
+				ins.Output = output
+				return ins, nil
+			}
+		}
+		if e.bearoff != nil {
+			if output, err := e.bearoff.Evaluate(boBoard); err == nil {
+				ins.Evaluator = "bearoff_one_sided"
+				ins.Output = output
+				return ins, nil
+			}
+		}
+	case class == neuralnet.ClassBearoffOS:
+		if output, err := e.bearoffOS.Evaluate(neuralnet.GetBearoffBoard(board)); err == nil {
+			ins.Evaluator = "bearoff_one_sided"
+			ins.Output = output
+			return ins, nil
+		}
+	case isBearoffClass(class):
+		if e.bearoff != nil {
+			if output, err := e.bearoff.Evaluate(neuralnet.GetBearoffBoard(board)); err == nil {
+				ins.Evaluator = "bearoff_one_sided"
+				ins.Output = output
+				return ins, nil
+			}
+		}
+	}
+
+	var (
+		output [5]float32
+		err    error
+		loaded bool
+	)
+	switch {
+	case class == neuralnet.ClassContact ||
+		(class == neuralnet.ClassCrashed && (e.crashed == nil || routing.DisableCrashedNet)):
+		ins.Network = "contact"
+		ins.Inputs = neuralnet.ContactInputs(board)
+		ins.InputNames = neuralnet.InputNames(neuralnet.ClassContact)
+		output, err = e.evaluateContact(board, nil)
+		loaded = e.contact != nil
+	case class == neuralnet.ClassCrashed:
+		ins.Network = "crashed"
+		ins.Inputs = neuralnet.CrashedInputs(board)
+		ins.InputNames = neuralnet.InputNames(neuralnet.ClassCrashed)
+		output, err = e.evaluateCrashed(board, nil)
+		loaded = true
+	default:
+		// Races, and bearoffs without a database or with the databases disabled
+		ins.Network = "race"
+		ins.Inputs = neuralnet.RaceInputs(board)
+		ins.InputNames = neuralnet.InputNames(neuralnet.ClassRace)
+		output, err = e.evaluateRace(board, nil)
+		loaded = e.race != nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ins.Output = output
+	ins.Evaluator = ins.Network + "_net"
+	if !loaded {
+		ins.Evaluator = "heuristic"
+	}
+	return ins, nil
+}
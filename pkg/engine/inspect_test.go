@@ -0,0 +1,128 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/yourusername/bgengine/internal/neuralnet"
+)
+
+func TestInspect(t *testing.T) {
+	e, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	var race, bearoff, over Board
+	race[1][7], race[1][2] = 8, 7
+	race[0][10], race[0][3] = 8, 7
+	bearoff[1][0], bearoff[1][1] = 2, 1
+	bearoff[0][0], bearoff[0][2] = 1, 1
+	over[0][5] = 3
+
+	for _, c := range []struct {
+		name      string
+		board     Board
+		class     string
+		evaluator string
+		network   string
+		inputs    int
+	}{
+		{"contact", StartingPosition().Board, "contact", "heuristic", "contact", 250},
+		{"race", race, "race", "heuristic", "race", 214},
+		{"short bearoff", bearoff, "bearoff_ts", "exact", "", 0},
+		{"game over", over, "over", "game_over", "", 0},
+	} {
+		ins, err := e.Inspect(&GameState{Board: c.board, CubeValue: 1, CubeOwner: -1})
+		if err != nil {
+			t.Fatalf("%s: Inspect failed: %v", c.name, err)
+		}
+		if ins.Class != c.class || ins.Evaluator != c.evaluator || ins.Network != c.network {
+			t.Errorf("%s: class %q evaluator %q network %q, want %q %q %q",
+				c.name, ins.Class, ins.Evaluator, ins.Network, c.class, c.evaluator, c.network)
+		}
+		if len(ins.Inputs) != c.inputs || len(ins.InputNames) != c.inputs {
+			t.Errorf("%s: %d inputs and %d names, want %d", c.name, len(ins.Inputs), len(ins.InputNames), c.inputs)
+		}
+
+		// The output is what the evaluation uses
+		want, err := e.evaluateOutput(neuralnet.Board(c.board), fullTotals, nil)
+		if err != nil {
+			t.Fatalf("%s: evaluateOutput failed: %v", c.name, err)
+		}
+		if ins.Output != want {
+			t.Errorf("%s: output %v, want %v", c.name, ins.Output, want)
+		}
+	}
+}
+
+func TestRouting(t *testing.T) {
+	e, err := NewEngine(EngineOptions{SkipWarmup: true})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	// Nets telling apart which one evaluated a position
+	e.contact = constantNet(neuralnet.NumContactInputs, 1, -2, -4, -2, -4)
+	e.crashed = constantNet(neuralnet.NumContactInputs, -1, -2, -4, -2, -4)
+	e.race = constantNet(neuralnet.NumRaceInputs, 0, -9, -9, -9, -9)
+	e.initBufferPools()
+
+	var crashed Board
+	for _, pos := range warmupPositions() {
+		if pos.class == neuralnet.ClassCrashed {
+			crashed = pos.board
+		}
+	}
+	var bearoff Board
+	bearoff[1][0], bearoff[1][1] = 2, 1
+	bearoff[0][0], bearoff[0][2] = 1, 1
+
+	inspect := func(b Board) *Inspection {
+		t.Helper()
+		ins, err := e.Inspect(&GameState{Board: b, CubeValue: 1, CubeOwner: -1})
+		if err != nil {
+			t.Fatalf("Inspect failed: %v", err)
+		}
+		return ins
+	}
+	winProb := func() float64 {
+		t.Helper()
+		eval, err := e.EvaluateCached(&GameState{Board: crashed, CubeValue: 1, CubeOwner: -1}, 0)
+		if err != nil {
+			t.Fatalf("EvaluateCached failed: %v", err)
+		}
+		return eval.WinProb
+	}
+
+	if ins := inspect(crashed); ins.Evaluator != "crashed_net" || ins.Disabled != nil {
+		t.Errorf("crashed position: evaluator %q, disabled %v; want crashed_net, none", ins.Evaluator, ins.Disabled)
+	}
+	if ins := inspect(bearoff); ins.Evaluator != "exact" {
+		t.Errorf("short bearoff: evaluator %q, want exact", ins.Evaluator)
+	}
+	before := winProb()
+
+	e.SetRouting(Routing{DisableCrashedNet: true, DisableBearoffDB: true})
+	if ins := inspect(crashed); ins.Network != "contact" || ins.Evaluator != "contact_net" ||
+		len(ins.Disabled) != 2 || ins.Disabled[0] != "crashed_net" || ins.Disabled[1] != "bearoff_db" {
+		t.Errorf("crashed position with the crashed net off: network %q, evaluator %q, disabled %v",
+			ins.Network, ins.Evaluator, ins.Disabled)
+	}
+	if ins := inspect(bearoff); ins.Evaluator != "race_net" {
+		t.Errorf("short bearoff with the databases off: evaluator %q, want race_net", ins.Evaluator)
+	}
+	out, _ := e.evaluateOutput(neuralnet.Board(bearoff), fullTotals, nil)
+	if race, _ := e.evaluateRace(neuralnet.Board(bearoff), nil); out[0] != race[0] {
+		t.Errorf("short bearoff evaluated at %v, want the race net's %v", out[0], race[0])
+	}
+
+	// The cached crashed net evaluation was flushed
+	after := winProb()
+	if after == before || after != float64(inspect(crashed).Output[0]) {
+		t.Errorf("cached win probability %v after switching nets (was %v), want the contact net's", after, before)
+	}
+
+	e.SetRouting(Routing{})
+	if got := winProb(); got != before || e.Routing() != (Routing{}) {
+		t.Errorf("win probability %v after switching back, want %v", got, before)
+	}
+}
@@ -0,0 +1,106 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// DefaultJokers is the number of rolls listed as a game's luckiest and
+// unluckiest.
+const DefaultJokers = 3
+
+// LuckDetail is the luck of one roll: how much the dice gained the player who
+// rolled them over the average roll. Jokers are the rolls that swing a game
+// most.
+type LuckDetail struct {
+	GameNumber int     `json:"game_number"`
+	MoveNumber int     `json:"move_number"`
+	Player     int     `json:"player"`   // Player who rolled
+	Position   string  `json:"position"` // Position ID before the roll, seen by the player
+	Dice       [2]int  `json:"dice"`
+	Luck       float64 `json:"luck"`   // Cubeless equity swing for the player
+	Points     float64 `json:"points"` // The swing in points at the cube value of the game
+}
+
+// Jokers returns the k rolls with the biggest swing in points for the players
+// who rolled them, luckiest first, and the k with the biggest swing against
+// them, unluckiest first. Ties keep the order of rolls.
+func Jokers(rolls []LuckDetail, k int) (lucky, unlucky []LuckDetail) {
+	sorted := append([]LuckDetail(nil), rolls...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Points > sorted[j].Points })
+	for _, r := range sorted {
+		if len(lucky) == k || r.Points <= 0 {
+			break
+		}
+		lucky = append(lucky, r)
+	}
+	for i := len(sorted) - 1; i >= 0; i-- {
+		r := sorted[i]
+		if len(unlucky) == k || r.Points >= 0 {
+			break
+		}
+		unlucky = append(unlucky, r)
+	}
+	return lucky, unlucky
+}
+
+// LuckAdjustedResult is the result of a match with the luck of the dice
+// taken out, in points: what the players' play alone would have won them.
+type LuckAdjustedResult struct {
+	Points   [2]float64 `json:"points"`   // Net points won by each player
+	Luck     [2]float64 `json:"luck"`     // Net luck of each player: their rolls' swing less their opponent's
+	Adjusted [2]float64 `json:"adjusted"` // Points less luck
+	Summary  string     `json:"summary"`  // e.g. "Bob won but was outplayed; luck accounted for +0.8 points"
+}
+
+// AdjustForLuck works out the luck-adjusted result from the game results in
+// GameStats and the luck in RollLuck. It returns nil if the luck was not
+// measured.
+func (a *MatchAnalysis) AdjustForLuck() *LuckAdjustedResult {
+	if a.RollLuck == nil {
+		return nil
+	}
+
+	r := &LuckAdjustedResult{}
+	for _, g := range a.GameStats {
+		if g.Winner == 0 || g.Winner == 1 {
+			r.Points[g.Winner] += float64(g.Points)
+			r.Points[1-g.Winner] -= float64(g.Points)
+		}
+	}
+	for _, roll := range a.RollLuck {
+		r.Luck[roll.Player] += roll.Points
+		r.Luck[1-roll.Player] -= roll.Points
+	}
+	for p := 0; p < 2; p++ {
+		r.Adjusted[p] = r.Points[p] - r.Luck[p]
+	}
+
+	name := func(p int) string {
+		if a.PlayerStats[p].Name != "" {
+			return a.PlayerStats[p].Name
+		}
+		return fmt.Sprintf("Player %d", p+1)
+	}
+	winner := 0
+	if r.Points[1] > r.Points[0] {
+		winner = 1
+	}
+	luck := fmt.Sprintf("luck accounted for %+.1f points", r.Luck[winner])
+	switch {
+	case r.Points[winner] == 0:
+		lucky := 0
+		if r.Luck[1] > r.Luck[0] {
+			lucky = 1
+		}
+		r.Summary = fmt.Sprintf("Neither player is ahead; luck favored %s by %.1f points", name(lucky), math.Abs(r.Luck[lucky]))
+	case r.Adjusted[winner] < 0:
+		r.Summary = fmt.Sprintf("%s won but was outplayed; %s", name(winner), luck)
+	case r.Luck[winner] < 0:
+		r.Summary = fmt.Sprintf("%s won despite the dice; %s", name(winner), luck)
+	default:
+		r.Summary = fmt.Sprintf("%s won; %s", name(winner), luck)
+	}
+	return r
+}
@@ -0,0 +1,102 @@
+package engine
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestJokers(t *testing.T) {
+	rolls := []LuckDetail{
+		{MoveNumber: 1, Points: 0.2},
+		{MoveNumber: 2, Points: -0.4},
+		{MoveNumber: 3, Points: 0.6},
+		{MoveNumber: 4, Points: 0.2},
+		{MoveNumber: 5, Points: -0.1},
+		{MoveNumber: 6, Points: 0},
+	}
+	moves := func(rolls []LuckDetail) []int {
+		var n []int
+		for _, r := range rolls {
+			n = append(n, r.MoveNumber)
+		}
+		return n
+	}
+
+	lucky, unlucky := Jokers(rolls, 2)
+	if got := moves(lucky); !reflect.DeepEqual(got, []int{3, 1}) {
+		t.Errorf("luckiest = moves %v, want [3 1]", got)
+	}
+	if got := moves(unlucky); !reflect.DeepEqual(got, []int{2, 5}) {
+		t.Errorf("unluckiest = moves %v, want [2 5]", got)
+	}
+
+	// Rolls that swing nothing are neither
+	lucky, unlucky = Jokers(rolls, 10)
+	if len(lucky) != 3 || len(unlucky) != 2 {
+		t.Errorf("with k = 10: %d lucky and %d unlucky rolls, want 3 and 2", len(lucky), len(unlucky))
+	}
+}
+
+func TestMatchJokers(t *testing.T) {
+	e, err := NewEngine(EngineOptions{SkipWarmup: true})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	// A bear-off that player 0 saves with a late 6-6. Player 1, with
+	// checkers on the 3 and ace points, rolls the only number that does not
+	// bear both off; player 0, with four checkers on the 6 point, then needs
+	// and rolls 6-6.
+	var first, last Board
+	first[1][2], first[1][0] = 1, 1
+	first[0][5] = 4
+	last[1][5] = 4
+	last[0][0] = 1
+	positions := []AnalyzedPosition{
+		{Board: first, Turn: 1, Dice: [2]int{2, 1}, CubeValue: 1, CubeOwner: -1, GameNumber: 1, MoveNumber: 29, Player: 1,
+			Move: &Move{From: [4]int8{2, 0, -1, -1}, To: [4]int8{0, -1, -1, -1}}},
+		{Board: last, Turn: 0, Dice: [2]int{6, 6}, CubeValue: 1, CubeOwner: -1, GameNumber: 1, MoveNumber: 30, Player: 0,
+			Move: &Move{From: [4]int8{5, 5, 5, 5}, To: [4]int8{-1, -1, -1, -1}}},
+	}
+
+	opts := DefaultMatchAnalysisOptions()
+	opts.IncludeLuck = true
+	opts.Player1Name, opts.Player2Name = "Alice", "Bob"
+	a, err := e.AnalyzePositionList(positions, opts)
+	if err != nil {
+		t.Fatalf("AnalyzePositionList failed: %v", err)
+	}
+
+	if len(a.RollLuck) != 2 || a.RollLuck[0].MoveNumber != 29 || a.RollLuck[1].MoveNumber != 30 {
+		t.Fatalf("roll luck = %+v, want moves 29 and 30", a.RollLuck)
+	}
+	game := a.GameStats[0]
+	if len(game.Luckiest) != 1 || game.Luckiest[0].Dice != [2]int{6, 6} || game.Luckiest[0].Player != 0 {
+		t.Fatalf("luckiest rolls = %+v, want player 0's 6-6", game.Luckiest)
+	}
+	if joker := game.Luckiest[0]; joker.Luck < 1 || joker.Points != joker.Luck || joker.Position != EncodePositionID(last) {
+		t.Errorf("6-6 = %+v, want a swing of more than a point from %s", joker, EncodePositionID(last))
+	}
+	if len(game.Unluckiest) != 1 || game.Unluckiest[0].Dice != [2]int{2, 1} || game.Unluckiest[0].Player != 1 {
+		t.Errorf("unluckiest rolls = %+v, want player 1's 2-1", game.Unluckiest)
+	}
+
+	if a.LuckAdjusted != nil {
+		t.Errorf("luck-adjusted result %+v before the game results are known", a.LuckAdjusted)
+	}
+	a.GameStats[0].Winner, a.GameStats[0].Points = 0, 1
+	r := a.AdjustForLuck()
+	if r.Points != [2]float64{1, -1} || r.Luck[0] <= 1 || r.Luck[0] != -r.Luck[1] || r.Adjusted[0] != r.Points[0]-r.Luck[0] {
+		t.Errorf("luck-adjusted result = %+v", r)
+	}
+	if !strings.HasPrefix(r.Summary, "Alice won but was outplayed; luck accounted for +") {
+		t.Errorf("summary = %q", r.Summary)
+	}
+
+	// Without luck there is nothing to adjust
+	opts.IncludeLuck = false
+	if a, _ = e.AnalyzePositionList(positions, opts); a.RollLuck != nil || a.AdjustForLuck() != nil || a.GameStats[0].Luckiest != nil {
+		t.Errorf("analysis without luck has luck: %+v", a)
+	}
+}
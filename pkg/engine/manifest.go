@@ -0,0 +1,155 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+)
+
+// DiceRNG names the way rollout dice are drawn, recorded in rollout
+// manifests: each trial rolls with its own math/rand source, seeded from the
+// rollout seed and the trial number by a splitmix64 step (see trialSeed).
+// It changes whenever the same seed would roll different dice.
+const DiceRNG = "math/rand-splitmix64-per-trial"
+
+// DataFile identifies a data file the engine loaded (see DataStatus).
+type DataFile struct {
+	Kind   string `json:"kind"`           // "weights", "bearoff", "bearoff-ts", "bearoff-os" or "met"
+	Path   string `json:"path,omitempty"` // Empty for data given by NewEngineFromBytes
+	Size   int64  `json:"size"`           // Size in bytes
+	SHA256 string `json:"sha256"`         // SHA-256 checksum, in hex
+}
+
+// DataStatus returns the data files the engine was created from, with their
+// checksums, in the order CheckData checks them. The checksums are computed
+// once, when the engine is created, which reads each file through even if
+// it is then read on demand (EngineOptions.BearoffLazy).
+func (e *Engine) DataStatus() []DataFile {
+	return append([]DataFile(nil), e.data...)
+}
+
+// dataFiles checksums the data files opts names.
+func dataFiles(opts EngineOptions) ([]DataFile, error) {
+	var files []DataFile
+	add := func(kind, path string) error {
+		if path == "" {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		h := sha256.New()
+		size, err := io.Copy(h, f)
+		if err != nil {
+			return fmt.Errorf("failed to checksum %s: %w", path, err)
+		}
+		files = append(files, DataFile{Kind: kind, Path: path, Size: size, SHA256: hex.EncodeToString(h.Sum(nil))})
+		return nil
+	}
+
+	for _, f := range []struct{ kind, path string }{
+		{"weights", opts.WeightsFile},
+		{"weights", opts.WeightsFileText},
+		{"bearoff", opts.BearoffFile},
+		{"bearoff-ts", opts.BearoffTSFile},
+		{"bearoff-os", opts.BearoffOSFile},
+		{"met", opts.METFile},
+	} {
+		if err := add(f.kind, f.path); err != nil {
+			return nil, err
+		}
+	}
+	for _, path := range opts.METFiles {
+		if err := add("met", path); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// dataBytes returns the DataFile of data, or nil if it is empty.
+func dataBytes(kind string, data []byte) []DataFile {
+	if len(data) == 0 {
+		return nil
+	}
+	sum := sha256.Sum256(data)
+	return []DataFile{{Kind: kind, Size: int64(len(data)), SHA256: hex.EncodeToString(sum[:])}}
+}
+
+// RolloutManifest records everything needed to play a rollout's trials
+// again: the engine and the data it evaluated with, the position, and the
+// settings the trials were played with, with the seed drawn for a rollout
+// asked for with seed 0. Rolling out the position with the same settings
+// and Seed on an engine with the same manifest data gives the same result.
+type RolloutManifest struct {
+	BuildInfo             // Engine build and data files
+	MET          string   `json:"met,omitempty"`            // Match equity table, in match play
+	Disabled     []string `json:"disabled,omitempty"`       // Evaluators switched off (see Routing)
+	EvenFallback bool     `json:"even_fallback,omitempty"`  // EngineOptions.EvenFallback
+	CoreNetsOnly bool     `json:"core_nets_only,omitempty"` // EngineOptions.CoreNetsOnly
+	HybridRace   bool     `json:"hybrid_race,omitempty"`    // EngineOptions.HybridRace
+
+	Position string `json:"position"` // Position ID
+	Cube     string `json:"cube"`     // Side on roll, cube and match state, as in RolloutKey
+
+	Seed        int64  `json:"seed"`   // Seed the trials were played with, never 0
+	Trials      int    `json:"trials"` // Trials played, from trial 0
+	Truncate    int    `json:"truncate"`
+	LeafPly     int    `json:"leaf_ply"`
+	LeafCubeful bool   `json:"leaf_cubeful"`
+	FirstRoll   string `json:"first_roll"`          // "none", "standard" or "already_rolled"
+	Dice        []int  `json:"dice,omitempty"`      // Dice of an already rolled first roll
+	DiceMode    string `json:"dice_mode,omitempty"` // "random" or "exhaustive-2roll"; absent in manifests of random rollouts made before it was recorded
+	DiceRNG     string `json:"dice_rng"`            // DiceRNG
+}
+
+// String returns the name of the rule as a RolloutManifest records it.
+func (r FirstRollRule) String() string {
+	switch r {
+	case FirstRollAuto:
+		return "auto"
+	case FirstRollNone:
+		return "none"
+	case FirstRollStandard:
+		return "standard"
+	case FirstRollAlreadyRolled:
+		return "already_rolled"
+	}
+	return fmt.Sprintf("FirstRollRule(%d)", int(r))
+}
+
+// rolloutManifest returns the manifest of result, a rollout of state played
+// with opts, whose defaults are filled in.
+func (e *Engine) rolloutManifest(state *GameState, opts RolloutOptions, result *RolloutResult) *RolloutManifest {
+	key := NewRolloutKey(state, opts)
+	m := &RolloutManifest{
+		BuildInfo:    *e.BuildInfo(),
+		Disabled:     e.Routing().Disabled(),
+		EvenFallback: e.evenFallback,
+		CoreNetsOnly: e.coreNetsOnly,
+		HybridRace:   e.hybridRace,
+		Position:     key.Position,
+		Cube:         key.Cube,
+		Seed:         opts.Seed,
+		Trials:       result.TrialsCompleted,
+		Truncate:     opts.Truncate,
+		LeafPly:      opts.LeafPly,
+		LeafCubeful:  opts.LeafCubeful,
+		FirstRoll:    opts.FirstRoll.String(),
+		DiceMode:     string(opts.DiceMode),
+		DiceRNG:      DiceRNG,
+	}
+	if opts.FirstRoll == FirstRollAlreadyRolled {
+		m.Dice = []int{state.Dice[0], state.Dice[1]}
+	}
+	if state.MatchLength > 0 {
+		if t, err := e.metTable(state.MET); err == nil && t != nil {
+			m.MET = t.Name
+		}
+	}
+	return m
+}
@@ -0,0 +1,87 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDataStatus(t *testing.T) {
+	table := `<met>
+  <info><name>Two</name><length>2</length></info>
+  <pre-crawford-table type="explicit">
+    <row><me>0.5</me><me>0.7</me></row>
+    <row><me>0.3</me><me>0.5</me></row>
+  </pre-crawford-table>
+</met>`
+	sum := sha256.Sum256([]byte(table))
+	want := DataFile{Kind: "met", Size: int64(len(table)), SHA256: hex.EncodeToString(sum[:])}
+
+	path := filepath.Join(t.TempDir(), "two.xml")
+	if err := os.WriteFile(path, []byte(table), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	e, err := NewEngine(EngineOptions{METFile: path, SkipWarmup: true})
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	fromFile := want
+	fromFile.Path = path
+	if got := e.DataStatus(); !reflect.DeepEqual(got, []DataFile{fromFile}) {
+		t.Errorf("DataStatus = %+v, want %+v", got, fromFile)
+	}
+
+	e, err = NewEngineFromBytes(nil, nil, nil, []byte(table), EngineOptions{SkipWarmup: true})
+	if err != nil {
+		t.Fatalf("NewEngineFromBytes failed: %v", err)
+	}
+	if got := e.DataStatus(); !reflect.DeepEqual(got, []DataFile{want}) {
+		t.Errorf("DataStatus from bytes = %+v, want %+v", got, want)
+	}
+
+	e, err = NewEngine(EngineOptions{SkipWarmup: true})
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	if got := e.DataStatus(); len(got) != 0 {
+		t.Errorf("DataStatus with no data files = %+v, want none", got)
+	}
+}
+
+func TestRolloutManifestReproduces(t *testing.T) {
+	e, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	state := StartingPosition()
+
+	first, err := e.Rollout(state, RolloutOptions{Trials: 200, Truncate: 8})
+	if err != nil {
+		t.Fatalf("Rollout failed: %v", err)
+	}
+	m := first.Manifest
+	if m == nil {
+		t.Fatal("rollout has no manifest")
+	}
+	if m.Seed == 0 || m.Seed != first.Seed {
+		t.Errorf("manifest seed %d, result seed %d: want the drawn seed in both", m.Seed, first.Seed)
+	}
+	if m.Version != Version || m.DiceRNG != DiceRNG || m.Trials != 200 || m.Truncate != 8 ||
+		m.FirstRoll != "standard" || m.Position != EncodePositionID(state.Board) {
+		t.Errorf("manifest = %+v", m)
+	}
+
+	// Replaying with the manifest's settings gives the same result
+	again, err := e.Rollout(state, RolloutOptions{Trials: m.Trials, Truncate: m.Truncate, Seed: m.Seed, LeafPly: m.LeafPly})
+	if err != nil {
+		t.Fatalf("Rollout failed: %v", err)
+	}
+	first.setThroughput(0, 0, 0)
+	again.setThroughput(0, 0, 0)
+	if !reflect.DeepEqual(first, again) {
+		t.Errorf("rollout with the manifest seed differs:\n  first %+v\n  again %+v", *first, *again)
+	}
+}
@@ -3,7 +3,10 @@ package engine
 
 import (
 	"fmt"
+	"math"
+	"strings"
 
+	"github.com/yourusername/bgengine/internal/met"
 	"github.com/yourusername/bgengine/internal/positionid"
 )
 
@@ -21,7 +24,68 @@ type MatchAnalysis struct {
 	CubeErrors []CubeErrorDetail `json:"cube_errors"` // All cube errors
 
 	// Luck analysis
-	PlayerLuck [2]LuckAnalysis `json:"player_luck"` // Luck per player
+	PlayerLuck   [2]LuckAnalysis     `json:"player_luck"`             // Luck per player
+	RollLuck     []LuckDetail        `json:"roll_luck,omitempty"`     // Luck of every roll in order, with IncludeLuck
+	LuckAdjusted *LuckAdjustedResult `json:"luck_adjusted,omitempty"` // Result with the luck taken out, once the game results are known
+
+	// Equity graph
+	Timeline []TimelinePoint `json:"timeline"` // Player 0's MWC or equity after every decision
+
+	// Every decision in full, with MatchAnalysisOptions.Decisions, for writing
+	// annotated match files
+	Decisions []DecisionAnalysis `json:"-"`
+
+	Engine *BuildInfo `json:"engine,omitempty"` // Engine build and data the analysis was made with
+}
+
+// DecisionAnalysis is the full analysis of one decision, as match files
+// annotate it. A checker play has CubeAction NoDouble and its candidate moves
+// in Moves, in the mover's notation with the mover's equities; a cube action
+// has the cube decision in Cube, from the doubler's side for the response too.
+// Analysis read from a match file leaves MoveNumber to the position it is on.
+type DecisionAnalysis struct {
+	GameNumber int
+	MoveNumber int
+	Player     int
+	CubeAction CubeAction // Action taken, NoDouble for a checker play
+
+	Moves  []MoveWithEval // Candidate moves, best first (empty if forced)
+	Played int            // Index of the played move in Moves, -1 if not there
+
+	Cube *CubeEquities // Cube decision before the roll or on the double
+
+	Skill SkillType
+	Luck  *float64 // Luck of the roll (checker plays with IncludeLuck)
+}
+
+// CubeEquities is a cube decision as match files record it: the cubeless
+// evaluation of the position for the player on roll and the cubeful equities
+// of their choices, normalized to the current cube.
+type CubeEquities struct {
+	Eval       Evaluation
+	NoDouble   float64
+	DoubleTake float64
+	DoublePass float64 // Not recorded by gnubg's SGF files
+	Plies      int     // Depth of the evaluation
+}
+
+// TimelinePoint is one step of the match equity graph.
+// Value is player 0's match winning chance in match play, or player 0's equity
+// in points in money games; player 1's line is 1-Value or -Value. Each game
+// starts with a point at the table value for its score (Player -1, MoveNumber 0).
+// Values come from the cubeless evaluation of the position reached.
+type TimelinePoint struct {
+	GameNumber int     `json:"game_number"`
+	MoveNumber int     `json:"move_number"`
+	Player     int     `json:"player"` // Player who made the decision, -1 at game start
+	Value      float64 `json:"value"`
+	Delta      float64 `json:"delta"` // Change from the previous point
+	Skill      float64 `json:"skill"` // Part of Delta due to the decision (player 0's view, <= 0 for player 0's errors)
+	Luck       float64 `json:"luck"`  // Remainder of Delta: the dice since the previous point
+
+	// Spread is the equity spread of the top candidates of a checker play
+	// (see DecisionSpread), 0 for other points
+	Spread float64 `json:"spread,omitempty"`
 }
 
 // PlayerAnalysis contains analysis stats for one player across the match.
@@ -43,6 +107,20 @@ type PlayerAnalysis struct {
 	WrongDoubles  int     `json:"wrong_doubles"`  // Shouldn't have doubled
 	WrongTakes    int     `json:"wrong_takes"`    // Should have passed
 	WrongPasses   int     `json:"wrong_passes"`   // Should have taken
+	WrongBeavers  int     `json:"wrong_beavers"`  // Beavered when it was wrong
+	MissedBeavers int     `json:"missed_beavers"` // Took when a beaver was right
+
+	// DeadCubeDecisions counts cube actions taken with the cube dead at the
+	// score (see Engine.DeadCube). They are not in TotalCube and never
+	// charged.
+	DeadCubeDecisions int `json:"dead_cube_decisions"`
+
+	// Weakness breaks the reported move and cube errors down by kind of
+	// position, game phase and decision
+	Weakness WeaknessProfile `json:"weakness"`
+
+	// Difficulty of the unforced moves and the EPM weighted by it
+	DifficultyStats
 }
 
 // GameAnalysis contains analysis of a single game.
@@ -54,7 +132,9 @@ type GameAnalysis struct {
 	TotalError   [2]float64        `json:"total_error"` // Error per player
 	ErrorPerMove [2]float64        `json:"error_per_move"`
 	CubeActions  int               `json:"cube_actions"`
-	Errors       []MoveErrorDetail `json:"errors"` // Errors in this game
+	Errors       []MoveErrorDetail `json:"errors"`               // Errors in this game
+	Luckiest     []LuckDetail      `json:"luckiest,omitempty"`   // Luckiest rolls, luckiest first, with IncludeLuck
+	Unluckiest   []LuckDetail      `json:"unluckiest,omitempty"` // Unluckiest rolls, unluckiest first, with IncludeLuck
 }
 
 // MoveErrorDetail contains details about a single move error.
@@ -69,6 +149,9 @@ type MoveErrorDetail struct {
 	EquityLoss float64   `json:"equity_loss"`
 	Skill      SkillType `json:"skill"`
 	SkillStr   string    `json:"skill_str"`
+	Category   string    `json:"category"` // Kind of position for the mover (see CategoryName)
+	Phase      GamePhase `json:"phase"`    // Phase of the game for the mover
+	Spread     float64   `json:"spread"`   // Equity spread of the top candidates (see DecisionSpread)
 }
 
 // CubeErrorDetail contains details about a cube decision error.
@@ -84,6 +167,8 @@ type CubeErrorDetail struct {
 	EquityLoss float64    `json:"equity_loss"`
 	Skill      SkillType  `json:"skill"`
 	SkillStr   string     `json:"skill_str"`
+	Category   string     `json:"category"` // Kind of position for the player deciding (see CategoryName)
+	Phase      GamePhase  `json:"phase"`    // Phase of the game for the player deciding
 }
 
 // LuckAnalysis contains luck statistics for a player.
@@ -98,28 +183,82 @@ type LuckAnalysis struct {
 
 // AnalyzedPosition represents a position with analysis for match reconstruction.
 type AnalyzedPosition struct {
-	Board      Board      `json:"board"`
-	Turn       int        `json:"turn"`
-	Dice       [2]int     `json:"dice"`
-	CubeValue  int        `json:"cube_value"`
-	CubeOwner  int        `json:"cube_owner"`
-	Score      [2]int     `json:"score"`
-	Move       *Move      `json:"move,omitempty"`
-	CubeAction CubeAction `json:"cube_action,omitempty"`
-	GameNumber int        `json:"game_number"`
-	MoveNumber int        `json:"move_number"`
-	Player     int        `json:"player"`
+	Board       Board      `json:"board"`
+	Turn        int        `json:"turn"`
+	Dice        [2]int     `json:"dice"`
+	CubeValue   int        `json:"cube_value"`
+	CubeOwner   int        `json:"cube_owner"`
+	Score       [2]int     `json:"score"`
+	MatchLength int        `json:"match_length"` // 0 = money game
+	Crawford    bool       `json:"crawford,omitempty"`
+	Move        *Move      `json:"move,omitempty"`
+	CubeAction  CubeAction `json:"cube_action,omitempty"`
+	GameNumber  int        `json:"game_number"`
+	MoveNumber  int        `json:"move_number"`
+	Player      int        `json:"player"`
+
+	// Annotation is the analysis the match file recorded for the decision,
+	// such as gnubg's, to compare with the engine's
+	Annotation *DecisionAnalysis `json:"-"`
 }
 
 // MatchAnalysisOptions configures match analysis behavior.
 type MatchAnalysisOptions struct {
 	IncludeLuck    bool    `json:"include_luck"`    // Calculate luck (slower)
 	ErrorThreshold float64 `json:"error_threshold"` // Min error to report (default 0)
-	Ply            int     `json:"ply"`             // Analysis ply (0, 1, 2)
+	Ply            int     `json:"ply"`             // Checker play analysis ply (0, 1, 2)
 	Player1Name    string  `json:"player1_name"`
 	Player2Name    string  `json:"player2_name"`
+
+	// Thresholds rates the moves and cube decisions (nil = DefaultAnalysisConfig)
+	Thresholds *AnalysisConfig `json:"thresholds,omitempty"`
+
+	// MET names the match equity table match play is analyzed with (see
+	// Engine.METNames; "" = the engine's)
+	MET string `json:"met,omitempty"`
+
+	// Decisions keeps the full analysis of every decision in
+	// MatchAnalysis.Decisions
+	Decisions bool `json:"decisions,omitempty"`
+
+	// Jokers is the number of rolls listed as each game's luckiest and
+	// unluckiest with IncludeLuck (0 = DefaultJokers)
+	Jokers int `json:"jokers,omitempty"`
+
+	// OnDecision, if set, is called with each decision as soon as it has
+	// been analyzed, in the order of the positions, so that callers can
+	// stream the results or show progress. An error it returns stops the
+	// analysis, and AnalyzePositionList returns it as it is.
+	OnDecision func(DecisionResult) error `json:"-"`
+}
+
+// DecisionResult is one analyzed decision, as AnalyzePositionList reports it
+// to MatchAnalysisOptions.OnDecision: every checker play and cube action,
+// not only those rated as errors.
+type DecisionResult struct {
+	GameNumber int       `json:"game_number"`
+	MoveNumber int       `json:"move_number"`
+	Player     int       `json:"player"`
+	Kind       string    `json:"kind"`     // "move" or "cube"
+	Position   string    `json:"position"` // Position ID before the decision
+	Dice       [2]int    `json:"dice"`     // Roll of a checker play, [0, 0] for a cube action
+	Played     string    `json:"played"`   // Move played, or cube action taken
+	Best       string    `json:"best"`     // Best move, or best cube action
+	EquityLoss float64   `json:"equity_loss"`
+	Skill      SkillType `json:"skill"`
+	SkillStr   string    `json:"skill_str"`
+	Forced     bool      `json:"forced,omitempty"` // The play was the only one
+	Luck       *float64  `json:"luck,omitempty"`   // Luck of the roll, with IncludeLuck
+
+	// NotApplicable marks a cube action that could not matter, with the
+	// reason in Note; it is not charged
+	NotApplicable bool   `json:"not_applicable,omitempty"`
+	Note          string `json:"note,omitempty"`
 }
 
+// DeadCubeNote is the note on a cube action taken with the cube dead.
+const DeadCubeNote = "cube dead at this score"
+
 // DefaultMatchAnalysisOptions returns sensible defaults.
 func DefaultMatchAnalysisOptions() MatchAnalysisOptions {
 	return MatchAnalysisOptions{
@@ -131,14 +270,53 @@ func DefaultMatchAnalysisOptions() MatchAnalysisOptions {
 	}
 }
 
+// PositionError reports a position in a list that could not be analyzed,
+// such as a move that is not legal from the position before it.
+type PositionError struct {
+	Index      int // Index of the position in the list
+	GameNumber int
+	MoveNumber int
+	Err        error
+}
+
+func (e *PositionError) Error() string {
+	return fmt.Sprintf("position %d (game %d, move %d): %v", e.Index, e.GameNumber, e.MoveNumber, e.Err)
+}
+
+func (e *PositionError) Unwrap() error {
+	return e.Err
+}
+
+// CheckMove returns an error if m is not a legal play of dice on board. It
+// is ValidateMove with the arguments in the order of AnalyzedPosition.
+func CheckMove(board Board, m Move, dice [2]int) error {
+	return ValidateMove(board, dice, m)
+}
+
 // AnalyzePositionList analyzes a list of positions with moves/cube actions.
 // This is the core function for match analysis from recorded games.
+// A move that is not legal from its position, or a position that cannot be
+// analyzed, stops the analysis with a *PositionError. Thresholds that fail
+// Validate are reported before any position is analyzed.
 func (e *Engine) AnalyzePositionList(positions []AnalyzedPosition, opts MatchAnalysisOptions) (*MatchAnalysis, error) {
+	cfg := DefaultAnalysisConfig()
+	if opts.Thresholds != nil {
+		if err := opts.Thresholds.Validate(); err != nil {
+			return nil, err
+		}
+		cfg = *opts.Thresholds
+	}
+	t, err := e.metTable(opts.MET)
+	if err != nil {
+		return nil, err
+	}
+
 	if len(positions) == 0 {
-		return &MatchAnalysis{}, nil
+		return &MatchAnalysis{Engine: e.BuildInfo()}, nil
 	}
 
 	result := &MatchAnalysis{
+		Engine: e.BuildInfo(),
 		PlayerStats: [2]PlayerAnalysis{
 			{Name: opts.Player1Name},
 			{Name: opts.Player2Name},
@@ -146,55 +324,161 @@ func (e *Engine) AnalyzePositionList(positions []AnalyzedPosition, opts MatchAna
 		GameStats:  make([]GameAnalysis, 0),
 		MoveErrors: make([]MoveErrorDetail, 0),
 		CubeErrors: make([]CubeErrorDetail, 0),
+		Timeline:   make([]TimelinePoint, 0),
+	}
+
+	jokers := opts.Jokers
+	if jokers <= 0 {
+		jokers = DefaultJokers
 	}
 
 	// Track current game
 	currentGame := -1
+	var rolls [2]int // Rolls the luck of each player was measured on
 	var gameAnalysis *GameAnalysis
+	gameRolls := 0  // Index in result.RollLuck of the current game's first roll
+	played := false // A checker play of the current game has been analyzed
+
+	finishGame := func() {
+		for p := 0; p < 2; p++ {
+			if gameAnalysis.MoveCount[p] > 0 {
+				gameAnalysis.ErrorPerMove[p] = gameAnalysis.TotalError[p] / float64(gameAnalysis.MoveCount[p])
+			}
+		}
+		gameAnalysis.Luckiest, gameAnalysis.Unluckiest = Jokers(result.RollLuck[gameRolls:], jokers)
+		result.GameStats = append(result.GameStats, *gameAnalysis)
+	}
+
+	for i, pos := range positions {
+		fail := func(err error) (*MatchAnalysis, error) {
+			return nil, &PositionError{Index: i, GameNumber: pos.GameNumber, MoveNumber: pos.MoveNumber, Err: err}
+		}
 
-	for _, pos := range positions {
 		// Start new game if needed
 		if pos.GameNumber != currentGame {
 			if gameAnalysis != nil {
-				// Finalize previous game
-				for p := 0; p < 2; p++ {
-					if gameAnalysis.MoveCount[p] > 0 {
-						gameAnalysis.ErrorPerMove[p] = gameAnalysis.TotalError[p] / float64(gameAnalysis.MoveCount[p])
-					}
-				}
-				result.GameStats = append(result.GameStats, *gameAnalysis)
+				finishGame()
 			}
 			currentGame = pos.GameNumber
+			gameRolls = len(result.RollLuck)
+			played = false
 			gameAnalysis = &GameAnalysis{
 				GameNumber: currentGame,
 				Winner:     -1,
 				Errors:     make([]MoveErrorDetail, 0),
 			}
 			result.TotalGames++
+
+			result.addTimelinePoint(TimelinePoint{
+				GameNumber: currentGame,
+				Player:     -1,
+				Value:      resultValue(t, pos.Score, pos.MatchLength, 0, 0),
+			})
 		}
 
 		player := pos.Player
 
+		// The player who lost the opening roll has no play to analyze
+		if pos.Move != nil && openingLoserMove(pos.Board, *pos.Move, !played) {
+			continue
+		}
+
 		// Analyze move if present
 		if pos.Move != nil {
+			played = true
+			if err := CheckMove(pos.Board, *pos.Move, pos.Dice); err != nil {
+				return fail(err)
+			}
 			result.TotalMoves++
 			gameAnalysis.MoveCount[player]++
 
 			gs := &GameState{
-				Board:     pos.Board,
-				Turn:      pos.Turn,
-				CubeValue: pos.CubeValue,
-				CubeOwner: pos.CubeOwner,
-				Score:     pos.Score,
+				Board:       pos.Board,
+				Turn:        pos.Turn,
+				CubeValue:   pos.CubeValue,
+				CubeOwner:   pos.CubeOwner,
+				MatchLength: pos.MatchLength,
+				Score:       pos.Score,
+				Crawford:    pos.Crawford,
+				MET:         opts.MET,
 			}
 
-			analysis, err := e.AnalyzeMoveSkill(gs, *pos.Move, pos.Dice)
+			analysis, err := e.analyzeMoveSkill(gs, *pos.Move, pos.Dice, cfg, opts.Ply)
 			if err != nil {
-				continue
+				return fail(err)
+			}
+
+			var luck *float64
+			if opts.IncludeLuck {
+				l, err := e.Luck(gs, pos.Dice)
+				if err != nil {
+					return fail(err)
+				}
+				luck = &l
+				rolls[player]++
+				result.RollLuck = append(result.RollLuck, LuckDetail{
+					GameNumber: pos.GameNumber,
+					MoveNumber: pos.MoveNumber,
+					Player:     player,
+					Position:   EncodePositionID(pos.Board),
+					Dice:       pos.Dice,
+					Luck:       l,
+					Points:     l * float64(max(pos.CubeValue, 1)),
+				})
+				pl := &result.PlayerLuck[player]
+				pl.TotalLuck += l
+				switch cfg.ClassifyLuck(l) {
+				case LuckVeryGood:
+					pl.VeryLucky++
+				case LuckGood:
+					pl.Lucky++
+				case LuckBad:
+					pl.Unlucky++
+				case LuckVeryBad:
+					pl.VeryUnlucky++
+				}
+			}
+
+			if opts.Decisions {
+				result.Decisions = append(result.Decisions, moveDecision(pos, analysis, luck))
+			}
+
+			played, errPlayed := e.timelineValue(t, pos, swapBoard(ApplyMove(pos.Board, *pos.Move)), 1-pos.Turn, pos.CubeValue)
+			best, errBest := e.timelineValue(t, pos, swapBoard(ApplyMove(pos.Board, analysis.BestMove)), 1-pos.Turn, pos.CubeValue)
+			if errPlayed == nil && errBest == nil {
+				result.addTimelinePoint(TimelinePoint{
+					GameNumber: pos.GameNumber,
+					MoveNumber: pos.MoveNumber,
+					Player:     player,
+					Value:      played,
+					Skill:      played - best,
+					Spread:     analysis.Spread,
+				})
+			}
+
+			if opts.OnDecision != nil {
+				if err := opts.OnDecision(DecisionResult{
+					GameNumber: pos.GameNumber,
+					MoveNumber: pos.MoveNumber,
+					Player:     player,
+					Kind:       "move",
+					Position:   EncodePositionID(pos.Board),
+					Dice:       pos.Dice,
+					Played:     FormatMove(*pos.Move),
+					Best:       FormatMove(analysis.BestMove),
+					EquityLoss: analysis.EquityLoss,
+					Skill:      analysis.Skill,
+					SkillStr:   analysis.Skill.String(),
+					Forced:     analysis.IsForced,
+					Luck:       luck,
+				}); err != nil {
+					return nil, err
+				}
 			}
 
 			if !analysis.IsForced {
 				result.PlayerStats[player].TotalMoves++
+				result.PlayerStats[player].DifficultyStats.Add(analysis.Spread, analysis.EquityLoss, analysis.EquityLoss >= opts.ErrorThreshold)
 
 				if analysis.EquityLoss >= opts.ErrorThreshold {
 					result.PlayerStats[player].TotalError += analysis.EquityLoss
@@ -213,6 +497,7 @@ func (e *Engine) AnalyzePositionList(positions []AnalyzedPosition, opts MatchAna
 					// Record error if significant
 					if analysis.Skill != SkillNone {
 						posID := EncodePositionID(pos.Board)
+						category, phase := ClassifyDecision(pos.Board, pos.MoveNumber, NoDouble)
 						errDetail := MoveErrorDetail{
 							GameNumber: pos.GameNumber,
 							MoveNumber: pos.MoveNumber,
@@ -224,6 +509,9 @@ func (e *Engine) AnalyzePositionList(positions []AnalyzedPosition, opts MatchAna
 							EquityLoss: analysis.EquityLoss,
 							Skill:      analysis.Skill,
 							SkillStr:   analysis.Skill.String(),
+							Category:   CategoryName(category),
+							Phase:      phase,
+							Spread:     analysis.Spread,
 						}
 						result.MoveErrors = append(result.MoveErrors, errDetail)
 						gameAnalysis.Errors = append(gameAnalysis.Errors, errDetail)
@@ -234,21 +522,82 @@ func (e *Engine) AnalyzePositionList(positions []AnalyzedPosition, opts MatchAna
 
 		// Analyze cube action if present
 		if pos.CubeAction != NoDouble && pos.CubeAction != 0 {
+			gs := &GameState{
+				Board:       pos.Board,
+				Turn:        pos.Turn,
+				CubeValue:   pos.CubeValue,
+				CubeOwner:   pos.CubeOwner,
+				MatchLength: pos.MatchLength,
+				Score:       pos.Score,
+				Crawford:    pos.Crawford,
+				MET:         opts.MET,
+			}
+
+			// Tiny differences between clamped MET entries would otherwise
+			// charge an action that cannot matter
+			if e.DeadCube(gs) {
+				if err := e.deadCubeDecision(result, t, pos, opts); err != nil {
+					return nil, err
+				}
+				continue
+			}
+
 			result.TotalCubeActs++
 			result.PlayerStats[player].TotalCube++
 			gameAnalysis.CubeActions++
 
-			gs := &GameState{
-				Board:     pos.Board,
-				Turn:      pos.Turn,
-				CubeValue: pos.CubeValue,
-				CubeOwner: pos.CubeOwner,
-				Score:     pos.Score,
+			analysis, err := e.AnalyzeCubeSkillWithConfig(gs, pos.CubeAction, cfg)
+			if err != nil {
+				return fail(err)
 			}
 
-			analysis, err := e.AnalyzeCubeSkill(gs, pos.CubeAction)
-			if err != nil {
-				continue
+			if opts.Decisions {
+				ca := analysis.Analysis
+				result.Decisions = append(result.Decisions, DecisionAnalysis{
+					GameNumber: pos.GameNumber,
+					MoveNumber: pos.MoveNumber,
+					Player:     player,
+					CubeAction: pos.CubeAction,
+					Played:     -1,
+					Cube: &CubeEquities{
+						Eval:       ca.Eval,
+						NoDouble:   ca.NoDoubleEquity,
+						DoubleTake: ca.DoubleTakeEq,
+						DoublePass: ca.DoublePassEq,
+					},
+					Skill: analysis.Skill,
+				})
+			}
+
+			if opts.OnDecision != nil {
+				if err := opts.OnDecision(DecisionResult{
+					GameNumber: pos.GameNumber,
+					MoveNumber: pos.MoveNumber,
+					Player:     player,
+					Kind:       "cube",
+					Position:   EncodePositionID(pos.Board),
+					Played:     pos.CubeAction.String(),
+					Best:       analysis.OptimalPlay.String(),
+					EquityLoss: analysis.EquityLoss,
+					Skill:      analysis.Skill,
+					SkillStr:   analysis.Skill.String(),
+				}); err != nil {
+					return nil, err
+				}
+			}
+
+			if value, err := e.cubeTimelineValue(t, pos); err == nil {
+				skill := analysis.EquityLoss * equityScale(t, pos)
+				if player == 0 {
+					skill = -skill
+				}
+				result.addTimelinePoint(TimelinePoint{
+					GameNumber: pos.GameNumber,
+					MoveNumber: pos.MoveNumber,
+					Player:     player,
+					Value:      value,
+					Skill:      skill,
+				})
 			}
 
 			if analysis.EquityLoss > 0 {
@@ -259,16 +608,21 @@ func (e *Engine) AnalyzePositionList(positions []AnalyzedPosition, opts MatchAna
 				switch {
 				case pos.CubeAction == NoDouble && analysis.OptimalPlay == Double:
 					result.PlayerStats[player].MissedDoubles++
-				case pos.CubeAction == Double && analysis.OptimalPlay == NoDouble:
+				case pos.CubeAction == Double && (analysis.OptimalPlay == NoDouble || analysis.OptimalPlay == TooGood):
 					result.PlayerStats[player].WrongDoubles++
 				case pos.CubeAction == Take && (analysis.OptimalPlay == Pass):
 					result.PlayerStats[player].WrongTakes++
-				case pos.CubeAction == Pass && (analysis.OptimalPlay == Take):
+				case pos.CubeAction == Pass && (analysis.OptimalPlay == Take || analysis.OptimalPlay == Beaver):
 					result.PlayerStats[player].WrongPasses++
+				case pos.CubeAction == Beaver:
+					result.PlayerStats[player].WrongBeavers++
+				case pos.CubeAction == Take && analysis.OptimalPlay == Beaver:
+					result.PlayerStats[player].MissedBeavers++
 				}
 
 				if analysis.Skill != SkillNone {
 					posID := EncodePositionID(pos.Board)
+					category, phase := ClassifyDecision(pos.Board, pos.MoveNumber, pos.CubeAction)
 					result.CubeErrors = append(result.CubeErrors, CubeErrorDetail{
 						GameNumber: pos.GameNumber,
 						MoveNumber: pos.MoveNumber,
@@ -281,6 +635,8 @@ func (e *Engine) AnalyzePositionList(positions []AnalyzedPosition, opts MatchAna
 						EquityLoss: analysis.EquityLoss,
 						Skill:      analysis.Skill,
 						SkillStr:   analysis.Skill.String(),
+						Category:   CategoryName(category),
+						Phase:      phase,
 					})
 				}
 			}
@@ -289,26 +645,188 @@ func (e *Engine) AnalyzePositionList(positions []AnalyzedPosition, opts MatchAna
 
 	// Finalize last game
 	if gameAnalysis != nil {
-		for p := 0; p < 2; p++ {
-			if gameAnalysis.MoveCount[p] > 0 {
-				gameAnalysis.ErrorPerMove[p] = gameAnalysis.TotalError[p] / float64(gameAnalysis.MoveCount[p])
-			}
-		}
-		result.GameStats = append(result.GameStats, *gameAnalysis)
+		finishGame()
 	}
 
 	// Calculate overall stats
+	weakness := WeaknessProfiles(result.MoveErrors, result.CubeErrors)
 	for p := 0; p < 2; p++ {
+		result.PlayerStats[p].Weakness = weakness[p]
 		if result.PlayerStats[p].TotalMoves > 0 {
 			result.PlayerStats[p].ErrorPerMove = result.PlayerStats[p].TotalError / float64(result.PlayerStats[p].TotalMoves)
 		}
+		result.PlayerStats[p].DifficultyStats.Finish()
 		result.PlayerStats[p].Rating = GetRating(result.PlayerStats[p].ErrorPerMove)
 		result.PlayerStats[p].RatingStr = result.PlayerStats[p].Rating.String()
+		if rolls[p] > 0 {
+			result.PlayerLuck[p].AvgLuck = result.PlayerLuck[p].TotalLuck / float64(rolls[p])
+		}
 	}
 
 	return result, nil
 }
 
+// deadCubeDecision records the cube action at pos, taken with the cube
+// dead: counted, reported as not applicable and never charged.
+func (e *Engine) deadCubeDecision(result *MatchAnalysis, t *met.Table, pos AnalyzedPosition, opts MatchAnalysisOptions) error {
+	result.PlayerStats[pos.Player].DeadCubeDecisions++
+	if opts.Decisions {
+		result.Decisions = append(result.Decisions, DecisionAnalysis{
+			GameNumber: pos.GameNumber,
+			MoveNumber: pos.MoveNumber,
+			Player:     pos.Player,
+			CubeAction: pos.CubeAction,
+			Played:     -1,
+			Skill:      SkillNone,
+		})
+	}
+	if opts.OnDecision != nil {
+		if err := opts.OnDecision(DecisionResult{
+			GameNumber:    pos.GameNumber,
+			MoveNumber:    pos.MoveNumber,
+			Player:        pos.Player,
+			Kind:          "cube",
+			Position:      EncodePositionID(pos.Board),
+			Played:        pos.CubeAction.String(),
+			Best:          pos.CubeAction.String(),
+			Skill:         SkillNone,
+			SkillStr:      SkillNone.String(),
+			NotApplicable: true,
+			Note:          DeadCubeNote,
+		}); err != nil {
+			return err
+		}
+	}
+	if value, err := e.cubeTimelineValue(t, pos); err == nil {
+		result.addTimelinePoint(TimelinePoint{
+			GameNumber: pos.GameNumber,
+			MoveNumber: pos.MoveNumber,
+			Player:     pos.Player,
+			Value:      value,
+		})
+	}
+	return nil
+}
+
+// moveDecision returns the full analysis of the checker play at pos: the
+// candidates analysis ranked, with the played move added if it is not among
+// them.
+func moveDecision(pos AnalyzedPosition, analysis *MoveSkillAnalysis, luck *float64) DecisionAnalysis {
+	d := DecisionAnalysis{
+		GameNumber: pos.GameNumber,
+		MoveNumber: pos.MoveNumber,
+		Player:     pos.Player,
+		Played:     -1,
+		Skill:      analysis.Skill,
+		Luck:       luck,
+	}
+	if analysis.IsForced || analysis.Played == nil {
+		return d
+	}
+	d.Moves = append([]MoveWithEval(nil), analysis.TopMoves...)
+	played := ApplyMove(pos.Board, analysis.Played.Move)
+	for i, m := range d.Moves {
+		if EqualBoards(ApplyMove(pos.Board, m.Move), played) {
+			d.Played = i
+		}
+	}
+	if d.Played < 0 {
+		d.Played = len(d.Moves)
+		d.Moves = append(d.Moves, *analysis.Played)
+	}
+	return d
+}
+
+// addTimelinePoint appends p to the timeline, filling in Delta from the previous
+// point of the same game and attributing what skill does not explain to luck.
+func (r *MatchAnalysis) addTimelinePoint(p TimelinePoint) {
+	if n := len(r.Timeline); n > 0 && p.Player >= 0 {
+		p.Delta = p.Value - r.Timeline[n-1].Value
+		p.Luck = p.Delta - p.Skill
+	}
+	r.Timeline = append(r.Timeline, p)
+}
+
+// resultValue returns player 0's timeline value after winner wins points
+// at the given score: their MWC from the match equity table t in match play
+// or their points in money games.
+func resultValue(t *met.Table, score [2]int, matchLength, winner, points int) float64 {
+	if matchLength == 0 {
+		if winner == 0 {
+			return float64(points)
+		}
+		return -float64(points)
+	}
+	score[winner] += points
+	return getMWCForScore(t, score, matchLength, 0, false)
+}
+
+// timelineValue returns player 0's timeline value for board with onRoll to play
+// and the given cube, weighting each game result by its cubeless probability.
+func (e *Engine) timelineValue(t *met.Table, pos AnalyzedPosition, board Board, onRoll, cube int) (float64, error) {
+	eval, err := e.Evaluate(&GameState{
+		Board:       board,
+		Turn:        onRoll,
+		CubeValue:   cube,
+		CubeOwner:   pos.CubeOwner,
+		MatchLength: pos.MatchLength,
+		Score:       pos.Score,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	other := 1 - onRoll
+	results := [6]struct {
+		p      float64
+		winner int
+		points int
+	}{
+		{eval.WinProb - eval.WinG, onRoll, cube},
+		{eval.WinG - eval.WinBG, onRoll, 2 * cube},
+		{eval.WinBG, onRoll, 3 * cube},
+		{1 - eval.WinProb - eval.LoseG, other, cube},
+		{eval.LoseG - eval.LoseBG, other, 2 * cube},
+		{eval.LoseBG, other, 3 * cube},
+	}
+
+	value := 0.0
+	for _, r := range results {
+		if r.p > 0 {
+			value += r.p * resultValue(t, pos.Score, pos.MatchLength, r.winner, r.points)
+		}
+	}
+	return value, nil
+}
+
+// cubeTimelineValue returns player 0's timeline value after the cube action in pos.
+// Cube positions hold the doubler on roll and the cube before the double.
+func (e *Engine) cubeTimelineValue(t *met.Table, pos AnalyzedPosition) (float64, error) {
+	cube := pos.CubeValue
+	switch pos.CubeAction {
+	case Double, Redouble, Take:
+		cube *= 2
+	case Beaver:
+		cube *= 4
+	case Raccoon:
+		cube *= 8
+	case Pass:
+		return resultValue(t, pos.Score, pos.MatchLength, pos.Turn, pos.CubeValue), nil
+	}
+	return e.timelineValue(t, pos, pos.Board, pos.Turn, cube)
+}
+
+// equityScale converts a normalized equity loss at pos to timeline units:
+// points in money games, MWC from the match equity table t in match play
+// (the inverse of Mwc2Eq).
+func equityScale(t *met.Table, pos AnalyzedPosition) float64 {
+	if pos.MatchLength == 0 {
+		return float64(pos.CubeValue)
+	}
+	mwc := getMWCForScore(t, pos.Score, pos.MatchLength, 0, false)
+	return math.Min(mwc, 1-mwc)
+}
+
 // EncodePositionID returns the base64 position ID for a board.
 func EncodePositionID(b Board) string {
 	return positionid.PositionID(positionid.Board(b))
@@ -316,29 +834,16 @@ func EncodePositionID(b Board) string {
 
 // FormatMove converts a Move to human-readable notation.
 func FormatMove(m Move) string {
-	result := ""
-	for i := 0; i < 4; i++ {
-		if m.From[i] < 0 {
-			break
-		}
+	var b strings.Builder
+	for i := 0; i < 4 && m.From[i] >= 0; i++ {
 		if i > 0 {
-			result += " "
-		}
-		from := int(m.From[i]) + 1
-		if m.From[i] == 24 {
-			result += "bar"
-		} else {
-			result += fmt.Sprintf("%d", from)
-		}
-		result += "/"
-		to := int(m.To[i]) + 1
-		if m.To[i] == -1 || m.To[i] == 25 {
-			result += "off"
-		} else {
-			result += fmt.Sprintf("%d", to)
+			b.WriteByte(' ')
 		}
+		b.WriteString(Point(m.From[i]).String())
+		b.WriteByte('/')
+		b.WriteString(Point(m.To[i]).String())
 	}
-	return result
+	return b.String()
 }
 
 // CubeActionString returns the string representation of a CubeAction.
@@ -356,6 +861,10 @@ func CubeActionString(a CubeAction) string {
 		return "pass"
 	case Beaver:
 		return "beaver"
+	case Raccoon:
+		return "raccoon"
+	case TooGood:
+		return "too_good"
 	default:
 		return "unknown"
 	}
@@ -366,12 +875,32 @@ func (a CubeAction) String() string {
 	return CubeActionString(a)
 }
 
+// openingLoserMove reports whether move, a play of no checkers, is the
+// opening roll as recorded for the player who lost it rather than a play:
+// first is whether it comes before any other play of its game, and board is
+// a starting position, from which every roll has a legal play. The winner of
+// the opening roll plays both dice.
+func openingLoserMove(board Board, move Move, first bool) bool {
+	return first && move.From[0] < 0 && IsStartingPosition(board)
+}
+
 // MatchToPositions converts a Match into a list of AnalyzedPositions for analysis.
 // This reconstructs positions from game actions.
 type MatchActions struct {
 	Actions     []MatchAction
 	Player1Name string
 	Player2Name string
+	Scores      map[int][2]int   // Score at the start of each game by game number, if known
+	Crawford    map[int]bool     // Whether each game is the Crawford game, by game number
+	StartBoards map[int]Board    // Starting board of each game by game number, if not the standard position
+	StartCubes  map[int]GameCube // Cube at the start of each game by game number, if not centered at 1
+}
+
+// GameCube is the state of the cube at the start of a game, for games that
+// are analyzed from a position part way through.
+type GameCube struct {
+	Value int // Cube value
+	Owner int // Player who owns the cube, -1 if centered
 }
 
 // MatchAction represents an action in a match for analysis.
@@ -382,39 +911,82 @@ type MatchAction struct {
 	Dice       [2]int
 	Move       *Move
 	CubeAction CubeAction
+	Annotation *DecisionAnalysis // Analysis recorded in the match file, if any
 }
 
 // ConvertMatchActionsToPositions reconstructs positions from match actions.
 // This walks through the actions and reconstructs the board state at each decision.
+// score is the score at the start of the first game. Later games take their score
+// from actions.Scores, or else add the result of the previous game when it ended
+// on the board or by a pass.
+// startBoard is the first game's starting board; each game starts from its
+// board in actions.StartBoards if there is one, and later games otherwise from
+// the standard starting position. Start boards are seen by the player of the
+// game's first action that is not the opening roll recorded for the player
+// who lost it, which is dropped. A game fragment that starts part way through,
+// or a money game whose stake was raised by automatic doubles, also takes its
+// cube from actions.StartCubes.
+// A move that is not a legal play of its dice ends the reconstruction: its
+// position is the last one returned, so that AnalyzePositionList stops at it
+// with the reason rather than analyzing positions that do not follow.
 func ConvertMatchActionsToPositions(actions MatchActions, startBoard Board, score [2]int, matchLen int) []AnalyzedPosition {
 	positions := make([]AnalyzedPosition, 0, len(actions.Actions))
 
 	currentBoard := startBoard
+	if b, ok := actions.StartBoards[1]; ok {
+		currentBoard = b
+	}
 	cubeValue := 1
 	cubeOwner := -1
 	gameNum := 1
-	moveNum := 0
+	if c, ok := actions.StartCubes[gameNum]; ok {
+		cubeValue, cubeOwner = c.Value, c.Owner
+	}
+	doubledValue, doubledOwner := cubeValue, cubeOwner // Cube before the last double
+	moveNum := 0                                       // Checker plays of the current game
+	if s, ok := actions.Scores[gameNum]; ok {
+		score = s
+	}
 
 	for _, action := range actions.Actions {
 		if action.GameNumber != gameNum {
 			// New game - reset
 			gameNum = action.GameNumber
 			currentBoard = StartingPosition().Board
+			if b, ok := actions.StartBoards[gameNum]; ok {
+				currentBoard = b
+			}
 			cubeValue = 1
 			cubeOwner = -1
+			if c, ok := actions.StartCubes[gameNum]; ok {
+				cubeValue, cubeOwner = c.Value, c.Owner
+			}
+			doubledValue, doubledOwner = cubeValue, cubeOwner
 			moveNum = 0
+			if s, ok := actions.Scores[gameNum]; ok {
+				score = s
+			}
 		}
 
 		pos := AnalyzedPosition{
-			Board:      currentBoard,
-			Turn:       action.Player,
-			Dice:       action.Dice,
-			CubeValue:  cubeValue,
-			CubeOwner:  cubeOwner,
-			Score:      score,
-			GameNumber: action.GameNumber,
-			MoveNumber: action.MoveNumber,
-			Player:     action.Player,
+			Board:       currentBoard,
+			Turn:        action.Player,
+			Dice:        action.Dice,
+			CubeValue:   cubeValue,
+			CubeOwner:   cubeOwner,
+			Score:       score,
+			MatchLength: matchLen,
+			Crawford:    actions.Crawford[action.GameNumber],
+			GameNumber:  action.GameNumber,
+			MoveNumber:  action.MoveNumber,
+			Player:      action.Player,
+			Annotation:  action.Annotation,
+		}
+
+		if action.Move != nil && openingLoserMove(currentBoard, *action.Move, moveNum == 0) {
+			// The opening roll belongs to the player who plays it: the
+			// game's first position is the winner's, with the winner on roll
+			continue
 		}
 
 		if action.Move != nil {
@@ -422,19 +994,41 @@ func ConvertMatchActionsToPositions(actions MatchActions, startBoard Board, scor
 			moveNum++
 			positions = append(positions, pos)
 			// Apply move to update board
-			currentBoard = ApplyMove(currentBoard, *action.Move)
+			var err error
+			if currentBoard, err = ApplyMoveChecked(currentBoard, action.Dice, *action.Move); err != nil {
+				return positions
+			}
+			if _, points, ok := GameResult(currentBoard); ok {
+				// Mover has borne off: the game ends on the board
+				score[action.Player] += points * cubeValue
+			}
 			// Swap sides
 			currentBoard = swapBoard(currentBoard)
 		}
 
 		if action.CubeAction != NoDouble && action.CubeAction != 0 {
 			pos.CubeAction = action.CubeAction
-			positions = append(positions, pos)
 			// Update cube state
-			if action.CubeAction == Double || action.CubeAction == Redouble {
+			switch action.CubeAction {
+			case Double, Redouble:
+				doubledValue, doubledOwner = cubeValue, cubeOwner
 				cubeValue *= 2
 				cubeOwner = 1 - action.Player // Opponent now owns cube
+			case Take, Pass, Beaver:
+				// Responses are analyzed from the doubler's side with the cube before the double
+				pos.Turn = 1 - action.Player
+				pos.CubeValue, pos.CubeOwner = doubledValue, doubledOwner
+				switch action.CubeAction {
+				case Beaver:
+					cubeValue *= 2 // Beavering player keeps the cube
+				case Pass:
+					score[pos.Turn] += doubledValue
+				}
+			case Raccoon:
+				pos.CubeValue, pos.CubeOwner = doubledValue, doubledOwner
+				cubeValue *= 2 // Cube stays with the beavering player
 			}
+			positions = append(positions, pos)
 		}
 	}
 
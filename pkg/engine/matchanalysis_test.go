@@ -1,6 +1,10 @@
 package engine
 
 import (
+	"errors"
+	"math"
+	"math/rand"
+	"reflect"
 	"testing"
 )
 
@@ -17,7 +21,7 @@ func TestAnalyzePositionList(t *testing.T) {
 	state := StartingPosition()
 	
 	// Best move for 31
-	bestMove, _, err := engine.BestMove(state, [2]int{3, 1})
+	bestMove, _, err := engine.BestMove(state, [2]int{3, 1}, DefaultEvalOptions())
 	if err != nil {
 		t.Fatalf("BestMove failed: %v", err)
 	}
@@ -150,3 +154,341 @@ func TestEncodePositionID(t *testing.T) {
 	}
 }
 
+
+func TestMatchTimeline(t *testing.T) {
+	engine, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+
+	// 3-point match: player 1 wins game 1 when player 0 passes a double,
+	// then wins the match when player 0 passes a redouble to 4 in game 2
+	move := Move{From: [4]int8{7, 5, -1, -1}, To: [4]int8{4, 4, -1, -1}}
+	actions := MatchActions{Actions: []MatchAction{
+		{GameNumber: 1, MoveNumber: 1, Player: 0, Dice: [2]int{3, 1}, Move: &move},
+		{GameNumber: 1, MoveNumber: 1, Player: 1, CubeAction: Double},
+		{GameNumber: 1, MoveNumber: 1, Player: 0, CubeAction: Pass},
+		{GameNumber: 2, MoveNumber: 0, Player: 0, CubeAction: Double},
+		{GameNumber: 2, MoveNumber: 0, Player: 1, CubeAction: Take},
+		{GameNumber: 2, MoveNumber: 0, Player: 1, CubeAction: Redouble},
+		{GameNumber: 2, MoveNumber: 0, Player: 0, CubeAction: Pass},
+	}}
+
+	positions := ConvertMatchActionsToPositions(actions, StartingPosition().Board, [2]int{}, 3)
+	if last := positions[len(positions)-1]; last.Score != [2]int{0, 1} || last.MatchLength != 3 {
+		t.Fatalf("game 2 score %v length %d, want [0 1] and 3", last.Score, last.MatchLength)
+	}
+
+	result, err := engine.AnalyzePositionList(positions, DefaultMatchAnalysisOptions())
+	if err != nil {
+		t.Fatalf("AnalyzePositionList failed: %v", err)
+	}
+
+	// Game starts plus one point per decision
+	timeline := result.Timeline
+	if len(timeline) != 9 {
+		t.Fatalf("len(Timeline) = %d, want 9: %+v", len(timeline), timeline)
+	}
+
+	start := getMWCForScore(engine.met, [2]int{0, 0}, 3, 0, false)
+	if timeline[0].Player != -1 || timeline[0].Value != start {
+		t.Errorf("Timeline starts at %+v, want MWC %.4f", timeline[0], start)
+	}
+
+	game2 := getMWCForScore(engine.met, [2]int{0, 1}, 3, 0, false)
+	if timeline[4].GameNumber != 2 || timeline[4].Player != -1 || timeline[4].Value != game2 {
+		t.Errorf("game 2 starts at %+v, want MWC %.4f", timeline[4], game2)
+	}
+	// Passing the first double leaves exactly the next game's MWC
+	if math.Abs(timeline[3].Value-game2) > 1e-9 {
+		t.Errorf("after pass: %.4f, want %.4f", timeline[3].Value, game2)
+	}
+
+	if end := timeline[len(timeline)-1]; end.Value != 0 {
+		t.Errorf("Timeline ends at %.4f, want 0 for player 1's win", end.Value)
+	}
+
+	for i := 1; i < len(timeline); i++ {
+		p := timeline[i]
+		if p.Player < 0 {
+			continue
+		}
+		if math.Abs(p.Delta-(p.Value-timeline[i-1].Value)) > 1e-9 || math.Abs(p.Delta-p.Skill-p.Luck) > 1e-9 {
+			t.Errorf("point %d: delta %.4f does not split into skill %.4f and luck %.4f", i, p.Delta, p.Skill, p.Luck)
+		}
+	}
+}
+
+// playGame returns the actions of a game played with the first legal move
+// for each roll, with a double and take after the given number of moves.
+func playGame(moves, doubleAt int) MatchActions {
+	rng := rand.New(rand.NewSource(3))
+	board := StartingPosition().Board
+	var actions MatchActions
+	for n := 0; n < moves; n++ {
+		player := n % 2
+		if n == doubleAt {
+			actions.Actions = append(actions.Actions,
+				MatchAction{GameNumber: 1, MoveNumber: n, Player: player, CubeAction: Double},
+				MatchAction{GameNumber: 1, MoveNumber: n, Player: 1 - player, CubeAction: Take})
+		}
+		dice := [2]int{rng.Intn(6) + 1, rng.Intn(6) + 1}
+		move := noMove
+		if ml := GenerateMoves(board, dice[0], dice[1]); len(ml.Moves) > 0 {
+			move = ml.Moves[0]
+		}
+		actions.Actions = append(actions.Actions,
+			MatchAction{GameNumber: 1, MoveNumber: n + 1, Player: player, Dice: dice, Move: &move})
+		board = swapBoard(ApplyMove(board, move))
+	}
+	return actions
+}
+
+func TestAnalyzeGameFragment(t *testing.T) {
+	engine, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+
+	full := playGame(20, 6)
+	score := [2]int{3, 2}
+	positions := ConvertMatchActionsToPositions(full, StartingPosition().Board, score, 7)
+	fullResult, err := engine.AnalyzePositionList(positions, DefaultMatchAnalysisOptions())
+	if err != nil {
+		t.Fatalf("AnalyzePositionList of the full game failed: %v", err)
+	}
+
+	// The last 10 moves, starting with the cube at 2 on player 1's side
+	const first = 12
+	start := positions[first]
+	if start.CubeValue != 2 || start.CubeOwner != 1 {
+		t.Fatalf("fragment starts with cube %d owned by %d, want 2 owned by 1", start.CubeValue, start.CubeOwner)
+	}
+	fragment := MatchActions{
+		Actions:     full.Actions[first:],
+		Scores:      map[int][2]int{1: score},
+		StartBoards: map[int]Board{1: start.Board},
+		StartCubes:  map[int]GameCube{1: {Value: 2, Owner: 1}},
+	}
+	fragPositions := ConvertMatchActionsToPositions(fragment, StartingPosition().Board, [2]int{}, 7)
+	if !reflect.DeepEqual(fragPositions, positions[first:]) {
+		t.Fatalf("fragment positions differ from the full game's:\n%+v\n%+v", fragPositions, positions[first:])
+	}
+
+	result, err := engine.AnalyzePositionList(fragPositions, DefaultMatchAnalysisOptions())
+	if err != nil {
+		t.Fatalf("AnalyzePositionList of the fragment failed: %v", err)
+	}
+	if result.TotalMoves != 10 {
+		t.Errorf("TotalMoves = %d, want 10", result.TotalMoves)
+	}
+
+	// Each decision in the fragment has the full game's timeline value
+	fullPoints := fullResult.Timeline[len(fullResult.Timeline)-10:]
+	points := result.Timeline[1:]
+	if len(points) != len(fullPoints) {
+		t.Fatalf("%d timeline points, want %d", len(points), len(fullPoints))
+	}
+	for i := range points {
+		if points[i].MoveNumber != fullPoints[i].MoveNumber || points[i].Value != fullPoints[i].Value || points[i].Skill != fullPoints[i].Skill {
+			t.Errorf("point %d = %+v, full game has %+v", i, points[i], fullPoints[i])
+		}
+	}
+
+	// A move that is not legal from the stated position is reported
+	bad := Move{From: [4]int8{2, -1, -1, -1}, To: [4]int8{0, -1, -1, -1}}
+	fragment.Actions = append([]MatchAction(nil), fragment.Actions...)
+	fragment.Actions[3].Move = &bad
+	fragPositions = ConvertMatchActionsToPositions(fragment, StartingPosition().Board, [2]int{}, 7)
+	_, err = engine.AnalyzePositionList(fragPositions, DefaultMatchAnalysisOptions())
+	var posErr *PositionError
+	if !errors.As(err, &posErr) || posErr.Index != 3 || posErr.MoveNumber != fragment.Actions[3].MoveNumber {
+		t.Errorf("illegal move: error %v, want a PositionError at index 3", err)
+	}
+}
+
+func TestOpeningRollLoser(t *testing.T) {
+	engine, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+
+	game := playGame(8, -1)
+	positions := ConvertMatchActionsToPositions(game, StartingPosition().Board, [2]int{}, 7)
+	want, err := engine.AnalyzePositionList(positions, DefaultMatchAnalysisOptions())
+	if err != nil {
+		t.Fatalf("AnalyzePositionList failed: %v", err)
+	}
+
+	// Player 1 lost the opening roll, recorded as a play of no checkers
+	// before player 0 plays it
+	loser := noMove
+	withLoser := game
+	withLoser.Actions = append([]MatchAction{{GameNumber: 1, MoveNumber: 1, Player: 1, Dice: [2]int{5, 2}, Move: &loser}}, game.Actions...)
+	got := ConvertMatchActionsToPositions(withLoser, StartingPosition().Board, [2]int{}, 7)
+	if !reflect.DeepEqual(got, positions) {
+		t.Fatalf("positions with the loser's opening roll differ:\n%+v\n%+v", got, positions)
+	}
+	if got[0].Turn != 0 || got[0].Player != 0 {
+		t.Errorf("first position has turn %d, player %d, want the winner of the opening roll", got[0].Turn, got[0].Player)
+	}
+
+	// Given to AnalyzePositionList directly it is skipped
+	loserPos := AnalyzedPosition{
+		Board: StartingPosition().Board, Turn: 1, Dice: [2]int{5, 2}, CubeValue: 1, CubeOwner: -1,
+		MatchLength: 7, Move: &loser, GameNumber: 1, MoveNumber: 1, Player: 1,
+	}
+	result, err := engine.AnalyzePositionList(append([]AnalyzedPosition{loserPos}, positions...), DefaultMatchAnalysisOptions())
+	if err != nil {
+		t.Fatalf("AnalyzePositionList with the loser's opening roll failed: %v", err)
+	}
+	if !reflect.DeepEqual(result, want) {
+		t.Errorf("analysis with the loser's opening roll differs:\n%+v\n%+v", result, want)
+	}
+
+	// Later in the game a dance is a play like any other
+	dance := positions[4]
+	dance.Move = &loser
+	if _, err := engine.AnalyzePositionList([]AnalyzedPosition{positions[0], dance}, DefaultMatchAnalysisOptions()); err == nil {
+		t.Error("illegal dance after the opening accepted")
+	}
+}
+
+func TestAnalyzePositionListOnDecision(t *testing.T) {
+	e, err := NewEngine(EngineOptions{SkipWarmup: true})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	// Player 1 doubles in a bear-off and both players then roll
+	var first, last Board
+	first[1][2], first[1][0] = 1, 1
+	first[0][5] = 4
+	last[1][5] = 4
+	last[0][0] = 1
+	positions := []AnalyzedPosition{
+		{Board: first, Turn: 1, CubeValue: 1, CubeOwner: -1, GameNumber: 1, MoveNumber: 28, Player: 1, CubeAction: Double},
+		{Board: first, Turn: 1, Dice: [2]int{2, 1}, CubeValue: 2, CubeOwner: 0, GameNumber: 1, MoveNumber: 29, Player: 1,
+			Move: &Move{From: [4]int8{2, 0, -1, -1}, To: [4]int8{0, -1, -1, -1}}},
+		{Board: last, Turn: 0, Dice: [2]int{6, 6}, CubeValue: 2, CubeOwner: 0, GameNumber: 1, MoveNumber: 30, Player: 0,
+			Move: &Move{From: [4]int8{5, 5, 5, 5}, To: [4]int8{-1, -1, -1, -1}}},
+	}
+
+	var decisions []DecisionResult
+	opts := DefaultMatchAnalysisOptions()
+	opts.IncludeLuck = true
+	opts.OnDecision = func(d DecisionResult) error {
+		decisions = append(decisions, d)
+		return nil
+	}
+	a, err := e.AnalyzePositionList(positions, opts)
+	if err != nil {
+		t.Fatalf("AnalyzePositionList failed: %v", err)
+	}
+
+	if len(decisions) != a.TotalMoves+a.TotalCubeActs || len(decisions) != 3 {
+		t.Fatalf("%d decisions reported, want 3", len(decisions))
+	}
+	for i, d := range decisions {
+		if d.MoveNumber != positions[i].MoveNumber || d.Position != EncodePositionID(positions[i].Board) || d.SkillStr != d.Skill.String() {
+			t.Errorf("decision %d = %+v, want position %d", i, d, i)
+		}
+	}
+	if d := decisions[0]; d.Kind != "cube" || d.Played != "double" || d.Luck != nil {
+		t.Errorf("cube decision = %+v", d)
+	}
+	if d := decisions[2]; d.Kind != "move" || d.Played != FormatMove(*positions[2].Move) || !d.Forced || d.Luck == nil || *d.Luck != a.RollLuck[1].Luck {
+		t.Errorf("last decision = %+v", d)
+	}
+
+	// An error from the callback stops the analysis
+	stop := errors.New("client went away")
+	calls := 0
+	opts.OnDecision = func(DecisionResult) error {
+		calls++
+		return stop
+	}
+	if _, err := e.AnalyzePositionList(positions, opts); err != stop || calls != 1 {
+		t.Errorf("AnalyzePositionList = %v after %d calls, want the callback's error after 1", err, calls)
+	}
+}
+
+func TestAnalyzePositionListDeadCube(t *testing.T) {
+	e, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+
+	// The player on roll bears off their last two checkers with any roll
+	var board Board
+	board[1][0] = 2
+	board[0][5], board[0][7], board[0][12] = 5, 5, 5
+
+	// At 1-away/1-away neither side can double to any purpose, yet the
+	// cube analysis would charge the double its cubeless equity
+	gs := &GameState{Board: board, CubeValue: 1, CubeOwner: -1, MatchLength: 5, Score: [2]int{4, 4}}
+	if skill, err := e.AnalyzeCubeSkillWithConfig(gs, Double, DefaultAnalysisConfig()); err != nil || skill.EquityLoss <= 0 {
+		t.Fatalf("double at 1-away/1-away charged %v, %v; want the phantom error the dead cube check prevents", skill, err)
+	}
+
+	tests := []struct {
+		name      string
+		score     [2]int
+		positions []AnalyzedPosition
+		dead      [2]int // Dead cube decisions per player
+		cube      [2]int // Cube decisions charged per player
+	}{
+		{"1-away/1-away", [2]int{4, 4}, []AnalyzedPosition{
+			{Turn: 0, Player: 0, CubeAction: Double},
+			{Turn: 0, Player: 1, CubeAction: Take},
+		}, [2]int{1, 1}, [2]int{0, 0}},
+		{"post-Crawford leader", [2]int{4, 2}, []AnalyzedPosition{
+			{Turn: 0, Player: 0, CubeAction: Double},
+			{Turn: 0, Player: 1, CubeAction: Pass},
+		}, [2]int{1, 1}, [2]int{0, 0}},
+		{"post-Crawford trailer", [2]int{2, 4}, []AnalyzedPosition{
+			{Turn: 0, Player: 0, CubeAction: Double},
+			{Turn: 0, Player: 1, CubeAction: Take},
+		}, [2]int{0, 0}, [2]int{1, 1}},
+		{"Crawford game", [2]int{2, 4}, []AnalyzedPosition{
+			{Turn: 0, Player: 0, CubeAction: Double, Crawford: true},
+		}, [2]int{1, 0}, [2]int{0, 0}},
+	}
+	for _, tt := range tests {
+		for i := range tt.positions {
+			p := &tt.positions[i]
+			p.Board, p.CubeValue, p.CubeOwner = board, 1, -1
+			p.MatchLength, p.Score, p.GameNumber, p.MoveNumber = 5, tt.score, 1, 10
+		}
+		var notes []DecisionResult
+		opts := DefaultMatchAnalysisOptions()
+		opts.OnDecision = func(d DecisionResult) error {
+			notes = append(notes, d)
+			return nil
+		}
+		result, err := e.AnalyzePositionList(tt.positions, opts)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		for p := 0; p < 2; p++ {
+			ps := result.PlayerStats[p]
+			if ps.DeadCubeDecisions != tt.dead[p] || ps.TotalCube != tt.cube[p] {
+				t.Errorf("%s: player %d has %d dead and %d charged cube decisions, want %d and %d",
+					tt.name, p, ps.DeadCubeDecisions, ps.TotalCube, tt.dead[p], tt.cube[p])
+			}
+			if tt.dead[p] > 0 && (ps.CubeError != 0 || ps.TotalError != 0) {
+				t.Errorf("%s: player %d charged %.4f for a dead cube", tt.name, p, ps.CubeError)
+			}
+		}
+		if dead := tt.dead[0] + tt.dead[1]; dead > 0 {
+			if len(result.CubeErrors) != 0 || result.TotalCubeActs != 0 {
+				t.Errorf("%s: %d cube errors of %d cube actions, want none", tt.name, len(result.CubeErrors), result.TotalCubeActs)
+			}
+			for _, d := range notes {
+				if !d.NotApplicable || d.Note != DeadCubeNote || d.EquityLoss != 0 || d.Skill != SkillNone {
+					t.Errorf("%s: decision %+v, want it not applicable", tt.name, d)
+				}
+			}
+		}
+	}
+}
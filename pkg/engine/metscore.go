@@ -0,0 +1,108 @@
+package engine
+
+import "fmt"
+
+// METGrid is a match equity table as the players' match winning chances by
+// the points they need, up to the table's native length.
+type METGrid struct {
+	Name   string
+	Length int
+
+	// PreCrawford[i][j] is the MWC of a player i+1-away against an opponent
+	// j+1-away before the Crawford game.
+	PreCrawford [][]float64
+
+	// PostCrawford[p][i] is the MWC of player p, i+1-away, against an
+	// opponent 1-away after the Crawford game.
+	PostCrawford [2][]float64
+}
+
+// METScore is the match equity at one score and what it means for the cube
+// there, worked out as cube analysis does for a centered cube of 1 without
+// gammons. Index 0 is the player Away[0] points away, 1 the opponent.
+type METScore struct {
+	Away     [2]int // Points each player needs
+	Crawford bool   // The game is the Crawford game
+	MWC      float64
+
+	// Match winning chance gained by winning a gammon rather than a single
+	// game, and a backgammon rather than a gammon, as a share of the MWC at
+	// stake in a single game (gnubg's gammon price)
+	GammonValue     [2]float64
+	BackgammonValue [2]float64
+
+	CanDouble   [2]bool    // The cube is alive for the player
+	TakePoint   [2]float64 // Winning chances the opponent needs to take the player's initial double
+	DoublePoint [2]float64 // Winning chances from which the player's initial double gains
+}
+
+// METGrid returns the match equity table named name, or the engine's own
+// table if name is empty, up to its native length (11 if it has none).
+func (e *Engine) METGrid(name string) (*METGrid, error) {
+	t, err := e.metTable(name)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		return nil, fmt.Errorf("no match equity table loaded")
+	}
+	n := t.Length
+	if n <= 0 {
+		n = 11
+	}
+	grid := &METGrid{Name: t.Name, Length: n, PreCrawford: make([][]float64, n)}
+	for i := range grid.PreCrawford {
+		grid.PreCrawford[i] = make([]float64, n)
+		for j := range grid.PreCrawford[i] {
+			grid.PreCrawford[i][j] = float64(t.PreCrawford[i][j])
+		}
+	}
+	for p := range grid.PostCrawford {
+		grid.PostCrawford[p] = make([]float64, n)
+		for i := range grid.PostCrawford[p] {
+			grid.PostCrawford[p][i] = float64(t.PostCrawford[p][i])
+		}
+	}
+	return grid, nil
+}
+
+// METScore works out the match equity at the score where the players need
+// away[0] and away[1] points from the match equity table named name, or the
+// engine's own table if name is empty. crawford marks the Crawford game
+// when a player is 1-away; without it such a score is post-Crawford, where
+// only the trailer may double.
+func (e *Engine) METScore(name string, away [2]int, crawford bool) (*METScore, error) {
+	t, err := e.metTable(name)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range away {
+		if a < 1 {
+			return nil, fmt.Errorf("points away must be at least 1, got %d", a)
+		}
+	}
+	length := max(away[0], away[1])
+	score := [2]int{length - away[0], length - away[1]}
+	crawford = crawford && (away[0] == 1 || away[1] == 1)
+
+	s := &METScore{
+		Away:     away,
+		Crawford: crawford,
+		MWC:      getMWCForScore(t, score, length, 0, crawford),
+	}
+	// A gammonless even game: the cube points depend only on the table
+	eval := &Evaluation{WinProb: 0.5}
+	for p := 0; p < 2; p++ {
+		state := &GameState{CubeValue: 1, CubeOwner: -1, Turn: p, MatchLength: length, Score: score, Crawford: crawford}
+		pci := e.cubeInfo(state, t)
+		s.GammonValue[p] = float64(pci.GammonPrice[p])
+		s.BackgammonValue[p] = float64(pci.GammonPrice[p+2])
+		if s.CanDouble[p], _ = e.GetDPEq(&pci); !s.CanDouble[p] {
+			continue
+		}
+		ctx := cubeMatchContext(t, state, eval)
+		s.TakePoint[p] = ctx.TakePoint
+		s.DoublePoint[p] = ctx.DoublePoint()
+	}
+	return s, nil
+}
@@ -0,0 +1,85 @@
+package engine
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMETScoreDefaultTable(t *testing.T) {
+	e, _ := NewEngine(EngineOptions{})
+	grid, err := e.METGrid("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// MWC of the player 1-away against 2-away, read as cube analysis does
+	leader := grid.PreCrawford[0][1]
+	crawfordLeader := 1 - grid.PostCrawford[1][1]
+
+	tests := []struct {
+		name      string
+		away      [2]int
+		crawford  bool
+		mwc       float64
+		canDouble [2]bool
+		takePoint [2]float64
+	}{
+		// At 2-away 2-away a take loses only the game that is passed anyway,
+		// so the taker needs the MWC of trailing 2-away 1-away
+		{"2a2a", [2]int{2, 2}, false, 0.5, [2]bool{true, true}, [2]float64{1 - leader, 1 - leader}},
+		// Without gammons the default table gives the textbook 30% at 3-away 3-away
+		{"3a3a", [2]int{3, 3}, false, 0.5, [2]bool{true, true}, [2]float64{0.3, 0.3}},
+		// In the Crawford game nobody may double
+		{"1a2a Crawford", [2]int{1, 2}, true, crawfordLeader, [2]bool{false, false}, [2]float64{}},
+		// After it the trailer doubles at once and the leader needs half
+		{"1a2a post-Crawford", [2]int{1, 2}, false, leader, [2]bool{false, true}, [2]float64{0, 0.5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := e.METScore("", tt.away, tt.crawford)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if math.Abs(s.MWC-tt.mwc) > 1e-6 {
+				t.Errorf("MWC = %.4f, want %.4f", s.MWC, tt.mwc)
+			}
+			if s.CanDouble != tt.canDouble {
+				t.Errorf("CanDouble = %v, want %v", s.CanDouble, tt.canDouble)
+			}
+			for p := range s.TakePoint {
+				if math.Abs(s.TakePoint[p]-tt.takePoint[p]) > 1e-6 {
+					t.Errorf("TakePoint[%d] = %.4f, want %.4f", p, s.TakePoint[p], tt.takePoint[p])
+				}
+			}
+		})
+	}
+}
+
+func TestMETScoreMatchesCubeAnalysis(t *testing.T) {
+	e, _ := NewEngine(EngineOptions{})
+	s, err := e.METScore("", [2]int{3, 5}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state := &GameState{CubeValue: 1, CubeOwner: -1, MatchLength: 5, Score: [2]int{2, 0}}
+	t5, _ := e.metTable("")
+	ctx := cubeMatchContext(t5, state, &Evaluation{WinProb: 0.5})
+	if ctx.TakePoint != s.TakePoint[0] || ctx.DoublePoint() != s.DoublePoint[0] {
+		t.Errorf("METScore points = %.4f/%.4f, cube analysis %.4f/%.4f",
+			s.TakePoint[0], s.DoublePoint[0], ctx.TakePoint, ctx.DoublePoint())
+	}
+	// The leader's double is harder to take than the trailer's
+	if s.TakePoint[0] <= s.TakePoint[1] {
+		t.Errorf("3-away's double needs %.4f to take, 5-away's %.4f", s.TakePoint[0], s.TakePoint[1])
+	}
+}
+
+func TestMETScoreRejectsZeroAway(t *testing.T) {
+	e, _ := NewEngine(EngineOptions{})
+	if _, err := e.METScore("", [2]int{0, 3}, false); err == nil {
+		t.Error("expected an error for a player 0-away")
+	}
+	if _, err := e.METScore("no-such-table", [2]int{3, 3}, false); err == nil {
+		t.Error("expected an error for an unknown table")
+	}
+}
@@ -1,6 +1,9 @@
 package engine
 
 import (
+	"errors"
+	"fmt"
+
 	"github.com/yourusername/bgengine/internal/positionid"
 )
 
@@ -23,8 +26,20 @@ func GenerateMoves(board Board, n0, n1 int) *MoveList {
 	ml := &MoveList{
 		Moves:      make([]Move, 0, 32), // Pre-allocate for typical case
 		ResultKeys: make([]positionid.PositionKey, 0, 32),
-		OrigBoard:  board,
 	}
+	GenerateMovesInto(ml, board, n0, n1)
+	return ml
+}
+
+// GenerateMovesInto is GenerateMoves generating into ml, reusing its
+// slices, for hot loops that keep a move list between calls. ml may be a
+// zero MoveList.
+func GenerateMovesInto(ml *MoveList, board Board, n0, n1 int) {
+	ml.Moves = ml.Moves[:0]
+	ml.ResultKeys = ml.ResultKeys[:0]
+	ml.MaxMoves = 0
+	ml.MaxPips = 0
+	ml.OrigBoard = board
 
 	// Set up the roll array (4 elements for doubles)
 	anRoll := [4]int{n0, n1, 0, 0}
@@ -44,8 +59,6 @@ func GenerateMoves(board Board, n0, n1 int) *MoveList {
 		anMoves = [8]int{-1, -1, -1, -1, -1, -1, -1, -1}
 		generateMovesSub(ml, anRoll[:], 0, 23, 0, board, anMoves[:], false)
 	}
-
-	return ml
 }
 
 // generateMovesSub is the recursive move generation function
@@ -62,7 +75,7 @@ func generateMovesSub(ml *MoveList, anRoll []int, nMoveDepth int,
 	if board[1][24] > 0 {
 		// Must enter from bar first
 		entryPoint := anRoll[nMoveDepth] - 1
-		if board[0][23-entryPoint] >= 2 {
+		if board[0][entryPoint] >= 2 {
 			// Blocked - can't enter
 			return !fUsed || fPartial
 		}
@@ -194,6 +207,7 @@ func saveMoves(ml *MoveList, cMoves int, cPip int, anMoves []int, board Board, f
 		move.From[i] = int8(anMoves[i*2])
 		move.To[i] = int8(anMoves[i*2+1])
 	}
+	canonicalizeMove(&move, cMoves)
 
 	// Check for duplicate moves (same resulting position)
 	key := positionid.MakePositionKey(positionid.Board(board))
@@ -207,6 +221,24 @@ func saveMoves(ml *MoveList, cMoves int, cPip int, anMoves []int, board Board, f
 	ml.ResultKeys = append(ml.ResultKeys, key)
 }
 
+// canonicalizeMove orders the first n checker moves of m by origin, highest
+// first, and by destination, highest first, for equal origins. This is the
+// order gnubg prints moves in, so a play formats the same whichever dice
+// order generated it. Chained moves keep their order since each later step
+// starts lower.
+func canonicalizeMove(m *Move, n int) {
+	for i := 1; i < n; i++ {
+		for j := i; j > 0; j-- {
+			if m.From[j] < m.From[j-1] ||
+				(m.From[j] == m.From[j-1] && m.To[j] <= m.To[j-1]) {
+				break
+			}
+			m.From[j], m.From[j-1] = m.From[j-1], m.From[j]
+			m.To[j], m.To[j-1] = m.To[j-1], m.To[j]
+		}
+	}
+}
+
 // ApplyMove applies a move to a board and returns the resulting board
 func ApplyMove(board Board, m Move) Board {
 	result := board
@@ -220,6 +252,188 @@ func ApplyMove(board Board, m Move) Board {
 	return result
 }
 
+// MoveError reports why a move is not a legal play of a roll.
+type MoveError struct {
+	Move   Move
+	Dice   [2]int
+	Reason string // e.g. "the 5 point is blocked"
+}
+
+func (e *MoveError) Error() string {
+	move := FormatMove(e.Move)
+	if move == "" {
+		move = "no move"
+	}
+	return fmt.Sprintf("%s is not a legal play of %d-%d: %s", move, e.Dice[0], e.Dice[1], e.Reason)
+}
+
+// ValidateMove returns nil if m is a legal play of dice on board, or
+// reaches the same position as one: the notation of a play need not be the
+// one GenerateMoves gives it. Otherwise it returns a *MoveError saying what
+// is wrong with m, such as a checker moved from an empty point, onto a
+// blocked one or by a number the dice do not show, or a play of fewer dice
+// or of a smaller one than the roll must play. Dice outside 1-6 are an error
+// of their own.
+func ValidateMove(board Board, dice [2]int, m Move) error {
+	for _, d := range dice {
+		if d < 1 || d > 6 {
+			return fmt.Errorf("invalid dice %d-%d", dice[0], dice[1])
+		}
+	}
+	if reason := movePointsFault(m); reason != "" {
+		return &MoveError{Move: m, Dice: dice, Reason: reason}
+	}
+
+	ml := GenerateMoves(board, dice[0], dice[1])
+	after := ApplyMove(board, m)
+	if len(ml.Moves) == 0 && EqualBoards(board, after) {
+		// Dancing, or a play that comes back to where it started
+		return nil
+	}
+	for _, legal := range ml.Moves {
+		if EqualBoards(ApplyMove(board, legal), after) {
+			return nil
+		}
+	}
+	return &MoveError{Move: m, Dice: dice, Reason: moveFault(board, dice, m, ml)}
+}
+
+// ApplyMoveChecked is ApplyMove for a move that has not been generated by
+// the engine, such as one parsed from a match file or given by a user. It
+// returns an error from ValidateMove, and leaves board as it is, if m is not
+// a legal play of dice.
+func ApplyMoveChecked(board Board, dice [2]int, m Move) (Board, error) {
+	if err := ValidateMove(board, dice, m); err != nil {
+		return board, err
+	}
+	return ApplyMove(board, m), nil
+}
+
+// movePointsFault returns why the points of m are not a move in any
+// position, or "" if they could be.
+func movePointsFault(m Move) string {
+	for i := 0; i < 4 && m.From[i] >= 0; i++ {
+		from, to := Point(m.From[i]), Point(m.To[i])
+		switch {
+		case from > BarPoint:
+			return fmt.Sprintf("%d is not a point", from.Number())
+		case to >= from:
+			return fmt.Sprintf("%s/%s moves backwards", from, to)
+		}
+	}
+	return ""
+}
+
+// moveFault returns why m, which reaches none of the positions of ml, is not
+// a legal play of dice on board. It plays m a checker at a time and reports
+// the first step that breaks a rule, or else what the play as a whole
+// leaves unplayed.
+func moveFault(board Board, dice [2]int, m Move, ml *MoveList) string {
+	unused := []int{dice[0], dice[1]}
+	if dice[0] == dice[1] {
+		unused = append(unused, dice[0], dice[0])
+	}
+	played, pips := 0, 0
+	b := board
+	for i := 0; i < 4 && m.From[i] >= 0; i++ {
+		from, to := Point(m.From[i]), Point(m.To[i])
+		step := fmt.Sprintf("%s/%s", from, to)
+		switch {
+		case b[1][from] == 0 && from == BarPoint:
+			return "no checker on the bar"
+		case b[1][from] == 0:
+			return fmt.Sprintf("no checker on the %s point", from)
+		case b[1][BarPoint] > 0 && from != BarPoint:
+			return "checkers on the bar must enter first"
+		case to >= 0 && b[0][23-to] >= 2:
+			return fmt.Sprintf("the %s point is blocked", to)
+		}
+		if to < 0 {
+			for p := Point(homeBoardPoints); p <= BarPoint; p++ {
+				n := int(b[1][p])
+				if p == from {
+					n-- // The checker may reach the home board on the way off
+				}
+				if n > 0 {
+					return "cannot bear off with checkers outside the home board"
+				}
+			}
+		}
+
+		used, err := stepDice(&b, unused, from, to)
+		if err != nil {
+			return fmt.Sprintf("%s: %v", step, err)
+		}
+		for _, d := range used {
+			unused = removeDie(unused, d)
+			pips += d
+		}
+		played += len(used)
+		applySubMove(&b, int(from), int(from-to))
+	}
+
+	switch {
+	case played < ml.MaxMoves && ml.MaxMoves == 2 && dice[0] != dice[1]:
+		return "both dice can be played"
+	case played < ml.MaxMoves:
+		return fmt.Sprintf("%d dice can be played, not %d", ml.MaxMoves, played)
+	case pips < ml.MaxPips:
+		return "the larger die can be played"
+	}
+	return "no legal play reaches the same position"
+}
+
+var errNoDice = errors.New("no unused die or dice move it")
+
+// stepDice returns the dice of unused that move a checker from to to on b:
+// one die, a larger one to bear off from the highest point, or several dice
+// moving the same checker on.
+func stepDice(b *Board, unused []int, from, to Point) ([]int, error) {
+	dist := int(from - to)
+	if to < 0 {
+		dist = int(from) + 1
+	}
+	for _, d := range unused {
+		if d == dist {
+			return []int{d}, nil
+		}
+	}
+	if to < 0 {
+		highest := true
+		for p := from + 1; p < BarPoint; p++ {
+			if b[1][p] > 0 {
+				highest = false
+			}
+		}
+		for _, d := range unused {
+			if d > dist && highest {
+				return []int{d}, nil
+			}
+		}
+		if highest {
+			return nil, errNoDice
+		}
+		return nil, errors.New("a larger die bears off from the highest point first")
+	}
+	if len(unused) == 2 && unused[0]+unused[1] == dist {
+		return unused, nil
+	}
+	if len(unused) > 1 && unused[0] == unused[1] && dist%unused[0] == 0 && dist/unused[0] <= len(unused) {
+		return unused[:dist/unused[0]], nil
+	}
+	return nil, errNoDice
+}
+
+// removeDie returns dice without one die of d.
+func removeDie(dice []int, d int) []int {
+	for i, x := range dice {
+		if x == d {
+			return append(dice[:i:i], dice[i+1:]...)
+		}
+	}
+	return dice
+}
+
 // CountHits counts the number of hits in a move
 func CountHits(board Board, m Move) int8 {
 	hits := int8(0)
@@ -332,21 +546,12 @@ func atoi(s string) int {
 	return n
 }
 
-// parsePointNotation converts point notation to internal index.
-// "bar" = 24 (index), "off" = -1, numbers are 1-indexed points.
+// parsePointNotation converts point notation to internal index (see
+// ParsePoint), or -1 if it is not a point.
 func parsePointNotation(s string) int {
-	// Lowercase comparison
-	if len(s) == 3 && (s[0] == 'b' || s[0] == 'B') && (s[1] == 'a' || s[1] == 'A') && (s[2] == 'r' || s[2] == 'R') {
-		return 24 // Bar is index 24
+	p, ok := ParsePoint(s)
+	if !ok {
+		return int(OffPoint)
 	}
-	if len(s) == 3 && (s[0] == 'o' || s[0] == 'O') && (s[1] == 'f' || s[1] == 'F') && (s[2] == 'f' || s[2] == 'F') {
-		return -1 // Off is -1
-	}
-
-	// Parse as number (1-indexed point)
-	point := atoi(s)
-	if point >= 1 && point <= 24 {
-		return point - 1 // Convert to 0-indexed
-	}
-	return -1
+	return int(p)
 }
@@ -1,6 +1,7 @@
 package engine
 
 import (
+	"reflect"
 	"testing"
 )
 
@@ -63,6 +64,18 @@ func TestGenerateMovesStartingPosition66(t *testing.T) {
 	}
 }
 
+func TestGenerateMovesInto(t *testing.T) {
+	states, dice := randomCorpus(9, 100)
+	var ml MoveList
+	for i, state := range states {
+		GenerateMovesInto(&ml, state.Board, dice[i][0], dice[i][1])
+		want := GenerateMoves(state.Board, dice[i][0], dice[i][1])
+		if !reflect.DeepEqual(ml.Moves, want.Moves) || ml.MaxMoves != want.MaxMoves || ml.MaxPips != want.MaxPips {
+			t.Fatalf("position %d: reused list has %v, want %v", i, ml.Moves, want.Moves)
+		}
+	}
+}
+
 func TestGenerateMovesBarEntry(t *testing.T) {
 	var board Board
 	// Player 1 has a checker on the bar
@@ -99,7 +112,7 @@ func TestGenerateMovesBlocked(t *testing.T) {
 
 	// Player 0 blocks all entry points (1-6)
 	for i := 0; i < 6; i++ {
-		board[0][23-i] = 2 // Block points 1-6 from player 1's perspective
+		board[0][i] = 2 // Block the opponent's home board points, where player 1 enters
 	}
 
 	ml := GenerateMoves(board, 3, 1)
@@ -110,6 +123,36 @@ func TestGenerateMovesBlocked(t *testing.T) {
 	}
 }
 
+// TestGenerateMovesBarEntryMirroredPoint is a regression test: entering
+// with a d was checked against the opponent's point at index 24-d, the
+// mirror of the entry point, so a far point blocked entry and the made
+// entry point did not.
+func TestGenerateMovesBarEntryMirroredPoint(t *testing.T) {
+	// A 3 enters on the opponent's 3-point, which is index 2 of their side
+	var board Board
+	board[1][24] = 1
+	board[1][5] = 5
+	board[0][2] = 2
+
+	if ml := GenerateMoves(board, 3, 3); len(ml.Moves) != 0 {
+		t.Errorf("Expected no entry onto the opponent's made 3-point, got %d moves", len(ml.Moves))
+	}
+
+	// A made point on the far side of the board does not block entry
+	board[0][2] = 0
+	board[0][21] = 2
+
+	ml := GenerateMoves(board, 3, 3)
+	if len(ml.Moves) == 0 {
+		t.Fatal("Expected entry on the open 3-point")
+	}
+	for i, m := range ml.Moves {
+		if m.From[0] != 24 || m.To[0] != 21 {
+			t.Errorf("Move %d: first step %d->%d, want bar->21", i, m.From[0], m.To[0])
+		}
+	}
+}
+
 func TestGenerateMovesBearoff(t *testing.T) {
 	var board Board
 	// Player 1 has all checkers in home board (points 0-5)
@@ -164,6 +207,63 @@ func TestNoDuplicateMoves(t *testing.T) {
 	}
 }
 
+func TestDistinctPlayCounts(t *testing.T) {
+	// Distinct resulting positions from the starting position
+	tests := []struct {
+		dice [2]int
+		want int
+	}{
+		{[2]int{6, 6}, 11},
+		{[2]int{3, 3}, 73},
+		{[2]int{5, 5}, 4},
+	}
+
+	for _, tt := range tests {
+		if got := len(GenerateMoves(startingBoard(), tt.dice[0], tt.dice[1]).Moves); got != tt.want {
+			t.Errorf("%d-%d: %d moves, want %d", tt.dice[0], tt.dice[1], got, tt.want)
+		}
+	}
+}
+
+func TestMovesDistinctAndCanonical(t *testing.T) {
+	states, _ := randomCorpus(5, 100)
+	for _, state := range states {
+		for d0 := 1; d0 <= 6; d0++ {
+			for d1 := 1; d1 <= d0; d1++ {
+				ml := GenerateMoves(state.Board, d0, d1)
+				for i, m := range ml.Moves {
+					for j := 1; j < 4 && m.From[j] >= 0; j++ {
+						if m.From[j] > m.From[j-1] ||
+							(m.From[j] == m.From[j-1] && m.To[j] > m.To[j-1]) {
+							t.Fatalf("%d-%d: move %s is not in canonical order", d0, d1, FormatMove(m))
+						}
+					}
+
+					result := ApplyMove(state.Board, m)
+					for _, other := range ml.Moves[:i] {
+						if EqualBoards(result, ApplyMove(state.Board, other)) {
+							t.Fatalf("%d-%d: %s and %s give the same position",
+								d0, d1, FormatMove(other), FormatMove(m))
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestCanonicalMoveFormat(t *testing.T) {
+	// 3-1 split: generated with either die first, printed highest point first
+	for _, m := range GenerateMoves(startingBoard(), 3, 1).Moves {
+		if m.From[0] == 12 && m.From[1] == 23 {
+			t.Errorf("move %s lists the 13-point first", FormatMove(m))
+		}
+		if FormatMove(m) == "24/21 24/23" {
+			t.Errorf("move %s lists the lower destination first", FormatMove(m))
+		}
+	}
+}
+
 func boardToString(b Board) string {
 	// Simple string representation for comparison
 	result := ""
@@ -175,3 +275,86 @@ func boardToString(b Board) string {
 	return result
 }
 
+func TestValidateMove(t *testing.T) {
+	start := startingBoard()
+	onBar := start
+	onBar[1][12]--
+	onBar[1][BarPoint]++
+	var bearoff Board
+	bearoff[0][23] = 15
+	bearoff[1][0] = 1
+	bearoff[1][4] = 2
+
+	tests := []struct {
+		board  Board
+		dice   [2]int
+		move   string
+		reason string // "" for a legal play
+	}{
+		{start, [2]int{3, 1}, "8/5 6/5", ""},
+		{start, [2]int{3, 1}, "13/9", ""},
+		{start, [2]int{3, 1}, "13/10 10/9", ""},
+		{start, [2]int{3, 1}, "7/4 6/5", "no checker on the 7 point"},
+		{start, [2]int{3, 1}, "13/12 8/5", "the 12 point is blocked"},
+		{onBar, [2]int{3, 1}, "8/5 6/5", "checkers on the bar must enter first"},
+		{start, [2]int{3, 1}, "6/off 6/5", "cannot bear off with checkers outside the home board"},
+		{start, [2]int{3, 1}, "24/18", "24/18: no unused die or dice move it"},
+		{start, [2]int{3, 1}, "8/5", "both dice can be played"},
+		{start, [2]int{3, 1}, "", "both dice can be played"},
+		{start, [2]int{3, 1}, "8/9 6/5", "8/9 moves backwards"},
+		{bearoff, [2]int{6, 5}, "5/off 5/off", ""},
+		{bearoff, [2]int{6, 5}, "1/off 5/off", "1/off: a larger die bears off from the highest point first"},
+	}
+	for _, tt := range tests {
+		m, err := ParseMove(tt.move)
+		if err != nil {
+			t.Fatalf("ParseMove(%q): %v", tt.move, err)
+		}
+		err = ValidateMove(tt.board, tt.dice, m)
+		if tt.reason == "" {
+			if err != nil {
+				t.Errorf("%q with %v: %v, want legal", tt.move, tt.dice, err)
+			}
+			continue
+		}
+		moveErr, ok := err.(*MoveError)
+		if !ok {
+			t.Errorf("%q with %v: error %v, want a *MoveError", tt.move, tt.dice, err)
+			continue
+		}
+		if moveErr.Reason != tt.reason {
+			t.Errorf("%q with %v: reason %q, want %q", tt.move, tt.dice, moveErr.Reason, tt.reason)
+		}
+	}
+
+	if err := ValidateMove(start, [2]int{7, 1}, Move{}); err == nil {
+		t.Error("dice 7-1 validated")
+	}
+
+	// Dancing is the only play against a closed board
+	closed := onBar
+	for p := 18; p < 24; p++ {
+		closed[0][23-p] = 2
+	}
+	if err := ValidateMove(closed, [2]int{3, 1}, noMove); err != nil {
+		t.Errorf("dancing: %v", err)
+	}
+}
+
+func TestApplyMoveChecked(t *testing.T) {
+	start := startingBoard()
+	m, _ := ParseMove("8/5 6/5")
+	after, err := ApplyMoveChecked(start, [2]int{3, 1}, m)
+	if err != nil || after != ApplyMove(start, m) {
+		t.Errorf("legal move: %v, board %v", err, after)
+	}
+
+	m, _ = ParseMove("7/4 6/5")
+	after, err = ApplyMoveChecked(start, [2]int{3, 1}, m)
+	if err == nil || after != start {
+		t.Errorf("illegal move: error %v, board changed %v", err, after != start)
+	}
+	if want := "7/4 6/5 is not a legal play of 3-1: no checker on the 7 point"; err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err, want)
+	}
+}
@@ -0,0 +1,103 @@
+package engine
+
+// MoveTag labels what a move does, for annotating move lists.
+type MoveTag string
+
+const (
+	TagHit        MoveTag = "hit"         // Sends at least one opponent checker to the bar
+	TagPoint      MoveTag = "point"       // Makes a new point outside the opponent's home board
+	TagAnchor     MoveTag = "anchor"      // Makes a new point in the opponent's home board
+	TagEscape     MoveTag = "escape"      // Brings a back checker past the opponent's blockade
+	TagSlot       MoveTag = "slot"        // Puts a single checker on an empty point in the home board
+	TagBreakPrime MoveTag = "break_prime" // Shortens a prime of four or more points
+)
+
+// moveTagOrder is the order tags are reported in.
+var moveTagOrder = []MoveTag{TagHit, TagPoint, TagAnchor, TagEscape, TagSlot, TagBreakPrime}
+
+// ClassifyMove returns the tags that describe move m played from before, with
+// the player on roll in before[1]. A move can do several things at once (a
+// hit and cover both hits and makes a point), so every tag that applies is
+// returned, in a fixed order; a move that does none of them has no tags.
+func ClassifyMove(before Board, m Move) []MoveTag {
+	after := ApplyMove(before, m)
+	d := DiffBoards(before, after)
+
+	found := make(map[MoveTag]bool)
+	if d.Hit[0] > 0 {
+		found[TagHit] = true
+	}
+	for _, point := range d.PointsMade[1] {
+		if point > 18 {
+			found[TagAnchor] = true
+		} else {
+			found[TagPoint] = true
+		}
+	}
+	for _, point := range d.BlotsCreated[1] {
+		// Hitting loose is not slotting
+		if point <= 6 && before[1][point-1] == 0 && before[0][24-point] == 0 {
+			found[TagSlot] = true
+		}
+	}
+	// Escapes and primes only matter while the sides can still meet
+	if hasContact(before) {
+		if escapes(before, after) {
+			found[TagEscape] = true
+		}
+		if d.PrimeBefore[1] >= 4 && d.PrimeAfter[1] < d.PrimeBefore[1] {
+			found[TagBreakPrime] = true
+		}
+	}
+
+	var tags []MoveTag
+	for _, tag := range moveTagOrder {
+		if found[tag] {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// escapes reports whether a move took a checker of the player on roll from
+// behind the opponent's blockade to in front of it. The front of the blockade
+// is the opponent's highest made point among his points 7-12, or his home
+// board when he has none there; a checker is behind it when it is on that
+// point or further back.
+func escapes(before, after Board) bool {
+	// Our index of the opponent's point 12 is 12, of his point 1 is 23
+	blockade := 18
+	for i := 12; i < 18; i++ {
+		if before[0][23-i] >= 2 {
+			blockade = i
+			break
+		}
+	}
+
+	behind := func(b Board) int {
+		n := 0
+		for i := blockade; i <= 24; i++ {
+			n += int(b[1][i])
+		}
+		return n
+	}
+	return behind(after) < behind(before)
+}
+
+// hasContact reports whether the sides have not yet passed each other.
+func hasContact(board Board) bool {
+	if board[0][24] > 0 {
+		return true
+	}
+	back := 24
+	for back >= 0 && board[1][back] == 0 {
+		back--
+	}
+	// The opponent's checker furthest forward from our side
+	for i := 0; i < 24; i++ {
+		if board[0][23-i] > 0 {
+			return i < back
+		}
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestClassifyMove(t *testing.T) {
+	start := StartingPosition().Board
+
+	// The opponent's points are numbered from his side: his 20-point is our
+	// 5-point
+	blot5 := boardWith(map[int]uint8{24: 2, 13: 5, 8: 3, 6: 5}, map[int]uint8{24: 2, 13: 4, 8: 3, 6: 5, 20: 1})
+	blot7 := boardWith(map[int]uint8{24: 2, 13: 5, 8: 3, 6: 5}, map[int]uint8{24: 2, 13: 4, 8: 3, 6: 5, 18: 1})
+	blot14 := boardWith(map[int]uint8{24: 2, 13: 5, 8: 3, 6: 5}, map[int]uint8{24: 2, 13: 4, 8: 3, 6: 5, 11: 1})
+	blots35 := boardWith(map[int]uint8{24: 2, 13: 5, 8: 3, 6: 5}, map[int]uint8{24: 2, 13: 3, 8: 3, 6: 5, 20: 1, 22: 1})
+	onBar := boardWith(map[int]uint8{25: 1, 24: 1, 13: 5, 8: 3, 6: 5}, map[int]uint8{24: 2, 13: 5, 8: 3, 6: 5})
+	barAndBlot := boardWith(map[int]uint8{25: 1, 22: 1, 13: 5, 8: 3, 6: 5}, map[int]uint8{24: 2, 13: 5, 8: 3, 6: 5})
+	barHit := boardWith(map[int]uint8{25: 1, 24: 1, 13: 5, 8: 3, 6: 5}, map[int]uint8{24: 2, 13: 4, 8: 3, 6: 5, 3: 1})
+	prime5 := boardWith(map[int]uint8{8: 2, 7: 2, 6: 2, 5: 2, 4: 2, 13: 5}, map[int]uint8{24: 2, 13: 5, 8: 3, 6: 5})
+	prime3 := boardWith(map[int]uint8{8: 2, 7: 2, 6: 2, 13: 9}, map[int]uint8{24: 2, 13: 5, 8: 3, 6: 5})
+	race := boardWith(map[int]uint8{19: 1, 6: 7, 5: 7}, map[int]uint8{5: 8, 4: 7})
+	bearoff := boardWith(map[int]uint8{6: 2, 5: 2, 4: 2, 3: 2}, map[int]uint8{6: 5, 5: 5})
+	blotOn5 := boardWith(map[int]uint8{24: 2, 13: 5, 8: 3, 6: 4, 5: 1}, map[int]uint8{24: 2, 13: 5, 8: 3, 6: 5})
+
+	tests := []struct {
+		name  string
+		board Board
+		move  string
+		want  []MoveTag
+	}{
+		// Opening moves
+		{"31 makes the 5-point", start, "8/5 6/5", []MoveTag{TagPoint}},
+		{"42 makes the 4-point", start, "8/4 6/4", []MoveTag{TagPoint}},
+		{"61 makes the bar point", start, "13/7 8/7", []MoveTag{TagPoint}},
+		{"64 makes the 2-point", start, "8/2 6/2", []MoveTag{TagPoint}},
+		{"64 runs", start, "24/14", []MoveTag{TagEscape}},
+		{"65 runs all the way", start, "24/13", []MoveTag{TagEscape}},
+		{"62 splits to the bar point", start, "24/18 13/11", nil},
+		{"21 slots", start, "13/11 6/5", []MoveTag{TagSlot}},
+		{"21 splits", start, "24/23 13/11", nil},
+		{"43 builds", start, "13/9 13/10", nil},
+		{"63 runs to the 15", start, "24/15", []MoveTag{TagEscape}},
+		{"66 makes both bar points", start, "24/18 24/18 13/7 13/7", []MoveTag{TagPoint}},
+		{"44 makes an anchor and a point", start, "24/20 24/20 13/9 13/9", []MoveTag{TagPoint, TagAnchor}},
+		{"33 makes the 21 anchor", start, "24/21 24/21 13/10 13/10", []MoveTag{TagPoint, TagAnchor}},
+		{"6 to the outfield slots nothing", start, "13/7", nil},
+		{"adding to a made point", start, "8/6", nil},
+
+		// Hits
+		{"point on the blot", blot5, "8/5* 6/5", []MoveTag{TagHit, TagPoint}},
+		{"hit loose is not a slot", blot5, "6/5* 13/11", []MoveTag{TagHit}},
+		{"hit and continue to an empty home point", blot7, "13/7* 7/5", []MoveTag{TagHit, TagSlot}},
+		{"hit on the bar point", blot7, "13/7* 8/7", []MoveTag{TagHit, TagPoint}},
+		{"hit while escaping", blot14, "24/14*", []MoveTag{TagHit, TagEscape}},
+		{"double hit", blots35, "8/5* 5/3*", []MoveTag{TagHit}},
+		{"hit from the bar", barHit, "bar/22*", []MoveTag{TagHit}},
+
+		// Entering from the bar
+		{"enter and stay back", onBar, "bar/20", nil},
+		{"enter and escape", onBar, "bar/22 22/16", []MoveTag{TagEscape}},
+		{"enter on own blot", barAndBlot, "bar/22", []MoveTag{TagAnchor}},
+
+		// Primes
+		{"breaking a 5-prime", prime5, "8/2 8/3", []MoveTag{TagSlot, TagBreakPrime}},
+		{"moving off the front of a prime", prime5, "13/3 13/3", []MoveTag{TagPoint}},
+		{"breaking a 3-prime", prime3, "8/2 8/3", []MoveTag{TagSlot}},
+
+		// Covering and races
+		{"covering a home blot", blotOn5, "8/5", []MoveTag{TagPoint}},
+		{"slotting the 4-point", start, "6/4", []MoveTag{TagSlot}},
+		{"race leaves no back checkers", race, "19/13", nil},
+		{"bearing off a prime", bearoff, "6/off 5/off", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := ParseMove(tt.move)
+			if err != nil {
+				t.Fatalf("ParseMove(%q): %v", tt.move, err)
+			}
+			if got := ClassifyMove(tt.board, m); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ClassifyMove(%s) = %v, want %v", tt.move, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassifyMoveLegalMoves(t *testing.T) {
+	// Every tag on a legal move is backed by the board
+	start := StartingPosition().Board
+	for d1 := 1; d1 <= 6; d1++ {
+		for d2 := d1; d2 <= 6; d2++ {
+			for _, m := range GenerateMoves(start, d1, d2).Moves {
+				d := DiffBoards(start, ApplyMove(start, m))
+				for _, tag := range ClassifyMove(start, m) {
+					if tag == TagHit && d.Hit[0] == 0 {
+						t.Errorf("%d%d %v: hit without a hit", d1, d2, m.From)
+					}
+					if (tag == TagPoint || tag == TagAnchor) && len(d.PointsMade[1]) == 0 {
+						t.Errorf("%d%d %v: %s without a new point", d1, d2, m.From, tag)
+					}
+				}
+			}
+		}
+	}
+}
@@ -1,16 +1,56 @@
 package engine
 
 import (
+	"errors"
+	"time"
+
 	"github.com/yourusername/bgengine/internal/positionid"
 )
 
 // EvalOptions controls evaluation behavior
 type EvalOptions struct {
-	Plies    int  // Number of plies to search (0 = neural net only)
-	Cubeful  bool // Include cube equity (not yet implemented)
-	UsePrune bool // Use pruning neural nets to filter moves
+	Plies     int           // Number of plies to search (0 = neural net only); the maximum when TimeLimit is set
+	Cubeful   bool          // Rank moves by cubeful equity (see MoveWithEval.CubefulEquity)
+	UsePrune  bool          // Use pruning neural nets to filter moves
+	TimeLimit time.Duration // Deepen one ply at a time until this runs out (0 = no limit)
+
+	// CubeEfficiency sets the money cube efficiency by position class
+	// (nil = DefaultCubeEfficiency).
+	CubeEfficiency *CubeEfficiency
+
+	// Noise is the standard deviation of Gaussian noise added to each output
+	// of a move's evaluation before moves are ranked, to weaken the engine
+	// (see BotLevels). With Deterministic the noise is drawn from the
+	// position after the move, so the same position always gets the same
+	// error; otherwise it differs on every call.
+	Noise         float64
+	Deterministic bool
+
+	// RankMargin is the safety margin of RankMovesWithOptions: it stops
+	// evaluating moves at Plies once no move left could gain more than the
+	// largest gain over its 0-ply equity seen so far plus RankMargin
+	// (0 = DefaultRankMargin).
+	RankMargin float64
+
+	// Timing, if not nil, is added to with where the time of the call went
+	// (see Timing). Evaluations without one read no clocks.
+	Timing *Timing
+
+	// PV, if not nil, is set to the principal variation of a lookahead
+	// evaluation (see PrincipalVariation): that of the position for
+	// EvaluatePliedWithOptions, and that of the best move for the move
+	// analyses, which also record each move's in MoveWithEval.PV. At 0 plies
+	// there is none and it is left empty. Evaluations without one keep no
+	// record of the search.
+	PV *PrincipalVariation
 }
 
+// DefaultTimedPlies is the deepest search under a time limit when EvalOptions.Plies is 0.
+const DefaultTimedPlies = 2
+
+// errDeadline aborts a lookahead that ran past its time limit.
+var errDeadline = errors.New("evaluation deadline exceeded")
+
 // DefaultEvalOptions returns sensible defaults for evaluation
 func DefaultEvalOptions() EvalOptions {
 	return EvalOptions{
@@ -20,12 +60,61 @@ func DefaultEvalOptions() EvalOptions {
 	}
 }
 
-// EvaluatePliedWithOptions evaluates with explicit options
+// EvaluatePliedWithOptions evaluates with explicit options.
+// With a TimeLimit it evaluates at 0 plies, then 1, 2, ... up to opts.Plies
+// (DefaultTimedPlies if 0) and returns the deepest evaluation finished in time.
 func (e *Engine) EvaluatePliedWithOptions(state *GameState, opts EvalOptions) (*Evaluation, error) {
+	defer opts.Timing.total(opts.Timing.start())
+	if opts.PV != nil {
+		*opts.PV = PrincipalVariation{}
+	}
+	if opts.TimeLimit > 0 {
+		eval, _, err := e.evaluateTimed(state, opts)
+		return eval, err
+	}
 	if opts.Plies <= 0 {
-		return e.Evaluate(state)
+		return e.evaluate(state, opts.Timing)
+	}
+	return e.evaluateNPlyPV(state, opts.Plies, opts.UsePrune, time.Time{}, opts.Timing, opts.PV)
+}
+
+// evaluateTimed is the iterative deepening loop behind EvaluatePliedWithOptions.
+// It also returns the ply of the evaluation.
+func (e *Engine) evaluateTimed(state *GameState, opts EvalOptions) (*Evaluation, int, error) {
+	deadline := time.Now().Add(opts.TimeLimit)
+	maxPlies := timedPlies(opts)
+
+	best, err := e.evaluate(state, opts.Timing)
+	if err != nil {
+		return nil, 0, err
+	}
+	ply := 0
+	var pv *PrincipalVariation
+	if opts.PV != nil {
+		pv = new(PrincipalVariation)
+	}
+	for p := 1; p <= maxPlies && time.Now().Before(deadline); p++ {
+		eval, err := e.evaluateNPlyPV(state, p, opts.UsePrune, deadline, opts.Timing, pv)
+		if errors.Is(err, errDeadline) {
+			break
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		best, ply = eval, p
+		if pv != nil {
+			*opts.PV = *pv
+		}
 	}
-	return e.evaluateNPlyWithPrune(state, opts.Plies, opts.UsePrune)
+	return best, ply, nil
+}
+
+// timedPlies returns the deepest search allowed under a time limit.
+func timedPlies(opts EvalOptions) int {
+	if opts.Plies > 0 {
+		return opts.Plies
+	}
+	return DefaultTimedPlies
 }
 
 // EvaluatePlied evaluates a position with n-ply lookahead
@@ -46,13 +135,30 @@ func (e *Engine) EvaluatePlied(state *GameState, plies int) (*Evaluation, error)
 
 // evaluateNPlyWithPrune performs n-ply lookahead with optional move pruning
 func (e *Engine) evaluateNPlyWithPrune(state *GameState, plies int, usePrune bool) (*Evaluation, error) {
+	return e.evaluateNPly(state, plies, usePrune, time.Time{}, nil)
+}
+
+// evaluateNPly is evaluateNPlyWithPrune with a deadline, adding to t if not
+// nil; a zero deadline never expires. Past the deadline it returns errDeadline.
+func (e *Engine) evaluateNPly(state *GameState, plies int, usePrune bool, deadline time.Time, t *Timing) (*Evaluation, error) {
+	return e.evaluateNPlyPV(state, plies, usePrune, deadline, t, nil)
+}
+
+// evaluateNPlyPV is evaluateNPly setting pv, if not nil, to the principal
+// variation of the evaluation.
+func (e *Engine) evaluateNPlyPV(state *GameState, plies int, usePrune bool, deadline time.Time, t *Timing, pv *PrincipalVariation) (*Evaluation, error) {
 	// Accumulate weighted probabilities
 	var sumProbs [5]float64
 	totalWeight := 0.0
+	rolls := newPVRolls(pv)
 
 	// Loop over all 21 possible dice combinations
 	for d1 := 1; d1 <= 6; d1++ {
 		for d2 := 1; d2 <= d1; d2++ {
+			if !deadline.IsZero() && time.Now().After(deadline) {
+				return nil, errDeadline
+			}
+
 			// Weight: doubles occur 1/36, non-doubles occur 2/36
 			weight := 2.0
 			if d1 == d2 {
@@ -60,27 +166,35 @@ func (e *Engine) evaluateNPlyWithPrune(state *GameState, plies int, usePrune boo
 			}
 
 			// Generate moves for this roll
-			ml := GenerateMoves(state.Board, d1, d2)
+			ml := generateMovesTimed(state.Board, [2]int{d1, d2}, t)
 
 			var eval *Evaluation
 			var err error
+			var roll *PVRoll
+			if rolls != nil {
+				rolls = append(rolls, PVRoll{Dice: [2]int{d1, d2}, Probability: weight / 36, Move: noMove, Ply: plies - 1})
+				roll = &rolls[len(rolls)-1]
+			}
 
 			if len(ml.Moves) == 0 {
 				// No legal moves - evaluate current position
-				eval, err = e.evaluateAtPlyWithPrune(state, plies-1, usePrune)
+				eval, err = e.evaluateAtPly(state, plies-1, usePrune, deadline, t, nil)
 			} else {
 				// Apply pruning if enabled and we have enough moves
 				moves := ml.Moves
 				if usePrune && len(moves) > MinPruneMoves {
-					moves = e.pruneMoves(state, moves)
+					moves = e.pruneMoves(state, moves, t)
 				}
 				// Find the best move and evaluate resulting position
-				eval, err = e.findBestMoveEvalWithPrune(state, moves, plies-1, usePrune)
+				eval, err = e.findBestMoveEval(state, moves, plies-1, usePrune, deadline, t, roll)
 			}
 
 			if err != nil {
 				return nil, err
 			}
+			if roll != nil {
+				roll.Eval = *eval
+			}
 
 			// Accumulate weighted probabilities
 			sumProbs[0] += weight * eval.WinProb
@@ -99,6 +213,7 @@ func (e *Engine) evaluateNPlyWithPrune(state *GameState, plies int, usePrune boo
 		WinBG:   sumProbs[2] / totalWeight,
 		LoseG:   sumProbs[3] / totalWeight,
 		LoseBG:  sumProbs[4] / totalWeight,
+		Source:  e.Source(state),
 	}
 
 	// Calculate equity
@@ -106,20 +221,33 @@ func (e *Engine) evaluateNPlyWithPrune(state *GameState, plies int, usePrune boo
 		result.WinG - result.LoseG +
 		result.WinBG - result.LoseBG
 
+	if pv != nil {
+		pv.set(state, plies, result.Equity, rolls)
+	}
 	return result, nil
 }
 
-// evaluateAtPlyWithPrune evaluates position at specified ply depth with optional pruning
-func (e *Engine) evaluateAtPlyWithPrune(state *GameState, plies int, usePrune bool) (*Evaluation, error) {
+// evaluateAtPly evaluates position at specified ply depth with optional pruning.
+// With lookahead that is not the book's, it sets pv, if not nil, to the
+// principal variation.
+func (e *Engine) evaluateAtPly(state *GameState, plies int, usePrune bool, deadline time.Time, t *Timing, pv *PrincipalVariation) (*Evaluation, error) {
 	if plies <= 0 {
 		// Use cached evaluation for leaf nodes (most cache hits happen here)
-		return e.EvaluateCached(state, 0)
+		return e.evaluateCached(state, 0, t)
+	}
+	// The book's positions are evaluated with pruning
+	if usePrune {
+		if eval, ok := e.bookEval(state, plies, t); ok {
+			return eval, nil
+		}
 	}
-	return e.evaluateNPlyWithPrune(state, plies, usePrune)
+	return e.evaluateNPlyPV(state, plies, usePrune, deadline, t, pv)
 }
 
-// findBestMoveEvalWithPrune finds the best move and returns its evaluation
-func (e *Engine) findBestMoveEvalWithPrune(state *GameState, moves []Move, plies int, usePrune bool) (*Evaluation, error) {
+// findBestMoveEval finds the best move and returns its evaluation. If roll
+// is not nil it records the best move in it, with the principal variation
+// after it when there is lookahead.
+func (e *Engine) findBestMoveEval(state *GameState, moves []Move, plies int, usePrune bool, deadline time.Time, t *Timing, roll *PVRoll) (*Evaluation, error) {
 	var bestEval *Evaluation
 	bestEquity := float64(-1000)
 
@@ -132,17 +260,25 @@ func (e *Engine) findBestMoveEvalWithPrune(state *GameState, moves []Move, plies
 
 		// Create state for evaluation from opponent's perspective
 		evalState := &GameState{
-			Board:       swappedBoard,
-			Turn:        1 - state.Turn,
-			CubeValue:   state.CubeValue,
-			CubeOwner:   state.CubeOwner,
-			MatchLength: state.MatchLength,
-			Score:       state.Score,
-			Crawford:    state.Crawford,
+			Board:         swappedBoard,
+			Turn:          1 - state.Turn,
+			CubeValue:     state.CubeValue,
+			CubeOwner:     state.CubeOwner,
+			MatchLength:   state.MatchLength,
+			Score:         state.Score,
+			Crawford:      state.Crawford,
+			TotalCheckers: swapTotals(state.TotalCheckers),
 		}
 
 		// Evaluate at specified ply
-		eval, err := e.evaluateAtPlyWithPrune(evalState, plies, usePrune)
+		var next *PrincipalVariation
+		if roll != nil && plies > 0 {
+			next = new(PrincipalVariation)
+		}
+		eval, err := e.evaluateAtPly(evalState, plies, usePrune, deadline, t, next)
+		if errors.Is(err, errDeadline) {
+			return nil, err
+		}
 		if err != nil {
 			continue
 		}
@@ -153,12 +289,18 @@ func (e *Engine) findBestMoveEvalWithPrune(state *GameState, moves []Move, plies
 		if inverted.Equity > bestEquity {
 			bestEquity = inverted.Equity
 			bestEval = inverted
+			if roll != nil {
+				roll.Move, roll.Next = m, nil
+				if next != nil && next.Rolls != nil {
+					roll.Next = next
+				}
+			}
 		}
 	}
 
 	if bestEval == nil {
 		// Fallback to 0-ply evaluation
-		return e.Evaluate(state)
+		return e.evaluate(state, t)
 	}
 
 	return bestEval, nil
@@ -214,7 +214,7 @@ func TestPruneMoves(t *testing.T) {
 	}
 
 	// Test pruning
-	pruned := engine.pruneMoves(state, ml.Moves)
+	pruned := engine.pruneMoves(state, ml.Moves, nil)
 	t.Logf("Pruned to %d moves", len(pruned))
 
 	// Should have reduced the number of moves
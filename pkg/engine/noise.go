@@ -0,0 +1,118 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+)
+
+// BotLevel is a named playing strength, set by the evaluation noise a bot
+// plays with.
+type BotLevel struct {
+	Name  string
+	Noise float64 // EvalOptions.Noise for the level
+}
+
+// BotLevels lists the named playing strengths, weakest first.
+var BotLevels = []BotLevel{
+	{"beginner", 0.06},
+	{"intermediate", 0.03},
+	{"expert", 0.005},
+	{"world-class", 0},
+}
+
+// ParseBotLevel returns the playing strength with the given name.
+func ParseBotLevel(name string) (BotLevel, error) {
+	key := strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), "_", "-")
+	names := make([]string, len(BotLevels))
+	for i, level := range BotLevels {
+		if level.Name == key {
+			return level, nil
+		}
+		names[i] = level.Name
+	}
+	return BotLevel{}, fmt.Errorf("unknown bot level %q (available: %s)", name, strings.Join(names, ", "))
+}
+
+// Options returns evaluation options that play at the level, with the
+// deterministic noise gnubg's bots use.
+func (l BotLevel) Options() EvalOptions {
+	opts := DefaultEvalOptions()
+	opts.Noise = l.Noise
+	opts.Deterministic = true
+	return opts
+}
+
+// moveNoise returns the noise to add to the mover's outputs
+// [win, winG, winBG, loseG, loseBG] after playing m, as gnubg adds noise to
+// its evaluations: each output gets Gaussian noise of standard deviation
+// opts.Noise. With opts.Deterministic the noise is drawn from the resulting
+// position, so a position always gets the same error.
+func moveNoise(state *GameState, m Move, opts EvalOptions) [5]float64 {
+	var noise [5]float64
+	if opts.Noise <= 0 {
+		return noise
+	}
+	var key uint64
+	if opts.Deterministic {
+		key = boardHash(ApplyMove(state.Board, m))
+	} else {
+		key = rand.Uint64()
+	}
+	for i := range noise {
+		noise[i] = opts.Noise * gaussian(key, uint64(i))
+	}
+	return noise
+}
+
+// noiseEquity returns the change in cubeless equity from adding noise to
+// the outputs.
+func noiseEquity(noise [5]float64) float64 {
+	return 2*noise[0] + noise[1] + noise[2] - noise[3] - noise[4]
+}
+
+// addNoise adds noise to an evaluation from the mover's perspective. The
+// noisy probabilities are only meant for ranking moves and need not be
+// consistent.
+func addNoise(eval *Evaluation, noise [5]float64) {
+	eval.WinProb += noise[0]
+	eval.WinG += noise[1]
+	eval.WinBG += noise[2]
+	eval.LoseG += noise[3]
+	eval.LoseBG += noise[4]
+	eval.Equity += noiseEquity(noise)
+}
+
+// boardHash returns an FNV-1a hash of a board.
+func boardHash(b Board) uint64 {
+	h := uint64(14695981039346656037)
+	for side := range b {
+		for _, n := range b[side] {
+			h ^= uint64(n)
+			h *= 1099511628211
+		}
+	}
+	return h
+}
+
+// gaussian returns the i-th standard normal sample for key, from a
+// splitmix64 stream and the Box-Muller transform.
+func gaussian(key, i uint64) float64 {
+	u1 := unitFloat(splitmix64(key + 2*i*0x9e3779b97f4a7c15))
+	u2 := unitFloat(splitmix64(key + (2*i+1)*0x9e3779b97f4a7c15))
+	return math.Sqrt(-2*math.Log(u1)) * math.Cos(2*math.Pi*u2)
+}
+
+// splitmix64 scrambles x into a well mixed 64-bit value.
+func splitmix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+// unitFloat maps x to (0, 1].
+func unitFloat(x uint64) float64 {
+	return float64(x>>11+1) / (1 << 53)
+}
@@ -0,0 +1,92 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+)
+
+// noiseRankings ranks every roll of the quiz bearoffs with opts.
+func noiseRankings(t *testing.T, e *Engine, opts EvalOptions) [][]MoveWithEval {
+	t.Helper()
+	var rankings [][]MoveWithEval
+	for _, board := range []Board{quizBearoffA, quizBearoffB, quizNearTie} {
+		state := &GameState{Board: board, CubeValue: 1, CubeOwner: -1}
+		for d1 := 1; d1 <= 6; d1++ {
+			for d2 := 1; d2 <= d1; d2++ {
+				moves, err := e.RankMovesWithOptions(state, [2]int{d1, d2}, 0, opts)
+				if err != nil {
+					t.Fatalf("RankMovesWithOptions: %v", err)
+				}
+				rankings = append(rankings, moves)
+			}
+		}
+	}
+	return rankings
+}
+
+func TestEvalNoise(t *testing.T) {
+	e := quizEngine(t)
+	clean := noiseRankings(t, e, EvalOptions{})
+
+	silent := noiseRankings(t, e, EvalOptions{Deterministic: true})
+	if !reflect.DeepEqual(silent, clean) {
+		t.Error("ranking without noise differs from the clean ranking")
+	}
+
+	noisy := EvalOptions{Noise: 0.06, Deterministic: true}
+	first, again := noiseRankings(t, e, noisy), noiseRankings(t, e, noisy)
+	if !reflect.DeepEqual(first, again) {
+		t.Error("deterministic noise ranked the same positions differently")
+	}
+	if reflect.DeepEqual(first, clean) {
+		t.Error("noise did not change any evaluation")
+	}
+
+	// BestMove picks the top of the noisy ranking
+	state := &GameState{Board: quizBearoffA, CubeValue: 1, CubeOwner: -1}
+	ranked, _ := e.RankMovesWithOptions(state, [2]int{6, 2}, 1, noisy)
+	best, equity, err := e.BestMove(state, [2]int{6, 2}, noisy)
+	if err != nil || best != ranked[0].Move || !almostEqual(equity, ranked[0].Equity) {
+		t.Errorf("BestMove = %s %.5f (%v), ranking starts with %s %.5f",
+			FormatMove(best), equity, err, FormatMove(ranked[0].Move), ranked[0].Equity)
+	}
+}
+
+// TestBotLevelStrength checks that weaker levels give up more equity than
+// stronger ones over the quiz bearoffs.
+func TestBotLevelStrength(t *testing.T) {
+	e := quizEngine(t)
+	clean := noiseRankings(t, e, EvalOptions{})
+	loss := func(level string) float64 {
+		l, err := ParseBotLevel(level)
+		if err != nil {
+			t.Fatalf("ParseBotLevel(%q): %v", level, err)
+		}
+		var total float64
+		for i, ranking := range noiseRankings(t, e, l.Options()) {
+			for _, m := range clean[i] {
+				if m.Move == ranking[0].Move {
+					total += clean[i][0].Equity - m.Equity
+				}
+			}
+		}
+		return total
+	}
+
+	beginner, expert, worldClass := loss("beginner"), loss("Expert"), loss("world_class")
+	t.Logf("equity lost: beginner %.4f, expert %.4f", beginner, expert)
+	if worldClass != 0 {
+		t.Errorf("world-class level lost %.4f equity, want 0", worldClass)
+	}
+	if !(beginner > expert) {
+		t.Errorf("beginner lost %.4f equity, expert %.4f; want beginner to lose more", beginner, expert)
+	}
+	if _, err := ParseBotLevel("grandmaster"); err == nil {
+		t.Error("ParseBotLevel accepted an unknown level")
+	}
+}
+
+func almostEqual(a, b float64) bool {
+	d := a - b
+	return d < 1e-9 && d > -1e-9
+}
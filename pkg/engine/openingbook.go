@@ -138,7 +138,11 @@ func (e *Engine) OpeningMoveWithEval(state *GameState, dice [2]int) (Move, *Eval
 	entry, found := e.LookupOpening(state, dice)
 	if !found {
 		// Fall back to regular analysis
-		return e.BestMove(state, dice)
+		analysis, err := e.AnalyzePosition(state, dice)
+		if err != nil || analysis.NumMoves == 0 {
+			return Move{}, nil, err
+		}
+		return analysis.BestMove, analysis.Moves[0].Eval, nil
 	}
 
 	// Apply the opening move and evaluate the resulting position
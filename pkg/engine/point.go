@@ -0,0 +1,132 @@
+package engine
+
+import (
+	"iter"
+	"strconv"
+	"strings"
+)
+
+// Point is a point of the board as one side sees it, numbered the way Board
+// and Move index it: 0 is the side's ace point, 23 its 24 point and BarPoint
+// its bar. A move to OffPoint bears a checker off.
+//
+// Players number the same points 1-24, with 25 for the bar and 0 for off
+// (see Number). Keep conversions between the two here rather than adding
+// or subtracting one at each call site.
+type Point int
+
+const (
+	OffPoint Point = -1 // Destination of a checker borne off
+	BarPoint Point = 24 // Index of a side's bar in Board and Move
+)
+
+// homeBoardPoints is the number of points of a home board.
+const homeBoardPoints = 6
+
+// PointFromNumber returns the point a player calls n: 1-24 from its own
+// side, 25 for the bar and 0 for off.
+func PointFromNumber(n int) Point {
+	return Point(n - 1)
+}
+
+// Number returns the player's own number for p, the inverse of
+// PointFromNumber: 1-24, 25 for the bar and 0 for off.
+func (p Point) Number() int {
+	return int(p) + 1
+}
+
+// String returns p in move notation: its number, "bar" or "off".
+func (p Point) String() string {
+	switch {
+	case p == BarPoint:
+		return "bar"
+	case p < 0 || p > BarPoint:
+		return "off"
+	}
+	return strconv.Itoa(p.Number())
+}
+
+// InHomeBoard reports whether p is in the home board of the side it is
+// numbered for.
+func (p Point) InHomeBoard() bool {
+	return p >= 0 && p < homeBoardPoints
+}
+
+// InOpponentHome reports whether p is in the opponent's home board, seen
+// from the side it is numbered for. The bar is not.
+func (p Point) InOpponentHome() bool {
+	return p >= BarPoint-homeBoardPoints && p < BarPoint
+}
+
+// ParsePoint parses a point of move notation: a number from 0 to 25, "bar"
+// or "off", in any case and with an optional hit marker.
+func ParsePoint(s string) (Point, bool) {
+	s = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(s), "*"))
+	switch s {
+	case "bar":
+		return BarPoint, true
+	case "off":
+		return OffPoint, true
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 || n > BarPoint.Number() {
+		return OffPoint, false
+	}
+	return PointFromNumber(n), true
+}
+
+// Bar returns the number of side's checkers on the bar.
+func (b *Board) Bar(side int) int {
+	return int(b[side][BarPoint])
+}
+
+// OnBoard returns the number of side's checkers on the board, the bar
+// included.
+func (b *Board) OnBoard(side int) int {
+	n := 0
+	for _, c := range b[side] {
+		n += int(c)
+	}
+	return n
+}
+
+// Off returns the number of side's checkers borne off when it plays with
+// total checkers.
+func (b *Board) Off(side, total int) int {
+	return total - b.OnBoard(side)
+}
+
+// HomeBoard yields side's points of its own home board, from the ace point
+// up, with the checkers side has on each.
+func (b *Board) HomeBoard(side int) iter.Seq2[Point, int] {
+	return func(yield func(Point, int) bool) {
+		for p := Point(0); p < homeBoardPoints; p++ {
+			if !yield(p, int(b[side][p])) {
+				return
+			}
+		}
+	}
+}
+
+// ForEachChecker calls f for each point where side has checkers, from the
+// ace point to the bar, with the number it has there.
+func (b *Board) ForEachChecker(side int, f func(p Point, n int)) {
+	for p := Point(0); p <= BarPoint; p++ {
+		if n := b[side][p]; n > 0 {
+			f(p, int(n))
+		}
+	}
+}
+
+// BackgammonZone returns the number of side's checkers on the bar or in the
+// opponent's home board: a side that loses with any there and none borne
+// off is backgammoned.
+func (b *Board) BackgammonZone(side int) int {
+	n := 0
+	b.ForEachChecker(side, func(p Point, c int) {
+		if p == BarPoint || p.InOpponentHome() {
+			n += c
+		}
+	})
+	return n
+}
@@ -0,0 +1,189 @@
+package engine
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// randomBoard scatters up to 15 checkers of each side over its points and
+// bar, bearing off all of a side's checkers now and then.
+func randomBoard(rng *rand.Rand) Board {
+	var b Board
+	for side := 0; side < 2; side++ {
+		if rng.Intn(4) == 0 {
+			continue
+		}
+		for n := rng.Intn(CheckersPerSide) + 1; n > 0; n-- {
+			b[side][rng.Intn(25)]++
+		}
+	}
+	return b
+}
+
+// The functions below are the index arithmetic the typed Point and Board
+// helpers replaced, kept to check that the refactor changed nothing.
+// oldFormatMove is the API's copy, which wrote a bear-off with a larger die
+// as "off" where the engine's wrote a negative point.
+
+func oldWinType(board *Board, loser int, total int) int {
+	count := 0
+	for i := 0; i < 25; i++ {
+		count += int(board[loser][i])
+	}
+	if count >= total {
+		hasInHome := board[loser][24] > 0
+		for i := 18; i < 24; i++ {
+			if board[loser][i] > 0 {
+				hasInHome = true
+			}
+		}
+		if hasInHome {
+			return 3
+		}
+		return 2
+	}
+	return 1
+}
+
+func oldGameOverGammons(loser [25]uint8, total int) (gammon, backgammon float64) {
+	count := 0
+	for _, c := range loser {
+		count += int(c)
+	}
+	if count < total {
+		return 0, 0
+	}
+	for i := 18; i < 25; i++ {
+		if loser[i] > 0 {
+			return 1, 1
+		}
+	}
+	return 1, 0
+}
+
+func oldFormatMove(m Move) string {
+	result := ""
+	for i := 0; i < 4; i++ {
+		if m.From[i] < 0 {
+			break
+		}
+		if i > 0 {
+			result += " "
+		}
+		if m.From[i] == 24 {
+			result += "bar"
+		} else {
+			result += fmt.Sprintf("%d", int(m.From[i])+1)
+		}
+		result += "/"
+		if m.To[i] < 0 {
+			result += "off"
+		} else {
+			result += fmt.Sprintf("%d", int(m.To[i])+1)
+		}
+	}
+	return result
+}
+
+func oldParsePointNotation(s string) int {
+	switch s {
+	case "bar", "BAR", "Bar":
+		return 24
+	case "off", "OFF", "Off":
+		return -1
+	}
+	point := atoi(s)
+	if point >= 1 && point <= 24 {
+		return point - 1
+	}
+	return -1
+}
+
+func TestWinTypeMatchesOldLogic(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 10000; i++ {
+		b := randomBoard(rng)
+		for loser := 0; loser < 2; loser++ {
+			total := b.OnBoard(loser) + rng.Intn(2)
+			if got, want := winType(&b, loser, total), oldWinType(&b, loser, total); got != want {
+				t.Fatalf("winType(%v, %d, %d) = %d, old logic %d", b, loser, total, got, want)
+			}
+			g, bg := gameOverGammons(&b, loser, total)
+			og, obg := oldGameOverGammons(b[loser], total)
+			if g != og || bg != obg {
+				t.Fatalf("gameOverGammons(%v, %d, %d) = %v/%v, old logic %v/%v", b, loser, total, g, bg, og, obg)
+			}
+		}
+	}
+}
+
+func TestFormatMoveMatchesOldLogic(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	for i := 0; i < 2000; i++ {
+		b := randomBoard(rng)
+		for _, m := range GenerateMoves(b, rng.Intn(6)+1, rng.Intn(6)+1).Moves {
+			if got, want := FormatMove(m), oldFormatMove(m); got != want {
+				t.Fatalf("FormatMove(%v) = %q, old logic %q", m, got, want)
+			}
+			parsed, err := ParseMove(FormatMove(m))
+			if err != nil || ApplyMove(b, parsed) != ApplyMove(b, m) {
+				t.Fatalf("ParseMove(%q) = %v, %v; plays differently from %v", FormatMove(m), parsed, err, m)
+			}
+		}
+	}
+}
+
+func TestParsePoint(t *testing.T) {
+	inputs := []string{"bar", "BAR", "Bar", "off", "OFF", "Off", "x", ""}
+	for n := 0; n <= 24; n++ {
+		inputs = append(inputs, fmt.Sprint(n))
+	}
+	for _, s := range inputs {
+		if got, want := parsePointNotation(s), oldParsePointNotation(s); got != want {
+			t.Errorf("parsePointNotation(%q) = %d, old logic %d", s, got, want)
+		}
+	}
+
+	tests := []struct {
+		in   string
+		want Point
+		ok   bool
+	}{
+		{"25", BarPoint, true}, // The bar by number, which the old parser read as off
+		{" 8* ", 7, true},
+		{"0", OffPoint, true},
+		{"26", OffPoint, false},
+	}
+	for _, tt := range tests {
+		if p, ok := ParsePoint(tt.in); p != tt.want || ok != tt.ok {
+			t.Errorf("ParsePoint(%q) = %d, %v; want %d, %v", tt.in, p, ok, tt.want, tt.ok)
+		}
+	}
+	for p := OffPoint; p <= BarPoint; p++ {
+		if got := PointFromNumber(p.Number()); got != p {
+			t.Errorf("PointFromNumber(%d.Number()) = %d", p, got)
+		}
+	}
+}
+
+func TestBoardHelpers(t *testing.T) {
+	b := StartingPosition().Board
+	if n := b.OnBoard(0); n != 15 {
+		t.Errorf("OnBoard = %d, want 15", n)
+	}
+	b[0][BarPoint], b[0][5] = 1, 4
+	if b.Bar(0) != 1 || b.Off(0, 15) != 0 || b.BackgammonZone(0) != 3 {
+		t.Errorf("Bar %d, Off %d, BackgammonZone %d; want 1, 0, 3", b.Bar(0), b.Off(0, 15), b.BackgammonZone(0))
+	}
+	home := 0
+	for p, n := range b.HomeBoard(0) {
+		if !p.InHomeBoard() {
+			t.Errorf("HomeBoard yielded point %v", p)
+		}
+		home += n
+	}
+	if home != 4 {
+		t.Errorf("home board holds %d checkers, want 4", home)
+	}
+}
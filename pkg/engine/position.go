@@ -1,10 +1,15 @@
 // Package engine provides the public API for the backgammon engine.
 package engine
 
+import (
+	"fmt"
+	"strings"
+)
+
 // Board represents checker positions for both players.
-// Index 0-24 represents points (0 = bar for opponent's checkers, 1-24 = board points)
 // In gnubg's TanBoard: [2][25] where [player][point]
-// Point 24 is the bar, points 0-23 are the board points
+// Each side's points are indexed from its own side as Point numbers them:
+// 0-23 are the board points and 24 (BarPoint) is the bar
 type Board [2][25]uint8
 
 // GameState represents the full state needed for evaluation
@@ -17,6 +22,66 @@ type GameState struct {
 	MatchLength int    // 0 = money game
 	Score       [2]int // Match score
 	Crawford    bool   // Crawford game flag
+	MET         string // Match equity table for match play, by name (see Engine.METNames; "" = the engine's)
+
+	// TotalCheckers is the number of checkers each side of Board plays
+	// with, for partial positions such as endgame studies that leave out
+	// the other checkers; 0 means 15. Checkers a side has fewer than its
+	// total on the board are borne off, and only such a side cannot be
+	// gammoned. Game results and the exact solver score gammons against the
+	// totals, and bearoffs take the gammon chances of a side with all of its
+	// checkers on the board from the one-sided database. The nets see
+	// missing checkers as borne off, so partial contact and race positions
+	// outside the databases get next to no gammons from them.
+	TotalCheckers [2]int
+}
+
+// CheckersPerSide is the number of checkers each side plays with in a full
+// game.
+const CheckersPerSide = 15
+
+// fullTotals is the checker totals of a full game.
+var fullTotals = [2]int{CheckersPerSide, CheckersPerSide}
+
+// checkerTotals returns the checkers each side of s.Board plays with.
+func (s *GameState) checkerTotals() [2]int {
+	totals := s.TotalCheckers
+	for side, n := range totals {
+		if n == 0 {
+			totals[side] = CheckersPerSide
+		}
+	}
+	return totals
+}
+
+// swapTotals returns checker totals with the sides swapped, for the board
+// turned round by swapBoard.
+func swapTotals(totals [2]int) [2]int {
+	return [2]int{totals[1], totals[0]}
+}
+
+// Partial reports whether either side plays with fewer than 15 checkers.
+func (s *GameState) Partial() bool {
+	return s.checkerTotals() != fullTotals
+}
+
+// CheckCheckers checks that each side's checker total is 1-15 and that
+// neither side has more checkers on the board than its total.
+func (s *GameState) CheckCheckers() error {
+	names := [2]string{"the opponent", "the player on roll"}
+	for side, total := range s.checkerTotals() {
+		if total < 1 || total > CheckersPerSide {
+			return fmt.Errorf("%s plays with %d checkers, want 1-%d", names[side], total, CheckersPerSide)
+		}
+		n := 0
+		for _, c := range s.Board[side] {
+			n += int(c)
+		}
+		if n > total {
+			return fmt.Errorf("%s has %d checkers on the board, more than its total of %d", names[side], n, total)
+		}
+	}
+	return nil
 }
 
 // Evaluation contains equity estimates from position evaluation
@@ -27,6 +92,7 @@ type Evaluation struct {
 	WinBG   float64 // P(win backgammon)
 	LoseG   float64 // P(lose gammon)
 	LoseBG  float64 // P(lose backgammon)
+	Source  string  // What evaluated the position: SourceNet, SourceBearoff or SourceHeuristic ("" if unknown or over)
 }
 
 // Move represents a sequence of checker moves
@@ -46,7 +112,9 @@ const (
 	Redouble // Double when we already own the cube
 	Take
 	Pass
-	Beaver // Take and immediately redouble (optional rule)
+	Beaver  // Take and immediately redouble, keeping the cube (money only)
+	Raccoon // Redouble a beaver; the beavering player keeps the cube (money only)
+	TooGood // Too good to double: play on for the gammon rather than cash (advice only; playing on is NoDouble)
 )
 
 // CubeDecision contains cube action recommendation
@@ -100,6 +168,75 @@ func StartingPosition() *GameState {
 	return gs
 }
 
+// OpponentOnRoll returns the state with the opponent of the player on roll
+// to play from the same position, before rolling. The score and cube owner
+// are kept: they are indexed by player, so they follow the swap of Turn.
+func (s *GameState) OpponentOnRoll() *GameState {
+	o := *s
+	o.Board = swapBoard(s.Board)
+	o.TotalCheckers = swapTotals(s.TotalCheckers)
+	o.Turn = 1 - s.Turn
+	o.Dice = [2]int{}
+	return &o
+}
+
+// Variant is a game variant with its own starting position. Variants use the
+// same 15 checkers and rules, so only the starting position differs.
+type Variant int
+
+const (
+	VariantStandard   Variant = iota // Standard backgammon
+	VariantNackgammon                // Nackgammon: two more back checkers on the 23-point
+)
+
+// String returns the variant's name as gnubg writes it.
+func (v Variant) String() string {
+	switch v {
+	case VariantNackgammon:
+		return "Nackgammon"
+	default:
+		return "Backgammon"
+	}
+}
+
+// ParseVariant returns the variant with the given name (case-insensitive).
+// "Standard" and "Backgammon" both name the standard game.
+func ParseVariant(name string) (Variant, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "standard", "backgammon":
+		return VariantStandard, nil
+	case "nackgammon":
+		return VariantNackgammon, nil
+	default:
+		return VariantStandard, fmt.Errorf("unknown variant %q", name)
+	}
+}
+
+// StartingPositionVariant returns the starting position of a variant.
+func StartingPositionVariant(v Variant) *GameState {
+	gs := StartingPosition()
+	if v == VariantNackgammon {
+		// 4 on the 6-point and 13-point, 2 each on the 23- and 24-points
+		for side := 0; side < 2; side++ {
+			gs.Board[side][5] = 4
+			gs.Board[side][12] = 4
+			gs.Board[side][22] = 2
+		}
+	}
+	return gs
+}
+
+// IsStartingPosition reports whether board is the starting position of a
+// variant.
+func IsStartingPosition(board Board) bool {
+	for _, v := range []Variant{VariantStandard, VariantNackgammon} {
+		if board == StartingPositionVariant(v).Board {
+			return true
+		}
+	}
+	return false
+}
+
 // EqualBoards returns true if two boards are identical
 func EqualBoards(b1, b2 Board) bool {
 	for i := 0; i < 2; i++ {
@@ -128,3 +265,15 @@ func CopyKey(src PositionKey) PositionKey {
 	copy(dst.Data[:], src.Data[:])
 	return dst
 }
+
+// PipCount returns the pip count of each side of the board.
+// Checkers on the bar count 25 pips.
+func PipCount(board Board) [2]int {
+	var pips [2]int
+	for side := 0; side < 2; side++ {
+		for i := 0; i < 25; i++ {
+			pips[side] += int(board[side][i]) * (i + 1)
+		}
+	}
+	return pips
+}
@@ -2,7 +2,9 @@
 package engine
 
 import (
+	"fmt"
 	"sort"
+	"strings"
 	"sync"
 
 	"github.com/yourusername/bgengine/internal/positionid"
@@ -32,6 +34,19 @@ func (c PositionCategory) String() string {
 	}[c]
 }
 
+// ParsePositionCategory returns the category named s, matched without regard
+// to case and with "_" or "-" standing for a space (e.g. "holding",
+// "safety_play").
+func ParsePositionCategory(s string) (PositionCategory, error) {
+	name := strings.NewReplacer("_", " ", "-", " ").Replace(strings.ToLower(s))
+	for c := CategoryUnknown; c <= CategorySafetyPlay; c++ {
+		if strings.ToLower(c.String()) == name {
+			return c, nil
+		}
+	}
+	return CategoryUnknown, fmt.Errorf("unknown position category %q", s)
+}
+
 // PositionEntry represents a position in the database.
 type PositionEntry struct {
 	ID          string           `json:"id"`          // Position ID
@@ -56,6 +71,7 @@ type PositionDB struct {
 	positions  map[string]*PositionEntry
 	byCategory map[PositionCategory][]*PositionEntry
 	byTag      map[string][]*PositionEntry
+	byKey      map[positionid.PositionKey]*PositionEntry // By canonical key, shared with the mirror image
 	mu         sync.RWMutex
 }
 
@@ -65,14 +81,25 @@ func NewPositionDB() *PositionDB {
 		positions:  make(map[string]*PositionEntry),
 		byCategory: make(map[PositionCategory][]*PositionEntry),
 		byTag:      make(map[string][]*PositionEntry),
+		byKey:      make(map[positionid.PositionKey]*PositionEntry),
 	}
 }
 
-// Add adds a position to the database.
-func (db *PositionDB) Add(entry *PositionEntry) {
+// Add adds a position to the database. A position whose board is the mirror
+// image of a stored one's (the same position entered from the other side)
+// is a duplicate: it is not added, and Add returns false.
+func (db *PositionDB) Add(entry *PositionEntry) bool {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
+	key, _ := positionid.CanonicalKey(positionid.Board(entry.Board))
+	if stored := db.byKey[key]; stored != nil && stored.Board != entry.Board {
+		return false
+	}
+	if db.byKey[key] == nil {
+		db.byKey[key] = entry
+	}
+
 	// Generate ID if not set
 	if entry.ID == "" {
 		entry.ID = EncodePositionID(entry.Board)
@@ -87,13 +114,23 @@ func (db *PositionDB) Add(entry *PositionEntry) {
 	for _, tag := range entry.Tags {
 		db.byTag[tag] = append(db.byTag[tag], entry)
 	}
+	return true
 }
 
-// Get retrieves a position by ID.
+// Get retrieves a position by ID. A position ID whose mirror image is stored
+// finds the stored position.
 func (db *PositionDB) Get(id string) *PositionEntry {
 	db.mu.RLock()
 	defer db.mu.RUnlock()
-	return db.positions[id]
+	if p := db.positions[id]; p != nil {
+		return p
+	}
+	board, err := positionid.BoardFromPositionID(id)
+	if err != nil {
+		return nil
+	}
+	key, _ := positionid.CanonicalKey(board)
+	return db.byKey[key]
 }
 
 // GetByCategory returns all positions in a category.
@@ -186,20 +223,25 @@ func toLower(s string) string {
 type PositionSimilarity struct {
 	Entry      *PositionEntry
 	Similarity float64 // 0.0 to 1.0
+	Mirrored   bool    // The entry resembles the board with the sides swapped
 }
 
-// FindSimilar finds positions similar to the given board.
+// FindSimilar finds positions similar to the given board, or to its mirror
+// image, whichever is closer.
 func (db *PositionDB) FindSimilar(board Board, maxResults int) []PositionSimilarity {
 	db.mu.RLock()
 	defer db.mu.RUnlock()
 
+	mirror := Board(positionid.SwapSides(positionid.Board(board)))
 	var results []PositionSimilarity
 	for _, p := range db.positions {
 		sim := calculateBoardSimilarity(board, p.Board)
-		if sim > 0.5 { // Threshold for "similar"
+		msim := calculateBoardSimilarity(mirror, p.Board)
+		if sim > 0.5 || msim > 0.5 { // Threshold for "similar"
 			results = append(results, PositionSimilarity{
 				Entry:      p,
-				Similarity: sim,
+				Similarity: max(sim, msim),
+				Mirrored:   msim > sim,
 			})
 		}
 	}
@@ -321,6 +363,17 @@ func ClassifyPosition(board Board) PositionCategory {
 		}
 	}
 
+	// Blitz: opponent on the bar against a strong home board
+	homePoints := 0
+	for point := 0; point < 6; point++ {
+		if board[0][point] >= 2 {
+			homePoints++
+		}
+	}
+	if p1bar > 0 && homePoints >= 3 {
+		return CategoryBlitz
+	}
+
 	// Priming: 4+ consecutive points made
 	maxPrime := 0
 	currentPrime := 0
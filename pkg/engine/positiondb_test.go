@@ -2,6 +2,8 @@ package engine
 
 import (
 	"testing"
+
+	"github.com/yourusername/bgengine/internal/positionid"
 )
 
 func TestPositionDB(t *testing.T) {
@@ -166,3 +168,33 @@ func TestPositionCategoryString(t *testing.T) {
 	}
 }
 
+
+func TestPositionDBMirrored(t *testing.T) {
+	board := StartingPosition().Board
+	board[1][5], board[1][4] = 4, 1 // 6-4 point
+	mirror := Board(positionid.SwapSides(positionid.Board(board)))
+
+	db := NewPositionDB()
+	if !db.Add(&PositionEntry{Name: "Slotted", Category: CategoryContact, Board: board}) {
+		t.Fatal("Add() = false for the first entry")
+	}
+	if db.Add(&PositionEntry{Name: "Slotted, other side", Category: CategoryContact, Board: mirror}) {
+		t.Error("Add() = true for the mirror image of a stored position")
+	}
+	if db.Count() != 1 || len(db.GetByCategory(CategoryContact)) != 1 {
+		t.Errorf("Count() = %d, want 1", db.Count())
+	}
+
+	// The mirror's ID finds the stored position
+	if p := db.Get(EncodePositionID(mirror)); p == nil || p.Name != "Slotted" {
+		t.Errorf("Get(mirror ID) = %+v, want the stored position", p)
+	}
+
+	similar := db.FindSimilar(mirror, 5)
+	if len(similar) != 1 || similar[0].Similarity != 1 || !similar[0].Mirrored {
+		t.Errorf("FindSimilar(mirror) = %+v, want the stored position, mirrored", similar)
+	}
+	if similar = db.FindSimilar(board, 5); len(similar) != 1 || similar[0].Mirrored {
+		t.Errorf("FindSimilar(board) = %+v, want the stored position, not mirrored", similar)
+	}
+}
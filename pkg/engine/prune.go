@@ -3,6 +3,7 @@ package engine
 import (
 	"math/bits"
 	"sort"
+	"time"
 
 	"github.com/yourusername/bgengine/internal/neuralnet"
 	"github.com/yourusername/bgengine/internal/positionid"
@@ -41,7 +42,7 @@ type scoredMove struct {
 
 // pruneMoves uses the pruning neural nets to quickly score moves and return only the best candidates
 // Returns the top moves that should be fully evaluated
-func (e *Engine) pruneMoves(state *GameState, moves []Move) []Move {
+func (e *Engine) pruneMoves(state *GameState, moves []Move, t *Timing) []Move {
 	if len(moves) <= MinPruneMoves {
 		return moves // No pruning needed
 	}
@@ -66,7 +67,7 @@ func (e *Engine) pruneMoves(state *GameState, moves []Move) []Move {
 	for i, m := range moves {
 		scored[i] = scoredMove{
 			move:  m,
-			score: e.scoreMoveForPruning(state, m),
+			score: e.scoreMoveForPruning(state, m, t),
 			index: i,
 		}
 	}
@@ -85,15 +86,19 @@ func (e *Engine) pruneMoves(state *GameState, moves []Move) []Move {
 }
 
 // scoreMoveForPruning quickly scores a move using the pruning neural net
-func (e *Engine) scoreMoveForPruning(state *GameState, m Move) float32 {
+func (e *Engine) scoreMoveForPruning(state *GameState, m Move, t *Timing) float32 {
 	// Apply the move
 	resultBoard := ApplyMove(state.Board, m)
 
 	// Swap sides for opponent's perspective
 	swappedBoard := positionid.SwapSides(positionid.Board(resultBoard))
 
-	// Classify the position
-	board := neuralnet.Board(swappedBoard)
+	return e.scorePruneBoard(neuralnet.Board(swappedBoard), t)
+}
+
+// scorePruneBoard scores a post-move board (opponent on roll) with the pruning
+// net for its class. Returns the negated opponent equity, 0 if no net is loaded.
+func (e *Engine) scorePruneBoard(board neuralnet.Board, t *Timing) float32 {
 	class := neuralnet.ClassifyPosition(board)
 
 	// Select pruning net based on position class
@@ -103,6 +108,9 @@ func (e *Engine) scoreMoveForPruning(state *GameState, m Move) float32 {
 		pNet = e.pRace
 	case neuralnet.ClassCrashed:
 		pNet = e.pCrashed
+		if e.disableCrashed.Load() {
+			pNet = e.pContact
+		}
 	default:
 		pNet = e.pContact
 	}
@@ -111,25 +119,27 @@ func (e *Engine) scoreMoveForPruning(state *GameState, m Move) float32 {
 		return 0 // No pruning net available
 	}
 
-	// Calculate base inputs only (200 inputs)
-	inputs := make([]float32, neuralnet.NumPruningInputs)
+	// Calculate base inputs only (200 inputs) into pooled buffers
+	inputsPtr := e.inputPool.Get().(*[]float32)
+	defer e.inputPool.Put(inputsPtr)
+	inputs := (*inputsPtr)[:neuralnet.NumPruningInputs]
+	start := t.start()
 	neuralnet.BaseInputsInto(board, inputs)
+	if t != nil {
+		t.Inputs += time.Since(start)
+		start = time.Now()
+	}
+
+	buf := e.pruneBufPool.Get().(*neuralnet.EvaluateBuffer)
+	defer e.pruneBufPool.Put(buf)
 
 	// Evaluate with pruning net
-	outputs := pNet.Evaluate(inputs)
-
-	// Return utility from opponent's perspective (we'll negate since we want best for us)
-	// Win prob is outputs[0], higher is better for opponent, worse for us
-	// So we return negative of opponent's equity
-	winProb := float64(outputs[0])
-	winG := float64(outputs[1])
-	winBG := float64(outputs[2])
-	loseG := float64(outputs[3])
-	loseBG := float64(outputs[4])
-
-	// Equity from opponent's perspective
-	oppEquity := winProb - (1 - winProb) + winG - loseG + winBG - loseBG
-
-	// Return negative (our perspective)
-	return float32(-oppEquity)
+	var outputs [5]float32
+	pNet.EvaluateFast(inputs, outputs[:], buf)
+	if t != nil {
+		t.Prune += time.Since(start)
+	}
+
+	// Equity from opponent's perspective; negate since we want best for us
+	return float32(-outputEquity(outputs))
 }
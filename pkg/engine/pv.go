@@ -0,0 +1,90 @@
+package engine
+
+import (
+	"math"
+	"sort"
+)
+
+// PVRolls is how many rolls a PrincipalVariation keeps.
+const PVRolls = 3
+
+// PrincipalVariation is the line a lookahead evaluation assumed: the rolls of
+// the player on roll that move the evaluation most, each with the play the
+// search found best for it and, when there was lookahead left, the
+// principal variation of the opponent's rolls after that play. Evaluations
+// record it with EvalOptions.PV.
+type PrincipalVariation struct {
+	Position string   // Position ID, the player who rolls on roll
+	Ply      int      // Depth the position was evaluated at
+	Equity   float64  // Cubeless equity of the player on roll at Ply: the average over the 21 rolls
+	Rolls    []PVRoll // The PVRolls rolls of the largest Impact, either way, largest first
+}
+
+// PVRoll is one roll of a principal variation.
+type PVRoll struct {
+	Dice        [2]int
+	Probability float64    // 1/36, or 2/36 for a non-double
+	Move        Move       // Best play of the roll; no sub-moves (From[0] == -1) if none is legal
+	After       string     // Position ID after Move, the opponent on roll ("" if no move is legal)
+	Eval        Evaluation // Evaluation after Move at Ply, for the player who rolled
+	Ply         int        // Depth the position after Move was evaluated at
+	Impact      float64    // Probability * (Eval.Equity - Equity of the variation): what the roll moves the equity by
+
+	// Next is the principal variation of the opponent's rolls after Move,
+	// when it was evaluated with lookahead
+	Next *PrincipalVariation
+}
+
+// newPVRolls returns the storage evaluateNPly records the 21 rolls of a
+// principal variation in, or nil without one to record: the search then
+// does no bookkeeping.
+func newPVRolls(pv *PrincipalVariation) []PVRoll {
+	if pv == nil {
+		return nil
+	}
+	return make([]PVRoll, 0, 21)
+}
+
+// set makes pv the principal variation of state evaluated at plies with
+// equity, from the best play of each of its 21 rolls.
+func (pv *PrincipalVariation) set(state *GameState, plies int, equity float64, rolls []PVRoll) {
+	for i := range rolls {
+		rolls[i].Impact = rolls[i].Probability * (rolls[i].Eval.Equity - equity)
+	}
+	sort.SliceStable(rolls, func(i, j int) bool {
+		return math.Abs(rolls[i].Impact) > math.Abs(rolls[j].Impact)
+	})
+	rolls = rolls[:min(PVRolls, len(rolls))]
+	for i := range rolls {
+		if rolls[i].Move.From[0] >= 0 {
+			rolls[i].After = ResultingPositionID(state.Board, rolls[i].Move)
+		}
+	}
+	*pv = PrincipalVariation{
+		Position: EncodePositionID(state.Board),
+		Ply:      plies,
+		Equity:   equity,
+		Rolls:    append([]PVRoll(nil), rolls...),
+	}
+}
+
+// movePV returns where a move analysis records the principal variation of a
+// move evaluated at plies: nil without EvalOptions.PV or lookahead.
+func (opts EvalOptions) movePV(plies int) *PrincipalVariation {
+	if opts.PV == nil || plies <= 0 {
+		return nil
+	}
+	return new(PrincipalVariation)
+}
+
+// setPV sets opts.PV, if not nil, to the principal variation of the best of
+// the ranked moves.
+func (opts EvalOptions) setPV(moves []MoveWithEval) {
+	if opts.PV == nil {
+		return
+	}
+	*opts.PV = PrincipalVariation{}
+	if len(moves) > 0 && moves[0].PV != nil {
+		*opts.PV = *moves[0].PV
+	}
+}
@@ -0,0 +1,163 @@
+package engine
+
+import (
+	"math"
+	"testing"
+
+	"github.com/yourusername/bgengine/internal/positionid"
+)
+
+// checkPV checks that every roll of pv is reproduced by evaluating the
+// positions it states directly: the play is legal and leads to After, its
+// evaluation at Ply is Eval, no other play of the roll does better, and the
+// continuation after it is that of After.
+func checkPV(t *testing.T, e *Engine, pv *PrincipalVariation, plies int) {
+	t.Helper()
+	board, err := positionid.BoardFromPositionID(pv.Position)
+	if err != nil {
+		t.Fatalf("BoardFromPositionID(%q): %v", pv.Position, err)
+	}
+	if pv.Ply != plies || len(pv.Rolls) != PVRolls {
+		t.Fatalf("PV of %s: ply %d with %d rolls, want ply %d with %d", pv.Position, pv.Ply, len(pv.Rolls), plies, PVRolls)
+	}
+
+	evalAt := func(b Board, plies int) *Evaluation {
+		eval, err := e.EvaluatePliedWithOptions(&GameState{Board: b, CubeValue: 1, CubeOwner: -1}, EvalOptions{Plies: plies})
+		if err != nil {
+			t.Fatalf("EvaluatePliedWithOptions: %v", err)
+		}
+		return invertEvaluation(eval)
+	}
+
+	for i, r := range pv.Rolls {
+		if i > 0 && math.Abs(r.Impact) > math.Abs(pv.Rolls[i-1].Impact) {
+			t.Errorf("PV of %s: roll %v has impact %.4f after %.4f", pv.Position, r.Dice, r.Impact, pv.Rolls[i-1].Impact)
+		}
+		if want := r.Probability * (r.Eval.Equity - pv.Equity); math.Abs(r.Impact-want) > 1e-12 {
+			t.Errorf("PV of %s: roll %v has impact %.6f, want %.6f", pv.Position, r.Dice, r.Impact, want)
+		}
+		if r.Ply != plies-1 {
+			t.Errorf("PV of %s: roll %v at ply %d, want %d", pv.Position, r.Dice, r.Ply, plies-1)
+		}
+
+		best := math.Inf(-1)
+		found := false
+		for _, m := range GenerateMoves(Board(board), r.Dice[0], r.Dice[1]).Moves {
+			after := swapBoard(ApplyMove(Board(board), m))
+			eq := evalAt(after, r.Ply).Equity
+			best = math.Max(best, eq)
+			if m == r.Move {
+				found = true
+				if id := EncodePositionID(after); r.After != id {
+					t.Errorf("PV of %s: %v %s leads to %s, stated %s", pv.Position, r.Dice, FormatMove(m), id, r.After)
+				}
+				if math.Abs(eq-r.Eval.Equity) > 1e-9 {
+					t.Errorf("PV of %s: %v %s evaluates to %.6f at ply %d, stated %.6f", pv.Position, r.Dice, FormatMove(m), eq, r.Ply, r.Eval.Equity)
+				}
+			}
+		}
+		if !found {
+			t.Fatalf("PV of %s: %s is not a legal play of %v", pv.Position, FormatMove(r.Move), r.Dice)
+		}
+		if r.Eval.Equity < best-1e-9 {
+			t.Errorf("PV of %s: %v %s has equity %.6f, another play %.6f", pv.Position, r.Dice, FormatMove(r.Move), r.Eval.Equity, best)
+		}
+
+		switch {
+		case r.Ply == 0 && r.Next != nil:
+			t.Errorf("PV of %s: roll %v has a continuation at 0 plies", pv.Position, r.Dice)
+		case r.Ply > 0 && (r.Next == nil || r.Next.Position != r.After):
+			t.Fatalf("PV of %s: roll %v continues with %+v, want the PV of %s", pv.Position, r.Dice, r.Next, r.After)
+		case r.Ply > 0:
+			checkPV(t, e, r.Next, r.Ply)
+		}
+	}
+}
+
+// pvState is a race of a few checkers a side, small enough to search at
+// 2 plies without pruning.
+func pvState() *GameState {
+	return &GameState{
+		Board:     boardFromPoints(map[int]uint8{8: 1, 6: 2, 5: 2, 4: 2, 3: 1}, map[int]uint8{7: 1, 6: 2, 5: 2, 4: 1, 2: 2}),
+		CubeValue: 1,
+		CubeOwner: -1,
+	}
+}
+
+func TestPrincipalVariation(t *testing.T) {
+	e, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	state := pvState()
+
+	var pv PrincipalVariation
+	eval, err := e.EvaluatePliedWithOptions(state, EvalOptions{Plies: 2, PV: &pv})
+	if err != nil {
+		t.Fatalf("EvaluatePliedWithOptions: %v", err)
+	}
+	if pv.Position != EncodePositionID(state.Board) || pv.Equity != eval.Equity {
+		t.Errorf("PV of %s with equity %.6f, want %s and %.6f", pv.Position, pv.Equity, EncodePositionID(state.Board), eval.Equity)
+	}
+	checkPV(t, e, &pv, 2)
+
+	// The record leaves the evaluation as it is
+	plain, err := e.EvaluatePliedWithOptions(state, EvalOptions{Plies: 2})
+	if err != nil {
+		t.Fatalf("EvaluatePliedWithOptions: %v", err)
+	}
+	if *plain != *eval {
+		t.Errorf("evaluation with PV %+v, without %+v", eval, plain)
+	}
+
+	// At 0 plies there is no variation
+	if _, err := e.EvaluatePliedWithOptions(state, EvalOptions{PV: &pv}); err != nil {
+		t.Fatalf("EvaluatePliedWithOptions: %v", err)
+	}
+	if pv.Position != "" || pv.Rolls != nil {
+		t.Errorf("PV at 0 plies: %+v, want none", pv)
+	}
+}
+
+func TestAnalyzePositionPV(t *testing.T) {
+	e, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	state := pvState()
+	dice := [2]int{4, 2}
+
+	var pv PrincipalVariation
+	result, err := e.AnalyzePositionWithOptions(state, dice, EvalOptions{Plies: 1, PV: &pv})
+	if err != nil {
+		t.Fatalf("AnalyzePositionWithOptions: %v", err)
+	}
+	for _, m := range result.Moves {
+		// The variation is of the opponent's rolls after the move
+		if m.PV == nil || m.PV.Position != ResultingPositionID(state.Board, m.Move) || m.PV.Equity != -m.Eval.Equity {
+			t.Fatalf("%s: PV %+v, want one of %s with equity %.6f", FormatMove(m.Move), m.PV, ResultingPositionID(state.Board, m.Move), -m.Eval.Equity)
+		}
+	}
+	if pv.Position != result.Moves[0].PV.Position || len(pv.Rolls) != PVRolls {
+		t.Errorf("opts.PV is of %s, want the best move's, %s", pv.Position, result.Moves[0].PV.Position)
+	}
+	checkPV(t, e, &pv, 1)
+
+	top, err := e.RankMovesWithOptions(state, dice, 2, EvalOptions{Plies: 1, PV: &pv})
+	if err != nil {
+		t.Fatalf("RankMovesWithOptions: %v", err)
+	}
+	if top[0].PV == nil || pv.Position != top[0].PV.Position {
+		t.Errorf("RankMovesWithOptions: opts.PV is of %s, want the best move's", pv.Position)
+	}
+
+	plain, err := e.AnalyzePositionWithOptions(state, dice, EvalOptions{Plies: 1})
+	if err != nil {
+		t.Fatalf("AnalyzePositionWithOptions: %v", err)
+	}
+	for i, m := range plain.Moves {
+		if m.PV != nil || m.Equity != result.Moves[i].Equity {
+			t.Fatalf("%s without PV: PV %+v, equity %.6f, want none and %.6f", FormatMove(m.Move), m.PV, m.Equity, result.Moves[i].Equity)
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/yourusername/bgengine/internal/positionid"
+)
+
+// ErrNoQuizProblem is returned by QuizGenerator.Next when no position left
+// to ask passes the filters.
+var ErrNoQuizProblem = errors.New("no quiz problem matches the filters")
+
+// QuizOptions configures a QuizGenerator.
+type QuizOptions struct {
+	// A position is only asked with a roll whose best move beats the
+	// second-best by at least MinGap (otherwise the answer is a coin toss)
+	// and at most MaxGap (otherwise it is too easy).
+	MinGap float64
+	MaxGap float64
+	// Seed makes the sequence of problems reproducible (0 = random)
+	Seed int64
+}
+
+// DefaultQuizOptions returns options asking for problems whose best move is
+// between 0.02 and 0.25 equity better than the runner-up.
+func DefaultQuizOptions() QuizOptions {
+	return QuizOptions{
+		MinGap: 0.02,
+		MaxGap: 0.25,
+	}
+}
+
+// Validate checks that the equity band is well formed.
+func (o QuizOptions) Validate() error {
+	if o.MinGap < 0 || o.MaxGap <= 0 {
+		return fmt.Errorf("equity gaps must be positive")
+	}
+	if o.MinGap > o.MaxGap {
+		return fmt.Errorf("min gap %.3f is above max gap %.3f", o.MinGap, o.MaxGap)
+	}
+	return nil
+}
+
+// QuizProblem is a checker play problem: a position and a roll to play.
+type QuizProblem struct {
+	Source     string           // ID of the position database entry, or "game N move M"
+	Name       string           // Human-readable name of the source
+	Board      Board            // Position, player on roll in Board[1]
+	Dice       [2]int           // Roll to play
+	Category   PositionCategory // Category of the position
+	Difficulty int              // 1 (easy) - 5 (hard)
+	BestEquity float64          // Equity of the best move
+	Gap        float64          // Equity by which the best move beats the second-best
+}
+
+// State returns the money game state of the problem, for grading an answer
+// with AnalyzeMoveSkill.
+func (p *QuizProblem) State() *GameState {
+	return &GameState{Board: p.Board, Turn: 0, CubeValue: 1, CubeOwner: -1}
+}
+
+// quizSource is a position the generator can ask. Positions from the
+// database are dice-agnostic and asked with any roll; match errors keep the
+// roll that was played.
+type quizSource struct {
+	id         string
+	name       string
+	board      Board
+	dice       [2]int // Zero for any roll
+	category   PositionCategory
+	difficulty int // 0 = rated by the equity gap
+}
+
+// QuizGenerator draws checker play problems from a set of positions, keeping
+// only position and roll combinations that are neither forced, near-ties nor
+// obvious. Each combination is asked at most once. It is safe for concurrent
+// use.
+type QuizGenerator struct {
+	engine  *Engine
+	opts    QuizOptions
+	mu      sync.Mutex
+	rng     *rand.Rand
+	sources []quizSource
+	asked   map[string]bool
+}
+
+// NewQuizGenerator creates a generator with no positions; add them with
+// AddPositions or AddMoveErrors.
+func NewQuizGenerator(e *Engine, opts QuizOptions) (*QuizGenerator, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	seed := opts.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &QuizGenerator{
+		engine: e,
+		opts:   opts,
+		rng:    rand.New(rand.NewSource(seed)),
+		asked:  make(map[string]bool),
+	}, nil
+}
+
+// AddPositions adds position database entries, to be asked with any roll.
+func (g *QuizGenerator) AddPositions(entries []*PositionEntry) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for _, p := range entries {
+		g.sources = append(g.sources, quizSource{
+			id:         p.ID,
+			name:       p.Name,
+			board:      p.Board,
+			category:   p.Category,
+			difficulty: p.Difficulty,
+		})
+	}
+	g.sortSources()
+}
+
+// AddMoveErrors adds the positions of a match analysis's checker play
+// errors, each to be asked with the roll that was misplayed.
+func (g *QuizGenerator) AddMoveErrors(errs []MoveErrorDetail) error {
+	sources := make([]quizSource, 0, len(errs))
+	for _, me := range errs {
+		board, err := positionid.BoardFromPositionID(me.Position)
+		if err != nil {
+			return fmt.Errorf("game %d move %d: %w", me.GameNumber, me.MoveNumber, err)
+		}
+		sources = append(sources, quizSource{
+			id:       fmt.Sprintf("game %d move %d", me.GameNumber, me.MoveNumber),
+			name:     fmt.Sprintf("Game %d, move %d", me.GameNumber, me.MoveNumber),
+			board:    Board(board),
+			dice:     me.Dice,
+			category: ClassifyPosition(Board(board)),
+		})
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.sources = append(g.sources, sources...)
+	g.sortSources()
+	return nil
+}
+
+// sortSources puts the sources in a fixed order, so that a seed always
+// yields the same problems. The caller must hold g.mu.
+func (g *QuizGenerator) sortSources() {
+	sort.SliceStable(g.sources, func(i, j int) bool {
+		return g.sources[i].id < g.sources[j].id
+	})
+}
+
+// Next returns a problem not asked before, from a position of the given
+// category (CategoryUnknown for any) and difficulty (0 for any). It returns
+// ErrNoQuizProblem when every candidate has been asked or filtered out.
+func (g *QuizGenerator) Next(category PositionCategory, difficulty int) (*QuizProblem, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for _, si := range g.rng.Perm(len(g.sources)) {
+		src := &g.sources[si]
+		if category != CategoryUnknown && src.category != category {
+			continue
+		}
+		if difficulty != 0 && src.difficulty != 0 && src.difficulty != difficulty {
+			continue
+		}
+
+		rolls := [][2]int{src.dice}
+		if src.dice == ([2]int{}) {
+			rolls = g.shuffledRolls()
+		}
+		for _, dice := range rolls {
+			key := src.id + "/" + fmt.Sprint(dice)
+			if g.asked[key] {
+				continue
+			}
+			p, err := g.problem(src, dice)
+			if err != nil {
+				return nil, err
+			}
+			if p == nil || (difficulty != 0 && p.Difficulty != difficulty) {
+				continue
+			}
+			g.asked[key] = true
+			return p, nil
+		}
+	}
+	return nil, ErrNoQuizProblem
+}
+
+// shuffledRolls returns the 21 distinct rolls in random order.
+// The caller must hold g.mu.
+func (g *QuizGenerator) shuffledRolls() [][2]int {
+	rolls := make([][2]int, 0, 21)
+	for d1 := 1; d1 <= 6; d1++ {
+		for d2 := 1; d2 <= d1; d2++ {
+			rolls = append(rolls, [2]int{d1, d2})
+		}
+	}
+	g.rng.Shuffle(len(rolls), func(i, j int) { rolls[i], rolls[j] = rolls[j], rolls[i] })
+	return rolls
+}
+
+// problem analyzes src with dice and returns it as a problem, or nil if the
+// roll is not interesting enough to ask.
+func (g *QuizGenerator) problem(src *quizSource, dice [2]int) (*QuizProblem, error) {
+	state := &GameState{Board: src.board, CubeValue: 1, CubeOwner: -1}
+	result, err := g.engine.AnalyzePosition(state, dice)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", src.id, err)
+	}
+	gap, ok := quizGap(result, g.opts)
+	if !ok {
+		return nil, nil
+	}
+
+	difficulty := src.difficulty
+	if difficulty == 0 {
+		difficulty = quizDifficulty(gap)
+	}
+	return &QuizProblem{
+		Source:     src.id,
+		Name:       src.name,
+		Board:      src.board,
+		Dice:       dice,
+		Category:   src.category,
+		Difficulty: difficulty,
+		BestEquity: result.BestEquity,
+		Gap:        gap,
+	}, nil
+}
+
+// quizGap returns the equity gap between the best and second-best move of
+// an analysis, and whether it lies in the options' band. Forced moves have
+// no second-best move and are never in the band.
+func quizGap(result *AnalysisResult, opts QuizOptions) (float64, bool) {
+	if len(result.Moves) < 2 {
+		return 0, false
+	}
+	gap := result.Moves[0].Equity - result.Moves[1].Equity
+	return gap, gap >= opts.MinGap && gap <= opts.MaxGap
+}
+
+// quizDifficulty rates a problem with no preset difficulty by how close the
+// runner-up comes to the best move.
+func quizDifficulty(gap float64) int {
+	switch {
+	case gap >= 0.16:
+		return 1
+	case gap >= 0.10:
+		return 2
+	case gap >= 0.06:
+		return 3
+	case gap >= 0.035:
+		return 4
+	default:
+		return 5
+	}
+}
@@ -0,0 +1,189 @@
+package engine
+
+import (
+	"errors"
+	"testing"
+)
+
+// Short bearoffs the even engine plays by game-over equities, so their
+// moves have known gaps.
+var (
+	// 6-2 and 6-3 are in the default band
+	quizBearoffA = boardFromPoints(map[int]uint8{6: 1, 5: 1, 1: 1}, map[int]uint8{6: 1, 2: 1})
+	// 6-3 is in the default band
+	quizBearoffB = boardFromPoints(map[int]uint8{6: 1, 4: 1, 1: 1}, map[int]uint8{3: 1, 2: 1, 1: 1})
+	// Only near-ties (5-2, 6-2) and obvious plays (4-3, 5-3, 6-3)
+	quizNearTie = boardFromPoints(map[int]uint8{5: 1, 4: 1, 2: 1}, map[int]uint8{4: 1, 3: 1})
+	// Every roll is forced
+	quizForced = boardFromPoints(map[int]uint8{1: 1}, map[int]uint8{1: 1})
+)
+
+// quizEngine returns an engine without nets that calls every position even.
+func quizEngine(t *testing.T) *Engine {
+	t.Helper()
+	e, err := NewEngine(EngineOptions{EvenFallback: true})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	return e
+}
+
+func quizEntry(id string, board Board, cat PositionCategory) *PositionEntry {
+	return &PositionEntry{ID: id, Name: id, Board: board, Category: cat}
+}
+
+func TestQuizGap(t *testing.T) {
+	ranked := func(equities ...float64) *AnalysisResult {
+		r := &AnalysisResult{}
+		for _, eq := range equities {
+			r.Moves = append(r.Moves, MoveWithEval{Equity: eq})
+		}
+		return r
+	}
+	opts := DefaultQuizOptions()
+
+	tests := []struct {
+		name   string
+		result *AnalysisResult
+		ok     bool
+	}{
+		{"forced", ranked(0.3), false},
+		{"no move", ranked(), false},
+		{"near tie", ranked(0.3, 0.295, 0.1), false},
+		{"obvious", ranked(0.3, -0.2), false},
+		{"in band", ranked(0.3, 0.22, 0.21), true},
+	}
+	for _, tt := range tests {
+		if _, ok := quizGap(tt.result, opts); ok != tt.ok {
+			t.Errorf("%s: in band = %t, want %t", tt.name, ok, tt.ok)
+		}
+	}
+
+	if err := (QuizOptions{MinGap: 0.3, MaxGap: 0.1}).Validate(); err == nil {
+		t.Error("Validate accepted min gap above max gap")
+	}
+}
+
+func TestQuizGeneratorExcludesForcedAndNearTies(t *testing.T) {
+	e := quizEngine(t)
+
+	g, _ := NewQuizGenerator(e, DefaultQuizOptions())
+	g.AddPositions([]*PositionEntry{
+		quizEntry("forced", quizForced, CategoryBearoff),
+		quizEntry("near-tie", quizNearTie, CategoryBearoff),
+	})
+	if p, err := g.Next(CategoryUnknown, 0); !errors.Is(err, ErrNoQuizProblem) {
+		t.Fatalf("Next = %+v, %v; want ErrNoQuizProblem", p, err)
+	}
+
+	// The near-ties are there, just outside the band
+	g, _ = NewQuizGenerator(e, QuizOptions{MinGap: 0.001, MaxGap: 0.01, Seed: 1})
+	g.AddPositions([]*PositionEntry{quizEntry("near-tie", quizNearTie, CategoryBearoff)})
+	p, err := g.Next(CategoryUnknown, 0)
+	if err != nil {
+		t.Fatalf("Next with a near-tie band: %v", err)
+	}
+	if p.Gap >= 0.01 || p.Difficulty != 5 {
+		t.Errorf("near-tie problem = %+v", p)
+	}
+}
+
+func TestQuizGeneratorSeed(t *testing.T) {
+	e := quizEngine(t)
+	draw := func(seed int64) []*QuizProblem {
+		g, err := NewQuizGenerator(e, QuizOptions{MinGap: 0.02, MaxGap: 0.25, Seed: seed})
+		if err != nil {
+			t.Fatalf("NewQuizGenerator: %v", err)
+		}
+		g.AddPositions([]*PositionEntry{
+			quizEntry("b", quizBearoffB, CategoryRace),
+			quizEntry("a", quizBearoffA, CategoryBearoff),
+			quizEntry("forced", quizForced, CategoryBearoff),
+		})
+		var problems []*QuizProblem
+		for {
+			p, err := g.Next(CategoryUnknown, 0)
+			if errors.Is(err, ErrNoQuizProblem) {
+				return problems
+			}
+			if err != nil {
+				t.Fatalf("Next: %v", err)
+			}
+			problems = append(problems, p)
+		}
+	}
+
+	first, again := draw(42), draw(42)
+	if len(first) != 3 || len(again) != len(first) {
+		t.Fatalf("drew %d and %d problems, want 3 each", len(first), len(again))
+	}
+	seen := make(map[[2]int]string)
+	for i, p := range first {
+		if p.Source != again[i].Source || p.Dice != again[i].Dice {
+			t.Errorf("problem %d: %s %v, then %s %v with the same seed", i, p.Source, p.Dice, again[i].Source, again[i].Dice)
+		}
+		if p.Gap < 0.02 || p.Gap > 0.25 {
+			t.Errorf("problem %s %v has gap %.4f outside the band", p.Source, p.Dice, p.Gap)
+		}
+		if src, ok := seen[p.Dice]; ok && src == p.Source {
+			t.Errorf("problem %s %v asked twice", p.Source, p.Dice)
+		}
+		seen[p.Dice] = p.Source
+	}
+
+	g, _ := NewQuizGenerator(e, QuizOptions{MinGap: 0.02, MaxGap: 0.25, Seed: 7})
+	g.AddPositions([]*PositionEntry{
+		quizEntry("a", quizBearoffA, CategoryBearoff),
+		quizEntry("b", quizBearoffB, CategoryRace),
+	})
+	p, err := g.Next(CategoryRace, 0)
+	if err != nil || p.Source != "b" || p.Dice != [2]int{6, 3} {
+		t.Fatalf("Next(race) = %+v, %v; want b with 63", p, err)
+	}
+	if _, err := g.Next(CategoryRace, 0); !errors.Is(err, ErrNoQuizProblem) {
+		t.Errorf("second race problem: %v, want ErrNoQuizProblem", err)
+	}
+}
+
+func TestQuizGeneratorMoveErrors(t *testing.T) {
+	g, _ := NewQuizGenerator(quizEngine(t), QuizOptions{MinGap: 0.02, MaxGap: 0.25, Seed: 1})
+	err := g.AddMoveErrors([]MoveErrorDetail{
+		{GameNumber: 1, MoveNumber: 4, Position: EncodePositionID(quizBearoffB), Dice: [2]int{6, 3}},
+		{GameNumber: 2, MoveNumber: 9, Position: EncodePositionID(quizBearoffB), Dice: [2]int{4, 3}},
+	})
+	if err != nil {
+		t.Fatalf("AddMoveErrors: %v", err)
+	}
+
+	// Only the 63 is interesting; the 43 is obvious
+	p, err := g.Next(CategoryUnknown, 0)
+	if err != nil {
+		t.Fatalf("Next: %v", err)
+	}
+	if p.Source != "game 1 move 4" || p.Dice != [2]int{6, 3} || p.Difficulty != quizDifficulty(p.Gap) {
+		t.Errorf("problem = %+v", p)
+	}
+	if _, err := g.Next(CategoryUnknown, 0); !errors.Is(err, ErrNoQuizProblem) {
+		t.Errorf("second problem: %v, want ErrNoQuizProblem", err)
+	}
+
+	if err := g.AddMoveErrors([]MoveErrorDetail{{Position: "not an id"}}); err == nil {
+		t.Error("AddMoveErrors accepted an invalid position ID")
+	}
+}
+
+func TestParsePositionCategory(t *testing.T) {
+	for s, want := range map[string]PositionCategory{
+		"holding":     CategoryHolding,
+		"Race":        CategoryRace,
+		"safety_play": CategorySafetyPlay,
+		"safety-play": CategorySafetyPlay,
+	} {
+		if got, err := ParsePositionCategory(s); err != nil || got != want {
+			t.Errorf("ParsePositionCategory(%q) = %v, %v; want %v", s, got, err, want)
+		}
+	}
+	if _, err := ParsePositionCategory("sideways"); err == nil {
+		t.Error("ParsePositionCategory accepted an unknown category")
+	}
+}
@@ -0,0 +1,7 @@
+//go:build race
+
+package engine
+
+// raceEnabled is set under the race detector, whose instrumentation
+// allocates: allocation bounds are skipped.
+const raceEnabled = true
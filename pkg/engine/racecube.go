@@ -0,0 +1,196 @@
+package engine
+
+import (
+	"errors"
+	"math"
+
+	"github.com/yourusername/bgengine/internal/neuralnet"
+)
+
+// ErrNotRace is returned by RaceCubeAnalysis for positions with contact.
+var ErrNotRace = errors.New("position is not a race")
+
+// Race cube verdicts, ordered from the weakest position to the strongest
+const (
+	RaceNotAvailable = "not_available" // The player on roll cannot double
+	RaceNoDouble     = "no_double"
+	RaceDoubleTake   = "double_take"
+	RaceDoublePass   = "double_pass"
+	RaceTooGood      = "too_good"
+)
+
+// raceTableStep and raceTableRows lay out RaceCube.Table: rows raceTableStep
+// pips apart, centered on the current lead.
+const (
+	raceTableStep = 2
+	raceTableRows = 11
+)
+
+// RaceCube places a race on the race cube scale for the player on roll: how
+// far the position is from the minimal double and from the last take, in
+// winning chances and in pips.
+type RaceCube struct {
+	Pips    [2]int  // Pip counts of the player on roll and the opponent
+	Lead    int     // Opponent's pip count less the player's
+	LeadPct float64 // Lead as a percentage of the player's pip count
+	WinProb float64 // Cubeless winning chance of the player on roll
+	Source  string  // Where WinProb comes from: SourceBearoff, SourceNet or SourceHeuristic
+
+	Verdict     string  // One of the Race* verdicts
+	DoublePoint float64 // Least winning chance at which the player should double
+	TakePoint   float64 // Greatest winning chance of the player at which the opponent can still take
+
+	// Pips the lead has to grow by to reach DoublePoint and TakePoint.
+	// Negative values are pips to spare: the lead can shrink by that much.
+	PipsToDouble float64
+	PipsToPass   float64
+
+	Table []RaceCubeRow // Winning chances and cube equities around the current lead
+}
+
+// RaceCubeRow is a line of the equity table of a race: the position as it
+// would be with a different lead, at the same length.
+type RaceCubeRow struct {
+	Lead       int     // Lead in pips
+	WinProb    float64 // Winning chance of the player on roll
+	NoDouble   float64 // Cubeful equity of not doubling
+	DoubleTake float64 // Cubeful equity of double/take
+	Verdict    string  // One of the Race* verdicts
+}
+
+// RaceCubeAnalysis analyzes the cube decision of a race (or a bearoff) for
+// the player on roll by its pip counts. The winning chance comes from the
+// bearoff database or the race net; the double and take points follow from
+// the cube analysis at the position's gammon rates, cube ownership and match
+// score. Pip distances convert winning chances with the race formula behind
+// KeithWinProb. It returns ErrNotRace if there is contact.
+func (e *Engine) RaceCubeAnalysis(state *GameState) (*RaceCube, error) {
+	board := neuralnet.Board(state.Board)
+	if class := neuralnet.ClassifyPosition(board); class != neuralnet.ClassRace && !isBearoffClass(class) {
+		return nil, ErrNotRace
+	}
+	t, err := e.metTable(state.MET)
+	if err != nil {
+		return nil, err
+	}
+	eval, err := e.Evaluate(state)
+	if err != nil {
+		return nil, err
+	}
+
+	pips := PipCount(state.Board)
+	rc := &RaceCube{
+		Pips:    [2]int{pips[1], pips[0]},
+		Lead:    pips[0] - pips[1],
+		WinProb: eval.WinProb,
+		Source:  eval.Source,
+	}
+	if pips[1] > 0 {
+		rc.LeadPct = 100 * float64(rc.Lead) / float64(pips[1])
+	}
+
+	ce := DefaultCubeEfficiency()
+	verdict := func(p float64) string {
+		return raceVerdict(e.cubeAnalysis(state, withWinProb(eval, p), ce, t).DecisionType)
+	}
+	rc.Verdict = raceVerdict(e.cubeAnalysis(state, eval, ce, t).DecisionType)
+	if rc.Verdict == RaceNotAvailable {
+		return rc, nil
+	}
+	rc.DoublePoint = raceThreshold(func(p float64) bool { return raceRank(verdict(p)) >= raceRank(RaceDoubleTake) })
+	rc.TakePoint = raceThreshold(func(p float64) bool { return raceRank(verdict(p)) >= raceRank(RaceDoublePass) })
+
+	// Spread of the race at this length, as in KeithWinProb
+	spread := 1.51 * math.Sqrt(math.Max(float64(KeithCount(state.Board, 0)+KeithCount(state.Board, 1)), 1))
+	lead := raceLead(eval.WinProb, spread)
+	rc.PipsToDouble = raceLead(rc.DoublePoint, spread) - lead
+	rc.PipsToPass = raceLead(rc.TakePoint, spread) - lead
+
+	for i := 0; i < raceTableRows; i++ {
+		shift := (i - raceTableRows/2) * raceTableStep
+		p := normalCDF((lead + float64(shift) + 4) / spread)
+		a := e.cubeAnalysis(state, withWinProb(eval, p), ce, t)
+		rc.Table = append(rc.Table, RaceCubeRow{
+			Lead:       rc.Lead + shift,
+			WinProb:    p,
+			NoDouble:   a.NoDoubleEquity,
+			DoubleTake: a.DoubleTakeEq,
+			Verdict:    raceVerdict(a.DecisionType),
+		})
+	}
+	return rc, nil
+}
+
+// raceVerdict sorts a cube decision into the race verdicts.
+func raceVerdict(t CubeDecisionType) string {
+	switch t {
+	case DOUBLE_TAKE, REDOUBLE_TAKE, DOUBLE_BEAVER, OPTIONAL_DOUBLE_TAKE, OPTIONAL_REDOUBLE_TAKE, OPTIONAL_DOUBLE_BEAVER:
+		return RaceDoubleTake
+	case DOUBLE_PASS, REDOUBLE_PASS, OPTIONAL_DOUBLE_PASS, OPTIONAL_REDOUBLE_PASS:
+		return RaceDoublePass
+	case TOOGOOD_TAKE, TOOGOOD_PASS, TOOGOODRE_TAKE, TOOGOODRE_PASS:
+		return RaceTooGood
+	case NOT_AVAILABLE, NODOUBLE_DEADCUBE, NO_REDOUBLE_DEADCUBE:
+		return RaceNotAvailable
+	default:
+		return RaceNoDouble
+	}
+}
+
+// raceRank orders the race verdicts from the weakest position to the
+// strongest.
+func raceRank(verdict string) int {
+	switch verdict {
+	case RaceNoDouble:
+		return 1
+	case RaceDoubleTake:
+		return 2
+	case RaceDoublePass:
+		return 3
+	case RaceTooGood:
+		return 4
+	default:
+		return 0
+	}
+}
+
+// raceThreshold returns the least winning chance at which ok holds, or 1 if
+// it does not hold even for a sure win. ok must be monotonic.
+func raceThreshold(ok func(p float64) bool) float64 {
+	lo, hi := 0.0, 1.0
+	if !ok(hi) {
+		return 1
+	}
+	for i := 0; i < 30; i++ {
+		mid := (lo + hi) / 2
+		if ok(mid) {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+	return hi
+}
+
+// raceLead inverts raceWinProb: the lead in pips that gives the player on
+// roll winning chance p in a race with the given spread.
+func raceLead(p, spread float64) float64 {
+	p = math.Max(0.0005, math.Min(0.9995, p))
+	return math.Sqrt2*math.Erfinv(2*p-1)*spread - 4
+}
+
+// withWinProb returns eval with winning chance p, keeping the gammon and
+// backgammon rates of wins and losses.
+func withWinProb(eval *Evaluation, p float64) *Evaluation {
+	scaled := *eval
+	scaled.WinProb = p
+	if eval.WinProb > 0 {
+		scaled.WinG = eval.WinG / eval.WinProb * p
+		scaled.WinBG = eval.WinBG / eval.WinProb * p
+	}
+	if eval.WinProb < 1 {
+		scaled.LoseG = eval.LoseG / (1 - eval.WinProb) * (1 - p)
+		scaled.LoseBG = eval.LoseBG / (1 - eval.WinProb) * (1 - p)
+	}
+	return &scaled
+}
@@ -0,0 +1,99 @@
+package engine
+
+import (
+	"errors"
+	"testing"
+)
+
+// raceLadder returns a 105 pip race for the player on roll against an
+// opponent whose pip count is 105+lead, moving the opponent's checkers a pip
+// at a time without making contact.
+func raceLadder(lead int) Board {
+	var b Board
+	for i := 4; i <= 8; i++ {
+		b[0][i], b[1][i] = 3, 3
+	}
+	for ; lead > 0; lead-- {
+		for i := 13; i >= 0; i-- {
+			if b[0][i] > 0 {
+				b[0][i]--
+				b[0][i+1]++
+				break
+			}
+		}
+	}
+	for ; lead < 0; lead++ {
+		for i := 1; i < 24; i++ {
+			if b[0][i] > 0 {
+				b[0][i]--
+				b[0][i-1]++
+				break
+			}
+		}
+	}
+	return b
+}
+
+func TestRaceCubeAnalysis(t *testing.T) {
+	e, err := NewEngine(EngineOptions{SkipWarmup: true})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	firstDouble, firstPass := 0, 0
+	prev := 0
+	for lead := -20; lead <= 20; lead++ {
+		state := &GameState{Board: raceLadder(lead), CubeValue: 1, CubeOwner: -1}
+		rc, err := e.RaceCubeAnalysis(state)
+		if err != nil {
+			t.Fatalf("lead %d: %v", lead, err)
+		}
+		if rc.Pips != [2]int{105, 105 + lead} || rc.Lead != lead {
+			t.Fatalf("lead %d: pips %v, lead %d", lead, rc.Pips, rc.Lead)
+		}
+		rank := raceRank(rc.Verdict)
+		if rank < prev {
+			t.Errorf("lead %d: verdict %s after a stronger one", lead, rc.Verdict)
+		}
+		prev = rank
+		if rank >= raceRank(RaceDoubleTake) && firstDouble == 0 {
+			firstDouble = lead
+			if rc.PipsToDouble > 0 {
+				t.Errorf("lead %d: first double with %.1f pips to go", lead, rc.PipsToDouble)
+			}
+		}
+		if rank >= raceRank(RaceDoublePass) && firstPass == 0 {
+			firstPass = lead
+			if rc.PipsToPass > 0 {
+				t.Errorf("lead %d: first pass with %.1f pips to go", lead, rc.PipsToPass)
+			}
+		}
+		if rc.DoublePoint >= rc.TakePoint || len(rc.Table) != raceTableRows || rc.Table[raceTableRows/2].Lead != lead {
+			t.Errorf("lead %d: %+v", lead, rc)
+		}
+	}
+
+	// Around 105 pips the minimal double is a lead of about 8% and the last
+	// take about 12%
+	if firstDouble < 4 || firstDouble > 11 {
+		t.Errorf("first double at a lead of %d pips", firstDouble)
+	}
+	if firstPass <= firstDouble || firstPass > 18 {
+		t.Errorf("first pass at a lead of %d pips (first double at %d)", firstPass, firstDouble)
+	}
+
+	// Owning the cube the player can wait longer
+	owned := &GameState{Board: raceLadder(firstDouble), CubeValue: 2, CubeOwner: 0}
+	if rc, err := e.RaceCubeAnalysis(owned); err != nil || rc.DoublePoint <= 0 {
+		t.Errorf("owned cube: %+v, %v", rc, err)
+	} else if centered, _ := e.RaceCubeAnalysis(&GameState{Board: raceLadder(firstDouble), CubeValue: 1, CubeOwner: -1}); rc.DoublePoint <= centered.DoublePoint {
+		t.Errorf("redouble point %.3f, want above the initial double point %.3f", rc.DoublePoint, centered.DoublePoint)
+	}
+	if rc, err := e.RaceCubeAnalysis(&GameState{Board: raceLadder(10), CubeValue: 2, CubeOwner: 1}); err != nil || rc.Verdict != RaceNotAvailable {
+		t.Errorf("opponent's cube: %+v, %v", rc, err)
+	}
+
+	if _, err := e.RaceCubeAnalysis(StartingPosition()); !errors.Is(err, ErrNotRace) {
+		t.Errorf("starting position: error %v, want ErrNotRace", err)
+	}
+}
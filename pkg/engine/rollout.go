@@ -1,10 +1,14 @@
 package engine
 
 import (
+	"context"
+	"fmt"
 	"math"
 	"math/rand"
 	"runtime"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // RolloutOptions controls rollout execution
@@ -14,6 +18,106 @@ type RolloutOptions struct {
 	Seed     int64 // RNG seed (0 = use current time)
 	Workers  int   // Number of parallel workers (0 = GOMAXPROCS)
 	Cubeful  bool  // Include cube decisions in rollout
+
+	FirstRoll FirstRollRule // How the first turn of each trial is rolled (default FirstRollAuto)
+
+	// InitialDice are dice already rolled in the position: every trial
+	// starts with the side on roll playing its best move for them, and the
+	// dice after that are random. Zero rolls the first turn as FirstRoll
+	// says. Setting them is the same as setting the state's dice with
+	// FirstRollAlreadyRolled.
+	InitialDice [2]int
+
+	// LeafPly is the depth, 0 to MaxLeafPly, of the evaluation that scores a
+	// trial stopped by Truncate. A deeper leaf costs more per trial but is
+	// less biased, so the trials can be truncated sooner for the same
+	// accuracy. Leaf evaluations go through the engine's evaluation cache:
+	// trials truncated at the same ply reach the same positions again.
+	LeafPly int
+
+	// LeafCubeful scores truncated trials by the cubeful money equity of the
+	// leaf, from Janowski's formula with the cube as it is in the position
+	// rolled out, instead of its cubeless equity. The probabilities stay
+	// cubeless.
+	LeafCubeful bool
+
+	// DiceMode says how the dice of the trials are drawn (default
+	// DiceModeAuto).
+	DiceMode DiceMode
+
+	// wrapDice, if set, wraps the dice of each trial, so that tests can
+	// record the rolls a rollout makes.
+	wrapDice func(trial int, d diceSource) diceSource
+}
+
+// MaxLeafPly is the deepest leaf evaluation of a truncated rollout.
+const MaxLeafPly = 2
+
+// FirstRollRule says how the first turn of a rollout trial is rolled.
+type FirstRollRule int
+
+const (
+	// FirstRollAuto uses FirstRollStandard for the starting position with no
+	// dice set, and FirstRollNone otherwise.
+	FirstRollAuto FirstRollRule = iota
+	// FirstRollNone rolls the first turn like any other: the side on roll
+	// moves first, and may roll a double.
+	FirstRollNone
+	// FirstRollStandard plays an opening roll: each side rolls one die, ties
+	// are rolled again, and the side with the higher die moves first using
+	// both dice. The opening roll is never a double.
+	FirstRollStandard
+	// FirstRollAlreadyRolled plays the state's dice as the opening roll,
+	// moved by the side on roll.
+	FirstRollAlreadyRolled
+)
+
+// resolve returns the rule used for state, replacing FirstRollAuto.
+func (r FirstRollRule) resolve(state *GameState) FirstRollRule {
+	if r != FirstRollAuto {
+		return r
+	}
+	if state.Dice == [2]int{} && EqualBoards(state.Board, StartingPosition().Board) {
+		return FirstRollStandard
+	}
+	return FirstRollNone
+}
+
+// DiceMode says how the dice of rollout trials are drawn.
+type DiceMode string
+
+const (
+	// DiceModeAuto uses DiceExhaustive2Roll unless the first roll rule is
+	// FirstRollStandard, and DiceRandom then.
+	DiceModeAuto DiceMode = ""
+	// DiceRandom draws every roll of a trial from the trial's own random
+	// source (see trialSeed).
+	DiceRandom DiceMode = "random"
+	// DiceExhaustive2Roll plays the first two rolls of trial t that are not
+	// already rolled as the two-roll sequence t mod 1296 of an enumeration of
+	// the 36*36 ordered pairs of rolls, and draws the rest at random. Any
+	// 1296 consecutive trials play each sequence exactly once, and each block
+	// of 36 trials from a multiple of 36 rolls each of the 36 rolls once
+	// first and once second. The sequence of a trial depends only on its
+	// number, so it does not depend on the number of workers. It cannot be
+	// used with FirstRollStandard, whose opening roll is never a double.
+	DiceExhaustive2Roll DiceMode = "exhaustive-2roll"
+)
+
+// twoRollSequences is the number of ordered sequences of two rolls, the
+// trials DiceExhaustive2Roll takes to play each once.
+const twoRollSequences = 36 * 36
+
+// resolve returns the mode used with rule (already resolved), replacing
+// DiceModeAuto.
+func (m DiceMode) resolve(rule FirstRollRule) DiceMode {
+	if m != DiceModeAuto {
+		return m
+	}
+	if rule == FirstRollStandard {
+		return DiceRandom
+	}
+	return DiceExhaustive2Roll
 }
 
 // RolloutProgress contains progress information during a rollout
@@ -31,36 +135,64 @@ type ProgressCallback func(progress RolloutProgress)
 // RolloutResult contains the results of a rollout
 type RolloutResult struct {
 	// Average probabilities
-	WinProb float64
-	WinG    float64
-	WinBG   float64
-	LoseG   float64
-	LoseBG  float64
-	Equity  float64
+	WinProb float64 `json:"win_prob"`
+	WinG    float64 `json:"win_g"`
+	WinBG   float64 `json:"win_bg"`
+	LoseG   float64 `json:"lose_g"`
+	LoseBG  float64 `json:"lose_bg"`
+	Equity  float64 `json:"equity"`
 
 	// Standard deviations
-	WinProbStdDev float64
-	WinGStdDev    float64
-	WinBGStdDev   float64
-	LoseGStdDev   float64
-	LoseBGStdDev  float64
-	EquityStdDev  float64
+	WinProbStdDev float64 `json:"win_prob_std_dev"`
+	WinGStdDev    float64 `json:"win_g_std_dev"`
+	WinBGStdDev   float64 `json:"win_bg_std_dev"`
+	LoseGStdDev   float64 `json:"lose_g_std_dev"`
+	LoseBGStdDev  float64 `json:"lose_bg_std_dev"`
+	EquityStdDev  float64 `json:"equity_std_dev"`
 
 	// Confidence interval (95%)
-	EquityCI float64
+	EquityCI float64 `json:"equity_ci"`
 
 	// Statistics
-	TrialsCompleted int
-	GamesWon        int
-	GammonsWon      int
-	BackgammonsWon  int
-	GamesLost       int
-	GammonsLost     int
-	BackgammonsLost int
+	TrialsCompleted int `json:"trials_completed"` // Trials 0..TrialsCompleted-1 have been played
+	GamesWon        int `json:"games_won"`
+	GammonsWon      int `json:"gammons_won"`
+	BackgammonsWon  int `json:"backgammons_won"`
+	GamesLost       int `json:"games_lost"`
+	GammonsLost     int `json:"gammons_lost"`
+	BackgammonsLost int `json:"backgammons_lost"`
+
+	// Accumulated sums the averages are derived from, kept so the rollout
+	// can be continued with RolloutExtend
+	SumProbs    [5]float64 `json:"sum_probs"`    // WinProb, WinG, WinBG, LoseG, LoseBG
+	SumSqProbs  [5]float64 `json:"sum_sq_probs"` // Sum of squares for variance
+	SumEquity   float64    `json:"sum_equity"`
+	SumSqEquity float64    `json:"sum_sq_equity"`
+
+	// Settings the trials were played with
+	Seed        int64         `json:"seed"` // Drawn at random for a rollout asked for with seed 0
+	Truncate    int           `json:"truncate"`
+	FirstRoll   FirstRollRule `json:"first_roll,omitempty"`
+	LeafPly     int           `json:"leaf_ply,omitempty"`
+	LeafCubeful bool          `json:"leaf_cubeful,omitempty"`
+	DiceMode    DiceMode      `json:"dice_mode"` // DiceRandom or DiceExhaustive2Roll; empty for rollouts stored before it was kept, which are DiceRandom
+
+	// Manifest records what is needed to play the trials again. Rollouts
+	// stored before manifests were kept have none.
+	Manifest *RolloutManifest `json:"manifest,omitempty"`
+
+	// Set when the result came from the engine's RolloutStore
+	Cached   bool      `json:"-"` // No trials were played for this request
+	StoredAt time.Time `json:"-"` // When the stored rollout was first made
+
+	// Throughput of the trials played for this result, not kept with it
+	Elapsed         time.Duration `json:"-"` // Time spent playing the trials
+	TrialsPerSecond float64       `json:"-"` // Trials played per second
+	AvgPlies        float64       `json:"-"` // Average plies per trial played
 }
 
-// partialResult holds results from a single worker
-type partialResult struct {
+// rolloutSums accumulates trial results
+type rolloutSums struct {
 	sumProbs    [5]float64 // WinProb, WinG, WinBG, LoseG, LoseBG
 	sumSqProbs  [5]float64 // Sum of squares for variance
 	sumEquity   float64
@@ -77,7 +209,7 @@ type partialResult struct {
 // DefaultRolloutOptions returns sensible defaults
 func DefaultRolloutOptions() RolloutOptions {
 	return RolloutOptions{
-		Trials:   1296, // 36^2 for full dice coverage
+		Trials:   1296, // Each two-roll sequence once (see DiceExhaustive2Roll)
 		Truncate: 0,    // Play to completion
 		Seed:     0,    // Random seed
 		Workers:  0,    // Use all cores
@@ -86,308 +218,486 @@ func DefaultRolloutOptions() RolloutOptions {
 }
 
 // Rollout performs a Monte Carlo rollout of the position
+// With a RolloutStore configured, a stored rollout of the same position and
+// settings is returned or extended instead (see storedRollout).
 func (e *Engine) Rollout(state *GameState, opts RolloutOptions) (*RolloutResult, error) {
-	// Set defaults
+	return e.storedRollout(context.Background(), state, opts, nil)
+}
+
+// RolloutWithProgress performs a rollout with periodic progress callbacks
+// The callback is called after each batch of trials completes
+func (e *Engine) RolloutWithProgress(state *GameState, opts RolloutOptions, callback ProgressCallback) (*RolloutResult, error) {
+	return e.storedRollout(context.Background(), state, opts, callback)
+}
+
+// RolloutContext is RolloutWithProgress stopped early when ctx is done: the
+// workers start no more trials, the rollout returns ctx.Err() once the
+// trials being played finish, and nothing is stored.
+func (e *Engine) RolloutContext(ctx context.Context, state *GameState, opts RolloutOptions, callback ProgressCallback) (*RolloutResult, error) {
+	return e.storedRollout(ctx, state, opts, callback)
+}
+
+// RolloutStore returns the engine's rollout store, or nil if it has none.
+func (e *Engine) RolloutStore() RolloutStore {
+	return e.rolloutStore
+}
+
+// storedRollout runs a rollout through the rollout store. A stored result with
+// at least opts.Trials trials is returned as it is, marked Cached; a shorter
+// one is extended with RolloutExtend by the missing trials and stored again.
+// Rollouts of the same key are serialized, so two requests for one position
+// play its trials once.
+func (e *Engine) storedRollout(ctx context.Context, state *GameState, opts RolloutOptions, callback ProgressCallback) (*RolloutResult, error) {
+	state, opts, err := opts.withInitialDice(state)
+	if err != nil {
+		return nil, err
+	}
+	if e.rolloutStore == nil {
+		return e.rollout(ctx, state, opts, nil, callback)
+	}
 	if opts.Trials <= 0 {
 		opts.Trials = 1296
 	}
-	if opts.Workers <= 0 {
-		opts.Workers = runtime.GOMAXPROCS(0)
+
+	key := NewRolloutKey(state, opts)
+	mu := &e.rolloutLocks[key.hash()%uint64(len(e.rolloutLocks))]
+	mu.Lock()
+	defer mu.Unlock()
+
+	stored, err := e.rolloutStore.Get(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rollout store: %w", err)
+	}
+
+	now := time.Now()
+	entry := &StoredRollout{Key: key, Created: now, Updated: now}
+	var prior *RolloutResult
+	if stored != nil {
+		entry.Created = stored.Created
+		prior = stored.Result
+		if prior.TrialsCompleted >= opts.Trials {
+			result := *prior
+			result.DiceMode = prior.diceMode()
+			result.Cached = true
+			result.StoredAt = stored.Created
+			result.setThroughput(0, 0, 0)
+			if callback != nil {
+				callback(RolloutProgress{
+					TrialsCompleted: result.TrialsCompleted,
+					TrialsTotal:     result.TrialsCompleted,
+					Percent:         100,
+					CurrentEquity:   result.Equity,
+					CurrentCI:       result.EquityCI,
+				})
+			}
+			return &result, nil
+		}
+		opts.Trials -= prior.TrialsCompleted
+		opts.Seed = prior.Seed
+		opts.DiceMode = prior.diceMode()
 	}
-	if opts.Seed == 0 {
-		opts.Seed = rand.Int63()
+
+	result, err := e.rollout(ctx, state, opts, prior, callback)
+	if err != nil {
+		return nil, err
+	}
+	entry.Result = result
+	if err := e.rolloutStore.Put(entry); err != nil {
+		return nil, err
 	}
+	result.StoredAt = entry.Created
+	return result, nil
+}
 
-	// Distribute trials across workers
-	trialsPerWorker := opts.Trials / opts.Workers
-	extraTrials := opts.Trials % opts.Workers
+// RolloutExtend continues a previous rollout of the same position with
+// opts.Trials more trials. The seed, truncation, leaf evaluation and dice
+// mode are taken from prior, and
+// the new trials carry on from prior.TrialsCompleted, so extending an N-trial
+// rollout by M trials gives exactly the result of an (N+M)-trial rollout with
+// the same seed. A nil prior starts a new rollout.
+func (e *Engine) RolloutExtend(state *GameState, opts RolloutOptions, prior *RolloutResult) (*RolloutResult, error) {
+	state, opts, err := opts.withInitialDice(state)
+	if err != nil {
+		return nil, err
+	}
+	if prior == nil {
+		return e.rollout(context.Background(), state, opts, nil, nil)
+	}
+	if opts.Seed != 0 && opts.Seed != prior.Seed {
+		return nil, fmt.Errorf("seed %d does not match the prior rollout's seed %d", opts.Seed, prior.Seed)
+	}
+	if opts.Truncate != 0 && opts.Truncate != prior.Truncate {
+		return nil, fmt.Errorf("truncate %d does not match the prior rollout's truncate %d", opts.Truncate, prior.Truncate)
+	}
+	if opts.LeafPly != 0 && opts.LeafPly != prior.LeafPly {
+		return nil, fmt.Errorf("leaf ply %d does not match the prior rollout's leaf ply %d", opts.LeafPly, prior.LeafPly)
+	}
+	if opts.DiceMode != DiceModeAuto && opts.DiceMode != prior.diceMode() {
+		return nil, fmt.Errorf("dice mode %s does not match the prior rollout's dice mode %s", opts.DiceMode, prior.diceMode())
+	}
+	if prior.FirstRoll != FirstRollAuto {
+		opts.FirstRoll = prior.FirstRoll
+	}
+	opts.DiceMode = prior.diceMode()
+	opts.Seed = prior.Seed
+	opts.Truncate = prior.Truncate
+	opts.LeafPly = prior.LeafPly
+	opts.LeafCubeful = prior.LeafCubeful
+	return e.rollout(context.Background(), state, opts, prior, nil)
+}
 
-	// Channel for collecting results
-	results := make(chan partialResult, opts.Workers)
-	var wg sync.WaitGroup
+// rollout plays opts.Trials trials after those in prior (if any), unless ctx
+// is done first.
+// Each trial's dice come from its number: its place in the two-roll
+// enumeration and its own seed (see DiceMode and trialSeed). The results are
+// accumulated in trial order, so the result does not depend on the number of
+// workers or on how the trials were scheduled.
+func (e *Engine) rollout(ctx context.Context, state *GameState, opts RolloutOptions, prior *RolloutResult, callback ProgressCallback) (*RolloutResult, error) {
+	opts, err := opts.withDefaults(state)
+	if err != nil {
+		return nil, err
+	}
 
-	// Launch workers
-	for i := 0; i < opts.Workers; i++ {
-		wg.Add(1)
-		workerTrials := trialsPerWorker
-		if i < extraTrials {
-			workerTrials++
+	var sums rolloutSums
+	if prior != nil {
+		sums = sumsOf(prior)
+	}
+	first := sums.trials
+
+	// Report progress approximately 20 times during the rollout
+	batchSize := opts.Trials / 20
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	progress := sums
+	completed := 0
+	var onTrial func(Evaluation)
+	if callback != nil {
+		onTrial = func(outcome Evaluation) {
+			progress.add(outcome)
+			completed++
+			if completed%batchSize == 0 || completed == opts.Trials {
+				n := float64(progress.trials)
+				stdDev := calcStdDev(progress.sumEquity, progress.sumSqEquity, n)
+				callback(RolloutProgress{
+					TrialsCompleted: completed,
+					TrialsTotal:     opts.Trials,
+					Percent:         100.0 * float64(completed) / float64(opts.Trials),
+					CurrentEquity:   progress.sumEquity / n,
+					CurrentCI:       1.96 * stdDev / math.Sqrt(n),
+				})
+			}
 		}
-		workerSeed := opts.Seed + int64(i)*1000000
+	}
+	start := time.Now()
+	outcomes, plies, err := e.playTrials(ctx, state, opts, first, onTrial)
+	if err != nil {
+		return nil, err
+	}
+	elapsed := time.Since(start)
 
-		go func(trials int, seed int64) {
-			defer wg.Done()
-			results <- e.rolloutWorker(state, trials, seed, opts.Truncate, opts.Cubeful)
-		}(workerTrials, workerSeed)
+	// Accumulate in trial order so the sums are reproducible
+	for _, outcome := range outcomes {
+		sums.add(outcome)
 	}
 
-	// Close channel when all workers done
-	go func() {
-		wg.Wait()
-		close(results)
-	}()
+	result := sums.result()
+	result.Seed = opts.Seed
+	result.Truncate = opts.Truncate
+	result.FirstRoll = opts.FirstRoll
+	result.LeafPly = opts.LeafPly
+	result.LeafCubeful = opts.LeafCubeful
+	result.DiceMode = opts.DiceMode
+	result.Manifest = e.rolloutManifest(state, opts, result)
+	result.setThroughput(len(outcomes), plies, elapsed)
+	return result, nil
+}
 
-	// Aggregate results
-	return e.aggregateResults(results, opts.Trials)
+// setThroughput records that trials trials of plies plies in all were played
+// in elapsed.
+func (r *RolloutResult) setThroughput(trials, plies int, elapsed time.Duration) {
+	r.Elapsed, r.TrialsPerSecond, r.AvgPlies = elapsed, 0, 0
+	if trials == 0 {
+		return
+	}
+	if elapsed > 0 {
+		r.TrialsPerSecond = float64(trials) / elapsed.Seconds()
+	}
+	r.AvgPlies = float64(plies) / float64(trials)
 }
 
-// RolloutWithProgress performs a rollout with periodic progress callbacks
-// The callback is called after each batch of trials completes
-func (e *Engine) RolloutWithProgress(state *GameState, opts RolloutOptions, callback ProgressCallback) (*RolloutResult, error) {
-	// Set defaults
+// withInitialDice returns state with opts.InitialDice as its dice and opts
+// with the first roll rule that plays them, or state and opts as they are
+// without initial dice.
+func (opts RolloutOptions) withInitialDice(state *GameState) (*GameState, RolloutOptions, error) {
+	if opts.InitialDice == [2]int{} {
+		return state, opts, nil
+	}
+	if !validDice(opts.InitialDice) {
+		return state, opts, fmt.Errorf("initial dice must be 1-6, got %v", opts.InitialDice)
+	}
+	if opts.FirstRoll != FirstRollAuto && opts.FirstRoll != FirstRollAlreadyRolled {
+		return state, opts, fmt.Errorf("initial dice need the first roll rule AlreadyRolled, got %d", opts.FirstRoll)
+	}
+	rolled := *state
+	rolled.Dice = opts.InitialDice
+	opts.FirstRoll = FirstRollAlreadyRolled
+	opts.InitialDice = [2]int{}
+	return &rolled, opts, nil
+}
+
+// withDefaults fills in the defaults of unset options for a rollout of state
+// and checks the first roll rule and the checker totals against it.
+func (opts RolloutOptions) withDefaults(state *GameState) (RolloutOptions, error) {
+	if err := state.CheckCheckers(); err != nil {
+		return opts, err
+	}
 	if opts.Trials <= 0 {
 		opts.Trials = 1296
 	}
 	if opts.Workers <= 0 {
 		opts.Workers = runtime.GOMAXPROCS(0)
 	}
+	if opts.Workers > opts.Trials {
+		opts.Workers = opts.Trials
+	}
 	if opts.Seed == 0 {
 		opts.Seed = rand.Int63()
 	}
-
-	// For progress reporting, we break trials into batches
-	// Report progress approximately 20 times during the rollout
-	batchSize := opts.Trials / 20
-	if batchSize < 1 {
-		batchSize = 1
+	if opts.LeafPly < 0 || opts.LeafPly > MaxLeafPly {
+		return opts, fmt.Errorf("leaf ply must be 0-%d, got %d", MaxLeafPly, opts.LeafPly)
 	}
-	if batchSize > opts.Trials/opts.Workers {
-		batchSize = opts.Trials / opts.Workers
+	opts.FirstRoll = opts.FirstRoll.resolve(state)
+	if opts.FirstRoll == FirstRollAlreadyRolled && !validDice(state.Dice) {
+		return opts, fmt.Errorf("first roll rule AlreadyRolled needs dice in the state, got %v", state.Dice)
 	}
-	if batchSize < 1 {
-		batchSize = 1
+	opts.DiceMode = opts.DiceMode.resolve(opts.FirstRoll)
+	switch opts.DiceMode {
+	case DiceRandom:
+	case DiceExhaustive2Roll:
+		if opts.FirstRoll == FirstRollStandard {
+			return opts, fmt.Errorf("dice mode %s cannot be used with the standard opening roll", opts.DiceMode)
+		}
+	default:
+		return opts, fmt.Errorf("unknown dice mode %q", opts.DiceMode)
 	}
+	return opts, nil
+}
 
-	// Channel for collecting incremental results
-	incrementalResults := make(chan partialResult, opts.Workers*20)
-	var wg sync.WaitGroup
+// diceMode returns the dice mode r was played with.
+func (r *RolloutResult) diceMode() DiceMode {
+	if r.DiceMode == "" {
+		return DiceRandom
+	}
+	return r.DiceMode
+}
 
-	// Launch workers with batch reporting
-	trialsPerWorker := opts.Trials / opts.Workers
-	extraTrials := opts.Trials % opts.Workers
+// playTrials plays opts.Trials trials numbered from first and returns their
+// outcomes in trial order, and the plies played in all. opts must have its
+// defaults filled in. onTrial, if not nil, is called with each outcome as its
+// trial completes, from a single goroutine. If ctx is done before all trials
+// are played, the workers stop after the trials they are playing and
+// playTrials returns ctx.Err().
+func (e *Engine) playTrials(ctx context.Context, state *GameState, opts RolloutOptions, first int, onTrial func(Evaluation)) ([]Evaluation, int, error) {
+	// Workers take trials in turn and report each one as it completes
+	outcomes := make([]Evaluation, opts.Trials)
+	done := make(chan int, opts.Trials)
+	var next int64 = -1
+	var plies int64
+	var wg sync.WaitGroup
 
-	for i := 0; i < opts.Workers; i++ {
+	for w := 0; w < opts.Workers; w++ {
 		wg.Add(1)
-		workerTrials := trialsPerWorker
-		if i < extraTrials {
-			workerTrials++
-		}
-		workerSeed := opts.Seed + int64(i)*1000000
-
-		go func(trials int, seed int64, batch int) {
+		go func() {
 			defer wg.Done()
-			e.rolloutWorkerWithProgress(state, trials, seed, opts.Truncate, opts.Cubeful, batch, incrementalResults)
-		}(workerTrials, workerSeed, batchSize)
+			rng := rand.New(rand.NewSource(opts.Seed))
+			for {
+				i := int(atomic.AddInt64(&next, 1))
+				if i >= opts.Trials || ctx.Err() != nil {
+					return
+				}
+				rng.Seed(trialSeed(opts.Seed, first+i))
+				var n int
+				dice := newTrialDice(opts, first+i, rng)
+				if opts.wrapDice != nil {
+					dice = opts.wrapDice(first+i, dice)
+				}
+				outcomes[i], n = e.playOutGame(state, dice, opts)
+				atomic.AddInt64(&plies, int64(n))
+				done <- i
+			}
+		}()
 	}
 
 	// Close channel when all workers done
 	go func() {
 		wg.Wait()
-		close(incrementalResults)
+		close(done)
 	}()
 
-	// Aggregate results with progress reporting
-	return e.aggregateResultsWithProgress(incrementalResults, opts.Trials, callback)
+	for i := range done {
+		if onTrial != nil && ctx.Err() == nil {
+			onTrial(outcomes[i])
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+	return outcomes, int(plies), nil
+}
+
+// trialSeed derives the dice seed for a trial from the rollout seed and the
+// trial index (a splitmix64 step), so that trials never share dice and any
+// trial can be replayed on its own.
+func trialSeed(seed int64, trial int) int64 {
+	z := uint64(seed) + uint64(trial+1)*0x9e3779b97f4a7c15
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return int64(z ^ (z >> 31))
 }
 
-// rolloutWorkerWithProgress performs rollouts and reports progress in batches
-func (e *Engine) rolloutWorkerWithProgress(state *GameState, trials int, seed int64, truncate int, cubeful bool, batchSize int, results chan<- partialResult) {
-	rng := rand.New(rand.NewSource(seed))
+// diceSource rolls the dice of a rollout trial.
+type diceSource interface {
+	roll() [2]int
+}
 
-	for trialsRemaining := trials; trialsRemaining > 0; {
-		// Process a batch
-		currentBatch := batchSize
-		if currentBatch > trialsRemaining {
-			currentBatch = trialsRemaining
-		}
+// trialDice rolls the dice of one trial: the rolls of its two-roll sequence
+// first, if it has one, then rolls drawn from rng.
+type trialDice struct {
+	rng      *rand.Rand
+	sequence [2][2]int
+	fixed    int // Rolls of sequence not yet rolled
+}
 
-		pr := partialResult{}
-		for i := 0; i < currentBatch; i++ {
-			result := e.playOutGame(state, rng, truncate, cubeful)
-
-			pr.sumProbs[0] += result.WinProb
-			pr.sumProbs[1] += result.WinG
-			pr.sumProbs[2] += result.WinBG
-			pr.sumProbs[3] += result.LoseG
-			pr.sumProbs[4] += result.LoseBG
-
-			pr.sumSqProbs[0] += result.WinProb * result.WinProb
-			pr.sumSqProbs[1] += result.WinG * result.WinG
-			pr.sumSqProbs[2] += result.WinBG * result.WinBG
-			pr.sumSqProbs[3] += result.LoseG * result.LoseG
-			pr.sumSqProbs[4] += result.LoseBG * result.LoseBG
-
-			pr.sumEquity += result.Equity
-			pr.sumSqEquity += result.Equity * result.Equity
-			pr.trials++
-
-			if result.WinProb > 0.5 {
-				pr.wins++
-				if result.WinBG > 0 {
-					pr.bgsWon++
-				} else if result.WinG > 0 {
-					pr.gammonsWon++
-				}
-			} else {
-				pr.losses++
-				if result.LoseBG > 0 {
-					pr.bgsLost++
-				} else if result.LoseG > 0 {
-					pr.gammonsLost++
-				}
-			}
-		}
+func (d *trialDice) roll() [2]int {
+	if d.fixed > 0 {
+		dice := d.sequence[len(d.sequence)-d.fixed]
+		d.fixed--
+		return dice
+	}
+	return [2]int{d.rng.Intn(6) + 1, d.rng.Intn(6) + 1}
+}
 
-		results <- pr
-		trialsRemaining -= currentBatch
+// newTrialDice returns the dice of trial number trial of a rollout played
+// with opts, whose defaults are filled in, drawing from rng once the trial's
+// sequence is rolled.
+func newTrialDice(opts RolloutOptions, trial int, rng *rand.Rand) diceSource {
+	d := &trialDice{rng: rng}
+	if opts.DiceMode == DiceExhaustive2Roll {
+		d.sequence[0], d.sequence[1] = twoRollSequence(trial)
+		d.fixed = len(d.sequence)
 	}
+	return d
 }
 
-// aggregateResultsWithProgress combines results and calls progress callback
-func (e *Engine) aggregateResultsWithProgress(results chan partialResult, totalTrials int, callback ProgressCallback) (*RolloutResult, error) {
-	var (
-		sumProbs                     [5]float64
-		sumSqProbs                   [5]float64
-		sumEquity                    float64
-		sumSqEquity                  float64
-		trials                       int
-		wins, gammonsWon, bgsWon     int
-		losses, gammonsLost, bgsLost int
-	)
+// twoRollSequence returns the two rolls DiceExhaustive2Roll plays in trial.
+// The first roll runs through the 36 rolls with each block of 36 trials, and
+// the second is shifted by one more in each block, so 1296 trials pair each
+// first roll with each second roll.
+func twoRollSequence(trial int) (first, second [2]int) {
+	s := trial % twoRollSequences
+	a := s % 36
+	b := (s/36 + a) % 36
+	return [2]int{a/6 + 1, a%6 + 1}, [2]int{b/6 + 1, b%6 + 1}
+}
 
-	for pr := range results {
-		for i := 0; i < 5; i++ {
-			sumProbs[i] += pr.sumProbs[i]
-			sumSqProbs[i] += pr.sumSqProbs[i]
+// add accumulates the result of one trial
+func (s *rolloutSums) add(result Evaluation) {
+	// Accumulate probabilities
+	s.sumProbs[0] += result.WinProb
+	s.sumProbs[1] += result.WinG
+	s.sumProbs[2] += result.WinBG
+	s.sumProbs[3] += result.LoseG
+	s.sumProbs[4] += result.LoseBG
+
+	s.sumSqProbs[0] += result.WinProb * result.WinProb
+	s.sumSqProbs[1] += result.WinG * result.WinG
+	s.sumSqProbs[2] += result.WinBG * result.WinBG
+	s.sumSqProbs[3] += result.LoseG * result.LoseG
+	s.sumSqProbs[4] += result.LoseBG * result.LoseBG
+
+	s.sumEquity += result.Equity
+	s.sumSqEquity += result.Equity * result.Equity
+	s.trials++
+
+	// Count outcomes
+	if result.WinProb > 0.5 {
+		s.wins++
+		if result.WinBG > 0 {
+			s.bgsWon++
+		} else if result.WinG > 0 {
+			s.gammonsWon++
 		}
-		sumEquity += pr.sumEquity
-		sumSqEquity += pr.sumSqEquity
-		trials += pr.trials
-		wins += pr.wins
-		gammonsWon += pr.gammonsWon
-		bgsWon += pr.bgsWon
-		losses += pr.losses
-		gammonsLost += pr.gammonsLost
-		bgsLost += pr.bgsLost
-
-		// Call progress callback
-		if callback != nil && trials > 0 {
-			n := float64(trials)
-			currentEquity := sumEquity / n
-			currentStdDev := calcStdDev(sumEquity, sumSqEquity, n)
-			currentCI := 1.96 * currentStdDev / math.Sqrt(n)
-
-			callback(RolloutProgress{
-				TrialsCompleted: trials,
-				TrialsTotal:     totalTrials,
-				Percent:         100.0 * float64(trials) / float64(totalTrials),
-				CurrentEquity:   currentEquity,
-				CurrentCI:       currentCI,
-			})
+	} else {
+		s.losses++
+		if result.LoseBG > 0 {
+			s.bgsLost++
+		} else if result.LoseG > 0 {
+			s.gammonsLost++
 		}
 	}
+}
 
-	n := float64(trials)
-	if n == 0 {
-		return &RolloutResult{}, nil
-	}
-
-	// Calculate means
-	result := &RolloutResult{
-		WinProb:         sumProbs[0] / n,
-		WinG:            sumProbs[1] / n,
-		WinBG:           sumProbs[2] / n,
-		LoseG:           sumProbs[3] / n,
-		LoseBG:          sumProbs[4] / n,
-		Equity:          sumEquity / n,
-		TrialsCompleted: trials,
-		GamesWon:        wins,
-		GammonsWon:      gammonsWon,
-		BackgammonsWon:  bgsWon,
-		GamesLost:       losses,
-		GammonsLost:     gammonsLost,
-		BackgammonsLost: bgsLost,
-	}
-
-	// Calculate standard deviations
-	if n > 1 {
-		result.WinProbStdDev = calcStdDev(sumProbs[0], sumSqProbs[0], n)
-		result.WinGStdDev = calcStdDev(sumProbs[1], sumSqProbs[1], n)
-		result.WinBGStdDev = calcStdDev(sumProbs[2], sumSqProbs[2], n)
-		result.LoseGStdDev = calcStdDev(sumProbs[3], sumSqProbs[3], n)
-		result.LoseBGStdDev = calcStdDev(sumProbs[4], sumSqProbs[4], n)
-		result.EquityStdDev = calcStdDev(sumEquity, sumSqEquity, n)
-		result.EquityCI = 1.96 * result.EquityStdDev / math.Sqrt(n)
+// sumsOf recovers the accumulated sums of a rollout result
+func sumsOf(r *RolloutResult) rolloutSums {
+	return rolloutSums{
+		sumProbs:    r.SumProbs,
+		sumSqProbs:  r.SumSqProbs,
+		sumEquity:   r.SumEquity,
+		sumSqEquity: r.SumSqEquity,
+		trials:      r.TrialsCompleted,
+		wins:        r.GamesWon,
+		gammonsWon:  r.GammonsWon,
+		bgsWon:      r.BackgammonsWon,
+		losses:      r.GamesLost,
+		gammonsLost: r.GammonsLost,
+		bgsLost:     r.BackgammonsLost,
 	}
-
-	return result, nil
 }
 
-// aggregateResults combines partial results from workers
-func (e *Engine) aggregateResults(results chan partialResult, _ int) (*RolloutResult, error) {
-	var (
-		sumProbs                     [5]float64
-		sumSqProbs                   [5]float64
-		sumEquity                    float64
-		sumSqEquity                  float64
-		trials                       int
-		wins, gammonsWon, bgsWon     int
-		losses, gammonsLost, bgsLost int
-	)
-
-	for pr := range results {
-		for i := 0; i < 5; i++ {
-			sumProbs[i] += pr.sumProbs[i]
-			sumSqProbs[i] += pr.sumSqProbs[i]
-		}
-		sumEquity += pr.sumEquity
-		sumSqEquity += pr.sumSqEquity
-		trials += pr.trials
-		wins += pr.wins
-		gammonsWon += pr.gammonsWon
-		bgsWon += pr.bgsWon
-		losses += pr.losses
-		gammonsLost += pr.gammonsLost
-		bgsLost += pr.bgsLost
-	}
-
-	n := float64(trials)
+// result computes averages and statistics from the sums
+func (s *rolloutSums) result() *RolloutResult {
+	n := float64(s.trials)
 	if n == 0 {
-		return &RolloutResult{}, nil
+		return &RolloutResult{}
 	}
 
 	// Calculate means
 	result := &RolloutResult{
-		WinProb:         sumProbs[0] / n,
-		WinG:            sumProbs[1] / n,
-		WinBG:           sumProbs[2] / n,
-		LoseG:           sumProbs[3] / n,
-		LoseBG:          sumProbs[4] / n,
-		Equity:          sumEquity / n,
-		TrialsCompleted: trials,
-		GamesWon:        wins,
-		GammonsWon:      gammonsWon,
-		BackgammonsWon:  bgsWon,
-		GamesLost:       losses,
-		GammonsLost:     gammonsLost,
-		BackgammonsLost: bgsLost,
-	}
-
-	// Calculate standard deviations using Welford's formula
+		WinProb:         s.sumProbs[0] / n,
+		WinG:            s.sumProbs[1] / n,
+		WinBG:           s.sumProbs[2] / n,
+		LoseG:           s.sumProbs[3] / n,
+		LoseBG:          s.sumProbs[4] / n,
+		Equity:          s.sumEquity / n,
+		TrialsCompleted: s.trials,
+		GamesWon:        s.wins,
+		GammonsWon:      s.gammonsWon,
+		BackgammonsWon:  s.bgsWon,
+		GamesLost:       s.losses,
+		GammonsLost:     s.gammonsLost,
+		BackgammonsLost: s.bgsLost,
+		SumProbs:        s.sumProbs,
+		SumSqProbs:      s.sumSqProbs,
+		SumEquity:       s.sumEquity,
+		SumSqEquity:     s.sumSqEquity,
+	}
+
+	// Calculate standard deviations
 	// Var(X) = E(X^2) - E(X)^2
 	if n > 1 {
-		result.WinProbStdDev = calcStdDev(sumProbs[0], sumSqProbs[0], n)
-		result.WinGStdDev = calcStdDev(sumProbs[1], sumSqProbs[1], n)
-		result.WinBGStdDev = calcStdDev(sumProbs[2], sumSqProbs[2], n)
-		result.LoseGStdDev = calcStdDev(sumProbs[3], sumSqProbs[3], n)
-		result.LoseBGStdDev = calcStdDev(sumProbs[4], sumSqProbs[4], n)
-		result.EquityStdDev = calcStdDev(sumEquity, sumSqEquity, n)
+		result.WinProbStdDev = calcStdDev(s.sumProbs[0], s.sumSqProbs[0], n)
+		result.WinGStdDev = calcStdDev(s.sumProbs[1], s.sumSqProbs[1], n)
+		result.WinBGStdDev = calcStdDev(s.sumProbs[2], s.sumSqProbs[2], n)
+		result.LoseGStdDev = calcStdDev(s.sumProbs[3], s.sumSqProbs[3], n)
+		result.LoseBGStdDev = calcStdDev(s.sumProbs[4], s.sumSqProbs[4], n)
+		result.EquityStdDev = calcStdDev(s.sumEquity, s.sumSqEquity, n)
 
 		// 95% confidence interval = 1.96 * stdErr = 1.96 * stdDev / sqrt(n)
 		result.EquityCI = 1.96 * result.EquityStdDev / math.Sqrt(n)
 	}
 
-	return result, nil
+	return result
 }
 
 // calcStdDev calculates standard deviation from sum and sum of squares
@@ -403,87 +713,73 @@ func calcStdDev(sum, sumSq, n float64) float64 {
 	return math.Sqrt(variance)
 }
 
-// rolloutWorker performs rollouts for a single worker
-func (e *Engine) rolloutWorker(state *GameState, trials int, seed int64, truncate int, cubeful bool) partialResult {
-	rng := rand.New(rand.NewSource(seed))
-	pr := partialResult{}
-
-	for trial := 0; trial < trials; trial++ {
-		result := e.playOutGame(state, rng, truncate, cubeful)
-
-		// Accumulate probabilities
-		pr.sumProbs[0] += result.WinProb
-		pr.sumProbs[1] += result.WinG
-		pr.sumProbs[2] += result.WinBG
-		pr.sumProbs[3] += result.LoseG
-		pr.sumProbs[4] += result.LoseBG
-
-		pr.sumSqProbs[0] += result.WinProb * result.WinProb
-		pr.sumSqProbs[1] += result.WinG * result.WinG
-		pr.sumSqProbs[2] += result.WinBG * result.WinBG
-		pr.sumSqProbs[3] += result.LoseG * result.LoseG
-		pr.sumSqProbs[4] += result.LoseBG * result.LoseBG
-
-		pr.sumEquity += result.Equity
-		pr.sumSqEquity += result.Equity * result.Equity
-		pr.trials++
-
-		// Count outcomes
-		if result.WinProb > 0.5 {
-			pr.wins++
-			if result.WinBG > 0 {
-				pr.bgsWon++
-			} else if result.WinG > 0 {
-				pr.gammonsWon++
-			}
-		} else {
-			pr.losses++
-			if result.LoseBG > 0 {
-				pr.bgsLost++
-			} else if result.LoseG > 0 {
-				pr.gammonsLost++
-			}
+// openingRoll returns the first roll of a trial under rule (already resolved)
+// and the side that plays it.
+func openingRoll(state *GameState, rule FirstRollRule, src diceSource) (dice [2]int, turn int) {
+	switch rule {
+	case FirstRollStandard:
+		// One die each, rerolling ties; the higher die moves first
+		for dice[0] == dice[1] {
+			dice = src.roll()
+		}
+		if dice[0] > dice[1] {
+			return dice, state.Turn
 		}
+		return dice, 1 - state.Turn
+	case FirstRollAlreadyRolled:
+		return state.Dice, state.Turn
+	default:
+		return src.roll(), state.Turn
 	}
+}
 
-	return pr
+// validDice reports whether both dice are 1-6.
+func validDice(dice [2]int) bool {
+	return dice[0] >= 1 && dice[0] <= 6 && dice[1] >= 1 && dice[1] <= 6
 }
 
-// playOutGame plays a single game to completion or truncation
-// Returns evaluation from the perspective of the original player (state.Turn)
-// cubeful parameter reserved for future cubeful rollouts
-func (e *Engine) playOutGame(state *GameState, rng *rand.Rand, truncate int, _ bool) Evaluation {
+// playOutGame plays a single game to completion or truncation, as opts say
+// Returns evaluation from the perspective of the side on roll in state, and
+// the number of plies played
+// opts.Cubeful is reserved for future cubeful rollouts
+func (e *Engine) playOutGame(state *GameState, src diceSource, opts RolloutOptions) (Evaluation, int) {
 	// Copy the board so we don't modify the original
 	board := state.Board
-	originalPlayer := state.Turn // Remember who we're evaluating for
-	turn := state.Turn
+	totals := state.checkerTotals()
+
+	// Turns are sides of the board: the side on roll in state is player 1
+	// (see moverBoard), and the outcome is from its perspective
+	const originalPlayer = 1
+	dice, first := openingRoll(state, opts.FirstRoll, src)
+	turn := originalPlayer
+	if first != state.Turn {
+		turn = 1 - originalPlayer
+	}
 	ply := 0
 
 	const maxPlies = 1000 // Safety limit
 
 	for ply < maxPlies {
-		// Check for truncation
-		if truncate > 0 && ply >= truncate {
-			return e.evaluateForRollout(&board, originalPlayer)
-		}
-
 		// Check if game is over
-		status := e.gameStatus(&board)
+		status := e.gameStatus(&board, totals)
 		if status != 0 {
-			return e.gameOverEvaluation(status, originalPlayer)
+			return e.gameOverEvaluation(status, originalPlayer), ply
 		}
 
-		// Roll dice
-		die1 := rng.Intn(6) + 1
-		die2 := rng.Intn(6) + 1
+		// Check for truncation
+		if opts.Truncate > 0 && ply >= opts.Truncate {
+			return e.evaluateForRollout(state, &board, turn, opts), ply
+		}
 
-		// Generate moves for current player
-		moves := e.generateMovesForBoard(&board, turn, die1, die2)
+		// Roll dice (the first roll came from openingRoll)
+		if ply > 0 {
+			dice = src.roll()
+		}
 
-		if len(moves) > 0 {
-			// Find best move using neural net
-			bestMove := e.findBestMoveFromList(&board, turn, moves)
-			// Apply the move
+		// Find and play the best move from the mover's perspective
+		bestMove, _, err := e.BestMove(&GameState{Board: e.moverBoard(&board, turn), TotalCheckers: moverTotals(totals, turn)},
+			dice, rolloutEvalOptions)
+		if err == nil && bestMove.From[0] >= 0 {
 			e.applyMoveToBoard(&board, turn, bestMove)
 		}
 
@@ -493,65 +789,53 @@ func (e *Engine) playOutGame(state *GameState, rng *rand.Rand, truncate int, _ b
 	}
 
 	// If we hit max plies, evaluate current position
-	return e.evaluateForRollout(&board, originalPlayer)
+	return e.evaluateForRollout(state, &board, turn, opts), ply
 }
 
 // gameStatus returns the game status
 // 0 = game in progress, 1 = player 0 wins, -1 = player 1 wins
 // 2/-2 = gammon, 3/-3 = backgammon
-func (e *Engine) gameStatus(board *Board) int {
-	// Check if player 0 has borne off all checkers
-	p0Total := 0
-	for i := 0; i < 25; i++ {
-		p0Total += int(board[0][i])
-	}
-	if p0Total == 0 {
-		// Player 0 wins - check for gammon/backgammon
-		return e.winType(board, 1) // Check opponent's position
+// totals are the checkers each side plays with (see GameState.TotalCheckers).
+func (e *Engine) gameStatus(board *Board, totals [2]int) int {
+	winner, points, ok := gameResult(board, totals)
+	switch {
+	case !ok:
+		return 0 // Game in progress
+	case winner == 0:
+		return points
+	default:
+		return -points
 	}
+}
 
-	// Check if player 1 has borne off all checkers
-	p1Total := 0
-	for i := 0; i < 25; i++ {
-		p1Total += int(board[1][i])
-	}
-	if p1Total == 0 {
-		// Player 1 wins
-		return -e.winType(board, 0)
-	}
+// GameResult reports whether board is a finished game of 15 checkers a
+// side: ok is true when a side has borne off all its checkers, winner is
+// that side's index and points is 1 for a single game, 2 for a gammon and 3
+// for a backgammon, before the cube.
+func GameResult(board Board) (winner int, points int, ok bool) {
+	return gameResult(&board, fullTotals)
+}
 
-	return 0 // Game in progress
+// gameResult is GameResult for sides playing with totals checkers.
+func gameResult(board *Board, totals [2]int) (winner int, points int, ok bool) {
+	for side := 0; side < 2; side++ {
+		if board.OnBoard(side) == 0 {
+			return side, winType(board, 1-side, totals[1-side]), true
+		}
+	}
+	return -1, 0, false
 }
 
 // winType determines if it's a gammon (2) or backgammon (3) or regular win (1)
-func (e *Engine) winType(board *Board, loser int) int {
-	// Check if loser has borne off any checkers
-	total := 0
-	for i := 0; i < 25; i++ {
-		total += int(board[loser][i])
-	}
-	if total == 15 {
-		// Loser has all 15 checkers - it's a gammon or backgammon
-		// Check if any checkers in winner's home board or on the bar
-		hasInHome := false
-		if board[loser][24] > 0 { // On bar
-			hasInHome = true
-		} else {
-			// Check winner's home board (opponent's points 18-23)
-			// From loser's perspective, winner's home is points 0-5
-			for i := 0; i < 6; i++ {
-				if board[loser][i] > 0 {
-					hasInHome = true
-					break
-				}
-			}
-		}
-		if hasInHome {
-			return 3 // Backgammon
-		}
-		return 2 // Gammon
+// for a loser that plays with total checkers.
+func winType(board *Board, loser int, total int) int {
+	if board.Off(loser, total) > 0 {
+		return 1 // Regular win
 	}
-	return 1 // Regular win
+	if board.BackgammonZone(loser) > 0 {
+		return 3 // Backgammon
+	}
+	return 2 // Gammon
 }
 
 // gameOverEvaluation returns an evaluation for a completed game
@@ -620,86 +904,71 @@ func (e *Engine) gameOverEvaluation(status int, perspective int) Evaluation {
 	return eval
 }
 
-// evaluateForRollout evaluates the current board position from the specified player's perspective
-func (e *Engine) evaluateForRollout(board *Board, perspective int) Evaluation {
-	state := &GameState{
-		Board: *board,
-		Turn:  perspective,
-	}
-	eval, err := e.Evaluate(state)
+// evaluateForRollout scores the trial of a rollout of state with opts that
+// stopped at board, with turn on roll, from the perspective of the side on
+// roll in state (player 1 of board)
+func (e *Engine) evaluateForRollout(state *GameState, board *Board, turn int, opts RolloutOptions) Evaluation {
+	leaf := &GameState{Board: e.moverBoard(board, turn), CubeValue: 1, CubeOwner: -1,
+		TotalCheckers: moverTotals(state.checkerTotals(), turn)}
+	eval, err := e.evaluateCached(leaf, opts.LeafPly, nil)
 	if err != nil || eval == nil {
 		return Evaluation{WinProb: 0.5}
 	}
+	result := *eval
+
+	if opts.LeafCubeful {
+		// The cube of state, with its owner as a side of the board
+		owner := -1
+		switch state.CubeOwner {
+		case state.Turn:
+			owner = 1
+		case 1 - state.Turn:
+			owner = 0
+		}
+		output := []float64{eval.WinProb, eval.WinG, eval.WinBG, eval.LoseG, eval.LoseBG}
+		x := DefaultCubeEfficiency().For(leaf.Board)
+		result.Equity = e.Cl2CfMoney(output, SetCubeInfoMoney(1, owner, turn, false, false), x)
+	}
 
-	// If perspective is player 1, we need to invert
-	if perspective == 1 {
+	// The evaluation is the mover's: invert it for the other side
+	if turn != 1 {
 		return Evaluation{
-			WinProb: 1 - eval.WinProb,
-			WinG:    eval.LoseG,
-			WinBG:   eval.LoseBG,
-			LoseG:   eval.WinG,
-			LoseBG:  eval.WinBG,
-			Equity:  -eval.Equity,
+			WinProb: 1 - result.WinProb,
+			WinG:    result.LoseG,
+			WinBG:   result.LoseBG,
+			LoseG:   result.WinG,
+			LoseBG:  result.WinBG,
+			Equity:  -result.Equity,
 		}
 	}
-	return *eval
+	return result
 }
 
 // generateMovesForBoard generates moves for the specified player
 func (e *Engine) generateMovesForBoard(board *Board, turn int, die1, die2 int) []Move {
-	// GenerateMoves assumes player 1 is on roll, so we need to swap if turn == 0
-	var workBoard Board
-	if turn == 0 {
-		// Swap sides
-		workBoard = swapBoardSides(*board)
-	} else {
-		workBoard = *board
-	}
-
-	ml := GenerateMoves(workBoard, die1, die2)
+	ml := GenerateMoves(e.moverBoard(board, turn), die1, die2)
 	return ml.Moves
 }
 
-// findBestMoveFromList finds the best move from a list using 1-ply evaluation
-func (e *Engine) findBestMoveFromList(board *Board, turn int, moves []Move) Move {
-	if len(moves) == 0 {
-		return Move{}
-	}
-	if len(moves) == 1 {
-		return moves[0]
-	}
+// rolloutEvalOptions is the move selection used when playing out rollout games
+var rolloutEvalOptions = EvalOptions{Plies: 0, UsePrune: true}
 
-	// Work board from the perspective of the moving player
-	var workBoard Board
+// moverBoard returns the board from the perspective of the player on roll
+// (GenerateMoves and BestMove assume player 1 is on roll)
+func (e *Engine) moverBoard(board *Board, turn int) Board {
 	if turn == 0 {
-		workBoard = swapBoardSides(*board)
-	} else {
-		workBoard = *board
+		return swapBoardSides(*board)
 	}
+	return *board
+}
 
-	bestMove := moves[0]
-	bestEquity := float64(-999)
-
-	for _, m := range moves {
-		// Apply move
-		resultBoard := ApplyMove(workBoard, m)
-		// Swap back for evaluation (evaluate from moving player's perspective)
-		swapped := swapBoardSides(resultBoard)
-
-		state := &GameState{Board: swapped}
-		eval, err := e.Evaluate(state)
-		if err != nil || eval == nil {
-			continue
-		}
-
-		// Higher equity is better for the moving player
-		if eval.Equity > bestEquity {
-			bestEquity = eval.Equity
-			bestMove = m
-		}
+// moverTotals returns the checker totals of the sides of a rollout's board
+// from the perspective of the player on roll, as moverBoard does the board.
+func moverTotals(totals [2]int, turn int) [2]int {
+	if turn == 0 {
+		return swapTotals(totals)
 	}
-
-	return bestMove
+	return totals
 }
 
 // applyMoveToBoard applies a move to the board in place
@@ -1,6 +1,13 @@
 package engine
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"math/rand"
+	"path/filepath"
+	"reflect"
 	"runtime"
 	"testing"
 	"time"
@@ -132,7 +139,7 @@ func TestGameStatus(t *testing.T) {
 
 	// Game in progress - starting position
 	state := StartingPosition()
-	status := engine.gameStatus(&state.Board)
+	status := engine.gameStatus(&state.Board, fullTotals)
 	if status != 0 {
 		t.Errorf("Starting position status = %d, want 0 (in progress)", status)
 	}
@@ -142,12 +149,40 @@ func TestGameStatus(t *testing.T) {
 	// Player 0: no checkers (all borne off)
 	// Player 1: 15 checkers on point 0 (gammon - none borne off)
 	winBoard[1][0] = 15
-	status = engine.gameStatus(&winBoard)
+	status = engine.gameStatus(&winBoard, fullTotals)
 	if status <= 0 {
 		t.Errorf("Player 0 win status = %d, want > 0", status)
 	}
 }
 
+func TestGameResult(t *testing.T) {
+	if _, _, ok := GameResult(StartingPosition().Board); ok {
+		t.Error("GameResult of the starting position reports a finished game")
+	}
+
+	tests := []struct {
+		name   string
+		loser  [25]uint8
+		points int
+	}{
+		{"single", [25]uint8{0: 14}, 1},
+		{"gammon", [25]uint8{0: 15}, 2},
+		{"backgammon in the winner's home board", [25]uint8{0: 14, 20: 1}, 3},
+		{"backgammon on the bar", [25]uint8{0: 14, 24: 1}, 3},
+	}
+	for _, tt := range tests {
+		for winner := 0; winner < 2; winner++ {
+			var board Board
+			board[1-winner] = tt.loser
+			gotWinner, gotPoints, ok := GameResult(board)
+			if !ok || gotWinner != winner || gotPoints != tt.points {
+				t.Errorf("%s for side %d: GameResult = %d, %d, %v, want %d, %d, true",
+					tt.name, winner, gotWinner, gotPoints, ok, winner, tt.points)
+			}
+		}
+	}
+}
+
 func TestRolloutSinglePly(t *testing.T) {
 	// Test that a single ply of rollout produces reasonable results
 	engine, err := NewEngine(EngineOptions{})
@@ -359,3 +394,585 @@ func TestRolloutProgressEquityConvergence(t *testing.T) {
 
 	t.Logf("Final: equity=%.4f ±%.4f", result.Equity, result.EquityCI)
 }
+
+func TestRolloutExtend(t *testing.T) {
+	engine, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	state := StartingPosition()
+	opts := RolloutOptions{Trials: 1000, Seed: 4242, Workers: 4}
+
+	first, err := engine.Rollout(state, opts)
+	if err != nil {
+		t.Fatalf("Rollout failed: %v", err)
+	}
+
+	// The prior result survives a round trip through JSON
+	data, err := json.Marshal(first)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var prior RolloutResult
+	if err := json.Unmarshal(data, &prior); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	// Extend with a different worker count and no seed: both come from prior
+	extended, err := engine.RolloutExtend(state, RolloutOptions{Trials: 1000, Workers: 3}, &prior)
+	if err != nil {
+		t.Fatalf("RolloutExtend failed: %v", err)
+	}
+
+	opts.Trials = 2000
+	whole, err := engine.Rollout(state, opts)
+	if err != nil {
+		t.Fatalf("Rollout failed: %v", err)
+	}
+
+	// Throughput is of the run, not the trials
+	extended.setThroughput(0, 0, 0)
+	whole.setThroughput(0, 0, 0)
+	if !reflect.DeepEqual(extended, whole) {
+		t.Errorf("extended rollout differs from single rollout:\n  extended %+v\n  single   %+v", *extended, *whole)
+	}
+
+	if _, err := engine.RolloutExtend(state, RolloutOptions{Trials: 10, Seed: 1}, &prior); err == nil {
+		t.Error("expected error when extending with a different seed")
+	}
+}
+
+// countingDice rolls the dice of another source and records them.
+type countingDice struct {
+	src   diceSource
+	rolls *[][2]int
+}
+
+func (d *countingDice) roll() [2]int {
+	dice := d.src.roll()
+	*d.rolls = append(*d.rolls, dice)
+	return dice
+}
+
+// countDice makes rollouts with opts record the rolls of each of trials
+// trials, and returns the rolls of each trial.
+func countDice(opts *RolloutOptions, trials int) [][][2]int {
+	rolls := make([][][2]int, trials)
+	opts.wrapDice = func(trial int, d diceSource) diceSource {
+		return &countingDice{src: d, rolls: &rolls[trial]}
+	}
+	return rolls
+}
+
+func TestRolloutDiceExhaustive(t *testing.T) {
+	engine, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	state := StartingPosition()
+	state.Board[1][5], state.Board[1][4] = 4, 1
+
+	// Truncated after two plies, each trial rolls its sequence and no more
+	for _, workers := range []int{1, 3, 8} {
+		opts := RolloutOptions{Trials: twoRollSequences, Truncate: 2, Seed: 5, Workers: workers}
+		rolls := countDice(&opts, twoRollSequences)
+		result, err := engine.Rollout(state, opts)
+		if err != nil {
+			t.Fatalf("Rollout failed: %v", err)
+		}
+		if result.DiceMode != DiceExhaustive2Roll || result.Manifest.DiceMode != string(DiceExhaustive2Roll) {
+			t.Errorf("dice mode %q (manifest %q), want %q", result.DiceMode, result.Manifest.DiceMode, DiceExhaustive2Roll)
+		}
+		seen := make(map[[2][2]int]int)
+		for trial, r := range rolls {
+			if len(r) != 2 {
+				t.Fatalf("%d workers: trial %d rolled %v, want two rolls", workers, trial, r)
+			}
+			seen[[2][2]int{r[0], r[1]}]++
+		}
+		if len(seen) != twoRollSequences {
+			t.Errorf("%d workers: %d distinct sequences, want %d", workers, len(seen), twoRollSequences)
+		}
+		for seq, n := range seen {
+			if n != 1 {
+				t.Errorf("%d workers: sequence %v played %d times", workers, seq, n)
+			}
+		}
+	}
+
+	// With the first roll already rolled, the two rolls after it are
+	// enumerated, and each block of 36 trials rolls each roll once
+	rolled := *state
+	rolled.Dice = [2]int{6, 5}
+	opts := RolloutOptions{Trials: 36, Truncate: 3, Seed: 5, Workers: 2, FirstRoll: FirstRollAlreadyRolled}
+	rolls := countDice(&opts, 36)
+	if _, err := engine.Rollout(&rolled, opts); err != nil {
+		t.Fatalf("Rollout failed: %v", err)
+	}
+	firsts, seconds := make(map[[2]int]bool), make(map[[2]int]bool)
+	for trial, r := range rolls {
+		if len(r) != 2 {
+			t.Fatalf("trial %d rolled %v after the rolled dice, want two rolls", trial, r)
+		}
+		firsts[r[0]], seconds[r[1]] = true, true
+	}
+	if len(firsts) != 36 || len(seconds) != 36 {
+		t.Errorf("36 trials rolled %d distinct first and %d distinct second rolls, want 36", len(firsts), len(seconds))
+	}
+}
+
+func TestRolloutDiceMode(t *testing.T) {
+	engine, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	// The standard opening roll is never a double, so its dice are random
+	result, err := engine.Rollout(StartingPosition(), RolloutOptions{Trials: 10, Truncate: 2, Seed: 1})
+	if err != nil {
+		t.Fatalf("Rollout failed: %v", err)
+	}
+	if result.DiceMode != DiceRandom {
+		t.Errorf("starting position rolled out with dice mode %q, want %q", result.DiceMode, DiceRandom)
+	}
+	if _, err := engine.Rollout(StartingPosition(), RolloutOptions{Trials: 10, DiceMode: DiceExhaustive2Roll}); err == nil {
+		t.Error("expected error for enumerated dice with the standard opening roll")
+	}
+	if _, err := engine.Rollout(StartingPosition(), RolloutOptions{Trials: 10, DiceMode: "loaded"}); err == nil {
+		t.Error("expected error for an unknown dice mode")
+	}
+
+	// A random rollout of a position that could be enumerated stays random
+	// when extended, and is stored apart from the enumerated one
+	state := StartingPosition()
+	state.Board[1][5], state.Board[1][4] = 4, 1
+	opts := RolloutOptions{Trials: 20, Truncate: 2, Seed: 1, DiceMode: DiceRandom}
+	prior, err := engine.Rollout(state, opts)
+	if err != nil {
+		t.Fatalf("Rollout failed: %v", err)
+	}
+	prior.DiceMode = "" // As stored before the dice mode was kept
+	extended, err := engine.RolloutExtend(state, RolloutOptions{Trials: 20}, prior)
+	if err != nil {
+		t.Fatalf("RolloutExtend failed: %v", err)
+	}
+	opts.Trials = 40
+	whole, err := engine.Rollout(state, opts)
+	if err != nil {
+		t.Fatalf("Rollout failed: %v", err)
+	}
+	if extended.DiceMode != DiceRandom || extended.SumEquity != whole.SumEquity {
+		t.Errorf("extended random rollout: dice mode %q, equity sum %v, want %q, %v", extended.DiceMode, extended.SumEquity, DiceRandom, whole.SumEquity)
+	}
+	if _, err := engine.RolloutExtend(state, RolloutOptions{Trials: 20, DiceMode: DiceExhaustive2Roll}, prior); err == nil {
+		t.Error("expected error when extending with a different dice mode")
+	}
+	if NewRolloutKey(state, opts) == NewRolloutKey(state, RolloutOptions{Seed: 1, Truncate: 2}) {
+		t.Error("random and enumerated rollouts have the same key")
+	}
+}
+
+func TestFirstRollResolve(t *testing.T) {
+	start := StartingPosition()
+	rolled := StartingPosition()
+	rolled.Dice = [2]int{3, 1}
+	other := StartingPosition()
+	other.Board[1][5], other.Board[1][4] = 4, 1
+
+	tests := []struct {
+		name  string
+		rule  FirstRollRule
+		state *GameState
+		want  FirstRollRule
+	}{
+		{"auto at start", FirstRollAuto, start, FirstRollStandard},
+		{"auto with dice", FirstRollAuto, rolled, FirstRollNone},
+		{"auto mid-game", FirstRollAuto, other, FirstRollNone},
+		{"explicit none", FirstRollNone, start, FirstRollNone},
+		{"explicit rolled", FirstRollAlreadyRolled, rolled, FirstRollAlreadyRolled},
+	}
+	for _, tc := range tests {
+		if got := tc.rule.resolve(tc.state); got != tc.want {
+			t.Errorf("%s: resolve = %d, want %d", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestOpeningRollStandard(t *testing.T) {
+	state := StartingPosition()
+	rng := rand.New(rand.NewSource(1))
+
+	const trials = 36000
+	movers := [2]int{}
+	seen := make(map[[2]int]bool)
+	for i := 0; i < trials; i++ {
+		rng.Seed(trialSeed(12345, i))
+		dice, turn := openingRoll(state, FirstRollStandard, &trialDice{rng: rng})
+		if dice[0] == dice[1] {
+			t.Fatalf("trial %d opened with a double %v", i, dice)
+		}
+		if (dice[0] > dice[1]) != (turn == state.Turn) {
+			t.Fatalf("trial %d: roll %v moved by side %d", i, dice, turn)
+		}
+		movers[turn]++
+		seen[dice] = true
+	}
+
+	if share := float64(movers[state.Turn]) / trials; share < 0.49 || share > 0.51 {
+		t.Errorf("side on roll moved first in %.1f%% of trials, want 50%%", 100*share)
+	}
+	if len(seen) != 30 {
+		t.Errorf("%d distinct opening rolls, want 30", len(seen))
+	}
+}
+
+func TestRolloutFirstRoll(t *testing.T) {
+	engine, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	result, err := engine.Rollout(StartingPosition(), RolloutOptions{Trials: 20, Truncate: 2, Seed: 3})
+	if err != nil {
+		t.Fatalf("Rollout failed: %v", err)
+	}
+	if result.FirstRoll != FirstRollStandard {
+		t.Errorf("starting position rolled out with first roll rule %d, want FirstRollStandard", result.FirstRoll)
+	}
+
+	// AlreadyRolled needs the dice
+	if _, err := engine.Rollout(StartingPosition(), RolloutOptions{Trials: 1, FirstRoll: FirstRollAlreadyRolled}); err == nil {
+		t.Error("expected error for AlreadyRolled without dice")
+	}
+
+	state := StartingPosition()
+	state.Dice = [2]int{3, 1}
+	rng := rand.New(rand.NewSource(1))
+	if dice, turn := openingRoll(state, FirstRollAlreadyRolled, &trialDice{rng: rng}); dice != state.Dice || turn != state.Turn {
+		t.Errorf("AlreadyRolled opening roll = %v by side %d, want %v by side %d", dice, turn, state.Dice, state.Turn)
+	}
+	result, err = engine.Rollout(state, RolloutOptions{Trials: 20, Truncate: 2, Seed: 3, FirstRoll: FirstRollAlreadyRolled})
+	if err != nil {
+		t.Fatalf("Rollout failed: %v", err)
+	}
+	if result.FirstRoll != FirstRollAlreadyRolled {
+		t.Errorf("first roll rule %d, want FirstRollAlreadyRolled", result.FirstRoll)
+	}
+}
+
+// partialStudy returns a five-checker endgame study: the player on roll has
+// two checkers left on the 1-point of a five-checker game, and the opponent
+// all five still out on its 8-point.
+func partialStudy() *GameState {
+	state := &GameState{Turn: 1, CubeValue: 1, CubeOwner: -1, TotalCheckers: [2]int{5, 5}}
+	state.Board[1][0] = 2
+	state.Board[0][7] = 5
+	return state
+}
+
+func TestRolloutPartialPosition(t *testing.T) {
+	e, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	opts := RolloutOptions{Trials: 36, Seed: 1, FirstRoll: FirstRollNone}
+
+	// The player on roll bears both checkers off before the opponent moves,
+	// and the opponent, with all five checkers left, is gammoned
+	state := partialStudy()
+	result, err := e.Rollout(state, opts)
+	if err != nil {
+		t.Fatalf("Rollout: %v", err)
+	}
+	if result.TrialsCompleted != 36 || result.WinProb != 1 || result.WinG != 1 || result.WinBG != 0 {
+		t.Errorf("partial rollout: %d trials, win %.3f, gammon %.3f, backgammon %.3f; want all 36 gammons",
+			result.TrialsCompleted, result.WinProb, result.WinG, result.WinBG)
+	}
+
+	// In a full game the missing checkers are borne off: no gammon
+	full := *state
+	full.TotalCheckers = [2]int{}
+	if result, err := e.Rollout(&full, opts); err != nil || result.WinProb != 1 || result.WinG != 0 {
+		t.Errorf("full game rollout: %+v, %v; want single wins", result, err)
+	}
+	if NewRolloutKey(state, opts) == NewRolloutKey(&full, opts) {
+		t.Error("the partial and full game rollouts have the same store key")
+	}
+
+	// Five checkers each, all in the home boards: every trial ends with one
+	// side bearing its five off, and with no checker in the winner's home
+	// board no trial is a backgammon
+	state = &GameState{Turn: 1, CubeValue: 1, CubeOwner: -1, TotalCheckers: [2]int{5, 5}}
+	for i := 0; i < 5; i++ {
+		state.Board[0][i], state.Board[1][i] = 1, 1
+	}
+	result, err = e.Rollout(state, RolloutOptions{Trials: 200, Seed: 1, FirstRoll: FirstRollNone})
+	if err != nil {
+		t.Fatalf("Rollout: %v", err)
+	}
+	if result.GamesWon+result.GamesLost != 200 || result.WinProb < 0.5 || result.WinBG != 0 || result.LoseBG != 0 {
+		t.Errorf("5-checker race: %d won, %d lost, win %.3f, backgammons %.3f/%.3f; want 200 games, the player on roll ahead, no backgammons",
+			result.GamesWon, result.GamesLost, result.WinProb, result.WinBG, result.LoseBG)
+	}
+
+	// More checkers on the board than the total
+	state.TotalCheckers[0] = 4
+	if _, err := e.Rollout(state, opts); err == nil {
+		t.Error("Rollout accepted 5 checkers on the board of a side with 4")
+	}
+}
+
+func TestRolloutInitialDice(t *testing.T) {
+	e, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+
+	// Two checkers left each: the player on roll bears both off only with
+	// 6-6, and otherwise the opponent bears off first
+	joker := &GameState{Turn: 1, CubeValue: 1, CubeOwner: -1}
+	joker.Board[1][5] = 2
+	joker.Board[0][0] = 2
+	for _, trials := range []int{1, 50} {
+		result, err := e.Rollout(joker, RolloutOptions{Trials: trials, Seed: 1, InitialDice: [2]int{6, 6}})
+		if err != nil {
+			t.Fatalf("Rollout: %v", err)
+		}
+		if result.Equity != 1 || result.WinProb != 1 || result.FirstRoll != FirstRollAlreadyRolled {
+			t.Errorf("%d trials with 6-6: equity %.3f, win %.3f, first roll %d; want every trial won",
+				trials, result.Equity, result.WinProb, result.FirstRoll)
+		}
+	}
+	result, err := e.Rollout(joker, RolloutOptions{Trials: 50, Seed: 1})
+	if err != nil {
+		t.Fatalf("Rollout: %v", err)
+	}
+	if result.Equity > -0.5 {
+		t.Errorf("rolling the dice: equity %.3f, want the opponent winning nearly always", result.Equity)
+	}
+
+	// On the bar against a closed board every roll dances, so rolling out
+	// with the dice rolled is rolling out the position after the dance
+	// with the opponent on roll, with the same dice after the first turn
+	dance := &GameState{Turn: 1, CubeValue: 1, CubeOwner: -1}
+	dance.Board[1][24], dance.Board[1][5], dance.Board[1][7], dance.Board[1][12] = 1, 5, 4, 5
+	for i := 0; i < 6; i++ {
+		dance.Board[0][i] = 2
+	}
+	dance.Board[0][12] = 3
+	opts := RolloutOptions{Trials: 100, Seed: 7}
+	post := &GameState{Board: swapBoardSides(dance.Board), Turn: 1, CubeValue: 1, CubeOwner: -1}
+	postOpts := opts
+	postOpts.FirstRoll = FirstRollNone
+	after, err := e.Rollout(post, postOpts)
+	if err != nil {
+		t.Fatalf("Rollout after the dance: %v", err)
+	}
+	opts.InitialDice = [2]int{6, 5}
+	danced, err := e.Rollout(dance, opts)
+	if err != nil {
+		t.Fatalf("Rollout with 6-5: %v", err)
+	}
+	if math.Abs(danced.Equity+after.Equity) > 1e-9 || math.Abs(danced.WinProb-(1-after.WinProb)) > 1e-9 {
+		t.Errorf("dancing 6-5: equity %.4f, win %.4f; the opponent on roll after it: equity %.4f, win %.4f",
+			danced.Equity, danced.WinProb, after.Equity, after.WinProb)
+	}
+
+	// The dice are the state's dice with FirstRollAlreadyRolled
+	rolled := *dance
+	rolled.Dice = [2]int{6, 5}
+	rolledOpts := RolloutOptions{Trials: 100, Seed: 7, FirstRoll: FirstRollAlreadyRolled}
+	if NewRolloutKey(dance, opts) != NewRolloutKey(&rolled, rolledOpts) {
+		t.Error("initial dice and the state's dice rolled out under different store keys")
+	}
+	opts.InitialDice = [2]int{5, 6}
+	if NewRolloutKey(dance, opts) == NewRolloutKey(&rolled, rolledOpts) {
+		t.Error("rollouts with different initial dice share a store key")
+	}
+
+	for _, bad := range []RolloutOptions{
+		{Trials: 1, InitialDice: [2]int{7, 1}},
+		{Trials: 1, InitialDice: [2]int{3, 1}, FirstRoll: FirstRollStandard},
+	} {
+		if _, err := e.Rollout(joker, bad); err == nil {
+			t.Errorf("Rollout accepted initial dice %v with first roll rule %d", bad.InitialDice, bad.FirstRoll)
+		}
+	}
+	if _, err := e.RolloutCube(joker, RolloutOptions{Trials: 1, InitialDice: [2]int{6, 6}}); err == nil {
+		t.Error("RolloutCube accepted initial dice")
+	}
+}
+
+func TestRolloutSideOnRoll(t *testing.T) {
+	e, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+
+	// The player on roll bears off its last two checkers whichever player
+	// state.Turn names, played out or stopped after its roll
+	for _, turn := range []int{0, 1} {
+		state := &GameState{Board: boardFromPoints(map[int]uint8{1: 2}, map[int]uint8{6: 15}), Turn: turn, CubeValue: 1, CubeOwner: -1}
+		for _, truncate := range []int{0, 1} {
+			result, err := e.Rollout(state, RolloutOptions{Trials: 36, Truncate: truncate, Seed: 1})
+			if err != nil {
+				t.Fatalf("Rollout failed: %v", err)
+			}
+			if result.WinProb != 1 || result.LoseG != 0 {
+				t.Errorf("turn %d, truncate %d: win %.3f, lose gammon %.3f; want a certain win", turn, truncate, result.WinProb, result.LoseG)
+			}
+		}
+	}
+}
+
+// leafRace is a race of 96 pips against 90, with the player on roll 6 pips
+// behind.
+var leafRace = boardFromPoints(map[int]uint8{9: 3, 8: 3, 6: 3, 5: 3, 4: 3}, map[int]uint8{8: 3, 7: 3, 6: 3, 5: 3, 4: 3})
+
+func TestRolloutLeafPly(t *testing.T) {
+	if testing.Short() {
+		t.Skip("full-length rollout")
+	}
+	e, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	state := &GameState{Board: leafRace, CubeValue: 1, CubeOwner: -1}
+
+	full, err := e.Rollout(state, RolloutOptions{Trials: 1296, Seed: 5})
+	if err != nil {
+		t.Fatalf("Rollout failed: %v", err)
+	}
+	truncated, err := e.Rollout(state, RolloutOptions{Trials: 1296, Seed: 5, Truncate: 6, LeafPly: 1})
+	if err != nil {
+		t.Fatalf("Rollout failed: %v", err)
+	}
+	if truncated.LeafPly != 1 || truncated.AvgPlies != 6 {
+		t.Errorf("leaf ply %d after %.1f plies, want 1 after 6", truncated.LeafPly, truncated.AvgPlies)
+	}
+
+	// Unbiased enough to land within the full rollout's confidence interval,
+	// and with the leaf standing in for the luck of the rest of the game, far
+	// less variance: the same accuracy takes a fraction of the time
+	t.Logf("full %.4f +/- %.4f in %v, truncated %.4f +/- %.4f in %v", full.Equity, full.EquityCI, full.Elapsed, truncated.Equity, truncated.EquityCI, truncated.Elapsed)
+	if diff := math.Abs(truncated.Equity - full.Equity); diff > full.EquityCI {
+		t.Errorf("truncated equity %.4f, full %.4f +/- %.4f", truncated.Equity, full.Equity, full.EquityCI)
+	}
+	if truncated.EquityCI > full.EquityCI/2 {
+		t.Errorf("truncated CI %.4f, want under half the full CI %.4f", truncated.EquityCI, full.EquityCI)
+	}
+	cost := func(r *RolloutResult) float64 { return r.Elapsed.Seconds() * r.EquityCI * r.EquityCI }
+	if cost(truncated) > cost(full)/2 {
+		t.Errorf("truncated: %v for CI %.4f; full: %v for CI %.4f", truncated.Elapsed, truncated.EquityCI, full.Elapsed, full.EquityCI)
+	}
+
+	// Extending keeps the leaf
+	extended, err := e.RolloutExtend(state, RolloutOptions{Trials: 10}, truncated)
+	if err != nil || extended.LeafPly != 1 {
+		t.Errorf("extended: leaf ply %v, err %v", extended, err)
+	}
+	if _, err := e.RolloutExtend(state, RolloutOptions{Trials: 10, LeafPly: 2}, truncated); err == nil {
+		t.Error("extended with another leaf ply")
+	}
+	if _, err := e.Rollout(state, RolloutOptions{Trials: 10, Truncate: 6, LeafPly: MaxLeafPly + 1}); err == nil {
+		t.Error("rolled out with a leaf deeper than MaxLeafPly")
+	}
+}
+
+func TestRolloutLeafCubeful(t *testing.T) {
+	e, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+
+	// The same trials scored with the cube centred, owned by the player on
+	// roll and owned by the opponent: the probabilities are the same, and
+	// owning the cube is worth the most
+	rollout := func(owner int, cubeful bool) *RolloutResult {
+		t.Helper()
+		state := &GameState{Board: leafRace, CubeValue: 1, CubeOwner: owner}
+		result, err := e.Rollout(state, RolloutOptions{Trials: 50, Seed: 9, Truncate: 4, LeafCubeful: cubeful})
+		if err != nil {
+			t.Fatalf("Rollout failed: %v", err)
+		}
+		return result
+	}
+	cubeless, centred, owned, opponent := rollout(-1, false), rollout(-1, true), rollout(0, true), rollout(1, true)
+	if centred.WinProb != cubeless.WinProb || owned.WinProb != cubeless.WinProb || centred.Equity == cubeless.Equity {
+		t.Errorf("win %.4f/%.4f/%.4f, equity %.4f cubeful against %.4f cubeless", cubeless.WinProb, centred.WinProb, owned.WinProb, centred.Equity, cubeless.Equity)
+	}
+	if !(owned.Equity > centred.Equity && centred.Equity > opponent.Equity) {
+		t.Errorf("cubeful equities: owned %.4f, centred %.4f, opponent's %.4f", owned.Equity, centred.Equity, opponent.Equity)
+	}
+	if !centred.LeafCubeful {
+		t.Error("result does not record the cubeful leaves")
+	}
+}
+
+func TestRolloutContext(t *testing.T) {
+	store, err := OpenFileRolloutStore(filepath.Join(t.TempDir(), "rollouts.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine, err := NewEngine(EngineOptions{RolloutStore: store})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	state := StartingPosition()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := engine.RolloutContext(ctx, state, RolloutOptions{Trials: 100, Seed: 1}, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("rollout with a cancelled context: %v, want context.Canceled", err)
+	}
+
+	// Cancelled part way: the trials stop, no more progress is reported,
+	// and nothing is stored
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	calls := 0
+	start := time.Now()
+	_, err = engine.RolloutContext(ctx, state, RolloutOptions{Trials: 2000, Seed: 1, Workers: 2}, func(RolloutProgress) {
+		calls++
+		cancel()
+	})
+	if !errors.Is(err, context.Canceled) || calls != 1 {
+		t.Errorf("rollout cancelled after the first progress report: %v after %d reports", err, calls)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("cancelled rollout took %v", elapsed)
+	}
+	if stored, _ := engine.RolloutStore().Get(NewRolloutKey(state, RolloutOptions{Trials: 2000, Seed: 1})); stored != nil {
+		t.Errorf("cancelled rollout stored: %+v", stored)
+	}
+
+	if _, err := engine.RolloutCubeContext(ctx, state, RolloutOptions{Trials: 100}); !errors.Is(err, context.Canceled) {
+		t.Errorf("cube rollout with a cancelled context: %v, want context.Canceled", err)
+	}
+}
+
+func TestRolloutPartialTruncated(t *testing.T) {
+	e, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+
+	// Two checkers each: the leaves of a truncated rollout score the
+	// opponent's gammons against its two checkers, as completed trials do
+	state := &GameState{Turn: 1, CubeValue: 1, CubeOwner: -1, TotalCheckers: [2]int{2, 2}}
+	state.Board[1][4], state.Board[1][2] = 1, 1
+	state.Board[0][3] = 2
+
+	played, err := e.Rollout(state, RolloutOptions{Trials: 1296, Seed: 3, FirstRoll: FirstRollNone})
+	if err != nil {
+		t.Fatalf("Rollout: %v", err)
+	}
+	truncated, err := e.Rollout(state, RolloutOptions{Trials: 1296, Seed: 3, Truncate: 1, FirstRoll: FirstRollNone})
+	if err != nil {
+		t.Fatalf("Rollout: %v", err)
+	}
+	if played.WinG < 0.3 || math.Abs(truncated.WinG-played.WinG) > 0.05 {
+		t.Errorf("gammon rate %.3f truncated at 1 ply, %.3f played out; want the same", truncated.WinG, played.WinG)
+	}
+}
@@ -0,0 +1,373 @@
+package engine
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/yourusername/bgengine/internal/fileutil"
+)
+
+// RolloutKey identifies a stored rollout. Two rollouts with the same key play
+// the same games, so one can be extended into the other.
+type RolloutKey struct {
+	Position string `json:"position"` // Position ID
+	Cube     string `json:"cube"`     // Side on roll, cube and match state
+	Settings uint64 `json:"settings"` // Hash of the rollout settings (see NewRolloutKey)
+}
+
+// NewRolloutKey returns the store key for a rollout of state with opts.
+// The settings hash covers truncation and the leaf evaluation, cubeful play,
+// an explicit seed, the first roll rule, with the dice already rolled, and an
+// enumerated dice mode (rollouts stored before dice were enumerated played at
+// random, and keep their keys); the trial count is not part of the key, since
+// more trials extend a rollout.
+// Rollouts stored before trials were played from the side on roll whatever
+// state.Turn have keys without "v2", so they are not reused.
+func NewRolloutKey(state *GameState, opts RolloutOptions) RolloutKey {
+	if rolled, o, err := opts.withInitialDice(state); err == nil {
+		state, opts = rolled, o
+	}
+	h := fnv.New64a()
+	fmt.Fprintf(h, "v2 truncate=%d cubeful=%t", opts.Truncate, opts.Cubeful)
+	if opts.LeafPly != 0 || opts.LeafCubeful {
+		fmt.Fprintf(h, " leaf=%d leafcubeful=%t", opts.LeafPly, opts.LeafCubeful)
+	}
+	if opts.Seed != 0 {
+		fmt.Fprintf(h, " seed=%d", opts.Seed)
+	}
+	rule := opts.FirstRoll.resolve(state)
+	switch rule {
+	case FirstRollStandard:
+		io.WriteString(h, " firstroll=standard")
+	case FirstRollAlreadyRolled:
+		fmt.Fprintf(h, " firstroll=%d-%d", state.Dice[0], state.Dice[1])
+	}
+	if mode := opts.DiceMode.resolve(rule); mode != DiceRandom {
+		fmt.Fprintf(h, " dice=%s", mode)
+	}
+	cube := fmt.Sprintf("turn=%d cube=%d owner=%d match=%d score=%d-%d crawford=%t",
+		state.Turn, state.CubeValue, state.CubeOwner, state.MatchLength,
+		state.Score[0], state.Score[1], state.Crawford)
+	if state.Partial() {
+		totals := state.checkerTotals()
+		cube += fmt.Sprintf(" checkers=%d-%d", totals[0], totals[1])
+	}
+	return RolloutKey{
+		Position: EncodePositionID(state.Board),
+		Cube:     cube,
+		Settings: h.Sum64(),
+	}
+}
+
+// String returns the key in a readable form.
+func (k RolloutKey) String() string {
+	return fmt.Sprintf("%s %s settings=%016x", k.Position, k.Cube, k.Settings)
+}
+
+// ID returns a short identifier for the key, used by the admin API.
+func (k RolloutKey) ID() string {
+	return fmt.Sprintf("%016x", k.hash())
+}
+
+func (k RolloutKey) hash() uint64 {
+	h := fnv.New64a()
+	io.WriteString(h, k.String())
+	return h.Sum64()
+}
+
+// StoredRollout is a rollout result kept in a RolloutStore.
+type StoredRollout struct {
+	Key     RolloutKey     `json:"key"`
+	Result  *RolloutResult `json:"result"`
+	Created time.Time      `json:"created"` // When the first trials were stored
+	Updated time.Time      `json:"updated"` // When the result was last extended
+}
+
+// RolloutStore keeps rollout results so repeated requests for the same
+// position can be answered without playing the trials again.
+// Implementations must be safe for concurrent use.
+type RolloutStore interface {
+	// Get returns the stored rollout for key, or nil if there is none.
+	Get(key RolloutKey) (*StoredRollout, error)
+	// Put stores entry unless the store already holds a result for the same
+	// key with more trials, so a slower rollout never overwrites a longer one.
+	Put(entry *StoredRollout) error
+	// Delete removes the rollout for key. Deleting a missing key is not an error.
+	Delete(key RolloutKey) error
+	// List returns all stored rollouts, most recently updated first.
+	List() ([]*StoredRollout, error)
+}
+
+// Rollout store file format. The first line is a header naming the format and
+// version; every later line is one JSON record. Records are only appended, and
+// a later record for a key replaces an earlier one.
+const (
+	rolloutStoreFormat  = "bgengine-rollouts"
+	rolloutStoreVersion = 1
+)
+
+type rolloutStoreHeader struct {
+	Format  string `json:"format"`
+	Version int    `json:"version"`
+}
+
+type rolloutStoreRecord struct {
+	Op    string         `json:"op"` // "put" or "delete"
+	Entry *StoredRollout `json:"entry,omitempty"`
+	Key   *RolloutKey    `json:"key,omitempty"` // For deletes
+}
+
+// FileRolloutStore is a RolloutStore kept in memory and journaled to a file.
+type FileRolloutStore struct {
+	path        string
+	mu          sync.Mutex
+	f           *os.File
+	entries     map[RolloutKey]*StoredRollout
+	skipped     int
+	quarantined string
+}
+
+// OpenFileRolloutStore opens the store at path, creating the file if it does
+// not exist. Records that cannot be read (for example a line cut short by a
+// crash) are skipped and counted in Skipped. A file whose header cannot be
+// read is renamed aside (see Quarantined) and the store starts empty; a file
+// with a different format or a newer version is an error.
+func OpenFileRolloutStore(path string) (*FileRolloutStore, error) {
+	s := &FileRolloutStore{path: path, entries: make(map[RolloutKey]*StoredRollout)}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open rollout store: %w", err)
+	}
+	err = s.load(f)
+	if errors.Is(err, fileutil.ErrCorrupt) {
+		f.Close()
+		if s.quarantined, err = fileutil.Quarantine(path); err != nil {
+			return nil, fmt.Errorf("rollout store %s is corrupt and cannot be moved aside: %w", path, err)
+		}
+		if f, err = os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o644); err != nil {
+			return nil, fmt.Errorf("failed to open rollout store: %w", err)
+		}
+		err = s.load(f)
+	}
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("rollout store %s: %w", path, err)
+	}
+	s.f = f
+	return s, nil
+}
+
+// load replays the records in f and leaves it positioned for appending.
+func (s *FileRolloutStore) load(f *os.File) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() == 0 {
+		return writeJSONLine(f, rolloutStoreHeader{Format: rolloutStoreFormat, Version: rolloutStoreVersion})
+	}
+
+	r := bufio.NewReader(f)
+	line, err := r.ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return err
+	}
+	var header rolloutStoreHeader
+	if err := json.Unmarshal(line, &header); err != nil {
+		return fmt.Errorf("%w: unreadable header: %v", fileutil.ErrCorrupt, err)
+	}
+	if header.Format != rolloutStoreFormat {
+		return fmt.Errorf("not a rollout store")
+	}
+	if header.Version < 1 || header.Version > rolloutStoreVersion {
+		return fmt.Errorf("unsupported rollout store version %d (want %d)", header.Version, rolloutStoreVersion)
+	}
+
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(line) > 0 {
+			s.replay(line)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// Start appends on a fresh line if the last record was cut short
+	end, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	last := make([]byte, 1)
+	if _, err := f.ReadAt(last, end-1); err != nil {
+		return err
+	}
+	if last[0] != '\n' {
+		_, err = f.Write([]byte{'\n'})
+	}
+	return err
+}
+
+// replay applies one record line, counting it as skipped if it is unreadable.
+func (s *FileRolloutStore) replay(line []byte) {
+	var rec rolloutStoreRecord
+	if err := json.Unmarshal(line, &rec); err != nil {
+		s.skipped++
+		return
+	}
+	switch {
+	case rec.Op == "put" && rec.Entry != nil && rec.Entry.Result != nil:
+		s.entries[rec.Entry.Key] = rec.Entry
+	case rec.Op == "delete" && rec.Key != nil:
+		delete(s.entries, *rec.Key)
+	default:
+		s.skipped++
+	}
+}
+
+// Skipped returns the number of unreadable records found when the store was opened.
+func (s *FileRolloutStore) Skipped() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.skipped
+}
+
+// Quarantined returns the name a corrupt store file was moved to when the
+// store was opened, or "" if the file was sound.
+func (s *FileRolloutStore) Quarantined() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.quarantined
+}
+
+// Get implements RolloutStore.
+func (s *FileRolloutStore) Get(key RolloutKey) (*StoredRollout, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return copyStoredRollout(s.entries[key]), nil
+}
+
+// Put implements RolloutStore.
+func (s *FileRolloutStore) Put(entry *StoredRollout) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if old := s.entries[entry.Key]; old != nil && old.Result.TrialsCompleted > entry.Result.TrialsCompleted {
+		return nil
+	}
+	entry = copyStoredRollout(entry)
+	if err := writeJSONLine(s.f, rolloutStoreRecord{Op: "put", Entry: entry}); err != nil {
+		return fmt.Errorf("failed to write rollout store: %w", err)
+	}
+	s.entries[entry.Key] = entry
+	return nil
+}
+
+// Delete implements RolloutStore.
+func (s *FileRolloutStore) Delete(key RolloutKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.entries[key]; !ok {
+		return nil
+	}
+	if err := writeJSONLine(s.f, rolloutStoreRecord{Op: "delete", Key: &key}); err != nil {
+		return fmt.Errorf("failed to write rollout store: %w", err)
+	}
+	delete(s.entries, key)
+	return nil
+}
+
+// List implements RolloutStore.
+func (s *FileRolloutStore) List() ([]*StoredRollout, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]*StoredRollout, 0, len(s.entries))
+	for _, e := range s.entries {
+		list = append(list, copyStoredRollout(e))
+	}
+	sortStoredRollouts(list)
+	return list, nil
+}
+
+// Compact rewrites the file with one record per stored rollout, dropping
+// replaced, deleted and unreadable records.
+func (s *FileRolloutStore) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var buf bytes.Buffer
+	err := writeJSONLine(&buf, rolloutStoreHeader{Format: rolloutStoreFormat, Version: rolloutStoreVersion})
+	for _, e := range s.entries {
+		if err == nil {
+			err = writeJSONLine(&buf, rolloutStoreRecord{Op: "put", Entry: e})
+		}
+	}
+	if err == nil {
+		err = fileutil.AtomicWriteFile(s.path, buf.Bytes(), 0o644)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to compact rollout store: %w", err)
+	}
+
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to reopen rollout store: %w", err)
+	}
+	s.f.Close()
+	s.f = f
+	s.skipped = 0
+	return nil
+}
+
+// Close closes the store file.
+func (s *FileRolloutStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.f.Close()
+}
+
+// writeJSONLine writes v as one line of JSON.
+func writeJSONLine(w io.Writer, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// copyStoredRollout returns a copy of e that shares nothing with it.
+func copyStoredRollout(e *StoredRollout) *StoredRollout {
+	if e == nil {
+		return nil
+	}
+	c := *e
+	result := *e.Result
+	c.Result = &result
+	return &c
+}
+
+// sortStoredRollouts orders rollouts most recently updated first.
+func sortStoredRollouts(list []*StoredRollout) {
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].Updated.Equal(list[j].Updated) {
+			return list[i].Updated.After(list[j].Updated)
+		}
+		return list[i].Key.String() < list[j].Key.String()
+	})
+}
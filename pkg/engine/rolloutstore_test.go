@@ -0,0 +1,313 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testStoredRollout(position string, trials int, updated time.Time) *StoredRollout {
+	return &StoredRollout{
+		Key:     RolloutKey{Position: position, Cube: "turn=0", Settings: 1},
+		Result:  &RolloutResult{TrialsCompleted: trials, Equity: 0.1, Seed: 5},
+		Created: updated,
+		Updated: updated,
+	}
+}
+
+func TestFileRolloutStoreRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rollouts.jsonl")
+	s, err := OpenFileRolloutStore(path)
+	if err != nil {
+		t.Fatalf("OpenFileRolloutStore failed: %v", err)
+	}
+
+	t0 := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	a := testStoredRollout("A", 100, t0)
+	b := testStoredRollout("B", 200, t0.Add(time.Hour))
+	c := testStoredRollout("C", 300, t0)
+	for _, e := range []*StoredRollout{a, b, c} {
+		if err := s.Put(e); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+
+	// A shorter rollout does not replace a longer one
+	if err := s.Put(testStoredRollout("B", 50, t0)); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := s.Delete(c.Key); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := s.Delete(c.Key); err != nil {
+		t.Errorf("Delete of a missing key failed: %v", err)
+	}
+	s.Close()
+
+	s, err = OpenFileRolloutStore(path)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer s.Close()
+
+	list, err := s.List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(list) != 2 || list[0].Key != b.Key || list[1].Key != a.Key {
+		t.Fatalf("List = %v, want B then A", list)
+	}
+	if got := list[0].Result.TrialsCompleted; got != 200 {
+		t.Errorf("B has %d trials, want 200", got)
+	}
+	if !list[1].Created.Equal(t0) {
+		t.Errorf("A created %v, want %v", list[1].Created, t0)
+	}
+	if got, _ := s.Get(c.Key); got != nil {
+		t.Errorf("deleted key still stored: %+v", got)
+	}
+	if s.Skipped() != 0 {
+		t.Errorf("Skipped = %d, want 0", s.Skipped())
+	}
+}
+
+func TestFileRolloutStoreCorruption(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "rollouts.jsonl")
+
+	s, err := OpenFileRolloutStore(path)
+	if err != nil {
+		t.Fatalf("OpenFileRolloutStore failed: %v", err)
+	}
+	a := testStoredRollout("A", 100, time.Now())
+	if err := s.Put(a); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	s.Close()
+
+	// A garbage line and a record cut short by a crash
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("not json\n{\"op\":\"put\",\"entry\":{\"key\":")
+	f.Close()
+
+	s, err = OpenFileRolloutStore(path)
+	if err != nil {
+		t.Fatalf("open with corrupt records failed: %v", err)
+	}
+	if s.Skipped() != 2 {
+		t.Errorf("Skipped = %d, want 2", s.Skipped())
+	}
+	if got, _ := s.Get(a.Key); got == nil {
+		t.Error("good record lost")
+	}
+
+	// New records are readable after the truncated one
+	b := testStoredRollout("B", 100, time.Now())
+	if err := s.Put(b); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	s.Close()
+	s, err = OpenFileRolloutStore(path)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	if got, _ := s.Get(b.Key); got == nil {
+		t.Error("record written after a truncated line was lost")
+	}
+
+	// Compacting drops the bad records
+	if err := s.Compact(); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	s.Close()
+	s, err = OpenFileRolloutStore(path)
+	if err != nil {
+		t.Fatalf("reopen after compact failed: %v", err)
+	}
+	defer s.Close()
+	if s.Skipped() != 0 {
+		t.Errorf("Skipped after compact = %d, want 0", s.Skipped())
+	}
+	if list, _ := s.List(); len(list) != 2 {
+		t.Errorf("%d rollouts after compact, want 2", len(list))
+	}
+
+	// Files that are not rollout stores, or are too new, are refused
+	for name, content := range map[string]string{
+		"other.jsonl": "{\"format\":\"something-else\",\"version\":1}\n",
+		"newer.jsonl": "{\"format\":\"bgengine-rollouts\",\"version\":99}\n",
+	} {
+		p := filepath.Join(dir, name)
+		os.WriteFile(p, []byte(content), 0o644)
+		if _, err := OpenFileRolloutStore(p); err == nil {
+			t.Errorf("%s: expected error", name)
+		} else if name == "newer.jsonl" && !strings.Contains(err.Error(), "version 99") {
+			t.Errorf("%s: error %q does not name the version", name, err)
+		}
+	}
+
+	// A file with an unreadable header, like one cut short while it was
+	// created, is moved aside and the store starts empty
+	for name, content := range map[string]string{
+		"text.jsonl":      "hello\n",
+		"truncated.jsonl": "{\"format\":\"bgengine-rol",
+	} {
+		p := filepath.Join(dir, name)
+		os.WriteFile(p, []byte(content), 0o644)
+		s, err := OpenFileRolloutStore(p)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		aside := s.Quarantined()
+		if list, _ := s.List(); aside == "" || len(list) != 0 {
+			t.Errorf("%s: quarantined to %q with %d rollouts", name, aside, len(list))
+		}
+		if data, err := os.ReadFile(aside); err != nil || string(data) != content {
+			t.Errorf("%s: quarantined file %q holds %q, %v", name, aside, data, err)
+		}
+		s.Close()
+		if s, err := OpenFileRolloutStore(p); err != nil || s.Quarantined() != "" {
+			t.Errorf("%s: reopening the fresh store: %v", name, err)
+		} else {
+			s.Close()
+		}
+	}
+}
+
+func TestStoredRollout(t *testing.T) {
+	store, err := OpenFileRolloutStore(filepath.Join(t.TempDir(), "rollouts.jsonl"))
+	if err != nil {
+		t.Fatalf("OpenFileRolloutStore failed: %v", err)
+	}
+	defer store.Close()
+	e, err := NewEngine(EngineOptions{RolloutStore: store})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	plain, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	state := StartingPosition()
+	opts := RolloutOptions{Trials: 100, Truncate: 6, Seed: 77, Workers: 2}
+
+	first, err := e.Rollout(state, opts)
+	if err != nil {
+		t.Fatalf("Rollout failed: %v", err)
+	}
+	if first.Cached || first.StoredAt.IsZero() {
+		t.Errorf("first rollout: Cached = %v, StoredAt = %v", first.Cached, first.StoredAt)
+	}
+
+	// The same or fewer trials come from the store
+	for _, trials := range []int{100, 40} {
+		opts.Trials = trials
+		again, err := e.Rollout(state, opts)
+		if err != nil {
+			t.Fatalf("Rollout failed: %v", err)
+		}
+		if !again.Cached || again.TrialsCompleted != 100 || again.Equity != first.Equity {
+			t.Errorf("%d trials: got cached=%v trials=%d equity=%v, want the stored rollout",
+				trials, again.Cached, again.TrialsCompleted, again.Equity)
+		}
+		if !again.StoredAt.Equal(first.StoredAt) {
+			t.Errorf("%d trials: StoredAt = %v, want %v", trials, again.StoredAt, first.StoredAt)
+		}
+	}
+
+	// More trials extend the stored rollout to exactly the longer rollout
+	opts.Trials = 250
+	extended, err := e.Rollout(state, opts)
+	if err != nil {
+		t.Fatalf("Rollout failed: %v", err)
+	}
+	whole, err := plain.Rollout(state, opts)
+	if err != nil {
+		t.Fatalf("Rollout failed: %v", err)
+	}
+	if extended.Cached || extended.TrialsCompleted != 250 || extended.Equity != whole.Equity {
+		t.Errorf("extended: cached=%v trials=%d equity=%v, want %d trials equity %v",
+			extended.Cached, extended.TrialsCompleted, extended.Equity, whole.TrialsCompleted, whole.Equity)
+	}
+	if !extended.StoredAt.Equal(first.StoredAt) {
+		t.Errorf("extended StoredAt = %v, want the original %v", extended.StoredAt, first.StoredAt)
+	}
+
+	// Other settings are stored separately
+	opts.Truncate = 8
+	other, err := e.Rollout(state, opts)
+	if err != nil {
+		t.Fatalf("Rollout failed: %v", err)
+	}
+	if other.Cached {
+		t.Error("rollout with different truncation came from the store")
+	}
+	if list, _ := store.List(); len(list) != 2 {
+		t.Errorf("%d stored rollouts, want 2", len(list))
+	}
+}
+
+// countingStore counts the writes to a RolloutStore.
+type countingStore struct {
+	RolloutStore
+	mu   sync.Mutex
+	puts int
+}
+
+func (s *countingStore) Put(entry *StoredRollout) error {
+	s.mu.Lock()
+	s.puts++
+	s.mu.Unlock()
+	return s.RolloutStore.Put(entry)
+}
+
+func TestStoredRolloutConcurrent(t *testing.T) {
+	file, err := OpenFileRolloutStore(filepath.Join(t.TempDir(), "rollouts.jsonl"))
+	if err != nil {
+		t.Fatalf("OpenFileRolloutStore failed: %v", err)
+	}
+	defer file.Close()
+	store := &countingStore{RolloutStore: file}
+	e, err := NewEngine(EngineOptions{RolloutStore: store})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	state := StartingPosition()
+	opts := RolloutOptions{Trials: 200, Truncate: 6, Workers: 2}
+
+	var wg sync.WaitGroup
+	results := make([]*RolloutResult, 2)
+	errs := make([]error, 2)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = e.Rollout(state, opts)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			t.Fatalf("Rollout failed: %v", err)
+		}
+	}
+	if store.puts != 1 {
+		t.Errorf("store written %d times, want 1", store.puts)
+	}
+	if results[0].Cached == results[1].Cached {
+		t.Errorf("Cached = %v and %v, want exactly one cached result", results[0].Cached, results[1].Cached)
+	}
+	if results[0].Equity != results[1].Equity {
+		t.Errorf("equities differ: %v and %v", results[0].Equity, results[1].Equity)
+	}
+}
@@ -0,0 +1,107 @@
+package engine
+
+import (
+	"log/slog"
+	"math"
+
+	"github.com/yourusername/bgengine/internal/neuralnet"
+)
+
+// significantCorrection is the total change to an output, summed over the
+// five probabilities, above which SanitizeOutput corrections are logged.
+const significantCorrection = 1e-3
+
+// SanitizeOutput makes a raw evaluator output [win, winG, winBG, loseG, loseBG]
+// consistent: every probability is clamped to [0, 1] (NaN counts as 0, or as
+// 0.5 for the win probability), then the gammon and backgammon chances are cut
+// back so that winBG <= winG <= win and loseBG <= loseG <= 1-win.
+// It returns the total amount the output was changed by.
+func SanitizeOutput(output *[5]float32) float32 {
+	var change float32
+	set := func(i int, v float32) {
+		if v != output[i] {
+			if math.IsNaN(float64(output[i])) || math.IsInf(float64(output[i]), 0) {
+				change += 1
+			} else {
+				change += float32(math.Abs(float64(v - output[i])))
+			}
+			output[i] = v
+		}
+	}
+
+	for i, v := range output {
+		switch {
+		case math.IsNaN(float64(v)) && i == 0:
+			set(i, 0.5)
+		case math.IsNaN(float64(v)) || v < 0:
+			set(i, 0)
+		case v > 1:
+			set(i, 1)
+		}
+	}
+
+	win := output[0]
+	set(1, min(output[1], win))
+	set(2, min(output[2], output[1]))
+	set(3, min(output[3], 1-win))
+	set(4, min(output[4], output[3]))
+	return change
+}
+
+// clearImpossibleGammons zeroes the gammon and backgammon chances against a
+// side that has borne off a checker, as gnubg's SanityCheck does before using
+// a net output: the nets give such positions small gammon chances that can
+// never happen. totals are the checkers each side plays with (see
+// GameState.TotalCheckers), so a side of a partial position with all of its
+// checkers on the board keeps its gammons. board[1] is the player on roll,
+// whose gammon wins are output[1] and output[2]; output[3] and output[4] are
+// the opponent's.
+func clearImpossibleGammons(board neuralnet.Board, totals [2]int, output *[5]float32) {
+	var checkers [2]int
+	for side := 0; side < 2; side++ {
+		for _, n := range board[side] {
+			checkers[side] += int(n)
+		}
+	}
+	if checkers[0] < totals[0] {
+		output[1], output[2] = 0, 0
+	}
+	if checkers[1] < totals[1] {
+		output[3], output[4] = 0, 0
+	}
+}
+
+// sanitizeOutput applies SanitizeOutput to an evaluator output and logs
+// corrections large enough to point at a problem with the evaluator.
+func sanitizeOutput(output *[5]float32, evaluator string) {
+	raw := *output
+	if change := SanitizeOutput(output); change > significantCorrection {
+		logCorrection(evaluator, raw, *output, change)
+	}
+}
+
+// logCorrection logs a correction made by sanitizeOutput. It takes the
+// outputs by value so that sanitizeOutput's caller keeps its output on the
+// stack: slicing it for the logger would move it to the heap on every call.
+func logCorrection(evaluator string, raw, corrected [5]float32, change float32) {
+	slog.Debug("corrected inconsistent evaluator output",
+		"evaluator", evaluator, "raw", raw[:], "corrected", corrected[:], "change", change)
+}
+
+// sanitizeProbs returns a sanitized copy of the five probabilities in arOutput,
+// for the cube formulas that take float64 outputs.
+func sanitizeProbs(arOutput []float64) []float64 {
+	var output [5]float32
+	for i := range output {
+		output[i] = float32(arOutput[i])
+	}
+	if SanitizeOutput(&output) == 0 {
+		return arOutput
+	}
+	ar := make([]float64, len(arOutput))
+	copy(ar, arOutput)
+	for i, v := range output {
+		ar[i] = float64(v)
+	}
+	return ar
+}
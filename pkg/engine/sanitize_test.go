@@ -0,0 +1,255 @@
+package engine
+
+import (
+	"math"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/yourusername/bgengine/internal/neuralnet"
+	"github.com/yourusername/bgengine/internal/positionid"
+)
+
+// adversarialProb returns a probability that is often out of range or invalid.
+func adversarialProb(rng *rand.Rand) float64 {
+	switch rng.Intn(10) {
+	case 0:
+		return math.NaN()
+	case 1:
+		return math.Inf(1 - 2*rng.Intn(2))
+	case 2:
+		return -rng.Float64() * 1e-3
+	case 3:
+		return 1 + rng.Float64()*1e-3
+	case 4:
+		return 0
+	default:
+		return rng.Float64()*1.4 - 0.2
+	}
+}
+
+// constantNet returns a net whose outputs ignore the position: output i is
+// the logistic function of logits[i].
+func constantNet(inputs uint32, logits ...float32) *neuralnet.NeuralNet {
+	return &neuralnet.NeuralNet{
+		CInput:          inputs,
+		CHidden:         1,
+		COutput:         5,
+		RBetaHidden:     1,
+		RBetaOutput:     1,
+		HiddenWeight:    make([]float32, inputs),
+		OutputWeight:    make([]float32, 5),
+		HiddenThreshold: []float32{0},
+		OutputThreshold: logits,
+	}
+}
+
+func checkConsistent(t *testing.T, raw, output [5]float32) {
+	t.Helper()
+	for i, v := range output {
+		if !(v >= 0 && v <= 1) {
+			t.Fatalf("SanitizeOutput(%v)[%d] = %v, want within [0, 1]", raw, i, v)
+		}
+	}
+	if output[2] > output[1] || output[1] > output[0] || output[4] > output[3] || output[3] > 1-output[0] {
+		t.Fatalf("SanitizeOutput(%v) = %v, gammon chances out of order", raw, output)
+	}
+}
+
+func TestSanitizeOutput(t *testing.T) {
+	tests := []struct {
+		raw, want [5]float32
+	}{
+		{[5]float32{0.6, 0.2, 0.01, 0.1, 0.005}, [5]float32{0.6, 0.2, 0.01, 0.1, 0.005}},
+		{[5]float32{0.3, 0.31, 0.01, 0.1, 0.005}, [5]float32{0.3, 0.3, 0.01, 0.1, 0.005}},
+		{[5]float32{1.01, 0.2, -0.001, 0.1, 0.005}, [5]float32{1, 0.2, 0, 0, 0}},
+		{[5]float32{0.5, 0.1, 0.2, 0.1, 0.05}, [5]float32{0.5, 0.1, 0.1, 0.1, 0.05}},
+		{[5]float32{float32(math.NaN()), 0.1, 0, 0.1, 0}, [5]float32{0.5, 0.1, 0, 0.1, 0}},
+	}
+	for _, tt := range tests {
+		got := tt.raw
+		change := SanitizeOutput(&got)
+		if got != tt.want {
+			t.Errorf("SanitizeOutput(%v) = %v, want %v", tt.raw, got, tt.want)
+		}
+		if (change == 0) != (tt.raw == tt.want) {
+			t.Errorf("SanitizeOutput(%v) reported change %v", tt.raw, change)
+		}
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	for n := 0; n < 10000; n++ {
+		var raw [5]float32
+		for i := range raw {
+			raw[i] = float32(adversarialProb(rng))
+		}
+		output := raw
+		SanitizeOutput(&output)
+		checkConsistent(t, raw, output)
+
+		again := output
+		if change := SanitizeOutput(&again); change != 0 || again != output {
+			t.Fatalf("SanitizeOutput is not idempotent on %v: %v (change %v)", output, again, change)
+		}
+	}
+}
+
+func TestCubeFormulasBounded(t *testing.T) {
+	e, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	cubes := []*CubeInfo{
+		SetCubeInfoMoney(1, -1, 0, false, false),
+		SetCubeInfoMoney(1, -1, 0, true, false),
+		SetCubeInfoMoney(2, 0, 0, false, false),
+		SetCubeInfoMoney(2, 1, 0, false, false),
+	}
+
+	rng := rand.New(rand.NewSource(2))
+	for n := 0; n < 10000; n++ {
+		arOutput := make([]float64, 5)
+		for i := range arOutput {
+			arOutput[i] = adversarialProb(rng)
+		}
+		for _, pci := range cubes {
+			for name, eq := range map[string]float64{
+				"Utility":    e.Utility(arOutput, pci),
+				"Cl2CfMoney": e.Cl2CfMoney(arOutput, pci, rng.Float64()),
+			} {
+				if math.IsNaN(eq) || eq < -3 || eq > 3 {
+					t.Fatalf("%s(%v, cube %d owner %d) = %v, want within [-3, 3]",
+						name, arOutput, pci.NCube, pci.FCubeOwner, eq)
+				}
+			}
+		}
+	}
+}
+
+func TestEvaluateSanitized(t *testing.T) {
+	e, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	// A race net with gammons more likely than wins, and loser's gammons
+	// more likely than losing
+	net := constantNet(neuralnet.NumRaceInputs, -2, 2, 3, 3, 4)
+	if raw := net.Evaluate(make([]float32, neuralnet.NumRaceInputs)); raw[1] <= raw[0] {
+		t.Fatalf("test net output %v is already consistent", raw)
+	}
+	e.race = net
+	e.initBufferPools()
+
+	state := &GameState{CubeValue: 1, CubeOwner: -1}
+	state.Board[0][7], state.Board[0][8], state.Board[0][9] = 5, 5, 5
+	state.Board[1][7], state.Board[1][8], state.Board[1][9] = 5, 5, 5
+	if class := neuralnet.ClassifyPosition(neuralnet.Board(state.Board)); class != neuralnet.ClassRace {
+		t.Fatalf("test position is %v, want a race", class)
+	}
+
+	eval, err := e.Evaluate(state)
+	if err != nil {
+		t.Fatalf("Evaluate failed: %v", err)
+	}
+	output := [5]float32{float32(eval.WinProb), float32(eval.WinG), float32(eval.WinBG), float32(eval.LoseG), float32(eval.LoseBG)}
+	checkConsistent(t, output, output)
+	if eval.Equity < -3 || eval.Equity > 3 {
+		t.Errorf("Equity = %v, want within [-3, 3]", eval.Equity)
+	}
+
+	for _, match := range []int{0, 7} {
+		state.MatchLength = match
+		state.Score = [2]int{3, 4}
+		cube, err := e.AnalyzeCube(state)
+		if err != nil {
+			t.Fatalf("AnalyzeCube failed: %v", err)
+		}
+		for name, eq := range map[string]float64{
+			"no double":   cube.NoDoubleEquity,
+			"double/take": cube.DoubleTakeEq,
+			"double/pass": cube.DoublePassEq,
+		} {
+			if math.IsNaN(eq) || math.IsInf(eq, 0) || math.Abs(eq) > 6 {
+				t.Errorf("match %d: %s equity = %v", match, name, eq)
+			}
+		}
+	}
+}
+
+func TestClearImpossibleGammons(t *testing.T) {
+	e, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	// A race net giving both sides gammon chances
+	e.race = constantNet(neuralnet.NumRaceInputs, 0, -2, -4, -2, -4)
+	e.initBufferPools()
+
+	tests := []struct {
+		name         string
+		mover, opp   map[int]uint8
+		wins, losses bool // Whether gammons are still possible
+	}{
+		{"nobody off", map[int]uint8{10: 5, 9: 5, 8: 5}, map[int]uint8{10: 5, 9: 5, 8: 5}, true, true},
+		{"mover off", map[int]uint8{10: 5, 9: 5, 8: 4}, map[int]uint8{10: 5, 9: 5, 8: 5}, true, false},
+		{"opponent off", map[int]uint8{10: 5, 9: 5, 8: 5}, map[int]uint8{10: 5, 9: 3, 8: 2}, false, true},
+		{"both off", map[int]uint8{10: 5, 9: 5}, map[int]uint8{10: 5, 9: 3, 8: 2}, false, false},
+	}
+	for _, tt := range tests {
+		state := &GameState{Board: boardFromPoints(tt.mover, tt.opp), CubeValue: 1, CubeOwner: -1}
+		if class := neuralnet.ClassifyPosition(neuralnet.Board(state.Board)); class != neuralnet.ClassRace {
+			t.Fatalf("%s: position is %v, want a race", tt.name, class)
+		}
+		eval, err := e.Evaluate(state)
+		if err != nil {
+			t.Fatalf("%s: Evaluate failed: %v", tt.name, err)
+		}
+		if wins := eval.WinG > 0 && eval.WinBG > 0; wins != tt.wins {
+			t.Errorf("%s: WinG = %v, WinBG = %v, want gammons possible = %t", tt.name, eval.WinG, eval.WinBG, tt.wins)
+		}
+		if losses := eval.LoseG > 0 && eval.LoseBG > 0; losses != tt.losses {
+			t.Errorf("%s: LoseG = %v, LoseBG = %v, want gammons possible = %t", tt.name, eval.LoseG, eval.LoseBG, tt.losses)
+		}
+	}
+}
+
+// TestClearImpossibleGammonsFixture checks clearImpossibleGammons against
+// the borne-off rule, applied by hand, for the positions in
+// testdata/borneoff.txt.
+func TestClearImpossibleGammonsFixture(t *testing.T) {
+	data, err := os.ReadFile("testdata/borneoff.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) != 11 {
+			t.Fatalf("malformed line %q", line)
+		}
+		board, err := positionid.BoardFromPositionID(fields[0])
+		if err != nil {
+			t.Fatalf("%s: %v", fields[0], err)
+		}
+		var raw, want [5]float32
+		for i := range raw {
+			r, err1 := strconv.ParseFloat(fields[1+i], 32)
+			w, err2 := strconv.ParseFloat(fields[6+i], 32)
+			if err1 != nil || err2 != nil {
+				t.Fatalf("malformed line %q", line)
+			}
+			raw[i], want[i] = float32(r), float32(w)
+		}
+
+		got := raw
+		clearImpossibleGammons(neuralnet.Board(board), fullTotals, &got)
+		if got != want {
+			t.Errorf("%s: clearImpossibleGammons(%v) = %v, want %v", fields[0], raw, got, want)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/yourusername/bgengine/internal/bearoff"
+	"github.com/yourusername/bgengine/internal/met"
+)
+
+// Self-check statuses, for SelfCheckSentinel.Status
+const (
+	SelfCheckPass    = "pass"
+	SelfCheckFail    = "fail"
+	SelfCheckSkipped = "skipped" // The data the sentinel checks is not loaded
+)
+
+// SelfCheckValue is a value a sentinel observed, with the range it must
+// fall in.
+type SelfCheckValue struct {
+	Name     string  `json:"name"` // What was observed, such as "win" or "win_g"
+	Observed float64 `json:"observed"`
+	Min      float64 `json:"min"`
+	Max      float64 `json:"max"`
+}
+
+// ok reports whether v is in its range.
+func (v SelfCheckValue) ok() bool {
+	return v.Observed >= v.Min && v.Observed <= v.Max
+}
+
+// SelfCheckSentinel is the outcome of one sentinel of SelfCheck.
+type SelfCheckSentinel struct {
+	Name   string           `json:"name"`             // "contact", "race", "terminal_gammon", "bearoff", "bearoff-ts", "bearoff-os" or "met"
+	Status string           `json:"status"`           // SelfCheckPass, SelfCheckFail or SelfCheckSkipped
+	Detail string           `json:"detail,omitempty"` // Why the sentinel failed or was skipped
+	Values []SelfCheckValue `json:"values,omitempty"` // The values observed
+}
+
+// SelfCheckReport is the outcome of SelfCheck.
+type SelfCheckReport struct {
+	Pass      bool                `json:"pass"` // No sentinel failed
+	CheckedAt time.Time           `json:"checked_at"`
+	Duration  time.Duration       `json:"-"`
+	Sentinels []SelfCheckSentinel `json:"sentinels"`
+}
+
+// Failed returns the sentinels of r that failed.
+func (r *SelfCheckReport) Failed() []SelfCheckSentinel {
+	var failed []SelfCheckSentinel
+	for _, s := range r.Sentinels {
+		if s.Status == SelfCheckFail {
+			failed = append(failed, s)
+		}
+	}
+	return failed
+}
+
+// String summarizes r in a line: "pass" or the failed sentinels and why.
+func (r *SelfCheckReport) String() string {
+	if r.Pass {
+		return "pass"
+	}
+	var parts []string
+	for _, s := range r.Failed() {
+		parts = append(parts, s.Name+": "+s.Detail)
+	}
+	return "fail: " + strings.Join(parts, "; ")
+}
+
+// sentinel is a check SelfCheck runs. It returns the values it observed,
+// or skipped with the reason if the engine lacks what it checks.
+type sentinel struct {
+	name  string
+	check func(e *Engine) (values []SelfCheckValue, skipped string, err error)
+}
+
+// Ranges a 0-ply evaluation by the gnubg nets falls in
+var (
+	// The starting position, with the player on roll
+	sentinelStartWin = [2]float64{0.48, 0.52}
+	// A race of 106 pips against 119 with the player on roll ahead, which
+	// rolls out to a 0.80 win
+	sentinelRaceWin = [2]float64{0.78, 0.82}
+)
+
+// sentinelRace is the race sentinel's board.
+func sentinelRace() Board {
+	return boardFromPoints(
+		map[int]uint8{10: 3, 8: 4, 6: 4, 5: 4},
+		map[int]uint8{13: 3, 10: 4, 6: 4, 4: 4})
+}
+
+// sentinels returns the checks SelfCheck runs: a position for each net, a
+// finished game, each bearoff database by a position whose odds are known
+// exactly, and the match equity table by a score every published table
+// gives much the same equity.
+func sentinels() []sentinel {
+	return []sentinel{
+		{"contact", func(e *Engine) ([]SelfCheckValue, string, error) {
+			return e.netSentinel(StartingPosition().Board, sentinelStartWin)
+		}},
+		{"race", func(e *Engine) ([]SelfCheckValue, string, error) {
+			return e.netSentinel(sentinelRace(), sentinelRaceWin)
+		}},
+		{"terminal_gammon", func(e *Engine) ([]SelfCheckValue, string, error) {
+			// The player on roll has borne off against 15 checkers
+			// outside the home board: exactly a gammon
+			board := boardFromPoints(nil, map[int]uint8{6: 5, 8: 5, 13: 5})
+			eval, err := e.Evaluate(&GameState{Board: board, CubeValue: 1, CubeOwner: -1})
+			if err != nil {
+				return nil, "", err
+			}
+			return []SelfCheckValue{
+				{Name: "win", Observed: eval.WinProb, Min: 1, Max: 1},
+				{Name: "win_g", Observed: eval.WinG, Min: 1, Max: 1},
+				{Name: "win_bg", Observed: eval.WinBG, Min: 0, Max: 0},
+			}, "", nil
+		}},
+		{"bearoff", func(e *Engine) ([]SelfCheckValue, string, error) {
+			// A checker on the 6 point comes off in one roll unless the
+			// roll is 11, 21, 31, 41 or 32
+			return oneRollSentinel(e.bearoff, 6, 27.0/36)
+		}},
+		{"bearoff-ts", func(e *Engine) ([]SelfCheckValue, string, error) {
+			if e.bearoffTS == nil {
+				return nil, "no two-sided bearoff database loaded", nil
+			}
+			// A checker on the 6 point against one on the 1 point wins if
+			// it comes off at once
+			var board bearoff.Board
+			board[1][5], board[0][0] = 1, 1
+			output, err := e.bearoffTS.Evaluate(board)
+			if err != nil {
+				return nil, "", err
+			}
+			return []SelfCheckValue{exactValue("win", float64(output[0]), 27.0/36)}, "", nil
+		}},
+		{"bearoff-os", func(e *Engine) ([]SelfCheckValue, string, error) {
+			// A checker on the 7 point needs 7 pips: 9 rolls and their
+			// reverses, and every double but 11
+			return oneRollSentinel(e.bearoffOS, 7, 23.0/36)
+		}},
+		{"met", func(e *Engine) ([]SelfCheckValue, string, error) {
+			if e.met == nil || e.met.Name == met.Default().Name {
+				return nil, "no match equity table loaded (the built-in approximation is in use)", nil
+			}
+			// The leader of a 3-point match at 2-1 in the Crawford game:
+			// 0.68 to 0.70 in the published tables
+			return []SelfCheckValue{
+				{Name: "crawford_1_away_2_away", Observed: float64(e.met.GetME(2, 1, 3, 0, true)), Min: 0.66, Max: 0.73},
+				exactValue("even_5_away", float64(e.met.GetME(0, 0, 5, 0, false)), 0.5),
+			}, "", nil
+		}},
+	}
+}
+
+// netSentinel evaluates board, which must be evaluated by a net, and checks
+// its winning chance against win.
+func (e *Engine) netSentinel(board Board, win [2]float64) ([]SelfCheckValue, string, error) {
+	state := &GameState{Board: board, CubeValue: 1, CubeOwner: -1}
+	eval, err := e.Evaluate(state)
+	if err != nil {
+		return nil, "", err
+	}
+	values := []SelfCheckValue{{Name: "win", Observed: eval.WinProb, Min: win[0], Max: win[1]}}
+	if source := e.Source(state); source != SourceNet {
+		return values, "", fmt.Errorf("evaluated by %s, not a net: no weights loaded", source)
+	}
+	return values, "", nil
+}
+
+// oneRollSentinel checks that a side of one checker on point of a one-sided
+// database db comes off in one roll with probability want.
+func oneRollSentinel(db *bearoff.Database, point int, want float64) ([]SelfCheckValue, string, error) {
+	if db == nil {
+		return nil, "no bearoff database loaded", nil
+	}
+	var side [bearoff.MaxPoints]uint8
+	side[point-1] = 1
+	prob, err := db.Distribution(side)
+	if err != nil {
+		return nil, "", err
+	}
+	return []SelfCheckValue{exactValue("one_roll", float64(prob[1]), want)}, "", nil
+}
+
+// exactValue is a value known exactly, allowing for the float32 rounding of
+// the data files.
+func exactValue(name string, observed, want float64) SelfCheckValue {
+	return SelfCheckValue{Name: name, Observed: observed, Min: want - 1e-3, Max: want + 1e-3}
+}
+
+// SelfCheck evaluates a handful of sentinel positions with known odds and
+// reports which come out wrong, so that a server started with the wrong
+// weights, bearoff databases or match equity table can say so rather than
+// serve nonsense. The contact and race sentinels must be evaluated by the
+// nets; the bearoff and match equity sentinels are skipped when their data
+// is not loaded. It takes about as long as a dozen 0-ply evaluations.
+func (e *Engine) SelfCheck() *SelfCheckReport {
+	return e.selfCheck(sentinels())
+}
+
+// selfCheck runs checks.
+func (e *Engine) selfCheck(checks []sentinel) *SelfCheckReport {
+	start := time.Now()
+	report := &SelfCheckReport{Pass: true, CheckedAt: start}
+	for _, s := range checks {
+		result := SelfCheckSentinel{Name: s.name, Status: SelfCheckPass}
+		values, skipped, err := s.check(e)
+		result.Values = values
+		switch {
+		case err != nil:
+			result.Status, result.Detail = SelfCheckFail, err.Error()
+		case skipped != "":
+			result.Status, result.Detail = SelfCheckSkipped, skipped
+		default:
+			for _, v := range values {
+				if !v.ok() {
+					result.Status = SelfCheckFail
+					result.Detail = fmt.Sprintf("%s is %.4f, want %.4f to %.4f", v.Name, v.Observed, v.Min, v.Max)
+					break
+				}
+			}
+		}
+		if result.Status == SelfCheckFail {
+			report.Pass = false
+		}
+		report.Sentinels = append(report.Sentinels, result)
+	}
+	report.Duration = time.Since(start)
+	return report
+}
@@ -0,0 +1,117 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/yourusername/bgengine/internal/met"
+)
+
+// sentinelResult returns the result of the sentinel named name in r.
+func sentinelResult(t *testing.T, r *SelfCheckReport, name string) SelfCheckSentinel {
+	t.Helper()
+	for _, s := range r.Sentinels {
+		if s.Name == name {
+			return s
+		}
+	}
+	t.Fatalf("report has no %s sentinel: %+v", name, r.Sentinels)
+	return SelfCheckSentinel{}
+}
+
+func TestSelfCheck(t *testing.T) {
+	e, err := NewEngine(EngineOptions{
+		WeightsFileText: "../../data/gnubg.weights",
+		BearoffFile:     "../../data/gnubg_os0.bd",
+		BearoffTSFile:   "../../data/gnubg_ts.bd",
+		METFile:         "../../data/g11.xml",
+	})
+	if err != nil {
+		t.Skip("Skipping - data files not available")
+	}
+
+	r := e.SelfCheck()
+	if !r.Pass {
+		t.Errorf("self-check with the gnubg data failed: %s", r)
+	}
+	for _, s := range r.Sentinels {
+		if s.Status == SelfCheckSkipped && s.Name != "bearoff-os" {
+			t.Errorf("%s sentinel skipped: %s", s.Name, s.Detail)
+		}
+	}
+}
+
+func TestSelfCheckWithoutWeights(t *testing.T) {
+	e, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+
+	r := e.SelfCheck()
+	if r.Pass {
+		t.Fatal("self-check of an engine without weights passed")
+	}
+	for _, name := range []string{"contact", "race"} {
+		s := sentinelResult(t, r, name)
+		if s.Status != SelfCheckFail || !strings.Contains(s.Detail, "no weights") {
+			t.Errorf("%s sentinel = %+v, want a failure for the missing weights", name, s)
+		}
+		if len(s.Values) != 1 || s.Values[0].Observed == 0 {
+			t.Errorf("%s sentinel reports no observed value: %+v", name, s.Values)
+		}
+	}
+	if s := sentinelResult(t, r, "terminal_gammon"); s.Status != SelfCheckPass {
+		t.Errorf("terminal gammon sentinel = %+v, want a pass", s)
+	}
+	for _, name := range []string{"bearoff", "bearoff-ts", "bearoff-os", "met"} {
+		if s := sentinelResult(t, r, name); s.Status != SelfCheckSkipped {
+			t.Errorf("%s sentinel without its data = %+v, want skipped", name, s)
+		}
+	}
+	if got := r.String(); !strings.HasPrefix(got, "fail: contact: ") || !strings.Contains(got, "race: ") {
+		t.Errorf("String() = %q, want the contact and race failures", got)
+	}
+}
+
+func TestSelfCheckMET(t *testing.T) {
+	e, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+
+	// A table with the published Crawford equity
+	table := met.Default()
+	table.Name = "test"
+	table.PostCrawford[1][1] = 0.31
+	e.met = table
+	if s := sentinelResult(t, e.SelfCheck(), "met"); s.Status != SelfCheckPass {
+		t.Errorf("met sentinel = %+v, want a pass", s)
+	}
+
+	// A table of another shape, as if the wrong file were loaded
+	table.PostCrawford[1][1] = 0.45
+	if s := sentinelResult(t, e.SelfCheck(), "met"); s.Status != SelfCheckFail || !strings.Contains(s.Detail, "crawford_1_away_2_away is 0.5500") {
+		t.Errorf("met sentinel = %+v, want a failure for the Crawford equity", s)
+	}
+}
+
+func TestSelfCheckReport(t *testing.T) {
+	e, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	value := func(observed float64) func(*Engine) ([]SelfCheckValue, string, error) {
+		return func(*Engine) ([]SelfCheckValue, string, error) {
+			return []SelfCheckValue{{Name: "v", Observed: observed, Min: 0.4, Max: 0.6}}, "", nil
+		}
+	}
+
+	r := e.selfCheck([]sentinel{{"in", value(0.5)}, {"edge", value(0.6)}})
+	if !r.Pass || len(r.Failed()) != 0 || r.String() != "pass" {
+		t.Errorf("report of values in range = %+v, want a pass", r)
+	}
+	r = e.selfCheck([]sentinel{{"in", value(0.5)}, {"out", value(0.7)}})
+	if r.Pass || len(r.Failed()) != 1 || r.String() != "fail: out: v is 0.7000, want 0.4000 to 0.6000" {
+		t.Errorf("report of a value out of range = %+v (%s), want the out sentinel failed", r, r)
+	}
+}
@@ -0,0 +1,279 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/yourusername/bgengine/internal/positionid"
+)
+
+// ErrIllegalHop is returned when a partial move contains a hop that cannot
+// be played: no checker to move, a blocked point, a die already used, a
+// checker moved while another waits on the bar, or a bear-off too early.
+var ErrIllegalHop = errors.New("illegal checker hop")
+
+// ErrNoCompletion is returned by CompletePartial when the hops so far are
+// playable but no legal full move starts with them.
+var ErrNoCompletion = errors.New("partial move cannot be completed to a legal move")
+
+// PartialMove is one checker hop of a move entered a die at a time, as an
+// interactive board does. From and To are board indices as in Move: From is
+// 0-23 or 24 for the bar, To is 0-23 or -1 for a checker borne off. Die is
+// the die the hop uses, which a bear-off with a larger die does not imply.
+type PartialMove struct {
+	From int
+	To   int
+	Die  int
+}
+
+// String returns the hop in move notation, e.g. "13/10" or "3/off".
+func (h PartialMove) String() string {
+	return FormatMove(Move{
+		From: [4]int8{int8(h.From), -1, -1, -1},
+		To:   [4]int8{int8(h.To), -1, -1, -1},
+	})
+}
+
+// SubMoves is what may follow a partial move; see LegalSubMoves.
+type SubMoves struct {
+	Next        []PartialMove // Hops that still lead to a legal full move, highest origin first
+	Rejected    []PartialMove // Hops playable now that no legal full move continues
+	Remaining   []int         // Dice not yet played
+	Completable bool          // Some legal full move starts with the hops so far
+	Complete    bool          // The hops so far are a legal full move
+}
+
+// LegalSubMoves reports which single-checker hops may follow the hops in
+// used, played in order from board with the player on roll in board[1].
+//
+// The rules that a move must use both dice if it can, or else the larger
+// one, are applied to the move as a whole: a hop is offered in Next if some
+// legal full move goes on from it, and is listed in Rejected if it can be
+// played but would make every completion illegal, such as using the larger
+// die in a way that leaves the smaller one unplayable when both could be
+// played. A used hop that cannot be played at all is an error wrapping
+// ErrIllegalHop; hops that can be played but lead nowhere are not an error
+// and leave Completable false.
+func LegalSubMoves(board Board, dice [2]int, used []PartialMove) (*SubMoves, error) {
+	if dice[0] < 1 || dice[0] > 6 || dice[1] < 1 || dice[1] > 6 {
+		return nil, fmt.Errorf("invalid dice %d-%d", dice[0], dice[1])
+	}
+	remaining := []int{dice[0], dice[1]}
+	if dice[0] == dice[1] {
+		remaining = append(remaining, dice[0], dice[0])
+	}
+	goalMoves, goalPips := bestHops(board, remaining)
+
+	pos, pips := board, 0
+	for i, h := range used {
+		var err error
+		if remaining, err = playHop(&pos, remaining, h); err != nil {
+			return nil, fmt.Errorf("hop %d (%s with a %d): %w", i+1, h, h.Die, err)
+		}
+		pips += h.Die
+	}
+
+	result := &SubMoves{Remaining: append([]int(nil), remaining...)}
+	moves, morePips := bestHops(pos, remaining)
+	result.Completable = len(used)+moves == goalMoves && pips+morePips == goalPips
+	result.Complete = len(used) == goalMoves && pips == goalPips
+	if !result.Completable || result.Complete {
+		return result, nil
+	}
+
+	for from := 24; from >= 0; from-- {
+		for _, die := range distinctDice(remaining) {
+			if !canHop(pos, from, die) {
+				continue
+			}
+			next := pos
+			applySubMove(&next, from, die)
+			moves, morePips := bestHops(next, withoutDie(remaining, die))
+			h := PartialMove{From: from, To: hopDest(from, die), Die: die}
+			if len(used)+1+moves == goalMoves && pips+die+morePips == goalPips {
+				result.Next = append(result.Next, h)
+			} else {
+				result.Rejected = append(result.Rejected, h)
+			}
+		}
+	}
+	return result, nil
+}
+
+// CompletePartial returns the legal full moves that start with the hops in
+// used, ranked as AnalyzePositionWithOptions ranks them with opts. Moves that
+// reach the same position count once, whichever order their hops were
+// played in. It returns an error wrapping ErrIllegalHop if a hop cannot be
+// played, and one wrapping ErrNoCompletion if no legal move starts with the
+// hops. With no legal move at all the result is empty.
+func (e *Engine) CompletePartial(state *GameState, dice [2]int, used []PartialMove, opts EvalOptions) ([]MoveWithEval, error) {
+	sub, err := LegalSubMoves(state.Board, dice, used)
+	if err != nil {
+		return nil, err
+	}
+	if !sub.Completable {
+		return nil, fmt.Errorf("%w: %s", ErrNoCompletion, formatHops(used))
+	}
+
+	// Since the hops so far can be completed, the best the remaining dice
+	// can do from here is exactly what every completion must play
+	pos := state.Board
+	for _, h := range used {
+		applySubMove(&pos, h.From, h.Die)
+	}
+	moves, pips := bestHops(pos, sub.Remaining)
+	results := make(map[positionid.PositionKey]bool)
+	collectCompletions(pos, sub.Remaining, moves, pips, results)
+
+	analysis, err := e.AnalyzePositionWithOptions(state, dice, opts)
+	if err != nil {
+		return nil, err
+	}
+	var ranked []MoveWithEval
+	for _, m := range analysis.Moves {
+		after := ApplyMove(state.Board, m.Move)
+		if results[positionid.MakePositionKey(positionid.Board(after))] {
+			ranked = append(ranked, m)
+		}
+	}
+	return ranked, nil
+}
+
+// playHop plays h on pos, returning the dice still to play.
+func playHop(pos *Board, remaining []int, h PartialMove) ([]int, error) {
+	if !containsDie(remaining, h.Die) {
+		return nil, fmt.Errorf("%w: no %d left to play", ErrIllegalHop, h.Die)
+	}
+	if h.From < 0 || h.From > 24 || !canHop(*pos, h.From, h.Die) {
+		return nil, ErrIllegalHop
+	}
+	if h.To != hopDest(h.From, h.Die) {
+		return nil, fmt.Errorf("%w: a %d from there goes to %s", ErrIllegalHop, h.Die,
+			PartialMove{From: h.From, To: hopDest(h.From, h.Die)})
+	}
+	applySubMove(pos, h.From, h.Die)
+	return withoutDie(remaining, h.Die), nil
+}
+
+// canHop reports whether the player on roll may move a checker from from
+// with die, entering from the bar first.
+func canHop(board Board, from, die int) bool {
+	if board[1][from] == 0 {
+		return false
+	}
+	if board[1][24] > 0 && from != 24 {
+		return false
+	}
+	return legalMove(board, from, die)
+}
+
+// hopDest is where a checker moved from from with die lands, -1 for off.
+func hopDest(from, die int) int {
+	return max(from-die, -1)
+}
+
+// bestHops returns the most checker moves that can be played from board
+// with dice, in any order, and the most pips those moves can use: together
+// they are what a legal full move must play.
+func bestHops(board Board, dice []int) (moves, pips int) {
+	if len(dice) == 0 {
+		return 0, 0
+	}
+	total := 0
+	for _, d := range dice {
+		total += d
+	}
+	for _, die := range distinctDice(dice) {
+		rest := withoutDie(dice, die)
+		for from := 24; from >= 0; from-- {
+			if !canHop(board, from, die) {
+				continue
+			}
+			next := board
+			applySubMove(&next, from, die)
+			m, p := bestHops(next, rest)
+			m, p = m+1, p+die
+			if m > moves || (m == moves && p > pips) {
+				moves, pips = m, p
+			}
+			if moves == len(dice) && pips == total {
+				return moves, pips
+			}
+		}
+	}
+	return moves, pips
+}
+
+// collectCompletions adds to results the positions reached by playing
+// exactly moves checker moves using pips pips from board with dice.
+func collectCompletions(board Board, dice []int, moves, pips int, results map[positionid.PositionKey]bool) {
+	if moves == 0 {
+		if pips == 0 {
+			results[positionid.MakePositionKey(positionid.Board(board))] = true
+		}
+		return
+	}
+	for _, die := range distinctDice(dice) {
+		if die > pips {
+			continue
+		}
+		rest := withoutDie(dice, die)
+		for from := 24; from >= 0; from-- {
+			if !canHop(board, from, die) {
+				continue
+			}
+			next := board
+			applySubMove(&next, from, die)
+			collectCompletions(next, rest, moves-1, pips-die, results)
+		}
+	}
+}
+
+// distinctDice returns the different values in dice, highest first.
+func distinctDice(dice []int) []int {
+	var out []int
+	for _, d := range dice {
+		if !containsDie(out, d) {
+			out = append(out, d)
+		}
+	}
+	if len(out) == 2 && out[0] < out[1] {
+		out[0], out[1] = out[1], out[0]
+	}
+	return out
+}
+
+func containsDie(dice []int, die int) bool {
+	for _, d := range dice {
+		if d == die {
+			return true
+		}
+	}
+	return false
+}
+
+// withoutDie returns a copy of dice with one die removed.
+func withoutDie(dice []int, die int) []int {
+	out := make([]int, 0, len(dice))
+	removed := false
+	for _, d := range dice {
+		if d == die && !removed {
+			removed = true
+			continue
+		}
+		out = append(out, d)
+	}
+	return out
+}
+
+// formatHops returns hops in move notation, e.g. "13/10 10/9".
+func formatHops(hops []PartialMove) string {
+	s := ""
+	for i, h := range hops {
+		if i > 0 {
+			s += " "
+		}
+		s += h.String()
+	}
+	return s
+}
@@ -0,0 +1,352 @@
+package engine
+
+import (
+	"errors"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/yourusername/bgengine/internal/positionid"
+)
+
+// hop is shorthand for a PartialMove in 1-based points: 25 is the bar and
+// 0 is off.
+func hop(from, to, die int) PartialMove {
+	return PartialMove{From: from - 1, To: to - 1, Die: die}
+}
+
+// dancerBoard has the player on roll with 13 checkers on the ace point,
+// which cannot move until the others are home, and the two given checkers
+// (1-based points). The opponent holds the given points (1-based, from the
+// mover's side).
+func dancerBoard(a, b int, blocks ...int) Board {
+	var board Board
+	board[1][0] = 13
+	board[1][a-1]++
+	board[1][b-1]++
+	board[0][0] = 15
+	for _, p := range blocks {
+		board[0][24-p] = 2
+		board[0][0] -= 2
+	}
+	return board
+}
+
+func TestLegalSubMoves(t *testing.T) {
+	start := startingBoard()
+
+	barBoard := startingBoard()
+	barBoard[1][23] = 1
+	barBoard[1][24] = 1
+
+	twoOnBar := startingBoard()
+	twoOnBar[1][23] = 0
+	twoOnBar[1][24] = 2
+
+	// One checker left outside, on the 7-point
+	nearlyHome := dancerBoard(7, 1)
+
+	var bearoff Board
+	bearoff[1][1] = 1 // 2-point
+	bearoff[1][0] = 1 // 1-point
+	bearoff[0][0] = 15
+
+	tests := []struct {
+		name        string
+		board       Board
+		dice        [2]int
+		used        []PartialMove
+		next        []PartialMove
+		rejected    []PartialMove
+		remaining   []int
+		completable bool
+		complete    bool
+	}{
+		{
+			name:  "opening 6-5",
+			board: start, dice: [2]int{6, 5},
+			next:        []PartialMove{hop(24, 18, 6), hop(13, 7, 6), hop(13, 8, 5), hop(8, 2, 6), hop(8, 3, 5)},
+			remaining:   []int{6, 5},
+			completable: true,
+		},
+		{
+			name:  "opening 6-5 after 24/18",
+			board: start, dice: [2]int{6, 5}, used: []PartialMove{hop(24, 18, 6)},
+			next:        []PartialMove{hop(18, 13, 5), hop(13, 8, 5), hop(8, 3, 5)},
+			remaining:   []int{5},
+			completable: true,
+		},
+		{
+			name:  "opening 3-1 complete",
+			board: start, dice: [2]int{3, 1}, used: []PartialMove{hop(8, 5, 3), hop(6, 5, 1)},
+			remaining:   []int{},
+			completable: true, complete: true,
+		},
+		{
+			// 13/7 leaves the 1 unplayable, but 21/15 15/14 plays both dice
+			name:  "must use both dice",
+			board: dancerBoard(21, 13, 6, 20), dice: [2]int{6, 1},
+			next:        []PartialMove{hop(21, 15, 6), hop(13, 12, 1)},
+			rejected:    []PartialMove{hop(13, 7, 6)},
+			remaining:   []int{6, 1},
+			completable: true,
+		},
+		{
+			name:  "must use both dice: dead end",
+			board: dancerBoard(21, 13, 6, 20), dice: [2]int{6, 1}, used: []PartialMove{hop(13, 7, 6)},
+			remaining: []int{1},
+		},
+		{
+			// Only one die can be played, so it must be the larger
+			name:  "must use larger die",
+			board: dancerBoard(13, 1, 2), dice: [2]int{6, 5},
+			next:        []PartialMove{hop(13, 7, 6)},
+			rejected:    []PartialMove{hop(13, 8, 5)},
+			remaining:   []int{6, 5},
+			completable: true,
+		},
+		{
+			name:  "must use larger die: done",
+			board: dancerBoard(13, 1, 2), dice: [2]int{6, 5}, used: []PartialMove{hop(13, 7, 6)},
+			remaining:   []int{5},
+			completable: true, complete: true,
+		},
+		{
+			name:  "must use larger die: smaller played",
+			board: dancerBoard(13, 1, 2), dice: [2]int{6, 5}, used: []PartialMove{hop(13, 8, 5)},
+			remaining: []int{6},
+		},
+		{
+			// The opponent holds the 6-point, so the checker enters with the 1
+			name:  "bar entry",
+			board: barBoard, dice: [2]int{6, 1},
+			next:        []PartialMove{hop(25, 24, 1)},
+			remaining:   []int{6, 1},
+			completable: true,
+		},
+		{
+			name:  "bar entry then free",
+			board: barBoard, dice: [2]int{6, 1}, used: []PartialMove{hop(25, 24, 1)},
+			next:        []PartialMove{hop(24, 18, 6), hop(13, 7, 6), hop(8, 2, 6)},
+			remaining:   []int{6},
+			completable: true,
+		},
+		{
+			// The second checker cannot enter with the 6, which ends the move
+			name:  "second checker stays on the bar",
+			board: twoOnBar, dice: [2]int{6, 1}, used: []PartialMove{hop(25, 24, 1)},
+			remaining:   []int{6},
+			completable: true, complete: true,
+		},
+		{
+			name:  "bear off with the exact number",
+			board: nearlyHome, dice: [2]int{1, 6}, used: []PartialMove{hop(7, 6, 1)},
+			next:        []PartialMove{hop(6, 0, 6)},
+			remaining:   []int{6},
+			completable: true,
+		},
+		{
+			name:  "bear off with a larger die",
+			board: bearoff, dice: [2]int{6, 5},
+			next:        []PartialMove{hop(2, 0, 6), hop(2, 0, 5)},
+			remaining:   []int{6, 5},
+			completable: true,
+		},
+		{
+			name:  "bear off with a larger die, then the other",
+			board: bearoff, dice: [2]int{6, 5}, used: []PartialMove{hop(2, 0, 5)},
+			next:        []PartialMove{hop(1, 0, 6)},
+			remaining:   []int{6},
+			completable: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LegalSubMoves(tt.board, tt.dice, tt.used)
+			if err != nil {
+				t.Fatalf("LegalSubMoves failed: %v", err)
+			}
+			if !reflect.DeepEqual(got.Next, tt.next) {
+				t.Errorf("next %v, want %v", got.Next, tt.next)
+			}
+			if !reflect.DeepEqual(got.Rejected, tt.rejected) {
+				t.Errorf("rejected %v, want %v", got.Rejected, tt.rejected)
+			}
+			if !reflect.DeepEqual(got.Remaining, tt.remaining) && len(got.Remaining)+len(tt.remaining) > 0 {
+				t.Errorf("remaining %v, want %v", got.Remaining, tt.remaining)
+			}
+			if got.Completable != tt.completable || got.Complete != tt.complete {
+				t.Errorf("completable %v complete %v, want %v %v", got.Completable, got.Complete, tt.completable, tt.complete)
+			}
+		})
+	}
+}
+
+func TestLegalSubMovesIllegalHop(t *testing.T) {
+	start := startingBoard()
+	barBoard := startingBoard()
+	barBoard[1][23] = 1
+	barBoard[1][24] = 1
+
+	tests := []struct {
+		name  string
+		board Board
+		dice  [2]int
+		used  []PartialMove
+	}{
+		{"no checker", start, [2]int{3, 1}, []PartialMove{hop(7, 4, 3)}},
+		{"blocked point", start, [2]int{5, 2}, []PartialMove{hop(24, 19, 5)}},
+		{"die not rolled", start, [2]int{3, 1}, []PartialMove{hop(8, 4, 4)}},
+		{"die used twice", start, [2]int{3, 1}, []PartialMove{hop(8, 5, 3), hop(6, 3, 3)}},
+		{"wrong destination", start, [2]int{3, 1}, []PartialMove{hop(8, 4, 3)}},
+		{"checker on the bar", barBoard, [2]int{6, 1}, []PartialMove{hop(13, 7, 6)}},
+		{"blocked entry", barBoard, [2]int{6, 1}, []PartialMove{hop(25, 19, 6)}},
+		{"bear off too early", dancerBoard(7, 1), [2]int{1, 6}, []PartialMove{hop(1, 0, 1)}},
+		{"bear off not from the highest point", dancerBoard(6, 1), [2]int{3, 1}, []PartialMove{hop(1, 0, 3)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := LegalSubMoves(tt.board, tt.dice, tt.used); !errors.Is(err, ErrIllegalHop) {
+				t.Errorf("got %v, want ErrIllegalHop", err)
+			}
+		})
+	}
+
+	if _, err := LegalSubMoves(start, [2]int{0, 7}, nil); err == nil {
+		t.Error("expected an error for invalid dice")
+	}
+}
+
+// TestLegalSubMovesMatchesGenerator walks every path of hops LegalSubMoves
+// offers, in positions from random games, and checks that the complete
+// paths reach exactly the positions GenerateMoves finds, that every offered
+// path can be completed and that every rejected hop cannot.
+func TestLegalSubMovesMatchesGenerator(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	board := startingBoard()
+	positions := 0
+	for ply := 0; positions < 200; ply++ {
+		d0, d1 := rng.Intn(6)+1, rng.Intn(6)+1
+		ml := GenerateMoves(board, d0, d1)
+		if ply%3 == 0 || board[1][24] > 0 {
+			checkSubMoveWalk(t, board, [2]int{d0, d1})
+			positions++
+		}
+		if len(ml.Moves) > 0 {
+			board = ApplyMove(board, ml.Moves[rng.Intn(len(ml.Moves))])
+		}
+		board = swapBoard(board)
+		if pipCount(board[0]) == 0 || pipCount(board[1]) == 0 {
+			board = startingBoard()
+		}
+	}
+}
+
+func pipCount(side [25]uint8) int {
+	n := 0
+	for i, c := range side {
+		n += int(c) * (i + 1)
+	}
+	return n
+}
+
+func checkSubMoveWalk(t *testing.T, board Board, dice [2]int) {
+	t.Helper()
+	want := make(map[positionid.PositionKey]bool)
+	for _, key := range GenerateMoves(board, dice[0], dice[1]).ResultKeys {
+		want[key] = true
+	}
+	got := make(map[positionid.PositionKey]bool)
+
+	var walk func(used []PartialMove)
+	walk = func(used []PartialMove) {
+		sub, err := LegalSubMoves(board, dice, used)
+		if err != nil {
+			t.Fatalf("%v %v after %s: %v", boardToString(board), dice, formatHops(used), err)
+		}
+		if !sub.Completable {
+			t.Fatalf("%v %v: offered %s cannot be completed", boardToString(board), dice, formatHops(used))
+		}
+		if sub.Complete {
+			after := board
+			for _, h := range used {
+				applySubMove(&after, h.From, h.Die)
+			}
+			got[positionid.MakePositionKey(positionid.Board(after))] = true
+			return
+		}
+		for _, h := range sub.Rejected {
+			rejected, err := LegalSubMoves(board, dice, append(append([]PartialMove(nil), used...), h))
+			if err != nil || rejected.Completable {
+				t.Errorf("%v %v: rejected %s after %s can be completed (%v)", boardToString(board), dice, h, formatHops(used), err)
+			}
+		}
+		for _, h := range sub.Next {
+			walk(append(append([]PartialMove(nil), used...), h))
+		}
+	}
+	walk(nil)
+
+	if len(want) == 0 {
+		want[positionid.MakePositionKey(positionid.Board(board))] = true
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("%v %v: hops reach %d positions, generator %d", boardToString(board), dice, len(got), len(want))
+	}
+}
+
+func TestCompletePartial(t *testing.T) {
+	e, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	state := StartingPosition()
+	dice := [2]int{3, 1}
+
+	all, err := e.CompletePartial(state, dice, nil, EvalOptions{})
+	if err != nil {
+		t.Fatalf("CompletePartial failed: %v", err)
+	}
+	analysis, _ := e.AnalyzePosition(state, dice)
+	if len(all) != analysis.NumMoves {
+		t.Errorf("%d completions of no hops, want all %d legal moves", len(all), analysis.NumMoves)
+	}
+
+	// After 24/23 a completion may play 24/20, whichever order the generator
+	// found it in, but not 8/5 6/5
+	got, err := e.CompletePartial(state, dice, []PartialMove{hop(24, 23, 1)}, EvalOptions{})
+	if err != nil {
+		t.Fatalf("CompletePartial failed: %v", err)
+	}
+	reaches := func(notation string) bool {
+		m, _ := ParseMove(notation)
+		target := ApplyMove(state.Board, m)
+		for _, c := range got {
+			if EqualBoards(ApplyMove(state.Board, c.Move), target) {
+				return true
+			}
+		}
+		return false
+	}
+	if !reaches("24/20") || !reaches("24/23 8/5") || reaches("8/5 6/5") {
+		t.Errorf("completions of 24/23: %v", got)
+	}
+	// 24/23 then a 3 from the 24, 23, 13, 8 or 6-point
+	if len(got) != 5 {
+		t.Errorf("%d completions of 24/23, want 5", len(got))
+	}
+	for i := 1; i < len(got); i++ {
+		if got[i].Equity > got[i-1].Equity {
+			t.Errorf("completions not ranked: %.4f after %.4f", got[i].Equity, got[i-1].Equity)
+		}
+	}
+
+	// A playable hop that cannot be completed
+	dead := &GameState{Board: dancerBoard(13, 1, 2), CubeValue: 1, CubeOwner: -1}
+	if _, err := e.CompletePartial(dead, [2]int{6, 5}, []PartialMove{hop(13, 8, 5)}, EvalOptions{}); !errors.Is(err, ErrNoCompletion) {
+		t.Errorf("got %v, want ErrNoCompletion", err)
+	}
+	if _, err := e.CompletePartial(state, dice, []PartialMove{hop(7, 4, 3)}, EvalOptions{}); !errors.Is(err, ErrIllegalHop) {
+		t.Errorf("got %v, want ErrIllegalHop", err)
+	}
+}
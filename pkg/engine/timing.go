@@ -0,0 +1,54 @@
+package engine
+
+import "time"
+
+// Timing breaks down where the time of an evaluation went. Pass one in
+// EvalOptions.Timing to have the engine add to it; with none the engine reads
+// no clocks. A Timing is not safe for concurrent use, so give each call its
+// own.
+//
+// Total is the wall time of the call. The components are spent inside it;
+// what is left (applying moves, classifying positions, sorting) is Other.
+type Timing struct {
+	Total time.Duration
+
+	MoveGen   time.Duration // Generating legal moves
+	Inputs    time.Duration // Encoding positions as net inputs
+	Contact   time.Duration // Contact net forward passes
+	Crashed   time.Duration // Crashed net forward passes
+	Race      time.Duration // Race net forward passes
+	Prune     time.Duration // Pruning net forward passes
+	Heuristic time.Duration // Pip count formulas standing in for a net that is not loaded
+	Bearoff   time.Duration // Bearoff database lookups and exact bearoff solves
+	Cache     time.Duration // Evaluation cache lookups and stores
+
+	Evaluations int // Positions evaluated statically
+	BookHits    int // Positions answered from the book (see BuildBook)
+}
+
+// Components returns the sum of the components of t.
+func (t *Timing) Components() time.Duration {
+	return t.MoveGen + t.Inputs + t.Contact + t.Crashed + t.Race + t.Prune +
+		t.Heuristic + t.Bearoff + t.Cache
+}
+
+// Other returns the part of Total not in a component.
+func (t *Timing) Other() time.Duration {
+	return max(0, t.Total-t.Components())
+}
+
+// start returns the time now, or the zero time if t is nil, so that a call
+// without a Timing reads no clock.
+func (t *Timing) start() time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+// total adds the time since start to Total.
+func (t *Timing) total(start time.Time) {
+	if t != nil {
+		t.Total += time.Since(start)
+	}
+}
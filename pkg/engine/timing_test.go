@@ -0,0 +1,114 @@
+package engine
+
+import (
+	"testing"
+)
+
+func TestTimingComponents(t *testing.T) {
+	state := StartingPosition()
+	dice := [2]int{3, 1}
+
+	for plies := 0; plies <= 1; plies++ {
+		// A fresh engine each time, so the evaluations are not cache hits
+		e, err := NewEngine(EngineOptions{})
+		if err != nil {
+			t.Fatalf("NewEngine failed: %v", err)
+		}
+		var tm Timing
+		got, err := e.AnalyzePositionWithOptions(state, dice, EvalOptions{Plies: plies, UsePrune: true, Timing: &tm})
+		if err != nil {
+			t.Fatalf("%d ply with timing: %v", plies, err)
+		}
+		want, err := e.AnalyzePositionWithOptions(state, dice, EvalOptions{Plies: plies, UsePrune: true})
+		if err != nil {
+			t.Fatalf("%d ply: %v", plies, err)
+		}
+		if got.BestMove != want.BestMove || got.BestEquity != want.BestEquity {
+			t.Errorf("%d ply: timing changed the best move: %v %.4f, want %v %.4f",
+				plies, got.BestMove, got.BestEquity, want.BestMove, want.BestEquity)
+		}
+
+		if tm.Total <= 0 || tm.MoveGen <= 0 || tm.Heuristic <= 0 {
+			t.Errorf("%d ply: missing times: %+v", plies, tm)
+		}
+		if tm.Evaluations < want.NumMoves {
+			t.Errorf("%d ply: %d evaluations for %d moves", plies, tm.Evaluations, want.NumMoves)
+		}
+		if tm.Components() > tm.Total {
+			t.Errorf("%d ply: components %v exceed the total %v", plies, tm.Components(), tm.Total)
+		}
+		// The components are most of the work: at lookahead, the time left
+		// over for applying moves and classifying positions is not the bulk
+		if plies > 0 && tm.Components() < tm.Total/3 {
+			t.Errorf("%d ply: components %v are a small part of the total %v: %+v",
+				plies, tm.Components(), tm.Total, tm)
+		}
+		t.Logf("%d ply: total %v, components %v, other %v, %d evaluations",
+			plies, tm.Total, tm.Components(), tm.Other(), tm.Evaluations)
+	}
+}
+
+func TestTimingNotNested(t *testing.T) {
+	e, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	var tm Timing
+	opts := EvalOptions{Plies: 1, UsePrune: true, Timing: &tm}
+	if _, err := e.AnalyzeCubeWithOptions(StartingPosition(), opts); err != nil {
+		t.Fatalf("AnalyzeCubeWithOptions failed: %v", err)
+	}
+	first := tm.Total
+	if first <= 0 {
+		t.Fatal("no total")
+	}
+	if _, err := e.RankMovesWithOptions(StartingPosition(), [2]int{6, 5}, 3, opts); err != nil {
+		t.Fatalf("RankMovesWithOptions failed: %v", err)
+	}
+	if tm.Components() > tm.Total {
+		t.Errorf("components %v exceed the total %v: a call was counted twice", tm.Components(), tm.Total)
+	}
+}
+
+func TestTimingDisabledNoAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("the race detector allocates")
+	}
+	e, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	state := StartingPosition()
+	dice := [2]int{6, 4}
+	opts := EvalOptions{UsePrune: true}
+	if _, _, err := e.BestMove(state, dice, opts); err != nil {
+		t.Fatalf("BestMove failed: %v", err)
+	}
+
+	// Reading the clocks and adding up the times allocates nothing, so
+	// timing adds no allocations whether it is enabled or not
+	disabled := testing.AllocsPerRun(100, func() { e.BestMove(state, dice, opts) })
+	opts.Timing = &Timing{}
+	enabled := testing.AllocsPerRun(100, func() { e.BestMove(state, dice, opts) })
+	if enabled != disabled {
+		t.Errorf("BestMove with timing allocates %.1f times, without %.1f", enabled, disabled)
+	}
+}
+
+func TestRolloutThroughput(t *testing.T) {
+	e, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	result, err := e.Rollout(StartingPosition(), RolloutOptions{Trials: 36, Seed: 7, Truncate: 10})
+	if err != nil {
+		t.Fatalf("Rollout failed: %v", err)
+	}
+	if result.Elapsed <= 0 || result.TrialsPerSecond <= 0 {
+		t.Errorf("no throughput: elapsed %v, %.1f trials/s", result.Elapsed, result.TrialsPerSecond)
+	}
+	// Truncated at 10 plies, no game is over sooner
+	if result.AvgPlies != 10 {
+		t.Errorf("average plies %.2f, want 10", result.AvgPlies)
+	}
+}
@@ -2,6 +2,7 @@
 package engine
 
 import (
+	"errors"
 	"fmt"
 )
 
@@ -41,23 +42,39 @@ func (l LuckType) String() string {
 	return [...]string{"Very Unlucky", "Unlucky", "None", "Lucky", "Very Lucky"}[l]
 }
 
-// SkillThresholds are the equity loss thresholds for skill ratings.
-// These match gnubg's default values.
-var SkillThresholds = [4]float64{
-	0.12, // SKILL_VERYBAD - blunder
-	0.06, // SKILL_BAD - error
-	0.03, // SKILL_DOUBTFUL - questionable
-	0.00, // SKILL_NONE - good
+// AnalysisConfig holds the thresholds used to rate moves, cube decisions and
+// rolls. Skill thresholds are equity losses, luck thresholds equity swings;
+// each is the smallest value given that rating.
+type AnalysisConfig struct {
+	DoubtfulThreshold  float64 `json:"doubtful_threshold"`   // Doubtful (?!)
+	BadThreshold       float64 `json:"bad_threshold"`        // Bad (?)
+	BlunderThreshold   float64 `json:"blunder_threshold"`    // Very bad (??)
+	LuckyThreshold     float64 `json:"lucky_threshold"`      // Lucky or unlucky
+	VeryLuckyThreshold float64 `json:"very_lucky_threshold"` // Very lucky or very unlucky
 }
 
-// LuckThresholds are the equity swing thresholds for luck ratings.
-// These match gnubg's default values.
-var LuckThresholds = [5]float64{
-	0.6, // LUCK_VERYBAD
-	0.3, // LUCK_BAD
-	0.0, // LUCK_NONE
-	0.3, // LUCK_GOOD
-	0.6, // LUCK_VERYGOOD
+// DefaultAnalysisConfig returns gnubg's default thresholds.
+func DefaultAnalysisConfig() AnalysisConfig {
+	return AnalysisConfig{
+		DoubtfulThreshold:  0.03,
+		BadThreshold:       0.06,
+		BlunderThreshold:   0.12,
+		LuckyThreshold:     0.3,
+		VeryLuckyThreshold: 0.6,
+	}
+}
+
+// Validate checks that the thresholds are positive and in order.
+func (c AnalysisConfig) Validate() error {
+	if !(c.DoubtfulThreshold > 0 && c.DoubtfulThreshold < c.BadThreshold && c.BadThreshold < c.BlunderThreshold) {
+		return fmt.Errorf("skill thresholds must satisfy 0 < doubtful < bad < blunder, got %g, %g, %g",
+			c.DoubtfulThreshold, c.BadThreshold, c.BlunderThreshold)
+	}
+	if !(c.LuckyThreshold > 0 && c.LuckyThreshold < c.VeryLuckyThreshold) {
+		return fmt.Errorf("luck thresholds must satisfy 0 < lucky < very lucky, got %g, %g",
+			c.LuckyThreshold, c.VeryLuckyThreshold)
+	}
+	return nil
 }
 
 // RatingThresholds are error-per-move thresholds for overall player ratings.
@@ -100,12 +117,12 @@ func (r RatingType) String() string {
 
 // ClassifySkill returns the skill rating based on equity loss.
 // equityLoss should be positive for moves worse than best.
-func ClassifySkill(equityLoss float64) SkillType {
-	if equityLoss >= SkillThresholds[0] {
+func (c AnalysisConfig) ClassifySkill(equityLoss float64) SkillType {
+	if equityLoss >= c.BlunderThreshold {
 		return SkillVeryBad
-	} else if equityLoss >= SkillThresholds[1] {
+	} else if equityLoss >= c.BadThreshold {
 		return SkillBad
-	} else if equityLoss >= SkillThresholds[2] {
+	} else if equityLoss >= c.DoubtfulThreshold {
 		return SkillDoubtful
 	}
 	return SkillNone
@@ -113,19 +130,44 @@ func ClassifySkill(equityLoss float64) SkillType {
 
 // ClassifyLuck returns the luck rating based on equity swing.
 // Positive values = good luck, negative = bad luck.
-func ClassifyLuck(equitySwing float64) LuckType {
-	if equitySwing > LuckThresholds[4] {
+func (c AnalysisConfig) ClassifyLuck(equitySwing float64) LuckType {
+	if equitySwing > c.VeryLuckyThreshold {
 		return LuckVeryGood
-	} else if equitySwing > LuckThresholds[3] {
+	} else if equitySwing > c.LuckyThreshold {
 		return LuckGood
-	} else if equitySwing < -LuckThresholds[0] {
+	} else if equitySwing < -c.VeryLuckyThreshold {
 		return LuckVeryBad
-	} else if equitySwing < -LuckThresholds[1] {
+	} else if equitySwing < -c.LuckyThreshold {
 		return LuckBad
 	}
 	return LuckNone
 }
 
+// Luck returns how much rolling dice gained the player on roll: their
+// cubeless equity after playing it best at 0 plies less the average over the
+// 36 rolls, as gnubg measures the luck of a roll.
+func (e *Engine) Luck(state *GameState, dice [2]int) (float64, error) {
+	var equity, sum float64
+	for d1 := 1; d1 <= 6; d1++ {
+		for d2 := 1; d2 <= d1; d2++ {
+			weight := 2.0
+			if d1 == d2 {
+				weight = 1.0
+			}
+
+			eq, err := e.rollEquity(state, d1, d2)
+			if err != nil {
+				return 0, err
+			}
+			sum += weight * eq
+			if (dice[0] == d1 && dice[1] == d2) || (dice[0] == d2 && dice[1] == d1) {
+				equity = eq
+			}
+		}
+	}
+	return equity - sum/36, nil
+}
+
 // GetRating returns the player rating based on error per move.
 // Lower EPM = better rating. Thresholds are upper bounds for each rating.
 func GetRating(errorPerMove float64) RatingType {
@@ -153,12 +195,14 @@ func GetRating(errorPerMove float64) RatingType {
 type MoveSkillAnalysis struct {
 	Move       Move           // The move that was played
 	BestMove   Move           // The best move according to analysis
-	Equity     float64        // Equity of the played move
-	BestEquity float64        // Equity of the best move
+	Equity     float64        // Cubeful equity of the played move
+	BestEquity float64        // Cubeful equity of the best move
 	EquityLoss float64        // Best equity - played equity (positive = error)
 	Skill      SkillType      // Skill rating
 	IsForced   bool           // True if only one legal move
+	Spread     float64        // Equity spread of the top candidates (see DecisionSpread)
 	TopMoves   []MoveWithEval // Top N moves for context
+	Played     *MoveWithEval  // The played move as it was evaluated (nil if forced)
 }
 
 // CubeSkillAnalysis contains the detailed analysis of a cube decision for tutoring.
@@ -169,13 +213,35 @@ type CubeSkillAnalysis struct {
 	EquityLoss  float64       // Cost of the error (if any)
 	Skill       SkillType     // Skill rating
 	IsClose     bool          // True if the decision was close
+	Race        *RaceCube     // Where the race stands against the double and take points (races only)
+	Entry       []EntryStats  // Bar entry of each side with checkers on the bar
 }
 
 // AnalyzeMoveSkill evaluates a played move and returns skill analysis.
-// playedMove is the move the player made, dice is the roll.
+// playedMove is the move the player made, dice is the roll. A played move
+// that is not a legal play of dice is reported with the error of
+// ValidateMove.
 func (e *Engine) AnalyzeMoveSkill(state *GameState, playedMove Move, dice [2]int) (*MoveSkillAnalysis, error) {
-	// Use AnalyzePosition which generates and evaluates all moves
-	analysisResult, err := e.AnalyzePosition(state, dice)
+	return e.AnalyzeMoveSkillWithConfig(state, playedMove, dice, DefaultAnalysisConfig())
+}
+
+// AnalyzeMoveSkillWithConfig is AnalyzeMoveSkill rating the move with the
+// thresholds in cfg.
+func (e *Engine) AnalyzeMoveSkillWithConfig(state *GameState, playedMove Move, dice [2]int, cfg AnalysisConfig) (*MoveSkillAnalysis, error) {
+	return e.analyzeMoveSkill(state, playedMove, dice, cfg, 0)
+}
+
+// analyzeMoveSkill rates a played move against the moves ranked at plies by
+// cubeful equity, so that errors are measured as they cost at the score.
+func (e *Engine) analyzeMoveSkill(state *GameState, playedMove Move, dice [2]int, cfg AnalysisConfig, plies int) (*MoveSkillAnalysis, error) {
+	if err := ValidateMove(state.Board, dice, playedMove); err != nil {
+		return nil, err
+	}
+
+	opts := DefaultEvalOptions()
+	opts.Plies = plies
+	opts.Cubeful = true
+	analysisResult, err := e.AnalyzePositionWithOptions(state, dice, opts)
 	if err != nil {
 		return nil, fmt.Errorf("analyzing position: %w", err)
 	}
@@ -208,6 +274,12 @@ func (e *Engine) AnalyzeMoveSkill(state *GameState, playedMove Move, dice [2]int
 		maxTop = len(analysisResult.Moves)
 	}
 	analysis.TopMoves = analysisResult.Moves[:maxTop]
+	equities := make([]float64, len(analysis.TopMoves))
+	for i := range analysis.TopMoves {
+		analysis.TopMoves[i].PositionID = ResultingPositionID(state.Board, analysis.TopMoves[i].Move)
+		equities[i] = analysis.TopMoves[i].Equity
+	}
+	analysis.Spread = DecisionSpread(equities)
 
 	// Find the played move by comparing resulting positions
 	playedResult := ApplyMove(state.Board, playedMove)
@@ -216,7 +288,10 @@ func (e *Engine) AnalyzeMoveSkill(state *GameState, playedMove Move, dice [2]int
 	for _, m := range analysisResult.Moves {
 		resultBoard := ApplyMove(state.Board, m.Move)
 		if EqualBoards(resultBoard, playedResult) {
+			// Report the canonical notation for the played position
+			analysis.Move = m.Move
 			analysis.Equity = m.Equity
+			analysis.Played = &m
 			foundMove = true
 			break
 		}
@@ -230,14 +305,71 @@ func (e *Engine) AnalyzeMoveSkill(state *GameState, playedMove Move, dice [2]int
 
 	// Calculate equity loss
 	analysis.EquityLoss = analysis.BestEquity - analysis.Equity
-	analysis.Skill = ClassifySkill(analysis.EquityLoss)
+	analysis.Skill = cfg.ClassifySkill(analysis.EquityLoss)
 
 	return analysis, nil
 }
 
+// IllegalMoveError is returned when a resulting position cannot be reached
+// from the starting position with the given dice.
+type IllegalMoveError struct {
+	LegalResults []Board // Every position reachable with the dice (mover still in Board[1])
+}
+
+func (e *IllegalMoveError) Error() string {
+	return fmt.Sprintf("resulting position is not reachable with the given dice (%d legal positions)", len(e.LegalResults))
+}
+
+// FindMoveForResult finds the legal move that turns board into result with the given dice.
+// result has the opponent on roll (in result[1]), as ResultingBoard gives it and as a
+// gnubg position ID after the move has it.
+// Moves that reach the same position are equivalent; the canonical one from
+// GenerateMoves is returned. Returns *IllegalMoveError if no move reaches result.
+func FindMoveForResult(board Board, result Board, dice [2]int) (Move, error) {
+	ml := GenerateMoves(board, dice[0], dice[1])
+	after := swapBoard(result)
+
+	if len(ml.Moves) == 0 {
+		// Dancing leaves the board unchanged
+		if EqualBoards(board, after) {
+			return noMove, nil
+		}
+		return noMove, &IllegalMoveError{LegalResults: []Board{board}}
+	}
+
+	legal := make([]Board, len(ml.Moves))
+	for i, m := range ml.Moves {
+		legal[i] = ApplyMove(board, m)
+		if EqualBoards(legal[i], after) {
+			return m, nil
+		}
+	}
+
+	return noMove, &IllegalMoveError{LegalResults: legal}
+}
+
+// AnalyzeMoveSkillFromResult evaluates a played move given the position after the move
+// instead of its notation. result has the opponent on roll, as in FindMoveForResult.
+func (e *Engine) AnalyzeMoveSkillFromResult(state *GameState, result Board, dice [2]int) (*MoveSkillAnalysis, error) {
+	playedMove, err := FindMoveForResult(state.Board, result, dice)
+	if err != nil {
+		return nil, err
+	}
+	return e.AnalyzeMoveSkill(state, playedMove, dice)
+}
+
 // AnalyzeCubeSkill evaluates a cube decision and returns skill analysis.
-// actualAction is what the player did (Double, Take, Pass, NoDouble).
+// actualAction is what the player did (Double, Take, Pass, Beaver, Raccoon, NoDouble).
+// In a position too good to double OptimalPlay is TooGood, which NoDouble plays.
+// Responses (Take, Pass, Beaver) and Raccoon are analyzed from the doubler's position,
+// i.e. state.Turn is the player who doubled and the cube is as it was before the double.
 func (e *Engine) AnalyzeCubeSkill(state *GameState, actualAction CubeAction) (*CubeSkillAnalysis, error) {
+	return e.AnalyzeCubeSkillWithConfig(state, actualAction, DefaultAnalysisConfig())
+}
+
+// AnalyzeCubeSkillWithConfig is AnalyzeCubeSkill rating the decision with the
+// thresholds in cfg.
+func (e *Engine) AnalyzeCubeSkillWithConfig(state *GameState, actualAction CubeAction, cfg AnalysisConfig) (*CubeSkillAnalysis, error) {
 	cubeAnalysis, err := e.AnalyzeCube(state)
 	if err != nil {
 		return nil, fmt.Errorf("analyzing cube: %w", err)
@@ -247,6 +379,10 @@ func (e *Engine) AnalyzeCubeSkill(state *GameState, actualAction CubeAction) (*C
 		Analysis:   cubeAnalysis,
 		ActualPlay: actualAction,
 	}
+	if analysis.Race, err = e.RaceCubeAnalysis(state); err != nil && !errors.Is(err, ErrNotRace) {
+		return nil, fmt.Errorf("analyzing race: %w", err)
+	}
+	analysis.Entry = e.EntryStats(state)
 
 	// Determine optimal play from the decision
 	analysis.OptimalPlay = cubeAnalysis.Decision.Action
@@ -254,42 +390,80 @@ func (e *Engine) AnalyzeCubeSkill(state *GameState, actualAction CubeAction) (*C
 	// Check if this is a close decision
 	analysis.IsClose = isCloseCubeDecisionAnalysis(cubeAnalysis)
 
-	// Calculate equity loss based on what happened
+	// Calculate equity loss based on what happened. A double is worth the
+	// opponent's better response to it, so doubling when too good to
+	// double only cashes the game
 	switch actualAction {
 	case NoDouble:
 		if cubeAnalysis.Decision.Action == Double {
 			// Missed double
-			analysis.EquityLoss = cubeAnalysis.DoubleTakeEq - cubeAnalysis.NoDoubleEquity
-			if analysis.EquityLoss < 0 {
-				// If opponent would pass
-				analysis.EquityLoss = cubeAnalysis.DoublePassEq - cubeAnalysis.NoDoubleEquity
-			}
+			analysis.EquityLoss = doubleEquity(cubeAnalysis) - cubeAnalysis.NoDoubleEquity
 		}
 	case Double:
-		if cubeAnalysis.Decision.Action == NoDouble {
-			// Wrong double
-			analysis.EquityLoss = cubeAnalysis.NoDoubleEquity - cubeAnalysis.DoubleTakeEq
+		if a := cubeAnalysis.Decision.Action; a == NoDouble || a == TooGood {
+			// Wrong double, or a cash when playing on for the gammon was right
+			analysis.EquityLoss = cubeAnalysis.NoDoubleEquity - doubleEquity(cubeAnalysis)
 		}
-	case Take:
-		if cubeAnalysis.DecisionType == DOUBLE_PASS || cubeAnalysis.DecisionType == REDOUBLE_PASS {
-			// Wrong take (should have passed)
-			analysis.EquityLoss = 1.0 + cubeAnalysis.DoubleTakeEq // Lost 1 instead of equity from taking
+	case Take, Pass, Beaver:
+		if cubeAnalysis.DecisionType.available() {
+			analysis.OptimalPlay, analysis.EquityLoss = cubeResponseLoss(cubeAnalysis, actualAction, state.MatchLength == 0)
 		}
-	case Pass:
-		if cubeAnalysis.DecisionType == DOUBLE_TAKE || cubeAnalysis.DecisionType == REDOUBLE_TAKE {
-			// Wrong pass (should have taken)
-			analysis.EquityLoss = 1.0 + cubeAnalysis.DoubleTakeEq // Gave up take equity for -1
+	case Raccoon:
+		if cubeAnalysis.DecisionType.available() && state.MatchLength == 0 {
+			// The doubler's choice after a beaver: raccoon or play on at 4x
+			analysis.OptimalPlay = Raccoon
+			if cubeAnalysis.BeaverEquity > cubeAnalysis.RaccoonEquity {
+				analysis.OptimalPlay = NoDouble
+			}
+			analysis.EquityLoss = cubeAnalysis.BeaverEquity - cubeAnalysis.RaccoonEquity
 		}
 	}
 
 	if analysis.EquityLoss < 0 {
 		analysis.EquityLoss = 0
 	}
-	analysis.Skill = ClassifySkill(analysis.EquityLoss)
+	analysis.Skill = cfg.ClassifySkill(analysis.EquityLoss)
 
 	return analysis, nil
 }
 
+// doubleEquity returns the doubler's equity of a double in ca: that of the
+// take or the pass, whichever the opponent does better with.
+func doubleEquity(ca *CubeAnalysis) float64 {
+	return min(ca.DoubleTakeEq, ca.DoublePassEq)
+}
+
+// cubeResponseLoss scores the opponent's response to a double. All equities in
+// CubeAnalysis are from the doubler's side, so the responder's equity is their negation.
+// Beavers are only considered in money games; in match play a beaver is scored as a take.
+// Returns the best response and the equity lost by the actual one.
+func cubeResponseLoss(ca *CubeAnalysis, actual CubeAction, beavers bool) (CubeAction, float64) {
+	take := -ca.DoubleTakeEq
+	pass := -ca.DoublePassEq
+
+	best, bestEq := Take, take
+	if pass > bestEq {
+		best, bestEq = Pass, pass
+	}
+	beaver := take
+	if beavers {
+		beaver = -ca.BeaverEquity
+		if beaver > bestEq {
+			best, bestEq = Beaver, beaver
+		}
+	}
+
+	played := take
+	switch actual {
+	case Pass:
+		played = pass
+	case Beaver:
+		played = beaver
+	}
+
+	return best, bestEq - played
+}
+
 // isCloseCubeDecisionAnalysis returns true if the cube decision is close.
 // A decision is close if the difference between doubling and not doubling
 // is less than 0.16 equity.
@@ -1,7 +1,13 @@
 package engine
 
 import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
 	"testing"
+
+	"github.com/yourusername/bgengine/internal/neuralnet"
 )
 
 func TestClassifySkill(t *testing.T) {
@@ -20,7 +26,7 @@ func TestClassifySkill(t *testing.T) {
 	}
 
 	for _, tc := range tests {
-		got := ClassifySkill(tc.loss)
+		got := DefaultAnalysisConfig().ClassifySkill(tc.loss)
 		if got != tc.want {
 			t.Errorf("ClassifySkill(%f) = %v, want %v", tc.loss, got, tc.want)
 		}
@@ -44,7 +50,7 @@ func TestClassifyLuck(t *testing.T) {
 	}
 
 	for _, tc := range tests {
-		got := ClassifyLuck(tc.swing)
+		got := DefaultAnalysisConfig().ClassifyLuck(tc.swing)
 		if got != tc.want {
 			t.Errorf("ClassifyLuck(%f) = %v, want %v", tc.swing, got, tc.want)
 		}
@@ -117,7 +123,7 @@ func TestAnalyzeMoveSkill(t *testing.T) {
 	dice := [2]int{3, 1}
 
 	// Get the best move
-	bestMove, _, err := engine.BestMove(state, dice)
+	bestMove, _, err := engine.BestMove(state, dice, DefaultEvalOptions())
 	if err != nil {
 		t.Fatalf("BestMove failed: %v", err)
 	}
@@ -161,3 +167,359 @@ func TestAnalyzeMoveSkill(t *testing.T) {
 		t.Errorf("Bad move should have positive equity loss, got %f", badAnalysis.EquityLoss)
 	}
 }
+
+func TestFindMoveForResult(t *testing.T) {
+	state := StartingPosition()
+	dice := [2]int{3, 1}
+
+	// 6/5 8/5 and 8/5 6/5 reach the same board and are equivalent
+	played := Move{
+		From: [4]int8{5, 7, -1, -1},
+		To:   [4]int8{4, 4, -1, -1},
+	}
+	result := ResultingBoard(state.Board, played)
+
+	m, err := FindMoveForResult(state.Board, result, dice)
+	if err != nil {
+		t.Fatalf("FindMoveForResult failed: %v", err)
+	}
+	if !EqualBoards(ResultingBoard(state.Board, m), result) {
+		t.Errorf("Found move From=%v To=%v does not reach the played position", m.From, m.To)
+	}
+
+	// The board with the mover still on roll is not the resulting position
+	if _, err := FindMoveForResult(state.Board, ApplyMove(state.Board, played), dice); err == nil {
+		t.Error("FindMoveForResult accepted the result with the mover on roll")
+	}
+
+	// Unreachable position reports every legal result
+	_, err = FindMoveForResult(state.Board, state.Board, dice)
+	illegal, ok := err.(*IllegalMoveError)
+	if !ok {
+		t.Fatalf("Expected *IllegalMoveError, got %v", err)
+	}
+	ml := GenerateMoves(state.Board, dice[0], dice[1])
+	if len(illegal.LegalResults) != len(ml.Moves) {
+		t.Errorf("LegalResults = %d, want %d", len(illegal.LegalResults), len(ml.Moves))
+	}
+}
+
+func TestAnalyzeMoveSkillFromResult(t *testing.T) {
+	engine, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+
+	state := StartingPosition()
+	dice := [2]int{3, 1}
+	played := Move{
+		From: [4]int8{7, 5, -1, -1},
+		To:   [4]int8{4, 4, -1, -1},
+	}
+
+	byMove, err := engine.AnalyzeMoveSkill(state, played, dice)
+	if err != nil {
+		t.Fatalf("AnalyzeMoveSkill failed: %v", err)
+	}
+	byResult, err := engine.AnalyzeMoveSkillFromResult(state, ResultingBoard(state.Board, played), dice)
+	if err != nil {
+		t.Fatalf("AnalyzeMoveSkillFromResult failed: %v", err)
+	}
+
+	if byMove.Move != byResult.Move {
+		t.Errorf("Canonical moves differ: %v vs %v", byMove.Move, byResult.Move)
+	}
+	if byMove.Equity != byResult.Equity {
+		t.Errorf("Equities differ: %f vs %f", byMove.Equity, byResult.Equity)
+	}
+}
+
+func TestAnalyzeCubeSkillBeaver(t *testing.T) {
+	engine, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+
+	// Without nets the starting position evaluates as even: the double is
+	// wrong, so beavering is correct and a take gives up equity
+	even := StartingPosition()
+
+	beaver, err := engine.AnalyzeCubeSkill(even, Beaver)
+	if err != nil {
+		t.Fatalf("AnalyzeCubeSkill failed: %v", err)
+	}
+	ca := beaver.Analysis
+	if ca.DoubleTakeEq >= 0 || ca.BeaverEquity >= ca.DoubleTakeEq {
+		t.Fatalf("expected BeaverEquity < DoubleTakeEq < 0, got %.4f and %.4f", ca.BeaverEquity, ca.DoubleTakeEq)
+	}
+	if beaver.OptimalPlay != Beaver || beaver.EquityLoss != 0 {
+		t.Errorf("correct beaver: optimal %v loss %.4f, want beaver with no loss", beaver.OptimalPlay, beaver.EquityLoss)
+	}
+
+	take, err := engine.AnalyzeCubeSkill(even, Take)
+	if err != nil {
+		t.Fatalf("AnalyzeCubeSkill failed: %v", err)
+	}
+	wantLoss := ca.DoubleTakeEq - ca.BeaverEquity
+	if take.OptimalPlay != Beaver || math.Abs(take.EquityLoss-wantLoss) > 1e-9 {
+		t.Errorf("missed beaver: optimal %v loss %.4f, want beaver and %.4f", take.OptimalPlay, take.EquityLoss, wantLoss)
+	}
+
+	// Raccooning a correct beaver compounds the doubler's error
+	raccoon, err := engine.AnalyzeCubeSkill(even, Raccoon)
+	if err != nil {
+		t.Fatalf("AnalyzeCubeSkill failed: %v", err)
+	}
+	if raccoon.OptimalPlay != NoDouble || raccoon.EquityLoss <= 0 {
+		t.Errorf("wrong raccoon: optimal %v loss %.4f, want no_double with a loss", raccoon.OptimalPlay, raccoon.EquityLoss)
+	}
+}
+
+func TestAnalyzeCubeSkillWrongBeaver(t *testing.T) {
+	engine, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+
+	// Last roll with 27 winning rolls for the doubler: a take, not a beaver
+	state := &GameState{CubeValue: 1, CubeOwner: -1}
+	state.Board[1][5] = 1
+	state.Board[0][0] = 2
+
+	analysis, err := engine.AnalyzeCubeSkill(state, Beaver)
+	if err != nil {
+		t.Fatalf("AnalyzeCubeSkill failed: %v", err)
+	}
+	ca := analysis.Analysis
+	if ca.DoubleTakeEq <= 0 || ca.DoubleTakeEq >= ca.DoublePassEq {
+		t.Fatalf("expected a take, got DoubleTakeEq %.4f", ca.DoubleTakeEq)
+	}
+	if analysis.OptimalPlay != Take {
+		t.Errorf("OptimalPlay = %v, want take", analysis.OptimalPlay)
+	}
+	// Scored against the take
+	wantLoss := ca.BeaverEquity - ca.DoubleTakeEq
+	if math.Abs(analysis.EquityLoss-wantLoss) > 1e-9 || analysis.Skill != SkillVeryBad {
+		t.Errorf("EquityLoss = %.4f (%v), want %.4f very bad", analysis.EquityLoss, analysis.Skill, wantLoss)
+	}
+
+	// The doubler is right to raccoon a wrong beaver
+	raccoon, err := engine.AnalyzeCubeSkill(state, Raccoon)
+	if err != nil {
+		t.Fatalf("AnalyzeCubeSkill failed: %v", err)
+	}
+	if raccoon.OptimalPlay != Raccoon || raccoon.EquityLoss != 0 {
+		t.Errorf("raccoon: optimal %v loss %.4f, want raccoon with no loss", raccoon.OptimalPlay, raccoon.EquityLoss)
+	}
+
+	// Beavers are not allowed in match play and score as a take
+	state.MatchLength = 5
+	match, err := engine.AnalyzeCubeSkill(state, Beaver)
+	if err != nil {
+		t.Fatalf("AnalyzeCubeSkill failed: %v", err)
+	}
+	if match.OptimalPlay == Beaver || match.Analysis.BeaverEquity != 0 {
+		t.Errorf("match play: optimal %v BeaverEquity %.4f, want no beaver", match.OptimalPlay, match.Analysis.BeaverEquity)
+	}
+}
+
+func TestAnalyzeCubeSkillTooGood(t *testing.T) {
+	engine, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+
+	// The opponent cannot bear off before the doubler is off: a certain
+	// gammon, worth playing on for rather than cashing
+	tooGood := &GameState{
+		Board:     boardWith(map[int]uint8{1: 3, 2: 2}, map[int]uint8{7: 3, 8: 3, 9: 3, 10: 2, 11: 2, 12: 2}),
+		CubeValue: 1,
+		CubeOwner: -1,
+	}
+	// Almost sure to win, never a gammon: the doubler should cash
+	cash := &GameState{
+		Board:     boardWith(map[int]uint8{4: 2}, map[int]uint8{6: 3}),
+		CubeValue: 1,
+		CubeOwner: -1,
+	}
+
+	ca, err := engine.AnalyzeCube(tooGood)
+	if err != nil {
+		t.Fatalf("AnalyzeCube failed: %v", err)
+	}
+	if ca.DecisionType != TOOGOOD_PASS || ca.Decision.Action != TooGood {
+		t.Fatalf("reference position is %v (%v), want too_good_pass (too_good)", ca.DecisionType, ca.Decision.Action)
+	}
+	if ca.Decision.DoubleEquity != ca.DoublePassEq {
+		t.Errorf("Decision.DoubleEquity = %.4f, want the cash %.4f", ca.Decision.DoubleEquity, ca.DoublePassEq)
+	}
+	cashCA, err := engine.AnalyzeCube(cash)
+	if err != nil {
+		t.Fatalf("AnalyzeCube failed: %v", err)
+	}
+	if cashCA.DecisionType != DOUBLE_PASS {
+		t.Fatalf("cash position is %v, want double_pass", cashCA.DecisionType)
+	}
+
+	tests := []struct {
+		name    string
+		state   *GameState
+		action  CubeAction
+		optimal CubeAction
+		loss    float64
+	}{
+		// Cashing gives up the gammon
+		{"double when too good", tooGood, Double, TooGood, ca.NoDoubleEquity - ca.DoublePassEq},
+		{"play on when too good", tooGood, NoDouble, TooGood, 0},
+		// Taking a double from a position too good to double
+		{"take", tooGood, Take, Pass, ca.DoubleTakeEq - ca.DoublePassEq},
+		{"pass", tooGood, Pass, Pass, 0},
+		// Playing on where the cash was right loses the difference to the
+		// cash, not to the double taken
+		{"play on when cashing", cash, NoDouble, Double, cashCA.DoublePassEq - cashCA.NoDoubleEquity},
+		{"cash", cash, Double, Double, 0},
+	}
+	for _, tt := range tests {
+		analysis, err := engine.AnalyzeCubeSkill(tt.state, tt.action)
+		if err != nil {
+			t.Fatalf("%s: AnalyzeCubeSkill failed: %v", tt.name, err)
+		}
+		if analysis.OptimalPlay != tt.optimal || math.Abs(analysis.EquityLoss-tt.loss) > 1e-9 {
+			t.Errorf("%s: optimal %v loss %.4f, want %v and %.4f", tt.name, analysis.OptimalPlay, analysis.EquityLoss, tt.optimal, tt.loss)
+		}
+		if tt.loss >= DefaultAnalysisConfig().DoubtfulThreshold && analysis.Skill == SkillNone {
+			t.Errorf("%s: a loss of %.4f is rated %v", tt.name, tt.loss, analysis.Skill)
+		}
+	}
+}
+
+func TestAnalysisConfigValidate(t *testing.T) {
+	if err := DefaultAnalysisConfig().Validate(); err != nil {
+		t.Errorf("default config: %v", err)
+	}
+	bad := []func(*AnalysisConfig){
+		func(c *AnalysisConfig) { c.DoubtfulThreshold = 0 },
+		func(c *AnalysisConfig) { c.BadThreshold = c.DoubtfulThreshold },
+		func(c *AnalysisConfig) { c.BlunderThreshold = 0.05 },
+		func(c *AnalysisConfig) { c.LuckyThreshold = -0.1 },
+		func(c *AnalysisConfig) { c.VeryLuckyThreshold = 0.2 },
+	}
+	for i, change := range bad {
+		cfg := DefaultAnalysisConfig()
+		change(&cfg)
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("config %d %+v: want error", i, cfg)
+		}
+	}
+}
+
+func TestAnalyzeMoveSkillWithConfig(t *testing.T) {
+	e, err := NewEngine(EngineOptions{})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	// A contact net whose win chance depends on the position
+	net := constantNet(neuralnet.NumContactInputs, 0, -2, -4, -2, -4)
+	rng := rand.New(rand.NewSource(3))
+	for i := range net.HiddenWeight {
+		net.HiddenWeight[i] = float32(rng.NormFloat64())
+	}
+	net.OutputWeight[0] = 4
+	e.contact, e.crashed = net, net
+	e.initBufferPools()
+
+	state := StartingPosition()
+	dice := [2]int{3, 1}
+	// The tutor ranks moves by cubeful equity
+	ranked, err := e.AnalyzePositionWithOptions(state, dice, EvalOptions{Cubeful: true})
+	if err != nil {
+		t.Fatalf("AnalyzePositionWithOptions failed: %v", err)
+	}
+	worst := ranked.Moves[len(ranked.Moves)-1].Move
+	loss := ranked.BestEquity - ranked.Moves[len(ranked.Moves)-1].Equity
+	if loss <= 0 {
+		t.Fatalf("test net gives no equity loss")
+	}
+
+	// Strict and lenient thresholds around the same loss
+	strict := AnalysisConfig{DoubtfulThreshold: loss / 4, BadThreshold: loss / 2, BlunderThreshold: loss * 0.9, LuckyThreshold: 0.3, VeryLuckyThreshold: 0.6}
+	lenient := AnalysisConfig{DoubtfulThreshold: loss * 2, BadThreshold: loss * 3, BlunderThreshold: loss * 4, LuckyThreshold: 0.3, VeryLuckyThreshold: 0.6}
+	want := map[*AnalysisConfig]SkillType{&strict: SkillVeryBad, &lenient: SkillNone}
+
+	// Concurrent analyses with different thresholds do not interfere
+	var wg sync.WaitGroup
+	errs := make(chan string, 16)
+	for i := 0; i < 8; i++ {
+		for cfg, skill := range want {
+			wg.Add(1)
+			go func(cfg AnalysisConfig, skill SkillType) {
+				defer wg.Done()
+				analysis, err := e.AnalyzeMoveSkillWithConfig(state, worst, dice, cfg)
+				if err != nil {
+					errs <- err.Error()
+					return
+				}
+				if analysis.Skill != skill || math.Abs(analysis.EquityLoss-loss) > 1e-9 {
+					errs <- fmt.Sprintf("loss %.4f rated %v with %+v, want loss %.4f rated %v",
+						analysis.EquityLoss, analysis.Skill, cfg, loss, skill)
+				}
+			}(*cfg, skill)
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for msg := range errs {
+		t.Error(msg)
+	}
+
+	// Match analysis uses the thresholds in its options
+	positions := []AnalyzedPosition{{Board: state.Board, Dice: dice, CubeValue: 1, CubeOwner: -1, Move: &worst, GameNumber: 1, MoveNumber: 1}}
+	for cfg, skill := range want {
+		opts := DefaultMatchAnalysisOptions()
+		opts.Thresholds = cfg
+		result, err := e.AnalyzePositionList(positions, opts)
+		if err != nil {
+			t.Fatalf("AnalyzePositionList failed: %v", err)
+		}
+		if got := len(result.MoveErrors); (got > 0) != (skill != SkillNone) {
+			t.Errorf("thresholds %+v: %d move errors, want rating %v", *cfg, got, skill)
+		}
+	}
+	opts := DefaultMatchAnalysisOptions()
+	opts.Thresholds = &AnalysisConfig{DoubtfulThreshold: 0.1, BadThreshold: 0.05, BlunderThreshold: 0.2}
+	if _, err := e.AnalyzePositionList(positions, opts); err == nil {
+		t.Error("AnalyzePositionList accepted unordered thresholds")
+	}
+}
+
+func TestLuck(t *testing.T) {
+	e, err := NewEngine(EngineOptions{SkipWarmup: true})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	// Checkers on the 6 and 5 points against one on the ace point: 6 rolls
+	// in 36 win and the rest lose, so the average equity is -2/3
+	var board Board
+	board[1][5], board[1][4] = 1, 1
+	board[0][0] = 1
+	state := &GameState{Board: board, CubeValue: 1, CubeOwner: -1}
+	for _, tt := range []struct {
+		dice [2]int
+		want float64
+	}{
+		{[2]int{6, 5}, 5.0 / 3},
+		{[2]int{5, 6}, 5.0 / 3},
+		{[2]int{3, 3}, 5.0 / 3},
+		{[2]int{2, 1}, -1.0 / 3},
+	} {
+		luck, err := e.Luck(state, tt.dice)
+		if err != nil {
+			t.Fatalf("Luck failed: %v", err)
+		}
+		if math.Abs(luck-tt.want) > 1e-9 {
+			t.Errorf("Luck(%v) = %v, want %v", tt.dice, luck, tt.want)
+		}
+	}
+}
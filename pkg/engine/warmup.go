@@ -0,0 +1,89 @@
+package engine
+
+import (
+	"fmt"
+
+	"github.com/yourusername/bgengine/internal/neuralnet"
+)
+
+// warmupPosition is a position Warmup evaluates, with the class it is
+// meant to exercise.
+type warmupPosition struct {
+	class neuralnet.PositionClass
+	board Board
+}
+
+// warmupPositions returns one position of every class the evaluator handles.
+// Points are 1-24 from each side's own perspective, as in boardFromPoints.
+func warmupPositions() []warmupPosition {
+	return []warmupPosition{
+		{neuralnet.ClassContact, StartingPosition().Board},
+		// Player on roll has borne off most of his checkers but still has
+		// two back checkers behind the opponent's 5-point board
+		{neuralnet.ClassCrashed, boardFromPoints(
+			map[int]uint8{24: 2, 2: 2, 1: 2},
+			map[int]uint8{6: 3, 5: 3, 4: 3, 3: 3, 2: 3})},
+		{neuralnet.ClassRace, boardFromPoints(
+			map[int]uint8{10: 3, 8: 4, 6: 4, 5: 4},
+			map[int]uint8{11: 3, 9: 4, 6: 4, 4: 4})},
+		{neuralnet.ClassBearoff1, boardFromPoints(
+			map[int]uint8{6: 4, 5: 4, 4: 3, 3: 2, 2: 2},
+			map[int]uint8{6: 5, 5: 4, 4: 3, 3: 3})},
+		// Too long for the exact solver, so it reaches the two-sided database
+		{neuralnet.ClassBearoffTS, boardFromPoints(
+			map[int]uint8{6: 2, 5: 2, 4: 2},
+			map[int]uint8{6: 2, 5: 2, 4: 1, 3: 1})},
+	}
+}
+
+// boardFromPoints builds a board from checker counts by point (1-24, 25 for
+// the bar) of the player on roll and of the opponent.
+func boardFromPoints(mover, opp map[int]uint8) Board {
+	var b Board
+	for p, n := range mover {
+		b[1][p-1] = n
+	}
+	for p, n := range opp {
+		b[0][p-1] = n
+	}
+	return b
+}
+
+// Warmup evaluates a handful of representative positions so that the lazily
+// built parts of the engine (buffer pools, the sigmoid and input tables, the
+// bearoff index tables and the evaluation cache) are ready before the first
+// real request. It covers every position class, including the one-sided and
+// two-sided bearoff databases when they are loaded, checker play at 1 ply
+// and a cube decision. NewEngine calls it unless EngineOptions.SkipWarmup is
+// set; Warmed reports whether it has completed.
+func (e *Engine) Warmup() error {
+	for _, pos := range warmupPositions() {
+		state := &GameState{Board: pos.board, CubeValue: 1, CubeOwner: -1}
+		if _, err := e.Evaluate(state); err != nil {
+			return fmt.Errorf("warm-up %s evaluation: %w", pos.class, err)
+		}
+		if _, err := e.EvaluateCached(state, 0); err != nil {
+			return fmt.Errorf("warm-up %s evaluation: %w", pos.class, err)
+		}
+	}
+
+	start := StartingPosition()
+	if _, err := e.EvaluatePlied(start, 1); err != nil {
+		return fmt.Errorf("warm-up 1-ply evaluation: %w", err)
+	}
+	if _, err := e.AnalyzePosition(start, [2]int{3, 1}); err != nil {
+		return fmt.Errorf("warm-up move analysis: %w", err)
+	}
+	if _, err := e.AnalyzeCube(start); err != nil {
+		return fmt.Errorf("warm-up cube analysis: %w", err)
+	}
+
+	e.warm.Store(true)
+	return nil
+}
+
+// Warmed reports whether Warmup has completed, so the engine answers its
+// first requests at full speed.
+func (e *Engine) Warmed() bool {
+	return e.warm.Load()
+}
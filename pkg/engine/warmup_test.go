@@ -0,0 +1,120 @@
+package engine
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/yourusername/bgengine/internal/neuralnet"
+)
+
+// randomNet returns a net of the given size with random weights, as slow to
+// evaluate as a real net of that size.
+func randomNet(inputs, hidden uint32, seed int64) *neuralnet.NeuralNet {
+	rng := rand.New(rand.NewSource(seed))
+	weights := func(n uint32, scale float64) []float32 {
+		w := make([]float32, n)
+		for i := range w {
+			w[i] = float32(rng.NormFloat64() * scale)
+		}
+		return w
+	}
+	return &neuralnet.NeuralNet{
+		CInput:          inputs,
+		CHidden:         hidden,
+		COutput:         5,
+		RBetaHidden:     0.1,
+		RBetaOutput:     1,
+		HiddenWeight:    weights(inputs*hidden, 1),
+		OutputWeight:    weights(hidden*5, 0.1),
+		HiddenThreshold: weights(hidden, 1),
+		OutputThreshold: weights(5, 1),
+	}
+}
+
+func TestWarmupPositions(t *testing.T) {
+	for _, pos := range warmupPositions() {
+		if class := neuralnet.ClassifyPosition(neuralnet.Board(pos.board)); class != pos.class {
+			t.Errorf("%s warm-up position classifies as %s", pos.class, class)
+		}
+		// The two-sided position must reach the database rather than the
+		// exact solver
+		if pos.class == neuralnet.ClassBearoffTS && preferExact(pos.board) {
+			t.Errorf("two-sided warm-up position is solved exactly")
+		}
+	}
+}
+
+func TestWarmupFirstEvaluation(t *testing.T) {
+	e, err := NewEngine(EngineOptions{SkipWarmup: true})
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	if e.Warmed() {
+		t.Fatal("Warmed() = true before Warmup")
+	}
+	e.contact = randomNet(neuralnet.NumContactInputs, 128, 1)
+	e.crashed = randomNet(neuralnet.NumContactInputs, 128, 2)
+	e.race = randomNet(neuralnet.NumRaceInputs, 128, 3)
+	e.initBufferPools()
+
+	if err := e.Warmup(); err != nil {
+		t.Fatalf("Warmup failed: %v", err)
+	}
+	if !e.Warmed() {
+		t.Fatal("Warmed() = false after Warmup")
+	}
+
+	// Positions Warmup has not seen, so none of them is cached
+	states, _ := randomCorpus(7, 12)
+	elapsed := func(state *GameState) time.Duration {
+		start := time.Now()
+		if _, err := e.EvaluatePlied(state, 1); err != nil {
+			t.Fatalf("EvaluatePlied failed: %v", err)
+		}
+		return time.Since(start)
+	}
+
+	first := elapsed(states[0])
+	var steady []time.Duration
+	for _, state := range states[1:] {
+		steady = append(steady, elapsed(state))
+	}
+	sort.Slice(steady, func(i, j int) bool { return steady[i] < steady[j] })
+	median := steady[len(steady)/2]
+
+	if first > 3*median {
+		t.Errorf("first 1-ply evaluation after warm-up took %v, steady state %v", first, median)
+	}
+}
+
+func TestWarmupTwoSidedBearoff(t *testing.T) {
+	e, err := NewEngine(EngineOptions{
+		BearoffFile:   "../../data/gnubg_os0.bd",
+		BearoffTSFile: "../../data/gnubg_ts.bd",
+	})
+	if err != nil || e.bearoffTS == nil {
+		t.Skip("Skipping - bearoff databases not available")
+	}
+	if !e.Warmed() {
+		t.Fatal("NewEngine did not warm up")
+	}
+
+	for _, pos := range warmupPositions() {
+		if pos.class != neuralnet.ClassBearoffTS {
+			continue
+		}
+		want, err := e.bearoffTS.Evaluate(neuralnet.GetBearoffBoard(neuralnet.Board(pos.board)))
+		if err != nil {
+			t.Fatalf("two-sided lookup failed: %v", err)
+		}
+		got, err := e.evaluateOutput(neuralnet.Board(pos.board), fullTotals, nil)
+		if err != nil {
+			t.Fatalf("evaluate failed: %v", err)
+		}
+		if got != want {
+			t.Errorf("two-sided warm-up position evaluates to %v, database has %v", got, want)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+//go:build !js
+
+package engine
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// TestBuildWasm checks that the engine and the WebAssembly example build
+// for the browser, where there is no file system.
+func TestBuildWasm(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds for js/wasm")
+	}
+	gobin := filepath.Join(runtime.GOROOT(), "bin", "go")
+	if _, err := os.Stat(gobin); err != nil {
+		t.Skipf("go tool not found: %v", err)
+	}
+	cmd := exec.Command(gobin, "build", "-o", os.DevNull, "./", "../../examples/wasm")
+	cmd.Env = append(os.Environ(), "GOOS=js", "GOARCH=wasm")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("GOOS=js GOARCH=wasm go build: %v\n%s", err, out)
+	}
+}
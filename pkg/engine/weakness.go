@@ -0,0 +1,148 @@
+package engine
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/yourusername/bgengine/internal/neuralnet"
+)
+
+// GamePhase is the stage of a game a decision was made in.
+type GamePhase string
+
+const (
+	PhaseOpening GamePhase = "opening" // The first OpeningMoves moves of a game
+	PhaseMiddle  GamePhase = "middle"  // Contact after the opening
+	PhaseBearIn  GamePhase = "bearin"  // The race, until the player's checkers are home
+	PhaseBearOff GamePhase = "bearoff" // All the player's checkers home, with contact or without
+)
+
+// OpeningMoves is the last move number of a game counted as the opening.
+const OpeningMoves = 4
+
+// Decision kinds of a WeaknessProfile.
+const (
+	DecisionChecker = "checker"
+	DecisionCube    = "cube"
+)
+
+// ClassifyPhase returns the phase of the game for the player on roll in
+// board[1] at move moveNumber of a game.
+func ClassifyPhase(board Board, moveNumber int) GamePhase {
+	if moveNumber <= OpeningMoves {
+		return PhaseOpening
+	}
+	home := true
+	for i := 6; i < 25; i++ {
+		if board[1][i] > 0 {
+			home = false
+			break
+		}
+	}
+	if home {
+		return PhaseBearOff
+	}
+	if neuralnet.ClassifyPosition(neuralnet.Board(board)) <= neuralnet.ClassRace {
+		return PhaseBearIn
+	}
+	return PhaseMiddle
+}
+
+// ClassifyDecision returns the position category and game phase of a
+// decision at move moveNumber of a game, for the player who made it: the
+// player on roll in board[1], except for a take, pass or beaver, which
+// answers the double of the player on roll.
+func ClassifyDecision(board Board, moveNumber int, action CubeAction) (PositionCategory, GamePhase) {
+	switch action {
+	case Take, Pass, Beaver:
+		board = swapBoard(board)
+	}
+	// ClassifyPosition describes the game of the player in board[0]
+	return ClassifyPosition(swapBoard(board)), ClassifyPhase(board, moveNumber)
+}
+
+// CategoryName returns the name of a position category as match analysis
+// reports it, e.g. "holding" or "safety_play". ParsePositionCategory reads it.
+func CategoryName(c PositionCategory) string {
+	return strings.ReplaceAll(strings.ToLower(c.String()), " ", "_")
+}
+
+// WeaknessBucket is the errors of one kind in a WeaknessProfile.
+type WeaknessBucket struct {
+	Name       string  `json:"name"`
+	Errors     int     `json:"errors"`      // Errors of this kind
+	EquityLoss float64 `json:"equity_loss"` // Equity they lost
+}
+
+// WeaknessProfile breaks a player's errors down three ways, to show where
+// the equity goes: by the kind of position (see CategoryName), by the phase
+// of the game and by checker play against cube decisions. Each list holds
+// only the kinds with errors, the costliest first.
+type WeaknessProfile struct {
+	Categories []WeaknessBucket `json:"categories,omitempty"`
+	Phases     []WeaknessBucket `json:"phases,omitempty"`
+	Decisions  []WeaknessBucket `json:"decisions,omitempty"` // DecisionChecker and DecisionCube
+}
+
+// Add counts an error that lost equityLoss in a position of the given
+// category and phase, in checker play or, with cube, a cube decision.
+func (p *WeaknessProfile) Add(category string, phase GamePhase, cube bool, equityLoss float64) {
+	decision := DecisionChecker
+	if cube {
+		decision = DecisionCube
+	}
+	p.Categories = addWeakness(p.Categories, category, equityLoss)
+	p.Phases = addWeakness(p.Phases, string(phase), equityLoss)
+	p.Decisions = addWeakness(p.Decisions, decision, equityLoss)
+}
+
+// Worst returns the kind of position the player lost the most equity in,
+// or false if they made no errors.
+func (p *WeaknessProfile) Worst() (WeaknessBucket, bool) {
+	if len(p.Categories) == 0 {
+		return WeaknessBucket{}, false
+	}
+	return p.Categories[0], true
+}
+
+// addWeakness counts an error in the bucket called name, keeping buckets
+// ordered by equity lost, then by number of errors, then by name.
+func addWeakness(buckets []WeaknessBucket, name string, equityLoss float64) []WeaknessBucket {
+	i := 0
+	for i < len(buckets) && buckets[i].Name != name {
+		i++
+	}
+	if i == len(buckets) {
+		buckets = append(buckets, WeaknessBucket{Name: name})
+	}
+	buckets[i].Errors++
+	buckets[i].EquityLoss += equityLoss
+	sort.SliceStable(buckets, func(a, b int) bool {
+		x, y := buckets[a], buckets[b]
+		if x.EquityLoss != y.EquityLoss {
+			return x.EquityLoss > y.EquityLoss
+		}
+		if x.Errors != y.Errors {
+			return x.Errors > y.Errors
+		}
+		return x.Name < y.Name
+	})
+	return buckets
+}
+
+// WeaknessProfiles builds each player's WeaknessProfile from the errors of
+// a match analysis.
+func WeaknessProfiles(moves []MoveErrorDetail, cubes []CubeErrorDetail) [2]WeaknessProfile {
+	var profiles [2]WeaknessProfile
+	for _, me := range moves {
+		if me.Player == 0 || me.Player == 1 {
+			profiles[me.Player].Add(me.Category, me.Phase, false, me.EquityLoss)
+		}
+	}
+	for _, ce := range cubes {
+		if ce.Player == 0 || ce.Player == 1 {
+			profiles[ce.Player].Add(ce.Category, ce.Phase, true, ce.EquityLoss)
+		}
+	}
+	return profiles
+}
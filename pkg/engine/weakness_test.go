@@ -0,0 +1,106 @@
+package engine
+
+import "testing"
+
+func TestClassifyPhase(t *testing.T) {
+	start := StartingPosition().Board
+
+	var race Board
+	race[1][7], race[1][9], race[1][3] = 5, 5, 5
+	race[0][2], race[0][4], race[0][10] = 5, 5, 5
+
+	var bearoff Board
+	bearoff[1][0], bearoff[1][3], bearoff[1][5] = 5, 5, 5
+	bearoff[0][20], bearoff[0][5], bearoff[0][3] = 2, 8, 5 // Still an anchor behind
+
+	tests := []struct {
+		name  string
+		board Board
+		move  int
+		want  GamePhase
+	}{
+		{"first move", start, 1, PhaseOpening},
+		{"last opening move", start, OpeningMoves, PhaseOpening},
+		{"contact after the opening", start, OpeningMoves + 1, PhaseMiddle},
+		{"race", race, 20, PhaseBearIn},
+		{"all home with contact", bearoff, 30, PhaseBearOff},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyPhase(tt.board, tt.move); got != tt.want {
+				t.Errorf("ClassifyPhase = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassifyDecision(t *testing.T) {
+	// The player on roll has closed four home points with the opponent on
+	// the bar
+	var board Board
+	board[1][0], board[1][1], board[1][2], board[1][3] = 2, 2, 2, 2
+	board[1][5], board[1][12] = 3, 4
+	board[0][24], board[0][23], board[0][5], board[0][12] = 1, 2, 7, 5
+
+	if cat, phase := ClassifyDecision(board, 10, NoDouble); cat != CategoryBlitz || phase != PhaseMiddle {
+		t.Errorf("checker play = %v/%q, want Blitz/middle", cat, phase)
+	}
+	if cat, _ := ClassifyDecision(board, 10, Double); cat != CategoryBlitz {
+		t.Errorf("double = %v, want Blitz", cat)
+	}
+	// The taker is the one being blitzed
+	if cat, _ := ClassifyDecision(board, 10, Take); cat == CategoryBlitz {
+		t.Error("take classified as Blitz from the doubler's side")
+	}
+	if CategoryName(CategorySafetyPlay) != "safety_play" {
+		t.Errorf("CategoryName(SafetyPlay) = %q", CategoryName(CategorySafetyPlay))
+	}
+}
+
+func TestWeaknessProfiles(t *testing.T) {
+	moves := []MoveErrorDetail{
+		{Player: 0, EquityLoss: 0.05, Category: "holding", Phase: PhaseMiddle},
+		{Player: 0, EquityLoss: 0.10, Category: "blitz", Phase: PhaseOpening},
+		{Player: 0, EquityLoss: 0.02, Category: "holding", Phase: PhaseMiddle},
+		{Player: 1, EquityLoss: 0.04, Category: "race", Phase: PhaseBearIn},
+	}
+	cubes := []CubeErrorDetail{
+		{Player: 0, EquityLoss: 0.08, Category: "holding", Phase: PhaseMiddle},
+		{Player: 1, EquityLoss: 0.04, Category: "bearoff", Phase: PhaseBearOff},
+	}
+	profiles := WeaknessProfiles(moves, cubes)
+
+	want0 := WeaknessProfile{
+		Categories: []WeaknessBucket{{"holding", 3, 0.15}, {"blitz", 1, 0.10}},
+		Phases:     []WeaknessBucket{{"middle", 3, 0.15}, {"opening", 1, 0.10}},
+		Decisions:  []WeaknessBucket{{DecisionChecker, 3, 0.17}, {DecisionCube, 1, 0.08}},
+	}
+	checkBuckets(t, "player 0 categories", profiles[0].Categories, want0.Categories)
+	checkBuckets(t, "player 0 phases", profiles[0].Phases, want0.Phases)
+	checkBuckets(t, "player 0 decisions", profiles[0].Decisions, want0.Decisions)
+
+	// Equal losses and counts fall back to name order
+	checkBuckets(t, "player 1 categories", profiles[1].Categories,
+		[]WeaknessBucket{{"bearoff", 1, 0.04}, {"race", 1, 0.04}})
+
+	worst, ok := profiles[0].Worst()
+	if !ok || worst.Name != "holding" {
+		t.Errorf("Worst = %+v, %v, want holding", worst, ok)
+	}
+	if _, ok := (&WeaknessProfile{}).Worst(); ok {
+		t.Error("Worst of an empty profile should be false")
+	}
+}
+
+func checkBuckets(t *testing.T, name string, got, want []WeaknessBucket) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s = %+v, want %+v", name, got, want)
+	}
+	for i := range want {
+		if got[i].Name != want[i].Name || got[i].Errors != want[i].Errors ||
+			!almostEqual(got[i].EquityLoss, want[i].EquityLoss) {
+			t.Errorf("%s[%d] = %+v, want %+v", name, i, got[i], want[i])
+		}
+	}
+}
@@ -324,16 +324,16 @@ func (s *Server) handleFIBSBoard(cmd string) string {
 		return "Error: no dice rolled\n"
 	}
 
-	analysis, err := s.engine.AnalyzePosition(state, fb.Dice)
+	bestMove, _, err := s.engine.BestMove(state, fb.Dice, engine.DefaultEvalOptions())
 	if err != nil {
 		return fmt.Sprintf("Error: %v\n", err)
 	}
 
-	if analysis.NumMoves == 0 {
+	if bestMove.From[0] < 0 {
 		return "cannot move\n"
 	}
 
 	// Format the best move
-	moveStr := FormatMove(analysis.BestMove, fb.Direction)
+	moveStr := FormatMove(bestMove, fb.Direction)
 	return moveStr + "\n"
 }
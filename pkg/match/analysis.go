@@ -0,0 +1,284 @@
+package match
+
+import (
+	"github.com/yourusername/bgengine/pkg/engine"
+)
+
+// AnalysisActions converts the match into the action list used by engine match
+// analysis (engine.ConvertMatchActionsToPositions and Engine.AnalyzePositionList).
+// Moves are converted to the engine's mover-relative notation, and a roll that is
+// not followed by a move is reported as an empty move so the board stays in step,
+// except for an opening roll recorded for the player who lost it.
+// Each game's recorded starting score is passed on in Scores, its starting
+// board in StartBoards when it is not the standard starting position, the
+// cube of a game that starts with automatic doubles in StartCubes, and the
+// Crawford game in Crawford.
+// Analysis recorded on an action is passed on as the engine action's Annotation.
+func (m *Match) AnalysisActions() engine.MatchActions {
+	actions, _ := m.analysisActions()
+	return actions
+}
+
+// analysisActions returns AnalysisActions and, for each engine action, the
+// action of the match it came from: the move, the roll of a roll that could
+// not be played, or the cube action.
+func (m *Match) analysisActions() (engine.MatchActions, []*Action) {
+	var sources []*Action
+	actions := engine.MatchActions{
+		Player1Name: m.Player1,
+		Player2Name: m.Player2,
+		Scores:      make(map[int][2]int, len(m.Games)),
+		StartBoards: make(map[int]engine.Board),
+		StartCubes:  make(map[int]engine.GameCube),
+		Crawford:    make(map[int]bool),
+	}
+
+	standard := engine.StartingPosition().Board
+	for _, game := range m.Games {
+		if game.Crawford {
+			actions.Crawford[game.Number] = true
+		}
+		if game.InitialBoard != (engine.Board{}) && game.InitialBoard != standard {
+			actions.StartBoards[game.Number] = game.InitialBoard
+		}
+		if game.AutomaticDoubles > 0 {
+			// The cube decisions of the game are taken at the doubled stake
+			actions.StartCubes[game.Number] = engine.GameCube{Value: game.Stake(), Owner: -1}
+		}
+		// Formats without per-game scores leave later games at 0-0;
+		// the engine then carries the score forward from each result
+		if game.Score1 != 0 || game.Score2 != 0 || game.Number == 1 {
+			actions.Scores[game.Number] = [2]int{game.Score1, game.Score2}
+		}
+		moveNum := 0
+		pending := false // A roll has not been played yet
+		var roll *Action
+
+		flush := func() {
+			// Every roll has a play from the starting position, so an unplayed
+			// roll before the first play is the loser's half of the opening roll
+			if pending && moveNum == 0 && engine.IsStartingPosition(game.InitialBoard) {
+				pending = false
+			}
+			if pending {
+				moveNum++
+				dance := engine.Move{
+					From: [4]int8{-1, -1, -1, -1},
+					To:   [4]int8{-1, -1, -1, -1},
+				}
+				actions.Actions = append(actions.Actions, engine.MatchAction{
+					GameNumber: game.Number,
+					MoveNumber: moveNum,
+					Player:     roll.Player,
+					Dice:       roll.Dice,
+					Move:       &dance,
+					Annotation: roll.Analysis,
+				})
+				sources = append(sources, roll)
+				pending = false
+			}
+		}
+
+		roll = &Action{}
+		for i := range game.Actions {
+			action := &game.Actions[i]
+			switch action.Type {
+			case ActionRoll:
+				flush()
+				roll = action
+				pending = true
+
+			case ActionMove:
+				moveNum++
+				move := engineMove(action.Move, action.Player)
+				actions.Actions = append(actions.Actions, engine.MatchAction{
+					GameNumber: game.Number,
+					MoveNumber: moveNum,
+					Player:     action.Player,
+					Dice:       roll.Dice,
+					Move:       &move,
+					Annotation: action.Analysis,
+				})
+				sources = append(sources, action)
+				pending = false
+
+			case ActionDouble, ActionTake, ActionPass, ActionBeaver, ActionRaccoon:
+				flush()
+				actions.Actions = append(actions.Actions, engine.MatchAction{
+					GameNumber: game.Number,
+					MoveNumber: moveNum,
+					Player:     action.Player,
+					CubeAction: cubeActionFor(action.Type),
+					Annotation: action.Analysis,
+				})
+				sources = append(sources, action)
+			}
+		}
+	}
+
+	return actions, sources
+}
+
+// Annotate records the decisions of a, an analysis of the match with
+// MatchAnalysisOptions.Decisions, on the actions they analyze, so that
+// ExportSGF writes them. Decisions that match no action are ignored.
+func (m *Match) Annotate(a *engine.MatchAnalysis) {
+	type key struct {
+		game, move, player int
+		cube               engine.CubeAction
+	}
+	actions, sources := m.analysisActions()
+	byKey := make(map[key]*Action, len(sources))
+	for i, action := range actions.Actions {
+		byKey[key{action.GameNumber, action.MoveNumber, action.Player, action.CubeAction}] = sources[i]
+	}
+	for i := range a.Decisions {
+		d := &a.Decisions[i]
+		if action, ok := byKey[key{d.GameNumber, d.MoveNumber, d.Player, d.CubeAction}]; ok {
+			action.Analysis = d
+		}
+	}
+}
+
+// Analyze analyzes every game of the match with e. The players are named
+// as in the match, or as in opts when the match has no names. The games'
+// results are taken from the match, and with opts.IncludeLuck so is the
+// luck-adjusted result.
+func (m *Match) Analyze(e *engine.Engine, opts engine.MatchAnalysisOptions) (*engine.MatchAnalysis, error) {
+	if m.Player1 != "" {
+		opts.Player1Name = m.Player1
+	}
+	if m.Player2 != "" {
+		opts.Player2Name = m.Player2
+	}
+	positions := engine.ConvertMatchActionsToPositions(m.AnalysisActions(), engine.StartingPosition().Board, [2]int{}, m.MatchLength)
+	a, err := e.AnalyzePositionList(positions, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	games := make(map[int]*Game, len(m.Games))
+	for _, g := range m.Games {
+		games[g.Number] = g
+	}
+	for i := range a.GameStats {
+		if g, ok := games[a.GameStats[i].GameNumber]; ok {
+			a.GameStats[i].Winner = g.Winner
+			a.GameStats[i].Points = g.Points
+			if g.Points == 0 && g.Winner >= 0 {
+				a.GameStats[i].Points = g.PointsWon(g.Result)
+			}
+		}
+	}
+	a.LuckAdjusted = a.AdjustForLuck()
+	return a, nil
+}
+
+// MoveNotation returns the move of an ActionMove in the engine's notation,
+// numbered from the side of the player who made it ("24/18 13/11").
+func (a Action) MoveNotation() string {
+	if a.Type != ActionMove {
+		return ""
+	}
+	return engine.FormatMove(engineMove(a.Move, a.Player))
+}
+
+// matchPoint converts a point as player numbers it to the match
+// representation, numbered 1-24 from player 0's side: player 0's bar is 25
+// and its off 0, player 1's bar 0 and its off 25.
+func matchPoint(p engine.Point, player int) int {
+	n := p.Number()
+	if player == 1 {
+		n = 25 - n
+	}
+	return n
+}
+
+// enginePoint converts a point of the match representation to the point as
+// player numbers it, the inverse of matchPoint.
+func enginePoint(point, player int) engine.Point {
+	if player == 1 {
+		point = 25 - point
+	}
+	return engine.PointFromNumber(point)
+}
+
+// boardResult replays the checker plays of the game and reports how it
+// ends on the board: the player who bears off their last checker and
+// whether that wins a single game, a gammon or a backgammon. ok is false if
+// the game does not end by bearing off, or a play cannot be replayed.
+func (g *Game) boardResult() (winner int, result GameResult, ok bool) {
+	actions, _ := (&Match{Games: []*Game{g}}).analysisActions()
+	positions := engine.ConvertMatchActionsToPositions(actions, engine.StartingPosition().Board, [2]int{}, 0)
+	for i := len(positions) - 1; i >= 0; i-- {
+		pos := positions[i]
+		if pos.Move == nil {
+			continue
+		}
+		board, err := engine.ApplyMoveChecked(pos.Board, pos.Dice, *pos.Move)
+		if err != nil {
+			return -1, ResultInProgress, false
+		}
+		// The board is the mover's, with the mover as side 1
+		side, points, ok := engine.GameResult(board)
+		if !ok || side != 1 {
+			return -1, ResultInProgress, false
+		}
+		return pos.Player, []GameResult{ResultSingle, ResultGammon, ResultBackgammon}[points-1], true
+	}
+	return -1, ResultInProgress, false
+}
+
+// engineMove converts a move from the match representation to the engine's
+// mover-relative points (see engine.Point).
+func engineMove(move engine.Move, player int) engine.Move {
+	result := engine.Move{
+		From: [4]int8{-1, -1, -1, -1},
+		To:   [4]int8{-1, -1, -1, -1},
+	}
+
+	for i := 0; i < 4; i++ {
+		if move.From[i] < 0 {
+			break
+		}
+		result.From[i] = int8(enginePoint(int(move.From[i]), player))
+		result.To[i] = int8(enginePoint(int(move.To[i]), player))
+	}
+
+	return result
+}
+
+// matchMove converts a move from the engine's mover-relative points back to
+// the match representation, the inverse of engineMove.
+func matchMove(move engine.Move, player int) engine.Move {
+	result := engine.Move{
+		From: [4]int8{-1, -1, -1, -1},
+		To:   [4]int8{-1, -1, -1, -1},
+	}
+
+	for i := 0; i < 4; i++ {
+		if move.From[i] < 0 {
+			break
+		}
+		result.From[i] = int8(matchPoint(engine.Point(move.From[i]), player))
+		result.To[i] = int8(matchPoint(engine.Point(move.To[i]), player))
+	}
+
+	return result
+}
+
+// cubeActionFor maps a cube action type to the engine's CubeAction.
+func cubeActionFor(t ActionType) engine.CubeAction {
+	switch t {
+	case ActionTake:
+		return engine.Take
+	case ActionPass:
+		return engine.Pass
+	case ActionBeaver:
+		return engine.Beaver
+	case ActionRaccoon:
+		return engine.Raccoon
+	default:
+		return engine.Double
+	}
+}
@@ -0,0 +1,181 @@
+package match
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrFlagFell is returned by Clock.Stop when the player on the clock ran out
+// of time during their move.
+var ErrFlagFell = errors.New("flag fell")
+
+// FlagPenalty is what a player loses when their flag falls.
+type FlagPenalty int
+
+const (
+	FlagLosesMatch FlagPenalty = iota // The opponent wins the match
+	FlagLosesGame                     // The opponent wins the game at the current cube
+)
+
+// DelayMode is how the delay of each move is given.
+type DelayMode int
+
+const (
+	DelaySimple    DelayMode = iota // The reserve runs only once the move has taken the delay
+	DelayBronstein                  // The reserve runs at once, and gets back the time the move took, up to the delay
+)
+
+// ClockConfig configures a match clock.
+type ClockConfig struct {
+	Reserve   time.Duration // Time bank of each player at the start of the match
+	Delay     time.Duration // Time each move may take without costing reserve (see DelayMode)
+	DelayMode DelayMode     // How the delay is given
+	Increment time.Duration // Time added to the reserve after each move
+	Penalty   FlagPenalty   // What a player loses when their reserve runs out
+}
+
+// Validate checks that the clock gives the players some time.
+func (c ClockConfig) Validate() error {
+	if c.Reserve <= 0 {
+		return fmt.Errorf("reserve must be positive, got %v", c.Reserve)
+	}
+	if c.Delay < 0 || c.Increment < 0 {
+		return fmt.Errorf("delay and increment must not be negative")
+	}
+	if c.DelayMode != DelaySimple && c.DelayMode != DelayBronstein {
+		return fmt.Errorf("unknown delay mode %d", c.DelayMode)
+	}
+	if c.Penalty != FlagLosesMatch && c.Penalty != FlagLosesGame {
+		return fmt.Errorf("unknown flag penalty %d", c.Penalty)
+	}
+	return nil
+}
+
+// ClockState is a snapshot of a clock, for session state updates.
+type ClockState struct {
+	Remaining [2]time.Duration `json:"remaining"` // Reserve left to each player, counting the running move
+	Running   int              `json:"running"`   // Player on the clock (-1 = stopped)
+	Flagged   int              `json:"flagged"`   // Player whose flag fell (-1 = none)
+}
+
+// Clock is a two-player match clock with a reserve per player, a delay per
+// move and an increment after each move. Players are indexed as in
+// GameState (0 or 1). It is not safe for concurrent use.
+type Clock struct {
+	config  ClockConfig
+	now     func() time.Time
+	reserve [2]time.Duration
+	running int
+	started time.Time
+	flagged int
+}
+
+// NewClock creates a stopped clock. now is the time source (nil for
+// time.Now), so tests can simulate slow moves.
+func NewClock(config ClockConfig, now func() time.Time) (*Clock, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if now == nil {
+		now = time.Now
+	}
+	return &Clock{
+		config:  config,
+		now:     now,
+		reserve: [2]time.Duration{config.Reserve, config.Reserve},
+		running: -1,
+		flagged: -1,
+	}, nil
+}
+
+// Start puts player on the clock for their move.
+func (c *Clock) Start(player int) error {
+	if player != 0 && player != 1 {
+		return fmt.Errorf("invalid player %d", player)
+	}
+	if c.flagged >= 0 {
+		return fmt.Errorf("player %d's flag has fallen", c.flagged)
+	}
+	if c.running >= 0 {
+		return fmt.Errorf("player %d is already on the clock", c.running)
+	}
+	c.running = player
+	c.started = c.now()
+	return nil
+}
+
+// Stop ends the running player's move: the time the move took is charged
+// to their reserve, less the delay (see DelayMode), and the increment
+// added. It returns ErrFlagFell if the reserve ran out during the move;
+// neither a Bronstein delay nor the increment saves a fallen flag.
+func (c *Clock) Stop() error {
+	player := c.running
+	if player < 0 {
+		return fmt.Errorf("clock is not running")
+	}
+	elapsed := c.now().Sub(c.started)
+	remaining := c.Remaining(player)
+	c.running = -1
+	if remaining <= 0 {
+		c.reserve[player] = 0
+		c.flagged = player
+		return ErrFlagFell
+	}
+	if c.config.DelayMode == DelayBronstein {
+		remaining += min(elapsed, c.config.Delay)
+	}
+	c.reserve[player] = remaining + c.config.Increment
+	return nil
+}
+
+// Refill gives player, whose flag has fallen, a full reserve again, for a
+// match that goes on after a flag costs a game (FlagLosesGame).
+func (c *Clock) Refill(player int) error {
+	if player != c.flagged {
+		return fmt.Errorf("player %d's flag has not fallen", player)
+	}
+	c.reserve[player] = c.config.Reserve
+	c.flagged = -1
+	return nil
+}
+
+// Remaining returns the reserve player has left, counting the move they are
+// making. During a Bronstein delay this is the reserve before the time the
+// move took is given back.
+func (c *Clock) Remaining(player int) time.Duration {
+	reserve := c.reserve[player]
+	if player == c.running {
+		charged := c.now().Sub(c.started)
+		if c.config.DelayMode == DelaySimple {
+			charged -= c.config.Delay
+		}
+		if charged > 0 {
+			reserve -= charged
+		}
+	}
+	return max(reserve, 0)
+}
+
+// Flagged returns the player whose flag has fallen, or -1. A running player
+// whose reserve is exhausted counts as flagged before Stop is called.
+func (c *Clock) Flagged() int {
+	if c.flagged < 0 && c.running >= 0 && c.Remaining(c.running) == 0 {
+		return c.running
+	}
+	return c.flagged
+}
+
+// Penalty returns what the flagged player loses.
+func (c *Clock) Penalty() FlagPenalty {
+	return c.config.Penalty
+}
+
+// State returns a snapshot of the clock.
+func (c *Clock) State() ClockState {
+	return ClockState{
+		Remaining: [2]time.Duration{c.Remaining(0), c.Remaining(1)},
+		Running:   c.running,
+		Flagged:   c.Flagged(),
+	}
+}
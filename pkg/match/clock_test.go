@@ -0,0 +1,139 @@
+package match
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeTime is a time source the test advances by hand.
+type fakeTime struct{ t time.Time }
+
+func (f *fakeTime) now() time.Time        { return f.t }
+func (f *fakeTime) sleep(d time.Duration) { f.t = f.t.Add(d) }
+
+func TestClock(t *testing.T) {
+	ft := &fakeTime{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c, err := NewClock(ClockConfig{
+		Reserve:   time.Minute,
+		Delay:     10 * time.Second,
+		Increment: 2 * time.Second,
+		Penalty:   FlagLosesGame,
+	}, ft.now)
+	if err != nil {
+		t.Fatalf("NewClock: %v", err)
+	}
+
+	// A move within the delay costs nothing and earns the increment
+	c.Start(0)
+	ft.sleep(8 * time.Second)
+	if got := c.Remaining(0); got != time.Minute {
+		t.Errorf("remaining within the delay = %v, want 1m", got)
+	}
+	if err := c.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if got := c.Remaining(0); got != 62*time.Second {
+		t.Errorf("remaining after a quick move = %v, want 1m2s", got)
+	}
+
+	// A slow move is charged beyond the delay
+	c.Start(1)
+	ft.sleep(40 * time.Second)
+	if s := c.State(); s.Running != 1 || s.Remaining != [2]time.Duration{62 * time.Second, 30 * time.Second} || s.Flagged != -1 {
+		t.Errorf("state during a slow move = %+v", s)
+	}
+	c.Stop()
+	if got := c.Remaining(1); got != 32*time.Second {
+		t.Errorf("remaining after a slow move = %v, want 32s", got)
+	}
+	if err := c.Start(1); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if err := c.Start(0); err == nil {
+		t.Error("Start accepted a second running player")
+	}
+
+	// The flag falls once the reserve and delay are used up
+	ft.sleep(42 * time.Second)
+	if c.Flagged() != 1 || c.Remaining(1) != 0 {
+		t.Errorf("flagged = %d with %v left, want player 1 with none", c.Flagged(), c.Remaining(1))
+	}
+	if err := c.Stop(); !errors.Is(err, ErrFlagFell) {
+		t.Fatalf("Stop after the flag fell: %v, want ErrFlagFell", err)
+	}
+	if s := c.State(); s.Running != -1 || s.Flagged != 1 || s.Remaining[1] != 0 {
+		t.Errorf("state after the flag fell = %+v", s)
+	}
+	if c.Penalty() != FlagLosesGame {
+		t.Errorf("penalty = %v, want FlagLosesGame", c.Penalty())
+	}
+	if err := c.Start(0); err == nil {
+		t.Error("Start accepted a move after a flag fell")
+	}
+}
+
+func TestClockConfigValidate(t *testing.T) {
+	for _, config := range []ClockConfig{
+		{},
+		{Reserve: time.Minute, Delay: -time.Second},
+		{Reserve: time.Minute, Penalty: 7},
+		{Reserve: time.Minute, DelayMode: 2},
+	} {
+		if _, err := NewClock(config, nil); err == nil {
+			t.Errorf("NewClock(%+v) accepted an invalid config", config)
+		}
+	}
+}
+
+func TestClockBronsteinDelay(t *testing.T) {
+	ft := &fakeTime{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c, err := NewClock(ClockConfig{
+		Reserve:   time.Minute,
+		Delay:     10 * time.Second,
+		DelayMode: DelayBronstein,
+		Penalty:   FlagLosesGame,
+	}, ft.now)
+	if err != nil {
+		t.Fatalf("NewClock: %v", err)
+	}
+
+	// The reserve runs at once, and a move within the delay gets it all back
+	c.Start(0)
+	ft.sleep(8 * time.Second)
+	if got := c.Remaining(0); got != 52*time.Second {
+		t.Errorf("remaining during the delay = %v, want 52s", got)
+	}
+	c.Stop()
+	if got := c.Remaining(0); got != time.Minute {
+		t.Errorf("remaining after a quick move = %v, want 1m", got)
+	}
+
+	// A slow move gets back the delay only
+	c.Start(0)
+	ft.sleep(25 * time.Second)
+	c.Stop()
+	if got := c.Remaining(0); got != 45*time.Second {
+		t.Errorf("remaining after a slow move = %v, want 45s", got)
+	}
+
+	// Unlike a simple delay, the delay does not hold off the flag
+	c.Start(0)
+	ft.sleep(46 * time.Second)
+	if err := c.Stop(); !errors.Is(err, ErrFlagFell) {
+		t.Fatalf("Stop after the reserve ran out: %v, want ErrFlagFell", err)
+	}
+
+	if err := c.Refill(1); err == nil {
+		t.Error("Refill accepted a player whose flag has not fallen")
+	}
+	if err := c.Refill(0); err != nil {
+		t.Fatalf("Refill: %v", err)
+	}
+	if s := c.State(); s.Flagged != -1 || s.Remaining[0] != time.Minute {
+		t.Errorf("state after Refill = %+v", s)
+	}
+	if err := c.Start(0); err != nil {
+		t.Errorf("Start after Refill: %v", err)
+	}
+}
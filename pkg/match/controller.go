@@ -0,0 +1,354 @@
+package match
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/yourusername/bgengine/pkg/engine"
+)
+
+// ErrInvalidAction is wrapped by the errors of a Controller decision that
+// the player may not take now, such as a roll out of turn or a double in the
+// Crawford game.
+var ErrInvalidAction = errors.New("invalid action")
+
+// Phase is the decision a Controller waits for.
+type Phase int
+
+const (
+	PhaseRoll Phase = iota // The player on roll doubles or rolls
+	PhaseTake              // The opponent takes or passes a double
+	PhaseMove              // The player on roll plays the dice
+	PhaseOver              // The match is over
+)
+
+// String returns the phase's name, such as "roll" or "over".
+func (p Phase) String() string {
+	switch p {
+	case PhaseRoll:
+		return "roll"
+	case PhaseTake:
+		return "take"
+	case PhaseMove:
+		return "move"
+	case PhaseOver:
+		return "over"
+	default:
+		return "unknown"
+	}
+}
+
+// ControllerConfig configures a timed match.
+type ControllerConfig struct {
+	Player1     string        // Name of player 1 (player 0 of the clock)
+	Player2     string        // Name of player 2
+	MatchLength int           // Match length (0 = money session, which a flag fall alone ends)
+	NoCrawford  bool          // Play the match without the Crawford rule
+	Clock       ClockConfig   // Match clock
+	Dice        func() [2]int // Dice source (nil = random)
+}
+
+// ControllerState is a snapshot of a Controller, for session state updates.
+type ControllerState struct {
+	Phase     Phase
+	Turn      int          // Player on roll
+	Decider   int          // Player the controller waits for (-1 once the match is over)
+	Board     engine.Board // Board of the player on roll
+	Dice      [2]int       // Roll to play in PhaseMove, or the last roll
+	CubeValue int
+	CubeOwner int // -1 = centered
+	Score     [2]int
+	Game      int  // Game number, from 1
+	Crawford  bool // The game is the Crawford game
+	Winner    int  // Winner of the match (-1 = not over)
+	Clock     ClockState
+}
+
+// Controller runs a timed match between two players: it takes their
+// decisions in turn, rolls the dice, records the games in a Match and keeps
+// the score. Each decision is stamped on the match clock: the player the
+// controller waits for is on the clock until they decide, and a player
+// whose flag falls loses the game or the match by the clock's FlagPenalty.
+// Players are indexed as in Clock. A Controller is not safe for concurrent
+// use.
+type Controller struct {
+	match *Match
+	clock *Clock
+	dice  func() [2]int
+
+	game     *Game
+	board    engine.Board
+	turn     int
+	phase    Phase
+	roll     [2]int
+	cube     int
+	owner    int
+	score    [2]int
+	crawford bool // The Crawford game has been played
+	winner   int
+}
+
+// NewController starts a timed match: the opening roll is made and the
+// player who won it is on the clock to play it. now is the time source of
+// the clock (nil for time.Now).
+func NewController(config ControllerConfig, now func() time.Time) (*Controller, error) {
+	if config.MatchLength < 0 {
+		return nil, fmt.Errorf("invalid match length %d", config.MatchLength)
+	}
+	clock, err := NewClock(config.Clock, now)
+	if err != nil {
+		return nil, err
+	}
+	dice := config.Dice
+	if dice == nil {
+		dice = func() [2]int { return [2]int{rand.IntN(6) + 1, rand.IntN(6) + 1} }
+	}
+	m := NewMatch(config.Player1, config.Player2, config.MatchLength)
+	m.NoCrawford = config.NoCrawford
+	c := &Controller{match: m, clock: clock, dice: dice, winner: -1}
+	c.startGame()
+	return c, nil
+}
+
+// Match returns the record of the match so far.
+func (c *Controller) Match() *Match {
+	return c.match
+}
+
+// State returns a snapshot of the match. A flag that has fallen since the
+// last decision is settled first, so the state shows the game or match it
+// cost.
+func (c *Controller) State() ControllerState {
+	c.settleFlag()
+	decider := c.decider()
+	if c.phase == PhaseOver {
+		decider = -1
+	}
+	return ControllerState{
+		Phase:     c.phase,
+		Turn:      c.turn,
+		Decider:   decider,
+		Board:     c.board,
+		Dice:      c.roll,
+		CubeValue: c.cube,
+		CubeOwner: c.owner,
+		Score:     c.score,
+		Game:      c.game.Number,
+		Crawford:  c.game.Crawford,
+		Winner:    c.winner,
+		Clock:     c.clock.State(),
+	}
+}
+
+// Roll rolls the dice for player, who is on roll and has not doubled. A
+// roll that cannot be played passes the turn.
+func (c *Controller) Roll(player int) error {
+	if err := c.check(player, PhaseRoll); err != nil {
+		return err
+	}
+	c.roll = c.dice()
+	c.game.AddRoll(player, c.roll[0], c.roll[1])
+	if len(engine.GenerateMoves(c.board, c.roll[0], c.roll[1]).Moves) > 0 {
+		c.phase = PhaseMove
+		return nil
+	}
+	if err := c.stamp(); err != nil {
+		return err
+	}
+	c.passTurn(engine.Move{From: [4]int8{-1, -1, -1, -1}, To: [4]int8{-1, -1, -1, -1}})
+	return nil
+}
+
+// Play plays m, in the engine's points of player (see engine.Point), for
+// player, who is on roll. An illegal play is refused with the
+// *engine.MoveError of engine.ValidateMove, and player stays on the clock.
+func (c *Controller) Play(player int, m engine.Move) error {
+	if err := c.check(player, PhaseMove); err != nil {
+		return err
+	}
+	after, err := engine.ApplyMoveChecked(c.board, c.roll, m)
+	if err != nil {
+		return err
+	}
+	if err := c.stamp(); err != nil {
+		return err
+	}
+	c.game.AddMove(player, matchMove(m, player))
+	if side, points, ok := engine.GameResult(after); ok && side == 1 {
+		result := []GameResult{ResultSingle, ResultGammon, ResultBackgammon}[points-1]
+		c.endGame(player, result, c.game.PointsWon(result))
+		return nil
+	}
+	c.passTurn(m)
+	return nil
+}
+
+// Double offers a double for player, who is on roll, has not rolled and may
+// turn the cube. The opponent goes on the clock to take or pass.
+func (c *Controller) Double(player int) error {
+	if err := c.check(player, PhaseRoll); err != nil {
+		return err
+	}
+	switch {
+	case c.game.Crawford:
+		return fmt.Errorf("%w: no doubling in the Crawford game", ErrInvalidAction)
+	case c.owner >= 0 && c.owner != player:
+		return fmt.Errorf("%w: player %d owns the cube", ErrInvalidAction, c.owner)
+	}
+	if err := c.stamp(); err != nil {
+		return err
+	}
+	c.game.AddDouble(player, 2*c.cube)
+	c.phase = PhaseTake
+	return c.clock.Start(1 - player)
+}
+
+// Take accepts the double offered to player, who then owns the cube. The
+// doubler goes back on the clock to roll.
+func (c *Controller) Take(player int) error {
+	if err := c.check(player, PhaseTake); err != nil {
+		return err
+	}
+	if err := c.stamp(); err != nil {
+		return err
+	}
+	c.game.AddTake(player)
+	c.cube *= 2
+	c.owner = player
+	c.phase = PhaseRoll
+	return c.clock.Start(c.turn)
+}
+
+// Pass declines the double offered to player, who loses the game at the
+// cube before it.
+func (c *Controller) Pass(player int) error {
+	if err := c.check(player, PhaseTake); err != nil {
+		return err
+	}
+	if err := c.stamp(); err != nil {
+		return err
+	}
+	c.game.AddPass(player)
+	c.endGame(c.turn, ResultDrop, c.game.PointsWon(ResultDrop))
+	return nil
+}
+
+// decider returns the player the controller waits for.
+func (c *Controller) decider() int {
+	if c.phase == PhaseTake {
+		return 1 - c.turn
+	}
+	return c.turn
+}
+
+// check returns an error if player may not decide now, in phase: ErrFlagFell
+// if a flag has fallen since the last decision, or else ErrInvalidAction.
+func (c *Controller) check(player int, phase Phase) error {
+	if c.settleFlag() {
+		return ErrFlagFell
+	}
+	switch {
+	case c.phase == PhaseOver:
+		return fmt.Errorf("%w: the match is over", ErrInvalidAction)
+	case player != c.decider():
+		return fmt.Errorf("%w: player %d is not to decide", ErrInvalidAction, player)
+	case c.phase != phase:
+		return fmt.Errorf("%w: waiting for player %d to %s", ErrInvalidAction, player, c.phase)
+	}
+	return nil
+}
+
+// stamp stops the clock on the decision just made. A flag that fell during
+// the decision is settled, and the decision refused with ErrFlagFell.
+func (c *Controller) stamp() error {
+	if err := c.clock.Stop(); err != nil {
+		c.settleFlag()
+		return err
+	}
+	return nil
+}
+
+// passTurn hands the dice to the opponent after m, and puts them on the
+// clock.
+func (c *Controller) passTurn(m engine.Move) {
+	c.board = engine.ResultingBoard(c.board, m)
+	c.turn = 1 - c.turn
+	c.phase = PhaseRoll
+	c.clock.Start(c.turn)
+}
+
+// settleFlag applies the clock's penalty to a player whose flag has fallen:
+// with FlagLosesMatch the opponent wins the match, with FlagLosesGame the
+// game at the cube, and the player gets a full reserve for the next game. It
+// reports whether a flag fell.
+func (c *Controller) settleFlag() bool {
+	loser := c.clock.Flagged()
+	if loser < 0 || c.phase == PhaseOver {
+		return false
+	}
+	if c.clock.running >= 0 {
+		c.clock.Stop()
+	}
+	winner := 1 - loser
+	points := c.game.PointsWon(ResultTime)
+	if c.clock.Penalty() == FlagLosesMatch {
+		if c.match.MatchLength > 0 {
+			points = max(c.match.MatchLength-c.score[winner], points)
+		}
+		c.scoreGame(winner, ResultTime, points)
+		c.phase, c.winner = PhaseOver, winner
+		return true
+	}
+	c.clock.Refill(loser)
+	c.endGame(winner, ResultTime, points)
+	return true
+}
+
+// endGame scores the game for winner and starts the next one, unless the
+// match is over.
+func (c *Controller) endGame(winner int, result GameResult, points int) {
+	if c.scoreGame(winner, result, points) {
+		c.phase, c.winner = PhaseOver, winner
+		return
+	}
+	c.startGame()
+}
+
+// scoreGame records how the game ended and adds the points to the score.
+// It reports whether winner has won the match.
+func (c *Controller) scoreGame(winner int, result GameResult, points int) bool {
+	c.game.Winner, c.game.Result, c.game.Points = winner, result, points
+	c.score[winner] += points
+	if c.game.Crawford {
+		c.crawford = true
+	}
+	return c.match.MatchLength > 0 && c.score[winner] >= c.match.MatchLength
+}
+
+// startGame starts the next game with the opening roll, which the player
+// with the higher die plays.
+func (c *Controller) startGame() {
+	length := c.match.MatchLength
+	crawford := length > 0 && !c.match.NoCrawford && !c.crawford &&
+		(c.score[0] == length-1 || c.score[1] == length-1)
+	c.game = NewGame(len(c.match.Games)+1, c.score[0], c.score[1], crawford)
+	c.game.PostCrawford = c.crawford
+	c.match.Games = append(c.match.Games, c.game)
+
+	roll := c.dice()
+	for roll[0] == roll[1] {
+		roll = c.dice()
+	}
+	c.turn = 0
+	if roll[1] > roll[0] {
+		c.turn = 1
+	}
+	c.roll = roll
+	c.game.AddRoll(c.turn, roll[0], roll[1])
+	c.board = c.game.InitialBoard
+	c.cube, c.owner = 1, -1
+	c.phase = PhaseMove
+	c.clock.Start(c.turn)
+}
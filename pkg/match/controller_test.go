@@ -0,0 +1,175 @@
+package match
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/yourusername/bgengine/pkg/engine"
+)
+
+// scriptedDice returns a dice source that rolls rolls in turn, over again.
+func scriptedDice(rolls ...[2]int) func() [2]int {
+	i := 0
+	return func() [2]int {
+		r := rolls[i%len(rolls)]
+		i++
+		return r
+	}
+}
+
+// newTestController starts a match on ft's clock with dice that open 3-1
+// for player 0 and roll rolls after that.
+func newTestController(t *testing.T, ft *fakeTime, length int, penalty FlagPenalty, rolls ...[2]int) *Controller {
+	t.Helper()
+	c, err := NewController(ControllerConfig{
+		Player1:     "X",
+		Player2:     "O",
+		MatchLength: length,
+		Clock: ClockConfig{
+			Reserve: time.Minute,
+			Delay:   10 * time.Second,
+			Penalty: penalty,
+		},
+		Dice: scriptedDice(append([][2]int{{3, 1}}, rolls...)...),
+	}, ft.now)
+	if err != nil {
+		t.Fatalf("NewController: %v", err)
+	}
+	return c
+}
+
+func TestControllerStampsDecisions(t *testing.T) {
+	ft := &fakeTime{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c := newTestController(t, ft, 5, FlagLosesGame, [2]int{6, 5})
+
+	s := c.State()
+	if s.Phase != PhaseMove || s.Turn != 0 || s.Dice != [2]int{3, 1} || s.Clock.Running != 0 {
+		t.Fatalf("state after the opening roll = %+v", s)
+	}
+
+	// A slow move is charged to the mover, and the opponent goes on the clock
+	ft.sleep(30 * time.Second)
+	m, _ := engine.ParseMove("8/5 6/5")
+	if err := c.Play(0, m); err != nil {
+		t.Fatalf("Play: %v", err)
+	}
+	s = c.State()
+	if s.Phase != PhaseRoll || s.Turn != 1 || s.Clock.Running != 1 || s.Clock.Remaining[0] != 40*time.Second {
+		t.Errorf("state after a slow move = %+v", s)
+	}
+	if err := c.Roll(0); !errors.Is(err, ErrInvalidAction) {
+		t.Errorf("Roll out of turn: %v, want ErrInvalidAction", err)
+	}
+
+	// The taker is on the clock for the take, the doubler again to roll
+	ft.sleep(5 * time.Second)
+	if err := c.Double(1); err != nil {
+		t.Fatalf("Double: %v", err)
+	}
+	if s := c.State(); s.Phase != PhaseTake || s.Decider != 0 || s.Clock.Running != 0 {
+		t.Errorf("state after a double = %+v", s)
+	}
+	ft.sleep(time.Second)
+	if err := c.Take(0); err != nil {
+		t.Fatalf("Take: %v", err)
+	}
+	s = c.State()
+	if s.Phase != PhaseRoll || s.Decider != 1 || s.CubeValue != 2 || s.CubeOwner != 0 || s.Clock.Running != 1 {
+		t.Errorf("state after a take = %+v", s)
+	}
+	if err := c.Double(1); !errors.Is(err, ErrInvalidAction) {
+		t.Errorf("Double without the cube: %v, want ErrInvalidAction", err)
+	}
+
+	// A decision after the flag fell is refused, and costs the game at the
+	// cube; the flagged player starts the next game with a full reserve
+	ft.sleep(71 * time.Second)
+	if err := c.Roll(1); !errors.Is(err, ErrFlagFell) {
+		t.Fatalf("Roll after the flag fell: %v, want ErrFlagFell", err)
+	}
+	s = c.State()
+	if s.Score != [2]int{2, 0} || s.Game != 2 || s.Phase != PhaseMove || s.Winner != -1 {
+		t.Errorf("state after the flag fell = %+v", s)
+	}
+	if s.Clock.Flagged != -1 || s.Clock.Remaining != [2]time.Duration{40 * time.Second, time.Minute} {
+		t.Errorf("clock after the flag fell = %+v", s.Clock)
+	}
+	g := c.Match().Games[0]
+	if g.Winner != 0 || g.Result != ResultTime || g.Points != 2 {
+		t.Errorf("game 1 = winner %d, %v, %d points; want 0, time, 2", g.Winner, g.Result, g.Points)
+	}
+	var types []ActionType
+	for _, a := range g.Actions {
+		types = append(types, a.Type)
+	}
+	if want := []ActionType{ActionRoll, ActionMove, ActionDouble, ActionTake}; !slices.Equal(types, want) {
+		t.Errorf("game 1 actions = %v, want %v", types, want)
+	}
+}
+
+func TestControllerFlagLosesMatch(t *testing.T) {
+	ft := &fakeTime{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c := newTestController(t, ft, 5, FlagLosesMatch)
+
+	// The flag falls on the state update, with no decision to refuse
+	ft.sleep(71 * time.Second)
+	s := c.State()
+	if s.Phase != PhaseOver || s.Winner != 1 || s.Score != [2]int{0, 5} || s.Decider != -1 {
+		t.Errorf("state after the flag fell = %+v", s)
+	}
+	if s.Clock.Flagged != 0 || s.Clock.Running != -1 {
+		t.Errorf("clock after the flag fell = %+v", s.Clock)
+	}
+	m, _ := engine.ParseMove("8/5 6/5")
+	if err := c.Play(0, m); !errors.Is(err, ErrInvalidAction) {
+		t.Errorf("Play after the match: %v, want ErrInvalidAction", err)
+	}
+}
+
+func TestControllerCrawford(t *testing.T) {
+	ft := &fakeTime{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c := newTestController(t, ft, 2, FlagLosesMatch, [2]int{3, 1}, [2]int{6, 5})
+
+	m, _ := engine.ParseMove("8/5 6/5")
+	if err := c.Play(0, m); err != nil {
+		t.Fatalf("Play: %v", err)
+	}
+	illegal, _ := engine.ParseMove("13/7 13/7")
+	if err := c.Double(1); err != nil {
+		t.Fatalf("Double: %v", err)
+	}
+	if err := c.Play(1, illegal); !errors.Is(err, ErrInvalidAction) {
+		t.Errorf("Play while a double is offered: %v, want ErrInvalidAction", err)
+	}
+	if err := c.Pass(0); err != nil {
+		t.Fatalf("Pass: %v", err)
+	}
+
+	s := c.State()
+	if s.Score != [2]int{0, 1} || s.Game != 2 || !s.Crawford {
+		t.Fatalf("state after the pass = %+v, want the Crawford game at 0-1", s)
+	}
+	if g := c.Match().Games[0]; g.Result != ResultDrop || g.Points != 1 {
+		t.Errorf("game 1 = %v for %d points, want drop for 1", g.Result, g.Points)
+	}
+
+	// The opening roll 3-1 is played, then 6-5 may not be played as 13/7(2)
+	if err := c.Play(0, m); err != nil {
+		t.Fatalf("Play: %v", err)
+	}
+	if err := c.Double(1); !errors.Is(err, ErrInvalidAction) {
+		t.Errorf("Double in the Crawford game: %v, want ErrInvalidAction", err)
+	}
+	if err := c.Roll(1); err != nil {
+		t.Fatalf("Roll: %v", err)
+	}
+	var moveErr *engine.MoveError
+	if err := c.Play(1, illegal); !errors.As(err, &moveErr) {
+		t.Errorf("illegal Play: %v, want a *engine.MoveError", err)
+	}
+	if s := c.State(); s.Phase != PhaseMove || s.Clock.Running != 1 {
+		t.Errorf("state after an illegal play = %+v", s)
+	}
+}
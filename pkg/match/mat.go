@@ -23,13 +23,45 @@ import (
 //  name1 : 0            name2 : 0
 //  1) 31: 8/5 6/5       52: 24/22 13/8
 //  2) 43: 24/20 13/10   ...
+//
+// The opening roll is written in the column of the player who won it, who
+// plays both dice; when that is player 2 the first column of move 1 is left
+// empty:
+//
+//  1)                   52: 13/11 13/8
+//  2) 31: 8/5 6/5       ...
+//
+// Money sessions played with automatic doubles may start a game with
+// "Automatic double" lines, one for each equal opening roll, before the
+// first move. The end of a game is written "Wins 2 points" in the winner's
+// column.
+//
+// gnubg writes whether the Crawford rule is played as a
+// ; [Crawford "On"] or ; [Crawford "Off"] tag, and some programs mark the
+// Crawford game's header "Game 5 (Crawford)". Without a marker, the Crawford
+// game is the first to start with a player one point from victory.
+//
+// The header tags the sites write differ:
+//
+//  ; [Player 1 Elo "1712.85/2345"]   gnubg, GamesGrid: rating/experience
+//  ; [Player 1 Rating "1875"]        FIBS clients, Heroes, Galaxy
+//  ; [EventDate "2003.06.14"]        gnubg, GamesGrid, with [EventTime "19.01"]
+//  ; [Date "Sat Jun 14 19:01:22 2003"] other programs, in any of dateLayouts
+//  ; [Match ID "80221337"]           GamesGrid, Galaxy
+//
+// Tags the importer does not interpret, such as [CubeLimit "64"] or a site's
+// game clock, are kept in Match.Tags.
 
 var (
 	matchLengthRE = regexp.MustCompile(`(\d+)\s+point\s+match`)
 	gameHeaderRE  = regexp.MustCompile(`Game\s+(\d+)`)
 	scoreLineRE   = regexp.MustCompile(`^(.+?)\s*:\s*(\d+)\s+(.+?)\s*:\s*(\d+)`)
 	moveLineRE    = regexp.MustCompile(`^\s*(\d+)\)`)
-	tagRE         = regexp.MustCompile(`\[(\w+)\s+"([^"]+)"\]`)
+	tagRE         = regexp.MustCompile(`\[([\w .-]+?)\s+"([^"]*)"\]`)
+	ratingTagRE   = regexp.MustCompile(`^player ?([12]) (?:elo|rating)$`)
+	variationRE   = regexp.MustCompile(`(?i)^;?\s*Variation\s*:\s*(\w+)`)
+	autoDoubleRE  = regexp.MustCompile(`(?i)^automatic\s+doubles?\b`)
+	winsRE        = regexp.MustCompile(`(?i)^wins\s+(\d+)\s+points?`)
 )
 
 // ImportMAT reads a match from MAT format.
@@ -41,9 +73,12 @@ func ImportMAT(r io.Reader) (*Match, error) {
 
 	var currentGame *Game
 	inGame := false
+	column2 := 0       // Column of player 2's moves, from the score line
+	var eventTime *Tag // gnubg's EventTime tag, applied to the date at the end
 
 	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
+		raw := scanner.Text()
+		line := strings.TrimSpace(raw)
 
 		// Skip empty lines
 		if line == "" {
@@ -53,7 +88,7 @@ func ImportMAT(r io.Reader) (*Match, error) {
 		// Parse metadata comments
 		if strings.HasPrefix(line, ";") {
 			if m := tagRE.FindStringSubmatch(line); m != nil {
-				key := strings.ToLower(m[1])
+				key := strings.ToLower(strings.Join(strings.Fields(m[1]), " "))
 				value := m[2]
 				switch key {
 				case "player1", "player 1":
@@ -64,15 +99,52 @@ func ImportMAT(r io.Reader) (*Match, error) {
 					match.Place = value
 				case "event":
 					match.Event = value
-				case "date":
-					match.Date = value
+				case "match id", "matchid":
+					match.EventInfo = value
+				case "round":
+					match.Round = value
+				case "date", "eventdate", "event date":
+					match.SetDate(value)
+				case "eventtime", "event time":
+					eventTime = &Tag{Name: m[1], Value: value}
 				case "annotator", "transcriber":
 					match.Annotator = value
+				case "comment":
+					match.Comment = value
+				case "variation":
+					if err := setVariant(match, value); err != nil {
+						return nil, err
+					}
+				case "crawford":
+					switch strings.ToLower(value) {
+					case "off", "false", "no":
+						match.NoCrawford = true
+					}
+				default:
+					if r := ratingTagRE.FindStringSubmatch(key); r != nil {
+						if rating, ok := parseRating(value); ok {
+							match.PlayerRatings[r[1][0]-'1'] = rating
+							break
+						}
+					}
+					match.Tags = append(match.Tags, Tag{Name: m[1], Value: value})
+				}
+			} else if m := variationRE.FindStringSubmatch(line); m != nil {
+				if err := setVariant(match, m[1]); err != nil {
+					return nil, err
 				}
 			}
 			continue
 		}
 
+		// gnubg also writes the variant as a plain "Variation: NackGammon" line
+		if m := variationRE.FindStringSubmatch(line); m != nil {
+			if err := setVariant(match, m[1]); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
 		// Parse match length
 		if m := matchLengthRE.FindStringSubmatch(line); m != nil {
 			match.MatchLength, _ = strconv.Atoi(m[1])
@@ -87,12 +159,14 @@ func ImportMAT(r io.Reader) (*Match, error) {
 			}
 			gameNum, _ := strconv.Atoi(m[1])
 			currentGame = &Game{
-				Number:    gameNum,
-				Actions:   make([]Action, 0),
-				CubeValue: 1,
-				CubeOwner: -1,
-				Winner:    -1,
-				Result:    ResultInProgress,
+				Number:       gameNum,
+				InitialBoard: engine.StartingPositionVariant(match.Variant).Board,
+				Actions:      make([]Action, 0),
+				CubeValue:    1,
+				CubeOwner:    -1,
+				Winner:       -1,
+				Result:       ResultInProgress,
+				Crawford:     strings.Contains(strings.ToLower(line), "crawford"),
 			}
 			inGame = true
 			continue
@@ -100,6 +174,9 @@ func ImportMAT(r io.Reader) (*Match, error) {
 
 		// Parse score line (name : score   name : score)
 		if inGame && currentGame != nil {
+			if m := scoreLineRE.FindStringSubmatchIndex(raw); m != nil {
+				column2 = m[6]
+			}
 			if m := scoreLineRE.FindStringSubmatch(line); m != nil {
 				if match.Player1 == "" {
 					match.Player1 = strings.TrimSpace(m[1])
@@ -115,7 +192,18 @@ func ImportMAT(r io.Reader) (*Match, error) {
 
 		// Parse move lines
 		if inGame && currentGame != nil && moveLineRE.MatchString(line) {
-			parseMoveLineMAT(line, currentGame)
+			parseMoveLineMAT(raw, column2, currentGame)
+			continue
+		}
+
+		// Automatic doubles and the result have lines of their own, in the
+		// column of the player they concern
+		if inGame && currentGame != nil && (autoDoubleRE.MatchString(line) || winsRE.MatchString(line)) {
+			player := 0
+			if secondColumn(0, len(raw)-len(strings.TrimLeft(raw, " \t")), column2) {
+				player = 1
+			}
+			parsePlayerMoveMAT(line, player, currentGame)
 		}
 	}
 
@@ -128,31 +216,150 @@ func ImportMAT(r io.Reader) (*Match, error) {
 		return nil, fmt.Errorf("reading MAT file: %w", err)
 	}
 
+	// The time of day goes with the date; without a date it is kept as it is
+	if eventTime != nil {
+		if t, ok := withTimeOfDay(match.Date, eventTime.Value); ok && !match.Date.IsZero() {
+			match.Date = t
+		} else {
+			match.Tags = append(match.Tags, *eventTime)
+		}
+	}
+
+	markCrawford(match)
+	checkResults(match)
 	return match, nil
 }
 
+// checkResults completes and cross-checks the results of match's games. A
+// game that ends by bearing off without a "Wins" line takes its winner and
+// points from the final position; a "Wins" line the final position
+// contradicts, and in match play a score line that does not follow from the
+// game before it, add a warning to match.
+func checkResults(match *Match) {
+	for i, game := range match.Games {
+		if winner, result, ok := game.boardResult(); ok {
+			points := game.PointsWon(result)
+			switch {
+			case game.Winner < 0:
+				game.Winner, game.Result, game.Points = winner, result, points
+			case game.Result == ResultDrop:
+			case game.Winner != winner:
+				match.Warnings = append(match.Warnings, fmt.Sprintf(
+					"game %d: recorded as won by player %d, but player %d bears off", game.Number, game.Winner+1, winner+1))
+			case game.Points != points && !(match.MatchLength == 0 && game.Points == game.PointsWon(ResultSingle)):
+				// Money sessions played with the Jacoby rule score an
+				// undoubled gammon as a single game
+				match.Warnings = append(match.Warnings, fmt.Sprintf(
+					"game %d: recorded as %d points, but the final position is a %s for %d", game.Number, game.Points, result, points))
+			}
+		}
+
+		// Money sessions do not keep a running score, and a game without a
+		// score line starts at 0-0
+		if match.MatchLength == 0 || i+1 == len(match.Games) || game.Winner < 0 {
+			continue
+		}
+		next := match.Games[i+1]
+		if next.Score1 == 0 && next.Score2 == 0 {
+			continue
+		}
+		score := [2]int{game.Score1, game.Score2}
+		score[game.Winner] += game.Points
+		if score != [2]int{next.Score1, next.Score2} {
+			match.Warnings = append(match.Warnings, fmt.Sprintf(
+				"game %d: starts at %d-%d, but game %d ends at %d-%d",
+				next.Number, next.Score1, next.Score2, game.Number, score[0], score[1]))
+		}
+	}
+}
+
+// markCrawford marks the Crawford game of match and the games after it. A
+// game marked Crawford in the file is the Crawford game; otherwise it is the
+// first game to start with either player one point from victory. Money
+// sessions and matches played without the Crawford rule have none.
+func markCrawford(match *Match) {
+	if match.MatchLength == 0 || match.NoCrawford {
+		for _, game := range match.Games {
+			game.Crawford = false
+		}
+		return
+	}
+	crawford := -1
+	for i, game := range match.Games {
+		if game.Crawford {
+			crawford = i
+			break
+		}
+	}
+	if crawford < 0 {
+		for i, game := range match.Games {
+			if game.Score1 == match.MatchLength-1 || game.Score2 == match.MatchLength-1 {
+				crawford = i
+				break
+			}
+		}
+	}
+	if crawford < 0 {
+		return
+	}
+	for i, game := range match.Games {
+		game.Crawford = i == crawford
+		game.PostCrawford = i > crawford
+	}
+}
+
+// setVariant sets the match variant from a MAT "Variation" header.
+func setVariant(match *Match, name string) error {
+	v, err := engine.ParseVariant(name)
+	if err != nil {
+		return fmt.Errorf("unsupported MAT variation: %w", err)
+	}
+	match.Variant = v
+	return nil
+}
+
 // parseMoveLineMAT parses a single move line in MAT format.
 // Format: "1) 31: 8/5 6/5       52: 24/22 13/8"
-func parseMoveLineMAT(line string, game *Game) {
+// column2 is the column of player 2's moves, or 0 if it is not known; a line
+// with only one entry starting nearer to it than to the move number is
+// player 2's.
+func parseMoveLineMAT(line string, column2 int, game *Game) {
 	// Remove the move number prefix
-	parts := strings.SplitN(line, ")", 2)
-	if len(parts) < 2 {
+	paren := strings.Index(line, ")")
+	if paren == -1 {
 		return
 	}
-	line = strings.TrimSpace(parts[1])
+	rest := line[paren+1:]
+	start := paren + 1 + len(rest) - len(strings.TrimLeft(rest, " \t"))
+	line = strings.TrimSpace(rest)
 
 	// Split into player 1 and player 2 portions
 	// This is tricky because whitespace separates them
 	// Look for pattern like "   " (multiple spaces) as separator
 	halves := regexp.MustCompile(`\s{3,}`).Split(line, 2)
 
-	for playerIdx, half := range halves {
-		parsePlayerMoveMAT(strings.TrimSpace(half), playerIdx, game)
+	first := 0
+	if len(halves) == 1 && secondColumn(paren+1, start, column2) {
+		first = 1
+	}
+	for i, half := range halves {
+		parsePlayerMoveMAT(strings.TrimSpace(half), first+i, game)
 	}
 }
 
+// secondColumn reports whether a lone entry of a move line, starting at
+// column start after the move number ending at column number, is in player
+// 2's column, which starts at column2. Without a score line to place the
+// column, an entry more than 8 spaces from the move number is player 2's.
+func secondColumn(number, start, column2 int) bool {
+	if column2 <= number {
+		return start-number > 8
+	}
+	return start-number > column2-start
+}
+
 // parsePlayerMoveMAT parses a single player's roll and move.
-// Format: "31: 8/5 6/5" or "Doubles => 2" or "Takes" or "Drops"
+// Format: "31: 8/5 6/5" or "Doubles => 2" or "Takes" or "Beavers => 4" or "Drops"
 func parsePlayerMoveMAT(text string, player int, game *Game) {
 	if text == "" {
 		return
@@ -173,10 +380,39 @@ func parsePlayerMoveMAT(text string, player int, game *Game) {
 		game.CubeOwner = player
 		return
 	}
+	if strings.HasPrefix(lowerText, "beavers") {
+		// Parse "Beavers => 4": take and redouble, keeping the cube
+		game.AddBeaver(player, game.CubeValue*4)
+		game.CubeValue *= 4
+		game.CubeOwner = player
+		return
+	}
+	if strings.HasPrefix(lowerText, "raccoons") {
+		// Parse "Raccoons => 8": redouble the beaver, the cube stays put
+		game.AddRaccoon(player, game.CubeValue*2)
+		game.CubeValue *= 2
+		return
+	}
 	if lowerText == "drops" || lowerText == "passes" {
 		game.AddPass(player)
 		game.Winner = 1 - player
 		game.Result = ResultDrop
+		game.Points = game.PointsWon(ResultDrop)
+		return
+	}
+	if autoDoubleRE.MatchString(lowerText) {
+		// Parse "Automatic double" or "Automatic double => 2": the cube is
+		// doubled on an equal opening roll, whoever's column it is in
+		game.AddAutomaticDouble()
+		return
+	}
+	if m := winsRE.FindStringSubmatch(lowerText); m != nil {
+		// Parse "Wins 2 points" or "Wins 1 point and the match"
+		game.Winner = player
+		game.Points, _ = strconv.Atoi(m[1])
+		if game.Result != ResultDrop {
+			game.Result = resultForPoints(game, game.Points)
+		}
 		return
 	}
 
@@ -207,6 +443,20 @@ func parsePlayerMoveMAT(text string, player int, game *Game) {
 	}
 }
 
+// resultForPoints returns how game was won from the points the winner
+// scored: a single game, a gammon or a backgammon at the cube the game
+// ended with.
+func resultForPoints(game *Game, points int) GameResult {
+	switch points {
+	case game.PointsWon(ResultGammon):
+		return ResultGammon
+	case game.PointsWon(ResultBackgammon):
+		return ResultBackgammon
+	default:
+		return ResultSingle
+	}
+}
+
 // parseMoveNotation parses backgammon move notation like "8/5 6/5" or "24/22(2)".
 // Returns the move and true if parsing succeeded.
 func parseMoveNotation(notation string, player int) (engine.Move, bool) {
@@ -254,58 +504,60 @@ func parseMoveNotation(notation string, player int) (engine.Move, bool) {
 	return move, moveIdx > 0
 }
 
-// parsePoint converts point notation to internal point number.
-// "bar" = 25 (for player 0) or 0 (for player 1)
-// "off" = 0 (for player 0) or 25 (for player 1)
-// Numbers are from player's perspective
+// parsePoint converts point notation, numbered from player's side, to the
+// match representation (see matchPoint), or -1 if it is not a point. "home"
+// is another name for off.
 func parsePoint(s string, player int) int {
-	s = strings.ToLower(strings.TrimSpace(s))
-
-	if s == "bar" {
-		if player == 0 {
-			return 25
-		}
-		return 0
+	if s = strings.TrimSpace(s); strings.EqualFold(s, "home") {
+		s = "off"
 	}
-
-	if s == "off" || s == "home" {
-		if player == 0 {
-			return 0
-		}
-		return 25
-	}
-
-	point, err := strconv.Atoi(s)
-	if err != nil {
+	p, ok := engine.ParsePoint(s)
+	if !ok {
 		return -1
 	}
-
-	// Convert from player's perspective to internal representation
-	// Player 0 moves from 24 to 1 (high to low)
-	// Player 1 moves from 1 to 24 (low to high)
-	if player == 1 {
-		point = 25 - point
-	}
-
-	return point
+	return matchPoint(p, player)
 }
 
 // ExportMAT writes a match in MAT format.
 func ExportMAT(w io.Writer, match *Match) error {
-	// Write metadata
-	if match.Place != "" {
-		fmt.Fprintf(w, " ; [Site \"%s\"]\n", match.Place)
+	// Write metadata, in the order and tags gnubg writes
+	tag := func(name, value string) {
+		if value != "" {
+			fmt.Fprintf(w, " ; [%s \"%s\"]\n", name, tagValue(value))
+		}
+	}
+	tag("Site", match.Place)
+	tag("Match ID", match.EventInfo)
+	fmt.Fprintf(w, " ; [Player 1 \"%s\"]\n", tagValue(match.Player1))
+	fmt.Fprintf(w, " ; [Player 2 \"%s\"]\n", tagValue(match.Player2))
+	for i, rating := range match.PlayerRatings {
+		if rating > 0 {
+			tag(fmt.Sprintf("Player %d Elo", i+1), strconv.FormatFloat(rating, 'f', -1, 64))
+		}
+	}
+	if match.Date.IsZero() {
+		tag("EventDate", match.DateText)
+	} else {
+		tag("EventDate", match.Date.Format("2006.01.02"))
+		switch h, m, s := match.Date.Clock(); {
+		case s != 0:
+			tag("EventTime", match.Date.Format("15.04.05"))
+		case h != 0 || m != 0:
+			tag("EventTime", match.Date.Format("15.04"))
+		}
 	}
-	if match.Event != "" {
-		fmt.Fprintf(w, " ; [Event \"%s\"]\n", match.Event)
+	tag("Event", match.Event)
+	tag("Round", match.Round)
+	tag("Annotator", match.Annotator)
+	tag("Comment", match.Comment)
+	if match.Variant != engine.VariantStandard {
+		tag("Variation", match.Variant.String())
 	}
-	if match.Date != "" {
-		fmt.Fprintf(w, " ; [Date \"%s\"]\n", match.Date)
+	if match.NoCrawford && match.MatchLength > 0 {
+		tag("Crawford", "Off")
 	}
-	fmt.Fprintf(w, " ; [Player 1 \"%s\"]\n", match.Player1)
-	fmt.Fprintf(w, " ; [Player 2 \"%s\"]\n", match.Player2)
-	if match.Annotator != "" {
-		fmt.Fprintf(w, " ; [Annotator \"%s\"]\n", match.Annotator)
+	for _, t := range match.Tags {
+		fmt.Fprintf(w, " ; [%s \"%s\"]\n", t.Name, tagValue(t.Value))
 	}
 
 	// Write match length
@@ -325,56 +577,89 @@ func ExportMAT(w io.Writer, match *Match) error {
 	return nil
 }
 
+// matColumnWidth is the width of player 1's column in exported move lines.
+const matColumnWidth = 28
+
 // exportGameMAT writes a single game in MAT format.
 func exportGameMAT(w io.Writer, match *Match, game *Game) error {
-	fmt.Fprintf(w, " Game %d\n", game.Number)
-	fmt.Fprintf(w, " %s : %d                          %s : %d\n",
-		match.Player1, game.Score1, match.Player2, game.Score2)
+	if game.Crawford {
+		fmt.Fprintf(w, " Game %d (Crawford)\n", game.Number)
+	} else {
+		fmt.Fprintf(w, " Game %d\n", game.Number)
+	}
+	// Player 2's name lines up with player 2's column of the move lines
+	fmt.Fprintf(w, " %-*s%s : %d\n", matColumnWidth+4, fmt.Sprintf("%s : %d", match.Player1, game.Score1),
+		match.Player2, game.Score2)
+	for i := 1; i <= game.AutomaticDoubles; i++ {
+		fmt.Fprintf(w, "     Automatic double => %d\n", 1<<i)
+	}
 
 	moveNum := 0
-	player := 0
+	column := -1 // Column last written on the current line, -1 before any
 	var currentRoll [2]int
 
+	// entry writes text in player's column, starting a new numbered line
+	// when the player's column of the current one is taken. A line started
+	// by player 2, as when player 2 wins the opening roll, leaves player 1's
+	// column empty.
+	entry := func(player int, text string) {
+		if column >= player {
+			fmt.Fprintf(w, "\n")
+			column = -1
+		}
+		if column == -1 {
+			moveNum++
+			fmt.Fprintf(w, "%3d) ", moveNum)
+			if player == 1 {
+				fmt.Fprintf(w, "%*s", matColumnWidth, "")
+			}
+		}
+		if player == 0 {
+			fmt.Fprintf(w, "%s%*s", text, max(matColumnWidth-len(text), 3), "")
+		} else {
+			fmt.Fprintf(w, "%s", text)
+		}
+		column = player
+	}
+
 	for _, action := range game.Actions {
 		switch action.Type {
 		case ActionRoll:
-			if action.Player == 0 {
-				moveNum++
-				fmt.Fprintf(w, "%3d) ", moveNum)
-			}
 			currentRoll = action.Dice
-			player = action.Player
 
 		case ActionMove:
-			moveStr := formatMoveMAT(action.Move, player)
-			fmt.Fprintf(w, "%d%d: %s", currentRoll[0], currentRoll[1], moveStr)
-			if player == 0 {
-				fmt.Fprintf(w, "                    ")
-			} else {
-				fmt.Fprintf(w, "\n")
-			}
+			entry(action.Player, fmt.Sprintf("%d%d: %s", currentRoll[0], currentRoll[1], formatMoveMAT(action.Move, action.Player)))
 
 		case ActionDouble:
-			if player == 0 {
-				fmt.Fprintf(w, "     Doubles => %d                    ", action.Value)
-			} else {
-				fmt.Fprintf(w, "Doubles => %d\n", action.Value)
-			}
+			entry(action.Player, fmt.Sprintf(" Doubles => %d", action.Value))
 
 		case ActionTake:
-			if player == 0 {
-				fmt.Fprintf(w, "     Takes                    ")
-			} else {
-				fmt.Fprintf(w, "Takes\n")
-			}
+			entry(action.Player, " Takes")
 
 		case ActionPass:
-			if player == 0 {
-				fmt.Fprintf(w, "     Drops                    ")
-			} else {
-				fmt.Fprintf(w, "Drops\n")
-			}
+			entry(action.Player, " Drops")
+
+		case ActionBeaver:
+			entry(action.Player, fmt.Sprintf(" Beavers => %d", action.Value))
+
+		case ActionRaccoon:
+			entry(action.Player, fmt.Sprintf(" Raccoons => %d", action.Value))
+		}
+	}
+	if column != -1 {
+		fmt.Fprintf(w, "\n")
+	}
+	if game.Winner >= 0 && game.Points > 0 {
+		// In the winner's column of the move lines
+		indent := 5
+		if game.Winner == 1 {
+			indent += matColumnWidth
 		}
+		unit := "points"
+		if game.Points == 1 {
+			unit = "point"
+		}
+		fmt.Fprintf(w, "%*sWins %d %s\n", indent, "", game.Points, unit)
 	}
 
 	fmt.Fprintf(w, "\n")
@@ -398,18 +683,8 @@ func formatMoveMAT(move engine.Move, player int) string {
 	return strings.Join(parts, " ")
 }
 
-// formatPointMAT formats a point number for MAT output.
+// formatPointMAT formats a point of the match representation for MAT
+// output, numbered from player's side.
 func formatPointMAT(point int, player int) string {
-	// Convert from internal to player's perspective
-	if player == 1 {
-		point = 25 - point
-	}
-
-	if point == 25 || point == 24 {
-		return "bar"
-	}
-	if point <= 0 {
-		return "off"
-	}
-	return strconv.Itoa(point)
+	return enginePoint(point, player).String()
 }
@@ -2,9 +2,16 @@ package match
 
 import (
 	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/yourusername/bgengine/internal/positionid"
 	"github.com/yourusername/bgengine/pkg/engine"
 )
 
@@ -162,3 +169,925 @@ func TestParsePoint(t *testing.T) {
 	}
 }
 
+func TestParsePointHitMarker(t *testing.T) {
+	if parsePoint("9*", 0) != 9 {
+		t.Error("parsePoint(9*, 0) should be 9")
+	}
+	if parsePoint("9*", 1) != 16 {
+		t.Error("parsePoint(9*, 1) should be 16")
+	}
+}
+
+// TestPointConversionsMatchOldLogic checks the conversions through
+// engine.Point against the arithmetic they replaced, for every point of both
+// players: the match representation's bar at 25 is the engine's 24.
+func TestPointConversionsMatchOldLogic(t *testing.T) {
+	oldParse := func(s string, player int) int {
+		switch s {
+		case "bar":
+			return [...]int{25, 0}[player]
+		case "off":
+			return [...]int{0, 25}[player]
+		}
+		point, _ := strconv.Atoi(s)
+		if player == 1 {
+			point = 25 - point
+		}
+		return point
+	}
+	oldFormat := func(point, player int) string {
+		if player == 1 {
+			point = 25 - point
+		}
+		if point == 25 {
+			return "bar"
+		}
+		if point <= 0 {
+			return "off"
+		}
+		return strconv.Itoa(point)
+	}
+	oldEngine := func(point, player int) int {
+		if player == 1 {
+			point = 25 - point
+		}
+		return point - 1
+	}
+
+	for player := 0; player < 2; player++ {
+		inputs := []string{"bar", "off"}
+		for n := 1; n <= 24; n++ {
+			inputs = append(inputs, strconv.Itoa(n))
+		}
+		for _, s := range inputs {
+			if got, want := parsePoint(s, player), oldParse(s, player); got != want {
+				t.Errorf("parsePoint(%q, %d) = %d, old logic %d", s, player, got, want)
+			}
+		}
+		for point := 0; point <= 25; point++ {
+			if got, want := formatPointMAT(point, player), oldFormat(point, player); got != want {
+				t.Errorf("formatPointMAT(%d, %d) = %q, old logic %q", point, player, got, want)
+			}
+			p := enginePoint(point, player)
+			if int(p) != oldEngine(point, player) || matchPoint(p, player) != point {
+				t.Errorf("enginePoint(%d, %d) = %d, old logic %d, back %d", point, player, p, oldEngine(point, player), matchPoint(p, player))
+			}
+		}
+	}
+}
+
+func TestImportMATBeavers(t *testing.T) {
+	f, err := os.Open("testdata/beavers.mat")
+	if err != nil {
+		t.Fatalf("open fixture: %v", err)
+	}
+	defer f.Close()
+
+	m, err := ImportMAT(f)
+	if err != nil {
+		t.Fatalf("ImportMAT error: %v", err)
+	}
+	if len(m.Games) != 2 {
+		t.Fatalf("Games = %d, want 2", len(m.Games))
+	}
+
+	g := m.Games[0]
+	var types []ActionType
+	for _, a := range g.Actions {
+		if a.Type != ActionRoll && a.Type != ActionMove {
+			types = append(types, a.Type)
+		}
+	}
+	want := []ActionType{ActionDouble, ActionBeaver, ActionRaccoon}
+	if len(types) != len(want) {
+		t.Fatalf("cube actions = %v, want %v", types, want)
+	}
+	for i := range want {
+		if types[i] != want[i] {
+			t.Errorf("cube action %d = %v, want %v", i, types[i], want[i])
+		}
+	}
+	if g.CubeValue != 8 || g.CubeOwner != 1 {
+		t.Errorf("cube after raccoon = %d owned by %d, want 8 owned by 1", g.CubeValue, g.CubeOwner)
+	}
+
+	// Beavers survive a MAT round trip
+	var buf bytes.Buffer
+	if err := ExportMAT(&buf, m); err != nil {
+		t.Fatalf("ExportMAT error: %v", err)
+	}
+	if !strings.Contains(buf.String(), "Beavers => 4") || !strings.Contains(buf.String(), "Raccoons => 8") {
+		t.Errorf("exported MAT missing beaver/raccoon:\n%s", buf.String())
+	}
+}
+
+func TestAnalyzeBeaverMatch(t *testing.T) {
+	f, err := os.Open("testdata/beavers.mat")
+	if err != nil {
+		t.Fatalf("open fixture: %v", err)
+	}
+	defer f.Close()
+
+	m, err := ImportMAT(f)
+	if err != nil {
+		t.Fatalf("ImportMAT error: %v", err)
+	}
+
+	// With nets missing every contact position evaluates as even, so doubling is
+	// wrong and beavering is right
+	e, err := engine.NewEngine(engine.EngineOptions{EvenFallback: true})
+	if err != nil {
+		t.Fatalf("NewEngine error: %v", err)
+	}
+
+	actions := m.AnalysisActions()
+	positions := engine.ConvertMatchActionsToPositions(actions, engine.StartingPosition().Board, [2]int{}, m.MatchLength)
+	result, err := e.AnalyzePositionList(positions, engine.DefaultMatchAnalysisOptions())
+	if err != nil {
+		t.Fatalf("AnalyzePositionList error: %v", err)
+	}
+
+	if result.TotalCubeActs != 5 {
+		t.Errorf("TotalCubeActs = %d, want 5", result.TotalCubeActs)
+	}
+	if result.PlayerStats[1].WrongBeavers != 0 {
+		t.Errorf("Bob's correct beaver counted as wrong")
+	}
+	if result.PlayerStats[0].MissedBeavers != 1 {
+		t.Errorf("Alice MissedBeavers = %d, want 1", result.PlayerStats[0].MissedBeavers)
+	}
+
+	var sawTake, sawRaccoon bool
+	for _, ce := range result.CubeErrors {
+		switch ce.PlayedStr {
+		case "beaver":
+			t.Errorf("unexpected beaver error: %+v", ce)
+		case "take":
+			sawTake = true
+			if ce.OptimalStr != "beaver" || ce.EquityLoss <= 0 || ce.EquityLoss > 1 {
+				t.Errorf("take error = %+v, want missed beaver with loss in (0, 1]", ce)
+			}
+		case "raccoon":
+			sawRaccoon = true
+			if ce.Player != 0 || ce.EquityLoss <= 0 {
+				t.Errorf("raccoon error = %+v, want Alice losing equity", ce)
+			}
+		}
+	}
+	if !sawTake || !sawRaccoon {
+		t.Errorf("missing take or raccoon error in %+v", result.CubeErrors)
+	}
+}
+
+func TestAnalyzeCorruptedMAT(t *testing.T) {
+	data, err := os.ReadFile("testdata/beavers.mat")
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	// A typo in game 2's last move: 13/3 is not a play of 6-4
+	i := bytes.LastIndex(data, []byte("13/9"))
+	corrupted := string(data[:i]) + "13/3" + string(data[i+len("13/9"):])
+
+	m, err := ImportMAT(strings.NewReader(corrupted))
+	if err != nil {
+		t.Fatalf("ImportMAT error: %v", err)
+	}
+	e, err := engine.NewEngine(engine.EngineOptions{EvenFallback: true})
+	if err != nil {
+		t.Fatalf("NewEngine error: %v", err)
+	}
+
+	positions := engine.ConvertMatchActionsToPositions(m.AnalysisActions(), engine.StartingPosition().Board, [2]int{}, m.MatchLength)
+	last := positions[len(positions)-1]
+	if last.GameNumber != 2 || last.Move == nil || engine.FormatMove(*last.Move) != "24/18 13/3" {
+		t.Errorf("reconstruction ends at %+v, want the corrupted move", last)
+	}
+
+	_, err = m.Analyze(e, engine.DefaultMatchAnalysisOptions())
+	var posErr *engine.PositionError
+	var moveErr *engine.MoveError
+	if !errors.As(err, &posErr) || posErr.GameNumber != 2 || posErr.MoveNumber != 3 {
+		t.Fatalf("Analyze error %v, want a PositionError at game 2, move 3", err)
+	}
+	if !errors.As(err, &moveErr) || moveErr.Reason != "13/3: no unused die or dice move it" {
+		t.Errorf("Analyze error %v, want the reason 13/3 is illegal", err)
+	}
+}
+
+func TestImportMATCrawford(t *testing.T) {
+	data, err := os.ReadFile("testdata/crawford.mat")
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	m, err := ImportMAT(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("ImportMAT error: %v", err)
+	}
+	for i, g := range m.Games {
+		if want := i == 3; g.Crawford != want {
+			t.Errorf("game %d Crawford = %v, want %v", g.Number, g.Crawford, want)
+		}
+		if want := i > 3; g.PostCrawford != want {
+			t.Errorf("game %d PostCrawford = %v, want %v", g.Number, g.PostCrawford, want)
+		}
+	}
+
+	// Positions of the Crawford game carry it to the engine
+	positions := engine.ConvertMatchActionsToPositions(m.AnalysisActions(), engine.StartingPosition().Board, [2]int{}, m.MatchLength)
+	for _, pos := range positions {
+		if pos.Crawford != (pos.GameNumber == 4) {
+			t.Errorf("game %d move %d Crawford = %v", pos.GameNumber, pos.MoveNumber, pos.Crawford)
+		}
+	}
+
+	e, err := engine.NewEngine(engine.EngineOptions{EvenFallback: true})
+	if err != nil {
+		t.Fatalf("NewEngine error: %v", err)
+	}
+	result, err := m.Analyze(e, engine.DefaultMatchAnalysisOptions())
+	if err != nil {
+		t.Fatalf("Analyze error: %v", err)
+	}
+	cube := make(map[int]int)
+	for _, g := range result.GameStats {
+		cube[g.GameNumber] = g.CubeActions
+	}
+	if cube[4] != 0 {
+		t.Errorf("%d cube decisions analyzed in the Crawford game, want 0", cube[4])
+	}
+	// Post-Crawford, the trailer doubles at once and the leader takes
+	if cube[5] != 2 {
+		t.Errorf("%d cube decisions analyzed after the Crawford game, want 2", cube[5])
+	}
+	if s := result.PlayerStats[1]; s.WrongDoubles != 0 || s.DeadCubeDecisions != 0 {
+		t.Errorf("Bob's post-Crawford double: %d wrong, %d dead, want a right double", s.WrongDoubles, s.DeadCubeDecisions)
+	}
+
+	// A Crawford tag turning the rule off, and a Crawford game marked in
+	// its header, override the score
+	off, _ := ImportMAT(strings.NewReader(` ; [Crawford "Off"]` + "\n" + string(data)))
+	for _, g := range off.Games {
+		if g.Crawford || g.PostCrawford {
+			t.Errorf("without the Crawford rule, game %d is marked Crawford %v, post-Crawford %v", g.Number, g.Crawford, g.PostCrawford)
+		}
+	}
+	marked, _ := ImportMAT(strings.NewReader(strings.Replace(string(data), "Game 5", "Game 5 (Crawford)", 1)))
+	if marked.Games[3].Crawford || !marked.Games[4].Crawford {
+		t.Errorf("game 5 marked Crawford: Crawford games 4 %v, 5 %v", marked.Games[3].Crawford, marked.Games[4].Crawford)
+	}
+
+	// Export marks the rule and the Crawford game
+	var buf bytes.Buffer
+	if err := ExportMAT(&buf, off); err != nil {
+		t.Fatalf("ExportMAT error: %v", err)
+	}
+	if !strings.Contains(buf.String(), `[Crawford "Off"]`) {
+		t.Errorf("exported MAT missing the Crawford tag:\n%s", buf.String())
+	}
+	buf.Reset()
+	if err := ExportMAT(&buf, marked); err != nil {
+		t.Fatalf("ExportMAT error: %v", err)
+	}
+	if again, err := ImportMAT(&buf); err != nil || !again.Games[4].Crawford {
+		t.Errorf("Crawford game 5 lost in a round trip (error %v)", err)
+	}
+}
+
+func TestImportMATOpeningRoll(t *testing.T) {
+	f, err := os.Open("testdata/opening.mat")
+	if err != nil {
+		t.Fatalf("open fixture: %v", err)
+	}
+	defer f.Close()
+
+	m, err := ImportMAT(f)
+	if err != nil {
+		t.Fatalf("ImportMAT error: %v", err)
+	}
+	if len(m.Games) != 2 {
+		t.Fatalf("Games = %d, want 2", len(m.Games))
+	}
+
+	// Bob wins the opening roll of game 1 and Alice that of game 2; each
+	// plays both dice
+	for i, want := range []Action{
+		{Type: ActionRoll, Player: 1, Dice: [2]int{5, 2}},
+		{Type: ActionRoll, Player: 0, Dice: [2]int{3, 1}},
+	} {
+		if got := m.Games[i].Actions[0]; got != want {
+			t.Errorf("game %d opens with %+v, want %+v", i+1, got, want)
+		}
+	}
+	g := m.Games[0]
+	if g.Winner != 0 || g.Result != ResultDrop {
+		t.Errorf("game 1 winner %d by %v, want Alice by a drop", g.Winner, g.Result)
+	}
+	if last := g.Actions[len(g.Actions)-2]; last.Type != ActionDouble || last.Player != 0 {
+		t.Errorf("game 1 double = %+v, want Alice's", last)
+	}
+
+	// The opening roll survives a MAT round trip
+	var buf bytes.Buffer
+	if err := ExportMAT(&buf, m); err != nil {
+		t.Fatalf("ExportMAT error: %v", err)
+	}
+	again, err := ImportMAT(&buf)
+	if err != nil {
+		t.Fatalf("ImportMAT of the export: %v", err)
+	}
+	for i := range m.Games {
+		if !reflect.DeepEqual(again.Games[i].Actions, m.Games[i].Actions) {
+			t.Errorf("game %d actions after a round trip:\n%+v\nwant\n%+v", i+1, again.Games[i].Actions, m.Games[i].Actions)
+		}
+	}
+
+	// Only the winner of the opening roll plays move 1
+	e, err := engine.NewEngine(engine.EngineOptions{EvenFallback: true})
+	if err != nil {
+		t.Fatalf("NewEngine error: %v", err)
+	}
+	positions := engine.ConvertMatchActionsToPositions(m.AnalysisActions(), engine.StartingPosition().Board, [2]int{}, m.MatchLength)
+	opener := map[int]int{1: 1, 2: 0}
+	for _, pos := range positions {
+		if pos.MoveNumber == 1 && pos.Move != nil && (pos.Player != opener[pos.GameNumber] || pos.Turn != pos.Player) {
+			t.Errorf("game %d move 1 played by %d on turn %d, want %d", pos.GameNumber, pos.Player, pos.Turn, opener[pos.GameNumber])
+		}
+	}
+	result, err := e.AnalyzePositionList(positions, engine.DefaultMatchAnalysisOptions())
+	if err != nil {
+		t.Fatalf("AnalyzePositionList error: %v", err)
+	}
+	for _, me := range result.MoveErrors {
+		if me.MoveNumber == 1 && me.Player != opener[me.GameNumber] {
+			t.Errorf("move 1 error for the player who lost the opening roll: %+v", me)
+		}
+	}
+	if result.TotalMoves != 7 {
+		t.Errorf("TotalMoves = %d, want 7", result.TotalMoves)
+	}
+}
+
+func TestImportMATResults(t *testing.T) {
+	read := func(name string) []byte {
+		data, err := os.ReadFile("testdata/" + name)
+		if err != nil {
+			t.Fatalf("read fixture: %v", err)
+		}
+		return data
+	}
+
+	// Game 1 ends in a gammon for Bob borne off without a "Wins" line
+	data := read("gammon.mat")
+	m, err := ImportMAT(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("ImportMAT error: %v", err)
+	}
+	g := m.Games[0]
+	if g.Winner != 1 || g.Points != 2 || g.Result != ResultGammon {
+		t.Errorf("game 1 winner %d, %d points, %v, want 1, 2, gammon", g.Winner, g.Points, g.Result)
+	}
+	if len(m.Warnings) != 0 {
+		t.Errorf("warnings = %q, want none", m.Warnings)
+	}
+
+	// A result the final position contradicts
+	wrong := strings.Replace(string(data), "\n\n Game 2", "\n                                 Wins 1 point\n\n Game 2", 1)
+	if m, err = ImportMAT(strings.NewReader(wrong)); err != nil {
+		t.Fatalf("ImportMAT error: %v", err)
+	}
+	if len(m.Warnings) != 2 || !strings.Contains(m.Warnings[0], "gammon for 2") {
+		t.Errorf("warnings for a gammon recorded as 1 point = %q, want the result and game 2's score", m.Warnings)
+	}
+
+	// Game 2's score line gives Bob 1 point for the gammon
+	m, err = ImportMAT(bytes.NewReader(read("badscore.mat")))
+	if err != nil {
+		t.Fatalf("ImportMAT error: %v", err)
+	}
+	if len(m.Warnings) != 1 || !strings.Contains(m.Warnings[0], "game 2: starts at 0-1, but game 1 ends at 0-2") {
+		t.Errorf("warnings = %q, want one for game 2's score", m.Warnings)
+	}
+
+	for _, name := range []string{"crawford.mat", "opening.mat", "autodouble.mat", "beavers.mat", "nackgammon.mat"} {
+		m, err := ImportMAT(bytes.NewReader(read(name)))
+		if err != nil {
+			t.Fatalf("ImportMAT(%s) error: %v", name, err)
+		}
+		if len(m.Warnings) != 0 {
+			t.Errorf("%s: warnings = %q, want none", name, m.Warnings)
+		}
+	}
+}
+
+func TestImportNackgammonMatch(t *testing.T) {
+	f, err := os.Open("testdata/nackgammon.mat")
+	if err != nil {
+		t.Fatalf("open fixture: %v", err)
+	}
+	defer f.Close()
+
+	m, err := ImportMAT(f)
+	if err != nil {
+		t.Fatalf("ImportMAT error: %v", err)
+	}
+	if m.Variant != engine.VariantNackgammon {
+		t.Fatalf("Variant = %v, want Nackgammon", m.Variant)
+	}
+	nack := engine.StartingPositionVariant(engine.VariantNackgammon).Board
+	for side := 0; side < 2; side++ {
+		n := 0
+		for _, c := range nack[side] {
+			n += int(c)
+		}
+		if n != 15 || nack[side][22] != 2 || nack[side][23] != 2 {
+			t.Errorf("Nackgammon side %d = %v, want 15 checkers with two each on the 23- and 24-points", side, nack[side])
+		}
+	}
+	if m.Games[0].InitialBoard != nack {
+		t.Errorf("InitialBoard = %v, want the Nackgammon start", m.Games[0].InitialBoard)
+	}
+
+	// The standard board passed in is replaced by the game's own start
+	positions := engine.ConvertMatchActionsToPositions(m.AnalysisActions(), engine.StartingPosition().Board, [2]int{}, m.MatchLength)
+	if len(positions) != 5 {
+		t.Fatalf("%d positions, want 5", len(positions))
+	}
+	if positions[0].Board != nack {
+		t.Errorf("first position = %v, want the Nackgammon start", positions[0].Board)
+	}
+
+	// Every recorded move is legal in the reconstructed position; 23/21 in
+	// the last move needs the Nackgammon back checkers
+	for i, pos := range positions {
+		after := engine.ApplyMove(pos.Board, *pos.Move)
+		legal := engine.GenerateMoves(pos.Board, pos.Dice[0], pos.Dice[1])
+		found := false
+		for _, mv := range legal.Moves {
+			if engine.ApplyMove(pos.Board, mv) == after {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("move %d (%v, dice %v) is illegal in the reconstructed position", i+1, *pos.Move, pos.Dice)
+		}
+	}
+
+	// After Alice's 8/5 6/5, from Bob's side (Alice in board[0])
+	alice := positions[1].Board[0]
+	if alice[4] != 2 || alice[5] != 3 || alice[7] != 2 || alice[12] != 4 || alice[22] != 2 || alice[23] != 2 {
+		t.Errorf("Alice after 8/5 6/5 = %v", alice)
+	}
+	// After Bob's 13/11 13/8, from Alice's side (Bob in board[0])
+	bob := positions[2].Board[0]
+	if bob[12] != 2 || bob[10] != 1 || bob[7] != 4 || bob[22] != 2 {
+		t.Errorf("Bob after 13/11 13/8 = %v", bob)
+	}
+
+	e, err := engine.NewEngine(engine.EngineOptions{})
+	if err != nil {
+		t.Fatalf("NewEngine error: %v", err)
+	}
+	result, err := e.AnalyzePositionList(positions, engine.DefaultMatchAnalysisOptions())
+	if err != nil {
+		t.Fatalf("AnalyzePositionList error: %v", err)
+	}
+	if result.TotalMoves != 5 {
+		t.Errorf("TotalMoves = %d, want 5", result.TotalMoves)
+	}
+}
+
+func TestNackgammonVariationHeader(t *testing.T) {
+	for _, header := range []string{
+		` ; [Variation "Nackgammon"]`,
+		` ; Variation: NackGammon`,
+		`Variation: NackGammon`,
+	} {
+		m, err := ImportMAT(strings.NewReader(header + "\n 1 point match\n\n Game 1\n A : 0   B : 0\n  1) 31: 8/5 6/5\n"))
+		if err != nil {
+			t.Fatalf("%s: ImportMAT error: %v", header, err)
+		}
+		if m.Variant != engine.VariantNackgammon {
+			t.Errorf("%s: Variant = %v, want Nackgammon", header, m.Variant)
+		}
+	}
+
+	if _, err := ImportMAT(strings.NewReader(" ; [Variation \"Hypergammon\"]\n")); err == nil {
+		t.Error("expected error for an unsupported variation")
+	}
+
+	// The variant survives a MAT round trip
+	m := NewMatch("A", "B", 3)
+	m.Variant = engine.VariantNackgammon
+	var buf bytes.Buffer
+	if err := ExportMAT(&buf, m); err != nil {
+		t.Fatalf("ExportMAT error: %v", err)
+	}
+	back, err := ImportMAT(&buf)
+	if err != nil {
+		t.Fatalf("ImportMAT error: %v", err)
+	}
+	if back.Variant != engine.VariantNackgammon {
+		t.Errorf("round trip Variant = %v, want Nackgammon", back.Variant)
+	}
+}
+
+func TestSnowieTextStartingPosition(t *testing.T) {
+	const text = "0;0;0;0;0;Player 1;Player 2;0;0;0;1;0;0;-2;0;0;0;0;5;0;3;0;0;0;-5;5;0;0;0;-3;0;-5;0;0;0;0;2;0;3;1;"
+	want, err := positionid.BoardFromPositionID("4HPwATDgc/ABMA")
+	if err != nil {
+		t.Fatalf("BoardFromPositionID failed: %v", err)
+	}
+
+	// Either player on roll sees the same board
+	for turn := 0; turn < 2; turn++ {
+		s := strings.Replace(text, "0;0;0;0;0;", "0;0;0;0;"+string(rune('0'+turn))+";", 1)
+		gs, err := ParseSnowieText(s)
+		if err != nil {
+			t.Fatalf("ParseSnowieText(%q) failed: %v", s, err)
+		}
+		if gs.Board != engine.Board(want) {
+			t.Errorf("turn %d: board = %v, want %v", turn, gs.Board, want)
+		}
+		if gs.Turn != turn || gs.Dice != [2]int{3, 1} || gs.CubeValue != 1 || gs.CubeOwner != -1 {
+			t.Errorf("turn %d: state = %+v", turn, gs)
+		}
+	}
+
+	gs := &engine.GameState{Board: engine.Board(want), CubeValue: 1, CubeOwner: -1, Dice: [2]int{3, 1}}
+	if got := FormatSnowieText(gs); got != text {
+		t.Errorf("FormatSnowieText = %q, want %q", got, text)
+	}
+}
+
+func TestSnowieTextRoundTrip(t *testing.T) {
+	tests := []struct {
+		posID string
+		state engine.GameState
+	}{
+		{"4HPwATDgc/ABMA", engine.GameState{CubeValue: 1, CubeOwner: -1}},
+		{"4HPwATDgc/ABMA", engine.GameState{Turn: 1, MatchLength: 7, Score: [2]int{2, 5}, CubeValue: 2, CubeOwner: 0, Dice: [2]int{6, 6}}},
+		{"sGfwATDgc/ABMA", engine.GameState{Turn: 0, MatchLength: 5, Score: [2]int{4, 2}, CubeValue: 1, CubeOwner: -1, Crawford: true}},
+		{"4HPwATDgc/ABMA", engine.GameState{Turn: 1, CubeValue: 4, CubeOwner: 1, Dice: [2]int{5, 2}}},
+		// Checkers on both bars and some borne off
+		{"", engine.GameState{CubeValue: 1, CubeOwner: -1}},
+	}
+	for _, tt := range tests {
+		state := tt.state
+		if tt.posID == "" {
+			state.Board[1][24], state.Board[1][5], state.Board[1][2] = 2, 3, 1
+			state.Board[0][24], state.Board[0][20] = 1, 4
+		} else {
+			board, err := positionid.BoardFromPositionID(tt.posID)
+			if err != nil {
+				t.Fatalf("BoardFromPositionID(%s) failed: %v", tt.posID, err)
+			}
+			state.Board = engine.Board(board)
+		}
+
+		text := FormatSnowieText(&state)
+		got, err := ParseSnowieText(text)
+		if err != nil {
+			t.Fatalf("ParseSnowieText(%q) failed: %v", text, err)
+		}
+		if *got != state {
+			t.Errorf("round trip of %+v through %q gave %+v", state, text, *got)
+		}
+
+		// The player on roll's checkers are positive on his own points
+		fields := strings.Split(text, ";")
+		for i := 0; i < 24; i++ {
+			if n := state.Board[1][i]; n > 0 && fields[13+i] != strconv.Itoa(int(n)) {
+				t.Errorf("%q: point %d = %s, want %d", text, i+1, fields[13+i], n)
+			}
+		}
+	}
+}
+
+func TestParseSnowieTextErrors(t *testing.T) {
+	valid := "0;0;0;0;0;A;B;0;0;0;1;0;0;-2;0;0;0;0;5;0;3;0;0;0;-5;5;0;0;0;-3;0;-5;0;0;0;0;2;0;3;1;"
+	if _, err := ParseSnowieText(valid); err != nil {
+		t.Fatalf("ParseSnowieText(valid) failed: %v", err)
+	}
+	// Wrapped over lines with a positive opponent bar count
+	if gs, err := ParseSnowieText(strings.Replace(valid, "5;0;0;0;-3;", "5;0;0;0;-3;\n", 1)); err != nil || gs.Board[1][5] != 5 {
+		t.Errorf("wrapped text: %+v, %v", gs, err)
+	}
+
+	tests := []string{
+		"",
+		"4HPwATDgc/ABMA",
+		strings.TrimSuffix(valid, "3;1;"),
+		strings.Replace(valid, "0;0;0;0;0;", "0;0;0;0;2;", 1),
+		strings.Replace(valid, ";1;0;0;-2;", ";3;0;0;-2;", 1),
+		strings.Replace(valid, ";1;0;0;-2;", ";1;2;0;-2;", 1),
+		strings.Replace(valid, ";5;0;3;", ";9;0;3;", 1),
+		strings.Replace(valid, "3;1;", "7;1;", 1),
+		strings.Replace(valid, "3;1;", "3;0;", 1),
+		strings.Replace(valid, ";-5;5;", ";x;5;", 1),
+	}
+	for _, s := range tests {
+		if gs, err := ParseSnowieText(s); err == nil {
+			t.Errorf("ParseSnowieText(%q) = %+v, want error", s, gs)
+		}
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := OpenFileStore(dir)
+	if err != nil {
+		t.Fatalf("OpenFileStore error: %v", err)
+	}
+
+	f, err := os.Open("testdata/beavers.mat")
+	if err != nil {
+		t.Fatalf("open fixture: %v", err)
+	}
+	defer f.Close()
+	m, err := ImportMAT(f)
+	if err != nil {
+		t.Fatalf("ImportMAT error: %v", err)
+	}
+
+	id, err := store.Add(m)
+	if err != nil {
+		t.Fatalf("Add error: %v", err)
+	}
+	other, err := store.Add(NewMatch("Carol", "Dave", 5))
+	if err != nil {
+		t.Fatalf("Add error: %v", err)
+	}
+	if id == other {
+		t.Fatalf("two matches share ID %s", id)
+	}
+
+	e, err := engine.NewEngine(engine.EngineOptions{})
+	if err != nil {
+		t.Fatalf("NewEngine error: %v", err)
+	}
+	analysis, err := m.Analyze(e, engine.DefaultMatchAnalysisOptions())
+	if err != nil {
+		t.Fatalf("Analyze error: %v", err)
+	}
+	if analysis.PlayerStats[0].Name != "Alice" {
+		t.Errorf("player 1 named %q, want Alice from the match", analysis.PlayerStats[0].Name)
+	}
+	for i, g := range analysis.GameStats {
+		if g.Winner != m.Games[i].Winner || g.Points != m.Games[i].Points {
+			t.Errorf("game %d: winner %d points %d, want %d %d from the match", g.GameNumber, g.Winner, g.Points, m.Games[i].Winner, m.Games[i].Points)
+		}
+	}
+	if analysis.LuckAdjusted != nil {
+		t.Errorf("luck-adjusted result without luck: %+v", analysis.LuckAdjusted)
+	}
+	opts := engine.DefaultMatchAnalysisOptions()
+	opts.IncludeLuck = true
+	// The fixture's games are unfinished, so all that shows is the luck
+	if lucky, err := m.Analyze(e, opts); err != nil || lucky.LuckAdjusted == nil || !strings.HasPrefix(lucky.LuckAdjusted.Summary, "Neither player is ahead") {
+		t.Errorf("Analyze with luck: %v, luck-adjusted result %+v", err, lucky.LuckAdjusted)
+	}
+	if err := store.SetAnalysis(id, analysis, 1); err != nil {
+		t.Fatalf("SetAnalysis error: %v", err)
+	}
+	if err := store.SetAnalysis("missing", analysis, 1); err != ErrMatchNotFound {
+		t.Errorf("SetAnalysis(missing) = %v, want ErrMatchNotFound", err)
+	}
+
+	// A stray file and one from a newer version are skipped
+	os.WriteFile(filepath.Join(dir, "junk.json"), []byte("{"), 0o644)
+	os.WriteFile(filepath.Join(dir, "future.json"), []byte(`{"format":"bgengine-match","version":99,"id":"future","match":{}}`), 0o644)
+
+	// Everything survives reopening the store
+	store, err = OpenFileStore(dir)
+	if err != nil {
+		t.Fatalf("reopen error: %v", err)
+	}
+	if store.Skipped() != 2 {
+		t.Errorf("Skipped = %d, want 2", store.Skipped())
+	}
+	// Only the corrupt one is moved aside
+	if q := store.Quarantined(); len(q) != 1 || !strings.HasPrefix(filepath.Base(q[0]), "junk.json.corrupt-") {
+		t.Errorf("Quarantined = %v, want junk.json moved aside", q)
+	}
+
+	sm, err := store.Get(id)
+	if err != nil {
+		t.Fatalf("Get error: %v", err)
+	}
+	if sm.Match.Player1 != "Alice" || len(sm.Match.Games) != 2 || len(sm.Match.Games[0].Actions) != len(m.Games[0].Actions) {
+		t.Errorf("stored match = %+v, want the imported match", sm.Match)
+	}
+	if sm.Match.Games[0].InitialBoard != m.Games[0].InitialBoard {
+		t.Errorf("stored initial board = %v", sm.Match.Games[0].InitialBoard)
+	}
+	if sm.Analysis == nil || sm.Analysis.TotalCubeActs != analysis.TotalCubeActs || sm.AnalysisPly != 1 || sm.AnalyzedAt.IsZero() {
+		t.Errorf("stored analysis = %+v at ply %d", sm.Analysis, sm.AnalysisPly)
+	}
+	if sm.Analysis != nil && (analysis.Engine == nil || !reflect.DeepEqual(sm.Analysis.Engine, analysis.Engine)) {
+		t.Errorf("stored analysis engine = %+v, want %+v", sm.Analysis.Engine, analysis.Engine)
+	}
+	if _, err := store.Get("missing"); err != ErrMatchNotFound {
+		t.Errorf("Get(missing) = %v, want ErrMatchNotFound", err)
+	}
+
+	list, total, err := store.List(0, 0)
+	if err != nil || total != 2 || len(list) != 2 {
+		t.Fatalf("List = %d of %d, %v; want 2 of 2", len(list), total, err)
+	}
+	for _, info := range list {
+		if (info.ID == id) != info.Analyzed {
+			t.Errorf("%s: Analyzed = %t", info.ID, info.Analyzed)
+		}
+	}
+	page, total, _ := store.List(1, 1)
+	if total != 2 || len(page) != 1 || page[0].ID != list[1].ID {
+		t.Errorf("List(1, 1) = %+v of %d, want the second match", page, total)
+	}
+	if page, _, _ := store.List(5, 10); len(page) != 0 {
+		t.Errorf("List past the end = %+v", page)
+	}
+}
+
+func TestFileStoreVersions(t *testing.T) {
+	dir := t.TempDir()
+
+	// Version 1 kept the match next to the format and version
+	legacy := `{"format":"bgengine-match","version":1,"id":"00000000000000a1","uploaded":"2024-01-02T03:04:05Z",` +
+		`"match":{"Player1":"Eve","Player2":"Frank","MatchLength":3}}`
+	os.WriteFile(filepath.Join(dir, "00000000000000a1.json"), []byte(legacy), 0o644)
+
+	store, err := OpenFileStore(dir)
+	if err != nil {
+		t.Fatalf("OpenFileStore error: %v", err)
+	}
+	if store.Skipped() != 0 {
+		t.Fatalf("Skipped = %d, want the version 1 file read", store.Skipped())
+	}
+	sm, err := store.Get("00000000000000a1")
+	if err != nil || sm.Match.Player1 != "Eve" || sm.Match.MatchLength != 3 || sm.Uploaded.Year() != 2024 {
+		t.Fatalf("version 1 match = %+v, %v", sm, err)
+	}
+
+	// Writing it upgrades the file to the current version
+	if err := store.SetAnalysis(sm.ID, &engine.MatchAnalysis{TotalGames: 1}, 0); err != nil {
+		t.Fatalf("SetAnalysis error: %v", err)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "00000000000000a1.json"))
+	if !strings.Contains(string(data), `"version": 3`) || !strings.Contains(string(data), `"payload"`) {
+		t.Errorf("rewritten file is not version 3:\n%s", data)
+	}
+
+	// Version 2 kept the date as the match file recorded it
+	v2 := `{"format":"bgengine-match","version":2,"payload":{"id":"00000000000000b2","uploaded":"2024-01-02T03:04:05Z",` +
+		`"match":{"Player1":"Eve","Player2":"Frank","MatchLength":3,"Date":"2003.06.14"}}}`
+	os.WriteFile(filepath.Join(dir, "00000000000000b2.json"), []byte(v2), 0o644)
+	store, err = OpenFileStore(dir)
+	if err != nil {
+		t.Fatalf("reopen error: %v", err)
+	}
+	sm, err = store.Get("00000000000000b2")
+	if err != nil || sm.Match.DateText != "2003.06.14" || !sm.Match.Date.Equal(time.Date(2003, 6, 14, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("version 2 match = %+v, %v", sm, err)
+	}
+	if matches, _, _ := store.List(0, 0); len(matches) != 2 || matches[0].Date != "2003-06-14" && matches[1].Date != "2003-06-14" {
+		t.Errorf("listing = %+v, want the version 2 match dated 2003-06-14", matches)
+	}
+	os.Remove(filepath.Join(dir, "00000000000000b2.json"))
+
+	// A match cut short by a crash is moved aside on the next open, and the
+	// rest of the store is unaffected
+	id, err := store.Add(NewMatch("Alice", "Bob", 5))
+	if err != nil {
+		t.Fatalf("Add error: %v", err)
+	}
+	path := filepath.Join(dir, id+".json")
+	data, _ = os.ReadFile(path)
+	os.WriteFile(path, data[:len(data)/2], 0o644)
+
+	store, err = OpenFileStore(dir)
+	if err != nil {
+		t.Fatalf("reopen error: %v", err)
+	}
+	if q := store.Quarantined(); store.Skipped() != 1 || len(q) != 1 || !strings.HasPrefix(q[0], path+".corrupt-") {
+		t.Errorf("Skipped = %d, Quarantined = %v", store.Skipped(), q)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("corrupt file still in place: %v", err)
+	}
+	if _, total, _ := store.List(0, 0); total != 1 {
+		t.Errorf("%d matches, want the version 1 one", total)
+	}
+}
+
+func TestActionNames(t *testing.T) {
+	m := NewMatch("Alice", "Bob", 0)
+	g := NewGame(1, 0, 0, false)
+	g.AddRoll(1, 3, 1)
+	move, _ := parseMoveNotation("8/5 6/5", 1)
+	g.AddMove(1, move)
+	m.Games = append(m.Games, g)
+
+	if got := g.Actions[1].MoveNotation(); got != "8/5 6/5" {
+		t.Errorf("MoveNotation = %q, want 8/5 6/5", got)
+	}
+	if got := g.Actions[0].MoveNotation(); got != "" {
+		t.Errorf("MoveNotation of a roll = %q, want empty", got)
+	}
+	if ActionAcceptResign.String() != "accept_resign" || ResultResignBG.String() != "resign_backgammon" {
+		t.Errorf("names = %s, %s", ActionAcceptResign, ResultResignBG)
+	}
+}
+
+func TestAutomaticDoubleMoneySession(t *testing.T) {
+	f, err := os.Open("testdata/autodouble.mat")
+	if err != nil {
+		t.Fatalf("open fixture: %v", err)
+	}
+	defer f.Close()
+
+	m, err := ImportMAT(f)
+	if err != nil {
+		t.Fatalf("ImportMAT error: %v", err)
+	}
+	if len(m.Games) != 2 {
+		t.Fatalf("Games = %d, want 2", len(m.Games))
+	}
+	want := []struct {
+		auto, winner, points int
+		result               GameResult
+	}{
+		{1, 0, 2, ResultDrop},
+		{0, 1, 4, ResultGammon},
+	}
+	for i, w := range want {
+		g := m.Games[i]
+		if g.AutomaticDoubles != w.auto || g.Winner != w.winner || g.Points != w.points || g.Result != w.result {
+			t.Errorf("game %d: %d automatic doubles, won by %d for %d points (%v), want %d, %d for %d (%v)",
+				g.Number, g.AutomaticDoubles, g.Winner, g.Points, g.Result, w.auto, w.winner, w.points, w.result)
+		}
+		if got := g.PointsWon(g.Result); got != w.points {
+			t.Errorf("game %d: PointsWon = %d, want %d", g.Number, got, w.points)
+		}
+	}
+
+	// The cube decisions of the first game are taken at the doubled stake
+	positions := engine.ConvertMatchActionsToPositions(m.AnalysisActions(), engine.StartingPosition().Board, [2]int{}, m.MatchLength)
+	for _, pos := range positions {
+		if pos.GameNumber == 1 && pos.CubeValue != 2 {
+			t.Errorf("game 1 move %d: cube %d, want 2", pos.MoveNumber, pos.CubeValue)
+		}
+	}
+
+	e, err := engine.NewEngine(engine.EngineOptions{EvenFallback: true})
+	if err != nil {
+		t.Fatalf("NewEngine error: %v", err)
+	}
+	opts := engine.DefaultMatchAnalysisOptions()
+	opts.IncludeLuck = true
+	a, err := m.Analyze(e, opts)
+	if err != nil {
+		t.Fatalf("Analyze error: %v", err)
+	}
+	if a.LuckAdjusted == nil || a.LuckAdjusted.Points != [2]float64{-2, 2} {
+		t.Errorf("point totals = %+v, want Alice -2, Bob +2", a.LuckAdjusted)
+	}
+
+	// Automatic doubles and results survive a MAT round trip
+	var buf bytes.Buffer
+	if err := ExportMAT(&buf, m); err != nil {
+		t.Fatalf("ExportMAT error: %v", err)
+	}
+	again, err := ImportMAT(&buf)
+	if err != nil {
+		t.Fatalf("ImportMAT of export error: %v\n%s", err, buf.String())
+	}
+	for i, g := range again.Games {
+		if g.AutomaticDoubles != m.Games[i].AutomaticDoubles || g.Winner != m.Games[i].Winner || g.Points != m.Games[i].Points {
+			t.Errorf("game %d after a round trip: %d automatic doubles, won by %d for %d points\n%s",
+				g.Number, g.AutomaticDoubles, g.Winner, g.Points, buf.String())
+		}
+	}
+}
+
+func TestMoneyRulesOpeningRoll(t *testing.T) {
+	g := NewGame(1, 0, 0, false)
+	rules := MoneyRules{AutoDoubles: true, Cap: 2}
+	for _, roll := range [][2]int{{3, 3}, {5, 5}, {6, 6}} {
+		if !rules.OpeningRoll(g, roll[0], roll[1]) {
+			t.Errorf("tie %v not rolled again", roll)
+		}
+	}
+	if rules.OpeningRoll(g, 3, 1) {
+		t.Error("31 rolled again")
+	}
+	if g.AutomaticDoubles != 2 || g.Stake() != 4 {
+		t.Errorf("%d automatic doubles for a stake of %d, want 2 capped for 4", g.AutomaticDoubles, g.Stake())
+	}
+
+	plain := NewGame(1, 0, 0, false)
+	MoneyRules{}.OpeningRoll(plain, 4, 4)
+	if plain.AutomaticDoubles != 0 {
+		t.Errorf("automatic double without the rule")
+	}
+}
@@ -0,0 +1,115 @@
+package match
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Tag is a header tag of a match file the importers do not interpret, such
+// as gnubg's ; [CubeLimit "64"] or a site's game clock. Tags are kept in the
+// order they were read and written back on export.
+type Tag struct {
+	Name  string
+	Value string
+}
+
+// dateLayouts are the date formats match files are written with, tried in
+// order. gnubg and GamesGrid write 2003.06.14, SGF files 2003-06-14, FIBS
+// clients the ctime form, and other sites the written-out forms. Dates with
+// the day first use dots (14.06.2003); with slashes, a date whose year is
+// last is read month first (06/14/2003), as sites in the US write it.
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"2006.01.02 15.04",
+	"2006.01.02 15:04:05",
+	"2006.01.02 15:04",
+	"2006.01.02",
+	"2006/01/02",
+	"02.01.2006 15:04",
+	"02.01.2006",
+	"01/02/2006",
+	time.ANSIC,
+	"Mon Jan _2 15:04:05 MST 2006",
+	"Mon, 2 Jan 2006",
+	"Jan 2, 2006 15:04",
+	"Jan 2, 2006",
+	"January 2, 2006",
+	"2 Jan 2006",
+	"2 January 2006",
+}
+
+// ParseMatchDate parses a date as match files write it (see dateLayouts).
+// A date without a zone is taken as UTC. It reports false for a date in
+// none of the formats.
+func ParseMatchDate(s string) (time.Time, bool) {
+	s = strings.Join(strings.Fields(s), " ")
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// timeOfDayRE matches the time of day tags give: 19.01, 19:01 or 19:01:22.
+var timeOfDayRE = regexp.MustCompile(`^(\d{1,2})[.:](\d{2})(?:[.:](\d{2}))?$`)
+
+// withTimeOfDay returns date at the time of day s, as gnubg's EventTime tag
+// writes it, and reports whether s was understood.
+func withTimeOfDay(date time.Time, s string) (time.Time, bool) {
+	m := timeOfDayRE.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return date, false
+	}
+	hour, _ := strconv.Atoi(m[1])
+	minute, _ := strconv.Atoi(m[2])
+	second, _ := strconv.Atoi(m[3])
+	if hour > 23 || minute > 59 || second > 59 {
+		return date, false
+	}
+	y, mo, d := date.Date()
+	return time.Date(y, mo, d, hour, minute, second, 0, date.Location()), true
+}
+
+// ratingRE matches the rating at the start of a rating tag: gnubg and
+// GamesGrid write rating/experience ("1712.85/2345"), other sites the rating
+// alone or with the experience in parentheses ("1875 (312)").
+var ratingRE = regexp.MustCompile(`^\s*(\d+(?:\.\d+)?)`)
+
+// parseRating returns the rating a rating tag gives.
+func parseRating(s string) (float64, bool) {
+	m := ratingRE.FindStringSubmatch(s)
+	if m == nil {
+		return 0, false
+	}
+	r, err := strconv.ParseFloat(m[1], 64)
+	return r, err == nil
+}
+
+// SetDate sets the match date from s as a file records it: DateText keeps s,
+// and Date is s parsed, or zero if it is in no known format.
+func (m *Match) SetDate(s string) {
+	m.DateText = s
+	m.Date, _ = ParseMatchDate(s)
+}
+
+// DateString returns the match date for display: the parsed date as
+// 2006-01-02, or the date as recorded if it could not be parsed.
+func (m *Match) DateString() string {
+	if m.Date.IsZero() {
+		return m.DateText
+	}
+	return m.Date.Format("2006-01-02")
+}
+
+// tagValue returns s made safe to write as a tag value: tags are one line
+// and quoted.
+func tagValue(s string) string {
+	s = strings.ReplaceAll(s, `"`, "'")
+	return strings.Join(strings.Fields(s), " ")
+}
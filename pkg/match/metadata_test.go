@@ -0,0 +1,174 @@
+package match
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// matBody is a one-game match to put after a header block.
+const matBody = " 5 point match\n\n Game 1\n alice : 0                          bob : 0\n  1) 31: 8/5 6/5                    52: 13/11 13/8\n"
+
+func TestImportMATMetadata(t *testing.T) {
+	tests := []struct {
+		site      string
+		header    string
+		date      time.Time
+		dateText  string
+		ratings   [2]float64
+		event     string
+		eventInfo string
+		round     string
+		tags      []Tag
+	}{
+		{
+			site: "GamesGrid",
+			header: ` ; [Site "GamesGrid"]
+ ; [Match ID "80221337"]
+ ; [Player 1 "alice"]
+ ; [Player 2 "bob"]
+ ; [Player 1 Elo "1712.85/2345"]
+ ; [Player 2 Elo "1650.10/812"]
+ ; [EventDate "2003.06.14"]
+ ; [EventTime "19.01"]
+ ; [Variation "Backgammon"]
+ ; [Unrated "Off"]
+ ; [Crawford "On"]
+ ; [CubeLimit "64"]
+`,
+			date:      time.Date(2003, 6, 14, 19, 1, 0, 0, time.UTC),
+			dateText:  "2003.06.14",
+			ratings:   [2]float64{1712.85, 1650.10},
+			eventInfo: "80221337",
+			tags:      []Tag{{"Unrated", "Off"}, {"CubeLimit", "64"}},
+		},
+		{
+			site: "FIBS",
+			header: ` ; [Site "FIBS"]
+ ; [Player 1 "alice"]
+ ; [Player 2 "bob"]
+ ; [Player 1 Rating "1723.45"]
+ ; [Player 2 Rating "1598.02"]
+ ; [Date "Sat Jun 14 19:01:22 2003"]
+`,
+			date:     time.Date(2003, 6, 14, 19, 1, 22, 0, time.UTC),
+			dateText: "Sat Jun 14 19:01:22 2003",
+			ratings:  [2]float64{1723.45, 1598.02},
+		},
+		{
+			site: "Heroes",
+			header: ` ; [Site "BackgammonHeroes"]
+ ; [Event "Heroes Weekly"]
+ ; [Round "3"]
+ ; [Player 1 "alice"]
+ ; [Player 2 "bob"]
+ ; [Player 1 Rating "1875 (312)"]
+ ; [Player 2 Rating "1790 (45)"]
+ ; [Date "14.06.2023 19:01"]
+ ; [Clock "5 min reserve, 12s delay"]
+`,
+			date:     time.Date(2023, 6, 14, 19, 1, 0, 0, time.UTC),
+			dateText: "14.06.2023 19:01",
+			ratings:  [2]float64{1875, 1790},
+			event:    "Heroes Weekly",
+			round:    "3",
+			tags:     []Tag{{"Clock", "5 min reserve, 12s delay"}},
+		},
+		{
+			site: "Galaxy",
+			header: ` ; [Site "Backgammon Galaxy"]
+ ; [Match ID "d41d8cd9"]
+ ; [Player 1 "alice"]
+ ; [Player 2 "bob"]
+ ; [Player 1 Rating "1932"]
+ ; [Player 2 Rating "1801"]
+ ; [Date "2023-06-14T19:01:22Z"]
+ ; [Time Control "fischer 2m+8s"]
+`,
+			date:      time.Date(2023, 6, 14, 19, 1, 22, 0, time.UTC),
+			dateText:  "2023-06-14T19:01:22Z",
+			ratings:   [2]float64{1932, 1801},
+			eventInfo: "d41d8cd9",
+			tags:      []Tag{{"Time Control", "fischer 2m+8s"}},
+		},
+		{
+			site: "unparsed",
+			header: ` ; [Player 1 "alice"]
+ ; [Player 2 "bob"]
+ ; [Player 1 Elo "n/a"]
+ ; [Date "the summer of 2003"]
+ ; [EventTime "19.01"]
+ ; [Note ""]
+`,
+			dateText: "the summer of 2003",
+			tags:     []Tag{{"Player 1 Elo", "n/a"}, {"Note", ""}, {"EventTime", "19.01"}},
+		},
+	}
+
+	for _, tc := range tests {
+		m, err := ImportMAT(strings.NewReader(tc.header + matBody))
+		if err != nil {
+			t.Fatalf("%s: ImportMAT error: %v", tc.site, err)
+		}
+		check := func(what string, m *Match) {
+			t.Helper()
+			if !m.Date.Equal(tc.date) {
+				t.Errorf("%s %s: Date = %v, want %v", tc.site, what, m.Date, tc.date)
+			}
+			if m.PlayerRatings != tc.ratings {
+				t.Errorf("%s %s: PlayerRatings = %v, want %v", tc.site, what, m.PlayerRatings, tc.ratings)
+			}
+			if m.Event != tc.event || m.EventInfo != tc.eventInfo || m.Round != tc.round {
+				t.Errorf("%s %s: Event, EventInfo, Round = %q, %q, %q, want %q, %q, %q",
+					tc.site, what, m.Event, m.EventInfo, m.Round, tc.event, tc.eventInfo, tc.round)
+			}
+			if m.Player1 != "alice" || m.Player2 != "bob" {
+				t.Errorf("%s %s: players %q and %q", tc.site, what, m.Player1, m.Player2)
+			}
+			if len(m.Tags) != len(tc.tags) || len(tc.tags) > 0 && !reflect.DeepEqual(m.Tags, tc.tags) {
+				t.Errorf("%s %s: Tags = %q, want %q", tc.site, what, m.Tags, tc.tags)
+			}
+		}
+		check("import", m)
+		if m.DateText != tc.dateText {
+			t.Errorf("%s: DateText = %q, want %q", tc.site, m.DateText, tc.dateText)
+		}
+
+		// Exported in gnubg's tags, the metadata reads back the same and
+		// no unknown tag is dropped
+		var buf bytes.Buffer
+		if err := ExportMAT(&buf, m); err != nil {
+			t.Fatalf("%s: ExportMAT error: %v", tc.site, err)
+		}
+		if !tc.date.IsZero() && !strings.Contains(buf.String(), tc.date.Format(` ; [EventDate "2006.01.02"]`)) {
+			t.Errorf("%s: export has no EventDate tag:\n%s", tc.site, buf.String())
+		}
+		back, err := ImportMAT(&buf)
+		if err != nil {
+			t.Fatalf("%s: ImportMAT of the export error: %v", tc.site, err)
+		}
+		check("round trip", back)
+		if back.DateString() != m.DateString() || back.Place != m.Place {
+			t.Errorf("%s round trip: date %q, site %q, want %q, %q", tc.site, back.DateString(), back.Place, m.DateString(), m.Place)
+		}
+	}
+}
+
+func TestParseMatchDate(t *testing.T) {
+	want := time.Date(2003, 6, 14, 0, 0, 0, 0, time.UTC)
+	for _, s := range []string{
+		"2003.06.14", "2003-06-14", "2003/06/14", "14.06.2003", "06/14/2003",
+		"Jun 14, 2003", "June 14, 2003", "14 Jun 2003", "14 June 2003", " 2003-06-14 ",
+	} {
+		if got, ok := ParseMatchDate(s); !ok || !got.Equal(want) {
+			t.Errorf("ParseMatchDate(%q) = %v, %t, want %v", s, got, ok, want)
+		}
+	}
+	for _, s := range []string{"", "yesterday", "2003.13.14", "14/06/2003"} {
+		if got, ok := ParseMatchDate(s); ok {
+			t.Errorf("ParseMatchDate(%q) = %v, want no date", s, got)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package match
+
+// MoneyRules are the optional rules of a money session that change how a
+// game starts.
+type MoneyRules struct {
+	// AutoDoubles doubles the centered cube whenever the opening roll is a
+	// tie (automatic doubles). The cube stays centered, so either player
+	// may turn it first.
+	AutoDoubles bool
+
+	// Cap is the most automatic doubles in one game (0 = no limit). Ties
+	// beyond it are rolled again without doubling.
+	Cap int
+}
+
+// OpeningRoll applies the rules to g for an opening roll of die1 by player 1
+// and die2 by player 2, before any move of g. A tie doubles the cube with
+// AutoDoubles, up to Cap, and is rolled again: OpeningRoll reports whether
+// the players roll again.
+func (r MoneyRules) OpeningRoll(g *Game, die1, die2 int) bool {
+	if die1 != die2 {
+		return false
+	}
+	if r.AutoDoubles && (r.Cap <= 0 || g.AutomaticDoubles < r.Cap) {
+		g.AddAutomaticDouble()
+	}
+	return true
+}
@@ -1,10 +1,8 @@
 package match
 
 import (
-	"bufio"
 	"fmt"
 	"io"
-	"regexp"
 	"strconv"
 	"strings"
 
@@ -14,146 +12,320 @@ import (
 // SGF (Smart Game Format) is a standard format for recording games.
 // See: https://www.red-bean.com/sgf/backgammon.html
 //
-// Example SGF:
-// (;FF[4]GM[6]AP[gnubg:1.06.002]
-//  PW[Player1]PB[Player2]
-//  MI[length:7][game:0][ws:0][bs:0]
-//  ;B[31]
-//  ;W[52]
-//  ...)
-
-var (
-	sgfPropertyRE = regexp.MustCompile(`([A-Z]+)\[([^\]]*)\]`)
-)
+// Each game is a game tree in the dialect gnubg writes:
+//
+//  (;FF[4]GM[6]CA[UTF-8]AP[gnubg:1.06.002]
+//   MI[length:7][game:0][ws:0][bs:0]PW[Player1]PB[Player2]
+//   ;W[31hdfd]
+//   ;B[52lnlq]
+//   ;W[double];B[take]
+//   ...)
+//
+// W is player 1 and B player 2. A play is the dice followed by a pair of
+// letters per checker moved, from and to: points are lettered from a, player
+// 2's 1 point, to x, player 1's 1 point, y is the bar and z off. A roll that
+// cannot be played is the dice alone.
+//
+// Analysis is written in gnubg's annotation properties: A lists the candidate
+// plays with their evaluations, DA the cube decision, LU the luck of the roll
+// and BM (bad move, [1] bad or [2] very bad) or DO (doubtful) the skill. An
+// evaluation is "ver 3 E" followed by the probabilities of winning, winning a
+// gammon and a backgammon, losing a gammon and a backgammon, the equity and
+// the evaluation context (plies, deterministic, pruning, noise); DA holds two
+// sets of the probabilities with the cubeless and cubeful equity, for no
+// double and double/take. The layout follows gnubg's sgf.c; it has not been
+// checked against files gnubg saved.
+
+// sgfVersion is the version of gnubg's evaluation strings that is written.
+const sgfVersion = 3
+
+// sgfProperty is a property of an SGF node with its values, unescaped.
+type sgfProperty struct {
+	ID     string
+	Values []string
+}
 
-// ImportSGF reads a match from SGF format.
-func ImportSGF(r io.Reader) (*Match, error) {
-	scanner := bufio.NewScanner(r)
-	var content strings.Builder
+// sgfNode is the properties of a node in the order they appear.
+type sgfNode []sgfProperty
+
+// values returns the values of the property id.
+func (n sgfNode) values(id string) ([]string, bool) {
+	for _, p := range n {
+		if p.ID == id {
+			return p.Values, true
+		}
+	}
+	return nil, false
+}
 
-	for scanner.Scan() {
-		content.WriteString(scanner.Text())
-		content.WriteString("\n")
+// value returns the first value of the property id.
+func (n sgfNode) value(id string) (string, bool) {
+	v, ok := n.values(id)
+	if !ok || len(v) == 0 {
+		return "", false
 	}
+	return v[0], true
+}
 
-	if err := scanner.Err(); err != nil {
+// ImportSGF reads a match from SGF format.
+func ImportSGF(r io.Reader) (*Match, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
 		return nil, fmt.Errorf("reading SGF file: %w", err)
 	}
 
-	return parseSGF(content.String())
+	return parseSGF(string(content))
 }
 
-// parseSGF parses SGF content into a Match.
+// parseSGF parses SGF content into a Match, a game per game tree.
 func parseSGF(content string) (*Match, error) {
 	match := &Match{
 		Games: make([]*Game, 0),
 	}
 
-	// Find all game trees (each starts with '(')
-	games := splitSGFGames(content)
-
-	for i, gameContent := range games {
-		game, err := parseSGFGame(gameContent, i+1)
-		if err != nil {
-			return nil, fmt.Errorf("parsing game %d: %w", i+1, err)
-		}
+	trees, err := parseSGFCollection(content)
+	if err != nil {
+		return nil, err
+	}
 
+	for i, nodes := range trees {
 		// Extract match info from first game
 		if i == 0 {
-			extractMatchInfo(gameContent, match)
+			extractMatchInfo(nodes[0], match)
 		}
 
+		game, err := parseSGFGame(nodes, i+1)
+		if err != nil {
+			return nil, fmt.Errorf("parsing game %d: %w", i+1, err)
+		}
 		match.Games = append(match.Games, game)
 	}
 
 	return match, nil
 }
 
-// splitSGFGames splits SGF content into individual game trees.
-func splitSGFGames(content string) []string {
-	var games []string
-	depth := 0
-	start := -1
+// sgfParser reads the SGF grammar:
+//
+//	Collection = GameTree { GameTree }
+//	GameTree   = "(" Sequence { GameTree } ")"
+//	Sequence   = Node { Node }
+//	Node       = ";" { Property }
+//	Property   = PropIdent PropValue { PropValue }
+//	PropValue  = "[" text "]"
+//
+// Only upper case letters of a property identifier count, as in FF[3] files.
+type sgfParser struct {
+	s   string
+	pos int
+}
+
+// parseSGFCollection returns the main line of each game tree in content:
+// its nodes, following the first variation where the tree branches.
+func parseSGFCollection(content string) ([][]sgfNode, error) {
+	p := &sgfParser{s: content}
+	var trees [][]sgfNode
+
+	for {
+		p.skipSpace()
+		if p.pos == len(p.s) {
+			break
+		}
+		nodes, err := p.tree()
+		if err != nil {
+			return nil, err
+		}
+		trees = append(trees, nodes)
+	}
 
-	for i, ch := range content {
-		if ch == '(' {
-			if depth == 0 {
-				start = i
+	if len(trees) == 0 {
+		return nil, fmt.Errorf("no SGF game tree")
+	}
+	return trees, nil
+}
+
+func (p *sgfParser) skipSpace() {
+	for p.pos < len(p.s) && strings.IndexByte(" \t\r\n", p.s[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+func (p *sgfParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("SGF offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+// tree reads a game tree and returns its main line.
+func (p *sgfParser) tree() ([]sgfNode, error) {
+	if p.pos == len(p.s) || p.s[p.pos] != '(' {
+		return nil, p.errorf("expected '('")
+	}
+	p.pos++
+
+	var nodes []sgfNode
+	for p.skipSpace(); p.pos < len(p.s) && p.s[p.pos] == ';'; p.skipSpace() {
+		node, err := p.node()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	if len(nodes) == 0 {
+		return nil, p.errorf("game tree without nodes")
+	}
+
+	for variation := 0; p.pos < len(p.s) && p.s[p.pos] == '('; variation++ {
+		sub, err := p.tree()
+		if err != nil {
+			return nil, err
+		}
+		if variation == 0 {
+			nodes = append(nodes, sub...)
+		}
+		p.skipSpace()
+	}
+
+	if p.pos == len(p.s) || p.s[p.pos] != ')' {
+		return nil, p.errorf("expected ')'")
+	}
+	p.pos++
+	return nodes, nil
+}
+
+// node reads a node and its properties.
+func (p *sgfParser) node() (sgfNode, error) {
+	p.pos++ // ';'
+	var node sgfNode
+
+	for p.skipSpace(); p.pos < len(p.s) && isLetter(p.s[p.pos]); p.skipSpace() {
+		var id strings.Builder
+		for ; p.pos < len(p.s) && isLetter(p.s[p.pos]); p.pos++ {
+			if c := p.s[p.pos]; c >= 'A' && c <= 'Z' {
+				id.WriteByte(c)
 			}
-			depth++
-		} else if ch == ')' {
-			depth--
-			if depth == 0 && start >= 0 {
-				games = append(games, content[start:i+1])
-				start = -1
+		}
+
+		prop := sgfProperty{ID: id.String()}
+		for p.skipSpace(); p.pos < len(p.s) && p.s[p.pos] == '['; p.skipSpace() {
+			v, err := p.value()
+			if err != nil {
+				return nil, err
 			}
+			prop.Values = append(prop.Values, v)
 		}
+		if len(prop.Values) == 0 {
+			return nil, p.errorf("property %s without a value", prop.ID)
+		}
+		node = append(node, prop)
 	}
+	return node, nil
+}
 
-	return games
+// value reads a property value, removing escapes and soft line breaks.
+func (p *sgfParser) value() (string, error) {
+	start := p.pos
+	p.pos++ // '['
+	var v strings.Builder
+
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		p.pos++
+		switch c {
+		case ']':
+			return v.String(), nil
+		case '\\':
+			if p.pos == len(p.s) {
+				break
+			}
+			next := p.s[p.pos]
+			p.pos++
+			if next == '\n' {
+				continue // Soft line break
+			}
+			if next == '\r' {
+				if p.pos < len(p.s) && p.s[p.pos] == '\n' {
+					p.pos++
+				}
+				continue
+			}
+			v.WriteByte(next)
+		default:
+			v.WriteByte(c)
+		}
+	}
+
+	p.pos = start
+	return "", p.errorf("unterminated property value")
 }
 
-// extractMatchInfo extracts match-level info from SGF properties.
-func extractMatchInfo(content string, match *Match) {
-	props := parseSGFProperties(content)
+func isLetter(c byte) bool {
+	return (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z')
+}
 
-	if pw, ok := props["PW"]; ok {
+// extractMatchInfo extracts match-level info from the root node.
+func extractMatchInfo(root sgfNode, match *Match) {
+	if pw, ok := root.value("PW"); ok {
 		match.Player1 = pw
 	}
-	if pb, ok := props["PB"]; ok {
+	if pb, ok := root.value("PB"); ok {
 		match.Player2 = pb
 	}
-	if dt, ok := props["DT"]; ok {
-		match.Date = dt
+	if dt, ok := root.value("DT"); ok {
+		match.SetDate(dt)
 	}
-	if ev, ok := props["EV"]; ok {
+	if ev, ok := root.value("EV"); ok {
 		match.Event = ev
 	}
-	if pc, ok := props["PC"]; ok {
+	if ro, ok := root.value("RO"); ok {
+		match.Round = ro
+	}
+	if pc, ok := root.value("PC"); ok {
 		match.Place = pc
 	}
-	if an, ok := props["AN"]; ok {
+	if an, ok := root.value("AN"); ok {
 		match.Annotator = an
 	}
-
-	// Parse MI (Match Information) property
-	if mi, ok := props["MI"]; ok {
-		miParts := strings.Split(mi, "][")
-		for _, part := range miParts {
-			kv := strings.SplitN(part, ":", 2)
-			if len(kv) == 2 {
-				switch kv[0] {
-				case "length":
-					match.MatchLength, _ = strconv.Atoi(kv[1])
-				}
-			}
-		}
+	if gc, ok := root.value("GC"); ok {
+		match.Comment = gc
+	}
+	if length, ok := matchInfo(root, "length"); ok {
+		match.MatchLength = length
 	}
 }
 
-// parseSGFProperties extracts all properties from SGF content.
-func parseSGFProperties(content string) map[string]string {
-	props := make(map[string]string)
-
-	matches := sgfPropertyRE.FindAllStringSubmatch(content, -1)
-	for _, m := range matches {
-		if len(m) >= 3 {
-			props[m[1]] = m[2]
+// matchInfo returns the number key of the MI (match information) property,
+// such as MI[length:7][game:0][ws:0][bs:0].
+func matchInfo(root sgfNode, key string) (int, bool) {
+	values, _ := root.values("MI")
+	for _, v := range values {
+		k, n, ok := strings.Cut(v, ":")
+		if ok && k == key {
+			i, err := strconv.Atoi(n)
+			return i, err == nil
 		}
 	}
-
-	return props
+	return 0, false
 }
 
-// parseSGFGame parses a single SGF game tree.
-func parseSGFGame(content string, gameNum int) (*Game, error) {
-	game := NewGame(gameNum, 0, 0, false)
+// sgfGame reads the nodes of a game tree into a game, following the cube.
+type sgfGame struct {
+	game *Game
+	cube int // Cube value
 
-	// Split into nodes (separated by ';')
-	nodes := strings.Split(content, ";")
+	offered  int  // Player whose double awaits a response, -1 if none
+	offer    int  // Value of the cube offered
+	beavered bool // The offered double was beavered
+}
 
-	for _, node := range nodes[1:] { // Skip first empty element
-		if err := parseSGFNode(node, game); err != nil {
+// parseSGFGame parses the nodes of a game tree.
+func parseSGFGame(nodes []sgfNode, gameNum int) (*Game, error) {
+	root := nodes[0]
+	ws, _ := matchInfo(root, "ws")
+	bs, _ := matchInfo(root, "bs")
+	rules, _ := root.value("RU")
+	game := NewGame(gameNum, ws, bs, strings.Contains(rules, "CrawfordGame"))
+
+	g := &sgfGame{game: game, cube: game.CubeValue, offered: -1}
+	for _, node := range nodes {
+		if err := g.node(node); err != nil {
 			return nil, err
 		}
 	}
@@ -161,99 +333,308 @@ func parseSGFGame(content string, gameNum int) (*Game, error) {
 	return game, nil
 }
 
-// parseSGFNode parses a single SGF node and adds actions to the game.
-func parseSGFNode(node string, game *Game) error {
-	props := parseSGFProperties(node)
+// node adds the actions of a node to the game, with the analysis recorded
+// on them.
+func (g *sgfGame) node(node sgfNode) error {
+	for _, prop := range node {
+		player := -1
+		switch prop.ID {
+		case "W":
+			player = 0
+		case "B":
+			player = 1
+		case "D", "T", "P":
+			// Cube actions as written by earlier versions, without the player
+			player = g.legacyCubePlayer(prop.ID)
+		default:
+			continue
+		}
 
-	// Parse dice roll (B or W property with dice)
-	// Format: B[31] means Black rolled 3-1
-	if dice, ok := props["B"]; ok && len(dice) >= 2 {
-		die1, _ := strconv.Atoi(string(dice[0]))
-		die2, _ := strconv.Atoi(string(dice[1]))
-		if die1 >= 1 && die1 <= 6 && die2 >= 1 && die2 <= 6 {
-			game.AddRoll(1, die1, die2) // Black = player 1
+		action, err := g.play(player, prop.ID, prop.Values[0])
+		if err != nil {
+			return err
 		}
-		// Parse move if present (rest of the value)
-		if len(dice) > 2 {
-			move := parseSGFMove(dice[2:], 1)
-			game.AddMove(1, move)
+		if action >= 0 {
+			a := &g.game.Actions[action]
+			a.Analysis = readSGFAnalysis(node, g.game.Number, *a)
 		}
 	}
+	return nil
+}
 
-	if dice, ok := props["W"]; ok && len(dice) >= 2 {
-		die1, _ := strconv.Atoi(string(dice[0]))
-		die2, _ := strconv.Atoi(string(dice[1]))
-		if die1 >= 1 && die1 <= 6 && die2 >= 1 && die2 <= 6 {
-			game.AddRoll(0, die1, die2) // White = player 0
+// legacyCubePlayer returns the player of a D (double), T (take) or P (pass)
+// node: the player on roll doubles and the other responds.
+func (g *sgfGame) legacyCubePlayer(id string) int {
+	if g.offered >= 0 {
+		if id == "D" && g.beavered {
+			return g.offered // Raccoon
 		}
-		if len(dice) > 2 {
-			move := parseSGFMove(dice[2:], 0)
-			game.AddMove(0, move)
+		return 1 - g.offered
+	}
+	for i := len(g.game.Actions) - 1; i >= 0; i-- {
+		if a := g.game.Actions[i]; a.Type == ActionRoll || a.Type == ActionMove {
+			return 1 - a.Player
 		}
 	}
+	return 0
+}
 
-	// Parse cube actions
-	if _, ok := props["D"]; ok {
-		// Double offered
-		game.AddDouble(game.CubeOwner, game.CubeValue*2)
-	}
-	if _, ok := props["T"]; ok {
-		// Take
-		game.AddTake(1 - game.CubeOwner)
-	}
-	if _, ok := props["P"]; ok {
-		// Pass (drop)
-		game.AddPass(1 - game.CubeOwner)
+// play adds what player did in a W or B value (or a legacy D, T or P node)
+// and returns the index of the action the node's analysis belongs to, -1 if
+// none.
+func (g *sgfGame) play(player int, id, value string) (int, error) {
+	game := g.game
+	switch {
+	case id == "D" || value == "double":
+		switch {
+		case g.offered < 0:
+			g.offered, g.offer = player, g.cube*2
+			game.AddDouble(player, g.offer)
+		case player != g.offered && !g.beavered:
+			// Doubled back before taking: a beaver
+			g.beavered = true
+			g.cube = g.offer * 2
+			game.AddBeaver(player, g.cube)
+		case player == g.offered && g.beavered:
+			g.cube *= 2
+			game.AddRaccoon(player, g.cube)
+		default:
+			return -1, fmt.Errorf("%s doubles again with a double pending", sgfColor(player))
+		}
+
+	case id == "T" || value == "take":
+		if g.offered < 0 {
+			return -1, fmt.Errorf("%s takes without a double", sgfColor(player))
+		}
+		if g.beavered {
+			// The beaver or raccoon is accepted; the beaver already took
+			g.offered, g.beavered = -1, false
+			return -1, nil
+		}
+		g.cube = g.offer
+		g.offered = -1
+		game.AddTake(player)
+
+	case id == "P" || value == "drop":
+		if g.offered < 0 {
+			return -1, fmt.Errorf("%s drops without a double", sgfColor(player))
+		}
+		g.offered = -1
+		game.AddPass(player)
+
+	case len(value) >= 2 && value[0] >= '1' && value[0] <= '6' && value[1] >= '1' && value[1] <= '6':
+		if g.beavered {
+			// A beaver or raccoon the doubler accepted by playing on
+			g.offered, g.beavered = -1, false
+		}
+		game.AddRoll(player, int(value[0]-'0'), int(value[1]-'0'))
+		if len(value) == 2 {
+			return len(game.Actions) - 1, nil
+		}
+		move, err := parseSGFMove(value[2:], player)
+		if err != nil {
+			return -1, fmt.Errorf("%s move %q: %w", sgfColor(player), value, err)
+		}
+		game.AddMove(player, move)
+
+	default:
+		// Resignations and other records are not part of the match
+		return -1, nil
 	}
+	return len(game.Actions) - 1, nil
+}
 
-	return nil
+func sgfColor(player int) string {
+	return [...]string{"W", "B"}[player]
 }
 
-// parseSGFMove parses SGF move notation.
-// SGF uses letters for points: a=1, b=2, ..., x=24, y=bar, z=off
-func parseSGFMove(moveStr string, player int) engine.Move {
+// parseSGFMove parses the letters of a play into a move in the match
+// representation.
+func parseSGFMove(letters string, player int) (engine.Move, error) {
 	move := engine.Move{
 		From: [4]int8{-1, -1, -1, -1},
 		To:   [4]int8{-1, -1, -1, -1},
 	}
+	if len(letters)%2 != 0 || len(letters) > 8 {
+		return move, fmt.Errorf("want up to 4 pairs of points")
+	}
+
+	for i := 0; i < len(letters); i += 2 {
+		from, ok := sgfPointFrom(letters[i], player)
+		to, ok2 := sgfPointFrom(letters[i+1], player)
+		if !ok || !ok2 || letters[i] == 'z' || letters[i+1] == 'y' {
+			return move, fmt.Errorf("invalid points %q", letters[i:i+2])
+		}
+		move.From[i/2] = int8(from)
+		move.To[i/2] = int8(to)
+	}
+
+	return move, nil
+}
+
+// sgfPointFrom converts an SGF point letter to a point of the match
+// representation for player: a is 24 and x is 1, y player's bar and z off.
+func sgfPointFrom(c byte, player int) (int, bool) {
+	switch {
+	case c == 'y':
+		return matchPoint(engine.BarPoint, player), true
+	case c == 'z':
+		return matchPoint(engine.OffPoint, player), true
+	case c >= 'a' && c <= 'x':
+		return 24 - int(c-'a'), true
+	}
+	return 0, false
+}
+
+// sgfPoint converts a point of the match representation to its SGF letter.
+func sgfPoint(point, player int) byte {
+	switch {
+	case point == matchPoint(engine.BarPoint, player):
+		return 'y'
+	case point == matchPoint(engine.OffPoint, player):
+		return 'z'
+	}
+	return byte('a' + 24 - point)
+}
+
+// formatMoveSGF formats a move of the match representation in SGF notation.
+func formatMoveSGF(move engine.Move, player int) string {
+	var result strings.Builder
+
+	for i := 0; i < 4; i++ {
+		if move.From[i] < 0 {
+			break
+		}
+		result.WriteByte(sgfPoint(int(move.From[i]), player))
+		result.WriteByte(sgfPoint(int(move.To[i]), player))
+	}
+
+	return result.String()
+}
+
+// readSGFAnalysis returns the analysis gnubg recorded on the node of action,
+// or nil if there is none. Values that cannot be read are left out.
+func readSGFAnalysis(node sgfNode, gameNum int, action Action) *engine.DecisionAnalysis {
+	d := &engine.DecisionAnalysis{
+		GameNumber: gameNum,
+		Player:     action.Player,
+		CubeAction: decisionCubeAction(action),
+		Played:     -1,
+		Skill:      engine.SkillNone,
+	}
+	found := false
+
+	if values, ok := node.values("A"); ok && action.Type == ActionMove && len(values) > 1 {
+		played, err := strconv.Atoi(values[0])
+		for _, v := range values[1:] {
+			letters, eval, _ := strings.Cut(v, " ")
+			move, err := parseSGFMove(letters, action.Player)
+			if err != nil {
+				continue
+			}
+			m := engine.MoveWithEval{Move: engineMove(move, action.Player)}
+			if numbers, plies, ok := readSGFEval(eval); ok && len(numbers) >= 6 {
+				m.Eval = sgfEvaluation(numbers)
+				m.Equity = m.Eval.Equity
+				m.Ply = plies
+			}
+			d.Moves = append(d.Moves, m)
+		}
+		if err == nil && played >= 0 && played < len(d.Moves) {
+			d.Played = played
+		}
+		found = len(d.Moves) > 0
+	}
+
+	if v, ok := node.value("DA"); ok {
+		if numbers, plies, ok := readSGFEval(v); ok && len(numbers) >= 14 {
+			d.Cube = &engine.CubeEquities{
+				Eval:       *sgfEvaluation(numbers),
+				NoDouble:   numbers[6],
+				DoubleTake: numbers[13],
+				Plies:      plies,
+			}
+			found = true
+		}
+	}
 
-	// Each move is 2 characters (from, to)
-	moveIdx := 0
-	for i := 0; i+1 < len(moveStr) && moveIdx < 4; i += 2 {
-		from := sgfPointToInt(moveStr[i], player)
-		to := sgfPointToInt(moveStr[i+1], player)
-		if from >= 0 && to >= -1 {
-			move.From[moveIdx] = int8(from)
-			move.To[moveIdx] = int8(to)
-			moveIdx++
+	if v, ok := node.value("LU"); ok {
+		if luck, err := strconv.ParseFloat(v, 64); err == nil {
+			d.Luck = &luck
+			found = true
 		}
 	}
 
-	return move
+	if v, ok := node.value("BM"); ok {
+		d.Skill = engine.SkillBad
+		if v == "2" {
+			d.Skill = engine.SkillVeryBad
+		}
+		found = true
+	} else if _, ok := node.values("DO"); ok {
+		d.Skill = engine.SkillDoubtful
+		found = true
+	}
+
+	if !found {
+		return nil
+	}
+	return d
+}
+
+// decisionCubeAction returns the engine's cube action for a cube action of
+// the match, or NoDouble for a roll or move.
+func decisionCubeAction(a Action) engine.CubeAction {
+	switch a.Type {
+	case ActionDouble, ActionTake, ActionPass, ActionBeaver, ActionRaccoon:
+		return cubeActionFor(a.Type)
+	}
+	return engine.NoDouble
 }
 
-// sgfPointToInt converts SGF point notation to internal point number.
-func sgfPointToInt(ch byte, player int) int {
-	if ch == 'y' {
-		// Bar
-		return 24
+// readSGFEval reads an evaluation string, "ver 3 E" and its numbers, the last
+// four of them the evaluation context. It returns the numbers before the
+// context and the plies of the evaluation. Rollouts are not read.
+func readSGFEval(s string) ([]float64, int, bool) {
+	fields := strings.Fields(s)
+	if len(fields) >= 2 && fields[0] == "ver" {
+		fields = fields[2:]
+	}
+	if len(fields) < 5 || fields[0] != "E" {
+		return nil, 0, false
 	}
-	if ch == 'z' {
-		// Bear off
-		return -1
+	fields = fields[1:]
+
+	context := fields[len(fields)-4:]
+	plies, err := strconv.Atoi(strings.TrimSuffix(context[0], "C"))
+	if err != nil {
+		return nil, 0, false
 	}
-	if ch >= 'a' && ch <= 'x' {
-		point := int(ch - 'a' + 1)
-		// Convert to internal representation
-		if player == 0 {
-			return 25 - point
+
+	numbers := make([]float64, len(fields)-4)
+	for i := range numbers {
+		if numbers[i], err = strconv.ParseFloat(fields[i], 64); err != nil {
+			return nil, 0, false
 		}
-		return point - 1
 	}
-	return -2 // Invalid
+	return numbers, plies, true
 }
 
-// ExportSGF writes a match in SGF format.
+// sgfEvaluation returns the evaluation of the first six numbers of an
+// evaluation string: the five probabilities and the equity.
+func sgfEvaluation(numbers []float64) *engine.Evaluation {
+	return &engine.Evaluation{
+		WinProb: numbers[0],
+		WinG:    numbers[1],
+		WinBG:   numbers[2],
+		LoseG:   numbers[3],
+		LoseBG:  numbers[4],
+		Equity:  numbers[5],
+	}
+}
+
+// ExportSGF writes a match in SGF format, with the analysis recorded on its
+// actions (see Match.Annotate) in gnubg's annotation properties.
 func ExportSGF(w io.Writer, match *Match) error {
 	for _, game := range match.Games {
 		if err := exportGameSGF(w, match, game); err != nil {
@@ -263,97 +644,143 @@ func ExportSGF(w io.Writer, match *Match) error {
 	return nil
 }
 
+// sgfText escapes a property value.
+func sgfText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `]`, `\]`).Replace(s)
+}
+
 // exportGameSGF writes a single game in SGF format.
 func exportGameSGF(w io.Writer, match *Match, game *Game) error {
-	// Write game tree header
-	fmt.Fprintf(w, "(;FF[4]GM[6]AP[bgengine:1.0]\n")
-
-	// Write player names
-	fmt.Fprintf(w, "PW[%s]PB[%s]\n", match.Player1, match.Player2)
+	var b strings.Builder
 
-	// Write match info
-	fmt.Fprintf(w, "MI[length:%d][game:%d][ws:%d][bs:%d]\n",
+	// Write game tree header
+	b.WriteString("(;FF[4]GM[6]CA[UTF-8]AP[bgengine:1.0]\n")
+	fmt.Fprintf(&b, "MI[length:%d][game:%d][ws:%d][bs:%d]",
 		match.MatchLength, game.Number-1, game.Score1, game.Score2)
-
-	// Write date if available
-	if match.Date != "" {
-		fmt.Fprintf(w, "DT[%s]\n", match.Date)
-	}
-	if match.Event != "" {
-		fmt.Fprintf(w, "EV[%s]\n", match.Event)
+	fmt.Fprintf(&b, "PW[%s]PB[%s]", sgfText(match.Player1), sgfText(match.Player2))
+	for _, prop := range []struct{ id, value string }{
+		{"DT", match.DateString()}, {"EV", match.Event}, {"RO", match.Round},
+		{"PC", match.Place}, {"AN", match.Annotator}, {"GC", match.Comment},
+	} {
+		if prop.value != "" {
+			fmt.Fprintf(&b, "%s[%s]", prop.id, sgfText(prop.value))
+		}
 	}
-	if match.Place != "" {
-		fmt.Fprintf(w, "PC[%s]\n", match.Place)
+	if match.MatchLength > 0 {
+		if game.Crawford {
+			b.WriteString("RU[Crawford:CrawfordGame]")
+		} else {
+			b.WriteString("RU[Crawford]")
+		}
 	}
+	b.WriteString("\n")
 
 	// Write game actions
-	var currentRoll [2]int
-	var currentPlayer int
+	var roll *Action
+	pending := false // A roll has not been played yet
+	moves := 0       // Plays written so far
+
+	flush := func() {
+		// An unplayed roll before the first play from the starting position
+		// is the opening roll as recorded for the player who lost it
+		if pending && !(moves == 0 && engine.IsStartingPosition(game.InitialBoard)) {
+			fmt.Fprintf(&b, ";%s[%d%d]", sgfColor(roll.Player), roll.Dice[0], roll.Dice[1])
+			writeSGFAnalysis(&b, roll.Analysis, roll.Player)
+			b.WriteString("\n")
+			moves++
+		}
+		pending = false
+	}
 
-	for _, action := range game.Actions {
+	for i := range game.Actions {
+		action := &game.Actions[i]
 		switch action.Type {
 		case ActionRoll:
-			currentRoll = action.Dice
-			currentPlayer = action.Player
+			flush()
+			roll = action
+			pending = true
 
 		case ActionMove:
-			playerChar := "W"
-			if currentPlayer == 1 {
-				playerChar = "B"
+			dice := [2]int{}
+			analysis := action.Analysis
+			if roll != nil {
+				dice = roll.Dice
+				if analysis == nil {
+					analysis = roll.Analysis
+				}
 			}
-			moveStr := formatMoveSGF(action.Move, currentPlayer)
-			fmt.Fprintf(w, ";%s[%d%d%s]\n", playerChar, currentRoll[0], currentRoll[1], moveStr)
-
-		case ActionDouble:
-			fmt.Fprintf(w, ";D[]\n")
-
-		case ActionTake:
-			fmt.Fprintf(w, ";T[]\n")
-
-		case ActionPass:
-			fmt.Fprintf(w, ";P[]\n")
+			fmt.Fprintf(&b, ";%s[%d%d%s]", sgfColor(action.Player), dice[0], dice[1], formatMoveSGF(action.Move, action.Player))
+			writeSGFAnalysis(&b, analysis, action.Player)
+			b.WriteString("\n")
+			pending = false
+			moves++
+
+		case ActionDouble, ActionBeaver, ActionRaccoon, ActionTake, ActionPass:
+			// A beaver is a double by the player doubled, a raccoon a double
+			// back by the doubler
+			flush()
+			value := "double"
+			switch action.Type {
+			case ActionTake:
+				value = "take"
+			case ActionPass:
+				value = "drop"
+			}
+			fmt.Fprintf(&b, ";%s[%s]", sgfColor(action.Player), value)
+			writeSGFAnalysis(&b, action.Analysis, action.Player)
+			b.WriteString("\n")
 		}
 	}
+	flush()
 
 	// Close game tree
-	fmt.Fprintf(w, ")\n")
-	return nil
+	b.WriteString(")\n")
+	_, err := io.WriteString(w, b.String())
+	return err
 }
 
-// formatMoveSGF formats a move in SGF notation.
-func formatMoveSGF(move engine.Move, player int) string {
-	var result strings.Builder
-
-	for i := 0; i < 4; i++ {
-		if move.From[i] < 0 {
-			break
-		}
-		result.WriteByte(intToSGFPoint(int(move.From[i]), player))
-		result.WriteByte(intToSGFPoint(int(move.To[i]), player))
+// writeSGFAnalysis writes the annotation properties of d, the analysis of a
+// decision by player.
+func writeSGFAnalysis(b *strings.Builder, d *engine.DecisionAnalysis, player int) {
+	if d == nil {
+		return
 	}
 
-	return result.String()
-}
-
-// intToSGFPoint converts internal point number to SGF notation.
-func intToSGFPoint(point int, player int) byte {
-	if point == 24 || point == 25 {
-		return 'y' // Bar
+	if c := d.Cube; c != nil {
+		e := c.Eval
+		probs := fmt.Sprintf("%.4f %.4f %.4f %.4f %.4f %.4f", e.WinProb, e.WinG, e.WinBG, e.LoseG, e.LoseBG, e.Equity)
+		fmt.Fprintf(b, "DA[ver %d E %s %.4f %s %.4f %s]", sgfVersion, probs, c.NoDouble, probs, c.DoubleTake, sgfEvalContext(c.Plies))
 	}
-	if point < 0 {
-		return 'z' // Bear off
+
+	if len(d.Moves) > 0 && d.Played >= 0 {
+		fmt.Fprintf(b, "A[%d]", d.Played)
+		for _, m := range d.Moves {
+			letters := formatMoveSGF(matchMove(m.Move, player), player)
+			if e := m.Eval; e != nil {
+				fmt.Fprintf(b, "[%s ver %d E %.4f %.4f %.4f %.4f %.4f %.4f %s]", letters, sgfVersion,
+					e.WinProb, e.WinG, e.WinBG, e.LoseG, e.LoseBG, m.Equity, sgfEvalContext(m.Ply))
+			} else {
+				fmt.Fprintf(b, "[%s -]", letters)
+			}
+		}
 	}
 
-	// Convert from internal to SGF (a=1, b=2, ..., x=24)
-	var sgfPoint int
-	if player == 0 {
-		sgfPoint = 25 - point
-	} else {
-		sgfPoint = point + 1
+	if d.Luck != nil {
+		fmt.Fprintf(b, "LU[%+f]", *d.Luck)
 	}
 
-	if sgfPoint >= 1 && sgfPoint <= 24 {
-		return byte('a' + sgfPoint - 1)
+	switch d.Skill {
+	case engine.SkillVeryBad:
+		b.WriteString("BM[2]")
+	case engine.SkillBad:
+		b.WriteString("BM[1]")
+	case engine.SkillDoubtful:
+		b.WriteString("DO[]")
 	}
-	return 'z'
+}
+
+// sgfEvalContext returns the evaluation context of an evaluation at plies:
+// cubeless, deterministic, without pruning or noise.
+func sgfEvalContext(plies int) string {
+	return fmt.Sprintf("%d 1 0 %.4f", plies, 0.0)
 }
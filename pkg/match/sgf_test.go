@@ -0,0 +1,335 @@
+package match
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/yourusername/bgengine/pkg/engine"
+)
+
+// The SGF tests check the dialect gnubg writes as described in sgf.go; there
+// are no files saved by gnubg to test against.
+
+var (
+	// sgfGrammarRE matches a collection of game trees without variations,
+	// independently of the parser
+	sgfGrammarRE = regexp.MustCompile(`^(\s*\(\s*(;\s*([A-Z]+(\s*\[(\\.|[^\\\]])*\])+\s*)*)+\)\s*)+$`)
+	sgfPropRE    = regexp.MustCompile(`([A-Z]+)((?:\s*\[(?:\\.|[^\\\]])*\])+)`)
+	sgfValueRE   = regexp.MustCompile(`\[((?:\\.|[^\\\]])*)\]`)
+
+	sgfEvalRE = `ver 3 E( -?\d+\.\d{4}){%d} \d+C? [01] [01] \d+\.\d{4}`
+
+	// sgfDialect is the form of each property's values in gnubg's dialect
+	sgfDialect = map[string]*regexp.Regexp{
+		"FF": regexp.MustCompile(`^4$`),
+		"GM": regexp.MustCompile(`^6$`),
+		"CA": regexp.MustCompile(`^UTF-8$`),
+		"AP": regexp.MustCompile(`^.+$`),
+		"MI": regexp.MustCompile(`^(length|game|ws|bs):\d+$`),
+		"PW": regexp.MustCompile(`^.*$`),
+		"PB": regexp.MustCompile(`^.*$`),
+		"DT": regexp.MustCompile(`^.*$`),
+		"EV": regexp.MustCompile(`^.*$`),
+		"PC": regexp.MustCompile(`^.*$`),
+		"RU": regexp.MustCompile(`^Crawford(:CrawfordGame)?$`),
+		"W":  regexp.MustCompile(`^([1-6][1-6]([a-y][a-z]){0,4}|double|take|drop)$`),
+		"B":  regexp.MustCompile(`^([1-6][1-6]([a-y][a-z]){0,4}|double|take|drop)$`),
+		"A":  regexp.MustCompile(`^(\d+|([a-y][a-z]){1,4} (-|` + strings.Replace(sgfEvalRE, "%d", "6", 1) + `))$`),
+		"DA": regexp.MustCompile(`^` + strings.Replace(sgfEvalRE, "%d", "14", 1) + `$`),
+		"LU": regexp.MustCompile(`^[+-]\d+\.\d{6}$`),
+		"BM": regexp.MustCompile(`^[12]$`),
+		"DO": regexp.MustCompile(`^$`),
+	}
+)
+
+// validateSGF checks that s is a collection of game trees whose properties
+// are written as gnubg reads them.
+func validateSGF(t *testing.T, s string) {
+	t.Helper()
+	if !sgfGrammarRE.MatchString(s) {
+		t.Fatalf("not an SGF collection:\n%s", s)
+	}
+	for _, m := range sgfPropRE.FindAllStringSubmatch(s, -1) {
+		re, ok := sgfDialect[m[1]]
+		if !ok {
+			t.Errorf("unexpected property %s", m[0])
+			continue
+		}
+		for _, v := range sgfValueRE.FindAllStringSubmatch(m[2], -1) {
+			if !re.MatchString(v[1]) {
+				t.Errorf("property %s value %q does not match %s", m[1], v[1], re)
+			}
+		}
+	}
+}
+
+// sameActions reports whether two games have the same actions, ignoring the
+// analysis on them and the cube values MAT files do not record.
+func sameActions(a, b []Action) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Type != b[i].Type || a[i].Player != b[i].Player || a[i].Dice != b[i].Dice || a[i].Move != b[i].Move {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSGFMoveLetters(t *testing.T) {
+	m := NewMatch("Alice", "Bob", 5)
+	game := NewGame(1, 0, 0, false)
+	game.AddRoll(0, 3, 1)
+	game.AddMove(0, engine.Move{From: [4]int8{8, 6, -1, -1}, To: [4]int8{5, 5, -1, -1}})
+	game.AddRoll(1, 5, 2)
+	game.AddMove(1, engine.Move{From: [4]int8{12, 12, -1, -1}, To: [4]int8{14, 17, -1, -1}})
+	game.AddRoll(0, 6, 6)
+	game.AddRoll(1, 2, 1)
+	game.AddMove(1, engine.Move{From: [4]int8{0, 24, -1, -1}, To: [4]int8{2, 25, -1, -1}})
+	m.Games = append(m.Games, game)
+
+	var buf bytes.Buffer
+	if err := ExportSGF(&buf, m); err != nil {
+		t.Fatalf("ExportSGF error: %v", err)
+	}
+	out := buf.String()
+	validateSGF(t, out)
+
+	// Player 1's 8/5 6/5, player 2's 13/11 13/8, a dance and player 2's
+	// bar/23 1/off, lettered as gnubg does
+	for _, want := range []string{";W[31qtst]", ";B[52mkmh]", ";W[66]", ";B[21ywaz]"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("export missing %s:\n%s", want, out)
+		}
+	}
+
+	again, err := ImportSGF(&buf)
+	if err != nil {
+		t.Fatalf("ImportSGF error: %v", err)
+	}
+	if !sameActions(again.Games[0].Actions, game.Actions) {
+		t.Errorf("actions after a round trip:\n%+v\nwant\n%+v", again.Games[0].Actions, game.Actions)
+	}
+}
+
+func TestSGFRoundTrip(t *testing.T) {
+	for _, name := range []string{"beavers.mat", "opening.mat"} {
+		f, err := os.Open("testdata/" + name)
+		if err != nil {
+			t.Fatalf("open fixture: %v", err)
+		}
+		m, err := ImportMAT(f)
+		f.Close()
+		if err != nil {
+			t.Fatalf("%s: ImportMAT error: %v", name, err)
+		}
+
+		var buf bytes.Buffer
+		if err := ExportSGF(&buf, m); err != nil {
+			t.Fatalf("%s: ExportSGF error: %v", name, err)
+		}
+		validateSGF(t, buf.String())
+
+		again, err := ImportSGF(&buf)
+		if err != nil {
+			t.Fatalf("%s: ImportSGF error: %v", name, err)
+		}
+		if again.Player1 != m.Player1 || again.Player2 != m.Player2 || again.MatchLength != m.MatchLength || len(again.Games) != len(m.Games) {
+			t.Fatalf("%s: imported %+v, want %+v", name, again, m)
+		}
+		for i, g := range m.Games {
+			got := again.Games[i]
+			if got.Score1 != g.Score1 || got.Score2 != g.Score2 || got.Crawford != g.Crawford {
+				t.Errorf("%s game %d: score %d-%d Crawford %v, want %d-%d %v", name, i+1, got.Score1, got.Score2, got.Crawford, g.Score1, g.Score2, g.Crawford)
+			}
+			if !sameActions(got.Actions, g.Actions) {
+				t.Errorf("%s game %d actions after a round trip:\n%+v\nwant\n%+v", name, i+1, got.Actions, g.Actions)
+			}
+		}
+	}
+
+	// The beaver is Bob's double back and the raccoon Alice's
+	f, _ := os.ReadFile("testdata/beavers.mat")
+	m, _ := ImportMAT(bytes.NewReader(f))
+	var buf bytes.Buffer
+	ExportSGF(&buf, m)
+	if !strings.Contains(buf.String(), ";W[double]\n;B[double]\n;W[double]\n") {
+		t.Errorf("beaver and raccoon not written as doubles:\n%s", buf.String())
+	}
+}
+
+func TestSGFAnalysisRoundTrip(t *testing.T) {
+	e, err := engine.NewEngine(engine.EngineOptions{})
+	if err != nil {
+		t.Fatalf("NewEngine error: %v", err)
+	}
+
+	for _, name := range []string{"beavers.mat", "opening.mat"} {
+		f, err := os.Open("testdata/" + name)
+		if err != nil {
+			t.Fatalf("open fixture: %v", err)
+		}
+		m, err := ImportMAT(f)
+		f.Close()
+		if err != nil {
+			t.Fatalf("%s: ImportMAT error: %v", name, err)
+		}
+
+		opts := engine.DefaultMatchAnalysisOptions()
+		opts.Decisions = true
+		opts.IncludeLuck = true
+		analysis, err := m.Analyze(e, opts)
+		if err != nil {
+			t.Fatalf("%s: Analyze error: %v", name, err)
+		}
+		if len(analysis.Decisions) != analysis.TotalMoves+analysis.TotalCubeActs {
+			t.Fatalf("%s: %d decisions for %d moves and %d cube actions", name, len(analysis.Decisions), analysis.TotalMoves, analysis.TotalCubeActs)
+		}
+		m.Annotate(analysis)
+
+		var buf bytes.Buffer
+		if err := ExportSGF(&buf, m); err != nil {
+			t.Fatalf("%s: ExportSGF error: %v", name, err)
+		}
+		out := buf.String()
+		validateSGF(t, out)
+		for _, prop := range []string{"A[", "DA[", "LU["} {
+			if !strings.Contains(out, prop) {
+				t.Errorf("%s: export has no %s property:\n%s", name, prop, out)
+			}
+		}
+
+		again, err := ImportSGF(&buf)
+		if err != nil {
+			t.Fatalf("%s: ImportSGF error: %v", name, err)
+		}
+
+		// Every decision comes back on its position
+		positions := engine.ConvertMatchActionsToPositions(again.AnalysisActions(), engine.StartingPosition().Board, [2]int{}, again.MatchLength)
+		i := 0
+		for _, pos := range positions {
+			if pos.Move != nil && pos.CubeAction != engine.NoDouble {
+				t.Fatalf("%s: position with both a move and a cube action", name)
+			}
+			if pos.Annotation == nil {
+				continue
+			}
+			if i == len(analysis.Decisions) {
+				t.Fatalf("%s: more annotations than decisions", name)
+			}
+			compareDecisions(t, name, pos.Annotation, &analysis.Decisions[i])
+			i++
+		}
+		if i != len(analysis.Decisions) {
+			t.Errorf("%s: %d annotated positions, want %d", name, i, len(analysis.Decisions))
+		}
+	}
+}
+
+// compareDecisions checks that got, read from an SGF file, is the decision
+// want as far as the file records it.
+func compareDecisions(t *testing.T, name string, got, want *engine.DecisionAnalysis) {
+	t.Helper()
+	near := func(a, b float64) bool { return math.Abs(a-b) <= 5e-5 }
+	where := func() string {
+		return fmt.Sprintf("%s: game %d move %d %s", name, want.GameNumber, want.MoveNumber, want.CubeAction)
+	}
+
+	if got.Player != want.Player || got.CubeAction != want.CubeAction || got.Skill != want.Skill || got.Played != want.Played || len(got.Moves) != len(want.Moves) {
+		t.Errorf("%s: decision %+v, want %+v", where(), got, want)
+		return
+	}
+	for j, m := range want.Moves {
+		g := got.Moves[j]
+		if engine.FormatMove(g.Move) != engine.FormatMove(m.Move) || g.Eval == nil || !near(g.Equity, m.Equity) ||
+			!near(g.Eval.WinProb, m.Eval.WinProb) || !near(g.Eval.WinG, m.Eval.WinG) || !near(g.Eval.LoseBG, m.Eval.LoseBG) {
+			t.Errorf("%s: candidate %d = %s %+v, want %s %+v", where(), j, engine.FormatMove(g.Move), g.Eval, engine.FormatMove(m.Move), m.Eval)
+		}
+	}
+	if (got.Luck == nil) != (want.Luck == nil) || (want.Luck != nil && math.Abs(*got.Luck-*want.Luck) > 5e-7) {
+		t.Errorf("%s: luck %v, want %v", where(), got.Luck, want.Luck)
+	}
+	if (got.Cube == nil) != (want.Cube == nil) {
+		t.Errorf("%s: cube %+v, want %+v", where(), got.Cube, want.Cube)
+	} else if c := want.Cube; c != nil {
+		g := got.Cube
+		if !near(g.NoDouble, c.NoDouble) || !near(g.DoubleTake, c.DoubleTake) || !near(g.Eval.WinProb, c.Eval.WinProb) || !near(g.Eval.Equity, c.Eval.Equity) {
+			t.Errorf("%s: cube %+v, want %+v", where(), g, c)
+		}
+	}
+}
+
+func TestImportSGFAnnotations(t *testing.T) {
+	content := `(;FF[4]GM[6]CA[UTF-8]AP[GNU Backgammon:1.06.002]
+MI[length:5][game:2][ws:3][bs:1]PW[Al\]ice]PB[Bob]RU[Crawford:CrawfordGame]
+;W[31qtst]A[0][qtst ver 3 E 0.5500 0.1600 0.0100 0.1200 0.0050 0.0800 2C 1 1 0.0000][adab ver 3 E 0.5400 0.1500 0.0100 0.1200 0.0050 0.0600 2C 1 1 0.0000]LU[+0.123456]
+;B[52mkmh]A[1][mhkh ver 3 E 0.4600 0.1200 0.0050 0.1500 0.0100 -0.0500 0 1 0 0.0000][mkmh -]BM[2]
+;W[double]DA[ver 3 E 0.7000 0.2000 0.0000 0.0500 0.0000 0.4500 0.6000 0.7000 0.2000 0.0000 0.0500 0.0000 0.4500 0.5500 2 1 0 0.0000]DO[]
+;B[take]
+(;W[64]C[a variation]))`
+
+	m, err := ImportSGF(strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("ImportSGF error: %v", err)
+	}
+	if m.Player1 != "Al]ice" || m.MatchLength != 5 {
+		t.Errorf("match %q length %d", m.Player1, m.MatchLength)
+	}
+	g := m.Games[0]
+	if g.Score1 != 3 || g.Score2 != 1 || !g.Crawford {
+		t.Errorf("game score %d-%d Crawford %v, want 3-1 Crawford", g.Score1, g.Score2, g.Crawford)
+	}
+	if n := len(g.Actions); n != 7 || g.Actions[6].Type != ActionRoll {
+		t.Fatalf("actions %+v, want 2 plays, a double, a take and the variation's roll", g.Actions)
+	}
+
+	first := g.Actions[1].Analysis
+	if first == nil || first.Played != 0 || len(first.Moves) != 2 || first.Luck == nil || *first.Luck != 0.123456 || first.Skill != engine.SkillNone {
+		t.Fatalf("first play analysis %+v", first)
+	}
+	if got := engine.FormatMove(first.Moves[1].Move); got != "24/21 24/23" {
+		t.Errorf("second candidate %s, want 24/21 24/23", got)
+	}
+	if m := first.Moves[0]; m.Equity != 0.08 || m.Ply != 2 || m.Eval.WinG != 0.16 {
+		t.Errorf("first candidate %+v %+v", m, m.Eval)
+	}
+
+	second := g.Actions[3].Analysis
+	if second == nil || second.Played != 1 || second.Skill != engine.SkillVeryBad || second.Moves[1].Eval != nil {
+		t.Fatalf("second play analysis %+v", second)
+	}
+	if got := engine.FormatMove(second.Moves[1].Move); got != "13/11 13/8" {
+		t.Errorf("played candidate %s, want 13/11 13/8", got)
+	}
+
+	double := g.Actions[4].Analysis
+	if double == nil || double.Cube == nil || double.Skill != engine.SkillDoubtful || double.CubeAction != engine.Double {
+		t.Fatalf("double analysis %+v", double)
+	}
+	if c := double.Cube; c.NoDouble != 0.6 || c.DoubleTake != 0.55 || c.Eval.WinProb != 0.7 || c.Eval.Equity != 0.45 || c.Plies != 2 {
+		t.Errorf("cube %+v", c)
+	}
+	if g.Actions[5].Analysis != nil {
+		t.Errorf("take without annotation has analysis %+v", g.Actions[5].Analysis)
+	}
+
+	for _, bad := range []string{
+		"",
+		"(;W[31]",
+		"(;W[31)",
+		"(;W)",
+		"()",
+		"(;W[31zz])",
+		"(;B[take])",
+	} {
+		if _, err := ImportSGF(strings.NewReader(bad)); err == nil {
+			t.Errorf("ImportSGF(%q) succeeded", bad)
+		}
+	}
+}
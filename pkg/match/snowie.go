@@ -0,0 +1,212 @@
+package match
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/yourusername/bgengine/pkg/engine"
+)
+
+// Snowie text is the single-position export format of Snowie, also read and
+// written by gnubg. A position is one line of semicolon-separated fields:
+//
+//	 0      match length (0 = money game)
+//	 1      Jacoby rule (money games)
+//	 2, 3   unused (0)
+//	 4      player on roll (0 or 1)
+//	 5, 6   names of player 0 and player 1
+//	 7      Crawford game (0 or 1)
+//	 8, 9   scores of player 0 and player 1
+//	10      cube value
+//	11      cube owner: 1 = player on roll, -1 = opponent, 0 = centered
+//	12      checkers on the bar of the player on roll
+//	13-36   points 1-24 as seen by the player on roll: positive counts are
+//	        his checkers, negative counts his opponent's
+//	37      checkers on the bar of the opponent (negative)
+//	38, 39  dice (0 if not rolled)
+//
+// Checkers borne off are not written; they are whatever is missing from 15.
+//
+// Example (starting position, player 0 on roll with 3-1):
+//
+//	0;0;0;0;0;Player 1;Player 2;0;0;0;1;0;0;-2;0;0;0;0;5;0;3;0;0;0;-5;5;0;0;0;-3;0;-5;0;0;0;0;2;0;3;1;
+
+// snowieFields is the number of fields in a Snowie text position.
+const snowieFields = 40
+
+// Snowie text field indices.
+const (
+	snowieMatchLength = 0
+	snowieJacoby      = 1
+	snowieTurn        = 4
+	snowieName0       = 5
+	snowieName1       = 6
+	snowieCrawford    = 7
+	snowieScore0      = 8
+	snowieScore1      = 9
+	snowieCubeValue   = 10
+	snowieCubeOwner   = 11
+	snowieBar         = 12
+	snowiePoints      = 13
+	snowieOppBar      = 37
+	snowieDie1        = 38
+	snowieDie2        = 39
+)
+
+// IsSnowieText reports whether s looks like a Snowie text position rather
+// than a position ID, which never contains a semicolon.
+func IsSnowieText(s string) bool {
+	return strings.Contains(s, ";")
+}
+
+// ParseSnowieText parses a Snowie text position. The returned state has Turn
+// set to the player on roll, with Board[1] holding his checkers; score and
+// cube owner use absolute player numbers like the rest of the engine.
+// The player names and Jacoby setting are not part of GameState and are
+// ignored.
+func ParseSnowieText(s string) (*engine.GameState, error) {
+	// Tolerate a position wrapped over several lines
+	s = strings.Join(strings.Fields(s), " ")
+	s = strings.ReplaceAll(s, "; ", ";")
+	s = strings.TrimSuffix(s, ";")
+
+	fields := strings.Split(s, ";")
+	if len(fields) != snowieFields {
+		return nil, fmt.Errorf("invalid Snowie text: expected %d fields, got %d", snowieFields, len(fields))
+	}
+
+	values := make([]int, snowieFields)
+	for i, f := range fields {
+		if i == snowieName0 || i == snowieName1 {
+			continue
+		}
+		v, err := strconv.Atoi(strings.TrimSpace(f))
+		if err != nil {
+			return nil, fmt.Errorf("invalid Snowie text: field %d: %q is not a number", i, f)
+		}
+		values[i] = v
+	}
+
+	turn := values[snowieTurn]
+	if turn != 0 && turn != 1 {
+		return nil, fmt.Errorf("invalid Snowie text: player on roll %d, want 0 or 1", turn)
+	}
+
+	gs := &engine.GameState{
+		Turn:        turn,
+		MatchLength: values[snowieMatchLength],
+		Score:       [2]int{values[snowieScore0], values[snowieScore1]},
+		CubeValue:   values[snowieCubeValue],
+		Crawford:    values[snowieCrawford] != 0,
+		Dice:        [2]int{values[snowieDie1], values[snowieDie2]},
+	}
+	if gs.MatchLength < 0 || gs.Score[0] < 0 || gs.Score[1] < 0 {
+		return nil, fmt.Errorf("invalid Snowie text: negative match length or score")
+	}
+	if gs.CubeValue == 0 {
+		gs.CubeValue = 1
+	}
+	if gs.CubeValue < 1 || gs.CubeValue&(gs.CubeValue-1) != 0 {
+		return nil, fmt.Errorf("invalid Snowie text: cube value %d is not a power of two", gs.CubeValue)
+	}
+
+	switch values[snowieCubeOwner] {
+	case 0:
+		gs.CubeOwner = -1
+	case 1:
+		gs.CubeOwner = turn
+	case -1:
+		gs.CubeOwner = 1 - turn
+	default:
+		return nil, fmt.Errorf("invalid Snowie text: cube owner %d, want -1, 0 or 1", values[snowieCubeOwner])
+	}
+
+	for _, d := range gs.Dice {
+		if d < 0 || d > 6 {
+			return nil, fmt.Errorf("invalid Snowie text: die %d out of range", d)
+		}
+	}
+	if (gs.Dice[0] == 0) != (gs.Dice[1] == 0) {
+		return nil, fmt.Errorf("invalid Snowie text: only one die rolled")
+	}
+
+	// The opponent's bar is written as a negative count, but some
+	// programs leave out the sign
+	bar, oppBar := values[snowieBar], values[snowieOppBar]
+	if oppBar < 0 {
+		oppBar = -oppBar
+	}
+	if bar < 0 {
+		return nil, fmt.Errorf("invalid Snowie text: negative bar count %d", bar)
+	}
+	gs.Board[1][24] = uint8(min(bar, 15))
+	gs.Board[0][24] = uint8(min(oppBar, 15))
+	counts := [2]int{oppBar, bar}
+
+	for i := 0; i < 24; i++ {
+		v := values[snowiePoints+i]
+		switch {
+		case v > 0:
+			gs.Board[1][i] = uint8(min(v, 15))
+			counts[1] += v
+		case v < 0:
+			gs.Board[0][23-i] = uint8(min(-v, 15))
+			counts[0] -= v
+		}
+	}
+	for side, n := range counts {
+		if n > 15 {
+			return nil, fmt.Errorf("invalid Snowie text: %d checkers for player %d, at most 15", n, side)
+		}
+	}
+
+	return gs, nil
+}
+
+// FormatSnowieText formats a game state as Snowie text. Names are written as
+// "Player 1" and "Player 2" and the Jacoby rule as off.
+func FormatSnowieText(gs *engine.GameState) string {
+	fields := make([]string, snowieFields)
+	for i := range fields {
+		fields[i] = "0"
+	}
+	set := func(i, v int) { fields[i] = strconv.Itoa(v) }
+
+	set(snowieMatchLength, gs.MatchLength)
+	set(snowieTurn, gs.Turn)
+	fields[snowieName0] = "Player 1"
+	fields[snowieName1] = "Player 2"
+	if gs.Crawford {
+		set(snowieCrawford, 1)
+	}
+	set(snowieScore0, gs.Score[0])
+	set(snowieScore1, gs.Score[1])
+
+	cube := gs.CubeValue
+	if cube < 1 {
+		cube = 1
+	}
+	set(snowieCubeValue, cube)
+	switch gs.CubeOwner {
+	case -1:
+	case gs.Turn:
+		set(snowieCubeOwner, 1)
+	default:
+		set(snowieCubeOwner, -1)
+	}
+
+	set(snowieBar, int(gs.Board[1][24]))
+	for i := 0; i < 24; i++ {
+		if n := gs.Board[1][i]; n > 0 {
+			set(snowiePoints+i, int(n))
+		} else if n := gs.Board[0][23-i]; n > 0 {
+			set(snowiePoints+i, -int(n))
+		}
+	}
+	set(snowieOppBar, -int(gs.Board[0][24]))
+	set(snowieDie1, gs.Dice[0])
+	set(snowieDie2, gs.Dice[1])
+
+	return strings.Join(fields, ";") + ";"
+}
@@ -0,0 +1,313 @@
+package match
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yourusername/bgengine/internal/fileutil"
+	"github.com/yourusername/bgengine/pkg/engine"
+)
+
+// ErrMatchNotFound is returned by a Store for an unknown match ID.
+var ErrMatchNotFound = errors.New("match not found")
+
+// StoredMatch is a match kept in a Store, with its latest analysis.
+type StoredMatch struct {
+	ID          string                `json:"id"`
+	Uploaded    time.Time             `json:"uploaded"`
+	Match       *Match                `json:"match"`
+	Analysis    *engine.MatchAnalysis `json:"analysis,omitempty"`     // nil until analyzed
+	AnalysisPly int                   `json:"analysis_ply,omitempty"` // Ply the analysis was run at
+	AnalyzedAt  time.Time             `json:"analyzed_at"`            // Zero until analyzed
+}
+
+// MatchInfo summarizes a stored match for listing.
+type MatchInfo struct {
+	ID          string
+	Player1     string
+	Player2     string
+	MatchLength int
+	Date        string     // Match.DateString
+	Ratings     [2]float64 // Match.PlayerRatings
+	Games       int
+	Uploaded    time.Time
+	Analyzed    bool
+	AnalysisPly int
+	AnalyzedAt  time.Time
+	// ErrorPerMove is each player's EPM from the analysis (zero until analyzed)
+	ErrorPerMove [2]float64
+}
+
+// Info returns the listing summary of s.
+func (s *StoredMatch) Info() MatchInfo {
+	info := MatchInfo{
+		ID:          s.ID,
+		Player1:     s.Match.Player1,
+		Player2:     s.Match.Player2,
+		MatchLength: s.Match.MatchLength,
+		Date:        s.Match.DateString(),
+		Ratings:     s.Match.PlayerRatings,
+		Games:       len(s.Match.Games),
+		Uploaded:    s.Uploaded,
+	}
+	if s.Analysis != nil {
+		info.Analyzed = true
+		info.AnalysisPly = s.AnalysisPly
+		info.AnalyzedAt = s.AnalyzedAt
+		info.ErrorPerMove = [2]float64{
+			s.Analysis.PlayerStats[0].ErrorPerMove,
+			s.Analysis.PlayerStats[1].ErrorPerMove,
+		}
+	}
+	return info
+}
+
+// Store keeps imported matches and their analyses.
+// Implementations must be safe for concurrent use.
+type Store interface {
+	// Add stores a new match and returns its ID.
+	Add(m *Match) (string, error)
+	// Get returns the match with id, or ErrMatchNotFound.
+	Get(id string) (*StoredMatch, error)
+	// SetAnalysis replaces the analysis of match id.
+	SetAnalysis(id string, analysis *engine.MatchAnalysis, ply int) error
+	// List returns up to limit matches starting at offset, most recently
+	// uploaded first, and the number of matches stored.
+	List(offset, limit int) ([]MatchInfo, int, error)
+}
+
+// Match store file format. Each match is one JSON file named after its ID,
+// holding the StoredMatch in a fileutil envelope. Version 1 files kept the
+// format and version next to the StoredMatch fields instead, and version 2
+// files kept the match date as the text the file recorded.
+const (
+	matchStoreFormat  = "bgengine-match"
+	matchStoreVersion = 3
+)
+
+var matchFileFormat = fileutil.Format{
+	Name:    matchStoreFormat,
+	Version: matchStoreVersion,
+	Migrations: map[int]fileutil.Migration{
+		// The payload of a version 1 file is the whole file, whose extra
+		// format and version fields the StoredMatch ignores
+		1: func(payload json.RawMessage) (json.RawMessage, error) { return payload, nil },
+		2: migrateMatchDate,
+	},
+}
+
+// migrateMatchDate upgrades a version 2 payload, whose match Date is the
+// text the match file recorded, to keep it as DateText and parse it into
+// Date (see Match.SetDate).
+func migrateMatchDate(payload json.RawMessage) (json.RawMessage, error) {
+	var sm map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &sm); err != nil {
+		return nil, err
+	}
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(sm["match"], &m); err != nil || m == nil {
+		return payload, err
+	}
+	var text string
+	if raw, ok := m["Date"]; ok {
+		if err := json.Unmarshal(raw, &text); err != nil {
+			return nil, fmt.Errorf("match date: %w", err)
+		}
+	}
+	var match Match
+	match.SetDate(text)
+	date, err := json.Marshal(match.Date)
+	if err != nil {
+		return nil, err
+	}
+	m["Date"] = date
+	m["DateText"], _ = json.Marshal(match.DateText)
+	if sm["match"], err = json.Marshal(m); err != nil {
+		return nil, err
+	}
+	return json.Marshal(sm)
+}
+
+// FileStore is a Store kept as one JSON file per match in a directory.
+// Summaries of every match are held in memory for listing; matches are
+// read from disk when fetched.
+type FileStore struct {
+	dir         string
+	mu          sync.RWMutex
+	index       map[string]MatchInfo
+	skipped     int
+	quarantined []string
+}
+
+// OpenFileStore opens the store in dir, creating the directory if it does not
+// exist. Files that cannot be read, or were written by a newer version, are
+// skipped and counted in Skipped. Corrupt files, such as one cut short by a
+// crash, are also renamed aside (see Quarantined) so they are not read again.
+func OpenFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create match store: %w", err)
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open match store: %w", err)
+	}
+
+	s := &FileStore{dir: dir, index: make(map[string]MatchInfo)}
+	for _, path := range paths {
+		sm, err := readMatchFile(path)
+		if errors.Is(err, fileutil.ErrCorrupt) {
+			if aside, qerr := fileutil.Quarantine(path); qerr == nil {
+				s.quarantined = append(s.quarantined, aside)
+			}
+		}
+		if err != nil || sm.ID != strings.TrimSuffix(filepath.Base(path), ".json") {
+			s.skipped++
+			continue
+		}
+		s.index[sm.ID] = sm.Info()
+	}
+	return s, nil
+}
+
+// Skipped returns the number of unreadable files found when the store was opened.
+func (s *FileStore) Skipped() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.skipped
+}
+
+// Quarantined returns the names corrupt files found when the store was
+// opened were moved to.
+func (s *FileStore) Quarantined() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]string(nil), s.quarantined...)
+}
+
+// Add implements Store.
+func (s *FileStore) Add(m *Match) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id, err := s.newID()
+	if err != nil {
+		return "", fmt.Errorf("failed to add match: %w", err)
+	}
+	sm := &StoredMatch{ID: id, Uploaded: time.Now().UTC(), Match: m}
+	if err := s.write(sm); err != nil {
+		return "", err
+	}
+	s.index[id] = sm.Info()
+	return id, nil
+}
+
+// Get implements Store.
+func (s *FileStore) Get(id string) (*StoredMatch, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.index[id]; !ok {
+		return nil, ErrMatchNotFound
+	}
+	sm, err := readMatchFile(s.path(id))
+	if err != nil {
+		return nil, fmt.Errorf("match %s: %w", id, err)
+	}
+	return sm, nil
+}
+
+// SetAnalysis implements Store.
+func (s *FileStore) SetAnalysis(id string, analysis *engine.MatchAnalysis, ply int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.index[id]; !ok {
+		return ErrMatchNotFound
+	}
+	sm, err := readMatchFile(s.path(id))
+	if err != nil {
+		return fmt.Errorf("match %s: %w", id, err)
+	}
+	sm.Analysis = analysis
+	sm.AnalysisPly = ply
+	sm.AnalyzedAt = time.Now().UTC()
+	if err := s.write(sm); err != nil {
+		return err
+	}
+	s.index[id] = sm.Info()
+	return nil
+}
+
+// List implements Store.
+func (s *FileStore) List(offset, limit int) ([]MatchInfo, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list := make([]MatchInfo, 0, len(s.index))
+	for _, info := range s.index {
+		list = append(list, info)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].Uploaded.Equal(list[j].Uploaded) {
+			return list[i].Uploaded.After(list[j].Uploaded)
+		}
+		return list[i].ID < list[j].ID
+	})
+
+	total := len(list)
+	offset = max(0, min(offset, total))
+	end := total
+	if limit > 0 {
+		end = min(offset+limit, total)
+	}
+	return list[offset:end], total, nil
+}
+
+// path returns the file of match id.
+func (s *FileStore) path(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
+
+// newID returns a random ID not yet in the store.
+func (s *FileStore) newID() (string, error) {
+	for {
+		b := make([]byte, 8)
+		if _, err := rand.Read(b); err != nil {
+			return "", err
+		}
+		id := hex.EncodeToString(b)
+		if _, ok := s.index[id]; !ok {
+			return id, nil
+		}
+	}
+}
+
+// write replaces the file of sm atomically, so a crash never leaves a match
+// half written.
+func (s *FileStore) write(sm *StoredMatch) error {
+	if err := matchFileFormat.WriteFile(s.path(sm.ID), sm); err != nil {
+		return fmt.Errorf("failed to write match store: %w", err)
+	}
+	return nil
+}
+
+// readMatchFile reads one stored match, checking its format and version.
+func readMatchFile(path string) (*StoredMatch, error) {
+	var sm StoredMatch
+	if err := matchFileFormat.ReadFile(path, &sm); err != nil {
+		return nil, err
+	}
+	if sm.Match == nil {
+		return nil, fmt.Errorf("%w: stored match has no match", fileutil.ErrCorrupt)
+	}
+	return &sm, nil
+}
@@ -3,60 +3,110 @@
 package match
 
 import (
+	"time"
+
 	"github.com/yourusername/bgengine/pkg/engine"
 )
 
 // Match represents a complete backgammon match.
 type Match struct {
 	// Match metadata
-	Player1     string   // Name of player 1 (X)
-	Player2     string   // Name of player 2 (O)
-	MatchLength int      // Match length (0 = money game)
-	Date        string   // Match date (YYYY-MM-DD format)
-	Event       string   // Event name
-	Round       string   // Round number
-	Place       string   // Location
-	Annotator   string   // Who analyzed the match
-	Comment     string   // General match comments
-	Games       []*Game  // List of games in the match
+	Player1       string         // Name of player 1 (X)
+	Player2       string         // Name of player 2 (O)
+	PlayerRatings [2]float64     // Ratings of player 1 and player 2 at the site (0 = not recorded)
+	MatchLength   int            // Match length (0 = money game)
+	Date          time.Time      // When the match was played, in UTC unless the file gives a zone (zero if not recorded or not understood)
+	DateText      string         // Date as the file records it (see SetDate)
+	Event         string         // Event name
+	EventInfo     string         // The site's identifier of the match, such as GamesGrid's match ID
+	Round         string         // Round number
+	Place         string         // Location
+	Annotator     string         // Who analyzed the match
+	Comment       string         // General match comments
+	Variant       engine.Variant // Game variant (standard or Nackgammon)
+	NoCrawford    bool           // True if the match is played without the Crawford rule
+	Tags          []Tag          // Header tags the importer does not interpret, written back on export
+	Games         []*Game        // List of games in the match
+	Warnings      []string       // Inconsistencies found on import, such as a score that does not follow from the games before it
 }
 
 // Game represents a single game within a match.
 type Game struct {
-	Number       int           // Game number (1-indexed)
-	Score1       int           // Player 1 score at start of game
-	Score2       int           // Player 2 score at start of game
-	Crawford     bool          // True if this is the Crawford game
-	InitialBoard engine.Board  // Starting position (usually standard)
-	CubeValue    int           // Initial cube value (usually 1)
-	CubeOwner    int           // Initial cube owner (-1 = centered)
-	Actions      []Action      // Sequence of game actions
-	Winner       int           // 0 = player 1, 1 = player 2, -1 = not finished
-	Points       int           // Points won (1, 2, or 3)
-	Result       GameResult    // How the game ended
+	Number       int          // Game number (1-indexed)
+	Score1       int          // Player 1 score at start of game
+	Score2       int          // Player 2 score at start of game
+	Crawford     bool         // True if this is the Crawford game
+	PostCrawford bool         // True if the Crawford game has been played
+	InitialBoard engine.Board // Starting position (usually standard)
+	CubeValue    int          // Initial cube value (usually 1)
+	CubeOwner    int          // Initial cube owner (-1 = centered)
+	Actions      []Action     // Sequence of game actions
+	Winner       int          // 0 = player 1, 1 = player 2, -1 = not finished
+	Points       int          // Points won, counting the cube
+	Result       GameResult   // How the game ended
+
+	// AutomaticDoubles is how many times the cube was doubled automatically
+	// before the first move, on equal opening rolls (money play only). The
+	// cube starts centered at Stake.
+	AutomaticDoubles int
 }
 
 // ActionType represents the type of game action.
 type ActionType int
 
 const (
-	ActionRoll       ActionType = iota // Dice roll
-	ActionMove                         // Checker move
-	ActionDouble                       // Cube double
-	ActionTake                         // Take the cube
-	ActionPass                         // Pass (decline the cube)
-	ActionResign                       // Resignation
-	ActionAcceptResign                 // Accept resignation
-	ActionRejectResign                 // Reject resignation
+	ActionRoll         ActionType = iota // Dice roll
+	ActionMove                           // Checker move
+	ActionDouble                         // Cube double
+	ActionTake                           // Take the cube
+	ActionPass                           // Pass (decline the cube)
+	ActionBeaver                         // Take and redouble, keeping the cube
+	ActionRaccoon                        // Redouble a beaver
+	ActionResign                         // Resignation
+	ActionAcceptResign                   // Accept resignation
+	ActionRejectResign                   // Reject resignation
 )
 
+// String returns the action type's name, such as "roll" or "accept_resign".
+func (t ActionType) String() string {
+	switch t {
+	case ActionRoll:
+		return "roll"
+	case ActionMove:
+		return "move"
+	case ActionDouble:
+		return "double"
+	case ActionTake:
+		return "take"
+	case ActionPass:
+		return "pass"
+	case ActionBeaver:
+		return "beaver"
+	case ActionRaccoon:
+		return "raccoon"
+	case ActionResign:
+		return "resign"
+	case ActionAcceptResign:
+		return "accept_resign"
+	case ActionRejectResign:
+		return "reject_resign"
+	default:
+		return "unknown"
+	}
+}
+
 // Action represents a single game action (roll, move, cube action).
 type Action struct {
 	Type   ActionType  // Type of action
 	Player int         // 0 = player 1, 1 = player 2
 	Dice   [2]int      // Dice values (for ActionRoll)
 	Move   engine.Move // Move made (for ActionMove)
-	Value  int         // Cube value (for ActionDouble) or resign level (for ActionResign)
+	Value  int         // Cube value (for ActionDouble, ActionBeaver, ActionRaccoon) or resign level (for ActionResign)
+
+	// Analysis of the decision, from Match.Annotate or read from an annotated
+	// SGF file (for ActionMove, or ActionRoll when the roll could not be
+	// played, and cube actions)
+	Analysis *engine.DecisionAnalysis
 }
 
 // GameResult indicates how a game ended.
@@ -71,8 +121,33 @@ const (
 	ResultResignBG                       // Resigned for backgammon
 	ResultDrop                           // Passed a double
 	ResultInProgress                     // Game not finished
+	ResultTime                           // The loser's flag fell (see Clock)
 )
 
+// String returns the result's name, such as "gammon" or "resign_single".
+func (r GameResult) String() string {
+	switch r {
+	case ResultSingle:
+		return "single"
+	case ResultGammon:
+		return "gammon"
+	case ResultBackgammon:
+		return "backgammon"
+	case ResultResignSingle:
+		return "resign_single"
+	case ResultResignGammon:
+		return "resign_gammon"
+	case ResultResignBG:
+		return "resign_backgammon"
+	case ResultDrop:
+		return "drop"
+	case ResultTime:
+		return "time"
+	default:
+		return "in_progress"
+	}
+}
+
 // NewMatch creates a new empty match.
 func NewMatch(player1, player2 string, matchLength int) *Match {
 	return &Match{
@@ -135,6 +210,24 @@ func (g *Game) AddTake(player int) {
 	})
 }
 
+// AddBeaver adds a beaver action to the game.
+func (g *Game) AddBeaver(player int, value int) {
+	g.Actions = append(g.Actions, Action{
+		Type:   ActionBeaver,
+		Player: player,
+		Value:  value,
+	})
+}
+
+// AddRaccoon adds a raccoon action to the game.
+func (g *Game) AddRaccoon(player int, value int) {
+	g.Actions = append(g.Actions, Action{
+		Type:   ActionRaccoon,
+		Player: player,
+		Value:  value,
+	})
+}
+
 // AddPass adds a pass (drop) action to the game.
 func (g *Game) AddPass(player int) {
 	g.Actions = append(g.Actions, Action{
@@ -143,3 +236,43 @@ func (g *Game) AddPass(player int) {
 	})
 }
 
+// AddAutomaticDouble records an automatic double of the centered cube
+// before the first move.
+func (g *Game) AddAutomaticDouble() {
+	g.AutomaticDoubles++
+	g.CubeValue *= 2
+}
+
+// Stake returns the value of the centered cube the game starts with: 1,
+// doubled for each automatic double.
+func (g *Game) Stake() int {
+	return 1 << g.AutomaticDoubles
+}
+
+// PointsWon returns the points the winner of the game scores for result:
+// the cube when the game ends, from Stake through the cube actions, times
+// 2 for a gammon and 3 for a backgammon. A double that is passed scores
+// the cube before it. ResultInProgress scores nothing.
+func (g *Game) PointsWon(result GameResult) int {
+	cube := g.Stake()
+	for _, a := range g.Actions {
+		switch a.Type {
+		case ActionTake:
+			cube *= 2
+		case ActionBeaver, ActionRaccoon:
+			cube = a.Value
+		case ActionPass:
+			return cube
+		}
+	}
+	switch result {
+	case ResultSingle, ResultResignSingle, ResultDrop, ResultTime:
+		return cube
+	case ResultGammon, ResultResignGammon:
+		return 2 * cube
+	case ResultBackgammon, ResultResignBG:
+		return 3 * cube
+	default:
+		return 0
+	}
+}
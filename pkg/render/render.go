@@ -0,0 +1,420 @@
+// Package render draws backgammon positions as SVG images.
+//
+// The output only depends on the position and options, with elements in a
+// fixed order, so the same position always renders to the same bytes.
+package render
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/yourusername/bgengine/pkg/engine"
+)
+
+// DefaultSize is the width in pixels of a rendered board when
+// RenderOptions.Size is 0.
+const DefaultSize = 600
+
+// MaxSize is the largest width in pixels RenderSVG accepts.
+const MaxSize = 4000
+
+// RenderOptions selects what RenderSVG draws.
+type RenderOptions struct {
+	Size         int          // Width in pixels (0 = DefaultSize); the height follows the board's aspect ratio
+	Dice         [2]int       // Dice of the player on roll (zero for none)
+	Move         *engine.Move // Move of the player on roll to draw as arrows (nil for none)
+	FromOpponent bool         // Draw the board from the side of the player not on roll
+}
+
+// Board geometry, in viewBox units. The board has 12 columns of points
+// with the bar in the middle, the cube strip on its left and the bearoff
+// tray on its right; point numbers and pip counts go above and below.
+const (
+	viewW = 510
+	viewH = 400
+
+	boardX   = 50  // Left edge of the playing area
+	boardY   = 40  // Top edge of the playing area
+	boardH   = 320 // Height of the playing area
+	pointW   = 30  // Width of a point
+	pointH   = 130 // Height of a point's triangle
+	barW     = 30  // Width of the bar
+	trayX    = 450 // Left edge of the bearoff tray
+	trayW    = 50  // Width of the bearoff tray
+	radius   = 13  // Checker radius
+	maxDrawn = 5   // Checkers drawn on a point before the rest are counted
+	cubeW    = 36  // Side of the cube
+	dieW     = 26  // Side of a die
+)
+
+// Colors of the board and of each player's checkers. Players keep their
+// color whichever side they are drawn on.
+const (
+	frameColor = "#6b4226"
+	fieldColor = "#e8dcc0"
+	darkPoint  = "#8b3a3a"
+	lightPoint = "#c9a66b"
+	arrowColor = "#d62828"
+)
+
+var (
+	checkerFill   = [2]string{"#f5f0e1", "#2b2b2b"}
+	checkerStroke = [2]string{"#222222", "#000000"}
+	checkerText   = [2]string{"#000000", "#ffffff"}
+)
+
+// RenderSVG draws state as an SVG image, with the player on roll at the
+// bottom (or the opponent with opts.FromOpponent) bearing off to the right.
+// Board[1] is the player on roll, who is player state.Turn for the cube
+// owner and the colors.
+func RenderSVG(state *engine.GameState, opts RenderOptions) ([]byte, error) {
+	size := opts.Size
+	if size == 0 {
+		size = DefaultSize
+	}
+	if size < 0 || size > MaxSize {
+		return nil, fmt.Errorf("size must be 1-%d, got %d", MaxSize, size)
+	}
+	if err := checkState(state); err != nil {
+		return nil, err
+	}
+	if err := checkDice(opts.Dice); err != nil {
+		return nil, err
+	}
+	if opts.Move != nil {
+		if err := checkMove(*opts.Move); err != nil {
+			return nil, err
+		}
+	}
+
+	r := &renderer{state: state, bottom: 1}
+	if opts.FromOpponent {
+		r.bottom = 0
+	}
+
+	fmt.Fprintf(&r.buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n",
+		size, (size*viewH+viewW/2)/viewW, viewW, viewH)
+	r.defs()
+	r.board()
+	r.pointNumbers()
+	for _, side := range []int{r.bottom, 1 - r.bottom} {
+		r.checkers(side)
+		r.bar(side)
+		r.borneOff(side)
+	}
+	r.cube()
+	r.pipCounts()
+	if opts.Dice != ([2]int{}) {
+		r.dice(opts.Dice)
+	}
+	if opts.Move != nil {
+		r.arrows(*opts.Move)
+	}
+	r.buf.WriteString("</svg>\n")
+	return r.buf.Bytes(), nil
+}
+
+// checkState checks that neither side has more than 15 checkers.
+func checkState(state *engine.GameState) error {
+	if state == nil {
+		return fmt.Errorf("no position to render")
+	}
+	for side := 0; side < 2; side++ {
+		n := 0
+		for _, c := range state.Board[side] {
+			n += int(c)
+		}
+		if n > 15 {
+			return fmt.Errorf("side %d has %d checkers", side, n)
+		}
+	}
+	if state.Turn != 0 && state.Turn != 1 {
+		return fmt.Errorf("invalid turn %d", state.Turn)
+	}
+	return nil
+}
+
+// checkDice accepts no dice or two dice of 1-6.
+func checkDice(dice [2]int) error {
+	if dice == ([2]int{}) {
+		return nil
+	}
+	if dice[0] < 1 || dice[0] > 6 || dice[1] < 1 || dice[1] > 6 {
+		return fmt.Errorf("dice must be 1-6, got %d-%d", dice[0], dice[1])
+	}
+	return nil
+}
+
+// checkMove checks that the sub-moves of m are on the board.
+func checkMove(m engine.Move) error {
+	for i := 0; i < 4 && m.From[i] >= 0; i++ {
+		if m.From[i] > 24 || m.To[i] > 23 || m.To[i] >= m.From[i] {
+			return fmt.Errorf("invalid move %s", engine.FormatMove(m))
+		}
+	}
+	return nil
+}
+
+// renderer accumulates the SVG of one position.
+type renderer struct {
+	buf    bytes.Buffer
+	state  *engine.GameState
+	bottom int // Side of state.Board drawn at the bottom
+}
+
+// player returns the player (0 or 1) whose checkers are Board[side].
+func (r *renderer) player(side int) int {
+	if side == 1 {
+		return r.state.Turn
+	}
+	return 1 - r.state.Turn
+}
+
+// point returns the column (0-11 from the left) and row (0 = top,
+// 1 = bottom) of point i (0-23) of side.
+func (r *renderer) point(side, i int) (col, row int) {
+	p := i + 1 // Point number of the bottom player
+	if side != r.bottom {
+		p = 25 - p
+	}
+	if p <= 12 {
+		return 12 - p, 1
+	}
+	return p - 13, 0
+}
+
+// columnX returns the left edge of a column.
+func columnX(col int) int {
+	x := boardX + col*pointW
+	if col >= 6 {
+		x += barW
+	}
+	return x
+}
+
+// stackY returns the center of the n-th checker (from 0) of a stack on a
+// point in row.
+func stackY(row, n int) int {
+	if row == 0 {
+		return boardY + radius + n*2*radius
+	}
+	return boardY + boardH - radius - n*2*radius
+}
+
+func (r *renderer) defs() {
+	fmt.Fprintf(&r.buf, `<defs><marker id="bg-arrow" viewBox="0 0 10 10" refX="8" refY="5" markerWidth="4" markerHeight="4" orient="auto"><path d="M0,0 L10,5 L0,10 z" fill="%s"/></marker></defs>`+"\n", arrowColor)
+}
+
+func (r *renderer) board() {
+	fmt.Fprintf(&r.buf, `<rect id="frame" x="%d" y="%d" width="%d" height="%d" fill="%s"/>`+"\n",
+		boardX-8, boardY-8, trayX+trayW-boardX+16, boardH+16, frameColor)
+	for half := 0; half < 2; half++ {
+		fmt.Fprintf(&r.buf, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s"/>`+"\n",
+			columnX(6*half), boardY, 6*pointW, boardH, fieldColor)
+	}
+	for row := 0; row < 2; row++ {
+		for col := 0; col < 12; col++ {
+			x := columnX(col)
+			base, apex := boardY, boardY+pointH
+			if row == 1 {
+				base, apex = boardY+boardH, boardY+boardH-pointH
+			}
+			fill := lightPoint
+			if (col+row)%2 == 0 {
+				fill = darkPoint
+			}
+			fmt.Fprintf(&r.buf, `<polygon points="%d,%d %d,%d %d,%d" fill="%s"/>`+"\n",
+				x, base, x+pointW, base, x+pointW/2, apex, fill)
+		}
+	}
+	fmt.Fprintf(&r.buf, `<rect id="tray" x="%d" y="%d" width="%d" height="%d" fill="%s"/>`+"\n",
+		trayX+4, boardY, trayW-8, boardH, fieldColor)
+}
+
+// pointNumbers labels the points as the bottom player counts them.
+func (r *renderer) pointNumbers() {
+	for col := 0; col < 12; col++ {
+		x := columnX(col) + pointW/2
+		fmt.Fprintf(&r.buf, `<text x="%d" y="%d" font-family="sans-serif" font-size="11" text-anchor="middle">%d</text>`+"\n",
+			x, boardY-12, col+13)
+		fmt.Fprintf(&r.buf, `<text x="%d" y="%d" font-family="sans-serif" font-size="11" text-anchor="middle">%d</text>`+"\n",
+			x, boardY+boardH+20, 12-col)
+	}
+}
+
+// checker draws a checker of player, with label on it if not empty.
+func (r *renderer) checker(player, x, y int, label string) {
+	fmt.Fprintf(&r.buf, `<circle cx="%d" cy="%d" r="%d" fill="%s" stroke="%s"/>`+"\n",
+		x, y, radius-1, checkerFill[player], checkerStroke[player])
+	if label != "" {
+		fmt.Fprintf(&r.buf, `<text x="%d" y="%d" font-family="sans-serif" font-size="12" font-weight="bold" text-anchor="middle" fill="%s">%s</text>`+"\n",
+			x, y+4, checkerText[player], label)
+	}
+}
+
+// stack draws n checkers of player from the n-th position of a stack, the
+// last drawn checker labelled with the total when not all fit.
+func (r *renderer) stack(player, x int, y func(int) int, n, fit int) {
+	for k := 0; k < min(n, fit); k++ {
+		label := ""
+		if k == fit-1 && n > fit {
+			label = fmt.Sprint(n)
+		}
+		r.checker(player, x, y(k), label)
+	}
+}
+
+// checkers draws the checkers of side on the points.
+func (r *renderer) checkers(side int) {
+	player := r.player(side)
+	for i := 0; i < 24; i++ {
+		n := int(r.state.Board[side][i])
+		if n == 0 {
+			continue
+		}
+		col, row := r.point(side, i)
+		r.stack(player, columnX(col)+pointW/2, func(k int) int { return stackY(row, k) }, n, maxDrawn)
+	}
+}
+
+// bar draws the checkers of side on the bar, the bottom player's below
+// the middle of the board.
+func (r *renderer) bar(side int) {
+	n := int(r.state.Board[side][24])
+	if n == 0 {
+		return
+	}
+	mid := boardY + boardH/2
+	y := func(k int) int { return mid + 2*radius + k*2*radius }
+	if side != r.bottom {
+		y = func(k int) int { return mid - 2*radius - k*2*radius }
+	}
+	r.stack(r.player(side), columnX(6)-barW/2, y, n, 4)
+}
+
+// borneOff draws the checkers side has borne off in the tray, the bottom
+// player's at the bottom.
+func (r *renderer) borneOff(side int) {
+	off := 15
+	for _, c := range r.state.Board[side] {
+		off -= int(c)
+	}
+	player := r.player(side)
+	for k := 0; k < off; k++ {
+		y := boardY + boardH - (k+1)*9
+		if side != r.bottom {
+			y = boardY + 1 + k*9
+		}
+		fmt.Fprintf(&r.buf, `<rect x="%d" y="%d" width="%d" height="8" fill="%s" stroke="%s"/>`+"\n",
+			trayX+8, y, trayW-16, checkerFill[player], checkerStroke[player])
+	}
+}
+
+// cube draws the cube in the middle when centered, or on its owner's side.
+// There is no cube in the Crawford game.
+func (r *renderer) cube() {
+	if r.state.Crawford {
+		return
+	}
+	value := r.state.CubeValue
+	y := boardY + boardH/2 - cubeW/2
+	switch r.state.CubeOwner {
+	case r.player(r.bottom):
+		y = boardY + boardH - cubeW
+	case r.player(1 - r.bottom):
+		y = boardY
+	default:
+		if value <= 1 {
+			value = 64
+		}
+	}
+	fmt.Fprintf(&r.buf, `<rect id="cube" x="%d" y="%d" width="%d" height="%d" rx="4" fill="#ffffff" stroke="#000000"/>`+"\n",
+		(boardX-cubeW)/2-4, y, cubeW, cubeW)
+	fmt.Fprintf(&r.buf, `<text x="%d" y="%d" font-family="sans-serif" font-size="16" font-weight="bold" text-anchor="middle">%d</text>`+"\n",
+		(boardX-8)/2, y+cubeW/2+6, value)
+}
+
+// pipCounts writes each player's pip count on their side.
+func (r *renderer) pipCounts() {
+	pips := engine.PipCount(r.state.Board)
+	for _, side := range []int{r.bottom, 1 - r.bottom} {
+		y := boardY + boardH + 36
+		if side != r.bottom {
+			y = 14
+		}
+		onRoll := ""
+		if side == 1 {
+			onRoll = ", on roll"
+		}
+		fmt.Fprintf(&r.buf, `<text x="%d" y="%d" font-family="sans-serif" font-size="12">Player %d: %d pips%s</text>`+"\n",
+			boardX, y, r.player(side), pips[side], onRoll)
+	}
+}
+
+// diePips lists the pip positions of each die face, in thirds of the die.
+var diePips = [7][][2]int{
+	1: {{1, 1}},
+	2: {{0, 0}, {2, 2}},
+	3: {{0, 0}, {1, 1}, {2, 2}},
+	4: {{0, 0}, {2, 0}, {0, 2}, {2, 2}},
+	5: {{0, 0}, {2, 0}, {1, 1}, {0, 2}, {2, 2}},
+	6: {{0, 0}, {2, 0}, {0, 1}, {2, 1}, {0, 2}, {2, 2}},
+}
+
+// dice draws the dice of the player on roll in the middle of their half of
+// the board: the right half for the bottom player, the left for the top.
+func (r *renderer) dice(dice [2]int) {
+	center := columnX(9)
+	if r.bottom != 1 {
+		center = columnX(3)
+	}
+	player := r.player(1)
+	y := boardY + boardH/2 - dieW/2
+	for k, d := range dice {
+		x := center - dieW - 4 + k*(dieW+8)
+		fmt.Fprintf(&r.buf, `<rect x="%d" y="%d" width="%d" height="%d" rx="4" fill="%s" stroke="%s"/>`+"\n",
+			x, y, dieW, dieW, checkerFill[player], checkerStroke[player])
+		for _, p := range diePips[d] {
+			fmt.Fprintf(&r.buf, `<circle cx="%d" cy="%d" r="2.5" fill="%s"/>`+"\n",
+				x+7+p[0]*6, y+7+p[1]*6, checkerText[player])
+		}
+	}
+}
+
+// arrows draws each sub-move of m by the player on roll as an arrow.
+func (r *renderer) arrows(m engine.Move) {
+	for i := 0; i < 4 && m.From[i] >= 0; i++ {
+		x1, y1 := r.anchor(int(m.From[i]))
+		x2, y2 := r.anchor(int(m.To[i]))
+		fmt.Fprintf(&r.buf, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="%s" stroke-width="3" stroke-opacity="0.8" marker-end="url(#bg-arrow)"/>`+"\n",
+			x1, y1, x2, y2, arrowColor)
+	}
+}
+
+// anchor returns where an arrow of the player on roll starts or ends for
+// point i (0-23), the bar (24) or off the board (negative).
+func (r *renderer) anchor(i int) (x, y int) {
+	low := r.bottom == 1 // The player on roll is at the bottom
+	switch {
+	case i < 0:
+		x = trayX + trayW/2
+		y = boardY + boardH/4
+		if low {
+			y = boardY + 3*boardH/4
+		}
+	case i == 24:
+		x = columnX(6) - barW/2
+		y = boardY + boardH/2 - 2*radius
+		if low {
+			y = boardY + boardH/2 + 2*radius
+		}
+	default:
+		col, row := r.point(1, i)
+		x = columnX(col) + pointW/2
+		y = boardY + pointH/2
+		if row == 1 {
+			y = boardY + boardH - pointH/2
+		}
+	}
+	return x, y
+}
@@ -0,0 +1,129 @@
+package render
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yourusername/bgengine/internal/positionid"
+	"github.com/yourusername/bgengine/pkg/engine"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// startState returns the starting position with player turn on roll.
+func startState(t *testing.T, turn int) *engine.GameState {
+	t.Helper()
+	board, err := positionid.BoardFromPositionID("4HPwATDgc/ABMA")
+	if err != nil {
+		t.Fatalf("BoardFromPositionID: %v", err)
+	}
+	return &engine.GameState{Board: engine.Board(board), Turn: turn, CubeValue: 1, CubeOwner: -1}
+}
+
+// golden compares got with testdata/name, or rewrites it with -update.
+func golden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatalf("write %s: %v", path, err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read %s: %v (run with -update to create it)", path, err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs from the golden file; run with -update if the change is intended", name)
+	}
+}
+
+func TestRenderSVGGolden(t *testing.T) {
+	move, _ := engine.ParseMove("8/5 6/5")
+	tests := []struct {
+		name  string
+		state *engine.GameState
+		opts  RenderOptions
+	}{
+		{"start.svg", startState(t, 0), RenderOptions{}},
+		{"start-31.svg", startState(t, 0), RenderOptions{Dice: [2]int{3, 1}, Move: &move, Size: 300}},
+		{"start-31-opponent.svg", startState(t, 0), RenderOptions{Dice: [2]int{3, 1}, Move: &move, FromOpponent: true}},
+	}
+	for _, tt := range tests {
+		got, err := RenderSVG(tt.state, tt.opts)
+		if err != nil {
+			t.Fatalf("%s: RenderSVG: %v", tt.name, err)
+		}
+		again, _ := RenderSVG(tt.state, tt.opts)
+		if !bytes.Equal(got, again) {
+			t.Errorf("%s: two renders differ", tt.name)
+		}
+		golden(t, tt.name, got)
+	}
+}
+
+func TestRenderSVGMirror(t *testing.T) {
+	// A bearoff with checkers on the bar, borne off and stacked past what
+	// fits on a point, and the cube owned by player 1
+	state := &engine.GameState{CubeValue: 4, CubeOwner: 1}
+	state.Board[1][0], state.Board[1][5], state.Board[1][24] = 7, 3, 1
+	state.Board[0][2], state.Board[0][17] = 2, 6
+
+	// The opponent's view of the position is the position with the
+	// opponent on roll, seen from the player on roll
+	opp, err := RenderSVG(state, RenderOptions{FromOpponent: true})
+	if err != nil {
+		t.Fatalf("RenderSVG: %v", err)
+	}
+	swapped, err := RenderSVG(state.OpponentOnRoll(), RenderOptions{})
+	if err != nil {
+		t.Fatalf("RenderSVG: %v", err)
+	}
+	// Only the "on roll" marker may differ
+	strip := func(b []byte) string { return strings.ReplaceAll(string(b), ", on roll", "") }
+	if strip(opp) != strip(swapped) {
+		t.Error("board drawn from the opponent's side differs from the swapped position")
+	}
+
+	svg := string(opp)
+	for _, want := range []string{
+		`fill="#000000">7</text>`,                                         // Player 0's stack of 7 on their 1 point
+		`<rect id="cube" x="3" y="324"`,                                   // Player 1's cube, at the bottom
+		`font-size="16" font-weight="bold" text-anchor="middle">4</text>`, // Its value
+		"Player 1: 114 pips",                                              // At the bottom
+		"Player 0: 50 pips, on roll",                                      // At the top
+	} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("render is missing %q", want)
+		}
+	}
+	if n := strings.Count(svg, "<circle"); n != 5+3+1+2+5 {
+		t.Errorf("drew %d checkers, want 16", n)
+	}
+	if n := strings.Count(svg, `height="8"`); n != 4+7 {
+		t.Errorf("drew %d borne off checkers, want 11", n)
+	}
+}
+
+func TestRenderSVGErrors(t *testing.T) {
+	state := startState(t, 0)
+	bad := engine.Move{From: [4]int8{3, -1, -1, -1}, To: [4]int8{5, -1, -1, -1}}
+	crowded := startState(t, 0)
+	crowded.Board[1][0] = 5
+
+	for name, call := range map[string]func() error{
+		"size":  func() error { _, err := RenderSVG(state, RenderOptions{Size: MaxSize + 1}); return err },
+		"dice":  func() error { _, err := RenderSVG(state, RenderOptions{Dice: [2]int{7, 1}}); return err },
+		"move":  func() error { _, err := RenderSVG(state, RenderOptions{Move: &bad}); return err },
+		"board": func() error { _, err := RenderSVG(crowded, RenderOptions{}); return err },
+		"nil":   func() error { _, err := RenderSVG(nil, RenderOptions{}); return err },
+	} {
+		if call() == nil {
+			t.Errorf("%s: RenderSVG accepted invalid input", name)
+		}
+	}
+}
@@ -0,0 +1,361 @@
+// Package wire encodes the binary frames of the WebSocket API, a compact
+// alternative to its JSON messages for clients that send many small
+// evaluate, move and cube requests, such as bots playing many games at
+// once.
+//
+// A client asks for binary frames by offering Subprotocol when it opens the
+// connection. JSON text messages keep working on the connection; each
+// binary message is one frame. Frames are little-endian and laid out as
+// follows, where the body depends on the kind:
+//
+//	frame:           kind u8 | id u32 | body
+//	position:        key [7]u32 | match length u8 | score [2]u8 | cube value u16 | cube owner i8 | flags u8
+//	evaluate, cube:  position | ply i8
+//	move:            position | ply i8 | dice [2]u8 | num moves u8
+//	evaluate result: probabilities [5]f32 | equity f32 | cubeful equity f32
+//	move result:     count u8 | count × (from [4]i8 | to [4]i8 | probabilities [5]f32 | equity f32)
+//	cube result:     decision u8 | probabilities [5]f32 | no double f32 | double/take f32 | double/pass f32
+//	error:           code length u8 | code | message length u16 | message
+//
+// Encoding appends to a caller's buffer and decoding fills a caller's
+// Request or Response, so that a client or server that reuses them handles
+// a frame without allocating.
+package wire
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/yourusername/bgengine/internal/positionid"
+)
+
+// Subprotocol is the WebSocket subprotocol a client offers to send and
+// receive binary frames.
+const Subprotocol = "bgengine.binary.v1"
+
+// Kind is the kind of a frame, its first byte.
+type Kind uint8
+
+// Request kinds, and the kinds of the responses to them.
+const (
+	KindEvaluate Kind = 0x01
+	KindMove     Kind = 0x02
+	KindCube     Kind = 0x03
+
+	KindEvaluateResult Kind = 0x81
+	KindMoveResult     Kind = 0x82
+	KindCubeResult     Kind = 0x83
+	KindError          Kind = 0xff
+)
+
+// String returns the name of k as the JSON messages call it.
+func (k Kind) String() string {
+	switch k {
+	case KindEvaluate:
+		return "evaluate"
+	case KindMove:
+		return "move"
+	case KindCube:
+		return "cube"
+	case KindEvaluateResult, KindMoveResult, KindCubeResult:
+		return "result"
+	case KindError:
+		return "error"
+	}
+	return fmt.Sprintf("Kind(%#x)", uint8(k))
+}
+
+// Position flags.
+const (
+	FlagCrawford uint8 = 1 << iota // The game is the Crawford game
+	FlagOpponent                   // Analyze for the opponent, with the opponent on roll
+)
+
+// DefaultPly asks for the server's default ply.
+const DefaultPly int8 = -1
+
+// Frame sizes in bytes.
+const (
+	headerSize    = 1 + 4
+	positionSize  = 7*4 + 1 + 2 + 2 + 1 + 1
+	evaluateSize  = headerSize + positionSize + 1
+	moveSize      = evaluateSize + 2 + 1
+	probsSize     = 5 * 4
+	moveEntrySize = 4 + 4 + probsSize + 4
+)
+
+// MaxMoves is the most moves a move result carries.
+const MaxMoves = math.MaxUint8
+
+// ErrMalformed is wrapped by the errors of frames that cannot be decoded.
+var ErrMalformed = errors.New("wire: malformed frame")
+
+// Position is the position of a request: the board as a position key, with
+// the match and cube state.
+type Position struct {
+	Key         [7]uint32 // Position key of the board, the player on roll first (see KeyFromPositionID)
+	MatchLength uint8     // 0 for a money game
+	Score       [2]uint8  // Match score of the player on roll and the opponent
+	CubeValue   uint16    // 0 or 1 for a cube at 1
+	CubeOwner   int8      // -1 centered, 0 the player on roll, 1 the opponent
+	Flags       uint8     // FlagCrawford, FlagOpponent
+}
+
+// Request is an evaluate, move or cube request.
+type Request struct {
+	Kind     Kind
+	ID       uint32 // Echoed in the response
+	Position Position
+	Ply      int8     // Evaluation depth, or DefaultPly
+	Dice     [2]uint8 // Move requests: the roll
+	NumMoves uint8    // Move requests: the most moves to return, 0 for all
+}
+
+// Probabilities are the cubeless outcome probabilities of the player on
+// roll: win, win gammon, win backgammon, lose gammon and lose backgammon,
+// as fractions.
+type Probabilities [5]float32
+
+// Move is one move of a move result.
+type Move struct {
+	From, To      [4]int8 // Checker moves as engine.Move numbers them, -1 for unused
+	Probabilities Probabilities
+	Equity        float32 // Cubeless equity after the move
+}
+
+// Response answers a request with the same ID. The fields set depend on
+// the kind.
+type Response struct {
+	Kind Kind
+	ID   uint32
+
+	Probabilities Probabilities // Evaluate and cube results
+	Equity        float32       // Evaluate results: cubeless equity
+	CubefulEquity float32       // Evaluate results: cubeful equity with the cube as it is
+
+	Moves []Move // Move results, best first
+
+	Decision                         uint8   // Cube results: the decision, numbered as engine.CubeDecisionType
+	NoDouble, DoubleTake, DoublePass float32 // Cube results: the equities of the cube actions
+
+	Code    string // Errors: the error code, as in the JSON API
+	Message string // Errors: the error message
+}
+
+// KeyFromPositionID returns the position key of a gnubg position ID, for a
+// request's Position.Key.
+func KeyFromPositionID(id string) ([7]uint32, error) {
+	posID, _ := positionid.SplitGnubgID(id)
+	board, err := positionid.BoardFromPositionID(posID)
+	if err != nil {
+		return [7]uint32{}, err
+	}
+	return positionid.MakePositionKey(board).Data, nil
+}
+
+// AppendRequest appends the frame of r to b.
+func AppendRequest(b []byte, r *Request) []byte {
+	b = appendHeader(b, r.Kind, r.ID)
+	b = appendPosition(b, &r.Position)
+	b = append(b, byte(r.Ply))
+	if r.Kind == KindMove {
+		b = append(b, r.Dice[0], r.Dice[1], r.NumMoves)
+	}
+	return b
+}
+
+// DecodeRequest decodes a request frame into r. It returns an error
+// wrapping ErrMalformed if the frame is not a whole evaluate, move or cube
+// request; r then has the frame's kind and ID if it is long enough to
+// carry them.
+func DecodeRequest(b []byte, r *Request) error {
+	*r = Request{}
+	if len(b) < headerSize {
+		return malformed("request of %d bytes", len(b))
+	}
+	r.Kind, r.ID = Kind(b[0]), binary.LittleEndian.Uint32(b[1:])
+	want := evaluateSize
+	switch r.Kind {
+	case KindEvaluate, KindCube:
+	case KindMove:
+		want = moveSize
+	default:
+		return malformed("unknown request kind %#x", b[0])
+	}
+	if len(b) != want {
+		return malformed("%s request of %d bytes, want %d", r.Kind, len(b), want)
+	}
+	decodePosition(b[headerSize:], &r.Position)
+	b = b[headerSize+positionSize:]
+	r.Ply = int8(b[0])
+	if r.Kind == KindMove {
+		r.Dice = [2]uint8{b[1], b[2]}
+		r.NumMoves = b[3]
+	}
+	return nil
+}
+
+// AppendResponse appends the frame of r to b. A move result carries at
+// most MaxMoves moves, and an error at most 255 bytes of code and 65535 of
+// message; the rest is cut off.
+func AppendResponse(b []byte, r *Response) []byte {
+	b = appendHeader(b, r.Kind, r.ID)
+	switch r.Kind {
+	case KindEvaluateResult:
+		b = appendProbabilities(b, &r.Probabilities)
+		b = appendFloat(b, r.Equity)
+		b = appendFloat(b, r.CubefulEquity)
+	case KindMoveResult:
+		moves := r.Moves
+		if len(moves) > MaxMoves {
+			moves = moves[:MaxMoves]
+		}
+		b = append(b, byte(len(moves)))
+		for i := range moves {
+			m := &moves[i]
+			for _, p := range m.From {
+				b = append(b, byte(p))
+			}
+			for _, p := range m.To {
+				b = append(b, byte(p))
+			}
+			b = appendProbabilities(b, &m.Probabilities)
+			b = appendFloat(b, m.Equity)
+		}
+	case KindCubeResult:
+		b = append(b, r.Decision)
+		b = appendProbabilities(b, &r.Probabilities)
+		b = appendFloat(b, r.NoDouble)
+		b = appendFloat(b, r.DoubleTake)
+		b = appendFloat(b, r.DoublePass)
+	case KindError:
+		code, msg := r.Code, r.Message
+		if len(code) > math.MaxUint8 {
+			code = code[:math.MaxUint8]
+		}
+		if len(msg) > math.MaxUint16 {
+			msg = msg[:math.MaxUint16]
+		}
+		b = append(b, byte(len(code)))
+		b = append(b, code...)
+		b = binary.LittleEndian.AppendUint16(b, uint16(len(msg)))
+		b = append(b, msg...)
+	}
+	return b
+}
+
+// DecodeResponse decodes a response frame into r, reusing the capacity of
+// r.Moves. It returns an error wrapping ErrMalformed if the frame is not a
+// whole response.
+func DecodeResponse(b []byte, r *Response) error {
+	moves := r.Moves[:0]
+	*r = Response{}
+	if len(b) < headerSize {
+		return malformed("response of %d bytes", len(b))
+	}
+	r.Kind, r.ID = Kind(b[0]), binary.LittleEndian.Uint32(b[1:])
+	body := b[headerSize:]
+	switch r.Kind {
+	case KindEvaluateResult:
+		if len(body) != probsSize+8 {
+			return malformed("evaluate result of %d bytes, want %d", len(b), headerSize+probsSize+8)
+		}
+		body = decodeProbabilities(body, &r.Probabilities)
+		r.Equity = decodeFloat(body)
+		r.CubefulEquity = decodeFloat(body[4:])
+	case KindMoveResult:
+		if len(body) < 1 || len(body) != 1+int(body[0])*moveEntrySize {
+			return malformed("move result of %d bytes", len(b))
+		}
+		n := int(body[0])
+		body = body[1:]
+		for i := 0; i < n; i++ {
+			var m Move
+			for j := range m.From {
+				m.From[j] = int8(body[j])
+				m.To[j] = int8(body[4+j])
+			}
+			body = decodeProbabilities(body[8:], &m.Probabilities)
+			m.Equity = decodeFloat(body)
+			body = body[4:]
+			moves = append(moves, m)
+		}
+		r.Moves = moves
+	case KindCubeResult:
+		if len(body) != 1+probsSize+12 {
+			return malformed("cube result of %d bytes, want %d", len(b), headerSize+1+probsSize+12)
+		}
+		r.Decision = body[0]
+		body = decodeProbabilities(body[1:], &r.Probabilities)
+		r.NoDouble = decodeFloat(body)
+		r.DoubleTake = decodeFloat(body[4:])
+		r.DoublePass = decodeFloat(body[8:])
+	case KindError:
+		if len(body) < 1 || len(body) < 1+int(body[0])+2 {
+			return malformed("error of %d bytes", len(b))
+		}
+		code := body[1 : 1+int(body[0])]
+		body = body[1+len(code):]
+		n := int(binary.LittleEndian.Uint16(body))
+		if len(body) != 2+n {
+			return malformed("error of %d bytes", len(b))
+		}
+		r.Code, r.Message = string(code), string(body[2:])
+	default:
+		return malformed("unknown response kind %#x", b[0])
+	}
+	return nil
+}
+
+func malformed(format string, args ...any) error {
+	return fmt.Errorf("%w: "+format, append([]any{ErrMalformed}, args...)...)
+}
+
+func appendHeader(b []byte, k Kind, id uint32) []byte {
+	b = append(b, byte(k))
+	return binary.LittleEndian.AppendUint32(b, id)
+}
+
+func appendPosition(b []byte, p *Position) []byte {
+	for _, w := range p.Key {
+		b = binary.LittleEndian.AppendUint32(b, w)
+	}
+	b = append(b, p.MatchLength, p.Score[0], p.Score[1])
+	b = binary.LittleEndian.AppendUint16(b, p.CubeValue)
+	return append(b, byte(p.CubeOwner), p.Flags)
+}
+
+func decodePosition(b []byte, p *Position) {
+	for i := range p.Key {
+		p.Key[i] = binary.LittleEndian.Uint32(b[4*i:])
+	}
+	b = b[4*len(p.Key):]
+	p.MatchLength, p.Score = b[0], [2]uint8{b[1], b[2]}
+	p.CubeValue = binary.LittleEndian.Uint16(b[3:])
+	p.CubeOwner, p.Flags = int8(b[5]), b[6]
+}
+
+func appendFloat(b []byte, f float32) []byte {
+	return binary.LittleEndian.AppendUint32(b, math.Float32bits(f))
+}
+
+func decodeFloat(b []byte) float32 {
+	return math.Float32frombits(binary.LittleEndian.Uint32(b))
+}
+
+func appendProbabilities(b []byte, p *Probabilities) []byte {
+	for _, f := range p {
+		b = appendFloat(b, f)
+	}
+	return b
+}
+
+// decodeProbabilities decodes p from the start of b and returns the rest.
+func decodeProbabilities(b []byte, p *Probabilities) []byte {
+	for i := range p {
+		p[i] = decodeFloat(b[4*i:])
+	}
+	return b[probsSize:]
+}
@@ -0,0 +1,195 @@
+package wire
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/yourusername/bgengine/internal/positionid"
+)
+
+func testRequests() []Request {
+	key, _ := KeyFromPositionID("4HPwATDgc/ABMA")
+	pos := Position{Key: key, MatchLength: 7, Score: [2]uint8{3, 6}, CubeValue: 2, CubeOwner: 1, Flags: FlagCrawford}
+	return []Request{
+		{Kind: KindEvaluate, ID: 1, Position: pos, Ply: DefaultPly},
+		{Kind: KindEvaluate, ID: 0xffffffff, Position: Position{Key: key, CubeOwner: -1, Flags: FlagOpponent}, Ply: 2},
+		{Kind: KindMove, ID: 42, Position: pos, Ply: 1, Dice: [2]uint8{6, 4}, NumMoves: 5},
+		{Kind: KindMove, ID: 7, Position: Position{CubeValue: 0xffff}, Ply: DefaultPly, Dice: [2]uint8{1, 1}},
+		{Kind: KindCube, ID: 3, Position: pos, Ply: 0},
+	}
+}
+
+func testResponses() []Response {
+	probs := Probabilities{0.55, 0.15, 0.01, 0.12, 0.005}
+	moves := make([]Move, MaxMoves)
+	for i := range moves {
+		moves[i] = Move{
+			From:          [4]int8{24, 12, -1, -1},
+			To:            [4]int8{20, -3, -1, -1},
+			Probabilities: probs,
+			Equity:        -float32(i) / 100,
+		}
+	}
+	return []Response{
+		{Kind: KindEvaluateResult, ID: 1, Probabilities: probs, Equity: 0.123, CubefulEquity: 0.2},
+		{Kind: KindMoveResult, ID: 2, Moves: moves[:3]},
+		{Kind: KindMoveResult, ID: 3, Moves: moves},
+		{Kind: KindMoveResult, ID: 4, Moves: []Move{}},
+		{Kind: KindCubeResult, ID: 5, Decision: 4, Probabilities: probs, NoDouble: 0.9, DoubleTake: 1.2, DoublePass: 1},
+		{Kind: KindError, ID: 6, Code: "INVALID_DICE", Message: "invalid dice"},
+		{Kind: KindError, ID: 7},
+	}
+}
+
+func TestRequestRoundTrip(t *testing.T) {
+	for _, want := range testRequests() {
+		b := AppendRequest(nil, &want)
+		var got Request
+		if err := DecodeRequest(b, &got); err != nil {
+			t.Fatalf("DecodeRequest(%+v): %v", want, err)
+		}
+		if got != want {
+			t.Errorf("round trip of %+v gave %+v", want, got)
+		}
+
+		// Every shorter or longer frame is malformed
+		for n := 0; n < len(b); n++ {
+			if err := DecodeRequest(b[:n], &got); !errors.Is(err, ErrMalformed) {
+				t.Errorf("%s request cut to %d bytes: error %v", want.Kind, n, err)
+			}
+		}
+		if err := DecodeRequest(append(b, 0), &got); !errors.Is(err, ErrMalformed) {
+			t.Errorf("%s request with a trailing byte: error %v", want.Kind, err)
+		}
+	}
+
+	for _, k := range []Kind{0, KindEvaluateResult, KindError, 0x42} {
+		var r Request
+		b := AppendRequest(nil, &Request{Kind: k, ID: 9})
+		if err := DecodeRequest(b, &r); !errors.Is(err, ErrMalformed) || r.ID != 9 {
+			t.Errorf("request of kind %s: error %v, ID %d", k, err, r.ID)
+		}
+	}
+}
+
+func TestResponseRoundTrip(t *testing.T) {
+	var got Response
+	for _, want := range testResponses() {
+		b := AppendResponse(nil, &want)
+		if err := DecodeResponse(b, &got); err != nil {
+			t.Fatalf("DecodeResponse(%s %d): %v", want.Kind, want.ID, err)
+		}
+		if want.Kind == KindMoveResult && len(want.Moves) == 0 {
+			want.Moves = got.Moves[:0]
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("round trip of %+v gave %+v", want, got)
+		}
+
+		for n := 0; n < len(b); n++ {
+			if err := DecodeResponse(b[:n], &got); !errors.Is(err, ErrMalformed) {
+				t.Errorf("%s %d cut to %d bytes: error %v", want.Kind, want.ID, n, err)
+			}
+		}
+		if err := DecodeResponse(append(b, 0), &got); !errors.Is(err, ErrMalformed) {
+			t.Errorf("%s %d with a trailing byte: error %v", want.Kind, want.ID, err)
+		}
+	}
+
+	// Long moves lists and error texts are cut to what the frame carries
+	long := Response{Kind: KindMoveResult, Moves: make([]Move, MaxMoves+1)}
+	if err := DecodeResponse(AppendResponse(nil, &long), &got); err != nil || len(got.Moves) != MaxMoves {
+		t.Errorf("%d moves: error %v, decoded %d", len(long.Moves), err, len(got.Moves))
+	}
+	long = Response{Kind: KindError, Code: strings.Repeat("C", 300), Message: strings.Repeat("m", 70000)}
+	if err := DecodeResponse(AppendResponse(nil, &long), &got); err != nil || len(got.Code) != 255 || len(got.Message) != 65535 {
+		t.Errorf("long error: error %v, code %d and message %d bytes", err, len(got.Code), len(got.Message))
+	}
+}
+
+func TestDecodeResponseReusesMoves(t *testing.T) {
+	b := AppendResponse(nil, &testResponses()[1])
+	var r Response
+	if err := DecodeResponse(b, &r); err != nil {
+		t.Fatal(err)
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		if err := DecodeResponse(b, &r); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("DecodeResponse of a move result allocates %v times, want 0", allocs)
+	}
+
+	req := testRequests()[2]
+	buf := make([]byte, 0, 64)
+	allocs = testing.AllocsPerRun(100, func() {
+		buf = AppendRequest(buf[:0], &req)
+		if err := DecodeRequest(buf, &req); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("a request round trip allocates %v times, want 0", allocs)
+	}
+}
+
+func TestKeyFromPositionID(t *testing.T) {
+	const id = "4HPwATDgc/ABMA"
+	key, err := KeyFromPositionID(id + ":cAkAAAAAAAAA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	board := positionid.BoardFromKey(positionid.PositionKey{Data: key})
+	if got := positionid.PositionID(board); got != id {
+		t.Errorf("key decodes to %s, want %s", got, id)
+	}
+	if _, err := KeyFromPositionID("not a position"); err == nil {
+		t.Error("invalid position ID accepted")
+	}
+}
+
+func FuzzDecodeRequest(f *testing.F) {
+	for _, r := range testRequests() {
+		f.Add(AppendRequest(nil, &r))
+	}
+	f.Add([]byte{})
+	f.Add([]byte{byte(KindMove), 1, 2})
+	f.Fuzz(func(t *testing.T, b []byte) {
+		var r Request
+		if err := DecodeRequest(b, &r); err != nil {
+			if !errors.Is(err, ErrMalformed) {
+				t.Fatalf("DecodeRequest(%x) error %v does not wrap ErrMalformed", b, err)
+			}
+			return
+		}
+		if again := AppendRequest(nil, &r); !bytes.Equal(again, b) {
+			t.Fatalf("DecodeRequest(%x) re-encodes as %x", b, again)
+		}
+	})
+}
+
+func FuzzDecodeResponse(f *testing.F) {
+	for _, r := range testResponses() {
+		f.Add(AppendResponse(nil, &r))
+	}
+	f.Add([]byte{})
+	f.Add([]byte{byte(KindMoveResult), 0, 0, 0, 0, 9})
+	f.Add([]byte{byte(KindError), 0, 0, 0, 0, 200, 1})
+	f.Fuzz(func(t *testing.T, b []byte) {
+		var r Response
+		if err := DecodeResponse(b, &r); err != nil {
+			if !errors.Is(err, ErrMalformed) {
+				t.Fatalf("DecodeResponse(%x) error %v does not wrap ErrMalformed", b, err)
+			}
+			return
+		}
+		if again := AppendResponse(nil, &r); !bytes.Equal(again, b) {
+			t.Fatalf("DecodeResponse(%x) re-encodes as %x", b, again)
+		}
+	})
+}